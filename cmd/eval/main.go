@@ -0,0 +1,157 @@
+// eval replays golden conversations against the current agent loop and model, and scores tool
+// choice, answer similarity and latency. Scenarios are YAML or JSON files with mocked tools;
+// recorded threads are read from the database and replayed with the tool results they had. Each
+// scenario runs against a throwaway database, so it can run while HattieBot is up. Exits 1 when
+// any scenario fails, so it can gate a deploy.
+// Usage: HATTIEBOT_CONFIG_DIR=/data eval [-scenarios file|dir] [-thread id,...] [-soul file] [-model id] [-json] [-v]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/eval"
+	"github.com/hattiebot/hattiebot/internal/llmrouter"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func main() {
+	scenarios := flag.String("scenarios", "", "scenario file, or directory of .yaml/.yml/.json scenarios")
+	threads := flag.String("thread", "", "comma-separated recorded threads to replay")
+	soulPath := flag.String("soul", "", "use this file instead of SOUL.md (a candidate identity)")
+	model := flag.String("model", "", "model to evaluate instead of the configured one (bypasses llm_routing.json)")
+	asJSON := flag.Bool("json", false, "print results as JSON")
+	verbose := flag.Bool("v", false, "show the agent's log output")
+	flag.Parse()
+	if *scenarios == "" && *threads == "" {
+		fmt.Fprintf(os.Stderr, "usage: eval [-scenarios file|dir] [-thread id,...] [-soul file] [-model id] [-json] [-v]\n")
+		os.Exit(1)
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	cfg := config.New("")
+	if cf, _ := store.LoadConfigFile(cfg.ConfigDir); cf != nil {
+		cfg.OpenRouterAPIKey, cfg.Model, cfg.AgentName = cf.OpenRouterAPIKey, cf.Model, cf.AgentName
+	}
+	if cfg.OpenRouterAPIKey == "" {
+		cfg.OpenRouterAPIKey = os.Getenv("OPENROUTER_API_KEY")
+	}
+	if cfg.Model == "" {
+		cfg.Model = os.Getenv("HATTIEBOT_MODEL")
+	}
+	if cfg.OpenRouterBaseURL != "" {
+		openrouter.BaseURL = strings.TrimRight(cfg.OpenRouterBaseURL, "/")
+	}
+	ctx := context.Background()
+
+	var all []*eval.Scenario
+	if *scenarios != "" {
+		loaded, err := eval.Load(*scenarios)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load scenarios: %v\n", err)
+			os.Exit(1)
+		}
+		all = append(all, loaded...)
+	}
+	if *threads != "" {
+		db, err := store.Open(ctx, cfg.DBPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+			os.Exit(1)
+		}
+		for _, id := range strings.Split(*threads, ",") {
+			s, err := eval.FromThread(ctx, db, strings.TrimSpace(id))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "thread %s: %v\n", id, err)
+				os.Exit(1)
+			}
+			all = append(all, s)
+		}
+		db.Close()
+	}
+
+	runner := &eval.Runner{Client: buildClient(cfg, *model), Config: cfg}
+	if *model != "" {
+		cfg.Model = *model
+	}
+	if *soulPath != "" {
+		soul, err := os.ReadFile(*soulPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read soul: %v\n", err)
+			os.Exit(1)
+		}
+		runner.Soul = string(soul)
+	}
+
+	var results []eval.Result
+	failed := 0
+	for _, s := range all {
+		r := runner.Run(ctx, s)
+		results = append(results, r)
+		if !r.Passed {
+			failed++
+		}
+		if !*asJSON {
+			printResult(r)
+		}
+	}
+	if *asJSON {
+		b, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(b))
+	} else {
+		fmt.Printf("\n%d of %d scenarios passed (model %s)\n", len(results)-failed, len(results), cfg.Model)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// buildClient builds the model client as HattieBot does: llm_routing.json when it has a default
+// route, else OpenRouter with the configured model. A model given on the command line is used
+// directly.
+func buildClient(cfg *config.Config, model string) core.LLMClient {
+	if model != "" {
+		return openrouter.NewClient(cfg.OpenRouterAPIKey, model, cfg.ConfigDir)
+	}
+	routingCfg, _ := store.LoadLLMRouting(cfg.ConfigDir)
+	if routingCfg != nil && routingCfg.HasDefaultRoute() {
+		bootstrap := openrouter.NewClient(cfg.OpenRouterAPIKey, cfg.Model, cfg.ConfigDir)
+		return llmrouter.NewRouterClient(routingCfg, bootstrap, cfg.ConfigDir, nil)
+	}
+	return openrouter.NewClient(cfg.OpenRouterAPIKey, cfg.Model, cfg.ConfigDir)
+}
+
+func printResult(r eval.Result) {
+	status := "PASS"
+	if !r.Passed {
+		status = "FAIL"
+	}
+	fmt.Printf("%s  %s  score %.2f  %s\n", status, r.Scenario, r.Score, r.Latency.Round(time.Millisecond))
+	if r.Error != "" {
+		fmt.Printf("      error: %s\n", r.Error)
+	}
+	for i, t := range r.Turns {
+		line := fmt.Sprintf("  %d. score %.2f", i+1, t.Score)
+		if t.ToolScore != nil {
+			line += fmt.Sprintf("  tools %.2f [%s]", *t.ToolScore, strings.Join(t.Tools, ", "))
+		}
+		if t.AnswerScore != nil {
+			line += fmt.Sprintf("  answer %.2f", *t.AnswerScore)
+		}
+		fmt.Printf("%s  %s\n", line, t.Latency.Round(time.Millisecond))
+		for _, f := range t.Failures {
+			fmt.Printf("      %s\n", f)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// export dumps a conversation thread, or all history for a user, to Markdown or JSONL (the chat
+// fine-tuning format), to stdout, a file, or Nextcloud Files. It only reads the database, so it can
+// run while HattieBot is up.
+// Usage: HATTIEBOT_CONFIG_DIR=/data export (-thread id | -user id) [-format markdown|jsonl] [-include-tools] [-o file | -nextcloud path]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/convexport"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools/nextcloud"
+)
+
+func main() {
+	thread := flag.String("thread", "", "thread to export")
+	user := flag.String("user", "", "export every thread this user sent messages in")
+	format := flag.String("format", convexport.FormatMarkdown, "markdown or jsonl")
+	includeTools := flag.Bool("include-tools", false, "include tool calls and tool results")
+	outPath := flag.String("o", "", "write to this file instead of stdout")
+	ncPath := flag.String("nextcloud", "", "upload to this Nextcloud Files path; end with / to use a generated file name")
+	flag.Parse()
+	if (*thread == "") == (*user == "") {
+		fmt.Fprintf(os.Stderr, "usage: export (-thread id | -user id) [-format markdown|jsonl] [-include-tools] [-o file | -nextcloud path]\n")
+		os.Exit(1)
+	}
+	cfg := config.New("")
+	ctx := context.Background()
+	db, err := store.Open(ctx, cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	opts := convexport.Options{ThreadID: *thread, UserID: *user, Format: *format, IncludeTools: *includeTools}
+	var buf strings.Builder
+	stats, err := convexport.Export(ctx, db, opts, &buf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		os.Exit(1)
+	}
+	switch {
+	case *ncPath != "":
+		dest := *ncPath
+		if strings.HasSuffix(dest, "/") {
+			dest += convexport.FileName(opts, time.Now())
+		}
+		if err := nextcloud.WriteNextcloudFile(cfg, dest, buf.String()); err != nil {
+			fmt.Fprintf(os.Stderr, "upload: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "exported %d threads (%d messages) to Nextcloud %s\n", stats.Threads, stats.Messages, dest)
+	case *outPath != "":
+		if err := os.WriteFile(*outPath, []byte(buf.String()), 0600); err != nil {
+			fmt.Fprintf(os.Stderr, "write: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "exported %d threads (%d messages) to %s\n", stats.Threads, stats.Messages, *outPath)
+	default:
+		fmt.Print(buf.String())
+	}
+}
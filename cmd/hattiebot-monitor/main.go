@@ -0,0 +1,52 @@
+// hattiebot-monitor is a live view of a running HattieBot, like htop: turns in progress and their
+// tool calls, queue depth, scheduler countdowns, recent tool calls and filterable logs. It reads the
+// bot's monitor socket (HATTIEBOT_MONITOR_SOCKET), so it runs next to the bot, e.g. with docker exec.
+// Usage: HATTIEBOT_CONFIG_DIR=/data hattiebot-monitor [-socket path] [-interval 1s] [-level error] [-component agent] [-search text] [-width n]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/monitor"
+)
+
+func main() {
+	cfg := config.New("")
+	socket := flag.String("socket", cfg.MonitorSocket, "the bot's monitor socket")
+	interval := flag.Duration("interval", time.Second, "refresh interval")
+	level := flag.String("level", "", "show only logs of this level (error, warn, info)")
+	component := flag.String("component", "", "show only logs of this component")
+	search := flag.String("search", "", "show only logs containing this text")
+	limit := flag.Int("logs", 30, "log entries to show")
+	width := flag.Int("width", 0, "screen width (default $COLUMNS, else 120)")
+	flag.Parse()
+	if *socket == "" || *socket == "off" {
+		fmt.Fprintln(os.Stderr, "the monitor socket is off; set HATTIEBOT_MONITOR_SOCKET or -socket")
+		os.Exit(1)
+	}
+	if *interval < 100*time.Millisecond {
+		*interval = 100 * time.Millisecond
+	}
+	if *width <= 0 {
+		*width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+		if *width <= 0 {
+			*width = 120
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	f := monitor.Filter{Level: *level, Component: *component, Search: *search, Limit: *limit}
+	if err := monitor.Run(ctx, *socket, os.Stdin, os.Stdout, *interval, f, *width); err != nil {
+		fmt.Fprintf(os.Stderr, "monitor: %v\n", err)
+		os.Exit(1)
+	}
+}
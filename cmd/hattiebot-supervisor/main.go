@@ -0,0 +1,56 @@
+// hattiebot-supervisor runs HattieBot and installs the self-updates it builds (self_update tool):
+// an approved staged binary replaces the running one on SIGUSR1 or at start, and is rolled back
+// when it crash-loops during its probation period. Arguments are passed to the bot.
+// Usage: HATTIEBOT_CONFIG_DIR=/data hattiebot-supervisor [hattiebot args...]
+// Environment: HATTIEBOT_SUPERVISOR_BINARY (default /usr/local/bin/hattiebot),
+// HATTIEBOT_SUPERVISOR_PROBATION (default 2m), HATTIEBOT_SUPERVISOR_MAX_CRASHES (default 2).
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/selfupdate"
+)
+
+func main() {
+	cfg := config.New("")
+	s := &selfupdate.Supervisor{
+		Dir:         selfupdate.DirFor(cfg.ConfigDir),
+		ImageBinary: os.Getenv("HATTIEBOT_SUPERVISOR_BINARY"),
+		Args:        os.Args[1:],
+	}
+	if s.ImageBinary == "" {
+		s.ImageBinary = "/usr/local/bin/hattiebot"
+	}
+	if v := os.Getenv("HATTIEBOT_SUPERVISOR_PROBATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "HATTIEBOT_SUPERVISOR_PROBATION: %v\n", err)
+			os.Exit(2)
+		}
+		s.Probation = d
+	}
+	if v := os.Getenv("HATTIEBOT_SUPERVISOR_MAX_CRASHES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "HATTIEBOT_SUPERVISOR_MAX_CRASHES: %v\n", err)
+			os.Exit(2)
+		}
+		s.MaxCrashes = n
+	}
+	signals := make(chan os.Signal, 4)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGTERM, syscall.SIGINT)
+	code, err := s.Run(context.Background(), signals)
+	if err != nil {
+		log.Printf("[Supervisor] %v", err)
+	}
+	os.Exit(code)
+}
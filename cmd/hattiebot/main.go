@@ -8,16 +8,24 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/hattiebot/hattiebot/internal/agent"
 	"github.com/hattiebot/hattiebot/internal/agent/templates"
+	"github.com/hattiebot/hattiebot/internal/backup"
+	"github.com/hattiebot/hattiebot/internal/briefing"
 	"github.com/hattiebot/hattiebot/internal/bootstrap"
 	"github.com/hattiebot/hattiebot/internal/channels/admin_term"
+	apichannel "github.com/hattiebot/hattiebot/internal/channels/api"
 	"github.com/hattiebot/hattiebot/internal/channels/custom_webhook"
 	"github.com/hattiebot/hattiebot/internal/channels/nextcloudtalk"
 	"github.com/hattiebot/hattiebot/internal/config"
@@ -25,21 +33,45 @@ import (
 	"github.com/hattiebot/hattiebot/internal/gateway"
 	"github.com/hattiebot/hattiebot/internal/embeddinggood"
 	"github.com/hattiebot/hattiebot/internal/embeddingrouter"
+	"github.com/hattiebot/hattiebot/internal/egress"
+	"github.com/hattiebot/hattiebot/internal/creditmon"
+	"github.com/hattiebot/hattiebot/internal/dashboard"
+	"github.com/hattiebot/hattiebot/internal/errbudget"
+	"github.com/hattiebot/hattiebot/internal/events"
+	"github.com/hattiebot/hattiebot/internal/feeds"
+	"github.com/hattiebot/hattiebot/internal/health"
+	"github.com/hattiebot/hattiebot/internal/httpapi"
 	"github.com/hattiebot/hattiebot/internal/llmrouter"
+	"github.com/hattiebot/hattiebot/internal/logging"
 	"github.com/hattiebot/hattiebot/internal/memory"
 	"github.com/hattiebot/hattiebot/internal/middleware"
+	"github.com/hattiebot/hattiebot/internal/monitor"
 	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/queue"
+	"github.com/hattiebot/hattiebot/internal/redact"
+	"github.com/hattiebot/hattiebot/internal/reload"
+	"github.com/hattiebot/hattiebot/internal/retention"
+	"github.com/hattiebot/hattiebot/internal/sandbox"
 	"github.com/hattiebot/hattiebot/internal/scheduler"
 
 	"github.com/hattiebot/hattiebot/internal/secrets"
+	"github.com/hattiebot/hattiebot/internal/selfupdate"
 	"github.com/hattiebot/hattiebot/internal/store"
 	"github.com/hattiebot/hattiebot/internal/tools"
 	"github.com/hattiebot/hattiebot/internal/tools/nextcloud"
+	"github.com/hattiebot/hattiebot/internal/tracing"
+	"github.com/hattiebot/hattiebot/internal/worksync"
 	"github.com/hattiebot/hattiebot/internal/tui"
+	"github.com/hattiebot/hattiebot/internal/speech"
+	"github.com/hattiebot/hattiebot/internal/version"
 	"github.com/hattiebot/hattiebot/internal/webhookserver"
 	"github.com/hattiebot/hattiebot/internal/wiring"
 )
 
+// llmProbeTTL is how often health checks may call the LLM and embedding providers; in between
+// they report the last result.
+const llmProbeTTL = 10 * time.Minute
+
 func main() {
 	cfg := config.New("")
 	if err := run(cfg); err != nil {
@@ -49,6 +81,7 @@ func main() {
 }
 
 func run(cfg *config.Config) error {
+	startedAt := time.Now()
 	// First boot: no config file -> run first-boot setup, then continue (don't exit)
 	cf, _ := store.LoadConfigFile(cfg.ConfigDir)
 	if cf == nil {
@@ -146,7 +179,7 @@ func run(cfg *config.Config) error {
                                 fmt.Println("[Main] Successfully archived HattieBot credentials in Nextcloud Passwords app (folder: HattieBot Secrets). Admin: open Passwords app → Shared with you.")
                                 return
                             }
-							fmt.Printf("[Main] StoreSecret error: %v. Retrying...\n", err)
+							logging.For("main").Warn("storing credentials in Nextcloud Passwords failed; retrying", "error", err)
 						}
 					}
 				}(cfg, botPass, botUser)
@@ -155,7 +188,7 @@ func run(cfg *config.Config) error {
 				go func(c *config.Config, n string) {
 					time.Sleep(60 * time.Second) // Allow Nextcloud/Talk to be ready (fresh install needs more time)
 					if err := bootstrap.InitIntroConversation(c, n); err != nil {
-						fmt.Printf("[Main] InitIntroConversation: %v\n", err)
+						logging.For("main").Error("creating intro conversation failed", "error", err)
 					} else {
 						fmt.Println("[Main] Intro conversation created with admin.")
 					}
@@ -224,6 +257,11 @@ func run(cfg *config.Config) error {
 	if cf.DefaultChannel != "" {
 		cfg.DefaultChannel = cf.DefaultChannel
 	}
+	cfg.FileStores = cf.FileStores
+	cfg.DefaultFileStore = cf.DefaultFileStore
+	if cf.SearchProvider != "" {
+		cfg.SearchProvider, cfg.SearchURL, cfg.SearchAPIKeySecret = cf.SearchProvider, cf.SearchURL, cf.SearchAPIKeySecret
+	}
 
 	// Fallback to env vars if config file missing them
 	if cfg.OpenRouterAPIKey == "" {
@@ -256,15 +294,28 @@ func run(cfg *config.Config) error {
 	if cfg.DefaultChannel == "" && os.Getenv("HATTIEBOT_DEFAULT_CHANNEL") != "" {
 		cfg.DefaultChannel = os.Getenv("HATTIEBOT_DEFAULT_CHANNEL")
 	}
-	if cfg.OpenRouterAPIKey == "" {
+	// Offline setups route to a local Ollama server and need no OpenRouter key or model
+	if rc, _ := store.LoadLLMRouting(cfg.ConfigDir); rc.DefaultIsLocal() {
+		if cfg.OpenRouterAPIKey == "" {
+			log.Printf("[AGENT] No OpenRouter API key: running on the local ollama route only")
+		}
+	} else if cfg.OpenRouterAPIKey == "" {
 		return fmt.Errorf("OpenRouter API key not set: add to config or set OPENROUTER_API_KEY")
-	}
-	if cfg.Model == "" {
+	} else if cfg.Model == "" {
 		return fmt.Errorf("model not set: add to config or set HATTIEBOT_MODEL")
 	}
 
+	// Mask configured credentials (and common key patterns) in log output
+	redact.AddSecret(cfg.OpenRouterAPIKey, cfg.EmbeddingServiceAPIKey, cfg.HattieBridgeWebhookSecret, cfg.NextcloudBotAppPassword, cfg.SpeechAPIKey, cfg.SecretsPassphrase, cfg.VaultToken, cfg.VaultSecretID)
+	// Structured, leveled logging; log.Printf("[TAG] ...") lines are tagged with their component
+	logging.Setup(logging.Options{Level: cfg.LogLevel, Format: cfg.LogFormat, Output: redact.NewWriter(os.Stderr)})
+
 	// Open DB (create if missing)
 	ctx := context.Background()
+	if cfg.StorageBackend != "" && cfg.StorageBackend != "sqlite" {
+		// Refuse rather than silently diverge from the migrated copy.
+		return fmt.Errorf("storage_backend %q is configured, but this build runs on SQLite only; set storage_backend to \"sqlite\" in config.json to use %s", cfg.StorageBackend, cfg.DBPath)
+	}
 	db, err := store.Open(ctx, cfg.DBPath)
 	if err != nil {
 		return fmt.Errorf("open db: %w", err)
@@ -289,15 +340,37 @@ func run(cfg *config.Config) error {
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "warning: failed to load system config: %v\n", err)
 	}
-	// Optional: dynamic routing from llm_routing.json; fallback to single OpenRouter client
-	var client core.LLMClient
-	routingCfg, _ := store.LoadLLMRouting(cfg.ConfigDir)
-	if routingCfg != nil && routingCfg.HasDefaultRoute() {
-		bootstrap := openrouter.NewClient(cfg.OpenRouterAPIKey, cfg.Model, cfg.ConfigDir)
-		client = llmrouter.NewRouterClient(routingCfg, bootstrap, cfg.ConfigDir, nil)
-	} else {
-		client = wiring.LoadClient(sysCfg.LLMClient, cfg.OpenRouterAPIKey, cfg.Model)
+	if cfg.OpenRouterBaseURL != "" {
+		openrouter.BaseURL = strings.TrimRight(cfg.OpenRouterBaseURL, "/")
 	}
+	// OpenTelemetry: turn traces go to an OTLP collector when an endpoint is configured
+	if cfg.OTLPEndpoint != "" {
+		shutdownTracing := tracing.Setup(cfg.OTLPEndpoint, cfg.OTLPHeaders)
+		defer func() {
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			shutdownTracing(flushCtx)
+		}()
+		fmt.Printf("Tracing: exporting spans to %s\n", cfg.OTLPEndpoint)
+	}
+	// Initialize LogStore for observability (system_logs is created by the schema migrations)
+	logStore := store.NewLogStore(db.DB)
+	// Copy log records to system_logs for read_logs and system_status; flushed before the DB closes
+	logging.SetSink(logStore)
+	defer logging.SetSink(nil)
+
+	// Optional: dynamic routing from llm_routing.json; fallback to single OpenRouter client.
+	// Both clients are rebuilt by the config reloader when the routing files change.
+	buildLLM := func() core.LLMClient {
+		routingCfg, _ := store.LoadLLMRouting(cfg.ConfigDir)
+		if routingCfg != nil && routingCfg.HasDefaultRoute() {
+			bootstrap := openrouter.NewClient(cfg.OpenRouterAPIKey, cfg.Model, cfg.ConfigDir)
+			router := llmrouter.NewRouterClient(routingCfg, bootstrap, cfg.ConfigDir, nil)
+			return router
+		}
+		return wiring.LoadClient(sysCfg.LLMClient, cfg.OpenRouterAPIKey, cfg.Model)
+	}
+	client := reload.NewLLMClient(buildLLM())
 
 	// Validate Model Configuration (prevent bricking if config.json has bad model)
 	healthCtx, hCancel := context.WithTimeout(ctx, 15*time.Second)
@@ -306,55 +379,85 @@ func run(cfg *config.Config) error {
 	_, err = client.ChatCompletion(healthCtx, []core.Message{{Role: "user", Content: "ping - respond with one word"}})
 	hCancel()
 	if err != nil {
-		fmt.Printf("[Init] Warning: Model '%s' failed validation: %v. Falling back to env model.\n", cfg.Model, err)
+		logging.For("init").Warn("model failed validation; falling back to env model", "model", cfg.Model, "error", err)
 		if cfg.EnvModel != "" && cfg.Model != cfg.EnvModel {
-			fmt.Printf("[Init] Activating fallback model: %s\n", cfg.EnvModel)
+			logging.For("init").Info("activating fallback model", "model", cfg.EnvModel)
 			cfg.Model = cfg.EnvModel
 			// Re-initialize client with fallback model
-			if routingCfg != nil && routingCfg.HasDefaultRoute() {
-				bootstrap := openrouter.NewClient(cfg.OpenRouterAPIKey, cfg.Model, cfg.ConfigDir)
-				client = llmrouter.NewRouterClient(routingCfg, bootstrap, cfg.ConfigDir, nil)
-			} else {
-				client = wiring.LoadClient(sysCfg.LLMClient, cfg.OpenRouterAPIKey, cfg.Model)
-			}
+			client.Set(buildLLM())
 		} else {
-			fmt.Println("[Init] No fallback model available or fallback matches current. Continuing with risk of failure.")
+			logging.For("init").Warn("no fallback model available or fallback matches current; continuing with risk of failure")
 		}
 	} else {
-		fmt.Printf("[Init] Model '%s' verified successfully.\n", cfg.Model)
+		logging.For("init").Info("model verified", "model", cfg.Model)
 	}
 
 	// Build embedder: embedding_routing.json default provider > single EmbeddingGood URL > LLM client Embed
-	llmFallback := embeddinggood.NewLLMEmbedWrapper(client)
-	var embedder core.EmbeddingClient
-	embedCfg, _ := store.LoadEmbeddingRouting(cfg.ConfigDir)
-	if embedCfg != nil && embedCfg.HasDefaultProvider() {
-		embedder = embeddingrouter.NewRouter(embedCfg, llmFallback, nil, cfg.ConfigDir)
-	} else if cfg.EmbeddingServiceURL != "" && cfg.EmbeddingServiceAPIKey != "" {
-		embedder = embeddinggood.NewClient(cfg.EmbeddingServiceURL, cfg.EmbeddingServiceAPIKey, cfg.EmbeddingDimension)
-	} else {
-		embedder = llmFallback
+	buildEmbedder := func(llm core.LLMClient) core.EmbeddingClient {
+		e := embeddingrouter.Build(cfg.ConfigDir, cfg.EmbeddingServiceURL, cfg.EmbeddingServiceAPIKey, cfg.EmbeddingDimension, llm)
+		if cfg.LocalEmbeddings {
+			return embeddinggood.WithLocalFallback(e)
+		}
+		return e
+	}
+	embedder := reload.NewEmbeddingClient(buildEmbedder(client))
+	// Memories embedded by another provider or dimension are invisible to recall until re-embedded
+	go func() {
+		st, err := memory.CheckEmbeddings(ctx, db, embedder)
+		if err != nil {
+			log.Printf("[MEMORY] Checking memory embeddings: %v", err)
+		} else if st.Stale > 0 {
+			log.Printf("[MEMORY] %d memories were embedded by another model or dimension than %q (%d dimensions); run reembed or the reembed_memories tool", st.Stale, st.Model, st.Dim)
+		}
+	}()
+	reloader := &reload.Reloader{
+		ConfigDir:     cfg.ConfigDir,
+		LLM:           client,
+		Embedder:      embedder,
+		BuildLLM:      buildLLM,
+		BuildEmbedder: buildEmbedder,
 	}
 
 	// Wrap with Policy Middleware
 	// Simple confirmation for now: log and approve.
 	confirmFunc := func(msg string) (bool, error) {
-		fmt.Printf("[POLICY] %s -> Auto-approved for verification.\n", msg)
+		logging.For("policy").Info("auto-approved for verification", "request", msg)
 		return true, nil
 	}
 	// Initial executor loading now requires client for Embedding support
 	rawExecutor := wiring.LoadExecutor(sysCfg.ToolExecutor, cfg, db, client)
-	truncating := middleware.NewTruncatingExecutor(rawExecutor, cfg.ToolOutputMaxRunes)
-	executor := middleware.NewPolicyMiddleware(truncating, tools.BuiltinToolDefs(), confirmFunc)
+	// Error budget: rolling provider/tool/empty-response failure rates; when exhausted the bot self-throttles
+	errBudget := errbudget.NewBudget()
+	// Redact before truncating so a secret is never cut in half and left partly visible
+	redacting := middleware.NewRedactingExecutor(rawExecutor)
+	truncating := middleware.NewTruncatingExecutor(redacting, cfg.ToolOutputMaxRunes)
+	policy := middleware.NewPolicyMiddleware(truncating, tools.BuiltinToolDefs(), confirmFunc)
+	policy.Permissions = db
+	policy.Throttle = errBudget
+	policy.Drafts = middleware.NewDrafts(cfg.ConfirmTools)
+	policy.Drafts.Preapproved = func(ctx context.Context, planID int64) bool {
+		ok, err := db.PlanPreapproved(ctx, planID)
+		if err != nil {
+			log.Printf("[Drafts] Plan %d: %v", planID, err)
+		}
+		return ok
+	}
+	policy.DryRun = cfg.DryRun
+	// Outbound event subscriptions (manage_event_subscriptions): signed, retried deliveries
+	eventBus := &events.Bus{DB: db}
+	eventBus.Start(ctx)
+	// Audit so policy denials are recorded too; tracing outermost so its span covers the whole call
+	executor := middleware.NewTracingExecutor(middleware.NewAuditingExecutor(middleware.NewEventExecutor(middleware.NewErrorBudgetExecutor(policy, errBudget), eventBus), db))
+	// Retention: expire old messages (summarized per thread), audit log entries and system logs, daily
+	cleaner := &retention.Cleaner{DB: db, Logs: logStore, Client: client, Policy: retention.Policy{
+		MessageDays: cfg.MessageRetentionDays,
+		Summarize:   cfg.MessageRetentionSummarize,
+		AuditDays:   cfg.AuditRetentionDays,
+	}}
+	cleaner.Start(ctx, retention.DefaultInterval)
 
 	contextManager := wiring.LoadContextSelector(sysCfg.ContextSelector, db)
 
-	// Initialize LogStore for observability
-	logStore := store.NewLogStore(db.DB)
-	if err := logStore.CreateTable(); err != nil {
-		fmt.Fprintf(os.Stderr, "warning: failed to init log store: %v\n", err)
-	}
-
 	// Initialize SubmindRegistry
 	submindRegistry, err := agent.LoadSubmindRegistry(cfg.ConfigDir)
 	if err != nil {
@@ -371,102 +474,519 @@ func run(cfg *config.Config) error {
 		Compactor:       memory.NewCompactor(client, 4000), // Threshold: ~4000 tokens
 		SubmindRegistry: submindRegistry,
 		LogStore:        logStore,
+		ToolSelector:    agent.NewToolSelector(embedder, cfg.ToolSubsetSize).Configure(cfg.ToolCore, cfg.ToolRules),
+		ErrorBudget:     errBudget,
+		Events:          eventBus,
+	}
+	if cfg.ThrottleModel != "" && cfg.ThrottleModel != cfg.Model {
+		loop.CheapClient = openrouter.NewClient(cfg.OpenRouterAPIKey, cfg.ThrottleModel, cfg.ConfigDir)
 	}
 
 	// Initialize SecretStore
 	secretStore := secrets.NewMultiStore()
 	secretStore.Register("env", &secrets.EnvSecretStore{})
 	secretStore.Register("passwords", secrets.NewNextcloudSecretStore(cfg))
+	if cfg.VaultAddr != "" {
+		secretStore.Register("vault", secrets.NewVaultSecretStore(secrets.VaultConfig{
+			Addr:      cfg.VaultAddr,
+			Token:     cfg.VaultToken,
+			RoleID:    cfg.VaultRoleID,
+			SecretID:  cfg.VaultSecretID,
+			Mount:     cfg.VaultMount,
+			Namespace: cfg.VaultNamespace,
+		}))
+	}
+	if fileStore, err := secrets.OpenFileSecretStore(cfg.SecretsFile, cfg.SecretsKeyFile, cfg.SecretsPassphrase); err != nil {
+		log.Printf("Warning: local secret store unavailable: %v", err)
+	} else {
+		secretStore.Register("local", fileStore)
+		if cfg.NextcloudURL == "" || cfg.NextcloudBotAppPassword == "" {
+			secretStore.Default = "local" // no Passwords app: {{secret:...}} and the secret tools use the local file
+		}
+	}
+	tools.InitEmail(cfg, secretStore)
+	tools.InitSearch(cfg, secretStore)
+	// Plugins: in-process Go tools from $CONFIG_DIR/plugins, registered alongside the built-ins
+	pluginLoader := tools.InitPlugins(cfg.ConfigDir, func(ctx context.Context, name string) bool {
+		t, _ := db.ToolByName(ctx, name)
+		return t != nil
+	})
+	loadedPlugins, pluginErrs := pluginLoader.Load()
+	if len(loadedPlugins) > 0 {
+		log.Printf("[PLUGINS] loaded %d plugin tools from %s", len(loadedPlugins), pluginLoader.Dir)
+	}
+	for _, err := range pluginErrs {
+		log.Printf("[PLUGINS] %v", err)
+	}
+	policy.Dynamic = pluginLoader.Definition
+	if toolExec, ok := rawExecutor.(*tools.Executor); ok {
+		toolExec.Plugins = pluginLoader
+	}
 
 
 	// Start scheduler background runner
 	schedRunner := scheduler.NewRunner(db)
+	if cfg.SchedulerIntervalSec > 0 {
+		schedRunner.Interval = time.Duration(cfg.SchedulerIntervalSec) * time.Second
+	}
 	schedRunner.ToolExecutor = executor // Wire executor for execute_tool action
+	schedRunner.Throttle = errBudget
+	schedRunner.Events = eventBus
 	schedRunner.Start()
 	defer schedRunner.Stop()
 
 	// Gateway Setup
 	gw := gateway.New(func(ctx context.Context, msg gateway.Message) (string, error) {
 		// Handler: Receive message from any channel, run through agent loop
-		fmt.Printf("[Gateway] Received from %s (%s): %s\n", msg.Channel, msg.SenderID, msg.Content)
+		logging.For("gateway").InfoContext(ctx, "received message", "channel", msg.Channel, "sender", msg.SenderID, "content", redact.String(msg.Content))
 		return loop.RunOneTurn(ctx, msg)
 	})
 
+	// Group rooms: answer only messages addressed to the bot, keep the rest as context
+	gw.SetAddressing(loop.Addressed, loop.RecordPassive)
+	gw.SetPreemption(loop.MayInterrupt)
+
 	// Inject Gateway and Sub-Mind components into Executor
 	loop.Gateway = gw
+	// Config changes are applied between turns
+	reloader.WhenIdle = gw.WhenIdle
+	reloader.Start(ctx, time.Duration(cfg.ConfigWatchSec)*time.Second)
     // Explicitly set Spawner via interface method (safe DI)
     executor.SetSpawner(loop)
+	// Background sub-minds (spawn_submind async); pick up the ones a restart interrupted
+	loop.Runner = agent.NewSubmindRunner(ctx, loop, cfg.SubmindConcurrency)
+	if n, err := loop.Runner.Recover(ctx); err != nil {
+		log.Printf("Warning: failed to recover background sub-minds: %v", err)
+	} else if n > 0 {
+		log.Printf("Resumed %d background sub-mind session(s)", n)
+	}
 
 	if toolExec, ok := rawExecutor.(*tools.Executor); ok {
 		toolExec.Gateway = gw
 		toolExec.LogStore = logStore
 		toolExec.SubmindRegistry = submindRegistry
 		toolExec.Embedder = embedder
+		toolExec.Reloader = reloader
+		// Per-trust sandbox profiles for run_terminal_cmd
+		sb, err := sandbox.Load(cfg.ConfigDir)
+		if err != nil {
+			log.Printf("Warning: sandbox config: %v (using defaults)", err)
+			sb = sandbox.DefaultConfig()
+		}
+		toolExec.Sandbox = sb
+		// Egress proxy: registered tools reach the network through it, per network_policy.json
+		proxy, err := egress.NewProxy(cfg.ConfigDir)
+		if err != nil {
+			log.Printf("Warning: network policy: %v (using default policy)", err)
+		}
+		if err := proxy.Start("127.0.0.1:0"); err != nil {
+			log.Printf("Warning: egress proxy not started: %v (registered tools have unrestricted network access)", err)
+		} else {
+			toolExec.Egress = proxy
+			log.Printf("[EGRESS] Proxy for registered tools on %s (mode %s)", proxy.Addr(), proxy.Policy().Mode)
+		}
 		// Spawner is now set via wrapper
 	}
 
-	// 1. Admin Terminal Channel
-	gw.Register(adminterm.New())
+	// 1. Admin Terminal Channel, with slash commands answered from the store and health registries
+	term := adminterm.New()
+	term.Commands = &adminterm.Commands{DB: db, Logs: logStore, Config: cfg, Gateway: gw}
+	if toolExec, ok := rawExecutor.(*tools.Executor); ok {
+		term.Commands.Status = toolExec.StatusGatherer
+	}
+	gw.Register(term)
+
+	// HTTP API for other programs (pkg/hattiebot SDK); served by the webhook server
+	apiCh := apichannel.New(gw.PushIngress)
+	gw.Register(apiCh)
+	apiHandler := &httpapi.Handler{DB: db, Executor: executor, Channel: apiCh}
+	openAIHandler := &httpapi.OpenAIHandler{API: apiHandler}
+	httpPort := 8080
+	httpPortSet := false
+	if p := os.Getenv("HATTIEBOT_HTTP_PORT"); p != "" {
+		if n, err := strconv.Atoi(p); err == nil && n > 0 {
+			httpPort, httpPortSet = n, true
+		}
+	}
+	if p := os.Getenv("HATTIEBOT_API_PORT"); p != "" && os.Getenv("HATTIEBOT_HTTP_PORT") == "" {
+		if n, err := strconv.Atoi(p); err == nil && n > 0 {
+			httpPort, httpPortSet = n, true
+		}
+	}
+	trustedProxies, err := webhookserver.ParseIPNets(cfg.HTTPTrustedProxies)
+	if err != nil {
+		return fmt.Errorf("HATTIEBOT_HTTP_TRUSTED_PROXIES: %w", err)
+	}
+	// hardenHTTP applies the bind address, TLS, timeouts and trusted proxies to an HTTP server
+	hardenHTTP := func(srv *webhookserver.Server) *webhookserver.Server {
+		srv.Addr = net.JoinHostPort(cfg.HTTPBindAddr, strconv.Itoa(httpPort))
+		srv.TLSCertFile, srv.TLSKeyFile = cfg.HTTPTLSCertFile, cfg.HTTPTLSKeyFile
+		srv.AutocertDomains, srv.AutocertEmail = cfg.HTTPAutocertDomains, cfg.HTTPAutocertEmail
+		srv.AutocertCacheDir = filepath.Join(cfg.ConfigDir, "autocert")
+		srv.ReadTimeout = time.Duration(cfg.HTTPReadTimeoutSec) * time.Second
+		srv.WriteTimeout = time.Duration(cfg.HTTPWriteTimeoutSec) * time.Second
+		srv.MaxHeaderBytes = cfg.HTTPMaxHeaderBytes
+		srv.TrustedProxies = trustedProxies
+		return srv
+	}
+	talkAllowedIPs, err := webhookserver.ParseIPNets(cfg.TalkAllowedIPs)
+	if err != nil {
+		return fmt.Errorf("HATTIEBOT_TALK_ALLOWED_IPS: %w", err)
+	}
+	publicStatus := func() webhookserver.PublicStatus {
+		st := webhookserver.PublicStatus{Version: version.Version, StartedAt: startedAt}
+		names := gw.GetChannelNames()
+		sort.Strings(names)
+		for _, name := range names {
+			st.Channels = append(st.Channels, webhookserver.ChannelStatus{Name: name, Status: gw.ChannelHealth(name).Status})
+		}
+		if tick := schedRunner.LastTick(); !tick.IsZero() {
+			st.LastSchedulerAt = &tick
+		}
+		return st
+	}
+
+	// Health registry: system_status and the admin-only /health?detail=1 report every subsystem
+	healthReg := health.NewRegistry()
+	healthReg.Register("database", db)
+	healthReg.Register("llm", health.NewProbe("llm", llmProbeTTL, func(ctx context.Context) error {
+		_, err := client.ChatCompletion(ctx, []core.Message{{Role: "user", Content: "ping - respond with one word"}})
+		return err
+	}))
+	healthReg.Register("embedder", health.NewProbe("embedder", llmProbeTTL, func(ctx context.Context) error {
+		// Probe the provider itself: the local fallback would hide an outage
+		c := embedder.Current()
+		if f, ok := c.(*embeddinggood.LocalFallback); ok {
+			c = f.Primary
+		}
+		_, err := c.Embed(ctx, "ping", "query")
+		return err
+	}))
+	healthReg.Register("gateway", gw)
+	// Share turns with other processes through an external queue
+	if cfg.QueueURL != "" {
+		q, err := queue.NewRedis(cfg.QueueURL, cfg.QueuePartitions)
+		if err != nil {
+			return fmt.Errorf("HATTIEBOT_QUEUE_URL: %w", err)
+		}
+		gw.SetQueue(q)
+		healthReg.Register("queue", q)
+	}
+	healthReg.Register("scheduler", schedRunner)
+	healthReg.Register("error_budget", errBudget)
+
+	// Router for proactive messaging (scheduler, notify_user, webhook prompts)
+	router := gateway.NewRouter(gw, db)
+	if cfg.DefaultChannel != "" {
+		router.DefaultChannel = cfg.DefaultChannel
+	}
+	// Calls held from unattended runs are sent to their owner to confirm
+	policy.Drafts.Notify = func(userID, msg string) {
+		if err := router.RouteMessage(context.Background(), userID, msg, ""); err != nil {
+			log.Printf("[Drafts] Failed to notify %s: %v", userID, err)
+		}
+	}
+	adminID := cfg.AdminUserID
+	if adminID == "" {
+		adminID = "admin"
+	}
+	var httpSrv *webhookserver.Server
 
 	// 2. Nextcloud Talk Channel (if configured); webhooks from HattieBridge, send via chat API as Hattie user
 	if cfg.NextcloudURL != "" && cfg.HattieBridgeWebhookSecret != "" && cfg.NextcloudBotUser != "" && cfg.NextcloudBotAppPassword != "" {
-		gw.Register(nextcloudtalk.New(nextcloudtalk.Config{
+		stt, tts := speech.New(cfg)
+		talkCh := nextcloudtalk.New(nextcloudtalk.Config{
 			BaseURL:        cfg.NextcloudURL,
 			BotUser:        cfg.NextcloudBotUser,
 			BotAppPassword: cfg.NextcloudBotAppPassword,
-		}))
-		httpPort := 8080
-		if p := os.Getenv("HATTIEBOT_HTTP_PORT"); p != "" {
-			if n, err := strconv.Atoi(p); err == nil && n > 0 {
-				httpPort = n
-			}
-		}
-		if p := os.Getenv("HATTIEBOT_API_PORT"); p != "" && os.Getenv("HATTIEBOT_HTTP_PORT") == "" {
-			if n, err := strconv.Atoi(p); err == nil && n > 0 {
-				httpPort = n
-			}
-		}
-
-		webhookSrv := &webhookserver.Server{
-			Addr:               fmt.Sprintf(":%d", httpPort),
+			Synthesizer:    tts,
+		})
+		gw.Register(talkCh)
+		webhookSrv := hardenHTTP(&webhookserver.Server{
 			HattieBridgeSecret: cfg.HattieBridgeWebhookSecret,
+			TalkAllowedIPs:     talkAllowedIPs,
+			TalkClientCAFile:   cfg.TalkClientCAFile,
+			TalkClientNames:    cfg.TalkClientNames,
 			PushIngress:        gw.PushIngress,
 			ConfigDir:          cfg.ConfigDir,
 			SecretStore:        secretStore,
 			ToolExecutor:       executor,
-		}
+			Events:             db,
+			Deliveries:         db,
+			Prompt:             router.PushBackgroundPrompt,
+			PromptUser:         adminID,
+			Workers:            cfg.WebhookWorkers,
+			QueueSize:          cfg.WebhookQueueSize,
+			Reactions:          db,
+			Transcriber:        stt,
+			FetchAttachment:    talkCh.DownloadAttachment,
+			Status:             publicStatus,
+			API:                apiHandler,
+			OpenAI:             openAIHandler,
+			Health:             healthReg.Check,
+			HealthAuth:         apiHandler.IsAdmin,
+			Auth:               apiHandler.Auth(),
+		})
+		httpSrv = webhookSrv
 		defaultCh := "nextcloud_talk"
 		if cfg.DefaultChannel != "" {
 			defaultCh = cfg.DefaultChannel
 		}
-		adminID := cfg.AdminUserID
-		if adminID == "" {
-			adminID = "admin"
-		}
 		gw.Register(custom_webhook.New(gw, defaultCh, adminID))
 		go func() {
 			if err := webhookSrv.Run(); err != nil {
 				fmt.Fprintf(os.Stderr, "webhook server: %v\n", err)
 			}
 		}()
+	} else if httpPortSet {
+		// No Nextcloud: serve only the APIs, health and status pages on the configured port
+		apiSrv := hardenHTTP(&webhookserver.Server{
+			Status:     publicStatus,
+			API:        apiHandler,
+			OpenAI:     openAIHandler,
+			Health:     healthReg.Check,
+			HealthAuth: apiHandler.IsAdmin,
+			Auth:       apiHandler.Auth(),
+		})
+		httpSrv = apiSrv
+		go func() {
+			if err := apiSrv.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "API server: %v\n", err)
+			}
+		}()
 	}
 
-	// 4. Router and Escalation Monitor for proactive messaging
-	router := gateway.NewRouter(gw, db)
-	if cfg.DefaultChannel != "" {
-		router.DefaultChannel = cfg.DefaultChannel
+	if httpSrv != nil {
+		healthReg.Register("webhook_server", httpSrv)
 	}
+
+	// 4. Escalation Monitor for proactive messaging
 	schedRunner.Router = router // Wire router so scheduler can deliver reminders proactively
 	if toolExec, ok := rawExecutor.(*tools.Executor); ok {
 		toolExec.Router = router // For notify_user tool
 		toolExec.SecretStore = secretStore
+		toolExec.ErrorBudget = errBudget
+		toolExec.HealthReg = healthReg
+		toolExec.Events = eventBus
+	}
+	// Daily briefings (manage_briefing): delivered by the scheduler through the router
+	briefings := &briefing.Service{DB: db, Config: cfg, Client: client, Sender: router, Tools: executor, Throttle: errBudget}
+	schedRunner.Briefings = briefings
+	if toolExec, ok := rawExecutor.(*tools.Executor); ok {
+		toolExec.Briefings = briefings
+	}
+	// Web dashboard on its own port, for admins' API tokens
+	if cfg.DashboardPort > 0 {
+		dash := &dashboard.Handler{DB: db, SchedulerLastTick: schedRunner.LastTick}
+		if toolExec, ok := rawExecutor.(*tools.Executor); ok {
+			dash.Status = func(ctx context.Context) (tools.SystemStatus, error) {
+				return toolExec.StatusGatherer().Gather(ctx)
+			}
+		}
+		go func() {
+			addr := fmt.Sprintf(":%d", cfg.DashboardPort)
+			log.Printf("[Dashboard] Serving on %s", addr)
+			if err := http.ListenAndServe(addr, dash); err != nil {
+				fmt.Fprintf(os.Stderr, "dashboard: %v\n", err)
+			}
+		}()
+	}
+	// Tell the admin when the bot starts or stops self-throttling
+	errBudget.OnChange = func(throttled bool, reason string) {
+		msg := "[Error budget] Error rates recovered; full autonomy restored."
+		if throttled {
+			msg = "[Error budget] Self-throttling: " + reason + ". Scheduled agent tasks are deferred, restricted tools need approval"
+			if loop.CheapClient != nil {
+				msg += ", and the cheaper model " + cfg.ThrottleModel + " is in use"
+			}
+			msg += " until error rates recover."
+		}
+		log.Printf("[AGENT] %s", msg)
+		if cfg.AdminUserID == "" {
+			return
+		}
+		go func() {
+			if err := router.RouteMessage(context.Background(), cfg.AdminUserID, msg, ""); err != nil {
+				log.Printf("[AGENT] Failed to notify admin of error budget change: %v", err)
+			}
+		}()
 	}
 	escalationMonitor := &scheduler.EscalationMonitor{
-		DB:     db,
-		Router: router,
+		DB:          db,
+		Router:      router,
+		AdminUserID: cfg.AdminUserID,
+		Overdue:     time.Duration(cfg.EscalationOverdueMin) * time.Minute,
+		Chain: scheduler.DefaultEscalationChain(
+			time.Duration(cfg.EscalationAdminAfterMin)*time.Minute,
+			time.Duration(cfg.EscalationUrgentAfterMin)*time.Minute),
 	}
 	escalationMonitor.Start(ctx, 5*time.Minute) // Check every 5 minutes
 
+	// Repair broken registered tools in the background and tell the admin what changed
+	if cfg.ToolAutoRepair {
+		repairer := &agent.ToolRepairer{Loop: loop}
+		repairer.Notify = func(msg string) {
+			if cfg.AdminUserID == "" {
+				return
+			}
+			if err := router.RouteMessage(context.Background(), cfg.AdminUserID, msg, ""); err != nil {
+				log.Printf("[AGENT] Failed to notify admin of tool repair: %v", err)
+			}
+		}
+		repairer.Start(ctx, agent.DefaultRepairInterval)
+	}
+
+	// Watch OpenRouter credits: threshold warnings and a weekly spend digest for the admin
+	if cfg.OpenRouterAPIKey != "" {
+		creditMonitor := creditmon.New(openrouter.NewClient(cfg.OpenRouterAPIKey, cfg.Model, cfg.ConfigDir), db, cfg.ConfigDir)
+		creditMonitor.Thresholds = cfg.CreditWarnUSD
+		creditMonitor.WarnDays = cfg.CreditWarnDays
+		creditMonitor.Models = []string{cfg.Model}
+		if cfg.ThrottleModel != "" {
+			creditMonitor.Models = append(creditMonitor.Models, cfg.ThrottleModel)
+		}
+		creditMonitor.Notify = func(msg string) {
+			log.Printf("[AGENT] %s", msg)
+			if cfg.AdminUserID == "" {
+				return
+			}
+			if err := router.RouteMessage(context.Background(), cfg.AdminUserID, msg, ""); err != nil {
+				log.Printf("[AGENT] Failed to notify admin of credit status: %v", err)
+			}
+		}
+		if toolExec, ok := rawExecutor.(*tools.Executor); ok {
+			toolExec.Credits = creditMonitor
+		}
+		healthReg.Register("credits", creditMonitor)
+		creditMonitor.Start(ctx, creditmon.DefaultInterval)
+	}
+
+	// Scheduled backups of the database and config dir; backup_now runs one on request
+	if target, err := backup.NewTarget(cfg); err != nil {
+		log.Printf("Warning: backups disabled: %v", err)
+	} else if target != nil {
+		backups := &backup.Manager{Target: target, DB: db.DB, DBPath: cfg.DBPath, ConfigDir: cfg.ConfigDir, Keep: cfg.BackupKeep}
+		backups.Notify = func(msg string) {
+			if cfg.AdminUserID == "" {
+				return
+			}
+			if err := router.RouteMessage(context.Background(), cfg.AdminUserID, msg, ""); err != nil {
+				log.Printf("[Backup] Failed to notify admin: %v", err)
+			}
+		}
+		if toolExec, ok := rawExecutor.(*tools.Executor); ok {
+			toolExec.Backups = backups
+		}
+		backups.Start(ctx, time.Duration(cfg.BackupIntervalHours)*time.Hour)
+	}
+
+	// Two-way sync of the workspace with a Nextcloud folder; sync_workspace runs one on request
+	if cfg.WorkspaceSyncFolder != "" {
+		if cfg.NextcloudURL == "" || cfg.NextcloudBotUser == "" || cfg.NextcloudBotAppPassword == "" {
+			log.Printf("Warning: workspace sync disabled: nextcloud credentials not configured")
+		} else {
+			syncer := &worksync.Syncer{Dir: cfg.WorkspaceDir, Remote: &worksync.NextcloudRemote{Config: cfg, Folder: cfg.WorkspaceSyncFolder}, DB: db}
+			syncer.Notify = func(msg string) {
+				if cfg.AdminUserID == "" {
+					return
+				}
+				if err := router.RouteMessage(context.Background(), cfg.AdminUserID, msg, ""); err != nil {
+					log.Printf("[WorkspaceSync] Failed to notify admin: %v", err)
+				}
+			}
+			if toolExec, ok := rawExecutor.(*tools.Executor); ok {
+				toolExec.WorkspaceSync = syncer
+			}
+			syncer.Start(ctx, time.Duration(cfg.WorkspaceSyncIntervalMin)*time.Minute)
+		}
+	}
+
+	// RSS/Atom subscriptions (manage_feed): new matching items become autonomous agent tasks
+	feedPoller := &feeds.Poller{DB: db, Push: router.PushBackgroundPrompt, Throttle: errBudget}
+	feedPoller.Notify = func(userID, msg string) {
+		if err := router.RouteMessage(context.Background(), userID, msg, ""); err != nil {
+			log.Printf("[Feeds] Failed to notify %s: %v", userID, err)
+		}
+	}
+	if toolExec, ok := rawExecutor.(*tools.Executor); ok {
+		toolExec.Feeds = feedPoller
+	}
+	feedPoller.Start(ctx, feeds.CheckTick)
+
+	// Self-update (self_update): builds of the source checkout, installed by hattiebot-supervisor
+	if cfg.GitRepoDir != "" {
+		builder := &selfupdate.Builder{
+			SourceDir:   cfg.GitRepoDir,
+			Dir:         selfupdate.DirFor(cfg.ConfigDir),
+			TestTimeout: time.Duration(cfg.SelfUpdateTestTimeoutMin) * time.Minute,
+		}
+		// Builds run agent-written code, so they are sandboxed ("build" unless configured); rather
+		// than build unsandboxed, self-update is disabled when the profile cannot run
+		sandboxOK := true
+		profileName := cfg.SelfUpdateSandbox
+		if profileName == "" {
+			profileName = "build"
+		}
+		sb, err := sandbox.Load(cfg.ConfigDir)
+		profile, ok := sb.Profiles[profileName]
+		if err == nil && !ok {
+			err = fmt.Errorf("no such profile")
+		}
+		if err == nil {
+			err = profile.Available()
+		}
+		if err != nil {
+			log.Printf("Warning: self-update disabled: sandbox profile %q not available (%v)", profileName, err)
+			sandboxOK = false
+		}
+		builder.Sandbox = profile
+		if modCache, err := exec.Command("go", "env", "GOMODCACHE").Output(); err == nil {
+			builder.GoModCache = strings.TrimSpace(string(modCache))
+		}
+		if sandboxOK {
+			updates := &selfupdate.Manager{Builder: builder, RestartCommand: cfg.RestartCommand}
+			updates.Notify = func(userID, msg string) {
+				if err := router.RouteMessage(context.Background(), userID, msg, ""); err != nil {
+					log.Printf("[SelfUpdate] Failed to notify %s: %v", userID, err)
+				}
+			}
+			if toolExec, ok := rawExecutor.(*tools.Executor); ok {
+				toolExec.SelfUpdate = updates
+			}
+			// Tell the admin what the supervisor did while the bot was down (installs, rollbacks),
+			// once the channels below are up
+			if cfg.AdminUserID != "" {
+				go func() {
+					time.Sleep(30 * time.Second)
+					if err := updates.ReportEvents(func(msg string) { updates.Notify(cfg.AdminUserID, msg) }); err != nil {
+						log.Printf("[SelfUpdate] Read supervisor state: %v", err)
+					}
+				}()
+			}
+		}
+	}
+
+	// Every channel is registered by now
+	for name, check := range gw.ChannelChecks() {
+		healthReg.Register(name, check)
+	}
+	// Tell threads whose turn a crash cut off what was done before it
+	if n, err := loop.RecoverInterruptedTurns(ctx); err != nil {
+		log.Printf("Warning: failed to recover interrupted turns: %v", err)
+	} else if n > 0 {
+		log.Printf("Reported %d interrupted turn(s)", n)
+	}
+
+	// Live view for hattiebot-monitor
+	if cfg.MonitorSocket != "" && cfg.MonitorSocket != "off" {
+		src := &monitor.Source{DB: db, Logs: logStore, Gateway: gw, Health: healthReg, StartedAt: startedAt, SchedulerTick: schedRunner.LastTick}
+		go func() {
+			if err := monitor.Listen(ctx, cfg.MonitorSocket, src); err != nil {
+				log.Printf("Warning: monitor socket %s: %v", cfg.MonitorSocket, err)
+			}
+		}()
+	}
+
 	// Start Gateway (blocks until ctx canceled)
 	fmt.Println("System architecture upgraded. Gateway starting...")
 	if err := gw.StartAll(ctx); err != nil {
@@ -0,0 +1,393 @@
+// hattiectl manages a running HattieBot over its HTTP API (pkg/hattiebot): send messages, tail
+// logs, list and change schedules and webhook routes, and run backups, without exec-ing into the
+// container. It acts as the API token's user, so the same trust level and tool policies apply;
+// logs, webhook routes and backups need an admin's token.
+// Usage: HATTIEBOT_URL=http://host:8080 HATTIEBOT_TOKEN=hb_... hattiectl [-url url] [-token token] [-json] command [args]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hattiebot/hattiebot/pkg/hattiebot"
+)
+
+const usage = `usage: hattiectl [-url url] [-token token] [-json] command [args]
+
+Commands:
+  send [-thread id] message...         send a message and print the reply
+  logs [-level l] [-component c] [-n 50] [-f]
+                                       recent log entries; -f keeps following
+  schedules [list]                     your active schedules
+  schedules add -description d -type daily|once|... -at 09:00 [-action remind|agent_prompt|execute_tool]
+                [-prompt p] [-autonomous] [-tool name -args json] [-tz zone]
+  schedules pause|delete|history id
+  webhooks [list]                      configured webhook routes
+  webhooks add json|@file              add a route (add_webhook_route arguments)
+  webhooks remove path|id
+  backup [-list]                       back up now, or list the stored backups
+  tools                                built-in and registered tools
+
+HATTIEBOT_URL (default http://localhost:8080) and HATTIEBOT_TOKEN set -url and -token.
+`
+
+// followInterval is how often logs -f polls for new entries.
+const followInterval = 2 * time.Second
+
+// cli is one hattiectl invocation.
+type cli struct {
+	client *hattiebot.Client
+	json   bool // print raw JSON instead of tables
+}
+
+func main() {
+	baseURL := os.Getenv("HATTIEBOT_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	flag.StringVar(&baseURL, "url", baseURL, "the bot's address")
+	token := flag.String("token", os.Getenv("HATTIEBOT_TOKEN"), "API token (manage_api_tokens)")
+	asJSON := flag.Bool("json", false, "print JSON")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *token == "" {
+		fmt.Fprintln(os.Stderr, "an API token is required: set HATTIEBOT_TOKEN or -token")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	c := &cli{client: hattiebot.New(baseURL, *token), json: *asJSON}
+	if err := c.run(ctx, flag.Arg(0), flag.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "hattiectl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (c *cli) run(ctx context.Context, cmd string, args []string) error {
+	switch cmd {
+	case "send":
+		return c.send(ctx, args)
+	case "logs":
+		return c.logs(ctx, args)
+	case "schedules":
+		return c.schedules(ctx, args)
+	case "webhooks":
+		return c.webhooks(ctx, args)
+	case "backup":
+		return c.backup(ctx, args)
+	case "tools":
+		return c.tools(ctx)
+	case "help":
+		fmt.Print(usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q (see hattiectl help)", cmd)
+}
+
+func (c *cli) send(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	thread := fs.String("thread", "", "thread ID (default: the token user's API thread)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	content := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("send: a message is required")
+	}
+	s, err := c.client.Stream(ctx, hattiebot.MessageRequest{Content: content, ThreadID: *thread})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	reply, err := s.Reply(func(status string) { fmt.Fprintf(os.Stderr, "… %s\n", status) })
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(reply)
+	}
+	fmt.Println(reply.Content)
+	return nil
+}
+
+func (c *cli) logs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	level := fs.String("level", "", "error, warn or info")
+	component := fs.String("component", "", "e.g. agent, gateway, scheduler, llm")
+	n := fs.Int("n", 50, "entries to show (max 200)")
+	follow := fs.Bool("f", false, "keep printing new entries")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	q := hattiebot.LogQuery{Level: *level, Component: *component, Limit: *n}
+	var last int64
+	for {
+		entries, err := c.client.Logs(ctx, q)
+		if err != nil {
+			return err
+		}
+		// Newest first from the API; print oldest first, skipping entries already printed
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			if e.ID <= last {
+				continue
+			}
+			last = e.ID
+			if c.json {
+				b, _ := json.Marshal(e)
+				fmt.Println(string(b))
+				continue
+			}
+			fmt.Printf("%s %-5s %-10s %s\n", e.Timestamp.Local().Format("01-02 15:04:05"), strings.ToUpper(e.Level), e.Component, e.Message)
+		}
+		if !*follow {
+			return nil
+		}
+		// Catch up on everything between polls
+		q.Limit = 200
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(followInterval):
+		}
+	}
+}
+
+func (c *cli) schedules(ctx context.Context, args []string) error {
+	sub := "list"
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+	switch sub {
+	case "list":
+		plans, err := c.client.ListSchedules(ctx)
+		if err != nil || c.json {
+			return orJSON(plans, err)
+		}
+		w := table("ID", "TYPE", "NEXT RUN", "ACTION", "DESCRIPTION")
+		for _, p := range plans {
+			next := "-"
+			if p.NextRunAt != nil {
+				next = p.NextRunAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%d\t%s %s\t%s\t%s\t%s\n", p.ID, p.ScheduleType, p.ScheduleValue, next, p.ActionType, p.Description)
+		}
+		return w.Flush()
+	case "add":
+		return c.addSchedule(ctx, args)
+	case "pause", "delete", "history":
+		if len(args) != 1 {
+			return fmt.Errorf("schedules %s: a schedule ID is required", sub)
+		}
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("schedules %s: invalid ID %q", sub, args[0])
+		}
+		switch sub {
+		case "pause":
+			err = c.client.PauseSchedule(ctx, id)
+		case "delete":
+			err = c.client.DeleteSchedule(ctx, id)
+		default:
+			runs, err := c.client.ScheduleHistory(ctx, id, 0)
+			if err != nil || c.json {
+				return orJSON(runs, err)
+			}
+			w := table("STARTED", "STATUS", "SUMMARY")
+			for _, r := range runs {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", r.StartedAt.Local().Format("2006-01-02 15:04"), r.Status, r.Summary)
+			}
+			return w.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Schedule %d: %sd.\n", id, strings.TrimSuffix(sub, "e"))
+		return nil
+	}
+	return fmt.Errorf("unknown schedules command %q", sub)
+}
+
+func (c *cli) addSchedule(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("schedules add", flag.ContinueOnError)
+	var req hattiebot.ScheduleRequest
+	fs.StringVar(&req.Description, "description", "", "what to remind or do")
+	fs.StringVar(&req.ScheduleType, "type", "once", "once, hourly, daily, weekdays, weekly or monthly")
+	fs.StringVar(&req.RunAt, "at", "", `when: "2h", "tomorrow 09:00", "09:00", "mon 09:00"`)
+	fs.StringVar(&req.ActionType, "action", "", "remind (default), agent_prompt or execute_tool")
+	fs.StringVar(&req.Prompt, "prompt", "", "agent_prompt: the task")
+	fs.BoolVar(&req.Autonomous, "autonomous", false, "agent_prompt: run silently, reporting via notify_user")
+	fs.StringVar(&req.Tool, "tool", "", "execute_tool: the tool")
+	toolArgs := fs.String("args", "", "execute_tool: JSON arguments")
+	fs.StringVar(&req.Timezone, "tz", "", "IANA time zone (default: the server's)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if req.Description == "" || req.RunAt == "" {
+		return fmt.Errorf("schedules add: -description and -at are required")
+	}
+	if *toolArgs != "" {
+		if err := json.Unmarshal([]byte(*toolArgs), &req.ToolArgs); err != nil {
+			return fmt.Errorf("schedules add: -args: %w", err)
+		}
+	}
+	created, err := c.client.CreateSchedule(ctx, req)
+	if err != nil || c.json {
+		return orJSON(created, err)
+	}
+	fmt.Printf("Schedule %d created; next run %s.\n", created.ID, created.NextRun)
+	return nil
+}
+
+func (c *cli) webhooks(ctx context.Context, args []string) error {
+	sub := "list"
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+	switch sub {
+	case "list":
+		routes, err := c.client.WebhookRoutes(ctx)
+		if err != nil || c.json {
+			return orJSON(routes, err)
+		}
+		w := table("ID", "PATH", "AUTH", "TARGET")
+		for _, r := range routes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ID, r.Path, r.AuthType, routeTarget(r))
+		}
+		return w.Flush()
+	case "add":
+		if len(args) != 1 {
+			return fmt.Errorf("webhooks add: the route as JSON, or @file, is required")
+		}
+		spec := []byte(args[0])
+		if strings.HasPrefix(args[0], "@") {
+			b, err := os.ReadFile(args[0][1:])
+			if err != nil {
+				return err
+			}
+			spec = b
+		}
+		if !json.Valid(spec) {
+			return fmt.Errorf("webhooks add: the route is not valid JSON")
+		}
+		out, err := c.client.AddWebhookRoute(ctx, json.RawMessage(spec))
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	case "remove":
+		if len(args) != 1 {
+			return fmt.Errorf("webhooks remove: a route path or ID is required")
+		}
+		if err := c.client.RemoveWebhookRoute(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Removed webhook route %s.\n", args[0])
+		return nil
+	}
+	return fmt.Errorf("unknown webhooks command %q", sub)
+}
+
+// routeTarget summarizes what a webhook route runs.
+func routeTarget(r hattiebot.WebhookRoute) string {
+	switch {
+	case len(r.Targets) > 0:
+		return fmt.Sprintf("%d targets", len(r.Targets))
+	case r.TargetType == "agent_prompt":
+		return "agent prompt"
+	}
+	return "tool " + r.TargetTool
+}
+
+func (c *cli) backup(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	list := fs.Bool("list", false, "list the stored backups instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *list {
+		backups, err := c.client.ListBackups(ctx)
+		if err != nil || c.json {
+			return orJSON(backups, err)
+		}
+		fmt.Printf("Backups at %s:\n", backups.Target)
+		for _, name := range backups.Backups {
+			fmt.Println("  " + name)
+		}
+		if l := backups.Last; l != nil && l.Error != "" {
+			fmt.Printf("Last run failed at %s: %s\n", l.At.Local().Format("2006-01-02 15:04"), l.Error)
+		}
+		return nil
+	}
+	res, err := c.client.Backup(ctx)
+	if err != nil || c.json {
+		return orJSON(res, err)
+	}
+	fmt.Printf("Backed up to %s/%s (%d bytes, %d config files).\n", res.Target, res.Name, res.SizeBytes, res.Files)
+	if len(res.Rotated) > 0 {
+		fmt.Printf("Rotated out: %s\n", strings.Join(res.Rotated, ", "))
+	}
+	return nil
+}
+
+func (c *cli) tools(ctx context.Context) error {
+	list, err := c.client.ListTools(ctx)
+	if err != nil || c.json {
+		return orJSON(list, err)
+	}
+	w := table("NAME", "KIND", "POLICY/STATUS")
+	for _, t := range list {
+		kind, state := "registered", t.Status
+		if t.Builtin {
+			kind, state = "built-in", t.Policy
+		}
+		if state == "" {
+			state = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, kind, state)
+	}
+	return w.Flush()
+}
+
+// table returns a tab writer on stdout with the header row written.
+func table(header ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	return w
+}
+
+// orJSON returns err, or prints v as JSON.
+func orJSON(v interface{}, err error) error {
+	if err != nil {
+		return err
+	}
+	return printJSON(v)
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
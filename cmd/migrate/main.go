@@ -0,0 +1,53 @@
+// migrate applies pending schema migrations to the HattieBot database, or lists them with -status.
+// hattiebot migrates on startup too; this is for upgrading or inspecting a database offline.
+// Usage: HATTIEBOT_CONFIG_DIR=/data migrate [-status]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func main() {
+	status := flag.Bool("status", false, "list migrations and when they were applied, without applying any")
+	flag.Parse()
+	cfg := config.New("")
+	ctx := context.Background()
+	db, err := store.OpenWithoutMigrating(ctx, cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	if *status {
+		list, err := db.MigrationStatus(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "status: %v\n", err)
+			os.Exit(1)
+		}
+		for _, m := range list {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%3d  %-40s %s\n", m.Version, m.Name, applied)
+		}
+		return
+	}
+	applied, err := db.Migrate(ctx)
+	for _, m := range applied {
+		fmt.Printf("applied %d (%s)\n", m.Version, m.Name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		os.Exit(1)
+	}
+	if len(applied) == 0 {
+		fmt.Println("schema is up to date")
+	}
+}
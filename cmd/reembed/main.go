@@ -0,0 +1,110 @@
+// reembed gives stored memories new vectors from the current embedding provider. Vectors of
+// another dimension or model cannot be compared with new queries, so after switching providers
+// recall misses older memories until they are re-embedded. Memories are embedded in batches and
+// progress is printed after each; a run that is stopped picks up where it left off. It can run
+// while HattieBot is up.
+// Usage: HATTIEBOT_CONFIG_DIR=/data reembed [-status] [-all] [-batch n] [-limit n] [-v]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/embeddingrouter"
+	"github.com/hattiebot/hattiebot/internal/memory"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func main() {
+	status := flag.Bool("status", false, "only report which models and dimensions the memories were embedded with")
+	all := flag.Bool("all", false, "re-embed every memory, not only those of another model or dimension")
+	batch := flag.Int("batch", memory.DefaultReembedBatch, "memories per batch")
+	limit := flag.Int("limit", 0, "stop after this many memories (0 = all)")
+	verbose := flag.Bool("v", false, "show log output, including memories that fail to embed")
+	flag.Parse()
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	cfg := config.New("")
+	if cf, _ := store.LoadConfigFile(cfg.ConfigDir); cf != nil {
+		cfg.OpenRouterAPIKey, cfg.Model = cf.OpenRouterAPIKey, cf.Model
+		if cf.EmbeddingServiceURL != "" {
+			cfg.EmbeddingServiceURL = cf.EmbeddingServiceURL
+		}
+		if cf.EmbeddingServiceAPIKey != "" {
+			cfg.EmbeddingServiceAPIKey = cf.EmbeddingServiceAPIKey
+		}
+		if cf.EmbeddingDimension > 0 {
+			cfg.EmbeddingDimension = cf.EmbeddingDimension
+		}
+	}
+	if cfg.OpenRouterAPIKey == "" {
+		cfg.OpenRouterAPIKey = os.Getenv("OPENROUTER_API_KEY")
+	}
+	if cfg.EmbeddingServiceURL == "" {
+		cfg.EmbeddingServiceURL = os.Getenv("EMBEDDING_SERVICE_URL")
+	}
+	if cfg.EmbeddingServiceAPIKey == "" {
+		cfg.EmbeddingServiceAPIKey = os.Getenv("EMBEDDING_SERVICE_API_KEY")
+	}
+	if cfg.OpenRouterBaseURL != "" {
+		openrouter.BaseURL = strings.TrimRight(cfg.OpenRouterBaseURL, "/")
+	}
+	llm := openrouter.NewClient(cfg.OpenRouterAPIKey, cfg.Model, cfg.ConfigDir)
+	embedder := embeddingrouter.Build(cfg.ConfigDir, cfg.EmbeddingServiceURL, cfg.EmbeddingServiceAPIKey, cfg.EmbeddingDimension, llm)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	db, err := store.Open(ctx, cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	st, err := memory.CheckEmbeddings(ctx, db, embedder)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check embeddings: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("current embedder: %s, %d dimensions\n", displayModel(st.Model), st.Dim)
+	for _, c := range st.Stored {
+		fmt.Printf("%8d memories  %s, %d dimensions\n", c.Chunks, displayModel(c.Model), c.Dim)
+	}
+	fmt.Printf("%d memories need re-embedding\n", st.Stale)
+	if *status || (st.Stale == 0 && !*all) {
+		return
+	}
+
+	p, err := memory.Reembed(ctx, db, embedder, memory.ReembedOptions{
+		All:       *all,
+		BatchSize: *batch,
+		Limit:     *limit,
+		Progress: func(p memory.ReembedProgress) {
+			fmt.Printf("re-embedded %d of %d (%d failed)\n", p.Done, p.Total, p.Failed)
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reembed: %v (re-embedded %d of %d; run again to continue)\n", err, p.Done, p.Total)
+		os.Exit(1)
+	}
+	fmt.Printf("done: re-embedded %d memories, %d failed\n", p.Done, p.Failed)
+	if p.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func displayModel(m string) string {
+	if m == "" {
+		return "unknown model"
+	}
+	return m
+}
@@ -0,0 +1,70 @@
+// restore puts a backup made by HattieBot (scheduled or backup_now) back in place. Stop HattieBot first.
+// The current database is moved to hattiebot.db.before-restore; config files from the backup
+// overwrite those in the config dir unless -db-only is given.
+// Usage: HATTIEBOT_CONFIG_DIR=/data HATTIEBOT_BACKUP_TARGET=... restore [-list] [-db-only] [-target spec] [name|latest]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hattiebot/hattiebot/internal/backup"
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func main() {
+	list := flag.Bool("list", false, "list the backups at the target and exit")
+	dbOnly := flag.Bool("db-only", false, "restore only the database, not the config files")
+	targetSpec := flag.String("target", "", "backup target (default HATTIEBOT_BACKUP_TARGET), e.g. local:/backups, nextcloud:/Backups, s3://bucket/prefix, store:name/folder")
+	flag.Parse()
+	cfg := config.New("")
+	if cf, err := store.LoadConfigFile(cfg.ConfigDir); err == nil && cf != nil {
+		// Named file stores (store:name targets) and Nextcloud credentials live in config.json
+		cfg.FileStores = cf.FileStores
+		if cf.NextcloudURL != "" {
+			cfg.NextcloudURL, cfg.NextcloudBotUser, cfg.NextcloudBotAppPassword = cf.NextcloudURL, cf.NextcloudBotUser, cf.NextcloudBotAppPassword
+		}
+	}
+	if *targetSpec != "" {
+		cfg.BackupTarget = *targetSpec
+	}
+	target, err := backup.NewTarget(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if target == nil {
+		fmt.Fprintf(os.Stderr, "no backup target: set HATTIEBOT_BACKUP_TARGET or use -target\n")
+		os.Exit(1)
+	}
+	ctx := context.Background()
+	if *list {
+		names, err := target.List(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "list: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return
+	}
+	name := flag.Arg(0)
+	if name == "" {
+		fmt.Fprintf(os.Stderr, "usage: restore [-list] [-db-only] [-target spec] <name|latest>\n")
+		os.Exit(1)
+	}
+	res, err := backup.Restore(ctx, target, name, cfg.DBPath, cfg.ConfigDir, *dbOnly)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("restored %s to %s\n", res.Name, res.Database)
+	if res.PreviousDB != "" {
+		fmt.Printf("previous database kept at %s\n", res.PreviousDB)
+	}
+	fmt.Printf("%d config files restored\n", len(res.ConfigFiles))
+}
@@ -57,6 +57,9 @@ class ChatMessageSentListener implements IEventListener {
 			'webhook_url' => getenv('HATTIEBOT_WEBHOOK_URL') ?: '',
 			'webhook_secret' => getenv('HATTIEBOT_WEBHOOK_SECRET') ?: '',
 			'hattie_user' => getenv('HATTIEBOT_HATTIE_USER') ?: '',
+			'client_cert' => getenv('HATTIEBOT_WEBHOOK_CLIENT_CERT') ?: '',
+			'client_key' => getenv('HATTIEBOT_WEBHOOK_CLIENT_KEY') ?: '',
+			'ca_cert' => getenv('HATTIEBOT_WEBHOOK_CA_CERT') ?: '',
 		];
 	}
 
@@ -160,6 +163,14 @@ class ChatMessageSentListener implements IEventListener {
 			CURLOPT_RETURNTRANSFER => true,
 			CURLOPT_TIMEOUT => 10,
 		]);
+		// Optional mTLS: HattieBot can require a client certificate on /webhook/talk
+		if (!empty($cfg['client_cert'])) {
+			curl_setopt($ch, CURLOPT_SSLCERT, $cfg['client_cert']);
+			curl_setopt($ch, CURLOPT_SSLKEY, $cfg['client_key'] ?: $cfg['client_cert']);
+		}
+		if (!empty($cfg['ca_cert'])) {
+			curl_setopt($ch, CURLOPT_CAINFO, $cfg['ca_cert']);
+		}
 
 		$response = curl_exec($ch);
 		$httpCode = curl_getinfo($ch, CURLINFO_HTTP_CODE);
@@ -0,0 +1,181 @@
+//go:build e2e
+
+// Package e2e drives a running bot stack end to end: the bot wired by cmd/hattiebot, a scripted
+// mock LLM and a Nextcloud Talk stub (see docker-compose.yml here and docs/e2e-testing.md).
+// Run with scripts/e2e.sh, or against an already running stack with:
+//
+//	go test -tags e2e ./e2e
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+var (
+	botURL        = env("HATTIEBOT_E2E_BOT_URL", "http://localhost:18080")
+	nextcloudURL  = env("HATTIEBOT_E2E_NEXTCLOUD_URL", "http://localhost:18081")
+	llmURL        = env("HATTIEBOT_E2E_LLM_URL", "http://localhost:18082")
+	webhookSecret = env("HATTIEBOT_E2E_WEBHOOK_SECRET", "e2e-secret")
+	botPurpose    = env("HATTIEBOT_E2E_PURPOSE", "end-to-end regression testing")
+)
+
+type talkMessage struct {
+	Room    string `json:"room"`
+	User    string `json:"user"`
+	Message string `json:"message"`
+	Edited  bool   `json:"edited"`
+}
+
+type llmRequest struct {
+	System   string `json:"system"`
+	LastRole string `json:"last_role"`
+	Last     string `json:"last"`
+	Tools    int    `json:"tools"`
+}
+
+func getJSON(t *testing.T, url string, out interface{}) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+}
+
+// eventually polls cond every second until it returns true or timeout passes.
+func eventually(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+	t.Fatalf("timed out after %v waiting for %s", timeout, what)
+}
+
+// roomMessage waits for a message in room containing want and returns it.
+func roomMessage(t *testing.T, room, want string, timeout time.Duration) talkMessage {
+	t.Helper()
+	var found talkMessage
+	eventually(t, timeout, fmt.Sprintf("a message containing %q in room %s", want, room), func() bool {
+		var msgs []talkMessage
+		getJSON(t, nextcloudURL+"/_stub/messages?room="+room, &msgs)
+		for _, m := range msgs {
+			if strings.Contains(m.Message, want) && !m.Edited {
+				found = m
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+func postTalkMessage(t *testing.T, secret, actor, room string, id int, content string) int {
+	t.Helper()
+	inner, _ := json.Marshal(map[string]interface{}{"message": content, "parameters": map[string]interface{}{}})
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type":   "Create",
+		"actor":  map[string]string{"type": "Person", "id": "users/" + actor, "name": actor},
+		"object": map[string]string{"type": "Note", "id": fmt.Sprint(id), "name": "message", "content": string(inner)},
+		"target": map[string]string{"type": "Collection", "id": room, "name": "E2E"},
+	})
+	req, _ := http.NewRequest(http.MethodPost, botURL+"/webhook/talk", strings.NewReader(string(payload)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-HattieBridge-Secret", secret)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post webhook: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestEndToEnd(t *testing.T) {
+	const room = "e2eroom"
+
+	t.Run("boot", func(t *testing.T) {
+		eventually(t, 3*time.Minute, "the bot's /health", func() bool {
+			resp, err := http.Get(botURL + "/health")
+			if err != nil {
+				return false
+			}
+			resp.Body.Close()
+			return resp.StatusCode == http.StatusOK
+		})
+		// Compose-mode seeding wrote the Nextcloud config, so the Talk channel is registered.
+		var status struct {
+			Channels []string `json:"channels"`
+		}
+		getJSON(t, botURL+"/status", &status)
+		if !strings.Contains(strings.Join(status.Channels, ","), "nextcloud_talk") {
+			t.Errorf("channels = %v, want nextcloud_talk", status.Channels)
+		}
+		// The seeded model was validated against the mock LLM at startup.
+		var reqs []llmRequest
+		getJSON(t, llmURL+"/_mock/requests", &reqs)
+		if len(reqs) == 0 || !strings.Contains(reqs[0].Last, "ping") {
+			t.Errorf("no startup model check reached the mock LLM: %+v", reqs)
+		}
+	})
+
+	t.Run("webhook_auth", func(t *testing.T) {
+		if code := postTalkMessage(t, "wrong-secret", "admin", room, 1, "hello"); code != http.StatusForbidden {
+			t.Errorf("wrong secret: HTTP %d, want 403", code)
+		}
+	})
+
+	t.Run("tool_calling_turn", func(t *testing.T) {
+		if code := postTalkMessage(t, webhookSecret, "admin", room, 2, "remind me in 3s: water the e2e plants"); code != http.StatusOK {
+			t.Fatalf("webhook: HTTP %d", code)
+		}
+		// The mock LLM calls manage_schedule; its result comes back as the final reply in the room.
+		reply := roomMessage(t, room, "Done:", 2*time.Minute)
+		if !strings.Contains(reply.Message, "scheduled") {
+			t.Errorf("reply = %q, want the manage_schedule result", reply.Message)
+		}
+		var reqs []llmRequest
+		getJSON(t, llmURL+"/_mock/requests", &reqs)
+		sawPrompt, sawToolResult := false, false
+		for _, r := range reqs {
+			// SOUL.md written at first boot is part of the system prompt.
+			if strings.Contains(r.Last, "water the e2e plants") && r.Tools > 0 && strings.Contains(r.System, botPurpose) {
+				sawPrompt = true
+			}
+			if r.LastRole == "tool" && strings.Contains(r.Last, "scheduled") {
+				sawToolResult = true
+			}
+		}
+		if !sawPrompt || !sawToolResult {
+			t.Errorf("LLM requests: seeded prompt with tools %v, tool result %v", sawPrompt, sawToolResult)
+		}
+	})
+
+	t.Run("scheduler_delivery", func(t *testing.T) {
+		// The reminder is delivered proactively to the room the admin last wrote from.
+		roomMessage(t, room, "[Scheduled Reminder] water the e2e plants", 90*time.Second)
+	})
+}
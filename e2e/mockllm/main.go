@@ -0,0 +1,159 @@
+// Command mockllm is a scripted OpenAI-compatible chat completions server for the e2e harness.
+// It stands in for OpenRouter (OPENROUTER_BASE_URL) and answers deterministically:
+//
+//   - "remind me in <delay>: <text>" (with tools offered) → a manage_schedule create tool call
+//   - a tool result → "Done: <tool output>"
+//   - anything else → "pong: <message>"
+//
+// GET /_mock/requests returns the recorded requests so tests can check what the bot sent.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type message struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content,omitempty"`
+	ToolCalls  []toolCall      `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatRequest struct {
+	Model    string            `json:"model"`
+	Messages []message         `json:"messages"`
+	Tools    []json.RawMessage `json:"tools,omitempty"`
+}
+
+// recorded is what /_mock/requests returns for each completion request.
+type recorded struct {
+	Model    string `json:"model"`
+	System   string `json:"system"`
+	LastRole string `json:"last_role"`
+	Last     string `json:"last"`
+	Tools    int    `json:"tools"`
+}
+
+var remindRe = regexp.MustCompile(`remind me in (\S+): (.+)`)
+
+type server struct {
+	mu       sync.Mutex
+	requests []recorded
+	calls    int
+}
+
+// text returns a message's content as plain text (string or array of text parts).
+func text(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Text string `json:"text"`
+	}
+	_ = json.Unmarshal(raw, &parts)
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString(p.Text)
+	}
+	return b.String()
+}
+
+func (s *server) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+		http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+		return
+	}
+	last := req.Messages[len(req.Messages)-1]
+	rec := recorded{Model: req.Model, LastRole: last.Role, Last: text(last.Content), Tools: len(req.Tools)}
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			rec.System += text(m.Content) + "\n"
+		}
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, rec)
+	s.calls++
+	id := s.calls
+	s.mu.Unlock()
+
+	reply := message{Role: "assistant"}
+	finish := "stop"
+	switch {
+	case last.Role == "tool":
+		out := rec.Last
+		if len(out) > 500 {
+			out = out[:500]
+		}
+		reply.Content, _ = json.Marshal("Done: " + out)
+	case len(req.Tools) > 0 && remindRe.MatchString(rec.Last):
+		m := remindRe.FindStringSubmatch(rec.Last)
+		args, _ := json.Marshal(map[string]string{
+			"action":        "create",
+			"action_type":   "remind",
+			"schedule_type": "once",
+			"run_at":        m[1],
+			"description":   strings.TrimSpace(m[2]),
+		})
+		tc := toolCall{ID: fmt.Sprintf("call_%d", id), Type: "function"}
+		tc.Function.Name = "manage_schedule"
+		tc.Function.Arguments = string(args)
+		reply.ToolCalls = []toolCall{tc}
+		reply.Content, _ = json.Marshal("")
+		finish = "tool_calls"
+	default:
+		reply.Content, _ = json.Marshal("pong: " + strings.TrimSpace(rec.Last))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      fmt.Sprintf("mock-%d", id),
+		"model":   req.Model,
+		"choices": []interface{}{map[string]interface{}{"index": 0, "message": reply, "finish_reason": finish}},
+		"usage":   map[string]interface{}{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15, "cost": 0},
+	})
+}
+
+func (s *server) handleRequests(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.requests)
+}
+
+func main() {
+	addr := os.Getenv("MOCK_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	s := &server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_mock/requests", s.handleRequests)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			s.handleCompletions(w, r)
+			return
+		}
+		// Embeddings, credits, model pricing: not mocked; the bot degrades gracefully.
+		log.Printf("[mockllm] %s %s: not mocked", r.Method, r.URL.Path)
+		http.Error(w, `{"error":{"message":"not mocked"}}`, http.StatusNotFound)
+	})
+	log.Printf("[mockllm] listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
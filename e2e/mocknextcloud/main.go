@@ -0,0 +1,105 @@
+// Command mocknextcloud is a minimal Nextcloud Talk stub for the e2e harness. It answers the
+// readiness check and records the chat messages, edits and reactions the bot posts as its Talk user.
+//
+// GET /_stub/messages?room=<token> returns the recorded messages (all rooms when room is empty).
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// posted is one message the bot sent (or edited) in a room.
+type posted struct {
+	ID      int64     `json:"id"`
+	Room    string    `json:"room"`
+	User    string    `json:"user"`
+	Message string    `json:"message"`
+	Edited  bool      `json:"edited,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+type stub struct {
+	mu       sync.Mutex
+	nextID   int64
+	messages []posted
+}
+
+const chatPrefix = "/ocs/v2.php/apps/spreed/api/v1/chat/"
+
+func ocs(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ocs": map[string]interface{}{"meta": map[string]interface{}{"status": "ok", "statuscode": status}, "data": data},
+	})
+}
+
+func (s *stub) handleChat(w http.ResponseWriter, r *http.Request) {
+	user, _, _ := r.BasicAuth()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, chatPrefix), "/")
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		ocs(w, http.StatusBadRequest, nil)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 1:
+		s.nextID++
+		s.messages = append(s.messages, posted{ID: s.nextID, Room: parts[0], User: user, Message: body.Message, At: time.Now()})
+		log.Printf("[mocknextcloud] %s -> %s: %q", user, parts[0], body.Message)
+		ocs(w, http.StatusCreated, map[string]interface{}{"id": s.nextID})
+	case r.Method == http.MethodPut && len(parts) == 2:
+		s.messages = append(s.messages, posted{Room: parts[0], User: user, Message: body.Message, Edited: true, At: time.Now()})
+		ocs(w, http.StatusOK, nil)
+	default:
+		ocs(w, http.StatusNotFound, nil)
+	}
+}
+
+func (s *stub) handleMessages(w http.ResponseWriter, r *http.Request) {
+	room := r.URL.Query().Get("room")
+	s.mu.Lock()
+	out := []posted{}
+	for _, m := range s.messages {
+		if room == "" || m.Room == room {
+			out = append(out, m)
+		}
+	}
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func main() {
+	addr := os.Getenv("MOCK_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	s := &stub{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status.php", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"installed":true,"maintenance":false,"needsDbUpgrade":false,"version":"29.0.0.0","productname":"Nextcloud"}`))
+	})
+	mux.HandleFunc(chatPrefix, s.handleChat)
+	mux.HandleFunc("/ocs/v2.php/apps/spreed/api/v1/reaction/", func(w http.ResponseWriter, r *http.Request) {
+		ocs(w, http.StatusCreated, map[string]interface{}{})
+	})
+	mux.HandleFunc("/_stub/messages", s.handleMessages)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[mocknextcloud] %s %s: not stubbed", r.Method, r.URL.Path)
+		ocs(w, http.StatusNotFound, nil)
+	})
+	log.Printf("[mocknextcloud] listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// groupParticipantWindow is how many recent user messages decide whether a thread is a group
+// conversation: one where more than one person wrote.
+const groupParticipantWindow = 50
+
+// followUpWindow is how long after its reply to someone the bot treats that person's next message
+// in a group room as addressed to it.
+const followUpWindow = 3 * time.Minute
+
+// classifyTimeout bounds the classifier call for unclear group messages.
+const classifyTimeout = 15 * time.Second
+
+// Addressed reports whether msg is meant for the agent. Messages in one-to-one threads, and urgent
+// ones (gateway.IsUrgent), always are. In group threads (more than one person has written) it
+// depends on the room's addressing mode (manage_thread, else Config.GroupAddressing): "all"
+// answers everything, "mention" only messages that name or @-mention the bot, and "auto" also
+// follow-ups to its last reply and, with Config.GroupClassifier, messages the model judges
+// addressed to it.
+func (l *Loop) Addressed(ctx context.Context, msg gateway.Message) bool {
+	if msg.Autonomous || msg.Urgent || msg.ThreadID == "" {
+		return true
+	}
+	mode := l.Config.GroupAddressing
+	if s := threadSettingsFor(ctx, l.DB, msg); s != nil && s.Addressing != "" {
+		mode = s.Addressing
+	}
+	if mode == "all" || mode == "" {
+		return true
+	}
+	userID, err := l.DB.ResolveIdentity(ctx, msg.Channel, msg.SenderID)
+	if err != nil {
+		userID = msg.SenderID
+	}
+	others, err := l.DB.ThreadParticipants(ctx, msg.Channel, msg.ThreadID, groupParticipantWindow)
+	if err != nil {
+		log.Printf("[AGENT] Failed to load participants of thread %s: %v", msg.ThreadID, err)
+		return true
+	}
+	if !isGroup(others, userID) {
+		return true
+	}
+	if l.mentionsBot(msg) {
+		return true
+	}
+	if mode != "auto" {
+		return false
+	}
+	history, err := l.DB.ThreadHistory(ctx, msg.ThreadID, 10)
+	if err != nil {
+		log.Printf("[AGENT] Failed to load history of thread %s: %v", msg.ThreadID, err)
+		return false
+	}
+	if isFollowUp(history, userID, time.Now()) {
+		return true
+	}
+	if !l.Config.GroupClassifier {
+		return false
+	}
+	return l.classifyAddressed(ctx, history, msg)
+}
+
+// isGroup reports whether someone other than userID wrote among participants.
+func isGroup(participants []string, userID string) bool {
+	for _, p := range participants {
+		if p != userID {
+			return true
+		}
+	}
+	return false
+}
+
+// botNames are the names the bot answers to: its agent name and its chat user.
+func (l *Loop) botNames() []string {
+	var names []string
+	for _, n := range []string{l.Config.AgentName, l.Config.NextcloudBotUser} {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// mentionsBot reports whether msg @-mentions the bot (or everyone), names it as a word, or is a
+// chat command ("/regenerate").
+func (l *Loop) mentionsBot(msg gateway.Message) bool {
+	names := l.botNames()
+	for _, m := range msg.Mentioned {
+		if m == "all" {
+			return true
+		}
+		for _, n := range names {
+			if strings.EqualFold(m, n) {
+				return true
+			}
+		}
+	}
+	content := strings.TrimSpace(msg.Content)
+	if strings.HasPrefix(content, "/") {
+		return true
+	}
+	for _, n := range names {
+		if namePattern(n).MatchString(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// namePattern matches name (or "@name") as a whole word, ignoring case.
+func namePattern(name string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|[^\pL\pN_])@?` + regexp.QuoteMeta(name) + `($|[^\pL\pN_])`)
+}
+
+// isFollowUp reports whether the thread's last message is the bot's reply to userID, sent within
+// followUpWindow of now: a conversation the bot is part of, so the next message is likely for it.
+func isFollowUp(history []store.Message, userID string, now time.Time) bool {
+	for i := len(history) - 1; i >= 0; i-- {
+		m := history[i]
+		if m.Role == "tool" || (m.Role == "assistant" && m.Content == "") {
+			continue
+		}
+		if m.Role != "assistant" || now.Sub(m.CreatedAt) > followUpWindow {
+			return false
+		}
+		// Whom the reply answered: the user message before it
+		for j := i - 1; j >= 0; j-- {
+			if history[j].Role == "user" {
+				return history[j].SenderID == userID
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// classifyAddressed asks the model whether msg is addressed to the bot, given the last messages.
+// Errors count as "no": in a group, staying quiet is the safer mistake.
+func (l *Loop) classifyAddressed(ctx context.Context, history []store.Message, msg gateway.Message) bool {
+	client := l.Client
+	if l.CheapClient != nil {
+		client = l.CheapClient
+	}
+	var b strings.Builder
+	for _, m := range history {
+		switch m.Role {
+		case "user":
+			fmt.Fprintf(&b, "%s: %s\n", m.SenderID, truncateRunes(m.Content, 300))
+		case "assistant":
+			if m.Content != "" {
+				fmt.Fprintf(&b, "assistant: %s\n", truncateRunes(m.Content, 300))
+			}
+		}
+	}
+	name := strings.Join(l.botNames(), " / ")
+	if name == "" {
+		name = "the assistant"
+	}
+	prompt := fmt.Sprintf("Several people talk in this group chat, which includes an AI assistant (%s). Recent messages:\n%s\nNew message from %s: %s\n\nIs the new message addressed to the assistant (a request, question or reply meant for it), rather than to the other people? Answer only yes or no.",
+		name, b.String(), msg.SenderID, truncateRunes(msg.Content, 1000))
+	cctx, cancel := context.WithTimeout(ctx, classifyTimeout)
+	defer cancel()
+	answer, err := client.ChatCompletion(cctx, []openrouter.Message{{Role: "user", Content: prompt}})
+	if err != nil {
+		log.Printf("[AGENT] Group message classifier failed: %v", err)
+		return false
+	}
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "yes")
+}
+
+// RecordPassive stores a group message the agent was not addressed in, so later turns see it as
+// context. Senders the bot does not serve (blocked, restricted pending approval, or never seen
+// and so not approved) are not recorded.
+func (l *Loop) RecordPassive(ctx context.Context, msg gateway.Message) {
+	userID, err := l.DB.ResolveIdentity(ctx, msg.Channel, msg.SenderID)
+	if err != nil {
+		userID = msg.SenderID
+	}
+	if u, err := l.DB.GetUser(ctx, userID); err != nil || u.TrustLevel == "blocked" || u.TrustLevel == "restricted" {
+		return
+	}
+	if _, err := l.DB.InsertMessage(ctx, "user", passiveContent(msg.SenderID, msg.Content), "", userID, msg.Channel, msg.ThreadID, "", "", ""); err != nil {
+		log.Printf("[AGENT] Failed to record group message in %s: %v", msg.ThreadID, err)
+	}
+}
+
+// MayInterrupt reports whether msg's sender may interrupt another sender's running turn with an
+// urgent message (gateway.SetPreemption): approved users only, not blocked, restricted or unknown
+// senders.
+func (l *Loop) MayInterrupt(ctx context.Context, msg gateway.Message) bool {
+	userID, err := l.DB.ResolveIdentity(ctx, msg.Channel, msg.SenderID)
+	if err != nil {
+		userID = msg.SenderID
+	}
+	u, err := l.DB.GetUser(ctx, userID)
+	return err == nil && u.TrustLevel != "blocked" && u.TrustLevel != "restricted"
+}
+
+// passiveContent marks a recorded group message with its sender, since the model sees messages
+// without their senders, and as not addressed to the bot.
+func passiveContent(sender, content string) string {
+	return fmt.Sprintf("[%s, to the group]: %s", sender, content)
+}
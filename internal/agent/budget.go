@@ -0,0 +1,246 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+)
+
+// Prompt sections, as BuildSystemPrompt and the loop assemble them.
+const (
+	SectionIdentity     = "identity"     // SOUL.md
+	SectionRuntime      = "runtime"      // time, OS, paths
+	SectionContext      = "context"      // active job, project, room settings, broken tools
+	SectionTools        = "tools"        // registered tools
+	SectionDocs         = "docs"         // context documents
+	SectionSetup        = "setup"        // the setup checklist
+	SectionInstructions = "instructions" // StaticInstructions
+	SectionUser         = "user"         // who the user is
+	SectionFacts        = "facts"        // memorized facts about the user
+	SectionTurn         = "turn"         // profile, pending items and notes for this turn
+	SectionHistory      = "history"      // the conversation so far
+)
+
+// PromptSection is one part of the system prompt.
+type PromptSection struct {
+	Name string
+	Text string
+}
+
+// JoinSections returns the prompt text of sections, in order.
+func JoinSections(sections []PromptSection) string {
+	var b strings.Builder
+	for _, s := range sections {
+		b.WriteString(s.Text)
+	}
+	return b.String()
+}
+
+// sectionBudget is a section's share of the context budget when the prompt is too long, and its
+// priority: sections over their share are trimmed lowest priority first.
+type sectionBudget struct {
+	share    float64
+	priority int
+}
+
+// sectionBudgets add up to 1, so trimming every section to its share always fits the budget.
+var sectionBudgets = map[string]sectionBudget{
+	SectionDocs:         {0.10, 1},
+	SectionTools:        {0.08, 2},
+	SectionSetup:        {0.02, 3},
+	SectionFacts:        {0.06, 4},
+	SectionContext:      {0.08, 5},
+	SectionHistory:      {0.40, 6},
+	SectionIdentity:     {0.12, 7},
+	SectionInstructions: {0.08, 8},
+	SectionTurn:         {0.04, 9},
+	SectionUser:         {0.01, 10},
+	SectionRuntime:      {0.01, 10},
+}
+
+// trimmedNote ends a section that was cut to fit the budget.
+const trimmedNote = "\n[... trimmed to fit the context budget]\n"
+
+// estimateTokens approximates the tokens of s, like the compactor: about four bytes per token.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// messageTokens approximates a message's tokens, with a few for its role and framing.
+func messageTokens(m openrouter.Message) int {
+	n := 4 + estimateTokens(m.Content)
+	for _, tc := range m.ToolCalls {
+		n += estimateTokens(tc.Function.Name) + estimateTokens(tc.Function.Arguments)
+	}
+	return n
+}
+
+// SectionUsage is what the budget did to one section.
+type SectionUsage struct {
+	Name    string
+	Tokens  int // before trimming
+	Budget  int // its share of the budget
+	Kept    int // after trimming
+	Dropped int // history: messages dropped
+}
+
+// BudgetReport says how a prompt was fitted into the context budget.
+type BudgetReport struct {
+	Budget   int // tokens for the sections and history
+	Fixed    int // tokens that cannot be trimmed: the new message and tool definitions
+	Total    int // before trimming
+	Sections []SectionUsage
+}
+
+// Trimmed reports whether any section was cut.
+func (r BudgetReport) Trimmed() bool {
+	for _, s := range r.Sections {
+		if s.Kept < s.Tokens {
+			return true
+		}
+	}
+	return false
+}
+
+// String summarizes the trimmed sections, e.g. "docs 9000→1200, history 30000→12000 (14 messages dropped)".
+func (r BudgetReport) String() string {
+	var parts []string
+	for _, s := range r.Sections {
+		if s.Kept >= s.Tokens {
+			continue
+		}
+		part := fmt.Sprintf("%s %d→%d", s.Name, s.Tokens, s.Kept)
+		if s.Kept == 0 {
+			part = fmt.Sprintf("%s %d→dropped", s.Name, s.Tokens)
+		}
+		if s.Dropped > 0 {
+			part += fmt.Sprintf(" (%d messages dropped)", s.Dropped)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FitContext fits the system prompt sections and the history into budget tokens, less fixed
+// tokens for what cannot be trimmed. When they are too long, each section over its share of the
+// budget is cut down towards that share, lowest priority first, until everything fits: text
+// sections lose their last lines and the history its oldest messages. A budget of 0 leaves the
+// prompt as it is.
+func FitContext(sections []PromptSection, history []openrouter.Message, budget, fixed int) ([]PromptSection, []openrouter.Message, BudgetReport) {
+	report := BudgetReport{Budget: budget - fixed, Fixed: fixed}
+	usage := make([]SectionUsage, 0, len(sections)+1)
+	for _, s := range sections {
+		n := estimateTokens(s.Text)
+		usage = append(usage, SectionUsage{Name: s.Name, Tokens: n, Kept: n})
+		report.Total += n
+	}
+	historyTokens := 0
+	for _, m := range history {
+		historyTokens += messageTokens(m)
+	}
+	usage = append(usage, SectionUsage{Name: SectionHistory, Tokens: historyTokens, Kept: historyTokens})
+	report.Total += historyTokens
+	report.Sections = usage
+	if budget <= 0 || report.Total <= report.Budget {
+		return sections, history, report
+	}
+	if report.Budget <= 0 {
+		log.Printf("[AGENT] Context budget: the message and tool definitions alone take %d of %d tokens", fixed, budget)
+		report.Budget = 0
+	}
+
+	// Trim lowest priority first
+	order := make([]int, len(usage))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return sectionBudgets[usage[order[a]].Name].priority < sectionBudgets[usage[order[b]].Name].priority
+	})
+	out := append([]PromptSection(nil), sections...)
+	excess := report.Total - report.Budget
+	for _, i := range order {
+		if excess <= 0 {
+			break
+		}
+		u := &usage[i]
+		u.Budget = int(sectionBudgets[u.Name].share * float64(report.Budget))
+		if u.Tokens <= u.Budget {
+			continue
+		}
+		target := u.Tokens - excess
+		if target < u.Budget {
+			target = u.Budget
+		}
+		if u.Name == SectionHistory {
+			history, u.Dropped = trimHistory(history, target)
+			u.Kept = 0
+			for _, m := range history {
+				u.Kept += messageTokens(m)
+			}
+		} else {
+			out[i].Text = trimText(out[i].Text, target)
+			u.Kept = estimateTokens(out[i].Text)
+		}
+		excess -= u.Tokens - u.Kept
+	}
+	for i := range usage {
+		if usage[i].Budget == 0 {
+			usage[i].Budget = int(sectionBudgets[usage[i].Name].share * float64(report.Budget))
+		}
+	}
+	return out, history, report
+}
+
+// trimText cuts text to at most tokens, at a line break, and marks it as trimmed; "" when not
+// even one line fits.
+func trimText(text string, tokens int) string {
+	if estimateTokens(text) <= tokens {
+		return text
+	}
+	limit := tokens*4 - len(trimmedNote)
+	if limit <= 0 {
+		return ""
+	}
+	cut := strings.LastIndex(text[:limit], "\n")
+	if cut <= 0 {
+		return ""
+	}
+	return text[:cut] + trimmedNote
+}
+
+// trimHistory drops the oldest messages until the rest take at most tokens, and returns how many
+// it dropped. Tool results whose call was dropped go with it.
+func trimHistory(history []openrouter.Message, tokens int) ([]openrouter.Message, int) {
+	total := 0
+	for _, m := range history {
+		total += messageTokens(m)
+	}
+	start := 0
+	for start < len(history) && (total > tokens || history[start].Role == "tool") {
+		total -= messageTokens(history[start])
+		start++
+	}
+	return history[start:], start
+}
+
+// fitContext applies the configured context budget to a turn's prompt and logs what it trimmed.
+func (l *Loop) fitContext(sections []PromptSection, history []openrouter.Message, content string, toolDefs []openrouter.ToolDefinition) (string, []openrouter.Message) {
+	budget := 0
+	if l.Config != nil {
+		budget = l.Config.ContextBudget
+	}
+	fixed := messageTokens(openrouter.Message{Role: "user", Content: content})
+	if b, err := json.Marshal(toolDefs); err == nil {
+		fixed += estimateTokens(string(b))
+	}
+	sections, history, report := FitContext(sections, history, budget, fixed)
+	if report.Trimmed() {
+		log.Printf("[AGENT] Context budget: prompt of %d tokens over the %d available; trimmed %s", report.Total, report.Budget, report)
+	}
+	return JoinSections(sections), history
+}
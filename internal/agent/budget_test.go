@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+)
+
+// lines returns n lines of about 40 bytes (10 tokens) each.
+func lines(prefix string, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString(prefix + " line with some filler text here.....\n")
+	}
+	return b.String()
+}
+
+func TestFitContextUnderBudget(t *testing.T) {
+	sections := []PromptSection{{SectionIdentity, lines("soul", 10)}, {SectionDocs, lines("doc", 10)}}
+	history := []openrouter.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}
+	for _, budget := range []int{0, 1000} {
+		out, hist, report := FitContext(sections, history, budget, 50)
+		if JoinSections(out) != JoinSections(sections) || len(hist) != 2 || report.Trimmed() {
+			t.Errorf("budget %d changed the prompt: %s", budget, report)
+		}
+	}
+}
+
+func TestFitContextTrimsLowestPriorityFirst(t *testing.T) {
+	identity := lines("soul", 50)
+	sections := []PromptSection{
+		{SectionIdentity, identity},
+		{SectionTools, lines("tool", 20)},
+		{SectionDocs, lines("doc", 400)},
+		{SectionFacts, lines("fact", 10)},
+	}
+	var history []openrouter.Message
+	for i := 0; i < 40; i++ {
+		history = append(history, openrouter.Message{Role: "user", Content: lines("question", 3)})
+		history = append(history,
+			openrouter.Message{Role: "assistant", ToolCalls: []openrouter.ToolCall{{ID: "c"}}},
+			openrouter.Message{Role: "tool", ToolCallID: "c", Content: lines("result", 2)},
+			openrouter.Message{Role: "assistant", Content: "done"})
+	}
+
+	// Docs (about 4000 tokens) and history (about 2800) make it too long; trimming the docs to
+	// their share is not enough, so the history loses old messages too, but the identity stays whole
+	out, hist, report := FitContext(sections, history, 3000, 200)
+	if report.Budget != 2800 {
+		t.Fatalf("budget = %d", report.Budget)
+	}
+	used := estimateTokens(JoinSections(out))
+	for _, m := range hist {
+		used += messageTokens(m)
+	}
+	if used > report.Budget {
+		t.Errorf("%d tokens used of %d: %s", used, report.Budget, report)
+	}
+	if out[0].Text != identity || out[1].Text != sections[1].Text || out[3].Text != sections[3].Text {
+		t.Error("a section within its share was trimmed")
+	}
+	if docs := estimateTokens(out[2].Text); docs > 280 || !strings.HasSuffix(out[2].Text, trimmedNote) || !strings.HasPrefix(out[2].Text, "doc line") {
+		t.Errorf("docs kept %d tokens: %q...", docs, out[2].Text[:40])
+	}
+	if len(hist) == 0 || len(hist) == len(history) || hist[0].Role == "tool" {
+		t.Errorf("history kept %d of %d messages, starting with %q", len(hist), len(history), hist[0].Role)
+	}
+	if hist[len(hist)-1].Content != "done" {
+		t.Error("the newest messages were dropped")
+	}
+	if s := report.String(); !strings.Contains(s, "docs 4100→") || !strings.Contains(s, "messages dropped") || strings.Contains(s, "identity") {
+		t.Errorf("report = %s", s)
+	}
+}
+
+func TestFitContextDropsSection(t *testing.T) {
+	// One long line cannot be cut at a line break, so the section goes
+	sections := []PromptSection{{SectionRuntime, "\n\nruntime\n"}, {SectionDocs, strings.Repeat("x", 4000)}}
+	out, _, report := FitContext(sections, nil, 100, 0)
+	if out[1].Text != "" || out[0].Text != sections[0].Text {
+		t.Errorf("sections = %q", out)
+	}
+	if s := report.String(); s != "docs 1000→dropped" {
+		t.Errorf("report = %s", s)
+	}
+}
+
+func TestLoopAppliesContextBudget(t *testing.T) {
+	ctx := context.Background()
+	db := SetupTestDB(t)
+	defer db.Close()
+	if _, err := db.CreateContextDoc(ctx, "manual", strings.Repeat("A long manual line about everything.\n", 50000)+"END OF MANUAL\n", "the manual"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetContextDocActive(ctx, "manual", true); err != nil {
+		t.Fatal(err)
+	}
+	client := &countingClient{}
+	loop := &Loop{
+		Config:   &config.Config{AdminUserID: "admin", Model: "mock-model", ContextBudget: 200000},
+		DB:       db,
+		Client:   client,
+		Context:  &ContextManager{DB: db},
+		Executor: &MockExecutor{},
+	}
+	if _, err := loop.RunOneTurn(ctx, gateway.Message{SenderID: "admin", Channel: "test", ThreadID: "t1", Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	system := client.last[0].Content
+	if strings.Contains(system, "END OF MANUAL") || !strings.Contains(system, trimmedNote) {
+		t.Error("the oversized context document was not trimmed")
+	}
+	for _, want := range []string{"== RUNTIME ==", "User Context:", "Create Tools Autonomously"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system prompt lost %q", want)
+		}
+	}
+	if n := estimateTokens(system); n > 200000 {
+		t.Errorf("system prompt is %d tokens", n)
+	}
+}
@@ -3,6 +3,7 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/hattiebot/hattiebot/internal/core"
 	"github.com/hattiebot/hattiebot/internal/openrouter"
@@ -23,11 +24,18 @@ type ContextManager struct {
 
 // SelectHistory returns the most recent N messages for the thread that likely fit within the token limit.
 // For now, we use a simple message count limit (e.g. 20) as a proxy for token limit.
+// Regenerated replies are left out, and a branched thread starts with its parent's history.
 func (cm *ContextManager) SelectHistory(ctx context.Context, threadID string) ([]openrouter.Message, error) {
 	// Hardcoded limit for now - in future, estimate tokens.
 	const MessageLimit = 30 // Keep last 30 messages (~3-5k tokens usually)
 
-	recent, err := cm.DB.RecentMessages(ctx, MessageLimit, threadID)
+	var recent []store.Message
+	var err error
+	if threadID != "" {
+		recent, err = cm.DB.ThreadHistory(ctx, threadID, MessageLimit)
+	} else {
+		recent, err = cm.DB.RecentMessages(ctx, MessageLimit, threadID)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -49,5 +57,12 @@ func (cm *ContextManager) SelectHistory(ctx context.Context, threadID string) ([
 
 		messages = append(messages, msg)
 	}
+	// Messages removed by the retention policy live on as the thread's summary
+	if summary, err := cm.DB.LatestConversationSummary(ctx, threadID); err == nil && summary != nil {
+		messages = append([]openrouter.Message{{
+			Role:    "system",
+			Content: fmt.Sprintf("Summary of this conversation before %s (older messages were deleted under the retention policy):\n%s", summary.CoversUntil.Format("2006-01-02"), summary.Content),
+		}}, messages...)
+	}
 	return messages, nil
 }
@@ -0,0 +1,27 @@
+package agent
+
+import "strings"
+
+// DryRunCommand, put before a request, runs that turn as a dry run: restricted tools describe what
+// they would do instead of running (see middleware.WithDryRun), so a complex plan can be reviewed
+// before it runs for real. The request is kept as sent, so the next turn knows it was a dry run.
+const DryRunCommand = "/dryrun"
+
+// dryRunUsage answers a /dryrun without a request.
+const dryRunUsage = "Usage: /dryrun <request>, e.g. \"/dryrun migrate my files to the new folder\". I will plan it and show every step I would take, without changing anything."
+
+// dryRunPrompt tells the model how to handle a dry-run turn.
+const dryRunPrompt = "\n\n[DRY RUN]: This turn is a dry run. Read-only tools work as usual, but restricted tools (commands, file writes, messages, API calls, registered tools) are not executed: they return a description of what they would do. Plan the whole task as if they succeeded, then reply with a numbered list of every action you would take (tool, command or path, target), and ask the user to send the request without /dryrun to run it for real."
+
+// parseDryRunCommand reports whether content starts with /dryrun and returns the request after it.
+func parseDryRunCommand(content string) (request string, ok bool) {
+	content = strings.TrimSpace(content)
+	if len(content) < len(DryRunCommand) || !strings.EqualFold(content[:len(DryRunCommand)], DryRunCommand) {
+		return "", false
+	}
+	rest := content[len(DryRunCommand):]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' && rest[0] != '\n' {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
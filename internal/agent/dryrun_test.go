@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/middleware"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/tools"
+)
+
+// commandClient runs one terminal command per turn, then answers with the tool's result.
+type commandClient struct {
+	MockClient
+	system string
+}
+
+func (c *commandClient) ChatCompletionWithTools(ctx context.Context, msgs []openrouter.Message, defs []openrouter.ToolDefinition) (string, []openrouter.ToolCall, error) {
+	c.system = msgs[0].Content
+	if last := msgs[len(msgs)-1]; last.Role == "tool" {
+		return last.Content, nil, nil
+	}
+	call := openrouter.ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "run_terminal_cmd"
+	call.Function.Arguments = `{"command": "mv ~/files /mnt/new"}`
+	return "", []openrouter.ToolCall{call}, nil
+}
+
+func TestDryRunCommandSimulatesRestrictedTools(t *testing.T) {
+	ctx := context.Background()
+	db := SetupTestDB(t)
+	defer db.Close()
+	client := &commandClient{}
+	raw := &MockExecutor{}
+	loop := &Loop{
+		Config:   &config.Config{AdminUserID: "admin", Model: "mock-model", ConfigDir: t.TempDir()},
+		DB:       db,
+		Client:   client,
+		Context:  &ContextManager{DB: db},
+		Executor: middleware.NewPolicyMiddleware(raw, tools.BuiltinToolDefs(), nil),
+	}
+	msg := gateway.Message{SenderID: "admin", Channel: "test", ThreadID: "t1"}
+
+	msg.Content = "/dryrun"
+	if reply, _ := loop.RunOneTurn(ctx, msg); reply != dryRunUsage {
+		t.Errorf("bare /dryrun = %q", reply)
+	}
+	msg.Content = "/dryrun move my files to the new disk"
+	reply, err := loop.RunOneTurn(ctx, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.LastToolCalled != "" || !strings.Contains(reply, `"dry_run":true`) || !strings.Contains(reply, "mv ~/files /mnt/new") {
+		t.Errorf("dry run executed %q or described it as %s", raw.LastToolCalled, reply)
+	}
+	if !strings.Contains(client.system, "[DRY RUN]") {
+		t.Error("the model was not told the turn is a dry run")
+	}
+
+	msg.Content = "move my files to the new disk"
+	if reply, _ := loop.RunOneTurn(ctx, msg); reply != "mock_result" || raw.LastToolCalled != "run_terminal_cmd" {
+		t.Errorf("real run = %q, executed %q", reply, raw.LastToolCalled)
+	}
+	if strings.Contains(client.system, "[DRY RUN]") {
+		t.Error("a normal turn was marked as a dry run")
+	}
+	if _, ok := parseDryRunCommand("/dryrunner"); ok {
+		t.Error("/dryrunner parsed as /dryrun")
+	}
+}
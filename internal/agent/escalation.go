@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// parseAckCommand recognizes a reply acknowledging escalations: "ack", or "ack <id>" for one.
+func parseAckCommand(content string) (id int64, ok bool) {
+	fields := strings.Fields(strings.TrimSpace(content))
+	if len(fields) == 0 || len(fields) > 2 || !strings.EqualFold(strings.TrimPrefix(fields[0], "/"), "ack") {
+		return 0, false
+	}
+	if len(fields) == 2 {
+		n, err := strconv.ParseInt(strings.TrimPrefix(fields[1], "#"), 10, 64)
+		if err != nil || n <= 0 {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, true
+}
+
+// acknowledgeEscalations stops the escalations the user may clear and returns a notice, or ""
+// when a bare "ack" found nothing to acknowledge and the turn should go on as a normal message.
+func (l *Loop) acknowledgeEscalations(ctx context.Context, user *store.User, id int64) string {
+	admin := user.TrustLevel == "admin" || store.RoleAtLeast(user.Role, store.RoleAdmin)
+	acked, err := l.DB.AcknowledgeEscalations(ctx, user.ID, admin, id)
+	if err != nil {
+		log.Printf("[AGENT] Acknowledging escalations: %v", err)
+		return "I could not record your acknowledgement."
+	}
+	if len(acked) == 0 {
+		if id != 0 {
+			return fmt.Sprintf("There is no open escalation #%d for you to acknowledge.", id)
+		}
+		return ""
+	}
+	lines := make([]string, len(acked))
+	for i, e := range acked {
+		lines[i] = fmt.Sprintf("- #%d: %s", e.ID, e.Subject)
+	}
+	return "Acknowledged; I will stop escalating:\n" + strings.Join(lines, "\n")
+}
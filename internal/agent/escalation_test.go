@@ -0,0 +1,26 @@
+package agent
+
+import "testing"
+
+func TestParseAckCommand(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		id    int64
+		isAck bool
+	}{
+		{"ack", 0, true},
+		{" ACK ", 0, true},
+		{"/ack", 0, true},
+		{"ack 12", 12, true},
+		{"ack #12", 12, true},
+		{"ack it", 0, false},
+		{"ack 12 thanks", 0, false},
+		{"acknowledged", 0, false},
+		{"", 0, false},
+	} {
+		id, ok := parseAckCommand(tc.in)
+		if id != tc.id || ok != tc.isAck {
+			t.Errorf("parseAckCommand(%q) = %d, %v; want %d, %v", tc.in, id, ok, tc.id, tc.isAck)
+		}
+	}
+}
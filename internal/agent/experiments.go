@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"math/rand"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// promptArm assigns this turn to an arm of the running prompt experiment: the variant for the
+// experiment's fraction of turns, the control otherwise. It returns nil when no experiment runs;
+// scheduled and autonomous turns are left out, since nobody rates or regenerates their replies.
+func (l *Loop) promptArm(ctx context.Context, msg gateway.Message) (*store.PromptExperiment, string) {
+	if msg.Autonomous {
+		return nil, ""
+	}
+	exp, err := l.DB.RunningPromptExperiment(ctx)
+	if err != nil {
+		log.Printf("[AGENT] Prompt experiment lookup failed: %v", err)
+		return nil, ""
+	}
+	if exp == nil {
+		return nil, ""
+	}
+	if rand.Float64() < exp.Fraction {
+		return exp, store.ArmVariant
+	}
+	return exp, store.ArmControl
+}
+
+// buildArmPrompt builds the system prompt sections with the arm's identity: the experiment's
+// variant text, or SOUL.md.
+func (l *Loop) buildArmPrompt(ctx context.Context, userID string, exp *store.PromptExperiment, arm string) ([]PromptSection, error) {
+	if exp != nil && arm == store.ArmVariant {
+		return SystemPromptSections(ctx, l.DB, l.Config, userID, exp.Variant)
+	}
+	soul, err := LoadIdentity(l.Config.ConfigDir)
+	if err != nil {
+		log.Printf("[AGENT] Failed to load SOUL.md: %v", err)
+	}
+	return SystemPromptSections(ctx, l.DB, l.Config, userID, soul)
+}
+
+// recordExperimentTurn stores the turn's arm and outcome; its reply's feedback and regeneration
+// are picked up by store.ExperimentStats later.
+func (l *Loop) recordExperimentTurn(ctx context.Context, exp *store.PromptExperiment, arm, userID string, msg gateway.Message, replyID int64, toolErrors int) {
+	if exp == nil || replyID == 0 {
+		return
+	}
+	if err := l.DB.RecordExperimentTurn(ctx, exp.ID, arm, userID, msg.ThreadID, replyID, toolErrors); err != nil {
+		log.Printf("[AGENT] Recording prompt experiment turn failed: %v", err)
+	}
+}
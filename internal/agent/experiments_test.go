@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestPromptExperimentAssignsTurns(t *testing.T) {
+	ctx := context.Background()
+	db := SetupTestDB(t)
+	defer db.Close()
+	client := &countingClient{}
+	loop := &Loop{
+		Config:   &config.Config{AdminUserID: "admin", Model: "mock-model", ConfigDir: t.TempDir()},
+		DB:       db,
+		Client:   client,
+		Context:  &ContextManager{DB: db},
+		Executor: &MockExecutor{},
+	}
+	if _, err := db.CreatePromptExperiment(ctx, store.PromptExperiment{Name: "terse", Variant: "# SOUL.md - Terse\nAnswer in one line.", Fraction: 1}); err != nil {
+		t.Fatal(err)
+	}
+	exp, _ := db.RunningPromptExperiment(ctx)
+	msg := gateway.Message{SenderID: "admin", Channel: "test", ThreadID: "t1", Content: "name a color"}
+	if _, err := loop.RunOneTurn(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if system := client.last[0].Content; !strings.Contains(system, "Answer in one line.") {
+		t.Errorf("variant turn did not get the variant identity: %.200s", system)
+	}
+	msg.Content = RegenerateCommand
+	if _, err := loop.RunOneTurn(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	// Scheduled turns are not part of the experiment
+	if _, err := loop.RunOneTurn(ctx, gateway.Message{SenderID: "admin", Channel: "test", ThreadID: "t2", Content: "check feeds", Autonomous: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.ExperimentStats(ctx, exp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := stats[1]; v.Arm != store.ArmVariant || v.Turns != 2 || v.Regenerated != 1 || v.Succeeded != 1 {
+		t.Errorf("variant stats = %+v, want 2 turns, 1 regenerated and 1 success", v)
+	}
+	if c := stats[0]; c.Turns != 0 {
+		t.Errorf("control stats = %+v, want no turns", c)
+	}
+}
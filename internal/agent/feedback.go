@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// FeedbackCommand records the user's rating of and comment on the last reply:
+// "/feedback [+|-|👍|👎] text".
+const FeedbackCommand = "/feedback"
+
+// feedbackMemorizePrompt replaces a /feedback message when feedback_memorize is on, so the agent
+// can turn the comment into a lasting preference.
+const feedbackMemorizePrompt = "[FEEDBACK on your previous reply, already recorded]: %s\n\nIf this states a lasting preference about how you should answer or act (tone, length, format, habits), save it with manage_user_preference as a short \"prefers ...\" fact. Then acknowledge in one sentence. Do not redo the previous task unless asked."
+
+// parseFeedbackCommand splits a /feedback message into a rating and a comment.
+func parseFeedbackCommand(content string) (rating int, comment string, ok bool) {
+	content = strings.TrimSpace(content)
+	if len(content) < len(FeedbackCommand) || !strings.EqualFold(content[:len(FeedbackCommand)], FeedbackCommand) {
+		return 0, "", false
+	}
+	rest := content[len(FeedbackCommand):]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' && rest[0] != '\n' {
+		return 0, "", false
+	}
+	rest = strings.TrimSpace(rest)
+	for prefix, r := range map[string]int{"+": 1, "👍": 1, "-": -1, "👎": -1} {
+		if strings.HasPrefix(rest, prefix) {
+			rating, rest = r, strings.TrimSpace(strings.TrimPrefix(rest, prefix))
+			break
+		}
+	}
+	return rating, rest, true
+}
+
+// recordFeedback stores a /feedback message against the thread's last reply. It returns a notice
+// to answer with, or, when the comment should be memorized, the prompt the turn continues with.
+func (l *Loop) recordFeedback(ctx context.Context, user *store.User, msg gateway.Message, rating int, comment string) (notice, prompt string) {
+	if rating == 0 && comment == "" {
+		return "Usage: /feedback [+|-] what was good or bad about my last reply.", ""
+	}
+	f := store.Feedback{UserID: user.ID, Channel: msg.Channel, ThreadID: msg.ThreadID, Rating: rating, Comment: comment, Source: store.FeedbackCommand}
+	if last, err := l.DB.LastAssistantMessage(ctx, msg.ThreadID); err == nil {
+		f.MessageID = last.ID
+	} else if err != sql.ErrNoRows {
+		log.Printf("[AGENT] Feedback: %v", err)
+	}
+	if _, err := l.DB.InsertFeedback(ctx, f); err != nil {
+		log.Printf("[AGENT] Feedback: %v", err)
+		return "I could not record your feedback.", ""
+	}
+	if comment != "" && l.Config.FeedbackMemorize {
+		return "", fmt.Sprintf(feedbackMemorizePrompt, comment)
+	}
+	return "Thanks, your feedback is recorded. It goes into my next self-reflection.", ""
+}
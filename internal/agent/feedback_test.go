@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+)
+
+func TestParseFeedbackCommand(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		rating  int
+		comment string
+		ok      bool
+	}{
+		{"/feedback too long", 0, "too long", true},
+		{" /Feedback + exactly right", 1, "exactly right", true},
+		{"/feedback 👎 wrong city", -1, "wrong city", true},
+		{"/feedback", 0, "", true},
+		{"/feedbackplease", 0, "", false},
+		{"my feedback: good", 0, "", false},
+	} {
+		rating, comment, ok := parseFeedbackCommand(tt.in)
+		if rating != tt.rating || comment != tt.comment || ok != tt.ok {
+			t.Errorf("parseFeedbackCommand(%q) = %d, %q, %v", tt.in, rating, comment, ok)
+		}
+	}
+}
+
+func TestFeedbackCommandRecordsAgainstLastReply(t *testing.T) {
+	ctx := context.Background()
+	db := SetupTestDB(t)
+	defer db.Close()
+	client := &countingClient{}
+	cfg := &config.Config{AdminUserID: "admin", Model: "mock-model"}
+	loop := &Loop{Config: cfg, DB: db, Client: client, Context: &ContextManager{DB: db}, Executor: &MockExecutor{}}
+	msg := gateway.Message{SenderID: "admin", Channel: "test", ThreadID: "t1", Content: "plan my week"}
+	if _, err := loop.RunOneTurn(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	msg.Content = "/feedback - too long, use bullet points"
+	reply, err := loop.RunOneTurn(ctx, msg)
+	if err != nil || client.calls != 1 || reply == "" {
+		t.Fatalf("feedback = %q, %v after %d model calls, want a notice without a model call", reply, err, client.calls)
+	}
+	got, err := db.FeedbackSince(ctx, time.Now().Add(-time.Hour))
+	if err != nil || len(got) != 1 || got[0].Rating != -1 || got[0].Comment != "too long, use bullet points" || got[0].Reply != "answer 1" {
+		t.Fatalf("feedback = %+v, %v", got, err)
+	}
+
+	// With feedback_memorize the comment goes to the agent to save as a preference
+	cfg.FeedbackMemorize = true
+	msg.Content = "/feedback always answer in German"
+	if reply, _ := loop.RunOneTurn(ctx, msg); reply != "answer 2" {
+		t.Errorf("memorized feedback reply = %q, want the agent's answer", reply)
+	}
+	if last := client.last[len(client.last)-1].Content; last == msg.Content {
+		t.Errorf("agent got the raw command %q instead of the feedback prompt", last)
+	}
+}
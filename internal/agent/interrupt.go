@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+)
+
+// interruptedPrompt tells the model that the message cut its previous turn short.
+const interruptedPrompt = "\n\n[INTERRUPTED]: This urgent message cut your previous turn short: the model call or tool running at the time was cancelled, and the tool calls it did not finish are marked in the history. Deal with this message first. If it asks you to stop, confirm what was and was not done and do not resume the previous work unless asked."
+
+// recordInterruptedCalls answers the tool calls an urgent message left unfinished, so the thread's
+// history keeps every call paired with a result. running is true when calls[0] was cancelled while
+// it ran, in which case it may have partly taken effect.
+func (l *Loop) recordInterruptedCalls(ctx context.Context, msg gateway.Message, calls []openrouter.ToolCall, running bool) {
+	ctx = context.WithoutCancel(ctx)
+	for i, tc := range calls {
+		reason := "not run: the turn was interrupted by an urgent message"
+		if i == 0 && running {
+			reason = "cancelled while running by an urgent message; it may have partly taken effect"
+		}
+		b, _ := json.Marshal(map[string]string{"error": reason})
+		if _, err := l.DB.InsertMessage(ctx, "tool", string(b), "", "system", msg.Channel, msg.ThreadID, "", "", tc.ID); err != nil {
+			log.Printf("[AGENT] Failed to record interrupted call %s: %v", tc.Function.Name, err)
+		}
+	}
+	log.Printf("[AGENT] Turn in %s interrupted with %d tool call(s) unfinished", msg.ThreadID, len(calls))
+}
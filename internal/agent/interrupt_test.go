@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+)
+
+func TestUrgentMessageInterruptsTurn(t *testing.T) {
+	ctx := context.Background()
+	db := SetupTestDB(t)
+	defer db.Close()
+	turnCtx, interrupt := context.WithCancelCause(ctx)
+	exec := &crashingExecutor{crashOn: "backup_now", crash: func() { interrupt(gateway.ErrInterrupted) }}
+	loop := &Loop{
+		Config:   &config.Config{Model: "mock-model", ConfigDir: t.TempDir()},
+		DB:       db,
+		Client:   &twoToolClient{},
+		Context:  &ContextManager{DB: db},
+		Executor: exec,
+	}
+	msg := gateway.Message{SenderID: "alice", Channel: "talk", ThreadID: "room", Content: "back up, then clear /srv/old"}
+	if _, err := loop.RunOneTurn(turnCtx, msg); !errors.Is(err, gateway.ErrInterrupted) {
+		t.Fatalf("err = %v", err)
+	}
+	// Unlike a crash, the interrupted turn is over: nothing to report after a restart
+	if turns, _ := db.JournaledTurns(ctx); len(turns) != 0 {
+		t.Errorf("interrupted turn stayed in the journal: %+v", turns)
+	}
+	history, _ := db.ThreadMessages(ctx, "room")
+	results := map[string]string{}
+	for _, m := range history {
+		if m.Role == "tool" {
+			results[m.ToolCallID] = m.Content
+		}
+	}
+	if !strings.Contains(results["call_backup_now"], "cancelled while running") || !strings.Contains(results["call_run_terminal_cmd"], "not run") {
+		t.Fatalf("tool results = %v", results)
+	}
+
+	// The urgent message's turn is told what happened
+	client := &countingClient{}
+	loop.Client = client
+	if _, err := loop.RunOneTurn(ctx, gateway.Message{SenderID: "alice", Channel: "talk", ThreadID: "room", Content: "!stop", Urgent: true, Preempted: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(client.last[0].Content, "[INTERRUPTED]") {
+		t.Error("system prompt lacks the interruption note")
+	}
+}
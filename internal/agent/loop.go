@@ -10,11 +10,15 @@ import (
 
 	"github.com/hattiebot/hattiebot/internal/config"
 	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/errbudget"
+	"github.com/hattiebot/hattiebot/internal/events"
 	"github.com/hattiebot/hattiebot/internal/gateway"
 	"github.com/hattiebot/hattiebot/internal/memory"
+	"github.com/hattiebot/hattiebot/internal/middleware"
 	"github.com/hattiebot/hattiebot/internal/openrouter"
 	"github.com/hattiebot/hattiebot/internal/store"
 	"github.com/hattiebot/hattiebot/internal/tools"
+	"github.com/hattiebot/hattiebot/internal/tracing"
 )
 
 // isProviderValidationError returns true for OpenRouter "Provider returned error" 400s due to
@@ -53,6 +57,9 @@ func userFriendlyProviderError(err error) string {
 	return "I'm sorry, the AI provider temporarily returned an error. Please try again in a moment—your message was received and I'll process it when you resend."
 }
 
+// maxEventReply caps the reply text in turn.completed events, in runes.
+const maxEventReply = 2000
+
 // maxMessagesBeforeTruncationRetry is the message count above which we truncate and retry on provider validation error.
 const maxMessagesBeforeTruncationRetry = 28
 
@@ -67,6 +74,15 @@ type Loop struct {
 	Compactor       *memory.Compactor
 	SubmindRegistry *SubmindRegistry
 	LogStore        *store.LogStore
+	ToolSelector    *ToolSelector // nil = send every tool on every request
+	// ErrorBudget tracks provider/empty-response failures; while it is throttled the loop uses
+	// CheapClient (when set) and tells the model it is running with reduced autonomy.
+	ErrorBudget *errbudget.Budget
+	CheapClient core.LLMClient
+	// Runner runs sub-minds in the background (spawn_submind async); nil = synchronous only.
+	Runner *SubmindRunner
+	// Events receives a turn.completed event after each reply; nil publishes nothing.
+	Events *events.Bus
 }
 
 // SpawnSubmind creates and runs a sub-mind with the given mode and task.
@@ -87,6 +103,10 @@ func (l *Loop) SpawnSubmind(ctx context.Context, userID, mode, task string, sess
 		Client:   l.Client,
 		Executor: l.Executor,
 		LogStore: l.LogStore,
+		Progress: l.submindProgress(ctx),
+	}
+	if l.DB != nil {
+		submind.Tools = l.DB
 	}
 
 	// No persistence: backward compat when userID empty or no DB
@@ -109,12 +129,36 @@ func (l *Loop) SpawnSubmind(ctx context.Context, userID, mode, task string, sess
 	return submind.RunWithSession(ctx, task, sessionID, userID, l.DB)
 }
 
+// StartSubmind queues a sub-mind on the background runner and returns its session ID.
+// Implements the core.AsyncSubmindSpawner interface.
+func (l *Loop) StartSubmind(ctx context.Context, userID, mode, task string) (int64, error) {
+	if l.Runner == nil {
+		return 0, fmt.Errorf("background sub-minds are not available")
+	}
+	return l.Runner.Start(ctx, userID, mode, task)
+}
+
+// CancelSubmind stops one of userID's background sub-minds.
+func (l *Loop) CancelSubmind(userID string, sessionID int64) bool {
+	return l.Runner != nil && l.Runner.Cancel(userID, sessionID)
+}
+
 // RunOneTurn adds the user message, calls the model (with tool execution loop), saves messages, and returns the assistant reply.
 // RunOneTurn adds the user message, calls the model (with tool execution loop), saves messages, and returns the assistant reply.
 func (l *Loop) RunOneTurn(ctx context.Context, msg gateway.Message) (assistantContent string, err error) {
+	started := time.Now()
+	ctx, span := tracing.Start(ctx, "agent.turn")
+	span.Set("user", msg.SenderID).Set("channel", msg.Channel).Set("thread", msg.ThreadID)
+	defer func() { span.EndErr(err) }()
 	// 1. Resolve User Identity
-	// Gateway message doesn't carry Name yet, so we rely on ID.
-	user, err := l.DB.GetOrCreateUser(ctx, msg.SenderID, "", msg.Channel)
+	// Gateway message doesn't carry Name yet, so we rely on ID. A sender linked to another
+	// identity (link_identity) acts as that identity's user.
+	userID, err := l.DB.ResolveIdentity(ctx, msg.Channel, msg.SenderID)
+	if err != nil {
+		log.Printf("[AGENT] Failed to resolve identity of %s: %v", msg.SenderID, err)
+		userID = msg.SenderID
+	}
+	user, err := l.DB.GetOrCreateUser(ctx, userID, "", msg.Channel)
 	if err != nil {
 		log.Printf("[AGENT] Failed to resolve user: %v", err)
 		return "", fmt.Errorf("resolving user: %w", err)
@@ -139,14 +183,25 @@ func (l *Loop) RunOneTurn(ctx context.Context, msg gateway.Message) (assistantCo
 	}
 
 	// 1.5. Authorization & Trust Level Check
-	// Auto-promote configured admin
+	// Auto-promote configured admin to owner
 	if l.Config.AdminUserID != "" && user.ID == l.Config.AdminUserID {
-		if user.TrustLevel != "admin" {
-			log.Printf("[AGENT] Auto-promoting admin user %s", user.ID)
-			if err := l.DB.UpdateUserTrust(ctx, user.ID, "admin"); err == nil {
+		if user.Role != store.RoleOwner || user.TrustLevel != "admin" {
+			log.Printf("[AGENT] Auto-promoting admin user %s to owner", user.ID)
+			if err := l.DB.UpdateUserRole(ctx, user.ID, store.RoleOwner); err == nil {
+				user.Role = store.RoleOwner
 				user.TrustLevel = "admin"
 			}
 		}
+	} else if l.Config.AdminUserID != "" && user.Role == store.RoleOwner {
+		// Ownership moved to another admin_user_id; the previous owner keeps admin rights.
+		if err := l.DB.UpdateUserRole(ctx, user.ID, store.RoleAdmin); err == nil {
+			user.Role = store.RoleAdmin
+		}
+	}
+
+	// API tokens without the admin scope act without admin rights
+	if msg.NoAdmin {
+		user = user.WithoutAdmin()
 	}
 
 	// Enforce Trust Levels
@@ -172,6 +227,57 @@ func (l *Loop) RunOneTurn(ctx context.Context, msg gateway.Message) (assistantCo
 	// Inject user_id and trust_level into context for tools
 	ctx = context.WithValue(ctx, "user_id", user.ID)
 	ctx = context.WithValue(ctx, "user_trust", user.TrustLevel)
+	ctx = context.WithValue(ctx, "user_role", user.Role)
+
+	if isRegenerateCommand(msg.Content) {
+		content, notice := l.regenerate(ctx, user, msg)
+		if notice != "" {
+			return notice, nil
+		}
+		msg.Content = content
+	}
+	if rating, comment, ok := parseFeedbackCommand(msg.Content); ok {
+		notice, prompt := l.recordFeedback(ctx, user, msg, rating, comment)
+		if notice != "" {
+			return notice, nil
+		}
+		msg.Content = prompt
+	}
+	// "ack" stops the escalations of overdue items (scheduler.EscalationMonitor)
+	if id, ok := parseAckCommand(msg.Content); ok && !msg.Autonomous {
+		if notice := l.acknowledgeEscalations(ctx, user, id); notice != "" {
+			return notice, nil
+		}
+	}
+	// A dry run (global or /dryrun) simulates restricted tools instead of running them
+	dryRun := l.Config.DryRun
+	if request, ok := parseDryRunCommand(msg.Content); ok {
+		if request == "" {
+			return dryRunUsage, nil
+		}
+		dryRun = true
+	}
+	if dryRun {
+		ctx = middleware.WithDryRun(ctx)
+	}
+
+	// Attribute token/cost usage for this turn to the active job and triggering plan
+	ctx, activeJob := l.attributeUsage(ctx, user.ID, msg)
+	// Scheduled turns leave a structured run record (see report_task_result)
+	ctx, planRunID := l.startPlanRun(ctx, user.ID, msg)
+	if planRunID != 0 {
+		defer func() { l.finishPlanRun(ctx, planRunID, assistantContent, err) }()
+	}
+	if exceeded, notice := l.jobBudgetExceeded(ctx, activeJob); exceeded {
+		log.Printf("[AGENT] %s", notice)
+		return notice, nil
+	}
+	// ask_user and paused sub-minds find the conversation through the message
+	if _, ok := gateway.MessageFromContext(ctx); !ok {
+		ctx = gateway.WithMessage(ctx, msg)
+	}
+	// This message may answer a question asked earlier in the thread; resume that step
+	pendingNote := l.resumePendingInput(ctx, user.ID, msg)
 
 	// 2. Select History filtered by thread
 	historyMessages, err := l.Context.SelectHistory(ctx, msg.ThreadID)
@@ -189,7 +295,9 @@ func (l *Loop) RunOneTurn(ctx context.Context, msg gateway.Message) (assistantCo
 		}
 	}
 
-	systemPrompt, err := BuildSystemPrompt(ctx, l.DB, l.Config, user.ID)
+	// A running prompt experiment may give this turn its variant identity
+	experiment, arm := l.promptArm(ctx, msg)
+	sections, err := l.buildArmPrompt(ctx, user.ID, experiment, arm)
 	if err != nil {
 		return "", err
 	}
@@ -202,24 +310,45 @@ func (l *Loop) RunOneTurn(ctx context.Context, msg gateway.Message) (assistantCo
 	if user.Name != "" && user.Name != "User "+user.ID {
 		userContext += fmt.Sprintf("\n- Name: %s", user.Name)
 	}
+	factsContext := ""
 	if len(facts) > 0 {
-		userContext += "\n- Memories/Facts:"
+		factsContext = "\n- Memories/Facts:"
 		for _, f := range facts {
-			userContext += fmt.Sprintf("\n  * %s: %s", f.Key, f.Value)
+			factsContext += fmt.Sprintf("\n  * %s: %s", f.Key, f.Value)
 		}
 	}
+	// The rest is this turn's context: profile, pending items and notes
+	sections = append(sections, PromptSection{SectionUser, userContext}, PromptSection{SectionFacts, factsContext})
+	userContext = ""
+	if profile, err := l.DB.GetUserProfile(ctx, user.ID); err == nil {
+		userContext += profileContext(profile, time.Now())
+	} else {
+		log.Printf("[AGENT] Failed to load profile for %s: %v", user.ID, err)
+	}
 	
 	// Inject Pending/Blocked Items (Gap 6)
 	// Fetch blocked jobs
 	blockedJobs, _ := l.DB.ListJobs(ctx, user.ID, "blocked")
+	// Questions waiting for this user's answer in other threads (this thread's is in pendingNote)
+	var openQuestions []store.PendingInput
+	if pending, err := l.DB.ListPendingInputs(ctx, user.ID); err == nil {
+		for _, q := range pending {
+			if q.ThreadID != msg.ThreadID {
+				openQuestions = append(openQuestions, q)
+			}
+		}
+	}
 	// Fetch overdue plans (simple active filter for now)
 	activePlans, _ := l.DB.ListPlans(ctx, user.ID, "active") // Filter in loop if needed
 	
-	if len(blockedJobs) > 0 || len(activePlans) > 0 {
+	if len(blockedJobs) > 0 || len(activePlans) > 0 || len(openQuestions) > 0 {
 		userContext += "\n\n[PENDING ITEMS - ASK USER TO RESOLVE]:"
 		for _, j := range blockedJobs {
 			userContext += fmt.Sprintf("\n- Job #%d: %s (BLOCKED: %s) [TIP: Use snooze action if user needs time]", j.ID, j.Title, j.BlockedReason)
 		}
+		for _, q := range openQuestions {
+			userContext += fmt.Sprintf("\n- Question #%d asked in another conversation (%s), waiting for %s: %q", q.ID, q.Channel, q.Expect.Describe(), q.Question)
+		}
 		now := time.Now()
 		for _, p := range activePlans {
 			if p.NextRunAt != nil && p.NextRunAt.Before(now) {
@@ -228,24 +357,66 @@ func (l *Loop) RunOneTurn(ctx context.Context, msg gateway.Message) (assistantCo
 		}
 	}
 
+	client := l.Client
+	if l.ErrorBudget.Throttled() {
+		st := l.ErrorBudget.Status()
+		userContext += "\n\n[SELF-THROTTLED]: Recent error rates exceeded the error budget (" + st.Reason + "). Autonomy is reduced until they recover: scheduled agent tasks are deferred, restricted tools need the user's explicit approval, and a cheaper model may be in use. Prefer simple, low-risk steps and tell the user if something keeps failing."
+		if l.CheapClient != nil {
+			client = l.CheapClient
+		}
+	}
+
 	if msg.Autonomous {
 		userContext += "\n\n[AUTONOMOUS TASK]: You are running an autonomous scheduled task. Complete it without requiring user input. Only call notify_user if something needs the user's attention (errors, anomalies, important findings). If the task completes successfully with nothing notable, finish without calling notify_user."
 	}
+	if dryRun {
+		userContext += dryRunPrompt
+	}
+	if planRunID != 0 {
+		userContext += planRunPrompt
+	}
+	userContext += pendingNote
+	if msg.Preempted {
+		userContext += interruptedPrompt
+	}
 
-	systemPrompt += userContext
-
-	// Build OpenRouter messages: system + history + new user
-	messages := []openrouter.Message{{Role: "system", Content: systemPrompt}}
-	messages = append(messages, historyMessages...)
-	messages = append(messages, openrouter.Message{Role: "user", Content: msg.Content})
+	sections = append(sections, PromptSection{SectionTurn, userContext})
 
 	// Save user message
-	_, err = l.DB.InsertMessage(ctx, "user", msg.Content, "", msg.SenderID, msg.Channel, msg.ThreadID, "", "", "")
+	_, err = l.DB.InsertMessage(ctx, "user", msg.Content, "", user.ID, msg.Channel, msg.ThreadID, "", "", "")
 	if err != nil {
 		return "", err
 	}
+	journal := l.startJournal(ctx, user.ID, msg)
+	defer journal.finish(ctx)
+
+	allToolDefs := tools.BuiltinToolDefs()
+	// A thread limited to some tools (manage_thread) never sees or runs the others
+	threadTools := threadToolAllowlist(threadSettingsFor(ctx, l.DB, msg))
+	if threadTools != nil {
+		allToolDefs = threadTools.FilterDefs(allToolDefs, user.Role)
+	}
+	toolDefs := l.ToolSelector.Select(ctx, msg.Content, recentToolNames(historyMessages), allToolDefs)
+	toolSubset := hasTool(toolDefs, RequestToolsName)
+	if planRunID != 0 && !hasTool(toolDefs, "report_task_result") {
+		for _, td := range allToolDefs {
+			if td.Function.Name == "report_task_result" {
+				toolDefs = append(toolDefs, td)
+			}
+		}
+	}
+	if toolSubset {
+		log.Printf("[AGENT] Sending %d of %d tools for this request", len(toolDefs)-1, len(allToolDefs))
+	}
+
+	// Fit the prompt into the context budget, then build OpenRouter messages: system + history + new user
+	systemPrompt, historyMessages := l.fitContext(sections, historyMessages, msg.Content, toolDefs)
+	messages := []openrouter.Message{{Role: "system", Content: systemPrompt}}
+	messages = append(messages, historyMessages...)
+	messages = append(messages, openrouter.Message{Role: "user", Content: msg.Content})
 
-	toolDefs := tools.BuiltinToolDefs()
+	// Registered tools as listed in the system prompt; re-sent mid-turn if the registry changes.
+	registeredTools := l.registeredToolNames(ctx)
     
     // Empty-response retries: count consecutive empty model replies; reset after any successful tool execution.
     const maxEmptyRetries = 2
@@ -257,7 +428,10 @@ func (l *Loop) RunOneTurn(ctx context.Context, msg gateway.Message) (assistantCo
     truncationRetryDone := false
     // Track tool rounds for status-update hint (after 2+ rounds with no user feedback).
     toolRounds := 0
+    toolErrors := 0
     statusUpdateHintSent := false
+    // ID of the status message on channels that support editing; later updates edit it in place.
+    statusMsgID := ""
 
     var content string
     var toolCalls []openrouter.ToolCall
@@ -283,9 +457,15 @@ TurnLoop:
                     })
                 }
                 var err error
-                content, toolCalls, err = l.Client.ChatCompletionWithTools(ctx, messages, toolDefs)
+                llmCtx, llmSpan := tracing.Start(ctx, "llm.chat")
+                llmSpan.SetKind(tracing.KindClient).Set("llm.tools", len(toolDefs)).Set("llm.messages", len(messages))
+                content, toolCalls, err = client.ChatCompletionWithTools(llmCtx, messages, toolDefs)
+                llmSpan.Set("llm.tool_calls", len(toolCalls)).EndErr(err)
                 log.Printf("[AGENT] ChatCompletionWithTools returned: content_len=%d, toolCalls=%d, err=%v", len(content), len(toolCalls), err)
                 if err != nil {
+                    if gateway.Interrupted(ctx) {
+                        return "", context.Cause(ctx)
+                    }
                     // Only fallback to non-tool mode if the error indicates tools aren't supported.
                     // Do NOT treat "Invalid tool call" / "invalid JSON" (bad request) as unsupported—provider does support tools.
                     errStr := err.Error()
@@ -315,12 +495,15 @@ TurnLoop:
                     }
                     // Transient or other error—return user-friendly message for provider/API errors
                     log.Printf("[AGENT] API error (not tool-related): %v", err)
+                    l.ErrorBudget.Record(errbudget.Provider, true)
                     if isProviderOrAPIError(err) {
                         return userFriendlyProviderError(err), nil
                     }
                     return "", err
                 }
                 
+                l.ErrorBudget.Record(errbudget.Provider, false)
+
                 // Content-based tool parsing (e.g. XML)
                 if len(toolCalls) == 0 {
                     parsed, cleaned := ParseContentToolCalls(content)
@@ -338,7 +521,7 @@ TurnLoop:
                 if strings.TrimSpace(content) != "" && len(toolCalls) > 0 && l.Gateway != nil {
                     statusContent := StripInlineToolCallMarkers(content)
                     if strings.TrimSpace(statusContent) != "" {
-                        l.Gateway.RouteReply(msg, statusContent)
+                        statusMsgID = l.Gateway.RouteStatus(msg, statusMsgID, statusContent)
                         log.Printf("[AGENT] Sent intermediate status update to user: %q", statusContent)
                     }
                 }
@@ -348,10 +531,23 @@ TurnLoop:
                     break
                 }
                 var toolNames []string
-                for _, tc := range toolCalls {
-                    toolNames = append(toolNames, tc.Function.Name)
+                registryCalled := false
+                for i, tc := range toolCalls {
+                    toolCalls[i] = l.resolveRegisteredCall(ctx, tc, allToolDefs)
+                    toolNames = append(toolNames, toolCalls[i].Function.Name)
+                    registryCalled = registryCalled || registryTools[tc.Function.Name]
                 }
                 log.Printf("[AGENT] Executing %d tool calls: %s", len(toolCalls), strings.Join(toolNames, ", "))
+                // The model asked for (or guessed) a tool outside this turn's subset: send the full set from now on.
+                if toolSubset {
+                    for _, name := range toolNames {
+                        if name == RequestToolsName || !hasTool(toolDefs, name) {
+                            log.Printf("[AGENT] Model asked for more tools (%s); switching to all %d tools", name, len(allToolDefs))
+                            toolDefs, toolSubset = allToolDefs, false
+                            break
+                        }
+                    }
+                }
                 toolRounds++
 
                 // Append assistant message with tool_calls
@@ -362,13 +558,35 @@ TurnLoop:
                 }
                 messages = append(messages, assistantMsg)
 
-                // Save assistant message to DB
+                // Save assistant message to DB, even if an urgent message interrupts the turn now:
+                // recordInterruptedCalls answers its calls
                 toolCallsJSON, _ := json.Marshal(toolCalls)
-                l.DB.InsertMessage(ctx, "assistant", content, l.Config.Model, "hattiebot", msg.Channel, msg.ThreadID, string(toolCallsJSON), "", "")
+                l.DB.InsertMessage(context.WithoutCancel(ctx), "assistant", content, l.Config.Model, "hattiebot", msg.Channel, msg.ThreadID, string(toolCallsJSON), "", "")
+                journal.toolCalls(ctx, toolCalls)
 
-                for _, tc := range toolCalls {
+                for i, tc := range toolCalls {
+                    if gateway.Interrupted(ctx) {
+                        l.recordInterruptedCalls(ctx, msg, toolCalls[i:], false)
+                        return "", context.Cause(ctx)
+                    }
                     args := tc.Function.Arguments
-                    result, execErr := l.Executor.Execute(ctx, tc.Function.Name, args)
+                    var result string
+                    var execErr error
+                    if tc.Function.Name == RequestToolsName {
+                        result = fmt.Sprintf(`{"status": "all tools attached", "count": %d}`, len(allToolDefs))
+                    } else if !threadToolAllowed(threadTools, allToolDefs, tc) {
+                        b, _ := json.Marshal(map[string]string{"error": tc.Function.Name + " is not allowed in this conversation"})
+                        result = string(b)
+                    } else {
+                        result, execErr = l.Executor.Execute(ctx, tc.Function.Name, args)
+                        if gateway.Interrupted(ctx) {
+                            l.recordInterruptedCalls(ctx, msg, toolCalls[i:], true)
+                            return "", context.Cause(ctx)
+                        }
+                    }
+                    if middleware.ToolFailed(result, execErr) {
+                        toolErrors++
+                    }
                     if execErr != nil {
                         b, _ := json.Marshal(map[string]string{"error": execErr.Error()})
                         result = string(b)
@@ -383,12 +601,35 @@ TurnLoop:
 
                     // Save to DB
                     l.DB.InsertMessage(ctx, "tool", result, "", "system", msg.Channel, msg.ThreadID, "", "", tc.ID)
+                    journal.toolDone(ctx, tc.ID, result, execErr)
+                }
+                journal.state(ctx, store.TurnThinking)
+                // Read-after-write: tools registered (or removed) this round are usable right away.
+                if registryCalled {
+                    if now := l.registeredToolNames(ctx); now != registeredTools {
+                        registeredTools = now
+                        update := "The registered tools changed during this turn. Current list (use them via execute_registered_tool):\n"
+                        if block := registeredToolsBlock(ctx, l.DB); block != "" {
+                            update += block
+                        } else {
+                            update += "(none)"
+                        }
+                        messages = append(messages, openrouter.Message{Role: "system", Content: update})
+                    }
                 }
                 // Inject any new user messages that arrived while we were working (e.g. "stop").
                 // The model will see them on the next LLM call and can respond accordingly.
                 if l.Gateway != nil {
                     tk := gateway.ThreadKey(msg)
-                    pending := l.Gateway.GetPendingAndClear(tk)
+                    var pending []gateway.Message
+                    for _, p := range l.Gateway.GetPendingAndClear(tk) {
+                        // Group chatter that arrived meanwhile is context, not a new instruction
+                        if !l.Addressed(ctx, p) {
+                            l.RecordPassive(ctx, p)
+                            continue
+                        }
+                        pending = append(pending, p)
+                    }
                     if len(pending) > 0 {
                         messages = append(messages, openrouter.Message{
                             Role:    "system",
@@ -400,6 +641,12 @@ TurnLoop:
                         }
                     }
                 }
+                // Stop spending once the active job's budget is used up mid-turn.
+                if exceeded, notice := l.jobBudgetExceeded(ctx, activeJob); exceeded {
+                    log.Printf("[AGENT] %s", notice)
+                    content = notice
+                    break TurnLoop
+                }
                 // Reset empty-response counter after successful tool execution so we don't give up mid-request.
                 emptyRetries = 0
                 continue
@@ -413,7 +660,11 @@ TurnLoop:
                 simpleMessages = append(simpleMessages, openrouter.Message{Role: m.Role, Content: m.Content})
             }
             var err error
-            content, err = l.Client.ChatCompletion(ctx, simpleMessages)
+            llmCtx, llmSpan := tracing.Start(ctx, "llm.chat")
+            llmSpan.SetKind(tracing.KindClient).Set("llm.messages", len(simpleMessages))
+            content, err = client.ChatCompletion(llmCtx, simpleMessages)
+            llmSpan.EndErr(err)
+            l.ErrorBudget.Record(errbudget.Provider, err != nil)
             if err != nil {
                 log.Printf("[AGENT] ChatCompletion error: %v", err)
                 if isProviderOrAPIError(err) {
@@ -425,7 +676,9 @@ TurnLoop:
         } // End Inner Tool Loop
 
         // Validate Content & Self-Correct (only count consecutive empty responses; counter was reset after tool execution)
-        if strings.TrimSpace(content) == "" || content == "(No text in model response; try rephrasing or a different model.)" {
+        isEmpty := strings.TrimSpace(content) == "" || content == "(No text in model response; try rephrasing or a different model.)"
+        l.ErrorBudget.Record(errbudget.Empty, isEmpty)
+        if isEmpty {
             if emptyRetries < maxEmptyRetries {
                 log.Printf("[AGENT] Empty response detected. Triggering self-correction (consecutive empty %d/%d)...", emptyRetries+1, maxEmptyRetries)
                 retryMsg := openrouter.Message{
@@ -447,11 +700,24 @@ TurnLoop:
 	content = StripInlineToolCallMarkers(content)
 
 	// Save assistant message
+	journal.state(ctx, store.TurnReplying)
 	toolCallsJSON := ""
 	toolResultsJSON := ""
-	_, err = l.DB.InsertMessage(ctx, "assistant", content, l.Config.Model, "hattiebot", msg.Channel, msg.ThreadID, toolCallsJSON, toolResultsJSON, "")
+	replyID, err := l.DB.InsertMessage(ctx, "assistant", content, l.Config.Model, "hattiebot", msg.Channel, msg.ThreadID, toolCallsJSON, toolResultsJSON, "")
 	if err != nil {
 		return "", err
 	}
+	l.recordExperimentTurn(ctx, experiment, arm, user.ID, msg, replyID, toolErrors)
+	l.Events.Publish(ctx, events.TurnCompleted, user.ID, map[string]interface{}{
+		"channel":     msg.Channel,
+		"thread_id":   msg.ThreadID,
+		"message_id":  replyID,
+		"reply":       truncateRunes(content, maxEventReply),
+		"tool_rounds": toolRounds,
+		"tool_errors": toolErrors,
+		"duration_ms": time.Since(started).Milliseconds(),
+		"autonomous":  msg.Autonomous,
+		"plan_id":     msg.PlanID,
+	})
 	return content, nil
 }
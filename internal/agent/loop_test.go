@@ -27,6 +27,8 @@ func (m *MockExecutor) Execute(ctx context.Context, name, argsJSON string) (stri
 	return "mock_result", nil
 }
 
+func (m *MockExecutor) SetSpawner(spawner core.SubmindSpawner) {}
+
 // SetupTestDB creates an in-memory SQLite DB for testing
 func SetupTestDB(t *testing.T) *store.DB {
 	ctx := context.Background()
@@ -238,8 +240,8 @@ func TestUserTrustLevels(t *testing.T) {
 		t.Errorf("Expected admin trust level, got %s", user.TrustLevel)
 	}
 
-	// 2. New User (Stranger) -> Should be restricted (assuming schema default is 'restricted')
-	msg2 := gateway.Message{SenderID: "stranger", Content: "Hello", Channel: "test", ThreadID: "t2"}
+	// 2. New User (Stranger) from Nextcloud Talk -> Should be restricted pending admin approval
+	msg2 := gateway.Message{SenderID: "stranger", Content: "Hello", Channel: "nextcloud_talk", ThreadID: "t2"}
 	reply, err := loop.RunOneTurn(ctx, msg2)
 	if err != nil {
 		t.Errorf("RunOneTurn failed: %v", err)
@@ -256,7 +258,7 @@ func TestUserTrustLevels(t *testing.T) {
 	db.UpdateUserTrust(ctx, "stranger", "trusted")
 	
 	// 4. Stranger (now Trusted) -> Should proceed
-	msg3 := gateway.Message{SenderID: "stranger", Content: "Hello again", Channel: "test", ThreadID: "t2"}
+	msg3 := gateway.Message{SenderID: "stranger", Content: "Hello again", Channel: "nextcloud_talk", ThreadID: "t2"}
 	reply3, err := loop.RunOneTurn(ctx, msg3)
 	if err != nil {
 		t.Errorf("RunOneTurn failed: %v", err)
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// cancelWords drop the open question instead of answering it.
+var cancelWords = map[string]bool{"cancel": true, "never mind": true, "nevermind": true, "forget it": true, "skip": true}
+
+// resumePendingInput checks msg against the question open in its thread (see ask_user) and returns
+// a note for the system prompt: the answer and the step to resume, the result of the resumed
+// sub-mind, or why the message did not answer. It returns "" when nothing is pending.
+func (l *Loop) resumePendingInput(ctx context.Context, userID string, msg gateway.Message) string {
+	if msg.Autonomous || msg.ThreadID == "" {
+		return ""
+	}
+	p, err := l.DB.OpenPendingInput(ctx, msg.ThreadID)
+	if err != nil {
+		log.Printf("[AGENT] Failed to load pending input: %v", err)
+		return ""
+	}
+	if p == nil || p.UserID != userID {
+		return ""
+	}
+	if time.Now().After(p.ExpiresAt) {
+		l.closePendingInput(ctx, p, store.InputExpired)
+		return ""
+	}
+
+	if cancelWords[strings.ToLower(strings.Trim(strings.TrimSpace(msg.Content), ".!"))] {
+		l.closePendingInput(ctx, p, store.InputCancelled)
+		return fmt.Sprintf("\n\n[PENDING QUESTION CANCELLED]: The user cancelled your question %q. Do not continue that step; acknowledge briefly.", p.Question)
+	}
+	answer, err := p.Expect.Check(msg.Content)
+	if err != nil {
+		return fmt.Sprintf("\n\n[PENDING QUESTION]: You asked %q and are waiting for %s. This message is not a valid answer (%v). If the user is trying to answer, ask again and say what is needed; if they moved on, help with that. The question stays open.", p.Question, p.Expect.Describe(), err)
+	}
+	if err := l.DB.ResolvePendingInput(ctx, p.ID, store.InputAnswered, answer); err != nil {
+		log.Printf("[AGENT] Failed to record answer to pending input %d: %v", p.ID, err)
+		return ""
+	}
+	if p.JobID != 0 {
+		if err := l.DB.UpdateJobStatus(ctx, p.JobID, "open", ""); err != nil {
+			log.Printf("[AGENT] Failed to reopen job %d: %v", p.JobID, err)
+		}
+	}
+
+	if p.SubmindSessionID != 0 {
+		return l.resumeSubmind(ctx, userID, p, answer)
+	}
+	note := fmt.Sprintf("\n\n[RESUMING PENDING STEP]: Earlier you asked %q. The user's message answers it: %q. Continue with the step that was waiting for this answer, without asking again: %s", p.Question, answer, p.Step)
+	if p.JobID != 0 {
+		note += fmt.Sprintf(" (Job #%d is open again.)", p.JobID)
+	}
+	return note
+}
+
+// resumeSubmind continues the sub-mind session that asked p with the answer.
+func (l *Loop) resumeSubmind(ctx context.Context, userID string, p *store.PendingInput, answer string) string {
+	ses, err := l.DB.GetSubmindSession(ctx, p.SubmindSessionID, userID)
+	if err != nil {
+		log.Printf("[AGENT] Failed to load sub-mind session %d: %v", p.SubmindSessionID, err)
+		return fmt.Sprintf("\n\n[RESUMING PENDING STEP]: A sub-mind asked %q and the user answered %q, but its session #%d could not be loaded (%v). Tell the user and continue the task yourself if you can.", p.Question, answer, p.SubmindSessionID, err)
+	}
+	if ses.Async && l.Runner != nil {
+		l.Runner.Resume(ctx, *ses, answer)
+		return fmt.Sprintf("\n\n[RESUMED SUB-MIND]: Background sub-mind session #%d (%s) asked %q; it continues in the background with the user's answer %q. Tell the user, and collect its result with check_submind.", ses.ID, ses.Mode, p.Question, answer)
+	}
+	res, err := l.SpawnSubmind(ctx, userID, ses.Mode, answer, ses.ID)
+	if err != nil {
+		return fmt.Sprintf("\n\n[RESUMING PENDING STEP]: Sub-mind session #%d (%s) asked %q and the user answered %q, but resuming it failed: %v", ses.ID, ses.Mode, p.Question, answer, err)
+	}
+	if res.AwaitingInput {
+		return fmt.Sprintf("\n\n[RESUMED SUB-MIND]: Sub-mind session #%d (%s) continued with the user's answer %q and now needs more input. Ask the user: %q", ses.ID, ses.Mode, answer, res.Question)
+	}
+	output := res.Output
+	if res.Error != "" {
+		output = "error: " + res.Error
+	}
+	return fmt.Sprintf("\n\n[RESUMED SUB-MIND]: Sub-mind session #%d (%s) was waiting for the answer to %q. It continued with the user's answer %q and returned:\n%s\nReport the outcome to the user.", ses.ID, ses.Mode, p.Question, answer, output)
+}
+
+// closePendingInput resolves p without an answer and releases what waited on it: the job is
+// blocked on the unanswered question and the sub-mind session suspended.
+func (l *Loop) closePendingInput(ctx context.Context, p *store.PendingInput, status string) {
+	if err := l.DB.ResolvePendingInput(ctx, p.ID, status, ""); err != nil {
+		log.Printf("[AGENT] Failed to close pending input %d: %v", p.ID, err)
+		return
+	}
+	if p.JobID != 0 {
+		if err := l.DB.UpdateJobStatus(ctx, p.JobID, "blocked", "Unanswered: "+p.Question); err != nil {
+			log.Printf("[AGENT] Failed to block job %d: %v", p.JobID, err)
+		}
+	}
+	if p.SubmindSessionID != 0 {
+		if err := l.DB.SetSubmindSessionStatus(ctx, p.SubmindSessionID, "suspended"); err != nil {
+			log.Printf("[AGENT] Failed to suspend sub-mind session %d: %v", p.SubmindSessionID, err)
+		}
+	}
+}
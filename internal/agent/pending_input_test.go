@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools"
+)
+
+// askingLLM asks the user once, then finishes with the answer it got.
+type askingLLM struct {
+	turns int
+	last  openrouter.Message
+}
+
+func (m *askingLLM) ChatCompletion(ctx context.Context, msgs []openrouter.Message) (string, error) {
+	return "", nil
+}
+
+func (m *askingLLM) ChatCompletionWithTools(ctx context.Context, msgs []openrouter.Message, defs []openrouter.ToolDefinition) (string, []openrouter.ToolCall, error) {
+	m.turns++
+	m.last = msgs[len(msgs)-1]
+	if m.turns == 1 {
+		call := openrouter.ToolCall{ID: "ask1"}
+		call.Function.Name = tools.AskUserToolName
+		call.Function.Arguments = `{"question": "Which region?", "expect": {"type": "choice", "choices": ["eu", "us"]}, "step": "create the bucket there"}`
+		return "", []openrouter.ToolCall{call}, nil
+	}
+	return "Created the bucket in " + m.last.Content, nil, nil
+}
+
+func (m *askingLLM) Embed(ctx context.Context, text string) ([]float32, error) { return nil, nil }
+
+func newPendingInputLoop(t *testing.T) (*Loop, *askingLLM, context.Context) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.GetOrCreateUser(ctx, "u1", "", "api")
+	reg := NewSubmindRegistry(t.TempDir())
+	if err := reg.Add(core.SubMindConfig{Name: "setup", SystemPrompt: "sys", AllowedTools: []string{"allowed_tool"}, MaxTurns: 5}); err != nil {
+		t.Fatal(err)
+	}
+	llm := &askingLLM{}
+	l := &Loop{DB: db, Client: llm, Executor: &MockSubmindExecutor{}, SubmindRegistry: reg}
+	ctx = context.WithValue(ctx, "user_id", "u1")
+	ctx = gateway.WithMessage(ctx, gateway.Message{SenderID: "u1", Channel: "api", ThreadID: "t1"})
+	return l, llm, ctx
+}
+
+func TestSubmindPausesForUserInput(t *testing.T) {
+	l, llm, ctx := newPendingInputLoop(t)
+
+	res, err := l.SpawnSubmind(ctx, "u1", "setup", "set up storage", 0)
+	if err != nil || !res.AwaitingInput || res.Question != "Which region?" {
+		t.Fatalf("spawn = %+v, %v", res, err)
+	}
+	if ses, _ := l.DB.GetSubmindSession(ctx, res.SessionID, "u1"); ses.Status != store.AwaitingInput {
+		t.Errorf("session status = %s", ses.Status)
+	}
+
+	// Someone else in the thread does not answer; a reply that does not fit keeps the question open
+	if note := l.resumePendingInput(ctx, "u2", gateway.Message{SenderID: "u2", ThreadID: "t1", Content: "eu"}); note != "" {
+		t.Errorf("other user's message resumed: %q", note)
+	}
+	note := l.resumePendingInput(ctx, "u1", gateway.Message{SenderID: "u1", ThreadID: "t1", Content: "whichever is cheaper"})
+	if !strings.Contains(note, "[PENDING QUESTION]") || !strings.Contains(note, "one of: eu, us") {
+		t.Errorf("invalid answer note = %q", note)
+	}
+
+	note = l.resumePendingInput(ctx, "u1", gateway.Message{SenderID: "u1", ThreadID: "t1", Content: "EU"})
+	if !strings.Contains(note, "[RESUMED SUB-MIND]") || !strings.Contains(note, `Created the bucket in {"answer":"eu"}`) {
+		t.Errorf("resume note = %q", note)
+	}
+	if llm.last.Role != "tool" || llm.last.ToolCallID != "ask1" {
+		t.Errorf("sub-mind resumed with %+v", llm.last)
+	}
+	if ses, _ := l.DB.GetSubmindSession(ctx, res.SessionID, "u1"); ses.Status != "completed" {
+		t.Errorf("session status after answer = %s", ses.Status)
+	}
+	if p, _ := l.DB.OpenPendingInput(ctx, "t1"); p != nil {
+		t.Errorf("question still open: %+v", p)
+	}
+}
+
+func TestJobAwaitsUserInput(t *testing.T) {
+	l, _, ctx := newPendingInputLoop(t)
+	jobID, _ := l.DB.CreateJob(ctx, "u1", "Nightly backups", "")
+	exec := &tools.Executor{DB: l.DB}
+
+	out, _ := exec.AskUserTool(ctx, `{"question": "Paste the S3 access key", "step": "store it with store_secret and run backup_now", "job_id": 1}`)
+	if !strings.Contains(out, store.AwaitingInput) {
+		t.Fatalf("ask_user = %s", out)
+	}
+	if job, _ := l.DB.GetJob(ctx, jobID); job.Status != store.AwaitingInput || job.BlockedReason != "Paste the S3 access key" {
+		t.Errorf("job = %+v", job)
+	}
+	note := l.resumePendingInput(ctx, "u1", gateway.Message{SenderID: "u1", ThreadID: "t1", Content: "AKIA123"})
+	if !strings.Contains(note, "[RESUMING PENDING STEP]") || !strings.Contains(note, "run backup_now") || !strings.Contains(note, `"AKIA123"`) {
+		t.Errorf("note = %q", note)
+	}
+	if job, _ := l.DB.GetJob(ctx, jobID); job.Status != "open" {
+		t.Errorf("job after answer = %s", job.Status)
+	}
+
+	// Cancelling blocks the job on the unanswered question
+	exec.AskUserTool(ctx, `{"question": "Which day?", "step": "schedule it", "job_id": 1}`)
+	if note := l.resumePendingInput(ctx, "u1", gateway.Message{SenderID: "u1", ThreadID: "t1", Content: "Never mind."}); !strings.Contains(note, "CANCELLED") {
+		t.Errorf("cancel note = %q", note)
+	}
+	if job, _ := l.DB.GetJob(ctx, jobID); job.Status != "blocked" {
+		t.Errorf("job after cancel = %s", job.Status)
+	}
+}
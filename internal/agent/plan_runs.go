@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"context"
+	"log"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// planRunPrompt asks the agent for a structured result at the end of a scheduled task.
+const planRunPrompt = "\n\n[SCHEDULED RUN]: When the task is done (or cannot be done), call report_task_result once with status, a short summary, any artifacts (files, URLs, IDs), and optionally next_suggested_run. This record is how recurring tasks are audited."
+
+// startPlanRun opens a plan_runs record for a turn triggered by a scheduled plan and puts its ID in
+// ctx as "plan_run_id" for report_task_result. Returns 0 for user-initiated turns.
+func (l *Loop) startPlanRun(ctx context.Context, userID string, msg gateway.Message) (context.Context, int64) {
+	if msg.PlanID == 0 {
+		return ctx, 0
+	}
+	runID, err := l.DB.StartPlanRun(ctx, msg.PlanID, userID, msg.ThreadID)
+	if err != nil {
+		log.Printf("[AGENT] Failed to record run of plan %d: %v", msg.PlanID, err)
+		return ctx, 0
+	}
+	return context.WithValue(ctx, "plan_run_id", runID), runID
+}
+
+// finishPlanRun records the turn's outcome when the agent did not call report_task_result:
+// failed on error, otherwise succeeded with the final reply as the summary.
+func (l *Loop) finishPlanRun(ctx context.Context, runID int64, reply string, turnErr error) {
+	run, err := l.DB.PlanRunByID(ctx, runID)
+	if err != nil || run == nil || run.Status != store.RunRunning {
+		return
+	}
+	result := store.PlanRun{Status: store.RunSucceeded, Summary: truncateSummary(reply)}
+	if turnErr != nil {
+		result = store.PlanRun{Status: store.RunFailed, Summary: truncateSummary(turnErr.Error())}
+	}
+	if err := l.DB.FinishPlanRun(ctx, runID, result); err != nil {
+		log.Printf("[AGENT] Failed to finish run %d: %v", runID, err)
+	}
+}
+
+func truncateSummary(s string) string {
+	const max = 500
+	if r := []rune(s); len(r) > max {
+		return string(r[:max]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+var verbosityGuidance = map[string]string{
+	"brief":    "keep replies short; lead with the answer and skip background unless asked",
+	"normal":   "answer fully but without padding",
+	"detailed": "give thorough answers with reasoning, steps and examples",
+}
+
+var formalityGuidance = map[string]string{
+	"casual":  "relaxed and friendly, first names, contractions are fine",
+	"neutral": "plain and polite",
+	"formal":  "formal and courteous, no slang or emoji",
+}
+
+// profileContext formats the user's profile (manage_profile) as a system prompt section the model
+// should follow, or "" when nothing is set. now is shown in the user's time zone.
+func profileContext(p *store.UserProfile, now time.Time) string {
+	if p == nil {
+		return ""
+	}
+	var lines []string
+	if p.Language != "" {
+		lines = append(lines, fmt.Sprintf("- Language: reply in %s unless the user writes in another language", p.Language))
+	}
+	if p.Timezone != "" {
+		lines = append(lines, fmt.Sprintf("- Time zone: %s (their local time now: %s); use it for times and schedules", p.Timezone, now.In(p.Location()).Format("Mon 2006-01-02 15:04")))
+	}
+	if g, ok := verbosityGuidance[p.Verbosity]; ok {
+		lines = append(lines, fmt.Sprintf("- Verbosity: %s (%s)", p.Verbosity, g))
+	}
+	if g, ok := formalityGuidance[p.Formality]; ok {
+		lines = append(lines, fmt.Sprintf("- Formality: %s (%s)", p.Formality, g))
+	}
+	if p.QuietStart != "" {
+		line := fmt.Sprintf("- Quiet hours: %s to %s; notifications that are not urgent are held until they end", p.QuietStart, p.QuietEnd)
+		if !p.QuietUntil(now).IsZero() {
+			line += " (it is quiet hours now)"
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\n\nUser Profile (follow these preferences; the user changes them with manage_profile):\n" + strings.Join(lines, "\n")
+}
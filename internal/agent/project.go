@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools/builtin"
+)
+
+// projectBlock is the current project's state for the system prompt: goal, progress, jobs,
+// schedules and the content of its context documents. It is "" when no project is current.
+func projectBlock(ctx context.Context, db *store.DB, userID string) string {
+	msg, _ := gateway.MessageFromContext(ctx)
+	p, err := db.CurrentProject(ctx, userID, msg.ThreadID)
+	if err != nil || p == nil {
+		return ""
+	}
+	st, err := builtin.BuildProjectStatus(ctx, db, p)
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\n== CURRENT PROJECT: %s (#%d) ==\n", p.Name, p.ID)
+	if p.Description != "" {
+		fmt.Fprintf(&b, "Goal: %s\n", p.Description)
+	}
+	fmt.Fprintf(&b, "Progress: %s\n", st.Summary)
+	for _, j := range st.Jobs {
+		if j.Status == "closed" {
+			continue
+		}
+		fmt.Fprintf(&b, "- Job #%d %s [%s]", j.ID, j.Title, j.Status)
+		if j.BlockedReason != "" {
+			fmt.Fprintf(&b, ": %s", j.BlockedReason)
+		}
+		b.WriteString("\n")
+	}
+	for _, pl := range st.Plans {
+		if pl.Status != "active" {
+			continue
+		}
+		fmt.Fprintf(&b, "- Schedule #%d %s", pl.ID, pl.Description)
+		if pl.NextRunAt != nil {
+			fmt.Fprintf(&b, " (next %s)", pl.NextRunAt.Format("2006-01-02 15:04"))
+		}
+		if pl.LastRun != "" {
+			fmt.Fprintf(&b, " last run %s", pl.LastRun)
+		}
+		b.WriteString("\n")
+	}
+	for _, title := range st.ContextDocs {
+		if doc, err := db.GetContextDoc(ctx, title); err == nil && doc != nil && !doc.IsActive {
+			fmt.Fprintf(&b, "### %s\n%s\n", doc.Title, doc.Content)
+		}
+	}
+	if st.Memories > 0 {
+		fmt.Fprintf(&b, "%d memories in this project (recall_memories searches them).\n", st.Memories)
+	}
+	if others, err := db.ListProjects(ctx, userID, store.ProjectActive); err == nil && len(others) > 1 {
+		var names []string
+		for _, o := range others {
+			if o.ID != p.ID {
+				names = append(names, o.Name)
+			}
+		}
+		fmt.Fprintf(&b, "Other projects: %s\n", strings.Join(names, ", "))
+	}
+	b.WriteString("New jobs, schedules and memories go into this project. project_status has the details; manage_project switches or edits projects.\n===============================\n")
+	return b.String()
+}
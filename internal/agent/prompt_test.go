@@ -4,9 +4,13 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
 	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools"
 )
 
 func TestBuildSystemPrompt_contains_SelfImprovement(t *testing.T) {
@@ -75,3 +79,95 @@ func TestBuildSystemPrompt_no_broken_tools_block_when_empty(t *testing.T) {
 		t.Error("expected no BROKEN TOOLS block when no broken tools")
 	}
 }
+
+func TestBuildSystemPrompt_injects_current_project(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.GetOrCreateUser(ctx, "user1", "", "api")
+	id, _ := db.CreateProject(ctx, "user1", "Website", "Relaunch the site")
+	job, _ := db.CreateJob(ctx, "user1", "Pick a theme", "")
+	db.UpdateJobStatus(ctx, job, "blocked", "waiting on the designer")
+	db.SetJobProject(ctx, "user1", job, id)
+	db.CreateContextDoc(ctx, "Brand guide", "Use green.", "colors")
+	db.AddProjectItem(ctx, id, store.ProjectItemContextDoc, "Brand guide")
+	cfg := &config.Config{ConfigDir: t.TempDir(), WorkspaceDir: t.TempDir(), AgentName: "Test"}
+
+	prompt, _ := BuildSystemPrompt(ctx, db, cfg, "user1")
+	if strings.Contains(prompt, "CURRENT PROJECT") {
+		t.Error("project block without a current project")
+	}
+	db.SetCurrentProject(ctx, "user1", id)
+	prompt, _ = BuildSystemPrompt(ctx, db, cfg, "user1")
+	for _, want := range []string{"== CURRENT PROJECT: Website (#1) ==", "Goal: Relaunch the site", "0 of 1 jobs closed, 1 blocked", "Job #1 Pick a theme [blocked]: waiting on the designer", "### Brand guide\nUse green."} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt lacks %q", want)
+		}
+	}
+}
+
+func TestBuildSystemPrompt_injects_thread_settings(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cfg := &config.Config{ConfigDir: t.TempDir(), WorkspaceDir: t.TempDir(), AgentName: "Test"}
+	err = db.SetThreadSettings(ctx, &store.ThreadSettings{Channel: "talk", ThreadID: "ops", PromptAddendum: "This room is for homelab ops.", AllowedTools: []string{"read_logs", "registered:disk_*"}, Verbosity: "brief"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	family := gateway.WithMessage(ctx, gateway.Message{Channel: "talk", ThreadID: "family"})
+	if prompt, _ := BuildSystemPrompt(family, db, cfg, "user1"); strings.Contains(prompt, "THIS CONVERSATION") {
+		t.Error("thread block for a thread without settings")
+	}
+	ops := gateway.WithMessage(ctx, gateway.Message{Channel: "talk", ThreadID: "ops"})
+	prompt, _ := BuildSystemPrompt(ops, db, cfg, "user1")
+	for _, want := range []string{"== THIS CONVERSATION ==", "This room is for homelab ops.", "- Verbosity here: brief", "limited to: read_logs, registered:disk_*"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt lacks %q", want)
+		}
+	}
+
+	allow := threadToolAllowlist(threadSettingsFor(ops, db, gateway.Message{Channel: "talk", ThreadID: "ops"}))
+	defs := allow.FilterDefs(tools.BuiltinToolDefs(), store.RoleOwner)
+	call := func(name, args string) openrouter.ToolCall {
+		var tc openrouter.ToolCall
+		tc.Function.Name, tc.Function.Arguments = name, args
+		return tc
+	}
+	for _, c := range []struct {
+		tc   openrouter.ToolCall
+		want bool
+	}{
+		{call("read_logs", "{}"), true},
+		{call("run_terminal_cmd", "{}"), false},
+		{call("execute_registered_tool", `{"name":"disk_usage"}`), true},
+		{call("execute_registered_tool", `{"name":"send_sms"}`), false},
+	} {
+		if got := threadToolAllowed(allow, defs, c.tc); got != c.want {
+			t.Errorf("%s %s allowed = %v, want %v", c.tc.Function.Name, c.tc.Function.Arguments, got, c.want)
+		}
+	}
+	if !threadToolAllowed(nil, nil, call("run_terminal_cmd", "{}")) {
+		t.Error("a thread without a tool subset should allow every tool")
+	}
+}
+
+func TestProfileContext(t *testing.T) {
+	if got := profileContext(&store.UserProfile{UserID: "alice"}, time.Now()); got != "" {
+		t.Errorf("empty profile = %q", got)
+	}
+	p := &store.UserProfile{UserID: "alice", Language: "German", Timezone: "UTC", Verbosity: "brief", Formality: "formal", QuietStart: "22:00", QuietEnd: "07:00"}
+	got := profileContext(p, time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC))
+	for _, want := range []string{"User Profile", "reply in German", "Tue 2026-03-10 23:00", "Verbosity: brief (keep replies short", "Formality: formal", "Quiet hours: 22:00 to 07:00", "it is quiet hours now"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("profile context lacks %q:\n%s", want, got)
+		}
+	}
+}
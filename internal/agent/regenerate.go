@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// RegenerateCommand, sent as a message on its own, asks for a new answer to the thread's last
+// message. The old reply stays in the database but leaves the context, so a bad answer does not
+// steer the rest of the conversation.
+const RegenerateCommand = "/regenerate"
+
+func isRegenerateCommand(content string) bool {
+	return strings.EqualFold(strings.TrimSpace(content), RegenerateCommand)
+}
+
+// regenerate supersedes the thread's last user message and everything after it, and returns that
+// message for the turn to answer again. With nothing to regenerate it returns a notice instead.
+// Only the sender of that message or an admin may regenerate it.
+func (l *Loop) regenerate(ctx context.Context, user *store.User, msg gateway.Message) (content, notice string) {
+	last, err := l.DB.LastUserMessage(ctx, msg.ThreadID)
+	if err == sql.ErrNoRows {
+		return "", "There is no message in this conversation to answer again."
+	}
+	if err != nil {
+		log.Printf("[AGENT] Regenerate: %v", err)
+		return "", "I could not look up the last message to regenerate."
+	}
+	if last.SenderID != user.ID && user.TrustLevel != "admin" {
+		return "", "Only the sender of the last message can regenerate its reply."
+	}
+	n, err := l.DB.SupersedeFrom(ctx, msg.ThreadID, last.ID)
+	if err != nil {
+		log.Printf("[AGENT] Regenerate: %v", err)
+		return "", "I could not drop the previous reply."
+	}
+	log.Printf("[AGENT] Regenerating reply to message %d in thread %s (%d messages superseded)", last.ID, msg.ThreadID, n)
+	return last.Content, ""
+}
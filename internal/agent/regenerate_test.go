@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+)
+
+// countingClient numbers its answers and keeps the messages of the last call.
+type countingClient struct {
+	MockClient
+	calls int
+	last  []openrouter.Message
+}
+
+func (c *countingClient) ChatCompletionWithTools(ctx context.Context, msgs []openrouter.Message, defs []openrouter.ToolDefinition) (string, []openrouter.ToolCall, error) {
+	c.calls++
+	c.last = msgs
+	return fmt.Sprintf("answer %d", c.calls), nil, nil
+}
+
+func TestRegenerateReplacesLastReply(t *testing.T) {
+	ctx := context.Background()
+	db := SetupTestDB(t)
+	defer db.Close()
+	client := &countingClient{}
+	loop := &Loop{
+		Config:   &config.Config{AdminUserID: "admin", Model: "mock-model"},
+		DB:       db,
+		Client:   client,
+		Context:  &ContextManager{DB: db},
+		Executor: &MockExecutor{},
+	}
+	msg := gateway.Message{SenderID: "admin", Channel: "test", ThreadID: "t1"}
+
+	msg.Content = RegenerateCommand
+	if reply, _ := loop.RunOneTurn(ctx, msg); client.calls != 0 || reply == "" {
+		t.Fatalf("regenerate in an empty thread: reply %q after %d calls", reply, client.calls)
+	}
+	msg.Content = "name a color"
+	if reply, err := loop.RunOneTurn(ctx, msg); err != nil || reply != "answer 1" {
+		t.Fatalf("first turn = %q, %v", reply, err)
+	}
+	msg.Content = " /regenerate "
+	reply, err := loop.RunOneTurn(ctx, msg)
+	if err != nil || reply != "answer 2" {
+		t.Fatalf("regenerate = %q, %v", reply, err)
+	}
+	var users int
+	for _, m := range client.last {
+		if m.Content == "answer 1" {
+			t.Error("the replaced reply was sent to the model again")
+		}
+		if m.Role == "user" {
+			users++
+			if m.Content != "name a color" {
+				t.Errorf("model got user message %q", m.Content)
+			}
+		}
+	}
+	if users != 1 {
+		t.Errorf("model got %d user messages, want the regenerated one only", users)
+	}
+	hist, err := loop.Context.SelectHistory(ctx, "t1")
+	if err != nil || len(hist) != 2 || hist[1].Content != "answer 2" {
+		t.Errorf("history after regenerate = %+v, %v", hist, err)
+	}
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+)
+
+// registryTools can change the set of registered tools; after a round that called one, the loop
+// re-checks the registry and re-sends the tool list if it changed.
+var registryTools = map[string]bool{
+	"register_tool": true,
+	"delete_tool":   true,
+	"manage_recipe": true,
+}
+
+// registeredToolNames returns a fingerprint of the registered tool set ("" if empty).
+func (l *Loop) registeredToolNames(ctx context.Context) string {
+	regTools, err := l.DB.AllTools(ctx)
+	if err != nil {
+		return ""
+	}
+	names := make([]string, 0, len(regTools))
+	for _, t := range regTools {
+		names = append(names, t.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// resolveRegisteredCall rewrites a call that names a registered tool directly (e.g. one registered
+// earlier in this turn, which the model treats as a function) into execute_registered_tool, so it
+// runs through the same policy checks instead of failing as an unknown tool.
+func (l *Loop) resolveRegisteredCall(ctx context.Context, tc openrouter.ToolCall, builtin []openrouter.ToolDefinition) openrouter.ToolCall {
+	name := tc.Function.Name
+	if name == RequestToolsName || hasTool(builtin, name) {
+		return tc
+	}
+	rt, err := l.DB.ToolByName(ctx, name)
+	if err != nil || rt == nil {
+		return tc
+	}
+	args := json.RawMessage("{}")
+	if a := strings.TrimSpace(tc.Function.Arguments); a != "" && json.Valid([]byte(a)) {
+		args = json.RawMessage(a)
+	}
+	wrapped, err := json.Marshal(map[string]interface{}{"name": name, "args": args})
+	if err != nil {
+		return tc
+	}
+	log.Printf("[AGENT] Routing direct call to registered tool %s through execute_registered_tool", name)
+	tc.Function.Name = "execute_registered_tool"
+	tc.Function.Arguments = string(wrapped)
+	return tc
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// registeringExecutor registers a tool on register_tool and records every call.
+type registeringExecutor struct {
+	db    *store.DB
+	calls []string
+	args  []string
+}
+
+func (e *registeringExecutor) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	e.calls = append(e.calls, name)
+	e.args = append(e.args, argsJSON)
+	if name == "register_tool" {
+		if _, err := e.db.InsertTool(ctx, "weather_lookup", "bin/weather", "Look up the weather", `{"type":"object"}`); err != nil {
+			return "", err
+		}
+		return `{"id": 1, "status": "registered"}`, nil
+	}
+	return `{"stdout": "{}", "exit_code": 0}`, nil
+}
+
+func (e *registeringExecutor) SetSpawner(spawner core.SubmindSpawner) {}
+
+// scriptedClient returns one scripted tool call per round, then a final answer; it keeps the
+// messages it was sent.
+type scriptedClient struct {
+	MockClient
+	script []openrouter.ToolCall
+	seen   [][]openrouter.Message
+}
+
+func (c *scriptedClient) ChatCompletionWithTools(ctx context.Context, msgs []openrouter.Message, defs []openrouter.ToolDefinition) (string, []openrouter.ToolCall, error) {
+	c.seen = append(c.seen, append([]openrouter.Message(nil), msgs...))
+	if len(c.script) == 0 {
+		return "done", nil, nil
+	}
+	tc := c.script[0]
+	c.script = c.script[1:]
+	return "", []openrouter.ToolCall{tc}, nil
+}
+
+func toolCall(id, name, args string) openrouter.ToolCall {
+	var tc openrouter.ToolCall
+	tc.ID = id
+	tc.Function.Name = name
+	tc.Function.Arguments = args
+	return tc
+}
+
+func TestToolRegisteredMidTurnIsUsable(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+	client := &scriptedClient{script: []openrouter.ToolCall{
+		toolCall("c1", "register_tool", `{"name": "weather_lookup", "binary_path": "bin/weather"}`),
+		toolCall("c2", "weather_lookup", `{"city": "Berlin"}`),
+	}}
+	exec := &registeringExecutor{db: db}
+	loop := &Loop{
+		Config:   &config.Config{AdminUserID: "admin", Model: "mock-model"},
+		DB:       db,
+		Client:   client,
+		Context:  &ContextManager{DB: db},
+		Executor: exec,
+	}
+	if _, err := loop.RunOneTurn(context.Background(), gateway.Message{SenderID: "admin", Content: "make a weather tool and use it", Channel: "test", ThreadID: "t1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exec.calls) != 2 || exec.calls[1] != "execute_registered_tool" {
+		t.Fatalf("executor calls = %v, want register_tool then execute_registered_tool", exec.calls)
+	}
+	if !strings.Contains(exec.args[1], `"name":"weather_lookup"`) || !strings.Contains(exec.args[1], `"args":{"city":"Berlin"}`) {
+		t.Errorf("direct call not wrapped with its args: %s", exec.args[1])
+	}
+
+	// The round after registration carries the refreshed registered-tool list.
+	var refreshed bool
+	for _, m := range client.seen[1] {
+		if m.Role == "system" && strings.Contains(m.Content, "registered tools changed") && strings.Contains(m.Content, "weather_lookup") {
+			refreshed = true
+		}
+	}
+	if !refreshed {
+		t.Error("registered tool list was not refreshed after register_tool")
+	}
+}
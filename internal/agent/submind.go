@@ -2,10 +2,14 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/jsonschema"
 	"github.com/hattiebot/hattiebot/internal/openrouter"
 	"github.com/hattiebot/hattiebot/internal/store"
 	"github.com/hattiebot/hattiebot/internal/tools"
@@ -17,6 +21,10 @@ type SubMind struct {
 	Client   core.LLMClient
 	Executor core.ToolExecutor
 	LogStore *store.LogStore
+	// Tools lists registered tools for "registered:" allowlist patterns; nil hides them.
+	Tools store.ToolRegistry
+	// Progress receives each step of the run (also written to LogStore); nil = log only.
+	Progress func(SubmindProgress)
 }
 
 // Run executes the sub-mind with the given task (no persistence).
@@ -35,23 +43,41 @@ func (s *SubMind) RunWithSession(ctx context.Context, task string, sessionID int
 		result.SessionID = sessionID
 	}
 
-	// Log start
-	if s.LogStore != nil {
-		s.LogStore.LogInfo("submind", fmt.Sprintf("started mode=%s task_len=%d", s.Config.Name, len(task)))
-	}
-
 	maxTurns := s.Config.MaxTurns
 	if maxTurns <= 0 {
 		maxTurns = 10 // Default
 	}
+	progress := SubmindProgress{SessionID: sessionID, Mode: s.Config.Name, MaxTurns: maxTurns}
+
+	// Modes with an output schema request structured output and check the final answer against it
+	var outputSchema *jsonschema.Schema
+	if len(s.Config.OutputSchema) > 0 {
+		var err error
+		if outputSchema, err = jsonschema.FromValue(s.Config.OutputSchema); err != nil {
+			result.Error = fmt.Sprintf("mode %s: output_schema: %v", s.Config.Name, err)
+			return result, nil
+		}
+		ctx = core.WithResponseFormat(ctx, core.SchemaResponseFormat(schemaFormatName(s.Config.Name), s.Config.OutputSchema))
+	}
 
 	// Build filtered tool definitions
-	allTools := tools.BuiltinToolDefs()
-	filteredTools := tools.FilterToolDefs(allTools, s.Config.AllowedTools)
+	// Allowlist patterns are resolved now, so tools registered since the mode was created are included
+	role, _ := ctx.Value("user_role").(string)
 	filteredExecutor := tools.NewFilteredExecutor(s.Executor, s.Config.AllowedTools)
+	filteredExecutor.Registry = s.Tools
+	filteredTools := filteredExecutor.Allowlist.FilterDefs(tools.BuiltinToolDefs(), role)
+	// Persisted sessions can pause on a question to the user and resume with the answer
+	persisted := sessionID > 0 && db != nil && userID != ""
+	if persisted && !hasTool(filteredTools, tools.AskUserToolName) {
+		for _, td := range tools.BuiltinToolDefs() {
+			if td.Function.Name == tools.AskUserToolName {
+				filteredTools = append(filteredTools, td)
+			}
+		}
+	}
 
 	var messages []openrouter.Message
-	if sessionID > 0 && db != nil && userID != "" {
+	if persisted {
 		// Resume: load session
 		ses, err := db.GetSubmindSession(ctx, sessionID, userID)
 		if err != nil {
@@ -68,28 +94,35 @@ func (s *SubMind) RunWithSession(ctx context.Context, task string, sessionID int
 			messages = []openrouter.Message{}
 		}
 		result.Turns = ses.Turns
+		// A session paused on ask_user gets task as the answer
+		if callID := pendingAskCall(messages); callID != "" {
+			messages = append(messages, openrouter.Message{Role: "tool", Content: answerJSON(task), ToolCallID: callID})
+		}
 	} else {
 		// New run
 		messages = []openrouter.Message{
-			{Role: "system", Content: s.Config.SystemPrompt},
+			{Role: "system", Content: s.Config.SystemPrompt + s.registeredToolsNote(ctx, filteredExecutor.Allowlist) + s.outputSchemaNote()},
 			{Role: "user", Content: task},
 		}
 	}
 
+	progress.Turn = result.Turns
+	s.emit(progress, ProgressStarted, "", fmt.Sprintf("task_len=%d", len(task)))
+
 	var content string
 	var toolCalls []openrouter.ToolCall
 
 	for result.Turns < maxTurns {
 		result.Turns++
+		progress.Turn = result.Turns
+		s.emit(progress, ProgressThinking, "", "")
 
 		// Call LLM with tools
 		var err error
 		content, toolCalls, err = s.Client.ChatCompletionWithTools(ctx, messages, filteredTools)
 		if err != nil {
 			result.Error = fmt.Sprintf("LLM error: %v", err)
-			if s.LogStore != nil {
-				s.LogStore.LogError("submind", fmt.Sprintf("failed mode=%s error=%v", s.Config.Name, err))
-			}
+			s.emit(progress, ProgressFailed, "", err.Error())
 			if sessionID > 0 && db != nil {
 				_ = db.UpdateSubmindSession(ctx, sessionID, toCoreMessages(messages), result.Turns, "failed", "", result.Error)
 			}
@@ -98,6 +131,28 @@ func (s *SubMind) RunWithSession(ctx context.Context, task string, sessionID int
 
 		// No tool calls = done
 		if len(toolCalls) == 0 {
+			if outputSchema != nil {
+				out, problems := structuredOutput(outputSchema, content)
+				if problems != "" {
+					if result.Turns < maxTurns {
+						// Providers without structured output support may ignore the format: ask again
+						s.emit(progress, ProgressThinking, "", "output does not match output_schema")
+						messages = append(messages,
+							openrouter.Message{Role: "assistant", Content: content},
+							openrouter.Message{Role: "user", Content: "Your final answer must be only JSON matching the output schema, with no other text. Problems: " + problems},
+						)
+						continue
+					}
+					result.Error = "final answer does not match output_schema: " + problems
+					result.Output = content
+					s.emit(progress, ProgressFailed, "", result.Error)
+					if sessionID > 0 && db != nil {
+						_ = db.UpdateSubmindSession(ctx, sessionID, toCoreMessages(messages), result.Turns, "failed", content, result.Error)
+					}
+					return result, nil
+				}
+				content = out
+			}
 			result.Success = true
 			result.Output = content
 			if sessionID > 0 && db != nil {
@@ -114,8 +169,14 @@ func (s *SubMind) RunWithSession(ctx context.Context, task string, sessionID int
 			ToolCalls: toolCalls,
 		})
 
-		// Execute each tool call
-		for _, tc := range toolCalls {
+		// Execute each tool call; a question to the user is answered when the session resumes
+		var ask *openrouter.ToolCall
+		for i, tc := range toolCalls {
+			if persisted && tc.Function.Name == tools.AskUserToolName && ask == nil {
+				ask = &toolCalls[i]
+				continue
+			}
+			s.emit(progress, ProgressTool, tc.Function.Name, "")
 			toolResult, _ := filteredExecutor.Execute(ctx, tc.Function.Name, tc.Function.Arguments)
 			messages = append(messages, openrouter.Message{
 				Role:       "tool",
@@ -123,6 +184,18 @@ func (s *SubMind) RunWithSession(ctx context.Context, task string, sessionID int
 				ToolCallID: tc.ID,
 			})
 		}
+		if ask != nil {
+			question, err := s.suspend(ctx, db, sessionID, userID, *ask, messages, result.Turns)
+			if err == nil {
+				result.Success = true
+				result.AwaitingInput = true
+				result.Question = question
+				result.Output = content
+				s.emit(progress, ProgressAwaiting, "", question)
+				return result, nil
+			}
+			messages = append(messages, openrouter.Message{Role: "tool", Content: fmt.Sprintf(`{"error": %q}`, err.Error()), ToolCallID: ask.ID})
+		}
 
 		// Checkpoint
 		if sessionID > 0 && db != nil {
@@ -141,13 +214,137 @@ func (s *SubMind) RunWithSession(ctx context.Context, task string, sessionID int
 		}
 	}
 
-	if s.LogStore != nil {
-		s.LogStore.LogInfo("submind", fmt.Sprintf("completed mode=%s turns=%d success=%v truncated=%v",
-			s.Config.Name, result.Turns, result.Success, result.Truncated))
+	detail := ""
+	if result.Truncated {
+		detail = "hit max_turns"
 	}
+	s.emit(progress, ProgressCompleted, "", detail)
 	return result, nil
 }
 
+// outputSchemaNote tells the model the shape of its final answer, for providers that do not
+// enforce the response format.
+func (s *SubMind) outputSchemaNote() string {
+	if len(s.Config.OutputSchema) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(s.Config.OutputSchema)
+	return "\n\nYour final answer (the reply without tool calls) must be only a JSON value matching this JSON Schema, with no other text:\n" + string(b)
+}
+
+// structuredOutput extracts the JSON answer from content, tolerating a Markdown code fence, and
+// validates it against schema. problems is empty when it matches.
+func structuredOutput(schema *jsonschema.Schema, content string) (out, problems string) {
+	out = strings.TrimSpace(content)
+	if strings.HasPrefix(out, "```") {
+		out = strings.TrimPrefix(out, "```json")
+		out = strings.TrimPrefix(out, "```")
+		out = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(out), "```"))
+	}
+	if !json.Valid([]byte(out)) {
+		return out, "the answer is not valid JSON"
+	}
+	errs := schema.ValidateJSON([]byte(out))
+	if len(errs) == 0 {
+		return out, ""
+	}
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return out, strings.Join(msgs, "; ")
+}
+
+// schemaFormatName makes a mode name usable as a response format name ([a-zA-Z0-9_-]).
+func schemaFormatName(mode string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, mode)
+	if name == "" {
+		return "output"
+	}
+	return name
+}
+
+// emit records one step of the run in the log store and passes it to Progress.
+func (s *SubMind) emit(p SubmindProgress, status, tool, detail string) {
+	p.Status, p.Tool, p.Detail = status, tool, detail
+	if s.LogStore != nil {
+		if status == ProgressFailed {
+			s.LogStore.LogError("submind", p.logLine())
+		} else {
+			s.LogStore.LogInfo("submind", p.logLine())
+		}
+	}
+	if s.Progress != nil {
+		s.Progress(p)
+	}
+}
+
+// suspend records the sub-mind's question as a pending input for the conversation the sub-mind
+// runs in and parks the session in status awaiting_input.
+func (s *SubMind) suspend(ctx context.Context, db *store.DB, sessionID int64, userID string, call openrouter.ToolCall, messages []openrouter.Message, turns int) (string, error) {
+	var args struct {
+		Question string            `json:"question"`
+		Expect   store.Expectation `json:"expect"`
+		Step     string            `json:"step"`
+	}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(args.Question) == "" {
+		return "", fmt.Errorf("question is required")
+	}
+	msg, ok := gateway.MessageFromContext(ctx)
+	if !ok || msg.ThreadID == "" || msg.Autonomous {
+		return "", fmt.Errorf("no user to ask here; finish with what you have and say what is missing")
+	}
+	if _, err := db.CreatePendingInput(ctx, store.PendingInput{
+		UserID:           userID,
+		ThreadID:         msg.ThreadID,
+		Channel:          msg.Channel,
+		SubmindSessionID: sessionID,
+		Question:         args.Question,
+		Expect:           args.Expect,
+		Step:             args.Step,
+	}); err != nil {
+		return "", err
+	}
+	if err := db.UpdateSubmindSession(ctx, sessionID, toCoreMessages(messages), turns, store.AwaitingInput, "", ""); err != nil {
+		return "", err
+	}
+	return args.Question, nil
+}
+
+// pendingAskCall returns the ID of the unanswered ask_user call in the last assistant message.
+func pendingAskCall(messages []openrouter.Message) string {
+	answered := map[string]bool{}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "tool" {
+			answered[messages[i].ToolCallID] = true
+			continue
+		}
+		if messages[i].Role != "assistant" {
+			return ""
+		}
+		for _, tc := range messages[i].ToolCalls {
+			if tc.Function.Name == tools.AskUserToolName && !answered[tc.ID] {
+				return tc.ID
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+func answerJSON(answer string) string {
+	b, _ := json.Marshal(map[string]string{"answer": answer})
+	return string(b)
+}
+
 func toCoreMessages(msgs []openrouter.Message) []core.Message {
 	out := make([]core.Message, len(msgs))
 	for i, m := range msgs {
@@ -155,3 +352,24 @@ func toCoreMessages(msgs []openrouter.Message) []core.Message {
 	}
 	return out
 }
+
+// registeredToolsNote lists the registered tools the allowlist permits, for the system prompt.
+func (s *SubMind) registeredToolsNote(ctx context.Context, allow *tools.ToolAllowlist) string {
+	if s.Tools == nil || !allow.HasRegistered() {
+		return ""
+	}
+	regTools, err := s.Tools.AllTools(ctx)
+	if err != nil {
+		return ""
+	}
+	var lines []string
+	for _, t := range regTools {
+		if allow.AllowsRegistered(t.Name) {
+			lines = append(lines, fmt.Sprintf("- %s: %s", t.Name, t.Description))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\n\nRegistered tools (call with execute_registered_tool {\"name\": ..., \"args\": {...}}):\n" + strings.Join(lines, "\n")
+}
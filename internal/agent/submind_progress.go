@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+)
+
+// DefaultSubmindProgressInterval is the least time between two progress updates in a thread.
+const DefaultSubmindProgressInterval = time.Minute
+
+// Sub-mind progress states.
+const (
+	ProgressStarted   = "started"
+	ProgressThinking  = "thinking"
+	ProgressTool      = "tool"
+	ProgressAwaiting  = "awaiting_input"
+	ProgressCompleted = "completed"
+	ProgressFailed    = "failed"
+)
+
+// SubmindProgress is one step of a sub-mind run, emitted as it happens.
+type SubmindProgress struct {
+	SessionID int64  `json:"session_id,omitempty"`
+	Mode      string `json:"mode"`
+	Turn      int    `json:"turn"`
+	MaxTurns  int    `json:"max_turns"`
+	Status    string `json:"status"`
+	Tool      string `json:"tool,omitempty"` // for ProgressTool
+	Detail    string `json:"detail,omitempty"`
+}
+
+// Done reports whether the run has stopped (finished, failed, or waiting on the user).
+func (p SubmindProgress) Done() bool {
+	return p.Status == ProgressCompleted || p.Status == ProgressFailed || p.Status == ProgressAwaiting
+}
+
+// String is the one-line status shown to the user, e.g. "planning sub-mind: turn 3/10, running web_search".
+func (p SubmindProgress) String() string {
+	s := fmt.Sprintf("%s sub-mind", p.Mode)
+	if p.SessionID != 0 {
+		s += fmt.Sprintf(" #%d", p.SessionID)
+	}
+	s += fmt.Sprintf(": turn %d/%d, ", p.Turn, p.MaxTurns)
+	switch p.Status {
+	case ProgressTool:
+		return s + "running " + p.Tool
+	case ProgressThinking:
+		return s + "thinking"
+	}
+	return s + p.Status
+}
+
+// logLine is the progress entry for the log store.
+func (p SubmindProgress) logLine() string {
+	s := fmt.Sprintf("progress mode=%s session=%d turn=%d/%d status=%s", p.Mode, p.SessionID, p.Turn, p.MaxTurns, p.Status)
+	if p.Tool != "" {
+		s += " tool=" + p.Tool
+	}
+	if p.Detail != "" {
+		s += fmt.Sprintf(" detail=%q", p.Detail)
+	}
+	return s
+}
+
+// progressThrottle posts sub-mind progress to the conversation the run belongs to, at most once
+// per interval. Nothing is posted during the first interval, so quick runs stay silent, and the
+// final step is left to the parent's reply.
+type progressThrottle struct {
+	interval time.Duration
+	send     func(string)
+
+	mu    sync.Mutex
+	start time.Time
+	last  time.Time
+}
+
+func (t *progressThrottle) report(p SubmindProgress) {
+	if p.Done() {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.start.IsZero() {
+		t.start = now
+	}
+	due := now.Sub(t.start) >= t.interval && now.Sub(t.last) >= t.interval
+	if due {
+		t.last = now
+	}
+	t.mu.Unlock()
+	if due {
+		t.send("⏳ " + p.String())
+	}
+}
+
+// submindProgress returns the progress hook for a sub-mind run in ctx: throttled status lines in
+// the user's thread, or nil when there is no gateway, no thread, or the turn is autonomous.
+func (l *Loop) submindProgress(ctx context.Context) func(SubmindProgress) {
+	interval := DefaultSubmindProgressInterval
+	if l.Config != nil {
+		interval = time.Duration(l.Config.SubmindProgressSec) * time.Second
+	}
+	msg, ok := gateway.MessageFromContext(ctx)
+	if l.Gateway == nil || interval <= 0 || !ok || msg.ThreadID == "" || msg.Autonomous {
+		return nil
+	}
+	gw := l.Gateway
+	t := &progressThrottle{interval: interval, send: func(text string) { gw.RouteReply(msg, text) }}
+	return t.report
+}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestSubMindEmitsProgress(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	logs := store.NewLogStore(db.DB)
+
+	var events []SubmindProgress
+	sm := &SubMind{
+		Config:   core.SubMindConfig{Name: "planning", SystemPrompt: "sys", AllowedTools: []string{"allowed_tool"}, MaxTurns: 5},
+		Client:   &MockSubmindLLM{},
+		Executor: &MockSubmindExecutor{},
+		LogStore: logs,
+		Progress: func(p SubmindProgress) { events = append(events, p) },
+	}
+	if _, err := sm.Run(ctx, "plan it"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Status+":"+e.Tool)
+	}
+	want := "started: thinking: tool:allowed_tool thinking: completed:"
+	if strings.Join(got, " ") != want {
+		t.Errorf("events = %v, want %s", got, want)
+	}
+	if s := events[2].String(); s != "planning sub-mind: turn 1/5, running allowed_tool" {
+		t.Errorf("status line = %q", s)
+	}
+
+	entries, _ := logs.GetLogs("", "submind", 10)
+	if len(entries) != len(events) {
+		t.Fatalf("logged %d entries, want %d", len(entries), len(events))
+	}
+	found := false
+	for _, e := range entries {
+		found = found || strings.Contains(e.Message, "status=tool tool=allowed_tool")
+	}
+	if !found {
+		t.Errorf("no tool progress in logs: %+v", entries)
+	}
+}
+
+func TestProgressThrottle(t *testing.T) {
+	var sent []string
+	th := &progressThrottle{interval: 50 * time.Millisecond, send: func(s string) { sent = append(sent, s) }}
+	step := SubmindProgress{Mode: "planning", Turn: 1, MaxTurns: 10, Status: ProgressThinking}
+
+	th.report(SubmindProgress{Mode: "planning", Status: ProgressStarted})
+	th.report(step)
+	if len(sent) != 0 {
+		t.Fatalf("posted during the first interval: %v", sent)
+	}
+	time.Sleep(60 * time.Millisecond)
+	th.report(step)
+	th.report(step)
+	if len(sent) != 1 || sent[0] != "⏳ planning sub-mind: turn 1/10, thinking" {
+		t.Fatalf("sent = %v", sent)
+	}
+	time.Sleep(60 * time.Millisecond)
+	th.report(SubmindProgress{Mode: "planning", Turn: 4, MaxTurns: 10, Status: ProgressCompleted})
+	if len(sent) != 1 {
+		t.Errorf("final step posted: %v", sent)
+	}
+}
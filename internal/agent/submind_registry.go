@@ -62,23 +62,53 @@ func LoadSubmindRegistry(configDir string) (*SubmindRegistry, error) {
 	return registry, nil
 }
 
+// planSchema is the output of the planning mode: steps the parent can follow without re-parsing
+// prose. It meets the strict structured-output rules.
+var planSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"steps": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"description": map[string]interface{}{"type": "string"},
+					"tools":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+					"files":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+				"required":             []string{"description", "tools", "files"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"steps"},
+	"additionalProperties": false,
+}
+
 // loadDefaults adds built-in sub-mind configurations.
 func (r *SubmindRegistry) loadDefaults() {
 	defaults := []core.SubMindConfig{
 		{
 			Name:         "reflection",
-			SystemPrompt: "You are analyzing your own system state. Be conservative — only flag real problems.\n\nIf healthy: \"No issues detected.\"\nIf problems: Describe ONE issue and suggest ONE action.",
+			SystemPrompt: "You are analyzing your own system state. Be conservative — only flag real problems.\n\nIf healthy: \"No issues detected.\"\nIf problems: Describe ONE issue and suggest ONE action.\n\nUser feedback, when included, counts too: a complaint that recurs is a problem; suggest one concrete change in how you answer (a preference to remember or a habit to drop).",
 			AllowedTools: []string{"system_status", "read_logs"},
 			MaxTurns:     3,
 			Protected:    true,
 		},
 		{
 			Name:         "tool_creation",
-			SystemPrompt: "You are building a Go CLI tool.\n\n1. Define JSON schema\n2. Write Go code (CGO_ENABLED=0)\n3. Compile with go build\n4. Register with register_tool\n\nAll tools MUST be Go. Use standard library. Return JSON.",
-			AllowedTools: []string{"read_file", "write_file", "run_terminal_cmd", "register_tool", "list_dir"},
+			SystemPrompt: "You are building a Go CLI tool.\n\n1. Define JSON schema\n2. Write Go code (CGO_ENABLED=0)\n3. Compile with go build\n4. Register with register_tool\n\nWhen repairing a tool, start from read_tool_source: it has the exact code of the registered version.\n\nAll tools MUST be Go. Use standard library. Return JSON.",
+			AllowedTools: []string{"read_file", "write_file", "run_terminal_cmd", "register_tool", "read_tool_source", "list_dir"},
 			MaxTurns:     20,
 			Protected:    true,
 		},
+		{
+			Name:         "tool_repair",
+			SystemPrompt: "You are repairing a broken Go CLI tool (JSON on stdin, JSON on stdout). Read the code and the error, find the cause, and make the smallest fix. Only edit and build inside the directory you are given; do not register the tool. Use the standard library and CGO_ENABLED=0.",
+			AllowedTools: []string{"read_tool_source", "read_file", "write_file", "list_dir", "run_terminal_cmd"},
+			MaxTurns:     15,
+			Protected:    true,
+		},
 		{
 			Name:         "code_analysis",
 			SystemPrompt: "Analyze the provided code. Focus on structure, purpose, and potential issues. Do NOT modify files.",
@@ -88,10 +118,11 @@ func (r *SubmindRegistry) loadDefaults() {
 		},
 		{
 			Name:         "planning",
-			SystemPrompt: "Decompose this task into numbered steps. Be specific about files and tools needed.",
+			SystemPrompt: "Decompose this task into ordered steps. Be specific about files and tools needed.",
 			AllowedTools: []string{},
 			MaxTurns:     3,
 			Protected:    true,
+			OutputSchema: planSchema,
 		},
 		{
 			Name:         "nextcloud_explorer",
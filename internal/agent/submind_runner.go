@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// DefaultSubmindConcurrency is how many background sub-minds run at once unless configured.
+const DefaultSubmindConcurrency = 3
+
+// DefaultSubmindTimeout bounds one background run, like a synchronous spawn_submind call.
+const DefaultSubmindTimeout = 15 * time.Minute
+
+// SubmindRunner runs sub-mind sessions in the background, at most Concurrency at a time, so
+// spawn_submind can return a session ID at once and check_submind collects the results later.
+// Sessions are persisted; ones cut off by a restart are picked up again by Recover.
+type SubmindRunner struct {
+	Loop    *Loop
+	Timeout time.Duration // per run; 0 = DefaultSubmindTimeout
+
+	ctx     context.Context // cancelled on shutdown; runs stop and are left for Recover
+	sem     chan struct{}
+	mu      sync.Mutex
+	running map[int64]runningSubmind
+	wg      sync.WaitGroup
+}
+
+type runningSubmind struct {
+	userID string
+	cancel context.CancelFunc
+}
+
+// NewSubmindRunner returns a runner whose runs live until ctx is done. concurrency <= 0 uses
+// DefaultSubmindConcurrency.
+func NewSubmindRunner(ctx context.Context, loop *Loop, concurrency int) *SubmindRunner {
+	if concurrency <= 0 {
+		concurrency = DefaultSubmindConcurrency
+	}
+	return &SubmindRunner{
+		Loop:    loop,
+		ctx:     ctx,
+		sem:     make(chan struct{}, concurrency),
+		running: make(map[int64]runningSubmind),
+	}
+}
+
+// Start creates a session for task and queues it. The run acts as the caller's user (role and
+// trust level from ctx) in the caller's conversation, so it can ask the user with ask_user.
+func (r *SubmindRunner) Start(ctx context.Context, userID, mode, task string) (int64, error) {
+	if r.Loop.SubmindRegistry == nil {
+		return 0, fmt.Errorf("submind registry not initialized")
+	}
+	cfg, ok := r.Loop.SubmindRegistry.Get(mode)
+	if !ok {
+		return 0, fmt.Errorf("unknown submind mode: %s", mode)
+	}
+	if userID == "" {
+		return 0, fmt.Errorf("user context required")
+	}
+	id, err := r.Loop.DB.CreateSubmindSession(ctx, userID, mode, task, cfg.SystemPrompt)
+	if err != nil {
+		return 0, err
+	}
+	msg, _ := gateway.MessageFromContext(ctx)
+	if err := r.Loop.DB.QueueSubmindSession(ctx, id, msg.ThreadID, msg.Channel); err != nil {
+		return 0, err
+	}
+	trust, _ := ctx.Value("user_trust").(string)
+	role, _ := ctx.Value("user_role").(string)
+	r.launch(store.SubmindSession{ID: id, UserID: userID, Mode: mode, ThreadID: msg.ThreadID, Channel: msg.Channel}, trust, role, task)
+	return id, nil
+}
+
+// Resume continues a background session with task (for a session waiting on ask_user, the answer).
+func (r *SubmindRunner) Resume(ctx context.Context, s store.SubmindSession, task string) {
+	trust, _ := ctx.Value("user_trust").(string)
+	role, _ := ctx.Value("user_role").(string)
+	r.launch(s, trust, role, task)
+}
+
+// Recover restarts the background sessions that were queued or running when the process stopped.
+func (r *SubmindRunner) Recover(ctx context.Context) (int, error) {
+	sessions, err := r.Loop.DB.UnfinishedAsyncSubmindSessions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range sessions {
+		u, err := r.Loop.DB.GetUser(ctx, s.UserID)
+		if err != nil {
+			_ = r.Loop.DB.FailSubmindSession(ctx, s.ID, "user not found after restart")
+			continue
+		}
+		r.launch(s, u.TrustLevel, u.Role, s.Task)
+	}
+	return len(sessions), nil
+}
+
+// Cancel stops one of userID's running or queued sessions; false when there is none.
+func (r *SubmindRunner) Cancel(userID string, id int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run, ok := r.running[id]
+	if !ok || run.userID != userID {
+		return false
+	}
+	run.cancel()
+	return true
+}
+
+// Running returns the IDs of the sessions queued or running in this process.
+func (r *SubmindRunner) Running() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]int64, 0, len(r.running))
+	for id := range r.running {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Wait blocks until every launched run has returned.
+func (r *SubmindRunner) Wait() {
+	r.wg.Wait()
+}
+
+func (r *SubmindRunner) launch(s store.SubmindSession, trust, role, task string) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSubmindTimeout
+	}
+	base := context.WithValue(r.ctx, "user_id", s.UserID)
+	base = context.WithValue(base, "user_trust", trust)
+	base = context.WithValue(base, "user_role", role)
+	base = gateway.WithMessage(base, gateway.Message{SenderID: s.UserID, Channel: s.Channel, ThreadID: s.ThreadID})
+	ctx, cancel := context.WithTimeout(base, timeout)
+
+	r.mu.Lock()
+	r.running[s.ID] = runningSubmind{userID: s.UserID, cancel: cancel}
+	r.mu.Unlock()
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer func() {
+			r.mu.Lock()
+			delete(r.running, s.ID)
+			r.mu.Unlock()
+			cancel()
+		}()
+		select {
+		case r.sem <- struct{}{}:
+		case <-ctx.Done():
+			r.finish(ctx, s.ID, nil)
+			return
+		}
+		defer func() { <-r.sem }()
+		if err := r.Loop.DB.SetSubmindSessionStatus(ctx, s.ID, "running"); err != nil {
+			log.Printf("[SUBMIND] session %d: %v", s.ID, err)
+		}
+		_, err := r.Loop.SpawnSubmind(ctx, s.UserID, s.Mode, task, s.ID)
+		r.finish(ctx, s.ID, err)
+	}()
+}
+
+// finish records why a run stopped early. A shutdown leaves the session for Recover.
+func (r *SubmindRunner) finish(ctx context.Context, id int64, err error) {
+	reason := ""
+	switch {
+	case r.ctx.Err() != nil:
+		return
+	case errors.Is(ctx.Err(), context.Canceled):
+		reason = "cancelled"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		reason = "timed out"
+	case err != nil:
+		reason = err.Error()
+	default:
+		return
+	}
+	// ctx is done here; record the outcome regardless
+	if ferr := r.Loop.DB.FailSubmindSession(context.Background(), id, reason); ferr != nil {
+		log.Printf("[SUBMIND] session %d: %v", id, ferr)
+	}
+}
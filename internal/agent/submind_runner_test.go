@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// gatedLLM answers each call with the task once release is closed, recording how many calls ran at once.
+type gatedLLM struct {
+	release chan struct{}
+
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (m *gatedLLM) ChatCompletion(ctx context.Context, msgs []openrouter.Message) (string, error) {
+	return "", nil
+}
+
+func (m *gatedLLM) ChatCompletionWithTools(ctx context.Context, msgs []openrouter.Message, defs []openrouter.ToolDefinition) (string, []openrouter.ToolCall, error) {
+	m.mu.Lock()
+	m.active++
+	if m.active > m.maxSeen {
+		m.maxSeen = m.active
+	}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.active--
+		m.mu.Unlock()
+	}()
+	select {
+	case <-m.release:
+		return "done: " + msgs[len(msgs)-1].Content, nil, nil
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+}
+
+func (m *gatedLLM) Embed(ctx context.Context, text string) ([]float32, error) { return nil, nil }
+
+func waitForStatus(t *testing.T, db *store.DB, id int64, status string) *store.SubmindSession {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ses, err := db.GetSubmindSession(context.Background(), id, "u1")
+		if err == nil && ses.Status == status {
+			return ses
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("session %d: want status %s, got %+v (%v)", id, status, ses, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSubmindRunnerRunsInParallel(t *testing.T) {
+	l, _, ctx := newPendingInputLoop(t)
+	llm := &gatedLLM{release: make(chan struct{})}
+	l.Client = llm
+	l.Runner = NewSubmindRunner(context.Background(), l, 2)
+
+	var ids []int64
+	for _, task := range []string{"a", "b", "c"} {
+		id, err := l.StartSubmind(ctx, "u1", "setup", task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	// Two run, the third waits for a slot
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		statuses := map[string]int{}
+		for _, id := range ids {
+			ses, _ := l.DB.GetSubmindSession(ctx, id, "u1")
+			statuses[ses.Status]++
+			if !ses.Async || ses.ThreadID != "t1" {
+				t.Fatalf("session = %+v", ses)
+			}
+		}
+		llm.mu.Lock()
+		active := llm.active
+		llm.mu.Unlock()
+		if statuses["running"] == 2 && statuses["queued"] == 1 && active == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("statuses = %v, %d in the LLM", statuses, active)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(llm.release)
+	l.Runner.Wait()
+	for i, task := range []string{"a", "b", "c"} {
+		ses := waitForStatus(t, l.DB, ids[i], "completed")
+		if ses.ResultOutput != "done: "+task {
+			t.Errorf("session %d output = %q", ids[i], ses.ResultOutput)
+		}
+	}
+	if llm.maxSeen != 2 {
+		t.Errorf("max concurrent runs = %d, want 2", llm.maxSeen)
+	}
+}
+
+func TestSubmindRunnerCancel(t *testing.T) {
+	l, _, ctx := newPendingInputLoop(t)
+	l.Client = &gatedLLM{release: make(chan struct{})}
+	l.Runner = NewSubmindRunner(context.Background(), l, 1)
+
+	id, err := l.StartSubmind(ctx, "u1", "setup", "slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, l.DB, id, "running")
+	if l.CancelSubmind("u2", id) {
+		t.Error("another user cancelled the session")
+	}
+	if !l.CancelSubmind("u1", id) {
+		t.Fatal("cancel returned false")
+	}
+	l.Runner.Wait()
+	if ses := waitForStatus(t, l.DB, id, "failed"); ses.ResultError != "cancelled" {
+		t.Errorf("error = %q", ses.ResultError)
+	}
+}
+
+func TestSubmindRunnerResumesAfterAnswer(t *testing.T) {
+	l, _, ctx := newPendingInputLoop(t)
+	l.Runner = NewSubmindRunner(context.Background(), l, 1)
+
+	id, err := l.StartSubmind(ctx, "u1", "setup", "set up storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Runner.Wait()
+	waitForStatus(t, l.DB, id, store.AwaitingInput)
+
+	note := l.resumePendingInput(ctx, "u1", gateway.Message{SenderID: "u1", ThreadID: "t1", Content: "us"})
+	if !strings.Contains(note, "continues in the background") {
+		t.Errorf("note = %q", note)
+	}
+	l.Runner.Wait()
+	if ses := waitForStatus(t, l.DB, id, "completed"); ses.ResultOutput != `Created the bucket in {"answer":"us"}` {
+		t.Errorf("output = %q", ses.ResultOutput)
+	}
+}
@@ -48,6 +48,8 @@ func (m *MockSubmindExecutor) Execute(ctx context.Context, name, args string) (s
 	return "tool_output", nil
 }
 
+func (m *MockSubmindExecutor) SetSpawner(spawner core.SubmindSpawner) {}
+
 func TestSubMindRun(t *testing.T) {
 	mockLLM := &MockSubmindLLM{}
 	mockExec := &MockSubmindExecutor{}
@@ -238,3 +240,55 @@ func (m *MockSubmindLLMInternal) ChatCompletionWithTools(ctx context.Context, ms
 	content, calls := m.ResponseFunc(m.TurnCount)
 	return content, calls, nil
 }
+
+// structuredLLM answers in prose first, then with a fenced JSON plan, recording the response
+// format each call carried.
+type structuredLLM struct {
+	replies []string
+	formats []*core.ResponseFormat
+}
+
+func (m *structuredLLM) ChatCompletion(ctx context.Context, msgs []openrouter.Message) (string, error) {
+	return "", nil
+}
+
+func (m *structuredLLM) ChatCompletionWithTools(ctx context.Context, msgs []openrouter.Message, tools []openrouter.ToolDefinition) (string, []openrouter.ToolCall, error) {
+	m.formats = append(m.formats, core.ResponseFormatFrom(ctx))
+	reply := m.replies[0]
+	m.replies = m.replies[1:]
+	return reply, nil, nil
+}
+
+func (m *structuredLLM) Embed(ctx context.Context, text string) ([]float32, error) {
+	return nil, nil
+}
+
+func TestSubMindOutputSchema(t *testing.T) {
+	cfg := core.SubMindConfig{Name: "planning", SystemPrompt: "plan", MaxTurns: 3, OutputSchema: planSchema}
+	llm := &structuredLLM{replies: []string{
+		"1. Read the file\n2. Fix it",
+		"```json\n{\"steps\": [{\"description\": \"Read main.go\", \"tools\": [\"read_file\"], \"files\": [\"main.go\"]}]}\n```",
+	}}
+	sm := &SubMind{Config: cfg, Client: llm, Executor: &MockSubmindExecutor{}}
+	result, err := sm.Run(context.Background(), "fix the bug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Turns != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.Output != `{"steps": [{"description": "Read main.go", "tools": ["read_file"], "files": ["main.go"]}]}` {
+		t.Errorf("output = %q", result.Output)
+	}
+	rf := llm.formats[0]
+	if rf == nil || rf.Type != "json_schema" || rf.JSONSchema.Name != "planning" || !rf.JSONSchema.Strict {
+		t.Errorf("response format = %+v", rf)
+	}
+
+	// An answer that never matches fails the run once the turns run out
+	cfg.MaxTurns = 1
+	sm = &SubMind{Config: cfg, Client: &structuredLLM{replies: []string{`{"steps": "none"}`}}, Executor: &MockSubmindExecutor{}}
+	if result, _ = sm.Run(context.Background(), "fix the bug"); result.Success || result.Error == "" {
+		t.Errorf("mismatching output accepted: %+v", result)
+	}
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools"
+)
+
+// threadSettingsFor returns the settings of msg's thread (manage_thread), or nil when it has none.
+func threadSettingsFor(ctx context.Context, db *store.DB, msg gateway.Message) *store.ThreadSettings {
+	if msg.ThreadID == "" {
+		return nil
+	}
+	s, err := db.GetThreadSettings(ctx, msg.Channel, msg.ThreadID)
+	if err != nil {
+		log.Printf("[AGENT] Failed to load settings for thread %s: %v", msg.ThreadID, err)
+		return nil
+	}
+	if s.IsEmpty() {
+		return nil
+	}
+	return s
+}
+
+// threadSettingsBlock formats a thread's settings as a system prompt section, or "" for nil.
+func threadSettingsBlock(s *store.ThreadSettings) string {
+	if s == nil {
+		return ""
+	}
+	block := "\n\n== THIS CONVERSATION ==\n(An admin set these for this room with manage_thread; they apply here and take precedence over the user's profile.)\n"
+	if s.PromptAddendum != "" {
+		block += strings.TrimSpace(s.PromptAddendum) + "\n"
+	}
+	if g, ok := verbosityGuidance[s.Verbosity]; ok {
+		block += fmt.Sprintf("- Verbosity here: %s (%s)\n", s.Verbosity, g)
+	}
+	if len(s.AllowedTools) > 0 {
+		block += fmt.Sprintf("- Tools here are limited to: %s. Others are not available in this room.\n", strings.Join(s.AllowedTools, ", "))
+	}
+	return block + "===============================\n"
+}
+
+// threadToolAllowlist compiles the thread's tool subset, or returns nil when every tool is allowed.
+func threadToolAllowlist(s *store.ThreadSettings) *tools.ToolAllowlist {
+	if s == nil || len(s.AllowedTools) == 0 {
+		return nil
+	}
+	return tools.CompileToolAllowlist(s.AllowedTools)
+}
+
+// threadToolAllowed reports whether tc may run under the thread's allowlist: built-in tools must be
+// among defs (already filtered by it), registered tools must match its registered: patterns.
+func threadToolAllowed(allow *tools.ToolAllowlist, defs []openrouter.ToolDefinition, tc openrouter.ToolCall) bool {
+	if allow == nil {
+		return true
+	}
+	if tc.Function.Name == "execute_registered_tool" {
+		var args struct {
+			Name string `json:"name"`
+		}
+		_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+		return allow.AllowsRegistered(args.Name)
+	}
+	return hasTool(defs, tc.Function.Name)
+}
@@ -0,0 +1,355 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// Tool repair defaults.
+const (
+	// repairUserID is the user the repair sub-mind runs as; its trust level maps to the "build"
+	// sandbox profile, so run_terminal_cmd can run go but writes only under sandboxes/tool-repair.
+	repairUserID          = "tool-repair"
+	repairTrust           = "tool_repair"
+	repairMaxAttempts     = 2
+	repairDiffMaxRunes    = 3000
+	repairSandboxDir      = "sandboxes/" + repairUserID
+	DefaultRepairInterval = 10 * time.Minute
+)
+
+// ToolRepairer repairs broken registered tools in the background: for each tool that enters status
+// broken it copies the tool's stored source into a sandbox directory, has a tool_repair sub-mind fix
+// and build it there, re-registers the fix (which re-runs the safety check and contract test),
+// replays the failing input, and notifies the admin with a diff. A fix that still fails is rolled
+// back. Each broken version gets at most repairMaxAttempts attempts.
+type ToolRepairer struct {
+	Loop   *Loop
+	Notify func(msg string)
+}
+
+// Start runs a repair pass now and then every interval.
+func (r *ToolRepairer) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			r.RunOnce(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce attempts to repair each broken tool that has attempts left. Skipped while the error
+// budget is throttled, like other autonomous work.
+func (r *ToolRepairer) RunOnce(ctx context.Context) {
+	l := r.Loop
+	if l == nil || l.DB == nil || l.SubmindRegistry == nil {
+		return
+	}
+	if l.ErrorBudget != nil && l.ErrorBudget.Throttled() {
+		return
+	}
+	broken, err := l.DB.ListBrokenTools(ctx)
+	if err != nil {
+		log.Printf("[REPAIR] listing broken tools: %v", err)
+		return
+	}
+	for _, t := range broken {
+		if t.Kind == store.ToolKindHTTP {
+			continue // no source here; the remote service is fixed where it runs
+		}
+		if n, err := l.DB.CountToolRepairs(ctx, t.Name, t.Version); err != nil || n >= repairMaxAttempts {
+			continue
+		}
+		repair, err := r.Repair(ctx, t)
+		if err != nil {
+			log.Printf("[REPAIR] %s: %v", t.Name, err)
+		}
+		if repair != nil {
+			r.notify(repairMessage(repair))
+		}
+	}
+}
+
+// Repair runs one repair attempt of a broken tool and returns the recorded outcome.
+func (r *ToolRepairer) Repair(ctx context.Context, t store.RegisteredTool) (*store.ToolRepair, error) {
+	l := r.Loop
+	id, err := l.DB.StartToolRepair(ctx, t.Name, t.Version, t.LastError)
+	if err != nil {
+		return nil, err
+	}
+	repair := &store.ToolRepair{ID: id, Name: t.Name, FromVersion: t.Version, Error: t.LastError}
+	_ = l.DB.SetToolStatus(ctx, t.Name, "pending_repair")
+	ctx = context.WithValue(ctx, "user_id", repairUserID)
+	ctx = context.WithValue(ctx, "user_trust", repairTrust)
+
+	fail := func(reason string) (*store.ToolRepair, error) {
+		_ = l.DB.SetToolStatus(ctx, t.Name, "broken")
+		repair.Status, repair.Result = store.RepairFailed, reason
+		return repair, l.DB.FinishToolRepair(ctx, id, store.RepairFailed, 0, reason, repair.Diff)
+	}
+
+	// 1. Source of the broken version into the sandbox
+	var src struct {
+		Error     string             `json:"error"`
+		SourceDir string             `json:"source_dir"`
+		Files     []store.SourceFile `json:"files"`
+	}
+	out, err := l.Executor.Execute(ctx, "read_tool_source", fmt.Sprintf(`{"name": %q}`, t.Name))
+	if err != nil || json.Unmarshal([]byte(out), &src) != nil || src.Error != "" || len(src.Files) == 0 {
+		return fail(fmt.Sprintf("no source to repair: %s", firstNonEmpty(src.Error, errString(err), out)))
+	}
+	workspace := l.Config.WorkspaceDir
+	workRel := filepath.Join(repairSandboxDir, filepath.Base(t.Name))
+	workDir := filepath.Join(workspace, workRel)
+	origDir := workDir + ".orig"
+	for _, dir := range []string{workDir, origDir} {
+		_ = os.RemoveAll(dir)
+		if err := writeSourceFiles(dir, src.Files); err != nil {
+			return fail(fmt.Sprintf("preparing sandbox: %v", err))
+		}
+	}
+	binName := filepath.Base(t.BinaryPath)
+	input, _ := l.DB.ToolFailedInput(ctx, t.Name)
+
+	// 2. Sub-mind fixes and builds in the sandbox
+	result, err := l.SpawnSubmind(ctx, "", "tool_repair", repairTask(t, input, workRel, binName), 0)
+	if err != nil {
+		return fail(fmt.Sprintf("repair sub-mind: %v", err))
+	}
+	summary := strings.TrimSpace(firstNonEmpty(result.Output, result.Error))
+	repair.Diff = sourceDiff(ctx, filepath.Dir(workDir), filepath.Base(origDir), filepath.Base(workDir), binName)
+	if strings.HasPrefix(summary, "CANNOT_FIX") {
+		return fail(strings.TrimSpace(strings.TrimPrefix(summary, "CANNOT_FIX:")))
+	}
+	built := filepath.Join(workDir, binName)
+	if _, err := os.Stat(built); err != nil {
+		return fail(fmt.Sprintf("the sub-mind did not build %s: %s", workRel+"/"+binName, summary))
+	}
+	if repair.Diff == "" {
+		return fail("the sub-mind did not change the source: " + summary)
+	}
+
+	// 3. Install over the original binary and source, then re-register (safety check + contract test)
+	binary := t.BinaryPath
+	if !filepath.IsAbs(binary) {
+		binary = filepath.Join(workspace, filepath.Clean(binary))
+	}
+	sourceDir := src.SourceDir
+	if sourceDir == "" {
+		sourceDir = workDir
+	}
+	backup := filepath.Join(origDir, ".binary")
+	if err := copyFile(binary, backup); err != nil {
+		return fail(fmt.Sprintf("backing up %s: %v", binary, err))
+	}
+	restore := func() {
+		_ = copyFile(backup, binary)
+		if sourceDir != workDir {
+			_ = writeSourceFiles(sourceDir, src.Files)
+		}
+	}
+	fixed, err := readGoSources(workDir)
+	if err == nil {
+		err = copyFile(built, binary)
+	}
+	if err == nil && sourceDir != workDir {
+		err = writeSourceFiles(sourceDir, fixed)
+	}
+	if err != nil {
+		restore()
+		return fail(fmt.Sprintf("installing fix: %v", err))
+	}
+	args, _ := json.Marshal(map[string]interface{}{
+		"name": t.Name, "binary_path": t.BinaryPath, "description": t.Description, "input_schema": t.InputSchema,
+		"source_dir": sourceDir, "force_update": true,
+	})
+	out, err = l.Executor.Execute(ctx, "register_tool", string(args))
+	var reg struct {
+		Error   string `json:"error"`
+		Version int    `json:"version"`
+	}
+	if err != nil || json.Unmarshal([]byte(out), &reg) != nil || reg.Error != "" || reg.Version == 0 {
+		restore()
+		return fail("re-registering the fix failed: " + firstNonEmpty(reg.Error, errString(err), out))
+	}
+	repair.ToVersion = reg.Version
+
+	// 4. Replay the failing input; roll back if the fix still fails
+	if input != "" {
+		replay, _ := json.Marshal(map[string]interface{}{"name": t.Name, "args": json.RawMessage(input)})
+		out, _ = l.Executor.Execute(ctx, "execute_registered_tool", string(replay))
+		if cur, _ := l.DB.ToolByName(ctx, t.Name); cur != nil && cur.FailureCount > 0 {
+			_, _ = l.Executor.Execute(ctx, "register_tool", fmt.Sprintf(`{"action": "rollback", "name": %q, "version": %d}`, t.Name, t.Version))
+			restore()
+			repair.ToVersion = 0
+			return fail(fmt.Sprintf("version %d still fails on the recorded input and was rolled back: %s", reg.Version, truncateRunes(out, 300)))
+		}
+	}
+	repair.Status, repair.Result = store.RepairFixed, summary
+	return repair, l.DB.FinishToolRepair(ctx, id, store.RepairFixed, reg.Version, summary, repair.Diff)
+}
+
+func (r *ToolRepairer) notify(msg string) {
+	log.Printf("[REPAIR] %s", strings.SplitN(msg, "\n", 2)[0])
+	if r.Notify != nil {
+		r.Notify(msg)
+	}
+}
+
+// repairTask is the sub-mind's task for one broken tool.
+func repairTask(t store.RegisteredTool, input, workRel, binName string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Repair the registered tool %q (version %d), which is broken after repeated failures.\n\n", t.Name, t.Version)
+	fmt.Fprintf(&b, "Description: %s\n", t.Description)
+	if t.InputSchema != "" {
+		fmt.Fprintf(&b, "Input schema: %s\n", t.InputSchema)
+	}
+	fmt.Fprintf(&b, "Last error: %s\n", t.LastError)
+	if input != "" {
+		fmt.Fprintf(&b, "Failing input (JSON on stdin): %s\n", input)
+	}
+	fmt.Fprintf(&b, "\nThe source of this version is in %s (read_tool_source has the same code). ", workRel)
+	fmt.Fprintf(&b, "Work only in that directory: fix the code with write_file, then build with run_terminal_cmd using work_dir %q and the command `CGO_ENABLED=0 go build -o %s .` (if there is no go.mod: `go build -o %s *.go`). ", workRel, binName, binName)
+	fmt.Fprintf(&b, "Test it with `echo '<input>' | ./%s`: it must print valid JSON and exit 0, including for the failing input. ", binName)
+	b.WriteString("Do not register the tool; it is validated and registered for you.\n\n")
+	b.WriteString("Finish with one or two sentences on the cause and the fix. If it cannot be fixed in code (e.g. the input was invalid or an external service is down), reply starting with CANNOT_FIX: and the reason.")
+	return b.String()
+}
+
+// repairMessage is the admin notification for a finished repair.
+func repairMessage(r *store.ToolRepair) string {
+	if r.Status != store.RepairFixed {
+		return fmt.Sprintf("[Tool repair] Could not repair %s (version %d): %s\nIt stays broken; fix it by hand, roll it back, or delete it.", r.Name, r.FromVersion, r.Result)
+	}
+	msg := fmt.Sprintf("[Tool repair] Repaired %s: version %d → %d. Error was: %s\nFix: %s", r.Name, r.FromVersion, r.ToVersion, r.Error, r.Result)
+	if r.Diff != "" {
+		msg += "\n```diff\n" + truncateRunes(r.Diff, repairDiffMaxRunes) + "\n```"
+	}
+	return msg + fmt.Sprintf("\nUndo with register_tool action=rollback name=%s version=%d.", r.Name, r.FromVersion)
+}
+
+// sourceDiff returns a unified diff of the source files between two directories under base,
+// ignoring the built binary. Returns "" when nothing changed.
+func sourceDiff(ctx context.Context, base, from, to, binName string) string {
+	cmd := exec.CommandContext(ctx, "diff", "-ruN", "--exclude="+binName, "--exclude=.binary", from, to)
+	cmd.Dir = base
+	out, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); err != nil && !(ok && exitErr.ExitCode() == 1) {
+		// diff unavailable: fall back to comparing file contents
+		a, _ := readGoSources(filepath.Join(base, from))
+		b, _ := readGoSources(filepath.Join(base, to))
+		return changedFiles(a, b)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func changedFiles(a, b []store.SourceFile) string {
+	before := map[string]string{}
+	for _, f := range a {
+		before[f.Name] = f.Content
+	}
+	var changed []string
+	for _, f := range b {
+		if content, ok := before[f.Name]; !ok || content != f.Content {
+			changed = append(changed, f.Name)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+	return "changed files: " + strings.Join(changed, ", ")
+}
+
+func readGoSources(dir string) ([]store.SourceFile, error) {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, extra := range []string{"go.mod", "go.sum"} {
+		if _, err := os.Stat(filepath.Join(dir, extra)); err == nil {
+			paths = append(paths, filepath.Join(dir, extra))
+		}
+	}
+	var files []store.SourceFile
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, store.SourceFile{Name: filepath.Base(p), Content: string(data)})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go source in %s", dir)
+	}
+	return files, nil
+}
+
+func writeSourceFiles(dir string, files []store.SourceFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(f.Name)), []byte(f.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// Write beside the target and rename, so a running copy of the old binary is not disturbed
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "\n…(truncated)"
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
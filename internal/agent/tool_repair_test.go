@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools"
+)
+
+const (
+	brokenToolSource = "package main\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\t\"os\"\n\t\"strings\"\n)\n\nfunc main() {\n\tin, _ := io.ReadAll(os.Stdin)\n\tif strings.Contains(string(in), \"boom\") {\n\t\tfmt.Print(\"panic: boom\")\n\t\tos.Exit(2)\n\t}\n\tfmt.Print(`{\"ok\": true}`)\n}\n"
+	fixedToolSource  = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Print(`{\"ok\": true}`)\n}\n"
+)
+
+// finalClient runs a script of tool calls and then answers with final.
+type finalClient struct {
+	scriptedClient
+	final string
+}
+
+func (c *finalClient) ChatCompletionWithTools(ctx context.Context, msgs []openrouter.Message, defs []openrouter.ToolDefinition) (string, []openrouter.ToolCall, error) {
+	if len(c.script) == 0 {
+		return c.final, nil, nil
+	}
+	return c.scriptedClient.ChatCompletionWithTools(ctx, msgs, defs)
+}
+
+// setupBrokenTool builds and registers a tool that fails on "boom" input, then breaks it.
+func setupBrokenTool(t *testing.T, client *finalClient) (*ToolRepairer, *store.DB, string, *[]string) {
+	t.Helper()
+	ctx := context.Background()
+	workspace := t.TempDir()
+	srcDir := filepath.Join(workspace, "src", "echoer")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "main.go"), []byte(brokenToolSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(workspace, "bin", "echoer")
+	if out, err := exec.CommandContext(ctx, "go", "build", "-o", bin, filepath.Join(srcDir, "main.go")).CombinedOutput(); err != nil {
+		t.Skipf("go build: %v\n%s", err, out)
+	}
+	db := SetupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+	ex := &tools.Executor{DB: db, WorkspaceDir: workspace, ConfigDir: t.TempDir()}
+	args, _ := json.Marshal(map[string]interface{}{"name": "echoer", "binary_path": bin, "description": "Echo", "source_dir": srcDir})
+	if out, _ := ex.Execute(ctx, "register_tool", string(args)); !strings.Contains(out, "registered") {
+		t.Fatalf("register: %s", out)
+	}
+	for i := 0; i < 3; i++ {
+		_, _ = ex.Execute(ctx, "execute_registered_tool", `{"name": "echoer", "args": {"mode": "boom"}}`)
+	}
+	if tool, _ := db.ToolByName(ctx, "echoer"); tool.Status != "broken" {
+		t.Fatalf("tool status = %s, want broken", tool.Status)
+	}
+	reg, _ := LoadSubmindRegistry(t.TempDir())
+	loop := &Loop{
+		Config:          &config.Config{WorkspaceDir: workspace},
+		DB:              db,
+		Client:          client,
+		Executor:        ex,
+		SubmindRegistry: reg,
+	}
+	var sent []string
+	r := &ToolRepairer{Loop: loop, Notify: func(msg string) { sent = append(sent, msg) }}
+	return r, db, srcDir, &sent
+}
+
+func TestToolRepairerFixesBrokenTool(t *testing.T) {
+	ctx := context.Background()
+	client := &finalClient{final: "The tool exited on unexpected input; it now always returns JSON."}
+	r, db, srcDir, sent := setupBrokenTool(t, client)
+	workDir := filepath.Join(r.Loop.Config.WorkspaceDir, repairSandboxDir, "echoer")
+	write, _ := json.Marshal(map[string]string{"path": filepath.Join(repairSandboxDir, "echoer", "main.go"), "content": fixedToolSource})
+	build, _ := json.Marshal(map[string]string{"command": "go build -o echoer main.go", "work_dir": workDir})
+	client.script = []openrouter.ToolCall{
+		toolCall("c1", "write_file", string(write)),
+		toolCall("c2", "run_terminal_cmd", string(build)),
+	}
+
+	r.RunOnce(ctx)
+
+	tool, _ := db.ToolByName(ctx, "echoer")
+	if tool.Status != "active" || tool.Version != 2 || tool.FailureCount != 0 {
+		t.Fatalf("after repair: %+v", tool)
+	}
+	if src, _ := os.ReadFile(filepath.Join(srcDir, "main.go")); string(src) != fixedToolSource {
+		t.Errorf("source dir not updated:\n%s", src)
+	}
+	repairs, _ := db.ListToolRepairs(ctx, "echoer", 0)
+	if len(repairs) != 1 || repairs[0].Status != store.RepairFixed || repairs[0].ToVersion != 2 {
+		t.Fatalf("repairs = %+v", repairs)
+	}
+	if len(*sent) != 1 || !strings.Contains((*sent)[0], "version 1 → 2") || !strings.Contains((*sent)[0], `-	if strings.Contains(string(in), "boom") {`) {
+		t.Errorf("notification = %v", *sent)
+	}
+	// The task carried the failing input to the sub-mind
+	if task := client.seen[0][1].Content; !strings.Contains(task, `"mode": "boom"`) || !strings.Contains(task, "panic: boom") {
+		t.Errorf("task = %s", task)
+	}
+}
+
+func TestToolRepairerGivesUp(t *testing.T) {
+	ctx := context.Background()
+	client := &finalClient{final: "CANNOT_FIX: the input is invalid"}
+	r, db, _, sent := setupBrokenTool(t, client)
+
+	for i := 0; i < repairMaxAttempts+1; i++ {
+		r.RunOnce(ctx)
+	}
+	tool, _ := db.ToolByName(ctx, "echoer")
+	if tool.Status != "broken" || tool.Version != 1 {
+		t.Fatalf("after failed repair: %+v", tool)
+	}
+	if n, _ := db.CountToolRepairs(ctx, "echoer", 1); n != repairMaxAttempts {
+		t.Errorf("attempts = %d, want %d", n, repairMaxAttempts)
+	}
+	if len(*sent) != repairMaxAttempts || !strings.Contains((*sent)[0], "the input is invalid") {
+		t.Errorf("notifications = %v", *sent)
+	}
+}
@@ -0,0 +1,251 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/memory"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+)
+
+// RequestToolsName is a loop-handled tool that swaps the per-turn tool subset for the full set.
+const RequestToolsName = "request_tools"
+
+// requestToolsDef is attached whenever the tool list is a subset, so the model can ask for the rest.
+var requestToolsDef = openrouter.ToolDefinition{
+	Type: "function",
+	Function: openrouter.FunctionSpec{
+		Name:        RequestToolsName,
+		Description: "Only tools relevant to this request are attached. Call this to get every available tool when you need one that is not listed.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"reason": map[string]string{"type": "string", "description": "What you are looking for"},
+			},
+		},
+	},
+}
+
+// CoreTools are always sent, whatever the request is about.
+var CoreTools = []string{
+	"memorize", "recall_memories", "search_history", "manage_schedule", "notify_user",
+	"read_file", "write_file", "list_dir", "run_terminal_cmd", "system_status",
+	"execute_registered_tool", "spawn_submind", "ask_user",
+}
+
+// DefaultToolRules pin tools whose descriptions embed poorly against the way people ask for
+// them. Keys are matched case-insensitively as substrings of the request.
+var DefaultToolRules = map[string][]string{
+	"briefing":  {"manage_briefing"},
+	"feed":      {"manage_feed"},
+	"rss":       {"manage_feed"},
+	"nextcloud": {"list_nextcloud_files", "read_nextcloud_file", "write_nextcloud_file"},
+	"deck":      {"manage_deck"},
+	"password":  {"get_secret", "store_secret"},
+	"secret":    {"get_secret", "store_secret"},
+	"logs":      {"read_logs"},
+	"backup":    {"backup_now"},
+	"subscri":   {"manage_event_subscriptions"},
+	"link":      {"link_identity"},
+	"quiet":     {"manage_profile", "manage_notifications"},
+	"notif":     {"manage_notifications"},
+	"digest":    {"manage_notifications"},
+	"timezone":  {"manage_profile"},
+	"language":  {"manage_profile"},
+}
+
+// recentToolLimit caps how many tools used earlier in the thread stay attached.
+const recentToolLimit = 8
+
+// ToolSelector picks the tools worth sending for a request: the core tools, tools pinned by a
+// keyword rule or by recent use in the thread, plus the MaxTools best embedding matches between
+// the request and the tool descriptions. Any failure to embed falls back to the full set.
+type ToolSelector struct {
+	Embedder core.EmbeddingClient
+	MaxTools int // relevance-ranked tools on top of the pinned ones; 0 disables subsetting
+	Core     []string
+	Rules    map[string][]string // lowercase keyword -> tools attached when the request contains it
+
+	mu    sync.Mutex
+	cache map[string][]float32 // "name: description" -> embedding
+}
+
+// NewToolSelector returns a selector that adds up to maxTools relevant tools to CoreTools, with
+// DefaultToolRules.
+func NewToolSelector(embedder core.EmbeddingClient, maxTools int) *ToolSelector {
+	return &ToolSelector{Embedder: embedder, MaxTools: maxTools, Core: CoreTools, Rules: DefaultToolRules}
+}
+
+// Configure applies the config's tool_core and tool_rules: a non-empty core list replaces
+// CoreTools, and rules are merged over the defaults (an empty list drops a default keyword).
+func (s *ToolSelector) Configure(core []string, rules map[string][]string) *ToolSelector {
+	if len(core) > 0 {
+		s.Core = core
+	}
+	if len(rules) > 0 {
+		merged := make(map[string][]string, len(s.Rules)+len(rules))
+		for k, v := range s.Rules {
+			merged[k] = v
+		}
+		for k, v := range rules {
+			k = strings.ToLower(strings.TrimSpace(k))
+			if len(v) == 0 {
+				delete(merged, k)
+			} else if k != "" {
+				merged[k] = v
+			}
+		}
+		s.Rules = merged
+	}
+	return s
+}
+
+// Select returns the tool subset for query, plus request_tools, or all when subsetting is off,
+// would not save anything, or embeddings are unavailable. recent lists tools called earlier in
+// the thread, most recent first; they stay attached so follow-ups keep their tools.
+func (s *ToolSelector) Select(ctx context.Context, query string, recent []string, all []openrouter.ToolDefinition) []openrouter.ToolDefinition {
+	if s == nil || s.Embedder == nil || s.MaxTools <= 0 || query == "" {
+		return all
+	}
+	pinned := s.pinned(query, recent)
+	var candidates []openrouter.ToolDefinition
+	for _, td := range all {
+		if !pinned[td.Function.Name] {
+			candidates = append(candidates, td)
+		}
+	}
+	if len(candidates) <= s.MaxTools {
+		return all
+	}
+
+	q, err := s.Embedder.Embed(ctx, query, "query")
+	if err != nil || len(q) == 0 {
+		log.Printf("[AGENT] Tool subsetting unavailable, sending all tools: %v", err)
+		return all
+	}
+	type scored struct {
+		name  string
+		score float64
+	}
+	vecs, err := s.toolEmbeddings(ctx, candidates, len(q))
+	if err != nil {
+		log.Printf("[AGENT] Tool subsetting unavailable, sending all tools: %v", err)
+		return all
+	}
+	ranked := make([]scored, 0, len(candidates))
+	for i, td := range candidates {
+		ranked = append(ranked, scored{td.Function.Name, memory.CosineSimilarity(q, vecs[i])})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	keep := make(map[string]bool, s.MaxTools)
+	for _, r := range ranked[:s.MaxTools] {
+		keep[r.name] = true
+	}
+
+	out := make([]openrouter.ToolDefinition, 0, len(pinned)+s.MaxTools+1)
+	for _, td := range all {
+		if pinned[td.Function.Name] || keep[td.Function.Name] {
+			out = append(out, td)
+		}
+	}
+	return append(out, requestToolsDef)
+}
+
+// pinned returns the tools sent whatever their embedding score: core tools, keyword rule
+// matches for query, and up to recentToolLimit recently used tools.
+func (s *ToolSelector) pinned(query string, recent []string) map[string]bool {
+	out := make(map[string]bool, len(s.Core)+recentToolLimit)
+	for _, name := range s.Core {
+		out[name] = true
+	}
+	lower := strings.ToLower(query)
+	for kw, names := range s.Rules {
+		if strings.Contains(lower, kw) {
+			for _, name := range names {
+				out[name] = true
+			}
+		}
+	}
+	n := 0
+	for _, name := range recent {
+		if n == recentToolLimit {
+			break
+		}
+		if name != RequestToolsName && !out[name] {
+			out[name] = true
+			n++
+		}
+	}
+	return out
+}
+
+// recentToolNames lists the tools called in history, most recent first, without duplicates.
+func recentToolNames(history []openrouter.Message) []string {
+	var out []string
+	seen := map[string]bool{}
+	for i := len(history) - 1; i >= 0; i-- {
+		for _, tc := range history[i].ToolCalls {
+			if name := tc.Function.Name; name != "" && !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
+
+// toolEmbeddings returns the embeddings of the tools' names and descriptions, in order. Each is
+// embedded once and cached; the ones not cached yet are embedded in one batch. Cached vectors of
+// another dimension than dim (e.g. local ones from an outage) are embedded again.
+func (s *ToolSelector) toolEmbeddings(ctx context.Context, tds []openrouter.ToolDefinition, dim int) ([][]float32, error) {
+	texts := make([]string, len(tds))
+	out := make([][]float32, len(tds))
+	var missing []string
+	s.mu.Lock()
+	for i, td := range tds {
+		texts[i] = td.Function.Name + ": " + td.Function.Description
+		if v, ok := s.cache[texts[i]]; ok && len(v) == dim {
+			out[i] = v
+		} else {
+			missing = append(missing, texts[i])
+		}
+	}
+	s.mu.Unlock()
+	if len(missing) == 0 {
+		return out, nil
+	}
+	vecs, err := s.Embedder.EmbedBatch(ctx, missing, "document")
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != len(missing) {
+		return nil, fmt.Errorf("got %d tool embeddings for %d tools", len(vecs), len(missing))
+	}
+	s.mu.Lock()
+	if s.cache == nil {
+		s.cache = make(map[string][]float32)
+	}
+	for i, text := range missing {
+		s.cache[text] = vecs[i]
+	}
+	for i, text := range texts {
+		out[i] = s.cache[text]
+	}
+	s.mu.Unlock()
+	return out, nil
+}
+
+// hasTool reports whether defs includes a tool named name.
+func hasTool(defs []openrouter.ToolDefinition, name string) bool {
+	for _, td := range defs {
+		if td.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
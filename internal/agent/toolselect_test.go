@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/tools"
+)
+
+// keywordEmbedder embeds text as keyword hits (tool names only for documents), so "webhook"
+// requests match webhook tools.
+type keywordEmbedder struct {
+	fail    bool
+	batches int
+}
+
+var embedKeywords = []string{"webhook", "calendar", "nextcloud", "user", "secret"}
+
+func (k *keywordEmbedder) Embed(ctx context.Context, text, embedType string) ([]float32, error) {
+	if k.fail {
+		return nil, errors.New("embedding service down")
+	}
+	if embedType == "document" {
+		text, _, _ = strings.Cut(text, ":")
+	}
+	v := make([]float32, len(embedKeywords)+1)
+	v[len(embedKeywords)] = 0.01 // avoid zero vectors
+	for i, kw := range embedKeywords {
+		v[i] = float32(strings.Count(strings.ToLower(text), kw))
+	}
+	return v, nil
+}
+
+func (k *keywordEmbedder) EmbedBatch(ctx context.Context, texts []string, embedType string) ([][]float32, error) {
+	k.batches++
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v, err := k.Embed(ctx, t, embedType)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func toolNames(defs []openrouter.ToolDefinition) map[string]bool {
+	out := map[string]bool{}
+	for _, td := range defs {
+		out[td.Function.Name] = true
+	}
+	return out
+}
+
+func TestToolSelectorSubsets(t *testing.T) {
+	all := tools.BuiltinToolDefs()
+	embedder := &keywordEmbedder{}
+	s := NewToolSelector(embedder, 3)
+
+	got := toolNames(s.Select(context.Background(), "add a webhook route for my GitHub pushes", nil, all))
+	if len(got) != len(CoreTools)+3+1 {
+		t.Errorf("got %d tools, want %d core + 3 relevant + request_tools", len(got), len(CoreTools))
+	}
+	for _, name := range append([]string{"add_webhook_route", "list_webhook_routes", "remove_webhook_route", RequestToolsName}, CoreTools...) {
+		if !got[name] {
+			t.Errorf("missing %s in subset %v", name, got)
+		}
+	}
+	if got["import_conversations"] {
+		t.Error("unrelated tool should be left out")
+	}
+	// Tool descriptions are embedded in one batch, then come from the cache
+	s.Select(context.Background(), "list my calendar", nil, all)
+	if embedder.batches != 1 {
+		t.Errorf("tool descriptions embedded in %d batches, want 1", embedder.batches)
+	}
+
+	if n := len(NewToolSelector(&keywordEmbedder{fail: true}, 3).Select(context.Background(), "webhook", nil, all)); n != len(all) {
+		t.Errorf("embedding failure sent %d tools, want all %d", n, len(all))
+	}
+	if n := len(NewToolSelector(&keywordEmbedder{}, 0).Select(context.Background(), "webhook", nil, all)); n != len(all) {
+		t.Errorf("disabled selector sent %d tools, want all %d", n, len(all))
+	}
+}
+
+func TestToolSelectorPinsRulesAndRecentTools(t *testing.T) {
+	all := tools.BuiltinToolDefs()
+	s := NewToolSelector(&keywordEmbedder{}, 3).Configure(nil, map[string][]string{"Standup": {"manage_deck"}, "backup": nil})
+
+	got := toolNames(s.Select(context.Background(), "prepare my standup notes and take a backup", []string{"export_thread", RequestToolsName}, all))
+	for _, name := range []string{"manage_deck", "export_thread"} {
+		if !got[name] {
+			t.Errorf("missing pinned %s in subset %v", name, got)
+		}
+	}
+	if got["backup_now"] {
+		t.Error("a rule removed by config should not pin its tools")
+	}
+	if len(got) != len(CoreTools)+2+3+1 {
+		t.Errorf("got %d tools, want %d core + 2 pinned + 3 relevant + request_tools", len(got), len(CoreTools))
+	}
+
+	history := []openrouter.Message{{Role: "user", Content: "hi"}, {Role: "assistant"}, {Role: "assistant"}}
+	history[1].ToolCalls = []openrouter.ToolCall{{}, {}}
+	history[1].ToolCalls[0].Function.Name = "manage_feed"
+	history[1].ToolCalls[1].Function.Name = "announce"
+	history[2].ToolCalls = []openrouter.ToolCall{{}}
+	history[2].ToolCalls[0].Function.Name = "manage_feed"
+	if names := recentToolNames(history); strings.Join(names, ",") != "manage_feed,announce" {
+		t.Errorf("recentToolNames = %v, want most recent first without duplicates", names)
+	}
+}
+
+// toolListClient calls request_tools once, then answers; it records the tool count of each call.
+type toolListClient struct {
+	MockClient
+	counts []int
+}
+
+func (c *toolListClient) ChatCompletionWithTools(ctx context.Context, msgs []openrouter.Message, defs []openrouter.ToolDefinition) (string, []openrouter.ToolCall, error) {
+	c.counts = append(c.counts, len(defs))
+	if len(c.counts) > 1 {
+		return "done", nil, nil
+	}
+	var tc openrouter.ToolCall
+	tc.ID = "call_1"
+	tc.Function.Name = RequestToolsName
+	tc.Function.Arguments = `{"reason": "need announce"}`
+	return "", []openrouter.ToolCall{tc}, nil
+}
+
+func TestRequestToolsSwitchesToFullSet(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+	client := &toolListClient{}
+	exec := &MockExecutor{}
+	loop := &Loop{
+		Config:       &config.Config{AdminUserID: "admin", Model: "mock-model"},
+		DB:           db,
+		Client:       client,
+		Context:      &ContextManager{DB: db},
+		Executor:     exec,
+		ToolSelector: NewToolSelector(&keywordEmbedder{}, 3),
+	}
+	_, err := loop.RunOneTurn(context.Background(), gateway.Message{SenderID: "admin", Content: "check my calendar", Channel: "test", ThreadID: "t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := len(tools.BuiltinToolDefs())
+	if len(client.counts) != 2 || client.counts[0] >= all || client.counts[1] != all {
+		t.Errorf("tool counts per call = %v, want a subset then all %d", client.counts, all)
+	}
+	if exec.LastToolCalled != "" {
+		t.Errorf("request_tools must be handled by the loop, executor got %q", exec.LastToolCalled)
+	}
+}
@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/middleware"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/redact"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// journalArgsMax caps the tool arguments kept in the turn journal, in runes.
+const journalArgsMax = 200
+
+// turnJournal records a turn's progress in the turn journal, so a turn cut off by a crash can be
+// reported on startup (RecoverInterruptedTurns). Journal errors are logged and never fail a turn.
+type turnJournal struct {
+	db *store.DB
+	id int64 // 0 when the turn could not be journaled
+}
+
+func (l *Loop) startJournal(ctx context.Context, userID string, msg gateway.Message) *turnJournal {
+	id, err := l.DB.StartTurn(ctx, userID, msg.Channel, msg.ThreadID, msg.Content)
+	if err != nil {
+		log.Printf("[AGENT] Turn journal: %v", err)
+	}
+	return &turnJournal{db: l.DB, id: id}
+}
+
+func (j *turnJournal) state(ctx context.Context, state string) {
+	if j.id != 0 {
+		j.check(j.db.SetTurnState(ctx, j.id, state))
+	}
+}
+
+// toolCalls records the calls the model asked for, before any of them runs.
+func (j *turnJournal) toolCalls(ctx context.Context, calls []openrouter.ToolCall) {
+	if j.id == 0 {
+		return
+	}
+	entries := make([]store.JournalToolCall, 0, len(calls))
+	for _, tc := range calls {
+		args := []rune(redact.String(tc.Function.Arguments))
+		if len(args) > journalArgsMax {
+			args = append(args[:journalArgsMax], '…')
+		}
+		entries = append(entries, store.JournalToolCall{ID: tc.ID, Name: tc.Function.Name, Args: string(args)})
+	}
+	j.check(j.db.JournalToolCalls(ctx, j.id, entries))
+}
+
+func (j *turnJournal) toolDone(ctx context.Context, callID, result string, err error) {
+	if j.id != 0 {
+		j.check(j.db.JournalToolDone(ctx, j.id, callID, middleware.ToolFailed(result, err)))
+	}
+}
+
+// finish drops the turn from the journal. A turn stopped by shutdown has a cancelled ctx, so its
+// entry stays and is reported after the restart; one interrupted by an urgent message is done.
+func (j *turnJournal) finish(ctx context.Context) {
+	if j.id != 0 && (ctx.Err() == nil || gateway.Interrupted(ctx)) {
+		j.check(j.db.FinishTurn(context.WithoutCancel(ctx), j.id))
+	}
+}
+
+func (j *turnJournal) check(err error) {
+	if err != nil && !strings.Contains(err.Error(), "context canceled") {
+		log.Printf("[AGENT] Turn journal %d: %v", j.id, err)
+	}
+}
+
+// RecoverInterruptedTurns reports the turns the previous process did not finish: each thread gets
+// a summary of what was done and what may not have been, stored in its history so the agent
+// knows too. Returns how many turns were reported.
+func (l *Loop) RecoverInterruptedTurns(ctx context.Context) (int, error) {
+	turns, err := l.DB.JournaledTurns(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range turns {
+		summary := recoverySummary(t)
+		if t.Channel == "" || t.ThreadID == "" {
+			log.Printf("[AGENT] Interrupted turn %d has no thread to report to: %s", t.ID, summary)
+		} else {
+			if _, err := l.DB.InsertMessage(ctx, "assistant", summary, "", "hattiebot", t.Channel, t.ThreadID, "", "", ""); err != nil {
+				return 0, err
+			}
+			sent := false
+			if l.Gateway != nil {
+				if _, ok := l.Gateway.ChannelByName(t.Channel); ok {
+					l.Gateway.RouteReply(gateway.Message{Channel: t.Channel, ThreadID: t.ThreadID, SenderID: t.UserID}, summary)
+					sent = true
+				}
+			}
+			if !sent {
+				log.Printf("[AGENT] Interrupted turn %d: channel %s is not available; summary kept in the thread", t.ID, t.Channel)
+			}
+		}
+		if err := l.DB.FinishTurn(ctx, t.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(turns), nil
+}
+
+// recoverySummary tells the user what an interrupted turn got done. Tools run one at a time, so
+// the first unfinished call is the one that was running: it may or may not have taken effect.
+func recoverySummary(t store.JournaledTurn) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "I was restarted while working on your message from %s", t.StartedAt.UTC().Format("2006-01-02 15:04 UTC"))
+	if request := []rune(strings.TrimSpace(t.Content)); len(request) > 0 {
+		if len(request) > 120 {
+			request = append(request[:120], '…')
+		}
+		fmt.Fprintf(&b, " (%q)", string(request))
+	}
+	b.WriteString(".")
+
+	var done, failed []string
+	var running string
+	var notStarted []string
+	for _, tc := range t.ToolCalls {
+		call := tc.Name
+		if tc.Args != "" {
+			call += " " + tc.Args
+		}
+		switch {
+		case tc.Done && tc.Failed:
+			failed = append(failed, call)
+		case tc.Done:
+			done = append(done, call)
+		case running == "":
+			running = call
+		default:
+			notStarted = append(notStarted, call)
+		}
+	}
+	list := func(title string, calls []string) {
+		if len(calls) == 0 {
+			return
+		}
+		b.WriteString("\n\n" + title)
+		for _, c := range calls {
+			b.WriteString("\n- " + c)
+		}
+	}
+	list("Completed:", done)
+	list("Failed:", failed)
+	if running != "" {
+		list("Running when I stopped, so it may or may not have taken effect:", []string{running})
+	}
+	list("Not started:", notStarted)
+	switch {
+	case t.State == store.TurnReplying:
+		b.WriteString("\n\nI had finished the work and was sending my answer; ask me for it again if it did not arrive.")
+	case len(t.ToolCalls) == 0:
+		b.WriteString("\n\nNothing had been done yet; send the message again to retry.")
+	default:
+		b.WriteString("\n\nCheck the items above before asking me to continue, so nothing runs twice.")
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+)
+
+// twoToolClient asks for two tool calls, then answers.
+type twoToolClient struct{ MockClient }
+
+func (c *twoToolClient) ChatCompletionWithTools(ctx context.Context, msgs []openrouter.Message, defs []openrouter.ToolDefinition) (string, []openrouter.ToolCall, error) {
+	if msgs[len(msgs)-1].Role == "tool" {
+		return "all done", nil, nil
+	}
+	var calls []openrouter.ToolCall
+	for _, name := range []string{"backup_now", "run_terminal_cmd"} {
+		call := openrouter.ToolCall{ID: "call_" + name, Type: "function"}
+		call.Function.Name = name
+		call.Function.Arguments = `{"command": "rm -rf /srv/old"}`
+		calls = append(calls, call)
+	}
+	return "", calls, nil
+}
+
+// crashingExecutor stops the process (cancels the turn) while running the named tool.
+type crashingExecutor struct {
+	MockExecutor
+	crashOn string
+	crash   context.CancelFunc
+}
+
+func (e *crashingExecutor) Execute(ctx context.Context, name, args string) (string, error) {
+	if name == e.crashOn {
+		e.crash()
+		return "", ctx.Err()
+	}
+	return e.MockExecutor.Execute(ctx, name, args)
+}
+
+type recordingChannel struct{ sent []gateway.Message }
+
+func (c *recordingChannel) Name() string                                               { return "talk" }
+func (c *recordingChannel) Start(ctx context.Context, in chan<- gateway.Message) error { return nil }
+func (c *recordingChannel) Send(msg gateway.Message) error                             { c.sent = append(c.sent, msg); return nil }
+func (c *recordingChannel) SendProactive(userID, content string) error                 { return nil }
+
+func TestInterruptedTurnIsReportedOnRecovery(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+	exec := &crashingExecutor{}
+	loop := &Loop{
+		Config:   &config.Config{Model: "mock-model", ConfigDir: t.TempDir()},
+		DB:       db,
+		Client:   &twoToolClient{},
+		Context:  &ContextManager{DB: db},
+		Executor: exec,
+	}
+	msg := gateway.Message{SenderID: "alice", Channel: "talk", ThreadID: "room", Content: "back up, then clear /srv/old"}
+
+	if _, err := loop.RunOneTurn(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if turns, _ := db.JournaledTurns(context.Background()); len(turns) != 0 {
+		t.Fatalf("a finished turn stayed in the journal: %+v", turns)
+	}
+
+	turnCtx, crash := context.WithCancel(context.Background())
+	exec.crashOn, exec.crash = "run_terminal_cmd", crash
+	loop.RunOneTurn(turnCtx, msg)
+
+	// The next process starts
+	ch := &recordingChannel{}
+	loop.Gateway = gateway.New(nil)
+	loop.Gateway.Register(ch)
+	ctx := context.Background()
+	n, err := loop.RecoverInterruptedTurns(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("recovered %d, %v", n, err)
+	}
+	if len(ch.sent) != 1 || ch.sent[0].ThreadID != "room" {
+		t.Fatalf("sent = %+v", ch.sent)
+	}
+	summary := ch.sent[0].Content
+	for _, want := range []string{"back up, then clear /srv/old", "Completed:\n- backup_now", "may or may not have taken effect:\n- run_terminal_cmd"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary lacks %q:\n%s", want, summary)
+		}
+	}
+	history, _ := db.ThreadMessages(ctx, "room")
+	if last := history[len(history)-1]; last.Role != "assistant" || last.Content != summary {
+		t.Errorf("summary not stored in the thread: %+v", last)
+	}
+	if n, _ := loop.RecoverInterruptedTurns(ctx); n != 0 {
+		t.Errorf("turn reported twice")
+	}
+}
@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// attributeUsage attaches a usage recorder to ctx so every LLM call in this turn (including
+// tool-spawned sub-minds) is stored against the user, thread, active job, and triggering plan.
+// Returns the active job (nil if none) for budget checks.
+func (l *Loop) attributeUsage(ctx context.Context, userID string, msg gateway.Message) (context.Context, *store.Job) {
+	job, err := l.DB.GetActiveJob(ctx, userID)
+	if err != nil {
+		log.Printf("[AGENT] Failed to load active job for usage attribution: %v", err)
+	}
+	var jobID int64
+	if job != nil {
+		jobID = job.ID
+	}
+	return core.WithUsageRecorder(ctx, l.DB.UsageRecorder(userID, msg.ThreadID, jobID, msg.PlanID)), job
+}
+
+// jobBudgetExceeded reports whether the job has a budget and has spent all of it.
+func (l *Loop) jobBudgetExceeded(ctx context.Context, job *store.Job) (bool, string) {
+	if job == nil || job.BudgetUSD == nil {
+		return false, ""
+	}
+	spent, err := l.DB.JobCost(ctx, job.ID)
+	if err != nil {
+		log.Printf("[AGENT] Failed to read cost for job %d: %v", job.ID, err)
+		return false, ""
+	}
+	if spent < *job.BudgetUSD {
+		return false, ""
+	}
+	return true, fmt.Sprintf("Job #%d \"%s\" has used its budget ($%.2f of $%.2f). Raise it with manage_job set_budget or close the job to continue.", job.ID, job.Title, spent, *job.BudgetUSD)
+}
@@ -0,0 +1,342 @@
+// Package backup snapshots hattiebot.db and the config directory into a .tar.gz archive, stores
+// it on a Target (a file store: a local directory, Nextcloud via WebDAV, or S3) and keeps the newest few.
+// A Manager runs backups on an interval and on request (backup_now); Restore, used by the restore
+// command, unpacks an archive back into place while HattieBot is stopped.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/filestore"
+)
+
+const (
+	archivePrefix = "hattiebot-"
+	archiveSuffix = ".tar.gz"
+	// dbEntry is the database inside an archive; config files are under configPrefix.
+	dbEntry      = "hattiebot.db"
+	configPrefix = "config/"
+)
+
+// Result describes one backup.
+type Result struct {
+	Name      string    `json:"name"`
+	Target    string    `json:"target"`
+	SizeBytes int64     `json:"size_bytes"`
+	Files     int       `json:"files"` // config files included besides the database
+	Rotated   []string  `json:"rotated,omitempty"`
+	Kept      []string  `json:"kept,omitempty"`
+	At        time.Time `json:"at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Manager backs up the database and config directory to Target, keeping Keep archives.
+type Manager struct {
+	Target    Target
+	DB        *sql.DB
+	DBPath    string // the live database file; its -wal and -shm files are skipped too
+	ConfigDir string
+	Keep      int              // archives kept after each backup (0 = keep all)
+	Notify    func(msg string) // told when a scheduled backup fails
+
+	now func() time.Time
+	mu  sync.Mutex // one backup at a time
+	// last is guarded by lastMu so status reads do not wait for a running backup.
+	lastMu sync.Mutex
+	last   *Result
+}
+
+// Run makes a backup now, uploads it and deletes archives beyond Keep.
+func (m *Manager) Run(ctx context.Context) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now
+	if m.now != nil {
+		now = m.now
+	}
+	at := now().UTC()
+	res := Result{Name: archivePrefix + at.Format("20060102T150405Z") + archiveSuffix, Target: m.Target.String(), At: at}
+	err := m.run(ctx, &res)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	m.lastMu.Lock()
+	m.last = &res
+	m.lastMu.Unlock()
+	return res, err
+}
+
+func (m *Manager) run(ctx context.Context, res *Result) error {
+	tmp, err := os.CreateTemp("", "hattiebot-backup-*"+archiveSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	files, err := m.writeArchive(ctx, tmp)
+	if err != nil {
+		return err
+	}
+	res.Files = files
+	if res.SizeBytes, err = tmp.Seek(0, io.SeekCurrent); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := m.Target.Put(ctx, res.Name, tmp, res.SizeBytes); err != nil {
+		return fmt.Errorf("uploading %s: %w", res.Name, err)
+	}
+	names, err := m.Target.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing backups for rotation: %w", err)
+	}
+	if m.Keep > 0 && len(names) > m.Keep {
+		for _, name := range names[:len(names)-m.Keep] {
+			if err := m.Target.Delete(ctx, name); err != nil {
+				return fmt.Errorf("rotating %s: %w", name, err)
+			}
+			res.Rotated = append(res.Rotated, name)
+		}
+		names = names[len(names)-m.Keep:]
+	}
+	res.Kept = names
+	return nil
+}
+
+// writeArchive writes a consistent snapshot of the database (VACUUM INTO, which is safe while
+// the bot keeps writing) and the config files to w, returning the number of config files.
+func (m *Manager) writeArchive(ctx context.Context, w io.Writer) (int, error) {
+	dir, err := os.MkdirTemp("", "hattiebot-snapshot-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, dbEntry)
+	if _, err := m.DB.ExecContext(ctx, "VACUUM INTO ?", snapshot); err != nil {
+		return 0, fmt.Errorf("snapshotting database: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := addFile(tw, snapshot, dbEntry); err != nil {
+		return 0, err
+	}
+	files := 0
+	if m.ConfigDir != "" {
+		skip := m.skipped()
+		err = filepath.WalkDir(m.ConfigDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if skip[p] {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(m.ConfigDir, p)
+			if err != nil {
+				return err
+			}
+			files++
+			return addFile(tw, p, configPrefix+filepath.ToSlash(rel))
+		})
+		if err != nil {
+			return 0, fmt.Errorf("archiving config dir: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	return files, gz.Close()
+}
+
+// skipped lists the paths under ConfigDir that are not archived: the live database files (the
+// snapshot replaces them), databases left by Restore, and a local backup directory inside the
+// config dir.
+func (m *Manager) skipped() map[string]bool {
+	skip := map[string]bool{}
+	if m.DBPath != "" {
+		db := filepath.Clean(m.DBPath)
+		for _, suffix := range []string{"", "-wal", "-shm", "-journal", ".restoring", ".before-restore", ".before-restore-wal", ".before-restore-shm"} {
+			skip[db+suffix] = true
+		}
+	}
+	if st, ok := m.Target.(*StoreTarget); ok {
+		if local, ok := st.Store.(*filestore.Local); ok {
+			skip[filepath.Clean(local.Dir)] = true
+		}
+	}
+	return skip
+}
+
+func addFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Last returns the most recent backup attempt, or nil.
+func (m *Manager) Last() *Result {
+	m.lastMu.Lock()
+	defer m.lastMu.Unlock()
+	return m.last
+}
+
+// Start backs up every interval in the background. The first backup runs one interval after start.
+func (m *Manager) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			res, err := m.Run(ctx)
+			if err != nil {
+				log.Printf("[Backup] Failed: %v", err)
+				if m.Notify != nil {
+					m.Notify(fmt.Sprintf("[Backup] Scheduled backup to %s failed: %v", res.Target, err))
+				}
+				continue
+			}
+			log.Printf("[Backup] Stored %s (%d bytes) at %s", res.Name, res.SizeBytes, res.Target)
+		}
+	}()
+}
+
+// RestoreResult describes what Restore replaced.
+type RestoreResult struct {
+	Name        string   `json:"name"`
+	Database    string   `json:"database"`
+	PreviousDB  string   `json:"previous_db,omitempty"` // where the replaced database was moved
+	ConfigFiles []string `json:"config_files,omitempty"`
+}
+
+// Restore downloads archive name ("latest" = the newest) from t and puts its database at dbPath,
+// moving the current one aside to dbPath.before-restore. Unless dbOnly, config files in the
+// archive are written to configDir, overwriting files of the same name. HattieBot must be stopped.
+func Restore(ctx context.Context, t Target, name, dbPath, configDir string, dbOnly bool) (RestoreResult, error) {
+	if name == "" || name == "latest" {
+		names, err := t.List(ctx)
+		if err != nil {
+			return RestoreResult{}, err
+		}
+		if len(names) == 0 {
+			return RestoreResult{}, fmt.Errorf("no backups at %s", t)
+		}
+		name = names[len(names)-1]
+	}
+	res := RestoreResult{Name: name, Database: dbPath}
+	rc, err := t.Get(ctx, name)
+	if err != nil {
+		return res, err
+	}
+	defer rc.Close()
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", name, err)
+	}
+	tr := tar.NewReader(gz)
+
+	newDB := dbPath + ".restoring"
+	defer os.Remove(newDB)
+	foundDB := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		switch {
+		case hdr.Name == dbEntry:
+			if err := writeFile(newDB, tr, 0600); err != nil {
+				return res, err
+			}
+			foundDB = true
+		case strings.HasPrefix(hdr.Name, configPrefix) && !dbOnly:
+			rel := filepath.FromSlash(strings.TrimPrefix(hdr.Name, configPrefix))
+			dest := filepath.Join(configDir, rel)
+			if !filepath.IsLocal(rel) {
+				return res, fmt.Errorf("%s: refusing to write %q outside the config dir", name, hdr.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+				return res, err
+			}
+			if err := writeFile(dest, tr, fs.FileMode(hdr.Mode).Perm()); err != nil {
+				return res, err
+			}
+			res.ConfigFiles = append(res.ConfigFiles, rel)
+		}
+	}
+	if !foundDB {
+		return res, fmt.Errorf("%s has no %s", name, dbEntry)
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		res.PreviousDB = dbPath + ".before-restore"
+		if err := os.Rename(dbPath, res.PreviousDB); err != nil {
+			return res, err
+		}
+	}
+	// A leftover write-ahead log belongs to the replaced database and must not be applied to this one.
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if res.PreviousDB == "" || os.Rename(dbPath+suffix, res.PreviousDB+suffix) != nil {
+			os.Remove(dbPath + suffix)
+		}
+	}
+	return res, os.Rename(newDB, dbPath)
+}
+
+func writeFile(path string, r io.Reader, perm fs.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,168 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/filestore"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func newTestManager(t *testing.T, target Target) (*Manager, *store.DB) {
+	t.Helper()
+	ctx := context.Background()
+	configDir := t.TempDir()
+	db, err := store.Open(ctx, filepath.Join(configDir, "hattiebot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.InsertMessage(ctx, "user", "remember me", "", "alice", "api", "t1", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(configDir, "SOUL.md"), []byte("be kind"), 0600)
+	os.MkdirAll(filepath.Join(configDir, "tools", "hello"), 0700)
+	os.WriteFile(filepath.Join(configDir, "tools", "hello", "main.go"), []byte("package main"), 0600)
+
+	at := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	m := &Manager{Target: target, DB: db.DB, DBPath: filepath.Join(configDir, "hattiebot.db"), ConfigDir: configDir, Keep: 2}
+	m.now = func() time.Time {
+		at = at.Add(time.Hour)
+		return at
+	}
+	return m, db
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, nil)
+	// A backup directory inside the config dir is not backed up into itself
+	target := &StoreTarget{Store: &filestore.Local{Dir: filepath.Join(m.ConfigDir, "backups")}}
+	m.Target = target
+
+	var last Result
+	for i := 0; i < 3; i++ {
+		res, err := m.Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = res
+	}
+	if last.Files != 2 || last.SizeBytes == 0 || len(last.Rotated) != 1 || len(last.Kept) != 2 || last.Kept[1] != last.Name {
+		t.Errorf("third backup = %+v", last)
+	}
+	if names, _ := target.List(ctx); !reflect.DeepEqual(names, last.Kept) {
+		t.Errorf("stored = %v, want %v", names, last.Kept)
+	}
+	if got := m.Last(); got == nil || got.Name != last.Name {
+		t.Errorf("Last() = %+v", got)
+	}
+
+	dest := t.TempDir()
+	dbPath := filepath.Join(dest, "hattiebot.db")
+	os.WriteFile(dbPath, []byte("broken"), 0600)
+	os.WriteFile(dbPath+"-wal", []byte("stale"), 0600)
+	res, err := Restore(ctx, target, "latest", dbPath, dest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(res.ConfigFiles)
+	if res.Name != last.Name || res.PreviousDB != dbPath+".before-restore" || !reflect.DeepEqual(res.ConfigFiles, []string{"SOUL.md", filepath.Join("tools", "hello", "main.go")}) {
+		t.Errorf("restore = %+v", res)
+	}
+	if _, err := os.Stat(dbPath + "-wal"); !os.IsNotExist(err) {
+		t.Errorf("stale WAL left next to the restored database: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "tools", "hello", "main.go")); string(data) != "package main" {
+		t.Errorf("restored tool source = %q", data)
+	}
+	db, err := store.Open(ctx, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	msgs, err := db.RecentMessages(ctx, 10, "t1")
+	if err != nil || len(msgs) != 1 || msgs[0].Content != "remember me" {
+		t.Errorf("restored messages = %+v, %v", msgs, err)
+	}
+}
+
+// fakeS3 stores objects in memory and checks that requests are signed.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AK/") || r.Header.Get("x-amz-date") == "" {
+		http.Error(w, "unsigned", http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/bucket":
+		var b strings.Builder
+		b.WriteString("<ListBucketResult>")
+		for k := range f.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				fmt.Fprintf(&b, "<Contents><Key>%s</Key></Contents>", k)
+			}
+		}
+		b.WriteString("<IsTruncated>false</IsTruncated></ListBucketResult>")
+		io.WriteString(w, b.String())
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Target(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{objects: map[string][]byte{"hattiebot/notes.txt": []byte("not a backup")}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	target := &StoreTarget{Store: &filestore.S3{Bucket: "bucket", Prefix: "hattiebot", Endpoint: srv.URL, AccessKey: "AK", SecretKey: "SK"}}
+	m, _ := newTestManager(t, target)
+	for i := 0; i < 3; i++ {
+		if _, err := m.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := target.List(ctx)
+	if err != nil || len(names) != 2 || len(fake.objects) != 3 {
+		t.Fatalf("listed %v, %v; stored %d objects", names, err, len(fake.objects))
+	}
+	rc, err := target.Get(ctx, names[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	if !bytes.Equal(data, fake.objects["hattiebot/"+names[1]]) {
+		t.Error("downloaded archive differs from the stored one")
+	}
+}
@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/filestore"
+)
+
+// Target stores backup archives by name.
+type Target interface {
+	// String describes the target for status output (never includes credentials).
+	String() string
+	Put(ctx context.Context, name string, r io.Reader, size int64) error
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the names of the stored archives, oldest first.
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// NewTarget builds the target configured in cfg.BackupTarget, or returns nil when backups are off:
+//
+//	local:/backups (or just /backups)   a directory
+//	nextcloud:/Backups/hattiebot        a folder in the bot user's Nextcloud files (WebDAV)
+//	s3://bucket/prefix                  an S3 (or S3-compatible, see BackupS3Endpoint) bucket
+//	store:name/folder                   a folder in a file store from config.json (file_stores)
+func NewTarget(cfg *config.Config) (Target, error) {
+	spec := strings.TrimSpace(cfg.BackupTarget)
+	switch {
+	case spec == "":
+		return nil, nil
+	case strings.HasPrefix(spec, "local:"):
+		return &StoreTarget{Store: &filestore.Local{Dir: strings.TrimPrefix(spec, "local:")}}, nil
+	case filepath.IsAbs(spec):
+		return &StoreTarget{Store: &filestore.Local{Dir: spec}}, nil
+	case strings.HasPrefix(spec, "nextcloud:"):
+		if cfg.NextcloudURL == "" || cfg.NextcloudBotUser == "" || cfg.NextcloudBotAppPassword == "" {
+			return nil, fmt.Errorf("backup target %q needs NEXTCLOUD_URL, NEXTCLOUD_BOT_USER and NEXTCLOUD_BOT_APP_PASSWORD", spec)
+		}
+		return &StoreTarget{Store: sub(filestore.Nextcloud(cfg), strings.TrimPrefix(spec, "nextcloud:"))}, nil
+	case strings.HasPrefix(spec, "s3://"):
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(spec, "s3://"), "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("backup target %q has no bucket", spec)
+		}
+		if cfg.BackupS3AccessKey == "" || cfg.BackupS3SecretKey == "" {
+			return nil, fmt.Errorf("backup target %q needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", spec)
+		}
+		return &StoreTarget{Store: &filestore.S3{
+			Bucket:    bucket,
+			Prefix:    strings.Trim(prefix, "/"),
+			Region:    cfg.BackupS3Region,
+			Endpoint:  cfg.BackupS3Endpoint,
+			AccessKey: cfg.BackupS3AccessKey,
+			SecretKey: cfg.BackupS3SecretKey,
+		}}, nil
+	case strings.HasPrefix(spec, "store:"):
+		name, dir, _ := strings.Cut(strings.TrimPrefix(spec, "store:"), "/")
+		if name == "" {
+			return nil, fmt.Errorf("backup target %q names no file store", spec)
+		}
+		st, err := filestore.Open(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("backup target %q: %w", spec, err)
+		}
+		return &StoreTarget{Store: sub(st, dir)}, nil
+	}
+	return nil, fmt.Errorf("unknown backup target %q (use local:/dir, nextcloud:/folder, s3://bucket/prefix or store:name/folder)", spec)
+}
+
+// sub narrows st to dir, unless dir is the root.
+func sub(st filestore.Store, dir string) filestore.Store {
+	if dir = strings.Trim(dir, "/"); dir != "" {
+		return filestore.Sub(st, dir)
+	}
+	return st
+}
+
+// isArchive reports whether name is an archive written by Run; other files at the target are left alone.
+func isArchive(name string) bool {
+	return strings.HasPrefix(name, archivePrefix) && strings.HasSuffix(name, archiveSuffix)
+}
+
+// StoreTarget keeps archives in the top folder of a file store.
+type StoreTarget struct {
+	Store filestore.Store
+}
+
+func (t *StoreTarget) String() string { return t.Store.String() }
+
+func (t *StoreTarget) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	return t.Store.Put(ctx, name, r, size)
+}
+
+func (t *StoreTarget) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return t.Store.Get(ctx, path.Base(name))
+}
+
+func (t *StoreTarget) List(ctx context.Context) ([]string, error) {
+	objs, err := t.Store.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, o := range objs {
+		if !o.Dir && isArchive(o.Key) {
+			names = append(names, o.Key)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (t *StoreTarget) Delete(ctx context.Context, name string) error {
+	err := t.Store.Delete(ctx, path.Base(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil // already rotated out
+	}
+	return err
+}
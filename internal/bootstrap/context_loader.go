@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/hattiebot/hattiebot/internal/logging"
 	"github.com/hattiebot/hattiebot/internal/store"
 )
 
@@ -72,16 +73,16 @@ func LoadContextDocs(ctx context.Context, db *store.DB, dir string) error {
 			// Update if content changed? Always update for now to simple.
 			if existing.Content != content {
 				if err := db.UpdateContextDoc(ctx, title, content, description); err != nil {
-					fmt.Printf("warning: failed to update context doc %s: %v\n", title, err)
+					logging.For("context").Warn("failed to update context doc", "title", title, "error", err)
 				} else {
-					fmt.Printf("[Context] Updated '%s' from file.\n", title)
+					logging.For("context").Info("updated context doc from file", "title", title)
 				}
 			}
 		} else {
 			if _, err := db.CreateContextDoc(ctx, title, content, description); err != nil {
-				fmt.Printf("warning: failed to create context doc %s: %v\n", title, err)
+				logging.For("context").Warn("failed to create context doc", "title", title, "error", err)
 			} else {
-				fmt.Printf("[Context] Created '%s' from file.\n", title)
+				logging.For("context").Info("created context doc from file", "title", title)
 			}
 			// Note: Created is inactive by default.
 		}
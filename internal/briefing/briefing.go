@@ -0,0 +1,438 @@
+// Package briefing builds and delivers the proactive daily briefing: a scheduled plan (action
+// type "briefing") gathers the user's calendar for the day (Nextcloud), plans due in the next 24
+// hours, jobs that are blocked or waiting for the user, webhook events no briefing has reported
+// yet (admins only) and the weather from a registered tool. The LLM writes the digest, which the
+// Router delivers like any proactive message; without an LLM (or while the bot self-throttles) a
+// plain rendering of the same data is sent instead.
+package briefing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/scheduler"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools/nextcloud"
+)
+
+// ActionType is the scheduled plan action that delivers a briefing; its payload is Settings.
+const ActionType = "briefing"
+
+// PlanDescription names the briefing plan in manage_schedule listings.
+const PlanDescription = "Daily briefing"
+
+// Sections of a briefing.
+const (
+	SectionCalendar = "calendar"
+	SectionPlans    = "plans"
+	SectionJobs     = "jobs"
+	SectionWebhooks = "webhooks"
+	SectionWeather  = "weather"
+)
+
+// AllSections lists every section, in the order the plain rendering uses.
+var AllSections = []string{SectionCalendar, SectionPlans, SectionJobs, SectionWebhooks, SectionWeather}
+
+// maxWebhookEvents caps the unread webhook events one briefing reports; the rest wait for the next.
+const maxWebhookEvents = 50
+
+// maxWeatherRunes caps the weather tool output handed to the LLM.
+const maxWeatherRunes = 1000
+
+// Settings configure a user's briefing.
+type Settings struct {
+	Sections     []string        `json:"sections"`               // empty means all
+	WeatherTool  string          `json:"weather_tool,omitempty"` // registered tool; no weather without it
+	WeatherArgs  json.RawMessage `json:"weather_args,omitempty"` // JSON args for the weather tool, e.g. {"city": "Berlin"}
+	Instructions string          `json:"instructions,omitempty"` // extra guidance for writing the digest
+}
+
+// ParseSettings decodes a briefing plan's payload; an empty payload is the default briefing.
+func ParseSettings(payload string) (Settings, error) {
+	var s Settings
+	if strings.TrimSpace(payload) == "" {
+		return s, nil
+	}
+	if err := json.Unmarshal([]byte(payload), &s); err != nil {
+		return s, fmt.Errorf("invalid briefing settings: %w", err)
+	}
+	return s, nil
+}
+
+// Validate checks the section names and weather arguments.
+func (s Settings) Validate() error {
+	for _, name := range s.Sections {
+		if !contains(AllSections, name) {
+			return fmt.Errorf("unknown briefing section %q (use %s)", name, strings.Join(AllSections, ", "))
+		}
+	}
+	if len(s.WeatherArgs) > 0 && !json.Valid(s.WeatherArgs) {
+		return fmt.Errorf("weather_args is not valid JSON")
+	}
+	return nil
+}
+
+// Has reports whether the briefing includes section.
+func (s Settings) Has(section string) bool {
+	if section == SectionWeather && s.WeatherTool == "" {
+		return false
+	}
+	return len(s.Sections) == 0 || contains(s.Sections, section)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Sender delivers a proactive message (implemented by gateway.Router).
+type Sender interface {
+	RouteMessage(ctx context.Context, userID, content, urgency string) error
+}
+
+// Service gathers, composes and delivers briefings.
+type Service struct {
+	DB     *store.DB
+	Config *config.Config
+	Client core.LLMClient    // writes the digest; nil sends the plain rendering
+	Sender Sender            // delivers it
+	Tools  core.ToolExecutor // runs the weather tool (execute_registered_tool)
+	// Throttle skips the LLM while the error budget is exhausted.
+	Throttle interface{ Throttled() bool }
+	// Calendar lists the user's events; nil uses Nextcloud CalDAV when it is configured.
+	Calendar func(userID string, from, to time.Time) ([]nextcloud.BusyPeriod, error)
+}
+
+// DuePlan is a scheduled plan that runs within the next day.
+type DuePlan struct {
+	ID          int64     `json:"id"`
+	Description string    `json:"description"`
+	At          time.Time `json:"at"`
+}
+
+// AttentionJob is a job that is blocked or waiting for the user.
+type AttentionJob struct {
+	ID     int64  `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Digest is the data one briefing reports.
+type Digest struct {
+	UserID   string                 `json:"-"`
+	Date     string                 `json:"date"` // e.g. "Monday, 2 March 2026"
+	Calendar []nextcloud.BusyPeriod `json:"calendar,omitempty"`
+	Plans    []DuePlan              `json:"due_plans,omitempty"`
+	Jobs     []AttentionJob         `json:"jobs_needing_attention,omitempty"`
+	Webhooks []store.WebhookEvent   `json:"unread_webhook_events,omitempty"`
+	Weather  string                 `json:"weather,omitempty"`
+	Errors   map[string]string      `json:"unavailable,omitempty"` // section -> why it is missing
+
+	loc *time.Location
+}
+
+// Summary is a one-line count of the digest, recorded as the plan run's summary.
+func (d *Digest) Summary() string {
+	s := fmt.Sprintf("%d events, %d due plans, %d jobs needing attention, %d webhook events",
+		len(d.Calendar), len(d.Plans), len(d.Jobs), len(d.Webhooks))
+	if len(d.Errors) > 0 {
+		var missing []string
+		for section := range d.Errors {
+			missing = append(missing, section)
+		}
+		sort.Strings(missing)
+		s += "; unavailable: " + strings.Join(missing, ", ")
+	}
+	return s
+}
+
+// Gather collects the digest for userID on the day of now in loc.
+func (s *Service) Gather(ctx context.Context, userID string, settings Settings, now time.Time, loc *time.Location) *Digest {
+	now = now.In(loc)
+	d := &Digest{UserID: userID, Date: now.Format("Monday, 2 January 2006"), Errors: map[string]string{}, loc: loc}
+	fail := func(section string, err error) {
+		d.Errors[section] = err.Error()
+		log.Printf("[BRIEFING] %s for %s: %v", section, userID, err)
+	}
+
+	if settings.Has(SectionCalendar) {
+		dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		calendar := s.Calendar
+		if calendar == nil && s.Config != nil && s.Config.NextcloudURL != "" {
+			cfg := s.Config
+			calendar = func(userID string, from, to time.Time) ([]nextcloud.BusyPeriod, error) {
+				return nextcloud.UserBusyPeriods(cfg, userID, from, to)
+			}
+		}
+		if calendar != nil {
+			events, err := calendar(userID, dayStart, dayStart.AddDate(0, 0, 1))
+			if err != nil {
+				fail(SectionCalendar, err)
+			}
+			d.Calendar = events
+		}
+	}
+
+	if settings.Has(SectionPlans) {
+		plans, err := s.DB.ListPlans(ctx, userID, "active")
+		if err != nil {
+			fail(SectionPlans, err)
+		}
+		for _, p := range plans {
+			if p.ActionType == ActionType || p.NextRunAt == nil || p.NextRunAt.After(now.Add(24*time.Hour)) {
+				continue
+			}
+			d.Plans = append(d.Plans, DuePlan{ID: p.ID, Description: p.Description, At: p.NextRunAt.In(loc)})
+		}
+	}
+
+	if settings.Has(SectionJobs) {
+		for _, status := range []string{"blocked", store.AwaitingInput} {
+			jobs, err := s.DB.ListJobs(ctx, userID, status)
+			if err != nil {
+				fail(SectionJobs, err)
+				continue
+			}
+			for _, j := range jobs {
+				d.Jobs = append(d.Jobs, AttentionJob{ID: j.ID, Title: j.Title, Status: j.Status, Reason: j.BlockedReason})
+			}
+		}
+	}
+
+	// Webhooks belong to the deployment, not to one user: only admins hear about them
+	if settings.Has(SectionWebhooks) && s.isAdmin(ctx, userID) {
+		events, err := s.DB.UnreadWebhookEvents(ctx, maxWebhookEvents)
+		if err != nil {
+			fail(SectionWebhooks, err)
+		}
+		d.Webhooks = events
+	}
+
+	if settings.Has(SectionWeather) {
+		if weather, err := s.weather(ctx, settings); err != nil {
+			fail(SectionWeather, err)
+		} else {
+			d.Weather = weather
+		}
+	}
+	return d
+}
+
+func (s *Service) isAdmin(ctx context.Context, userID string) bool {
+	if s.Config != nil && s.Config.AdminUserID != "" && userID == s.Config.AdminUserID {
+		return true
+	}
+	u, err := s.DB.GetUser(ctx, userID)
+	return err == nil && u != nil && u.Role == "admin"
+}
+
+// weather runs the configured registered tool and returns its output.
+func (s *Service) weather(ctx context.Context, settings Settings) (string, error) {
+	if s.Tools == nil {
+		return "", fmt.Errorf("no tool executor")
+	}
+	toolArgs := settings.WeatherArgs
+	if len(toolArgs) == 0 {
+		toolArgs = json.RawMessage("{}")
+	}
+	args, _ := json.Marshal(map[string]interface{}{"name": settings.WeatherTool, "args": toolArgs})
+	out, err := s.Tools.Execute(ctx, "execute_registered_tool", string(args))
+	if err != nil {
+		return "", err
+	}
+	var failed struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal([]byte(out), &failed) == nil && failed.Error != "" {
+		return "", fmt.Errorf("%s", failed.Error)
+	}
+	if r := []rune(strings.TrimSpace(out)); len(r) > maxWeatherRunes {
+		out = string(r[:maxWeatherRunes]) + "…"
+	}
+	return strings.TrimSpace(out), nil
+}
+
+const composePrompt = `You write the user's morning briefing for a chat message. Use only the JSON data given; never invent events or numbers.
+Start with a one-line greeting that mentions the weather if present. Then today's calendar, then what needs the user's attention (blocked jobs, jobs waiting for their answer, failed webhook events), then scheduled tasks due in the next 24 hours.
+Skip sections with no data and do not mention that they are empty. Name data that was unavailable in one short closing line. Keep it under 200 words, with short bullet lists and times in 24-hour format.`
+
+// Compose writes the digest as a chat message, with the LLM when available.
+func (s *Service) Compose(ctx context.Context, d *Digest, settings Settings) string {
+	if s.Client == nil || (s.Throttle != nil && s.Throttle.Throttled()) {
+		return d.Text()
+	}
+	data, _ := json.MarshalIndent(d, "", "  ")
+	system := composePrompt
+	if settings.Instructions != "" {
+		system += "\nThe user asked: " + settings.Instructions
+	}
+	text, err := s.Client.ChatCompletion(ctx, []core.Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: "Briefing data (times are in the user's time zone):\n" + string(data)},
+	})
+	if err != nil || strings.TrimSpace(text) == "" {
+		log.Printf("[BRIEFING] composing for %s failed (%v); sending the plain digest", d.UserID, err)
+		return d.Text()
+	}
+	return strings.TrimSpace(text)
+}
+
+// Text renders the digest without the LLM.
+func (d *Digest) Text() string {
+	loc := d.loc
+	if loc == nil {
+		loc = time.Local
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Good morning! Your briefing for %s.\n", d.Date)
+	if d.Weather != "" {
+		fmt.Fprintf(&b, "\n**Weather**\n%s\n", d.Weather)
+	}
+	if len(d.Calendar) > 0 {
+		b.WriteString("\n**Today's calendar**\n")
+		for _, e := range d.Calendar {
+			summary := e.Summary
+			if summary == "" {
+				summary = "(busy)"
+			}
+			fmt.Fprintf(&b, "- %s–%s %s\n", e.Start.In(loc).Format("15:04"), e.End.In(loc).Format("15:04"), summary)
+		}
+	}
+	if len(d.Jobs) > 0 {
+		b.WriteString("\n**Needs your attention**\n")
+		for _, j := range d.Jobs {
+			if j.Status == store.AwaitingInput {
+				fmt.Fprintf(&b, "- Job #%d %s is waiting for your answer\n", j.ID, j.Title)
+			} else {
+				fmt.Fprintf(&b, "- Job #%d %s is blocked: %s\n", j.ID, j.Title, j.Reason)
+			}
+		}
+	}
+	if len(d.Webhooks) > 0 {
+		failed := 0
+		for _, e := range d.Webhooks {
+			if e.Status != "ok" {
+				failed++
+			}
+		}
+		fmt.Fprintf(&b, "\n**Webhooks**\n- %d new events, %d failed\n", len(d.Webhooks), failed)
+		for _, e := range d.Webhooks {
+			if e.Status != "ok" {
+				fmt.Fprintf(&b, "- %s (%s) failed: %s\n", e.Path, e.Tool, e.Summary)
+			}
+		}
+	}
+	if len(d.Plans) > 0 {
+		b.WriteString("\n**Coming up**\n")
+		for _, p := range d.Plans {
+			fmt.Fprintf(&b, "- %s %s\n", p.At.In(loc).Format("Mon 15:04"), p.Description)
+		}
+	}
+	if len(d.Errors) > 0 {
+		var missing []string
+		for section := range d.Errors {
+			missing = append(missing, section)
+		}
+		sort.Strings(missing)
+		fmt.Fprintf(&b, "\n(Unavailable today: %s.)\n", strings.Join(missing, ", "))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Build gathers and composes userID's briefing without delivering it.
+func (s *Service) Build(ctx context.Context, userID string, settings Settings, loc *time.Location) (string, *Digest) {
+	ctx = context.WithValue(ctx, "user_id", userID)
+	d := s.Gather(ctx, userID, settings, time.Now(), loc)
+	return s.Compose(ctx, d, settings), d
+}
+
+// Send builds userID's briefing, delivers it and marks the webhook events it reported as read.
+func (s *Service) Send(ctx context.Context, userID string, settings Settings, loc *time.Location) (*Digest, error) {
+	if s.Sender == nil {
+		return nil, fmt.Errorf("no router to deliver the briefing")
+	}
+	text, d := s.Build(ctx, userID, settings, loc)
+	if err := s.Sender.RouteMessage(ctx, userID, text, ""); err != nil {
+		return d, fmt.Errorf("delivering briefing: %w", err)
+	}
+	// Keep it in history like other proactive messages
+	s.DB.InsertMessage(ctx, "assistant", text, "", "system", "scheduler", "scheduler", "", "", "")
+	ids := make([]int64, 0, len(d.Webhooks))
+	for _, e := range d.Webhooks {
+		ids = append(ids, e.ID)
+	}
+	if err := s.DB.MarkWebhookEventsRead(ctx, ids); err != nil {
+		log.Printf("[BRIEFING] marking webhook events read: %v", err)
+	}
+	return d, nil
+}
+
+// RunPlan delivers the briefing of a scheduled plan and returns the run summary.
+func (s *Service) RunPlan(ctx context.Context, p store.ScheduledPlan) (string, error) {
+	settings, err := ParseSettings(p.ActionPayload)
+	if err != nil {
+		return "", err
+	}
+	loc, err := scheduler.LoadLocation(p.Timezone)
+	if err != nil {
+		return "", err
+	}
+	d, err := s.Send(ctx, p.UserID, settings, loc)
+	if err != nil {
+		return "", err
+	}
+	return d.Summary(), nil
+}
+
+// FindPlan returns userID's briefing plan, or nil when there is none.
+func FindPlan(ctx context.Context, db *store.DB, userID string) (*store.ScheduledPlan, error) {
+	plans, err := db.ListPlans(ctx, userID, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range plans {
+		if plans[i].ActionType == ActionType && plans[i].Status != "completed" {
+			return &plans[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Schedule creates userID's briefing plan, replacing an existing one: every day ("daily") or
+// Monday to Friday ("weekdays") at runAt in time zone tz.
+func Schedule(ctx context.Context, db *store.DB, userID, days, runAt, tz string, settings Settings) (int64, error) {
+	if days == "" {
+		days = "daily"
+	}
+	if days != "daily" && days != "weekdays" {
+		return 0, fmt.Errorf("days must be daily or weekdays")
+	}
+	if err := settings.Validate(); err != nil {
+		return 0, err
+	}
+	rule, err := scheduler.ParseRule(days, runAt, tz)
+	if err != nil {
+		return 0, err
+	}
+	if existing, err := FindPlan(ctx, db, userID); err != nil {
+		return 0, err
+	} else if existing != nil {
+		if err := db.DeletePlan(ctx, existing.ID); err != nil {
+			return 0, err
+		}
+	}
+	payload, _ := json.Marshal(settings)
+	return db.CreatePlan(ctx, userID, PlanDescription, ActionType, string(payload), days, runAt, tz, rule.Next(time.Now()))
+}
@@ -0,0 +1,142 @@
+package briefing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools/nextcloud"
+)
+
+type fakeSender struct{ sent []string }
+
+func (f *fakeSender) RouteMessage(ctx context.Context, userID, content, urgency string) error {
+	f.sent = append(f.sent, userID+": "+content)
+	return nil
+}
+
+type fakeTools struct{ calls []string }
+
+func (f *fakeTools) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	f.calls = append(f.calls, name+" "+argsJSON)
+	return `{"forecast": "sunny, 21°C"}`, nil
+}
+
+func (f *fakeTools) SetSpawner(core.SubmindSpawner) {}
+
+type fakeLLM struct {
+	prompt string
+	err    error
+}
+
+func (f *fakeLLM) ChatCompletion(ctx context.Context, messages []core.Message) (string, error) {
+	f.prompt = messages[len(messages)-1].Content
+	return "Morning! Sunny today.", f.err
+}
+
+func (f *fakeLLM) ChatCompletionWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition) (string, []core.ToolCall, error) {
+	return "", nil, nil
+}
+
+func (f *fakeLLM) Embed(ctx context.Context, text string) ([]float32, error) { return nil, nil }
+
+func TestBriefing(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.GetOrCreateUser(ctx, "admin", "", "api")
+	db.ExecContext(ctx, `UPDATE users SET role = 'admin' WHERE id = 'admin'`)
+	db.GetOrCreateUser(ctx, "bob", "", "api")
+
+	now := time.Now()
+	db.CreatePlan(ctx, "admin", "Water plants", "remind", "{}", "daily", "18:00", "", now.Add(3*time.Hour))
+	db.CreatePlan(ctx, "admin", "Quarterly taxes", "remind", "{}", "once", "", "", now.Add(72*time.Hour))
+	job, _ := db.CreateJob(ctx, "admin", "Renew passport", "")
+	db.UpdateJobStatus(ctx, job, "blocked", "photo needed")
+	db.RecordWebhookEvent(ctx, store.WebhookEvent{Path: "/webhook/ci", Tool: "ci_notify", Status: "failed", Summary: "exit status 1"})
+
+	sender, tools, llm := &fakeSender{}, &fakeTools{}, &fakeLLM{}
+	svc := &Service{
+		DB: db, Client: llm, Sender: sender, Tools: tools,
+		Calendar: func(userID string, from, to time.Time) ([]nextcloud.BusyPeriod, error) {
+			return []nextcloud.BusyPeriod{{Start: from.Add(9 * time.Hour), End: from.Add(10 * time.Hour), Summary: "Standup"}}, nil
+		},
+	}
+	settings := Settings{WeatherTool: "weather", WeatherArgs: []byte(`{"city": "Berlin"}`)}
+
+	d := svc.Gather(ctx, "admin", settings, now, time.UTC)
+	if len(d.Calendar) != 1 || len(d.Plans) != 1 || len(d.Jobs) != 1 || len(d.Webhooks) != 1 || !strings.Contains(d.Weather, "sunny") {
+		t.Fatalf("digest = %+v", d)
+	}
+	if len(tools.calls) != 1 || tools.calls[0] != `execute_registered_tool {"args":{"city":"Berlin"},"name":"weather"}` {
+		t.Errorf("weather call = %v", tools.calls)
+	}
+	text := d.Text()
+	for _, want := range []string{"09:00–10:00 Standup", "Job #1 Renew passport is blocked: photo needed", "/webhook/ci (ci_notify) failed", "Water plants"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("plain briefing lacks %q:\n%s", want, text)
+		}
+	}
+
+	// Other users hear nothing about the deployment's webhooks
+	if d := svc.Gather(ctx, "bob", Settings{}, now, time.UTC); len(d.Webhooks) != 0 {
+		t.Errorf("non-admin got webhook events: %+v", d.Webhooks)
+	}
+
+	d, err = svc.Send(ctx, "admin", settings, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != "admin: Morning! Sunny today." || !strings.Contains(llm.prompt, "Renew passport") {
+		t.Errorf("sent = %v, prompt = %s", sender.sent, llm.prompt)
+	}
+	if d.Summary() != "1 events, 1 due plans, 1 jobs needing attention, 1 webhook events" {
+		t.Errorf("summary = %q", d.Summary())
+	}
+	if events, _ := db.UnreadWebhookEvents(ctx, 10); len(events) != 0 {
+		t.Errorf("reported webhook events still unread: %+v", events)
+	}
+
+	// A failing LLM still delivers the plain digest
+	llm.err = fmt.Errorf("provider down")
+	svc.Send(ctx, "admin", Settings{Sections: []string{SectionJobs}}, time.UTC)
+	if last := sender.sent[len(sender.sent)-1]; !strings.Contains(last, "Good morning!") || strings.Contains(last, "Standup") {
+		t.Errorf("fallback briefing = %s", last)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := Schedule(ctx, db, "u1", "daily", "07:30", "", Settings{Sections: []string{"news"}}); err == nil {
+		t.Error("unknown section accepted")
+	}
+	if _, err := Schedule(ctx, db, "u1", "daily", "07:30", "", Settings{}); err != nil {
+		t.Fatal(err)
+	}
+	id, err := Schedule(ctx, db, "u1", "weekdays", "8am", "Europe/Berlin", Settings{Sections: []string{SectionCalendar}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plans, _ := db.ListPlans(ctx, "u1", "")
+	if len(plans) != 1 || plans[0].ID != id || plans[0].ActionType != ActionType || plans[0].ScheduleType != "weekdays" {
+		t.Fatalf("plans = %+v", plans)
+	}
+	p, _ := FindPlan(ctx, db, "u1")
+	s, _ := ParseSettings(p.ActionPayload)
+	if !s.Has(SectionCalendar) || s.Has(SectionJobs) || s.Has(SectionWeather) {
+		t.Errorf("settings = %+v", s)
+	}
+}
@@ -0,0 +1,298 @@
+package adminterm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools"
+)
+
+const (
+	channelName   = "admin_term"
+	consoleThread = "terminal:console" // the terminal's conversation
+)
+
+// Commands are the terminal's slash commands. They run before the model, straight from the store
+// and health registries, so admin operations cost no tokens and work when the model does not.
+// Other slash commands (/regenerate, /dryrun, /feedback) go to the agent as usual.
+type Commands struct {
+	DB      *store.DB
+	Logs    *store.LogStore
+	Config  *config.Config
+	Gateway *gateway.Gateway
+	// Status returns the system_status gatherer; nil leaves /status out.
+	Status func() *tools.SystemStatusGatherer
+}
+
+// command is one slash command: usage is shown by /help.
+type command struct {
+	usage string
+	run   func(c *Commands, ctx context.Context, args []string) (string, error)
+}
+
+var commands = map[string]command{
+	"status": {"/status  health, components and error budget", (*Commands).status},
+	"logs":   {"/logs [error|warn|info] [component] [n]  recent log entries (default 20)", (*Commands).logs},
+	"plans":  {"/plans [active|paused|completed|all]  scheduled plans (default active)", (*Commands).plans},
+	"tools":  {"/tools [filter]  built-in and registered tools", (*Commands).tools},
+	"users":  {"/users [n]  users, most recently seen first (default 20)", (*Commands).users},
+	"cancel": {"/cancel  stop the turn running in this terminal", (*Commands).cancel},
+	"model":  {"/model [[route] model]  show the model routes, or set a route's model (default route: default)", (*Commands).model},
+}
+
+// Run runs line when it is one of the commands and returns its output; handled is false for
+// anything else, which goes to the agent.
+func (c *Commands) Run(ctx context.Context, line string) (out string, handled bool) {
+	if !strings.HasPrefix(line, "/") {
+		return "", false
+	}
+	fields := strings.Fields(line[1:])
+	if len(fields) == 0 {
+		return "", false
+	}
+	name := strings.ToLower(fields[0])
+	if name == "help" {
+		return c.help(), true
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return "", false
+	}
+	out, err := cmd.run(c, ctx, fields[1:])
+	if err != nil {
+		return "Error: " + err.Error(), true
+	}
+	return strings.TrimRight(out, "\n"), true
+}
+
+func (c *Commands) help() string {
+	usages := []string{"  /help  this list"}
+	for _, cmd := range commands {
+		usages = append(usages, "  "+cmd.usage)
+	}
+	sort.Strings(usages)
+	return "Commands (answered without the model):\n" + strings.Join(usages, "\n") + "\nAnything else goes to the agent."
+}
+
+func (c *Commands) status(ctx context.Context, args []string) (string, error) {
+	if c.Status == nil {
+		return "", fmt.Errorf("status is not available")
+	}
+	st, err := c.Status().Gather(ctx)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Health: %s\n", st.Health)
+	names := make([]string, 0, len(st.Components))
+	for name := range st.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h := st.Components[name]
+		fmt.Fprintf(&b, "  %-20s %-9s %s\n", name, h.Status, h.Message)
+	}
+	fmt.Fprintf(&b, "Channels: %s\n", strings.Join(st.ActiveChannels, ", "))
+	fmt.Fprintf(&b, "Messages: %d  Log entries: %d  Registered tools: %d  Token budget: %s\n", st.MessageCount, st.LogEntryCount, len(st.RegisteredTools), st.TokenBudget)
+	if eb := st.ErrorBudget; eb != nil && eb.Throttled {
+		fmt.Fprintf(&b, "Self-throttled since %s: %s\n", eb.Since.Format(time.RFC3339), eb.Reason)
+	}
+	if cr := st.Credits; cr != nil && cr.RemainingUSD != nil {
+		fmt.Fprintf(&b, "Credits: $%.2f remaining\n", *cr.RemainingUSD)
+	}
+	if ob := st.Onboarding; ob != nil && !ob.Complete {
+		fmt.Fprintf(&b, "Setup checklist: %d of %d done\n", ob.Done, ob.Total)
+	}
+	if len(st.RecentErrors) > 0 {
+		fmt.Fprintf(&b, "Recent errors (%d, see /logs error)\n", len(st.RecentErrors))
+	}
+	return b.String(), nil
+}
+
+func (c *Commands) logs(ctx context.Context, args []string) (string, error) {
+	if c.Logs == nil {
+		return "", fmt.Errorf("the log store is not configured")
+	}
+	level, component, limit := "", "", 20
+	for _, a := range args {
+		switch {
+		case a == "error" || a == "warn" || a == "info":
+			level = a
+		case isCount(a):
+			limit, _ = strconv.Atoi(a)
+		default:
+			component = a
+		}
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	entries, err := c.Logs.GetLogs(level, component, limit)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "No log entries.", nil
+	}
+	var b strings.Builder
+	// Oldest first, so the latest entry is next to the prompt
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		fmt.Fprintf(&b, "%s %-5s %-10s %s\n", e.Timestamp.Local().Format("01-02 15:04:05"), strings.ToUpper(e.Level), e.Component, e.Message)
+	}
+	return b.String(), nil
+}
+
+func (c *Commands) plans(ctx context.Context, args []string) (string, error) {
+	status := "active"
+	if len(args) > 0 {
+		status = args[0]
+	}
+	if status == "all" {
+		status = ""
+	}
+	plans, err := c.DB.ListAllPlans(ctx, status)
+	if err != nil {
+		return "", err
+	}
+	if len(plans) == 0 {
+		return "No plans.", nil
+	}
+	var b strings.Builder
+	for _, p := range plans {
+		next := "-"
+		if p.NextRunAt != nil {
+			next = p.NextRunAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(&b, "#%-4d %-9s %-16s %-12s %s  %s\n", p.ID, p.Status, next, p.UserID, p.ScheduleType, p.Description)
+	}
+	return b.String(), nil
+}
+
+func (c *Commands) tools(ctx context.Context, args []string) (string, error) {
+	filter := ""
+	if len(args) > 0 {
+		filter = strings.ToLower(args[0])
+	}
+	var b strings.Builder
+	var builtin []string
+	for _, d := range tools.BuiltinToolDefs() {
+		if filter == "" || strings.Contains(d.Function.Name, filter) {
+			policy := d.Policy
+			if policy == "" {
+				policy = "-"
+			}
+			builtin = append(builtin, fmt.Sprintf("  %-32s %s", d.Function.Name, policy))
+		}
+	}
+	sort.Strings(builtin)
+	fmt.Fprintf(&b, "Built-in tools (%d):\n%s\n", len(builtin), strings.Join(builtin, "\n"))
+	registered, err := c.DB.AllTools(ctx)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, t := range registered {
+		if filter == "" || strings.Contains(strings.ToLower(t.Name), filter) {
+			lines = append(lines, fmt.Sprintf("  %-32s %-14s v%d  %s", t.Name, t.Status, t.Version, t.Description))
+		}
+	}
+	fmt.Fprintf(&b, "Registered tools (%d):\n%s\n", len(lines), strings.Join(lines, "\n"))
+	return b.String(), nil
+}
+
+func (c *Commands) users(ctx context.Context, args []string) (string, error) {
+	limit := 20
+	if len(args) > 0 && isCount(args[0]) {
+		limit, _ = strconv.Atoi(args[0])
+	}
+	users, err := c.DB.RecentUsers(ctx, limit)
+	if err != nil {
+		return "", err
+	}
+	if len(users) == 0 {
+		return "No users.", nil
+	}
+	var b strings.Builder
+	for _, u := range users {
+		fmt.Fprintf(&b, "%-24s %-9s %-11s %-16s last seen %s\n", u.ID, u.Role, u.TrustLevel, u.Platform, u.LastSeen.Local().Format("2006-01-02 15:04"))
+	}
+	return b.String(), nil
+}
+
+func (c *Commands) cancel(ctx context.Context, args []string) (string, error) {
+	if c.Gateway == nil {
+		return "", fmt.Errorf("the gateway is not available")
+	}
+	if !c.Gateway.CancelTurn(gateway.ThreadKey(gateway.Message{Channel: channelName, ThreadID: consoleThread})) {
+		return "Nothing is running.", nil
+	}
+	return "Cancelled the running turn.", nil
+}
+
+func (c *Commands) model(ctx context.Context, args []string) (string, error) {
+	dir := ""
+	if c.Config != nil {
+		dir = c.Config.ConfigDir
+	}
+	routing, err := store.LoadLLMRouting(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(args) > 0 {
+		route, model := "default", args[0]
+		if len(args) > 1 {
+			route, model = args[0], args[1]
+		}
+		if routing == nil || routing.ModelRouting[route].Provider == "" {
+			return "", fmt.Errorf("no %s route in llm_routing.json; add one with manage_llm_provider (set_route)", route)
+		}
+		entry := routing.ModelRouting[route]
+		entry.Model = model
+		routing.ModelRouting[route] = entry
+		if err := store.SaveLLMRouting(dir, routing); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Route %s now uses %s/%s.", route, entry.Provider, model), nil
+	}
+
+	var b strings.Builder
+	if c.Config != nil {
+		fmt.Fprintf(&b, "Model: %s\n", c.Config.Model)
+		if c.Config.ThrottleModel != "" {
+			fmt.Fprintf(&b, "Throttle model: %s\n", c.Config.ThrottleModel)
+		}
+	}
+	if routing == nil || len(routing.ModelRouting) == 0 {
+		b.WriteString("No model routes (llm_routing.json).\n")
+		return b.String(), nil
+	}
+	routes := make([]string, 0, len(routing.ModelRouting))
+	for name := range routing.ModelRouting {
+		routes = append(routes, name)
+	}
+	sort.Strings(routes)
+	b.WriteString("Routes:\n")
+	for _, name := range routes {
+		var chain []string
+		for _, e := range routing.ModelRouting[name].Chain() {
+			chain = append(chain, e.Provider+"/"+e.Model)
+		}
+		fmt.Fprintf(&b, "  %-12s %s\n", name, strings.Join(chain, " -> "))
+	}
+	return b.String(), nil
+}
+
+// isCount reports whether s is a positive number.
+func isCount(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0
+}
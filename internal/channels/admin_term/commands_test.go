@@ -0,0 +1,58 @@
+package adminterm
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	logs := store.NewLogStore(db.DB)
+	logs.LogError("scheduler", "plan 7 failed")
+	db.GetOrCreateUser(ctx, "alice", "Alice", "nextcloud_talk")
+	db.CreatePlan(ctx, "alice", "water the plants", "remind", "{}", "daily", "09:00", "", time.Now().Add(time.Hour))
+	dir := t.TempDir()
+	store.SaveLLMRouting(dir, &store.LLMRoutingConfig{
+		LLMProviders: map[string]store.LLMProviderEntry{"or": {Type: "openrouter"}},
+		ModelRouting: map[string]store.ModelRouteEntry{"default": {Provider: "or", Model: "a"}},
+	})
+	c := &Commands{DB: db, Logs: logs, Config: &config.Config{ConfigDir: dir, Model: "a"}, Gateway: gateway.New(nil)}
+
+	for _, tt := range []struct{ line, want string }{
+		{"/logs error", "plan 7 failed"},
+		{"/users", "alice"},
+		{"/plans", "water the plants"},
+		{"/tools manage_th", "manage_thread"},
+		{"/model", "default      or/a"},
+		{"/model b", "Route default now uses or/b."},
+		{"/cancel", "Nothing is running."},
+		{"/help", "/status"},
+		{"/status", "Error: status is not available"},
+	} {
+		out, ok := c.Run(ctx, tt.line)
+		if !ok || !strings.Contains(out, tt.want) {
+			t.Errorf("%s: %v %q, want %q", tt.line, ok, out, tt.want)
+		}
+	}
+	if cfg, _ := store.LoadLLMRouting(dir); cfg.ModelRouting["default"].Model != "b" {
+		t.Errorf("/model b did not save: %+v", cfg.ModelRouting)
+	}
+	// Anything else goes to the agent
+	for _, line := range []string{"/regenerate", "/dryrun clean up", "status please", "/"} {
+		if _, ok := c.Run(ctx, line); ok {
+			t.Errorf("%q handled by the terminal", line)
+		}
+	}
+}
@@ -12,6 +12,9 @@ import (
 
 // TerminalChannel implements a simple stdin/stdout channel for the admin
 type TerminalChannel struct {
+	// Commands answers the admin slash commands (/status, /logs, ...) without the agent; nil sends
+	// every line to the agent.
+	Commands *Commands
 }
 
 func New() *TerminalChannel {
@@ -19,11 +22,16 @@ func New() *TerminalChannel {
 }
 
 func (t *TerminalChannel) Name() string {
-	return "admin_term"
+	return channelName
+}
+
+// Capabilities implements gateway.CapableChannel: the terminal shows markdown as raw text, so it is stripped.
+func (t *TerminalChannel) Capabilities() gateway.Capabilities {
+	return gateway.Capabilities{Markdown: false}
 }
 
 func (t *TerminalChannel) Start(ctx context.Context, ingress chan<- gateway.Message) error {
-	fmt.Println("HattieBot — Admin Terminal (Enter to send, Ctrl+C to exit)")
+	fmt.Println("HattieBot — Admin Terminal (Enter to send, /help for commands, Ctrl+C to exit)")
 	fmt.Println()
 
 	// Use a scanner in a goroutine so we can respect ctx.Done()
@@ -43,13 +51,19 @@ func (t *TerminalChannel) Start(ctx context.Context, ingress chan<- gateway.Mess
 			if text == "" {
 				continue
 			}
+			if t.Commands != nil {
+				if out, ok := t.Commands.Run(ctx, text); ok {
+					fmt.Printf("%s\n\n", out)
+					continue
+				}
+			}
 
 			// Send to gateway
 			ingress <- gateway.Message{
 				SenderID: "admin",
 				Content:  text,
 				Channel:  t.Name(),
-				ThreadID: "terminal:console",
+				ThreadID: consoleThread,
 			}
 		}
 	}()
@@ -0,0 +1,164 @@
+// Package api is the gateway channel behind the HTTP API (/api/v1/messages). Each request
+// waits for its own reply; status updates the agent sends while working are streamed to it.
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/pkg/hattiebot"
+)
+
+// Name is the channel name of messages received through the HTTP API.
+const Name = "api"
+
+// ErrThreadBusy is returned by Submit while another request in the same thread is still waiting for its reply.
+var ErrThreadBusy = errors.New("another message in this thread is still being answered")
+
+// ErrBusy is returned by Submit when the gateway's ingress buffer is full.
+var ErrBusy = errors.New("gateway busy, try again later")
+
+// Channel delivers agent replies to the HTTP requests that are waiting for them.
+type Channel struct {
+	PushIngress func(gateway.Message) bool
+
+	mu      sync.Mutex
+	nextID  int64
+	waiters map[string]*waiter // by request ID (Message.ReplyToID)
+	threads map[string]string  // thread key -> request ID in flight
+}
+
+type waiter struct {
+	thread string
+	events chan hattiebot.Event
+}
+
+// New creates the API channel; pushIngress is usually Gateway.PushIngress.
+func New(pushIngress func(gateway.Message) bool) *Channel {
+	return &Channel{
+		PushIngress: pushIngress,
+		waiters:     make(map[string]*waiter),
+		threads:     make(map[string]string),
+	}
+}
+
+func (c *Channel) Name() string { return Name }
+
+func (c *Channel) Start(ctx context.Context, ingress chan<- gateway.Message) error {
+	return nil
+}
+
+// Capabilities: replies are returned whole (no length limit) and status updates are edits of one message.
+func (c *Channel) Capabilities() gateway.Capabilities {
+	return gateway.Capabilities{Markdown: true, Editing: true}
+}
+
+// Submit pushes a message from userID into the gateway and returns the events of its reply.
+// The channel receives status events and is closed after the reply event. Call cancel when
+// the caller stops listening (e.g. the HTTP client disconnected); the turn itself continues.
+// Only one message per thread is answered at a time, so every request gets its own reply.
+// With noAdmin the user acts without admin rights (a token without the admin scope).
+func (c *Channel) Submit(userID, threadID, content string, noAdmin bool) (events <-chan hattiebot.Event, cancel func(), err error) {
+	if threadID == "" {
+		threadID = "user:" + userID
+	}
+	msg := gateway.Message{SenderID: userID, Content: content, Channel: Name, ThreadID: threadID, NoAdmin: noAdmin}
+	tk := gateway.ThreadKey(msg)
+
+	c.mu.Lock()
+	if _, busy := c.threads[tk]; busy {
+		c.mu.Unlock()
+		return nil, nil, ErrThreadBusy
+	}
+	c.nextID++
+	id := strconv.FormatInt(c.nextID, 10)
+	msg.ReplyToID = id
+	w := &waiter{thread: tk, events: make(chan hattiebot.Event, 16)}
+	c.waiters[id] = w
+	c.threads[tk] = id
+	c.mu.Unlock()
+
+	if c.PushIngress == nil || !c.PushIngress(msg) {
+		c.remove(id)
+		return nil, nil, ErrBusy
+	}
+	return w.events, func() { c.remove(id) }, nil
+}
+
+// remove forgets a waiter and frees its thread; it returns the waiter if it was still registered.
+func (c *Channel) remove(id string) *waiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.removeLocked(id)
+}
+
+func (c *Channel) removeLocked(id string) *waiter {
+	w, ok := c.waiters[id]
+	if !ok {
+		return nil
+	}
+	delete(c.waiters, id)
+	if c.threads[w.thread] == id {
+		delete(c.threads, w.thread)
+	}
+	return w
+}
+
+// status sends a status event without blocking the agent; a slow reader misses intermediate updates.
+func (c *Channel) status(msg gateway.Message, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := c.waiters[msg.ReplyToID]
+	if w == nil {
+		return
+	}
+	select {
+	case w.events <- hattiebot.Event{Type: hattiebot.EventStatus, Content: content, ThreadID: msg.ThreadID}:
+	default:
+	}
+}
+
+// Send delivers the final reply and ends the request.
+func (c *Channel) Send(msg gateway.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := c.removeLocked(msg.ReplyToID)
+	if w == nil {
+		log.Printf("[API] reply for request %q dropped: client no longer waiting", msg.ReplyToID)
+		return nil
+	}
+	// Drop unread status events if the buffer is full; the reply must not be lost or block the gateway.
+	for {
+		select {
+		case w.events <- hattiebot.Event{Type: hattiebot.EventReply, Content: msg.Content, ThreadID: msg.ThreadID}:
+			close(w.events)
+			return nil
+		default:
+			select {
+			case <-w.events:
+			default:
+			}
+		}
+	}
+}
+
+// SendEditable sends a status event; the request ID doubles as the message ID.
+func (c *Channel) SendEditable(msg gateway.Message) (string, error) {
+	c.status(msg, msg.Content)
+	return msg.ReplyToID, nil
+}
+
+// EditMessage sends an updated status event.
+func (c *Channel) EditMessage(msg gateway.Message, messageID, content string) error {
+	c.status(msg, content)
+	return nil
+}
+
+func (c *Channel) SendProactive(userID, content string) error {
+	return fmt.Errorf("api: SendProactive not supported (API clients only receive replies to their own messages)")
+}
@@ -20,6 +20,26 @@ func (c *Channel) Name() string {
 	return "discord_mock"
 }
 
+// Capabilities mirrors Discord: markdown, reactions, 2000-character messages.
+func (c *Channel) Capabilities() gateway.Capabilities {
+	return gateway.Capabilities{Markdown: true, Reactions: true, MaxLength: 2000}
+}
+
+func (c *Channel) React(msg gateway.Message, emoji string) error {
+	fmt.Printf("[DiscordMock] React %s to %s\n", emoji, msg.SenderID)
+	return nil
+}
+
+func (c *Channel) Unreact(msg gateway.Message, emoji string) error {
+	fmt.Printf("[DiscordMock] Unreact %s from %s\n", emoji, msg.SenderID)
+	return nil
+}
+
+func (c *Channel) Typing(threadID string, on bool) error {
+	fmt.Printf("[DiscordMock] Typing in %s: %v\n", threadID, on)
+	return nil
+}
+
 func (c *Channel) Start(ctx context.Context, ingress chan<- gateway.Message) error {
 	fmt.Println("DiscordMock: Starting (simulated)")
 	
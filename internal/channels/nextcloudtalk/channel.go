@@ -6,11 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/speech"
 )
 
 const ChannelName = "nextcloud_talk"
@@ -20,6 +24,8 @@ type Config struct {
 	BaseURL        string // Nextcloud base URL, e.g. http://nextcloud
 	BotUser        string // Hattie user (Nextcloud user) for Basic Auth
 	BotAppPassword string // Hattie user app password
+	// Synthesizer, when set, adds a spoken (TTS) reply to messages that arrived as voice messages.
+	Synthesizer speech.Synthesizer
 }
 
 // Channel implements gateway.Channel for Nextcloud Talk (webhook receive via HattieBridge, chat API send as Hattie user).
@@ -40,14 +46,69 @@ func (c *Channel) Name() string {
 	return ChannelName
 }
 
+// maxMessageLength is Talk's chat message limit (characters).
+const maxMessageLength = 32000
+
+// Capabilities implements gateway.CapableChannel. Talk renders markdown and supports reactions, edits,
+// quoted replies and mentions.
+func (c *Channel) Capabilities() gateway.Capabilities {
+	return gateway.Capabilities{Markdown: true, Reactions: true, Editing: true, Replies: true, Mentions: true, MaxLength: maxMessageLength}
+}
+
+// Distributed implements gateway.Distributed: replies go through the Talk bot API, so any
+// process sharing the ingress queue can run a Talk turn.
+func (c *Channel) Distributed() bool { return true }
+
+// React adds an emoji reaction to the incoming message (ReplyToID "roomToken:messageId").
+func (c *Channel) React(msg gateway.Message, emoji string) error {
+	return c.reaction(http.MethodPost, msg, emoji)
+}
+
+// Unreact removes the Hattie user's emoji reaction from the incoming message.
+func (c *Channel) Unreact(msg gateway.Message, emoji string) error {
+	return c.reaction(http.MethodDelete, msg, emoji)
+}
+
+func (c *Channel) reaction(method string, msg gateway.Message, emoji string) error {
+	idx := strings.Index(msg.ReplyToID, ":")
+	if idx <= 0 || idx == len(msg.ReplyToID)-1 {
+		return fmt.Errorf("nextcloud_talk: no message ID to react to")
+	}
+	roomToken, messageID := msg.ReplyToID[:idx], msg.ReplyToID[idx+1:]
+	base := strings.TrimSuffix(c.cfg.BaseURL, "/")
+	endpoint := base + "/ocs/v2.php/apps/spreed/api/v1/reaction/" + url.PathEscape(roomToken) + "/" + url.PathEscape(messageID)
+	raw, err := json.Marshal(map[string]string{"reaction": emoji})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.cfg.BotUser, c.cfg.BotAppPassword)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("OCS-APIRequest", "true")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("nextcloud_talk reaction: %s %s", resp.Status, string(body))
+	}
+	return nil
+}
+
 // Start does not run a poll loop; webhooks are received by the HTTP server and pushed to ingress.
 func (c *Channel) Start(ctx context.Context, ingress chan<- gateway.Message) error {
 	<-ctx.Done()
 	return nil
 }
 
-// Send posts a message to the Nextcloud Talk room via chat API as the Hattie user.
-func (c *Channel) Send(msg gateway.Message) error {
+// roomTokenOf returns the room to send msg to (ThreadID, else the room part of ReplyToID).
+func roomTokenOf(msg gateway.Message) (string, error) {
 	roomToken := msg.ThreadID
 	if roomToken == "" {
 		roomToken = msg.ReplyToID
@@ -57,23 +118,212 @@ func (c *Channel) Send(msg gateway.Message) error {
 		roomToken = roomToken[:idx]
 	}
 	if roomToken == "" {
-		return fmt.Errorf("nextcloud_talk: no room token (ThreadID or ReplyToID)")
-	}
-	// Parse reply ID if present, but we intentionally ignore it to avoid threaded/quoted replies
-	// (User preference: keeps chat cleaner).
-	/*
-	replyToID := 0
-	if idx := strings.Index(msg.ReplyToID, ":"); idx > 0 {
-		if n, err := fmt.Sscanf(msg.ReplyToID[idx+1:], "%d", &replyToID); err == nil && n == 1 {
-			// replyToID set
+		return "", fmt.Errorf("nextcloud_talk: no room token (ThreadID or ReplyToID)")
+	}
+	return roomToken, nil
+}
+
+// Send posts a message to the Nextcloud Talk room via chat API as the Hattie user.
+func (c *Channel) Send(msg gateway.Message) error {
+	roomToken, err := roomTokenOf(msg)
+	if err != nil {
+		return err
+	}
+	// Replies are plain messages unless quoting was asked for (msg.Quote); quoting every reply
+	// clutters the chat.
+	var replyTo int64
+	if msg.Quote {
+		replyTo = messageIDOf(msg)
+	}
+	if _, err := c.sendToRoom(roomToken, WithMentions(msg.Content, msg.Mentions), replyTo); err != nil {
+		return err
+	}
+	if msg.Voice && c.cfg.Synthesizer != nil && strings.TrimSpace(msg.Content) != "" {
+		// Text reply is already delivered; a failed audio reply is logged, not surfaced.
+		if err := c.sendVoiceReply(roomToken, msg.Content); err != nil {
+			log.Printf("[nextcloud_talk] voice reply failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// SendEditable implements gateway.MessageEditor: sends msg and returns the Talk message ID.
+func (c *Channel) SendEditable(msg gateway.Message) (string, error) {
+	roomToken, err := roomTokenOf(msg)
+	if err != nil {
+		return "", err
+	}
+	return c.sendToRoom(roomToken, WithMentions(msg.Content, msg.Mentions), 0)
+}
+
+// messageIDOf returns the Talk message ID from ReplyToID ("roomToken:messageId"), or 0.
+func messageIDOf(msg gateway.Message) int64 {
+	idx := strings.Index(msg.ReplyToID, ":")
+	if idx <= 0 {
+		return 0
+	}
+	id, _ := strconv.ParseInt(msg.ReplyToID[idx+1:], 10, 64)
+	return id
+}
+
+// WithMentions prefixes content with an @-mention for each user ID it does not mention yet. Talk
+// notifies mentioned users; the quoted form also covers IDs with spaces.
+func WithMentions(content string, userIDs []string) string {
+	var prefix []string
+	for _, id := range userIDs {
+		id = strings.TrimPrefix(strings.TrimSpace(id), "@")
+		if id == "" || strings.Contains(content, `@"`+id+`"`) || mentionsPlain(content, id) {
+			continue
 		}
+		prefix = append(prefix, `@"`+id+`"`)
+	}
+	if len(prefix) == 0 {
+		return content
 	}
-	*/
-	return c.sendToRoom(roomToken, msg.Content, 0)
+	return strings.Join(prefix, " ") + " " + content
 }
 
-// sendToRoom posts a message via Talk chat API (Basic Auth as Hattie user).
-func (c *Channel) sendToRoom(roomToken, message string, replyToID int) error {
+// mentionsPlain reports whether content contains "@id" not followed by more of a user ID.
+func mentionsPlain(content, id string) bool {
+	for i := 0; ; {
+		j := strings.Index(content[i:], "@"+id)
+		if j < 0 {
+			return false
+		}
+		end := i + j + 1 + len(id)
+		if end == len(content) || !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_.-@", rune(content[end])) {
+			return true
+		}
+		i = end
+	}
+}
+
+// SendMessage posts content to a room as the Hattie user, optionally quoting message replyTo and
+// mentioning users, and returns the new message ID.
+func (c *Channel) SendMessage(roomToken, content string, replyTo int64, mentions []string) (string, error) {
+	if roomToken == "" {
+		return "", fmt.Errorf("nextcloud_talk: room token required")
+	}
+	return c.sendToRoom(roomToken, WithMentions(content, mentions), replyTo)
+}
+
+// EditMessage implements gateway.MessageEditor via the Talk chat edit API (Talk 18+).
+func (c *Channel) EditMessage(msg gateway.Message, messageID, content string) error {
+	roomToken, err := roomTokenOf(msg)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(map[string]string{"message": content})
+	if err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(c.cfg.BaseURL, "/")
+	endpoint := base + "/ocs/v2.php/apps/spreed/api/v1/chat/" + url.PathEscape(roomToken) + "/" + url.PathEscape(messageID)
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.cfg.BotUser, c.cfg.BotAppPassword)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("OCS-APIRequest", "true")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("nextcloud_talk edit: %s %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+func (c *Channel) sendVoiceReply(roomToken, text string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	audio, ext, err := c.cfg.Synthesizer.Synthesize(ctx, text)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("HattieBot reply %s.%s", time.Now().Format("2006-01-02 15-04-05"), ext)
+	return c.SendAudio(ctx, roomToken, name, audio)
+}
+
+// DownloadAttachment fetches a file shared into a room from the Hattie user's files via WebDAV.
+// path is relative to the Hattie user's home (e.g. "Talk/recording.ogg").
+func (c *Channel) DownloadAttachment(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.davURL(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.cfg.BotUser, c.cfg.BotAppPassword)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("nextcloud_talk download %s: %s %s", path, resp.Status, string(body))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// SendAudio uploads audio to the Hattie user's Talk/ folder and shares it into the room as a voice message.
+func (c *Channel) SendAudio(ctx context.Context, roomToken, name string, audio []byte) error {
+	path := "Talk/" + name
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.davURL(path), bytes.NewReader(audio))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.cfg.BotUser, c.cfg.BotAppPassword)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("nextcloud_talk upload %s: %s", path, resp.Status)
+	}
+
+	form := url.Values{}
+	form.Set("shareType", "10") // Talk room
+	form.Set("shareWith", roomToken)
+	form.Set("path", "/"+path)
+	form.Set("talkMetaData", `{"messageType":"voice-message"}`)
+	base := strings.TrimSuffix(c.cfg.BaseURL, "/")
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, base+"/ocs/v2.php/apps/files_sharing/api/v1/shares", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.cfg.BotUser, c.cfg.BotAppPassword)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("OCS-APIRequest", "true")
+	req.Header.Set("Accept", "application/json")
+	resp, err = c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("nextcloud_talk share audio: %s %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+func (c *Channel) davURL(path string) string {
+	base := strings.TrimSuffix(c.cfg.BaseURL, "/")
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return base + "/remote.php/dav/files/" + url.PathEscape(c.cfg.BotUser) + "/" + strings.Join(segments, "/")
+}
+
+// sendToRoom posts a message via Talk chat API (Basic Auth as Hattie user) and returns the new message ID.
+func (c *Channel) sendToRoom(roomToken, message string, replyToID int64) (string, error) {
 	base := strings.TrimSuffix(c.cfg.BaseURL, "/")
 	url := base + "/ocs/v2.php/apps/spreed/api/v1/chat/" + roomToken
 	body := map[string]interface{}{
@@ -84,12 +334,12 @@ func (c *Channel) sendToRoom(roomToken, message string, replyToID int) error {
 	}
 	raw, err := json.Marshal(body)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader(raw))
 	if err != nil {
-		return err
+		return "", err
 	}
 	req.SetBasicAuth(c.cfg.BotUser, c.cfg.BotAppPassword)
 	req.Header.Set("Content-Type", "application/json")
@@ -98,26 +348,37 @@ func (c *Channel) sendToRoom(roomToken, message string, replyToID int) error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusCreated {
-		return nil
+		var created struct {
+			OCS struct {
+				Data struct {
+					ID int64 `json:"id"`
+				} `json:"data"`
+			} `json:"ocs"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil || created.OCS.Data.ID == 0 {
+			return "", nil // sent; ID unavailable (e.g. older Talk), so the message cannot be edited later
+		}
+		return fmt.Sprint(created.OCS.Data.ID), nil
 	}
 	bodyRead, _ := io.ReadAll(resp.Body)
 	errMsg := fmt.Sprintf("nextcloud_talk send: %s %s", resp.Status, string(bodyRead))
 	if resp.StatusCode == http.StatusUnauthorized {
 		errMsg += " (check NextcloudBotUser/BotAppPassword)"
 	}
-	return fmt.Errorf("%s", errMsg)
+	return "", fmt.Errorf("%s", errMsg)
 }
 
 // SendProactive sends a message to a user. Without a room mapping we cannot send to a specific user;
 // the caller may pass userID as a known room token for "DM" rooms, or we fail.
 func (c *Channel) SendProactive(userID, content string) error {
 	if userID != "" && !strings.Contains(userID, "@") {
-		return c.sendToRoom(userID, content, 0)
+		_, err := c.sendToRoom(userID, content, 0)
+		return err
 	}
 	return fmt.Errorf("nextcloud_talk: proactive send requires room token as userID (no user-to-room mapping)")
 }
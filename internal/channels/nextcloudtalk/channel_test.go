@@ -0,0 +1,75 @@
+package nextcloudtalk
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+)
+
+func TestWithMentions(t *testing.T) {
+	cases := []struct {
+		content  string
+		mentions []string
+		want     string
+	}{
+		{"Dinner?", []string{"alice", "bob smith"}, `@"alice" @"bob smith" Dinner?`},
+		{"@alice dinner?", []string{"alice"}, "@alice dinner?"},
+		{"@alice2 dinner?", []string{"alice"}, `@"alice" @alice2 dinner?`},
+		{"hi", nil, "hi"},
+	}
+	for _, c := range cases {
+		if got := WithMentions(c.content, c.mentions); got != c.want {
+			t.Errorf("WithMentions(%q, %v) = %q, want %q", c.content, c.mentions, got, c.want)
+		}
+	}
+}
+
+func TestSendQuotesAndPolls(t *testing.T) {
+	var chat []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if b, _ := io.ReadAll(r.Body); len(b) > 0 {
+			json.Unmarshal(b, &body)
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "POST /ocs/v2.php/apps/spreed/api/v1/chat/room1":
+			chat = append(chat, body)
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"ocs":{"data":{"id":7}}}`)
+		case "POST /ocs/v2.php/apps/spreed/api/v1/poll/room1":
+			if body["resultMode"] != float64(1) || len(body["options"].([]interface{})) != 2 {
+				t.Errorf("poll body = %v", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"ocs":{"data":{"id":3,"question":"Pizza or sushi?","options":["Pizza","Sushi"],"votes":[],"status":0,"resultMode":1}}}`)
+		case "DELETE /ocs/v2.php/apps/spreed/api/v1/poll/room1/3":
+			io.WriteString(w, `{"ocs":{"data":{"id":3,"question":"Pizza or sushi?","options":["Pizza","Sushi"],"votes":{"option-1":2},"numVoters":2,"status":1,"resultMode":1}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	c := New(Config{BaseURL: srv.URL, BotUser: "hattie", BotAppPassword: "pw"})
+
+	c.Send(gateway.Message{ThreadID: "room1", ReplyToID: "room1:41", Content: "plain"})
+	c.Send(gateway.Message{ThreadID: "room1", ReplyToID: "room1:41", Content: "quoted", Quote: true, Mentions: []string{"alice"}})
+	if len(chat) != 2 || chat[0]["replyTo"] != nil || chat[1]["replyTo"] != float64(41) || chat[1]["message"] != `@"alice" quoted` {
+		t.Fatalf("chat bodies = %v", chat)
+	}
+
+	p, err := c.CreatePoll("room1", "Pizza or sushi?", []string{"Pizza", "Sushi"}, true, 0)
+	if err != nil || p.ID != 3 || !p.Hidden || p.Closed || p.Results != nil {
+		t.Fatalf("created poll = %+v, %v", p, err)
+	}
+	p, err = c.ClosePoll("room1", 3)
+	if err != nil || !p.Closed || p.NumVoters != 2 || len(p.Results) != 2 || p.Results[0].Votes != 0 || p.Results[1].Votes != 2 {
+		t.Fatalf("closed poll = %+v, %v", p, err)
+	}
+	if _, err := c.CreatePoll("room1", "Yes?", []string{"Yes"}, false, 0); err == nil {
+		t.Error("single-option poll accepted")
+	}
+}
@@ -0,0 +1,134 @@
+package nextcloudtalk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Poll is a Talk poll with its results. Votes stay empty while a hidden poll is open.
+type Poll struct {
+	ID        int64        `json:"id"`
+	Question  string       `json:"question"`
+	Options   []string     `json:"options"`
+	Results   []PollResult `json:"results,omitempty"`
+	NumVoters int          `json:"num_voters"`
+	Closed    bool         `json:"closed"`
+	Hidden    bool         `json:"hidden,omitempty"`
+	MaxVotes  int          `json:"max_votes,omitempty"` // 0 = unlimited
+}
+
+// PollResult is the vote count of one option.
+type PollResult struct {
+	Option string `json:"option"`
+	Votes  int    `json:"votes"`
+}
+
+// talkPoll is the poll as the Talk API returns it. Votes is an object keyed "option-<index>", or
+// an empty array (PHP's empty map) before anyone voted or while results are hidden.
+type talkPoll struct {
+	ID         int64           `json:"id"`
+	Question   string          `json:"question"`
+	Options    []string        `json:"options"`
+	Votes      json.RawMessage `json:"votes"`
+	NumVoters  int             `json:"numVoters"`
+	Status     int             `json:"status"`     // 0 open, 1 closed
+	ResultMode int             `json:"resultMode"` // 0 public, 1 hidden
+	MaxVotes   int             `json:"maxVotes"`
+}
+
+func (p talkPoll) poll() *Poll {
+	out := &Poll{ID: p.ID, Question: p.Question, Options: p.Options, NumVoters: p.NumVoters,
+		Closed: p.Status == 1, Hidden: p.ResultMode == 1, MaxVotes: p.MaxVotes}
+	var votes map[string]int
+	if json.Unmarshal(p.Votes, &votes) == nil && len(votes) > 0 {
+		for i, opt := range p.Options {
+			out.Results = append(out.Results, PollResult{Option: opt, Votes: votes[fmt.Sprintf("option-%d", i)]})
+		}
+	}
+	return out
+}
+
+// CreatePoll posts a poll to a room. hidden keeps results secret until the poll is closed;
+// maxVotes limits the options each participant may pick (0 = unlimited).
+func (c *Channel) CreatePoll(roomToken, question string, options []string, hidden bool, maxVotes int) (*Poll, error) {
+	if strings.TrimSpace(question) == "" || len(options) < 2 {
+		return nil, fmt.Errorf("nextcloud_talk poll: a question and at least two options are required")
+	}
+	body := map[string]interface{}{"question": question, "options": options, "resultMode": 0, "maxVotes": maxVotes}
+	if hidden {
+		body["resultMode"] = 1
+	}
+	var p talkPoll
+	if err := c.pollRequest(http.MethodPost, roomToken, "", body, &p); err != nil {
+		return nil, err
+	}
+	return p.poll(), nil
+}
+
+// GetPoll returns a poll with its current results.
+func (c *Channel) GetPoll(roomToken string, pollID int64) (*Poll, error) {
+	var p talkPoll
+	if err := c.pollRequest(http.MethodGet, roomToken, fmt.Sprint(pollID), nil, &p); err != nil {
+		return nil, err
+	}
+	return p.poll(), nil
+}
+
+// ClosePoll ends voting and returns the final results. Only the poll's author (or a moderator) may close it.
+func (c *Channel) ClosePoll(roomToken string, pollID int64) (*Poll, error) {
+	var p talkPoll
+	if err := c.pollRequest(http.MethodDelete, roomToken, fmt.Sprint(pollID), nil, &p); err != nil {
+		return nil, err
+	}
+	return p.poll(), nil
+}
+
+// pollRequest calls the Talk poll API for a room (and poll ID, if given) and decodes ocs.data into out.
+func (c *Channel) pollRequest(method, roomToken, pollID string, body interface{}, out interface{}) error {
+	if roomToken == "" {
+		return fmt.Errorf("nextcloud_talk: room token required")
+	}
+	endpoint := strings.TrimSuffix(c.cfg.BaseURL, "/") + "/ocs/v2.php/apps/spreed/api/v1/poll/" + url.PathEscape(roomToken)
+	if pollID != "" {
+		endpoint += "/" + url.PathEscape(pollID)
+	}
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.cfg.BotUser, c.cfg.BotAppPassword)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("OCS-APIRequest", "true")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("nextcloud_talk poll: %s %s", resp.Status, string(raw))
+	}
+	var envelope struct {
+		OCS struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"ocs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("nextcloud_talk poll: %w", err)
+	}
+	return json.Unmarshal(envelope.OCS.Data, out)
+}
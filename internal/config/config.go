@@ -5,6 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/tracing"
 )
 
 // Config holds runtime configuration. Secrets (e.g. API key) are read from
@@ -41,21 +44,216 @@ type Config struct {
 	AdminUserID string `json:"admin_user_id"`
 	// ToolOutputMaxRunes caps tool output length (0 = no truncation). Set via HATTIEBOT_TOOL_OUTPUT_MAX_RUNES.
 	ToolOutputMaxRunes int `json:"tool_output_max_runes"`
+	// ToolSubsetSize is how many request-relevant tools (by embedding match) are sent on top of the
+	// always-on core tools (0 = send every tool). Set via HATTIEBOT_TOOL_SUBSET_SIZE.
+	ToolSubsetSize int `json:"tool_subset_size"`
+	// ToolCore replaces the always-sent core tool list when set. Set via HATTIEBOT_TOOL_CORE (comma-separated).
+	ToolCore []string `json:"tool_core"`
+	// ConfirmTools lists tools (registered tools by name) that return a draft and run only after the
+	// user confirms it in the conversation; nil = the built-in list, empty = none. Set via
+	// HATTIEBOT_CONFIRM_TOOLS (comma-separated, "none" for no tools).
+	ConfirmTools []string `json:"confirm_tools"`
+	// FeedbackMemorize, when true, hands /feedback comments to the agent to save lasting preferences
+	// as user facts. Set via HATTIEBOT_FEEDBACK_MEMORIZE.
+	FeedbackMemorize bool `json:"feedback_memorize"`
+	// DryRun makes every restricted tool call return a description of what it would do instead of
+	// running; /dryrun does the same for one turn. Set via HATTIEBOT_DRY_RUN.
+	DryRun bool `json:"dry_run"`
+	// LogLevel is the minimum level logged and stored in system_logs: debug, info, warn or error
+	// (default info). Set via HATTIEBOT_LOG_LEVEL.
+	LogLevel string `json:"log_level"`
+	// LogFormat is the console log format: text (default) or json. Set via HATTIEBOT_LOG_FORMAT.
+	LogFormat string `json:"log_format"`
+	// OTLPEndpoint is the OpenTelemetry collector (OTLP/HTTP, e.g. http://localhost:4318) that turn
+	// traces are exported to; empty = tracing off. Set via OTEL_EXPORTER_OTLP_ENDPOINT.
+	OTLPEndpoint string `json:"otlp_endpoint"`
+	// OTLPHeaders are sent with every export (e.g. an API key). Set via OTEL_EXPORTER_OTLP_HEADERS
+	// ("key=value,key2=value2").
+	OTLPHeaders map[string]string `json:"otlp_headers"`
+	// ToolRules maps a request keyword to tools always sent when the request contains it; merged over
+	// the built-in rules, where an empty list drops a built-in keyword. Config file only.
+	ToolRules map[string][]string `json:"tool_rules"`
 
 	// Embedding service (vector memory). When set, memorize/recall use this instead of LLM Embed.
 	EmbeddingServiceURL   string `json:"embedding_service_url"`
 	EmbeddingServiceAPIKey string `json:"embedding_service_api_key"`
 	EmbeddingDimension   int    `json:"embedding_dimension"`
+	// LocalEmbeddings falls back to offline hashing vectors when the embedding provider fails, so
+	// memory degrades instead of erroring (default on). Set via HATTIEBOT_LOCAL_EMBEDDINGS.
+	LocalEmbeddings bool `json:"local_embeddings"`
 
 	// Nextcloud (HattieBridge webhook; optional Files/Passwords)
 	NextcloudURL              string `json:"nextcloud_url"`
 	HattieBridgeWebhookSecret string `json:"hattie_bridge_webhook_secret"`
 	NextcloudBotUser          string `json:"nextcloud_bot_user"`
 	NextcloudBotAppPassword   string `json:"nextcloud_bot_app_password"`
+	// Optional Talk webhook hardening on top of the secret: source IPs/CIDRs (comma-separated), and
+	// a CA whose client certificates HattieBridge must present (HTTPS only), optionally by name.
+	TalkAllowedIPs   string   `json:"talk_allowed_ips"`
+	TalkClientCAFile string   `json:"talk_client_ca_file"`
+	TalkClientNames  []string `json:"talk_client_names"`
+
+	// Speech (voice messages). STT provider: "whisper_api" (OpenAI-compatible API) or "command" (local, e.g. whisper.cpp).
+	SpeechSTTProvider string `json:"speech_stt_provider"`
+	SpeechAPIURL      string `json:"speech_api_url"`
+	SpeechAPIKey      string `json:"speech_api_key"`
+	SpeechSTTModel    string `json:"speech_stt_model"`
+	// SpeechSTTCommand is run via sh -c; "{file}" is replaced by the audio path (appended if absent). Transcript on stdout.
+	SpeechSTTCommand string `json:"speech_stt_command"`
+	// SpeechTTSModel enables spoken replies to voice messages when set (e.g. "tts-1").
+	SpeechTTSModel string `json:"speech_tts_model"`
+	SpeechTTSVoice string `json:"speech_tts_voice"`
+	// Outbound email (send_email tool). SMTPPasswordSecret is a secret ref: "env:VAR" or a Nextcloud Passwords title.
+	SMTPHost           string `json:"smtp_host"`
+	SMTPPort           int    `json:"smtp_port"`
+	SMTPUsername       string `json:"smtp_username"`
+	SMTPPasswordSecret string `json:"smtp_password_secret"`
+	SMTPFrom           string `json:"smtp_from"`
+	// SMTPTLS is "starttls" (default), "tls" (implicit, usually port 465), or "none".
+	SMTPTLS string `json:"smtp_tls"`
+	// Web search (search_web tool). SearchProvider is "searxng", "brave" or "tavily"; SearchURL is the
+	// SearxNG instance. SearchAPIKeySecret is a secret ref: "env:VAR", "local:Name", or a title in the default store.
+	SearchProvider     string `json:"search_provider"`
+	SearchURL          string `json:"search_url"`
+	SearchAPIKeySecret string `json:"search_api_key_secret"`
+	// Git toolset for self-modification: GitRepoDir is the checkout of HattieBot's source the agent
+	// edits. Pushes go to GitRemote; pull requests target GitBaseBranch on GitForge ("github" or
+	// "gitea"; GitForgeURL and GitForgeRepo default from the remote URL). GitTokenSecret is a secret ref.
+	GitRepoDir     string `json:"git_repo_dir"`
+	GitRemote      string `json:"git_remote"`
+	GitBaseBranch  string `json:"git_base_branch"`
+	GitForge       string `json:"git_forge"`
+	GitForgeURL    string `json:"git_forge_url"`
+	GitForgeRepo   string `json:"git_forge_repo"`
+	GitTokenSecret string `json:"git_token_secret"`
+	// Self-update (self_update tool): builds GitRepoDir under the SelfUpdateSandbox profile of
+	// sandbox.json ("" means "build") and stages the binary when go test passes within
+	// SelfUpdateTestTimeoutMin. RestartCommand restarts the bot when it does not run under
+	// hattiebot-supervisor (e.g. a hook that runs docker restart).
+	SelfUpdateSandbox        string `json:"self_update_sandbox"`
+	SelfUpdateTestTimeoutMin int    `json:"self_update_test_timeout_min"`
+	RestartCommand           string `json:"restart_command"`
+	// Local encrypted secret store (source "local"), the default secret store when Nextcloud
+	// Passwords is not configured. The AES key is derived from SecretsPassphrase if set, else from
+	// SecretsKeyFile, which is generated on first start.
+	SecretsFile       string `json:"secrets_file"`
+	SecretsKeyFile    string `json:"secrets_key_file"`
+	SecretsPassphrase string `json:"-"`
+	// HashiCorp Vault secret source ("vault", KV v2). Enabled when VaultAddr is set; uses VaultToken,
+	// or AppRole login with VaultRoleID/VaultSecretID. References look like {{secret:vault:path#field}}.
+	VaultAddr      string `json:"vault_addr"`
+	VaultToken     string `json:"vault_token"`
+	VaultRoleID    string `json:"vault_role_id"`
+	VaultSecretID  string `json:"vault_secret_id"`
+	VaultMount     string `json:"vault_mount"`
+	VaultNamespace string `json:"vault_namespace"`
+	// OpenRouterBaseURL overrides the OpenRouter API endpoint (e.g. a proxy, or a mock LLM in the e2e harness).
+	OpenRouterBaseURL string `json:"openrouter_base_url"`
+	// SchedulerIntervalSec is how often the scheduler checks for due plans.
+	SchedulerIntervalSec int `json:"scheduler_interval_sec"`
+	// QueueURL is a Redis server (redis:// or rediss://) whose streams carry the turns of
+	// distributable channels, so several processes can share them ("" = in-memory only).
+	QueueURL string `json:"queue_url"`
+	// QueuePartitions is how many streams the queue is split into; every process must agree.
+	QueuePartitions int `json:"queue_partitions"`
+	// DashboardPort serves the web dashboard on its own port (0 = disabled).
+	DashboardPort int `json:"dashboard_port"`
+	// ConfigWatchSec is how often the routing files and SOUL.md are checked for changes (0 = never).
+	ConfigWatchSec int `json:"config_watch_sec"`
+	// ThrottleModel is the cheaper model used while the error budget is exhausted ("" = keep Model).
+	ThrottleModel string `json:"throttle_model"`
+	// GroupAddressing decides which messages the bot answers in rooms where several people write:
+	// "auto" (mentions, follow-ups to its last reply, and the classifier when on), "mention" (only
+	// when named or @-mentioned) or "all". Rooms override it with manage_thread.
+	GroupAddressing string `json:"group_addressing"`
+	// GroupClassifier asks the model (ThrottleModel when set) whether an unclear group message is
+	// addressed to the bot, in "auto" rooms.
+	GroupClassifier bool `json:"group_classifier"`
+	// MonitorSocket is the Unix socket the hattiebot-monitor view reads live state from
+	// (default <config dir>/monitor.sock; "off" disables it).
+	MonitorSocket string `json:"monitor_socket"`
+	// ContextBudget is how many tokens the system prompt and history may take per request; larger
+	// prompts are trimmed, lowest-priority sections first (0 = no limit).
+	ContextBudget int `json:"context_budget"`
+	// AuditRetentionDays is how long tool_audit_log entries are kept (0 = forever).
+	AuditRetentionDays int `json:"audit_retention_days"`
+	// MessageRetentionDays is how long raw conversation messages are kept (0 = forever). With
+	// MessageRetentionSummarize, each thread's expiring messages are replaced by an LLM summary.
+	MessageRetentionDays      int  `json:"message_retention_days"`
+	MessageRetentionSummarize bool `json:"message_retention_summarize"`
+	// SubmindConcurrency is how many background sub-minds (spawn_submind async) run at once.
+	SubmindConcurrency int `json:"submind_concurrency"`
+	// WebhookWorkers run the tools of async webhook routes; WebhookQueueSize deliveries may wait for them.
+	WebhookWorkers   int `json:"webhook_workers"`
+	WebhookQueueSize int `json:"webhook_queue_size"`
+	// HTTP server hardening. HTTPBindAddr is the interface the webhook/API server listens on ("" = all).
+	// HTTPS uses HTTPTLSCertFile and HTTPTLSKeyFile, or Let's Encrypt certificates for
+	// HTTPAutocertDomains. Timeouts and the header limit of 0 keep the server's defaults.
+	// HTTPTrustedProxies are the reverse proxies (IPs or CIDRs) whose X-Forwarded-For is believed.
+	HTTPBindAddr        string   `json:"http_bind_addr"`
+	HTTPTLSCertFile     string   `json:"http_tls_cert_file"`
+	HTTPTLSKeyFile      string   `json:"http_tls_key_file"`
+	HTTPAutocertDomains []string `json:"http_autocert_domains"`
+	HTTPAutocertEmail   string   `json:"http_autocert_email"`
+	HTTPReadTimeoutSec  int      `json:"http_read_timeout_sec"`
+	HTTPWriteTimeoutSec int      `json:"http_write_timeout_sec"`
+	HTTPMaxHeaderBytes  int      `json:"http_max_header_bytes"`
+	HTTPTrustedProxies  string   `json:"http_trusted_proxies"`
+	// SubmindProgressSec is the least time between sub-mind status updates posted to the user's thread (0 = none).
+	SubmindProgressSec int `json:"submind_progress_sec"`
+	// ToolVersionsKept is how many previous versions of each registered tool are kept for rollback.
+	ToolVersionsKept int `json:"tool_versions_kept"`
+	// ToolAutoRepair lets a background sub-mind attempt to fix registered tools that become broken.
+	ToolAutoRepair bool `json:"tool_auto_repair"`
+	// CreditWarnUSD are the remaining OpenRouter credit levels (USD) that each warn the admin once.
+	CreditWarnUSD []float64 `json:"credit_warn_usd"`
+	// CreditWarnDays warns the admin when the spend forecast says credits run out within this many days (0 = off).
+	CreditWarnDays float64 `json:"credit_warn_days"`
+	// Escalation chain for overdue plans: the user is told once a plan is EscalationOverdueMin
+	// overdue, the admin EscalationAdminAfterMin later, and both on their urgent channel
+	// EscalationUrgentAfterMin after the start, unless someone replies "ack" first.
+	EscalationOverdueMin     int `json:"escalation_overdue_min"`
+	EscalationAdminAfterMin  int `json:"escalation_admin_after_min"`
+	EscalationUrgentAfterMin int `json:"escalation_urgent_after_min"`
+	// StorageBackend is "" / "sqlite" (DBPath); the runtime store is SQLite only, so startup refuses any other value.
+	StorageBackend string `json:"storage_backend"`
+	DatabaseURL    string `json:"database_url"`
+	// BackupTarget is where backups of the database and config dir go: "local:/dir", "nextcloud:/folder"
+	// or "s3://bucket/prefix" ("" = no backups). S3 credentials come from the AWS_* variables.
+	BackupTarget        string `json:"backup_target"`
+	BackupIntervalHours int    `json:"backup_interval_hours"` // 0 = only on request (backup_now)
+	BackupKeep          int    `json:"backup_keep"`           // archives kept at the target (0 = all)
+	BackupS3Region      string `json:"backup_s3_region"`
+	BackupS3Endpoint    string `json:"backup_s3_endpoint"` // S3-compatible endpoint URL ("" = AWS)
+	BackupS3AccessKey   string `json:"-"`
+	BackupS3SecretKey   string `json:"-"`
+	// WorkspaceSyncFolder is a Nextcloud folder kept in two-way sync with WorkspaceDir ("" = off).
+	WorkspaceSyncFolder      string `json:"workspace_sync_folder"`
+	WorkspaceSyncIntervalMin int    `json:"workspace_sync_interval_min"` // 0 = only on request (sync_workspace)
+	// FileStores are the named remote file stores from config.json ("file_stores"), used by the
+	// file_store tool and by backups ("store:<name>/folder"). DefaultFileStore is used when a
+	// tool call names none.
+	FileStores       map[string]FileStore `json:"file_stores"`
+	DefaultFileStore string               `json:"default_file_store"`
 	// DefaultChannel is used for proactive routing when no user preference (e.g. "admin_term", "nextcloud_talk").
 	DefaultChannel string `json:"default_channel"`
 }
 
+// FileStore configures one file store: a local directory, a WebDAV collection or an S3 (or
+// S3-compatible, e.g. MinIO) bucket.
+type FileStore struct {
+	Type      string `json:"type"`                 // "local", "webdav" or "s3"
+	Dir       string `json:"dir,omitempty"`        // local: directory; webdav: folder below URL
+	URL       string `json:"url,omitempty"`        // webdav: collection URL; s3: endpoint ("" = AWS)
+	User      string `json:"user,omitempty"`       // webdav
+	Password  string `json:"password,omitempty"`   // webdav
+	Bucket    string `json:"bucket,omitempty"`     // s3
+	Prefix    string `json:"prefix,omitempty"`     // s3: key prefix
+	Region    string `json:"region,omitempty"`     // s3 ("" = us-east-1)
+	AccessKey string `json:"access_key,omitempty"` // s3 ("" = AWS_ACCESS_KEY_ID)
+	SecretKey string `json:"secret_key,omitempty"` // s3 ("" = AWS_SECRET_ACCESS_KEY)
+}
+
 // DefaultConfigDir returns the default config directory (project-local .hattiebot if present, else ~/.config/hattiebot).
 func DefaultConfigDir() string {
 	cwd, _ := os.Getwd()
@@ -86,6 +284,27 @@ func New(configDir string) *Config {
 			toolOutputMaxRunes = n
 		}
 	}
+	toolSubsetSize := 16
+	if v := os.Getenv("HATTIEBOT_TOOL_SUBSET_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			toolSubsetSize = n
+		}
+	}
+	var toolCore []string
+	for _, name := range strings.Split(os.Getenv("HATTIEBOT_TOOL_CORE"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			toolCore = append(toolCore, name)
+		}
+	}
+	var confirmTools []string
+	if v := os.Getenv("HATTIEBOT_CONFIRM_TOOLS"); v != "" {
+		confirmTools = []string{}
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" && name != "none" {
+				confirmTools = append(confirmTools, name)
+			}
+		}
+	}
 	embedDim := 768
 	if v := os.Getenv("HATTIEBOT_EMBEDDING_DIMENSION"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && (n == 128 || n == 256 || n == 512 || n == 768) {
@@ -93,6 +312,179 @@ func New(configDir string) *Config {
 		}
 	}
 	defaultCh := os.Getenv("HATTIEBOT_DEFAULT_CHANNEL")
+	groupAddressing := os.Getenv("HATTIEBOT_GROUP_ADDRESSING")
+	if groupAddressing == "" {
+		groupAddressing = "auto"
+	}
+	smtpPort := 587
+	if v := os.Getenv("HATTIEBOT_SMTP_PORT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			smtpPort = n
+		}
+	}
+	auditRetention := 90
+	if v := os.Getenv("HATTIEBOT_AUDIT_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			auditRetention = n
+		}
+	}
+	messageRetention := 0
+	if v := os.Getenv("HATTIEBOT_MESSAGE_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			messageRetention = n
+		}
+	}
+	submindConcurrency := 3
+	if v := os.Getenv("HATTIEBOT_SUBMIND_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			submindConcurrency = n
+		}
+	}
+	webhookWorkers := 4
+	if v := os.Getenv("HATTIEBOT_WEBHOOK_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			webhookWorkers = n
+		}
+	}
+	webhookQueueSize := 100
+	if v := os.Getenv("HATTIEBOT_WEBHOOK_QUEUE_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			webhookQueueSize = n
+		}
+	}
+	var talkClientNames []string
+	for _, n := range strings.Split(os.Getenv("HATTIEBOT_TALK_CLIENT_NAMES"), ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			talkClientNames = append(talkClientNames, n)
+		}
+	}
+	var autocertDomains []string
+	for _, d := range strings.Split(os.Getenv("HATTIEBOT_HTTP_AUTOCERT_DOMAINS"), ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			autocertDomains = append(autocertDomains, d)
+		}
+	}
+	httpReadTimeout := 0
+	if v := os.Getenv("HATTIEBOT_HTTP_READ_TIMEOUT_SEC"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			httpReadTimeout = n
+		}
+	}
+	httpWriteTimeout := 0
+	if v := os.Getenv("HATTIEBOT_HTTP_WRITE_TIMEOUT_SEC"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			httpWriteTimeout = n
+		}
+	}
+	httpMaxHeaderBytes := 0
+	if v := os.Getenv("HATTIEBOT_HTTP_MAX_HEADER_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			httpMaxHeaderBytes = n
+		}
+	}
+	submindProgress := 60
+	if v := os.Getenv("HATTIEBOT_SUBMIND_PROGRESS_SEC"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			submindProgress = n
+		}
+	}
+	queuePartitions := 16
+	if v := os.Getenv("HATTIEBOT_QUEUE_PARTITIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			queuePartitions = n
+		}
+	}
+	contextBudget := 64000
+	if v := os.Getenv("HATTIEBOT_CONTEXT_BUDGET"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			contextBudget = n
+		}
+	}
+	escalationOverdue := 60
+	if v := os.Getenv("HATTIEBOT_ESCALATION_OVERDUE_MIN"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			escalationOverdue = n
+		}
+	}
+	escalationAdminAfter := 30
+	if v := os.Getenv("HATTIEBOT_ESCALATION_ADMIN_AFTER_MIN"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			escalationAdminAfter = n
+		}
+	}
+	escalationUrgentAfter := 60
+	if v := os.Getenv("HATTIEBOT_ESCALATION_URGENT_AFTER_MIN"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			escalationUrgentAfter = n
+		}
+	}
+	toolVersionsKept := 3
+	if v := os.Getenv("HATTIEBOT_TOOL_VERSIONS_KEPT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			toolVersionsKept = n
+		}
+	}
+	schedulerInterval := 60
+	if v := os.Getenv("HATTIEBOT_SCHEDULER_INTERVAL_SEC"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			schedulerInterval = n
+		}
+	}
+	dashboardPort := 0
+	if v := os.Getenv("HATTIEBOT_DASHBOARD_PORT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			dashboardPort = n
+		}
+	}
+	configWatch := 10
+	if v := os.Getenv("HATTIEBOT_CONFIG_WATCH_SEC"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			configWatch = n
+		}
+	}
+	backupInterval := 24
+	if v := os.Getenv("HATTIEBOT_BACKUP_INTERVAL_HOURS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			backupInterval = n
+		}
+	}
+	workspaceSyncInterval := 10
+	if v := os.Getenv("HATTIEBOT_WORKSPACE_SYNC_INTERVAL_MIN"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			workspaceSyncInterval = n
+		}
+	}
+	selfUpdateTestTimeout := 15
+	if v := os.Getenv("HATTIEBOT_SELF_UPDATE_TEST_TIMEOUT_MIN"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			selfUpdateTestTimeout = n
+		}
+	}
+	backupKeep := 7
+	if v := os.Getenv("HATTIEBOT_BACKUP_KEEP"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			backupKeep = n
+		}
+	}
+	creditWarnUSD := []float64{10, 5, 1}
+	if v := os.Getenv("HATTIEBOT_CREDIT_WARN_USD"); v != "" {
+		creditWarnUSD = nil
+		for _, part := range strings.Split(v, ",") {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(part), 64); err == nil && f > 0 {
+				creditWarnUSD = append(creditWarnUSD, f)
+			}
+		}
+	}
+	var otlpHeaders map[string]string
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"); v != "" {
+		otlpHeaders = tracing.ParseHeaders(v)
+	}
+	creditWarnDays := 3.0
+	if v := os.Getenv("HATTIEBOT_CREDIT_WARN_DAYS"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+			creditWarnDays = f
+		}
+	}
 	cfg := &Config{
 		OpenRouterAPIKey:        os.Getenv("OPENROUTER_API_KEY"),
 		Model:                  os.Getenv("HATTIEBOT_MODEL"), // can be overridden by config file
@@ -105,17 +497,118 @@ func New(configDir string) *Config {
 		BinDir:                 filepath.Join(configDir, "bin"),
 		DocsDir:                filepath.Join(cwd, "docs"),
 		ToolOutputMaxRunes:     toolOutputMaxRunes,
+		ToolSubsetSize:         toolSubsetSize,
+		ToolCore:               toolCore,
+		ConfirmTools:           confirmTools,
+		FeedbackMemorize:       os.Getenv("HATTIEBOT_FEEDBACK_MEMORIZE") == "true" || os.Getenv("HATTIEBOT_FEEDBACK_MEMORIZE") == "1",
+		DryRun:                 os.Getenv("HATTIEBOT_DRY_RUN") == "true" || os.Getenv("HATTIEBOT_DRY_RUN") == "1",
+		LogLevel:               os.Getenv("HATTIEBOT_LOG_LEVEL"),
+		LogFormat:              os.Getenv("HATTIEBOT_LOG_FORMAT"),
+		OTLPEndpoint:           os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		OTLPHeaders:            otlpHeaders,
 		EmbeddingServiceURL:    os.Getenv("EMBEDDING_SERVICE_URL"),
 		EmbeddingServiceAPIKey: os.Getenv("EMBEDDING_SERVICE_API_KEY"),
 		EmbeddingDimension:    embedDim,
+		LocalEmbeddings:        os.Getenv("HATTIEBOT_LOCAL_EMBEDDINGS") != "false" && os.Getenv("HATTIEBOT_LOCAL_EMBEDDINGS") != "0",
 		NextcloudURL:              os.Getenv("NEXTCLOUD_URL"),
 		HattieBridgeWebhookSecret: os.Getenv("HATTIEBOT_WEBHOOK_SECRET"),
+		TalkAllowedIPs:            os.Getenv("HATTIEBOT_TALK_ALLOWED_IPS"),
+		TalkClientCAFile:          os.Getenv("HATTIEBOT_TALK_CLIENT_CA"),
+		TalkClientNames:           talkClientNames,
 		NextcloudBotUser:          os.Getenv("NEXTCLOUD_BOT_USER"),
 		NextcloudBotAppPassword: os.Getenv("NEXTCLOUD_BOT_APP_PASSWORD"),
 		DefaultChannel:         defaultCh,
+		SpeechSTTProvider:      os.Getenv("HATTIEBOT_STT_PROVIDER"),
+		SpeechAPIURL:           os.Getenv("HATTIEBOT_SPEECH_API_URL"),
+		SpeechAPIKey:           os.Getenv("HATTIEBOT_SPEECH_API_KEY"),
+		SpeechSTTModel:         os.Getenv("HATTIEBOT_STT_MODEL"),
+		SpeechSTTCommand:       os.Getenv("HATTIEBOT_STT_COMMAND"),
+		SpeechTTSModel:         os.Getenv("HATTIEBOT_TTS_MODEL"),
+		SpeechTTSVoice:         os.Getenv("HATTIEBOT_TTS_VOICE"),
+		SMTPHost:               os.Getenv("HATTIEBOT_SMTP_HOST"),
+		SMTPPort:               smtpPort,
+		SMTPUsername:           os.Getenv("HATTIEBOT_SMTP_USERNAME"),
+		SMTPPasswordSecret:     os.Getenv("HATTIEBOT_SMTP_PASSWORD_SECRET"),
+		SMTPFrom:               os.Getenv("HATTIEBOT_SMTP_FROM"),
+		SMTPTLS:                os.Getenv("HATTIEBOT_SMTP_TLS"),
+		SearchProvider:         os.Getenv("HATTIEBOT_SEARCH_PROVIDER"),
+		SearchURL:              os.Getenv("HATTIEBOT_SEARCH_URL"),
+		SearchAPIKeySecret:     os.Getenv("HATTIEBOT_SEARCH_API_KEY_SECRET"),
+		GitRepoDir:             os.Getenv("HATTIEBOT_GIT_REPO_DIR"),
+		GitRemote:              os.Getenv("HATTIEBOT_GIT_REMOTE"),
+		GitBaseBranch:          os.Getenv("HATTIEBOT_GIT_BASE_BRANCH"),
+		GitForge:               os.Getenv("HATTIEBOT_GIT_FORGE"),
+		GitForgeURL:            os.Getenv("HATTIEBOT_GIT_FORGE_URL"),
+		GitForgeRepo:           os.Getenv("HATTIEBOT_GIT_FORGE_REPO"),
+		GitTokenSecret:         os.Getenv("HATTIEBOT_GIT_TOKEN_SECRET"),
+		SelfUpdateSandbox:        os.Getenv("HATTIEBOT_SELF_UPDATE_SANDBOX"),
+		SelfUpdateTestTimeoutMin: selfUpdateTestTimeout,
+		RestartCommand:           os.Getenv("HATTIEBOT_RESTART_COMMAND"),
+		AuditRetentionDays:     auditRetention,
+		MessageRetentionDays:   messageRetention,
+		MessageRetentionSummarize: os.Getenv("HATTIEBOT_MESSAGE_RETENTION_SUMMARIZE") != "false" && os.Getenv("HATTIEBOT_MESSAGE_RETENTION_SUMMARIZE") != "0",
+		SubmindConcurrency:     submindConcurrency,
+		WebhookWorkers:         webhookWorkers,
+		WebhookQueueSize:       webhookQueueSize,
+		HTTPBindAddr:           os.Getenv("HATTIEBOT_HTTP_BIND_ADDR"),
+		HTTPTLSCertFile:        os.Getenv("HATTIEBOT_HTTP_TLS_CERT"),
+		HTTPTLSKeyFile:         os.Getenv("HATTIEBOT_HTTP_TLS_KEY"),
+		HTTPAutocertDomains:    autocertDomains,
+		HTTPAutocertEmail:      os.Getenv("HATTIEBOT_HTTP_AUTOCERT_EMAIL"),
+		HTTPReadTimeoutSec:     httpReadTimeout,
+		HTTPWriteTimeoutSec:    httpWriteTimeout,
+		HTTPMaxHeaderBytes:     httpMaxHeaderBytes,
+		HTTPTrustedProxies:     os.Getenv("HATTIEBOT_HTTP_TRUSTED_PROXIES"),
+		SubmindProgressSec:     submindProgress,
+		ToolVersionsKept:       toolVersionsKept,
+		ToolAutoRepair:         os.Getenv("HATTIEBOT_TOOL_AUTO_REPAIR") != "false" && os.Getenv("HATTIEBOT_TOOL_AUTO_REPAIR") != "0",
+		CreditWarnUSD:          creditWarnUSD,
+		CreditWarnDays:         creditWarnDays,
+		EscalationOverdueMin:     escalationOverdue,
+		EscalationAdminAfterMin:  escalationAdminAfter,
+		EscalationUrgentAfterMin: escalationUrgentAfter,
+		ThrottleModel:          os.Getenv("HATTIEBOT_THROTTLE_MODEL"),
+		GroupAddressing:        groupAddressing,
+		GroupClassifier:        os.Getenv("HATTIEBOT_GROUP_CLASSIFIER") == "true" || os.Getenv("HATTIEBOT_GROUP_CLASSIFIER") == "1",
+		MonitorSocket:          os.Getenv("HATTIEBOT_MONITOR_SOCKET"),
+		ContextBudget:          contextBudget,
+		OpenRouterBaseURL:      os.Getenv("OPENROUTER_BASE_URL"),
+		SchedulerIntervalSec:   schedulerInterval,
+		QueueURL:               os.Getenv("HATTIEBOT_QUEUE_URL"),
+		QueuePartitions:        queuePartitions,
+		DashboardPort:          dashboardPort,
+		ConfigWatchSec:         configWatch,
+		BackupTarget:           os.Getenv("HATTIEBOT_BACKUP_TARGET"),
+		BackupIntervalHours:    backupInterval,
+		BackupKeep:             backupKeep,
+		BackupS3Region:         os.Getenv("AWS_REGION"),
+		BackupS3Endpoint:       os.Getenv("HATTIEBOT_BACKUP_S3_ENDPOINT"),
+		BackupS3AccessKey:      os.Getenv("AWS_ACCESS_KEY_ID"),
+		BackupS3SecretKey:      os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		WorkspaceSyncFolder:    os.Getenv("HATTIEBOT_WORKSPACE_SYNC_FOLDER"),
+		WorkspaceSyncIntervalMin: workspaceSyncInterval,
+		SecretsFile:            os.Getenv("HATTIEBOT_SECRETS_FILE"),
+		SecretsKeyFile:         os.Getenv("HATTIEBOT_SECRETS_KEY_FILE"),
+		SecretsPassphrase:      os.Getenv("HATTIEBOT_SECRETS_PASSPHRASE"),
+		VaultAddr:              os.Getenv("VAULT_ADDR"),
+		VaultToken:             os.Getenv("VAULT_TOKEN"),
+		VaultRoleID:            os.Getenv("VAULT_ROLE_ID"),
+		VaultSecretID:          os.Getenv("VAULT_SECRET_ID"),
+		VaultMount:             os.Getenv("HATTIEBOT_VAULT_MOUNT"),
+		VaultNamespace:         os.Getenv("VAULT_NAMESPACE"),
 		AdminUserID:            os.Getenv("NEXTCLOUD_ADMIN_USER"),
 	}
 
+	if cfg.SecretsFile == "" {
+		cfg.SecretsFile = filepath.Join(configDir, "secrets.enc")
+	}
+	if cfg.SecretsKeyFile == "" {
+		cfg.SecretsKeyFile = filepath.Join(configDir, "secrets.key")
+	}
+	if cfg.MonitorSocket == "" {
+		cfg.MonitorSocket = filepath.Join(configDir, "monitor.sock")
+	}
+
 	// Priority: Env < Config File.
 	// We load config file (if exists) and OVERWRITE env vars.
 	configPath := filepath.Join(configDir, "config.json")
@@ -0,0 +1,250 @@
+// Package convexport writes conversation history out of HattieBot: as Markdown for reading and
+// archiving, or as JSONL in the chat fine-tuning format (one conversation per line).
+package convexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// Export formats.
+const (
+	FormatMarkdown = "markdown"
+	FormatJSONL    = "jsonl"
+)
+
+// Options selects what to export. Exactly one of ThreadID and UserID is set; UserID exports every
+// thread the user has sent messages in.
+type Options struct {
+	ThreadID string
+	UserID   string
+	Format   string // FormatMarkdown (default) or FormatJSONL
+	// IncludeTools keeps tool calls and tool results; without it only the conversation text is exported.
+	IncludeTools bool
+}
+
+// Stats counts what was exported.
+type Stats struct {
+	Threads  int `json:"threads"`
+	Messages int `json:"messages"`
+}
+
+// Export writes the selected history to w.
+func Export(ctx context.Context, db *store.DB, opts Options, w io.Writer) (Stats, error) {
+	var stats Stats
+	format, err := normalizeFormat(opts.Format)
+	if err != nil {
+		return stats, err
+	}
+	var threads []string
+	switch {
+	case opts.ThreadID != "" && opts.UserID != "":
+		return stats, fmt.Errorf("set either a thread or a user, not both")
+	case opts.ThreadID != "":
+		threads = []string{opts.ThreadID}
+	case opts.UserID != "":
+		if threads, err = db.UserThreadIDs(ctx, opts.UserID); err != nil {
+			return stats, err
+		}
+		if len(threads) == 0 {
+			return stats, fmt.Errorf("no messages from user %q", opts.UserID)
+		}
+	default:
+		return stats, fmt.Errorf("a thread or a user is required")
+	}
+
+	if format == FormatMarkdown {
+		title := "Conversation " + opts.ThreadID
+		if opts.UserID != "" {
+			title = "Conversations with " + opts.UserID
+		}
+		if _, err := fmt.Fprintf(w, "# %s\n\nExported %s UTC.\n", title, time.Now().UTC().Format("2006-01-02 15:04")); err != nil {
+			return stats, err
+		}
+	}
+	for _, thread := range threads {
+		msgs, err := db.ThreadMessages(ctx, thread)
+		if err != nil {
+			return stats, err
+		}
+		msgs = filter(msgs, opts.IncludeTools)
+		summary, err := db.LatestConversationSummary(ctx, thread)
+		if err != nil {
+			return stats, err
+		}
+		if len(msgs) == 0 && summary == nil {
+			if opts.ThreadID != "" {
+				return stats, fmt.Errorf("thread %q not found", thread)
+			}
+			continue
+		}
+		if format == FormatJSONL {
+			if len(msgs) == 0 {
+				continue
+			}
+			err = writeJSONL(w, msgs)
+		} else {
+			err = writeMarkdown(w, thread, msgs, summary)
+		}
+		if err != nil {
+			return stats, err
+		}
+		stats.Threads++
+		stats.Messages += len(msgs)
+	}
+	return stats, nil
+}
+
+func normalizeFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", "md", FormatMarkdown:
+		return FormatMarkdown, nil
+	case "json", FormatJSONL:
+		return FormatJSONL, nil
+	}
+	return "", fmt.Errorf("unknown format %q (use markdown or jsonl)", format)
+}
+
+// filter drops tool traffic unless includeTools, and assistant turns left empty without it.
+func filter(msgs []store.Message, includeTools bool) []store.Message {
+	var out []store.Message
+	for _, m := range msgs {
+		if !includeTools {
+			if m.Role == "tool" || (m.Role == "assistant" && strings.TrimSpace(m.Content) == "") {
+				continue
+			}
+			m.ToolCalls, m.ToolResults = "", ""
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// toolCalls returns the stored tool calls as JSON, or nil when there are none.
+func toolCalls(m store.Message) json.RawMessage {
+	calls := strings.TrimSpace(m.ToolCalls)
+	if calls == "" || calls == "null" || calls == "[]" || !json.Valid([]byte(calls)) {
+		return nil
+	}
+	return json.RawMessage(calls)
+}
+
+type chatMessage struct {
+	Role       string          `json:"role"`
+	Content    string          `json:"content"`
+	Name       string          `json:"name,omitempty"`
+	ToolCalls  json.RawMessage `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// writeJSONL writes one thread as a {"messages": [...]} line. In shared threads each user message
+// carries the sender as name.
+func writeJSONL(w io.Writer, msgs []store.Message) error {
+	senders := map[string]bool{}
+	for _, m := range msgs {
+		if m.Role == "user" {
+			senders[m.SenderID] = true
+		}
+	}
+	line := struct {
+		Messages []chatMessage `json:"messages"`
+	}{}
+	for _, m := range msgs {
+		cm := chatMessage{Role: m.Role, Content: m.Content, ToolCalls: toolCalls(m), ToolCallID: m.ToolCallID}
+		if m.Role == "user" && len(senders) > 1 {
+			cm.Name = jsonlName(m.SenderID)
+		}
+		line.Messages = append(line.Messages, cm)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(line); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// jsonlName makes a sender ID usable as a chat message name (letters, digits, _ and -).
+func jsonlName(senderID string) string {
+	name := strings.Trim(unsafeName.ReplaceAllString(senderID, "_"), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func writeMarkdown(w io.Writer, thread string, msgs []store.Message, summary *store.ConversationSummary) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n## Thread %s\n\n", thread)
+	if len(msgs) > 0 {
+		fmt.Fprintf(&b, "Channel: %s · %d messages · %s – %s UTC\n\n", msgs[0].Channel, len(msgs),
+			msgs[0].CreatedAt.UTC().Format("2006-01-02 15:04"), msgs[len(msgs)-1].CreatedAt.UTC().Format("2006-01-02 15:04"))
+	}
+	if summary != nil {
+		fmt.Fprintf(&b, "> **Summary of %d earlier messages** (deleted under the retention policy, up to %s):\n",
+			summary.MessageCount, summary.CoversUntil.UTC().Format("2006-01-02"))
+		for _, line := range strings.Split(summary.Content, "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		b.WriteString("\n")
+	}
+	for _, m := range msgs {
+		fmt.Fprintf(&b, "### %s (%s) · %s\n\n", m.SenderID, m.Role, m.CreatedAt.UTC().Format("2006-01-02 15:04:05"))
+		if m.Role == "tool" {
+			b.WriteString(fence(m.Content, ""))
+			continue
+		}
+		if content := strings.TrimSpace(m.Content); content != "" {
+			b.WriteString(content + "\n\n")
+		}
+		if calls := toolCalls(m); calls != nil {
+			var parsed []struct {
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			}
+			_ = json.Unmarshal(calls, &parsed)
+			for _, c := range parsed {
+				fmt.Fprintf(&b, "Called `%s`:\n\n", c.Function.Name)
+				b.WriteString(fence(c.Function.Arguments, "json"))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fence wraps s in a code fence longer than any backtick run inside it.
+func fence(s, lang string) string {
+	ticks := "```"
+	for strings.Contains(s, ticks) {
+		ticks += "`"
+	}
+	return ticks + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + ticks + "\n\n"
+}
+
+// FileName suggests a file name for an export made at.
+func FileName(opts Options, at time.Time) string {
+	subject := "thread-" + opts.ThreadID
+	if opts.UserID != "" {
+		subject = "user-" + opts.UserID
+	}
+	ext := ".md"
+	if f, _ := normalizeFormat(opts.Format); f == FormatJSONL {
+		ext = ".jsonl"
+	}
+	return "hattiebot-" + strings.Trim(unsafeName.ReplaceAllString(subject, "_"), "_") + "-" + at.UTC().Format("20060102-150405") + ext
+}
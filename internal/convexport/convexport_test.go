@@ -0,0 +1,110 @@
+package convexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func newTestDB(t *testing.T) *store.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, m := range []struct{ role, content, sender, thread, toolCalls, toolCallID string }{
+		{"user", "what's the weather?", "alice", "dm-alice", "", ""},
+		{"assistant", "", "hattiebot", "dm-alice", `[{"id":"c1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Lisbon\"}"}}]`, ""},
+		{"tool", `{"temp": 21}`, "system", "dm-alice", "", "c1"},
+		{"assistant", "21 °C in Lisbon.", "hattiebot", "dm-alice", "", ""},
+		{"user", "lunch?", "alice", "kitchen", "", ""},
+		{"user", "tacos", "bob@example.com", "kitchen", "", ""},
+		{"assistant", "Tacos it is.", "hattiebot", "kitchen", "", ""},
+		{"user", "hi", "bob@example.com", "dm-bob", "", ""},
+	} {
+		if _, err := db.InsertMessage(ctx, m.role, m.content, "", m.sender, "api", m.thread, m.toolCalls, "", m.toolCallID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestExportJSONL(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	var buf bytes.Buffer
+	stats, err := Export(ctx, db, Options{UserID: "alice", Format: FormatJSONL}, &buf)
+	if err != nil || stats.Threads != 2 || stats.Messages != 5 {
+		t.Fatalf("stats = %+v, %v", stats, err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines = %q", lines)
+	}
+	var first, second struct {
+		Messages []chatMessage `json:"messages"`
+	}
+	json.Unmarshal([]byte(lines[0]), &first)
+	json.Unmarshal([]byte(lines[1]), &second)
+	if len(first.Messages) != 2 || first.Messages[1].Content != "21 °C in Lisbon." || first.Messages[0].Name != "" {
+		t.Errorf("dm thread = %+v", first.Messages)
+	}
+	if len(second.Messages) != 3 || second.Messages[1].Name != "bob_example_com" {
+		t.Errorf("shared thread = %+v", second.Messages)
+	}
+
+	buf.Reset()
+	stats, err = Export(ctx, db, Options{ThreadID: "dm-alice", Format: "jsonl", IncludeTools: true}, &buf)
+	if err != nil || stats.Messages != 4 {
+		t.Fatalf("with tools: %+v, %v", stats, err)
+	}
+	if !strings.Contains(buf.String(), `"tool_calls":[{"id":"c1"`) || !strings.Contains(buf.String(), `"tool_call_id":"c1"`) {
+		t.Errorf("tool traffic missing: %s", buf.String())
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	if _, err := db.ReplaceMessagesWithSummary(ctx, store.ConversationSummary{ThreadID: "kitchen", Participants: []string{"alice"}, Content: "Agreed on pizza last week.", CoversUntil: time.Now().Add(-48 * time.Hour), MessageCount: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := Export(ctx, db, Options{ThreadID: "dm-alice", IncludeTools: true}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"# Conversation dm-alice", "### alice (user)", "Called `get_weather`:", "```json\n{\"city\":\"Lisbon\"}\n```", "### system (tool)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown lacks %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	if _, err := Export(ctx, db, Options{ThreadID: "kitchen"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "> Agreed on pizza last week.") {
+		t.Errorf("summary missing:\n%s", buf.String())
+	}
+
+	if _, err := Export(ctx, db, Options{ThreadID: "nope"}, &buf); err == nil {
+		t.Error("exporting a missing thread succeeded")
+	}
+	if _, err := Export(ctx, db, Options{ThreadID: "kitchen", Format: "csv"}, &buf); err == nil {
+		t.Error("unknown format accepted")
+	}
+	if name := FileName(Options{UserID: "bob@example.com", Format: FormatJSONL}, time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)); name != "hattiebot-user-bob_example_com-20261017-093000.jsonl" {
+		t.Errorf("FileName = %q", name)
+	}
+}
@@ -0,0 +1,286 @@
+// Package convimport parses conversation exports from other assistants (ChatGPT, Claude)
+// so they can be imported into HattieBot's history and memory.
+package convimport
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Export sources.
+const (
+	SourceChatGPT = "chatgpt"
+	SourceClaude  = "claude"
+)
+
+// Message is one user or assistant turn of an imported conversation.
+type Message struct {
+	Role      string // "user" or "assistant"
+	Content   string
+	CreatedAt time.Time // zero when the export has no timestamp
+}
+
+// Conversation is one imported conversation, oldest message first.
+type Conversation struct {
+	ID        string
+	Title     string
+	Source    string
+	CreatedAt time.Time
+	Messages  []Message
+}
+
+// maxExportSize caps how much of conversations.json is read (exports of heavy users run to hundreds of MB).
+const maxExportSize = 512 << 20
+
+// Load reads an export: the .zip archive as downloaded, an extracted directory, or conversations.json itself.
+// source is "chatgpt", "claude", or "" to detect the format. Conversations are returned oldest first.
+func Load(path, source string) ([]Conversation, error) {
+	data, err := readConversationsJSON(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, source)
+}
+
+// Parse decodes the contents of an export's conversations.json.
+func Parse(data []byte, source string) ([]Conversation, error) {
+	if source == "" {
+		source = detect(data)
+	}
+	var convs []Conversation
+	var err error
+	switch source {
+	case SourceChatGPT:
+		convs, err = parseChatGPT(data)
+	case SourceClaude:
+		convs, err = parseClaude(data)
+	default:
+		return nil, fmt.Errorf("unrecognized export format (expected a ChatGPT or Claude conversations.json)")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s export: %w", source, err)
+	}
+	sort.SliceStable(convs, func(i, j int) bool { return convs[i].CreatedAt.Before(convs[j].CreatedAt) })
+	return convs, nil
+}
+
+func readConversationsJSON(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		path = filepath.Join(path, "conversations.json")
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".zip") {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(io.LimitReader(f, maxExportSize))
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if filepath.Base(f.Name) != "conversations.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxExportSize))
+	}
+	return nil, fmt.Errorf("%s: no conversations.json in archive", filepath.Base(path))
+}
+
+// detect tells the formats apart by their distinctive keys: ChatGPT conversations have a
+// "mapping" tree, Claude conversations a flat "chat_messages" list.
+func detect(data []byte) string {
+	var probe []map[string]json.RawMessage
+	if json.Unmarshal(data, &probe) != nil || len(probe) == 0 {
+		return ""
+	}
+	if _, ok := probe[0]["mapping"]; ok {
+		return SourceChatGPT
+	}
+	if _, ok := probe[0]["chat_messages"]; ok {
+		return SourceClaude
+	}
+	return ""
+}
+
+type chatGPTConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Mapping        map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Parent  string `json:"parent"`
+	Message *struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		CreateTime float64 `json:"create_time"`
+		Content    struct {
+			ContentType string            `json:"content_type"`
+			Parts       []json.RawMessage `json:"parts"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// parseChatGPT follows each conversation from current_node back to the root, so only the branch
+// the user last saw is imported (regenerated and edited alternatives are skipped).
+func parseChatGPT(data []byte) ([]Conversation, error) {
+	var raw []chatGPTConversation
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	var out []Conversation
+	for _, rc := range raw {
+		conv := Conversation{ID: rc.ID, Title: rc.Title, Source: SourceChatGPT, CreatedAt: unixTime(rc.CreateTime)}
+		if conv.ID == "" {
+			conv.ID = rc.ConversationID
+		}
+		var branch []Message
+		seen := map[string]bool{}
+		for id := rc.CurrentNode; id != "" && !seen[id]; id = rc.Mapping[id].Parent {
+			seen[id] = true
+			node := rc.Mapping[id]
+			if node.Message == nil {
+				continue
+			}
+			role := node.Message.Author.Role
+			if role != "user" && role != "assistant" {
+				continue
+			}
+			if ct := node.Message.Content.ContentType; ct != "" && ct != "text" && ct != "multimodal_text" {
+				continue // code interpreter input, browsing results, etc.
+			}
+			text := strings.TrimSpace(joinTextParts(node.Message.Content.Parts))
+			if text == "" {
+				continue
+			}
+			branch = append(branch, Message{Role: role, Content: text, CreatedAt: unixTime(node.Message.CreateTime)})
+		}
+		for i := len(branch) - 1; i >= 0; i-- {
+			conv.Messages = append(conv.Messages, branch[i])
+		}
+		if len(conv.Messages) > 0 {
+			out = append(out, conv)
+		}
+	}
+	return out, nil
+}
+
+// joinTextParts keeps string parts; image and file parts (objects) are dropped.
+func joinTextParts(parts []json.RawMessage) string {
+	var texts []string
+	for _, p := range parts {
+		var s string
+		if json.Unmarshal(p, &s) == nil && s != "" {
+			texts = append(texts, s)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func unixTime(sec float64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(sec), int64((sec-float64(int64(sec)))*1e9)).UTC()
+}
+
+type claudeConversation struct {
+	UUID         string `json:"uuid"`
+	Name         string `json:"name"`
+	CreatedAt    string `json:"created_at"`
+	ChatMessages []struct {
+		Sender    string `json:"sender"`
+		Text      string `json:"text"`
+		CreatedAt string `json:"created_at"`
+		Content   []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"chat_messages"`
+}
+
+func parseClaude(data []byte) ([]Conversation, error) {
+	var raw []claudeConversation
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	var out []Conversation
+	for _, rc := range raw {
+		conv := Conversation{ID: rc.UUID, Title: rc.Name, Source: SourceClaude, CreatedAt: parseTime(rc.CreatedAt)}
+		for _, m := range rc.ChatMessages {
+			role := m.Sender
+			if role == "human" {
+				role = "user"
+			}
+			if role != "user" && role != "assistant" {
+				continue
+			}
+			text := m.Text
+			if text == "" {
+				var texts []string
+				for _, c := range m.Content {
+					if c.Type == "text" && c.Text != "" {
+						texts = append(texts, c.Text)
+					}
+				}
+				text = strings.Join(texts, "\n")
+			}
+			if text = strings.TrimSpace(text); text == "" {
+				continue
+			}
+			conv.Messages = append(conv.Messages, Message{Role: role, Content: text, CreatedAt: parseTime(m.CreatedAt)})
+		}
+		if len(conv.Messages) > 0 {
+			out = append(out, conv)
+		}
+	}
+	return out, nil
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// Transcript renders a conversation as plain "role: text" lines, truncated to maxChars
+// (0 = no limit); used as input when distilling memories.
+func (c Conversation) Transcript(maxChars int) string {
+	var sb strings.Builder
+	if c.Title != "" {
+		fmt.Fprintf(&sb, "Title: %s\n\n", c.Title)
+	}
+	for _, m := range c.Messages {
+		fmt.Fprintf(&sb, "%s: %s\n\n", m.Role, m.Content)
+		if maxChars > 0 && sb.Len() >= maxChars {
+			return strings.ToValidUTF8(sb.String()[:maxChars], "") + "\n[truncated]"
+		}
+	}
+	return sb.String()
+}
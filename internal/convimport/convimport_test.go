@@ -0,0 +1,86 @@
+package convimport
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const chatGPTExport = `[{
+  "id": "c1", "title": "Trip planning", "create_time": 1700000000.5, "current_node": "n4",
+  "mapping": {
+    "root": {"parent": null, "message": null},
+    "n1": {"parent": "root", "message": {"author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]}}},
+    "n2": {"parent": "n1", "message": {"author": {"role": "user"}, "create_time": 1700000001, "content": {"content_type": "text", "parts": ["Plan a trip to Lisbon"]}}},
+    "n3old": {"parent": "n2", "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["discarded draft"]}}},
+    "n3": {"parent": "n2", "message": {"author": {"role": "assistant"}, "create_time": 1700000002, "content": {"content_type": "text", "parts": ["Day 1: Alfama", {"asset_pointer": "file-1"}]}}},
+    "n4": {"parent": "n3", "message": {"author": {"role": "tool"}, "content": {"content_type": "text", "parts": ["ignored"]}}}
+  }
+}]`
+
+const claudeExport = `[{
+  "uuid": "u1", "name": "Go generics", "created_at": "2024-03-01T10:00:00.000000Z",
+  "chat_messages": [
+    {"sender": "human", "text": "How do constraints work?", "created_at": "2024-03-01T10:00:01Z"},
+    {"sender": "assistant", "text": "", "content": [{"type": "text", "text": "They are interfaces."}, {"type": "tool_use"}], "created_at": "2024-03-01T10:00:05Z"}
+  ]
+}, {"uuid": "empty", "name": "", "created_at": "2023-01-01T00:00:00Z", "chat_messages": []}]`
+
+func TestParseChatGPTFollowsCurrentBranch(t *testing.T) {
+	convs, err := Parse([]byte(chatGPTExport), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 1 || convs[0].Source != SourceChatGPT || convs[0].Title != "Trip planning" {
+		t.Fatalf("unexpected conversations: %+v", convs)
+	}
+	msgs := convs[0].Messages
+	if len(msgs) != 2 || msgs[0].Role != "user" || msgs[1].Content != "Day 1: Alfama" {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+	if msgs[0].CreatedAt.Unix() != 1700000001 {
+		t.Errorf("timestamp not kept: %v", msgs[0].CreatedAt)
+	}
+}
+
+func TestParseClaudeSkipsEmptyConversations(t *testing.T) {
+	convs, err := Parse([]byte(claudeExport), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 1 || convs[0].Source != SourceClaude {
+		t.Fatalf("unexpected conversations: %+v", convs)
+	}
+	msgs := convs[0].Messages
+	if len(msgs) != 2 || msgs[0].Role != "user" || msgs[1].Content != "They are interfaces." {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+}
+
+func TestParseRejectsUnknownFormat(t *testing.T) {
+	if _, err := Parse([]byte(`[{"foo": 1}]`), ""); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestLoadZipArchive(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "export.zip")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("data-2024/conversations.json")
+	w.Write([]byte(claudeExport))
+	zw.Close()
+	f.Close()
+
+	convs, err := Load(p, SourceClaude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 1 || convs[0].ID != "u1" {
+		t.Fatalf("unexpected conversations: %+v", convs)
+	}
+}
@@ -15,6 +15,44 @@ type LLMClient interface {
 // embedType is "document" or "query" for providers that distinguish them (e.g. EmbeddingGood).
 type EmbeddingClient interface {
 	Embed(ctx context.Context, text string, embedType string) ([]float32, error)
+	// EmbedBatch embeds several texts, in as few requests as the provider allows, and returns one
+	// vector per text in the same order.
+	EmbedBatch(ctx context.Context, texts []string, embedType string) ([][]float32, error)
+}
+
+// LLMBatchEmbedder is implemented by LLM clients that can embed several texts in one request.
+type LLMBatchEmbedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// LLMEmbedBatch embeds texts with c: in one call when c is an LLMBatchEmbedder, else one by one.
+func LLMEmbedBatch(ctx context.Context, c LLMClient, texts []string) ([][]float32, error) {
+	if b, ok := c.(LLMBatchEmbedder); ok {
+		return b.EmbedBatch(ctx, texts)
+	}
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v, err := c.Embed(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// EmbeddingModeler is implemented by embedding clients that can name the model behind their
+// vectors. Vectors of different models are not comparable, so memories record it.
+type EmbeddingModeler interface {
+	EmbeddingModel() string
+}
+
+// EmbeddingModel names c's model, or returns "" when c cannot tell.
+func EmbeddingModel(c EmbeddingClient) string {
+	if m, ok := c.(EmbeddingModeler); ok {
+		return m.EmbeddingModel()
+	}
+	return ""
 }
 
 // ContextSelector decides which messages from history to include in the prompt.
@@ -35,6 +73,9 @@ type SubMindConfig struct {
 	AllowedTools []string `json:"allowed_tools"`
 	MaxTurns     int      `json:"max_turns"`
 	Protected    bool     `json:"protected"` // Cannot be deleted by agent
+	// OutputSchema, when set, makes the final answer a JSON value matching this JSON Schema: it is
+	// requested as structured output and checked before the run completes.
+	OutputSchema map[string]interface{} `json:"output_schema,omitempty"`
 }
 
 // SubMindResult is the output of a sub-mind execution.
@@ -45,6 +86,9 @@ type SubMindResult struct {
 	Turns     int    `json:"turns"`     // How many iterations ran
 	Truncated bool   `json:"truncated"` // Hit MaxTurns limit
 	SessionID int64  `json:"session_id,omitempty"` // Set for new sessions so caller can resume later
+	// AwaitingInput: the session is paused on Question (ask_user); the user's answer resumes it.
+	AwaitingInput bool   `json:"awaiting_input,omitempty"`
+	Question      string `json:"question,omitempty"`
 }
 
 // SubmindSpawner spawns isolated LLM contexts for focused tasks.
@@ -53,6 +97,14 @@ type SubmindSpawner interface {
 	SpawnSubmind(ctx context.Context, userID, mode, task string, sessionID int64) (SubMindResult, error)
 }
 
+// AsyncSubmindSpawner runs sub-minds in the background instead of blocking the caller.
+type AsyncSubmindSpawner interface {
+	// StartSubmind queues a new session and returns its ID without waiting for it.
+	StartSubmind(ctx context.Context, userID, mode, task string) (int64, error)
+	// CancelSubmind stops one of userID's background sessions; false when it is not running.
+	CancelSubmind(userID string, sessionID int64) bool
+}
+
 // SubmindRegistry manages sub-mind configurations.
 type SubmindRegistry interface {
 	Get(name string) (SubMindConfig, bool)
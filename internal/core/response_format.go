@@ -0,0 +1,83 @@
+package core
+
+import "context"
+
+// ResponseFormat asks the model for structured output (the OpenAI-compatible response_format
+// field): Type "json_object" for any JSON object, or "json_schema" with JSONSchema.
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat is the schema of a "json_schema" response format. With Strict the provider
+// constrains decoding to the schema, which requires every object in it to list all its
+// properties as required and to set additionalProperties to false.
+type JSONSchemaFormat struct {
+	Name   string                 `json:"name"`
+	Strict bool                   `json:"strict,omitempty"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+type responseFormatKey struct{}
+
+// WithResponseFormat returns a context whose LLM calls request rf. Like usage recording it
+// travels on the context, so routers and fallback clients pass it on unchanged.
+func WithResponseFormat(ctx context.Context, rf *ResponseFormat) context.Context {
+	return context.WithValue(ctx, responseFormatKey{}, rf)
+}
+
+// ResponseFormatFrom returns the response format attached to ctx, or nil. LLM clients that
+// support structured output send it with each completion.
+func ResponseFormatFrom(ctx context.Context) *ResponseFormat {
+	rf, _ := ctx.Value(responseFormatKey{}).(*ResponseFormat)
+	return rf
+}
+
+// SchemaResponseFormat builds a "json_schema" response format for schema, strict when the
+// schema meets the strict-mode rules.
+func SchemaResponseFormat(name string, schema map[string]interface{}) *ResponseFormat {
+	return &ResponseFormat{Type: "json_schema", JSONSchema: &JSONSchemaFormat{Name: name, Strict: strictCompatible(schema), Schema: schema}}
+}
+
+// strictCompatible reports whether every object schema in s closes its properties and
+// requires all of them.
+func strictCompatible(s interface{}) bool {
+	switch v := s.(type) {
+	case map[string]interface{}:
+		if props, ok := v["properties"].(map[string]interface{}); ok || v["type"] == "object" {
+			if ap, ok := v["additionalProperties"].(bool); !ok || ap {
+				return false
+			}
+			required := map[string]bool{}
+			switch r := v["required"].(type) {
+			case []interface{}:
+				for _, n := range r {
+					if name, ok := n.(string); ok {
+						required[name] = true
+					}
+				}
+			case []string:
+				for _, name := range r {
+					required[name] = true
+				}
+			}
+			for name := range props {
+				if !required[name] {
+					return false
+				}
+			}
+		}
+		for _, child := range v {
+			if !strictCompatible(child) {
+				return false
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if !strictCompatible(child) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -22,7 +22,7 @@ type ToolCall struct {
 type ToolDefinition struct {
 	Type     string       `json:"type"`
 	Function FunctionSpec `json:"function"`
-	Policy   string       `json:"policy,omitempty"` // "safe", "restricted", "admin_only"
+	Policy   string       `json:"policy,omitempty"` // "safe", "restricted", "operator", "admin_only", "owner_only"
 }
 
 // FunctionSpec describes the function signature.
@@ -0,0 +1,29 @@
+package core
+
+import "context"
+
+// Usage is token and cost accounting for a single LLM call.
+type Usage struct {
+	Model            string  `json:"model"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"` // As reported by the provider; 0 when unknown
+}
+
+// UsageRecorder receives usage for every LLM call made with a context that carries it.
+type UsageRecorder func(ctx context.Context, u Usage)
+
+type usageRecorderKey struct{}
+
+// WithUsageRecorder returns a context whose LLM calls report usage to rec.
+// The caller decides attribution (user, job, plan) by closing over it in rec.
+func WithUsageRecorder(ctx context.Context, rec UsageRecorder) context.Context {
+	return context.WithValue(ctx, usageRecorderKey{}, rec)
+}
+
+// RecordUsage reports u to the recorder attached to ctx, if any. LLM clients call this after each completion.
+func RecordUsage(ctx context.Context, u Usage) {
+	if rec, ok := ctx.Value(usageRecorderKey{}).(UsageRecorder); ok && rec != nil {
+		rec(ctx, u)
+	}
+}
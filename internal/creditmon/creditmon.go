@@ -0,0 +1,409 @@
+// Package creditmon watches the OpenRouter account so the bot does not silently stop when the
+// credits run out. It polls the key and credit endpoints, tracks prices of the models in use,
+// forecasts when the balance runs dry from recent spend, warns the admin as the balance crosses
+// configured thresholds, and sends a weekly spend digest with the forecast.
+package creditmon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/health"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// Defaults for New.
+var DefaultThresholds = []float64{10, 5, 1}
+
+const (
+	DefaultWarnDays      = 3.0
+	DefaultInterval      = time.Hour
+	forecastWindow       = 7 * 24 * time.Hour
+	digestInterval       = 7 * 24 * time.Hour
+	pricingRefresh       = 24 * time.Hour
+	forecastWarnInterval = 24 * time.Hour
+	stateFile            = "credit_monitor.json"
+)
+
+// Source is the account API (implemented by *openrouter.Client).
+type Source interface {
+	KeyInfo(ctx context.Context) (*openrouter.KeyInfo, error)
+	Credits(ctx context.Context) (*openrouter.AccountCredits, error)
+	ModelPricing(ctx context.Context) (map[string]openrouter.ModelPrice, error)
+}
+
+// UsageSource summarizes recorded LLM spend (implemented by *store.DB).
+type UsageSource interface {
+	SummarizeUsage(ctx context.Context, groupBy string, since time.Time) ([]store.UsageSummary, error)
+}
+
+// Forecast projects spend from the last week of recorded usage.
+type Forecast struct {
+	WindowDays    int        `json:"window_days"`
+	SpentUSD      float64    `json:"spent_usd"`
+	DailyBurnUSD  float64    `json:"daily_burn_usd"`
+	DaysLeft      *float64   `json:"days_left,omitempty"` // nil when nothing is spent or no balance is known
+	EmptyBy       *time.Time `json:"empty_by,omitempty"`
+	Next30DaysUSD float64    `json:"next_30_days_usd"`
+}
+
+// Status is the last check, for system_status and the digest.
+type Status struct {
+	CheckedAt      time.Time                        `json:"checked_at"`
+	Error          string                           `json:"error,omitempty"`
+	RemainingUSD   *float64                         `json:"remaining_usd,omitempty"` // lower of key limit and account balance
+	AccountUSD     *float64                         `json:"account_balance_usd,omitempty"`
+	KeyLimitUSD    *float64                         `json:"key_limit_usd,omitempty"`
+	KeyUsageUSD    float64                          `json:"key_usage_usd"`
+	FreeTier       bool                             `json:"free_tier,omitempty"`
+	Forecast       Forecast                         `json:"forecast"`
+	SpendByModel   []store.UsageSummary             `json:"spend_by_model,omitempty"`
+	Pricing        map[string]openrouter.ModelPrice `json:"pricing,omitempty"` // USD per token for the models in use
+	WarnedBelowUSD float64                          `json:"warned_below_usd,omitempty"`
+}
+
+// state survives restarts so warnings and digests are not repeated.
+type state struct {
+	WarnedBelowUSD   float64   `json:"warned_below_usd,omitempty"`
+	ForecastWarnedAt time.Time `json:"forecast_warned_at,omitempty"`
+	LastDigestAt     time.Time `json:"last_digest_at,omitempty"`
+}
+
+// Monitor polls the account. Notify receives warnings and digests for the admin.
+type Monitor struct {
+	Source     Source
+	Usage      UsageSource
+	Notify     func(msg string)
+	Thresholds []float64 // remaining USD levels that trigger a warning, any order
+	WarnDays   float64   // warn when the forecast says credits run out sooner (0 = off)
+	Models     []string  // models whose prices are always tracked (others are added once they have spend)
+
+	configDir string
+	now       func() time.Time
+
+	mu       sync.Mutex
+	status   *Status
+	state    state
+	prices   map[string]openrouter.ModelPrice
+	pricedAt time.Time
+}
+
+// New returns a monitor with the default thresholds. State is kept in configDir ("" = in memory).
+func New(source Source, usage UsageSource, configDir string) *Monitor {
+	m := &Monitor{
+		Source:     source,
+		Usage:      usage,
+		Thresholds: DefaultThresholds,
+		WarnDays:   DefaultWarnDays,
+		configDir:  configDir,
+		now:        time.Now,
+	}
+	m.loadState()
+	return m
+}
+
+// Start checks now and then every interval, sending the weekly digest when it is due.
+func (m *Monitor) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := m.Check(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: credit monitor: %v\n", err)
+			}
+			if m.digestDue() {
+				m.notify(m.Digest(ctx))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Status returns the last check (nil before the first one).
+func (m *Monitor) Status() *Status {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.status == nil {
+		return nil
+	}
+	st := *m.status
+	return &st
+}
+
+// Check polls the account, updates the forecast, and sends any warnings that are due.
+func (m *Monitor) Check(ctx context.Context) (*Status, error) {
+	now := m.now()
+	st := &Status{CheckedAt: now}
+
+	key, keyErr := m.Source.KeyInfo(ctx)
+	credits, creditsErr := m.Source.Credits(ctx)
+	if keyErr != nil && creditsErr != nil {
+		st.Error = keyErr.Error()
+		m.mu.Lock()
+		st.WarnedBelowUSD = m.state.WarnedBelowUSD
+		m.status = st
+		m.mu.Unlock()
+		return st, keyErr
+	}
+	if key != nil {
+		st.KeyUsageUSD, st.KeyLimitUSD, st.FreeTier = key.Usage, key.Limit, key.IsFreeTier
+		if key.LimitRemaining != nil {
+			st.RemainingUSD = floatPtr(*key.LimitRemaining)
+		}
+	}
+	if credits != nil {
+		balance := credits.TotalCredits - credits.TotalUsage
+		st.AccountUSD = &balance
+		if st.RemainingUSD == nil || balance < *st.RemainingUSD {
+			st.RemainingUSD = floatPtr(balance)
+		}
+	}
+
+	if m.Usage != nil {
+		spend, err := m.Usage.SummarizeUsage(ctx, "model", now.Add(-forecastWindow))
+		if err == nil {
+			st.SpendByModel = spend
+		}
+	}
+	st.Forecast = forecast(st.SpendByModel, st.RemainingUSD, now)
+	st.Pricing = m.pricing(ctx, st.SpendByModel, now)
+
+	m.mu.Lock()
+	warnings := m.warnings(st, now)
+	st.WarnedBelowUSD = m.state.WarnedBelowUSD
+	m.status = st
+	m.mu.Unlock()
+	m.saveState()
+	for _, w := range warnings {
+		m.notify(w)
+	}
+	return st, nil
+}
+
+// forecast projects the last week's spend forward.
+func forecast(spend []store.UsageSummary, remaining *float64, now time.Time) Forecast {
+	f := Forecast{WindowDays: int(forecastWindow / (24 * time.Hour))}
+	for _, s := range spend {
+		f.SpentUSD += s.CostUSD
+	}
+	f.DailyBurnUSD = f.SpentUSD / float64(f.WindowDays)
+	f.Next30DaysUSD = f.DailyBurnUSD * 30
+	if remaining != nil && f.DailyBurnUSD > 0 {
+		days := math.Max(*remaining, 0) / f.DailyBurnUSD
+		f.DaysLeft = &days
+		empty := now.Add(time.Duration(days * float64(24*time.Hour)))
+		f.EmptyBy = &empty
+	}
+	return f
+}
+
+// warnings updates the warning state for a check and returns the messages to send. Balance
+// warnings go out once per threshold crossed; a top-up above the last warned level re-arms them.
+func (m *Monitor) warnings(st *Status, now time.Time) []string {
+	var out []string
+	if st.RemainingUSD != nil {
+		remaining := *st.RemainingUSD
+		crossed := 0.0
+		for _, t := range m.Thresholds {
+			if remaining < t && (crossed == 0 || t < crossed) {
+				crossed = t
+			}
+		}
+		if m.state.WarnedBelowUSD > 0 && (crossed == 0 || crossed > m.state.WarnedBelowUSD) {
+			// Topped up: re-arm the thresholds above the new balance without warning again
+			m.state.WarnedBelowUSD = crossed
+		}
+		if crossed > 0 && (m.state.WarnedBelowUSD == 0 || crossed < m.state.WarnedBelowUSD) {
+			m.state.WarnedBelowUSD = crossed
+			msg := fmt.Sprintf("[Credits] OpenRouter balance is $%.2f, below $%.2f.", remaining, crossed)
+			if remaining <= 0 {
+				msg = "[Credits] OpenRouter credits are used up: model calls will fail until the account is topped up."
+			} else if d := st.Forecast.DaysLeft; d != nil {
+				msg += fmt.Sprintf(" At ~$%.2f/day it runs out in about %s.", st.Forecast.DailyBurnUSD, formatDays(*d))
+			}
+			out = append(out, msg+" Top up at https://openrouter.ai/settings/credits.")
+		}
+	}
+	if d := st.Forecast.DaysLeft; d != nil && m.WarnDays > 0 && *d < m.WarnDays && *st.RemainingUSD > 0 && len(out) == 0 &&
+		now.Sub(m.state.ForecastWarnedAt) >= forecastWarnInterval {
+		m.state.ForecastWarnedAt = now
+		out = append(out, fmt.Sprintf("[Credits] At the current ~$%.2f/day, the $%.2f OpenRouter balance runs out in about %s (%s).",
+			st.Forecast.DailyBurnUSD, *st.RemainingUSD, formatDays(*d), st.Forecast.EmptyBy.Format("Mon Jan 2 15:04")))
+	}
+	return out
+}
+
+// pricing returns prices for the tracked models and the models with spend, refreshing the price
+// list at most once a day.
+func (m *Monitor) pricing(ctx context.Context, spend []store.UsageSummary, now time.Time) map[string]openrouter.ModelPrice {
+	m.mu.Lock()
+	stale := m.prices == nil || now.Sub(m.pricedAt) >= pricingRefresh
+	m.mu.Unlock()
+	if stale {
+		if prices, err := m.Source.ModelPricing(ctx); err == nil {
+			m.mu.Lock()
+			m.prices, m.pricedAt = prices, now
+			m.mu.Unlock()
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.prices == nil {
+		return nil
+	}
+	out := map[string]openrouter.ModelPrice{}
+	for _, model := range m.Models {
+		if p, ok := m.prices[model]; ok {
+			out[model] = p
+		}
+	}
+	for _, s := range spend {
+		if p, ok := m.prices[s.Key]; ok {
+			out[s.Key] = p
+		}
+	}
+	return out
+}
+
+// Digest renders the weekly spend summary with the forecast and records that it was sent.
+func (m *Monitor) Digest(ctx context.Context) string {
+	st := m.Status()
+	if st == nil {
+		var err error
+		if st, err = m.Check(ctx); st == nil {
+			return "[Weekly digest] Credit status unavailable: " + err.Error()
+		}
+	}
+	var b strings.Builder
+	f := st.Forecast
+	calls := 0
+	for _, s := range st.SpendByModel {
+		calls += s.Calls
+	}
+	fmt.Fprintf(&b, "[Weekly digest] LLM spend over the last %d days: $%.2f in %d calls.", f.WindowDays, f.SpentUSD, calls)
+	models := append([]store.UsageSummary(nil), st.SpendByModel...)
+	sort.SliceStable(models, func(i, j int) bool { return models[i].CostUSD > models[j].CostUSD })
+	for i, s := range models {
+		if i == 5 {
+			break
+		}
+		fmt.Fprintf(&b, "\n- %s: $%.2f (%d calls)", s.Key, s.CostUSD, s.Calls)
+		if p, ok := st.Pricing[s.Key]; ok {
+			fmt.Fprintf(&b, ", $%.2f/$%.2f per 1M prompt/completion tokens", p.Prompt*1e6, p.Completion*1e6)
+		}
+	}
+	switch {
+	case st.RemainingUSD == nil && st.Error != "":
+		fmt.Fprintf(&b, "\nCredits: could not be checked (%s).", st.Error)
+	case st.RemainingUSD == nil:
+		b.WriteString("\nCredits: no limit reported for this key.")
+	default:
+		fmt.Fprintf(&b, "\nCredits remaining: $%.2f.", *st.RemainingUSD)
+	}
+	fmt.Fprintf(&b, "\nForecast: ~$%.2f/day, about $%.2f over the next 30 days", f.DailyBurnUSD, f.Next30DaysUSD)
+	if f.DaysLeft != nil {
+		fmt.Fprintf(&b, "; credits run out in about %s (%s)", formatDays(*f.DaysLeft), f.EmptyBy.Format("Mon Jan 2"))
+	}
+	b.WriteString(".")
+
+	m.mu.Lock()
+	m.state.LastDigestAt = m.now()
+	m.mu.Unlock()
+	m.saveState()
+	return b.String()
+}
+
+// digestDue reports whether a week has passed since the last digest. The first digest goes out a
+// week after the monitor first runs.
+func (m *Monitor) digestDue() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state.LastDigestAt.IsZero() {
+		m.state.LastDigestAt = m.now()
+		go m.saveState()
+		return false
+	}
+	return m.now().Sub(m.state.LastDigestAt) >= digestInterval
+}
+
+// HealthCheck reports the credit state as a component for system_status.
+func (m *Monitor) HealthCheck() health.ComponentHealth {
+	h := health.ComponentHealth{Name: "credits", Status: "unknown", Message: "not checked yet"}
+	st := m.Status()
+	if st == nil {
+		return h
+	}
+	h.Status, h.Message, h.LastOK = "ok", "", st.CheckedAt
+	switch {
+	case st.Error != "":
+		h.Status, h.Message, h.LastOK, h.LastError = "degraded", st.Error, time.Time{}, st.CheckedAt
+	case st.RemainingUSD == nil:
+		h.Message = "no credit limit reported"
+	case *st.RemainingUSD <= 0:
+		h.Status, h.Message = "error", "credits used up"
+	case st.WarnedBelowUSD > 0:
+		h.Status, h.Message = "degraded", fmt.Sprintf("$%.2f left (below $%.2f)", *st.RemainingUSD, st.WarnedBelowUSD)
+	case st.Forecast.DaysLeft != nil && m.WarnDays > 0 && *st.Forecast.DaysLeft < m.WarnDays:
+		h.Status, h.Message = "degraded", fmt.Sprintf("$%.2f left, runs out in about %s", *st.RemainingUSD, formatDays(*st.Forecast.DaysLeft))
+	default:
+		h.Message = fmt.Sprintf("$%.2f left", *st.RemainingUSD)
+	}
+	return h
+}
+
+func (m *Monitor) notify(msg string) {
+	if m.Notify != nil && msg != "" {
+		m.Notify(msg)
+	}
+}
+
+func (m *Monitor) loadState() {
+	if m.configDir == "" {
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(m.configDir, stateFile))
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &m.state)
+}
+
+func (m *Monitor) saveState() {
+	if m.configDir == "" {
+		return
+	}
+	m.mu.Lock()
+	data, _ := json.MarshalIndent(m.state, "", "  ")
+	m.mu.Unlock()
+	if err := os.WriteFile(filepath.Join(m.configDir, stateFile), data, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: credit monitor state: %v\n", err)
+	}
+}
+
+func formatDays(d float64) string {
+	if d < 1 {
+		return fmt.Sprintf("%.0f hours", math.Max(d*24, 1))
+	}
+	if d < 1.5 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%.0f days", d)
+}
+
+func floatPtr(f float64) *float64 { return &f }
@@ -0,0 +1,161 @@
+package creditmon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+type fakeSource struct {
+	balance float64
+	err     error
+}
+
+func (f *fakeSource) KeyInfo(ctx context.Context) (*openrouter.KeyInfo, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &openrouter.KeyInfo{Usage: 40}, nil
+}
+
+func (f *fakeSource) Credits(ctx context.Context) (*openrouter.AccountCredits, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &openrouter.AccountCredits{TotalCredits: 40 + f.balance, TotalUsage: 40}, nil
+}
+
+func (f *fakeSource) ModelPricing(ctx context.Context) (map[string]openrouter.ModelPrice, error) {
+	return map[string]openrouter.ModelPrice{
+		"a/model": {Prompt: 0.000001, Completion: 0.000004},
+		"b/other": {Prompt: 0.000002, Completion: 0.000002},
+	}, nil
+}
+
+type fakeUsage []store.UsageSummary
+
+func (f fakeUsage) SummarizeUsage(ctx context.Context, groupBy string, since time.Time) ([]store.UsageSummary, error) {
+	return f, nil
+}
+
+func newTestMonitor(src *fakeSource, usage fakeUsage) (*Monitor, *[]string, *time.Time) {
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	var sent []string
+	m := New(src, usage, "")
+	m.now = func() time.Time { return now }
+	m.Models = []string{"a/model"}
+	m.Notify = func(msg string) { sent = append(sent, msg) }
+	return m, &sent, &now
+}
+
+func TestThresholdWarningsOnceAndReset(t *testing.T) {
+	src := &fakeSource{balance: 20}
+	m, sent, _ := newTestMonitor(src, nil)
+	ctx := context.Background()
+
+	check := func(balance float64) {
+		t.Helper()
+		src.balance = balance
+		if _, err := m.Check(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(20)
+	if len(*sent) != 0 {
+		t.Fatalf("warned above all thresholds: %v", *sent)
+	}
+	check(8)
+	check(7)
+	if len(*sent) != 1 || !strings.Contains((*sent)[0], "below $10.00") {
+		t.Fatalf("sent = %v, want one $10 warning", *sent)
+	}
+	// Dropping past two thresholds at once warns only for the lowest.
+	check(0.5)
+	if len(*sent) != 2 || !strings.Contains((*sent)[1], "below $1.00") {
+		t.Fatalf("sent = %v, want a $1 warning", *sent)
+	}
+	if h := m.HealthCheck(); h.Status != "degraded" {
+		t.Errorf("health = %+v, want degraded", h)
+	}
+	// A partial top-up re-arms the lower thresholds without a new warning.
+	check(7)
+	if len(*sent) != 2 {
+		t.Fatalf("warned after top-up: %v", (*sent)[2:])
+	}
+	check(3)
+	if len(*sent) != 3 || !strings.Contains((*sent)[2], "below $5.00") {
+		t.Fatalf("sent = %v, want a $5 warning after re-arming", *sent)
+	}
+	check(0)
+	if !strings.Contains((*sent)[len(*sent)-1], "used up") {
+		t.Errorf("last = %q, want used-up warning", (*sent)[len(*sent)-1])
+	}
+	if h := m.HealthCheck(); h.Status != "error" {
+		t.Errorf("health = %+v, want error", h)
+	}
+	check(50)
+	if st := m.Status(); st.WarnedBelowUSD != 0 {
+		t.Errorf("warned_below = %v after full top-up", st.WarnedBelowUSD)
+	}
+}
+
+func TestForecastAndDigest(t *testing.T) {
+	usage := fakeUsage{
+		{Key: "a/model", Calls: 100, CostUSD: 10.5},
+		{Key: "b/other", Calls: 20, CostUSD: 3.5},
+	}
+	m, sent, now := newTestMonitor(&fakeSource{balance: 4}, usage)
+	st, err := m.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := st.Forecast
+	if f.SpentUSD != 14 || f.DailyBurnUSD != 2 || f.Next30DaysUSD != 60 {
+		t.Errorf("forecast = %+v", f)
+	}
+	if f.DaysLeft == nil || *f.DaysLeft != 2 || !f.EmptyBy.Equal(now.Add(48*time.Hour)) {
+		t.Errorf("days left = %v, empty by %v", f.DaysLeft, f.EmptyBy)
+	}
+	if len(st.Pricing) != 2 {
+		t.Errorf("pricing = %v, want tracked and used models", st.Pricing)
+	}
+	// $4 is below $5 and $10: one balance warning, which includes the forecast.
+	if len(*sent) != 1 || !strings.Contains((*sent)[0], "2 days") {
+		t.Fatalf("sent = %v", *sent)
+	}
+
+	if m.digestDue() {
+		t.Fatal("digest due on first run")
+	}
+	*now = now.Add(8 * 24 * time.Hour)
+	if !m.digestDue() {
+		t.Fatal("digest not due after a week")
+	}
+	d := m.Digest(context.Background())
+	for _, want := range []string{"$14.00 in 120 calls", "a/model: $10.50", "$1.00/$4.00 per 1M", "Credits remaining: $4.00", "$60.00 over the next 30 days"} {
+		if !strings.Contains(d, want) {
+			t.Errorf("digest missing %q:\n%s", want, d)
+		}
+	}
+	if m.digestDue() {
+		t.Error("digest still due after sending")
+	}
+}
+
+func TestCheckError(t *testing.T) {
+	m, sent, _ := newTestMonitor(&fakeSource{err: errors.New("HTTP 401")}, nil)
+	if _, err := m.Check(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if h := m.HealthCheck(); h.Status != "degraded" || !strings.Contains(h.Message, "401") {
+		t.Errorf("health = %+v", h)
+	}
+	if len(*sent) != 0 {
+		t.Errorf("sent = %v", *sent)
+	}
+}
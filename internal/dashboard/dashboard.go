@@ -0,0 +1,146 @@
+// Package dashboard serves the web dashboard: conversations, the tool execution timeline, the
+// scheduler, and component health. The page and its assets are embedded in the binary; the data
+// comes from JSON endpoints under /api/ that require an admin's API token (see manage_api_tokens).
+package dashboard
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/httpauth"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the dashboard at "/" of its own listener.
+type Handler struct {
+	DB *store.DB
+	// Status gathers component health, error budget and credits (as system_status reports them).
+	Status func(ctx context.Context) (tools.SystemStatus, error)
+	// SchedulerLastTick reports when the scheduler last checked for due plans; optional.
+	SchedulerLastTick func() time.Time
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		assets, _ := fs.Sub(static, "static")
+		w.Header().Set("Cache-Control", "no-cache")
+		http.FileServer(http.FS(assets)).ServeHTTP(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	user, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	q := r.URL.Query()
+	switch r.URL.Path {
+	case "/api/me":
+		writeJSON(w, http.StatusOK, user)
+	case "/api/threads":
+		threads, err := h.DB.ListThreads(ctx, intParam(q.Get("limit"), 100))
+		h.respond(w, threads, err)
+	case "/api/messages":
+		if q.Get("thread") == "" {
+			writeError(w, http.StatusBadRequest, "thread is required")
+			return
+		}
+		msgs, err := h.DB.RecentMessages(ctx, intParam(q.Get("limit"), 200), q.Get("thread"))
+		h.respond(w, msgs, err)
+	case "/api/timeline":
+		entries, err := h.DB.ReadAuditLog(ctx, store.AuditFilter{
+			ThreadID: q.Get("thread"),
+			Tool:     q.Get("tool"),
+			Outcome:  q.Get("outcome"),
+			UserID:   q.Get("user"),
+			Limit:    intParam(q.Get("limit"), 100),
+		})
+		h.respond(w, entries, err)
+	case "/api/schedules":
+		plans, err := h.DB.ListAllPlans(ctx, q.Get("status"))
+		if err != nil {
+			h.respond(w, nil, err)
+			return
+		}
+		out := map[string]interface{}{"plans": plans}
+		if h.SchedulerLastTick != nil {
+			if t := h.SchedulerLastTick(); !t.IsZero() {
+				out["last_tick"] = t
+			}
+		}
+		writeJSON(w, http.StatusOK, out)
+	case "/api/runs":
+		id, err := strconv.ParseInt(q.Get("plan"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "plan must be a plan ID")
+			return
+		}
+		runs, err := h.DB.ListPlanRuns(ctx, id, intParam(q.Get("limit"), 20))
+		h.respond(w, runs, err)
+	case "/api/health":
+		if h.Status == nil {
+			writeError(w, http.StatusNotFound, "health is not available")
+			return
+		}
+		status, err := h.Status(ctx)
+		h.respond(w, status, err)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// authenticate accepts API tokens of owners and admins with the admin scope only, since the
+// dashboard shows every user's conversations.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request) (*store.User, bool) {
+	p, err := (&httpauth.Authenticator{DB: h.DB}).Authenticate(r, store.ScopeAdmin)
+	if err != nil {
+		writeError(w, err.Status, err.Message)
+		return nil, false
+	}
+	return p.User, true
+}
+
+func (h *Handler) respond(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		log.Printf("[Dashboard] %v", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// intParam parses a positive limit, falling back to def; limits are capped at 1000.
+func intParam(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return def
+	}
+	if n > 1000 {
+		return 1000
+	}
+	return n
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,164 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools"
+)
+
+func newTestDashboard(t *testing.T) (string, *store.DB) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	tick := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	h := &Handler{
+		DB: db,
+		Status: func(ctx context.Context) (tools.SystemStatus, error) {
+			return tools.SystemStatus{MessageCount: 2}, nil
+		},
+		SchedulerLastTick: func() time.Time { return tick },
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv.URL, db
+}
+
+func get(t *testing.T, url, token string, out interface{}) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestDashboardAuth(t *testing.T) {
+	url, db := newTestDashboard(t)
+	ctx := context.Background()
+	for _, id := range []string{"boss", "alice"} {
+		if _, err := db.GetOrCreateUser(ctx, id, "", "api"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.UpdateUserRole(ctx, "boss", store.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	_, adminToken, err := db.CreateAPIToken(ctx, "boss", "dash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, userToken, err := db.CreateAPIToken(ctx, "alice", "dash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(url + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("index: status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	cases := []struct {
+		token string
+		want  int
+	}{
+		{"", http.StatusUnauthorized},
+		{"hb_wrong", http.StatusUnauthorized},
+		{userToken, http.StatusForbidden},
+		{adminToken, http.StatusOK},
+	}
+	for _, c := range cases {
+		if got := get(t, url+"/api/me", c.token, nil); got != c.want {
+			t.Errorf("token %q: status %d, want %d", c.token, got, c.want)
+		}
+	}
+}
+
+func TestDashboardData(t *testing.T) {
+	url, db := newTestDashboard(t)
+	ctx := context.Background()
+	if _, err := db.GetOrCreateUser(ctx, "boss", "", "api"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateUserTrust(ctx, "boss", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	_, token, err := db.CreateAPIToken(ctx, "boss", "dash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []struct{ role, content, thread string }{
+		{"user", "hi", "t1"}, {"assistant", "hello", "t1"}, {"user", "other", "t2"},
+	} {
+		if _, err := db.InsertMessage(ctx, m.role, m.content, "", "alice", "api", m.thread, "", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range []store.AuditEntry{
+		{Tool: "read_file", ThreadID: "t1", Outcome: "ok"},
+		{Tool: "run_shell", ThreadID: "t2", Outcome: "error", Error: "boom"},
+	} {
+		if err := db.AppendAuditEntry(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	planID, err := db.CreatePlan(ctx, "alice", "daily digest", "agent", "{}", "cron", "0 9 * * *", "UTC", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var threads []store.ThreadSummary
+	if get(t, url+"/api/threads", token, &threads); len(threads) != 2 || threads[0].ThreadID != "t2" || threads[1].MessageCount != 2 || threads[1].LastMessage != "hello" {
+		t.Errorf("threads = %+v", threads)
+	}
+	var msgs []store.Message
+	if get(t, url+"/api/messages?thread=t1", token, &msgs); len(msgs) != 2 || msgs[0].Content != "hi" {
+		t.Errorf("messages = %+v", msgs)
+	}
+	if code := get(t, url+"/api/messages", token, nil); code != http.StatusBadRequest {
+		t.Errorf("messages without thread: status %d", code)
+	}
+	var entries []store.AuditEntry
+	if get(t, url+"/api/timeline?thread=t2", token, &entries); len(entries) != 1 || entries[0].Tool != "run_shell" {
+		t.Errorf("timeline = %+v", entries)
+	}
+	var sched struct {
+		Plans    []store.ScheduledPlan `json:"plans"`
+		LastTick time.Time             `json:"last_tick"`
+	}
+	if get(t, url+"/api/schedules", token, &sched); len(sched.Plans) != 1 || sched.Plans[0].ID != planID || sched.LastTick.IsZero() {
+		t.Errorf("schedules = %+v", sched)
+	}
+	if code := get(t, url+"/api/runs?plan=x", token, nil); code != http.StatusBadRequest {
+		t.Errorf("runs with a bad plan ID: status %d", code)
+	}
+	var status tools.SystemStatus
+	if get(t, url+"/api/health", token, &status); status.MessageCount != 2 {
+		t.Errorf("health = %+v", status)
+	}
+}
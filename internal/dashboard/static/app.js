@@ -0,0 +1,254 @@
+// HattieBot dashboard: a token-authenticated view of conversations, tool executions, the
+// scheduler, and health. All data comes from the JSON endpoints under /api/.
+"use strict";
+
+const REFRESH_MS = 10000;
+let token = localStorage.getItem("hattiebot_token") || "";
+let view = "conversations";
+let currentThread = "";
+let timer = null;
+
+const $ = (sel) => document.querySelector(sel);
+
+function el(tag, attrs = {}, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs)) {
+    if (k === "class") node.className = v;
+    else if (k === "onclick") node.addEventListener("click", v);
+    else node.setAttribute(k, v);
+  }
+  for (const c of children) {
+    if (c !== null && c !== undefined) node.append(c instanceof Node ? c : String(c));
+  }
+  return node;
+}
+
+function fmtTime(t) {
+  if (!t || t.startsWith("0001-")) return "";
+  return new Date(t).toLocaleString();
+}
+
+async function api(path) {
+  const resp = await fetch("/api/" + path, { headers: { Authorization: "Bearer " + token } });
+  const body = await resp.json().catch(() => ({}));
+  if (resp.status === 401 || resp.status === 403) {
+    signOut(body.error || "unauthorized");
+    throw new Error(body.error || "unauthorized");
+  }
+  if (!resp.ok) throw new Error(body.error || resp.statusText);
+  return body;
+}
+
+function signOut(message) {
+  token = "";
+  localStorage.removeItem("hattiebot_token");
+  clearInterval(timer);
+  $("#login").hidden = false;
+  $("#tabs").hidden = true;
+  $("#logout").hidden = true;
+  $("#who").textContent = "";
+  document.querySelectorAll(".view").forEach((v) => (v.hidden = true));
+  $("#login-error").textContent = message || "";
+}
+
+async function signIn() {
+  const me = await api("me");
+  $("#who").textContent = me.name ? `${me.name} (${me.id})` : me.id;
+  $("#login").hidden = true;
+  $("#tabs").hidden = false;
+  $("#logout").hidden = false;
+  show(view);
+}
+
+function show(name) {
+  view = name;
+  document.querySelectorAll("#tabs button").forEach((b) => b.classList.toggle("active", b.dataset.view === name));
+  document.querySelectorAll(".view").forEach((v) => (v.hidden = v.id !== name));
+  refresh();
+  clearInterval(timer);
+  timer = setInterval(refresh, REFRESH_MS);
+}
+
+function refresh() {
+  const loaders = { conversations: loadThreads, timeline: loadTimeline, scheduler: loadSchedules, health: loadHealth };
+  loaders[view]().catch((err) => console.error(err));
+}
+
+// Conversations
+
+async function loadThreads() {
+  const threads = (await api("threads")) || [];
+  const list = $("#threads");
+  list.replaceChildren(
+    ...threads.map((t) =>
+      el(
+        "div",
+        { class: "thread" + (t.thread_id === currentThread ? " active" : ""), onclick: () => openThread(t.thread_id) },
+        el("div", { class: "id" }, t.thread_id || "(no thread)"),
+        el("div", { class: "meta muted" }, `${t.channel} · ${t.message_count} messages · ${fmtTime(t.last_at)}`),
+        el("div", { class: "preview" }, t.last_message)
+      )
+    )
+  );
+  if (currentThread) await loadMessages();
+}
+
+async function openThread(id) {
+  currentThread = id;
+  document.querySelectorAll(".thread").forEach((n) => n.classList.toggle("active", n.querySelector(".id").textContent === id));
+  await loadMessages();
+}
+
+async function loadMessages() {
+  const msgs = (await api("messages?thread=" + encodeURIComponent(currentThread))) || [];
+  const box = $("#messages");
+  const atBottom = box.scrollHeight - box.scrollTop - box.clientHeight < 40;
+  box.replaceChildren(
+    ...msgs.map((m) => {
+      const meta = [m.role, m.sender_id, m.model, fmtTime(m.created_at), m.trace_id && "trace " + m.trace_id].filter(Boolean).join(" · ");
+      const node = el("div", { class: "msg " + m.role }, el("div", { class: "meta" }, meta));
+      if (m.content) node.append(el("div", { class: "body" }, m.content));
+      if (m.tool_calls) node.append(el("details", {}, el("summary", {}, "tool calls"), el("pre", {}, pretty(m.tool_calls))));
+      return node;
+    })
+  );
+  if (atBottom) box.scrollTop = box.scrollHeight;
+}
+
+function pretty(json) {
+  try {
+    return JSON.stringify(JSON.parse(json), null, 2);
+  } catch {
+    return json;
+  }
+}
+
+// Tool timeline
+
+async function loadTimeline() {
+  const params = new URLSearchParams(new FormData($("#timeline-filter")));
+  for (const [k, v] of [...params]) if (!v) params.delete(k);
+  const entries = (await api("timeline?" + params)) || [];
+  $("#timeline-rows").replaceChildren(
+    ...entries.map((e) =>
+      el(
+        "tr",
+        {},
+        el("td", {}, fmtTime(e.created_at)),
+        el("td", {}, e.tool),
+        el("td", {}, e.user_id || ""),
+        el("td", {}, e.thread_id || ""),
+        el("td", { class: e.outcome }, e.outcome),
+        el("td", {}, `${e.duration_ms} ms`),
+        el("td", { class: "details" }, e.error || e.args || "")
+      )
+    )
+  );
+}
+
+// Scheduler
+
+async function loadSchedules() {
+  const data = await api("schedules");
+  $("#last-tick").textContent = data.last_tick ? "Scheduler last checked for due tasks " + fmtTime(data.last_tick) : "The scheduler has not run yet.";
+  $("#plan-rows").replaceChildren(
+    ...(data.plans || []).map((p) =>
+      el(
+        "tr",
+        { class: "clickable", onclick: () => loadRuns(p) },
+        el("td", {}, p.id),
+        el("td", {}, p.user_id),
+        el("td", {}, p.description),
+        el("td", {}, p.action_type),
+        el("td", {}, `${p.schedule_type} ${p.schedule_value}${p.timezone ? " (" + p.timezone + ")" : ""}`),
+        el("td", {}, fmtTime(p.next_run_at)),
+        el("td", {}, fmtTime(p.last_run_at)),
+        el("td", {}, p.status)
+      )
+    )
+  );
+}
+
+async function loadRuns(plan) {
+  const runs = (await api("runs?plan=" + plan.id)) || [];
+  $("#runs").replaceChildren(
+    el("h2", {}, `Runs of #${plan.id}: ${plan.description}`),
+    runs.length === 0
+      ? el("p", { class: "muted" }, "No recorded runs.")
+      : el(
+          "table",
+          {},
+          el("thead", {}, el("tr", {}, ...["Started", "Finished", "Status", "Summary", "Artifacts"].map((h) => el("th", {}, h)))),
+          el(
+            "tbody",
+            {},
+            ...runs.map((r) =>
+              el(
+                "tr",
+                {},
+                el("td", {}, fmtTime(r.started_at)),
+                el("td", {}, fmtTime(r.finished_at)),
+                el("td", { class: r.status === "failed" ? "error" : r.status === "succeeded" ? "ok" : "" }, r.status),
+                el("td", {}, r.summary || ""),
+                el("td", { class: "details" }, (r.artifacts || []).join(", "))
+              )
+            )
+          )
+        )
+  );
+}
+
+// Health
+
+function card(label, value, cls = "") {
+  return el("div", { class: "card" }, el("div", { class: "label" }, label), el("div", { class: "value " + cls }, value));
+}
+
+async function loadHealth() {
+  const s = await api("health");
+  const cards = [
+    card("Messages", s.message_count),
+    card("Registered tools", (s.registered_tools || []).length),
+    card("Channels", (s.active_channels || []).join(", ") || "none"),
+    card("Token budget", s.token_budget),
+  ];
+  if (s.error_budget) {
+    cards.push(card("Error budget", s.error_budget.throttled ? "throttled" : "ok", s.error_budget.throttled ? "error" : "ok"));
+  }
+  if (s.credits && s.credits.remaining_usd !== undefined) {
+    cards.push(card("Credits left", "$" + s.credits.remaining_usd.toFixed(2)));
+  }
+  if (s.onboarding && !s.onboarding.complete) {
+    cards.push(card("Setup", `${s.onboarding.done}/${s.onboarding.total} done`, "warn"));
+  }
+  $("#health-summary").replaceChildren(...cards);
+  const comps = Object.values(s.components || {}).sort((a, b) => a.name.localeCompare(b.name));
+  $("#component-rows").replaceChildren(
+    ...comps.map((c) =>
+      el("tr", {}, el("td", {}, c.name), el("td", { class: c.status }, c.status), el("td", {}, c.message || ""), el("td", {}, fmtTime(c.last_ok)))
+    )
+  );
+  $("#error-rows").replaceChildren(
+    ...(s.recent_errors || []).map((e) =>
+      el("tr", {}, el("td", {}, fmtTime(e.timestamp)), el("td", {}, e.component), el("td", { class: e.level }, e.message))
+    )
+  );
+}
+
+// Wiring
+
+$("#login-form").addEventListener("submit", (ev) => {
+  ev.preventDefault();
+  token = $("#token").value.trim();
+  localStorage.setItem("hattiebot_token", token);
+  $("#token").value = "";
+  signIn().catch((err) => signOut(err.message));
+});
+$("#logout").addEventListener("click", () => signOut(""));
+document.querySelectorAll("#tabs button").forEach((b) => b.addEventListener("click", () => show(b.dataset.view)));
+$("#timeline-filter").addEventListener("submit", (ev) => {
+  ev.preventDefault();
+  loadTimeline();
+});
+
+if (token) signIn().catch((err) => signOut(err.message));
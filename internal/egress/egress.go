@@ -0,0 +1,151 @@
+// Package egress controls outbound network access from agent-created (registered) tools. Tools run
+// with HTTP(S)_PROXY pointing at a local forward proxy that checks each destination against the
+// policy in $CONFIG_DIR/network_policy.json and logs every connection attempt.
+package egress
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	ModeOff       = "off"       // no checks; destinations are still logged
+	ModeDenylist  = "denylist"  // everything except Deny
+	ModeAllowlist = "allowlist" // only Allow (Deny still wins)
+)
+
+const policyFile = "network_policy.json"
+
+// Policy decides which hosts registered tools may reach. Entries are domains ("example.com" also
+// matches its subdomains, "*.example.com" only subdomains), IPs, or CIDRs.
+type Policy struct {
+	Mode  string   `json:"mode"`
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// DefaultPolicy allows everything except cloud metadata endpoints.
+func DefaultPolicy() Policy {
+	return Policy{
+		Mode: ModeDenylist,
+		Deny: []string{"169.254.169.254", "metadata.google.internal", "fd00:ec2::254"},
+	}
+}
+
+// LoadPolicy reads $CONFIG_DIR/network_policy.json; DefaultPolicy if it does not exist.
+func LoadPolicy(configDir string) (Policy, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, policyFile))
+	if os.IsNotExist(err) {
+		return DefaultPolicy(), nil
+	}
+	if err != nil {
+		return DefaultPolicy(), err
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return DefaultPolicy(), fmt.Errorf("parse %s: %w", policyFile, err)
+	}
+	if err := p.Validate(); err != nil {
+		return DefaultPolicy(), err
+	}
+	return p, nil
+}
+
+// SavePolicy writes $CONFIG_DIR/network_policy.json.
+func SavePolicy(configDir string, p Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(configDir, policyFile), data, 0600)
+}
+
+// Validate checks the mode and normalizes entries (lowercase, sorted, no duplicates).
+func (p *Policy) Validate() error {
+	switch p.Mode {
+	case "":
+		p.Mode = ModeDenylist
+	case ModeOff, ModeDenylist, ModeAllowlist:
+	default:
+		return fmt.Errorf("unknown network policy mode %q (use allowlist, denylist, or off)", p.Mode)
+	}
+	var err error
+	if p.Allow, err = normalizeEntries(p.Allow); err != nil {
+		return err
+	}
+	p.Deny, err = normalizeEntries(p.Deny)
+	return err
+}
+
+// Check reports whether host (a name or IP, no port) may be reached, and why.
+func (p Policy) Check(host string) (bool, string) {
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+	if p.Mode == ModeOff {
+		return true, "policy off"
+	}
+	if e := match(host, p.Deny); e != "" {
+		return false, "denied by " + e
+	}
+	if p.Mode == ModeAllowlist {
+		if e := match(host, p.Allow); e != "" {
+			return true, "allowed by " + e
+		}
+		return false, "not in allowlist"
+	}
+	return true, "not in denylist"
+}
+
+// match returns the first entry that matches host, or "".
+func match(host string, entries []string) string {
+	ip := net.ParseIP(host)
+	for _, e := range entries {
+		switch {
+		case strings.Contains(e, "/"):
+			if _, cidr, err := net.ParseCIDR(e); err == nil && ip != nil && cidr.Contains(ip) {
+				return e
+			}
+		case strings.HasPrefix(e, "*."):
+			if strings.HasSuffix(host, e[1:]) {
+				return e
+			}
+		case host == e || strings.HasSuffix(host, "."+e):
+			return e
+		case ip != nil && net.ParseIP(e) != nil && ip.Equal(net.ParseIP(e)):
+			return e
+		}
+	}
+	return ""
+}
+
+func normalizeEntries(entries []string) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range entries {
+		e = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(e)), ".")
+		if e == "" || seen[e] {
+			continue
+		}
+		if strings.Contains(e, "/") {
+			if _, _, err := net.ParseCIDR(e); err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", e)
+			}
+		} else if strings.ContainsAny(e, " :@?#") && net.ParseIP(e) == nil {
+			return nil, fmt.Errorf("invalid domain %q (use a host name like api.example.com, not a URL)", e)
+		}
+		seen[e] = true
+		out = append(out, e)
+	}
+	sort.Strings(out)
+	return out, nil
+}
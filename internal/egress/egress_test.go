@@ -0,0 +1,139 @@
+package egress
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	p := Policy{Mode: ModeAllowlist, Allow: []string{"api.github.com", "*.example.com", "10.0.0.0/8"}, Deny: []string{"evil.api.github.com"}}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"api.github.com":         true,
+		"uploads.api.github.com": true,
+		"evil.api.github.com":    false,
+		"github.com":             false,
+		"www.example.com":        true,
+		"example.com":            false,
+		"10.1.2.3":               true,
+		"192.168.1.1":            false,
+		"API.GitHub.com.":        true,
+	}
+	for host, want := range cases {
+		if got, reason := p.Check(host); got != want {
+			t.Errorf("Check(%s) = %v (%s), want %v", host, got, reason, want)
+		}
+	}
+
+	def := DefaultPolicy()
+	if ok, _ := def.Check("169.254.169.254"); ok {
+		t.Error("default policy must block the metadata endpoint")
+	}
+	if ok, _ := def.Check("api.openai.com"); !ok {
+		t.Error("default policy should allow ordinary hosts")
+	}
+	if err := (&Policy{Mode: "block-all"}).Validate(); err == nil {
+		t.Error("expected invalid mode error")
+	}
+	if err := (&Policy{Allow: []string{"https://x.com/path"}}).Validate(); err == nil {
+		t.Error("expected URL entries to be rejected")
+	}
+}
+
+func TestPolicyPersists(t *testing.T) {
+	dir := t.TempDir()
+	if err := SavePolicy(dir, Policy{Mode: ModeAllowlist, Allow: []string{"B.com", "a.com", "a.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Mode != ModeAllowlist || len(p.Allow) != 2 || p.Allow[0] != "a.com" || p.Allow[1] != "b.com" {
+		t.Errorf("loaded policy = %+v", p)
+	}
+}
+
+func startProxy(t *testing.T, pol Policy) *Proxy {
+	t.Helper()
+	p, err := NewProxy(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetPolicy(pol); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func proxyClient(t *testing.T, p *Proxy, tool string) *http.Client {
+	u, err := url.Parse(p.Env(tool)["HTTPS_PROXY"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(u),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+}
+
+func TestProxyEnforcesAndLogs(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+	tlsUpstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secure"))
+	}))
+	defer tlsUpstream.Close()
+
+	p := startProxy(t, Policy{Mode: ModeAllowlist, Allow: []string{"127.0.0.1"}})
+	client := proxyClient(t, p, "weather_lookup")
+
+	for url, want := range map[string]string{upstream.URL: "hello", tlsUpstream.URL: "secure"} {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != want {
+			t.Errorf("GET %s = %q, want %q", url, body, want)
+		}
+	}
+
+	if err := p.SetPolicy(Policy{Mode: ModeDenylist, Deny: []string{"127.0.0.0/8"}}); err != nil {
+		t.Fatal(err)
+	}
+	client.CloseIdleConnections() // an open tunnel was checked when it was set up
+	if resp, err := client.Get(upstream.URL); err == nil {
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("denied plain request status = %d, want 403", resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+	if _, err := client.Get(tlsUpstream.URL); err == nil {
+		t.Error("denied CONNECT should fail")
+	}
+
+	events := p.Recent(10, "weather_lookup")
+	if len(events) != 4 {
+		t.Fatalf("logged %d events, want 4: %+v", len(events), events)
+	}
+	if events[0].Allowed || events[0].Method != "CONNECT" || !events[3].Allowed || events[3].Method != "HTTP" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+	if len(p.Recent(10, "other_tool")) != 0 {
+		t.Error("events should be filtered by tool")
+	}
+}
@@ -0,0 +1,277 @@
+package egress
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxEvents bounds the in-memory log of recent destinations kept for manage_network_policy.
+const maxEvents = 500
+
+// Event is one outbound connection attempt through the proxy.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Tool    string    `json:"tool,omitempty"`
+	Method  string    `json:"method"`
+	Host    string    `json:"host"`
+	Port    string    `json:"port"`
+	Allowed bool      `json:"allowed"`
+	Reason  string    `json:"reason"`
+}
+
+// Proxy is a forward HTTP proxy (plain requests and CONNECT tunnels) that enforces a Policy.
+// Tools identify themselves through the proxy URL's user name, so every destination is logged
+// against the tool that contacted it.
+type Proxy struct {
+	configDir string
+
+	mu     sync.RWMutex
+	policy Policy
+	events []Event
+
+	ln        net.Listener
+	srv       *http.Server
+	transport *http.Transport
+}
+
+// NewProxy loads the policy from configDir. Call Start to begin listening.
+func NewProxy(configDir string) (*Proxy, error) {
+	pol, err := LoadPolicy(configDir)
+	p := &Proxy{configDir: configDir, policy: pol}
+	p.transport = &http.Transport{
+		DialContext: p.dial,
+		// One connection per request, so every plain-HTTP request is checked and logged in dial.
+		DisableKeepAlives: true,
+	}
+	return p, err
+}
+
+// Start listens on addr (e.g. "127.0.0.1:0") and serves in the background.
+func (p *Proxy) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	p.ln = ln
+	p.srv = &http.Server{Handler: p, ReadHeaderTimeout: 30 * time.Second}
+	go func() {
+		if err := p.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("[EGRESS] proxy stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Close stops the proxy.
+func (p *Proxy) Close() error {
+	if p.srv == nil {
+		return nil
+	}
+	return p.srv.Close()
+}
+
+// Addr returns the listen address, or "" before Start.
+func (p *Proxy) Addr() string {
+	if p.ln == nil {
+		return ""
+	}
+	return p.ln.Addr().String()
+}
+
+// Env returns the environment that routes a tool's traffic through the proxy. Both spellings are
+// set because tools differ in which they read; NO_PROXY is cleared so nothing skips the proxy.
+func (p *Proxy) Env(tool string) map[string]string {
+	if p == nil || p.ln == nil {
+		return nil
+	}
+	u := (&url.URL{Scheme: "http", User: url.User(tool), Host: p.Addr()}).String()
+	return map[string]string{
+		"HTTP_PROXY": u, "HTTPS_PROXY": u, "ALL_PROXY": u,
+		"http_proxy": u, "https_proxy": u, "all_proxy": u,
+		"NO_PROXY": "", "no_proxy": "",
+	}
+}
+
+// Policy returns the current policy.
+func (p *Proxy) Policy() Policy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.policy
+}
+
+// SetPolicy validates, saves, and applies pol.
+func (p *Proxy) SetPolicy(pol Policy) error {
+	if err := SavePolicy(p.configDir, pol); err != nil {
+		return err
+	}
+	if err := pol.Validate(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.policy = pol
+	p.mu.Unlock()
+	return nil
+}
+
+// Recent returns up to limit logged destinations, newest first, optionally for one tool.
+func (p *Proxy) Recent(limit int, tool string) []Event {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if limit <= 0 {
+		limit = 50
+	}
+	var out []Event
+	for i := len(p.events) - 1; i >= 0 && len(out) < limit; i-- {
+		if tool == "" || p.events[i].Tool == tool {
+			out = append(out, p.events[i])
+		}
+	}
+	return out
+}
+
+func (p *Proxy) record(e Event) {
+	e.Time = time.Now()
+	log.Printf("[EGRESS] tool=%s %s %s:%s allowed=%v (%s)", e.Tool, e.Method, e.Host, e.Port, e.Allowed, e.Reason)
+	p.mu.Lock()
+	p.events = append(p.events, e)
+	if len(p.events) > maxEvents {
+		p.events = p.events[len(p.events)-maxEvents:]
+	}
+	p.mu.Unlock()
+}
+
+type toolKey struct{}
+
+// ServeHTTP handles CONNECT tunnels and absolute-URI proxy requests.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tool := proxyUser(r)
+	if r.Method == http.MethodConnect {
+		p.connect(w, r, tool)
+		return
+	}
+	if r.URL.Host == "" {
+		http.Error(w, "egress proxy: absolute URL required", http.StatusBadRequest)
+		return
+	}
+	out := r.Clone(context.WithValue(r.Context(), toolKey{}, tool))
+	out.RequestURI = ""
+	for _, h := range []string{"Proxy-Authorization", "Proxy-Connection", "Connection", "Keep-Alive", "Te", "Trailer", "Upgrade"} {
+		out.Header.Del(h)
+	}
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		status := http.StatusBadGateway
+		if strings.Contains(err.Error(), "egress policy") {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer resp.Body.Close()
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (p *Proxy) connect(w http.ResponseWriter, r *http.Request, tool string) {
+	ctx := context.WithValue(r.Context(), toolKey{}, tool)
+	upstream, err := p.dialMethod(ctx, "CONNECT", r.Host)
+	if err != nil {
+		status := http.StatusBadGateway
+		if strings.Contains(err.Error(), "egress policy") {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "egress proxy: hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hj.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	_, _ = client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	go func() {
+		if buf.Reader.Buffered() > 0 {
+			_, _ = io.CopyN(upstream, buf, int64(buf.Reader.Buffered()))
+		}
+		_, _ = io.Copy(upstream, client)
+		upstream.Close()
+	}()
+	_, _ = io.Copy(client, upstream)
+	client.Close()
+}
+
+func (p *Proxy) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	return p.dialMethod(ctx, "HTTP", addr)
+}
+
+// dialMethod checks host against the policy, then resolves it and checks the addresses against the
+// deny list too, so a permitted name cannot point at a denied address (e.g. cloud metadata).
+func (p *Proxy) dialMethod(ctx context.Context, method, addr string) (net.Conn, error) {
+	tool, _ := ctx.Value(toolKey{}).(string)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, "443"
+	}
+	pol := p.Policy()
+	ev := Event{Tool: tool, Method: method, Host: host, Port: port}
+	allowed, reason := pol.Check(host)
+	if !allowed {
+		ev.Reason = reason
+		p.record(ev)
+		return nil, fmt.Errorf("egress policy: %s is blocked (%s)", host, reason)
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		ev.Reason = "resolve failed: " + err.Error()
+		p.record(ev)
+		return nil, err
+	}
+	if pol.Mode != ModeOff {
+		for _, ip := range ips {
+			if e := match(ip.IP.String(), pol.Deny); e != "" {
+				ev.Reason = fmt.Sprintf("%s resolves to %s, denied by %s", host, ip.IP, e)
+				p.record(ev)
+				return nil, fmt.Errorf("egress policy: %s", ev.Reason)
+			}
+		}
+	}
+	ev.Allowed, ev.Reason = true, reason
+	p.record(ev)
+	var d net.Dialer
+	d.Timeout = 30 * time.Second
+	return d.DialContext(ctx, "tcp", net.JoinHostPort(ips[0].IP.String(), port))
+}
+
+// proxyUser returns the user name from Proxy-Authorization (Basic), which carries the tool name.
+func proxyUser(r *http.Request) string {
+	auth := r.Header.Get("Proxy-Authorization")
+	if !strings.HasPrefix(auth, "Basic ") {
+		return ""
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+	if err != nil {
+		return ""
+	}
+	user, _, _ := strings.Cut(string(raw), ":")
+	return user
+}
@@ -35,6 +35,11 @@ func NewClient(baseURL, apiKey string, dimension int) *Client {
 	}
 }
 
+// EmbeddingModel implements core.EmbeddingModeler; the service is identified by its URL.
+func (c *Client) EmbeddingModel() string {
+	return "embeddinggood:" + c.BaseURL
+}
+
 // EmbedRequest is the request body for POST /embed.
 type EmbedRequest struct {
 	Input     interface{} `json:"input"`     // string or []string
@@ -48,9 +53,38 @@ type EmbedResponse struct {
 	Dimension  int         `json:"dimension"`
 }
 
+// maxBatch is the most texts sent in one /embed request; EmbedBatch splits longer lists.
+const maxBatch = 64
+
 // Embed calls POST {BaseURL}/embed and returns the first embedding vector.
 // embedType should be "document" for memorize and "query" for recall_memories.
 func (c *Client) Embed(ctx context.Context, text string, embedType string) ([]float32, error) {
+	out, err := c.embed(ctx, text, 1, embedType)
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+// EmbedBatch embeds texts with one /embed request per maxBatch texts.
+func (c *Client) EmbedBatch(ctx context.Context, texts []string, embedType string) ([][]float32, error) {
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += maxBatch {
+		end := start + maxBatch
+		if end > len(texts) {
+			end = len(texts)
+		}
+		vecs, err := c.embed(ctx, texts[start:end], end-start, embedType)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vecs...)
+	}
+	return out, nil
+}
+
+// embed posts input (a string or []string of n texts) and returns the n vectors in order.
+func (c *Client) embed(ctx context.Context, input interface{}, n int, embedType string) ([][]float32, error) {
 	if c.BaseURL == "" {
 		return nil, fmt.Errorf("embeddinggood: base URL not set")
 	}
@@ -65,7 +99,7 @@ func (c *Client) Embed(ctx context.Context, text string, embedType string) ([]fl
 		dim = 768
 	}
 	body := EmbedRequest{
-		Input:     text,
+		Input:     input,
 		Type:      embedType,
 		Dimension: dim,
 	}
@@ -99,10 +133,16 @@ func (c *Client) Embed(ctx context.Context, text string, embedType string) ([]fl
 	if len(out.Embeddings) == 0 {
 		return nil, fmt.Errorf("embeddinggood: no embeddings in response")
 	}
-	vec64 := out.Embeddings[0]
-	vec := make([]float32, len(vec64))
-	for i, v := range vec64 {
-		vec[i] = float32(v)
+	if len(out.Embeddings) != n {
+		return nil, fmt.Errorf("embeddinggood: got %d embeddings for %d texts", len(out.Embeddings), n)
+	}
+	vecs := make([][]float32, n)
+	for i, vec64 := range out.Embeddings {
+		vec := make([]float32, len(vec64))
+		for j, v := range vec64 {
+			vec[j] = float32(v)
+		}
+		vecs[i] = vec
 	}
-	return vec, nil
+	return vecs, nil
 }
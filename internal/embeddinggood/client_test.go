@@ -0,0 +1,50 @@
+package embeddinggood
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEmbedBatchSplitsRequests(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req struct {
+			Input []string `json:"input"`
+			Type  string   `json:"type"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Type != "document" || r.Header.Get("x-api-key") != "k" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		resp := EmbedResponse{Dimension: 2}
+		for _, in := range req.Input {
+			var n float64
+			fmt.Sscanf(in, "t%g", &n)
+			resp.Embeddings = append(resp.Embeddings, []float64{n, 1})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	texts := make([]string, maxBatch+3)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("t%d", i)
+	}
+	vecs, err := NewClient(srv.URL, "k", 128).EmbedBatch(context.Background(), texts, "document")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 || len(vecs) != len(texts) {
+		t.Fatalf("%d requests, %d vectors; want 2 requests and %d vectors", requests, len(vecs), len(texts))
+	}
+	for i, v := range vecs {
+		if v[0] != float32(i) {
+			t.Fatalf("vector %d = %v, out of order", i, v)
+		}
+	}
+}
@@ -19,3 +19,12 @@ type llmEmbedWrapper struct {
 func (w *llmEmbedWrapper) Embed(ctx context.Context, text string, _ string) ([]float32, error) {
 	return w.client.Embed(ctx, text)
 }
+
+func (w *llmEmbedWrapper) EmbedBatch(ctx context.Context, texts []string, _ string) ([][]float32, error) {
+	return core.LLMEmbedBatch(ctx, w.client, texts)
+}
+
+// EmbeddingModel implements core.EmbeddingModeler; the LLM client embeds with its own model.
+func (w *llmEmbedWrapper) EmbeddingModel() string {
+	return "llm"
+}
@@ -0,0 +1,139 @@
+package embeddinggood
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+)
+
+// LocalDimension is the size of local vectors. No supported provider uses it, so memories stored
+// during an outage only match each other, and count as stale (to re-embed) once it is over.
+const LocalDimension = 320
+
+// LocalModel names the local embedder's vectors.
+const LocalModel = "local-hashing"
+
+// Local embeds text offline with the hashing trick: words and their character trigrams are hashed
+// into LocalDimension signed buckets and the vector is normalized. It only captures shared
+// vocabulary, not meaning, but keeps memorize and recall working without any service.
+type Local struct{}
+
+func (Local) Embed(_ context.Context, text string, _ string) ([]float32, error) {
+	return hashVector(text), nil
+}
+
+func (Local) EmbedBatch(_ context.Context, texts []string, _ string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = hashVector(t)
+	}
+	return out, nil
+}
+
+// EmbeddingModel implements core.EmbeddingModeler.
+func (Local) EmbeddingModel() string { return LocalModel }
+
+// IsLocal reports whether v is a local vector.
+func IsLocal(v []float32) bool { return len(v) == LocalDimension }
+
+func hashVector(text string) []float32 {
+	v := make([]float32, LocalDimension)
+	add := func(feature string, weight float32) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		v[sum%LocalDimension] += weight
+	}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		add("w:"+w, 1)
+		// Trigrams let inflected forms ("meeting", "meetings") share most features
+		runes := []rune("^" + w + "$")
+		for i := 0; i+3 <= len(runes); i++ {
+			add("t:"+string(runes[i:i+3]), 0.5)
+		}
+	}
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		v[0] = 1 // empty text: a fixed unit vector rather than a zero one
+		return v
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range v {
+		v[i] *= scale
+	}
+	return v
+}
+
+// LocalFallback is Primary, falling back to Local when Primary fails, so memory keeps working
+// (less precisely) through an outage instead of erroring.
+type LocalFallback struct {
+	Primary core.EmbeddingClient
+	local   Local
+	mu      sync.Mutex
+	down    bool // Primary failed last time; logged once per outage
+}
+
+// WithLocalFallback wraps primary with the local fallback.
+func WithLocalFallback(primary core.EmbeddingClient) *LocalFallback {
+	return &LocalFallback{Primary: primary}
+}
+
+func (f *LocalFallback) Embed(ctx context.Context, text, embedType string) ([]float32, error) {
+	v, err := f.Primary.Embed(ctx, text, embedType)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	if f.record(err) {
+		return v, nil
+	}
+	return f.local.Embed(ctx, text, embedType)
+}
+
+func (f *LocalFallback) EmbedBatch(ctx context.Context, texts []string, embedType string) ([][]float32, error) {
+	vecs, err := f.Primary.EmbedBatch(ctx, texts, embedType)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	if f.record(err) {
+		return vecs, nil
+	}
+	return f.local.EmbedBatch(ctx, texts, embedType)
+}
+
+// EmbeddingModel names Primary's model; local vectors are told apart by IsLocal.
+func (f *LocalFallback) EmbeddingModel() string {
+	return core.EmbeddingModel(f.Primary)
+}
+
+// record notes whether Primary worked and reports it.
+func (f *LocalFallback) record(err error) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		if f.down {
+			log.Printf("[EMBED] Embedding provider is back")
+		}
+		f.down = false
+		return true
+	}
+	if !f.down {
+		log.Printf("[EMBED] Embedding provider failed, using local vectors until it recovers: %v", err)
+	}
+	f.down = true
+	return false
+}
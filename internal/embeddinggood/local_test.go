@@ -0,0 +1,76 @@
+package embeddinggood
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func dot(a, b []float32) float32 {
+	var d float32
+	for i := range a {
+		d += a[i] * b[i]
+	}
+	return d
+}
+
+func TestLocalVectorsMatchSharedWords(t *testing.T) {
+	ctx := context.Background()
+	var l Local
+	q, _ := l.Embed(ctx, "When is the team meeting?", "query")
+	near, _ := l.Embed(ctx, "Team meetings are on Fridays", "document")
+	far, _ := l.Embed(ctx, "Buy groceries after work", "document")
+	if len(q) != LocalDimension || !IsLocal(near) {
+		t.Fatalf("dimension = %d", len(q))
+	}
+	if a, b := dot(q, near), dot(q, far); a <= b {
+		t.Errorf("similar text scored %.3f, unrelated %.3f", a, b)
+	}
+	if empty, _ := l.Embed(ctx, "", "query"); dot(empty, empty) == 0 {
+		t.Error("empty text gave a zero vector")
+	}
+}
+
+// flakyEmbedder fails while down.
+type flakyEmbedder struct{ down bool }
+
+func (f *flakyEmbedder) Embed(ctx context.Context, text, embedType string) ([]float32, error) {
+	if f.down {
+		return nil, errors.New("connection refused")
+	}
+	return []float32{1, 0}, nil
+}
+
+func (f *flakyEmbedder) EmbedBatch(ctx context.Context, texts []string, embedType string) ([][]float32, error) {
+	if f.down {
+		return nil, errors.New("connection refused")
+	}
+	out := make([][]float32, len(texts))
+	for i := range texts {
+		out[i] = []float32{1, 0}
+	}
+	return out, nil
+}
+
+func TestLocalFallback(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyEmbedder{down: true}
+	f := WithLocalFallback(primary)
+	if v, err := f.Embed(ctx, "hello", "query"); err != nil || !IsLocal(v) {
+		t.Fatalf("during outage = %d dims, %v", len(v), err)
+	}
+	if vecs, err := f.EmbedBatch(ctx, []string{"a", "b"}, "document"); err != nil || len(vecs) != 2 || !IsLocal(vecs[1]) {
+		t.Fatalf("batch during outage = %v, %v", vecs, err)
+	}
+	primary.down = false
+	if v, err := f.Embed(ctx, "hello", "query"); err != nil || len(v) != 2 {
+		t.Fatalf("after recovery = %v, %v", v, err)
+	}
+
+	primary.down = true
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := f.Embed(canceled, "hello", "query"); err == nil {
+		t.Error("canceled call fell back instead of failing")
+	}
+}
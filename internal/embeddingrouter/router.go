@@ -9,11 +9,12 @@ import (
 
 	"github.com/hattiebot/hattiebot/internal/core"
 	"github.com/hattiebot/hattiebot/internal/embeddinggood"
+	"github.com/hattiebot/hattiebot/internal/ollama"
 	"github.com/hattiebot/hattiebot/internal/store"
 )
 
 // Router implements core.EmbeddingClient by resolving the default provider from embedding_routing.json
-// and delegating to the corresponding EmbeddingGood client.
+// and delegating to the corresponding EmbeddingGood or Ollama client.
 type Router struct {
 	Config    *store.EmbeddingRoutingConfig
 	ConfigDir string // when set, getClient() reloads config from disk and invalidates cache when config changes
@@ -57,6 +58,51 @@ func (r *Router) Embed(ctx context.Context, text string, embedType string) ([]fl
 	return nil, nil
 }
 
+// EmbedBatch is Embed for several texts: the primary provider's batch call, else Fallback's.
+func (r *Router) EmbedBatch(ctx context.Context, texts []string, embedType string) ([][]float32, error) {
+	c, err := r.getClient()
+	if c != nil && err == nil {
+		out, err := c.EmbedBatch(ctx, texts, embedType)
+		if err == nil {
+			return out, nil
+		}
+		log.Printf("[EMBEDROUTER] primary batch failed: %v; falling back", err)
+	}
+	if r.Fallback != nil {
+		return r.Fallback.EmbedBatch(ctx, texts, embedType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// EmbeddingModel implements core.EmbeddingModeler: the default provider's name and model
+// identity, or the fallback's when no provider is usable.
+func (r *Router) EmbeddingModel() string {
+	if c, err := r.getClient(); c != nil && err == nil {
+		r.mu.RLock()
+		name := r.Config.DefaultProvider
+		r.mu.RUnlock()
+		return "provider:" + name + ":" + core.EmbeddingModel(c)
+	}
+	return core.EmbeddingModel(r.Fallback)
+}
+
+// Build returns the embedder HattieBot uses: the default provider of embedding_routing.json in
+// configDir, else a single EmbeddingGood service when serviceURL and apiKey are set, else llm's
+// own embeddings. The router and the EmbeddingGood client fall back to llm as well.
+func Build(configDir, serviceURL, apiKey string, dimension int, llm core.LLMClient) core.EmbeddingClient {
+	llmFallback := embeddinggood.NewLLMEmbedWrapper(llm)
+	embedCfg, _ := store.LoadEmbeddingRouting(configDir)
+	if embedCfg != nil && embedCfg.HasDefaultProvider() {
+		return NewRouter(embedCfg, llmFallback, nil, configDir)
+	} else if serviceURL != "" && apiKey != "" {
+		return embeddinggood.NewClient(serviceURL, apiKey, dimension)
+	}
+	return llmFallback
+}
+
 // getClient returns the EmbeddingClient for the default provider; caches by provider name.
 // When ConfigDir is set, re-reads embedding_routing.json and invalidates cache if config changed (hot-reload).
 func (r *Router) getClient() (core.EmbeddingClient, error) {
@@ -94,6 +140,15 @@ func (r *Router) getClient() (core.EmbeddingClient, error) {
 	}
 
 	baseURL := r.getEnv(entry.BaseURLEnv)
+	if entry.Type == "ollama" {
+		// A local server needs no key, and its model decides the dimension
+		if entry.Model == "" {
+			return nil, nil
+		}
+		c = ollama.Embedder{Client: ollama.NewClient(baseURL, entry.Model)}
+		r.cache[name] = c
+		return c, nil
+	}
 	apiKey := r.getEnv(entry.APIKeyEnv)
 	if baseURL == "" || apiKey == "" {
 		return nil, nil
@@ -0,0 +1,198 @@
+// Package errbudget tracks rolling error rates for the agent (provider failures, tool failures,
+// empty model responses) and switches the bot into a throttled state when a rate exceeds its
+// budget. While throttled, callers reduce autonomy: scheduled agent prompts are deferred, a
+// cheaper model is preferred, and restricted tools need the user's explicit approval.
+package errbudget
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/health"
+)
+
+// Event kinds.
+const (
+	Provider = "provider" // LLM API call failed
+	Tool     = "tool"     // tool returned an error
+	Empty    = "empty"    // model returned an empty response
+)
+
+// Defaults for NewBudget.
+const (
+	DefaultWindow      = 15 * time.Minute
+	DefaultTripRate    = 0.5 // throttle when at least half the calls of a kind fail...
+	DefaultRecoverRate = 0.2 // ...and recover once every kind is back below this rate
+	DefaultMinSamples  = 6   // ...counting only kinds with enough calls in the window
+)
+
+type event struct {
+	at     time.Time
+	kind   string
+	failed bool
+}
+
+// Rate is the failure rate of one event kind within the window.
+type Rate struct {
+	Total    int     `json:"total"`
+	Failures int     `json:"failures"`
+	Rate     float64 `json:"rate"`
+}
+
+// Status is a snapshot of the budget for system_status and the prompt.
+type Status struct {
+	Throttled bool            `json:"throttled"`
+	Reason    string          `json:"reason,omitempty"`
+	Since     time.Time       `json:"since,omitempty"`
+	Window    string          `json:"window"`
+	Rates     map[string]Rate `json:"rates"`
+}
+
+// Budget is a rolling-window error budget. The zero value is not usable; use NewBudget.
+// A nil *Budget is never throttled and ignores records.
+type Budget struct {
+	Window      time.Duration
+	TripRate    float64
+	RecoverRate float64
+	MinSamples  int
+	// OnChange is called (outside the lock) when the throttled state flips.
+	OnChange func(throttled bool, reason string)
+
+	mu        sync.Mutex
+	events    []event
+	throttled bool
+	reason    string
+	since     time.Time
+	now       func() time.Time
+}
+
+// NewBudget returns a budget with the default window and thresholds.
+func NewBudget() *Budget {
+	return &Budget{
+		Window:      DefaultWindow,
+		TripRate:    DefaultTripRate,
+		RecoverRate: DefaultRecoverRate,
+		MinSamples:  DefaultMinSamples,
+		now:         time.Now,
+	}
+}
+
+// Record adds an outcome of kind and re-evaluates the throttled state.
+func (b *Budget) Record(kind string, failed bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	now := b.now()
+	b.events = append(b.events, event{at: now, kind: kind, failed: failed})
+	changed, throttled, reason := b.evaluate(now)
+	cb := b.OnChange
+	b.mu.Unlock()
+	if changed && cb != nil {
+		cb(throttled, reason)
+	}
+}
+
+// Throttled reports whether the budget is exhausted. It also lets a throttled budget recover
+// once old failures age out of the window, even if nothing new was recorded.
+func (b *Budget) Throttled() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	changed, throttled, reason := b.evaluate(b.now())
+	cb := b.OnChange
+	b.mu.Unlock()
+	if changed && cb != nil {
+		cb(throttled, reason)
+	}
+	return throttled
+}
+
+// Status returns a snapshot of the current rates and state.
+func (b *Budget) Status() Status {
+	if b == nil {
+		return Status{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prune(b.now())
+	return Status{
+		Throttled: b.throttled,
+		Reason:    b.reason,
+		Since:     b.since,
+		Window:    b.Window.String(),
+		Rates:     b.rates(),
+	}
+}
+
+// HealthCheck reports the budget as a component: degraded while throttled.
+func (b *Budget) HealthCheck() health.ComponentHealth {
+	st := b.Status()
+	h := health.ComponentHealth{Name: "error_budget", Status: "ok", LastOK: time.Now()}
+	if st.Throttled {
+		h.Status = "degraded"
+		h.Message = "self-throttling: " + st.Reason
+		h.LastError = st.Since
+	}
+	return h
+}
+
+// evaluate must be called with b.mu held. It returns whether the state changed.
+func (b *Budget) evaluate(now time.Time) (changed, throttled bool, reason string) {
+	b.prune(now)
+	rates := b.rates()
+	if !b.throttled {
+		if over := b.over(rates, b.TripRate); over != "" {
+			b.throttled, b.reason, b.since = true, over, now
+			return true, true, over
+		}
+		return false, false, ""
+	}
+	if b.over(rates, b.RecoverRate) == "" {
+		b.throttled, b.reason, b.since = false, "", time.Time{}
+		return true, false, "error rates recovered"
+	}
+	return false, true, b.reason
+}
+
+// over describes the kinds whose failure rate is at or above limit, or "" if none are.
+func (b *Budget) over(rates map[string]Rate, limit float64) string {
+	var parts []string
+	for kind, r := range rates {
+		if r.Total >= b.MinSamples && r.Rate >= limit {
+			parts = append(parts, fmt.Sprintf("%s failures %d/%d in %s", kind, r.Failures, r.Total, b.Window))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
+
+func (b *Budget) rates() map[string]Rate {
+	out := map[string]Rate{}
+	for _, e := range b.events {
+		r := out[e.kind]
+		r.Total++
+		if e.failed {
+			r.Failures++
+		}
+		out[e.kind] = r
+	}
+	for kind, r := range out {
+		r.Rate = float64(r.Failures) / float64(r.Total)
+		out[kind] = r
+	}
+	return out
+}
+
+func (b *Budget) prune(now time.Time) {
+	cutoff := now.Add(-b.Window)
+	i := 0
+	for i < len(b.events) && b.events[i].at.Before(cutoff) {
+		i++
+	}
+	b.events = b.events[i:]
+}
@@ -0,0 +1,66 @@
+package errbudget
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetTripsAndRecovers(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBudget()
+	b.now = func() time.Time { return now }
+	var changes []bool
+	b.OnChange = func(throttled bool, reason string) { changes = append(changes, throttled) }
+
+	// Failures below the sample minimum do not trip the budget.
+	for i := 0; i < DefaultMinSamples-1; i++ {
+		b.Record(Provider, true)
+	}
+	if b.Throttled() {
+		t.Fatal("throttled before reaching the sample minimum")
+	}
+	b.Record(Provider, true)
+	if !b.Throttled() {
+		t.Fatal("expected throttle after repeated provider failures")
+	}
+	if st := b.Status(); !strings.Contains(st.Reason, "provider failures 6/6") {
+		t.Errorf("reason = %q", st.Reason)
+	}
+	if h := b.HealthCheck(); h.Status != "degraded" {
+		t.Errorf("health = %+v, want degraded", h)
+	}
+
+	// Successes bring the rate down, but not below the recovery rate yet (6/12 = 50%, 6/20 = 30%).
+	for i := 0; i < 14; i++ {
+		b.Record(Provider, false)
+	}
+	if !b.Throttled() {
+		t.Fatal("recovered above the recovery rate")
+	}
+
+	// Old failures age out of the window.
+	now = now.Add(DefaultWindow + time.Minute)
+	if b.Throttled() {
+		t.Fatal("still throttled after failures left the window")
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("state changes = %v, want [true false]", changes)
+	}
+}
+
+func TestBudgetKindsAreIndependent(t *testing.T) {
+	b := NewBudget()
+	for i := 0; i < 10; i++ {
+		b.Record(Tool, i%4 == 0) // 30% tool failures
+		b.Record(Empty, false)
+	}
+	if b.Throttled() {
+		t.Error("30% tool failures should stay within budget")
+	}
+	var nilBudget *Budget
+	nilBudget.Record(Tool, true)
+	if nilBudget.Throttled() {
+		t.Error("nil budget must never throttle")
+	}
+}
@@ -0,0 +1,102 @@
+package eval
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// scriptedClient calls the weather tool once, then answers with the tool result it got.
+type scriptedClient struct{}
+
+func (scriptedClient) ChatCompletion(ctx context.Context, msgs []core.Message) (string, error) {
+	return "ok", nil
+}
+
+func (scriptedClient) ChatCompletionWithTools(ctx context.Context, msgs []core.Message, defs []core.ToolDefinition) (string, []core.ToolCall, error) {
+	last := msgs[len(msgs)-1]
+	if last.Role == "tool" {
+		return "Berlin is " + last.Content + " today.", nil, nil
+	}
+	call := core.ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "get_weather"
+	call.Function.Arguments = `{"city": "Berlin"}`
+	return "", []core.ToolCall{call}, nil
+}
+
+func (scriptedClient) Embed(ctx context.Context, text string) ([]float32, error) { return nil, nil }
+
+func TestRunScoresScenario(t *testing.T) {
+	s, err := Parse(`
+name: weather
+tools:
+  get_weather: sunny
+turns:
+  - user: What's the weather in Berlin?
+    expect_tools: [get_weather]
+    expect_answer: Berlin is sunny today.
+    expect_contains: [sunny]
+  - user: And in Paris?
+    expect_tools: []
+    forbid_tools: [get_weather]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &Runner{Client: scriptedClient{}, Config: &config.Config{Model: "mock", ConfigDir: t.TempDir()}}
+	res := runner.Run(context.Background(), s)
+	if res.Error != "" || len(res.Turns) != 2 {
+		t.Fatalf("result = %+v", res)
+	}
+	first, second := res.Turns[0], res.Turns[1]
+	if !first.Passed || *first.ToolScore != 1 || *first.AnswerScore < 0.99 {
+		t.Errorf("first turn = %+v", first)
+	}
+	if second.Passed || *second.ToolScore != 0 || len(second.Failures) != 2 {
+		t.Errorf("second turn = %+v, want a failure for the unexpected, forbidden tool call", second)
+	}
+	if res.Passed || res.Score <= 0 || res.Score >= 1 {
+		t.Errorf("scenario = passed %v, score %.2f", res.Passed, res.Score)
+	}
+}
+
+func TestFromThread(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	add := func(role, content, toolCalls, toolCallID string) {
+		if _, err := db.InsertMessage(ctx, role, content, "", "alice", "talk", "room", toolCalls, "", toolCallID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("user", "weather in Berlin?", "", "")
+	add("assistant", "", `[{"id": "c1", "type": "function", "function": {"name": "get_weather", "arguments": "{}"}}]`, "")
+	add("tool", "sunny", "", "c1")
+	add("assistant", "It is sunny.", "", "")
+	add("user", "thanks", "", "")
+	add("assistant", "You're welcome!", "", "")
+
+	s, err := FromThread(ctx, db, "room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Turns) != 2 || s.mockResult("get_weather") != "sunny" {
+		t.Fatalf("scenario = %+v", s)
+	}
+	if got := s.Turns[0]; len(got.ExpectTools) != 1 || got.ExpectAnswer != "It is sunny." {
+		t.Errorf("first turn = %+v", got)
+	}
+	if got := s.Turns[1]; got.ExpectTools == nil || len(got.ExpectTools) != 0 || got.ExpectAnswer != "You're welcome!" {
+		t.Errorf("second turn = %+v, want no tools expected", got)
+	}
+	if _, err := Load("../../eval/scenarios"); err != nil {
+		t.Errorf("bundled scenarios: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/agent"
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// evalUser is the sender of scenario turns; it is the admin of the throwaway database.
+const evalUser = "eval"
+
+// Runner runs scenarios through the agent loop with the given model client.
+type Runner struct {
+	Client core.LLMClient
+	Config *config.Config // SOUL.md, model name and prompt settings; the database is never used
+	// Soul, when set, replaces SOUL.md for every turn (to evaluate a candidate identity).
+	Soul string
+}
+
+// TurnResult is the outcome of one scenario turn. Scores are nil when the turn did not expect
+// anything to score them against.
+type TurnResult struct {
+	User        string        `json:"user"`
+	Answer      string        `json:"answer"`
+	Tools       []string      `json:"tools"`
+	ToolScore   *float64      `json:"tool_score,omitempty"`
+	AnswerScore *float64      `json:"answer_score,omitempty"`
+	Score       float64       `json:"score"`
+	Latency     time.Duration `json:"latency_ns"`
+	Passed      bool          `json:"passed"`
+	Failures    []string      `json:"failures,omitempty"`
+}
+
+// Result is the outcome of a scenario: the mean of its turn scores, and whether every turn passed.
+type Result struct {
+	Scenario string        `json:"scenario"`
+	Turns    []TurnResult  `json:"turns"`
+	Score    float64       `json:"score"`
+	Latency  time.Duration `json:"latency_ns"`
+	Passed   bool          `json:"passed"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Run plays the scenario's turns in one thread of a fresh database and scores each reply.
+func (r *Runner) Run(ctx context.Context, s *Scenario) Result {
+	res := Result{Scenario: s.Name}
+	dir, err := os.MkdirTemp("", "hattiebot-eval-")
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer os.RemoveAll(dir)
+	db, err := store.Open(ctx, filepath.Join(dir, "eval.db"))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer db.Close()
+	if r.Soul != "" {
+		// A prompt experiment that covers every turn gives them the candidate identity
+		if _, err := db.CreatePromptExperiment(ctx, store.PromptExperiment{Name: "eval", Variant: r.Soul, Fraction: 1}); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+	}
+
+	cfg := *r.Config
+	cfg.AdminUserID = evalUser
+	cfg.DBPath = filepath.Join(dir, "eval.db")
+	tools := &mockExecutor{scenario: s}
+	loop := &agent.Loop{
+		Config:   &cfg,
+		DB:       db,
+		Client:   r.Client,
+		Context:  &agent.ContextManager{DB: db},
+		Executor: tools,
+	}
+	minScore := s.MinScore
+	if minScore == 0 {
+		minScore = DefaultMinScore
+	}
+
+	res.Passed = true
+	for _, t := range s.Turns {
+		tools.reset()
+		start := time.Now()
+		answer, err := loop.RunOneTurn(ctx, gateway.Message{SenderID: evalUser, Channel: "eval", ThreadID: "eval", Content: t.User})
+		tr := scoreTurn(t, answer, tools.called(), time.Since(start), minScore)
+		if err != nil {
+			tr.Passed = false
+			tr.Failures = append(tr.Failures, "turn failed: "+err.Error())
+		}
+		res.Turns = append(res.Turns, tr)
+		res.Score += tr.Score
+		res.Latency += tr.Latency
+		res.Passed = res.Passed && tr.Passed
+	}
+	res.Score /= float64(len(res.Turns))
+	return res
+}
+
+// scoreTurn scores a reply against the turn's expectations. The turn score is the mean of the tool
+// and answer scores (1 when neither is expected); forbidden tools, missing phrases and slow
+// replies fail the turn whatever its score.
+func scoreTurn(t Turn, answer string, called []string, latency time.Duration, minScore float64) TurnResult {
+	tr := TurnResult{User: t.User, Answer: answer, Tools: called, Latency: latency}
+	var scores []float64
+	if t.ExpectTools != nil {
+		v := toolScore(t.ExpectTools, called)
+		tr.ToolScore = &v
+		scores = append(scores, v)
+	}
+	if t.ExpectAnswer != "" {
+		v := similarity(t.ExpectAnswer, answer)
+		tr.AnswerScore = &v
+		scores = append(scores, v)
+	}
+	tr.Score = 1
+	if len(scores) > 0 {
+		tr.Score = 0
+		for _, v := range scores {
+			tr.Score += v
+		}
+		tr.Score /= float64(len(scores))
+	}
+	if tr.Score < minScore {
+		tr.Failures = append(tr.Failures, fmt.Sprintf("score %.2f is below %.2f", tr.Score, minScore))
+	}
+	calledSet := set(called)
+	for _, name := range t.ForbidTools {
+		if calledSet[name] {
+			tr.Failures = append(tr.Failures, "called forbidden tool "+name)
+		}
+	}
+	for _, phrase := range t.ExpectContains {
+		if !strings.Contains(strings.ToLower(answer), strings.ToLower(phrase)) {
+			tr.Failures = append(tr.Failures, fmt.Sprintf("answer does not contain %q", phrase))
+		}
+	}
+	if t.MaxLatency != "" {
+		if max, _ := time.ParseDuration(t.MaxLatency); latency > max {
+			tr.Failures = append(tr.Failures, fmt.Sprintf("took %s, more than %s", latency.Round(time.Millisecond), max))
+		}
+	}
+	tr.Passed = len(tr.Failures) == 0
+	return tr
+}
+
+// mockExecutor answers tool calls with the scenario's mocked results and records which tools the
+// agent called, so a scenario never touches real files, services or people.
+type mockExecutor struct {
+	scenario *Scenario
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *mockExecutor) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, name)
+	m.mu.Unlock()
+	return m.scenario.mockResult(name), nil
+}
+
+func (m *mockExecutor) SetSpawner(spawner core.SubmindSpawner) {}
+
+func (m *mockExecutor) reset() {
+	m.mu.Lock()
+	m.calls = nil
+	m.mu.Unlock()
+}
+
+func (m *mockExecutor) called() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.calls...)
+}
@@ -0,0 +1,186 @@
+// Package eval replays golden conversations against the agent loop and scores the outcome, so
+// prompt changes, self-modifications and model swaps can be checked before they are deployed.
+// A scenario is a list of user turns with what the agent is expected to do; tools are mocked, and
+// each scenario runs against a throwaway database.
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// DefaultMinScore is the turn score a scenario must reach when it sets no min_score.
+const DefaultMinScore = 0.6
+
+// Scenario is the document format (YAML or JSON; field names are the JSON tags).
+type Scenario struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Tools maps tool names to the mocked result they return; other tools return {"status": "ok"}.
+	Tools    map[string]json.RawMessage `json:"tools,omitempty"`
+	Turns    []Turn                     `json:"turns"`
+	MinScore float64                    `json:"min_score,omitempty"`
+}
+
+// Turn is one user message and what the reply to it should look like. Expectations left empty are
+// not scored; an empty (not missing) expect_tools list expects no tool calls.
+type Turn struct {
+	User           string   `json:"user"`
+	ExpectTools    []string `json:"expect_tools"`
+	ForbidTools    []string `json:"forbid_tools,omitempty"`
+	ExpectAnswer   string   `json:"expect_answer,omitempty"`
+	ExpectContains []string `json:"expect_contains,omitempty"`
+	MaxLatency     string   `json:"max_latency,omitempty"` // Go duration, e.g. 30s
+}
+
+// Parse reads a scenario from YAML or JSON.
+func Parse(src string) (*Scenario, error) {
+	data := []byte(src)
+	if t := strings.TrimSpace(src); !strings.HasPrefix(t, "{") {
+		// Decode YAML generically and re-encode as JSON, so one set of field tags serves both.
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse scenario yaml: %w", err)
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("parse scenario yaml: %w", err)
+		}
+	}
+	var s Scenario
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse scenario: %w", err)
+	}
+	return &s, s.Validate()
+}
+
+// Validate checks that the scenario can be run.
+func (s *Scenario) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("scenario has no name")
+	}
+	if len(s.Turns) == 0 {
+		return fmt.Errorf("scenario %q has no turns", s.Name)
+	}
+	for i, t := range s.Turns {
+		if strings.TrimSpace(t.User) == "" {
+			return fmt.Errorf("scenario %q turn %d has no user message", s.Name, i+1)
+		}
+		if t.MaxLatency != "" {
+			if _, err := time.ParseDuration(t.MaxLatency); err != nil {
+				return fmt.Errorf("scenario %q turn %d: max_latency: %w", s.Name, i+1, err)
+			}
+		}
+	}
+	if s.MinScore < 0 || s.MinScore > 1 {
+		return fmt.Errorf("scenario %q: min_score must be between 0 and 1", s.Name)
+	}
+	return nil
+}
+
+// mockResult returns the mocked result of a tool.
+func (s *Scenario) mockResult(tool string) string {
+	raw, ok := s.Tools[tool]
+	if !ok {
+		return `{"status": "ok"}`
+	}
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		return str
+	}
+	return string(raw)
+}
+
+// Load reads a scenario file, or every .yaml, .yml and .json file in a directory, sorted by name.
+func Load(path string) ([]*Scenario, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			switch filepath.Ext(e.Name()) {
+			case ".yaml", ".yml", ".json":
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+	var out []*Scenario
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		s, err := Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// recordedThreadLimit caps how many messages of a recorded thread are replayed.
+const recordedThreadLimit = 500
+
+// FromThread turns a recorded conversation into a scenario: each user message is a turn that
+// expects the tools the agent called and the reply it gave then, and tools return what they
+// returned then. Regenerated replies are left out, so the kept answer is the golden one.
+func FromThread(ctx context.Context, db *store.DB, threadID string) (*Scenario, error) {
+	msgs, err := db.ThreadHistory(ctx, threadID, recordedThreadLimit)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scenario{Name: "thread " + threadID, Tools: map[string]json.RawMessage{}}
+	callNames := map[string]string{} // tool call ID -> tool name
+	var turn *Turn
+	for _, m := range msgs {
+		switch m.Role {
+		case "user":
+			s.Turns = append(s.Turns, Turn{User: m.Content, ExpectTools: []string{}})
+			turn = &s.Turns[len(s.Turns)-1]
+		case "assistant":
+			if turn == nil {
+				continue
+			}
+			var calls []openrouter.ToolCall
+			if m.ToolCalls != "" && json.Unmarshal([]byte(m.ToolCalls), &calls) == nil && len(calls) > 0 {
+				for _, c := range calls {
+					callNames[c.ID] = c.Function.Name
+					turn.ExpectTools = append(turn.ExpectTools, c.Function.Name)
+				}
+				continue
+			}
+			if strings.TrimSpace(m.Content) != "" {
+				turn.ExpectAnswer = m.Content
+			}
+		case "tool":
+			if name := callNames[m.ToolCallID]; name != "" {
+				b, _ := json.Marshal(m.Content)
+				s.Tools[name] = b
+			}
+		}
+	}
+	if len(s.Turns) == 0 {
+		return nil, fmt.Errorf("thread %q has no user messages", threadID)
+	}
+	return s, s.Validate()
+}
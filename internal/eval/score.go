@@ -0,0 +1,59 @@
+package eval
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// toolScore compares the tools called in a turn with the expected ones (Jaccard index of the
+// two sets); 1 when both are empty.
+func toolScore(expected, called []string) float64 {
+	want, got := set(expected), set(called)
+	if len(want) == 0 && len(got) == 0 {
+		return 1
+	}
+	both := 0
+	for name := range want {
+		if got[name] {
+			both++
+		}
+	}
+	return float64(both) / float64(len(want)+len(got)-both)
+}
+
+// similarity is the cosine similarity of the word counts of a and b, from 0 (no shared words) to
+// 1 (same words, same proportions). It is lexical: a paraphrase scores lower than a copy.
+func similarity(a, b string) float64 {
+	va, vb := wordCounts(a), wordCounts(b)
+	var dot, na, nb float64
+	for w, n := range va {
+		dot += n * vb[w]
+		na += n * n
+	}
+	for _, n := range vb {
+		nb += n * n
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func wordCounts(s string) map[string]float64 {
+	counts := map[string]float64{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		counts[w]++
+	}
+	return counts
+}
+
+func set(names []string) map[string]bool {
+	out := make(map[string]bool, len(names))
+	for _, n := range names {
+		out[n] = true
+	}
+	return out
+}
@@ -0,0 +1,257 @@
+// Package events delivers bot events (turn completed, tool failed, plan executed, user blocked)
+// to external subscribers, the outbound counterpart of dynamic webhook routes.
+//
+// A subscription is a URL and a secret (see the manage_event_subscriptions tool). Publish stores
+// one delivery per interested subscription in the database, so deliveries survive restarts; the
+// dispatcher POSTs each as JSON signed with HMAC-SHA256 and retries failures with a doubling
+// delay until MaxAttempts.
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/logging"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/version"
+)
+
+// Event types.
+const (
+	TurnCompleted = "turn.completed"
+	ToolFailed    = "tool.failed"
+	PlanExecuted  = "plan.executed"
+	UserBlocked   = "user.blocked"
+	// Ping is sent only by Bus.Test.
+	Ping = "ping"
+)
+
+// Types lists the event types a subscription can ask for.
+var Types = []string{TurnCompleted, ToolFailed, PlanExecuted, UserBlocked}
+
+// Headers of a delivery. The signature is "sha256=" and the hex HMAC-SHA256 of the body with the
+// subscription's secret, the format hmac_sha256 webhook routes check.
+const (
+	SignatureHeader = "X-HattieBot-Signature"
+	EventHeader     = "X-HattieBot-Event"
+	DeliveryHeader  = "X-HattieBot-Delivery"
+)
+
+// Delivery defaults.
+const (
+	MaxAttempts    = 8
+	DefaultTimeout = 10 * time.Second
+	// retryBase is the delay before the second attempt; it doubles up to maxRetryDelay.
+	retryBase     = 30 * time.Second
+	maxRetryDelay = 6 * time.Hour
+	pollInterval  = 15 * time.Second
+	batchSize     = 20
+)
+
+// Event is the JSON body of a delivery.
+type Event struct {
+	ID     string                 `json:"id"`
+	Type   string                 `json:"type"`
+	Time   time.Time              `json:"time"`
+	UserID string                 `json:"user_id,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+// Bus queues events for subscribers and delivers them. A nil *Bus publishes nothing, so callers
+// need not check whether outbound events are configured.
+type Bus struct {
+	DB     *store.DB
+	Client *http.Client // default: DefaultTimeout
+
+	startOnce sync.Once
+	wake      chan struct{}
+	retryBase time.Duration // replaces retryBase (tests)
+}
+
+// Publish queues an event for every subscription that wants its type. It never fails the
+// caller; errors are logged.
+func (b *Bus) Publish(ctx context.Context, eventType, userID string, data map[string]interface{}) {
+	if b == nil || b.DB == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	subs, err := b.DB.ListEventSubscriptions(ctx)
+	if err != nil {
+		logging.For("events").ErrorContext(ctx, "listing subscriptions", "event", eventType, "error", err)
+		return
+	}
+	var ev *Event
+	var body []byte
+	queued := 0
+	for _, s := range subs {
+		if !s.Wants(eventType) {
+			continue
+		}
+		if ev == nil {
+			ev = &Event{ID: newID(), Type: eventType, Time: time.Now().UTC(), UserID: userID, Data: data}
+			if body, err = json.Marshal(ev); err != nil {
+				logging.For("events").ErrorContext(ctx, "encoding event", "event", eventType, "error", err)
+				return
+			}
+		}
+		d := store.EventDelivery{SubscriptionID: s.ID, EventID: ev.ID, EventType: eventType, UserID: userID, Payload: string(body)}
+		if err := b.DB.EnqueueEventDelivery(ctx, d); err != nil {
+			logging.For("events").ErrorContext(ctx, "queueing delivery", "event", eventType, "subscription", s.ID, "error", err)
+			continue
+		}
+		queued++
+	}
+	if queued > 0 {
+		b.signal()
+	}
+}
+
+// Start runs the dispatcher until ctx is done: due deliveries are sent as they are published and
+// on every poll, which picks up retries and deliveries left from before a restart.
+func (b *Bus) Start(ctx context.Context) {
+	if b == nil {
+		return
+	}
+	b.startOnce.Do(func() {
+		b.initWake()
+		go func() {
+			ticker := time.NewTicker(pollInterval)
+			defer ticker.Stop()
+			for {
+				b.DeliverDue(ctx)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				case <-b.wake:
+				}
+			}
+		}()
+	})
+}
+
+func (b *Bus) initWake() {
+	if b.wake == nil {
+		b.wake = make(chan struct{}, 1)
+	}
+}
+
+func (b *Bus) signal() {
+	if b.wake == nil {
+		return
+	}
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// DeliverDue sends the deliveries that are due, in batches, until none are left.
+func (b *Bus) DeliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := b.DB.DueEventDeliveries(ctx, batchSize)
+		if err != nil {
+			logging.For("events").ErrorContext(ctx, "loading due deliveries", "error", err)
+			return
+		}
+		for _, d := range due {
+			err := b.send(ctx, d.URL, d.Secret, d.EventType, d.EventID, []byte(d.Payload))
+			var retryAt time.Time
+			if err != nil && d.Attempts+1 < MaxAttempts {
+				retryAt = time.Now().Add(b.retryDelay(d.Attempts + 1))
+				logging.For("events").WarnContext(ctx, "delivery failed, will retry", "subscription", d.SubscriptionID, "event", d.EventType, "attempt", d.Attempts+1, "retry_at", retryAt, "error", err)
+			} else if err != nil {
+				logging.For("events").ErrorContext(ctx, "delivery failed, giving up", "subscription", d.SubscriptionID, "event", d.EventType, "attempts", d.Attempts+1, "error", err)
+			}
+			if ferr := b.DB.FinishEventAttempt(context.WithoutCancel(ctx), d, err, retryAt); ferr != nil {
+				logging.For("events").ErrorContext(ctx, "recording delivery attempt", "delivery", d.ID, "error", ferr)
+				return
+			}
+		}
+		if len(due) < batchSize {
+			return
+		}
+	}
+}
+
+// retryDelay is the wait after the given number of failed attempts.
+func (b *Bus) retryDelay(attempts int) time.Duration {
+	delay := retryBase
+	if b.retryBase > 0 {
+		delay = b.retryBase
+	}
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// Test sends a ping event to one subscription right away, without queueing or retries.
+func (b *Bus) Test(ctx context.Context, s store.EventSubscription) error {
+	ev := Event{ID: newID(), Type: Ping, Time: time.Now().UTC(), Data: map[string]interface{}{"subscription_id": s.ID}}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return b.send(ctx, s.URL, s.Secret, ev.Type, ev.ID, body)
+}
+
+// send POSTs one signed delivery. Any status outside 2xx is an error.
+func (b *Bus) send(ctx context.Context, url, secret, eventType, eventID string, body []byte) error {
+	client := b.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "HattieBot/"+version.Version)
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, eventID)
+	req.Header.Set(SignatureHeader, Sign(secret, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the signature header value of body: "sha256=" and its hex HMAC-SHA256.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random secret for a subscription that does not bring its own.
+func NewSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
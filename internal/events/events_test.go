@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestPublishDeliversSignedEventsWithRetries(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var mu sync.Mutex
+	var received []Event
+	failures := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign("s3cret", body) {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		var ev Event
+		if err := json.Unmarshal(body, &ev); err != nil || r.Header.Get(EventHeader) != ev.Type || r.Header.Get(DeliveryHeader) != ev.ID {
+			t.Errorf("delivery %s: %v, headers %v", body, err, r.Header)
+		}
+		received = append(received, ev)
+	}))
+	defer srv.Close()
+
+	id, err := db.CreateEventSubscription(ctx, store.EventSubscription{URL: srv.URL, Secret: "s3cret", Events: []string{ToolFailed}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := &Bus{DB: db, retryBase: time.Millisecond}
+	bus.Publish(ctx, TurnCompleted, "alice", nil) // not subscribed
+	bus.Publish(ctx, ToolFailed, "alice", map[string]interface{}{"tool": "deploy"})
+
+	bus.DeliverDue(ctx)
+	ds, err := db.ListEventDeliveries(ctx, id, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 1 || ds[0].Status != store.EventPending || ds[0].Attempts != 1 || ds[0].LastError == "" {
+		t.Fatalf("after a failed attempt: %+v", ds)
+	}
+	sub, _ := db.GetEventSubscription(ctx, id)
+	if sub.LastError == "" || sub.LastDeliveryAt == nil {
+		t.Fatalf("subscription after failure: %+v", sub)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	bus.DeliverDue(ctx)
+	ds, _ = db.ListEventDeliveries(ctx, id, 10)
+	if len(ds) != 1 || ds[0].Status != store.EventDelivered || ds[0].Attempts != 2 {
+		t.Fatalf("after retry: %+v", ds)
+	}
+	if len(received) != 1 || received[0].Type != ToolFailed || received[0].UserID != "alice" || received[0].Data["tool"] != "deploy" {
+		t.Fatalf("received %+v", received)
+	}
+
+	if err := bus.Test(ctx, *sub); err != nil || len(received) != 2 || received[1].Type != Ping {
+		t.Fatalf("test ping: %v, received %+v", err, received)
+	}
+}
+
+func TestDeliveryGivesUpAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer srv.Close()
+
+	id, _ := db.CreateEventSubscription(ctx, store.EventSubscription{URL: srv.URL, Secret: "s"})
+	bus := &Bus{DB: db, retryBase: time.Microsecond}
+	bus.Publish(ctx, UserBlocked, "mallory", nil)
+	for i := 0; i < MaxAttempts+2; i++ {
+		time.Sleep(time.Millisecond)
+		bus.DeliverDue(ctx)
+	}
+	ds, _ := db.ListEventDeliveries(ctx, id, 10)
+	if len(ds) != 1 || ds[0].Status != store.EventFailed || ds[0].Attempts != MaxAttempts {
+		t.Fatalf("deliveries = %+v", ds)
+	}
+}
+
+func TestRetryDelayDoublesUpToCap(t *testing.T) {
+	b := &Bus{}
+	if d := b.retryDelay(1); d != retryBase {
+		t.Errorf("first retry after %s", d)
+	}
+	if d := b.retryDelay(3); d != 4*retryBase {
+		t.Errorf("third retry after %s", d)
+	}
+	if d := b.retryDelay(30); d != maxRetryDelay {
+		t.Errorf("late retry after %s", d)
+	}
+}
+
+func TestNilBusPublishesNothing(t *testing.T) {
+	var b *Bus
+	b.Publish(context.Background(), TurnCompleted, "alice", nil)
+	b.Start(context.Background())
+}
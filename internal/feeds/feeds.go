@@ -0,0 +1,332 @@
+// Package feeds watches RSS and Atom feeds. A Poller checks each subscription on its own
+// interval, stores the items it has not seen before and hands those that pass the feed's keyword
+// filters to the agent as an autonomous task, which summarizes them and tells the user through
+// notify_user if anything is worth it. The first check of a feed only records what is already
+// there.
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+const (
+	// maxFeedBytes caps a downloaded feed.
+	maxFeedBytes = 5 << 20
+	// maxPushItems caps the items in one agent task; the rest wait for the next check.
+	maxPushItems = 10
+	// maxBackoff caps the delay between checks of a failing feed.
+	maxBackoff = 24 * time.Hour
+	// notifyAfterFailures is the number of failed checks in a row after which the user is told.
+	notifyAfterFailures = 5
+)
+
+// DefaultIntervalMin is the check interval of a new subscription.
+const DefaultIntervalMin = 60
+
+// CheckTick is how often the Poller looks for due feeds.
+const CheckTick = time.Minute
+
+// Result describes one check of a feed.
+type Result struct {
+	FeedID      int64  `json:"feed_id"`
+	NotModified bool   `json:"not_modified,omitempty"`
+	Baseline    bool   `json:"baseline,omitempty"` // first fetch: items recorded, nothing pushed
+	New         int    `json:"new"`
+	Matched     int    `json:"matched"`
+	Pushed      int    `json:"pushed"`
+	Deferred    string `json:"deferred,omitempty"` // why matched items still wait for the agent
+}
+
+// Poller checks due feeds and pushes new matching items to the agent.
+type Poller struct {
+	DB *store.DB
+	// Push hands prompt to the agent as an autonomous task for userID in threadID; false when the
+	// agent's queue is full.
+	Push   func(ctx context.Context, userID, threadID, prompt string) bool
+	Client *http.Client // nil = 30 second timeout
+	// Throttle holds matched items back while the error budget is exhausted.
+	Throttle interface{ Throttled() bool }
+	Notify   func(userID, msg string) // told when a feed keeps failing
+
+	now func() time.Time
+	mu  sync.Mutex // one check at a time
+}
+
+func (p *Poller) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// Start checks due feeds every tick until ctx is done.
+func (p *Poller) Start(ctx context.Context, tick time.Duration) {
+	if tick <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			p.CheckDue(ctx)
+		}
+	}()
+}
+
+// CheckDue checks every active feed whose next check time has come.
+func (p *Poller) CheckDue(ctx context.Context) {
+	feeds, err := p.DB.ListFeeds(ctx, "")
+	if err != nil {
+		log.Printf("[Feeds] Listing feeds: %v", err)
+		return
+	}
+	now := p.clock()
+	for i := range feeds {
+		f := &feeds[i]
+		if f.Status != store.FeedActive || (f.NextCheckAt != nil && f.NextCheckAt.After(now)) {
+			continue
+		}
+		if _, err := p.Check(ctx, f); err != nil {
+			log.Printf("[Feeds] Feed %d (%s): %v", f.ID, f.URL, err)
+		}
+	}
+}
+
+// Check fetches f, stores its new items and pushes the pending ones to the agent. A failed fetch
+// is recorded on the feed and checked again after a growing delay.
+func (p *Poller) Check(ctx context.Context, f *store.Feed) (*Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := &Result{FeedID: f.ID}
+	if err := p.check(ctx, f, res); err != nil {
+		delay := time.Duration(f.IntervalMin) * time.Minute << min(f.Failures, 6)
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+		if rerr := p.DB.RecordFeedCheck(ctx, f, err.Error(), p.clock().Add(delay)); rerr != nil {
+			return res, rerr
+		}
+		if f.Failures == notifyAfterFailures && p.Notify != nil {
+			p.Notify(f.UserID, fmt.Sprintf("[Feeds] Feed %d (%s) failed %d times in a row: %v", f.ID, f.URL, f.Failures, err))
+		}
+		return res, err
+	}
+	if err := p.DB.RecordFeedCheck(ctx, f, "", p.clock().Add(time.Duration(f.IntervalMin)*time.Minute)); err != nil {
+		return res, err
+	}
+	return res, p.pushPending(ctx, f, res)
+}
+
+func (p *Poller) check(ctx context.Context, f *store.Feed, res *Result) error {
+	doc, notModified, err := p.fetch(ctx, f)
+	if err != nil {
+		return err
+	}
+	if notModified {
+		res.NotModified = true
+		return nil
+	}
+	if f.Title == "" && doc.Title != "" {
+		f.Title = doc.Title
+		if err := p.DB.UpdateFeed(ctx, f); err != nil {
+			return err
+		}
+	}
+	known, err := p.DB.FeedItems(ctx, f.ID, "", 1)
+	if err != nil {
+		return err
+	}
+	res.Baseline = len(known) == 0
+	// Feeds list the newest item first; store oldest first so IDs follow publication
+	for i := len(doc.Items) - 1; i >= 0; i-- {
+		it := doc.Items[i]
+		state := store.FeedItemSeen
+		if !res.Baseline && Match(f, it) {
+			state = store.FeedItemPending
+		}
+		added, err := p.DB.AddFeedItem(ctx, store.FeedItem{FeedID: f.ID, GUID: it.GUID, Title: it.Title, Link: it.Link,
+			Summary: it.Summary, PublishedAt: it.Published, State: state})
+		if err != nil {
+			return err
+		}
+		if added {
+			res.New++
+			if state == store.FeedItemPending {
+				res.Matched++
+			}
+		}
+	}
+	return p.DB.PruneFeedItems(ctx, f.ID)
+}
+
+// fetch downloads f with the validators of the last fetch; notModified when the server says
+// nothing changed.
+func (p *Poller) fetch(ctx context.Context, f *store.Feed) (doc *Doc, notModified bool, err error) {
+	req, err := newRequest(ctx, f.URL)
+	if err != nil {
+		return nil, false, err
+	}
+	if f.ETag != "" {
+		req.Header.Set("If-None-Match", f.ETag)
+	}
+	if f.LastModified != "" {
+		req.Header.Set("If-Modified-Since", f.LastModified)
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, true, nil
+	}
+	doc, err = readFeed(resp)
+	if err != nil {
+		return nil, false, err
+	}
+	f.ETag, f.LastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	return doc, false, nil
+}
+
+// Preview fetches and parses rawURL, so a subscription can be checked before it is saved.
+func (p *Poller) Preview(ctx context.Context, rawURL string) (*Doc, error) {
+	req, err := newRequest(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readFeed(resp)
+}
+
+func (p *Poller) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+func newRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("feed url must be an http(s) URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "HattieBot feed reader")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+	return req, nil
+}
+
+func readFeed(resp *http.Response) (*Doc, error) {
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("feed answered %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFeedBytes {
+		return nil, fmt.Errorf("feed is larger than %d MB", maxFeedBytes>>20)
+	}
+	return Parse(strings.NewReader(string(data)))
+}
+
+// Match reports whether it passes f's filters: no exclude keyword, and an include keyword unless
+// there are none. Keywords match case-insensitively anywhere in the title or summary.
+func Match(f *store.Feed, it Item) bool {
+	text := strings.ToLower(it.Title + " " + it.Summary)
+	for _, k := range f.Exclude {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" && strings.Contains(text, k) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, k := range f.Include {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" && strings.Contains(text, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// pushPending hands up to maxPushItems pending items of f to the agent, oldest first.
+func (p *Poller) pushPending(ctx context.Context, f *store.Feed, res *Result) error {
+	if p.Push == nil {
+		return nil
+	}
+	items, err := p.DB.FeedItems(ctx, f.ID, store.FeedItemPending, 1000)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	if p.Throttle != nil && p.Throttle.Throttled() {
+		res.Deferred = "self-throttled"
+		return nil
+	}
+	// FeedItems is newest first
+	if len(items) > maxPushItems {
+		items = items[len(items)-maxPushItems:]
+	}
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	if !p.Push(ctx, f.UserID, fmt.Sprintf("feed:%d", f.ID), Prompt(f, items)) {
+		res.Deferred = "agent queue full"
+		return nil
+	}
+	ids := make([]int64, len(items))
+	for i, it := range items {
+		ids[i] = it.ID
+	}
+	res.Pushed = len(items)
+	return p.DB.MarkFeedItemsPushed(ctx, ids)
+}
+
+// Prompt is the agent task for new items of f.
+func Prompt(f *store.Feed, items []store.FeedItem) string {
+	var b strings.Builder
+	name := f.Title
+	if name == "" {
+		name = f.URL
+	}
+	fmt.Fprintf(&b, "[Feed] %d new item(s) in %q (feed %d, %s):\n", len(items), name, f.ID, f.URL)
+	for i, it := range items {
+		fmt.Fprintf(&b, "\n%d. %s\n", i+1, it.Title)
+		if it.PublishedAt != nil {
+			fmt.Fprintf(&b, "   Published: %s\n", it.PublishedAt.Format(time.RFC1123))
+		}
+		if it.Link != "" {
+			fmt.Fprintf(&b, "   %s\n", it.Link)
+		}
+		if it.Summary != "" {
+			fmt.Fprintf(&b, "   %s\n", it.Summary)
+		}
+	}
+	b.WriteString("\nSummarize what is new and worth knowing and send it to the user with notify_user (include the links). If none of it is interesting, finish without notifying.")
+	if f.Instructions != "" {
+		b.WriteString("\nInstructions for this feed: " + f.Instructions)
+	}
+	return b.String()
+}
@@ -0,0 +1,244 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+const rss = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel><title>Release notes</title>
+<item><title>v2.0 &amp; security fix</title><link>https://example.com/v2</link><guid>v2</guid>
+<pubDate>Sat, 17 Oct 2026 09:00:00 +0200</pubDate><description>&lt;p&gt;Fixes CVE-2026-1 &nbsp;in the parser&lt;/p&gt;</description></item>
+<item><title>v1.9</title><link>https://example.com/v1.9</link><content:encoded><![CDATA[<b>Bug fixes</b>]]></content:encoded></item>
+</channel></rss>`
+
+const atom = `<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title>
+<entry><id>tag:blog,2026:1</id><title type="html">Hello &lt;em&gt;world&lt;/em&gt;</title>
+<link rel="alternate" href="https://blog.example.com/1"/><link rel="edit" href="https://blog.example.com/edit/1"/>
+<updated>2026-10-16T08:00:00Z</updated><summary>First post</summary></entry>
+</feed>`
+
+const rdf = `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="https://news.example.com/"><title>News</title></channel>
+<item rdf:about="https://news.example.com/a"><title>A</title><link>https://news.example.com/a</link><dc:date>2026-10-15T07:00:00Z</dc:date></item>
+</rdf:RDF>`
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(strings.NewReader(rss))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Release notes" || len(doc.Items) != 2 {
+		t.Fatalf("rss = %+v", doc)
+	}
+	v2 := doc.Items[0]
+	if v2.GUID != "v2" || v2.Title != "v2.0 & security fix" || v2.Summary != "Fixes CVE-2026-1 in the parser" ||
+		v2.Published == nil || !v2.Published.Equal(time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("rss item = %+v", v2)
+	}
+	if v19 := doc.Items[1]; v19.GUID != "https://example.com/v1.9" || v19.Summary != "Bug fixes" {
+		t.Errorf("item without guid = %+v", v19)
+	}
+
+	doc, err = Parse(strings.NewReader(atom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := doc.Items[0]; doc.Title != "Blog" || e.GUID != "tag:blog,2026:1" || e.Title != "Hello world" ||
+		e.Link != "https://blog.example.com/1" || e.Published == nil {
+		t.Errorf("atom = %+v", doc)
+	}
+
+	doc, err = Parse(strings.NewReader(rdf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "News" || len(doc.Items) != 1 || doc.Items[0].GUID != "https://news.example.com/a" || doc.Items[0].Published == nil {
+		t.Errorf("rdf = %+v", doc)
+	}
+
+	if _, err := Parse(strings.NewReader("<html><body>Not a feed</body></html>")); err == nil {
+		t.Error("HTML page parsed as a feed")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	f := &store.Feed{Include: []string{"Security", "CVE"}, Exclude: []string{"beta"}}
+	for _, c := range []struct {
+		item Item
+		want bool
+	}{
+		{Item{Title: "Security release 2.0"}, true},
+		{Item{Title: "2.1", Summary: "fixes cve-2026-2"}, true},
+		{Item{Title: "Security release 3.0 beta"}, false},
+		{Item{Title: "New logo"}, false},
+	} {
+		if got := Match(f, c.item); got != c.want {
+			t.Errorf("Match(%q) = %v", c.item.Title, got)
+		}
+	}
+	if !Match(&store.Feed{}, Item{Title: "anything"}) {
+		t.Error("feed without filters skipped an item")
+	}
+}
+
+// feedServer serves an RSS feed with the given item titles (newest first) and answers
+// If-None-Match with 304.
+type feedServer struct {
+	mu      sync.Mutex
+	titles  []string
+	etag    string
+	status  int
+	fetches int
+}
+
+func (s *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	if s.etag != "" && r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	var b strings.Builder
+	b.WriteString(`<rss><channel><title>Project news</title>`)
+	for _, title := range s.titles {
+		fmt.Fprintf(&b, `<item><title>%s</title><guid>%s</guid></item>`, title, title)
+	}
+	b.WriteString(`</channel></rss>`)
+	w.Header().Set("ETag", s.etag)
+	w.Write([]byte(b.String()))
+}
+
+func (s *feedServer) set(etag string, titles ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.etag, s.titles = etag, titles
+}
+
+type throttle bool
+
+func (t *throttle) Throttled() bool { return bool(*t) }
+
+func TestPoller(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	srv := &feedServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	var prompts []string
+	var throttled throttle
+	var notified []string
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	p := &Poller{DB: db, Throttle: &throttled, now: func() time.Time { return now }}
+	p.Push = func(ctx context.Context, userID, threadID, prompt string) bool {
+		if userID != "admin" || threadID == "" {
+			t.Errorf("pushed to %s in %q", userID, threadID)
+		}
+		prompts = append(prompts, prompt)
+		return true
+	}
+	p.Notify = func(userID, msg string) { notified = append(notified, msg) }
+
+	id, err := db.CreateFeed(ctx, store.Feed{UserID: "admin", URL: ts.URL, IntervalMin: 30, Exclude: []string{"nightly"},
+		Instructions: "Only mention security fixes."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateFeed(ctx, store.Feed{UserID: "admin", URL: ts.URL, IntervalMin: 30}); err == nil {
+		t.Error("duplicate subscription accepted")
+	}
+	feed := func() *store.Feed {
+		f, err := db.GetFeed(ctx, id)
+		if err != nil || f == nil {
+			t.Fatalf("feed %d: %v", id, err)
+		}
+		return f
+	}
+
+	// The first check records the existing items without pushing them
+	srv.set(`"1"`, "v1.1", "v1.0")
+	p.CheckDue(ctx)
+	f := feed()
+	if len(prompts) != 0 || f.Title != "Project news" || f.NextCheckAt == nil || !f.NextCheckAt.Equal(now.Add(30*time.Minute)) {
+		t.Fatalf("after baseline: prompts %v, feed %+v", prompts, f)
+	}
+
+	// Not due yet, then unchanged (304)
+	p.CheckDue(ctx)
+	if srv.fetches != 1 {
+		t.Errorf("fetched %d times before the feed was due", srv.fetches)
+	}
+	now = now.Add(31 * time.Minute)
+	p.CheckDue(ctx)
+	if srv.fetches != 2 || len(prompts) != 0 {
+		t.Errorf("304 check: %d fetches, prompts %v", srv.fetches, prompts)
+	}
+
+	// New items: the filtered one is stored but not pushed, the rest go out in one prompt
+	srv.set(`"2"`, "v1.3", "nightly 2026-10-17", "v1.2", "v1.1", "v1.0")
+	res, err := p.Check(ctx, feed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.New != 3 || res.Matched != 2 || res.Pushed != 2 || len(prompts) != 1 {
+		t.Fatalf("result %+v, prompts %v", res, prompts)
+	}
+	if pr := prompts[0]; !strings.Contains(pr, "1. v1.2") || !strings.Contains(pr, "2. v1.3") || strings.Contains(pr, "nightly") ||
+		!strings.Contains(pr, "Only mention security fixes.") {
+		t.Errorf("prompt = %s", pr)
+	}
+
+	// While throttled, matched items wait and go out with the next check
+	throttled = true
+	srv.set(`"3"`, "v1.4", "v1.3")
+	if res, _ := p.Check(ctx, feed()); res.Matched != 1 || res.Pushed != 0 || res.Deferred == "" {
+		t.Errorf("throttled result = %+v", res)
+	}
+	throttled = false
+	if res, _ := p.Check(ctx, feed()); !res.NotModified || res.Pushed != 1 || len(prompts) != 2 {
+		t.Errorf("after throttle: %+v, %d prompts", res, len(prompts))
+	}
+	items, _ := db.FeedItems(ctx, id, "", 100)
+	if len(items) != 6 || items[0].Title != "v1.4" || items[0].State != store.FeedItemPushed {
+		t.Errorf("items = %+v", items)
+	}
+
+	// Failures back off (30 minutes doubled per failure) and the user hears about it once
+	srv.mu.Lock()
+	srv.status = http.StatusInternalServerError
+	srv.mu.Unlock()
+	for i := 0; i < 6; i++ {
+		p.Check(ctx, feed())
+	}
+	f = feed()
+	if f.Failures != 6 || f.LastError == "" || len(notified) != 1 || !f.NextCheckAt.Equal(now.Add(16*time.Hour)) {
+		t.Errorf("after failures: feed %+v, notified %v", f, notified)
+	}
+
+	if err := db.DeleteFeed(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := db.FeedItems(ctx, id, "", 100); len(items) != 0 {
+		t.Errorf("items left after unsubscribe: %d", len(items))
+	}
+}
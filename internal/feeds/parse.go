@@ -0,0 +1,182 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxSummaryRunes caps an item's stored summary.
+const maxSummaryRunes = 500
+
+// Doc is a parsed feed.
+type Doc struct {
+	Title string
+	Items []Item
+}
+
+// Item is an entry of a feed. GUID falls back to the link, then the title, so items without an
+// id still dedupe.
+type Item struct {
+	GUID      string
+	Title     string
+	Link      string
+	Summary   string // plain text, truncated
+	Published *time.Time
+}
+
+// rawFeed covers RSS 2.0 (<rss><channel><item>), RSS 1.0 (<rdf:RDF><channel/><item>) and Atom
+// (<feed><entry>); the root element name tells them apart.
+type rawFeed struct {
+	XMLName xml.Name
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rawItem `xml:"item"`
+	} `xml:"channel"`
+	Title   string     `xml:"title"`
+	Items   []rawItem  `xml:"item"`  // RSS 1.0 puts items next to the channel
+	Entries []rawEntry `xml:"entry"` // Atom
+}
+
+type rawItem struct {
+	GUID        string `xml:"guid"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+}
+
+type rawEntry struct {
+	ID    string `xml:"id"`
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+// Parse reads an RSS or Atom document.
+func Parse(r io.Reader) (*Doc, error) {
+	dec := xml.NewDecoder(r)
+	dec.Strict = false // real feeds carry HTML entities and stray ampersands
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = charsetReader
+	var raw rawFeed
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("not an RSS or Atom feed: %w", err)
+	}
+	doc := &Doc{}
+	switch strings.ToLower(raw.XMLName.Local) {
+	case "rss", "rdf":
+		doc.Title = clean(raw.Channel.Title)
+		for _, it := range append(raw.Channel.Items, raw.Items...) {
+			item := Item{
+				Title: clean(it.Title),
+				Link:  strings.TrimSpace(it.Link),
+				GUID:  firstNonEmpty(it.GUID, it.About, it.Link, it.Title),
+			}
+			item.Summary = summarize(firstNonEmpty(it.Description, it.Encoded))
+			item.Published = parseDate(firstNonEmpty(it.PubDate, it.Date))
+			doc.Items = append(doc.Items, item)
+		}
+	case "feed":
+		doc.Title = clean(raw.Title)
+		for _, e := range raw.Entries {
+			item := Item{Title: clean(e.Title)}
+			for _, l := range e.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					item.Link = strings.TrimSpace(l.Href)
+					break
+				}
+			}
+			item.GUID = firstNonEmpty(e.ID, item.Link, e.Title)
+			item.Summary = summarize(firstNonEmpty(e.Summary, e.Content))
+			item.Published = parseDate(firstNonEmpty(e.Published, e.Updated))
+			doc.Items = append(doc.Items, item)
+		}
+	default:
+		return nil, fmt.Errorf("not an RSS or Atom feed (root element <%s>)", raw.XMLName.Local)
+	}
+	var items []Item
+	for _, it := range doc.Items {
+		if it.GUID = strings.TrimSpace(it.GUID); it.GUID != "" {
+			items = append(items, it)
+		}
+	}
+	doc.Items = items
+	return doc, nil
+}
+
+// charsetReader accepts the encodings besides UTF-8 that feeds still declare.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1", "windows-1252", "cp1252":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		for _, c := range data {
+			b.WriteRune(rune(c))
+		}
+		return &b, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z, time.RFC1123, time.RFC3339, time.RFC3339Nano,
+	"Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST", "2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04 -0700", "2006-01-02T15:04:05", "2006-01-02",
+}
+
+func parseDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+var tagRE = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// clean turns text that may hold HTML into a single line of plain text.
+func clean(s string) string {
+	s = html.UnescapeString(tagRE.ReplaceAllString(s, " "))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func summarize(s string) string {
+	s = clean(s)
+	if utf8.RuneCountInString(s) > maxSummaryRunes {
+		s = string([]rune(s)[:maxSummaryRunes]) + "…"
+	}
+	return s
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,221 @@
+// Package filestore stores files by key in a local directory, a WebDAV collection (such as the
+// bot user's Nextcloud files) or an S3 bucket, so the file_store tool and backups work the same
+// on every deployment. Stores are named in config.json ("file_stores"); "nextcloud" is always
+// available when Nextcloud credentials are configured.
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+)
+
+// Store keeps files by key, a slash-separated path relative to the store's root.
+// Get and Delete of a missing key return an error matching fs.ErrNotExist.
+type Store interface {
+	// String describes the store for status output (never includes credentials).
+	String() string
+	// Put writes a file, creating missing folders and replacing an existing file.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the files and folders directly in dir ("" = the root), sorted by key.
+	// A missing dir is empty.
+	List(ctx context.Context, dir string) ([]Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Object is a file or folder in a store.
+type Object struct {
+	Key      string    `json:"key"`
+	Dir      bool      `json:"dir,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	Modified time.Time `json:"modified,omitempty"`
+}
+
+// NextcloudName is the store of the bot user's Nextcloud files.
+const NextcloudName = "nextcloud"
+
+// Names returns the stores available with cfg, sorted.
+func Names(cfg *config.Config) []string {
+	var names []string
+	for name := range cfg.FileStores {
+		names = append(names, name)
+	}
+	if _, ok := cfg.FileStores[NextcloudName]; !ok && nextcloudConfigured(cfg) {
+		names = append(names, NextcloudName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open returns the store called name. An empty name is cfg.DefaultFileStore, or the only
+// available store.
+func Open(cfg *config.Config, name string) (Store, error) {
+	if name == "" {
+		name = cfg.DefaultFileStore
+	}
+	if name == "" {
+		names := Names(cfg)
+		if len(names) != 1 {
+			return nil, fmt.Errorf("no file store given and %d configured (%s); set default_file_store in config.json", len(names), strings.Join(names, ", "))
+		}
+		name = names[0]
+	}
+	if spec, ok := cfg.FileStores[name]; ok {
+		s, err := New(spec)
+		if err != nil {
+			return nil, fmt.Errorf("file store %q: %w", name, err)
+		}
+		return s, nil
+	}
+	if name == NextcloudName && nextcloudConfigured(cfg) {
+		return Nextcloud(cfg), nil
+	}
+	return nil, fmt.Errorf("unknown file store %q (available: %s)", name, strings.Join(Names(cfg), ", "))
+}
+
+// New builds a store from its config.json entry.
+func New(spec config.FileStore) (Store, error) {
+	switch spec.Type {
+	case "local":
+		if spec.Dir == "" {
+			return nil, fmt.Errorf("local store needs dir")
+		}
+		return &Local{Dir: spec.Dir}, nil
+	case "webdav":
+		if spec.URL == "" {
+			return nil, fmt.Errorf("webdav store needs url")
+		}
+		var s Store = &WebDAV{URL: strings.TrimRight(spec.URL, "/"), User: spec.User, Password: spec.Password}
+		if dir := strings.Trim(spec.Dir, "/"); dir != "" {
+			s = Sub(s, dir)
+		}
+		return s, nil
+	case "s3":
+		if spec.Bucket == "" {
+			return nil, fmt.Errorf("s3 store needs bucket")
+		}
+		s := &S3{Bucket: spec.Bucket, Prefix: strings.Trim(spec.Prefix, "/"), Region: spec.Region, Endpoint: spec.URL,
+			AccessKey: spec.AccessKey, SecretKey: spec.SecretKey}
+		if s.AccessKey == "" && s.SecretKey == "" {
+			s.AccessKey, s.SecretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		if s.AccessKey == "" || s.SecretKey == "" {
+			return nil, fmt.Errorf("s3 store needs access_key and secret_key (or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown store type %q (use local, webdav or s3)", spec.Type)
+}
+
+func nextcloudConfigured(cfg *config.Config) bool {
+	return cfg.NextcloudURL != "" && cfg.NextcloudBotUser != "" && cfg.NextcloudBotAppPassword != ""
+}
+
+// Nextcloud returns the bot user's Nextcloud files as a WebDAV store.
+func Nextcloud(cfg *config.Config) *WebDAV {
+	return &WebDAV{
+		URL:      strings.TrimRight(cfg.NextcloudURL, "/") + "/remote.php/dav/files/" + url.PathEscape(cfg.NextcloudBotUser),
+		User:     cfg.NextcloudBotUser,
+		Password: cfg.NextcloudBotAppPassword,
+	}
+}
+
+// CleanKey normalizes key and rejects ".." segments and the empty key.
+func CleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	for _, seg := range strings.Split(key, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("invalid key %q: '..' is not allowed", key)
+		}
+	}
+	key = strings.Trim(path.Clean("/"+key), "/")
+	if key == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	return key, nil
+}
+
+// cleanDir is CleanKey for List, where "" is the root.
+func cleanDir(dir string) (string, error) {
+	if strings.Trim(dir, "/. ") == "" {
+		return "", nil
+	}
+	return CleanKey(dir)
+}
+
+// contentType guesses a file's MIME type from its extension.
+func contentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Sub returns the part of s below dir, with keys relative to dir.
+func Sub(s Store, dir string) Store {
+	return &subStore{s: s, dir: strings.Trim(dir, "/")}
+}
+
+type subStore struct {
+	s   Store
+	dir string
+}
+
+func (s *subStore) String() string { return strings.TrimRight(s.s.String(), "/") + "/" + s.dir }
+
+func (s *subStore) key(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.dir + "/" + key, nil
+}
+
+func (s *subStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	k, err := s.key(key)
+	if err != nil {
+		return err
+	}
+	return s.s.Put(ctx, k, r, size)
+}
+
+func (s *subStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	k, err := s.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.s.Get(ctx, k)
+}
+
+func (s *subStore) List(ctx context.Context, dir string) ([]Object, error) {
+	dir, err := cleanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := s.s.List(ctx, path.Join(s.dir, dir))
+	if err != nil {
+		return nil, err
+	}
+	for i := range objs {
+		objs[i].Key = strings.TrimPrefix(objs[i].Key, s.dir+"/")
+	}
+	return objs, nil
+}
+
+func (s *subStore) Delete(ctx context.Context, key string) error {
+	k, err := s.key(key)
+	if err != nil {
+		return err
+	}
+	return s.s.Delete(ctx, k)
+}
@@ -0,0 +1,275 @@
+package filestore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 keeps files in an S3 bucket. Requests are signed with AWS Signature Version 4, so
+// S3-compatible stores (MinIO, Backblaze B2, Cloudflare R2) work through Endpoint.
+type S3 struct {
+	Bucket    string
+	Prefix    string // key prefix without slashes at either end
+	Region    string // "" = us-east-1
+	Endpoint  string // "" = AWS (virtual-hosted style); otherwise path-style requests to this URL
+	AccessKey string
+	SecretKey string
+	Client    *http.Client // nil = 10 minute timeout
+
+	now func() time.Time
+}
+
+func (t *S3) String() string {
+	if t.Prefix == "" {
+		return "s3://" + t.Bucket
+	}
+	return "s3://" + t.Bucket + "/" + t.Prefix
+}
+
+func (t *S3) region() string {
+	if t.Region == "" {
+		return "us-east-1"
+	}
+	return t.Region
+}
+
+// key returns the object key of a store key ("" = the prefix itself).
+func (t *S3) key(name string) string {
+	if t.Prefix == "" || name == "" {
+		return t.Prefix + name
+	}
+	return t.Prefix + "/" + name
+}
+
+// objectURL returns the URL of key ("" = the bucket itself).
+func (t *S3) objectURL(key string) (*url.URL, error) {
+	base, p := "https://"+t.Bucket+".s3."+t.region()+".amazonaws.com", "/"+key
+	if t.Endpoint != "" {
+		base, p = strings.TrimRight(t.Endpoint, "/"), strings.TrimSuffix("/"+t.Bucket+"/"+key, "/")
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += p
+	segs := strings.Split(u.Path, "/")
+	for i, s := range segs {
+		segs[i] = awsEscape(s)
+	}
+	u.RawPath = strings.Join(segs, "/")
+	return u, nil
+}
+
+func (t *S3) do(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64, header map[string]string) (*http.Response, error) {
+	u, err := t.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	t.sign(req)
+	client := t.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound && key != "" {
+			return nil, fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("S3 %s %s: %d %s", method, key, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// sign adds a Signature Version 4 Authorization header. The payload is not hashed
+// (UNSIGNED-PAYLOAD); TLS protects it in transit.
+func (t *S3) sign(req *http.Request) {
+	now := time.Now
+	if t.now != nil {
+		now = t.now
+	}
+	ts := now().UTC()
+	amzDate := ts.Format("20060102T150405Z")
+	day := ts.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", "UNSIGNED-PAYLOAD")
+
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:UNSIGNED-PAYLOAD\nx-amz-date:" + amzDate + "\n",
+		signed,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	scope := day + "/" + t.region() + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+t.SecretKey), day)
+	key = hmacSHA256(key, t.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+t.AccessKey+"/"+scope+", SignedHeaders="+signed+", Signature="+sig)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscape percent-encodes everything but the unreserved characters, as SigV4 requires.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func (t *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(ctx, http.MethodPut, t.key(key), nil, r, size, map[string]string{"Content-Type": contentType(key)})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (t *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.do(ctx, http.MethodGet, t.key(key), nil, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// List lists with delimiter "/", so keys below dir's subfolders come back as folders.
+func (t *S3) List(ctx context.Context, dir string) ([]Object, error) {
+	dir, err := cleanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := t.key(dir)
+	if prefix != "" {
+		prefix += "/"
+	}
+	var objs []Object
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}, "delimiter": {"/"}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := t.do(ctx, http.MethodGet, "", q, nil, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		var out struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			CommonPrefixes []struct {
+				Prefix string `xml:"Prefix"`
+			} `xml:"CommonPrefixes"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("S3 list: %w", err)
+		}
+		for _, c := range out.Contents {
+			name := strings.TrimPrefix(c.Key, prefix)
+			if name != "" && !strings.Contains(name, "/") {
+				objs = append(objs, Object{Key: path.Join(dir, name), Size: c.Size, Modified: c.LastModified})
+			}
+		}
+		for _, p := range out.CommonPrefixes {
+			if name := strings.Trim(strings.TrimPrefix(p.Prefix, prefix), "/"); name != "" {
+				objs = append(objs, Object{Key: path.Join(dir, name), Dir: true})
+			}
+		}
+		if !out.IsTruncated || out.NextContinuationToken == "" {
+			break
+		}
+		token = out.NextContinuationToken
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Key < objs[j].Key })
+	return objs, nil
+}
+
+// Delete cannot tell a missing key apart: S3 answers 204 either way.
+func (t *S3) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(ctx, http.MethodDelete, t.key(key), nil, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
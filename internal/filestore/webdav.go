@@ -0,0 +1,202 @@
+package filestore
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WebDAV keeps files in a WebDAV collection, such as the bot user's Nextcloud files.
+type WebDAV struct {
+	URL      string // collection URL all keys are relative to
+	User     string
+	Password string
+	Client   *http.Client // nil = 10 minute timeout
+}
+
+func (s *WebDAV) String() string { return "webdav:" + s.URL }
+
+// url returns the URL of a key ("" = the collection itself).
+func (s *WebDAV) url(key string) string {
+	u := strings.TrimRight(s.URL, "/")
+	for _, seg := range strings.Split(key, "/") {
+		if seg != "" {
+			u += "/" + url.PathEscape(seg)
+		}
+	}
+	return u
+}
+
+func (s *WebDAV) do(ctx context.Context, method, u string, body io.Reader, size int64, header map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if s.User != "" {
+		req.SetBasicAuth(s.User, s.Password)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Minute}
+	}
+	return client.Do(req)
+}
+
+func webdavError(resp *http.Response, key string) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("WebDAV %s: %d %s", resp.Request.Method, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// mkdirs creates the collections of dir one at a time; existing collections answer 405.
+func (s *WebDAV) mkdirs(ctx context.Context, dir string) error {
+	u := strings.TrimRight(s.URL, "/")
+	for _, seg := range strings.Split(dir, "/") {
+		if seg == "" || seg == "." {
+			continue
+		}
+		u += "/" + url.PathEscape(seg)
+		resp, err := s.do(ctx, "MKCOL", u, nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+			return webdavError(resp, dir)
+		}
+	}
+	return nil
+}
+
+func (s *WebDAV) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	if err := s.mkdirs(ctx, path.Dir(key)); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPut, s.url(key), r, size, map[string]string{"Content-Type": contentType(key)})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return webdavError(resp, key)
+	}
+	return nil
+}
+
+func (s *WebDAV) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.url(key), nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, webdavError(resp, key)
+	}
+	return resp.Body, nil
+}
+
+func (s *WebDAV) List(ctx context.Context, dir string) ([]Object, error) {
+	dir, err := cleanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, "PROPFIND", s.url(dir), nil, 0, map[string]string{"Depth": "1"})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode >= 300 {
+		return nil, webdavError(resp, dir)
+	}
+	var ms struct {
+		Responses []struct {
+			Href     string `xml:"href"`
+			Propstat []struct {
+				Prop struct {
+					Length       int64  `xml:"getcontentlength"`
+					Modified     string `xml:"getlastmodified"`
+					ResourceType struct {
+						Collection *struct{} `xml:"collection"`
+					} `xml:"resourcetype"`
+				} `xml:"prop"`
+			} `xml:"propstat"`
+		} `xml:"response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("WebDAV PROPFIND: %w", err)
+	}
+	self := ""
+	if u, err := url.Parse(s.url(dir)); err == nil {
+		self = strings.TrimRight(u.Path, "/")
+	}
+	var objs []Object
+	for _, r := range ms.Responses {
+		u, err := url.Parse(r.Href) // some servers answer with absolute URLs
+		if err != nil {
+			continue
+		}
+		href := strings.TrimRight(u.Path, "/")
+		if href == self || href == "" {
+			continue // the collection itself
+		}
+		o := Object{Key: path.Join(dir, path.Base(href))}
+		// Properties the server lacks come in a separate 404 propstat
+		for _, ps := range r.Propstat {
+			if ps.Prop.ResourceType.Collection != nil {
+				o.Dir = true
+			}
+			if ps.Prop.Length > 0 {
+				o.Size = ps.Prop.Length
+			}
+			if t, err := http.ParseTime(ps.Prop.Modified); err == nil {
+				o.Modified = t
+			}
+		}
+		objs = append(objs, o)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Key < objs[j].Key })
+	return objs, nil
+}
+
+func (s *WebDAV) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.url(key), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return webdavError(resp, key)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+)
+
+// AnnounceTarget is a room/thread on a channel that receives announcements.
+type AnnounceTarget struct {
+	Channel  string `json:"channel"`
+	ThreadID string `json:"thread_id"` // room token, chat ID, etc.
+	Label    string `json:"label,omitempty"`
+}
+
+// Delivery is the per-target outcome of an announcement.
+type Delivery struct {
+	AnnounceTarget
+	Delivered bool   `json:"delivered"`
+	Parts     int    `json:"parts,omitempty"` // messages sent after splitting for the channel's length limit
+	Error     string `json:"error,omitempty"`
+}
+
+// Announce posts content to every target, formatted for each target's channel, and reports
+// per-target delivery. A failing target does not stop delivery to the others.
+func (g *Gateway) Announce(ctx context.Context, targets []AnnounceTarget, content string) []Delivery {
+	report := make([]Delivery, 0, len(targets))
+	for _, t := range targets {
+		d := Delivery{AnnounceTarget: t}
+		if err := ctx.Err(); err != nil {
+			d.Error = err.Error()
+			report = append(report, d)
+			continue
+		}
+		g.mu.RLock()
+		ch, ok := g.channels[t.Channel]
+		g.mu.RUnlock()
+		if !ok {
+			d.Error = fmt.Sprintf("channel %s not found", t.Channel)
+			report = append(report, d)
+			continue
+		}
+		for _, part := range FormatForChannel(content, capabilitiesOf(ch)) {
+			err := ch.Send(Message{SenderID: "hattiebot", Content: part, Channel: t.Channel, ThreadID: t.ThreadID})
+			if err != nil {
+				d.Error = err.Error()
+				break
+			}
+			d.Parts++
+		}
+		d.Delivered = d.Error == ""
+		report = append(report, d)
+	}
+	return report
+}
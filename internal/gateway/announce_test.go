@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type roomChannel struct {
+	name string
+	caps Capabilities
+	sent map[string][]string
+	fail string // thread that rejects sends
+}
+
+func (c *roomChannel) Name() string                                       { return c.name }
+func (c *roomChannel) Start(ctx context.Context, in chan<- Message) error { return nil }
+func (c *roomChannel) SendProactive(userID, content string) error         { return nil }
+func (c *roomChannel) Capabilities() Capabilities                         { return c.caps }
+func (c *roomChannel) Send(msg Message) error {
+	if msg.ThreadID == c.fail {
+		return errors.New("room not found")
+	}
+	c.sent[msg.ThreadID] = append(c.sent[msg.ThreadID], msg.Content)
+	return nil
+}
+
+func TestAnnounceFormatsPerChannelAndReports(t *testing.T) {
+	talk := &roomChannel{name: "talk", caps: Capabilities{Markdown: false, MaxLength: 20}, sent: map[string][]string{}, fail: "gone"}
+	discord := &roomChannel{name: "discord", caps: DefaultCapabilities, sent: map[string][]string{}}
+	g := New(nil)
+	g.Register(talk)
+	g.Register(discord)
+
+	msg := "**Maintenance** tonight at 10pm, expect downtime"
+	report := g.Announce(context.Background(), []AnnounceTarget{
+		{Channel: "talk", ThreadID: "family"},
+		{Channel: "talk", ThreadID: "gone"},
+		{Channel: "discord", ThreadID: "general"},
+		{Channel: "matrix", ThreadID: "x"},
+	}, msg)
+
+	if len(report) != 4 {
+		t.Fatalf("got %d results, want 4", len(report))
+	}
+	if !report[0].Delivered || report[0].Parts < 2 {
+		t.Errorf("talk/family = %+v, want delivered in several parts", report[0])
+	}
+	if got := strings.Join(talk.sent["family"], " "); strings.Contains(got, "**") {
+		t.Errorf("talk should get plain text, got %q", got)
+	}
+	if report[1].Delivered || report[1].Error == "" {
+		t.Errorf("talk/gone = %+v, want failure", report[1])
+	}
+	if !report[2].Delivered || discord.sent["general"][0] != msg {
+		t.Errorf("discord/general = %+v sent %q", report[2], discord.sent["general"])
+	}
+	if report[3].Delivered || !strings.Contains(report[3].Error, "not found") {
+		t.Errorf("unknown channel = %+v, want not found", report[3])
+	}
+}
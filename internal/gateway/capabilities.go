@@ -0,0 +1,269 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/logging"
+)
+
+// Capabilities describes what a channel can render and do. The gateway uses it to
+// split long replies and strip markdown the channel would show as raw syntax.
+type Capabilities struct {
+	Markdown  bool // renders markdown; when false, formatting is stripped before sending
+	Reactions bool // supports emoji reactions on incoming messages (channel implements Reactor)
+	Editing   bool // supports editing previously sent messages
+	Replies   bool // can send a reply quoting the incoming message (Message.Quote)
+	Mentions  bool // can @-mention users so they are notified (Message.Mentions)
+	MaxLength int  // max characters per message; 0 = no limit
+}
+
+// DefaultCapabilities applies to channels that do not implement CapableChannel.
+var DefaultCapabilities = Capabilities{Markdown: true}
+
+// CapableChannel is implemented by channels that advertise their capabilities.
+type CapableChannel interface {
+	Capabilities() Capabilities
+}
+
+// Reactor is implemented by channels that can add/remove emoji reactions on a received message.
+type Reactor interface {
+	React(msg Message, emoji string) error
+	Unreact(msg Message, emoji string) error
+}
+
+// MessageEditor is implemented by channels that can edit a message they sent (Capabilities.Editing).
+type MessageEditor interface {
+	// SendEditable sends msg and returns the channel's ID for it, for use with EditMessage.
+	SendEditable(msg Message) (messageID string, err error)
+	// EditMessage replaces the content of a previously sent message in msg's thread.
+	EditMessage(msg Message, messageID, content string) error
+}
+
+// Typer is implemented by channels that can show a typing indicator in a thread.
+// The gateway turns it on when a turn starts, refreshes it while the turn runs
+// (most platforms expire the indicator after a few seconds), and turns it off at the end.
+type Typer interface {
+	Typing(threadID string, on bool) error
+}
+
+// typingRefreshInterval re-sends the typing indicator before platforms expire it.
+const typingRefreshInterval = 5 * time.Second
+
+// startTyping shows the typing indicator for m's thread until the returned stop func is called.
+// It is a no-op for channels without Typer and for autonomous messages.
+func (g *Gateway) startTyping(m Message) (stop func()) {
+	g.mu.RLock()
+	ch, ok := g.channels[m.Channel]
+	g.mu.RUnlock()
+	t, isTyper := ch.(Typer)
+	if !ok || !isTyper || m.Autonomous {
+		return func() {}
+	}
+	if err := t.Typing(m.ThreadID, true); err != nil {
+		logging.For("gateway").Warn("typing indicator failed", "channel", m.Channel, "error", err)
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(typingRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				_ = t.Typing(m.ThreadID, false)
+				return
+			case <-ticker.C:
+				_ = t.Typing(m.ThreadID, true)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// Reactions added automatically on channels that support them: working while the turn runs, done after the reply.
+const (
+	ReactionWorking = "👀"
+	ReactionDone    = "✅"
+)
+
+// CapabilitiesOf returns the named channel's capabilities (DefaultCapabilities when unknown or not advertised).
+func (g *Gateway) CapabilitiesOf(channelName string) Capabilities {
+	g.mu.RLock()
+	ch, ok := g.channels[channelName]
+	g.mu.RUnlock()
+	if !ok {
+		return DefaultCapabilities
+	}
+	return capabilitiesOf(ch)
+}
+
+func capabilitiesOf(ch Channel) Capabilities {
+	if c, ok := ch.(CapableChannel); ok {
+		return c.Capabilities()
+	}
+	return DefaultCapabilities
+}
+
+// React adds an emoji reaction to msg on its channel. Returns an error when the channel does not support reactions.
+func (g *Gateway) React(msg Message, emoji string) error {
+	r, err := g.reactor(msg.Channel)
+	if err != nil {
+		return err
+	}
+	return r.React(msg, emoji)
+}
+
+func (g *Gateway) reactor(channelName string) (Reactor, error) {
+	g.mu.RLock()
+	ch, ok := g.channels[channelName]
+	g.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("channel %s not found", channelName)
+	}
+	r, ok := ch.(Reactor)
+	if !ok || !capabilitiesOf(ch).Reactions {
+		return nil, fmt.Errorf("channel %s does not support reactions", channelName)
+	}
+	return r, nil
+}
+
+// RouteStatus delivers an intermediate status update for originalMsg. On channels that support editing,
+// the first call sends a message and later calls (passing the returned ID) edit it in place, so a turn
+// shows a single updating "working…" message. Elsewhere each update is sent as a new reply and "" is returned.
+func (g *Gateway) RouteStatus(originalMsg Message, statusID, content string) string {
+	g.mu.RLock()
+	ch, ok := g.channels[originalMsg.Channel]
+	g.mu.RUnlock()
+	if !ok {
+		g.routeReply(originalMsg, content)
+		return ""
+	}
+	caps := capabilitiesOf(ch)
+	ed, canEdit := ch.(MessageEditor)
+	if !canEdit || !caps.Editing {
+		g.routeReply(originalMsg, content)
+		return ""
+	}
+	// A status line is never split; keep only what fits in one message.
+	content = FormatForChannel(content, caps)[0]
+	if statusID != "" {
+		err := ed.EditMessage(originalMsg, statusID, content)
+		g.recordSend(originalMsg.Channel, err)
+		if err == nil {
+			return statusID
+		}
+		logging.For("gateway").Warn("editing status failed; sending a new message", "channel", ch.Name(), "error", err)
+	}
+	id, err := ed.SendEditable(Message{
+		SenderID:  "hattiebot",
+		Content:   content,
+		Channel:   originalMsg.Channel,
+		ThreadID:  originalMsg.ThreadID,
+		ReplyToID: originalMsg.ReplyToID,
+	})
+	g.recordSend(originalMsg.Channel, err)
+	if err != nil {
+		logging.For("gateway").Error("sending status failed", "channel", ch.Name(), "error", err)
+		return ""
+	}
+	return id
+}
+
+// FormatForChannel adapts content to caps: strips markdown if unsupported and splits it into
+// chunks of at most MaxLength characters, preferring paragraph, then line, then word boundaries.
+func FormatForChannel(content string, caps Capabilities) []string {
+	if !caps.Markdown {
+		content = StripMarkdown(content)
+	}
+	if caps.MaxLength <= 0 || len([]rune(content)) <= caps.MaxLength {
+		return []string{content}
+	}
+	var chunks []string
+	rest := []rune(content)
+	for len(rest) > caps.MaxLength {
+		window := string(rest[:caps.MaxLength])
+		cut := strings.LastIndex(window, "\n\n")
+		if cut <= 0 {
+			cut = strings.LastIndex(window, "\n")
+		}
+		if cut <= 0 {
+			cut = strings.LastIndex(window, " ")
+		}
+		var chunk string
+		if cut <= 0 {
+			chunk = window
+		} else {
+			chunk = window[:cut]
+		}
+		rest = rest[len([]rune(chunk)):]
+		if c := strings.TrimSpace(chunk); c != "" {
+			chunks = append(chunks, c)
+		}
+		rest = []rune(strings.TrimLeft(string(rest), " \n"))
+	}
+	if c := strings.TrimSpace(string(rest)); c != "" {
+		chunks = append(chunks, c)
+	}
+	return chunks
+}
+
+var (
+	mdFence      = regexp.MustCompile("(?m)^```[^\\n]*\\n?")
+	mdHeading    = regexp.MustCompile(`(?m)^#{1,6}\s+`)
+	mdBold       = regexp.MustCompile(`\*\*([^*\n]+)\*\*|__([^_\n]+)__`)
+	mdItalic     = regexp.MustCompile(`(^|[\s(])[*_]([^*_\n]+)[*_]`)
+	mdInlineCode = regexp.MustCompile("`([^`\\n]+)`")
+	mdLink       = regexp.MustCompile(`!?\[([^\]]*)\]\(([^)\s]+)\)`)
+	mdBullet     = regexp.MustCompile(`(?m)^(\s*)[*+]\s+`)
+)
+
+// StripMarkdown removes common markdown syntax, keeping the text (and link URLs) readable as plain text.
+func StripMarkdown(s string) string {
+	s = mdFence.ReplaceAllString(s, "")
+	s = mdHeading.ReplaceAllString(s, "")
+	s = mdLink.ReplaceAllString(s, "$1 ($2)")
+	s = mdBold.ReplaceAllString(s, "$1$2")
+	s = mdItalic.ReplaceAllString(s, "$1$2")
+	s = mdInlineCode.ReplaceAllString(s, "$1")
+	s = mdBullet.ReplaceAllString(s, "$1- ")
+	return s
+}
+
+type messageKey struct{}
+
+// WithMessage returns a context carrying the message being handled, so tools (e.g. react) can act on it.
+func WithMessage(ctx context.Context, msg Message) context.Context {
+	return context.WithValue(ctx, messageKey{}, msg)
+}
+
+// MessageFromContext returns the message set by WithMessage, if any.
+func MessageFromContext(ctx context.Context) (Message, bool) {
+	msg, ok := ctx.Value(messageKey{}).(Message)
+	return msg, ok
+}
+
+// ReplyOptions shape the reply that ends the current turn. Tools set them through
+// ReplyOptionsFromContext; channels without the matching capability ignore them.
+type ReplyOptions struct {
+	Quote    bool     // quote the message being answered
+	Mentions []string // user IDs to @-mention
+}
+
+type replyOptionsKey struct{}
+
+func withReplyOptions(ctx context.Context, opts *ReplyOptions) context.Context {
+	return context.WithValue(ctx, replyOptionsKey{}, opts)
+}
+
+// ReplyOptionsFromContext returns the current turn's reply options for a tool to change. It is
+// false outside a gateway turn, e.g. for scheduled runs or sub-minds.
+func ReplyOptionsFromContext(ctx context.Context) (*ReplyOptions, bool) {
+	opts, ok := ctx.Value(replyOptionsKey{}).(*ReplyOptions)
+	return opts, ok && opts != nil
+}
@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFormatForChannelSplitsOnBoundaries(t *testing.T) {
+	content := strings.Repeat("a", 8) + "\n\n" + strings.Repeat("b", 8) + " " + strings.Repeat("c", 8)
+	got := FormatForChannel(content, Capabilities{Markdown: true, MaxLength: 12})
+	want := []string{strings.Repeat("a", 8), strings.Repeat("b", 8), strings.Repeat("c", 8)}
+	if len(got) != len(want) {
+		t.Fatalf("got %d chunks %q, want %q", len(got), got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	for _, c := range FormatForChannel(strings.Repeat("x", 25), Capabilities{Markdown: true, MaxLength: 10}) {
+		if len(c) > 10 {
+			t.Errorf("chunk over limit: %q", c)
+		}
+	}
+}
+
+func TestFormatForChannelStripsMarkdown(t *testing.T) {
+	in := "## Title\n**bold** and *it* with `code` see [docs](https://x.y)\n* item"
+	got := FormatForChannel(in, Capabilities{Markdown: false})
+	want := "Title\nbold and it with code see docs (https://x.y)\n- item"
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := FormatForChannel(in, DefaultCapabilities); got[0] != in {
+		t.Errorf("markdown channel should keep content, got %q", got[0])
+	}
+}
+
+type editingChannel struct {
+	sent   []string
+	edits  []string
+	nextID int
+}
+
+func (c *editingChannel) Name() string                                       { return "editing" }
+func (c *editingChannel) Start(ctx context.Context, in chan<- Message) error { return nil }
+func (c *editingChannel) Send(msg Message) error                             { c.sent = append(c.sent, msg.Content); return nil }
+func (c *editingChannel) SendProactive(userID, content string) error         { return nil }
+func (c *editingChannel) Capabilities() Capabilities {
+	return Capabilities{Markdown: true, Editing: true}
+}
+func (c *editingChannel) SendEditable(msg Message) (string, error) {
+	c.nextID++
+	c.sent = append(c.sent, msg.Content)
+	return fmt.Sprint(c.nextID), nil
+}
+func (c *editingChannel) EditMessage(msg Message, id, content string) error {
+	c.edits = append(c.edits, id+"="+content)
+	return nil
+}
+
+func TestRouteStatusEditsInPlace(t *testing.T) {
+	ch := &editingChannel{}
+	g := New(nil)
+	g.Register(ch)
+	msg := Message{Channel: "editing", ThreadID: "room"}
+
+	id := g.RouteStatus(msg, "", "working…")
+	id = g.RouteStatus(msg, id, "still working…")
+	id = g.RouteStatus(msg, id, "almost done")
+	if id != "1" || len(ch.sent) != 1 || len(ch.edits) != 2 || ch.edits[1] != "1=almost done" {
+		t.Errorf("id=%q sent=%q edits=%q", id, ch.sent, ch.edits)
+	}
+}
+
+type typingChannel struct {
+	editingChannel
+	calls chan bool
+}
+
+func (c *typingChannel) Name() string { return "typing" }
+func (c *typingChannel) Typing(threadID string, on bool) error {
+	c.calls <- on
+	return nil
+}
+
+func TestStartTypingTurnsIndicatorOnAndOff(t *testing.T) {
+	ch := &typingChannel{calls: make(chan bool, 4)}
+	g := New(nil)
+	g.Register(ch)
+
+	stop := g.startTyping(Message{Channel: "typing", ThreadID: "room"})
+	if on := <-ch.calls; !on {
+		t.Fatal("expected typing on at turn start")
+	}
+	stop()
+	stop() // idempotent
+	if on := <-ch.calls; on {
+		t.Fatal("expected typing off after stop")
+	}
+
+	g.startTyping(Message{Channel: "typing", ThreadID: "room", Autonomous: true})()
+	select {
+	case on := <-ch.calls:
+		t.Errorf("autonomous turn should not type, got %v", on)
+	default:
+	}
+}
@@ -2,18 +2,36 @@ package gateway
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/logging"
+	"github.com/hattiebot/hattiebot/internal/tracing"
 )
 
 // Message represents a generic message flowing through the gateway
 type Message struct {
 	SenderID   string
 	Content    string
-	Channel    string // "admin_term", "nextcloud_talk", etc.
-	ThreadID   string // "stream:topic", "pm:user", etc.
-	ReplyToID  string // Optional ID to reply to
-	Autonomous bool   // When true, agent's reply is not auto-routed; agent must use notify_user to send
+	Channel    string   // "admin_term", "nextcloud_talk", etc.
+	ThreadID   string   // "stream:topic", "pm:user", etc.
+	ReplyToID  string   // Optional ID to reply to
+	Autonomous bool     // When true, agent's reply is not auto-routed; agent must use notify_user to send
+	PlanID     int64    // Scheduled plan that triggered this message (0 = user-initiated); used for cost attribution
+	Voice      bool     // Content was transcribed from a voice message; channels may reply with audio
+	Quote      bool     // Outgoing: quote the ReplyToID message (channels with Capabilities.Replies)
+	Mentions   []string // Outgoing: user IDs to @-mention (channels with Capabilities.Mentions)
+	ReceivedAt time.Time // When the gateway took the message in; the start of its trace
+	NoAdmin    bool      // Sender acts without admin rights (an API token without the admin scope)
+	Mentioned  []string  // Incoming: IDs the message @-mentions ("all" = everyone), on channels that report them
+	Urgent     bool      // Incoming: interrupts the thread's running turn (set for InterruptPrefixes, or by the channel)
+	Preempted  bool      // Set by the gateway when this message cut the thread's previous turn short
+
+	done func() // acknowledges a message consumed from the Queue once it is finished with
 }
 
 // Channel defines the interface for all communication channels
@@ -38,6 +56,15 @@ type Gateway struct {
 	turnsMu    sync.Mutex
 	inFlight   map[string]bool
 	pending    map[string][]Message
+	idle       []func() // run by WhenIdle once no turn is in flight
+	activityMu sync.Mutex
+	activity   map[string]*channelActivity // per-channel traffic for ChannelHealth
+	queue      Queue                       // nil = every turn runs in this process
+	addressed  func(ctx context.Context, msg Message) bool
+	passive    func(ctx context.Context, msg Message)
+	cancels    map[string]context.CancelCauseFunc // running user turns by thread, for urgent messages
+	senders    map[string]string                  // sender of each running user turn, by thread
+	preempts   func(ctx context.Context, msg Message) bool
 }
 
 // threadKey returns a key for per-thread serialization
@@ -55,14 +82,79 @@ func ThreadKey(m Message) string {
 
 // GetPendingAndClear returns and removes any messages that arrived while this turn was in progress.
 // The agent loop calls this between tool rounds so the model can see new user messages (e.g. "stop").
+// Urgent messages stay queued: they cancel the turn and start the next one.
 func (g *Gateway) GetPendingAndClear(threadKey string) []Message {
 	g.turnsMu.Lock()
 	defer g.turnsMu.Unlock()
-	msgs := g.pending[threadKey]
-	delete(g.pending, threadKey)
+	var msgs, urgent []Message
+	for _, m := range g.pending[threadKey] {
+		if m.Urgent {
+			urgent = append(urgent, m)
+			continue
+		}
+		if m.done != nil {
+			m.done()
+		}
+		msgs = append(msgs, m)
+	}
+	if len(urgent) > 0 {
+		g.pending[threadKey] = urgent
+	} else {
+		delete(g.pending, threadKey)
+	}
 	return msgs
 }
 
+// QueueStats is a snapshot of the gateway's work in this process.
+type QueueStats struct {
+	Ingress int            `json:"ingress"`           // received, not yet dispatched
+	Running []string       `json:"running"`           // thread keys with a turn in progress
+	Waiting map[string]int `json:"waiting,omitempty"` // messages queued behind each running turn
+}
+
+// Stats returns the current queue depth: the ingress buffer, running turns and waiting messages.
+func (g *Gateway) Stats() QueueStats {
+	g.turnsMu.Lock()
+	defer g.turnsMu.Unlock()
+	st := QueueStats{Ingress: len(g.ingress), Running: []string{}}
+	for tk := range g.inFlight {
+		st.Running = append(st.Running, tk)
+	}
+	sort.Strings(st.Running)
+	for tk, msgs := range g.pending {
+		if len(msgs) > 0 {
+			if st.Waiting == nil {
+				st.Waiting = map[string]int{}
+			}
+			st.Waiting[tk] = len(msgs)
+		}
+	}
+	return st
+}
+
+// InterruptPrefixes mark a message as urgent: it cancels the thread's running turn, including an
+// LLM call or tool in progress, instead of waiting to be read between tool rounds.
+var InterruptPrefixes = []string{"!stop", "!urgent"}
+
+// ErrInterrupted is the cause of a turn's context cancelled by an urgent message.
+var ErrInterrupted = errors.New("interrupted by an urgent message")
+
+// IsUrgent reports whether content starts with one of InterruptPrefixes.
+func IsUrgent(content string) bool {
+	content = strings.ToLower(strings.TrimSpace(content))
+	for _, p := range InterruptPrefixes {
+		if strings.HasPrefix(content, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Interrupted reports whether ctx was cancelled by an urgent message.
+func Interrupted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrInterrupted)
+}
+
 // New creates a new Gateway
 func New(handler func(ctx context.Context, msg Message) (string, error)) *Gateway {
 	return &Gateway{
@@ -71,9 +163,25 @@ func New(handler func(ctx context.Context, msg Message) (string, error)) *Gatewa
 		handler:  handler,
 		inFlight: make(map[string]bool),
 		pending:  make(map[string][]Message),
+		cancels:  make(map[string]context.CancelCauseFunc),
+		senders:  make(map[string]string),
 	}
 }
 
+// SetAddressing makes the gateway ask addressed whether a message is meant for the agent before
+// running its turn. A message that is not (e.g. people talking among themselves in a group room)
+// is given to passive instead: no turn, reaction, typing indicator or reply. Call it before StartAll.
+func (g *Gateway) SetAddressing(addressed func(ctx context.Context, msg Message) bool, passive func(ctx context.Context, msg Message)) {
+	g.addressed, g.passive = addressed, passive
+}
+
+// SetPreemption lets an urgent message from a sender other than the running turn's interrupt it
+// when allowed reports true (e.g. for approved users). Without it, only the running turn's own
+// sender may interrupt. Call it before StartAll.
+func (g *Gateway) SetPreemption(allowed func(ctx context.Context, msg Message) bool) {
+	g.preempts = allowed
+}
+
 // Register adds a channel to the gateway
 func (g *Gateway) Register(c Channel) {
 	g.mu.Lock()
@@ -81,6 +189,14 @@ func (g *Gateway) Register(c Channel) {
 	g.channels[c.Name()] = c
 }
 
+// ChannelByName returns a registered channel, for tools that use channel-specific features.
+func (g *Gateway) ChannelByName(name string) (Channel, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	ch, ok := g.channels[name]
+	return ch, ok
+}
+
 // PushIngress delivers a message into the gateway from an external source (e.g. HTTP webhook).
 // It is non-blocking: if the ingress buffer is full, the message is dropped and false is returned.
 func (g *Gateway) PushIngress(msg Message) bool {
@@ -102,6 +218,13 @@ func (g *Gateway) StartAll(ctx context.Context) error {
 		defer wg.Done()
 		g.processIngress(ctx)
 	}()
+	if g.queue != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.consumeQueue(ctx)
+		}()
+	}
 
 	// Start Channels
 	g.mu.RLock()
@@ -110,7 +233,7 @@ func (g *Gateway) StartAll(ctx context.Context) error {
 		go func(ch Channel) {
 			defer wg.Done()
 			if err := ch.Start(ctx, g.ingress); err != nil {
-				fmt.Printf("Error in channel %s: %v\n", ch.Name(), err)
+				logging.For("gateway").Error("channel stopped", "channel", ch.Name(), "error", err)
 			}
 		}(c)
 	}
@@ -121,34 +244,114 @@ func (g *Gateway) StartAll(ctx context.Context) error {
 	return nil
 }
 
-// processIngress reads messages from channels and sends them to the agent handler.
-// Per-thread serialization: only one turn at a time per thread. Messages that arrive
-// while a turn is in progress are queued and injected into the conversation between
-// tool rounds, so the agent can see them (e.g. "stop") and respond.
+// processIngress reads messages from channels and sends them to the agent handler, or to the
+// Queue when one is set and the message may be handled by another process.
 func (g *Gateway) processIngress(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case msg := <-g.ingress:
-			tk := threadKey(msg)
-			g.turnsMu.Lock()
-			if g.inFlight[tk] {
-				g.pending[tk] = append(g.pending[tk], msg)
-				g.turnsMu.Unlock()
-				continue
+			if msg.ReceivedAt.IsZero() {
+				msg.ReceivedAt = time.Now()
 			}
-			g.inFlight[tk] = true
-			g.turnsMu.Unlock()
-			go g.runTurn(ctx, msg)
+			g.recordReceived(msg.Channel, msg.ReceivedAt)
+			if g.distributable(msg) {
+				err := g.queue.Publish(ctx, threadKey(msg), msg)
+				if err == nil {
+					continue
+				}
+				logging.For("gateway").Error("publishing to queue failed; handling here", "channel", msg.Channel, "error", err)
+			}
+			g.dispatch(ctx, msg)
+		}
+	}
+}
+
+// dispatch starts msg's turn, or queues it behind the thread's running turn.
+// Per-thread serialization: only one turn at a time per thread. Messages that arrive
+// while a turn is in progress are queued and injected into the conversation between
+// tool rounds, so the agent can see them (e.g. "stop") and respond.
+//
+// An urgent message (IsUrgent, or Message.Urgent) instead cancels the running turn at once and
+// runs next, ahead of the other queued messages. Autonomous turns are not interrupted, and in a
+// shared room only the running turn's sender or a sender SetPreemption allows may interrupt; other
+// urgent messages are queued as normal ones.
+func (g *Gateway) dispatch(ctx context.Context, msg Message) {
+	tk := threadKey(msg)
+	if !msg.Autonomous && IsUrgent(msg.Content) {
+		msg.Urgent = true
+	}
+	// Asked before locking: the check may look the sender up
+	allowed := msg.Urgent && g.preempts != nil && g.preempts(ctx, msg)
+	g.turnsMu.Lock()
+	if g.inFlight[tk] {
+		cancel := g.cancels[tk]
+		if msg.Urgent && cancel != nil && !allowed && g.senders[tk] != msg.SenderID {
+			logging.For("gateway").Info("urgent message from another sender queued without interrupting", "channel", msg.Channel, "thread", msg.ThreadID)
+			msg.Urgent = false
+		}
+		if msg.Urgent && cancel != nil {
+			msg.Preempted = true
+			g.pending[tk] = insertUrgent(g.pending[tk], msg)
+			cancel(ErrInterrupted)
+			logging.For("gateway").Info("urgent message interrupts the running turn", "channel", msg.Channel, "thread", msg.ThreadID)
+		} else {
+			g.pending[tk] = append(g.pending[tk], msg)
 		}
+		g.turnsMu.Unlock()
+		return
+	}
+	g.inFlight[tk] = true
+	g.turnsMu.Unlock()
+	go g.runTurn(ctx, msg)
+}
+
+// CancelTurn cancels the thread's running user turn as an urgent message would, without a message
+// to answer next. It reports whether a turn was running.
+func (g *Gateway) CancelTurn(threadKey string) bool {
+	g.turnsMu.Lock()
+	defer g.turnsMu.Unlock()
+	cancel := g.cancels[threadKey]
+	if cancel == nil {
+		return false
 	}
+	cancel(ErrInterrupted)
+	return true
+}
+
+// insertUrgent queues msg after the urgent messages already in queue and before the others.
+func insertUrgent(queue []Message, msg Message) []Message {
+	i := 0
+	for i < len(queue) && queue[i].Urgent {
+		i++
+	}
+	queue = append(queue, Message{})
+	copy(queue[i+1:], queue[i:])
+	queue[i] = msg
+	return queue
 }
 
 func (g *Gateway) runTurn(ctx context.Context, m Message) {
 	tk := threadKey(m)
+	parent := ctx
+	turnCtx, cancel := context.WithCancelCause(ctx)
+	if !m.Autonomous {
+		g.turnsMu.Lock()
+		g.cancels[tk] = cancel
+		g.senders[tk] = m.SenderID
+		g.turnsMu.Unlock()
+	}
+	ctx = turnCtx
 	defer func() {
+		cancel(nil)
+		ctx := parent
+		if m.done != nil {
+			m.done()
+		}
 		g.turnsMu.Lock()
+		delete(g.cancels, tk)
+		delete(g.senders, tk)
 		delete(g.inFlight, tk)
 		next := g.pending[tk]
 		if len(next) > 0 {
@@ -158,18 +361,93 @@ func (g *Gateway) runTurn(ctx context.Context, m Message) {
 			go g.runTurn(ctx, next[0])
 		} else {
 			delete(g.pending, tk)
+			if len(g.inFlight) == 0 {
+				g.runIdleLocked()
+			}
 			g.turnsMu.Unlock()
 		}
 	}()
-	replyContent, err := g.handler(ctx, m)
+	start := m.ReceivedAt
+	if start.IsZero() {
+		start = time.Now()
+	}
+	ctx, span := tracing.StartAt(ctx, "gateway.message", start)
+	span.SetKind(tracing.KindServer).
+		Set("channel", m.Channel).
+		Set("thread", m.ThreadID).
+		Set("autonomous", m.Autonomous).
+		Set("queue_wait_ms", time.Since(start))
+	if m.PlanID != 0 {
+		span.Set("plan_id", m.PlanID)
+	}
+	defer span.End()
+	if !m.Autonomous && g.addressed != nil && !g.addressed(ctx, m) {
+		span.Set("addressed", false)
+		g.passive(ctx, m)
+		return
+	}
+	reactor, _ := g.reactor(m.Channel)
+	if m.Autonomous {
+		reactor = nil
+	}
+	if reactor != nil {
+		if err := reactor.React(m, ReactionWorking); err != nil {
+			logging.For("gateway").WarnContext(ctx, "could not add reaction", "channel", m.Channel, "error", err)
+			reactor = nil
+		}
+	}
+	stopTyping := g.startTyping(m)
+	opts := &ReplyOptions{}
+	replyContent, err := g.handler(WithMessage(withReplyOptions(ctx, opts), m), m)
+	stopTyping()
+	if err != nil && Interrupted(ctx) {
+		// The urgent message's turn, next in the queue, answers instead
+		span.Set("interrupted", true)
+		if reactor != nil {
+			_ = reactor.Unreact(m, ReactionWorking)
+		}
+		return
+	}
 	if err != nil {
+		span.SetError(err)
 		replyContent = fmt.Sprintf("Error: %v", err)
 	}
+	if reactor != nil {
+		_ = reactor.Unreact(m, ReactionWorking)
+		if err == nil {
+			_ = reactor.React(m, ReactionDone)
+		}
+	}
 	if m.Autonomous {
-		fmt.Printf("[Gateway] Autonomous task completed (reply not routed): %q\n", replyContent)
+		logging.For("gateway").InfoContext(ctx, "autonomous task completed; reply not routed", "plan_id", m.PlanID, "reply", replyContent)
 		return
 	}
-	g.routeReply(m, replyContent)
+	if err != nil {
+		opts = &ReplyOptions{}
+	}
+	_, send := tracing.Start(ctx, "channel.send")
+	send.SetKind(tracing.KindClient).Set("channel", m.Channel)
+	send.EndErr(g.routeReplyWith(m, replyContent, *opts))
+}
+
+// WhenIdle runs fn when no turn is in progress: at once if the gateway is idle, otherwise when
+// the last running turn finishes. New turns wait while fn runs, so fn must not call back into
+// the gateway's turn bookkeeping (GetPendingAndClear).
+func (g *Gateway) WhenIdle(fn func()) {
+	g.turnsMu.Lock()
+	defer g.turnsMu.Unlock()
+	g.idle = append(g.idle, fn)
+	if len(g.inFlight) == 0 {
+		g.runIdleLocked()
+	}
+}
+
+func (g *Gateway) runIdleLocked() {
+	fns := g.idle
+	g.idle = nil
+	for _, fn := range fns {
+		fn()
+	}
 }
 
 // RouteReply sends content back to the appropriate channel. Exported so the agent loop can send intermediate status updates.
@@ -179,28 +457,49 @@ func (g *Gateway) RouteReply(originalMsg Message, content string) {
 
 // routeReply sends the agent's response back to the appropriate channel
 func (g *Gateway) routeReply(originalMsg Message, content string) {
-	fmt.Printf("[Gateway] Routing reply to %s: %q\n", originalMsg.Channel, content)
+	_ = g.routeReplyWith(originalMsg, content, ReplyOptions{})
+}
+
+// routeReplyWith is routeReply with the turn's reply options; they apply to the first part only,
+// so a split reply quotes and mentions once. The error is already logged; it is returned for the
+// turn's trace.
+func (g *Gateway) routeReplyWith(originalMsg Message, content string, opts ReplyOptions) error {
+	logging.For("gateway").Info("routing reply", "channel", originalMsg.Channel, "thread", originalMsg.ThreadID, "content", content)
 	g.mu.RLock()
 	ch, ok := g.channels[originalMsg.Channel]
 	g.mu.RUnlock()
 
 	if !ok {
-		fmt.Printf("Error: Channel %s not found for reply\n", originalMsg.Channel)
-		return
-	}
-
-	reply := Message{
-		SenderID:   "hattiebot", // Self
-		Content:    content,
-		Channel:    originalMsg.Channel,
-		ThreadID:   originalMsg.ThreadID,
-		ReplyToID:  originalMsg.ReplyToID,
+		logging.For("gateway").Error("channel not found for reply", "channel", originalMsg.Channel)
+		return fmt.Errorf("channel %s not found", originalMsg.Channel)
 	}
 
-	if err := ch.Send(reply); err != nil {
-		fmt.Printf("Error sending reply to %s: %v\n", ch.Name(), err)
+	caps := capabilitiesOf(ch)
+	for i, part := range FormatForChannel(content, caps) {
+		reply := Message{
+			SenderID:  "hattiebot", // Self
+			Content:   part,
+			Channel:   originalMsg.Channel,
+			ThreadID:  originalMsg.ThreadID,
+			ReplyToID: originalMsg.ReplyToID,
+			Voice:     originalMsg.Voice,
+		}
+		if i == 0 {
+			reply.Quote = opts.Quote && caps.Replies
+			if caps.Mentions {
+				reply.Mentions = opts.Mentions
+			}
+		}
+		err := ch.Send(reply)
+		g.recordSend(originalMsg.Channel, err)
+		if err != nil {
+			logging.For("gateway").Error("sending reply failed", "channel", ch.Name(), "error", err)
+			return err
+		}
 	}
+	return nil
 }
+
 // Broadcast sends a proactive message to a user via the specified channel.
 func (g *Gateway) Broadcast(ctx context.Context, channelName, userID, content, urgency string) error {
 	g.mu.RLock()
@@ -217,5 +516,12 @@ func (g *Gateway) Broadcast(ctx context.Context, channelName, userID, content, u
 		content = "🚨 URGENT: " + content
 	}
 
-	return ch.SendProactive(userID, content)
+	for _, part := range FormatForChannel(content, capabilitiesOf(ch)) {
+		err := ch.SendProactive(userID, part)
+		g.recordSend(channelName, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,281 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWhenIdleWaitsForRunningTurns(t *testing.T) {
+	release := make(chan struct{})
+	g := New(func(ctx context.Context, msg Message) (string, error) {
+		<-release
+		return "", nil
+	})
+	ran := 0
+	g.WhenIdle(func() { ran++ })
+	if ran != 1 {
+		t.Fatalf("idle gateway: ran %d times, want 1", ran)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go g.processIngress(ctx)
+	g.PushIngress(Message{Channel: "test", SenderID: "alice", Content: "hi", Autonomous: true})
+	for turnsInFlight(g) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan struct{})
+	g.WhenIdle(func() { close(done) })
+	select {
+	case <-done:
+		t.Fatal("ran while a turn was in progress")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not run after the turn finished")
+	}
+}
+
+func turnsInFlight(g *Gateway) int {
+	g.turnsMu.Lock()
+	defer g.turnsMu.Unlock()
+	return len(g.inFlight)
+}
+
+type replyChannel struct {
+	caps Capabilities
+	sent []Message
+}
+
+func (c *replyChannel) Name() string                                       { return "talk" }
+func (c *replyChannel) Start(ctx context.Context, in chan<- Message) error { return nil }
+func (c *replyChannel) Send(msg Message) error                             { c.sent = append(c.sent, msg); return nil }
+func (c *replyChannel) SendProactive(userID, content string) error         { return nil }
+func (c *replyChannel) Capabilities() Capabilities                         { return c.caps }
+
+func TestReplyOptionsApplyToFirstPart(t *testing.T) {
+	g := New(func(ctx context.Context, msg Message) (string, error) {
+		opts, ok := ReplyOptionsFromContext(ctx)
+		if !ok {
+			t.Error("no reply options in a gateway turn")
+			return "", nil
+		}
+		opts.Quote, opts.Mentions = true, []string{"bob"}
+		return "first part\n\nsecond part", nil
+	})
+	full := &replyChannel{caps: Capabilities{Markdown: true, Replies: true, Mentions: true, MaxLength: 12}}
+	g.Register(full)
+	g.runTurn(context.Background(), Message{Channel: "talk", ThreadID: "room", ReplyToID: "room:42", Content: "hi"})
+	if len(full.sent) != 2 || !full.sent[0].Quote || len(full.sent[0].Mentions) != 1 || full.sent[1].Quote || full.sent[1].Mentions != nil {
+		t.Fatalf("sent = %+v", full.sent)
+	}
+
+	plain := &replyChannel{caps: Capabilities{Markdown: true}}
+	g.Register(plain)
+	g.runTurn(context.Background(), Message{Channel: "talk", ThreadID: "room", Content: "hi"})
+	if len(plain.sent) != 1 || plain.sent[0].Quote || plain.sent[0].Mentions != nil {
+		t.Errorf("channel without replies/mentions got %+v", plain.sent)
+	}
+	if _, ok := ReplyOptionsFromContext(context.Background()); ok {
+		t.Error("reply options outside a turn")
+	}
+}
+
+type failingChannel struct{ replyChannel }
+
+func (c *failingChannel) Name() string           { return "flaky" }
+func (c *failingChannel) Send(msg Message) error { return errors.New("HTTP 503") }
+
+func TestChannelHealthTracksSends(t *testing.T) {
+	g := New(func(ctx context.Context, msg Message) (string, error) { return "hello", nil })
+	g.Register(&replyChannel{})
+	g.Register(&failingChannel{})
+	if h := g.ChannelHealth("talk"); h.Status != "ok" {
+		t.Fatalf("idle channel = %+v", h)
+	}
+	if h := g.ChannelHealth("missing"); h.Status != "error" {
+		t.Fatalf("unregistered channel = %+v", h)
+	}
+	g.runTurn(context.Background(), Message{Channel: "talk", ThreadID: "room", Content: "hi"})
+	g.runTurn(context.Background(), Message{Channel: "flaky", ThreadID: "room", Content: "hi"})
+	if h := g.ChannelHealth("talk"); h.Status != "ok" || h.LastOK.IsZero() {
+		t.Fatalf("talk = %+v", h)
+	}
+	if h := g.ChannelHealth("flaky"); h.Status != "error" || h.Message != "HTTP 503" {
+		t.Fatalf("flaky = %+v", h)
+	}
+	checks := g.ChannelChecks()
+	if len(checks) != 2 || checks["channel:flaky"].HealthCheck().Status != "error" {
+		t.Fatalf("checks = %v", checks)
+	}
+}
+
+func TestUnaddressedMessagesArePassive(t *testing.T) {
+	turns := 0
+	g := New(func(ctx context.Context, msg Message) (string, error) { turns++; return "reply", nil })
+	ch := &replyChannel{}
+	g.Register(ch)
+	var passive []string
+	g.SetAddressing(
+		func(ctx context.Context, msg Message) bool { return msg.Content == "@bot hi" },
+		func(ctx context.Context, msg Message) { passive = append(passive, msg.Content) },
+	)
+	g.runTurn(context.Background(), Message{Channel: "talk", ThreadID: "room", Content: "lunch?"})
+	g.runTurn(context.Background(), Message{Channel: "talk", ThreadID: "room", Content: "@bot hi"})
+	g.runTurn(context.Background(), Message{Channel: "talk", ThreadID: "room", Content: "reminder", Autonomous: true})
+	if turns != 2 || len(ch.sent) != 1 || len(passive) != 1 || passive[0] != "lunch?" {
+		t.Fatalf("turns = %d, sent = %d, passive = %v", turns, len(ch.sent), passive)
+	}
+}
+
+func TestUrgentMessageInterruptsRunningTurn(t *testing.T) {
+	started := make(chan Message, 4)
+	g := New(func(ctx context.Context, msg Message) (string, error) {
+		started <- msg
+		if msg.Content == "long task" {
+			<-ctx.Done()
+			return "", context.Cause(ctx)
+		}
+		return "re: " + msg.Content, nil
+	})
+	ch := &replyChannel{}
+	g.Register(ch)
+	ctx := context.Background()
+	g.dispatch(ctx, Message{Channel: "talk", ThreadID: "room", Content: "long task"})
+	<-started
+	g.dispatch(ctx, Message{Channel: "talk", ThreadID: "room", Content: "also this"})
+	if pending := g.GetPendingAndClear("talk:room"); len(pending) != 1 {
+		t.Fatalf("pending = %+v", pending)
+	}
+	g.dispatch(ctx, Message{Channel: "talk", ThreadID: "room", Content: "later"})
+	g.dispatch(ctx, Message{Channel: "talk", ThreadID: "room", Content: "!STOP that"})
+
+	// The urgent message runs next, ahead of the queued one
+	for _, want := range []string{"!STOP that", "later"} {
+		select {
+		case m := <-started:
+			if m.Content != want {
+				t.Fatalf("ran %q, want %q", m.Content, want)
+			}
+			if want == "!STOP that" && (!m.Urgent || !m.Preempted) {
+				t.Errorf("urgent message = %+v", m)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%q did not run", want)
+		}
+	}
+	for turnsInFlight(g) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The interrupted turn sends nothing
+	if len(ch.sent) != 2 || ch.sent[0].Content != "re: !STOP that" {
+		t.Errorf("sent = %+v", ch.sent)
+	}
+}
+
+func TestUrgentMessageFromBlockedSenderDoesNotInterrupt(t *testing.T) {
+	started, release := make(chan Message, 4), make(chan struct{})
+	cancelled := make(chan error, 1)
+	g := New(func(ctx context.Context, msg Message) (string, error) {
+		started <- msg
+		if msg.Content == "long task" {
+			select {
+			case <-ctx.Done():
+				cancelled <- context.Cause(ctx)
+			case <-release:
+			}
+		}
+		return "re: " + msg.Content, nil
+	})
+	g.Register(&replyChannel{})
+	g.SetPreemption(func(ctx context.Context, msg Message) bool { return msg.SenderID != "mallory" })
+	ctx := context.Background()
+	g.dispatch(ctx, Message{Channel: "talk", ThreadID: "room", SenderID: "alice", Content: "long task"})
+	<-started
+
+	g.dispatch(ctx, Message{Channel: "talk", ThreadID: "room", SenderID: "mallory", Content: "!stop"})
+	select {
+	case err := <-cancelled:
+		t.Fatalf("blocked sender interrupted the turn: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	// Queued as a normal message, injected into the running turn
+	if pending := g.GetPendingAndClear("talk:room"); len(pending) != 1 || pending[0].Urgent || pending[0].SenderID != "mallory" {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	// An approved user may interrupt someone else's turn
+	g.dispatch(ctx, Message{Channel: "talk", ThreadID: "room", SenderID: "bob", Content: "!stop"})
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, ErrInterrupted) {
+			t.Errorf("cause = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		close(release)
+		t.Fatal("approved user did not interrupt the turn")
+	}
+	for turnsInFlight(g) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCancelTurn(t *testing.T) {
+	started, done := make(chan struct{}), make(chan error, 1)
+	g := New(func(ctx context.Context, msg Message) (string, error) {
+		close(started)
+		<-ctx.Done()
+		done <- context.Cause(ctx)
+		return "", ctx.Err()
+	})
+	ch := &replyChannel{}
+	g.Register(ch)
+	if g.CancelTurn("talk:room") {
+		t.Fatal("cancelled a turn that is not running")
+	}
+	g.dispatch(context.Background(), Message{Channel: "talk", ThreadID: "room", Content: "long task"})
+	<-started
+	if !g.CancelTurn("talk:room") {
+		t.Fatal("running turn not found")
+	}
+	if err := <-done; !errors.Is(err, ErrInterrupted) {
+		t.Errorf("cause = %v", err)
+	}
+	for turnsInFlight(g) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if len(ch.sent) != 0 {
+		t.Errorf("cancelled turn replied: %+v", ch.sent)
+	}
+}
+
+func TestStats(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	g := New(func(ctx context.Context, msg Message) (string, error) {
+		if msg.Content == "first" {
+			close(started)
+			<-release
+		}
+		return "", nil
+	})
+	g.Register(&replyChannel{})
+	g.dispatch(context.Background(), Message{Channel: "talk", ThreadID: "room", Content: "first"})
+	<-started
+	g.dispatch(context.Background(), Message{Channel: "talk", ThreadID: "room", Content: "second"})
+	st := g.Stats()
+	if len(st.Running) != 1 || st.Running[0] != "talk:room" || st.Waiting["talk:room"] != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+	close(release)
+	for turnsInFlight(g) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if st := g.Stats(); len(st.Running) != 0 || len(st.Waiting) != 0 {
+		t.Errorf("stats after the turns = %+v", st)
+	}
+}
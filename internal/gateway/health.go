@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"sync"
 	"time"
 
 	"github.com/hattiebot/hattiebot/internal/health"
@@ -37,6 +38,73 @@ func (g *Gateway) HealthCheck() health.ComponentHealth {
 	return h
 }
 
+// channelActivity is a channel's traffic: when a message last came in, and how sends went.
+type channelActivity struct {
+	mu       sync.Mutex
+	received time.Time
+	sends    health.Tracker
+}
+
+func (g *Gateway) channelActivity(channel string) *channelActivity {
+	g.activityMu.Lock()
+	defer g.activityMu.Unlock()
+	if g.activity == nil {
+		g.activity = make(map[string]*channelActivity)
+	}
+	a, ok := g.activity[channel]
+	if !ok {
+		a = &channelActivity{}
+		g.activity[channel] = a
+	}
+	return a
+}
+
+func (g *Gateway) recordReceived(channel string, at time.Time) {
+	a := g.channelActivity(channel)
+	a.mu.Lock()
+	a.received = at
+	a.mu.Unlock()
+}
+
+func (g *Gateway) recordSend(channel string, err error) {
+	g.channelActivity(channel).sends.Fail(err)
+}
+
+// ChannelHealth reports a channel by its sends (replies, status updates, proactive messages):
+// "error" while the last send failed, "degraded" shortly after a failure. A channel that has not
+// sent yet is ok once it has received a message; channels are pushed to, so there is no poll to
+// check.
+func (g *Gateway) ChannelHealth(name string) health.ComponentHealth {
+	if _, ok := g.ChannelByName(name); !ok {
+		return health.ComponentHealth{Name: "channel:" + name, Status: "error", Message: "channel not registered"}
+	}
+	a := g.channelActivity(name)
+	h := a.sends.Health("channel:" + name)
+	a.mu.Lock()
+	received := a.received
+	a.mu.Unlock()
+	if h.Status == "unknown" {
+		h.Status, h.Message = "ok", "no messages sent yet"
+	}
+	if !received.IsZero() {
+		h.Message += "; last received " + received.Format(time.RFC3339)
+	}
+	if h.LastOK.Before(received) && h.Status == "ok" {
+		h.LastOK = received
+	}
+	return h
+}
+
+// ChannelChecks returns a health check for each registered channel, named "channel:<name>".
+func (g *Gateway) ChannelChecks() map[string]health.HealthChecker {
+	out := make(map[string]health.HealthChecker)
+	for _, name := range g.GetChannelNames() {
+		name := name
+		out["channel:"+name] = health.CheckFunc(func() health.ComponentHealth { return g.ChannelHealth(name) })
+	}
+	return out
+}
+
 // GetChannelNames returns the names of all registered channels.
 func (g *Gateway) GetChannelNames() []string {
 	g.mu.RLock()
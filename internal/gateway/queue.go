@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"context"
+
+	"github.com/hattiebot/hattiebot/internal/logging"
+)
+
+// Queue is an external ingress queue shared by several HattieBot processes, so any of them can
+// run a turn. Messages published with the same key (ThreadKey) are consumed in order and by one
+// process at a time, which keeps the per-thread serialization of processIngress across processes.
+type Queue interface {
+	// Publish appends msg to the queue under key.
+	Publish(ctx context.Context, key string, msg Message) error
+	// Consume hands queued messages to handle until ctx is canceled. handle must not block; it
+	// calls done once the message is finished with. Messages not done when a process stops are
+	// delivered again to the process that takes over their key.
+	Consume(ctx context.Context, handle func(msg Message, done func())) error
+}
+
+// Distributed is implemented by channels whose replies can be sent from any process, e.g.
+// through a platform's HTTP API. Only their messages, and autonomous ones (which send no reply),
+// go through the Queue; the others are always handled by the process that received them.
+type Distributed interface {
+	Distributed() bool
+}
+
+// SetQueue makes the gateway publish distributable messages to q and run the turns it consumes
+// from q. Call it before StartAll.
+func (g *Gateway) SetQueue(q Queue) {
+	g.queue = q
+}
+
+// distributable reports whether m may be handled by another process.
+func (g *Gateway) distributable(m Message) bool {
+	if g.queue == nil {
+		return false
+	}
+	if m.Autonomous {
+		return true
+	}
+	ch, ok := g.ChannelByName(m.Channel)
+	if !ok {
+		return false
+	}
+	d, ok := ch.(Distributed)
+	return ok && d.Distributed()
+}
+
+// consumeQueue runs the turns of messages consumed from the queue until ctx is canceled.
+func (g *Gateway) consumeQueue(ctx context.Context) {
+	err := g.queue.Consume(ctx, func(msg Message, done func()) {
+		msg.done = done
+		g.dispatch(ctx, msg)
+	})
+	if err != nil && ctx.Err() == nil {
+		logging.For("gateway").Error("queue consumer stopped", "error", err)
+	}
+}
@@ -0,0 +1,88 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memQueue is a Queue that hands published messages straight to its consumer.
+type memQueue struct {
+	mu        sync.Mutex
+	published []string
+	done      int
+	ch        chan Message
+}
+
+func (q *memQueue) Publish(ctx context.Context, key string, msg Message) error {
+	q.mu.Lock()
+	q.published = append(q.published, key)
+	q.mu.Unlock()
+	q.ch <- msg
+	return nil
+}
+
+func (q *memQueue) Consume(ctx context.Context, handle func(msg Message, done func())) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m := <-q.ch:
+			handle(m, func() { q.mu.Lock(); q.done++; q.mu.Unlock() })
+		}
+	}
+}
+
+type distributedChannel struct{ replyChannel }
+
+func (c *distributedChannel) Name() string      { return "talk" }
+func (c *distributedChannel) Distributed() bool { return true }
+
+type localChannel struct{ replyChannel }
+
+func (c *localChannel) Name() string { return "term" }
+
+func TestQueueCarriesDistributedChannels(t *testing.T) {
+	var mu sync.Mutex
+	var handled []string
+	g := New(func(ctx context.Context, msg Message) (string, error) {
+		mu.Lock()
+		handled = append(handled, msg.Channel+":"+msg.Content)
+		mu.Unlock()
+		return "ok", nil
+	})
+	g.Register(&distributedChannel{})
+	g.Register(&localChannel{})
+	q := &memQueue{ch: make(chan Message, 10)}
+	g.SetQueue(q)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go g.StartAll(ctx)
+
+	g.PushIngress(Message{Channel: "talk", ThreadID: "room", Content: "hi"})
+	g.PushIngress(Message{Channel: "term", ThreadID: "console", Content: "local"})
+	g.PushIngress(Message{Channel: "term", ThreadID: "plan", Content: "task", Autonomous: true})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(handled)
+		mu.Unlock()
+		q.mu.Lock()
+		done := q.done
+		q.mu.Unlock()
+		if n == 3 && done == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("handled %v, %d done", handled, done)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.published) != 2 || q.published[0] != "talk:room" || q.published[1] != "term:plan" {
+		t.Errorf("published %v, want the Talk and the autonomous message", q.published)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/hattiebot/hattiebot/internal/store"
 )
@@ -26,7 +28,132 @@ func NewRouter(g *Gateway, db *store.DB) *Router {
 }
 
 // RouteMessage routes a proactive message to the user based on urgency and available contact info.
+// The user's notification rules (manage_notifications) apply first: a message less urgent than
+// their digest threshold waits for the next digest (DeliverDigests), and during their quiet hours
+// (manage_profile) a message below the quiet bypass urgency is held and sent by DeliverHeld when
+// they end. urgency is one of store.NotificationUrgencies; "" is normal.
 func (r *Router) RouteMessage(ctx context.Context, userID, content, urgency string) error {
+	rules, err := r.DB.GetNotificationRules(ctx, userID)
+	if err != nil {
+		log.Printf("[ROUTER] Failed to fetch notification rules for user %s: %v", userID, err)
+		rules = &store.NotificationRules{UserID: userID}
+	}
+	if rules.Digested(urgency) {
+		if err := r.DB.AddDigestItem(ctx, userID, content, urgency); err != nil {
+			return fmt.Errorf("queueing message for digest: %w", err)
+		}
+		return nil
+	}
+	if !rules.BreaksQuiet(urgency) {
+		if until := r.quietUntil(ctx, userID); !until.IsZero() {
+			if _, err := r.DB.HoldMessage(ctx, userID, content, urgency, until); err != nil {
+				return fmt.Errorf("holding message during quiet hours: %w", err)
+			}
+			log.Printf("[ROUTER] Quiet hours for %s: holding message until %s", userID, until.Format(time.RFC3339))
+			return nil
+		}
+	}
+	return r.send(ctx, userID, content, urgency, rules)
+}
+
+// quietUntil returns when the user's current quiet hours end, or the zero time outside them.
+func (r *Router) quietUntil(ctx context.Context, userID string) time.Time {
+	profile, err := r.DB.GetUserProfile(ctx, userID)
+	if err != nil {
+		log.Printf("[ROUTER] Failed to fetch profile for user %s: %v", userID, err)
+		return time.Time{}
+	}
+	return profile.QuietUntil(time.Now())
+}
+
+// DeliverHeld sends the messages held for quiet hours that have ended. The scheduler calls it on
+// every tick; a message whose send fails stays held and is retried on the next call.
+func (r *Router) DeliverHeld(ctx context.Context) {
+	held, err := r.DB.DueHeldMessages(ctx, time.Now())
+	if err != nil {
+		log.Printf("[ROUTER] Failed to list held messages: %v", err)
+		return
+	}
+	for _, m := range held {
+		if err := r.send(ctx, m.UserID, m.Content, m.Urgency, r.rules(ctx, m.UserID)); err != nil {
+			log.Printf("[ROUTER] Failed to deliver held message %d to %s: %v", m.ID, m.UserID, err)
+			continue
+		}
+		if err := r.DB.DeleteHeldMessage(ctx, m.ID); err != nil {
+			log.Printf("[ROUTER] Failed to delete held message %d: %v", m.ID, err)
+		}
+	}
+}
+
+// DeliverDigests sends each user whose digest interval has passed one message summarizing their
+// batched notifications. The first digest goes out an interval after its oldest notification.
+// Digests wait for quiet hours to end unless low urgency breaks through them; a user who turned
+// digests off gets what is left at once. The scheduler calls it on every tick.
+func (r *Router) DeliverDigests(ctx context.Context) {
+	users, err := r.DB.DigestUsers(ctx)
+	if err != nil {
+		log.Printf("[ROUTER] Failed to list digest users: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, userID := range users {
+		rules := r.rules(ctx, userID)
+		items, err := r.DB.DigestItems(ctx, userID)
+		if err != nil || len(items) == 0 {
+			continue
+		}
+		since := items[0].CreatedAt
+		if rules.LastDigestAt != nil {
+			since = *rules.LastDigestAt
+		}
+		if rules.DigestBelow != "" && now.Before(since.Add(rules.DigestInterval())) {
+			continue
+		}
+		if !rules.BreaksQuiet(store.UrgencyLow) && !r.quietUntil(ctx, userID).IsZero() {
+			continue
+		}
+		content, urgency := r.formatDigest(ctx, userID, items)
+		if err := r.send(ctx, userID, content, urgency, rules); err != nil {
+			log.Printf("[ROUTER] Failed to deliver digest to %s: %v", userID, err)
+			continue
+		}
+		if err := r.DB.MarkDigestSent(ctx, userID, items[len(items)-1].ID, now); err != nil {
+			log.Printf("[ROUTER] Failed to mark digest sent for %s: %v", userID, err)
+		}
+	}
+}
+
+// formatDigest renders batched notifications as one message, with times in the user's time
+// zone. The digest takes the urgency of its most urgent item, for the per-urgency channel.
+func (r *Router) formatDigest(ctx context.Context, userID string, items []store.DigestItem) (string, string) {
+	loc := time.Local
+	if profile, err := r.DB.GetUserProfile(ctx, userID); err == nil {
+		loc = profile.Location()
+	}
+	urgency := store.UrgencyLow
+	var b strings.Builder
+	fmt.Fprintf(&b, "Digest: %d notification(s)\n", len(items))
+	for _, it := range items {
+		if store.UrgencyRank(it.Urgency) > store.UrgencyRank(urgency) {
+			urgency = it.Urgency
+		}
+		fmt.Fprintf(&b, "\n- %s: %s", it.CreatedAt.In(loc).Format("Mon 15:04"), it.Content)
+	}
+	return b.String(), urgency
+}
+
+func (r *Router) rules(ctx context.Context, userID string) *store.NotificationRules {
+	rules, err := r.DB.GetNotificationRules(ctx, userID)
+	if err != nil {
+		log.Printf("[ROUTER] Failed to fetch notification rules for user %s: %v", userID, err)
+		return &store.NotificationRules{UserID: userID}
+	}
+	return rules
+}
+
+// send delivers a proactive message now: on the channel the rules name for its urgency, else on
+// the channel the user was last seen on.
+func (r *Router) send(ctx context.Context, userID, content, urgency string, rules *store.NotificationRules) error {
 	// 1. Fetch Contact Info (Facts)
 	// We look for phone_number or specific channel preferences
 	facts, err := r.DB.SearchFacts(ctx, userID, "contact_info")
@@ -62,13 +189,18 @@ func (r *Router) RouteMessage(ctx context.Context, userID, content, urgency stri
 			targetChannel = "admin_term"
 		} else if user.Platform == "nextcloud_talk" {
 			targetChannel = "nextcloud_talk"
-			// Nextcloud Talk SendProactive requires room token, not user ID
-			if user.Metadata != "" {
-				var meta map[string]string
-				if json.Unmarshal([]byte(user.Metadata), &meta) == nil && meta["last_room_token"] != "" {
-					targetID = meta["last_room_token"]
-				}
-			}
+		}
+	}
+	if ch := rules.Channels[urgency]; ch != "" {
+		targetChannel = ch
+	} else if ch := rules.Channels[store.UrgencyNormal]; ch != "" && urgency == "" {
+		targetChannel = ch
+	}
+	// Nextcloud Talk SendProactive requires room token, not user ID
+	if targetChannel == "nextcloud_talk" && user != nil && user.Metadata != "" {
+		var meta map[string]string
+		if json.Unmarshal([]byte(user.Metadata), &meta) == nil && meta["last_room_token"] != "" {
+			targetID = meta["last_room_token"]
 		}
 	}
 
@@ -116,6 +248,22 @@ func (r *Router) PushAgentPrompt(ctx context.Context, userID, prompt string, aut
 		ThreadID:   threadID,
 		ReplyToID:  threadID,
 		Autonomous: autonomous,
+		PlanID:     planID,
+	}
+	return r.Gateway.PushIngress(msg)
+}
+
+// PushBackgroundPrompt pushes an autonomous agent task that is not a scheduled plan, such as new
+// feed items, into threadID. The agent's reply is not auto-routed; it must use notify_user to send.
+func (r *Router) PushBackgroundPrompt(ctx context.Context, userID, threadID, prompt string) bool {
+	channel, _ := r.GetTargetForUser(ctx, userID)
+	msg := Message{
+		SenderID:   userID,
+		Content:    prompt,
+		Channel:    channel,
+		ThreadID:   threadID,
+		ReplyToID:  threadID,
+		Autonomous: true,
 	}
 	return r.Gateway.PushIngress(msg)
 }
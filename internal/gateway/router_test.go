@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+type proactiveChannel struct {
+	replyChannel
+	proactive []string
+}
+
+func (c *proactiveChannel) Name() string { return "admin_term" }
+func (c *proactiveChannel) SendProactive(userID, content string) error {
+	c.proactive = append(c.proactive, content)
+	return nil
+}
+
+func TestRouterHoldsMessagesDuringQuietHours(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	g := New(func(ctx context.Context, msg Message) (string, error) { return "", nil })
+	ch := &proactiveChannel{}
+	g.Register(ch)
+	r := NewRouter(g, db)
+
+	now := time.Now().UTC()
+	quiet := &store.UserProfile{UserID: "alice", Timezone: "UTC",
+		QuietStart: now.Add(-time.Hour).Format("15:04"), QuietEnd: now.Add(time.Hour).Format("15:04")}
+	if err := db.SetUserProfile(ctx, quiet); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.RouteMessage(ctx, "alice", "reminder", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RouteMessage(ctx, "alice", "server down", "urgent"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.proactive) != 1 || ch.proactive[0] != "🚨 URGENT: server down" {
+		t.Fatalf("sent during quiet hours = %q, want only the urgent message", ch.proactive)
+	}
+	r.DeliverHeld(ctx)
+	if len(ch.proactive) != 1 {
+		t.Fatalf("held message sent before quiet hours ended: %q", ch.proactive)
+	}
+
+	// Once the quiet hours are over, the held message goes out and is not sent again.
+	if _, err := db.HoldMessage(ctx, "alice", "digest", "", now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	r.DeliverHeld(ctx)
+	r.DeliverHeld(ctx)
+	if len(ch.proactive) != 2 || ch.proactive[1] != "digest" {
+		t.Fatalf("sent = %q, want the due message once", ch.proactive)
+	}
+}
+
+type emailChannel struct{ proactiveChannel }
+
+func (c *emailChannel) Name() string { return "email" }
+
+func TestRouterNotificationRules(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	g := New(func(ctx context.Context, msg Message) (string, error) { return "", nil })
+	term, email := &proactiveChannel{}, &emailChannel{}
+	g.Register(term)
+	g.Register(email)
+	r := NewRouter(g, db)
+
+	rules := &store.NotificationRules{UserID: "alice", Channels: map[string]string{"high": "email"}, DigestBelow: "normal", DigestHours: 1}
+	if err := db.SetNotificationRules(ctx, rules); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []struct{ content, urgency string }{
+		{"new feed item", "low"}, {"backup done", ""}, {"disk 90% full", "high"}, {"another feed item", "low"},
+	} {
+		if err := r.RouteMessage(ctx, "alice", m.content, m.urgency); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(term.proactive) != 1 || term.proactive[0] != "backup done" {
+		t.Errorf("terminal got %q, want the normal message", term.proactive)
+	}
+	if len(email.proactive) != 1 || email.proactive[0] != "disk 90% full" {
+		t.Errorf("email got %q, want the high message", email.proactive)
+	}
+
+	// The digest waits for its interval.
+	r.DeliverDigests(ctx)
+	if len(term.proactive) != 1 {
+		t.Fatalf("digest sent early: %q", term.proactive)
+	}
+	// Turning digests off sends what is left in one message.
+	rules.DigestBelow = ""
+	if err := db.SetNotificationRules(ctx, rules); err != nil {
+		t.Fatal(err)
+	}
+	r.DeliverDigests(ctx)
+	r.DeliverDigests(ctx)
+	if len(term.proactive) != 2 || !strings.Contains(term.proactive[1], "Digest: 2 notification(s)") ||
+		!strings.Contains(term.proactive[1], "new feed item") || !strings.Contains(term.proactive[1], "another feed item") {
+		t.Fatalf("terminal got %q, want one digest of both low messages", term.proactive)
+	}
+	if got, _ := db.GetNotificationRules(ctx, "alice"); got.LastDigestAt == nil || got.Channels["high"] != "email" {
+		t.Errorf("rules after digest = %+v", got)
+	}
+}
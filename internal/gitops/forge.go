@@ -0,0 +1,124 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Forge kinds.
+const (
+	GitHub = "github"
+	Gitea  = "gitea"
+)
+
+// Forge opens pull requests on GitHub or Gitea (Forgejo answers the same API).
+type Forge struct {
+	Kind   string // github or gitea
+	APIURL string // GitHub: default https://api.github.com; Gitea: the server URL, e.g. https://git.example.com
+	Repo   string // owner/name
+	Token  string
+	Client *http.Client // nil = 30 second timeout
+}
+
+// PullRequest is an opened pull request.
+type PullRequest struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+}
+
+// ForgeFromRemote fills in what f lacks from the remote URL: the owner/name and, for Gitea, the
+// server. It understands https://host/owner/name(.git) and git@host:owner/name(.git).
+func ForgeFromRemote(f Forge, remoteURL string) (Forge, error) {
+	host, path := "", ""
+	if rest, ok := strings.CutPrefix(remoteURL, "git@"); ok {
+		if i := strings.Index(rest, ":"); i > 0 {
+			host, path = rest[:i], rest[i+1:]
+		}
+	} else if u, err := url.Parse(remoteURL); err == nil && u.Host != "" {
+		host, path = u.Host, u.Path
+		if u.Scheme == "ssh" {
+			host = u.Hostname()
+		}
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if f.Repo == "" {
+		if strings.Count(path, "/") != 1 {
+			return f, fmt.Errorf("cannot tell owner/name from remote %q; set HATTIEBOT_GIT_FORGE_REPO", remoteURL)
+		}
+		f.Repo = path
+	}
+	if f.Kind == "" {
+		if host == "github.com" {
+			f.Kind = GitHub
+		} else {
+			return f, fmt.Errorf("unknown forge for %s; set HATTIEBOT_GIT_FORGE to github or gitea", host)
+		}
+	}
+	if f.APIURL == "" && f.Kind == Gitea {
+		if host == "" {
+			return f, fmt.Errorf("cannot tell the Gitea server from remote %q; set HATTIEBOT_GIT_FORGE_URL", remoteURL)
+		}
+		f.APIURL = "https://" + host
+	}
+	return f, nil
+}
+
+// OpenPullRequest asks the forge to merge head into base.
+func (f *Forge) OpenPullRequest(ctx context.Context, head, base, title, body string) (*PullRequest, error) {
+	if f.Token == "" {
+		return nil, fmt.Errorf("no forge token configured (set HATTIEBOT_GIT_TOKEN_SECRET)")
+	}
+	var endpoint, auth string
+	switch f.Kind {
+	case GitHub:
+		api := f.APIURL
+		if api == "" {
+			api = "https://api.github.com"
+		}
+		endpoint, auth = strings.TrimRight(api, "/")+"/repos/"+f.Repo+"/pulls", "Bearer "+f.Token
+	case Gitea:
+		endpoint, auth = strings.TrimRight(f.APIURL, "/")+"/api/v1/repos/"+f.Repo+"/pulls", "token "+f.Token
+	default:
+		return nil, fmt.Errorf("unknown forge %q (use github or gitea)", f.Kind)
+	}
+	data, _ := json.Marshal(map[string]string{"title": title, "head": head, "base": base, "body": body})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(respBody))
+		if len(msg) > 500 {
+			msg = msg[:500]
+		}
+		return nil, fmt.Errorf("%s answered %s: %s", f.Kind, resp.Status, msg)
+	}
+	var pr struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(respBody, &pr); err != nil {
+		return nil, fmt.Errorf("%s: unexpected answer: %w", f.Kind, err)
+	}
+	return &PullRequest{Number: pr.Number, URL: pr.HTMLURL}, nil
+}
@@ -0,0 +1,310 @@
+// Package gitops runs the git operations of the self-modification workflow on the checkout of
+// HattieBot's own source: status, diff, log, branches, commits and pushes to the configured
+// remote, and pull requests through the GitHub or Gitea API. Changes reach the base branch only
+// through a reviewed pull request: committing on the base branch and force pushes are refused.
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default author of commits when the repository has no user configured.
+const (
+	DefaultAuthorName  = "HattieBot"
+	DefaultAuthorEmail = "hattiebot@local"
+)
+
+// maxDiffBytes caps the diff returned to the agent.
+const maxDiffBytes = 64 << 10
+
+// Repo is a git checkout.
+type Repo struct {
+	Dir        string
+	Remote     string // default "origin"
+	BaseBranch string // branch pull requests target; commits on it are refused (default "main")
+	// Token authenticates pushes over HTTPS (a GitHub or Gitea access token); "" uses the
+	// credentials git already has.
+	Token string
+}
+
+func (r *Repo) remote() string {
+	if r.Remote != "" {
+		return r.Remote
+	}
+	return "origin"
+}
+
+func (r *Repo) base() string {
+	if r.BaseBranch != "" {
+		return r.BaseBranch
+	}
+	return "main"
+}
+
+// git runs a git command in the checkout and returns its trimmed stdout.
+func (r *Repo) git(ctx context.Context, env []string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Env = append(cmd.Env, env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+// Check reports whether Dir is a git checkout.
+func (r *Repo) Check(ctx context.Context) error {
+	if r.Dir == "" {
+		return fmt.Errorf("no source checkout configured (set HATTIEBOT_GIT_REPO_DIR)")
+	}
+	if _, err := r.git(ctx, nil, "rev-parse", "--git-dir"); err != nil {
+		return fmt.Errorf("%s is not a git checkout: %w", r.Dir, err)
+	}
+	return nil
+}
+
+// FileStatus is a changed file, with git's two-letter status (e.g. " M", "A ", "??").
+type FileStatus struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+// Status is the state of the checkout.
+type Status struct {
+	Branch string       `json:"branch"`
+	Base   string       `json:"base"`
+	Ahead  int          `json:"ahead,omitempty"` // commits not on the remote branch yet
+	Behind int          `json:"behind,omitempty"`
+	Clean  bool         `json:"clean"`
+	Files  []FileStatus `json:"files"`
+}
+
+// Status returns the current branch and the changed files.
+func (r *Repo) Status(ctx context.Context) (*Status, error) {
+	out, err := r.git(ctx, nil, "status", "--porcelain=v1", "--branch", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{Base: r.base(), Files: []FileStatus{}}
+	for _, line := range strings.Split(out, "\n") {
+		if head, ok := strings.CutPrefix(line, "## "); ok {
+			st.Branch, st.Ahead, st.Behind = parseBranchLine(head)
+			continue
+		}
+		if len(line) > 3 {
+			st.Files = append(st.Files, FileStatus{Path: line[3:], Status: line[:2]})
+		}
+	}
+	st.Clean = len(st.Files) == 0
+	return st, nil
+}
+
+// parseBranchLine reads "main...origin/main [ahead 1, behind 2]" or "No commits yet on main".
+func parseBranchLine(s string) (branch string, ahead, behind int) {
+	if b, ok := strings.CutPrefix(s, "No commits yet on "); ok {
+		return b, 0, 0
+	}
+	branch = s
+	if i := strings.Index(s, "..."); i >= 0 {
+		branch = s[:i]
+	} else if i := strings.Index(s, " "); i >= 0 {
+		branch = s[:i]
+	}
+	if i := strings.Index(s, "["); i >= 0 {
+		for _, part := range strings.Split(strings.Trim(s[i:], "[]"), ", ") {
+			if n, ok := strings.CutPrefix(part, "ahead "); ok {
+				ahead, _ = strconv.Atoi(n)
+			}
+			if n, ok := strings.CutPrefix(part, "behind "); ok {
+				behind, _ = strconv.Atoi(n)
+			}
+		}
+	}
+	return branch, ahead, behind
+}
+
+// CurrentBranch returns the checked-out branch.
+func (r *Repo) CurrentBranch(ctx context.Context) (string, error) {
+	b, err := r.git(ctx, nil, "symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("not on a branch (detached HEAD?): %w", err)
+	}
+	return b, nil
+}
+
+// cleanPaths checks that paths stay inside the checkout.
+func (r *Repo) cleanPaths(paths []string) ([]string, error) {
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs := p
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(r.Dir, p)
+		}
+		rel, err := filepath.Rel(r.Dir, filepath.Clean(abs))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("path %q is outside the repository", p)
+		}
+		out = append(out, rel)
+	}
+	return out, nil
+}
+
+// Diff returns the unstaged changes (staged: the changes to be committed), optionally of some
+// paths only. Long diffs are cut at maxDiffBytes; truncated tells.
+func (r *Repo) Diff(ctx context.Context, staged bool, paths []string) (diff string, truncated bool, err error) {
+	paths, err = r.cleanPaths(paths)
+	if err != nil {
+		return "", false, err
+	}
+	args := []string{"diff", "--no-color"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	args = append(append(args, "--"), paths...)
+	out, err := r.git(ctx, nil, args...)
+	if err != nil {
+		return "", false, err
+	}
+	if len(out) > maxDiffBytes {
+		return out[:maxDiffBytes], true, nil
+	}
+	return out, false, nil
+}
+
+// Commit is an entry of the history.
+type Commit struct {
+	SHA     string `json:"sha"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+	Subject string `json:"subject"`
+}
+
+// Log returns the newest n commits of the current branch.
+func (r *Repo) Log(ctx context.Context, n int) ([]Commit, error) {
+	out, err := r.git(ctx, nil, "log", "-n", strconv.Itoa(n), "--format=%h%x1f%an%x1f%aI%x1f%s")
+	if err != nil {
+		return nil, err
+	}
+	commits := []Commit{}
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Split(line, "\x1f")
+		if len(f) == 4 {
+			commits = append(commits, Commit{SHA: f[0], Author: f[1], Date: f[2], Subject: f[3]})
+		}
+	}
+	return commits, nil
+}
+
+// Branches lists the local branches.
+func (r *Repo) Branches(ctx context.Context) ([]string, error) {
+	out, err := r.git(ctx, nil, "branch", "--format=%(refname:short)")
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return []string{}, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// Switch checks out branch, creating it from the current HEAD when create is set. Uncommitted
+// changes come along.
+func (r *Repo) Switch(ctx context.Context, branch string, create bool) error {
+	if _, err := r.git(ctx, nil, "check-ref-format", "--branch", branch); err != nil {
+		return fmt.Errorf("invalid branch name %q", branch)
+	}
+	args := []string{"switch"}
+	if create {
+		args = append(args, "-c")
+	}
+	_, err := r.git(ctx, nil, append(args, branch)...)
+	return err
+}
+
+// Commit stages paths (every change when all is set) and commits them on the current branch,
+// returning the new commit's SHA. Commits on the base branch are refused.
+func (r *Repo) Commit(ctx context.Context, message string, paths []string, all bool) (string, error) {
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("commit message is required")
+	}
+	branch, err := r.CurrentBranch(ctx)
+	if err != nil {
+		return "", err
+	}
+	if branch == r.base() {
+		return "", fmt.Errorf("refusing to commit on the base branch %s; create a branch first", branch)
+	}
+	if all {
+		if _, err := r.git(ctx, nil, "add", "--all"); err != nil {
+			return "", err
+		}
+	} else {
+		if len(paths) == 0 {
+			return "", fmt.Errorf("give the paths to commit, or all")
+		}
+		clean, err := r.cleanPaths(paths)
+		if err != nil {
+			return "", err
+		}
+		if _, err := r.git(ctx, nil, append([]string{"add", "--all", "--"}, clean...)...); err != nil {
+			return "", err
+		}
+	}
+	if _, err := r.git(ctx, nil, "diff", "--cached", "--quiet"); err == nil {
+		return "", fmt.Errorf("nothing to commit")
+	}
+	var env []string
+	if email, _ := r.git(ctx, nil, "config", "user.email"); email == "" {
+		env = []string{"GIT_AUTHOR_NAME=" + DefaultAuthorName, "GIT_AUTHOR_EMAIL=" + DefaultAuthorEmail,
+			"GIT_COMMITTER_NAME=" + DefaultAuthorName, "GIT_COMMITTER_EMAIL=" + DefaultAuthorEmail}
+	}
+	if _, err := r.git(ctx, env, "commit", "--no-verify", "-m", message); err != nil {
+		return "", err
+	}
+	return r.git(ctx, nil, "rev-parse", "--short", "HEAD")
+}
+
+// Push pushes the current branch to the remote branch of the same name and sets it as upstream.
+// The base branch is never pushed and nothing is force-pushed.
+func (r *Repo) Push(ctx context.Context) (string, error) {
+	branch, err := r.CurrentBranch(ctx)
+	if err != nil {
+		return "", err
+	}
+	if branch == r.base() {
+		return "", fmt.Errorf("refusing to push the base branch %s; push a feature branch and open a pull request", branch)
+	}
+	var env []string
+	if r.Token != "" {
+		// Passed through the environment so the token appears in neither argv nor the remote URL
+		auth := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + r.Token))
+		env = []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=Authorization: Basic " + auth}
+	}
+	if _, err := r.git(ctx, env, "push", "--set-upstream", r.remote(), branch+":refs/heads/"+branch); err != nil {
+		return "", err
+	}
+	return branch, nil
+}
+
+// RemoteURL returns the URL of the remote.
+func (r *Repo) RemoteURL(ctx context.Context) (string, error) {
+	return r.git(ctx, nil, "remote", "get-url", r.remote())
+}
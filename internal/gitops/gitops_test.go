@@ -0,0 +1,166 @@
+package gitops
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// setup returns a checkout of a bare "origin" with one commit on main.
+func setup(t *testing.T) (*Repo, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull) // no user.email, so commits get the default author
+	root := t.TempDir()
+	origin := filepath.Join(root, "origin.git")
+	work := filepath.Join(root, "work")
+	run(t, root, "init", "--bare", "-b", "main", origin)
+	run(t, root, "clone", origin, work)
+	run(t, work, "switch", "-c", "main")
+	os.WriteFile(filepath.Join(work, "main.go"), []byte("package main\n"), 0644)
+	run(t, work, "add", ".")
+	run(t, work, "-c", "user.name=Dev", "-c", "user.email=dev@example.com", "commit", "-m", "Initial commit")
+	run(t, work, "push", "origin", "main")
+	return &Repo{Dir: work}, origin
+}
+
+func TestWorkflow(t *testing.T) {
+	ctx := context.Background()
+	repo, origin := setup(t)
+	if err := repo.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(repo.Dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	os.MkdirAll(filepath.Join(repo.Dir, "internal"), 0755)
+	os.WriteFile(filepath.Join(repo.Dir, "internal", "new.go"), []byte("package internal\n"), 0644)
+	st, err := repo.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Branch != "main" || st.Clean || len(st.Files) != 2 || st.Files[1] != (FileStatus{Path: "internal/new.go", Status: "??"}) {
+		t.Errorf("status = %+v", st)
+	}
+	diff, truncated, err := repo.Diff(ctx, false, []string{"main.go"})
+	if err != nil || truncated || !strings.Contains(diff, "+func main() {}") {
+		t.Errorf("diff = %q, %v, %v", diff, truncated, err)
+	}
+	if _, _, err := repo.Diff(ctx, false, []string{"../outside"}); err == nil {
+		t.Error("path outside the repository accepted")
+	}
+
+	// The base branch only changes through pull requests
+	if _, err := repo.Commit(ctx, "Add main", nil, true); err == nil || !strings.Contains(err.Error(), "base branch") {
+		t.Errorf("commit on main: %v", err)
+	}
+	if _, err := repo.Push(ctx); err == nil {
+		t.Error("pushed the base branch")
+	}
+	if err := repo.Switch(ctx, "bad..name", true); err == nil {
+		t.Error("invalid branch name accepted")
+	}
+	if err := repo.Switch(ctx, "hattie/main-func", true); err != nil {
+		t.Fatal(err)
+	}
+	sha, err := repo.Commit(ctx, "Add main func", []string{"main.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, _ := repo.Log(ctx, 5)
+	if len(commits) != 2 || !strings.HasPrefix(sha, commits[0].SHA[:7]) || commits[0].Author != DefaultAuthorName || commits[0].Subject != "Add main func" {
+		t.Errorf("log = %+v (commit %s)", commits, sha)
+	}
+	if st, _ := repo.Status(ctx); len(st.Files) != 1 || st.Files[0].Path != "internal/new.go" {
+		t.Errorf("only main.go should be committed: %+v", st.Files)
+	}
+	if _, err := repo.Commit(ctx, "Nothing", []string{"main.go"}, false); err == nil || !strings.Contains(err.Error(), "nothing to commit") {
+		t.Errorf("empty commit: %v", err)
+	}
+
+	repo.Token = "secret-token"
+	if branch, err := repo.Push(ctx); err != nil || branch != "hattie/main-func" {
+		t.Fatalf("push = %q, %v", branch, err)
+	}
+	if got := run(t, origin, "log", "-1", "--format=%s", "hattie/main-func"); got != "Add main func" {
+		t.Errorf("remote branch head = %q", got)
+	}
+	if st, _ := repo.Status(ctx); st.Ahead != 0 || st.Branch != "hattie/main-func" {
+		t.Errorf("status after push = %+v", st)
+	}
+	branches, _ := repo.Branches(ctx)
+	if strings.Join(branches, ",") != "hattie/main-func,main" {
+		t.Errorf("branches = %v", branches)
+	}
+}
+
+func TestForgeFromRemote(t *testing.T) {
+	for _, c := range []struct {
+		remote string
+		in     Forge
+		want   Forge
+	}{
+		{"https://github.com/hattiebot/hattiebot.git", Forge{}, Forge{Kind: GitHub, Repo: "hattiebot/hattiebot"}},
+		{"git@github.com:hattiebot/hattiebot.git", Forge{}, Forge{Kind: GitHub, Repo: "hattiebot/hattiebot"}},
+		{"ssh://git@git.example.com:2222/ops/hattie.git", Forge{Kind: Gitea}, Forge{Kind: Gitea, APIURL: "https://git.example.com", Repo: "ops/hattie"}},
+		{"https://git.example.com/ops/hattie", Forge{Kind: Gitea, APIURL: "http://gitea:3000"}, Forge{Kind: Gitea, APIURL: "http://gitea:3000", Repo: "ops/hattie"}},
+	} {
+		got, err := ForgeFromRemote(c.in, c.remote)
+		if err != nil || got != c.want {
+			t.Errorf("%s: %+v, %v", c.remote, got, err)
+		}
+	}
+	if _, err := ForgeFromRemote(Forge{}, "https://git.example.com/ops/hattie"); err == nil {
+		t.Error("unknown host without forge kind accepted")
+	}
+}
+
+func TestOpenPullRequest(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotBody map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"number": 7, "html_url": "https://example.com/pr/7"}`))
+	}))
+	defer srv.Close()
+
+	gh := &Forge{Kind: GitHub, APIURL: srv.URL, Repo: "o/r", Token: "ghp"}
+	pr, err := gh.OpenPullRequest(context.Background(), "hattie/x", "main", "Fix x", "Details")
+	if err != nil || pr.Number != 7 || pr.URL != "https://example.com/pr/7" {
+		t.Fatalf("pr = %+v, %v", pr, err)
+	}
+	if gotPath != "/repos/o/r/pulls" || gotAuth != "Bearer ghp" || gotBody["head"] != "hattie/x" || gotBody["base"] != "main" || gotBody["title"] != "Fix x" {
+		t.Errorf("github request: %s %s %v", gotPath, gotAuth, gotBody)
+	}
+
+	gitea := &Forge{Kind: Gitea, APIURL: srv.URL, Repo: "o/r", Token: "gt"}
+	if _, err := gitea.OpenPullRequest(context.Background(), "hattie/x", "main", "Fix x", ""); err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/api/v1/repos/o/r/pulls" || gotAuth != "token gt" {
+		t.Errorf("gitea request: %s %s", gotPath, gotAuth)
+	}
+
+	if _, err := (&Forge{Kind: GitHub, Repo: "o/r"}).OpenPullRequest(context.Background(), "a", "main", "t", ""); err == nil {
+		t.Error("pull request without token")
+	}
+}
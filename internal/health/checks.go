@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RecentErrorWindow is how long a failure keeps a recovered component "degraded".
+const RecentErrorWindow = 5 * time.Minute
+
+// CheckFunc adapts a function to HealthChecker.
+type CheckFunc func() ComponentHealth
+
+// HealthCheck calls f.
+func (f CheckFunc) HealthCheck() ComponentHealth {
+	return f()
+}
+
+// Tracker records the outcome of a component's real work (sends, requests) for components that
+// are not probed actively. Its zero value is ready to use.
+type Tracker struct {
+	mu        sync.RWMutex
+	lastOK    time.Time
+	lastError time.Time
+	errMsg    string
+}
+
+// OK records a success.
+func (t *Tracker) OK() {
+	t.mu.Lock()
+	t.lastOK = time.Now()
+	t.mu.Unlock()
+}
+
+// Fail records a failure; a nil err is a success.
+func (t *Tracker) Fail(err error) {
+	if err == nil {
+		t.OK()
+		return
+	}
+	t.mu.Lock()
+	t.lastError, t.errMsg = time.Now(), err.Error()
+	t.mu.Unlock()
+}
+
+// Health reports the tracked outcomes as component name: "error" while the last attempt failed,
+// "degraded" for RecentErrorWindow after a failure, "unknown" before any activity, else "ok".
+func (t *Tracker) Health(name string) ComponentHealth {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	h := ComponentHealth{Name: name, Status: "ok", LastOK: t.lastOK, LastError: t.lastError}
+	switch {
+	case t.lastOK.IsZero() && t.lastError.IsZero():
+		h.Status, h.Message = "unknown", "no activity yet"
+	case t.lastError.After(t.lastOK):
+		h.Status, h.Message = "error", t.errMsg
+	case !t.lastError.IsZero() && time.Since(t.lastError) < RecentErrorWindow:
+		h.Status, h.Message = "degraded", "recent error: "+t.errMsg
+	}
+	return h
+}
+
+// Probe checks a dependency by calling it (e.g. a one-word LLM completion), at most once per TTL;
+// checks in between report the cached result, so health pages can be polled without cost.
+type Probe struct {
+	name    string
+	ttl     time.Duration
+	timeout time.Duration
+	run     func(ctx context.Context) error
+
+	mu      sync.Mutex
+	checked time.Time
+	last    ComponentHealth
+	lastOK  time.Time
+}
+
+// NewProbe returns a probe named name that calls run at most every ttl, with a 15s timeout.
+func NewProbe(name string, ttl time.Duration, run func(ctx context.Context) error) *Probe {
+	return &Probe{name: name, ttl: ttl, timeout: 15 * time.Second, run: run}
+}
+
+// HealthCheck runs the probe when the cached result is older than the TTL. Concurrent checks wait
+// for the same run.
+func (p *Probe) HealthCheck() ComponentHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.checked.IsZero() && time.Since(p.checked) < p.ttl {
+		return p.last
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	start := time.Now()
+	err := p.run(ctx)
+	took := time.Since(start)
+	p.checked = time.Now()
+	h := ComponentHealth{Name: p.name, Status: "ok", Message: fmt.Sprintf("answered in %s", took.Round(time.Millisecond))}
+	if err != nil {
+		h.Status, h.Message, h.LastError = "error", err.Error(), p.checked
+	} else {
+		p.lastOK = p.checked
+	}
+	h.LastOK = p.lastOK
+	p.last = h
+	return h
+}
@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTrackerStatus(t *testing.T) {
+	var tr Tracker
+	if h := tr.Health("channel:talk"); h.Status != "unknown" || h.Name != "channel:talk" {
+		t.Fatalf("fresh tracker = %+v", h)
+	}
+	tr.OK()
+	if h := tr.Health("x"); h.Status != "ok" || h.LastOK.IsZero() {
+		t.Fatalf("after success = %+v", h)
+	}
+	tr.Fail(errors.New("HTTP 502"))
+	if h := tr.Health("x"); h.Status != "error" || h.Message != "HTTP 502" {
+		t.Fatalf("after failure = %+v", h)
+	}
+	tr.Fail(nil)
+	if h := tr.Health("x"); h.Status != "degraded" {
+		t.Fatalf("recovered = %+v, want degraded within the error window", h)
+	}
+}
+
+func TestProbeCachesResult(t *testing.T) {
+	calls := 0
+	fail := errors.New("401 unauthorized")
+	var err error
+	p := NewProbe("llm", time.Hour, func(ctx context.Context) error {
+		calls++
+		return err
+	})
+	if h := p.HealthCheck(); h.Status != "ok" || h.Name != "llm" {
+		t.Fatalf("first check = %+v", h)
+	}
+	err = fail
+	if h := p.HealthCheck(); h.Status != "ok" || calls != 1 {
+		t.Fatalf("cached check = %+v after %d calls", h, calls)
+	}
+	p.ttl = 0
+	h := p.HealthCheck()
+	if h.Status != "error" || h.Message != fail.Error() || h.LastOK.IsZero() || calls != 2 {
+		t.Fatalf("expired check = %+v after %d calls", h, calls)
+	}
+}
+
+func TestRegistryOverallStatus(t *testing.T) {
+	r := NewRegistry()
+	r.Register("a", CheckFunc(func() ComponentHealth { return ComponentHealth{Status: "ok"} }))
+	r.Register("b", CheckFunc(func() ComponentHealth { return ComponentHealth{Status: "unknown"} }))
+	if got := r.GetStatus(); got != "ok" {
+		t.Fatalf("status = %q, want ok", got)
+	}
+	r.Register("c", CheckFunc(func() ComponentHealth { return ComponentHealth{Status: "degraded"} }))
+	if got := r.GetStatus(); got != "degraded" {
+		t.Fatalf("status = %q, want degraded", got)
+	}
+	r.Register("d", CheckFunc(func() ComponentHealth { return ComponentHealth{Status: "error"} }))
+	report := r.Check()
+	if report.Status != "error" || len(report.Components) != 4 {
+		t.Fatalf("report = %+v", report)
+	}
+}
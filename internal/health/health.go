@@ -16,6 +16,7 @@ type ComponentHealth struct {
 
 // HealthReport aggregates health from all components.
 type HealthReport struct {
+	Status     string                     `json:"status"` // worst component status: "ok", "degraded", "error"
 	Timestamp  time.Time                  `json:"timestamp"`
 	Components map[string]ComponentHealth `json:"components"`
 	Errors     []LogEntry                 `json:"recent_errors"`
@@ -26,7 +27,7 @@ type LogEntry struct {
 	ID        int64     `json:"id"`
 	Timestamp time.Time `json:"timestamp"`
 	Level     string    `json:"level"`     // error, warn, info
-	Component string    `json:"component"` // db, llm, gateway, compactor
+	Component string    `json:"component"` // agent, gateway, scheduler, llm, ...
 	Message   string    `json:"message"`
 }
 
@@ -55,35 +56,51 @@ func (r *Registry) Register(name string, checker HealthChecker) {
 	r.checkers[name] = checker
 }
 
-// Check runs all health checks and returns a report.
+// Check runs all health checks concurrently (some probe a service) and returns a report.
 func (r *Registry) Check() HealthReport {
 	r.mu.RLock()
-	defer r.mu.RUnlock()
+	checkers := make(map[string]HealthChecker, len(r.checkers))
+	for name, c := range r.checkers {
+		checkers[name] = c
+	}
+	r.mu.RUnlock()
 
 	report := HealthReport{
 		Timestamp:  time.Now(),
 		Components: make(map[string]ComponentHealth),
 	}
-
-	for name, checker := range r.checkers {
-		report.Components[name] = checker.HealthCheck()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker HealthChecker) {
+			defer wg.Done()
+			h := checker.HealthCheck()
+			mu.Lock()
+			report.Components[name] = h
+			mu.Unlock()
+		}(name, checker)
 	}
-
+	wg.Wait()
+	report.Status = Overall(report.Components)
 	return report
 }
 
-// GetStatus returns the overall system status.
-func (r *Registry) GetStatus() string {
-	report := r.Check()
-	for _, c := range report.Components {
-		if c.Status == "error" {
+// Overall returns the worst status among components: "error", then "degraded", else "ok".
+func Overall(components map[string]ComponentHealth) string {
+	status := "ok"
+	for _, c := range components {
+		switch c.Status {
+		case "error":
 			return "error"
+		case "degraded":
+			status = "degraded"
 		}
 	}
-	for _, c := range report.Components {
-		if c.Status == "degraded" {
-			return "degraded"
-		}
-	}
-	return "ok"
+	return status
+}
+
+// GetStatus returns the overall system status.
+func (r *Registry) GetStatus() string {
+	return r.Check().Status
 }
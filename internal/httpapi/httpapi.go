@@ -0,0 +1,299 @@
+// Package httpapi serves the HTTP API (/api/v1) used by the Go SDK in pkg/hattiebot.
+//
+// Every request authenticates with an API token (see the manage_api_tokens tool) and acts as
+// the token's user. Messages go through the gateway like chat messages; tool calls go through
+// the tool executor middleware, so trust levels, role policies and the audit log apply.
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/channels/api"
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/httpauth"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools"
+	"github.com/hattiebot/hattiebot/pkg/hattiebot"
+)
+
+// DefaultReplyTimeout bounds how long a request waits for the agent's reply.
+const DefaultReplyTimeout = 10 * time.Minute
+
+// maxBodyBytes limits request bodies.
+const maxBodyBytes = 1 << 20
+
+// sseKeepAlive is how often an idle event stream sends a comment, so proxies keep it open.
+const sseKeepAlive = 15 * time.Second
+
+// Handler serves /api/v1/. Mount it on a mux at "/api/".
+type Handler struct {
+	DB           *store.DB
+	Executor     core.ToolExecutor // middleware-wrapped executor
+	Channel      *api.Channel
+	ReplyTimeout time.Duration // default DefaultReplyTimeout
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, hattiebot.APIPrefix)
+	if path == r.URL.Path {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	scope := store.ScopeWrite
+	if path == "/tools" {
+		scope = store.ScopeRead
+	}
+	caller, ok := h.authenticate(w, r, scope)
+	if !ok {
+		return
+	}
+	switch {
+	case path == "/messages":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.handleMessage(w, r, caller)
+	case path == "/tools":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.handleListTools(w, r)
+	case strings.HasPrefix(path, "/tools/") && strings.HasSuffix(path, "/call"):
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/tools/"), "/call")
+		h.handleCallTool(w, r, caller.User, name)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// Auth returns the authenticator for the handler's tokens.
+func (h *Handler) Auth() *httpauth.Authenticator {
+	return &httpauth.Authenticator{DB: h.DB}
+}
+
+// authenticate resolves the bearer token to its caller and checks that it has scope.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request, scope string) (*httpauth.Principal, bool) {
+	p, err := h.Auth().Authenticate(r, scope)
+	if err != nil {
+		writeError(w, err.Status, err.Message)
+		return nil, false
+	}
+	return p, true
+}
+
+// IsAdmin reports whether the request carries an owner's or admin's API token with the admin scope.
+func (h *Handler) IsAdmin(r *http.Request) bool {
+	return h.Auth().Allowed(r, store.ScopeAdmin)
+}
+
+func (h *Handler) handleMessage(w http.ResponseWriter, r *http.Request, caller *httpauth.Principal) {
+	var req hattiebot.MessageRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, "content is required")
+		return
+	}
+	events, cancel, err := h.Channel.Submit(caller.User.ID, req.ThreadID, req.Content, caller.NoAdmin())
+	switch {
+	case errors.Is(err, api.ErrThreadBusy):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer cancel()
+
+	timeout := h.ReplyTimeout
+	if timeout <= 0 {
+		timeout = DefaultReplyTimeout
+	}
+	ctx, stop := context.WithTimeout(r.Context(), timeout)
+	defer stop()
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		streamEvents(ctx, w, events)
+		return
+	}
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == hattiebot.EventReply {
+				writeJSON(w, http.StatusOK, hattiebot.Reply{Content: ev.Content, ThreadID: ev.ThreadID})
+				return
+			}
+		case <-ctx.Done():
+			writeError(w, http.StatusGatewayTimeout, "no reply within "+timeout.String())
+			return
+		}
+	}
+}
+
+// streamEvents writes events as server-sent events until the reply arrives or ctx ends.
+func streamEvents(ctx context.Context, w http.ResponseWriter, events <-chan hattiebot.Event) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	write := func(ev hattiebot.Event) {
+		b, _ := json.Marshal(ev)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, b)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev := <-events:
+			write(ev)
+			if ev.Type == hattiebot.EventReply {
+				return
+			}
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-ctx.Done():
+			write(hattiebot.Event{Type: hattiebot.EventError, Content: "no reply: " + ctx.Err().Error()})
+			return
+		}
+	}
+}
+
+func (h *Handler) handleListTools(w http.ResponseWriter, r *http.Request) {
+	out := []hattiebot.Tool{}
+	for _, d := range tools.BuiltinToolDefs() {
+		schema, _ := json.Marshal(d.Function.Parameters)
+		out = append(out, hattiebot.Tool{
+			Name:        d.Function.Name,
+			Description: d.Function.Description,
+			Builtin:     true,
+			Policy:      d.Policy,
+			InputSchema: schema,
+		})
+	}
+	registered, err := h.DB.AllTools(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, t := range registered {
+		tool := hattiebot.Tool{Name: t.Name, Description: t.Description, Status: t.Status, Version: t.Version}
+		if json.Valid([]byte(t.InputSchema)) {
+			tool.InputSchema = json.RawMessage(t.InputSchema)
+		}
+		out = append(out, tool)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleCallTool runs a built-in tool, or a registered tool through execute_registered_tool.
+func (h *Handler) handleCallTool(w http.ResponseWriter, r *http.Request, user *store.User, name string) {
+	var args json.RawMessage
+	if err := decodeBody(r, &args); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage("{}")
+	}
+	if !isBuiltin(name) {
+		tool, err := h.DB.ToolByName(r.Context(), name)
+		if err != nil || tool == nil {
+			writeError(w, http.StatusNotFound, "unknown tool: "+name)
+			return
+		}
+		b, _ := json.Marshal(map[string]interface{}{"name": name, "args": args})
+		name, args = "execute_registered_tool", b
+	}
+	ctx := context.WithValue(r.Context(), "user_id", user.ID)
+	ctx = context.WithValue(ctx, "user_trust", user.TrustLevel)
+	ctx = context.WithValue(ctx, "user_role", user.Role)
+	// The caller makes the call explicitly, so confirm-gated tools are not drafted (middleware.Drafts)
+	ctx = context.WithValue(ctx, "direct_call", true)
+	out, err := h.Executor.Execute(ctx, name, string(args))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if msg, ok := toolError(out); ok {
+		writeError(w, http.StatusUnprocessableEntity, msg)
+		return
+	}
+	res := hattiebot.ToolResult{Output: json.RawMessage(out)}
+	if !json.Valid([]byte(out)) {
+		res.Output, _ = json.Marshal(out)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func isBuiltin(name string) bool {
+	for _, d := range tools.BuiltinToolDefs() {
+		if d.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// toolError reports whether a tool's output is an error result ({"error": "..."} and nothing else).
+func toolError(out string) (string, bool) {
+	var m map[string]json.RawMessage
+	if json.Unmarshal([]byte(out), &m) != nil || len(m) != 1 {
+		return "", false
+	}
+	var msg string
+	if json.Unmarshal(m["error"], &msg) != nil || msg == "" {
+		return "", false
+	}
+	return msg, true
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(b) > maxBodyBytes {
+		return fmt.Errorf("request body too large")
+	}
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, hattiebot.ErrorResponse{Error: msg})
+}
@@ -0,0 +1,253 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/channels/api"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools"
+	"github.com/hattiebot/hattiebot/pkg/hattiebot"
+)
+
+// newTestAPI serves the API backed by a gateway that reports one status update and echoes the message.
+func newTestAPI(t *testing.T) (*hattiebot.Client, *store.DB, string) {
+	t.Helper()
+	h, db, token := newTestHandler(t)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return hattiebot.New(srv.URL, token), db, srv.URL
+}
+
+// newTestHandler returns the API handler of newTestAPI and an API token for alice.
+func newTestHandler(t *testing.T) (*Handler, *store.DB, string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, id := range []string{"alice", "mallory"} {
+		if _, err := db.GetOrCreateUser(ctx, id, "", "api"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.UpdateUserTrust(ctx, "mallory", "blocked"); err != nil {
+		t.Fatal(err)
+	}
+
+	var gw *gateway.Gateway
+	gw = gateway.New(func(ctx context.Context, msg gateway.Message) (string, error) {
+		gw.RouteStatus(msg, "", "thinking about "+msg.Content)
+		return "echo from " + msg.SenderID + ": " + msg.Content, nil
+	})
+	ch := api.New(gw.PushIngress)
+	gw.Register(ch)
+	go gw.StartAll(ctx)
+
+	h := &Handler{DB: db, Executor: &tools.Executor{DB: db, WorkspaceDir: t.TempDir(), ConfigDir: t.TempDir()}, Channel: ch}
+	_, token, err := db.CreateAPIToken(ctx, "alice", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, db, token
+}
+
+func TestSendAndStream(t *testing.T) {
+	c, _, _ := newTestAPI(t)
+	ctx := context.Background()
+
+	reply, err := c.Send(ctx, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "echo from alice: hello" || reply.ThreadID != "user:alice" {
+		t.Errorf("reply = %+v", reply)
+	}
+
+	s, err := c.Stream(ctx, hattiebot.MessageRequest{Content: "lights", ThreadID: "homelab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var statuses []string
+	reply, err = s.Reply(func(st string) { statuses = append(statuses, st) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "echo from alice: lights" || reply.ThreadID != "homelab" {
+		t.Errorf("streamed reply = %+v", reply)
+	}
+	if len(statuses) != 1 || statuses[0] != "thinking about lights" {
+		t.Errorf("statuses = %v", statuses)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	c, db, url := newTestAPI(t)
+	ctx := context.Background()
+	var apiErr *hattiebot.APIError
+
+	if _, err := hattiebot.New(url, "hb_wrong").Send(ctx, "hi"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong token: %v", err)
+	}
+	_, blocked, _ := db.CreateAPIToken(ctx, "mallory", "")
+	if _, err := hattiebot.New(url, blocked).ListTools(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("blocked user: %v", err)
+	}
+	tokens, _ := db.ListAPITokens(ctx)
+	if len(tokens) != 2 || tokens[0].LastUsedAt != nil || tokens[1].LastUsedAt == nil {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+	if err := db.RevokeAPIToken(ctx, tokens[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListTools(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("revoked token: %v", err)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	_, db, url := newTestAPI(t)
+	ctx := context.Background()
+	var apiErr *hattiebot.APIError
+
+	_, readOnly, err := db.CreateAPIToken(ctx, "alice", "reader", store.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hattiebot.New(url, readOnly).ListTools(ctx); err != nil {
+		t.Errorf("read scope, list tools: %v", err)
+	}
+	if _, err := hattiebot.New(url, readOnly).Send(ctx, "hi"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("read scope, send: %v", err)
+	}
+	if _, _, err := db.CreateAPIToken(ctx, "alice", "", "superuser"); err == nil {
+		t.Error("unknown scope accepted")
+	}
+
+	// An admin's token without the admin scope acts with operator rights
+	if _, err := db.GetOrCreateUser(ctx, "boss", "", "api"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateUserRole(ctx, "boss", store.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	_, writer, _ := db.CreateAPIToken(ctx, "boss", "", store.ScopeWrite)
+	_, admin, _ := db.CreateAPIToken(ctx, "boss", "")
+	if _, err := hattiebot.New(url, writer).CallTool(ctx, "manage_api_tokens", map[string]string{"action": "list"}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("write scope, admin tool: %v", err)
+	}
+	if _, err := hattiebot.New(url, admin).CallTool(ctx, "manage_api_tokens", map[string]string{"action": "list"}); err != nil {
+		t.Errorf("admin scope, admin tool: %v", err)
+	}
+
+	h := &Handler{DB: db}
+	isAdmin := func(token string) bool {
+		r := httptest.NewRequest(http.MethodGet, "/health?detail=1", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		return h.IsAdmin(r)
+	}
+	if isAdmin(writer) || !isAdmin(admin) {
+		t.Errorf("IsAdmin: write scope %v, admin scope %v", isAdmin(writer), isAdmin(admin))
+	}
+}
+
+func TestToolsAndSchedules(t *testing.T) {
+	c, _, _ := newTestAPI(t)
+	ctx := context.Background()
+
+	list, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, tool := range list {
+		if tool.Name == "manage_schedule" && tool.Builtin && strings.Contains(string(tool.InputSchema), "run_at") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("manage_schedule missing from %d tools", len(list))
+	}
+
+	created, err := c.CreateSchedule(ctx, hattiebot.ScheduleRequest{Description: "water plants", ScheduleType: "daily", RunAt: "09:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plans, err := c.ListSchedules(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 1 || plans[0].ID != created.ID || plans[0].UserID != "alice" || plans[0].NextRunAt == nil {
+		t.Fatalf("plans = %+v", plans)
+	}
+	if runs, err := c.ScheduleHistory(ctx, created.ID, 5); err != nil || len(runs) != 0 {
+		t.Errorf("history = %v, %v", runs, err)
+	}
+	if err := c.PauseSchedule(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if plans, _ := c.ListSchedules(ctx); len(plans) != 0 {
+		t.Errorf("paused plan still listed as active: %+v", plans)
+	}
+
+	var apiErr *hattiebot.APIError
+	if _, err := c.ScheduleHistory(ctx, 999, 0); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(apiErr.Message, "not found") {
+		t.Errorf("tool error: %v", err)
+	}
+	if _, err := c.CallTool(ctx, "no_such_tool", nil); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown tool: %v", err)
+	}
+}
+
+func TestLogsWebhooksAndBackups(t *testing.T) {
+	h, db, token := newTestHandler(t)
+	logs := store.NewLogStore(db.DB)
+	h.Executor.(*tools.Executor).LogStore = logs
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := hattiebot.New(srv.URL, token)
+	ctx := context.Background()
+
+	logs.LogInfo("agent", "turn started")
+	logs.LogError("llm", "provider timed out")
+	entries, err := c.Logs(ctx, hattiebot.LogQuery{Component: "llm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Level != "error" || entries[0].Message != "provider timed out" || entries[0].ID == 0 {
+		t.Errorf("logs = %+v", entries)
+	}
+
+	route := map[string]string{"path": "/webhook/github", "id": "github", "secret_header": "X-Hub-Signature-256", "auth_type": "hmac_sha256", "target_tool": "notify_user"}
+	if _, err := c.AddWebhookRoute(ctx, route); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := c.WebhookRoutes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes[0].ID != "github" || routes[0].AuthType != "hmac_sha256" || routes[0].TargetTool != "notify_user" {
+		t.Errorf("routes = %+v", routes)
+	}
+	if err := c.RemoveWebhookRoute(ctx, "github"); err != nil {
+		t.Fatal(err)
+	}
+	if routes, _ := c.WebhookRoutes(ctx); len(routes) != 0 {
+		t.Errorf("routes after remove = %+v", routes)
+	}
+
+	var apiErr *hattiebot.APIError
+	if _, err := c.Backup(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(apiErr.Message, "HATTIEBOT_BACKUP_TARGET") {
+		t.Errorf("backup without a target: %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/channels/api"
+	"github.com/hattiebot/hattiebot/internal/httpauth"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/pkg/hattiebot"
+)
+
+// OpenAIPrefix is where the OpenAI-compatible endpoints are served.
+const OpenAIPrefix = "/v1"
+
+// DefaultOpenAIModel is the model name reported to OpenAI clients.
+const DefaultOpenAIModel = "hattiebot"
+
+// OpenAIHandler serves an OpenAI-compatible chat completions API (/v1/chat/completions and
+// /v1/models) on top of the HTTP API, so chat UIs and OpenAI client libraries can talk to the
+// agent as if it were a model. Mount it on a mux at "/v1/".
+//
+// The agent keeps the conversation itself: each request submits only the last user message, in
+// the thread of its API token (or of the conversation ID in the X-Conversation-Id header or
+// conversation_id field). Earlier messages the client resends are ignored.
+type OpenAIHandler struct {
+	API   *Handler
+	Model string // default DefaultOpenAIModel
+}
+
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model          string        `json:"model"`
+	Messages       []chatMessage `json:"messages"`
+	Stream         bool          `json:"stream"`
+	ConversationID string        `json:"conversation_id"`
+}
+
+type chatChoice struct {
+	Index        int             `json:"index"`
+	Message      *chatReplyDelta `json:"message,omitempty"`
+	Delta        *chatReplyDelta `json:"delta,omitempty"`
+	FinishReason *string         `json:"finish_reason"`
+}
+
+type chatReplyDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type chatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+// chatUsage is always zero: the agent's own LLM usage is tracked by usage_report, not per reply.
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code,omitempty"`
+	} `json:"error"`
+}
+
+func (h *OpenAIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, OpenAIPrefix)
+	scope := store.ScopeWrite
+	if path == "/models" {
+		scope = store.ScopeRead
+	}
+	caller, authErr := h.API.Auth().Authenticate(r, scope)
+	if authErr != nil {
+		code := "invalid_api_key"
+		if authErr.Status == http.StatusForbidden {
+			code = "permission_denied"
+		}
+		writeOpenAIError(w, authErr.Status, authErr.Message, code)
+		return
+	}
+	switch path {
+	case "/models":
+		if r.Method != http.MethodGet {
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed", "")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"object": "list",
+			"data":   []map[string]interface{}{{"id": h.model(), "object": "model", "created": 0, "owned_by": "hattiebot"}},
+		})
+	case "/chat/completions":
+		if r.Method != http.MethodPost {
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed", "")
+			return
+		}
+		var req chatCompletionRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, err.Error(), "")
+			return
+		}
+		content, err := lastUserMessage(req.Messages)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, err.Error(), "")
+			return
+		}
+		thread := "openai:" + strconv.FormatInt(caller.Token.ID, 10)
+		conv := r.Header.Get("X-Conversation-Id")
+		if conv == "" {
+			conv = req.ConversationID
+		}
+		if conv != "" {
+			thread += ":" + conv
+		}
+		h.complete(w, r, caller, thread, content, req.Stream)
+	default:
+		writeOpenAIError(w, http.StatusNotFound, "unknown endpoint "+r.URL.Path, "")
+	}
+}
+
+func (h *OpenAIHandler) model() string {
+	if h.Model != "" {
+		return h.Model
+	}
+	return DefaultOpenAIModel
+}
+
+// lastUserMessage returns the text of the last message, which must be from the user. Content may
+// be a string or a list of content parts; only text parts are used.
+func lastUserMessage(messages []chatMessage) (string, error) {
+	if len(messages) == 0 {
+		return "", errors.New("messages is required")
+	}
+	last := messages[len(messages)-1]
+	if last.Role != "user" {
+		return "", fmt.Errorf("the last message must have role user, got %q", last.Role)
+	}
+	var text string
+	if err := json.Unmarshal(last.Content, &text); err != nil {
+		var parts []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if json.Unmarshal(last.Content, &parts) != nil {
+			return "", errors.New("message content must be a string or a list of content parts")
+		}
+		var texts []string
+		for _, p := range parts {
+			if p.Type == "text" && p.Text != "" {
+				texts = append(texts, p.Text)
+			}
+		}
+		text = strings.Join(texts, "\n")
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", errors.New("the last user message has no text")
+	}
+	return text, nil
+}
+
+// complete submits content to the agent and answers with its reply as a chat completion, or as
+// a stream of chat completion chunks ending in "data: [DONE]".
+func (h *OpenAIHandler) complete(w http.ResponseWriter, r *http.Request, caller *httpauth.Principal, thread, content string, stream bool) {
+	events, cancel, err := h.API.Channel.Submit(caller.User.ID, thread, content, caller.NoAdmin())
+	switch {
+	case errors.Is(err, api.ErrThreadBusy):
+		writeOpenAIError(w, http.StatusConflict, err.Error()+" (set X-Conversation-Id to talk in parallel)", "conversation_busy")
+		return
+	case err != nil:
+		writeOpenAIError(w, http.StatusServiceUnavailable, err.Error(), "")
+		return
+	}
+	defer cancel()
+
+	timeout := h.API.ReplyTimeout
+	if timeout <= 0 {
+		timeout = DefaultReplyTimeout
+	}
+	ctx, stop := context.WithTimeout(r.Context(), timeout)
+	defer stop()
+
+	base := chatCompletion{
+		ID:      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		Created: time.Now().Unix(),
+		Model:   h.model(),
+	}
+	if !stream {
+		reply, ok := waitReply(ctx, events)
+		if !ok {
+			writeOpenAIError(w, http.StatusGatewayTimeout, "no reply within "+timeout.String(), "timeout")
+			return
+		}
+		stop := "stop"
+		res := base
+		res.Object = "chat.completion"
+		res.Choices = []chatChoice{{Message: &chatReplyDelta{Role: "assistant", Content: reply}, FinishReason: &stop}}
+		res.Usage = &chatUsage{}
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	send := func(v interface{}) {
+		b, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", b)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	chunk := func(delta chatReplyDelta, finish *string) chatCompletion {
+		c := base
+		c.Object = "chat.completion.chunk"
+		c.Choices = []chatChoice{{Delta: &delta, FinishReason: finish}}
+		return c
+	}
+	// The role chunk goes out at once so clients know the request was accepted
+	send(chunk(chatReplyDelta{Role: "assistant"}, nil))
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type != hattiebot.EventReply {
+				continue
+			}
+			send(chunk(chatReplyDelta{Content: ev.Content}, nil))
+			stop := "stop"
+			send(chunk(chatReplyDelta{}, &stop))
+			io.WriteString(w, "data: [DONE]\n\n")
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-ctx.Done():
+			var e openAIError
+			e.Error.Message, e.Error.Type, e.Error.Code = "no reply: "+ctx.Err().Error(), "server_error", "timeout"
+			send(e)
+			return
+		}
+	}
+}
+
+// waitReply waits for the reply event; status events are dropped since chat completions have no
+// place for them.
+func waitReply(ctx context.Context, events <-chan hattiebot.Event) (string, bool) {
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == hattiebot.EventReply {
+				return ev.Content, true
+			}
+		case <-ctx.Done():
+			return "", false
+		}
+	}
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, msg, code string) {
+	var e openAIError
+	e.Error.Message, e.Error.Code = msg, code
+	switch {
+	case status == http.StatusUnauthorized:
+		e.Error.Type = "authentication_error"
+	case status == http.StatusForbidden:
+		e.Error.Type = "permission_error"
+	case status < 500:
+		e.Error.Type = "invalid_request_error"
+	default:
+		e.Error.Type = "server_error"
+	}
+	writeJSON(w, status, e)
+}
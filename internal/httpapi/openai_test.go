@@ -0,0 +1,139 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestOpenAI(t *testing.T) (string, string) {
+	t.Helper()
+	h, _, token := newTestHandler(t)
+	srv := httptest.NewServer(&OpenAIHandler{API: h})
+	t.Cleanup(srv.Close)
+	return srv.URL, token
+}
+
+func postChat(t *testing.T, url, token, body string, header map[string]string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url+"/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestOpenAIChatCompletions(t *testing.T) {
+	url, token := newTestOpenAI(t)
+
+	// Only the last user message is sent; the agent keeps the history itself
+	resp := postChat(t, url, token, `{"model": "hattiebot", "messages": [
+		{"role": "system", "content": "be brief"},
+		{"role": "user", "content": "earlier"},
+		{"role": "assistant", "content": "ok"},
+		{"role": "user", "content": [{"type": "text", "text": "hello"}, {"type": "image_url", "image_url": {"url": "x"}}]}
+	]}`, nil)
+	var res struct {
+		Object  string `json:"object"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %v", resp.StatusCode, err)
+	}
+	if res.Object != "chat.completion" || res.Model != DefaultOpenAIModel || len(res.Choices) != 1 ||
+		res.Choices[0].Message.Role != "assistant" || res.Choices[0].Message.Content != "echo from alice: hello" || res.Choices[0].FinishReason != "stop" {
+		t.Errorf("completion = %+v", res)
+	}
+
+	// Streaming: a role chunk, the content, a finish chunk, then [DONE]
+	resp = postChat(t, url, token, `{"stream": true, "messages": [{"role": "user", "content": "hi"}]}`, map[string]string{"X-Conversation-Id": "c2"})
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("content type %q", ct)
+	}
+	var content, finish string
+	done := false
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		data := strings.TrimPrefix(sc.Text(), "data: ")
+		if data == sc.Text() {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk struct {
+			Object  string `json:"object"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil || chunk.Object != "chat.completion.chunk" {
+			t.Fatalf("chunk %s: %v", data, err)
+		}
+		content += chunk.Choices[0].Delta.Content
+		if chunk.Choices[0].FinishReason != nil {
+			finish = *chunk.Choices[0].FinishReason
+		}
+	}
+	if !done || content != "echo from alice: hi" || finish != "stop" {
+		t.Errorf("stream: done=%v content=%q finish=%q", done, content, finish)
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	url, token := newTestOpenAI(t)
+	cases := []struct {
+		token, body string
+		status      int
+		errType     string
+	}{
+		{"hb_wrong", `{"messages": [{"role": "user", "content": "hi"}]}`, http.StatusUnauthorized, "authentication_error"},
+		{token, `{"messages": []}`, http.StatusBadRequest, "invalid_request_error"},
+		{token, `{"messages": [{"role": "assistant", "content": "hi"}]}`, http.StatusBadRequest, "invalid_request_error"},
+	}
+	for _, c := range cases {
+		resp := postChat(t, url, c.token, c.body, nil)
+		var e openAIError
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		if resp.StatusCode != c.status || e.Error.Type != c.errType || e.Error.Message == "" {
+			t.Errorf("%s: status %d, error %+v", c.body, resp.StatusCode, e.Error)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, url+"/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var models struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&models); err != nil || len(models.Data) != 1 || models.Data[0].ID != DefaultOpenAIModel {
+		t.Errorf("models = %+v, %v", models, err)
+	}
+}
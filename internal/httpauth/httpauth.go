@@ -0,0 +1,112 @@
+// Package httpauth authenticates HTTP requests with API tokens (see the manage_api_tokens tool)
+// and checks their scopes. It is shared by every endpoint that acts for a user: the HTTP API,
+// the OpenAI-compatible API, the dashboard, /chat and /health?detail=1.
+//
+// A request acts as the token's user, limited by the token's scopes: read, write or admin.
+// Without the admin scope, an owner's or admin's token acts with operator rights.
+package httpauth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Token *store.APIToken
+	// User is the token's user, without admin rights when the token lacks the admin scope.
+	User *store.User
+}
+
+// NoAdmin reports whether the caller acts without admin rights it would otherwise have.
+func (p *Principal) NoAdmin() bool {
+	return !p.Token.HasScope(store.ScopeAdmin)
+}
+
+// Error is a failed authentication or authorization, with the HTTP status to answer.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Authenticator resolves bearer tokens against the api_tokens table.
+type Authenticator struct {
+	DB *store.DB
+}
+
+// Authenticate resolves the request's bearer token and checks that it has scope. Tokens of
+// blocked and not yet approved users are refused; the admin scope also needs an owner or admin.
+func (a *Authenticator) Authenticate(r *http.Request, scope string) (*Principal, *Error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return nil, &Error{http.StatusUnauthorized, "missing bearer token"}
+	}
+	t, err := a.DB.LookupAPIToken(r.Context(), token)
+	if err == sql.ErrNoRows {
+		return nil, &Error{http.StatusUnauthorized, "invalid token"}
+	}
+	if err != nil {
+		log.Printf("[Auth] token lookup: %v", err)
+		return nil, &Error{http.StatusInternalServerError, "internal error"}
+	}
+	user, err := a.DB.GetUser(r.Context(), t.UserID)
+	if err != nil {
+		return nil, &Error{http.StatusUnauthorized, "token user no longer exists"}
+	}
+	if user.TrustLevel == "blocked" || user.TrustLevel == "restricted" {
+		return nil, &Error{http.StatusForbidden, "user " + user.ID + " is " + user.TrustLevel}
+	}
+	if !t.HasScope(scope) {
+		return nil, &Error{http.StatusForbidden, "token lacks the " + scope + " scope"}
+	}
+	if scope == store.ScopeAdmin && user.TrustLevel != "admin" && !store.RoleAtLeast(user.Role, store.RoleAdmin) {
+		return nil, &Error{http.StatusForbidden, "admin endpoints are for admins; " + user.ID + " is not one"}
+	}
+	p := &Principal{Token: t, User: user}
+	if p.NoAdmin() {
+		p.User = user.WithoutAdmin()
+	}
+	return p, nil
+}
+
+// Allowed reports whether the request carries a token with scope.
+func (a *Authenticator) Allowed(r *http.Request, scope string) bool {
+	_, err := a.Authenticate(r, scope)
+	return err == nil
+}
+
+// Require serves next only to requests whose token has scope, with the caller in the request's
+// context (see FromContext). Others get a JSON {"error": "..."} with 401 or 403.
+func (a *Authenticator) Require(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r, scope)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(err.Status)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Message})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+	})
+}
+
+type principalKey struct{}
+
+// NewContext returns ctx carrying the caller.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the caller Require authenticated, or nil.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
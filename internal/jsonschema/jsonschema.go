@@ -0,0 +1,406 @@
+// Package jsonschema validates tool arguments against the JSON Schema subset used by tool
+// definitions: type, properties, required, additionalProperties, items, enum, const, numeric and
+// length bounds, pattern, and allOf/anyOf/oneOf. Other keywords (format, $ref, ...) are ignored,
+// so a schema using them validates more loosely rather than rejecting valid arguments.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxErrors bounds how many problems are reported for one value.
+const maxErrors = 20
+
+// Error is one validation problem. Path locates the offending value ("" is the whole value,
+// "items[2].name" a nested field).
+type Error struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Schema is a parsed schema. The zero value (and a nil *Schema) accepts everything.
+type Schema struct {
+	root interface{}
+}
+
+// Parse parses a JSON Schema document. An empty document yields a schema that accepts everything.
+func Parse(data []byte) (*Schema, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &Schema{}, nil
+	}
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	switch root.(type) {
+	case map[string]interface{}, bool:
+	default:
+		return nil, fmt.Errorf("invalid JSON Schema: must be an object or boolean")
+	}
+	return &Schema{root: root}, nil
+}
+
+// FromValue converts a schema built in Go (e.g. nested maps in a tool definition) by round-tripping
+// it through JSON.
+func FromValue(v interface{}) (*Schema, error) {
+	if v == nil {
+		return &Schema{}, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// ValidateJSON validates a JSON document. Empty input is treated as an empty object, the way tool
+// arguments are.
+func (s *Schema) ValidateJSON(data []byte) []Error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("{}")
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return []Error{{Message: "not valid JSON: " + err.Error()}}
+	}
+	if dec.More() {
+		return []Error{{Message: "not valid JSON: unexpected data after the value"}}
+	}
+	return s.Validate(v)
+}
+
+// Validate validates a value decoded by encoding/json (numbers as float64 or json.Number).
+func (s *Schema) Validate(v interface{}) []Error {
+	if s == nil || s.root == nil {
+		return nil
+	}
+	var errs []Error
+	validate(s.root, v, "", &errs)
+	if len(errs) > maxErrors {
+		errs = errs[:maxErrors]
+	}
+	return errs
+}
+
+func validate(schema, v interface{}, path string, errs *[]Error) {
+	if len(*errs) > maxErrors {
+		return
+	}
+	add := func(format string, args ...interface{}) {
+		*errs = append(*errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		if b, isBool := schema.(bool); isBool && !b {
+			add("no value is allowed here")
+		}
+		return
+	}
+
+	if t, ok := s["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []interface{}:
+			for _, x := range t {
+				if name, ok := x.(string); ok {
+					types = append(types, name)
+				}
+			}
+		}
+		if len(types) > 0 && !matchesAnyType(v, types) {
+			add("expected %s, got %s", strings.Join(types, " or "), typeName(v))
+			// Further keywords would only repeat the type problem
+			return
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok && !inEnum(v, enum) {
+		add("must be one of %s", formatEnum(enum))
+	}
+	if c, ok := s["const"]; ok && !equal(v, c) {
+		add("must be %s", formatValue(c))
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		validateObject(s, v, path, errs)
+	case []interface{}:
+		if n, ok := number(s["minItems"]); ok && float64(len(v)) < n {
+			add("must have at least %s items", formatNumber(n))
+		}
+		if n, ok := number(s["maxItems"]); ok && float64(len(v)) > n {
+			add("must have at most %s items", formatNumber(n))
+		}
+		if items, ok := s["items"]; ok {
+			for i, item := range v {
+				validate(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if n, ok := number(s["minLength"]); ok && length < n {
+			add("must be at least %s characters", formatNumber(n))
+		}
+		if n, ok := number(s["maxLength"]); ok && length > n {
+			add("must be at most %s characters", formatNumber(n))
+		}
+		if p, ok := s["pattern"].(string); ok {
+			if re, err := regexp.Compile(p); err == nil && !re.MatchString(v) {
+				add("must match pattern %s", p)
+			}
+		}
+	default:
+		if x, isNum := number(v); isNum {
+			if n, ok := number(s["minimum"]); ok && x < n {
+				add("must be >= %s", formatNumber(n))
+			}
+			if n, ok := number(s["maximum"]); ok && x > n {
+				add("must be <= %s", formatNumber(n))
+			}
+			if n, ok := number(s["exclusiveMinimum"]); ok && x <= n {
+				add("must be > %s", formatNumber(n))
+			}
+			if n, ok := number(s["exclusiveMaximum"]); ok && x >= n {
+				add("must be < %s", formatNumber(n))
+			}
+		}
+	}
+
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			validate(sub, v, path, errs)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok && countMatches(anyOf, v) == 0 {
+		add("does not match any of the allowed schemas")
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		if n := countMatches(oneOf, v); n != 1 {
+			add("must match exactly one of the allowed schemas (matches %d)", n)
+		}
+	}
+}
+
+func validateObject(s map[string]interface{}, v map[string]interface{}, path string, errs *[]Error) {
+	props, _ := s["properties"].(map[string]interface{})
+	if req, ok := s["required"].([]interface{}); ok {
+		for _, r := range req {
+			name, _ := r.(string)
+			if _, present := v[name]; name != "" && !present {
+				*errs = append(*errs, Error{Path: join(path, name), Message: "is required"})
+			}
+		}
+	}
+	if n, ok := number(s["minProperties"]); ok && float64(len(v)) < n {
+		*errs = append(*errs, Error{Path: path, Message: fmt.Sprintf("must have at least %s properties", formatNumber(n))})
+	}
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	additional, hasAdditional := s["additionalProperties"]
+	for _, k := range keys {
+		if sub, ok := props[k]; ok {
+			validate(sub, v[k], join(path, k), errs)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if b, ok := additional.(bool); ok && !b {
+			msg := "is not an allowed property"
+			if len(props) > 0 {
+				msg += "; allowed: " + strings.Join(sortedKeys(props), ", ")
+			}
+			*errs = append(*errs, Error{Path: join(path, k), Message: msg})
+			continue
+		}
+		validate(additional, v[k], join(path, k), errs)
+	}
+}
+
+func countMatches(schemas []interface{}, v interface{}) int {
+	n := 0
+	for _, sub := range schemas {
+		var errs []Error
+		validate(sub, v, "", &errs)
+		if len(errs) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func matchesAnyType(v interface{}, types []string) bool {
+	for _, t := range types {
+		switch t {
+		case "object":
+			if _, ok := v.(map[string]interface{}); ok {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]interface{}); ok {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "number":
+			if _, ok := number(v); ok {
+				return true
+			}
+		case "integer":
+			if x, ok := number(v); ok && x == math.Trunc(x) {
+				return true
+			}
+		default:
+			// Unknown type names are not enforced
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if x, ok := number(v); ok {
+		if x == math.Trunc(x) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// number returns v as a float64 when it is a JSON number.
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if equal(v, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares decoded JSON values, treating numbers by value.
+func equal(a, b interface{}) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	switch a := a.(type) {
+	case map[string]interface{}:
+		bm, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(bm) {
+			return false
+		}
+		for k, av := range a {
+			if bv, ok := bm[k]; !ok || !equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bs, ok := b.([]interface{})
+		if !ok || len(a) != len(bs) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], bs[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func formatEnum(enum []interface{}) string {
+	parts := make([]string, len(enum))
+	for i, e := range enum {
+		parts[i] = formatValue(e)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,79 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+const toolSchema = `{
+	"type": "object",
+	"properties": {
+		"action": {"type": "string", "enum": ["create", "list"]},
+		"count": {"type": "integer", "minimum": 1, "maximum": 10},
+		"ratio": {"type": "number"},
+		"tags": {"type": "array", "items": {"type": "string", "minLength": 2}, "maxItems": 3},
+		"env": {"type": "object", "additionalProperties": {"type": "string"}},
+		"strict": {"type": "object", "properties": {"id": {"type": "string", "pattern": "^[a-z]+$"}}, "required": ["id"], "additionalProperties": false},
+		"when": {"anyOf": [{"type": "string"}, {"type": "integer"}]},
+		"note": {"type": ["string", "null"]}
+	},
+	"required": ["action"]
+}`
+
+func TestValidateJSON(t *testing.T) {
+	s, err := Parse([]byte(toolSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		args string
+		want []string // substrings of the reported errors, in order; none means valid
+	}{
+		{`{"action": "create"}`, nil},
+		{`{"action": "list", "count": 3, "ratio": 0.5, "tags": ["ab"], "env": {"A": "b"}, "strict": {"id": "abc"}, "when": 5, "note": null}`, nil},
+		{`{"action": "create", "count": 3.0}`, nil},
+		{``, []string{"action: is required"}},
+		{`{"action": "delete"}`, []string{`action: must be one of "create", "list"`}},
+		{`{"action": "create", "count": "3"}`, []string{"count: expected integer, got string"}},
+		{`{"action": "create", "count": 2.5}`, []string{"count: expected integer, got number"}},
+		{`{"action": "create", "count": 11}`, []string{"count: must be <= 10"}},
+		{`{"action": "create", "tags": ["a", "bc", "de", "fg"]}`, []string{"tags: must have at most 3 items", "tags[0]: must be at least 2 characters"}},
+		{`{"action": "create", "env": {"A": 1}}`, []string{"env.A: expected string, got integer"}},
+		{`{"action": "create", "strict": {"id": "ABC", "x": 1}}`, []string{"strict.id: must match pattern", "strict.x: is not an allowed property; allowed: id"}},
+		{`{"action": "create", "strict": {}}`, []string{"strict.id: is required"}},
+		{`{"action": "create", "when": true}`, []string{"when: does not match any of the allowed schemas"}},
+		{`[]`, []string{"expected object, got array"}},
+		{`{"action": `, []string{"not valid JSON"}},
+	}
+	for _, c := range cases {
+		errs := s.ValidateJSON([]byte(c.args))
+		if len(errs) != len(c.want) {
+			t.Errorf("%s: got %v, want %d errors", c.args, errs, len(c.want))
+			continue
+		}
+		for i, w := range c.want {
+			if !strings.Contains(errs[i].Error(), w) {
+				t.Errorf("%s: error %d = %q, want it to contain %q", c.args, i, errs[i].Error(), w)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	if s, err := Parse(nil); err != nil || len(s.ValidateJSON([]byte(`[1]`))) != 0 {
+		t.Errorf("an empty schema should accept anything: %v", err)
+	}
+	if _, err := Parse([]byte(`{"type": `)); err == nil {
+		t.Error("expected an error for malformed JSON")
+	}
+	if _, err := Parse([]byte(`"object"`)); err == nil {
+		t.Error("expected an error for a schema that is not an object")
+	}
+	s, err := FromValue(map[string]interface{}{"type": "object", "properties": map[string]interface{}{"n": map[string]string{"type": "integer"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := s.ValidateJSON([]byte(`{"n": "x"}`)); len(errs) != 1 {
+		t.Errorf("FromValue schema errors = %v", errs)
+	}
+}
@@ -178,6 +178,9 @@ func (c *GenericProviderClient) ChatCompletion(ctx context.Context, messages []c
 		"prompt":   prompt,
 		"base_url": c.Instance.BaseURL,
 		"api_key":  apiKey,
+		// Templates map these to the provider's own fields (e.g. Ollama's "think")
+		"reasoning":            c.Route.Reasoning,
+		"max_reasoning_tokens": c.Route.MaxReasoningTokens,
 	}
 
 	// 2. Render URL
@@ -7,35 +7,57 @@ import (
 	"os"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/logging"
+	"github.com/hattiebot/hattiebot/internal/ollama"
 	"github.com/hattiebot/hattiebot/internal/openrouter"
 	"github.com/hattiebot/hattiebot/internal/store"
 )
 
-// RouterClient implements core.LLMClient by resolving the "default" route to a provider+model,
-// calling that client, and falling back to openrouter_bootstrap on error.
+// RouterClient implements core.LLMClient by resolving a route to a chain of provider+model
+// pairs (the route's model, then its fallbacks), calling the first healthy one, and falling back
+// to openrouter_bootstrap when the whole chain fails.
+//
+// Each model has a circuit breaker: after FailureThreshold consecutive failures it is skipped for
+// CooldownSec, then tried again. Switching models and opening or closing a breaker are logged as
+// warnings of component "llm", which the process logger stores in system_logs.
 type RouterClient struct {
 	Config    *store.LLMRoutingConfig
-	configDir string // when set, getClient() reloads config from disk and invalidates cache when config changes
+	configDir string // when set, each call reloads config from disk and invalidates cache when config changes
 	Fallback  core.LLMClient
 	Registry  *ProviderRegistry
+	LogStore  *store.LogStore // optional; failover events are also written here directly
 	getEnv    func(string) string
+	now       func() time.Time
 	mu        sync.RWMutex
 	cache     map[string]core.LLMClient
+	breakers  map[string]*breaker
+	active    string // provider:model (or "fallback") that served the last successful call
 }
 
+type breaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+const (
+	defaultFailureThreshold = 3
+	defaultCooldown         = 5 * time.Minute
+	fallbackKey             = "fallback"
+)
+
 // NewRouterClient creates a RouterClient with the given routing config and fallback client.
 // getEnv is used to resolve api_key_env; if nil, os.Getenv is used.
 func NewRouterClient(cfg *store.LLMRoutingConfig, fallback core.LLMClient, configDir string, getEnv func(string) string) *RouterClient {
 	if getEnv == nil {
 		getEnv = os.Getenv
 	}
-    registry := NewProviderRegistry(configDir)
-    if err := registry.LoadTemplates(); err != nil {
-        // Log error but continue?
-        fmt.Printf("warning: failed to load provider templates: %v\n", err)
-    }
+	registry := NewProviderRegistry(configDir)
+	if err := registry.LoadTemplates(); err != nil {
+		fmt.Printf("warning: failed to load provider templates: %v\n", err)
+	}
 
 	return &RouterClient{
 		Config:    cfg,
@@ -43,39 +65,46 @@ func NewRouterClient(cfg *store.LLMRoutingConfig, fallback core.LLMClient, confi
 		Fallback:  fallback,
 		Registry:  registry,
 		getEnv:    getEnv,
+		now:       time.Now,
 		cache:     make(map[string]core.LLMClient),
+		breakers:  make(map[string]*breaker),
 	}
 }
 
-// getClient resolves route "default" to (provider, model) and returns a core.LLMClient for it.
-// When configDir is set, re-reads llm_routing.json and provider templates, and invalidates cache if config changed (hot-reload).
-func (r *RouterClient) getClient(route string) (core.LLMClient, error) {
-	// Hot-reload: re-read config from disk and clear cache if changed
-	if r.configDir != "" {
-		r.mu.Lock()
-		newCfg, err := store.LoadLLMRouting(r.configDir)
-		if err == nil && newCfg != nil && !reflect.DeepEqual(newCfg, r.Config) {
-			r.Config = newCfg
-			r.cache = make(map[string]core.LLMClient)
-			_ = r.Registry.LoadTemplates()
-		}
-		r.mu.Unlock()
+// reloadConfig re-reads llm_routing.json and provider templates when configDir is set, and
+// invalidates the client cache if the config changed (hot-reload).
+func (r *RouterClient) reloadConfig() {
+	if r.configDir == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	newCfg, err := store.LoadLLMRouting(r.configDir)
+	if err == nil && newCfg != nil && !reflect.DeepEqual(newCfg, r.Config) {
+		r.Config = newCfg
+		r.cache = make(map[string]core.LLMClient)
+		_ = r.Registry.LoadTemplates()
 	}
+}
 
+// chain returns the route's models in the order they are tried.
+func (r *RouterClient) chain(route string) []store.ModelRouteEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	if r.Config == nil {
-		return nil, nil
-	}
-	routeEntry, ok := r.Config.ModelRouting[route]
-	if !ok || routeEntry.Provider == "" || routeEntry.Model == "" {
-		return nil, nil
-	}
-	providerEntry, ok := r.Config.LLMProviders[routeEntry.Provider]
-	if !ok {
-		return nil, nil
+		return nil
 	}
+	return r.Config.ModelRouting[route].Chain()
+}
 
-	// Cache Check
-	cacheKey := routeEntry.Provider + ":" + routeEntry.Model
+// getClient returns the client for one provider+model, or nil when the provider is not usable
+// (unknown provider, missing API key).
+func (r *RouterClient) getClient(entry store.ModelRouteEntry) (core.LLMClient, error) {
+	// Routes may use one model with different reasoning settings, so those are part of the key
+	cacheKey := entry.Provider + ":" + entry.Model
+	if entry.Reasoning != "" || entry.MaxReasoningTokens > 0 {
+		cacheKey += fmt.Sprintf(":%s:%d", entry.Reasoning, entry.MaxReasoningTokens)
+	}
 	r.mu.RLock()
 	c, ok := r.cache[cacheKey]
 	r.mu.RUnlock()
@@ -87,87 +116,204 @@ func (r *RouterClient) getClient(route string) (core.LLMClient, error) {
 	if c, ok = r.cache[cacheKey]; ok {
 		return c, nil
 	}
+	if r.Config == nil {
+		return nil, nil
+	}
+	providerEntry, ok := r.Config.LLMProviders[entry.Provider]
+	if !ok {
+		return nil, nil
+	}
 
-    // Build Client
-    var client core.LLMClient
-    
+	var client core.LLMClient
 	if providerEntry.Type == "openrouter" {
-        apiKey := r.getEnv(providerEntry.APIKeyEnv)
-        if apiKey == "" {
-            return nil, nil
-        }
-        client = openrouter.NewClient(apiKey, routeEntry.Model, r.configDir)
-    } else {
-        // Generic Provider lookup
-        tmpl, ok := r.Registry.GetTemplate(providerEntry.Type)
-        if !ok {
-            return nil, fmt.Errorf("unknown provider type '%s' (no template found)", providerEntry.Type)
-        }
-        client = &GenericProviderClient{
-            Template: tmpl,
-            Instance: providerEntry,
-            Route:    routeEntry,
-            GetEnv:   r.getEnv,
-        }
-    }
-	
+		apiKey := r.getEnv(providerEntry.APIKeyEnv)
+		if apiKey == "" {
+			return nil, nil
+		}
+		orClient := openrouter.NewClient(apiKey, entry.Model, r.configDir)
+		orClient.Reasoning = openrouter.ReasoningFor(entry.Reasoning, entry.MaxReasoningTokens)
+		client = orClient
+	} else if providerEntry.Type == "ollama" {
+		olClient := ollama.NewClient(providerEntry.BaseURL, entry.Model)
+		olClient.Think = ollama.ThinkFor(entry.Reasoning)
+		client = olClient
+	} else {
+		// Generic Provider lookup
+		tmpl, ok := r.Registry.GetTemplate(providerEntry.Type)
+		if !ok {
+			return nil, fmt.Errorf("unknown provider type '%s' (no template found)", providerEntry.Type)
+		}
+		client = &GenericProviderClient{
+			Template: tmpl,
+			Instance: providerEntry,
+			Route:    entry,
+			GetEnv:   r.getEnv,
+		}
+	}
 	r.cache[cacheKey] = client
 	return client, nil
 }
 
-// ChatCompletion calls the primary client for "default" route; on error uses Fallback.
-func (r *RouterClient) ChatCompletion(ctx context.Context, messages []core.Message) (string, error) {
-	c, err := r.getClient("default")
-	if c != nil && err == nil {
-		out, err := c.ChatCompletion(ctx, messages)
+// call runs fn against the route's chain until one model succeeds, then against Fallback.
+func (r *RouterClient) call(ctx context.Context, route string, fn func(core.LLMClient) error) error {
+	r.reloadConfig()
+	var lastErr error
+	for _, entry := range r.chain(route) {
+		key := entry.Provider + ":" + entry.Model
+		if r.isOpen(key) {
+			continue
+		}
+		c, err := r.getClient(entry)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if c == nil {
+			continue
+		}
+		err = fn(c)
 		if err == nil {
-			return out, nil
+			r.succeeded(key)
+			return nil
 		}
-		log.Printf("[LLMROUTER] primary client failed: %v; falling back", err)
+		if ctx.Err() != nil {
+			return err
+		}
+		log.Printf("[LLMROUTER] %s failed: %v", key, err)
+		lastErr = err
+		r.failed(key, err)
 	}
 	if r.Fallback != nil {
-		return r.Fallback.ChatCompletion(ctx, messages)
-	}
-	if err != nil {
-		return "", err
+		if lastErr != nil {
+			log.Printf("[LLMROUTER] no model in route %q succeeded (last error: %v); falling back", route, lastErr)
+		}
+		if err := fn(r.Fallback); err != nil {
+			return err
+		}
+		r.succeeded(fallbackKey)
+		return nil
 	}
-	return "", nil
+	return lastErr
 }
 
-// ChatCompletionWithTools calls the primary client for "default" route; on error uses Fallback.
-func (r *RouterClient) ChatCompletionWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition) (string, []core.ToolCall, error) {
-	c, err := r.getClient("default")
-	if c != nil && err == nil {
-		out, calls, err := c.ChatCompletionWithTools(ctx, messages, tools)
-		if err == nil {
-			return out, calls, nil
+func (r *RouterClient) breakerSettings() (int, time.Duration) {
+	threshold, cooldown := defaultFailureThreshold, defaultCooldown
+	if r.Config != nil && r.Config.CircuitBreaker != nil {
+		if n := r.Config.CircuitBreaker.FailureThreshold; n > 0 {
+			threshold = n
+		}
+		if n := r.Config.CircuitBreaker.CooldownSec; n > 0 {
+			cooldown = time.Duration(n) * time.Second
 		}
-		log.Printf("[LLMROUTER] primary client failed: %v; falling back", err)
 	}
-	if r.Fallback != nil {
-		return r.Fallback.ChatCompletionWithTools(ctx, messages, tools)
+	return threshold, cooldown
+}
+
+func (r *RouterClient) isOpen(key string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b := r.breakers[key]
+	return b != nil && r.now().Before(b.openUntil)
+}
+
+// failed counts a failure and opens the breaker once the threshold is reached; a model that
+// fails again after its cooldown is taken out of rotation for another cooldown.
+func (r *RouterClient) failed(key string, err error) {
+	r.mu.Lock()
+	threshold, cooldown := r.breakerSettings()
+	b := r.breakers[key]
+	if b == nil {
+		b = &breaker{}
+		r.breakers[key] = b
 	}
-	if err != nil {
-		return "", nil, err
+	b.failures++
+	opened := b.failures >= threshold
+	if opened {
+		b.openUntil = r.now().Add(cooldown)
+	}
+	failures := b.failures
+	r.mu.Unlock()
+	if opened {
+		r.event(fmt.Sprintf("circuit open for %s after %d consecutive failures (last: %v); skipping it for %s", key, failures, err, cooldown))
 	}
-	return "", nil, nil
 }
 
-// Embed calls the primary client for "default" route; on error uses Fallback.
-func (r *RouterClient) Embed(ctx context.Context, text string) ([]float32, error) {
-	c, err := r.getClient("default")
-	if c != nil && err == nil {
-		out, err := c.Embed(ctx, text)
-		if err == nil {
-			return out, nil
-		}
-		log.Printf("[LLMROUTER] primary client failed: %v; falling back", err)
+// succeeded resets the model's breaker and records a switch when a different model than last
+// time served the call.
+func (r *RouterClient) succeeded(key string) {
+	r.mu.Lock()
+	threshold, _ := r.breakerSettings()
+	recovered := false
+	if b := r.breakers[key]; b != nil {
+		recovered = b.failures >= threshold
+		delete(r.breakers, key)
 	}
-	if r.Fallback != nil {
-		return r.Fallback.Embed(ctx, text)
+	prev := r.active
+	r.active = key
+	r.mu.Unlock()
+	if recovered {
+		r.event(fmt.Sprintf("circuit closed for %s: it answered again", key))
 	}
-	if err != nil {
-		return nil, err
+	if prev != "" && prev != key {
+		r.event(fmt.Sprintf("switched model from %s to %s", prev, key))
 	}
-	return nil, nil
+}
+
+func (r *RouterClient) event(msg string) {
+	logging.For("llm").Warn(msg)
+	if r.LogStore != nil {
+		_ = r.LogStore.LogWarn("llm", msg)
+	}
+}
+
+// Active returns the provider:model (or "fallback") that served the last successful call.
+func (r *RouterClient) Active() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active
+}
+
+// ChatCompletion calls the "default" route's chain; when every model fails uses Fallback.
+func (r *RouterClient) ChatCompletion(ctx context.Context, messages []core.Message) (string, error) {
+	var out string
+	err := r.call(ctx, "default", func(c core.LLMClient) error {
+		var err error
+		out, err = c.ChatCompletion(ctx, messages)
+		return err
+	})
+	return out, err
+}
+
+// ChatCompletionWithTools calls the "default" route's chain; when every model fails uses Fallback.
+func (r *RouterClient) ChatCompletionWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition) (string, []core.ToolCall, error) {
+	var out string
+	var calls []core.ToolCall
+	err := r.call(ctx, "default", func(c core.LLMClient) error {
+		var err error
+		out, calls, err = c.ChatCompletionWithTools(ctx, messages, tools)
+		return err
+	})
+	return out, calls, err
+}
+
+// Embed calls the "default" route's chain; when every model fails uses Fallback.
+func (r *RouterClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	var out []float32
+	err := r.call(ctx, "default", func(c core.LLMClient) error {
+		var err error
+		out, err = c.Embed(ctx, text)
+		return err
+	})
+	return out, err
+}
+
+// EmbedBatch is Embed for several texts, batched when the chain's client supports it.
+func (r *RouterClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	var out [][]float32
+	err := r.call(ctx, "default", func(c core.LLMClient) error {
+		var err error
+		out, err = core.LLMEmbedBatch(ctx, c, texts)
+		return err
+	})
+	return out, err
 }
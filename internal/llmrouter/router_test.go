@@ -2,9 +2,17 @@ package llmrouter
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
 	"github.com/hattiebot/hattiebot/internal/store"
 )
 
@@ -66,10 +74,10 @@ func TestRouterClient_FallbackOnPrimaryError(t *testing.T) {
 	fallback := &mockLLMClient{chatResp: "fallback"}
 	cfg := &store.LLMRoutingConfig{
 		LLMProviders: map[string]store.LLMProviderEntry{
-			"ollama": {Type: "ollama", BaseURL: "http://localhost:11434"},
+			"local": {Type: "no_such_type", BaseURL: "http://localhost:11434"},
 		},
 		ModelRouting: map[string]store.ModelRouteEntry{
-			"default": {Provider: "ollama", Model: "llama3"},
+			"default": {Provider: "local", Model: "llama3"},
 		},
 	}
 	r := NewRouterClient(cfg, fallback, "", nil)
@@ -84,6 +92,37 @@ func TestRouterClient_FallbackOnPrimaryError(t *testing.T) {
 	}
 }
 
+func TestRouterClient_OllamaProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+			Think *bool  `json:"think"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/api/chat" || req.Model != "llama3" || req.Think == nil || !*req.Think {
+			t.Errorf("request %s %+v", r.URL.Path, req)
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","content":"from ollama"}}`))
+	}))
+	defer srv.Close()
+	cfg := &store.LLMRoutingConfig{
+		LLMProviders: map[string]store.LLMProviderEntry{
+			"ollama": {Type: "ollama", BaseURL: srv.URL},
+		},
+		ModelRouting: map[string]store.ModelRouteEntry{
+			"default": {Provider: "ollama", Model: "llama3", Reasoning: "high"},
+		},
+	}
+	r := NewRouterClient(cfg, &mockLLMClient{chatResp: "fallback"}, "", nil)
+	out, err := r.ChatCompletion(context.Background(), []core.Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "from ollama" {
+		t.Errorf("got %q, want the ollama reply", out)
+	}
+}
+
 func TestRouterClient_NoConfigUsesFallback(t *testing.T) {
 	fallback := &mockLLMClient{chatResp: "fallback"}
 	r := NewRouterClient(nil, fallback, "", nil)
@@ -97,3 +136,104 @@ func TestRouterClient_NoConfigUsesFallback(t *testing.T) {
 		t.Errorf("expected fallback response, got %q", out)
 	}
 }
+
+func TestRouterClient_FailoverChainWithCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	logs := store.NewLogStore(db.DB)
+
+	primary := &mockLLMClient{chatResp: "primary", chatErr: errors.New("503 overloaded")}
+	secondary := &mockLLMClient{chatResp: "secondary"}
+	fallback := &mockLLMClient{chatResp: "fallback"}
+	cfg := &store.LLMRoutingConfig{
+		ModelRouting: map[string]store.ModelRouteEntry{
+			"default": {Provider: "p", Model: "a", Fallbacks: []store.ModelRouteEntry{{Provider: "p", Model: "b"}}},
+		},
+		CircuitBreaker: &store.CircuitBreakerConfig{FailureThreshold: 2, CooldownSec: 60},
+	}
+	r := NewRouterClient(cfg, fallback, "", nil)
+	r.LogStore = logs
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	r.cache["p:a"] = primary
+	r.cache["p:b"] = secondary
+
+	for i := 0; i < 3; i++ {
+		if out, err := r.ChatCompletion(ctx, nil); err != nil || out != "secondary" {
+			t.Fatalf("call %d: %q, %v", i, out, err)
+		}
+	}
+	// The breaker opened after two failures, so the third call skipped the primary
+	if primary.chatCalls != 2 || r.Active() != "p:b" || fallback.chatCalls != 0 {
+		t.Errorf("primary calls = %d, active = %q, fallback calls = %d", primary.chatCalls, r.Active(), fallback.chatCalls)
+	}
+
+	// After the cooldown the primary is tried again and takes over once it answers
+	now = now.Add(61 * time.Second)
+	primary.chatErr = nil
+	if out, _ := r.ChatCompletion(ctx, nil); out != "primary" || r.Active() != "p:a" {
+		t.Errorf("after cooldown: %q, active %q", out, r.Active())
+	}
+
+	// With the whole chain failing, the bootstrap fallback answers
+	primary.chatErr, secondary.chatErr = errors.New("down"), errors.New("down")
+	if out, _ := r.ChatCompletion(ctx, nil); out != "fallback" || r.Active() != "fallback" {
+		t.Errorf("chain down: %q, active %q", out, r.Active())
+	}
+
+	entries, err := logs.GetLogs("warn", "llm", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, e := range entries {
+		msgs = append(msgs, e.Message)
+	}
+	all := strings.Join(msgs, "\n")
+	for _, want := range []string{"circuit open for p:a after 2 consecutive failures", "circuit closed for p:a", "switched model from p:b to p:a", "switched model from p:a to fallback"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("log store is missing %q:\n%s", want, all)
+		}
+	}
+}
+
+func TestRouterClient_ReasoningPerRoute(t *testing.T) {
+	cfg := &store.LLMRoutingConfig{
+		LLMProviders: map[string]store.LLMProviderEntry{"or": {Type: "openrouter", APIKeyEnv: "KEY"}},
+		ModelRouting: map[string]store.ModelRouteEntry{
+			"default": {Provider: "or", Model: "m", Reasoning: "off", Fallbacks: []store.ModelRouteEntry{{Provider: "or", Model: "m", MaxReasoningTokens: 2048}}},
+			"planning": {Provider: "or", Model: "m", Reasoning: "high"},
+		},
+	}
+	r := NewRouterClient(cfg, nil, "", func(string) string { return "k" })
+	reasoning := func(e store.ModelRouteEntry) *openrouter.Reasoning {
+		c, err := r.getClient(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.(*openrouter.Client).Reasoning
+	}
+	chain := r.chain("default")
+	if len(chain) != 2 {
+		t.Fatalf("chain = %+v", chain)
+	}
+	if rs := reasoning(chain[0]); rs == nil || rs.Enabled == nil || *rs.Enabled {
+		t.Errorf("off = %+v", rs)
+	}
+	if rs := reasoning(chain[1]); rs == nil || rs.MaxTokens != 2048 || rs.Effort != "" {
+		t.Errorf("max tokens = %+v", rs)
+	}
+	if rs := reasoning(r.chain("planning")[0]); rs == nil || rs.Effort != "high" {
+		t.Errorf("high = %+v", rs)
+	}
+	if rs := reasoning(store.ModelRouteEntry{Provider: "or", Model: "m"}); rs != nil {
+		t.Errorf("default = %+v", rs)
+	}
+	if err := (store.ModelRouteEntry{Model: "m", Reasoning: "max"}).ValidateReasoning(); err == nil {
+		t.Error("unknown reasoning level accepted")
+	}
+}
@@ -0,0 +1,212 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hattiebot/hattiebot/internal/tracing"
+)
+
+// defaultComponent tags records that name no component.
+const defaultComponent = "hattiebot"
+
+// handler writes records to the console handler and copies them to the sink. It keeps the
+// component and the rendered attributes of With calls, which the stored message needs.
+type handler struct {
+	out       slog.Handler
+	component string
+	attrs     string // " key=value" pairs added by With, for stored messages
+	group     string // key prefix added by WithGroup
+}
+
+func (h *handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.out.Enabled(ctx, l)
+}
+
+// Handle logs r with the trace ID of the span in ctx, if any, and stores it at info and above.
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String("trace_id", traceID))
+	}
+	err := h.out.Handle(ctx, r)
+	if s := sink.Load(); s != nil && r.Level >= slog.LevelInfo {
+		component, msg := h.component, r.Message+h.attrs
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == ComponentKey && h.group == "" {
+				component = a.Value.String()
+			} else {
+				msg += render(h.group, a)
+			}
+			return true
+		})
+		if component == "" {
+			component = defaultComponent
+		}
+		s.w.write(LevelName(r.Level), component, msg)
+	}
+	return err
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.out = h.out.WithAttrs(attrs)
+	for _, a := range attrs {
+		if a.Key == ComponentKey && h.group == "" {
+			next.component = a.Value.String()
+		} else {
+			next.attrs += render(h.group, a)
+		}
+	}
+	return &next
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := *h
+	next.out = h.out.WithGroup(name)
+	next.group += name + "."
+	return &next
+}
+
+// render formats an attribute as " key=value" for a stored message.
+func render(group string, a slog.Attr) string {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		var b strings.Builder
+		for _, ga := range v.Group() {
+			b.WriteString(render(group+a.Key+".", ga))
+		}
+		return b.String()
+	}
+	s := v.String()
+	if s == "" || strings.ContainsAny(s, " =\"\n") {
+		s = strconv.Quote(s)
+	}
+	return " " + group + a.Key + "=" + s
+}
+
+// tagPattern matches the "[TAG] " prefix of log.Printf lines.
+var tagPattern = regexp.MustCompile(`^\[([A-Za-z][A-Za-z0-9_ -]*)\]:?\s*`)
+
+// componentAliases folds tags of one area into one component.
+var componentAliases = map[string]string{
+	"openrouter":    "llm",
+	"llmrouter":     "llm",
+	"embedrouter":   "embedding",
+	"webhookserver": "webhook",
+}
+
+// bridge turns lines from the standard log package into tagged, leveled records.
+type bridge struct {
+	logger *slog.Logger
+}
+
+func (b *bridge) Write(p []byte) (int, error) {
+	component, msg := splitTag(strings.TrimRight(string(p), "\n"))
+	b.logger.Log(context.Background(), inferLevel(msg), msg, ComponentKey, component)
+	return len(p), nil
+}
+
+// splitTag splits "[AGENT] message" into the component ("agent") and the message.
+func splitTag(line string) (component, msg string) {
+	m := tagPattern.FindStringSubmatch(line)
+	if m == nil {
+		return defaultComponent, line
+	}
+	component = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(m[1])), " ", "_")
+	if alias, ok := componentAliases[component]; ok {
+		component = alias
+	}
+	return component, line[len(m[0]):]
+}
+
+var (
+	errorWords = []string{"panic", "fatal", "error", "failed", "failure"}
+	warnWords  = []string{"warn", "retry", "retrying", "skipping", "dropped", "deferred", "could not", "cannot", "unable", "invalid", "not configured", "timed out", "timeout"}
+)
+
+// inferLevel guesses the level of an untyped log line from its wording.
+func inferLevel(msg string) slog.Level {
+	lower := strings.ToLower(msg)
+	for _, w := range errorWords {
+		if strings.Contains(lower, w) {
+			return slog.LevelError
+		}
+	}
+	for _, w := range warnWords {
+		if strings.Contains(lower, w) {
+			return slog.LevelWarn
+		}
+	}
+	return slog.LevelInfo
+}
+
+// storeQueue entries may wait to be stored; more are dropped rather than blocking the caller.
+const storeQueue = 1024
+
+type storeEntry struct {
+	level, component, message string
+	done                      chan struct{} // set for flush markers
+}
+
+// storeWriter writes entries to a Sink from one goroutine, so logging never waits on the database.
+// Store errors are dropped: the console has the entry already.
+type storeWriter struct {
+	s    Sink
+	ch   chan storeEntry
+	stop chan struct{}
+	once sync.Once
+}
+
+func newStoreWriter(s Sink) *storeWriter {
+	w := &storeWriter{s: s, ch: make(chan storeEntry, storeQueue), stop: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+func (w *storeWriter) run() {
+	for {
+		select {
+		case e := <-w.ch:
+			if e.done != nil {
+				close(e.done)
+				continue
+			}
+			_ = w.s.Log(e.level, e.component, e.message)
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *storeWriter) write(level, component, message string) {
+	select {
+	case w.ch <- storeEntry{level: level, component: component, message: message}:
+	default:
+	}
+}
+
+func (w *storeWriter) flush() {
+	done := make(chan struct{})
+	select {
+	case w.ch <- storeEntry{done: done}:
+	case <-w.stop:
+		return
+	}
+	select {
+	case <-done:
+	case <-w.stop:
+	}
+}
+
+func (w *storeWriter) close() {
+	w.flush()
+	w.once.Do(func() { close(w.stop) })
+}
@@ -0,0 +1,127 @@
+// Package logging is HattieBot's process logger: log/slog records with a "component" field and a
+// level, written as text or JSON, and copied to the system_logs table (store.LogStore) so
+// read_logs and system_status see the same entries as the console.
+//
+// Code logs through For("component"). Older call sites that use log.Printf("[TAG] ...") are
+// bridged: the tag becomes the component and the level is inferred from the message.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// ComponentKey is the attribute that names the part of HattieBot a record comes from.
+const ComponentKey = "component"
+
+// Options configure Setup.
+type Options struct {
+	// Level is the minimum level logged: debug, info (default), warn or error.
+	Level string
+	// Format is text (default) or json.
+	Format string
+	// Output receives the console log (default os.Stderr).
+	Output io.Writer
+}
+
+// Sink stores log entries (implemented by *store.LogStore).
+type Sink interface {
+	Log(level, component, message string) error
+}
+
+// level is shared by every logger Setup creates, so SetLevel applies at once.
+var level = new(slog.LevelVar)
+
+// Setup installs the process logger as slog's default and routes the standard log package through
+// it, and returns it. Entries are stored once SetSink is called.
+func Setup(opts Options) *slog.Logger {
+	if opts.Output == nil {
+		opts.Output = os.Stderr
+	}
+	level.Set(ParseLevel(opts.Level))
+	hopts := &slog.HandlerOptions{Level: level}
+	var out slog.Handler
+	if strings.EqualFold(opts.Format, "json") {
+		out = slog.NewJSONHandler(opts.Output, hopts)
+	} else {
+		out = slog.NewTextHandler(opts.Output, hopts)
+	}
+	logger := slog.New(&handler{out: out})
+	slog.SetDefault(logger)
+	// SetDefault sends log.Printf through the handler untouched; the bridge tags and levels it first
+	log.SetFlags(0)
+	log.SetOutput(&bridge{logger: logger})
+	return logger
+}
+
+// SetLevel changes the minimum level of the logger Setup installed.
+func SetLevel(s string) {
+	level.Set(ParseLevel(s))
+}
+
+// ParseLevel maps debug, info, warn(ing) and error to a level; anything else is info.
+func ParseLevel(s string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// For returns the default logger tagged with component.
+func For(component string) *slog.Logger {
+	return slog.Default().With(ComponentKey, component)
+}
+
+// LevelName is the name a level is stored under in system_logs: debug, info, warn or error.
+func LevelName(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "error"
+	case l >= slog.LevelWarn:
+		return "warn"
+	case l >= slog.LevelInfo:
+		return "info"
+	}
+	return "debug"
+}
+
+// Printf logs a formatted message at level for component; for call sites that build messages
+// with fmt rather than attributes.
+func Printf(l slog.Level, component, format string, args ...interface{}) {
+	For(component).Log(context.Background(), l, fmt.Sprintf(format, args...))
+}
+
+// sinkHolder lets SetSink swap the store while records are being written.
+type sinkHolder struct{ w *storeWriter }
+
+var sink atomic.Pointer[sinkHolder]
+
+// SetSink copies records at info level and above into s, in the background; nil stops copying.
+// The previous sink is flushed.
+func SetSink(s Sink) {
+	var next *sinkHolder
+	if s != nil {
+		next = &sinkHolder{w: newStoreWriter(s)}
+	}
+	if prev := sink.Swap(next); prev != nil {
+		prev.w.close()
+	}
+}
+
+// Flush waits until the stored entries queued so far are written.
+func Flush() {
+	if h := sink.Load(); h != nil {
+		h.w.flush()
+	}
+}
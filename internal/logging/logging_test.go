@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type memSink struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (m *memSink) Log(level, component, message string) error {
+	m.mu.Lock()
+	m.entries = append(m.entries, level+"|"+component+"|"+message)
+	m.mu.Unlock()
+	return nil
+}
+
+// setup installs a logger writing to a buffer and restores the previous one after the test.
+func setup(t *testing.T, opts Options) (*bytes.Buffer, *memSink) {
+	prevSlog, prevOut, prevFlags := slog.Default(), log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		SetSink(nil)
+		slog.SetDefault(prevSlog)
+		log.SetOutput(prevOut)
+		log.SetFlags(prevFlags)
+	})
+	var buf bytes.Buffer
+	opts.Output = &buf
+	Setup(opts)
+	sink := &memSink{}
+	SetSink(sink)
+	return &buf, sink
+}
+
+func TestBridgeTagsLegacyLines(t *testing.T) {
+	buf, sink := setup(t, Options{Format: "json"})
+	log.Printf("[SCHEDULER] Executing tool: %s", "backup")
+	log.Printf("[OPENROUTER] Retry 1/3 after 1s...")
+	log.Printf("[AGENT] API error (not tool-related): boom")
+	log.Printf("untagged line")
+	Flush()
+
+	var lines []map[string]interface{}
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(l), &rec); err != nil {
+			t.Fatalf("console line %q is not JSON: %v", l, err)
+		}
+		lines = append(lines, rec)
+	}
+	if len(lines) != 4 || lines[0]["component"] != "scheduler" || lines[0]["msg"] != "Executing tool: backup" || lines[0]["level"] != "INFO" {
+		t.Fatalf("console = %v", lines)
+	}
+	want := []string{
+		"info|scheduler|Executing tool: backup",
+		"warn|llm|Retry 1/3 after 1s...",
+		"error|agent|API error (not tool-related): boom",
+		"info|hattiebot|untagged line",
+	}
+	if strings.Join(sink.entries, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stored:\n%s\nwant:\n%s", strings.Join(sink.entries, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestComponentLoggerAndLevel(t *testing.T) {
+	buf, sink := setup(t, Options{Level: "warn"})
+	logger := For("gateway").With("channel", "nextcloud_talk")
+	logger.Info("routing reply")
+	logger.Warn("typing indicator failed", "error", "HTTP 503")
+	logger.WithGroup("req").Error("sending reply failed", "status", 500)
+	Flush()
+
+	if strings.Contains(buf.String(), "routing reply") {
+		t.Errorf("info logged below the warn level: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "component=gateway channel=nextcloud_talk") {
+		t.Errorf("console = %s", buf.String())
+	}
+	want := []string{
+		`warn|gateway|typing indicator failed channel=nextcloud_talk error="HTTP 503"`,
+		`error|gateway|sending reply failed channel=nextcloud_talk req.status=500`,
+	}
+	if strings.Join(sink.entries, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stored:\n%s\nwant:\n%s", strings.Join(sink.entries, "\n"), strings.Join(want, "\n"))
+	}
+
+	SetLevel("debug")
+	For("gateway").Debug("now visible")
+	Flush()
+	if !strings.Contains(buf.String(), "now visible") || len(sink.entries) != 2 {
+		t.Errorf("debug: console %q, stored %v (debug is never stored)", buf.String(), sink.entries)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"": slog.LevelInfo, "DEBUG": slog.LevelDebug, "warning": slog.LevelWarn, "error": slog.LevelError, "loud": slog.LevelInfo} {
+		if got := ParseLevel(in); got != want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
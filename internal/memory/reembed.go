@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/embeddinggood"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// DefaultReembedBatch is how many memories Reembed loads and embeds per batch.
+const DefaultReembedBatch = 50
+
+// reembedMaxFailures is how many memories in a row may fail before Reembed assumes the embedder is
+// down and stops.
+const reembedMaxFailures = 5
+
+// EmbeddingStatus compares the stored memories with the current embedder.
+type EmbeddingStatus struct {
+	Model  string                 `json:"model"` // current embedder's model ("" = unknown)
+	Dim    int                    `json:"dim"`   // dimension of its vectors
+	Stored []store.EmbeddingCount `json:"stored"`
+	// Stale memories have vectors from another dimension or model: recall cannot find them until
+	// they are re-embedded.
+	Stale int `json:"stale"`
+}
+
+// CheckEmbeddings embeds a probe text to learn the embedder's dimension and counts the memories
+// whose vectors no longer match it.
+func CheckEmbeddings(ctx context.Context, db *store.DB, embedder core.EmbeddingClient) (*EmbeddingStatus, error) {
+	probe, err := embedder.Embed(ctx, "dimension check", "document")
+	if err != nil {
+		return nil, fmt.Errorf("probe embedding: %w", err)
+	}
+	st := &EmbeddingStatus{Model: core.EmbeddingModel(embedder), Dim: len(probe)}
+	if embeddinggood.IsLocal(probe) && st.Model != embeddinggood.LocalModel {
+		return nil, fmt.Errorf("the embedding provider is unreachable (only local fallback vectors)")
+	}
+	if st.Stored, err = db.EmbeddingCounts(ctx); err != nil {
+		return nil, err
+	}
+	if st.Stale, err = db.CountStaleChunks(ctx, st.Model, st.Dim, false); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// ReembedOptions tune Reembed.
+type ReembedOptions struct {
+	All       bool // re-embed every memory, not only stale ones (e.g. same dimension, new model)
+	BatchSize int  // memories per batch (default DefaultReembedBatch)
+	Limit     int  // stop after this many memories (0 = all of them)
+	// Progress, when set, is called after every batch.
+	Progress func(ReembedProgress)
+}
+
+// ReembedProgress reports how far Reembed got.
+type ReembedProgress struct {
+	Model  string `json:"model"`
+	Dim    int    `json:"dim"`
+	Total  int    `json:"total"` // memories to re-embed when the run started
+	Done   int    `json:"done"`
+	Failed int    `json:"failed"` // memories whose embedding failed; they stay stale
+}
+
+// Reembed gives memories new vectors from embedder, in batches of ID order, each embedded with
+// one EmbedBatch call. It is safe to stop
+// and run again: re-embedded memories are no longer stale and are skipped. A memory that fails to
+// embed is skipped and counted; several failures in a row stop the run, since the embedder is
+// most likely down.
+func Reembed(ctx context.Context, db *store.DB, embedder core.EmbeddingClient, opts ReembedOptions) (ReembedProgress, error) {
+	st, err := CheckEmbeddings(ctx, db, embedder)
+	if err != nil {
+		return ReembedProgress{}, err
+	}
+	p := ReembedProgress{Model: st.Model, Dim: st.Dim, Total: st.Stale}
+	if opts.All {
+		if p.Total, err = db.CountStaleChunks(ctx, p.Model, p.Dim, true); err != nil {
+			return p, err
+		}
+	}
+	if opts.Limit > 0 && opts.Limit < p.Total {
+		p.Total = opts.Limit
+	}
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = DefaultReembedBatch
+	}
+	var afterID int64
+	streak := 0
+	for p.Done+p.Failed < p.Total {
+		n := batch
+		if left := p.Total - p.Done - p.Failed; left < n {
+			n = left
+		}
+		// With All the re-embedded memories still match, so paging by ID is what moves on
+		chunks, err := db.StaleChunks(ctx, p.Model, p.Dim, opts.All, afterID, n)
+		if err != nil {
+			return p, err
+		}
+		if len(chunks) == 0 {
+			break
+		}
+		texts := make([]string, len(chunks))
+		for i, c := range chunks {
+			texts[i] = c.Content
+		}
+		// One request for the batch; when it fails, each memory is tried alone so one bad text
+		// does not fail the rest
+		vecs, err := embedder.EmbedBatch(ctx, texts, "document")
+		if err != nil || len(vecs) != len(chunks) {
+			log.Printf("[MEMORY] Batch embedding failed, embedding one by one: %v", err)
+			vecs = nil
+		}
+		for i, c := range chunks {
+			afterID = c.ID
+			if err := ctx.Err(); err != nil {
+				return p, err
+			}
+			var emb []float32
+			var err error
+			if vecs != nil {
+				emb = vecs[i]
+			} else {
+				emb, err = embedder.Embed(ctx, c.Content, "document")
+			}
+			if err == nil && len(emb) != p.Dim {
+				err = fmt.Errorf("got %d dimensions, want %d", len(emb), p.Dim)
+			}
+			if err == nil {
+				err = db.UpdateChunkEmbedding(ctx, c.ID, p.Model, emb)
+			}
+			if err != nil {
+				log.Printf("[MEMORY] Re-embedding memory %d: %v", c.ID, err)
+				p.Failed++
+				if streak++; streak >= reembedMaxFailures {
+					return p, fmt.Errorf("%d memories in a row failed to embed: %w", streak, err)
+				}
+				continue
+			}
+			p.Done++
+			streak = 0
+		}
+		log.Printf("[MEMORY] Re-embedded %d of %d memories (%d failed)", p.Done, p.Total, p.Failed)
+		if opts.Progress != nil {
+			opts.Progress(p)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/embeddinggood"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// fakeEmbedder returns dim-long vectors and fails for texts containing "bad".
+type fakeEmbedder struct {
+	model   string
+	dim     int
+	batches int
+}
+
+func (f *fakeEmbedder) Embed(_ context.Context, text, _ string) ([]float32, error) {
+	if strings.Contains(text, "bad") {
+		return nil, errors.New("rejected")
+	}
+	v := make([]float32, f.dim)
+	v[0] = 1
+	return v, nil
+}
+
+func (f *fakeEmbedder) EmbedBatch(ctx context.Context, texts []string, embedType string) ([][]float32, error) {
+	f.batches++
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v, err := f.Embed(ctx, t, embedType)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func (f *fakeEmbedder) EmbeddingModel() string { return f.model }
+
+func TestReembed(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, content := range []string{"a", "b", "bad c", "d", "e"} {
+		if err := db.InsertChunk(ctx, content, "chat", "u1", "old", []float32{1, 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	embedder := &fakeEmbedder{model: "new", dim: 3}
+
+	st, err := CheckEmbeddings(ctx, db, embedder)
+	if err != nil || st.Model != "new" || st.Dim != 3 || st.Stale != 5 {
+		t.Fatalf("status = %+v, %v", st, err)
+	}
+
+	var batches []ReembedProgress
+	p, err := Reembed(ctx, db, embedder, ReembedOptions{BatchSize: 2, Limit: 3, Progress: func(p ReembedProgress) { batches = append(batches, p) }})
+	if err != nil || p.Total != 3 || p.Done != 2 || p.Failed != 1 || len(batches) != 2 || embedder.batches != 2 {
+		t.Fatalf("limited run = %+v, %v (batches %+v)", p, err, batches)
+	}
+	// The failed memory stays stale; a second run finishes the rest
+	p, err = Reembed(ctx, db, embedder, ReembedOptions{BatchSize: 2})
+	if err != nil || p.Total != 3 || p.Done != 2 || p.Failed != 1 {
+		t.Fatalf("second run = %+v, %v", p, err)
+	}
+	if found, err := db.SearchChunks(ctx, []float32{1, 0, 0}, 10); err != nil || len(found) != 4 {
+		t.Errorf("search after re-embedding = %d results, %v", len(found), err)
+	}
+
+	// Failures in a row stop the run
+	for i := 0; i < reembedMaxFailures; i++ {
+		if err := db.InsertChunk(ctx, "bad", "chat", "u1", "old", []float32{1, 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p, err := Reembed(ctx, db, embedder, ReembedOptions{}); err == nil || p.Failed != reembedMaxFailures {
+		t.Errorf("run with failing memories = %+v, %v", p, err)
+	}
+}
+
+// downEmbedder is an embedding provider that cannot be reached.
+type downEmbedder struct{ fakeEmbedder }
+
+func (downEmbedder) Embed(context.Context, string, string) ([]float32, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (downEmbedder) EmbedBatch(context.Context, []string, string) ([][]float32, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestReembedRefusesLocalFallback(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.InsertChunk(ctx, "a", "chat", "u1", "old", []float32{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	// Re-embedding during an outage would replace every memory with local vectors
+	embedder := embeddinggood.WithLocalFallback(&downEmbedder{fakeEmbedder{model: "new"}})
+	if _, err := Reembed(ctx, db, embedder, ReembedOptions{}); err == nil {
+		t.Error("re-embedded with local fallback vectors")
+	}
+}
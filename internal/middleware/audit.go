@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/logging"
+	"github.com/hattiebot/hattiebot/internal/redact"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// AuditStore persists audit entries (implemented by *store.DB).
+type AuditStore interface {
+	AppendAuditEntry(ctx context.Context, e store.AuditEntry) error
+}
+
+// AuditingExecutor records every tool call (who, what, redacted arguments, where, outcome) in the audit log.
+// Wrap it outermost so calls denied by the policy middleware are recorded too.
+type AuditingExecutor struct {
+	next  core.ToolExecutor
+	store AuditStore
+}
+
+// NewAuditingExecutor returns an executor that audits calls to next.
+func NewAuditingExecutor(next core.ToolExecutor, s AuditStore) *AuditingExecutor {
+	return &AuditingExecutor{next: next, store: s}
+}
+
+// Execute runs the tool and appends an audit entry; audit failures are logged, never returned.
+func (a *AuditingExecutor) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	start := time.Now()
+	result, err := a.next.Execute(ctx, name, argsJSON)
+
+	entry := store.AuditEntry{
+		Tool:       name,
+		Args:       RedactArgs(argsJSON),
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	entry.UserID, _ = ctx.Value("user_id").(string)
+	if msg, ok := gateway.MessageFromContext(ctx); ok {
+		entry.Channel, entry.ThreadID = msg.Channel, msg.ThreadID
+	}
+	entry.Outcome, entry.Error = classifyOutcome(result, err)
+	entry.Error = redact.String(entry.Error)
+	// Record even if the turn was cancelled mid-call.
+	if aErr := a.store.AppendAuditEntry(context.WithoutCancel(ctx), entry); aErr != nil {
+		logging.For("audit").ErrorContext(ctx, "failed to record tool call", "tool", name, "error", aErr)
+	}
+	return result, err
+}
+
+func (a *AuditingExecutor) SetSpawner(spawner core.SubmindSpawner) {
+	a.next.SetSpawner(spawner)
+}
+
+// classifyOutcome maps a tool result to ok/error/denied/dry_run. Policy denials are plain "Error: ..." strings;
+// tool failures are Go errors or {"error": ...} JSON; simulated calls are {"dry_run": true, ...}.
+func classifyOutcome(result string, err error) (outcome, errMsg string) {
+	if err != nil {
+		return "error", err.Error()
+	}
+	if strings.HasPrefix(result, "Error: ") {
+		return "denied", strings.TrimPrefix(result, "Error: ")
+	}
+	var obj struct {
+		Error  interface{} `json:"error"`
+		DryRun bool        `json:"dry_run"`
+	}
+	if json.Unmarshal([]byte(result), &obj) == nil && obj.Error != nil && obj.Error != "" {
+		return "error", fmt.Sprint(obj.Error)
+	}
+	if obj.DryRun {
+		return "dry_run", ""
+	}
+	return "ok", ""
+}
+
+// ToolFailed reports whether a tool call failed. Policy denials are not failures.
+func ToolFailed(result string, err error) bool {
+	outcome, _ := classifyOutcome(result, err)
+	return outcome == "error"
+}
+
+const redacted = redact.Mask
+
+// sensitiveKey matches argument names whose values are credentials.
+var sensitiveKey = regexp.MustCompile(`(?i)(pass(word|wd|phrase)?|secret|token|api_?key|auth(orization)?|private_?key|credential|cookie)`)
+
+// RedactArgs replaces values of credential-like keys (at any depth) in a JSON argument object.
+// Unresolved {{secret:...}} references are left as is; they name a secret without revealing it.
+func RedactArgs(argsJSON string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(argsJSON), &v); err != nil {
+		return redact.String(argsJSON)
+	}
+	b, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return redact.String(argsJSON)
+	}
+	return redact.String(string(b))
+}
+
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if s, ok := val.(string); ok && sensitiveKey.MatchString(k) && s != "" && !strings.HasPrefix(s, "{{secret:") {
+				t[k] = redacted
+				continue
+			}
+			t[k] = redactValue(val)
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = redactValue(t[i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/redact"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+type memoryAudit struct{ entries []store.AuditEntry }
+
+func (m *memoryAudit) AppendAuditEntry(ctx context.Context, e store.AuditEntry) error {
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+func TestRedactArgs(t *testing.T) {
+	got := RedactArgs(`{"command": "deploy", "password": "hunter2", "env_vars": {"GITHUB_TOKEN": "ghp_x", "REGION": "eu"}, "api_key": "{{secret:OpenAI}}"}`)
+	for _, leaked := range []string{"hunter2", "ghp_x"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("secret %q not redacted: %s", leaked, got)
+		}
+	}
+	for _, kept := range []string{"deploy", "eu", "{{secret:OpenAI}}"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("expected %q to be kept: %s", kept, got)
+		}
+	}
+	if RedactArgs("not json") != "not json" {
+		t.Error("non-JSON args should pass through")
+	}
+}
+
+func TestAuditingExecutorRecordsOutcome(t *testing.T) {
+	audit := &memoryAudit{}
+	ctx := context.WithValue(context.Background(), "user_id", "alice")
+	ctx = gateway.WithMessage(ctx, gateway.Message{Channel: "nextcloud_talk", ThreadID: "room1"})
+
+	cases := []struct{ result, outcome string }{
+		{`{"status": "ok"}`, "ok"},
+		{`{"error": "file not found"}`, "error"},
+		{"Error: tool 'delete_tool' requires the admin role (you are user).", "denied"},
+	}
+	for _, c := range cases {
+		a := NewAuditingExecutor(&mockExecutor{result: c.result}, audit)
+		if got, err := a.Execute(ctx, "some_tool", `{"token": "abc"}`); err != nil || got != c.result {
+			t.Fatalf("result not passed through: %q, %v", got, err)
+		}
+	}
+	if len(audit.entries) != len(cases) {
+		t.Fatalf("expected %d entries, got %d", len(cases), len(audit.entries))
+	}
+	for i, c := range cases {
+		e := audit.entries[i]
+		if e.Outcome != c.outcome || e.UserID != "alice" || e.Channel != "nextcloud_talk" || e.ThreadID != "room1" || strings.Contains(e.Args, "abc") {
+			t.Errorf("entry %d: %+v", i, e)
+		}
+	}
+}
+
+type echoExecutor struct{ result string }
+
+func (e *echoExecutor) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	return e.result, nil
+}
+func (e *echoExecutor) SetSpawner(spawner core.SubmindSpawner) {}
+
+func TestRedactingExecutorMasksOutput(t *testing.T) {
+	redact.AddSecret("resolved-db-password")
+	exec := NewRedactingExecutor(&echoExecutor{result: `{"stdout": "connected using resolved-db-password"}`})
+	got, err := exec.Execute(context.Background(), "run_terminal_cmd", `{}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "resolved-db-password") || !strings.Contains(got, redact.Mask) {
+		t.Errorf("output not redacted: %s", got)
+	}
+}
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+)
+
+// DefaultConfirmTools are the outbound actions that need the user's confirmation unless the
+// config lists its own (confirm_tools).
+var DefaultConfirmTools = []string{"send_email", "store_secret", "announce"}
+
+// ConfirmArg is the argument that carries the user's confirmation code on the repeated call.
+const ConfirmArg = "confirm_code"
+
+// draftTTL is how long a draft waits for the user's confirmation, heldTTL one held from an
+// unattended run, whose owner may be away.
+const (
+	draftTTL = 15 * time.Minute
+	heldTTL  = 24 * time.Hour
+)
+
+// Drafts gates high-consequence tools behind a draft-and-confirm protocol. The first call does
+// nothing but return a preview and a confirmation code for the agent to show the user. The call
+// runs only when repeated with the same arguments plus the code, in a turn whose user message
+// (in the same thread, from the same user) contains that code, so the model cannot confirm on
+// the user's behalf.
+//
+// Unattended runs (autonomous turns, scheduled tool runs, webhook prompts) have nobody to ask and
+// may be driven by outside text, so their calls are held: the owner is notified with the preview
+// and runs the call by replying with the code in any of their conversations, where the agent
+// repeats the call with just the code. Only runs of plans the user preapproved when creating them
+// (manage_schedule preapprove, itself confirmed as a draft) use gated tools unasked. Direct API
+// calls (ctx "direct_call") are not gated: the caller makes them explicitly.
+type Drafts struct {
+	tools map[string]bool
+	// Notify tells a user about a call held for their confirmation.
+	Notify func(userID, msg string)
+	// Preapproved reports whether a scheduled plan's runs may use gated tools unasked.
+	Preapproved func(ctx context.Context, planID int64) bool
+
+	mu     sync.Mutex
+	drafts map[string]draft // code -> pending action
+}
+
+type draft struct {
+	tool, args string // args canonicalized, without ConfirmArg
+	thread     string // gateway.ThreadKey of the conversation
+	userID     string
+	expires    time.Time
+	held       bool // from an unattended run: confirmed in any of the user's conversations
+}
+
+// NewDrafts gates tools (registered tools by their own name), or DefaultConfirmTools when tools
+// is nil.
+func NewDrafts(tools []string) *Drafts {
+	if tools == nil {
+		tools = DefaultConfirmTools
+	}
+	d := &Drafts{tools: make(map[string]bool, len(tools)), drafts: make(map[string]draft)}
+	for _, t := range tools {
+		d.tools[t] = true
+	}
+	return d
+}
+
+// gate returns the context and arguments to run the call with (without ConfirmArg), or a result
+// to return instead of running it: a draft preview, or an error when the confirmation does not
+// hold. A confirmed call's context is marked "draft_confirmed".
+func (d *Drafts) gate(ctx context.Context, toolName, argsJSON string) (context.Context, string, string) {
+	if d == nil {
+		return ctx, argsJSON, ""
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil || args == nil {
+		args = map[string]interface{}{}
+	}
+	gated := toolName
+	needed := false
+	switch toolName {
+	case "execute_registered_tool":
+		gated, _ = args["name"].(string)
+		needed = d.tools[gated]
+	case "manage_schedule":
+		// A preapproved plan runs gated tools unasked, so creating one needs confirmation
+		needed, _ = args["preapprove"].(bool)
+	default:
+		needed = d.tools[gated]
+	}
+	if direct, _ := ctx.Value("direct_call").(bool); !needed || direct {
+		return ctx, argsJSON, ""
+	}
+	msg, attended := gateway.MessageFromContext(ctx)
+	attended = attended && !msg.Autonomous
+	planID := msg.PlanID
+	if planID == 0 {
+		planID, _ = ctx.Value("plan_id").(int64)
+	}
+	if !attended && planID != 0 && toolName != "manage_schedule" && d.Preapproved != nil && d.Preapproved(ctx, planID) {
+		return ctx, argsJSON, ""
+	}
+	code, _ := args[ConfirmArg].(string)
+	delete(args, ConfirmArg)
+	canonical, _ := json.Marshal(args)
+	userID, _ := ctx.Value("user_id").(string)
+	if !attended {
+		return ctx, "", d.hold(gated, toolName, string(canonical), userID)
+	}
+	thread := gateway.ThreadKey(msg)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire()
+	if code == "" {
+		code = newConfirmCode()
+		d.drafts[code] = draft{tool: gated, args: string(canonical), thread: thread, userID: userID, expires: time.Now().Add(draftTTL)}
+		b, _ := json.Marshal(map[string]interface{}{
+			"status":         "awaiting_confirmation",
+			"tool":           gated,
+			"preview":        json.RawMessage(RedactArgs(string(canonical))),
+			"confirm_code":   code,
+			"expires_in_min": int(draftTTL / time.Minute),
+			"instructions": fmt.Sprintf("Nothing was done yet. Show the user what will happen (from preview) and ask them to reply with \"confirm %s\" in this conversation. "+
+				"Only after their reply contains the code, call %s again with the same arguments plus \"%s\": \"%s\". If they want changes, call it with the new arguments to get a new draft.", code, toolName, ConfirmArg, code),
+		})
+		return ctx, "", string(b)
+	}
+	dr, ok := d.drafts[code]
+	switch {
+	case !ok:
+		return ctx, "", fmt.Sprintf("Error: confirmation code %s is unknown or expired. Call '%s' without %s to get a new draft.", code, toolName, ConfirmArg)
+	case dr.tool != gated || dr.userID != userID || (!dr.held && dr.thread != thread):
+		return ctx, "", fmt.Sprintf("Error: confirmation code %s belongs to another action or conversation.", code)
+	}
+	// A held call is repeated with just the code (and, for a registered tool, its name)
+	if dr.held && (len(args) == 0 || (toolName == "execute_registered_tool" && len(args) == 1)) {
+		canonical = []byte(dr.args)
+	}
+	switch {
+	case dr.args != string(canonical):
+		return ctx, "", fmt.Sprintf("Error: the arguments differ from the draft the user confirmed. Call '%s' without %s to show them a new draft.", toolName, ConfirmArg)
+	case !containsWord(msg.Content, code):
+		return ctx, "", fmt.Sprintf("Error: the user has not confirmed yet. Ask them to reply with \"confirm %s\"; call again once their reply contains the code.", code)
+	}
+	delete(d.drafts, code)
+	return context.WithValue(ctx, "draft_confirmed", true), string(canonical), ""
+}
+
+// hold keeps a gated call from an unattended run for userID to confirm, and notifies them.
+func (d *Drafts) hold(gated, toolName, canonical, userID string) string {
+	if userID == "" {
+		return fmt.Sprintf("Error: '%s' needs a user's confirmation, and this run has no user to ask.", gated)
+	}
+	d.mu.Lock()
+	d.expire()
+	code := newConfirmCode()
+	d.drafts[code] = draft{tool: gated, args: canonical, userID: userID, expires: time.Now().Add(heldTTL), held: true}
+	d.mu.Unlock()
+
+	if d.Notify != nil {
+		d.Notify(userID, fmt.Sprintf("A background task wants to run %s:\n%s\nNothing was done. To run it, reply \"confirm %s %s\" within %d hours.",
+			gated, RedactArgs(canonical), gated, code, int(heldTTL/time.Hour)))
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"status":  "held_for_confirmation",
+		"tool":    gated,
+		"preview": json.RawMessage(RedactArgs(canonical)),
+		"instructions": fmt.Sprintf("Nothing was done. This run is unattended, so the user was sent this draft to confirm. Do not call '%s' again in this run; "+
+			"when the user replies with the code, call it with just \"%s\": \"<code>\".", toolName, ConfirmArg),
+	})
+	return string(b)
+}
+
+// expire drops drafts past their time. d.mu must be held.
+func (d *Drafts) expire() {
+	now := time.Now()
+	for c, dr := range d.drafts {
+		if now.After(dr.expires) {
+			delete(d.drafts, c)
+		}
+	}
+}
+
+// newConfirmCode returns a random 6-digit code, easy to type on a phone.
+func newConfirmCode() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(900000))
+	if err != nil {
+		return fmt.Sprint(100000 + time.Now().UnixNano()%900000)
+	}
+	return fmt.Sprint(100000 + n.Int64())
+}
+
+// containsWord reports whether s contains code not run together with other digits.
+func containsWord(s, code string) bool {
+	for i := 0; i+len(code) <= len(s); i++ {
+		if s[i:i+len(code)] != code {
+			continue
+		}
+		before := i == 0 || s[i-1] < '0' || s[i-1] > '9'
+		after := i+len(code) == len(s) || s[i+len(code)] < '0' || s[i+len(code)] > '9'
+		if before && after {
+			return true
+		}
+	}
+	return false
+}
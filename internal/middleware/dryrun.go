@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/redact"
+)
+
+// dryRunKey is the context key that marks a turn as a dry run.
+const dryRunKey = "dry_run"
+
+// dryRunAlso are tools without a restricting policy that still act: they run code, start or
+// schedule work that would run for real later, post messages, or change stored settings and
+// memories. A dry run simulates them too, except calls whose action only reads (dryRunReads).
+var dryRunAlso = map[string]bool{
+	"execute_registered_tool": true, "autohand_cli": true,
+	"manage_schedule": true, "spawn_submind": true, "manage_briefing": true, "talk_actions": true, "react": true,
+	"manage_context_doc": true, "manage_profile": true, "manage_notifications": true,
+	"manage_user_preference": true, "memorize": true, "link_identity": true, "branch_thread": true, "export_thread": true,
+}
+
+// dryRunReads are the actions of dryRunAlso tools that only read, so they run in a dry run; so do
+// actions starting with list_ or get_.
+var dryRunReads = map[string]bool{"list": true, "get": true, "read": true, "search": true, "history": true, "preview": true, "reply_options": true}
+
+// dryRunTargets are the arguments that say what a call acts on, in the order they are described.
+var dryRunTargets = []string{"command", "work_dir", "name", "action", "method", "url", "path", "source", "destination", "to", "subject", "run_at"}
+
+// WithDryRun marks ctx as a dry run: restricted tools describe what they would do instead of
+// running (see PolicyMiddleware.DryRun).
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey, true)
+}
+
+// IsDryRun reports whether ctx is marked as a dry run.
+func IsDryRun(ctx context.Context) bool {
+	v, _ := ctx.Value(dryRunKey).(bool)
+	return v
+}
+
+// simulates reports whether a call is simulated rather than run: in a dry run, tools that need
+// confirmation or a role (restricted, admin_only, owner_only) and those in dryRunAlso.
+func (m *PolicyMiddleware) simulates(ctx context.Context, toolName, policy, argsJSON string) bool {
+	if !m.DryRun && !IsDryRun(ctx) {
+		return false
+	}
+	if policy == "restricted" || policy == "admin_only" || policy == "owner_only" {
+		return true
+	}
+	if !dryRunAlso[toolName] {
+		return false
+	}
+	var args struct {
+		Action string `json:"action"`
+	}
+	_ = json.Unmarshal([]byte(argsJSON), &args)
+	reads := dryRunReads[args.Action] || strings.HasPrefix(args.Action, "list_") || strings.HasPrefix(args.Action, "get_")
+	return !reads
+}
+
+// dryRunResult describes a call without running it: the tool, the arguments that name its
+// target (command, path, API call) and all arguments with credentials redacted.
+func dryRunResult(toolName, policy, argsJSON string) string {
+	var args map[string]interface{}
+	_ = json.Unmarshal([]byte(argsJSON), &args)
+	if nested, ok := args["args"].(map[string]interface{}); ok && toolName == "execute_registered_tool" {
+		for k, v := range nested {
+			if _, taken := args[k]; !taken {
+				args[k] = v
+			}
+		}
+	}
+	var parts []string
+	for _, key := range dryRunTargets {
+		if v, ok := args[key]; ok && v != "" && v != nil {
+			parts = append(parts, fmt.Sprintf("%s=%v", key, v))
+		}
+	}
+	would := "call " + toolName
+	if len(parts) > 0 {
+		would += " with " + strings.Join(parts, ", ")
+	}
+	var shown interface{} = redact.String(argsJSON)
+	if args != nil {
+		shown = json.RawMessage(RedactArgs(argsJSON))
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"dry_run": true,
+		"tool":    toolName,
+		"policy":  policy,
+		"would":   redact.String(would),
+		"args":    shown,
+		"note":    "Dry run: nothing was executed. Assume the call would succeed, continue planning, and finish with a numbered list of every step you would take for real.",
+	})
+	return string(b)
+}
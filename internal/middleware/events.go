@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/events"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/redact"
+)
+
+// EventExecutor publishes a tool.failed event for each failed tool call to outbound event
+// subscribers. Policy denials and dry runs are not failures.
+type EventExecutor struct {
+	next core.ToolExecutor
+	bus  *events.Bus
+}
+
+// NewEventExecutor returns an executor that reports failed calls to next on bus.
+func NewEventExecutor(next core.ToolExecutor, bus *events.Bus) *EventExecutor {
+	return &EventExecutor{next: next, bus: bus}
+}
+
+// Execute runs the tool and publishes its failure, if any.
+func (e *EventExecutor) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	result, err := e.next.Execute(ctx, name, argsJSON)
+	if outcome, errMsg := classifyOutcome(result, err); outcome == "error" {
+		data := map[string]interface{}{"tool": name, "error": redact.String(errMsg)}
+		if msg, ok := gateway.MessageFromContext(ctx); ok {
+			data["channel"], data["thread_id"] = msg.Channel, msg.ThreadID
+		}
+		userID, _ := ctx.Value("user_id").(string)
+		e.bus.Publish(ctx, events.ToolFailed, userID, data)
+	}
+	return result, err
+}
+
+func (e *EventExecutor) SetSpawner(spawner core.SubmindSpawner) {
+	e.next.SetSpawner(spawner)
+}
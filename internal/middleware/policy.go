@@ -2,19 +2,40 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/store"
 )
 
 // ConfirmationFunc is a callback to ask the user for permission
 type ConfirmationFunc func(msg string) (bool, error)
 
+// PermissionLookup finds tool_permissions grants (implemented by *store.DB).
+type PermissionLookup interface {
+	FindToolPermission(ctx context.Context, userID, trustLevel, tool, policy string) (*store.ToolPermission, error)
+}
+
 // PolicyMiddleware wraps a ToolExecutor and enforces policies
 type PolicyMiddleware struct {
 	next       core.ToolExecutor
 	confirm    ConfirmationFunc
 	toolDefs   map[string]core.ToolDefinition
+	// Permissions enables per-user grants; when set, restricted tools also require the admin role or a grant.
+	Permissions PermissionLookup
+	// Throttle, when throttled, makes non-safe tools require the user's explicit approval.
+	Throttle Throttler
+	// Drafts, when set, holds outbound actions until the user confirms them (see Drafts).
+	Drafts *Drafts
+	// DryRun simulates restricted tools on every call, as WithDryRun does for one turn: they return
+	// a description of what they would do instead of running.
+	DryRun bool
+	// Dynamic, when set, supplies definitions of tools loaded after startup (plugins). It is
+	// consulted first, so a reloaded plugin's policy replaces the one it had at startup.
+	Dynamic func(name string) (core.ToolDefinition, bool)
 }
 
 // NewPolicyMiddleware creates a new middleware. 
@@ -33,6 +54,11 @@ func NewPolicyMiddleware(next core.ToolExecutor, tools []core.ToolDefinition, co
 
 func (m *PolicyMiddleware) Execute(ctx context.Context, toolName string, argsJSON string) (string, error) {
 	def, ok := m.toolDefs[toolName]
+	if m.Dynamic != nil {
+		if d, found := m.Dynamic(toolName); found {
+			def, ok = d, true
+		}
+	}
 	
 	// If tool not found in definitions, assume it's safe OR fail? 
 	// Let's default to safe but log warning, or maybe it's dynamic.
@@ -42,7 +68,35 @@ func (m *PolicyMiddleware) Execute(ctx context.Context, toolName string, argsJSO
 		policy = def.Policy
 	}
 
-	if policy == "restricted" || policy == "admin_only" {
+	// Role check: tools marked owner_only/admin_only/operator need that role or higher, unless
+	// a tool_permissions grant allows them. Calls without a user role in context (system/internal)
+	// are not role-gated.
+	if role, ok := ctx.Value("user_role").(string); ok {
+		var denied string
+		var err error
+		argsJSON, denied, err = m.authorize(ctx, toolName, policy, role, argsJSON)
+		if err != nil {
+			return "", err
+		}
+		if denied != "" {
+			return denied, nil
+		}
+	}
+
+	if denied := m.throttleDenial(ctx, toolName, policy, argsJSON); denied != "" {
+		return denied, nil
+	}
+
+	if m.simulates(ctx, toolName, policy, argsJSON) {
+		return dryRunResult(toolName, policy, argsJSON), nil
+	}
+
+	ctx, argsJSON, draft := m.Drafts.gate(ctx, toolName, argsJSON)
+	if draft != "" {
+		return draft, nil
+	}
+
+	if policy == "restricted" || policy == "admin_only" || policy == "owner_only" {
 		// Ask for confirmation
 		if m.confirm != nil {
 			approved, err := m.confirm(fmt.Sprintf("Allow tool '%s'? Policy: %s", toolName, policy))
@@ -61,3 +115,64 @@ func (m *PolicyMiddleware) Execute(ctx context.Context, toolName string, argsJSO
 func (m *PolicyMiddleware) SetSpawner(spawner core.SubmindSpawner) {
 	m.next.SetSpawner(spawner)
 }
+
+// authorize checks role and grants for a call. It returns the (possibly rewritten) arguments, or a
+// denial message. A grant with a work_dir confines the call: a missing work_dir argument is set to it,
+// and one outside it is denied.
+func (m *PolicyMiddleware) authorize(ctx context.Context, toolName, policy, role, argsJSON string) (string, string, error) {
+	min, gated := store.PolicyMinRole[policy]
+	if policy == "restricted" && m.Permissions == nil {
+		gated = false // without grants, restricted tools are gated by confirmation only
+	}
+	if !gated || store.RoleAtLeast(role, min) {
+		return argsJSON, "", nil
+	}
+	denied := fmt.Sprintf("Error: tool '%s' requires the %s role (you are %s).", toolName, min, role)
+	if m.Permissions == nil || policy == "owner_only" {
+		return argsJSON, denied, nil
+	}
+	// Whole-policy grants apply to restricted tools only; admin tools must be granted by name.
+	grantPolicy := ""
+	if policy == "restricted" {
+		grantPolicy = policy
+	}
+	userID, _ := ctx.Value("user_id").(string)
+	trust, _ := ctx.Value("user_trust").(string)
+	grant, err := m.Permissions.FindToolPermission(ctx, userID, trust, toolName, grantPolicy)
+	if err != nil {
+		return argsJSON, "", fmt.Errorf("permission lookup: %w", err)
+	}
+	if grant == nil {
+		return argsJSON, denied + " Ask an admin to grant it with manage_permissions.", nil
+	}
+	if grant.WorkDir == "" {
+		return argsJSON, "", nil
+	}
+	return confineWorkDir(toolName, argsJSON, grant.WorkDir)
+}
+
+// confineWorkDir resolves the call's work_dir against dir and denies paths outside it.
+func confineWorkDir(toolName, argsJSON, dir string) (string, string, error) {
+	args := map[string]interface{}{}
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return argsJSON, fmt.Sprintf("Error: invalid arguments for '%s': %v", toolName, err), nil
+		}
+	}
+	wd, _ := args["work_dir"].(string)
+	if wd == "" {
+		wd = dir
+	} else if !filepath.IsAbs(wd) {
+		wd = filepath.Join(dir, wd)
+	}
+	wd = filepath.Clean(wd)
+	if rel, err := filepath.Rel(dir, wd); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return argsJSON, fmt.Sprintf("Error: your permission for '%s' is limited to %s.", toolName, dir), nil
+	}
+	args["work_dir"] = wd
+	b, err := json.Marshal(args)
+	if err != nil {
+		return argsJSON, "", err
+	}
+	return string(b), "", nil
+}
@@ -0,0 +1,316 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestPolicyMiddlewareGatesByRole(t *testing.T) {
+	defs := []core.ToolDefinition{
+		{Function: core.FunctionSpec{Name: "add_admin"}, Policy: "owner_only"},
+		{Function: core.FunctionSpec{Name: "delete_tool"}, Policy: "admin_only"},
+		{Function: core.FunctionSpec{Name: "list_users"}, Policy: "operator"},
+		{Function: core.FunctionSpec{Name: "read_file"}, Policy: "safe"},
+	}
+	m := NewPolicyMiddleware(&mockExecutor{result: "ran"}, defs, nil)
+
+	cases := []struct {
+		role, tool string
+		allowed    bool
+	}{
+		{"owner", "add_admin", true},
+		{"admin", "add_admin", false},
+		{"admin", "delete_tool", true},
+		{"operator", "delete_tool", false},
+		{"operator", "list_users", true},
+		{"user", "list_users", false},
+		{"user", "read_file", true},
+	}
+	for _, c := range cases {
+		ctx := context.WithValue(context.Background(), "user_role", c.role)
+		got, err := m.Execute(ctx, c.tool, "{}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed := got == "ran"; allowed != c.allowed {
+			t.Errorf("%s calling %s: got %q, want allowed=%v", c.role, c.tool, got, c.allowed)
+		}
+	}
+
+	// Internal calls without a user role are not role-gated.
+	if got, _ := m.Execute(context.Background(), "delete_tool", "{}"); got != "ran" {
+		t.Errorf("call without role was blocked: %q", got)
+	}
+	ctx := context.WithValue(context.Background(), "user_role", "user")
+	if got, _ := m.Execute(ctx, "delete_tool", "{}"); !strings.Contains(got, "requires the admin role") {
+		t.Errorf("unexpected denial message: %q", got)
+	}
+
+	// Tools loaded after startup (plugins) are gated by their dynamic definition
+	m.Dynamic = func(name string) (core.ToolDefinition, bool) {
+		if name == "plugin_tool" || name == "read_file" {
+			return core.ToolDefinition{Function: core.FunctionSpec{Name: name}, Policy: "admin_only"}, true
+		}
+		return core.ToolDefinition{}, false
+	}
+	for _, tool := range []string{"plugin_tool", "read_file"} {
+		if got, _ := m.Execute(ctx, tool, "{}"); got == "ran" {
+			t.Errorf("user ran %s despite its dynamic admin_only policy", tool)
+		}
+	}
+}
+
+type grantLookup map[string]*store.ToolPermission // keyed by userID+"/"+tool
+
+func (g grantLookup) FindToolPermission(ctx context.Context, userID, trustLevel, tool, policy string) (*store.ToolPermission, error) {
+	return g[userID+"/"+tool], nil
+}
+
+type argsRecorder struct{ args string }
+
+func (r *argsRecorder) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	r.args = argsJSON
+	return "ran", nil
+}
+
+func (r *argsRecorder) SetSpawner(spawner core.SubmindSpawner) {}
+
+func TestPolicyMiddlewareGrantsConfineWorkDir(t *testing.T) {
+	defs := []core.ToolDefinition{
+		{Function: core.FunctionSpec{Name: "run_terminal_cmd"}, Policy: "restricted"},
+		{Function: core.FunctionSpec{Name: "add_admin"}, Policy: "owner_only"},
+	}
+	rec := &argsRecorder{}
+	m := NewPolicyMiddleware(rec, defs, nil)
+	m.Permissions = grantLookup{
+		"bob/run_terminal_cmd": {Tool: "run_terminal_cmd", WorkDir: "/workspace/sandboxes/bob"},
+		"bob/add_admin":        {Tool: "add_admin"},
+	}
+	userCtx := func(id string) context.Context {
+		ctx := context.WithValue(context.Background(), "user_role", "user")
+		return context.WithValue(ctx, "user_id", id)
+	}
+
+	if got, _ := m.Execute(userCtx("carol"), "run_terminal_cmd", `{"command": "ls"}`); !strings.Contains(got, "manage_permissions") {
+		t.Errorf("ungranted restricted call should be denied, got %q", got)
+	}
+	if got, _ := m.Execute(userCtx("bob"), "run_terminal_cmd", `{"command": "ls"}`); got != "ran" || !strings.Contains(rec.args, `"work_dir":"/workspace/sandboxes/bob"`) {
+		t.Errorf("granted call: got %q args %s", got, rec.args)
+	}
+	if got, _ := m.Execute(userCtx("bob"), "run_terminal_cmd", `{"command": "ls", "work_dir": "sub"}`); got != "ran" || !strings.Contains(rec.args, `/workspace/sandboxes/bob/sub`) {
+		t.Errorf("relative work_dir: got %q args %s", got, rec.args)
+	}
+	if got, _ := m.Execute(userCtx("bob"), "run_terminal_cmd", `{"command": "ls", "work_dir": "/workspace/sandboxes/bob/../alice"}`); got == "ran" {
+		t.Error("escaping work_dir should be denied")
+	}
+	if got, _ := m.Execute(userCtx("bob"), "add_admin", `{}`); got == "ran" {
+		t.Error("owner_only tools cannot be granted")
+	}
+}
+
+type fixedThrottle bool
+
+func (f fixedThrottle) Throttled() bool { return bool(f) }
+
+func TestPolicyThrottleRequiresApproval(t *testing.T) {
+	defs := []core.ToolDefinition{
+		{Function: core.FunctionSpec{Name: "run_terminal_cmd"}, Policy: "restricted"},
+		{Function: core.FunctionSpec{Name: "recall_memories"}},
+	}
+	m := NewPolicyMiddleware(&mockExecutor{result: "ran"}, defs, nil)
+	m.Throttle = fixedThrottle(true)
+	chat := gateway.WithMessage(context.Background(), gateway.Message{Channel: "talk"})
+
+	if out, _ := m.Execute(chat, "recall_memories", `{}`); strings.HasPrefix(out, "Error:") {
+		t.Errorf("safe tool blocked while throttled: %s", out)
+	}
+	if out, _ := m.Execute(chat, "run_terminal_cmd", `{"command": "ls"}`); !strings.Contains(out, "user_approved") {
+		t.Errorf("restricted tool should ask for approval, got %q", out)
+	}
+	if out, _ := m.Execute(chat, "run_terminal_cmd", `{"command": "ls", "user_approved": true}`); strings.HasPrefix(out, "Error:") {
+		t.Errorf("approved call denied: %s", out)
+	}
+	auto := gateway.WithMessage(context.Background(), gateway.Message{Channel: "talk", Autonomous: true})
+	if out, _ := m.Execute(auto, "run_terminal_cmd", `{"command": "ls", "user_approved": true}`); !strings.Contains(out, "paused") {
+		t.Errorf("autonomous restricted call should wait for recovery, got %q", out)
+	}
+}
+
+func TestDraftsRequireUserConfirmation(t *testing.T) {
+	next := &argsRecorder{}
+	m := NewPolicyMiddleware(next, []core.ToolDefinition{{Function: core.FunctionSpec{Name: "send_email"}, Policy: "restricted"}}, nil)
+	m.Drafts = NewDrafts(nil)
+	turn := func(content string) context.Context {
+		ctx := context.WithValue(context.Background(), "user_id", "alice")
+		return gateway.WithMessage(ctx, gateway.Message{SenderID: "alice", Channel: "talk", ThreadID: "room", Content: content})
+	}
+	args := `{"to": ["bob@example.com"], "subject": "Hi", "body": "Lunch?"}`
+
+	out, _ := m.Execute(turn("email bob about lunch"), "send_email", args)
+	var draft struct {
+		Status string `json:"status"`
+		Code   string `json:"confirm_code"`
+	}
+	_ = json.Unmarshal([]byte(out), &draft)
+	if draft.Status != "awaiting_confirmation" || draft.Code == "" || next.args != "" {
+		t.Fatalf("first call = %s (ran with %q), want a draft", out, next.args)
+	}
+	withCode := strings.TrimSuffix(args, "}") + `, "confirm_code": "` + draft.Code + `"}`
+
+	// The model cannot confirm in the same turn, nor with other arguments or from another thread
+	if out, _ := m.Execute(turn("email bob about lunch"), "send_email", withCode); !strings.Contains(out, "not confirmed") {
+		t.Errorf("unconfirmed call = %s", out)
+	}
+	other := gateway.WithMessage(context.WithValue(context.Background(), "user_id", "alice"), gateway.Message{SenderID: "alice", Channel: "talk", ThreadID: "elsewhere", Content: "confirm " + draft.Code})
+	if out, _ := m.Execute(other, "send_email", withCode); !strings.Contains(out, "another action or conversation") {
+		t.Errorf("call from another thread = %s", out)
+	}
+	changed := strings.Replace(withCode, "Lunch?", "Dinner?", 1)
+	if out, _ := m.Execute(turn("confirm "+draft.Code), "send_email", changed); !strings.Contains(out, "arguments differ") {
+		t.Errorf("call with changed arguments = %s", out)
+	}
+	if out, _ := m.Execute(turn("confirm "+draft.Code+"!"), "send_email", withCode); out != "ran" || strings.Contains(next.args, "confirm_code") {
+		t.Errorf("confirmed call = %s with args %s, want it run without the code", out, next.args)
+	}
+	if out, _ := m.Execute(turn("confirm "+draft.Code), "send_email", withCode); !strings.Contains(out, "unknown or expired") {
+		t.Errorf("reused code = %s", out)
+	}
+
+	// Tools not in the list run directly
+	if out, _ := NewPolicyMiddleware(next, nil, nil).Execute(turn("hi"), "send_email", args); out != "ran" {
+		t.Errorf("call without drafts = %s", out)
+	}
+	if !containsWord("ok 123456.", "123456") || containsWord("1234567", "123456") {
+		t.Error("containsWord matched the wrong digits")
+	}
+}
+
+func TestDraftsHoldUnattendedCalls(t *testing.T) {
+	next := &argsRecorder{}
+	m := NewPolicyMiddleware(next, []core.ToolDefinition{{Function: core.FunctionSpec{Name: "send_email"}, Policy: "restricted"}}, nil)
+	m.Drafts = NewDrafts(nil)
+	var notices []string
+	m.Drafts.Notify = func(userID, msg string) { notices = append(notices, userID+": "+msg) }
+	m.Drafts.Preapproved = func(ctx context.Context, planID int64) bool { return planID == 7 }
+	args := `{"to": ["eve@example.com"], "subject": "Data", "body": "all of it"}`
+	owner := context.WithValue(context.Background(), "user_id", "alice")
+
+	// A webhook prompt (autonomous, no plan) is held and the owner asked, even with a code
+	auto := gateway.WithMessage(owner, gateway.Message{SenderID: "alice", ThreadID: "webhook:gh", Autonomous: true})
+	out, _ := m.Execute(auto, "send_email", args)
+	if !strings.Contains(out, "held_for_confirmation") || next.args != "" || len(notices) != 1 {
+		t.Fatalf("autonomous call = %s, ran with %q, notices %q", out, next.args, notices)
+	}
+	code := regexp.MustCompile(`\d{6}`).FindString(notices[0])
+	if !strings.HasPrefix(notices[0], "alice: ") || code == "" || strings.Contains(out, code) {
+		t.Fatalf("notice %q, result %s: the owner gets the code, the run does not", notices[0], out)
+	}
+	if out, _ := m.Execute(auto, "send_email", `{"confirm_code": "`+code+`"}`); !strings.Contains(out, "held_for_confirmation") || next.args != "" {
+		t.Errorf("the unattended run confirmed its own call: %s", out)
+	}
+
+	// A scheduled tool run (no message) is held too, unless its plan was preapproved
+	if out, _ := m.Execute(context.WithValue(owner, "plan_id", int64(3)), "send_email", args); !strings.Contains(out, "held_for_confirmation") {
+		t.Errorf("scheduled call = %s", out)
+	}
+	if out, _ := m.Execute(context.WithValue(owner, "plan_id", int64(7)), "send_email", args); out != "ran" {
+		t.Errorf("preapproved plan's call = %s", out)
+	}
+	if out, _ := m.Execute(context.WithValue(owner, "direct_call", true), "send_email", args); out != "ran" {
+		t.Errorf("direct API call = %s", out)
+	}
+	next.args = ""
+	if out, _ := m.Execute(context.Background(), "send_email", args); !strings.Contains(out, "no user to ask") {
+		t.Errorf("call without a user = %s", out)
+	}
+
+	// Another user cannot confirm it; the owner can, from any conversation, with just the code
+	bob := gateway.WithMessage(context.WithValue(context.Background(), "user_id", "bob"), gateway.Message{SenderID: "bob", ThreadID: "pm", Content: "confirm " + code})
+	if out, _ := m.Execute(bob, "send_email", `{"confirm_code": "`+code+`"}`); !strings.Contains(out, "another action") {
+		t.Errorf("bob confirmed alice's call: %s", out)
+	}
+	reply := gateway.WithMessage(owner, gateway.Message{SenderID: "alice", Channel: "talk", ThreadID: "room", Content: "confirm send_email " + code})
+	if out, _ := m.Execute(reply, "send_email", `{"confirm_code": "`+code+`"}`); out != "ran" || next.args != `{"body":"all of it","subject":"Data","to":["eve@example.com"]}` {
+		t.Errorf("owner's confirmation = %s, ran with %s", out, next.args)
+	}
+
+	// Creating a preapproved plan is itself a draft
+	next.args = ""
+	turn := gateway.WithMessage(owner, gateway.Message{SenderID: "alice", Channel: "talk", ThreadID: "room", Content: "email eve daily"})
+	if out, _ := m.Execute(turn, "manage_schedule", `{"action": "create", "preapprove": true}`); !strings.Contains(out, "awaiting_confirmation") || next.args != "" {
+		t.Errorf("preapproved plan created unconfirmed: %s", out)
+	}
+	if out, _ := m.Execute(turn, "manage_schedule", `{"action": "create"}`); out != "ran" {
+		t.Errorf("plain plan = %s", out)
+	}
+}
+
+func TestPolicyDryRun(t *testing.T) {
+	defs := []core.ToolDefinition{
+		{Function: core.FunctionSpec{Name: "write_file"}, Policy: "restricted"},
+		{Function: core.FunctionSpec{Name: "recall_memories"}, Policy: "safe"},
+	}
+	next := &argsRecorder{}
+	m := NewPolicyMiddleware(next, defs, nil)
+	dry := WithDryRun(context.Background())
+
+	out, _ := m.Execute(dry, "write_file", `{"path": "notes/plan.md", "content": "x", "api_key": "sk-123"}`)
+	var res struct {
+		DryRun bool            `json:"dry_run"`
+		Would  string          `json:"would"`
+		Args   json.RawMessage `json:"args"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil || !res.DryRun || next.args != "" {
+		t.Fatalf("dry run = %s (ran with %q)", out, next.args)
+	}
+	if !strings.Contains(res.Would, "path=notes/plan.md") || strings.Contains(string(res.Args), "sk-123") {
+		t.Errorf("description = %q, args %s", res.Would, res.Args)
+	}
+	if outcome, _ := classifyOutcome(out, nil); outcome != "dry_run" {
+		t.Errorf("outcome = %q", outcome)
+	}
+	if out, _ := m.Execute(dry, "recall_memories", `{}`); out != "ran" {
+		t.Errorf("safe tool in a dry run = %s", out)
+	}
+	if out, _ := m.Execute(dry, "execute_registered_tool", `{"name": "sync_photos", "args": {"path": "/photos"}}`); !strings.Contains(out, "name=sync_photos") {
+		t.Errorf("registered tool in a dry run = %s", out)
+	}
+	if out, _ := m.Execute(context.Background(), "write_file", `{"path": "a"}`); out != "ran" {
+		t.Errorf("call outside a dry run = %s", out)
+	}
+	m.DryRun = true
+	if out, _ := m.Execute(context.Background(), "write_file", `{"path": "a"}`); !strings.Contains(out, `"dry_run":true`) {
+		t.Errorf("call with the global dry run = %s", out)
+	}
+}
+
+func TestPolicyDryRunCreatesNoPlan(t *testing.T) {
+	next := &argsRecorder{}
+	m := NewPolicyMiddleware(next, nil, nil)
+	dry := WithDryRun(context.Background())
+
+	for tool, args := range map[string]string{
+		"manage_schedule": `{"action": "create", "kind": "execute_tool", "tool": "send_email", "schedule": "daily 09:00"}`,
+		"spawn_submind":   `{"goal": "clean up old notes", "async": true}`,
+		"manage_briefing": `{"action": "send_now"}`,
+	} {
+		if out, _ := m.Execute(dry, tool, args); !strings.Contains(out, `"dry_run":true`) || next.args != "" {
+			t.Errorf("%s in a dry run = %s (ran with %q)", tool, out, next.args)
+		}
+	}
+	for tool, args := range map[string]string{
+		"manage_schedule": `{"action": "list"}`,
+		"manage_briefing": `{"action": "preview"}`,
+	} {
+		if out, _ := m.Execute(dry, tool, args); out != "ran" {
+			t.Errorf("read-only %s in a dry run = %s", tool, out)
+		}
+	}
+}
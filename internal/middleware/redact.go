@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/redact"
+)
+
+// RedactingExecutor masks secrets in tool output before it reaches the LLM, history, or logs.
+// Resolved {{secret:...}} values are registered with the redact package by the secret store.
+type RedactingExecutor struct {
+	next core.ToolExecutor
+}
+
+// NewRedactingExecutor returns an executor that redacts results from next.
+func NewRedactingExecutor(next core.ToolExecutor) *RedactingExecutor {
+	return &RedactingExecutor{next: next}
+}
+
+// Execute runs the inner executor and redacts the result.
+func (r *RedactingExecutor) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	result, err := r.next.Execute(ctx, name, argsJSON)
+	return redact.String(result), err
+}
+
+func (r *RedactingExecutor) SetSpawner(spawner core.SubmindSpawner) {
+	r.next.SetSpawner(spawner)
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/errbudget"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+)
+
+// Throttler reports whether the bot is self-throttling (implemented by *errbudget.Budget).
+type Throttler interface {
+	Throttled() bool
+}
+
+// ErrorBudgetExecutor records each tool call's outcome in the error budget. Policy denials and
+// dry runs are not counted.
+type ErrorBudgetExecutor struct {
+	next   core.ToolExecutor
+	budget *errbudget.Budget
+}
+
+// NewErrorBudgetExecutor returns an executor that reports tool outcomes from next to budget.
+func NewErrorBudgetExecutor(next core.ToolExecutor, budget *errbudget.Budget) *ErrorBudgetExecutor {
+	return &ErrorBudgetExecutor{next: next, budget: budget}
+}
+
+// Execute runs the tool and records whether it failed.
+func (e *ErrorBudgetExecutor) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	result, err := e.next.Execute(ctx, name, argsJSON)
+	if outcome, _ := classifyOutcome(result, err); outcome != "denied" && outcome != "dry_run" {
+		e.budget.Record(errbudget.Tool, outcome == "error")
+	}
+	return result, err
+}
+
+func (e *ErrorBudgetExecutor) SetSpawner(spawner core.SubmindSpawner) {
+	e.next.SetSpawner(spawner)
+}
+
+// throttleDenial returns a denial when the bot is self-throttling and a non-safe tool is called
+// without the user's approval. Autonomous and scheduled calls cannot be approved and wait for
+// recovery; interactive calls go through once the model retries with "user_approved": true.
+func (m *PolicyMiddleware) throttleDenial(ctx context.Context, toolName, policy, argsJSON string) string {
+	if m.Throttle == nil || (policy != "restricted" && policy != "admin_only" && policy != "owner_only") || !m.Throttle.Throttled() {
+		return ""
+	}
+	if msg, ok := gateway.MessageFromContext(ctx); !ok || msg.Autonomous {
+		return fmt.Sprintf("Error: HattieBot is self-throttling after repeated errors; '%s' is paused for scheduled and autonomous runs until error rates recover.", toolName)
+	}
+	var args struct {
+		UserApproved bool `json:"user_approved"`
+	}
+	if json.Unmarshal([]byte(argsJSON), &args) == nil && args.UserApproved {
+		return ""
+	}
+	return fmt.Sprintf("Error: HattieBot is self-throttling after repeated errors, so '%s' needs the user's explicit approval. Ask the user; if they agree, call it again with \"user_approved\": true.", toolName)
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/tracing"
+)
+
+// TracingExecutor records each tool call as a span of the turn's trace, with its outcome as in the
+// audit log. Wrap it outermost so the span covers the policy checks too.
+type TracingExecutor struct {
+	next core.ToolExecutor
+}
+
+// NewTracingExecutor returns an executor that traces calls to next.
+func NewTracingExecutor(next core.ToolExecutor) *TracingExecutor {
+	return &TracingExecutor{next: next}
+}
+
+// Execute runs the tool inside a "tool.<name>" span.
+func (t *TracingExecutor) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	ctx, span := tracing.Start(ctx, "tool."+name)
+	result, err := t.next.Execute(ctx, name, argsJSON)
+	outcome, errMsg := classifyOutcome(result, err)
+	span.Set("tool.name", name).Set("tool.outcome", outcome)
+	if outcome == "error" {
+		span.Set("tool.error", errMsg)
+		if err == nil {
+			// Failed tools report errors in their JSON result; mark the span failed all the same
+			span.SetError(toolError(errMsg))
+		}
+	}
+	span.EndErr(err)
+	return result, err
+}
+
+func (t *TracingExecutor) SetSpawner(spawner core.SubmindSpawner) {
+	t.next.SetSpawner(spawner)
+}
+
+type toolError string
+
+func (e toolError) Error() string { return string(e) }
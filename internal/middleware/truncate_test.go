@@ -5,6 +5,8 @@ import (
 	"errors"
 	"strings"
 	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/core"
 )
 
 type mockExecutor struct {
@@ -19,6 +21,8 @@ func (m *mockExecutor) Execute(ctx context.Context, name, argsJSON string) (stri
 	return m.result, nil
 }
 
+func (m *mockExecutor) SetSpawner(spawner core.SubmindSpawner) {}
+
 func TestTruncatingExecutor_NoTruncationWhenMaxZero(t *testing.T) {
 	long := strings.Repeat("x", 1000)
 	inner := &mockExecutor{result: long}
@@ -0,0 +1,227 @@
+// Package monitor is a live view of a running HattieBot, like htop: turns in progress with their
+// tool calls, queue depth, the next scheduled plans, recent tool calls and logs.
+//
+// The bot serves snapshots of its state as JSON on a Unix socket (Listen); the hattiebot-monitor
+// command polls it and redraws the terminal (Run). The socket is only accessible to its owner, so
+// the view is for whoever runs the bot, like the admin terminal.
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/health"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/version"
+)
+
+// Snapshot limits.
+const (
+	maxPlans     = 8
+	maxToolCalls = 10
+	maxLogs      = 200
+	// searchWindow is how many log entries a text search looks through.
+	searchWindow = 1000
+)
+
+// Snapshot is the bot's state at one moment.
+type Snapshot struct {
+	Time          time.Time                         `json:"time"`
+	Version       string                            `json:"version"`
+	StartedAt     time.Time                         `json:"started_at"`
+	Health        string                            `json:"health,omitempty"`
+	Components    map[string]health.ComponentHealth `json:"components,omitempty"`
+	Turns         []store.JournaledTurn             `json:"turns"`
+	Queue         gateway.QueueStats                `json:"queue"`
+	Plans         []store.ScheduledPlan             `json:"plans"` // active, next due first
+	PlanCount     int                               `json:"plan_count"`
+	SchedulerTick time.Time                         `json:"scheduler_tick,omitempty"`
+	ToolCalls     []store.AuditEntry                `json:"tool_calls"` // newest first
+	Logs          []health.LogEntry                 `json:"logs"`       // newest first, filtered
+}
+
+// Filter narrows a snapshot's logs. Zero values match everything.
+type Filter struct {
+	Level     string `json:"level,omitempty"`
+	Component string `json:"component,omitempty"`
+	Search    string `json:"search,omitempty"` // case-insensitive text in the message
+	Limit     int    `json:"limit,omitempty"`  // default 50
+}
+
+// Source gathers snapshots from the running bot's components. Nil components are left out.
+type Source struct {
+	DB        *store.DB
+	Logs      *store.LogStore
+	Gateway   *gateway.Gateway
+	Health    *health.Registry
+	StartedAt time.Time
+	// SchedulerTick returns when the scheduler last looked for due plans.
+	SchedulerTick func() time.Time
+}
+
+// Snapshot gathers the current state, with the logs f selects.
+func (s *Source) Snapshot(ctx context.Context, f Filter) (*Snapshot, error) {
+	snap := &Snapshot{Time: time.Now(), Version: version.Version, StartedAt: s.StartedAt}
+	if s.Health != nil {
+		report := s.Health.Check()
+		snap.Health, snap.Components = report.Status, report.Components
+	}
+	if s.Gateway != nil {
+		snap.Queue = s.Gateway.Stats()
+	}
+	if s.SchedulerTick != nil {
+		snap.SchedulerTick = s.SchedulerTick()
+	}
+	if s.DB != nil {
+		turns, err := s.DB.JournaledTurns(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("turns: %w", err)
+		}
+		snap.Turns = turns
+		plans, err := s.DB.ListAllPlans(ctx, "active")
+		if err != nil {
+			return nil, fmt.Errorf("plans: %w", err)
+		}
+		snap.PlanCount = len(plans)
+		for _, p := range plans {
+			if p.NextRunAt != nil && len(snap.Plans) < maxPlans {
+				snap.Plans = append(snap.Plans, p)
+			}
+		}
+		calls, err := s.DB.ReadAuditLog(ctx, store.AuditFilter{Limit: maxToolCalls})
+		if err != nil {
+			return nil, fmt.Errorf("tool calls: %w", err)
+		}
+		snap.ToolCalls = calls
+	}
+	if s.Logs != nil {
+		logs, err := s.logs(f)
+		if err != nil {
+			return nil, fmt.Errorf("logs: %w", err)
+		}
+		snap.Logs = logs
+	}
+	return snap, nil
+}
+
+func (s *Source) logs(f Filter) ([]health.LogEntry, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > maxLogs {
+		limit = maxLogs
+	}
+	if f.Search == "" {
+		return s.Logs.GetLogs(f.Level, f.Component, limit)
+	}
+	entries, err := s.Logs.GetLogs(f.Level, f.Component, searchWindow)
+	if err != nil {
+		return nil, err
+	}
+	search := strings.ToLower(f.Search)
+	var out []health.LogEntry
+	for _, e := range entries {
+		if strings.Contains(strings.ToLower(e.Message), search) {
+			out = append(out, e)
+			if len(out) == limit {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// Handler serves GET /snapshot, with the filter as query parameters (level, component, search,
+// limit).
+func (s *Source) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := Filter{Level: q.Get("level"), Component: q.Get("component"), Search: q.Get("search")}
+		f.Limit, _ = strconv.Atoi(q.Get("limit"))
+		snap, err := s.Snapshot(r.Context(), f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(snap)
+	})
+	return mux
+}
+
+// Listen serves the monitor on a Unix socket at path until ctx is done. A socket file left by a
+// previous run is replaced, but not one another running bot still answers on.
+func Listen(ctx context.Context, path string, s *Source) error {
+	if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		c.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	_ = os.Remove(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return err
+	}
+	srv := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	err = srv.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Fetch gets a snapshot from the monitor socket at path.
+func Fetch(ctx context.Context, path string, f Filter) (*Snapshot, error) {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}},
+	}
+	q := url.Values{}
+	for k, v := range map[string]string{"level": f.Level, "component": f.Component, "search": f.Search} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://monitor/snapshot?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("monitor: HTTP %d", resp.StatusCode)
+	}
+	var snap Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
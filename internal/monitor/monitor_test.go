@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestSnapshotOverSocket(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	id, err := db.StartTurn(ctx, "alice", "talk", "room", "check the weather")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.JournalToolCalls(ctx, id, []store.JournalToolCall{{ID: "c1", Name: "web_search", Args: `{"q":"weather"}`}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePlan(ctx, "alice", "morning briefing", "remind", "{}", "daily", "08:00", "", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AppendAuditEntry(ctx, store.AuditEntry{UserID: "alice", Tool: "read_file", Outcome: "ok", DurationMS: 12}); err != nil {
+		t.Fatal(err)
+	}
+	logs := store.NewLogStore(db.DB)
+	logs.LogInfo("agent", "turn started")
+	logs.LogError("llm", "provider timed out")
+	logs.LogWarn("llm", "retrying after rate limit")
+
+	// Unix socket paths are short; t.TempDir may be too long
+	dir, err := os.MkdirTemp("", "mon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "monitor.sock")
+	src := &Source{DB: db, Logs: logs, StartedAt: time.Now().Add(-time.Minute)}
+	served := make(chan error, 1)
+	go func() { served <- Listen(ctx, path, src) }()
+	var snap *Snapshot
+	for i := 0; ; i++ {
+		if snap, err = Fetch(ctx, path, Filter{Component: "llm"}); err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode = %v, %v", info.Mode().Perm(), err)
+	}
+	if len(snap.Turns) != 1 || snap.Turns[0].State != store.TurnTools || len(snap.Turns[0].ToolCalls) != 1 {
+		t.Errorf("turns = %+v", snap.Turns)
+	}
+	if snap.PlanCount != 1 || len(snap.Plans) != 1 {
+		t.Errorf("plans = %d %+v", snap.PlanCount, snap.Plans)
+	}
+	if len(snap.ToolCalls) != 1 || snap.ToolCalls[0].Tool != "read_file" {
+		t.Errorf("tool calls = %+v", snap.ToolCalls)
+	}
+	if len(snap.Logs) != 2 {
+		t.Errorf("llm logs = %+v", snap.Logs)
+	}
+
+	if err := Listen(ctx, path, src); err == nil {
+		t.Error("a second Listen took over a live socket")
+	}
+	snap, err = Fetch(ctx, path, Filter{Search: "RATE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Logs) != 1 || snap.Logs[0].Level != "warn" {
+		t.Errorf("search logs = %+v", snap.Logs)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("Listen = %v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	next := now.Add(90 * time.Second)
+	snap := &Snapshot{
+		Time: now, Version: "1.2.3", StartedAt: now.Add(-time.Hour), Health: "ok",
+		Turns: []store.JournaledTurn{{ID: 7, UserID: "alice", Channel: "talk", State: "tools", Content: "check\nthe weather", StartedAt: now.Add(-5 * time.Second),
+			ToolCalls: []store.JournalToolCall{{Name: "web_search", Done: true}, {Name: "read_file", Failed: true}}}},
+		Plans:     []store.ScheduledPlan{{ID: 3, UserID: "alice", Description: "morning briefing", NextRunAt: &next}},
+		PlanCount: 1,
+	}
+	snap.Queue.Running = []string{"talk:room"}
+	snap.Queue.Waiting = map[string]int{"talk:room": 2}
+	var b bytes.Buffer
+	Render(&b, snap, Filter{Level: "error"}, 100)
+	out := b.String()
+	for _, want := range []string{"HattieBot 1.2.3", "up 1h0m0s", "1 running, 2 waiting", `#7`, `"check the weather"`, "✓ web_search", "✗ read_file", "in 1m30s", "Logs (level=error)", "no entries"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	for _, l := range strings.Split(out, "\n") {
+		if n := len([]rune(l)); n > 100 {
+			t.Errorf("line is %d wide: %s", n, l)
+		}
+	}
+}
+
+func TestApplyCommand(t *testing.T) {
+	f := Filter{Limit: 30}
+	steps := []struct {
+		cmd  string
+		want Filter
+	}{
+		{"l error", Filter{Level: "error", Limit: 30}},
+		{"c llm", Filter{Level: "error", Component: "llm", Limit: 30}},
+		{"/time out", Filter{Level: "error", Component: "llm", Search: "time out", Limit: 30}},
+		{"l", Filter{Component: "llm", Search: "time out", Limit: 30}},
+		{"", Filter{Limit: 30}},
+	}
+	for _, s := range steps {
+		var quit bool
+		if f, quit = applyCommand(f, s.cmd); quit || f != s.want {
+			t.Errorf("%q: %+v, quit %v; want %+v", s.cmd, f, quit, s.want)
+		}
+	}
+	if _, quit := applyCommand(f, "q"); !quit {
+		t.Error("q did not quit")
+	}
+}
@@ -0,0 +1,216 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// clearScreen moves the cursor home and clears the terminal before each redraw.
+const clearScreen = "\033[H\033[2J"
+
+// Render draws snap as text width columns wide: a header, the queue, turns in flight, components,
+// the next scheduled plans, recent tool calls and the logs f selects.
+func Render(w io.Writer, snap *Snapshot, f Filter, width int) {
+	if width < 40 {
+		width = 40
+	}
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintln(w, clip(fmt.Sprintf(format, args...), width))
+	}
+	section := func(title string) {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, clip("── "+title+" "+strings.Repeat("─", width), width))
+	}
+	now := snap.Time
+
+	health := snap.Health
+	if health == "" {
+		health = "unknown"
+	}
+	line("HattieBot %s  up %s  health %s  %s", snap.Version, since(snap.StartedAt, now), strings.ToUpper(health), now.Local().Format("15:04:05"))
+	waiting := 0
+	for _, n := range snap.Queue.Waiting {
+		waiting += n
+	}
+	line("Queue: %d incoming, %d running, %d waiting", snap.Queue.Ingress, len(snap.Queue.Running), waiting)
+
+	section(fmt.Sprintf("Turns (%d)", len(snap.Turns)))
+	if len(snap.Turns) == 0 {
+		line("  idle")
+	}
+	for _, t := range snap.Turns {
+		line("  #%-5d %-8s %-6s %-16s %s  %q", t.ID, t.State, since(t.StartedAt, now), t.UserID, t.Channel, oneLine(t.Content))
+		for _, c := range t.ToolCalls {
+			mark := "…"
+			switch {
+			case c.Failed:
+				mark = "✗"
+			case c.Done:
+				mark = "✓"
+			}
+			line("           %s %s %s", mark, c.Name, oneLine(c.Args))
+		}
+	}
+
+	if len(snap.Components) > 0 {
+		section("Components")
+		names := make([]string, 0, len(snap.Components))
+		for name := range snap.Components {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			c := snap.Components[name]
+			line("  %-20s %-9s %s", name, c.Status, oneLine(c.Message))
+		}
+	}
+
+	section(fmt.Sprintf("Scheduler (%d active plans)", snap.PlanCount))
+	if !snap.SchedulerTick.IsZero() {
+		line("  last tick %s ago", since(snap.SchedulerTick, now))
+	}
+	for _, p := range snap.Plans {
+		line("  #%-4d in %-8s %-16s %s", p.ID, until(*p.NextRunAt, now), p.UserID, oneLine(p.Description))
+	}
+
+	section("Recent tool calls")
+	if len(snap.ToolCalls) == 0 {
+		line("  none")
+	}
+	for _, c := range snap.ToolCalls {
+		outcome := c.Outcome
+		if c.Error != "" {
+			outcome += ": " + oneLine(c.Error)
+		}
+		line("  %s %-28s %6dms %-16s %s", c.CreatedAt.Local().Format("15:04:05"), c.Tool, c.DurationMS, c.UserID, outcome)
+	}
+
+	var filters []string
+	if f.Level != "" {
+		filters = append(filters, "level="+f.Level)
+	}
+	if f.Component != "" {
+		filters = append(filters, "component="+f.Component)
+	}
+	if f.Search != "" {
+		filters = append(filters, fmt.Sprintf("search=%q", f.Search))
+	}
+	title := "Logs"
+	if len(filters) > 0 {
+		title += " (" + strings.Join(filters, ", ") + ")"
+	}
+	section(title)
+	if len(snap.Logs) == 0 {
+		line("  no entries")
+	}
+	// Oldest first, so the latest entry is at the bottom
+	for i := len(snap.Logs) - 1; i >= 0; i-- {
+		e := snap.Logs[i]
+		line("  %s %-5s %-10s %s", e.Timestamp.Local().Format("15:04:05"), strings.ToUpper(e.Level), e.Component, oneLine(e.Message))
+	}
+
+	fmt.Fprintln(w)
+	line("l <level>  c <component>  /<text> search  Enter clears filters  q quits")
+}
+
+// Run polls the monitor socket at path every interval and redraws out until ctx is done or the
+// user quits. Lines read from in change the log filter (see the footer of Render).
+func Run(ctx context.Context, path string, in io.Reader, out io.Writer, interval time.Duration, f Filter, width int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	filters := make(chan Filter, 1)
+	go func() {
+		defer cancel()
+		cur := f
+		sc := bufio.NewScanner(in)
+		for sc.Scan() {
+			next, quit := applyCommand(cur, sc.Text())
+			if quit {
+				return
+			}
+			cur = next
+			select {
+			case <-filters:
+			default:
+			}
+			filters <- cur
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		snap, err := Fetch(ctx, path, f)
+		if ctx.Err() != nil {
+			return nil
+		}
+		fmt.Fprint(out, clearScreen)
+		if err != nil {
+			fmt.Fprintf(out, "Cannot reach the bot at %s: %v\nRetrying every %s; q quits.\n", path, err, interval)
+		} else {
+			Render(out, snap, f, width)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case f = <-filters:
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyCommand applies one input line to f: "l <level>", "c <component>", "/<text>", an empty
+// line to clear the filters, or "q" to quit.
+func applyCommand(f Filter, cmd string) (Filter, bool) {
+	cmd = strings.TrimSpace(cmd)
+	switch {
+	case cmd == "":
+		return Filter{Limit: f.Limit}, false
+	case cmd == "q" || cmd == "quit":
+		return f, true
+	case strings.HasPrefix(cmd, "/"):
+		f.Search = strings.TrimSpace(cmd[1:])
+	case cmd == "l" || strings.HasPrefix(cmd, "l "):
+		f.Level = strings.TrimSpace(strings.TrimPrefix(cmd, "l"))
+	case cmd == "c" || strings.HasPrefix(cmd, "c "):
+		f.Component = strings.TrimSpace(strings.TrimPrefix(cmd, "c"))
+	}
+	return f, false
+}
+
+// since formats the time from t to now, e.g. "3m12s"; "-" for a zero t.
+func since(t, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return now.Sub(t).Truncate(time.Second).String()
+}
+
+// until formats the time from now to t, "due" once it has passed.
+func until(t, now time.Time) string {
+	d := t.Sub(now).Truncate(time.Second)
+	if d <= 0 {
+		return "due"
+	}
+	return d.String()
+}
+
+// oneLine folds s onto one line.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// clip shortens s to width runes.
+func clip(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
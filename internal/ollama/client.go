@@ -0,0 +1,286 @@
+// Package ollama talks to a local Ollama server (https://ollama.com): chat, tool calls, embeddings
+// and model management, so HattieBot can run without any hosted provider.
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+)
+
+// DefaultBaseURL is where Ollama listens unless configured otherwise.
+const DefaultBaseURL = "http://localhost:11434"
+
+// Client calls Ollama's native API (/api/chat, /api/embed) for one model.
+type Client struct {
+	BaseURL string
+	Model   string
+	// Think is sent with every chat request when set: false turns thinking off, true turns it on
+	// for models that think. nil leaves it at the model's default.
+	Think *bool
+	HTTP  *http.Client
+
+	mu      sync.Mutex
+	noTools bool // the model rejected tools; chat without them from then on
+}
+
+// NewClient creates a client for model on the server at baseURL ("" = DefaultBaseURL). Local models
+// can be slow to load and answer, so requests may take up to ten minutes.
+func NewClient(baseURL, model string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Model:   model,
+		HTTP:    &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// ThinkFor maps a route's reasoning level ("off", "low", "medium", "high", "" for the model's
+// default) to the think flag.
+func ThinkFor(level string) *bool {
+	if level == "" {
+		return nil
+	}
+	on := level != "off"
+	return &on
+}
+
+type chatMessage struct {
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	Thinking  string         `json:"thinking,omitempty"`
+	ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
+	ToolName  string         `json:"tool_name,omitempty"` // for role "tool": which tool answered
+}
+
+type chatToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"` // an object, not a JSON string as in OpenAI's format
+	} `json:"function"`
+}
+
+type chatRequest struct {
+	Model    string                `json:"model"`
+	Messages []chatMessage         `json:"messages"`
+	Tools    []core.ToolDefinition `json:"tools,omitempty"`
+	Think    *bool                 `json:"think,omitempty"`
+	Stream   bool                  `json:"stream"`
+}
+
+type chatResponse struct {
+	Message chatMessage `json:"message"`
+	Error   string      `json:"error"`
+}
+
+// ChatCompletion implements core.LLMClient.
+func (c *Client) ChatCompletion(ctx context.Context, messages []core.Message) (string, error) {
+	msg, err := c.chat(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	return msg.Content, nil
+}
+
+// ChatCompletionWithTools implements core.LLMClient. Models without tool support answer in plain
+// text instead: Ollama rejects the tools, and the request is repeated without them.
+func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition) (string, []core.ToolCall, error) {
+	c.mu.Lock()
+	if c.noTools {
+		tools = nil
+	}
+	c.mu.Unlock()
+	msg, err := c.chat(ctx, messages, tools)
+	if err != nil && len(tools) > 0 && strings.Contains(err.Error(), "does not support tools") {
+		c.mu.Lock()
+		c.noTools = true
+		c.mu.Unlock()
+		msg, err = c.chat(ctx, messages, nil)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	var calls []core.ToolCall
+	for i, tc := range msg.ToolCalls {
+		// Ollama has no call IDs; tool results are matched back by tool name (see toChat)
+		var call core.ToolCall
+		call.ID = fmt.Sprintf("call_%d_%d", time.Now().UnixNano(), i)
+		call.Type = "function"
+		call.Function.Name = tc.Function.Name
+		call.Function.Arguments = string(tc.Function.Arguments)
+		if len(tc.Function.Arguments) == 0 || string(tc.Function.Arguments) == "null" {
+			call.Function.Arguments = "{}"
+		}
+		calls = append(calls, call)
+	}
+	return msg.Content, calls, nil
+}
+
+// chat posts one non-streaming /api/chat request and returns the reply message.
+func (c *Client) chat(ctx context.Context, messages []core.Message, tools []core.ToolDefinition) (*chatMessage, error) {
+	if c.Model == "" {
+		return nil, fmt.Errorf("ollama: model not set")
+	}
+	tools = stripPolicy(tools)
+	var out chatResponse
+	req := chatRequest{Model: c.Model, Messages: toChat(messages), Tools: tools, Think: c.Think}
+	if err := c.post(ctx, "/api/chat", req, &out); err != nil {
+		return nil, err
+	}
+	return &out.Message, nil
+}
+
+// stripPolicy copies tools without HattieBot's Policy field, which is not part of the schema.
+func stripPolicy(tools []core.ToolDefinition) []core.ToolDefinition {
+	if len(tools) == 0 {
+		return nil
+	}
+	out := make([]core.ToolDefinition, len(tools))
+	for i, t := range tools {
+		out[i] = core.ToolDefinition{Type: t.Type, Function: t.Function}
+	}
+	return out
+}
+
+// toChat converts messages to Ollama's format: tool call arguments become objects and tool results
+// carry the name of the tool they answer instead of a call ID.
+func toChat(messages []core.Message) []chatMessage {
+	names := map[string]string{} // tool call ID -> tool name
+	out := make([]chatMessage, 0, len(messages))
+	for _, m := range messages {
+		cm := chatMessage{Role: m.Role, Content: m.Content}
+		for _, tc := range m.ToolCalls {
+			names[tc.ID] = tc.Function.Name
+			var call chatToolCall
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = json.RawMessage(tc.Function.Arguments)
+			if !json.Valid(call.Function.Arguments) {
+				call.Function.Arguments = json.RawMessage("{}")
+			}
+			cm.ToolCalls = append(cm.ToolCalls, call)
+		}
+		if m.Role == "tool" {
+			cm.ToolName = names[m.ToolCallID]
+		}
+		out = append(out, cm)
+	}
+	return out
+}
+
+type embedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embedResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+// Embed implements core.LLMClient with the client's model, which must be an embedding model
+// (e.g. nomic-embed-text).
+func (c *Client) Embed(ctx context.Context, text string) ([]float32, error) {
+	out, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+// EmbedBatch implements core.LLMBatchEmbedder: /api/embed takes every text in one request.
+func (c *Client) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if c.Model == "" {
+		return nil, fmt.Errorf("ollama: model not set")
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	var out embedResponse
+	if err := c.post(ctx, "/api/embed", embedRequest{Model: c.Model, Input: texts}, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama: got %d embeddings for %d texts", len(out.Embeddings), len(texts))
+	}
+	vecs := make([][]float32, len(texts))
+	for i, v := range out.Embeddings {
+		vecs[i] = make([]float32, len(v))
+		for j, x := range v {
+			vecs[i][j] = float32(x)
+		}
+	}
+	return vecs, nil
+}
+
+// Embedder adapts a Client to core.EmbeddingClient, for embedding_routing.json providers of type
+// "ollama". Ollama does not distinguish queries from documents.
+type Embedder struct {
+	Client *Client
+}
+
+func (e Embedder) Embed(ctx context.Context, text, _ string) ([]float32, error) {
+	return e.Client.Embed(ctx, text)
+}
+
+func (e Embedder) EmbedBatch(ctx context.Context, texts []string, _ string) ([][]float32, error) {
+	return e.Client.EmbedBatch(ctx, texts)
+}
+
+// EmbeddingModel implements core.EmbeddingModeler.
+func (e Embedder) EmbeddingModel() string {
+	return "ollama:" + e.Client.Model
+}
+
+// post sends body as JSON to path and decodes the reply into out (when not nil).
+func (c *Client) post(ctx context.Context, path string, body, out interface{}) error {
+	return c.do(ctx, c.HTTP, http.MethodPost, path, body, out)
+}
+
+func (c *Client) do(ctx context.Context, hc *http.Client, method, path string, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return fmt.Errorf("ollama: HTTP %d: %s", resp.StatusCode, e.Error)
+		}
+		return fmt.Errorf("ollama: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("ollama: decode response: %w", err)
+	}
+	return nil
+}
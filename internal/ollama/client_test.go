@@ -0,0 +1,147 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+)
+
+func TestChatWithTools(t *testing.T) {
+	var got chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_time","arguments":{"zone":"UTC"}}}]},"done":true}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "llama3.1")
+	c.Think = ThinkFor("off")
+	var call core.ToolCall
+	call.ID, call.Type = "call_1", "function"
+	call.Function.Name, call.Function.Arguments = "read_file", `{"path":"a.txt"}`
+	history := []core.Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", ToolCalls: []core.ToolCall{call}},
+		{Role: "tool", ToolCallID: "call_1", Content: "contents"},
+	}
+	tools := []core.ToolDefinition{{Type: "function", Function: core.FunctionSpec{Name: "get_time"}, Policy: "safe"}}
+	_, calls, err := c.ChatCompletionWithTools(context.Background(), history, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0].Function.Name != "get_time" || calls[0].Function.Arguments != `{"zone":"UTC"}` || calls[0].ID == "" {
+		t.Fatalf("calls = %+v", calls)
+	}
+	if got.Model != "llama3.1" || got.Stream || got.Think == nil || *got.Think {
+		t.Errorf("request = %+v", got)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Policy != "" {
+		t.Errorf("tools = %+v", got.Tools)
+	}
+	if args := string(got.Messages[1].ToolCalls[0].Function.Arguments); args != `{"path":"a.txt"}` {
+		t.Errorf("tool call arguments sent as %s, want an object", args)
+	}
+	if got.Messages[2].ToolName != "read_file" {
+		t.Errorf("tool result names %q, want read_file", got.Messages[2].ToolName)
+	}
+}
+
+func TestChatWithoutToolSupport(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req chatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Tools) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"registry.ollama.ai/library/gemma:2b does not support tools"}`))
+			return
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","content":"plain answer"}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "gemma:2b")
+	tools := []core.ToolDefinition{{Type: "function", Function: core.FunctionSpec{Name: "get_time"}}}
+	for i := 0; i < 2; i++ {
+		content, calls, err := c.ChatCompletionWithTools(context.Background(), []core.Message{{Role: "user", Content: "hi"}}, tools)
+		if err != nil || content != "plain answer" || len(calls) != 0 {
+			t.Fatalf("got %q %v %v", content, calls, err)
+		}
+	}
+	// The second turn knows the model has no tools and sends none
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3", requests)
+	}
+}
+
+func TestEmbedBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embedRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		out := embedResponse{}
+		for i := range req.Input {
+			out.Embeddings = append(out.Embeddings, []float64{float64(i), 1})
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	defer srv.Close()
+
+	e := Embedder{Client: NewClient(srv.URL, "nomic-embed-text")}
+	vecs, err := e.EmbedBatch(context.Background(), []string{"a", "b", "c"}, "document")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != 3 || vecs[2][0] != 2 {
+		t.Errorf("vecs = %v", vecs)
+	}
+	if m := core.EmbeddingModel(e); m != "ollama:nomic-embed-text" {
+		t.Errorf("model = %q", m)
+	}
+}
+
+func TestModelManagement(t *testing.T) {
+	var deleted string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/tags":
+			w.Write([]byte(`{"models":[{"name":"llama3.1:8b","size":4900000000,"details":{"family":"llama","parameter_size":"8.0B","quantization_level":"Q4_K_M"}}]}`))
+		case "POST /api/show":
+			w.Write([]byte(`{"capabilities":["completion","tools"],"details":{"family":"llama"},"model_info":{"llama.context_length":131072}}`))
+		case "DELETE /api/delete":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			deleted = req["model"]
+		case "POST /api/pull":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"pull model manifest: file does not exist"}`))
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewClient(srv.URL, "")
+	models, err := c.ListModels(ctx)
+	if err != nil || len(models) != 1 || models[0].ParameterSize != "8.0B" || models[0].Quantization != "Q4_K_M" {
+		t.Fatalf("models = %+v, %v", models, err)
+	}
+	info, err := c.ShowModel(ctx, "llama3.1:8b")
+	if err != nil || info.ContextLength != 131072 || len(info.Capabilities) != 2 {
+		t.Fatalf("info = %+v, %v", info, err)
+	}
+	if err := c.DeleteModel(ctx, "llama3.1:8b"); err != nil || deleted != "llama3.1:8b" {
+		t.Fatalf("delete: %v, deleted %q", err, deleted)
+	}
+	if err := c.PullModel(ctx, "nope"); err == nil || err.Error() != "ollama: HTTP 500: pull model manifest: file does not exist" {
+		t.Errorf("pull error = %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package ollama
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Model is a model installed on the Ollama server.
+type Model struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"` // bytes on disk
+	ModifiedAt    time.Time `json:"modified_at"`
+	Family        string    `json:"family,omitempty"`
+	ParameterSize string    `json:"parameter_size,omitempty"` // e.g. "8.0B"
+	Quantization  string    `json:"quantization,omitempty"`   // e.g. "Q4_K_M"
+}
+
+// ModelInfo describes one model; Capabilities tells chat models ("completion"), models that can
+// call tools ("tools") and embedding models ("embedding") apart.
+type ModelInfo struct {
+	Name          string   `json:"name"`
+	Capabilities  []string `json:"capabilities,omitempty"`
+	Family        string   `json:"family,omitempty"`
+	ParameterSize string   `json:"parameter_size,omitempty"`
+	Quantization  string   `json:"quantization,omitempty"`
+	// ContextLength is the longest context the model supports (0 = unknown).
+	ContextLength int `json:"context_length,omitempty"`
+}
+
+type modelDetails struct {
+	Family            string `json:"family"`
+	ParameterSize     string `json:"parameter_size"`
+	QuantizationLevel string `json:"quantization_level"`
+}
+
+// ListModels returns the models installed on the server (GET /api/tags).
+func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
+	var out struct {
+		Models []struct {
+			Name       string       `json:"name"`
+			Size       int64        `json:"size"`
+			ModifiedAt time.Time    `json:"modified_at"`
+			Details    modelDetails `json:"details"`
+		} `json:"models"`
+	}
+	if err := c.do(ctx, c.HTTP, http.MethodGet, "/api/tags", nil, &out); err != nil {
+		return nil, err
+	}
+	models := make([]Model, 0, len(out.Models))
+	for _, m := range out.Models {
+		models = append(models, Model{
+			Name:          m.Name,
+			Size:          m.Size,
+			ModifiedAt:    m.ModifiedAt,
+			Family:        m.Details.Family,
+			ParameterSize: m.Details.ParameterSize,
+			Quantization:  m.Details.QuantizationLevel,
+		})
+	}
+	return models, nil
+}
+
+// ShowModel describes an installed model (POST /api/show).
+func (c *Client) ShowModel(ctx context.Context, name string) (*ModelInfo, error) {
+	var out struct {
+		Capabilities []string               `json:"capabilities"`
+		Details      modelDetails           `json:"details"`
+		ModelInfo    map[string]interface{} `json:"model_info"`
+	}
+	if err := c.post(ctx, "/api/show", map[string]string{"model": name}, &out); err != nil {
+		return nil, err
+	}
+	info := &ModelInfo{
+		Name:          name,
+		Capabilities:  out.Capabilities,
+		Family:        out.Details.Family,
+		ParameterSize: out.Details.ParameterSize,
+		Quantization:  out.Details.QuantizationLevel,
+	}
+	// The key is prefixed with the architecture, e.g. "llama.context_length"
+	for k, v := range out.ModelInfo {
+		if n, ok := v.(float64); ok && strings.HasSuffix(k, ".context_length") {
+			info.ContextLength = int(n)
+		}
+	}
+	return info, nil
+}
+
+// PullModel downloads name from the Ollama library (POST /api/pull) and returns once it is
+// installed. Downloads can take long, so only ctx bounds the request.
+func (c *Client) PullModel(ctx context.Context, name string) error {
+	var out struct {
+		Status string `json:"status"`
+	}
+	return c.do(ctx, &http.Client{}, http.MethodPost, "/api/pull", map[string]interface{}{"model": name, "stream": false}, &out)
+}
+
+// DeleteModel removes an installed model (DELETE /api/delete).
+func (c *Client) DeleteModel(ctx context.Context, name string) error {
+	return c.do(ctx, c.HTTP, http.MethodDelete, "/api/delete", map[string]string{"model": name}, nil)
+}
@@ -0,0 +1,116 @@
+// Package onboarding keeps the post-install setup checklist up to date by detecting which steps
+// are already satisfied. Detected items are persisted as done and never revert.
+package onboarding
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// Status summarizes the checklist for system_status.
+type Status struct {
+	Complete bool                   `json:"complete"`
+	Done     int                    `json:"done"`
+	Total    int                    `json:"total"`
+	Items    []store.OnboardingItem `json:"items"`
+}
+
+// consoleChannels don't count as a connected chat channel.
+var consoleChannels = map[string]bool{"": true, "admin_term": true, "import": true}
+
+// Refresh marks items whose conditions now hold as done and returns the checklist.
+// channelNames are the gateway's registered channels (nil when unknown).
+func Refresh(ctx context.Context, db *store.DB, cfg *config.Config, channelNames []string) ([]store.OnboardingItem, error) {
+	items, err := db.OnboardingChecklist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changed := false
+	for _, item := range items {
+		if item.Done || !detect(ctx, db, cfg, channelNames, item.Key) {
+			continue
+		}
+		if err := db.CompleteOnboardingItem(ctx, item.Key, "detected automatically"); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+	if !changed {
+		return items, nil
+	}
+	return db.OnboardingChecklist(ctx)
+}
+
+// Summarize counts dismissed items as settled.
+func Summarize(items []store.OnboardingItem) Status {
+	st := Status{Total: len(items), Items: items}
+	for _, item := range items {
+		if item.Done || item.Dismissed {
+			st.Done++
+		}
+	}
+	st.Complete = st.Done == st.Total
+	return st
+}
+
+// Pending returns items that are neither done nor dismissed.
+func Pending(items []store.OnboardingItem) []store.OnboardingItem {
+	var out []store.OnboardingItem
+	for _, item := range items {
+		if !item.Done && !item.Dismissed {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func detect(ctx context.Context, db *store.DB, cfg *config.Config, channelNames []string, key string) bool {
+	switch key {
+	case "channel_connected":
+		for _, name := range channelNames {
+			if !consoleChannels[name] {
+				return true
+			}
+		}
+		return exists(ctx, db, `SELECT 1 FROM messages WHERE channel NOT IN ('', 'admin_term', 'import') LIMIT 1`)
+	case "embedding_configured":
+		if cfg != nil && cfg.EmbeddingServiceURL != "" {
+			return true
+		}
+		if cfg != nil {
+			routing, _ := store.LoadEmbeddingRouting(cfg.ConfigDir)
+			return routing.HasDefaultProvider()
+		}
+		return false
+	case "backups_enabled":
+		return exists(ctx, db, `SELECT 1 FROM scheduled_plans WHERE status = 'active' AND schedule_type != 'once' AND LOWER(description) LIKE '%backup%' LIMIT 1`)
+	case "admin_approved":
+		return exists(ctx, db, `SELECT 1 FROM users WHERE role = ? LIMIT 1`, store.RoleOwner)
+	case "first_tool_built":
+		return exists(ctx, db, `SELECT 1 FROM tools_registry LIMIT 1`)
+	}
+	return false
+}
+
+func exists(ctx context.Context, db *store.DB, query string, args ...interface{}) bool {
+	var one int
+	return db.QueryRowContext(ctx, query, args...).Scan(&one) == nil
+}
+
+// PromptBlock renders pending items for the system prompt, or "" when setup is settled.
+func PromptBlock(items []store.OnboardingItem) string {
+	pending := Pending(items)
+	if len(pending) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\n== SETUP CHECKLIST (incomplete) ==\n")
+	for _, item := range pending {
+		sb.WriteString("- " + item.Title + " (" + item.Key + "): " + item.Hint + "\n")
+	}
+	sb.WriteString("[ACTION]: When it fits the conversation, suggest the first pending step (at most one per conversation; don't interrupt urgent requests). Mark steps done or dismissed with manage_onboarding.\n===============================\n")
+	return sb.String()
+}
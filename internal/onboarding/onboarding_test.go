@@ -0,0 +1,67 @@
+package onboarding
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestRefreshDetectsAndPersistsItems(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cfg := &config.Config{ConfigDir: t.TempDir(), EmbeddingServiceURL: "http://embed:8080"}
+
+	items, err := Refresh(ctx, db, cfg, []string{"admin_term"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := Summarize(items); st.Done != 1 || st.Total != len(store.OnboardingItems) || st.Complete {
+		t.Fatalf("fresh install: %+v", st)
+	}
+
+	if _, err := db.InsertTool(ctx, "weather", "/bin/weather", "", "{}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetOrCreateUser(ctx, "alice", "Alice", "nextcloud_talk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateUserRole(ctx, "alice", store.RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetOnboardingItemDismissed(ctx, "backups_enabled", true); err != nil {
+		t.Fatal(err)
+	}
+	items, err = Refresh(ctx, db, cfg, []string{"admin_term", "nextcloud_talk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := Summarize(items); !st.Complete {
+		t.Fatalf("expected complete checklist, got %+v", st)
+	}
+	if PromptBlock(items) != "" {
+		t.Error("complete checklist should not be injected into the prompt")
+	}
+
+	// Detected items stay done even if the condition no longer holds.
+	if _, err := db.ExecContext(ctx, `DELETE FROM tools_registry`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetOnboardingItemDismissed(ctx, "backups_enabled", false); err != nil {
+		t.Fatal(err)
+	}
+	items, _ = Refresh(ctx, db, nil, nil)
+	pending := Pending(items)
+	if len(pending) != 1 || pending[0].Key != "backups_enabled" {
+		t.Fatalf("unexpected pending items: %+v", pending)
+	}
+	if block := PromptBlock(items); !strings.Contains(block, "backups_enabled") || strings.Contains(block, "first_tool_built") {
+		t.Errorf("unexpected prompt block: %s", block)
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/hattiebot/hattiebot/internal/core"
 	"github.com/hattiebot/hattiebot/internal/registry"
+	"github.com/hattiebot/hattiebot/internal/tracing"
 )
 
 func init() {
@@ -20,7 +21,8 @@ func init() {
 	})
 }
 
-const BaseURL = "https://openrouter.ai/api/v1"
+// BaseURL is the OpenRouter API endpoint. main overrides it from OPENROUTER_BASE_URL (e.g. a mock LLM in the e2e harness).
+var BaseURL = "https://openrouter.ai/api/v1"
 
 // parseContent parses API content that may be string, null, or array of parts (e.g. [{"type":"text","text":"..."}]).
 func parseContent(raw json.RawMessage) string {
@@ -69,8 +71,39 @@ type Message = core.Message
 
 // ChatRequest is the request body for chat completions.
 type ChatRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model    string        `json:"model"`
+	Messages []Message     `json:"messages"`
+	Usage    *usageRequest `json:"usage,omitempty"`
+}
+
+// usageRequest asks OpenRouter to include cost in the response usage block.
+type usageRequest struct {
+	Include bool `json:"include"`
+}
+
+// usageResponse is the usage block returned by OpenRouter (cost is in credits, i.e. USD).
+type usageResponse struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// recordUsage forwards the response usage block to the recorder on ctx.
+func (c *Client) recordUsage(ctx context.Context, u *usageResponse) {
+	if u == nil {
+		return
+	}
+	tracing.FromContext(ctx).
+		Set("llm.model", c.Model).
+		Set("llm.prompt_tokens", u.PromptTokens).
+		Set("llm.completion_tokens", u.CompletionTokens).
+		Set("llm.cost_usd", u.Cost)
+	core.RecordUsage(ctx, core.Usage{
+		Model:            c.Model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		CostUSD:          u.Cost,
+	})
 }
 
 // ChatResponse is the response from chat completions.
@@ -81,6 +114,7 @@ type ChatResponse struct {
 			Role    string          `json:"role"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *usageResponse `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
@@ -92,6 +126,31 @@ type Client struct {
 	Model     string
 	HTTP      *http.Client
 	ConfigDir string // optional: when set, provider failures are persisted and consulted for time-limited provider.ignore
+	// Reasoning is sent with every completion; nil leaves thinking at the model's default.
+	Reasoning *Reasoning
+}
+
+// Reasoning is OpenRouter's unified reasoning request field, mapped by OpenRouter to each
+// model's own setting (reasoning effort, thinking budget, enable_thinking).
+type Reasoning struct {
+	Effort    string `json:"effort,omitempty"`     // "low", "medium" or "high"
+	MaxTokens int    `json:"max_tokens,omitempty"` // thinking budget; takes the place of Effort
+	Enabled   *bool  `json:"enabled,omitempty"`    // false turns thinking off
+}
+
+// ReasoningFor maps a route's reasoning level ("off", "low", "medium", "high", "" for the
+// model's default) and token cap to the request field; nil means send nothing.
+func ReasoningFor(level string, maxTokens int) *Reasoning {
+	switch {
+	case level == "off":
+		off := false
+		return &Reasoning{Enabled: &off}
+	case maxTokens > 0:
+		return &Reasoning{MaxTokens: maxTokens}
+	case level != "":
+		return &Reasoning{Effort: level}
+	}
+	return nil
 }
 
 // NewClient creates a client with the given API key, model, and optional config dir for provider-failure tracking.
@@ -113,7 +172,8 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []Message) (string
 	if c.Model == "" {
 		return "", fmt.Errorf("openrouter: model not set")
 	}
-	body := ChatRequest{Model: c.Model, Messages: messages}
+	body := ChatRequestWithTools{Model: c.Model, Messages: messages, Reasoning: c.Reasoning, Usage: &usageRequest{Include: true}}
+	body.withResponseFormat(ctx)
 	raw, err := json.Marshal(body)
 	if err != nil {
 		return "", err
@@ -134,6 +194,7 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []Message) (string
 
 	for i := 0; i <= maxRetries; i++ {
 		if i > 0 {
+			tracing.FromContext(ctx).Set("llm.retries", i)
 			time.Sleep(backoff)
 			backoff *= 2
 		}
@@ -167,6 +228,7 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []Message) (string
 	if out.Error != nil {
 		return "", fmt.Errorf("openrouter: %s", out.Error.Message)
 	}
+	c.recordUsage(ctx, out.Usage)
 	if len(out.Choices) == 0 {
 		return "", fmt.Errorf("openrouter: no choices in response")
 	}
@@ -191,13 +253,14 @@ func (c *Client) RewriteAsSystemPurpose(ctx context.Context, userDescription str
 
 // EmbeddingRequest is the request body for embeddings.
 type EmbeddingRequest struct {
-	Model string `json:"model"`
-	Input string `json:"input"`
+	Model string      `json:"model"`
+	Input interface{} `json:"input"` // string or []string
 }
 
 // EmbeddingResponse is the response from embeddings.
 type EmbeddingResponse struct {
 	Data []struct {
+		Index     int       `json:"index"`
 		Embedding []float32 `json:"embedding"`
 	} `json:"data"`
 	Error *struct {
@@ -207,6 +270,24 @@ type EmbeddingResponse struct {
 
 // Embed generates embeddings for the given text using text-embedding-3-small.
 func (c *Client) Embed(ctx context.Context, text string) ([]float32, error) {
+	out, err := c.embed(ctx, text, 1)
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+// EmbedBatch embeds several texts in one request (core.LLMBatchEmbedder).
+func (c *Client) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	return c.embed(ctx, texts, len(texts))
+}
+
+// embed posts input (a string or []string of n texts) to the embeddings endpoint and returns
+// the n vectors in input order.
+func (c *Client) embed(ctx context.Context, input interface{}, n int) ([][]float32, error) {
 	if c.APIKey == "" {
 		return nil, fmt.Errorf("openrouter: API key not set")
 	}
@@ -214,14 +295,14 @@ func (c *Client) Embed(ctx context.Context, text string) ([]float32, error) {
 	model := "text-embedding-3-small"
 	body := EmbeddingRequest{
 		Model: model,
-		Input: text,
+		Input: input,
 	}
 	raw, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
 	
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://openrouter.ai/api/v1/embeddings", bytes.NewReader(raw))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, BaseURL+"/embeddings", bytes.NewReader(raw))
 	if err != nil {
 		return nil, err
 	}
@@ -248,8 +329,17 @@ func (c *Client) Embed(ctx context.Context, text string) ([]float32, error) {
 	if out.Error != nil {
 		return nil, fmt.Errorf("api error: %s", out.Error.Message)
 	}
-	if len(out.Data) == 0 {
-		return nil, fmt.Errorf("no embedding data")
+	if len(out.Data) != n {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(out.Data), n)
+	}
+	vecs := make([][]float32, n)
+	for i, d := range out.Data {
+		// index orders the results; a response without it lists them in input order
+		idx := d.Index
+		if idx < 0 || idx >= n || vecs[idx] != nil {
+			idx = i
+		}
+		vecs[idx] = d.Embedding
 	}
-	return out.Data[0].Embedding, nil
+	return vecs, nil
 }
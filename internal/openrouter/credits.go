@@ -0,0 +1,102 @@
+package openrouter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// KeyInfo is the API key's usage and spending limit (GET /key). Limit and LimitRemaining are nil
+// for keys without a limit.
+type KeyInfo struct {
+	Label          string   `json:"label"`
+	Usage          float64  `json:"usage"`
+	Limit          *float64 `json:"limit"`
+	LimitRemaining *float64 `json:"limit_remaining"`
+	IsFreeTier     bool     `json:"is_free_tier"`
+}
+
+// AccountCredits is the account's purchased credits and total spend (GET /credits), in USD.
+type AccountCredits struct {
+	TotalCredits float64 `json:"total_credits"`
+	TotalUsage   float64 `json:"total_usage"`
+}
+
+// ModelPrice is a model's price in USD per token.
+type ModelPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// KeyInfo returns the usage and limit of the client's API key.
+func (c *Client) KeyInfo(ctx context.Context) (*KeyInfo, error) {
+	var out struct {
+		Data KeyInfo `json:"data"`
+	}
+	if err := c.getJSON(ctx, "/key", &out); err != nil {
+		return nil, err
+	}
+	return &out.Data, nil
+}
+
+// Credits returns the account's credit balance.
+func (c *Client) Credits(ctx context.Context) (*AccountCredits, error) {
+	var out struct {
+		Data AccountCredits `json:"data"`
+	}
+	if err := c.getJSON(ctx, "/credits", &out); err != nil {
+		return nil, err
+	}
+	return &out.Data, nil
+}
+
+// ModelPricing returns per-token prices by model ID. Prices the API reports as strings that do
+// not parse are left at 0.
+func (c *Client) ModelPricing(ctx context.Context) (map[string]ModelPrice, error) {
+	var out struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Pricing struct {
+				Prompt     string `json:"prompt"`
+				Completion string `json:"completion"`
+			} `json:"pricing"`
+		} `json:"data"`
+	}
+	if err := c.getJSON(ctx, "/models", &out); err != nil {
+		return nil, err
+	}
+	prices := make(map[string]ModelPrice, len(out.Data))
+	for _, m := range out.Data {
+		prompt, _ := strconv.ParseFloat(m.Pricing.Prompt, 64)
+		completion, _ := strconv.ParseFloat(m.Pricing.Completion, 64)
+		prices[m.ID] = ModelPrice{Prompt: prompt, Completion: completion}
+	}
+	return prices, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, v interface{}) error {
+	if c.APIKey == "" {
+		return fmt.Errorf("openrouter: API key not set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("openrouter: GET %s: HTTP %d: %s", path, resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("openrouter: GET %s: decode: %w", path, err)
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/tracing"
 )
 
 // ToolDefinition is a function tool for the API (OpenAI-compatible).
@@ -36,8 +37,29 @@ type ChatRequestWithTools struct {
 	Messages            []Message              `json:"messages"`
 	Tools               []apiToolDefinition   `json:"tools,omitempty"`
 	ToolChoice          interface{}           `json:"tool_choice,omitempty"` // "auto" or object
-	ProviderParameters map[string]interface{} `json:"provider_parameters,omitempty"` // e.g. enable_thinking: false
-	Provider           *struct{ Ignore []string `json:"ignore,omitempty"` } `json:"provider,omitempty"` // skip provider that returned the error
+	Reasoning          *Reasoning             `json:"reasoning,omitempty"`
+	Provider           *providerPreferences   `json:"provider,omitempty"`
+	ResponseFormat     *core.ResponseFormat   `json:"response_format,omitempty"`
+	Usage              *usageRequest          `json:"usage,omitempty"`
+}
+
+// providerPreferences steers OpenRouter's provider routing.
+type providerPreferences struct {
+	Ignore []string `json:"ignore,omitempty"` // skip providers that returned an error
+	// RequireParameters routes only to providers that support every request parameter, so a
+	// response_format is not silently dropped.
+	RequireParameters bool `json:"require_parameters,omitempty"`
+}
+
+// withResponseFormat sets the response format requested on ctx, if any.
+func (r *ChatRequestWithTools) withResponseFormat(ctx context.Context) {
+	if rf := core.ResponseFormatFrom(ctx); rf != nil {
+		r.ResponseFormat = rf
+		if r.Provider == nil {
+			r.Provider = &providerPreferences{}
+		}
+		r.Provider.RequireParameters = true
+	}
 }
 
 // openRouterErrorBody is the shape of a 400 response from OpenRouter (error.metadata.provider_name).
@@ -79,6 +101,7 @@ type ChatResponseWithTools struct {
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *usageResponse `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
@@ -111,18 +134,21 @@ func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []Message
 		}
 	}
 
-	// Retry logic with exponential backoff; on "reasoning_content" 400 we retry with thinking disabled, then skip the provider from the error response.
+	// Retry logic with exponential backoff; on "reasoning_content" 400 we retry with thinking disabled
+	// (unless the route chose a reasoning setting), then skip the provider from the error response.
 	maxRetries := 3
 	backoff := 1 * time.Second
 	var resp *http.Response
 	var lastErr error
 	var bodyBytes []byte
-	disableThinking := false
+	// A route with its own reasoning setting keeps it; only a model left at its default is retried without thinking
+	disableThinking := c.Reasoning != nil
 	var ignoreProviderSlug string
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
 			log.Printf("[OPENROUTER] Retry %d/%d after %v...", attempt, maxRetries, backoff)
+			tracing.FromContext(ctx).Set("llm.retries", attempt)
 			time.Sleep(backoff)
 			backoff *= 2
 		}
@@ -132,13 +158,15 @@ func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []Message
 			Messages:   messages,
 			Tools:      apiTools,
 			ToolChoice: nil,
+			Reasoning:  c.Reasoning,
+			Usage:      &usageRequest{Include: true},
 		}
 		if len(tools) > 0 {
 			body.ToolChoice = "auto"
 		}
-		if disableThinking {
-			body.ProviderParameters = map[string]interface{}{"enable_thinking": false}
-			log.Printf("[OPENROUTER] Retrying with enable_thinking=false")
+		if disableThinking && c.Reasoning == nil {
+			body.Reasoning = ReasoningFor("off", 0)
+			log.Printf("[OPENROUTER] Retrying with reasoning disabled")
 		}
 		// Merge time-limited blocked providers with current retry's ignore (if any).
 		ignoreList := make([]string, 0, len(blockedSlugs)+1)
@@ -154,11 +182,12 @@ func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []Message
 			seen[ignoreProviderSlug] = true
 		}
 		if len(ignoreList) > 0 {
-			body.Provider = &struct{ Ignore []string `json:"ignore,omitempty"` }{Ignore: ignoreList}
+			body.Provider = &providerPreferences{Ignore: ignoreList}
 			if ignoreProviderSlug != "" {
 				log.Printf("[OPENROUTER] Retrying with provider.ignore=%s", ignoreProviderSlug)
 			}
 		}
+		body.withResponseFormat(ctx)
 		raw, err := json.Marshal(body)
 		if err != nil {
 			return "", nil, err
@@ -186,14 +215,14 @@ func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []Message
 			log.Printf("[OPENROUTER] Retryable error: HTTP %d", resp.StatusCode)
 			continue
 		}
-		// Retry with enable_thinking=false, then provider.ignore=<provider from error> on provider validation 400.
+		// Retry with reasoning disabled, then provider.ignore=<provider from error> on provider validation 400.
 		if resp.StatusCode == http.StatusBadRequest && attempt < maxRetries {
 			bodyStr := string(bodyBytes)
 			if (strings.Contains(bodyStr, "Provider returned error") &&
 				(strings.Contains(bodyStr, "reasoning_content") || strings.Contains(bodyStr, "thinking"))) {
 				if !disableThinking {
 					disableThinking = true
-					log.Printf("[OPENROUTER] Provider validation 400 (reasoning_content/thinking); retrying with enable_thinking=false")
+					log.Printf("[OPENROUTER] Provider validation 400 (reasoning_content/thinking); retrying with reasoning disabled")
 					continue
 				}
 				if ignoreProviderSlug == "" {
@@ -206,12 +235,12 @@ func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []Message
 							if err := RecordProviderFailure(c.ConfigDir, c.Model, ignoreProviderSlug, blockedUntil); err != nil {
 								log.Printf("[OPENROUTER] Failed to record provider failure: %v", err)
 							}
-							log.Printf("[OPENROUTER] Still 400 after enable_thinking=false; retrying with provider.ignore=%s (from error response); cooldown until %s", ignoreProviderSlug, blockedUntil.Format(time.RFC3339))
+							log.Printf("[OPENROUTER] Still 400 with reasoning disabled or configured; retrying with provider.ignore=%s (from error response); cooldown until %s", ignoreProviderSlug, blockedUntil.Format(time.RFC3339))
 							continue
 						}
 					}
 					// No provider_name in response; cannot retry with provider.ignore
-					log.Printf("[OPENROUTER] Still 400 after enable_thinking=false; no provider_name in error response")
+					log.Printf("[OPENROUTER] Still 400 with reasoning disabled or configured; no provider_name in error response")
 				}
 			}
 		}
@@ -237,6 +266,7 @@ func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []Message
 	if out.Error != nil {
 		return "", nil, fmt.Errorf("openrouter: %s", out.Error.Message)
 	}
+	c.recordUsage(ctx, out.Usage)
 	if len(out.Choices) == 0 {
 		return "", nil, fmt.Errorf("openrouter: no choices in response (body: %s)", string(bodyBytes))
 	}
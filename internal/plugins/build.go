@@ -0,0 +1,129 @@
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// buildTimeout bounds one plugin build.
+const buildTimeout = 10 * time.Minute
+
+// Build compiles the Go main package in srcDir into Dir/<name>.so with the flags the running
+// binary was built with; a plugin built by another Go version, or with other flags, fails to load.
+// It returns the compiler output. Call Load afterwards to pick the plugin up.
+func (l *Loader) Build(ctx context.Context, srcDir, name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid plugin name %q (lowercase letters, digits and _)", name)
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", fmt.Errorf("no build information in this binary")
+	}
+	args := []string{"build", "-buildmode=plugin"}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "CGO_ENABLED" && s.Value == "0":
+			return "", fmt.Errorf("this binary was built without cgo and cannot load plugins")
+		case s.Key == "-trimpath" && s.Value == "true":
+			args = append(args, "-trimpath")
+		}
+	}
+	goVersion, err := exec.CommandContext(ctx, "go", "env", "GOVERSION").Output()
+	if err != nil {
+		return "", fmt.Errorf("go toolchain not available: %w", err)
+	}
+	if v := strings.TrimSpace(string(goVersion)); v != runtime.Version() {
+		return "", fmt.Errorf("the go toolchain is %s but the bot was built with %s; plugins must use the same version", v, runtime.Version())
+	}
+	if err := os.MkdirAll(l.Dir, 0755); err != nil {
+		return "", err
+	}
+	tmp := filepath.Join(l.Dir, name+".so.tmp")
+	defer os.Remove(tmp)
+
+	ctx, cancel := context.WithTimeout(ctx, buildTimeout)
+	defer cancel()
+	// Building the files rather than the package makes the plugin path a hash of the source, so
+	// a changed plugin gets a new path (Go refuses a second plugin with the same one)
+	files, err := sourceFiles(srcDir)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "go", append(append(args, "-o", tmp), files...)...)
+	cmd.Dir = srcDir
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("go build: %w", err)
+	}
+	return string(out), os.Rename(tmp, filepath.Join(l.Dir, name+".so"))
+}
+
+// sourceFiles lists the non-test Go files of the plugin's package.
+func sourceFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".go") && !strings.HasSuffix(e.Name(), "_test.go") {
+			files = append(files, e.Name())
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return files, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyFile copies src to dst unless dst exists (copies are named by content).
+func copyFile(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
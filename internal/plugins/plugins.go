@@ -0,0 +1,288 @@
+// Package plugins loads in-process Go tools from $CONFIG_DIR/plugins. A plugin is a main package
+// built with -buildmode=plugin that exports
+//
+//	func Definition() string // JSON: {"name", "description", "parameters", "policy"}
+//	func Execute(ctx context.Context, argsJSON string) (string, error)
+//
+// using only standard library types, so it builds outside this module. Loaded plugins are
+// registered in the built-in tool registry and called without spawning a process, unlike
+// registered tools. They run inside the bot: no sandbox, no egress policy, and a plugin that
+// ignores ctx keeps its goroutine after the call times out. Plugins therefore default to the
+// restricted policy and only admins build and load them (manage_plugin).
+//
+// Go cannot unload a plugin. Reloading a changed .so opens a copy under .loaded/ named by its
+// hash and replaces the tool; the old code stays in memory until the next restart. Go also
+// refuses a second plugin with the same plugin path (by default the package path), so Build
+// compiles the package's files by name, which makes the path a hash of the source.
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/jsonschema"
+	"github.com/hattiebot/hattiebot/internal/tools/builtin"
+)
+
+// loadedDir holds the copies that are actually opened (see the package comment).
+const loadedDir = ".loaded"
+
+// DefaultPolicy applies to plugins whose definition names none.
+const DefaultPolicy = "restricted"
+
+var (
+	validName     = regexp.MustCompile(`^[a-z][a-z0-9_]{1,63}$`)
+	validPolicies = map[string]bool{"safe": true, "restricted": true, "operator": true, "admin_only": true, "owner_only": true}
+)
+
+// Info describes a loaded plugin.
+type Info struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+	Policy string `json:"policy"`
+}
+
+// Loader loads the plugins in Dir and keeps the registry in step with it.
+type Loader struct {
+	Dir string
+	// Reserved reports names taken by other tools; a plugin may not shadow them.
+	Reserved func(name string) bool
+
+	mu     sync.Mutex
+	loaded map[string]*Tool // by file name
+}
+
+// Load opens new and changed .so files in Dir, registers their tools, and unregisters the tools
+// of removed files. It returns the plugins now loaded and an error per file that failed; a
+// failing file leaves the tool it provided before (if any) in place.
+func (l *Loader) Load() ([]Info, []error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.loaded == nil {
+		l.loaded = map[string]*Tool{}
+	}
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return l.infos(), []error{err}
+	}
+	var errs []error
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".so") {
+			continue
+		}
+		seen[e.Name()] = true
+		if err := l.load(e.Name()); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+		}
+	}
+	for file, t := range l.loaded {
+		if !seen[file] {
+			builtin.Unregister(t.name)
+			delete(l.loaded, file)
+		}
+	}
+	return l.infos(), errs
+}
+
+func (l *Loader) load(file string) error {
+	path := filepath.Join(l.Dir, file)
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	old := l.loaded[file]
+	if old != nil && old.sha == sum {
+		return nil
+	}
+	// Open a copy named by content: dlopen returns the already loaded library for a path it has
+	// seen, so a rebuilt file at the same path would never be picked up
+	copyPath := filepath.Join(l.Dir, loadedDir, strings.TrimSuffix(file, ".so")+"-"+sum[:12]+".so")
+	if err := copyFile(path, copyPath); err != nil {
+		return err
+	}
+	p, err := plugin.Open(copyPath)
+	if err != nil {
+		return err
+	}
+	t, err := newTool(p)
+	if err != nil {
+		return err
+	}
+	t.file, t.sha = file, sum
+	for other, o := range l.loaded {
+		if other != file && o.name == t.name {
+			return fmt.Errorf("tool %s is already provided by %s", t.name, other)
+		}
+	}
+	if (old == nil || old.name != t.name) && l.Reserved != nil && l.Reserved(t.name) {
+		return fmt.Errorf("tool name %s is taken by a built-in or registered tool", t.name)
+	}
+	if old != nil && old.name != t.name {
+		builtin.Unregister(old.name)
+	}
+	builtin.Register(t)
+	l.loaded[file] = t
+	return nil
+}
+
+// Definition returns the definition of a loaded plugin tool, so policy checks see plugins loaded
+// after startup.
+func (l *Loader) Definition(name string) (core.ToolDefinition, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, t := range l.loaded {
+		if t.name == name {
+			return t.def, true
+		}
+	}
+	return core.ToolDefinition{}, false
+}
+
+// Plugins returns the loaded plugins.
+func (l *Loader) Plugins() []Info {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.infos()
+}
+
+func (l *Loader) infos() []Info {
+	infos := make([]Info, 0, len(l.loaded))
+	for _, t := range l.loaded {
+		infos = append(infos, Info{Name: t.name, File: t.file, SHA256: t.sha, Policy: t.def.Policy})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// IsPlugin reports whether a registered tool comes from a plugin.
+func IsPlugin(t builtin.Tool) bool {
+	_, ok := t.(*Tool)
+	return ok
+}
+
+// Tool is a plugin's tool in the built-in registry.
+type Tool struct {
+	name    string
+	def     core.ToolDefinition
+	schema  *jsonschema.Schema
+	execute func(context.Context, string) (string, error)
+	file    string
+	sha     string
+}
+
+func newTool(p *plugin.Plugin) (*Tool, error) {
+	defSym, err := p.Lookup("Definition")
+	if err != nil {
+		return nil, fmt.Errorf("no Definition function: %w", err)
+	}
+	defFn, ok := defSym.(func() string)
+	if !ok {
+		return nil, fmt.Errorf("Definition must be func() string, not %T", defSym)
+	}
+	execSym, err := p.Lookup("Execute")
+	if err != nil {
+		return nil, fmt.Errorf("no Execute function: %w", err)
+	}
+	execFn, ok := execSym.(func(context.Context, string) (string, error))
+	if !ok {
+		return nil, fmt.Errorf("Execute must be func(context.Context, string) (string, error), not %T", execSym)
+	}
+	var spec struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Parameters  map[string]interface{} `json:"parameters"`
+		Policy      string                 `json:"policy"`
+	}
+	if err := json.Unmarshal([]byte(defFn()), &spec); err != nil {
+		return nil, fmt.Errorf("Definition: %w", err)
+	}
+	if !validName.MatchString(spec.Name) {
+		return nil, fmt.Errorf("invalid tool name %q (lowercase letters, digits and _)", spec.Name)
+	}
+	if spec.Description == "" {
+		return nil, fmt.Errorf("%s: description is required", spec.Name)
+	}
+	if spec.Policy == "" {
+		spec.Policy = DefaultPolicy
+	}
+	if !validPolicies[spec.Policy] {
+		return nil, fmt.Errorf("%s: unknown policy %q", spec.Name, spec.Policy)
+	}
+	if spec.Parameters == nil {
+		spec.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	schema, err := jsonschema.FromValue(spec.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("%s: parameters: %w", spec.Name, err)
+	}
+	return &Tool{
+		name: spec.Name,
+		def: core.ToolDefinition{
+			Type:     "function",
+			Function: core.FunctionSpec{Name: spec.Name, Description: spec.Description, Parameters: spec.Parameters},
+			Policy:   spec.Policy,
+		},
+		schema:  schema,
+		execute: execFn,
+	}, nil
+}
+
+// Name implements builtin.Tool.
+func (t *Tool) Name() string { return t.name }
+
+// Definition implements builtin.Tool.
+func (t *Tool) Definition() core.ToolDefinition { return t.def }
+
+// Execute validates the arguments and calls the plugin. A panic in the plugin becomes an error
+// result, and the call returns when ctx ends even if the plugin does not.
+func (t *Tool) Execute(ctx context.Context, argsJSON string) (string, error) {
+	if strings.TrimSpace(argsJSON) == "" {
+		argsJSON = "{}"
+	}
+	if errs := t.schema.ValidateJSON([]byte(argsJSON)); len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+		return errJSON(fmt.Errorf("invalid arguments for %s: %s", t.name, strings.Join(msgs, "; "))), nil
+	}
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("plugin %s panicked: %v", t.name, r)}
+			}
+		}()
+		out, err := t.execute(ctx, argsJSON)
+		done <- result{out, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return errJSON(r.err), nil
+		}
+		return r.out, nil
+	case <-ctx.Done():
+		return errJSON(fmt.Errorf("plugin %s did not return: %w", t.name, ctx.Err())), nil
+	}
+}
+
+func errJSON(err error) string {
+	b, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(b)
+}
@@ -0,0 +1,133 @@
+package plugins
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/tools/builtin"
+)
+
+const echoSource = `package main
+
+import (
+	"context"
+	"encoding/json"
+)
+
+func Definition() string {
+	return ` + "`" + `{"name": "echo_plugin", "description": "Echoes text", "policy": "safe",
+		"parameters": {"type": "object", "properties": {"text": {"type": "string"}, "mode": {"type": "string"}}, "required": ["text"]}}` + "`" + `
+}
+
+func Execute(ctx context.Context, argsJSON string) (string, error) {
+	var args struct{ Text, Mode string }
+	json.Unmarshal([]byte(argsJSON), &args)
+	switch args.Mode {
+	case "panic":
+		panic("boom")
+	case "hang":
+		select {}
+	}
+	return PREFIX + args.Text, nil
+}
+`
+
+// build writes a plugin's source and builds it into the loader's directory.
+func build(t *testing.T, l *Loader, name, source string) {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), name)
+	os.MkdirAll(src, 0755)
+	os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/"+name+"\n\ngo 1.21\n"), 0644)
+	os.WriteFile(filepath.Join(src, "main.go"), []byte(source), 0644)
+	if out, err := l.Build(context.Background(), src, name); err != nil {
+		t.Fatalf("build %s: %v\n%s", name, err, out)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds plugins")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	if out, _ := exec.Command("go", "env", "CGO_ENABLED").Output(); strings.TrimSpace(string(out)) != "1" {
+		t.Skip("plugins need cgo")
+	}
+	l := &Loader{Dir: t.TempDir(), Reserved: func(name string) bool { return name == "read_file" }}
+	build(t, l, "echo", strings.Replace(echoSource, "PREFIX", `"v1: "`, 1))
+	defer builtin.Unregister("echo_plugin")
+
+	infos, errs := l.Load()
+	if len(errs) > 0 || len(infos) != 1 || infos[0].Name != "echo_plugin" || infos[0].Policy != "safe" {
+		t.Fatalf("load = %+v, %v", infos, errs)
+	}
+	tool, ok := builtin.Lookup("echo_plugin")
+	if !ok || !IsPlugin(tool) {
+		t.Fatal("plugin not registered")
+	}
+	ctx := context.Background()
+	if out, _ := tool.Execute(ctx, `{"text": "hi"}`); out != "v1: hi" {
+		t.Errorf("execute = %q", out)
+	}
+	if out, _ := tool.Execute(ctx, `{}`); !strings.Contains(out, "invalid arguments") {
+		t.Errorf("missing argument: %q", out)
+	}
+	if out, _ := tool.Execute(ctx, `{"text": "x", "mode": "panic"}`); !strings.Contains(out, "panicked: boom") {
+		t.Errorf("panic: %q", out)
+	}
+	short, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if out, _ := tool.Execute(short, `{"text": "x", "mode": "hang"}`); !strings.Contains(out, "did not return") {
+		t.Errorf("hang: %q", out)
+	}
+	if def, ok := l.Definition("echo_plugin"); !ok || def.Policy != "safe" {
+		t.Errorf("definition = %+v", def)
+	}
+
+	// A rebuilt plugin replaces the tool
+	build(t, l, "echo", strings.Replace(echoSource, "PREFIX", `"v2: "`, 1))
+	if _, errs := l.Load(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	tool, _ = builtin.Lookup("echo_plugin")
+	if out, _ := tool.Execute(ctx, `{"text": "hi"}`); out != "v2: hi" {
+		t.Errorf("after rebuild = %q", out)
+	}
+
+	// Plugins may not take other tools' names or break the contract
+	build(t, l, "shadow", strings.Replace(strings.Replace(echoSource, "echo_plugin", "read_file", 1), "PREFIX", `""`, 1))
+	build(t, l, "noexec", "package main\n\nfunc Definition() string { return `{\"name\": \"noexec\", \"description\": \"x\"}` }\n")
+	infos, errs = l.Load()
+	if len(infos) != 1 || len(errs) != 2 {
+		t.Fatalf("load = %+v, %v", infos, errs)
+	}
+	msgs := errs[0].Error() + errs[1].Error()
+	if !strings.Contains(msgs, "taken by a built-in") || !strings.Contains(msgs, "no Execute function") {
+		t.Errorf("errors = %v", errs)
+	}
+	if _, ok := builtin.Lookup("read_file"); ok {
+		t.Error("plugin registered over a reserved name")
+	}
+
+	// Removing the file unloads the tool
+	os.Remove(filepath.Join(l.Dir, "echo.so"))
+	if infos, _ := l.Load(); len(infos) != 0 {
+		t.Errorf("after removal = %+v", infos)
+	}
+	if _, ok := builtin.Lookup("echo_plugin"); ok {
+		t.Error("removed plugin still registered")
+	}
+}
+
+func TestBuildInvalidName(t *testing.T) {
+	l := &Loader{Dir: t.TempDir()}
+	if _, err := l.Build(context.Background(), t.TempDir(), "../evil"); err == nil {
+		t.Error("invalid name accepted")
+	}
+}
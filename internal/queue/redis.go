@@ -0,0 +1,424 @@
+// Package queue provides external ingress queues for the gateway (gateway.Queue), so several
+// HattieBot processes can share the turns of one deployment.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/health"
+)
+
+// Defaults for Redis.
+const (
+	DefaultPartitions = 16
+	DefaultLeaseTTL   = 30 * time.Second
+	// streamMaxLen caps each partition stream; acknowledged entries beyond it are trimmed.
+	streamMaxLen = 10000
+	group        = "workers"
+)
+
+// Lua scripts that change a lease only while this worker still holds it.
+const (
+	// acquireLease also succeeds on a lease this worker still holds, e.g. after a read error.
+	acquireLease = `local v = redis.call('get', KEYS[1]) if v == false or v == ARGV[1] then redis.call('set', KEYS[1], ARGV[1], 'PX', ARGV[2]) return 1 else return 0 end`
+	refreshLease = `if redis.call('get', KEYS[1]) == ARGV[1] then return redis.call('pexpire', KEYS[1], ARGV[2]) else return 0 end`
+	releaseLease = `if redis.call('get', KEYS[1]) == ARGV[1] then return redis.call('del', KEYS[1]) else return 0 end`
+)
+
+// Redis is a gateway.Queue on Redis Streams. A message goes to one of Partitions streams, chosen
+// by a hash of its key (the thread). Each worker process leases its share of the partitions
+// and reads them with a consumer named after the partition, in one consumer group. Only the
+// lease holder reads a partition, so a thread's messages are handled in order by one process;
+// a worker that takes over a partition first gets the messages its previous holder read but
+// never acknowledged. Workers rebalance the partitions as they join and leave.
+type Redis struct {
+	url        *url.URL
+	Partitions int
+	// Prefix namespaces the keys (default "hattiebot").
+	Prefix string
+	// Worker names this process in leases (default host-pid).
+	Worker   string
+	LeaseTTL time.Duration
+	// Block is how long a read waits for new messages.
+	Block time.Duration
+
+	pubMu sync.Mutex
+	pub   *respConn
+
+	mu       sync.Mutex
+	owned    map[int]*partition
+	lastErr  error
+	lastOKAt time.Time
+}
+
+type partition struct {
+	cancel      context.CancelFunc
+	outstanding int // messages handed to the gateway and not done yet
+}
+
+// NewRedis returns a queue on the Redis server at rawURL (redis://[user:password@]host[:port][/db],
+// or rediss:// for TLS), split into partitions streams (0 = DefaultPartitions). Every process
+// sharing a queue must use the same number of partitions.
+func NewRedis(rawURL string, partitions int) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("queue URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("queue URL must start with redis:// or rediss://")
+	}
+	if partitions <= 0 {
+		partitions = DefaultPartitions
+	}
+	host, _ := os.Hostname()
+	return &Redis{
+		url:        u,
+		Partitions: partitions,
+		Prefix:     "hattiebot",
+		Worker:     fmt.Sprintf("%s-%d", host, os.Getpid()),
+		LeaseTTL:   DefaultLeaseTTL,
+		Block:      5 * time.Second,
+		owned:      map[int]*partition{},
+	}, nil
+}
+
+func (q *Redis) stream(p int) string { return fmt.Sprintf("%s:ingress:%d", q.Prefix, p) }
+func (q *Redis) lease(p int) string  { return fmt.Sprintf("%s:ingress:%d:lease", q.Prefix, p) }
+func (q *Redis) workers() string     { return q.Prefix + ":workers" }
+
+// partitionOf maps a key to its partition.
+func (q *Redis) partitionOf(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(q.Partitions))
+}
+
+// Publish implements gateway.Queue.
+func (q *Redis) Publish(ctx context.Context, key string, msg gateway.Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	q.pubMu.Lock()
+	defer q.pubMu.Unlock()
+	args := []string{"XADD", q.stream(q.partitionOf(key)), "MAXLEN", "~", strconv.Itoa(streamMaxLen), "*", "msg", string(body)}
+	for attempt := 0; ; attempt++ {
+		if q.pub == nil {
+			if q.pub, err = dialRedis(ctx, q.url); err != nil {
+				q.record(err)
+				return err
+			}
+		}
+		_, err = q.pub.do(0, args...)
+		if _, isReply := err.(redisError); err == nil || isReply || attempt == 1 {
+			q.record(err)
+			return err
+		}
+		q.pub.Close() // broken connection: reconnect once
+		q.pub = nil
+	}
+}
+
+// Consume implements gateway.Queue. It keeps this worker's share of partitions leased and reads
+// each from its own connection until ctx is canceled.
+func (q *Redis) Consume(ctx context.Context, handle func(msg gateway.Message, done func())) error {
+	ctl, err := dialRedis(ctx, q.url)
+	if err != nil {
+		return err
+	}
+	defer func() { ctl.Close() }()
+	var ctlMu sync.Mutex
+	ack := func(p int, id string) {
+		ctlMu.Lock()
+		_, err := ctl.do(0, "XACK", q.stream(p), group, id)
+		ctlMu.Unlock()
+		q.finished(p)
+		if err != nil {
+			log.Printf("[QUEUE] Acknowledging %s on partition %d: %v", id, p, err)
+		}
+	}
+	for p := 0; p < q.Partitions; p++ {
+		if _, err := ctl.do(0, "XGROUP", "CREATE", q.stream(p), group, "0", "MKSTREAM"); err != nil {
+			if re, ok := err.(redisError); !ok || len(re) < 9 || string(re[:9]) != "BUSYGROUP" {
+				return fmt.Errorf("creating consumer group on %s: %w", q.stream(p), err)
+			}
+		}
+	}
+	log.Printf("[QUEUE] Worker %s consuming %d partitions at %s", q.Worker, q.Partitions, q.url.Redacted())
+
+	ticker := time.NewTicker(q.LeaseTTL / 3)
+	defer ticker.Stop()
+	defer func() { q.releaseAll(ctl, &ctlMu) }()
+	for {
+		ctlMu.Lock()
+		err := q.rebalance(ctx, ctl, handle, ack)
+		if _, isReply := err.(redisError); err != nil && !isReply && ctx.Err() == nil {
+			// Broken connection: reconnect for the next round; leases outlive a short outage.
+			if c, derr := dialRedis(ctx, q.url); derr == nil {
+				ctl.Close()
+				ctl = c
+			}
+		}
+		ctlMu.Unlock()
+		q.record(err)
+		if err != nil {
+			log.Printf("[QUEUE] Rebalancing partitions: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// rebalance refreshes this worker's heartbeat and leases, gives up partitions beyond its share
+// of the live workers, and leases free ones up to it.
+func (q *Redis) rebalance(ctx context.Context, ctl *respConn, handle func(gateway.Message, func()), ack func(int, string)) error {
+	now := time.Now()
+	ttl := strconv.FormatInt(q.LeaseTTL.Milliseconds(), 10)
+	if _, err := ctl.do(0, "ZADD", q.workers(), strconv.FormatInt(now.UnixMilli(), 10), q.Worker); err != nil {
+		return err
+	}
+	if _, err := ctl.do(0, "ZREMRANGEBYSCORE", q.workers(), "-inf", strconv.FormatInt(now.Add(-q.LeaseTTL).UnixMilli(), 10)); err != nil {
+		return err
+	}
+	live, err := ctl.do(0, "ZCARD", q.workers())
+	if err != nil {
+		return err
+	}
+	n, _ := live.(int64)
+	if n < 1 {
+		n = 1
+	}
+	share := (q.Partitions + int(n) - 1) / int(n)
+
+	q.mu.Lock()
+	owned := make([]int, 0, len(q.owned))
+	for p := range q.owned {
+		owned = append(owned, p)
+	}
+	q.mu.Unlock()
+	for _, p := range owned {
+		kept, err := ctl.do(0, "EVAL", refreshLease, "1", q.lease(p), q.Worker, ttl)
+		if err != nil {
+			return err
+		}
+		if kept == int64(0) {
+			log.Printf("[QUEUE] Lost the lease on partition %d", p)
+			q.stop(p)
+		}
+	}
+	// Give back partitions beyond the share once their messages are done, so another worker
+	// never redelivers a message that is still being handled here.
+	q.mu.Lock()
+	extra := len(q.owned) - share
+	var release []int
+	for p, part := range q.owned {
+		if extra <= 0 {
+			break
+		}
+		if part.outstanding == 0 {
+			release = append(release, p)
+			extra--
+		}
+	}
+	q.mu.Unlock()
+	for _, p := range release {
+		q.stop(p)
+		if _, err := ctl.do(0, "EVAL", releaseLease, "1", q.lease(p), q.Worker); err != nil {
+			return err
+		}
+	}
+	// Lease free partitions, starting at a worker-specific offset so workers spread out.
+	start := q.partitionOf(q.Worker)
+	for i := 0; i < q.Partitions; i++ {
+		q.mu.Lock()
+		full := len(q.owned) >= share
+		_, mine := q.owned[(start+i)%q.Partitions]
+		q.mu.Unlock()
+		if full {
+			break
+		}
+		p := (start + i) % q.Partitions
+		if mine {
+			continue
+		}
+		got, err := ctl.do(0, "EVAL", acquireLease, "1", q.lease(p), q.Worker, ttl)
+		if err != nil {
+			return err
+		}
+		if got == int64(1) {
+			q.startPartition(ctx, p, handle, ack)
+		}
+	}
+	return nil
+}
+
+func (q *Redis) startPartition(ctx context.Context, p int, handle func(gateway.Message, func()), ack func(int, string)) {
+	pctx, cancel := context.WithCancel(ctx)
+	q.mu.Lock()
+	q.owned[p] = &partition{cancel: cancel}
+	q.mu.Unlock()
+	log.Printf("[QUEUE] Leased partition %d", p)
+	go func() {
+		if err := q.read(pctx, p, handle, ack); err != nil && pctx.Err() == nil {
+			log.Printf("[QUEUE] Reading partition %d: %v", p, err)
+			q.record(err)
+			q.stop(p) // leased again on a later rebalance, with a fresh connection
+		}
+	}()
+}
+
+// read hands partition p's messages to handle: first those read before but never acknowledged,
+// then new ones, until ctx is canceled.
+func (q *Redis) read(ctx context.Context, p int, handle func(gateway.Message, func()), ack func(int, string)) error {
+	conn, err := dialRedis(ctx, q.url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close() // interrupts a blocked read
+	}()
+	consumer := "p" + strconv.Itoa(p)
+	from := "0"
+	for ctx.Err() == nil {
+		args := []string{"XREADGROUP", "GROUP", group, consumer, "COUNT", "50"}
+		if from == ">" {
+			args = append(args, "BLOCK", strconv.FormatInt(q.Block.Milliseconds(), 10))
+		}
+		reply, err := conn.do(q.Block, append(args, "STREAMS", q.stream(p), from)...)
+		if err != nil {
+			return err
+		}
+		entries := streamEntries(reply)
+		if from != ">" {
+			if len(entries) == 0 {
+				from = ">" // the backlog is handled; wait for new messages
+				continue
+			}
+			// Pending entries stay pending until acked, so page past the ones already handed out.
+			from = entries[len(entries)-1].id
+		}
+		for _, e := range entries {
+			id := e.id
+			var msg gateway.Message
+			if e.body == "" || json.Unmarshal([]byte(e.body), &msg) != nil {
+				log.Printf("[QUEUE] Dropping unreadable entry %s on partition %d", id, p)
+				q.started(p)
+				ack(p, id)
+				continue
+			}
+			q.started(p)
+			var once sync.Once
+			handle(msg, func() { once.Do(func() { ack(p, id) }) })
+		}
+	}
+	return nil
+}
+
+type entry struct{ id, body string }
+
+// streamEntries extracts the entries of an XREADGROUP reply: [[stream, [[id, [field, value...]]...]]].
+// Entries trimmed from the stream come back without fields and get an empty body.
+func streamEntries(reply interface{}) []entry {
+	streams, _ := reply.([]interface{})
+	var out []entry
+	for _, s := range streams {
+		sv, _ := s.([]interface{})
+		if len(sv) != 2 {
+			continue
+		}
+		items, _ := sv[1].([]interface{})
+		for _, it := range items {
+			iv, _ := it.([]interface{})
+			if len(iv) != 2 {
+				continue
+			}
+			e := entry{}
+			e.id, _ = iv[0].(string)
+			fields, _ := iv[1].([]interface{})
+			for i := 0; i+1 < len(fields); i += 2 {
+				if f, _ := fields[i].(string); f == "msg" {
+					e.body, _ = fields[i+1].(string)
+				}
+			}
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (q *Redis) started(p int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if part := q.owned[p]; part != nil {
+		part.outstanding++
+	}
+}
+
+func (q *Redis) finished(p int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if part := q.owned[p]; part != nil && part.outstanding > 0 {
+		part.outstanding--
+	}
+}
+
+func (q *Redis) stop(p int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if part := q.owned[p]; part != nil {
+		part.cancel()
+		delete(q.owned, p)
+	}
+}
+
+// releaseAll gives up every lease and the heartbeat when the worker stops, so other workers
+// take over at once instead of after LeaseTTL.
+func (q *Redis) releaseAll(ctl *respConn, ctlMu *sync.Mutex) {
+	ctlMu.Lock()
+	defer ctlMu.Unlock()
+	q.mu.Lock()
+	owned := q.owned
+	q.owned = map[int]*partition{}
+	q.mu.Unlock()
+	for p, part := range owned {
+		part.cancel()
+		ctl.do(0, "EVAL", releaseLease, "1", q.lease(p), q.Worker)
+	}
+	ctl.do(0, "ZREM", q.workers(), q.Worker)
+}
+
+func (q *Redis) record(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lastErr = err
+	if err == nil {
+		q.lastOKAt = time.Now()
+	}
+}
+
+// HealthCheck reports the last Redis error, and how many partitions this worker holds.
+func (q *Redis) HealthCheck() health.ComponentHealth {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	h := health.ComponentHealth{Name: "queue", Status: "ok", LastOK: q.lastOKAt,
+		Message: fmt.Sprintf("%d of %d partitions leased", len(q.owned), q.Partitions)}
+	if q.lastErr != nil {
+		h.Status, h.Message = "error", q.lastErr.Error()
+	}
+	return h
+}
@@ -0,0 +1,308 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+)
+
+// fakeRedis serves the subset of Redis the queue uses, for one consumer group.
+type fakeRedis struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	streams map[string][]entry
+	next    map[string]int                 // per stream: index of the next undelivered entry
+	pending map[string]map[string][]string // stream -> consumer -> ids read and not acked
+	leases  map[string]string
+	expires map[string]time.Time
+	workers map[string]int64
+	seq     int
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, streams: map[string][]entry{}, next: map[string]int{}, pending: map[string]map[string][]string{},
+		leases: map[string]string{}, expires: map[string]time.Time{}, workers: map[string]int64{}}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeRedis) url() string { return "redis://" + f.ln.Addr().String() }
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			return
+		}
+		parts, _ := req.([]interface{})
+		args := make([]string, len(parts))
+		for i, p := range parts {
+			args[i], _ = p.(string)
+		}
+		if _, err := c.Write([]byte(f.exec(args))); err != nil {
+			return
+		}
+	}
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+func (f *fakeRedis) exec(args []string) string {
+	if len(args) > 0 && strings.ToUpper(args[0]) == "XREADGROUP" {
+		return f.xreadgroup(args)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch strings.ToUpper(args[0]) {
+	case "XGROUP":
+		return "+OK\r\n"
+	case "XADD":
+		f.seq++
+		id := fmt.Sprintf("%d-0", f.seq)
+		f.streams[args[1]] = append(f.streams[args[1]], entry{id: id, body: args[len(args)-1]})
+		return bulk(id)
+	case "XACK":
+		for c, ids := range f.pending[args[1]] {
+			for i, id := range ids {
+				if id == args[3] {
+					f.pending[args[1]][c] = append(ids[:i:i], ids[i+1:]...)
+					return ":1\r\n"
+				}
+			}
+		}
+		return ":0\r\n"
+	case "ZADD":
+		score, _ := strconv.ParseInt(args[2], 10, 64)
+		f.workers[args[3]] = score
+		return ":1\r\n"
+	case "ZREMRANGEBYSCORE":
+		min, _ := strconv.ParseInt(args[3], 10, 64)
+		for w, s := range f.workers {
+			if s <= min {
+				delete(f.workers, w)
+			}
+		}
+		return ":0\r\n"
+	case "ZCARD":
+		return fmt.Sprintf(":%d\r\n", len(f.workers))
+	case "ZREM":
+		delete(f.workers, args[2])
+		return ":1\r\n"
+	case "EVAL":
+		key, worker := args[3], args[4]
+		if time.Now().After(f.expires[key]) {
+			delete(f.leases, key)
+		}
+		holder, held := f.leases[key]
+		switch args[1] {
+		case acquireLease, refreshLease:
+			if (args[1] == acquireLease && !held) || holder == worker {
+				ms, _ := strconv.Atoi(args[5])
+				f.leases[key], f.expires[key] = worker, time.Now().Add(time.Duration(ms)*time.Millisecond)
+				return ":1\r\n"
+			}
+		case releaseLease:
+			if holder == worker {
+				delete(f.leases, key)
+				return ":1\r\n"
+			}
+		}
+		return ":0\r\n"
+	}
+	return "-ERR unknown command " + args[0] + "\r\n"
+}
+
+// xreadgroup supports "XREADGROUP GROUP g consumer COUNT n [BLOCK ms] STREAMS key id".
+func (f *fakeRedis) xreadgroup(args []string) string {
+	consumer, stream, from := args[3], args[len(args)-2], args[len(args)-1]
+	block := time.Duration(0)
+	for i, a := range args {
+		if a == "BLOCK" {
+			ms, _ := strconv.Atoi(args[i+1])
+			block = time.Duration(ms) * time.Millisecond
+		}
+	}
+	deadline := time.Now().Add(block)
+	for {
+		f.mu.Lock()
+		var out []entry
+		if from != ">" {
+			for _, id := range f.pending[stream][consumer] {
+				for _, e := range f.streams[stream] {
+					if e.id == id && idSeq(id) > idSeq(from) {
+						out = append(out, e)
+					}
+				}
+			}
+		} else {
+			out = f.streams[stream][f.next[stream]:]
+			f.next[stream] = len(f.streams[stream])
+			if f.pending[stream] == nil {
+				f.pending[stream] = map[string][]string{}
+			}
+			for _, e := range out {
+				f.pending[stream][consumer] = append(f.pending[stream][consumer], e.id)
+			}
+		}
+		f.mu.Unlock()
+		if len(out) > 0 || from != ">" || time.Now().After(deadline) {
+			if len(out) == 0 && from == ">" {
+				return "*-1\r\n"
+			}
+			var b strings.Builder
+			fmt.Fprintf(&b, "*1\r\n*2\r\n%s*%d\r\n", bulk(stream), len(out))
+			for _, e := range out {
+				fmt.Fprintf(&b, "*2\r\n%s*2\r\n%s%s", bulk(e.id), bulk("msg"), bulk(e.body))
+			}
+			return b.String()
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func idSeq(id string) int {
+	n, _ := strconv.Atoi(strings.TrimSuffix(id, "-0"))
+	return n
+}
+
+type received struct {
+	content string
+	done    func()
+}
+
+func startWorker(t *testing.T, url, name string) (chan received, context.CancelFunc, *Redis) {
+	q, err := NewRedis(url, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Worker, q.LeaseTTL, q.Block = name, 300*time.Millisecond, 50*time.Millisecond
+	got := make(chan received, 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		q.Consume(ctx, func(msg gateway.Message, done func()) { got <- received{msg.Content, done} })
+	}()
+	return got, func() { cancel(); <-stopped }, q
+}
+
+func next(t *testing.T, got chan received) received {
+	t.Helper()
+	select {
+	case r := <-got:
+		return r
+	case <-time.After(3 * time.Second):
+		t.Fatal("no message consumed")
+		return received{}
+	}
+}
+
+func TestRedisQueueOrderAndTakeover(t *testing.T) {
+	f := newFakeRedis(t)
+	pub, err := NewRedis(f.url(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, c := range []string{"one", "two", "three"} {
+		if err := pub.Publish(ctx, "talk:room", gateway.Message{Channel: "talk", ThreadID: "room", Content: c}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, stop, _ := startWorker(t, f.url(), "w1")
+	for _, want := range []string{"one", "two", "three"} {
+		r := next(t, got)
+		if r.content != want {
+			t.Fatalf("consumed %q, want %q", r.content, want)
+		}
+		if want != "three" {
+			r.done()
+		}
+	}
+	// w1 stops before finishing "three"; w2 takes over the partition and gets it again.
+	stop()
+	time.Sleep(100 * time.Millisecond) // let the fake's blocked reads for w1's closed connections time out
+	got2, stop2, q2 := startWorker(t, f.url(), "w2")
+	defer stop2()
+	if r := next(t, got2); r.content != "three" {
+		t.Fatalf("after takeover consumed %q, want the unfinished message", r.content)
+	} else {
+		r.done()
+	}
+	if err := pub.Publish(ctx, "talk:room", gateway.Message{Content: "four"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := next(t, got2); r.content != "four" {
+		t.Fatalf("consumed %q, want four", r.content)
+	}
+	if h := q2.HealthCheck(); h.Status != "ok" || h.Message != "4 of 4 partitions leased" {
+		t.Errorf("health = %+v", h)
+	}
+}
+
+func TestRedisQueueSharesPartitions(t *testing.T) {
+	f := newFakeRedis(t)
+	_, stop1, q1 := startWorker(t, f.url(), "w1")
+	defer stop1()
+	_, stop2, q2 := startWorker(t, f.url(), "w2")
+	defer stop2()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		q1.mu.Lock()
+		n1 := len(q1.owned)
+		q1.mu.Unlock()
+		q2.mu.Lock()
+		n2 := len(q2.owned)
+		q2.mu.Unlock()
+		if n1 == 2 && n2 == 2 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("partitions w1=%d w2=%d, want 2 each", n1, n2)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*3\r\n+OK\r\n:42\r\n$-1\r\n-BUSYGROUP exists\r\n"))
+	v, err := readReply(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := v.([]interface{})
+	if arr[0] != "OK" || arr[1] != int64(42) || arr[2] != nil {
+		t.Errorf("reply = %#v", arr)
+	}
+	if _, err := readReply(r); err == nil || err.Error() != "BUSYGROUP exists" {
+		t.Errorf("error reply = %v", err)
+	}
+	if got := string(encodeCommand([]string{"GET", "k"})); got != "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n" {
+		t.Errorf("encoded %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// respConn is a minimal Redis client: it sends commands as RESP arrays of bulk strings and
+// reads RESP2 replies. It is not safe for concurrent use.
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisError is an error reply ("-ERR ...") from the server.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// dialRedis connects to a redis:// or rediss:// (TLS) URL, authenticating with its user and
+// password and selecting the database in its path (redis://:secret@host:6379/2).
+func dialRedis(ctx context.Context, u *url.URL) (*respConn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	d := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	switch u.Scheme {
+	case "redis":
+		conn, err = d.DialContext(ctx, "tcp", host)
+	case "rediss":
+		td := &tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}}
+		conn, err = td.DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("queue URL must start with redis:// or rediss://")
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &respConn{conn: conn, r: bufio.NewReader(conn)}
+	if u.User != nil {
+		args := []string{"AUTH"}
+		if name := u.User.Username(); name != "" {
+			args = append(args, name)
+		}
+		pass, _ := u.User.Password()
+		if _, err := c.do(0, append(args, pass)...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis AUTH: %w", err)
+		}
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" && db != "0" {
+		if _, err := c.do(0, "SELECT", db); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis SELECT %s: %w", db, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *respConn) Close() error { return c.conn.Close() }
+
+// do sends a command and returns its reply: string, int64, []interface{}, nil, or a redisError
+// as the error. block is how long the server may hold the reply (for BLOCK commands).
+func (c *respConn) do(block time.Duration, args ...string) (interface{}, error) {
+	c.conn.SetDeadline(time.Now().Add(block + 10*time.Second))
+	if _, err := c.conn.Write(encodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+func encodeCommand(args []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return []byte(b.String())
+}
+
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		out := make([]interface{}, n)
+		for i := range out {
+			v, err := readReply(r)
+			if err != nil {
+				if _, ok := err.(redisError); !ok {
+					return nil, err
+				}
+				v = err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+}
@@ -0,0 +1,446 @@
+// Package recipes installs declarative integration bundles. A recipe names the secrets it needs
+// and declares webhook routes, registered tools, sub-minds, and schedules; it is installed and
+// removed as one unit, so a half-configured integration is never left behind.
+package recipes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/scheduler"
+	"github.com/hattiebot/hattiebot/internal/secrets"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/timeparse"
+)
+
+// Recipe is the document format (YAML or JSON; field names are the JSON tags).
+type Recipe struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Secrets     []SecretSpec         `json:"secrets"`
+	Webhooks    []store.WebhookRoute `json:"webhooks"`
+	Tools       []ToolSpec           `json:"tools"`
+	Subminds    []core.SubMindConfig `json:"subminds"`
+	Schedules   []ScheduleSpec       `json:"schedules"`
+}
+
+// SecretSpec is a secret the recipe needs; it must resolve before install. Recipes never carry
+// secret values.
+type SecretSpec struct {
+	Name        string `json:"name"`
+	Source      string `json:"source,omitempty"` // env, passwords, local, vault; default store if empty
+	Description string `json:"description,omitempty"`
+}
+
+// Ref is the secret reference as used in {{secret:...}}.
+func (s SecretSpec) Ref() string {
+	if s.Source == "" {
+		return s.Name
+	}
+	return s.Source + ":" + s.Name
+}
+
+// ToolSpec registers an already-built tool binary.
+type ToolSpec struct {
+	Name        string `json:"name"`
+	BinaryPath  string `json:"binary_path"`
+	Description string `json:"description"`
+	InputSchema string `json:"input_schema"`
+}
+
+// ScheduleSpec is a scheduled plan, with the same fields as manage_schedule.
+type ScheduleSpec struct {
+	Description  string                 `json:"description"`
+	ScheduleType string                 `json:"schedule_type"`
+	RunAt        string                 `json:"run_at"`
+	Timezone     string                 `json:"timezone,omitempty"`
+	ActionType   string                 `json:"action_type,omitempty"` // remind (default), execute_tool, agent_prompt
+	Tool         string                 `json:"tool,omitempty"`
+	ToolArgs     map[string]interface{} `json:"tool_args,omitempty"`
+	Prompt       string                 `json:"prompt,omitempty"`
+	Autonomous   bool                   `json:"autonomous,omitempty"`
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Parse reads a recipe from JSON or YAML.
+func Parse(src string) (*Recipe, error) {
+	data := []byte(src)
+	if t := strings.TrimSpace(src); !strings.HasPrefix(t, "{") {
+		// Decode YAML generically and re-encode as JSON, so one set of field tags serves both.
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse recipe yaml: %w", err)
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("parse recipe yaml: %w", err)
+		}
+	}
+	var r Recipe
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse recipe: %w", err)
+	}
+	return &r, r.Validate()
+}
+
+// Validate checks the recipe on its own, without looking at what is already installed.
+func (r *Recipe) Validate() error {
+	if !validName.MatchString(r.Name) {
+		return fmt.Errorf("recipe name %q must be lowercase letters, digits, - or _", r.Name)
+	}
+	if len(r.Webhooks)+len(r.Tools)+len(r.Subminds)+len(r.Schedules) == 0 {
+		return fmt.Errorf("recipe %s declares nothing to install", r.Name)
+	}
+	for _, s := range r.Secrets {
+		if s.Name == "" {
+			return fmt.Errorf("secret entry without a name")
+		}
+	}
+	for _, w := range r.Webhooks {
+		if !strings.HasPrefix(w.Path, "/webhook/") || w.Path == "/webhook/talk" {
+			return fmt.Errorf("webhook path %q must start with /webhook/ and cannot be /webhook/talk", w.Path)
+		}
+		if w.ID == "" {
+			return fmt.Errorf("webhook %s needs an id", w.Path)
+		}
+		if w.AuthType != "header" && w.AuthType != "hmac_sha256" {
+			return fmt.Errorf("webhook %s: auth_type must be header or hmac_sha256", w.Path)
+		}
+		if err := w.Validate(); err != nil {
+			return fmt.Errorf("webhook %s: %w", w.Path, err)
+		}
+	}
+	for _, t := range r.Tools {
+		if t.Name == "" || t.BinaryPath == "" {
+			return fmt.Errorf("tool entries need name and binary_path")
+		}
+	}
+	for _, s := range r.Subminds {
+		if s.Name == "" || s.SystemPrompt == "" {
+			return fmt.Errorf("sub-mind entries need name and system_prompt")
+		}
+	}
+	for _, s := range r.Schedules {
+		if s.Description == "" {
+			return fmt.Errorf("schedule entries need a description")
+		}
+		if _, err := nextRun(s, time.Now()); err != nil {
+			return fmt.Errorf("schedule %q: %w", s.Description, err)
+		}
+		if s.ActionType == "execute_tool" && s.Tool == "" {
+			return fmt.Errorf("schedule %q: execute_tool requires tool", s.Description)
+		}
+	}
+	return nil
+}
+
+// Installer applies recipes against the bot's config dir, database, and sub-mind registry.
+type Installer struct {
+	ConfigDir    string
+	WorkspaceDir string // relative tool binary paths resolve here
+	DB           *store.DB
+	Subminds     core.SubmindRegistry
+	Secrets      *secrets.MultiStore
+	// BlockedTools may not be granted to recipe sub-minds.
+	BlockedTools map[string]bool
+	// ValidateAllowedTools, when set, checks sub-mind allowed_tools patterns.
+	ValidateAllowedTools func(entries []string) error
+	// ValidateTool, when set, runs the contract test on a tool binary before it is registered.
+	ValidateTool func(ctx context.Context, binaryPath string) error
+}
+
+// List returns the installed recipes.
+func (in *Installer) List() ([]store.InstalledRecipe, error) {
+	return store.LoadInstalledRecipes(in.ConfigDir)
+}
+
+// Install parses src and installs every component, or nothing: on the first failure the
+// components already created are removed again.
+func (in *Installer) Install(ctx context.Context, userID, src string) (*store.InstalledRecipe, error) {
+	r, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	installed, err := in.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, ir := range installed {
+		if ir.Name == r.Name {
+			return nil, fmt.Errorf("recipe %s is already installed; remove it first", r.Name)
+		}
+	}
+	if err := in.checkPrerequisites(ctx, r); err != nil {
+		return nil, err
+	}
+
+	rec := store.InstalledRecipe{
+		Name:        r.Name,
+		Description: r.Description,
+		InstalledAt: time.Now(),
+		InstalledBy: userID,
+		Source:      src,
+	}
+	for _, s := range r.Secrets {
+		rec.Secrets = append(rec.Secrets, s.Ref())
+	}
+	if err := in.apply(ctx, userID, r, &rec); err != nil {
+		if rbErr := in.removeComponents(ctx, &rec); rbErr != nil {
+			return nil, fmt.Errorf("%w (rollback incomplete: %v)", err, rbErr)
+		}
+		return nil, err
+	}
+	if err := store.SaveInstalledRecipes(in.ConfigDir, append(installed, rec)); err != nil {
+		_ = in.removeComponents(ctx, &rec)
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Remove deletes everything the named recipe created. Secrets are left alone; they may be shared.
+// Components that fail to delete stay on the record so a retry can finish the job.
+func (in *Installer) Remove(ctx context.Context, name string) error {
+	installed, err := in.List()
+	if err != nil {
+		return err
+	}
+	for i := range installed {
+		if installed[i].Name != name {
+			continue
+		}
+		rmErr := in.removeComponents(ctx, &installed[i])
+		if rmErr == nil {
+			installed = append(installed[:i], installed[i+1:]...)
+		}
+		if err := store.SaveInstalledRecipes(in.ConfigDir, installed); err != nil {
+			return err
+		}
+		return rmErr
+	}
+	return fmt.Errorf("recipe %s is not installed", name)
+}
+
+// checkPrerequisites rejects missing secrets and name clashes before anything is written.
+func (in *Installer) checkPrerequisites(ctx context.Context, r *Recipe) error {
+	var missing []string
+	for _, s := range r.Secrets {
+		if in.Secrets == nil {
+			missing = append(missing, s.Ref())
+			continue
+		}
+		if _, err := in.Secrets.Resolve(s.Ref()); err != nil {
+			missing = append(missing, s.Ref())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing secrets: %s (store them with store_secret first)", strings.Join(missing, ", "))
+	}
+
+	routes, err := store.LoadWebhookRoutes(in.ConfigDir)
+	if err != nil {
+		return err
+	}
+	for _, w := range r.Webhooks {
+		for _, existing := range routes {
+			if existing.Path == w.Path || existing.ID == w.ID {
+				return fmt.Errorf("webhook route %s (id %s) already exists", w.Path, w.ID)
+			}
+		}
+	}
+	if (len(r.Tools) > 0 || len(r.Schedules) > 0) && in.DB == nil {
+		return fmt.Errorf("database not configured")
+	}
+	for _, t := range r.Tools {
+		if existing, err := in.DB.ToolByName(ctx, t.Name); err != nil {
+			return err
+		} else if existing != nil {
+			return fmt.Errorf("tool %s already exists", t.Name)
+		}
+	}
+	if len(r.Subminds) > 0 && in.Subminds == nil {
+		return fmt.Errorf("sub-mind registry not configured")
+	}
+	for _, s := range r.Subminds {
+		if _, ok := in.Subminds.Get(s.Name); ok {
+			return fmt.Errorf("sub-mind %s already exists", s.Name)
+		}
+		for _, tool := range s.AllowedTools {
+			if in.BlockedTools[tool] {
+				return fmt.Errorf("sub-mind %s cannot be granted blocked tool %s", s.Name, tool)
+			}
+		}
+		if in.ValidateAllowedTools != nil {
+			if err := in.ValidateAllowedTools(s.AllowedTools); err != nil {
+				return fmt.Errorf("sub-mind %s: %w", s.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// apply creates the components, recording each one in rec as soon as it exists.
+func (in *Installer) apply(ctx context.Context, userID string, r *Recipe, rec *store.InstalledRecipe) error {
+	if len(r.Webhooks) > 0 {
+		routes, err := store.LoadWebhookRoutes(in.ConfigDir)
+		if err != nil {
+			return err
+		}
+		if err := store.SaveWebhookRoutes(in.ConfigDir, append(routes, r.Webhooks...)); err != nil {
+			return err
+		}
+		for _, w := range r.Webhooks {
+			rec.Webhooks = append(rec.Webhooks, w.Path)
+		}
+	}
+	for _, t := range r.Tools {
+		if in.ValidateTool != nil {
+			binaryPath := t.BinaryPath
+			if !filepath.IsAbs(binaryPath) && in.WorkspaceDir != "" {
+				binaryPath = filepath.Join(in.WorkspaceDir, filepath.Clean(binaryPath))
+			}
+			if err := in.ValidateTool(ctx, binaryPath); err != nil {
+				return fmt.Errorf("tool %s: %w", t.Name, err)
+			}
+		}
+		if _, err := in.DB.InsertTool(ctx, t.Name, t.BinaryPath, t.Description, t.InputSchema); err != nil {
+			return fmt.Errorf("tool %s: %w", t.Name, err)
+		}
+		rec.Tools = append(rec.Tools, t.Name)
+	}
+	for _, s := range r.Subminds {
+		s.Protected = false
+		if err := in.Subminds.Add(s); err != nil {
+			return fmt.Errorf("sub-mind %s: %w", s.Name, err)
+		}
+		rec.Subminds = append(rec.Subminds, s.Name)
+	}
+	for _, s := range r.Schedules {
+		next, err := nextRun(s, time.Now())
+		if err != nil {
+			return err
+		}
+		actionType := s.ActionType
+		if actionType == "" {
+			actionType = "remind"
+		}
+		id, err := in.DB.CreatePlan(ctx, userID, s.Description, actionType, actionPayload(s), s.ScheduleType, s.RunAt, s.Timezone, next)
+		if err != nil {
+			return fmt.Errorf("schedule %q: %w", s.Description, err)
+		}
+		rec.PlanIDs = append(rec.PlanIDs, id)
+	}
+	return nil
+}
+
+// removeComponents deletes what rec lists, trimming rec to whatever could not be removed.
+func (in *Installer) removeComponents(ctx context.Context, rec *store.InstalledRecipe) error {
+	var errs []string
+	var keepPlans []int64
+	for _, id := range rec.PlanIDs {
+		if err := in.DB.DeletePlan(ctx, id); err != nil {
+			errs = append(errs, fmt.Sprintf("plan %d: %v", id, err))
+			keepPlans = append(keepPlans, id)
+		}
+	}
+	rec.PlanIDs = keepPlans
+
+	var keepSubminds []string
+	for _, name := range rec.Subminds {
+		if err := in.Subminds.Delete(name); err != nil {
+			errs = append(errs, fmt.Sprintf("sub-mind %s: %v", name, err))
+			keepSubminds = append(keepSubminds, name)
+		}
+	}
+	rec.Subminds = keepSubminds
+
+	var keepTools []string
+	for _, name := range rec.Tools {
+		if err := in.DB.DeleteTool(ctx, name); err != nil {
+			errs = append(errs, fmt.Sprintf("tool %s: %v", name, err))
+			keepTools = append(keepTools, name)
+		}
+	}
+	rec.Tools = keepTools
+
+	if len(rec.Webhooks) > 0 {
+		if err := in.removeWebhooks(rec.Webhooks); err != nil {
+			errs = append(errs, fmt.Sprintf("webhooks: %v", err))
+		} else {
+			rec.Webhooks = nil
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("could not remove %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (in *Installer) removeWebhooks(paths []string) error {
+	routes, err := store.LoadWebhookRoutes(in.ConfigDir)
+	if err != nil {
+		return err
+	}
+	drop := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		drop[p] = true
+	}
+	var kept []store.WebhookRoute
+	for _, r := range routes {
+		if !drop[r.Path] {
+			kept = append(kept, r)
+		}
+	}
+	return store.SaveWebhookRoutes(in.ConfigDir, kept)
+}
+
+// nextRun computes a schedule's first run the same way manage_schedule does.
+func nextRun(s ScheduleSpec, now time.Time) (time.Time, error) {
+	if s.ScheduleType == "once" {
+		loc, err := scheduler.LoadLocation(s.Timezone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, err := timeparse.Until(s.RunAt, now, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("run_at for once: %w", err)
+		}
+		return t, nil
+	}
+	rule, err := scheduler.ParseRule(s.ScheduleType, s.RunAt, s.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return rule.Next(now), nil
+}
+
+// actionPayload builds the plan payload the scheduler runner expects for the action type.
+func actionPayload(s ScheduleSpec) string {
+	var payload map[string]interface{}
+	switch s.ActionType {
+	case "execute_tool":
+		args := s.ToolArgs
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		payload = map[string]interface{}{"tool": s.Tool, "args": args}
+	case "agent_prompt":
+		prompt := s.Prompt
+		if prompt == "" {
+			prompt = s.Description
+		}
+		payload = map[string]interface{}{"prompt": prompt, "autonomous": s.Autonomous}
+	default:
+		return ""
+	}
+	b, _ := json.Marshal(payload)
+	return string(b)
+}
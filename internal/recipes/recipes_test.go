@@ -0,0 +1,156 @@
+package recipes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/secrets"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+type memSubminds map[string]core.SubMindConfig
+
+func (m memSubminds) Get(name string) (core.SubMindConfig, bool) { c, ok := m[name]; return c, ok }
+func (m memSubminds) Add(c core.SubMindConfig) error             { m[c.Name] = c; return nil }
+func (m memSubminds) List() []core.SubMindConfig                 { return nil }
+func (m memSubminds) Delete(name string) error {
+	if _, ok := m[name]; !ok {
+		return fmt.Errorf("not found")
+	}
+	delete(m, name)
+	return nil
+}
+
+const triageRecipe = `
+name: github-issue-triage
+description: Triage new GitHub issues
+secrets:
+  - name: RECIPE_TEST_GITHUB_SECRET
+    source: env
+webhooks:
+  - path: /webhook/github-issues
+    id: github-issues
+    auth_type: hmac_sha256
+    secret_header: X-Hub-Signature-256
+    secret_source: env
+    secret_key: RECIPE_TEST_GITHUB_SECRET
+    target_tool: spawn_submind
+    target_args: '{"name": "issue-triage", "task": "{{payload}}"}'
+subminds:
+  - name: issue-triage
+    system_prompt: Label and summarize the issue.
+    allowed_tools: [notify_user]
+    max_turns: 5
+schedules:
+  - description: Weekly triage summary
+    schedule_type: weekly
+    run_at: mon 09:00
+    timezone: Europe/Berlin
+    action_type: agent_prompt
+    prompt: Summarize last week's issues
+`
+
+func newInstaller(t *testing.T) (*Installer, memSubminds) {
+	t.Helper()
+	db, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.GetOrCreateUser(context.Background(), "admin", "", "test"); err != nil {
+		t.Fatal(err)
+	}
+	ms := secrets.NewMultiStore()
+	ms.Register("env", &secrets.EnvSecretStore{})
+	subs := memSubminds{}
+	return &Installer{ConfigDir: t.TempDir(), DB: db, Subminds: subs, Secrets: ms, BlockedTools: map[string]bool{"spawn_submind": true}}, subs
+}
+
+func TestInstallAndRemove(t *testing.T) {
+	ctx := context.Background()
+	in, subs := newInstaller(t)
+
+	if _, err := in.Install(ctx, "admin", triageRecipe); err == nil || !strings.Contains(err.Error(), "missing secrets") {
+		t.Fatalf("install without secret: err = %v", err)
+	}
+	if routes, _ := store.LoadWebhookRoutes(in.ConfigDir); len(routes) != 0 {
+		t.Fatal("failed install must not write routes")
+	}
+
+	t.Setenv("RECIPE_TEST_GITHUB_SECRET", "hmac-secret-value")
+	rec, err := in.Install(ctx, "admin", triageRecipe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Webhooks) != 1 || len(rec.Subminds) != 1 || len(rec.PlanIDs) != 1 {
+		t.Errorf("installed record = %+v", rec)
+	}
+	if _, ok := subs["issue-triage"]; !ok {
+		t.Error("sub-mind not added")
+	}
+	if plans, _ := in.DB.ListPlans(ctx, "admin", "active"); len(plans) != 1 || plans[0].Timezone != "Europe/Berlin" {
+		t.Errorf("plans = %+v", plans)
+	}
+	if _, err := in.Install(ctx, "admin", triageRecipe); err == nil {
+		t.Error("second install of the same recipe should fail")
+	}
+
+	if err := in.Remove(ctx, "github-issue-triage"); err != nil {
+		t.Fatal(err)
+	}
+	if routes, _ := store.LoadWebhookRoutes(in.ConfigDir); len(routes) != 0 {
+		t.Errorf("routes left after remove: %+v", routes)
+	}
+	if plans, _ := in.DB.ListPlans(ctx, "admin", "active"); len(plans) != 0 {
+		t.Errorf("plans left after remove: %+v", plans)
+	}
+	if len(subs) != 0 {
+		t.Errorf("sub-minds left after remove: %v", subs)
+	}
+	if list, _ := in.List(); len(list) != 0 {
+		t.Errorf("recipe still listed: %+v", list)
+	}
+}
+
+func TestInstallRollsBackOnFailure(t *testing.T) {
+	ctx := context.Background()
+	in, subs := newInstaller(t)
+	in.ValidateTool = func(ctx context.Context, binaryPath string) error {
+		return fmt.Errorf("not valid JSON")
+	}
+	src := `{
+		"name": "broken",
+		"webhooks": [{"path": "/webhook/broken", "id": "broken", "auth_type": "header", "secret_header": "X-Token", "target_tool": "notify_user"}],
+		"subminds": [{"name": "broken-mind", "system_prompt": "x"}],
+		"tools": [{"name": "broken_tool", "binary_path": "bin/broken"}]
+	}`
+	if _, err := in.Install(ctx, "admin", src); err == nil {
+		t.Fatal("expected tool validation failure")
+	}
+	if routes, _ := store.LoadWebhookRoutes(in.ConfigDir); len(routes) != 0 {
+		t.Errorf("webhook not rolled back: %+v", routes)
+	}
+	if len(subs) != 0 {
+		t.Errorf("sub-mind created after failed tool: %v", subs)
+	}
+	if list, _ := in.List(); len(list) != 0 {
+		t.Errorf("failed recipe recorded: %+v", list)
+	}
+}
+
+func TestParseRejectsInvalidRecipes(t *testing.T) {
+	for name, src := range map[string]string{
+		"bad name":     `{"name": "Bad Name", "subminds": [{"name": "a", "system_prompt": "b"}]}`,
+		"empty":        `name: nothing`,
+		"talk webhook": `{"name": "x", "webhooks": [{"path": "/webhook/talk", "id": "t", "auth_type": "header", "target_tool": "a"}]}`,
+		"bad schedule": `{"name": "x", "schedules": [{"description": "d", "schedule_type": "daily", "run_at": "25:00"}]}`,
+		"missing tool": `{"name": "x", "schedules": [{"description": "d", "schedule_type": "daily", "run_at": "09:00", "action_type": "execute_tool"}]}`,
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package redact scrubs secrets from text before it is logged, stored, or sent to the LLM.
+// It masks secret values registered at runtime (resolved {{secret:...}} references, configured
+// API keys) and common credential patterns (bearer tokens, provider API keys, password=...).
+package redact
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces every redacted value.
+const Mask = "[REDACTED]"
+
+// minSecretLen keeps short values (e.g. "admin", "1234") from masking ordinary words.
+const minSecretLen = 6
+
+var (
+	mu      sync.RWMutex
+	known   = map[string]bool{}
+	ordered []string // known values, longest first so overlapping secrets mask fully
+)
+
+// AddSecret registers secret values to mask wherever they appear. Short or empty values are ignored.
+func AddSecret(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	changed := false
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < minSecretLen || known[v] {
+			continue
+		}
+		known[v] = true
+		ordered = append(ordered, v)
+		changed = true
+	}
+	if changed {
+		sort.Slice(ordered, func(i, j int) bool { return len(ordered[i]) > len(ordered[j]) })
+	}
+}
+
+// patterns match well-known credential formats; the whole match is masked.
+var patterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),                                         // OpenAI / OpenRouter / Anthropic
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{20,}`),                                    // GitHub
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{20,}`),                                  // GitHub fine-grained
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),                                  // Slack
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),                                            // AWS access key ID
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),                                       // Google API key
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`), // JWT
+}
+
+// bearer keeps the scheme ("Bearer ") and masks the credential.
+var bearer = regexp.MustCompile(`(?i)\b(bearer)(\s+)[A-Za-z0-9._~+/=-]{16,}`)
+
+// keyValue masks values of credential-like keys in JSON, env, query-string, and YAML forms,
+// e.g. "password": "hunter22", API_KEY=abc123..., ?access_token=...
+var keyValue = regexp.MustCompile(`(?i)((?:pass(?:word|wd|phrase)?|secret|api[_-]?key|access[_-]?key|(?:access|auth|refresh|bot|app)[_-]?token|client[_-]?secret|private[_-]?key)["']?\s*[:=]\s*["']?)([^"'\s,;&}]{4,})`)
+
+// String returns s with registered secrets and credential patterns masked.
+func String(s string) string {
+	if s == "" {
+		return s
+	}
+	mu.RLock()
+	for _, v := range ordered {
+		if strings.Contains(s, v) {
+			s = strings.ReplaceAll(s, v, Mask)
+		}
+	}
+	mu.RUnlock()
+	for _, re := range patterns {
+		s = re.ReplaceAllString(s, Mask)
+	}
+	s = bearer.ReplaceAllString(s, "${1}${2}"+Mask)
+	return maskKeyValues(s)
+}
+
+func maskKeyValues(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range keyValue.FindAllStringSubmatchIndex(s, -1) {
+		valStart, valEnd := m[4], m[5]
+		// {{secret:name}} references name a secret without revealing it.
+		if strings.HasSuffix(s[:m[0]], "{{") || strings.HasPrefix(s[valStart:], "{{secret:") || s[valStart:valEnd] == Mask {
+			continue
+		}
+		b.WriteString(s[last:valStart])
+		b.WriteString(Mask)
+		last = valEnd
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Writer redacts each line written to it before passing it on; use it as log output.
+type Writer struct {
+	mu  sync.Mutex
+	out io.Writer
+	buf []byte
+}
+
+// NewWriter returns a Writer that redacts complete lines before writing them to out.
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out}
+}
+
+// Write buffers p and flushes every complete line, redacted. It always reports len(p) written.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	i := bytes.LastIndexByte(w.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	line := String(string(w.buf[:i+1]))
+	w.buf = append(w.buf[:0], w.buf[i+1:]...)
+	if _, err := io.WriteString(w.out, line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,296 @@
+// Package reload applies configuration changes without a restart. The LLM client and embedder
+// are handed out as swappable wrappers; a Reloader validates the config files, rebuilds the
+// clients and swaps them in once no turn is running. Start polls the files and reloads when they
+// change; the reload_config tool reloads on request.
+package reload
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// Files are the config files Start watches, relative to the config directory. SOUL.md and
+// webhook_routes.json are read on every turn and request, so for them a reload only validates.
+var Files = []string{"llm_routing.json", "embedding_routing.json", "webhook_routes.json", "SOUL.md"}
+
+// LLMClient is a core.LLMClient whose underlying client can be replaced while in use.
+type LLMClient struct {
+	mu sync.RWMutex
+	c  core.LLMClient
+}
+
+// NewLLMClient wraps c.
+func NewLLMClient(c core.LLMClient) *LLMClient { return &LLMClient{c: c} }
+
+// Current returns the client calls are currently delegated to.
+func (s *LLMClient) Current() core.LLMClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c
+}
+
+// Set replaces the client; calls already running finish on the old one.
+func (s *LLMClient) Set(c core.LLMClient) {
+	s.mu.Lock()
+	s.c = c
+	s.mu.Unlock()
+}
+
+func (s *LLMClient) ChatCompletion(ctx context.Context, messages []core.Message) (string, error) {
+	return s.Current().ChatCompletion(ctx, messages)
+}
+
+func (s *LLMClient) ChatCompletionWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition) (string, []core.ToolCall, error) {
+	return s.Current().ChatCompletionWithTools(ctx, messages, tools)
+}
+
+func (s *LLMClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	return s.Current().Embed(ctx, text)
+}
+
+func (s *LLMClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return core.LLMEmbedBatch(ctx, s.Current(), texts)
+}
+
+// EmbeddingClient is a core.EmbeddingClient whose underlying client can be replaced while in use.
+type EmbeddingClient struct {
+	mu sync.RWMutex
+	c  core.EmbeddingClient
+}
+
+// NewEmbeddingClient wraps c.
+func NewEmbeddingClient(c core.EmbeddingClient) *EmbeddingClient { return &EmbeddingClient{c: c} }
+
+// Current returns the client calls are currently delegated to.
+func (s *EmbeddingClient) Current() core.EmbeddingClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c
+}
+
+// Set replaces the client.
+func (s *EmbeddingClient) Set(c core.EmbeddingClient) {
+	s.mu.Lock()
+	s.c = c
+	s.mu.Unlock()
+}
+
+func (s *EmbeddingClient) Embed(ctx context.Context, text, embedType string) ([]float32, error) {
+	return s.Current().Embed(ctx, text, embedType)
+}
+
+func (s *EmbeddingClient) EmbedBatch(ctx context.Context, texts []string, embedType string) ([][]float32, error) {
+	return s.Current().EmbedBatch(ctx, texts, embedType)
+}
+
+// EmbeddingModel names the current client's model.
+func (s *EmbeddingClient) EmbeddingModel() string {
+	return core.EmbeddingModel(s.Current())
+}
+
+// Result describes one reload.
+type Result struct {
+	Reason  string            `json:"reason"`
+	Changed []string          `json:"changed"`          // files modified since the last reload
+	Errors  map[string]string `json:"errors,omitempty"` // per file; nothing is applied when set
+	DryRun  bool              `json:"dry_run,omitempty"`
+	Pending bool              `json:"pending,omitempty"` // waiting for running turns to finish
+	Applied time.Time         `json:"applied_at,omitempty"`
+}
+
+// Reloader rebuilds the LLM client and embedder from the config files.
+type Reloader struct {
+	ConfigDir string
+	LLM       *LLMClient
+	Embedder  *EmbeddingClient
+	// BuildLLM and BuildEmbedder construct clients from the current files, as at startup.
+	// BuildEmbedder gets the (swappable) LLM client for its fallback.
+	BuildLLM      func() core.LLMClient
+	BuildEmbedder func(llm core.LLMClient) core.EmbeddingClient
+	// WhenIdle runs fn once no turn is in progress (gateway.WhenIdle); nil runs it at once.
+	WhenIdle func(fn func())
+
+	mu     sync.Mutex
+	stamps map[string]fileStamp
+	last   *Result
+}
+
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// Validate checks that every config file that exists parses and is consistent.
+// It returns the problems by file name.
+func (r *Reloader) Validate() map[string]string {
+	errs := map[string]string{}
+	if c, err := store.LoadLLMRouting(r.ConfigDir); err != nil {
+		errs["llm_routing.json"] = err.Error()
+	} else if c != nil {
+		for name, route := range c.ModelRouting {
+			for _, e := range append([]store.ModelRouteEntry{route}, route.Fallbacks...) {
+				if _, ok := c.LLMProviders[e.Provider]; e.Provider != "" && !ok {
+					errs["llm_routing.json"] = fmt.Sprintf("route %q uses unknown provider %q", name, e.Provider)
+				}
+				if err := e.ValidateReasoning(); err != nil {
+					errs["llm_routing.json"] = fmt.Sprintf("route %q: %v", name, err)
+				}
+			}
+		}
+	}
+	if c, err := store.LoadEmbeddingRouting(r.ConfigDir); err != nil {
+		errs["embedding_routing.json"] = err.Error()
+	} else if c.HasDefaultProvider() {
+		if _, ok := c.EmbeddingProviders[c.DefaultProvider]; !ok {
+			errs["embedding_routing.json"] = fmt.Sprintf("default_provider %q is not in embedding_providers", c.DefaultProvider)
+		}
+	}
+	if routes, err := store.LoadWebhookRoutes(r.ConfigDir); err != nil {
+		errs["webhook_routes.json"] = err.Error()
+	} else {
+		for _, rt := range routes {
+			if rt.Path == "" {
+				errs["webhook_routes.json"] = fmt.Sprintf("route %q needs a path", rt.ID)
+			}
+			if err := rt.Validate(); err != nil {
+				errs["webhook_routes.json"] = fmt.Sprintf("route %q: %v", rt.ID, err)
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(r.ConfigDir, "SOUL.md")); err != nil && !os.IsNotExist(err) {
+		errs["SOUL.md"] = err.Error()
+	} else if err == nil && strings.TrimSpace(string(data)) == "" {
+		errs["SOUL.md"] = "file is empty"
+	}
+	return errs
+}
+
+// Reload validates the config files and, when they are valid and dryRun is false, rebuilds the
+// clients once no turn is running. Result.Pending reports that the swap is still waiting.
+func (r *Reloader) Reload(reason string, dryRun bool) (Result, error) {
+	r.mu.Lock()
+	res := Result{Reason: reason, Changed: r.changedLocked(!dryRun), DryRun: dryRun}
+	r.mu.Unlock()
+	if errs := r.Validate(); len(errs) > 0 {
+		res.Errors = errs
+		return res, fmt.Errorf("configuration not reloaded: %s", joinErrors(errs))
+	}
+	if dryRun {
+		return res, nil
+	}
+	done := make(chan struct{})
+	var appliedAt time.Time
+	apply := func() {
+		llm := r.BuildLLM()
+		r.LLM.Set(llm)
+		if r.Embedder != nil && r.BuildEmbedder != nil {
+			r.Embedder.Set(r.BuildEmbedder(r.LLM))
+		}
+		appliedAt = time.Now()
+		applied := res
+		applied.Applied = appliedAt
+		r.mu.Lock()
+		r.last = &applied
+		r.mu.Unlock()
+		log.Printf("[Reload] Configuration reloaded (%s)", reason)
+		close(done)
+	}
+	if r.WhenIdle != nil {
+		r.WhenIdle(apply)
+	} else {
+		apply()
+	}
+	select {
+	case <-done:
+		res.Applied = appliedAt
+	default:
+		res.Pending = true
+		log.Printf("[Reload] Configuration validated; reloading once running turns finish (%s)", reason)
+	}
+	return res, nil
+}
+
+// Last returns the last applied reload, or nil.
+func (r *Reloader) Last() *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Start records the current state of the config files and, when interval is positive, polls
+// them every interval in the background, reloading when one changes. Invalid files are logged and
+// left unapplied until they change again.
+func (r *Reloader) Start(ctx context.Context, interval time.Duration) {
+	r.mu.Lock()
+	r.changedLocked(true)
+	r.mu.Unlock()
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			r.mu.Lock()
+			changed := r.changedLocked(false)
+			r.mu.Unlock()
+			if len(changed) == 0 {
+				continue
+			}
+			if _, err := r.Reload("changed on disk: "+strings.Join(changed, ", "), false); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			}
+		}
+	}()
+}
+
+// changedLocked lists the files whose size or modification time differs from the last
+// snapshot (none before the first snapshot); with update it records the current state.
+func (r *Reloader) changedLocked(update bool) []string {
+	current := map[string]fileStamp{}
+	for _, name := range Files {
+		if fi, err := os.Stat(filepath.Join(r.ConfigDir, name)); err == nil {
+			current[name] = fileStamp{fi.ModTime(), fi.Size()}
+		}
+	}
+	changed := []string{}
+	if r.stamps == nil {
+		r.stamps = current
+		return changed
+	}
+	for _, name := range Files {
+		old, had := r.stamps[name]
+		cur, has := current[name]
+		if had != has || old != cur {
+			changed = append(changed, name)
+		}
+	}
+	if update {
+		r.stamps = current
+	}
+	return changed
+}
+
+func joinErrors(errs map[string]string) string {
+	parts := make([]string, 0, len(errs))
+	for file, msg := range errs {
+		parts = append(parts, file+": "+msg)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
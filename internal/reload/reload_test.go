@@ -0,0 +1,113 @@
+package reload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+)
+
+type fakeLLM struct{ name string }
+
+func (f fakeLLM) ChatCompletion(ctx context.Context, messages []core.Message) (string, error) {
+	return f.name, nil
+}
+
+func (f fakeLLM) ChatCompletionWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition) (string, []core.ToolCall, error) {
+	return f.name, nil, nil
+}
+
+func (f fakeLLM) Embed(ctx context.Context, text string) ([]float32, error) { return nil, nil }
+
+// newTestReloader builds clients named after the model in llm_routing.json's default route.
+func newTestReloader(t *testing.T) (*Reloader, string) {
+	t.Helper()
+	dir := t.TempDir()
+	build := func() core.LLMClient {
+		data, _ := os.ReadFile(filepath.Join(dir, "llm_routing.json"))
+		if strings.Contains(string(data), `"model": "b"`) {
+			return fakeLLM{"b"}
+		}
+		return fakeLLM{"a"}
+	}
+	r := &Reloader{ConfigDir: dir, LLM: NewLLMClient(build()), BuildLLM: build}
+	return r, dir
+}
+
+func writeRouting(t *testing.T, dir, model string) {
+	t.Helper()
+	routing := `{"llm_providers": {"or": {"type": "openrouter"}}, "model_routing": {"default": {"provider": "or", "model": "` + model + `"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "llm_routing.json"), []byte(routing), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func reply(t *testing.T, c core.LLMClient) string {
+	t.Helper()
+	out, _ := c.ChatCompletion(context.Background(), nil)
+	return out
+}
+
+func TestReload(t *testing.T) {
+	r, dir := newTestReloader(t)
+	r.Start(context.Background(), 0)
+
+	writeRouting(t, dir, "b")
+	res, err := r.Reload("test", true)
+	if err != nil || !res.DryRun || len(res.Changed) != 1 || res.Changed[0] != "llm_routing.json" || reply(t, r.LLM) != "a" {
+		t.Fatalf("dry run: %+v, %v, client %q", res, err, reply(t, r.LLM))
+	}
+	res, err = r.Reload("test", false)
+	if err != nil || res.Pending || res.Applied.IsZero() || reply(t, r.LLM) != "b" {
+		t.Fatalf("reload: %+v, %v, client %q", res, err, reply(t, r.LLM))
+	}
+	if res, _ := r.Reload("again", true); len(res.Changed) != 0 {
+		t.Errorf("changed after reload = %v", res.Changed)
+	}
+
+	// Invalid files are reported and nothing is applied
+	os.WriteFile(filepath.Join(dir, "llm_routing.json"), []byte(`{"model_routing": {"default": {"provider": "nope", "model": "a"}}}`), 0600)
+	os.WriteFile(filepath.Join(dir, "webhook_routes.json"), []byte(`{`), 0600)
+	os.WriteFile(filepath.Join(dir, "SOUL.md"), []byte("  \n"), 0600)
+	res, err = r.Reload("bad", false)
+	if err == nil || len(res.Errors) != 3 || !strings.Contains(res.Errors["llm_routing.json"], `unknown provider "nope"`) || reply(t, r.LLM) != "b" {
+		t.Errorf("invalid reload: %+v, %v, client %q", res, err, reply(t, r.LLM))
+	}
+}
+
+func TestReloadWaitsForIdle(t *testing.T) {
+	r, dir := newTestReloader(t)
+	var queued []func()
+	r.WhenIdle = func(fn func()) { queued = append(queued, fn) }
+	writeRouting(t, dir, "b")
+	res, err := r.Reload("test", false)
+	if err != nil || !res.Pending || reply(t, r.LLM) != "a" || r.Last() != nil {
+		t.Fatalf("reload while busy: %+v, %v, client %q", res, err, reply(t, r.LLM))
+	}
+	queued[0]()
+	if reply(t, r.LLM) != "b" || r.Last() == nil || r.Last().Applied.IsZero() {
+		t.Errorf("after idle: client %q, last %+v", reply(t, r.LLM), r.Last())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	r, dir := newTestReloader(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx, 10*time.Millisecond)
+	writeRouting(t, dir, "b")
+	deadline := time.Now().Add(2 * time.Second)
+	for reply(t, r.LLM) != "b" {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher did not reload the changed file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if last := r.Last(); last == nil || !strings.Contains(last.Reason, "llm_routing.json") {
+		t.Errorf("last reload = %+v", last)
+	}
+}
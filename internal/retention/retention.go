@@ -0,0 +1,237 @@
+// Package retention expires old data on a schedule. Raw conversation messages older than the
+// configured age are summarized per thread (the summary stands in for them in later context) and
+// deleted; system logs and the tool audit log are pruned to their own limits.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// DefaultInterval is how often Start runs the cleanup.
+const DefaultInterval = 24 * time.Hour
+
+const (
+	// Caps on what is sent to the LLM per thread; longer histories are summarized from their tail.
+	maxMessageChars = 1000
+	maxPromptChars  = 40000
+)
+
+// Policy says what to keep.
+type Policy struct {
+	MessageDays int // raw messages older than this are removed (0 = keep forever)
+	// Summarize replaces expiring messages with an LLM summary per thread. When summarizing a
+	// thread fails, its messages are kept until the next run.
+	Summarize bool
+	AuditDays int // tool_audit_log entries older than this are removed (0 = keep forever)
+}
+
+// Report describes one cleanup run.
+type Report struct {
+	At              time.Time `json:"at"`
+	Threads         int       `json:"threads"` // threads with expired messages
+	Summaries       int       `json:"summaries"`
+	MessagesDeleted int64     `json:"messages_deleted"`
+	AuditDeleted    int64     `json:"audit_deleted"`
+	Errors          []string  `json:"errors,omitempty"`
+}
+
+// Cleaner applies a Policy. Client summarizes threads; Logs is cleaned to its own limits when set.
+type Cleaner struct {
+	DB     *store.DB
+	Logs   *store.LogStore
+	Client core.LLMClient
+	Policy Policy
+
+	now  func() time.Time
+	mu   sync.Mutex
+	last *Report
+}
+
+// Run applies the policy once. Per-thread failures are collected in the report; the error is
+// non-nil only when nothing could be checked.
+func (c *Cleaner) Run(ctx context.Context) (Report, error) {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	rep := Report{At: now()}
+	var err error
+	if c.Policy.MessageDays > 0 {
+		err = c.expireMessages(ctx, rep.At.AddDate(0, 0, -c.Policy.MessageDays), &rep)
+	}
+	if c.Policy.AuditDays > 0 {
+		if n, aerr := c.DB.PruneAuditLog(ctx, c.Policy.AuditDays); aerr != nil {
+			rep.Errors = append(rep.Errors, "audit log: "+aerr.Error())
+		} else {
+			rep.AuditDeleted = n
+		}
+	}
+	if c.Logs != nil {
+		if lerr := c.Logs.Cleanup(); lerr != nil {
+			rep.Errors = append(rep.Errors, "system logs: "+lerr.Error())
+		}
+	}
+	c.mu.Lock()
+	c.last = &rep
+	c.mu.Unlock()
+	return rep, err
+}
+
+func (c *Cleaner) expireMessages(ctx context.Context, cutoff time.Time, rep *Report) error {
+	threads, err := c.DB.ThreadsWithMessagesBefore(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("finding expired messages: %w", err)
+	}
+	rep.Threads = len(threads)
+	for _, thread := range threads {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !c.Policy.Summarize || c.Client == nil {
+			n, err := c.DB.DeleteMessagesBefore(ctx, thread, cutoff)
+			if err != nil {
+				rep.Errors = append(rep.Errors, fmt.Sprintf("thread %s: %v", thread, err))
+			}
+			rep.MessagesDeleted += n
+			continue
+		}
+		n, summarized, err := c.summarizeThread(ctx, thread, cutoff)
+		if err != nil {
+			rep.Errors = append(rep.Errors, fmt.Sprintf("thread %s: %v", thread, err))
+			continue
+		}
+		if summarized {
+			rep.Summaries++
+		}
+		rep.MessagesDeleted += n
+	}
+	return nil
+}
+
+// summarizeThread folds the thread's messages before cutoff into its summary and deletes them.
+// It reports whether a summary was stored.
+func (c *Cleaner) summarizeThread(ctx context.Context, thread string, cutoff time.Time) (int64, bool, error) {
+	msgs, err := c.DB.MessagesBefore(ctx, thread, cutoff)
+	if err != nil || len(msgs) == 0 {
+		return 0, false, err
+	}
+	prev, err := c.DB.LatestConversationSummary(ctx, thread)
+	if err != nil {
+		return 0, false, err
+	}
+	participants := map[string]bool{}
+	if prev != nil {
+		for _, p := range prev.Participants {
+			participants[p] = true
+		}
+	}
+	var lines []string
+	for _, m := range msgs {
+		if m.Role != "user" && m.Role != "assistant" {
+			continue
+		}
+		if m.Role == "user" {
+			participants[m.SenderID] = true
+		}
+		content := m.Content
+		if r := []rune(content); len(r) > maxMessageChars {
+			content = string(r[:maxMessageChars]) + "…"
+		}
+		lines = append(lines, fmt.Sprintf("[%s] %s (%s): %s", m.CreatedAt.Format("2006-01-02 15:04"), m.Role, m.SenderID, content))
+	}
+	// Keep the newest lines that fit
+	total, start := 0, len(lines)
+	for start > 0 && total+len(lines[start-1]) <= maxPromptChars {
+		start--
+		total += len(lines[start])
+	}
+	lines = lines[start:]
+
+	summary := ""
+	if prev != nil {
+		summary = prev.Content
+	}
+	if len(lines) > 0 {
+		var sb strings.Builder
+		sb.WriteString("These conversation messages are about to be deleted under the data retention policy. Write a concise summary that keeps what is needed to continue the conversation later: decisions, commitments, open questions, preferences and important facts, with who said them. Leave out small talk. Reply with the summary only.\n\n")
+		if prev != nil {
+			sb.WriteString("Summary of the conversation before these messages (fold it into yours):\n" + prev.Content + "\n\n")
+		}
+		sb.WriteString("Messages:\n" + strings.Join(lines, "\n"))
+		reply, err := c.Client.ChatCompletion(ctx, []core.Message{
+			{Role: "system", Content: "You summarize conversation logs accurately and concisely."},
+			{Role: "user", Content: sb.String()},
+		})
+		if err != nil {
+			return 0, false, fmt.Errorf("summarizing: %w", err)
+		}
+		if summary = strings.TrimSpace(reply); summary == "" {
+			return 0, false, fmt.Errorf("summarizing: empty reply")
+		}
+	}
+
+	if summary == "" {
+		// Only tool output and scheduler notes expired; there is nothing to summarize
+		n, err := c.DB.DeleteMessagesBefore(ctx, thread, cutoff)
+		return n, false, err
+	}
+	names := make([]string, 0, len(participants))
+	for p := range participants {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	count := len(msgs)
+	if prev != nil {
+		count += prev.MessageCount
+	}
+	n, err := c.DB.ReplaceMessagesWithSummary(ctx, store.ConversationSummary{
+		ThreadID:     thread,
+		Channel:      msgs[len(msgs)-1].Channel,
+		Participants: names,
+		Content:      summary,
+		CoversUntil:  msgs[len(msgs)-1].CreatedAt,
+		MessageCount: count,
+	})
+	return n, err == nil, err
+}
+
+// Last returns the most recent run, or nil.
+func (c *Cleaner) Last() *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// Start runs the cleanup now and then every interval until ctx is done.
+func (c *Cleaner) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			rep, err := c.Run(ctx)
+			if err != nil {
+				log.Printf("[Retention] %v", err)
+			}
+			for _, e := range rep.Errors {
+				log.Printf("[Retention] %s", e)
+			}
+			if rep.MessagesDeleted > 0 || rep.AuditDeleted > 0 {
+				log.Printf("[Retention] Deleted %d messages (%d threads summarized) and %d audit log entries", rep.MessagesDeleted, rep.Summaries, rep.AuditDeleted)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,111 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+type fakeLLM struct {
+	prompts []string
+	err     error
+}
+
+func (f *fakeLLM) ChatCompletion(ctx context.Context, messages []core.Message) (string, error) {
+	f.prompts = append(f.prompts, messages[len(messages)-1].Content)
+	if f.err != nil {
+		return "", f.err
+	}
+	return "summary " + string(rune('A'+len(f.prompts)-1)), nil
+}
+
+func (f *fakeLLM) ChatCompletionWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition) (string, []core.ToolCall, error) {
+	return "", nil, nil
+}
+
+func (f *fakeLLM) Embed(ctx context.Context, text string) ([]float32, error) { return nil, nil }
+
+func newTestCleaner(t *testing.T) (*Cleaner, *fakeLLM, time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	llm := &fakeLLM{}
+	c := &Cleaner{DB: db, Client: llm, Policy: Policy{MessageDays: 30, Summarize: true}}
+	c.now = func() time.Time { return now }
+	return c, llm, now
+}
+
+func insert(t *testing.T, db *store.DB, role, sender, thread, content string, at time.Time) {
+	t.Helper()
+	if _, err := db.InsertImportedMessage(context.Background(), role, content, sender, "api", thread, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCleanerSummarizesExpiredMessages(t *testing.T) {
+	ctx := context.Background()
+	c, llm, now := newTestCleaner(t)
+	old := now.AddDate(0, 0, -40)
+	insert(t, c.DB, "user", "alice", "t1", "book the dentist for Tuesday", old)
+	insert(t, c.DB, "assistant", "hattiebot", "t1", "Booked.", old.Add(time.Minute))
+	insert(t, c.DB, "user", "alice", "t1", "thanks", now.AddDate(0, 0, -1))
+
+	rep, err := c.Run(ctx)
+	if err != nil || rep.Threads != 1 || rep.Summaries != 1 || rep.MessagesDeleted != 2 {
+		t.Fatalf("run = %+v, %v", rep, err)
+	}
+	if !strings.Contains(llm.prompts[0], "book the dentist") {
+		t.Errorf("prompt = %q", llm.prompts[0])
+	}
+	msgs, _ := c.DB.RecentMessages(ctx, 10, "t1")
+	if len(msgs) != 1 || msgs[0].Content != "thanks" {
+		t.Errorf("kept = %+v", msgs)
+	}
+	s, err := c.DB.LatestConversationSummary(ctx, "t1")
+	if err != nil || s == nil || s.Content != "summary A" || s.MessageCount != 2 || len(s.Participants) != 1 || s.Participants[0] != "alice" {
+		t.Fatalf("summary = %+v, %v", s, err)
+	}
+
+	// The next run folds the previous summary into the new one
+	c.now = func() time.Time { return now.AddDate(0, 0, 30) }
+	if rep, err := c.Run(ctx); err != nil || rep.Summaries != 1 || rep.MessagesDeleted != 1 {
+		t.Fatalf("second run = %+v, %v", rep, err)
+	}
+	if !strings.Contains(llm.prompts[1], "summary A") {
+		t.Errorf("second prompt lacks the previous summary: %q", llm.prompts[1])
+	}
+	if s, _ := c.DB.LatestConversationSummary(ctx, "t1"); s == nil || s.Content != "summary B" || s.MessageCount != 3 {
+		t.Errorf("second summary = %+v", s)
+	}
+}
+
+func TestCleanerKeepsMessagesWhenSummaryFails(t *testing.T) {
+	ctx := context.Background()
+	c, llm, now := newTestCleaner(t)
+	llm.err = errors.New("rate limited")
+	insert(t, c.DB, "user", "alice", "t1", "hello", now.AddDate(0, 0, -40))
+	rep, err := c.Run(ctx)
+	if err != nil || len(rep.Errors) != 1 || rep.MessagesDeleted != 0 {
+		t.Fatalf("run = %+v, %v", rep, err)
+	}
+	if msgs, _ := c.DB.RecentMessages(ctx, 10, "t1"); len(msgs) != 1 {
+		t.Errorf("messages = %+v", msgs)
+	}
+
+	// Without summaries, expired messages are simply deleted
+	c.Policy.Summarize = false
+	if rep, err := c.Run(ctx); err != nil || rep.MessagesDeleted != 1 || rep.Summaries != 0 {
+		t.Errorf("run without summaries = %+v, %v", rep, err)
+	}
+}
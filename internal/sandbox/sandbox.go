@@ -0,0 +1,501 @@
+// Package sandbox runs run_terminal_cmd commands under a profile chosen by the caller's trust level:
+// allowed binaries, a filesystem allowlist, network on/off, and CPU/memory/process/time limits.
+// Isolation is done by nsjail or docker when available; the "direct" backend applies only the
+// limits (ulimit), the allowlists, and a scrubbed environment, so "auto" profiles only fall back to
+// it when they allow it (AllowDirect).
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backends.
+const (
+	BackendNone   = "none"   // no sandbox: the command runs like an admin's
+	BackendDirect = "direct" // limits, allowlists, and scrubbed env in the bot's own namespace
+	BackendNsjail = "nsjail"
+	BackendDocker = "docker"
+	BackendAuto   = "auto" // nsjail, else docker, else direct if the profile allows it
+)
+
+// ErrNoIsolation is returned for an "auto" profile that needs nsjail or docker when neither is installed.
+var ErrNoIsolation = errors.New("sandbox: this profile needs nsjail or docker, and neither is installed")
+
+// Profile describes one sandbox.
+type Profile struct {
+	Backend string `json:"backend"`
+	// AllowedBinaries limits the programs a command may invoke (by base name); empty allows any.
+	// Checked by parsing the command line, so it is a guard rail; nsjail/docker provide the isolation.
+	AllowedBinaries []string `json:"allowed_binaries,omitempty"`
+	// WritablePaths and ReadOnlyPaths are relative to the workspace; "{user}" is replaced by the
+	// caller's user ID (e.g. "sandboxes/{user}"). The work_dir must be inside one of them. Empty
+	// WritablePaths means the whole workspace.
+	WritablePaths []string `json:"writable_paths,omitempty"`
+	ReadOnlyPaths []string `json:"read_only_paths,omitempty"`
+	Network       bool     `json:"network"`
+	CPUSeconds    int      `json:"cpu_seconds,omitempty"`
+	MemoryMB      int      `json:"memory_mb,omitempty"`
+	MaxProcesses  int      `json:"max_processes,omitempty"`
+	TimeoutSec    int      `json:"timeout_seconds,omitempty"`
+	// InheritEnv passes the bot's environment (API keys included) to the command. Off by default
+	// for sandboxed profiles: only PATH, HOME, LANG, and the call's env_vars are set.
+	InheritEnv bool `json:"inherit_env,omitempty"`
+	// Image is the docker image (default "alpine:3").
+	Image string `json:"image,omitempty"`
+	// AllowDirect lets an "auto" profile run with the direct backend when neither nsjail nor
+	// docker is installed. Direct enforces neither network:false nor the path allowlists, so
+	// without it such profiles refuse to run.
+	AllowDirect bool `json:"allow_direct,omitempty"`
+}
+
+// Config maps trust levels to profiles. Stored in $CONFIG_DIR/sandbox.json.
+type Config struct {
+	Profiles map[string]Profile `json:"profiles"`
+	// ByTrust maps a trust level ("admin", "trusted", ...) to a profile name; "default" covers the rest.
+	ByTrust map[string]string `json:"by_trust"`
+}
+
+const configFile = "sandbox.json"
+
+// DefaultConfig runs admins unsandboxed, the tool repairer in "build", and everyone else in the
+// "restricted" profile.
+func DefaultConfig() *Config {
+	return &Config{
+		Profiles: map[string]Profile{
+			"none": {Backend: BackendNone},
+			"standard": {
+				Backend:       BackendAuto,
+				Network:       true,
+				CPUSeconds:    300,
+				MemoryMB:      2048,
+				MaxProcesses:  256,
+				TimeoutSec:    600,
+				WritablePaths: []string{"."},
+				AllowDirect:   true,
+			},
+			"restricted": {
+				Backend: BackendAuto,
+				// No shells, interpreters or programs that run other programs (sh, python3, awk,
+				// find -exec, xargs): they would get around the allowlist.
+				AllowedBinaries: []string{
+					"echo", "cat", "ls", "head", "tail", "wc", "grep", "sed", "sort", "uniq",
+					"cut", "tr", "diff", "date", "pwd", "mkdir", "cp", "mv", "rm", "touch",
+					"jq", "true", "false", "test", "[", "cd", "printf",
+				},
+				WritablePaths: []string{"sandboxes/{user}"},
+				Network:       false,
+				CPUSeconds:    30,
+				MemoryMB:      512,
+				MaxProcesses:  64,
+				TimeoutSec:    60,
+			},
+			// build is restricted plus the Go toolchain, for the tool repairer and self-update.
+			// go runs the code it builds, so it is only safe isolated: no direct fallback.
+			"build": {
+				Backend:         BackendAuto,
+				AllowedBinaries: []string{"go", "gofmt", "echo", "cat", "ls", "head", "tail", "grep", "diff", "pwd", "mkdir", "cp", "mv", "rm", "cd"},
+				WritablePaths:   []string{"sandboxes/{user}"},
+				Network:         false,
+				CPUSeconds:      600,
+				MemoryMB:        4096,
+				MaxProcesses:    256,
+				TimeoutSec:      900,
+				Image:           "golang:1-alpine",
+			},
+		},
+		ByTrust: map[string]string{"admin": "none", "tool_repair": "build", "default": "restricted"},
+	}
+}
+
+// Load reads $CONFIG_DIR/sandbox.json over the defaults: profiles and trust mappings in the file
+// replace the built-in ones with the same name.
+func Load(configDir string) (*Config, error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(filepath.Join(configDir, configFile))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	var file Config
+	if err := json.Unmarshal(data, &file); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", configFile, err)
+	}
+	for name, p := range file.Profiles {
+		cfg.Profiles[name] = p
+	}
+	for trust, name := range file.ByTrust {
+		cfg.ByTrust[trust] = name
+	}
+	for trust, name := range cfg.ByTrust {
+		if _, ok := cfg.Profiles[name]; !ok {
+			return cfg, fmt.Errorf("%s: trust level %s uses unknown profile %q", configFile, trust, name)
+		}
+	}
+	return cfg, nil
+}
+
+// ForTrust returns the profile name and profile for a trust level. A call without a trust level
+// is treated as "restricted".
+func (c *Config) ForTrust(trust string) (string, Profile) {
+	if c == nil {
+		return BackendNone, Profile{Backend: BackendNone}
+	}
+	if trust == "" {
+		trust = "restricted"
+	}
+	name, ok := c.ByTrust[trust]
+	if !ok {
+		name = c.ByTrust["default"]
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		// Unknown mapping: fail closed to the tightest built-in profile.
+		name = "restricted"
+		p = DefaultConfig().Profiles[name]
+	}
+	return name, p
+}
+
+// Request is one command to run.
+type Request struct {
+	Workspace string
+	WorkDir   string // absolute or workspace-relative; defaults to the first writable path
+	Command   string
+	Env       map[string]string
+	UserID    string
+}
+
+// Timeout returns the profile's time limit, or def if unset.
+func (p Profile) Timeout(def time.Duration) time.Duration {
+	if p.TimeoutSec > 0 {
+		return time.Duration(p.TimeoutSec) * time.Second
+	}
+	return def
+}
+
+// Check validates the work dir and binaries for req and returns the resolved work dir.
+func (p Profile) Check(req Request) (string, error) {
+	if p.Backend == BackendNone || p.Backend == "" {
+		return req.WorkDir, nil
+	}
+	writable, readOnly := p.paths(req)
+	workDir := req.WorkDir
+	if workDir == "" {
+		workDir = writable[0]
+	} else if !filepath.IsAbs(workDir) {
+		workDir = filepath.Join(req.Workspace, workDir)
+	}
+	workDir = filepath.Clean(workDir)
+	if !within(workDir, append(writable, readOnly...)) {
+		return "", fmt.Errorf("sandbox: work_dir %s is outside the allowed paths", workDir)
+	}
+	if len(p.AllowedBinaries) > 0 {
+		allowed := make(map[string]bool, len(p.AllowedBinaries))
+		for _, b := range p.AllowedBinaries {
+			allowed[b] = true
+		}
+		for _, bin := range CommandBinaries(req.Command) {
+			if !allowed[filepath.Base(bin)] {
+				return "", fmt.Errorf("sandbox: %s is not an allowed program (allowed: %s)", bin, strings.Join(p.AllowedBinaries, ", "))
+			}
+		}
+	}
+	return workDir, nil
+}
+
+// paths returns the absolute writable and read-only paths for req (writable is never empty).
+func (p Profile) paths(req Request) (writable, readOnly []string) {
+	abs := func(rel string) string {
+		rel = strings.ReplaceAll(rel, "{user}", safeName(req.UserID))
+		if filepath.IsAbs(rel) {
+			return filepath.Clean(rel)
+		}
+		return filepath.Join(req.Workspace, rel)
+	}
+	for _, w := range p.WritablePaths {
+		writable = append(writable, abs(w))
+	}
+	if len(writable) == 0 {
+		writable = []string{filepath.Clean(req.Workspace)}
+	}
+	for _, r := range p.ReadOnlyPaths {
+		readOnly = append(readOnly, abs(r))
+	}
+	return writable, readOnly
+}
+
+// Command builds the process for req under the profile; the caller runs it. Check must pass first.
+func (p Profile) Command(ctx context.Context, req Request, workDir string) (*exec.Cmd, error) {
+	backend, err := p.backend()
+	if err != nil {
+		return nil, err
+	}
+	env := p.env(req)
+	switch backend {
+	case BackendNone, "":
+		cmd := exec.CommandContext(ctx, "sh", "-c", req.Command)
+		cmd.Dir, cmd.Env = workDir, env
+		return cmd, nil
+	case BackendDirect:
+		if err := mkdirAll([]string{workDir}); err != nil {
+			return nil, err
+		}
+		cmd := exec.CommandContext(ctx, "sh", "-c", p.ulimitPrefix()+`exec sh -c "$1"`, "sandbox", req.Command)
+		cmd.Dir, cmd.Env = workDir, env
+		return cmd, nil
+	}
+	writable, readOnly := p.paths(req)
+	if err := mkdirAll(writable); err != nil {
+		return nil, err
+	}
+	switch backend {
+	case BackendNsjail:
+		return exec.CommandContext(ctx, "nsjail", p.NsjailArgs(writable, readOnly, workDir, env, req.Command)...), nil
+	case BackendDocker:
+		return exec.CommandContext(ctx, "docker", p.DockerArgs(writable, readOnly, workDir, env, req.Command)...), nil
+	}
+	return nil, fmt.Errorf("sandbox: unknown backend %q", p.Backend)
+}
+
+// BackendName reports the backend the profile will actually use, "unavailable" when it cannot run.
+func (p Profile) BackendName() string {
+	backend, err := p.backend()
+	if err != nil {
+		return "unavailable"
+	}
+	if backend == "" {
+		return BackendNone
+	}
+	return backend
+}
+
+// Available returns ErrNoIsolation when the profile cannot run on this host.
+func (p Profile) Available() error {
+	_, err := p.backend()
+	return err
+}
+
+// backend resolves "auto" to nsjail or docker, or to direct when the profile allows it.
+func (p Profile) backend() (string, error) {
+	if p.Backend != BackendAuto {
+		return p.Backend, nil
+	}
+	if b := detectBackend(); b != "" {
+		return b, nil
+	}
+	if p.AllowDirect {
+		return BackendDirect, nil
+	}
+	return "", ErrNoIsolation
+}
+
+func (p Profile) env(req Request) []string {
+	var env []string
+	if p.Backend == BackendNone || p.Backend == "" || p.InheritEnv {
+		env = os.Environ()
+	} else {
+		path := os.Getenv("PATH")
+		if path == "" {
+			path = "/usr/local/bin:/usr/bin:/bin"
+		}
+		home, _ := p.paths(req)
+		env = []string{"PATH=" + path, "HOME=" + home[0], "LANG=C.UTF-8"}
+	}
+	keys := make([]string, 0, len(req.Env))
+	for k := range req.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+req.Env[k])
+	}
+	return env
+}
+
+func (p Profile) ulimitPrefix() string {
+	var b strings.Builder
+	if p.CPUSeconds > 0 {
+		fmt.Fprintf(&b, "ulimit -t %d; ", p.CPUSeconds)
+	}
+	if p.MemoryMB > 0 {
+		fmt.Fprintf(&b, "ulimit -v %d; ", p.MemoryMB*1024)
+	}
+	if p.MaxProcesses > 0 {
+		fmt.Fprintf(&b, "ulimit -u %d 2>/dev/null; ", p.MaxProcesses)
+	}
+	return b.String()
+}
+
+// systemReadOnly are bind-mounted read-only into nsjail so ordinary programs work.
+var systemReadOnly = []string{"/bin", "/sbin", "/usr", "/lib", "/lib64", "/etc/alternatives", "/etc/ssl", "/etc/resolv.conf", "/etc/hosts"}
+
+// NsjailArgs returns the nsjail command line for a profile.
+func (p Profile) NsjailArgs(writable, readOnly []string, workDir string, env []string, command string) []string {
+	args := []string{"-Mo", "--quiet", "--user", "65534", "--group", "65534"}
+	if p.TimeoutSec > 0 {
+		args = append(args, "--time_limit", fmt.Sprint(p.TimeoutSec))
+	}
+	if p.CPUSeconds > 0 {
+		args = append(args, "--rlimit_cpu", fmt.Sprint(p.CPUSeconds))
+	}
+	if p.MemoryMB > 0 {
+		args = append(args, "--rlimit_as", fmt.Sprint(p.MemoryMB))
+	}
+	if p.MaxProcesses > 0 {
+		args = append(args, "--rlimit_nproc", fmt.Sprint(p.MaxProcesses))
+	}
+	if p.Network {
+		args = append(args, "--disable_clone_newnet")
+	}
+	for _, dir := range systemReadOnly {
+		if _, err := os.Stat(dir); err == nil {
+			args = append(args, "-R", dir)
+		}
+	}
+	for _, dir := range readOnly {
+		args = append(args, "-R", dir)
+	}
+	for _, dir := range writable {
+		args = append(args, "-B", dir)
+	}
+	for _, kv := range env {
+		args = append(args, "-E", kv)
+	}
+	return append(args, "--cwd", workDir, "--", "/bin/sh", "-c", command)
+}
+
+// DockerArgs returns the docker run command line for a profile. Paths are mounted at the same
+// location inside the container so work_dir needs no translation.
+func (p Profile) DockerArgs(writable, readOnly []string, workDir string, env []string, command string) []string {
+	args := []string{"run", "--rm", "-i", "--user", "65534:65534"}
+	if !p.Network {
+		args = append(args, "--network", "none")
+	}
+	if p.MemoryMB > 0 {
+		args = append(args, "--memory", fmt.Sprintf("%dm", p.MemoryMB))
+	}
+	if p.MaxProcesses > 0 {
+		args = append(args, "--pids-limit", fmt.Sprint(p.MaxProcesses))
+	}
+	if p.CPUSeconds > 0 {
+		args = append(args, "--ulimit", fmt.Sprintf("cpu=%d", p.CPUSeconds))
+	}
+	for _, dir := range readOnly {
+		args = append(args, "-v", dir+":"+dir+":ro")
+	}
+	for _, dir := range writable {
+		args = append(args, "-v", dir+":"+dir)
+	}
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			continue // keep the image's PATH
+		}
+		args = append(args, "-e", kv)
+	}
+	image := p.Image
+	if image == "" {
+		image = "alpine:3"
+	}
+	return append(args, "-w", workDir, image, "sh", "-c", command)
+}
+
+// lookPath finds sandbox programs; tests replace it.
+var lookPath = exec.LookPath
+
+// detectBackend returns the isolating backend that is installed, "" when there is none.
+func detectBackend() string {
+	if _, err := lookPath("nsjail"); err == nil {
+		return BackendNsjail
+	}
+	if _, err := lookPath("docker"); err == nil {
+		return BackendDocker
+	}
+	return ""
+}
+
+func mkdirAll(dirs []string) error {
+	for _, d := range dirs {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return fmt.Errorf("sandbox: %w", err)
+		}
+	}
+	return nil
+}
+
+// within reports whether path is one of roots or below one.
+func within(path string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// safeName keeps user IDs usable as a single path element.
+func safeName(id string) string {
+	if id == "" {
+		return "anonymous"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == '.' || r == ':' {
+			return '_'
+		}
+		return r
+	}, id)
+}
+
+// CommandBinaries returns the program names invoked by a shell command line: the first word of each
+// pipeline stage, list element, and command substitution, skipping VAR=value assignments and
+// common prefix builtins.
+func CommandBinaries(command string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, seg := range splitCommands(command) {
+		fields := strings.Fields(seg)
+		for len(fields) > 0 {
+			f := strings.Trim(fields[0], `"'(){}`)
+			if f == "" || (strings.Contains(f, "=") && !strings.HasPrefix(f, "=")) || commandPrefixes[f] {
+				fields = fields[1:]
+				continue
+			}
+			if blockKeywords[f] {
+				break
+			}
+			if !seen[f] {
+				seen[f] = true
+				out = append(out, f)
+			}
+			break
+		}
+	}
+	return out
+}
+
+func splitCommands(command string) []string {
+	replacer := strings.NewReplacer("&&", "\n", "||", "\n", "|", "\n", ";", "\n", "&", "\n", "$(", "\n", "`", "\n", "\r", "\n")
+	return strings.Split(replacer.Replace(command), "\n")
+}
+
+// commandPrefixes are followed by the command that actually runs.
+var commandPrefixes = map[string]bool{
+	"!": true, "if": true, "elif": true, "while": true, "until": true, "then": true, "do": true, "else": true,
+	"exec": true, "env": true, "command": true, "nohup": true, "time": true,
+}
+
+// blockKeywords start or end a compound statement without naming a program.
+var blockKeywords = map[string]bool{
+	"fi": true, "for": true, "done": true, "case": true, "esac": true, "in": true, "function": true,
+}
@@ -0,0 +1,166 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommandBinaries(t *testing.T) {
+	got := CommandBinaries(`FOO=1 cat a.txt | grep x && echo "$(date)"; env curl http://x`)
+	want := []string{"cat", "grep", "echo", "date", "curl"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CommandBinaries = %v, want %v", got, want)
+	}
+}
+
+func TestForTrust(t *testing.T) {
+	cfg := DefaultConfig()
+	cases := map[string]string{"admin": "none", "trusted": "restricted", "restricted": "restricted", "tool_repair": "build", "": "restricted"}
+	for trust, want := range cases {
+		if name, _ := cfg.ForTrust(trust); name != want {
+			t.Errorf("ForTrust(%q) = %s, want %s", trust, name, want)
+		}
+	}
+	cfg.ByTrust["default"] = "missing"
+	if name, p := cfg.ForTrust("trusted"); name != "restricted" || p.Network {
+		t.Errorf("unknown profile should fail closed to restricted, got %s", name)
+	}
+}
+
+func TestLoadOverridesDefaults(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"profiles": {"dev": {"backend": "direct", "network": true}}, "by_trust": {"trusted": "dev"}}`
+	if err := os.WriteFile(filepath.Join(dir, configFile), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := cfg.ForTrust("trusted"); name != "dev" {
+		t.Errorf("trusted -> %s, want dev", name)
+	}
+	if name, _ := cfg.ForTrust("restricted"); name != "restricted" {
+		t.Errorf("restricted -> %s, want restricted (default kept)", name)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, configFile), []byte(`{"by_trust": {"trusted": "nope"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ws := t.TempDir()
+	p := DefaultConfig().Profiles["restricted"]
+	req := Request{Workspace: ws, UserID: "bob", Command: "ls -la | wc -l"}
+
+	workDir, err := p.Check(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(ws, "sandboxes", "bob"); workDir != want {
+		t.Errorf("work dir = %s, want %s", workDir, want)
+	}
+
+	req.Command = "curl http://example.com"
+	if _, err := p.Check(req); err == nil || !strings.Contains(err.Error(), "curl") {
+		t.Errorf("expected curl to be rejected, got %v", err)
+	}
+
+	req.Command = "ls"
+	for _, dir := range []string{"sandboxes/alice", "../etc", "/etc"} {
+		req.WorkDir = dir
+		if _, err := p.Check(req); err == nil {
+			t.Errorf("work_dir %s should be outside the allowed paths", dir)
+		}
+	}
+}
+
+func TestBackendArgs(t *testing.T) {
+	p := DefaultConfig().Profiles["restricted"]
+	env := []string{"PATH=/usr/bin", "FOO=bar"}
+
+	docker := strings.Join(p.DockerArgs([]string{"/ws/sandboxes/bob"}, nil, "/ws/sandboxes/bob", env, "ls"), " ")
+	for _, want := range []string{"--network none", "--memory 512m", "--pids-limit 64", "-v /ws/sandboxes/bob:/ws/sandboxes/bob", "-e FOO=bar", "-w /ws/sandboxes/bob"} {
+		if !strings.Contains(docker, want) {
+			t.Errorf("docker args missing %q: %s", want, docker)
+		}
+	}
+	if strings.Contains(docker, "PATH=") {
+		t.Errorf("docker args should keep the image PATH: %s", docker)
+	}
+
+	nsjail := strings.Join(p.NsjailArgs([]string{"/ws/sandboxes/bob"}, nil, "/ws/sandboxes/bob", env, "ls"), " ")
+	for _, want := range []string{"--rlimit_cpu 30", "--rlimit_as 512", "--time_limit 60", "-B /ws/sandboxes/bob", "-E FOO=bar"} {
+		if !strings.Contains(nsjail, want) {
+			t.Errorf("nsjail args missing %q: %s", want, nsjail)
+		}
+	}
+	if strings.Contains(nsjail, "--disable_clone_newnet") {
+		t.Errorf("restricted profile must not share the host network: %s", nsjail)
+	}
+}
+
+func TestDirectBackendScrubsEnv(t *testing.T) {
+	t.Setenv("HATTIEBOT_SANDBOX_SECRET", "leak")
+	ws := t.TempDir()
+	p := DefaultConfig().Profiles["restricted"]
+	p.Backend = BackendDirect
+	req := Request{Workspace: ws, UserID: "bob", Command: `echo "$HATTIEBOT_SANDBOX_SECRET:$GREETING"; pwd`, Env: map[string]string{"GREETING": "hi"}}
+
+	workDir, err := p.Check(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := p.Command(context.Background(), req, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 || lines[0] != ":hi" {
+		t.Errorf("output = %q, want host env hidden and env_vars passed", out)
+	}
+	if _, err := os.Stat(workDir); err != nil {
+		t.Errorf("work dir not created: %v", err)
+	}
+}
+
+func TestAutoFailsClosedWithoutIsolation(t *testing.T) {
+	defer func(orig func(string) (string, error)) { lookPath = orig }(lookPath)
+	lookPath = func(string) (string, error) { return "", os.ErrNotExist }
+	ws := t.TempDir()
+	req := Request{Workspace: ws, UserID: "bob", Command: "ls"}
+
+	restricted := DefaultConfig().Profiles["restricted"]
+	if _, err := restricted.Command(context.Background(), req, ws); err != ErrNoIsolation {
+		t.Errorf("restricted without nsjail or docker: err = %v, want ErrNoIsolation", err)
+	}
+	if got := restricted.BackendName(); got != "unavailable" {
+		t.Errorf("BackendName = %s, want unavailable", got)
+	}
+	standard := DefaultConfig().Profiles["standard"]
+	if got := standard.BackendName(); got != BackendDirect {
+		t.Errorf("standard allows direct, got %s", got)
+	}
+}
+
+func TestRestrictedAllowsNoInterpreters(t *testing.T) {
+	p := DefaultConfig().Profiles["restricted"]
+	ws := t.TempDir()
+	for _, cmd := range []string{`sh -c 'curl http://x'`, `python3 -c 'import os'`, `find . -exec curl {} \;`, `ls | xargs curl`, `awk 'BEGIN{system("id")}'`, `go run x.go`} {
+		if _, err := p.Check(Request{Workspace: ws, UserID: "bob", Command: cmd}); err == nil {
+			t.Errorf("%s should be rejected", cmd)
+		}
+	}
+}
@@ -10,10 +10,42 @@ import (
 	"github.com/hattiebot/hattiebot/internal/store"
 )
 
-// EscalationMonitor checks for overdue plans and blocked jobs, escalating them if needed.
+// Escalation step targets.
+const (
+	EscalateUser  = "user"
+	EscalateAdmin = "admin"
+	EscalateBoth  = "both"
+)
+
+// EscalationStep is one link of an escalation chain: After the escalation started, Target is
+// told with Urgency (which the notification rules route, e.g. urgent to the urgent channel).
+type EscalationStep struct {
+	After   time.Duration
+	Target  string
+	Urgency string
+}
+
+// DefaultEscalationChain notifies the user at once, the admin after adminAfter, and both on
+// their urgent channel after urgentAfter.
+func DefaultEscalationChain(adminAfter, urgentAfter time.Duration) []EscalationStep {
+	return []EscalationStep{
+		{After: 0, Target: EscalateUser, Urgency: store.UrgencyNormal},
+		{After: adminAfter, Target: EscalateAdmin, Urgency: store.UrgencyHigh},
+		{After: urgentAfter, Target: EscalateBoth, Urgency: store.UrgencyUrgent},
+	}
+}
+
+// EscalationMonitor checks for overdue plans and walks each through the escalation chain until
+// the plan runs or someone replies "ack" (see store.AcknowledgeEscalations).
 type EscalationMonitor struct {
-	DB      *store.DB
-	Router  *gateway.Router
+	DB     *store.DB
+	Router *gateway.Router
+	// AdminUserID receives the admin steps (default "admin").
+	AdminUserID string
+	// Overdue is how late a plan must be to start escalating (default 1h).
+	Overdue time.Duration
+	// Chain is the escalation chain (default DefaultEscalationChain(30m, 1h)).
+	Chain []EscalationStep
 }
 
 // Start begins a periodic check.
@@ -34,39 +66,85 @@ func (e *EscalationMonitor) Start(ctx context.Context, interval time.Duration) {
 	}()
 }
 
-// CheckAndEscalate finds items needing attention.
+// CheckAndEscalate finds overdue plans, sends the chain steps that have come due for each, and
+// closes the escalations of plans that are no longer overdue.
 func (e *EscalationMonitor) CheckAndEscalate(ctx context.Context) error {
-	// 1. Check Overdue Scheduled Plans
-	// Use GetDuePlans which returns active plans with next_run_at <= Now
-	// We might want plans overdue by a threshold.
-	// But GetDuePlans returns anything ready. "Overdue" implies ignored.
-	// Let's filter in memory for now: if NextRunAt is < (Now - 1h).
-	
 	duePlans, err := e.DB.GetDuePlans(ctx)
 	if err != nil {
 		return err
 	}
 
+	overdue := e.Overdue
+	if overdue <= 0 {
+		overdue = time.Hour
+	}
+	chain := e.Chain
+	if len(chain) == 0 {
+		chain = DefaultEscalationChain(30*time.Minute, time.Hour)
+	}
 	now := time.Now()
-	threshold := now.Add(-1 * time.Hour)
+	threshold := now.Add(-overdue)
 
+	stillOverdue := map[int64]bool{}
 	for _, p := range duePlans {
-		if p.NextRunAt == nil {
+		if p.NextRunAt == nil || !p.NextRunAt.Before(threshold) {
 			continue
 		}
-		
-		if p.NextRunAt.Before(threshold) {
-			// Overdue logic
-			msg := fmt.Sprintf("Plan #%d '%s' is overdue (was set for %s).", p.ID, p.Description, p.NextRunAt.Format(time.RFC3339))
-			
-			log.Printf("[ESCALATION] Escalating overdue plan %d", p.ID)
-			if e.Router != nil {
-				if err := e.Router.RouteMessage(ctx, "admin", msg, "urgent"); err != nil {
-					log.Printf("[ESCALATION] Failed to route message: %v", err)
-				}
-			}
+		stillOverdue[p.ID] = true
+		subject := fmt.Sprintf("Plan #%d '%s' is overdue (was set for %s).", p.ID, p.Description, p.NextRunAt.Format(time.RFC3339))
+		esc, err := e.DB.OpenEscalation(ctx, "plan", p.ID, p.UserID, subject)
+		if err != nil {
+			return err
+		}
+		if esc.AckedAt != nil {
+			continue
+		}
+		// Send only the latest step that has come due; earlier ones it skipped are covered by it.
+		step := esc.Step
+		for step < len(chain) && !now.Before(esc.StartedAt.Add(chain[step].After)) {
+			step++
+		}
+		if step == esc.Step {
+			continue
+		}
+		s := chain[step-1]
+		log.Printf("[ESCALATION] Escalating overdue plan %d (escalation %d, step %d: %s)", p.ID, esc.ID, step, s.Target)
+		e.notify(ctx, esc, s)
+		if err := e.DB.AdvanceEscalation(ctx, esc.ID, step, s.Target != EscalateUser); err != nil {
+			return err
 		}
 	}
 
+	if n, err := e.DB.ResolveEscalations(ctx, "plan", stillOverdue); err != nil {
+		return err
+	} else if n > 0 {
+		log.Printf("[ESCALATION] Closed %d escalation(s) of plans no longer overdue", n)
+	}
 	return nil
 }
+
+// notify sends one chain step's message to its targets.
+func (e *EscalationMonitor) notify(ctx context.Context, esc *store.Escalation, s EscalationStep) {
+	if e.Router == nil {
+		return
+	}
+	admin := e.AdminUserID
+	if admin == "" {
+		admin = "admin"
+	}
+	targets := map[string]string{}
+	if s.Target == EscalateUser || s.Target == EscalateBoth {
+		targets[esc.UserID] = fmt.Sprintf("[Escalation #%d] %s Reply \"ack\" to acknowledge.", esc.ID, esc.Subject)
+	}
+	if s.Target == EscalateAdmin && admin == esc.UserID {
+		targets[esc.UserID] = fmt.Sprintf("[Escalation #%d] %s Reply \"ack\" to acknowledge.", esc.ID, esc.Subject)
+	} else if (s.Target == EscalateAdmin || s.Target == EscalateBoth) && admin != esc.UserID {
+		targets[admin] = fmt.Sprintf("[Escalation #%d] %s %s has not acknowledged it for %s. Reply \"ack %d\" to acknowledge.",
+			esc.ID, esc.Subject, esc.UserID, time.Since(esc.StartedAt).Round(time.Minute), esc.ID)
+	}
+	for userID, msg := range targets {
+		if err := e.Router.RouteMessage(ctx, userID, msg, s.Urgency); err != nil {
+			log.Printf("[ESCALATION] Failed to route message to %s: %v", userID, err)
+		}
+	}
+}
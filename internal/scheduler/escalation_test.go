@@ -3,6 +3,8 @@ package scheduler
 
 import (
 	"context"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,7 +34,7 @@ func TestEscalationMonitor(t *testing.T) {
 	// 1. Create Overdue Plan
 	// created_at = now-2h, next_run = now-2h
 	past := time.Now().Add(-2 * time.Hour)
-	_, err = db.CreatePlan(ctx, "test-user", "Overdue Task", "remind", "", "once", past.Format(time.RFC3339), past)
+	_, err = db.CreatePlan(ctx, "test-user", "Overdue Task", "remind", "", "once", past.Format(time.RFC3339), "", past)
 	if err != nil {
 		t.Fatalf("Failed to create plan: %v", err)
 	}
@@ -62,3 +64,82 @@ func (m *MockChannel) Name() string { return m.name }
 func (m *MockChannel) Start(ctx context.Context, ingress chan<- gateway.Message) error { return nil }
 func (m *MockChannel) Send(msg gateway.Message) error { return nil }
 func (m *MockChannel) SendProactive(userID, content string) error { return nil }
+
+type recordingChannel struct {
+	MockChannel
+	sent []string // "userID: content"
+}
+
+func (c *recordingChannel) SendProactive(userID, content string) error {
+	c.sent = append(c.sent, userID+": "+content)
+	return nil
+}
+
+func TestEscalationChain(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	gw := gateway.New(func(ctx context.Context, msg gateway.Message) (string, error) { return "", nil })
+	ch := &recordingChannel{MockChannel: MockChannel{name: "admin_term"}}
+	gw.Register(ch)
+	monitor := &EscalationMonitor{
+		DB:          db,
+		Router:      gateway.NewRouter(gw, db),
+		AdminUserID: "boss",
+		Chain:       DefaultEscalationChain(20*time.Millisecond, time.Hour),
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	planID, err := db.CreatePlan(ctx, "alice", "Water plants", "remind", "", "once", past.Format(time.RFC3339), "", past)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Step 1 tells the user; checking again before step 2 is due sends nothing.
+	for i := 0; i < 2; i++ {
+		if err := monitor.CheckAndEscalate(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(ch.sent) != 1 || !strings.HasPrefix(ch.sent[0], "alice: [Escalation #1] Plan #") {
+		t.Fatalf("after step 1 sent %q", ch.sent)
+	}
+
+	// Step 2 tells the admin.
+	time.Sleep(30 * time.Millisecond)
+	if err := monitor.CheckAndEscalate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.sent) != 2 || !strings.HasPrefix(ch.sent[1], "boss: [Escalation #1]") || !strings.Contains(ch.sent[1], "alice has not acknowledged") {
+		t.Fatalf("after step 2 sent %q", ch.sent)
+	}
+
+	// Someone else cannot acknowledge it; the admin can, which stops the chain.
+	if acked, _ := db.AcknowledgeEscalations(ctx, "mallory", false, 0); len(acked) != 0 {
+		t.Errorf("mallory acknowledged %+v", acked)
+	}
+	if acked, err := db.AcknowledgeEscalations(ctx, "boss", true, 1); err != nil || len(acked) != 1 {
+		t.Fatalf("admin ack = %+v, %v", acked, err)
+	}
+	monitor.Chain[2].After = 0
+	if err := monitor.CheckAndEscalate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.sent) != 2 {
+		t.Errorf("escalated after ack: %q", ch.sent)
+	}
+
+	// Once the plan is no longer overdue the escalation closes.
+	if err := db.MarkPlanRun(ctx, planID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := monitor.CheckAndEscalate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if open, _ := db.ListEscalations(ctx, true); len(open) != 0 {
+		t.Errorf("open escalations = %+v", open)
+	}
+}
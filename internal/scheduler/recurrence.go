@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // plan time zones must resolve in slim containers without system zoneinfo
+
+	"github.com/hattiebot/hattiebot/internal/timeparse"
+)
+
+// Rule is a recurring schedule evaluated on the wall clock of its time zone, so a daily 09:00
+// plan stays at 09:00 local time across DST changes instead of drifting by an hour.
+//
+// Schedule values by type:
+//
+//	hourly    ignored; runs one hour after the previous run
+//	daily     "09:00"
+//	weekdays  "09:00" (Monday to Friday)
+//	weekly    "mon 09:00", "mon,thu 09:00", or "09:00" (same weekday as the previous run)
+//	monthly   "15 09:00" (clamped to the month's last day) or "last 09:00"
+type Rule struct {
+	Type     string
+	Hour     int
+	Minute   int
+	Weekdays []time.Weekday // weekly: days to run on; weekdays: Monday-Friday
+	MonthDay int            // monthly: 1-31, or -1 for the last day of the month
+	Location *time.Location
+}
+
+// RecurringTypes lists the schedule types ParseRule accepts.
+var RecurringTypes = []string{"hourly", "daily", "weekdays", "weekly", "monthly"}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// LoadLocation resolves a plan's IANA time zone name; empty means the server's local zone.
+func LoadLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", tz)
+	}
+	return loc, nil
+}
+
+// ParseRule parses a recurring schedule type and value (see Rule) in the given time zone.
+func ParseRule(scheduleType, value, tz string) (Rule, error) {
+	loc, err := LoadLocation(tz)
+	if err != nil {
+		return Rule{}, err
+	}
+	r := Rule{Type: scheduleType, Location: loc}
+	if scheduleType == "hourly" {
+		return r, nil
+	}
+
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) == 0 {
+		return Rule{}, fmt.Errorf("%s schedule needs a time like 09:00", scheduleType)
+	}
+	r.Hour, r.Minute, err = timeparse.Clock(fields[len(fields)-1])
+	if err != nil {
+		return Rule{}, err
+	}
+	prefix := fields[:len(fields)-1]
+
+	switch scheduleType {
+	case "daily":
+		if len(prefix) > 0 {
+			return Rule{}, fmt.Errorf("daily schedule takes only a time, got %q", value)
+		}
+	case "weekdays":
+		if len(prefix) > 0 {
+			return Rule{}, fmt.Errorf("weekdays schedule takes only a time, got %q", value)
+		}
+		r.Weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	case "weekly":
+		if len(prefix) > 1 {
+			return Rule{}, fmt.Errorf("invalid weekly schedule %q (use e.g. \"mon,thu 09:00\")", value)
+		}
+		if len(prefix) == 1 {
+			for _, name := range strings.Split(prefix[0], ",") {
+				name = strings.TrimSpace(name)
+				if len(name) > 3 {
+					name = name[:3] // "monday" -> "mon"
+				}
+				d, ok := weekdayNames[name]
+				if !ok {
+					return Rule{}, fmt.Errorf("unknown weekday %q", name)
+				}
+				r.Weekdays = append(r.Weekdays, d)
+			}
+		}
+	case "monthly":
+		if len(prefix) != 1 {
+			return Rule{}, fmt.Errorf("invalid monthly schedule %q (use e.g. \"15 09:00\" or \"last 09:00\")", value)
+		}
+		if prefix[0] == "last" {
+			r.MonthDay = -1
+		} else if n, err := strconv.Atoi(prefix[0]); err == nil && n >= 1 && n <= 31 {
+			r.MonthDay = n
+		} else {
+			return Rule{}, fmt.Errorf("invalid day of month %q (use 1-31 or last)", prefix[0])
+		}
+	default:
+		return Rule{}, fmt.Errorf("unknown schedule type %q (use once, %s)", scheduleType, strings.Join(RecurringTypes, ", "))
+	}
+	return r, nil
+}
+
+// Next returns the first run strictly after the given instant. Local times skipped by a DST
+// change run at the equivalent instant after the jump; repeated ones run once.
+func (r Rule) Next(after time.Time) time.Time {
+	if r.Type == "hourly" {
+		return after.Add(time.Hour)
+	}
+	loc := r.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	a := after.In(loc)
+
+	if r.Type == "monthly" {
+		for i := 0; i <= 12; i++ {
+			first := time.Date(a.Year(), a.Month()+time.Month(i), 1, 0, 0, 0, 0, loc)
+			last := time.Date(first.Year(), first.Month()+1, 0, 0, 0, 0, 0, loc).Day()
+			day := r.MonthDay
+			if day < 0 || day > last {
+				day = last
+			}
+			if t := time.Date(first.Year(), first.Month(), day, r.Hour, r.Minute, 0, 0, loc); t.After(after) {
+				return t
+			}
+		}
+		return time.Time{}
+	}
+
+	days := r.Weekdays
+	if r.Type == "weekly" && len(days) == 0 {
+		days = []time.Weekday{a.Weekday()}
+	}
+	for i := 0; i <= 8; i++ {
+		t := time.Date(a.Year(), a.Month(), a.Day()+i, r.Hour, r.Minute, 0, 0, loc)
+		if t.After(after) && runsOn(days, t.Weekday()) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func runsOn(days []time.Weekday, d time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, w := range days {
+		if w == d {
+			return true
+		}
+	}
+	return false
+}
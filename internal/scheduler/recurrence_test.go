@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func mustLoc(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("tzdata for %s unavailable: %v", name, err)
+	}
+	return loc
+}
+
+func TestDailyKeepsWallClockAcrossDST(t *testing.T) {
+	ny := mustLoc(t, "America/New_York")
+	rule, err := ParseRule("daily", "09:00", "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Spring forward on 2026-03-08 and fall back on 2026-11-01.
+	for _, start := range []time.Time{
+		time.Date(2026, 3, 6, 9, 0, 0, 0, ny),
+		time.Date(2026, 10, 30, 9, 0, 0, 0, ny),
+	} {
+		at := start
+		for i := 0; i < 4; i++ {
+			at = rule.Next(at)
+			if l := at.In(ny); l.Hour() != 9 || l.Minute() != 0 {
+				t.Fatalf("run %d after %s = %s, want 09:00 local", i, start, l)
+			}
+		}
+		if want := start.AddDate(0, 0, 4); !at.Equal(want) {
+			t.Errorf("four runs from %s ended at %s, want %s", start, at.In(ny), want)
+		}
+	}
+}
+
+func TestSkippedAndRepeatedLocalTimes(t *testing.T) {
+	ny := mustLoc(t, "America/New_York")
+
+	// 02:30 does not exist on 2026-03-08; the run still happens that day, once.
+	rule, _ := ParseRule("daily", "02:30", "America/New_York")
+	next := rule.Next(time.Date(2026, 3, 7, 12, 0, 0, 0, ny))
+	if l := next.In(ny); l.Day() != 8 {
+		t.Errorf("skipped time ran on %s, want 2026-03-08", l)
+	}
+	if after := rule.Next(next); after.In(ny).Day() != 9 || after.In(ny).Hour() != 2 {
+		t.Errorf("run after the gap = %s, want 2026-03-09 02:30", after.In(ny))
+	}
+
+	// 01:30 happens twice on 2026-11-01; the plan fires once that day.
+	rule, _ = ParseRule("daily", "01:30", "America/New_York")
+	first := rule.Next(time.Date(2026, 10, 31, 12, 0, 0, 0, ny))
+	second := rule.Next(first)
+	if first.In(ny).Day() != 1 || second.In(ny).Day() != 2 {
+		t.Errorf("repeated hour runs = %s, %s; want Nov 1 then Nov 2", first.In(ny), second.In(ny))
+	}
+}
+
+func TestWeeklyAndWeekdays(t *testing.T) {
+	berlin := mustLoc(t, "Europe/Berlin")
+	// Friday 2026-03-27 10:00; Berlin springs forward on Sunday 2026-03-29.
+	fri := time.Date(2026, 3, 27, 10, 0, 0, 0, berlin)
+
+	rule, err := ParseRule("weekdays", "08:00", "Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rule.Next(fri), time.Date(2026, 3, 30, 8, 0, 0, 0, berlin); !got.Equal(want) {
+		t.Errorf("weekdays after Friday = %s, want %s", got, want)
+	}
+
+	rule, err = ParseRule("weekly", "Mon,Thursday 18:30", "Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := rule.Next(fri)
+	if want := time.Date(2026, 3, 30, 18, 30, 0, 0, berlin); !got.Equal(want) {
+		t.Errorf("weekly mon,thu = %s, want %s", got, want)
+	}
+	if got, want := rule.Next(got), time.Date(2026, 4, 2, 18, 30, 0, 0, berlin); !got.Equal(want) {
+		t.Errorf("weekly mon,thu second = %s, want %s", got, want)
+	}
+
+	rule, _ = ParseRule("weekly", "10:00", "Europe/Berlin")
+	if got, want := rule.Next(fri), time.Date(2026, 4, 3, 10, 0, 0, 0, berlin); !got.Equal(want) {
+		t.Errorf("bare weekly = %s, want %s (same weekday next week, same local time)", got, want)
+	}
+}
+
+func TestMonthlyClampsToMonthEnd(t *testing.T) {
+	loc := mustLoc(t, "Europe/Berlin")
+	rule, err := ParseRule("monthly", "31 09:00", "Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 1, 31, 9, 0, 0, 0, loc)
+	var days []int
+	for i := 0; i < 3; i++ {
+		at = rule.Next(at)
+		days = append(days, at.Day())
+	}
+	if days[0] != 28 || days[1] != 31 || days[2] != 30 {
+		t.Errorf("day 31 runs = %v, want [28 31 30]", days)
+	}
+
+	rule, _ = ParseRule("monthly", "last 23:00", "Europe/Berlin")
+	got := rule.Next(time.Date(2028, 2, 1, 0, 0, 0, 0, loc))
+	if want := time.Date(2028, 2, 29, 23, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("last day in leap February = %s, want %s", got, want)
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, c := range []struct{ typ, value, tz string }{
+		{"daily", "", ""},
+		{"daily", "25:00", ""},
+		{"weekly", "funday 09:00", ""},
+		{"monthly", "09:00", ""},
+		{"monthly", "32 09:00", ""},
+		{"daily", "09:00", "Mars/Olympus"},
+		{"yearly", "09:00", ""},
+	} {
+		if _, err := ParseRule(c.typ, c.value, c.tz); err == nil {
+			t.Errorf("ParseRule(%q, %q, %q) succeeded, want error", c.typ, c.value, c.tz)
+		}
+	}
+}
+
+func TestNextPlanRun(t *testing.T) {
+	now := time.Now()
+	if nextPlanRun(store.ScheduledPlan{ScheduleType: "once"}, now) != nil {
+		t.Error("once plan should complete")
+	}
+	next := nextPlanRun(store.ScheduledPlan{ScheduleType: "daily", ScheduleValue: "07:15", Timezone: "UTC"}, now)
+	if next == nil || next.UTC().Hour() != 7 || next.UTC().Minute() != 15 || !next.After(now) {
+		t.Errorf("daily next = %v, want next 07:15 UTC", next)
+	}
+	if next := nextPlanRun(store.ScheduledPlan{ScheduleType: "daily", ScheduleValue: "bogus"}, now); next == nil {
+		t.Error("invalid rule should retry, not complete")
+	}
+}
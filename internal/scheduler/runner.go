@@ -5,11 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/events"
 	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/health"
 	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tracing"
 )
 
 // Runner checks for due plans and executes them.
@@ -18,7 +22,18 @@ type Runner struct {
 	ToolExecutor core.ToolExecutor
 	Router       *gateway.Router // For proactive reminder delivery
 	Interval     time.Duration
-	stop         chan struct{}
+	// Throttle defers agent_prompt plans while the error budget is exhausted.
+	Throttle interface{ Throttled() bool }
+	// Briefings delivers "briefing" plans and returns the run summary.
+	Briefings interface {
+		RunPlan(ctx context.Context, p store.ScheduledPlan) (string, error)
+	}
+	// Events receives a plan.executed event for each plan run; nil publishes nothing.
+	Events *events.Bus
+	stop   chan struct{}
+
+	mu       sync.RWMutex
+	lastTick time.Time
 }
 
 func NewRunner(db *store.DB) *Runner {
@@ -49,13 +64,50 @@ func (r *Runner) Start() {
 	}()
 }
 
+// LastTick returns when the scheduler last checked for due plans (zero if it has not ticked yet).
+func (r *Runner) LastTick() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastTick
+}
+
+// HealthCheck reports the scheduler loop as stalled ("error") when it has not ticked for three
+// intervals (at least five minutes), and "unknown" before its first tick.
+func (r *Runner) HealthCheck() health.ComponentHealth {
+	h := health.ComponentHealth{Name: "scheduler", Status: "ok"}
+	last := r.LastTick()
+	if last.IsZero() {
+		h.Status, h.Message = "unknown", "not ticked yet"
+		return h
+	}
+	h.LastOK = last
+	stall := 3 * r.Interval
+	if stall < 5*time.Minute {
+		stall = 5 * time.Minute
+	}
+	if age := time.Since(last); age > stall {
+		h.Status, h.Message = "error", fmt.Sprintf("last tick %s ago (interval %s)", age.Round(time.Second), r.Interval)
+	} else {
+		h.Message = fmt.Sprintf("last tick %s ago", age.Round(time.Second))
+	}
+	return h
+}
+
 // Stop halts the scheduler.
 func (r *Runner) Stop() {
 	close(r.stop)
 }
 
 func (r *Runner) checkAndRun() {
+	r.mu.Lock()
+	r.lastTick = time.Now()
+	r.mu.Unlock()
+
 	ctx := context.Background()
+	if r.Router != nil {
+		r.Router.DeliverHeld(ctx) // messages held for quiet hours that have ended
+		r.Router.DeliverDigests(ctx)
+	}
 	// Lock for 5 minutes (if crash, other nodes pick up after 5m)
 	plans, err := r.DB.ClaimDuePlans(ctx, 5*time.Minute)
 	if err != nil {
@@ -64,19 +116,72 @@ func (r *Runner) checkAndRun() {
 	}
 
 	for _, p := range plans {
+		if p.ActionType == "agent_prompt" && r.Throttle != nil && r.Throttle.Throttled() {
+			log.Printf("[SCHEDULER] Self-throttling: deferring agent prompt plan %d by %s", p.ID, throttleDeferral)
+			if err := r.DB.DeferPlan(ctx, p.ID, time.Now().Add(throttleDeferral)); err != nil {
+				log.Printf("[SCHEDULER] Error deferring plan %d: %v", p.ID, err)
+			}
+			continue
+		}
 		log.Printf("[SCHEDULER] Executing plan %d: %s (%s)", p.ID, p.Description, p.ActionType)
 		r.executePlan(ctx, p)
+		r.Events.Publish(ctx, events.PlanExecuted, p.UserID, map[string]interface{}{
+			"plan_id":       p.ID,
+			"action_type":   p.ActionType,
+			"description":   p.Description,
+			"schedule_type": p.ScheduleType,
+		})
 
 		// Mark as run (updates next_run_at for recurring)
-		if err := r.DB.MarkPlanRun(ctx, p.ID, p.ScheduleType); err != nil {
+		if err := r.DB.MarkPlanRun(ctx, p.ID, nextPlanRun(p, time.Now())); err != nil {
 			log.Printf("[SCHEDULER] Error marking plan %d as run: %v", p.ID, err)
 		}
 	}
 }
 
+// throttleDeferral is how long agent_prompt plans wait while the bot is self-throttling.
+const throttleDeferral = 15 * time.Minute
+
+// nextPlanRun computes a plan's next run from its recurrence rule; nil means the plan is done.
+// A plan whose rule no longer parses falls back to running again in a day rather than stopping.
+func nextPlanRun(p store.ScheduledPlan, now time.Time) *time.Time {
+	if p.ScheduleType == "once" {
+		return nil
+	}
+	rule, err := ParseRule(p.ScheduleType, p.ScheduleValue, p.Timezone)
+	if err != nil {
+		log.Printf("[SCHEDULER] Plan %d has an invalid schedule (%v); retrying in 24h", p.ID, err)
+		next := now.Add(24 * time.Hour)
+		return &next
+	}
+	next := rule.Next(now)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
 func (r *Runner) executePlan(ctx context.Context, p store.ScheduledPlan) {
 	// Inject user_id from the plan into context so tool policies work
 	ctx = context.WithValue(ctx, "user_id", p.UserID)
+	// and the owner's role and trust, so role-gated tools are checked as if the owner called them;
+	// an owner who no longer exists gets neither
+	var role, trust string
+	if u, err := r.DB.GetUser(ctx, p.UserID); err == nil && u != nil {
+		role, trust = u.Role, u.TrustLevel
+	} else if err != nil {
+		log.Printf("[SCHEDULER] Plan %d: loading owner %s: %v", p.ID, p.UserID, err)
+	}
+	ctx = context.WithValue(ctx, "user_role", role)
+	ctx = context.WithValue(ctx, "user_trust", trust)
+	// Confirm-gated tools run unasked only for preapproved plans (see middleware.Drafts)
+	ctx = context.WithValue(ctx, "plan_id", p.ID)
+	// Attribute any LLM usage from tool execution (e.g. sub-minds) to this plan
+	ctx = core.WithUsageRecorder(ctx, r.DB.UsageRecorder(p.UserID, "scheduler", 0, p.ID))
+	// agent_prompt runs are traced by the gateway as their own turn, with the same plan_id
+	ctx, span := tracing.Start(ctx, "scheduler.run")
+	span.Set("plan_id", p.ID).Set("action", p.ActionType).Set("user", p.UserID)
+	defer span.End()
 
 	switch p.ActionType {
 	case "remind":
@@ -119,6 +224,7 @@ func (r *Runner) executePlan(ctx context.Context, p store.ScheduledPlan) {
 			return
 		}
 		result, err := r.ToolExecutor.Execute(ctx, payload.Tool, string(payload.Args))
+		span.Set("tool.name", payload.Tool).SetError(err)
 
 		var msg string
 		if err != nil {
@@ -141,6 +247,7 @@ func (r *Runner) executePlan(ctx context.Context, p store.ScheduledPlan) {
 				log.Printf("[SCHEDULER] Invalid agent_prompt payload for plan %d: %v", p.ID, err)
 				errMsg := fmt.Sprintf("[Scheduled Task] Error: Invalid agent_prompt payload - %v", err)
 				r.DB.InsertMessage(ctx, "assistant", errMsg, "", "system", "scheduler", "scheduler", "", "", "")
+				r.recordRun(ctx, p, store.RunFailed, errMsg)
 				return
 			}
 		}
@@ -151,16 +258,41 @@ func (r *Runner) executePlan(ctx context.Context, p store.ScheduledPlan) {
 		if r.Router == nil {
 			log.Printf("[SCHEDULER] Router not configured, cannot push agent prompt")
 			r.DB.InsertMessage(ctx, "assistant", "[Scheduled Task] Error: Router not configured", "", "system", "scheduler", "scheduler", "", "", "")
+			r.recordRun(ctx, p, store.RunFailed, "router not configured")
 			return
 		}
 		if !r.Router.PushAgentPrompt(ctx, p.UserID, payload.Prompt, payload.Autonomous, p.ID) {
 			log.Printf("[SCHEDULER] Ingress buffer full, agent prompt dropped for plan %d", p.ID)
 			r.DB.InsertMessage(ctx, "assistant", "[Scheduled Task] Error: Ingress buffer full, task deferred", "", "system", "scheduler", "scheduler", "", "", "")
+			r.recordRun(ctx, p, store.RunSkipped, "ingress buffer full; the agent never saw this run")
 		}
 
+	case "briefing":
+		if r.Briefings == nil {
+			log.Printf("[SCHEDULER] Briefings not configured, skipping briefing plan %d", p.ID)
+			r.recordRun(ctx, p, store.RunSkipped, "briefings not configured")
+			return
+		}
+		summary, err := r.Briefings.RunPlan(ctx, p)
+		if err != nil {
+			log.Printf("[SCHEDULER] Briefing plan %d failed: %v", p.ID, err)
+			span.SetError(err)
+			r.recordRun(ctx, p, store.RunFailed, err.Error())
+			return
+		}
+		r.recordRun(ctx, p, store.RunSucceeded, summary)
+
 	default:
 		log.Printf("[SCHEDULER] Unknown action type: %s", p.ActionType)
 		msg := fmt.Sprintf("[Scheduled Task] Unknown action type: %s", p.ActionType)
 		r.DB.InsertMessage(ctx, "assistant", msg, "", "system", "scheduler", "scheduler", "", "", "")
 	}
 }
+
+// recordRun stores the result of a briefing, or of an agent_prompt run that never reached the
+// agent. Runs that do reach it are recorded by the agent loop.
+func (r *Runner) recordRun(ctx context.Context, p store.ScheduledPlan, status, summary string) {
+	if err := r.DB.RecordPlanRun(ctx, p.ID, p.UserID, status, summary); err != nil {
+		log.Printf("[SCHEDULER] Error recording run of plan %d: %v", p.ID, err)
+	}
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// ctxRecorder records the role and trust each tool call runs with.
+type ctxRecorder struct {
+	role, trust string
+	hasRole     bool
+}
+
+func (c *ctxRecorder) Execute(ctx context.Context, name, args string) (string, error) {
+	c.role, c.hasRole = ctx.Value("user_role").(string)
+	c.trust, _ = ctx.Value("user_trust").(string)
+	return "{}", nil
+}
+
+func (c *ctxRecorder) SetSpawner(core.SubmindSpawner) {}
+
+func TestExecutePlan_runsToolsAsThePlanOwner(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.GetOrCreateUser(ctx, "bob", "Bob", "admin_term"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateUserTrust(ctx, "bob", "trusted"); err != nil {
+		t.Fatal(err)
+	}
+	rec := &ctxRecorder{}
+	r := NewRunner(db)
+	r.ToolExecutor = rec
+
+	plan := store.ScheduledPlan{ID: 1, UserID: "bob", ActionType: "execute_tool", ActionPayload: `{"tool":"delete_tool","args":{}}`, ScheduleType: "once", NextRunAt: &time.Time{}}
+	r.executePlan(ctx, plan)
+	if !rec.hasRole || rec.role != store.RoleUser || rec.trust != "trusted" {
+		t.Errorf("tool ran with role %q (set %v), trust %q; want bob's user/trusted", rec.role, rec.hasRole, rec.trust)
+	}
+
+	// An owner that no longer exists gets no privileges, but is still role-gated
+	plan.UserID = "gone"
+	r.executePlan(ctx, plan)
+	if !rec.hasRole || rec.role != "" || rec.trust != "" {
+		t.Errorf("missing owner: role %q (set %v), trust %q", rec.role, rec.hasRole, rec.trust)
+	}
+}
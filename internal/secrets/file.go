@@ -0,0 +1,253 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// fileFormatVersion is bumped if the envelope or key derivation changes.
+const fileFormatVersion = 1
+
+// scrypt parameters for deriving the AES-256 key from the key file or passphrase.
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// encryptedFile is the on-disk envelope; the plaintext is a JSON object of name -> value.
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// FileSecretStore keeps secrets in a local AES-256-GCM encrypted file, for installs without the
+// Nextcloud Passwords app. The key is derived with scrypt from a passphrase or a key file.
+type FileSecretStore struct {
+	Path string
+
+	mu      sync.Mutex
+	secret  []byte // passphrase or key file contents
+	salt    []byte // salt the cached key was derived with
+	derived []byte
+}
+
+// OpenFileSecretStore opens (or prepares to create) the encrypted store at path. The passphrase
+// is used when set; otherwise the key file is read, and generated with a random key if missing.
+// An existing store is decrypted once so a wrong key fails at startup, not on first use.
+func OpenFileSecretStore(path, keyFile, passphrase string) (*FileSecretStore, error) {
+	secret := []byte(passphrase)
+	if passphrase == "" {
+		if keyFile == "" {
+			return nil, fmt.Errorf("secrets file needs a key file or passphrase")
+		}
+		var err error
+		if secret, err = loadOrCreateKeyFile(keyFile); err != nil {
+			return nil, err
+		}
+	}
+	s := &FileSecretStore{Path: path, secret: secret}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func loadOrCreateKeyFile(keyFile string) ([]byte, error) {
+	data, err := os.ReadFile(keyFile)
+	if err == nil {
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return nil, fmt.Errorf("key file %s is empty", keyFile)
+		}
+		return []byte(key), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	key := hex.EncodeToString(raw)
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, []byte(key+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("write key file: %w", err)
+	}
+	return []byte(key), nil
+}
+
+// GetSecret returns the value stored under key, matching names case-insensitively if there is
+// no exact match.
+func (s *FileSecretStore) GetSecret(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	if v, ok := all[key]; ok {
+		return v, nil
+	}
+	for name, v := range all {
+		if strings.EqualFold(name, key) {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("secret not found: %s", key)
+}
+
+// SetSecret stores value under key, replacing any previous value.
+func (s *FileSecretStore) SetSecret(key, value string) error {
+	if strings.TrimSpace(key) == "" {
+		return fmt.Errorf("secret name required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	all[key] = value
+	return s.save(all)
+}
+
+// DeleteSecret removes key; it reports an error if there was no such secret.
+func (s *FileSecretStore) DeleteSecret(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := all[key]; !ok {
+		return fmt.Errorf("secret not found: %s", key)
+	}
+	delete(all, key)
+	return s.save(all)
+}
+
+// List returns the stored secret names, sorted. Values are never listed.
+func (s *FileSecretStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// load must be called with s.mu held. A missing file is an empty store.
+func (s *FileSecretStore) load() (map[string]string, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secrets file: %w", err)
+	}
+	var env encryptedFile
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("parse secrets file: %w", err)
+	}
+	if env.Version != fileFormatVersion || env.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported secrets file version %d (%s)", env.Version, env.KDF)
+	}
+	gcm, err := s.cipher(env.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, env.Nonce, env.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt secrets file: wrong key or corrupted file")
+	}
+	all := map[string]string{}
+	if err := json.Unmarshal(plain, &all); err != nil {
+		return nil, fmt.Errorf("parse decrypted secrets: %w", err)
+	}
+	return all, nil
+}
+
+// save must be called with s.mu held. It writes a temp file and renames it into place.
+func (s *FileSecretStore) save(all map[string]string) error {
+	salt := s.salt
+	if salt == nil {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+	}
+	gcm, err := s.cipher(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	plain, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(encryptedFile{
+		Version:    fileFormatVersion,
+		KDF:        "scrypt",
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plain, nil),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write secrets file: %w", err)
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+// cipher returns AES-GCM keyed from the secret and salt; the derived key is cached per salt
+// because scrypt is deliberately slow.
+func (s *FileSecretStore) cipher(salt []byte) (cipher.AEAD, error) {
+	if s.derived == nil || string(s.salt) != string(salt) {
+		key, err := scrypt.Key(s.secret, salt, scryptN, scryptR, scryptP, scryptKeyLen)
+		if err != nil {
+			return nil, err
+		}
+		s.salt, s.derived = append([]byte(nil), salt...), key
+	}
+	block, err := aes.NewCipher(s.derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
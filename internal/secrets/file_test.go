@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileSecretStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path, keyFile := filepath.Join(dir, "secrets.enc"), filepath.Join(dir, "secrets.key")
+
+	s, err := OpenFileSecretStore(path, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("key file not generated with 0600: %v %v", info, err)
+	}
+	if err := s.SetSecret("GitHub Token", "ghp-value-123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSecret("Other", "other-value"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "ghp-value-123") || strings.Contains(string(data), "GitHub Token") {
+		t.Fatal("secrets file is not encrypted")
+	}
+
+	// Reopen with the same generated key file.
+	s, err = OpenFileSecretStore(path, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.GetSecret("github token"); err != nil || v != "ghp-value-123" {
+		t.Errorf("GetSecret = %q, %v", v, err)
+	}
+	if err := s.DeleteSecret("Other"); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := s.List(); !reflect.DeepEqual(names, []string{"GitHub Token"}) {
+		t.Errorf("List = %v", names)
+	}
+	if _, err := s.GetSecret("Other"); err == nil {
+		t.Error("deleted secret still readable")
+	}
+}
+
+func TestFileSecretStoreWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	s, err := OpenFileSecretStore(path, "", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSecret("db", "s3cret-db-pass"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileSecretStore(path, "", "wrong horse"); err == nil {
+		t.Error("expected wrong passphrase to fail at open")
+	}
+	if _, err := OpenFileSecretStore(path, "", ""); err == nil {
+		t.Error("expected error without key file or passphrase")
+	}
+}
+
+func TestMultiStoreResolve(t *testing.T) {
+	local, err := OpenFileSecretStore(filepath.Join(t.TempDir(), "secrets.enc"), "", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = local.SetSecret("api", "local-api-value")
+	_ = local.SetSecret("odd:name", "colon-value")
+	t.Setenv("RESOLVE_TEST_VAR", "env-value")
+
+	ms := NewMultiStore()
+	ms.Register("env", &EnvSecretStore{})
+	ms.Register("local", local)
+	ms.Default = "local"
+
+	for ref, want := range map[string]string{
+		"api":                  "local-api-value",
+		"local:api":            "local-api-value",
+		"env:RESOLVE_TEST_VAR": "env-value",
+		"odd:name":             "colon-value", // unregistered prefix: whole ref is the key
+	} {
+		if got, err := ms.Resolve(ref); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+}
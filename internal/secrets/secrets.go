@@ -3,10 +3,12 @@ package secrets
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/redact"
 	"github.com/hattiebot/hattiebot/internal/tools/nextcloud"
 )
 
@@ -15,6 +17,14 @@ type SecretStore interface {
 	GetSecret(key string) (string, error)
 }
 
+// SecretWriter is implemented by stores the bot can write to (the local encrypted file).
+type SecretWriter interface {
+	SecretStore
+	SetSecret(key, value string) error
+	DeleteSecret(key string) error
+	List() ([]string, error)
+}
+
 // EnvSecretStore reads from environment variables.
 type EnvSecretStore struct{}
 
@@ -75,6 +85,8 @@ func (s *NextcloudSecretStore) GetSecret(key string) (string, error) {
 // MultiStore combines stores.
 type MultiStore struct {
 	stores map[string]SecretStore
+	// Default is the source for references without a "source:" prefix ("passwords" if unset).
+	Default string
 }
 
 func NewMultiStore() *MultiStore {
@@ -87,10 +99,41 @@ func (m *MultiStore) Register(source string, store SecretStore) {
 	m.stores[source] = store
 }
 
+// Source returns the store registered for source, or nil.
+func (m *MultiStore) Source(source string) SecretStore {
+	return m.stores[source]
+}
+
+// DefaultSource returns the source used for unprefixed references.
+func (m *MultiStore) DefaultSource() string {
+	if m.Default == "" {
+		return "passwords"
+	}
+	return m.Default
+}
+
+// Resolve looks up a secret reference: "source:key" for a registered source (e.g. "env:SMTP_PASS",
+// "local:GitHub Token", "vault:hattiebot/github#token"), otherwise key in the default source.
+func (m *MultiStore) Resolve(ref string) (string, error) {
+	source, key := m.DefaultSource(), ref
+	if prefix, rest, ok := strings.Cut(ref, ":"); ok {
+		if _, registered := m.stores[prefix]; registered {
+			source, key = prefix, rest
+		}
+	}
+	return m.GetSecret(source, key)
+}
+
+// GetSecret resolves key from source. Resolved values are registered for redaction so they
+// never reach logs, stored messages, or the LLM.
 func (m *MultiStore) GetSecret(source, key string) (string, error) {
 	s, ok := m.stores[source]
 	if !ok {
 		return "", fmt.Errorf("unknown secret source: %s", source)
 	}
-	return s.GetSecret(key)
+	val, err := s.GetSecret(key)
+	if err == nil {
+		redact.AddSecret(val)
+	}
+	return val, err
 }
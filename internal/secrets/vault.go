@@ -0,0 +1,184 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultConfig configures the HashiCorp Vault source. Token auth is used when Token is set,
+// otherwise AppRole (RoleID + SecretID).
+type VaultConfig struct {
+	Addr      string
+	Token     string
+	RoleID    string
+	SecretID  string
+	Mount     string // KV v2 mount (default "secret")
+	Namespace string // Vault Enterprise namespace (optional)
+}
+
+// VaultSecretStore reads secrets from a Vault KV v2 engine. Keys are "path#field", e.g.
+// "hattiebot/github#webhook_secret"; without "#field" the secret must have a single field
+// (or a field named "value"). Values are cached for TTL.
+type VaultSecretStore struct {
+	Config VaultConfig
+	Client *http.Client
+	TTL    time.Duration
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time // zero: does not expire (static token)
+	cache       map[string]cachedSecret
+}
+
+// NewVaultSecretStore returns a Vault store with a 5 minute cache.
+func NewVaultSecretStore(cfg VaultConfig) *VaultSecretStore {
+	if cfg.Mount == "" {
+		cfg.Mount = "secret"
+	}
+	return &VaultSecretStore{
+		Config: cfg,
+		Client: &http.Client{Timeout: 15 * time.Second},
+		TTL:    5 * time.Minute,
+		cache:  make(map[string]cachedSecret),
+	}
+}
+
+func (s *VaultSecretStore) GetSecret(key string) (string, error) {
+	path, field, _ := strings.Cut(key, "#")
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "", fmt.Errorf("vault secret path required (path#field)")
+	}
+
+	s.mu.Lock()
+	cached, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.ExpiresAt) {
+		return cached.Value, nil
+	}
+
+	data, err := s.read(path)
+	if err != nil {
+		return "", err
+	}
+	val, err := pickField(data, path, field)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.cache[key] = cachedSecret{Value: val, ExpiresAt: time.Now().Add(s.TTL)}
+	s.mu.Unlock()
+	return val, nil
+}
+
+func pickField(data map[string]interface{}, path, field string) (string, error) {
+	if field == "" {
+		if len(data) == 1 {
+			for _, v := range data {
+				return fmt.Sprint(v), nil
+			}
+		}
+		field = "value"
+	}
+	v, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %q", path, field)
+	}
+	return fmt.Sprint(v), nil
+}
+
+// read fetches the latest version of a KV v2 secret, logging in again once if the token was
+// rejected (expired AppRole token).
+func (s *VaultSecretStore) read(path string) (map[string]interface{}, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := s.authToken()
+		if err != nil {
+			return nil, err
+		}
+		url := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(s.Config.Addr, "/"), strings.Trim(s.Config.Mount, "/"), path)
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("X-Vault-Token", token)
+		if s.Config.Namespace != "" {
+			req.Header.Set("X-Vault-Namespace", s.Config.Namespace)
+		}
+		resp, err := s.Client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("vault request: %w", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusForbidden && attempt == 0 && s.Config.Token == "" {
+			s.mu.Lock()
+			s.token = ""
+			s.mu.Unlock()
+			continue
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("vault secret not found: %s", path)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("vault error %d reading %s", resp.StatusCode, path)
+		}
+		var out struct {
+			Data struct {
+				Data map[string]interface{} `json:"data"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &out); err != nil {
+			return nil, fmt.Errorf("parse vault response: %w", err)
+		}
+		return out.Data.Data, nil
+	}
+}
+
+// authToken returns the static token, or a cached AppRole token, logging in when needed.
+func (s *VaultSecretStore) authToken() (string, error) {
+	if s.Config.Token != "" {
+		return s.Config.Token, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && (s.tokenExpiry.IsZero() || time.Now().Before(s.tokenExpiry)) {
+		return s.token, nil
+	}
+	if s.Config.RoleID == "" || s.Config.SecretID == "" {
+		return "", fmt.Errorf("vault auth not configured (set a token or AppRole role_id and secret_id)")
+	}
+	payload, _ := json.Marshal(map[string]string{"role_id": s.Config.RoleID, "secret_id": s.Config.SecretID})
+	req, _ := http.NewRequest("POST", strings.TrimRight(s.Config.Addr, "/")+"/v1/auth/approle/login", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if s.Config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.Config.Namespace)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault approle login: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault approle login failed: status %d", resp.StatusCode)
+	}
+	var out struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault approle login: no token in response")
+	}
+	s.token = out.Auth.ClientToken
+	s.tokenExpiry = time.Time{}
+	if out.Auth.LeaseDuration > 0 {
+		// Renew a little early so a request never races the expiry.
+		s.tokenExpiry = time.Now().Add(time.Duration(out.Auth.LeaseDuration)*time.Second - 30*time.Second)
+	}
+	return s.token, nil
+}
@@ -0,0 +1,73 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeVault serves one KV v2 secret at secret/data/app/github and AppRole login.
+func fakeVault(t *testing.T, logins *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["role_id"] != "role" || body["secret_id"] != "sid" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			*logins++
+			_, _ = w.Write([]byte(`{"auth": {"client_token": "approle-token", "lease_duration": 3600}}`))
+		case "/v1/secret/data/app/github":
+			if tok := r.Header.Get("X-Vault-Token"); tok != "static-token" && tok != "approle-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"data": {"data": {"webhook_secret": "whsec-value", "token": "gh-token-value"}, "metadata": {"version": 3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestVaultSecretStoreToken(t *testing.T) {
+	var logins int
+	srv := fakeVault(t, &logins)
+	defer srv.Close()
+
+	s := NewVaultSecretStore(VaultConfig{Addr: srv.URL, Token: "static-token"})
+	if v, err := s.GetSecret("app/github#webhook_secret"); err != nil || v != "whsec-value" {
+		t.Errorf("GetSecret = %q, %v", v, err)
+	}
+	if _, err := s.GetSecret("app/github"); err == nil {
+		t.Error("expected error: no field given and secret has several fields")
+	}
+	if _, err := s.GetSecret("app/missing#x"); err == nil {
+		t.Error("expected not found")
+	}
+	if _, err := s.GetSecret("app/github#nope"); err == nil {
+		t.Error("expected missing field error")
+	}
+}
+
+func TestVaultSecretStoreAppRoleViaMultiStore(t *testing.T) {
+	var logins int
+	srv := fakeVault(t, &logins)
+	defer srv.Close()
+
+	ms := NewMultiStore()
+	ms.Register("vault", NewVaultSecretStore(VaultConfig{Addr: srv.URL, RoleID: "role", SecretID: "sid"}))
+	for i := 0; i < 2; i++ {
+		if v, err := ms.Resolve("vault:app/github#token"); err != nil || v != "gh-token-value" {
+			t.Fatalf("Resolve = %q, %v", v, err)
+		}
+	}
+	if _, err := ms.Resolve("vault:app/github#webhook_secret"); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 1 {
+		t.Errorf("logged in %d times, want 1 (token reused)", logins)
+	}
+}
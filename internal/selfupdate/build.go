@@ -0,0 +1,299 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/sandbox"
+)
+
+// Build defaults.
+const (
+	DefaultBuildTimeout = 10 * time.Minute
+	DefaultTestTimeout  = 15 * time.Minute
+	// outputTail is how much of the build and test output a result keeps.
+	outputTail = 8 << 10
+)
+
+// Builder builds and tests a commit of the source checkout.
+type Builder struct {
+	SourceDir string // git checkout of HattieBot's source
+	Dir       string // self-update directory
+	// Sandbox runs go build and go test; the zero profile runs them directly. A sandboxed build
+	// may write only to the self-update work directory.
+	Sandbox sandbox.Profile
+	GoBin   string // default "go"
+	GoCache string // GOCACHE for the build; default work/cache
+	// GoModCache is mounted read-only as GOMODCACHE in a sandboxed build, which has no network to
+	// download modules.
+	GoModCache   string
+	BuildTimeout time.Duration
+	TestTimeout  time.Duration
+}
+
+// BuildResult is the outcome of a build.
+type BuildResult struct {
+	Ref        string    `json:"ref"`
+	Commit     string    `json:"commit,omitempty"`
+	Subject    string    `json:"subject,omitempty"`
+	Stage      string    `json:"stage"` // where it stopped: export, build, test, or staged
+	OK         bool      `json:"ok"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output,omitempty"` // tail of the failing step's output
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+	Manifest   *Manifest `json:"manifest,omitempty"`
+	TestsTaken string    `json:"tests_duration,omitempty"`
+}
+
+// Build exports ref (default HEAD), builds ./cmd/hattiebot, runs go test ./..., and on success
+// writes the binary and its manifest to staged/, replacing (and unapproving) any staged build.
+// The live checkout is never built in place, so uncommitted edits do not reach the binary.
+func (b *Builder) Build(ctx context.Context, ref string) *BuildResult {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	res := &BuildResult{Ref: ref, Stage: "export", Started: time.Now().UTC()}
+	fail := func(err error, output string) *BuildResult {
+		res.Error, res.Output, res.Finished = err.Error(), tail(output), time.Now().UTC()
+		return res
+	}
+	if b.SourceDir == "" {
+		return fail(fmt.Errorf("no source checkout configured (set HATTIEBOT_GIT_REPO_DIR)"), "")
+	}
+	commit, err := b.git(ctx, "rev-parse", "--verify", ref+"^{commit}")
+	if err != nil {
+		return fail(err, "")
+	}
+	res.Commit = commit
+	res.Subject, _ = b.git(ctx, "log", "-1", "--format=%s", commit)
+
+	work := filepath.Join(b.Dir, WorkDir)
+	src := filepath.Join(work, "src")
+	if err := os.RemoveAll(src); err != nil {
+		return fail(err, "")
+	}
+	if err := os.MkdirAll(filepath.Join(work, "out"), 0755); err != nil {
+		return fail(err, "")
+	}
+	if err := b.export(ctx, commit, src); err != nil {
+		return fail(err, "")
+	}
+	defer os.RemoveAll(src)
+
+	res.Stage = "build"
+	out := filepath.Join(work, "out", BinaryName)
+	os.Remove(out)
+	ldflags := "-X github.com/hattiebot/hattiebot/internal/version.Version=" + shortSHA(commit)
+	cmd := fmt.Sprintf("%s build -trimpath -ldflags %s -o %s ./cmd/hattiebot", b.goBin(), shellQuote(ldflags), shellQuote(out))
+	if output, err := b.run(ctx, work, src, cmd, timeoutOr(b.BuildTimeout, DefaultBuildTimeout)); err != nil {
+		return fail(fmt.Errorf("go build: %w", err), output)
+	}
+
+	res.Stage = "test"
+	start := time.Now()
+	if output, err := b.run(ctx, work, src, b.goBin()+" test -count=1 ./...", timeoutOr(b.TestTimeout, DefaultTestTimeout)); err != nil {
+		return fail(fmt.Errorf("go test: %w", err), output)
+	}
+	res.TestsTaken = time.Since(start).Round(time.Second).String()
+
+	res.Stage = "staged"
+	m, err := b.stage(ctx, out, commit, ref, res.Subject)
+	if err != nil {
+		return fail(err, "")
+	}
+	res.OK, res.Manifest, res.Finished = true, m, time.Now().UTC()
+	return res
+}
+
+// stage copies the built binary into staged/ with its manifest.
+func (b *Builder) stage(ctx context.Context, bin, commit, ref, subject string) (*Manifest, error) {
+	staged := filepath.Join(b.Dir, StagedDir)
+	if err := os.RemoveAll(staged); err != nil {
+		return nil, err
+	}
+	if err := copyFile(bin, filepath.Join(staged, BinaryName), 0755); err != nil {
+		return nil, fmt.Errorf("stage binary: %w", err)
+	}
+	sum, err := FileSHA256(filepath.Join(staged, BinaryName))
+	if err != nil {
+		return nil, err
+	}
+	goVersion, _ := exec.CommandContext(ctx, b.goBin(), "env", "GOVERSION").Output()
+	m := &Manifest{Commit: commit, Ref: ref, Subject: subject, SHA256: sum, BuiltAt: time.Now().UTC(),
+		GoVersion: strings.TrimSpace(string(goVersion)), Tests: "passed"}
+	return m, WriteManifest(staged, m)
+}
+
+func (b *Builder) goBin() string {
+	if b.GoBin != "" {
+		return b.GoBin
+	}
+	return "go"
+}
+
+func (b *Builder) goCache(work string) string {
+	if b.GoCache != "" {
+		return b.GoCache
+	}
+	return filepath.Join(work, "cache")
+}
+
+// git runs git in the source checkout and returns its trimmed output.
+func (b *Builder) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = b.SourceDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// export writes the tree of commit to dir with git archive.
+func (b *Builder) export(ctx context.Context, commit, dir string) error {
+	cmd := exec.CommandContext(ctx, "git", "archive", "--format=tar", commit)
+	cmd.Dir = b.SourceDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	extractErr := untar(stdout, dir)
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git archive: %s", strings.TrimSpace(stderr.String()))
+	}
+	return extractErr
+}
+
+// untar extracts regular files, directories and symlinks, refusing paths that leave dir.
+func untar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+		path := filepath.Join(dir, hdr.Name)
+		if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("archive entry %q leaves the build directory", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0755|0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// run runs a shell command in src under the sandbox profile, with work as the sandbox workspace
+// (the build tree, caches and output all live there). It returns the combined output.
+func (b *Builder) run(ctx context.Context, work, src, command string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.Sandbox.Timeout(timeout))
+	defer cancel()
+	req := sandbox.Request{
+		Workspace: work,
+		WorkDir:   src,
+		Command:   command,
+		// cgo stays at the toolchain default: a binary built without it cannot load plugins
+		Env: map[string]string{
+			"GOCACHE": b.goCache(work),
+			"GOFLAGS": "-buildvcs=false",
+		},
+	}
+	profile := b.Sandbox
+	if profile.BackendName() != sandbox.BackendNone {
+		profile.WritablePaths = nil // the whole work directory, and nothing else
+		profile.ReadOnlyPaths = append([]string(nil), profile.ReadOnlyPaths...)
+		if b.GoModCache != "" {
+			profile.ReadOnlyPaths = append(profile.ReadOnlyPaths, b.GoModCache)
+			req.Env["GOMODCACHE"] = b.GoModCache
+			req.Env["GOPROXY"] = "off"
+		}
+		req.Env["GOTOOLCHAIN"] = "local"
+	}
+	workDir, err := profile.Check(req)
+	if err != nil {
+		return "", err
+	}
+	cmd, err := profile.Command(ctx, req, workDir)
+	if err != nil {
+		return "", err
+	}
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return output.String(), fmt.Errorf("timed out after %s", b.Sandbox.Timeout(timeout))
+	}
+	return output.String(), err
+}
+
+func timeoutOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+func shortSHA(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// tail keeps the end of long output, where the errors are.
+func tail(s string) string {
+	if len(s) <= outputTail {
+		return s
+	}
+	return "…" + s[len(s)-outputTail:]
+}
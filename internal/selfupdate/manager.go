@@ -0,0 +1,220 @@
+package selfupdate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/version"
+)
+
+// restartDelay lets the tool's reply reach the user before the bot goes down.
+const restartDelay = 3 * time.Second
+
+// Manager is the bot's side of self-updates: it runs builds in the background and asks the
+// supervisor (or the restart command) to install or roll back.
+type Manager struct {
+	Builder *Builder
+	// RestartCommand is run (sh -c) to restart the bot when it is not a child of the supervisor,
+	// e.g. a call to a host hook that runs docker restart. The supervisor is still what installs
+	// the approved binary, on its next start.
+	RestartCommand string
+	// Notify tells the user who started a build how it went.
+	Notify func(userID, msg string)
+
+	mu       sync.Mutex
+	building *BuildResult
+	last     *BuildResult
+}
+
+// StartBuild builds ref in the background; the result goes to Notify and Status.
+func (m *Manager) StartBuild(ref, userID string) (*BuildResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.building != nil {
+		return nil, fmt.Errorf("a build of %s is already running (started %s)", m.building.Ref, m.building.Started.Format(time.RFC3339))
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	m.building = &BuildResult{Ref: ref, Stage: "export", Started: time.Now().UTC()}
+	started := *m.building
+	go func() {
+		res := m.Builder.Build(context.Background(), ref)
+		m.mu.Lock()
+		m.building, m.last = nil, res
+		m.mu.Unlock()
+		if m.Notify != nil && userID != "" {
+			m.Notify(userID, Summary(res))
+		}
+	}()
+	return &started, nil
+}
+
+// Summary describes a finished build for a chat message.
+func Summary(res *BuildResult) string {
+	if res.OK {
+		return fmt.Sprintf("[Self-update] Commit %s (%s) built and passed its tests in %s. It is staged; apply it with self_update action=apply.",
+			shortSHA(res.Commit), res.Subject, res.Finished.Sub(res.Started).Round(time.Second))
+	}
+	msg := fmt.Sprintf("[Self-update] Build of %s failed at the %s step: %s", res.Ref, res.Stage, res.Error)
+	if res.Output != "" {
+		out := res.Output
+		if len(out) > 1500 {
+			out = "…" + out[len(out)-1500:]
+		}
+		msg += "\n\n" + out
+	}
+	return msg
+}
+
+// Status is the state of self-updates.
+type Status struct {
+	Version    string       `json:"version"`
+	Supervised bool         `json:"supervised"`
+	Building   *BuildResult `json:"building,omitempty"`
+	LastBuild  *BuildResult `json:"last_build,omitempty"`
+	Staged     *Manifest    `json:"staged,omitempty"`
+	Approved   bool         `json:"approved,omitempty"`
+	Active     *Manifest    `json:"active,omitempty"`
+	Previous   *Manifest    `json:"previous,omitempty"`
+	Probation  bool         `json:"probation,omitempty"`
+	Events     []Event      `json:"recent_events,omitempty"`
+}
+
+// Status reports the running version, the build in progress, and the binaries on disk.
+func (m *Manager) Status() *Status {
+	m.mu.Lock()
+	st := &Status{Version: version.Version, Supervised: supervisorPID() > 0, Building: m.building, LastBuild: m.last}
+	m.mu.Unlock()
+	dir := m.Builder.Dir
+	st.Staged, _ = ReadManifest(filepath.Join(dir, StagedDir))
+	st.Approved = exists(filepath.Join(dir, StagedDir, ApprovedName))
+	st.Active, _ = ReadManifest(filepath.Join(dir, ActiveDir))
+	st.Previous, _ = ReadManifest(filepath.Join(dir, PreviousDir))
+	if state, err := ReadState(dir); err == nil {
+		st.Probation = state.Probation
+		if n := len(state.Events); n > 5 {
+			st.Events = state.Events[n-5:]
+		} else {
+			st.Events = state.Events
+		}
+	}
+	return st
+}
+
+// Apply approves the staged binary (which must be commit, when given) and restarts the bot so the
+// supervisor installs it. It returns how the restart was requested.
+func (m *Manager) Apply(commit string) (string, error) {
+	staged := filepath.Join(m.Builder.Dir, StagedDir)
+	man, err := Verify(staged)
+	if err != nil {
+		return "", fmt.Errorf("nothing to apply: %w", err)
+	}
+	if commit != "" && !strings.HasPrefix(man.Commit, commit) {
+		return "", fmt.Errorf("the staged binary is commit %s, not %s", shortSHA(man.Commit), commit)
+	}
+	if man.Tests != "passed" {
+		return "", fmt.Errorf("the staged binary has not passed its tests")
+	}
+	if err := os.WriteFile(filepath.Join(staged, ApprovedName), []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return "", err
+	}
+	how, err := m.restart()
+	if err != nil {
+		return "", fmt.Errorf("commit %s is approved and will be installed when the supervisor next starts, but: %w", shortSHA(man.Commit), err)
+	}
+	return how, nil
+}
+
+// Rollback asks the supervisor to restore the previous binary (the image binary when there is
+// none) and restarts the bot.
+func (m *Manager) Rollback() (string, error) {
+	dir := m.Builder.Dir
+	if !exists(filepath.Join(dir, ActiveDir)) {
+		return "", fmt.Errorf("already running the image binary; nothing to roll back")
+	}
+	if err := os.WriteFile(filepath.Join(dir, RollbackName), []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return "", err
+	}
+	return m.restart()
+}
+
+// Discard removes the staged binary.
+func (m *Manager) Discard() error {
+	staged := filepath.Join(m.Builder.Dir, StagedDir)
+	if !exists(staged) {
+		return fmt.Errorf("nothing is staged")
+	}
+	return os.RemoveAll(staged)
+}
+
+// restart signals the supervisor, or runs RestartCommand, after restartDelay.
+func (m *Manager) restart() (string, error) {
+	if pid := supervisorPID(); pid > 0 {
+		time.AfterFunc(restartDelay, func() {
+			if err := syscall.Kill(pid, syscall.SIGUSR1); err != nil {
+				log.Printf("[SelfUpdate] Signal supervisor %d: %v", pid, err)
+			}
+		})
+		return "supervisor", nil
+	}
+	if m.RestartCommand != "" {
+		command := m.RestartCommand
+		time.AfterFunc(restartDelay, func() {
+			if out, err := exec.Command("sh", "-c", command).CombinedOutput(); err != nil {
+				log.Printf("[SelfUpdate] Restart command failed: %v: %s", err, strings.TrimSpace(string(out)))
+			}
+		})
+		return "restart_command", nil
+	}
+	return "", fmt.Errorf("not running under hattiebot-supervisor and HATTIEBOT_RESTART_COMMAND is not set")
+}
+
+// ReportEvents passes the supervisor events not reported yet to notify, oldest first. Called at
+// startup, so a rollback that happened while the bot was down reaches the admin.
+func (m *Manager) ReportEvents(notify func(msg string)) error {
+	dir := m.Builder.Dir
+	state, err := ReadState(dir)
+	if err != nil {
+		return err
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, ReportedName))
+	reported, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	if n := len(state.Events); n > 0 && state.Events[n-1].Seq < reported {
+		reported = 0 // state.json was recreated
+	}
+	last := reported
+	for _, ev := range state.Events {
+		if ev.Seq <= reported {
+			continue
+		}
+		switch ev.Kind {
+		case EventPromoted:
+			notify(fmt.Sprintf("[Self-update] Now running commit %s (%s). It is on probation until it has stayed up for a while.", shortSHA(ev.Commit), ev.Detail))
+		case EventRolledBack:
+			notify(fmt.Sprintf("[Self-update] Rolled back commit %s: %s.", shortSHA(ev.Commit), ev.Detail))
+		case EventImageChanged:
+			notify(fmt.Sprintf("[Self-update] Dropped self-update %s: %s.", shortSHA(ev.Commit), ev.Detail))
+		}
+		last = ev.Seq
+	}
+	if last == reported {
+		return nil
+	}
+	return writeFileAtomic(filepath.Join(dir, ReportedName), []byte(strconv.Itoa(last)+"\n"), 0644)
+}
+
+// supervisorPID returns the PID the supervisor left in the environment, or 0.
+func supervisorPID() int {
+	pid, _ := strconv.Atoi(os.Getenv(SupervisorEnv))
+	return pid
+}
@@ -0,0 +1,220 @@
+// Package selfupdate rebuilds HattieBot from its own source and swaps the new binary in safely.
+// A build exports a commit of the source checkout, runs go build and go test on it (optionally
+// under a sandbox profile), and stages the binary only when the tests pass. Applying approves the
+// staged binary and asks the supervisor (cmd/hattiebot-supervisor) to restart; the supervisor
+// promotes it, keeps the previous binary, and rolls back when the new one crash-loops during its
+// probation period.
+//
+// Layout of the self-update directory ($CONFIG_DIR/selfupdate):
+//
+//	staged/   hattiebot + manifest.json, written by a green build; "approved" marks it for install
+//	active/   the binary the supervisor runs instead of the image binary
+//	previous/ the binary active replaced, restored on rollback
+//	work/     build tree and Go build cache
+//	state.json          supervisor state and events (promotions, rollbacks)
+//	rollback            marker asking the supervisor to restore previous
+//	reported            sequence number of the last event the bot reported
+package selfupdate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Names inside the self-update directory.
+const (
+	StagedDir    = "staged"
+	ActiveDir    = "active"
+	PreviousDir  = "previous"
+	WorkDir      = "work"
+	BinaryName   = "hattiebot"
+	ManifestName = "manifest.json"
+	ApprovedName = "approved"
+	RollbackName = "rollback"
+	StateName    = "state.json"
+	ReportedName = "reported"
+)
+
+// SupervisorEnv is set by the supervisor in the bot's environment to the supervisor's PID.
+const SupervisorEnv = "HATTIEBOT_SUPERVISOR_PID"
+
+// DirFor returns the self-update directory under a config dir.
+func DirFor(configDir string) string {
+	return filepath.Join(configDir, "selfupdate")
+}
+
+// Manifest describes a built binary.
+type Manifest struct {
+	Commit    string    `json:"commit"`
+	Ref       string    `json:"ref"`
+	Subject   string    `json:"subject,omitempty"`
+	SHA256    string    `json:"sha256"`
+	BuiltAt   time.Time `json:"built_at"`
+	GoVersion string    `json:"go_version,omitempty"`
+	Tests     string    `json:"tests"` // "passed"; only binaries that passed go test are staged
+	// ImageSHA256 is the hash of the image binary when the manifest was promoted to active; a
+	// different image binary (the container was upgraded) makes the supervisor ignore active.
+	ImageSHA256 string `json:"image_sha256,omitempty"`
+}
+
+// ReadManifest reads the manifest in dir; nil, nil when there is none.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, ManifestName), err)
+	}
+	return &m, nil
+}
+
+// WriteManifest writes m to dir atomically.
+func WriteManifest(dir string, m *Manifest) error {
+	data, _ := json.MarshalIndent(m, "", "  ")
+	return writeFileAtomic(filepath.Join(dir, ManifestName), data, 0644)
+}
+
+// Verify checks that dir holds a binary matching its manifest and returns the manifest.
+func Verify(dir string) (*Manifest, error) {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("no binary in %s", dir)
+	}
+	sum, err := FileSHA256(filepath.Join(dir, BinaryName))
+	if err != nil {
+		return nil, err
+	}
+	if sum != m.SHA256 {
+		return nil, fmt.Errorf("%s: binary does not match its manifest", dir)
+	}
+	return m, nil
+}
+
+// FileSHA256 returns the hex SHA-256 of a file.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Event is something the supervisor did, reported to the admin by the bot.
+type Event struct {
+	Seq    int       `json:"seq"`
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"` // promoted, confirmed, rolled_back, image_changed
+	Commit string    `json:"commit,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Event kinds.
+const (
+	EventPromoted     = "promoted"
+	EventConfirmed    = "confirmed"
+	EventRolledBack   = "rolled_back"
+	EventImageChanged = "image_changed"
+)
+
+// maxEvents is how many events state.json keeps.
+const maxEvents = 50
+
+// State is the supervisor's state.json.
+type State struct {
+	// Probation is set while the active binary has not yet run for the probation period.
+	Probation bool    `json:"probation"`
+	Crashes   int     `json:"crashes"`
+	Events    []Event `json:"events"`
+}
+
+// ReadState reads state.json; an empty state when there is none.
+func ReadState(dir string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(dir, StateName))
+	if os.IsNotExist(err) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return &State{}, err
+	}
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return &State{}, fmt.Errorf("%s: %w", StateName, err)
+	}
+	return &s, nil
+}
+
+func (s *State) write(dir string) error {
+	data, _ := json.MarshalIndent(s, "", "  ")
+	return writeFileAtomic(filepath.Join(dir, StateName), data, 0644)
+}
+
+func (s *State) add(kind, commit, detail string) {
+	seq := 1
+	if n := len(s.Events); n > 0 {
+		seq = s.Events[n-1].Seq + 1
+	}
+	s.Events = append(s.Events, Event{Seq: seq, Time: time.Now().UTC(), Kind: kind, Commit: commit, Detail: detail})
+	if len(s.Events) > maxEvents {
+		s.Events = s.Events[len(s.Events)-maxEvents:]
+	}
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// copyFile copies src to dst atomically with mode perm.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,231 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/sandbox"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=Dev", "-c", "user.email=dev@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	for _, tool := range []string{"git", "go"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skip(tool + " not installed")
+		}
+	}
+	if testing.Short() {
+		t.Skip("builds a module")
+	}
+	src := t.TempDir()
+	git(t, src, "init", "-b", "main")
+	writeFiles(t, src, map[string]string{
+		"go.mod":                      "module github.com/hattiebot/hattiebot\n\ngo 1.21\n",
+		"internal/version/version.go": "package version\n\nvar Version = \"dev\"\n",
+		"cmd/hattiebot/main.go":       "package main\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/hattiebot/hattiebot/internal/version\"\n)\n\nfunc main() { fmt.Println(version.Version) }\n",
+		"internal/version/v_test.go":  "package version\n\nimport \"testing\"\n\nfunc TestOK(t *testing.T) {}\n",
+	})
+	git(t, src, "add", ".")
+	git(t, src, "commit", "-m", "Initial commit")
+	commit := git(t, src, "rev-parse", "HEAD")
+	// Uncommitted edits must not reach the build
+	os.WriteFile(filepath.Join(src, "cmd/hattiebot/main.go"), []byte("package main\n\nfunc main() { broken"), 0644)
+
+	cache, _ := exec.Command("go", "env", "GOCACHE").Output()
+	b := &Builder{SourceDir: src, Dir: t.TempDir(), GoCache: strings.TrimSpace(string(cache))}
+	res := b.Build(context.Background(), "")
+	if !res.OK || res.Stage != "staged" || res.Commit != commit || res.Subject != "Initial commit" {
+		t.Fatalf("build = %+v", res)
+	}
+	m, err := Verify(filepath.Join(b.Dir, StagedDir))
+	if err != nil || m.Commit != commit || m.Tests != "passed" {
+		t.Fatalf("staged = %+v, %v", m, err)
+	}
+	out, err := exec.Command(filepath.Join(b.Dir, StagedDir, BinaryName)).Output()
+	if err != nil || strings.TrimSpace(string(out)) != commit[:12] {
+		t.Errorf("staged binary printed %q, %v", out, err)
+	}
+
+	// Under the build sandbox profile (direct here, as nsjail or docker may be missing) the work
+	// directory is writable though the profile's own paths are elsewhere
+	profile := sandbox.DefaultConfig().Profiles["build"]
+	profile.Backend = sandbox.BackendDirect
+	sandboxed := &Builder{SourceDir: src, Dir: t.TempDir(), GoCache: b.GoCache, Sandbox: profile}
+	if res := sandboxed.Build(context.Background(), commit); !res.OK {
+		t.Fatalf("sandboxed build = %+v", res)
+	}
+
+	git(t, src, "checkout", "--", ".")
+	writeFiles(t, src, map[string]string{"internal/version/v_test.go": "package version\n\nimport \"testing\"\n\nfunc TestOK(t *testing.T) { t.Fatal(\"boom\") }\n"})
+	git(t, src, "commit", "-am", "Break the tests")
+	res = b.Build(context.Background(), "main")
+	if res.OK || res.Stage != "test" || !strings.Contains(res.Output, "boom") {
+		t.Fatalf("failing build = %+v", res)
+	}
+	if m, _ := ReadManifest(filepath.Join(b.Dir, StagedDir)); m == nil || m.Commit != commit {
+		t.Errorf("a failed build replaced the staged binary: %+v", m)
+	}
+	if res := b.Build(context.Background(), "no-such-ref"); res.OK || res.Stage != "export" {
+		t.Errorf("unknown ref = %+v", res)
+	}
+}
+
+// stage writes a shell script as the staged binary and approves it.
+func stage(t *testing.T, dir, commit, script string) {
+	t.Helper()
+	staged := filepath.Join(dir, StagedDir)
+	writeFiles(t, staged, map[string]string{BinaryName: "#!/bin/sh\n" + script + "\n", ApprovedName: ""})
+	sum, _ := FileSHA256(filepath.Join(staged, BinaryName))
+	if err := WriteManifest(staged, &Manifest{Commit: commit, SHA256: sum, Tests: "passed"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func kinds(t *testing.T, dir string) string {
+	t.Helper()
+	state, err := ReadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var k []string
+	for _, ev := range state.Events {
+		k = append(k, ev.Kind)
+	}
+	return strings.Join(k, ",")
+}
+
+func TestSupervisor(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "selfupdate")
+	image := filepath.Join(root, "hattiebot")
+	writeFiles(t, root, map[string]string{"hattiebot": "#!/bin/sh\necho image\n"})
+	var stdout bytes.Buffer
+	s := &Supervisor{Dir: dir, ImageBinary: image, Probation: time.Minute, RestartDelay: time.Millisecond, Stdout: &stdout, Logf: t.Logf}
+	run := func(signals <-chan os.Signal) int {
+		t.Helper()
+		stdout.Reset()
+		code, err := s.Run(context.Background(), signals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	if code := run(nil); code != 0 || stdout.String() != "image\n" {
+		t.Fatalf("image run: %d %q", code, stdout.String())
+	}
+
+	// A good update is installed and runs
+	stage(t, dir, "aaaaaaaaaaaaaaaa", "echo good")
+	if code := run(nil); code != 0 || stdout.String() != "good\n" {
+		t.Fatalf("good update: %d %q", code, stdout.String())
+	}
+	if m, _ := ReadManifest(filepath.Join(dir, ActiveDir)); m == nil || m.Commit != "aaaaaaaaaaaaaaaa" || m.ImageSHA256 == "" {
+		t.Errorf("active = %+v", m)
+	}
+
+	// A crash-looping update is rolled back to the previous one
+	stage(t, dir, "bbbbbbbbbbbbbbbb", "echo bad; exit 3")
+	if code := run(nil); code != 0 || stdout.String() != "bad\nbad\ngood\n" {
+		t.Fatalf("bad update: %d %q", code, stdout.String())
+	}
+	if m, _ := Verify(filepath.Join(dir, ActiveDir)); m == nil || m.Commit != "aaaaaaaaaaaaaaaa" {
+		t.Errorf("active after rollback = %+v", m)
+	}
+	if got := kinds(t, dir); got != "promoted,promoted,rolled_back" {
+		t.Errorf("events = %s", got)
+	}
+
+	// The restored binary already proved itself
+	state, _ := ReadState(dir)
+	if state.Probation {
+		t.Error("the restored binary is on probation")
+	}
+
+	// SIGUSR1 restarts into an approved update
+	writeFiles(t, root, map[string]string{"hattiebot": "#!/bin/sh\nexec sleep 30\n"})
+	os.RemoveAll(filepath.Join(dir, ActiveDir))
+	signals := make(chan os.Signal, 1)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		stage(t, dir, "cccccccccccccccc", "echo updated")
+		signals <- syscall.SIGUSR1
+	}()
+	if code := run(signals); code != 0 || stdout.String() != "updated\n" {
+		t.Fatalf("signaled update: %d %q", code, stdout.String())
+	}
+
+	// A new image binary drops updates built for the old one
+	writeFiles(t, root, map[string]string{"hattiebot": "#!/bin/sh\necho new image\n"})
+	if code := run(nil); code != 0 || stdout.String() != "new image\n" {
+		t.Fatalf("new image: %d %q", code, stdout.String())
+	}
+	if exists(filepath.Join(dir, ActiveDir)) || !strings.HasSuffix(kinds(t, dir), "image_changed") {
+		t.Errorf("active kept after image change: %s", kinds(t, dir))
+	}
+}
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(SupervisorEnv, "")
+	m := &Manager{Builder: &Builder{Dir: dir}}
+	if _, err := m.Apply(""); err == nil {
+		t.Error("applied with nothing staged")
+	}
+	stage(t, dir, "dddddddddddddddd", "true")
+	os.Remove(filepath.Join(dir, StagedDir, ApprovedName))
+	if _, err := m.Apply("eeee"); err == nil || !strings.Contains(err.Error(), "not eeee") {
+		t.Errorf("apply of another commit: %v", err)
+	}
+	if _, err := m.Apply("dddd"); err == nil || !strings.Contains(err.Error(), "HATTIEBOT_RESTART_COMMAND") {
+		t.Errorf("apply without supervisor: %v", err)
+	}
+	if st := m.Status(); !st.Approved || st.Staged == nil || st.Supervised {
+		t.Errorf("status = %+v", st)
+	}
+	if _, err := m.Rollback(); err == nil {
+		t.Error("rolled back the image binary")
+	}
+
+	state := &State{}
+	state.add(EventPromoted, "dddddddddddddddd", "Fix things")
+	state.add(EventRolledBack, "dddddddddddddddd", "exited with code 2 2 times")
+	state.write(dir)
+	var msgs []string
+	notify := func(msg string) { msgs = append(msgs, msg) }
+	if err := m.ReportEvents(notify); err != nil || len(msgs) != 2 || !strings.Contains(msgs[1], "Rolled back commit dddddddddddd") {
+		t.Fatalf("reported %q, %v", msgs, err)
+	}
+	m.ReportEvents(notify)
+	if len(msgs) != 2 {
+		t.Errorf("events reported twice: %q", msgs)
+	}
+}
@@ -0,0 +1,288 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Supervisor defaults.
+const (
+	DefaultProbation  = 2 * time.Minute
+	DefaultMaxCrashes = 2
+	// stopGrace is how long the bot gets to shut down before it is killed.
+	stopGrace = 30 * time.Second
+)
+
+// Supervisor runs the bot and installs approved updates: at start and on SIGUSR1 it promotes an
+// approved staged binary to active (the old one becomes previous) or, when asked, restores
+// previous. A promoted binary is on probation until it has run for Probation; exiting with an
+// error MaxCrashes times during probation rolls it back. Outside probation a crash ends the
+// supervisor with the bot's exit code, so the container's restart policy applies as before.
+type Supervisor struct {
+	Dir          string        // self-update directory
+	ImageBinary  string        // the installed binary, run when nothing is active
+	Args         []string      // passed to the bot
+	Probation    time.Duration // default DefaultProbation
+	MaxCrashes   int           // default DefaultMaxCrashes
+	RestartDelay time.Duration // between restarts during probation (default 2s)
+	Stdout       io.Writer     // default os.Stdout
+	Stderr       io.Writer     // default os.Stderr
+	Logf         func(format string, args ...interface{})
+}
+
+// Run supervises until the bot exits on its own, a SIGTERM or SIGINT arrives on signals, or ctx
+// is canceled, and returns the bot's exit code.
+func (s *Supervisor) Run(ctx context.Context, signals <-chan os.Signal) (int, error) {
+	for {
+		state, err := ReadState(s.Dir)
+		if err != nil {
+			s.logf("state: %v (starting fresh)", err)
+		}
+		s.install(state)
+		bin, manifest := s.binary(state)
+		if err := state.write(s.Dir); err != nil {
+			s.logf("write state: %v", err)
+		}
+
+		cmd := exec.Command(bin, s.Args...)
+		cmd.Env = append(os.Environ(), SupervisorEnv+"="+strconv.Itoa(os.Getpid()))
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, s.stdout(), s.stderr()
+		if err := cmd.Start(); err != nil {
+			if manifest != nil {
+				s.rollback(state, "could not start: "+err.Error())
+				state.write(s.Dir)
+				continue
+			}
+			return 1, fmt.Errorf("start %s: %w", bin, err)
+		}
+		if manifest != nil {
+			s.logf("running %s (commit %s)", bin, shortSHA(manifest.Commit))
+		}
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+
+		var confirm <-chan time.Time
+		if manifest != nil && state.Probation {
+			confirm = time.After(s.probation())
+		}
+		restart, stopping := false, false
+		canceled := ctx.Done()
+		var exitErr error
+	wait:
+		for {
+			select {
+			case exitErr = <-done:
+				break wait
+			case <-confirm:
+				confirm = nil
+				state.Probation, state.Crashes = false, 0
+				state.add(EventConfirmed, manifest.Commit, fmt.Sprintf("ran for %s", s.probation()))
+				if err := state.write(s.Dir); err != nil {
+					s.logf("write state: %v", err)
+				}
+			case sig := <-signals:
+				if sig == syscall.SIGUSR1 {
+					if s.pending() && !restart {
+						s.logf("update requested, restarting the bot")
+						restart = true
+						stop(cmd, syscall.SIGTERM)
+					}
+					continue
+				}
+				stopping = true
+				stop(cmd, sig)
+			case <-canceled:
+				canceled = nil
+				stopping = true
+				stop(cmd, syscall.SIGTERM)
+			}
+		}
+
+		code := exitCode(exitErr)
+		switch {
+		case stopping:
+			return code, nil
+		case restart:
+			continue
+		case code == 0:
+			return 0, nil
+		case manifest != nil && state.Probation:
+			state.Crashes++
+			s.logf("commit %s exited with %d during probation (crash %d of %d)", shortSHA(manifest.Commit), code, state.Crashes, s.maxCrashes())
+			if state.Crashes >= s.maxCrashes() {
+				s.rollback(state, fmt.Sprintf("exited with code %d %d times within %s of starting", code, state.Crashes, s.probation()))
+			}
+			if err := state.write(s.Dir); err != nil {
+				s.logf("write state: %v", err)
+			}
+			select {
+			case <-time.After(s.restartDelay()):
+			case <-ctx.Done():
+				return code, nil
+			}
+			continue
+		}
+		return code, nil
+	}
+}
+
+// pending reports whether there is an approved update or a rollback request to act on.
+func (s *Supervisor) pending() bool {
+	return exists(filepath.Join(s.Dir, RollbackName)) || exists(filepath.Join(s.Dir, StagedDir, ApprovedName))
+}
+
+// install acts on a rollback request or promotes an approved staged binary.
+func (s *Supervisor) install(state *State) {
+	if marker := filepath.Join(s.Dir, RollbackName); exists(marker) {
+		os.Remove(marker)
+		s.rollback(state, "requested")
+		return
+	}
+	staged := filepath.Join(s.Dir, StagedDir)
+	if !exists(filepath.Join(staged, ApprovedName)) {
+		return
+	}
+	m, err := Verify(staged)
+	if err != nil {
+		s.logf("not installing the staged binary: %v", err)
+		os.Remove(filepath.Join(staged, ApprovedName))
+		return
+	}
+	os.Remove(filepath.Join(staged, ApprovedName))
+	active, previous := filepath.Join(s.Dir, ActiveDir), filepath.Join(s.Dir, PreviousDir)
+	if exists(active) {
+		os.RemoveAll(previous)
+		if err := os.Rename(active, previous); err != nil {
+			s.logf("keep previous binary: %v", err)
+			return
+		}
+	}
+	if err := os.Rename(staged, active); err != nil {
+		s.logf("promote staged binary: %v", err)
+		return
+	}
+	m.ImageSHA256, _ = FileSHA256(s.ImageBinary)
+	if err := WriteManifest(active, m); err != nil {
+		s.logf("write manifest: %v", err)
+	}
+	state.Probation, state.Crashes = true, 0
+	state.add(EventPromoted, m.Commit, m.Subject)
+	s.logf("installed commit %s", shortSHA(m.Commit))
+}
+
+// rollback replaces active with previous, or removes it so the image binary runs.
+func (s *Supervisor) rollback(state *State, reason string) {
+	active, previous := filepath.Join(s.Dir, ActiveDir), filepath.Join(s.Dir, PreviousDir)
+	from := "image binary"
+	if m, _ := ReadManifest(active); m != nil {
+		from = m.Commit
+	}
+	os.RemoveAll(active)
+	now := "the image binary"
+	if m, err := Verify(previous); err == nil {
+		if err := os.Rename(previous, active); err == nil {
+			now = "commit " + shortSHA(m.Commit)
+		}
+	} else {
+		os.RemoveAll(previous)
+	}
+	state.Probation, state.Crashes = false, 0
+	state.add(EventRolledBack, from, reason+"; now running "+now)
+	s.logf("rolled back %s (%s); now running %s", shortSHA(from), reason, now)
+}
+
+// binary returns the binary to run and, for an installed update, its manifest. An active binary
+// that fails verification, or was installed over a different image binary, is dropped.
+func (s *Supervisor) binary(state *State) (string, *Manifest) {
+	active := filepath.Join(s.Dir, ActiveDir)
+	if !exists(active) {
+		return s.ImageBinary, nil
+	}
+	m, err := Verify(active)
+	if err != nil {
+		s.logf("ignoring the active binary: %v", err)
+		os.RemoveAll(active)
+		return s.ImageBinary, nil
+	}
+	if image, err := FileSHA256(s.ImageBinary); err == nil && m.ImageSHA256 != "" && image != m.ImageSHA256 {
+		os.RemoveAll(active)
+		os.RemoveAll(filepath.Join(s.Dir, PreviousDir))
+		state.Probation, state.Crashes = false, 0
+		state.add(EventImageChanged, m.Commit, "the installed binary changed; self-updates built for the old one were dropped")
+		s.logf("image binary changed; dropping self-update %s", shortSHA(m.Commit))
+		return s.ImageBinary, nil
+	}
+	return filepath.Join(active, BinaryName), m
+}
+
+// stop sends sig to the bot and kills it if it is still running after stopGrace.
+func stop(cmd *exec.Cmd, sig os.Signal) {
+	if err := cmd.Process.Signal(sig); err != nil {
+		return
+	}
+	p := cmd.Process
+	time.AfterFunc(stopGrace, func() { p.Kill() })
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+func (s *Supervisor) probation() time.Duration {
+	if s.Probation > 0 {
+		return s.Probation
+	}
+	return DefaultProbation
+}
+
+func (s *Supervisor) maxCrashes() int {
+	if s.MaxCrashes > 0 {
+		return s.MaxCrashes
+	}
+	return DefaultMaxCrashes
+}
+
+func (s *Supervisor) restartDelay() time.Duration {
+	if s.RestartDelay > 0 {
+		return s.RestartDelay
+	}
+	return 2 * time.Second
+}
+
+func (s *Supervisor) stdout() io.Writer {
+	if s.Stdout != nil {
+		return s.Stdout
+	}
+	return os.Stdout
+}
+
+func (s *Supervisor) stderr() io.Writer {
+	if s.Stderr != nil {
+		return s.Stderr
+	}
+	return os.Stderr
+}
+
+func (s *Supervisor) logf(format string, args ...interface{}) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+		return
+	}
+	log.Printf("[Supervisor] "+format, args...)
+}
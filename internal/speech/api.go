@@ -0,0 +1,130 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the OpenAI audio API; any compatible server (e.g. a local faster-whisper) works.
+const DefaultAPIURL = "https://api.openai.com/v1"
+
+// maxTTSInputRunes caps text sent to TTS; providers reject very long inputs.
+const maxTTSInputRunes = 4000
+
+// APIClient calls an OpenAI-compatible /audio/transcriptions and /audio/speech API.
+type APIClient struct {
+	BaseURL  string
+	APIKey   string
+	STTModel string // e.g. whisper-1
+	TTSModel string // e.g. tts-1
+	Voice    string // e.g. alloy
+	HTTP     *http.Client
+}
+
+// NewAPIClient creates a client; empty baseURL uses DefaultAPIURL.
+func NewAPIClient(baseURL, apiKey, sttModel, ttsModel, voice string) *APIClient {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	if sttModel == "" {
+		sttModel = "whisper-1"
+	}
+	if voice == "" {
+		voice = "alloy"
+	}
+	return &APIClient{
+		BaseURL:  strings.TrimSuffix(baseURL, "/"),
+		APIKey:   apiKey,
+		STTModel: sttModel,
+		TTSModel: ttsModel,
+		Voice:    voice,
+		HTTP:     &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// Transcribe uploads audio as multipart/form-data and returns the transcript text.
+func (c *APIClient) Transcribe(ctx context.Context, audio []byte, filename, mimeType string) (string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.WriteField("model", c.STTModel); err != nil {
+		return "", err
+	}
+	if filename == "" {
+		filename = "audio.ogg"
+	}
+	part, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("speech: transcription HTTP %d: %s", resp.StatusCode, string(respBody))
+	}
+	var out struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return "", fmt.Errorf("speech: decode transcription: %w", err)
+	}
+	return strings.TrimSpace(out.Text), nil
+}
+
+// Synthesize returns MP3 audio for text.
+func (c *APIClient) Synthesize(ctx context.Context, text string) ([]byte, string, error) {
+	if c.TTSModel == "" {
+		return nil, "", fmt.Errorf("speech: TTS model not configured")
+	}
+	if r := []rune(text); len(r) > maxTTSInputRunes {
+		text = string(r[:maxTTSInputRunes])
+	}
+	raw, err := json.Marshal(map[string]string{
+		"model":           c.TTSModel,
+		"input":           text,
+		"voice":           c.Voice,
+		"response_format": "mp3",
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/audio/speech", bytes.NewReader(raw))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	audio, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("speech: synthesis HTTP %d: %s", resp.StatusCode, string(audio))
+	}
+	return audio, "mp3", nil
+}
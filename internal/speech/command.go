@@ -0,0 +1,54 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// CommandTranscriber runs a local STT command (e.g. whisper.cpp) on a temp audio file.
+// Command is run via sh -c with "{file}" replaced by the audio path; if absent, the path is appended.
+// The transcript is read from stdout.
+type CommandTranscriber struct {
+	Command string
+}
+
+func (t *CommandTranscriber) Transcribe(ctx context.Context, audio []byte, filename, mimeType string) (string, error) {
+	ext := filepath.Ext(filename)
+	if ext == "" {
+		ext = ".ogg"
+	}
+	f, err := os.CreateTemp("", "hattiebot-voice-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(audio); err != nil {
+		f.Close()
+		return "", err
+	}
+	f.Close()
+
+	cmdline := t.Command
+	if strings.Contains(cmdline, "{file}") {
+		cmdline = strings.ReplaceAll(cmdline, "{file}", shellQuote(f.Name()))
+	} else {
+		cmdline += " " + shellQuote(f.Name())
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", cmdline)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("speech: stt command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
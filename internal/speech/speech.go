@@ -0,0 +1,40 @@
+// Package speech provides speech-to-text and text-to-speech for voice messages.
+// Providers are configured via env (see config.Config Speech* fields): an OpenAI-compatible
+// audio API (Whisper / TTS) or a local command for transcription (e.g. whisper.cpp).
+package speech
+
+import (
+	"context"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+)
+
+// Transcriber converts recorded audio to text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, filename, mimeType string) (string, error)
+}
+
+// Synthesizer converts text to audio. Returns the audio bytes and their file extension (e.g. "mp3").
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) (audio []byte, ext string, err error)
+}
+
+// New builds the configured transcriber and synthesizer. Either may be nil when not configured.
+func New(cfg *config.Config) (Transcriber, Synthesizer) {
+	var stt Transcriber
+	switch cfg.SpeechSTTProvider {
+	case "whisper_api":
+		if cfg.SpeechAPIKey != "" {
+			stt = NewAPIClient(cfg.SpeechAPIURL, cfg.SpeechAPIKey, cfg.SpeechSTTModel, "", "")
+		}
+	case "command":
+		if cfg.SpeechSTTCommand != "" {
+			stt = &CommandTranscriber{Command: cfg.SpeechSTTCommand}
+		}
+	}
+	var tts Synthesizer
+	if cfg.SpeechTTSModel != "" && cfg.SpeechAPIKey != "" {
+		tts = NewAPIClient(cfg.SpeechAPIURL, cfg.SpeechAPIKey, "", cfg.SpeechTTSModel, cfg.SpeechTTSVoice)
+	}
+	return stt, tts
+}
@@ -0,0 +1,62 @@
+package speech
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIClientTranscribe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/transcriptions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		if got := r.FormValue("model"); got != "whisper-1" {
+			t.Errorf("model = %q", got)
+		}
+		if _, hdr, err := r.FormFile("file"); err != nil || hdr.Filename != "note.ogg" {
+			t.Errorf("file part missing or misnamed: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"text": " hello there \n"})
+	}))
+	defer srv.Close()
+
+	c := NewAPIClient(srv.URL, "key", "", "", "")
+	text, err := c.Transcribe(context.Background(), []byte("OggS"), "note.ogg", "audio/ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello there" {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestAPIClientSynthesize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["model"] != "tts-1" || body["voice"] != "alloy" || body["input"] != "hi" {
+			t.Errorf("unexpected request %v", body)
+		}
+		w.Write([]byte("ID3"))
+	}))
+	defer srv.Close()
+
+	c := NewAPIClient(srv.URL, "key", "", "tts-1", "")
+	audio, ext, err := c.Synthesize(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(audio) != "ID3" || ext != "mp3" {
+		t.Errorf("got %q %q", audio, ext)
+	}
+
+	if _, _, err := NewAPIClient(srv.URL, "key", "", "", "").Synthesize(context.Background(), "hi"); err == nil {
+		t.Error("expected error without TTS model")
+	}
+}
@@ -0,0 +1,611 @@
+// Package storagemigrate copies the SQLite store into Postgres and verifies the copy.
+// Tables and columns are read from the live SQLite schema, so new tables are migrated
+// without changes here. memory_chunks embeddings (JSON float arrays) become pgvector columns.
+package storagemigrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options controls a migration run.
+type Options struct {
+	// VectorDim is the pgvector dimension for embedding columns; 0 = infer from the first stored embedding.
+	VectorDim int
+	// DropExisting drops target tables first. Without it, migration fails if a target table already has rows.
+	DropExisting bool
+	// Logf receives progress lines; nil = silent.
+	Logf func(format string, args ...interface{})
+}
+
+// TableReport is the verification result for one table.
+type TableReport struct {
+	Table          string `json:"table"`
+	SourceRows     int64  `json:"source_rows"`
+	TargetRows     int64  `json:"target_rows"`
+	SourceChecksum string `json:"source_checksum"`
+	TargetChecksum string `json:"target_checksum"`
+}
+
+// OK reports whether counts and checksums match.
+func (r TableReport) OK() bool {
+	return r.SourceRows == r.TargetRows && r.SourceChecksum == r.TargetChecksum
+}
+
+type columnKind int
+
+const (
+	kindText columnKind = iota
+	kindInt
+	kindFloat
+	kindBool
+	kindTime
+	kindBytes
+	kindVector
+)
+
+type column struct {
+	Name    string
+	Kind    columnKind
+	NotNull bool
+	Default sql.NullString
+	PK      int // position in primary key (0 = not part of it)
+}
+
+type index struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+type table struct {
+	Name    string
+	Columns []column
+	Indexes []index
+}
+
+// Migrate copies every SQLite table in src into dst (a Postgres connection) and verifies row counts
+// and checksums. It returns one report per table; err is non-nil if any step or verification failed.
+func Migrate(ctx context.Context, src, dst *sql.DB, opts Options) ([]TableReport, error) {
+	logf := opts.Logf
+	if logf == nil {
+		logf = func(string, ...interface{}) {}
+	}
+	tables, err := readSchema(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("reading sqlite schema: %w", err)
+	}
+
+	dim := opts.VectorDim
+	if dim == 0 {
+		if dim, err = inferVectorDim(ctx, src, tables); err != nil {
+			return nil, err
+		}
+	}
+	if hasVectors(tables) {
+		if _, err := dst.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS vector`); err != nil {
+			return nil, fmt.Errorf("enabling pgvector (is the extension installed?): %w", err)
+		}
+	}
+	if _, err := dst.ExecContext(ctx, `SET TIME ZONE 'UTC'`); err != nil {
+		return nil, err
+	}
+
+	for _, t := range tables {
+		if opts.DropExisting {
+			if _, err := dst.ExecContext(ctx, `DROP TABLE IF EXISTS `+quoteIdent(t.Name)+` CASCADE`); err != nil {
+				return nil, fmt.Errorf("dropping %s: %w", t.Name, err)
+			}
+		}
+		for _, stmt := range createStatements(t, dim) {
+			if _, err := dst.ExecContext(ctx, stmt); err != nil {
+				return nil, fmt.Errorf("creating %s: %w", t.Name, err)
+			}
+		}
+		n, err := copyTable(ctx, src, dst, t)
+		if err != nil {
+			return nil, fmt.Errorf("copying %s: %w", t.Name, err)
+		}
+		logf("copied %s: %d rows", t.Name, n)
+	}
+
+	var reports []TableReport
+	var failed []string
+	for _, t := range tables {
+		r := TableReport{Table: t.Name}
+		if r.SourceRows, r.SourceChecksum, err = checksum(ctx, src, t); err != nil {
+			return reports, fmt.Errorf("checksumming source %s: %w", t.Name, err)
+		}
+		if r.TargetRows, r.TargetChecksum, err = checksum(ctx, dst, t); err != nil {
+			return reports, fmt.Errorf("checksumming target %s: %w", t.Name, err)
+		}
+		if !r.OK() {
+			failed = append(failed, t.Name)
+		}
+		reports = append(reports, r)
+	}
+	if len(failed) > 0 {
+		return reports, fmt.Errorf("verification failed for: %s", strings.Join(failed, ", "))
+	}
+	return reports, nil
+}
+
+func readSchema(ctx context.Context, src *sql.DB) ([]table, error) {
+	rows, err := src.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND sql NOT LIKE 'CREATE VIRTUAL%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var tables []table
+	for _, name := range names {
+		t := table{Name: name}
+		crows, err := src.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?)`, name)
+		if err != nil {
+			return nil, err
+		}
+		for crows.Next() {
+			var c column
+			var typ string
+			if err := crows.Scan(&c.Name, &typ, &c.NotNull, &c.Default, &c.PK); err != nil {
+				crows.Close()
+				return nil, err
+			}
+			c.Kind = kindOf(name, c.Name, typ)
+			t.Columns = append(t.Columns, c)
+		}
+		crows.Close()
+		if t.Indexes, err = readIndexes(ctx, src, name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+func readIndexes(ctx context.Context, src *sql.DB, tableName string) ([]index, error) {
+	rows, err := src.QueryContext(ctx, `SELECT name, "unique", origin FROM pragma_index_list(?)`, tableName)
+	if err != nil {
+		return nil, err
+	}
+	var idxs []index
+	for rows.Next() {
+		var ix index
+		var origin string
+		if err := rows.Scan(&ix.Name, &ix.Unique, &origin); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if origin == "pk" {
+			continue // recreated as PRIMARY KEY
+		}
+		idxs = append(idxs, ix)
+	}
+	rows.Close()
+	for i := range idxs {
+		crows, err := src.QueryContext(ctx, `SELECT name FROM pragma_index_info(?) ORDER BY seqno`, idxs[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		for crows.Next() {
+			var c string
+			if err := crows.Scan(&c); err != nil {
+				crows.Close()
+				return nil, err
+			}
+			idxs[i].Columns = append(idxs[i].Columns, c)
+		}
+		crows.Close()
+		// Auto-created names (sqlite_autoindex_*) are reserved-looking; give them a stable readable name.
+		if strings.HasPrefix(idxs[i].Name, "sqlite_autoindex_") {
+			idxs[i].Name = tableName + "_" + strings.Join(idxs[i].Columns, "_") + "_key"
+		}
+	}
+	return idxs, nil
+}
+
+// kindOf maps a SQLite declared type to a column kind (SQLite type affinity rules, simplified).
+func kindOf(tableName, colName, declType string) columnKind {
+	t := strings.ToUpper(declType)
+	switch {
+	case tableName == "memory_chunks" && colName == "embedding":
+		return kindVector
+	case strings.Contains(t, "INT"):
+		return kindInt
+	case strings.Contains(t, "BOOL"):
+		return kindBool
+	case strings.Contains(t, "DATE"), strings.Contains(t, "TIME"):
+		return kindTime
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return kindFloat
+	case strings.Contains(t, "BLOB"):
+		return kindBytes
+	default:
+		return kindText
+	}
+}
+
+func hasVectors(tables []table) bool {
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			if c.Kind == kindVector {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inferVectorDim(ctx context.Context, src *sql.DB, tables []table) (int, error) {
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			if c.Kind != kindVector {
+				continue
+			}
+			var raw []byte
+			err := src.QueryRowContext(ctx, `SELECT `+quoteIdent(c.Name)+` FROM `+quoteIdent(t.Name)+` WHERE `+quoteIdent(c.Name)+` IS NOT NULL LIMIT 1`).Scan(&raw)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			v, err := parseVector(raw)
+			if err != nil {
+				return 0, fmt.Errorf("inferring vector dimension from %s.%s: %w", t.Name, c.Name, err)
+			}
+			return len(v), nil
+		}
+	}
+	return 0, nil
+}
+
+func createStatements(t table, vectorDim int) []string {
+	var pk []string
+	for _, c := range t.Columns {
+		if c.PK > 0 {
+			pk = append(pk, c.Name)
+		}
+	}
+	var defs []string
+	for _, c := range t.Columns {
+		d := quoteIdent(c.Name) + " " + pgType(c.Kind, vectorDim)
+		if len(pk) == 1 && c.PK == 1 {
+			if c.Kind == kindInt {
+				d += " GENERATED BY DEFAULT AS IDENTITY"
+			}
+			d += " PRIMARY KEY"
+		} else if c.NotNull {
+			d += " NOT NULL"
+		}
+		if c.Default.Valid && !(len(pk) == 1 && c.PK == 1) {
+			d += " DEFAULT " + pgDefault(c.Kind, c.Default.String)
+		}
+		defs = append(defs, d)
+	}
+	if len(pk) > 1 {
+		defs = append(defs, "PRIMARY KEY ("+quoteIdents(pk)+")")
+	}
+	stmts := []string{"CREATE TABLE IF NOT EXISTS " + quoteIdent(t.Name) + " (\n\t" + strings.Join(defs, ",\n\t") + "\n)"}
+	for _, ix := range t.Indexes {
+		kw := "INDEX"
+		if ix.Unique {
+			kw = "UNIQUE INDEX"
+		}
+		stmts = append(stmts, fmt.Sprintf("CREATE %s IF NOT EXISTS %s ON %s (%s)", kw, quoteIdent(ix.Name), quoteIdent(t.Name), quoteIdents(ix.Columns)))
+	}
+	return stmts
+}
+
+func pgType(k columnKind, vectorDim int) string {
+	switch k {
+	case kindInt:
+		return "BIGINT"
+	case kindFloat:
+		return "DOUBLE PRECISION"
+	case kindBool:
+		return "BOOLEAN"
+	case kindTime:
+		return "TIMESTAMPTZ"
+	case kindBytes:
+		return "BYTEA"
+	case kindVector:
+		if vectorDim > 0 {
+			return fmt.Sprintf("vector(%d)", vectorDim)
+		}
+		return "vector"
+	default:
+		return "TEXT"
+	}
+}
+
+func pgDefault(k columnKind, def string) string {
+	if k == kindBool {
+		switch strings.ToUpper(strings.TrimSpace(def)) {
+		case "0", "FALSE":
+			return "FALSE"
+		case "1", "TRUE":
+			return "TRUE"
+		}
+	}
+	return def // CURRENT_TIMESTAMP, numeric and quoted string literals are the same in both dialects
+}
+
+func copyTable(ctx context.Context, src, dst *sql.DB, t table) (int64, error) {
+	var names []string
+	for _, c := range t.Columns {
+		names = append(names, c.Name)
+	}
+	rows, err := src.QueryContext(ctx, `SELECT `+quoteIdents(names)+` FROM `+quoteIdent(t.Name)+` ORDER BY rowid`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	tx, err := dst.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var existing int64
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+quoteIdent(t.Name)).Scan(&existing); err != nil {
+		return 0, err
+	}
+	if existing > 0 {
+		return 0, fmt.Errorf("target table already has %d rows (use drop-existing to replace it)", existing)
+	}
+
+	placeholders := make([]string, len(names))
+	for i := range placeholders {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO `+quoteIdent(t.Name)+` (`+quoteIdents(names)+`) VALUES (`+strings.Join(placeholders, ", ")+`)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var n int64
+	vals := make([]interface{}, len(names))
+	ptrs := make([]interface{}, len(names))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, err
+		}
+		args := make([]interface{}, len(vals))
+		for i, c := range t.Columns {
+			if args[i], err = toPostgres(c.Kind, vals[i]); err != nil {
+				return n, fmt.Errorf("row %d column %s: %w", n+1, c.Name, err)
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return n, fmt.Errorf("row %d: %w", n+1, err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+
+	for _, c := range t.Columns {
+		if c.PK == 1 && c.Kind == kindInt {
+			q := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 1), MAX(%s) IS NOT NULL) FROM %s`,
+				quoteIdent(t.Name), c.Name, quoteIdent(c.Name), quoteIdent(c.Name), quoteIdent(t.Name))
+			if _, err := tx.ExecContext(ctx, q); err != nil {
+				return n, fmt.Errorf("resetting id sequence: %w", err)
+			}
+		}
+	}
+	return n, tx.Commit()
+}
+
+// toPostgres converts a loosely typed SQLite value to what the Postgres column expects.
+func toPostgres(k columnKind, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch k {
+	case kindBool:
+		switch b := v.(type) {
+		case int64:
+			return b != 0, nil
+		case bool:
+			return b, nil
+		case string:
+			return b == "1" || strings.EqualFold(b, "true"), nil
+		}
+	case kindTime:
+		if s, ok := asString(v); ok {
+			t, err := parseTime(s)
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		}
+	case kindVector:
+		raw, _ := asString(v)
+		if strings.TrimSpace(raw) == "" || raw == "null" {
+			return nil, nil
+		}
+		vec, err := parseVector([]byte(raw))
+		if err != nil {
+			return nil, err
+		}
+		return formatVector(vec), nil
+	case kindText:
+		if s, ok := asString(v); ok {
+			return s, nil
+		}
+		return fmt.Sprint(v), nil
+	case kindBytes:
+		if s, ok := v.(string); ok {
+			return []byte(s), nil
+		}
+	}
+	return v, nil
+}
+
+// checksum returns the row count and an order-independent SHA-256 over canonicalized rows.
+func checksum(ctx context.Context, db *sql.DB, t table) (int64, string, error) {
+	var names []string
+	for _, c := range t.Columns {
+		names = append(names, c.Name)
+	}
+	rows, err := db.QueryContext(ctx, `SELECT `+quoteIdents(names)+` FROM `+quoteIdent(t.Name))
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+
+	vals := make([]interface{}, len(names))
+	ptrs := make([]interface{}, len(names))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	var rowHashes []string
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return 0, "", err
+		}
+		h := sha256.New()
+		for i, c := range t.Columns {
+			s, err := canonical(c.Kind, vals[i])
+			if err != nil {
+				return 0, "", fmt.Errorf("column %s: %w", c.Name, err)
+			}
+			fmt.Fprintf(h, "%d:%s\x1f", len(s), s)
+		}
+		rowHashes = append(rowHashes, hex.EncodeToString(h.Sum(nil)))
+	}
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+	sort.Strings(rowHashes)
+	sum := sha256.Sum256([]byte(strings.Join(rowHashes, "\n")))
+	return int64(len(rowHashes)), hex.EncodeToString(sum[:]), nil
+}
+
+// canonical renders a value identically whichever driver (SQLite or Postgres) returned it.
+func canonical(k columnKind, v interface{}) (string, error) {
+	v, err := toPostgres(k, v) // normalize SQLite's loose typing first
+	if err != nil {
+		return "", err
+	}
+	if v == nil {
+		return "\x00NULL", nil
+	}
+	switch k {
+	case kindInt:
+		return fmt.Sprint(v), nil
+	case kindFloat:
+		if f, ok := v.(float64); ok {
+			return strconv.FormatFloat(f, 'g', -1, 64), nil
+		}
+		return fmt.Sprint(v), nil
+	case kindBool:
+		return strconv.FormatBool(v.(bool)), nil
+	case kindTime:
+		// Postgres keeps microseconds.
+		return v.(time.Time).UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano), nil
+	case kindVector:
+		return v.(string), nil // already formatted by toPostgres
+	}
+	if b, ok := v.([]byte); ok {
+		return string(b), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+func asString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), true
+	case time.Time:
+		return s.UTC().Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTime parses SQLite datetime text; values without a zone are UTC (CURRENT_TIMESTAMP).
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	// Go's time.Time.String() format, e.g. "2026-01-02 15:04:05.123 +0000 UTC"
+	if i := strings.Index(s, " m="); i > 0 {
+		s = s[:i]
+	}
+	if t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", s); err == nil {
+		return t, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized datetime %q", s)
+}
+
+// parseVector decodes an embedding stored as a JSON float array (see store.InsertChunk).
+func parseVector(raw []byte) ([]float32, error) {
+	var v []float32
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// formatVector renders pgvector text input, e.g. "[0.1,0.2]".
+func formatVector(v []float32) string {
+	parts := make([]string, len(v))
+	for i, f := range v {
+		parts[i] = strconv.FormatFloat(float64(f), 'g', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func quoteIdents(names []string) string {
+	q := make([]string, len(names))
+	for i, n := range names {
+		q[i] = quoteIdent(n)
+	}
+	return strings.Join(q, ", ")
+}
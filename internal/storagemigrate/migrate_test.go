@@ -0,0 +1,77 @@
+package storagemigrate
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestReadSchemaAndCreateStatements(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tables, err := readSchema(ctx, db.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]table{}
+	for _, tb := range tables {
+		byName[tb.Name] = tb
+	}
+	for _, name := range []string{"users", "jobs", "memory_chunks", "llm_usage"} {
+		if _, ok := byName[name]; !ok {
+			t.Fatalf("table %s not found", name)
+		}
+	}
+
+	ddl := strings.Join(createStatements(byName["jobs"], 0), "\n")
+	for _, want := range []string{`"id" BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY`, `"snoozed_until" TIMESTAMPTZ`, `"budget_usd" DOUBLE PRECISION`, `"status" TEXT NOT NULL DEFAULT 'open'`} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("jobs DDL missing %q:\n%s", want, ddl)
+		}
+	}
+	ddl = strings.Join(createStatements(byName["memory_chunks"], 768), "\n")
+	if !strings.Contains(ddl, `"embedding" vector(768)`) {
+		t.Errorf("memory_chunks DDL should use pgvector:\n%s", ddl)
+	}
+	ddl = strings.Join(createStatements(byName["facts"], 0), "\n")
+	if !strings.Contains(ddl, `CREATE UNIQUE INDEX IF NOT EXISTS "facts_user_id_key_key" ON "facts" ("user_id", "key")`) {
+		t.Errorf("facts unique constraint not recreated:\n%s", ddl)
+	}
+}
+
+func TestCanonicalMatchesAcrossDrivers(t *testing.T) {
+	ts := time.Date(2026, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	cases := []struct {
+		kind           columnKind
+		sqlite, pgside interface{}
+	}{
+		{kindBool, int64(1), true},
+		{kindTime, "2026-03-01 12:30:00", time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{kindTime, ts, ts.Truncate(time.Microsecond)},
+		{kindVector, []byte("[0.5,1,-2.25]"), "[0.5,1,-2.25]"},
+		{kindFloat, float64(0), float64(0)},
+		{kindText, "hello", "hello"},
+		{kindInt, nil, nil},
+	}
+	for _, c := range cases {
+		a, err := canonical(c.kind, c.sqlite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := canonical(c.kind, c.pgside)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a != b {
+			t.Errorf("kind %d: sqlite %q != postgres %q", c.kind, a, b)
+		}
+	}
+}
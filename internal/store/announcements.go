@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AnnouncementTarget is a channel room/thread that receives announcements.
+type AnnouncementTarget struct {
+	Channel  string `json:"channel"`
+	ThreadID string `json:"thread_id"`
+	Label    string `json:"label,omitempty"`
+}
+
+// AnnouncementAudience is a named set of announcement targets (e.g. "family").
+type AnnouncementAudience struct {
+	Name      string               `json:"name"`
+	Targets   []AnnouncementTarget `json:"targets"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// SaveAudience creates or replaces a named audience.
+func (db *DB) SaveAudience(ctx context.Context, name string, targets []AnnouncementTarget) error {
+	if name == "" || len(targets) == 0 {
+		return fmt.Errorf("audience name and at least one target are required")
+	}
+	for _, t := range targets {
+		if t.Channel == "" || t.ThreadID == "" {
+			return fmt.Errorf("each target needs channel and thread_id")
+		}
+	}
+	b, err := json.Marshal(targets)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO announcement_audiences (name, targets, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT(name) DO UPDATE SET targets = excluded.targets, updated_at = CURRENT_TIMESTAMP`,
+		name, string(b),
+	)
+	return err
+}
+
+// GetAudience returns a named audience, or nil, nil if it does not exist.
+func (db *DB) GetAudience(ctx context.Context, name string) (*AnnouncementAudience, error) {
+	var a AnnouncementAudience
+	var targets string
+	err := db.QueryRowContext(ctx, `SELECT name, targets, updated_at FROM announcement_audiences WHERE name = ?`, name).
+		Scan(&a.Name, &targets, &a.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(targets), &a.Targets); err != nil {
+		return nil, fmt.Errorf("audience %s: %w", name, err)
+	}
+	return &a, nil
+}
+
+// ListAudiences returns all audiences ordered by name.
+func (db *DB) ListAudiences(ctx context.Context) ([]AnnouncementAudience, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, targets, updated_at FROM announcement_audiences ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AnnouncementAudience
+	for rows.Next() {
+		var a AnnouncementAudience
+		var targets string
+		if err := rows.Scan(&a.Name, &targets, &a.UpdatedAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(targets), &a.Targets)
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// DeleteAudience removes a named audience.
+func (db *DB) DeleteAudience(ctx context.Context, name string) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM announcement_audiences WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("audience %s not found", name)
+	}
+	return nil
+}
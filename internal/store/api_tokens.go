@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix starts every API token, so leaked tokens are easy to recognize.
+const APITokenPrefix = "hb_"
+
+// API token scopes. A token can do what its scopes allow and its user may do: read
+// (list tools, dashboards), write (send messages, call tools), admin (admin-only tools and
+// endpoints). Each scope includes the ones before it.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+// APIScopes lists the scopes from least to most powerful.
+var APIScopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// APIToken is a bearer token for the HTTP API. Only its hash is stored.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// HasScope reports whether the token's scopes include scope, directly or through a more
+// powerful one.
+func (t *APIToken) HasScope(scope string) bool {
+	want := scopeRank(scope)
+	for _, s := range t.Scopes {
+		if want >= 0 && scopeRank(s) >= want {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeRank(scope string) int {
+	for i, s := range APIScopes {
+		if s == scope {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidateAPIScopes checks scope names.
+func ValidateAPIScopes(scopes []string) error {
+	for _, s := range scopes {
+		if scopeRank(s) < 0 {
+			return fmt.Errorf("unknown scope %q (use %s)", s, strings.Join(APIScopes, ", "))
+		}
+	}
+	return nil
+}
+
+func splitScopes(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken creates a token acting as userID and returns it with its ID. The token cannot be
+// read back later. Without scopes it gets all of them.
+func (db *DB) CreateAPIToken(ctx context.Context, userID, name string, scopes ...string) (int64, string, error) {
+	if len(scopes) == 0 {
+		scopes = APIScopes
+	}
+	if err := ValidateAPIScopes(scopes); err != nil {
+		return 0, "", err
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return 0, "", err
+	}
+	token := APITokenPrefix + hex.EncodeToString(buf)
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO api_tokens (user_id, name, token_hash, scopes) VALUES (?, ?, ?, ?)`,
+		userID, name, hashAPIToken(token), strings.Join(scopes, ","),
+	)
+	if err != nil {
+		return 0, "", err
+	}
+	id, err := res.LastInsertId()
+	return id, token, err
+}
+
+// APITokenUser returns the user a token acts as and records its use, or sql.ErrNoRows for unknown tokens.
+func (db *DB) APITokenUser(ctx context.Context, token string) (string, error) {
+	t, err := db.LookupAPIToken(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	return t.UserID, nil
+}
+
+// LookupAPIToken returns the token record for a token value and records its use, or sql.ErrNoRows
+// for unknown tokens.
+func (db *DB) LookupAPIToken(ctx context.Context, token string) (*APIToken, error) {
+	var t APIToken
+	var scopes string
+	err := db.QueryRowContext(ctx, `SELECT id, user_id, name, scopes, created_at FROM api_tokens WHERE token_hash = ?`, hashAPIToken(token)).Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.Scopes = splitScopes(scopes)
+	now := time.Now()
+	_, _ = db.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, now, t.ID)
+	t.LastUsedAt = &now
+	return &t, nil
+}
+
+// ListAPITokens returns all API tokens (without secrets), oldest first.
+func (db *DB) ListAPITokens(ctx context.Context) ([]APIToken, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, user_id, name, scopes, created_at, last_used_at FROM api_tokens ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []APIToken
+	for rows.Next() {
+		var t APIToken
+		var scopes string
+		var used sql.NullTime
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.CreatedAt, &used); err != nil {
+			return nil, err
+		}
+		t.Scopes = splitScopes(scopes)
+		if used.Valid {
+			t.LastUsedAt = &used.Time
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// RevokeAPIToken deletes a token. It returns sql.ErrNoRows if there is no token with that ID.
+func (db *DB) RevokeAPIToken(ctx context.Context, id int64) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// AuditEntry is one tool execution recorded in tool_audit_log.
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UserID     string    `json:"user_id,omitempty"`
+	Tool       string    `json:"tool"`
+	Args       string    `json:"args,omitempty"`
+	Channel    string    `json:"channel,omitempty"`
+	ThreadID   string    `json:"thread_id,omitempty"`
+	Outcome    string    `json:"outcome"` // ok, error, denied
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// AuditFilter narrows ReadAuditLog; zero values match everything.
+type AuditFilter struct {
+	UserID   string
+	Tool     string
+	Outcome  string
+	ThreadID string
+	Since    time.Time
+	Limit    int // default 50
+}
+
+// AppendAuditEntry records a tool execution. The table is append-only.
+func (db *DB) AppendAuditEntry(ctx context.Context, e AuditEntry) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO tool_audit_log (user_id, tool, args, channel, thread_id, outcome, error, duration_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.UserID, e.Tool, e.Args, e.Channel, e.ThreadID, e.Outcome, e.Error, e.DurationMS,
+	)
+	return err
+}
+
+// ReadAuditLog returns matching entries, newest first.
+func (db *DB) ReadAuditLog(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	query := `SELECT id, created_at, user_id, tool, COALESCE(args, ''), COALESCE(channel, ''), COALESCE(thread_id, ''), outcome, COALESCE(error, ''), duration_ms
+		FROM tool_audit_log WHERE 1=1`
+	var args []interface{}
+	if f.UserID != "" {
+		query += ` AND user_id = ?`
+		args = append(args, f.UserID)
+	}
+	if f.Tool != "" {
+		query += ` AND tool = ?`
+		args = append(args, f.Tool)
+	}
+	if f.Outcome != "" {
+		query += ` AND outcome = ?`
+		args = append(args, f.Outcome)
+	}
+	if f.ThreadID != "" {
+		query += ` AND thread_id = ?`
+		args = append(args, f.ThreadID)
+	}
+	if !f.Since.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, f.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, f.Limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.UserID, &e.Tool, &e.Args, &e.Channel, &e.ThreadID, &e.Outcome, &e.Error, &e.DurationMS); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// PruneAuditLog deletes entries older than retentionDays (0 = keep forever) and returns how many were removed.
+func (db *DB) PruneAuditLog(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays).UTC().Format("2006-01-02 15:04:05")
+	res, err := db.ExecContext(ctx, `DELETE FROM tool_audit_log WHERE created_at < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
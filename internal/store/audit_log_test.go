@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAuditLogIsAppendOnlyAndPrunable(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, tool := range []string{"read_file", "run_terminal_cmd"} {
+		if err := db.AppendAuditEntry(ctx, AuditEntry{UserID: "alice", Tool: tool, Outcome: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE tool_audit_log SET outcome = 'denied'`); err == nil {
+		t.Error("expected updates to be rejected")
+	}
+	entries, err := db.ReadAuditLog(ctx, AuditFilter{Tool: "run_terminal_cmd"})
+	if err != nil || len(entries) != 1 || entries[0].UserID != "alice" {
+		t.Fatalf("filtered read: %+v, %v", entries, err)
+	}
+
+	if _, err := db.ExecContext(ctx, `INSERT INTO tool_audit_log (created_at, tool, outcome) VALUES ('2000-01-01 00:00:00', 'old', 'ok')`); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.PruneAuditLog(ctx, 30); err != nil || n != 1 {
+		t.Fatalf("prune: removed %d, %v", n, err)
+	}
+	if n, _ := db.PruneAuditLog(ctx, 0); n != 0 {
+		t.Error("retention 0 should keep everything")
+	}
+}
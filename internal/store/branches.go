@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// maxBranchDepth bounds how many ancestors ThreadHistory follows.
+const maxBranchDepth = 16
+
+// ThreadBranch records that a thread continues another thread from one of its messages.
+type ThreadBranch struct {
+	ThreadID        string    `json:"thread_id"`
+	ParentThreadID  string    `json:"parent_thread_id"`
+	ParentMessageID int64     `json:"parent_message_id"`
+	CreatedBy       string    `json:"created_by,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// BranchThread starts threadID as a branch of parentThreadID: its history is the parent's
+// messages up to and including messageID, followed by its own. threadID must not have
+// messages or a parent yet.
+func (db *DB) BranchThread(ctx context.Context, parentThreadID string, messageID int64, threadID, createdBy string) error {
+	var owner string
+	err := db.QueryRowContext(ctx, `SELECT thread_id FROM messages WHERE id = ?`, messageID).Scan(&owner)
+	if err == sql.ErrNoRows || (err == nil && owner != parentThreadID) {
+		return fmt.Errorf("message %d is not in thread %q", messageID, parentThreadID)
+	}
+	if err != nil {
+		return err
+	}
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages WHERE thread_id = ?`, threadID).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("thread %q already has messages", threadID)
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO thread_branches (thread_id, parent_thread_id, parent_message_id, created_by) VALUES (?, ?, ?, ?)`,
+		threadID, parentThreadID, messageID, createdBy)
+	if err != nil {
+		return fmt.Errorf("thread %q is already a branch: %w", threadID, err)
+	}
+	return nil
+}
+
+// GetThreadBranch returns the branch record of threadID, or nil when it is not a branch.
+func (db *DB) GetThreadBranch(ctx context.Context, threadID string) (*ThreadBranch, error) {
+	var b ThreadBranch
+	err := db.QueryRowContext(ctx,
+		`SELECT thread_id, parent_thread_id, parent_message_id, created_by, created_at FROM thread_branches WHERE thread_id = ?`,
+		threadID).Scan(&b.ThreadID, &b.ParentThreadID, &b.ParentMessageID, &b.CreatedBy, &b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ThreadHistory returns the last limit messages of threadID as the model sees them, oldest
+// first: superseded messages are left out, and a branch continues from its parent's messages up
+// to the branch point.
+func (db *DB) ThreadHistory(ctx context.Context, threadID string, limit int) ([]Message, error) {
+	var out []Message
+	thread, upTo := threadID, int64(0)
+	for depth := 0; len(out) < limit && depth < maxBranchDepth; depth++ {
+		query := `SELECT id, role, content, COALESCE(model, ''), sender_id, channel, thread_id, tool_calls, tool_results, tool_call_id, created_at
+			FROM messages WHERE thread_id = ? AND superseded = 0`
+		args := []interface{}{thread}
+		if upTo > 0 {
+			query += ` AND id <= ?`
+			args = append(args, upTo)
+		}
+		query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+		args = append(args, limit-len(out))
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		var part []Message
+		for rows.Next() {
+			var m Message
+			var toolCalls, toolResults, toolCallID sql.NullString
+			if err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.Model, &m.SenderID, &m.Channel, &m.ThreadID, &toolCalls, &toolResults, &toolCallID, &m.CreatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			m.ToolCalls, m.ToolResults, m.ToolCallID = toolCalls.String, toolResults.String, toolCallID.String
+			part = append(part, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		for i, j := 0, len(part)-1; i < j; i, j = i+1, j-1 {
+			part[i], part[j] = part[j], part[i]
+		}
+		out = append(part, out...)
+
+		b, err := db.GetThreadBranch(ctx, thread)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			break
+		}
+		thread, upTo = b.ParentThreadID, b.ParentMessageID
+	}
+	return out, nil
+}
+
+// LastUserMessage returns the newest user message of threadID that is not superseded.
+func (db *DB) LastUserMessage(ctx context.Context, threadID string) (*Message, error) {
+	var m Message
+	err := db.QueryRowContext(ctx,
+		`SELECT id, role, content, sender_id, channel, thread_id, created_at FROM messages
+		 WHERE thread_id = ? AND role = 'user' AND superseded = 0 ORDER BY id DESC LIMIT 1`,
+		threadID).Scan(&m.ID, &m.Role, &m.Content, &m.SenderID, &m.Channel, &m.ThreadID, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SupersedeFrom hides message id of threadID and every later message in it from the context,
+// keeping them in the table. It returns how many messages it marked.
+func (db *DB) SupersedeFrom(ctx context.Context, threadID string, id int64) (int64, error) {
+	res, err := db.ExecContext(ctx, `UPDATE messages SET superseded = 1 WHERE thread_id = ? AND id >= ? AND superseded = 0`, threadID, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func threadContents(msgs []Message) string {
+	var parts []string
+	for _, m := range msgs {
+		parts = append(parts, m.Content)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestThreadBranchesAndSupersede(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var ids []int64
+	for _, c := range []string{"q1", "a1", "q2", "a2"} {
+		role := "user"
+		if c[0] == 'a' {
+			role = "assistant"
+		}
+		id, err := db.InsertMessage(ctx, role, c, "", "alice", "api", "main", "", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := db.BranchThread(ctx, "other", ids[1], "b1", "alice"); err == nil {
+		t.Error("branching from a message of another thread succeeded")
+	}
+	if err := db.BranchThread(ctx, "main", ids[1], "b1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.BranchThread(ctx, "main", ids[1], "b1", "alice"); err == nil {
+		t.Error("branching into an existing branch succeeded")
+	}
+	if _, err := db.InsertMessage(ctx, "user", "q2b", "", "alice", "api", "b1", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	hist, err := db.ThreadHistory(ctx, "b1", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := threadContents(hist); got != "q1,a1,q2b" {
+		t.Errorf("branch history = %s, want parent up to the branch point then its own", got)
+	}
+	if hist, _ := db.ThreadHistory(ctx, "b1", 2); threadContents(hist) != "a1,q2b" {
+		t.Errorf("limited branch history = %s, want the last 2", threadContents(hist))
+	}
+
+	last, err := db.LastUserMessage(ctx, "main")
+	if err != nil || last.Content != "q2" {
+		t.Fatalf("LastUserMessage = %+v, %v", last, err)
+	}
+	if n, err := db.SupersedeFrom(ctx, "main", last.ID); err != nil || n != 2 {
+		t.Fatalf("SupersedeFrom = %d, %v, want 2", n, err)
+	}
+	if hist, _ := db.ThreadHistory(ctx, "main", 30); threadContents(hist) != "q1,a1" {
+		t.Errorf("history after supersede = %s", threadContents(hist))
+	}
+	if all, _ := db.ThreadMessages(ctx, "main"); len(all) != 4 {
+		t.Errorf("superseded messages were deleted: %d left", len(all))
+	}
+}
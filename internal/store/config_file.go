@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+
+	"github.com/hattiebot/hattiebot/internal/config"
 )
 
 // ConfigFile holds persisted config (API key, model) for first boot and beyond.
@@ -29,6 +31,19 @@ type ConfigFile struct {
 	NextcloudBotAppPassword    string `json:"nextcloud_bot_app_password,omitempty"`
 	NextcloudIntroSent         bool   `json:"nextcloud_intro_sent,omitempty"`
 	DefaultChannel             string `json:"default_channel,omitempty"`
+
+	// Named remote file stores (local dir, WebDAV, S3) for the file_store tool and backups
+	FileStores       map[string]config.FileStore `json:"file_stores,omitempty"`
+	DefaultFileStore string                      `json:"default_file_store,omitempty"`
+
+	// Web search provider for search_web (overrides the HATTIEBOT_SEARCH_* env vars)
+	SearchProvider     string `json:"search_provider,omitempty"`
+	SearchURL          string `json:"search_url,omitempty"`
+	SearchAPIKeySecret string `json:"search_api_key_secret,omitempty"`
+
+	// Storage backend: "" or "sqlite" = hattiebot.db, the only one the runtime store supports
+	StorageBackend string `json:"storage_backend,omitempty"`
+	DatabaseURL    string `json:"database_url,omitempty"`
 }
 
 // LoadConfigFile reads config from dir/config.json. Missing file returns nil, nil.
@@ -8,10 +8,11 @@ import (
 
 // EmbeddingProviderEntry describes one embedding provider (e.g. embeddinggood).
 type EmbeddingProviderEntry struct {
-	Type       string `json:"type"`                 // "embeddinggood", etc.
+	Type       string `json:"type"`                 // "embeddinggood", "ollama"
 	BaseURLEnv string `json:"base_url_env,omitempty"`
 	APIKeyEnv  string `json:"api_key_env,omitempty"`
 	Dimension  int    `json:"dimension,omitempty"` // 128, 256, 512, 768; 0 = use default
+	Model      string `json:"model,omitempty"`     // for "ollama": the embedding model, e.g. nomic-embed-text
 }
 
 // EmbeddingRoutingConfig holds embedding_providers and default_provider for dynamic routing.
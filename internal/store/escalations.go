@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Escalation tracks one item (e.g. an overdue plan) through an escalation chain. It is open
+// until the item no longer needs attention; an acknowledged escalation stays open but quiet.
+type Escalation struct {
+	ID      int64  `json:"id"`
+	Kind    string `json:"kind"` // "plan"
+	ItemID  int64  `json:"item_id"`
+	UserID  string `json:"user_id"`
+	Subject string `json:"subject"`
+	// Step is how many steps of the chain have been notified.
+	Step          int        `json:"step"`
+	AdminNotified bool       `json:"admin_notified"`
+	StartedAt     time.Time  `json:"started_at"`
+	AckedAt       *time.Time `json:"acked_at,omitempty"`
+	AckedBy       string     `json:"acked_by,omitempty"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+}
+
+const escalationColumns = `id, kind, item_id, user_id, subject, step, admin_notified, started_at, acked_at, acked_by, resolved_at`
+
+func scanEscalation(row interface{ Scan(...interface{}) error }) (*Escalation, error) {
+	var e Escalation
+	var acked, resolved sql.NullTime
+	if err := row.Scan(&e.ID, &e.Kind, &e.ItemID, &e.UserID, &e.Subject, &e.Step, &e.AdminNotified, &e.StartedAt, &acked, &e.AckedBy, &resolved); err != nil {
+		return nil, err
+	}
+	if acked.Valid {
+		e.AckedAt = &acked.Time
+	}
+	if resolved.Valid {
+		e.ResolvedAt = &resolved.Time
+	}
+	return &e, nil
+}
+
+// OpenEscalation returns the open escalation of an item, starting one at step 0 when there is
+// none.
+func (db *DB) OpenEscalation(ctx context.Context, kind string, itemID int64, userID, subject string) (*Escalation, error) {
+	e, err := scanEscalation(db.QueryRowContext(ctx,
+		`SELECT `+escalationColumns+` FROM escalations WHERE kind = ? AND item_id = ? AND resolved_at IS NULL`, kind, itemID))
+	if err != sql.ErrNoRows {
+		return e, err
+	}
+	res, err := db.ExecContext(ctx, `INSERT INTO escalations (kind, item_id, user_id, subject, started_at) VALUES (?, ?, ?, ?, ?)`,
+		kind, itemID, userID, subject, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return scanEscalation(db.QueryRowContext(ctx, `SELECT `+escalationColumns+` FROM escalations WHERE id = ?`, id))
+}
+
+// AdvanceEscalation records that the chain's steps up to step have been notified.
+func (db *DB) AdvanceEscalation(ctx context.Context, id int64, step int, adminNotified bool) error {
+	_, err := db.ExecContext(ctx, `UPDATE escalations SET step = ?, admin_notified = admin_notified OR ? WHERE id = ?`, step, adminNotified, id)
+	return err
+}
+
+// ResolveEscalations closes the open escalations of kind whose item is not in stillOpen, and
+// returns how many it closed.
+func (db *DB) ResolveEscalations(ctx context.Context, kind string, stillOpen map[int64]bool) (int, error) {
+	open, err := db.ListEscalations(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range open {
+		if e.Kind != kind || stillOpen[e.ItemID] {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `UPDATE escalations SET resolved_at = ? WHERE id = ?`, time.Now(), e.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// AcknowledgeEscalations stops the open, unacknowledged escalations userID may clear: their own,
+// and as an admin those the admin was told about. id 0 acknowledges all of them.
+func (db *DB) AcknowledgeEscalations(ctx context.Context, userID string, admin bool, id int64) ([]Escalation, error) {
+	open, err := db.ListEscalations(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	var acked []Escalation
+	now := time.Now()
+	for _, e := range open {
+		if e.AckedAt != nil || (id != 0 && e.ID != id) || (e.UserID != userID && !(admin && e.AdminNotified)) {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `UPDATE escalations SET acked_at = ?, acked_by = ? WHERE id = ?`, now, userID, e.ID); err != nil {
+			return acked, err
+		}
+		e.AckedAt, e.AckedBy = &now, userID
+		acked = append(acked, e)
+	}
+	return acked, nil
+}
+
+// ListEscalations returns escalations, newest first; openOnly leaves out resolved ones.
+func (db *DB) ListEscalations(ctx context.Context, openOnly bool) ([]Escalation, error) {
+	query := `SELECT ` + escalationColumns + ` FROM escalations`
+	if openOnly {
+		query += ` WHERE resolved_at IS NULL`
+	}
+	rows, err := db.QueryContext(ctx, query+` ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Escalation
+	for rows.Next() {
+		e, err := scanEscalation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Event delivery statuses.
+const (
+	EventPending   = "pending"
+	EventDelivered = "delivered"
+	EventFailed    = "failed"
+)
+
+// EventDeliveryRetention is how long finished event deliveries are kept.
+const EventDeliveryRetention = 30 * 24 * time.Hour
+
+// maxEventDeliveryError caps the stored error of a failed attempt, in runes.
+const maxEventDeliveryError = 300
+
+// EventSubscription is an external endpoint that receives bot events as signed POSTs.
+type EventSubscription struct {
+	ID             int64      `json:"id"`
+	URL            string     `json:"url"`
+	Secret         string     `json:"-"`
+	Events         []string   `json:"events,omitempty"` // empty = all
+	Description    string     `json:"description,omitempty"`
+	CreatedBy      string     `json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// Wants reports whether the subscription receives events of type eventType.
+func (s EventSubscription) Wants(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == eventType || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// EventDelivery is one event owed to a subscription. Due deliveries carry the subscription's
+// URL and secret.
+type EventDelivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	UserID         string     `json:"user_id,omitempty"`
+	Payload        string     `json:"-"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	URL            string     `json:"-"`
+	Secret         string     `json:"-"`
+}
+
+// CreateEventSubscription stores a subscription and returns its ID.
+func (db *DB) CreateEventSubscription(ctx context.Context, s EventSubscription) (int64, error) {
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO event_subscriptions (url, secret, events, description, created_by) VALUES (?, ?, ?, ?, ?)`,
+		s.URL, s.Secret, strings.Join(s.Events, ","), s.Description, s.CreatedBy)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+const eventSubscriptionColumns = `id, url, secret, events, description, created_by, created_at, last_delivery_at, last_error`
+
+func scanEventSubscription(row interface{ Scan(...interface{}) error }) (EventSubscription, error) {
+	var s EventSubscription
+	var events string
+	var last sql.NullTime
+	if err := row.Scan(&s.ID, &s.URL, &s.Secret, &events, &s.Description, &s.CreatedBy, &s.CreatedAt, &last, &s.LastError); err != nil {
+		return s, err
+	}
+	if events != "" {
+		s.Events = strings.Split(events, ",")
+	}
+	if last.Valid {
+		s.LastDeliveryAt = &last.Time
+	}
+	return s, nil
+}
+
+// ListEventSubscriptions returns all subscriptions, oldest first.
+func (db *DB) ListEventSubscriptions(ctx context.Context) ([]EventSubscription, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+eventSubscriptionColumns+` FROM event_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []EventSubscription
+	for rows.Next() {
+		s, err := scanEventSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// GetEventSubscription returns one subscription; sql.ErrNoRows when there is none.
+func (db *DB) GetEventSubscription(ctx context.Context, id int64) (*EventSubscription, error) {
+	s, err := scanEventSubscription(db.QueryRowContext(ctx, `SELECT `+eventSubscriptionColumns+` FROM event_subscriptions WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// DeleteEventSubscription removes a subscription and the deliveries still owed to it. False when
+// there was no such subscription.
+func (db *DB) DeleteEventSubscription(ctx context.Context, id int64) (bool, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM event_subscriptions WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM event_deliveries WHERE subscription_id = ?`, id); err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// EnqueueEventDelivery owes payload to a subscription, due now, and drops deliveries finished
+// longer than EventDeliveryRetention ago.
+func (db *DB) EnqueueEventDelivery(ctx context.Context, d EventDelivery) error {
+	now := time.Now().UTC()
+	if _, err := db.ExecContext(ctx,
+		`INSERT INTO event_deliveries (subscription_id, event_id, event_type, user_id, payload, status, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		d.SubscriptionID, d.EventID, d.EventType, d.UserID, d.Payload, EventPending, now); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `DELETE FROM event_deliveries WHERE status <> ? AND finished_at < ?`, EventPending, now.Add(-EventDeliveryRetention))
+	return err
+}
+
+// DueEventDeliveries returns up to limit pending deliveries whose next attempt is due, oldest first.
+func (db *DB) DueEventDeliveries(ctx context.Context, limit int) ([]EventDelivery, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT d.id, d.subscription_id, d.event_id, d.event_type, d.user_id, d.payload, d.attempts, d.next_attempt_at, d.created_at, s.url, s.secret
+		 FROM event_deliveries d JOIN event_subscriptions s ON s.id = d.subscription_id
+		 WHERE d.status = ? AND d.next_attempt_at <= ? ORDER BY d.next_attempt_at, d.id LIMIT ?`,
+		EventPending, time.Now().UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []EventDelivery
+	for rows.Next() {
+		d := EventDelivery{Status: EventPending}
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.UserID, &d.Payload, &d.Attempts, &d.NextAttemptAt, &d.CreatedAt, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// FinishEventAttempt records one delivery attempt. Without an error the delivery is done; with
+// one it is tried again at retryAt, or, when retryAt is zero, marked failed. The subscription
+// keeps the time and error of its latest attempt.
+func (db *DB) FinishEventAttempt(ctx context.Context, d EventDelivery, attemptErr error, retryAt time.Time) error {
+	now := time.Now().UTC()
+	errMsg := ""
+	if attemptErr != nil {
+		errMsg = attemptErr.Error()
+		if r := []rune(errMsg); len(r) > maxEventDeliveryError {
+			errMsg = string(r[:maxEventDeliveryError]) + "…"
+		}
+	}
+	var err error
+	switch {
+	case attemptErr == nil:
+		_, err = db.ExecContext(ctx, `UPDATE event_deliveries SET status = ?, attempts = attempts + 1, last_error = '', finished_at = ? WHERE id = ?`,
+			EventDelivered, now, d.ID)
+	case retryAt.IsZero():
+		_, err = db.ExecContext(ctx, `UPDATE event_deliveries SET status = ?, attempts = attempts + 1, last_error = ?, finished_at = ? WHERE id = ?`,
+			EventFailed, errMsg, now, d.ID)
+	default:
+		_, err = db.ExecContext(ctx, `UPDATE event_deliveries SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?`,
+			errMsg, retryAt.UTC(), d.ID)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `UPDATE event_subscriptions SET last_delivery_at = ?, last_error = ? WHERE id = ?`, now, errMsg, d.SubscriptionID)
+	return err
+}
+
+// ListEventDeliveries returns a subscription's latest deliveries, newest first.
+func (db *DB) ListEventDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]EventDelivery, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, subscription_id, event_id, event_type, user_id, status, attempts, next_attempt_at, last_error, created_at, finished_at
+		 FROM event_deliveries WHERE subscription_id = ? ORDER BY id DESC LIMIT ?`, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []EventDelivery
+	for rows.Next() {
+		var d EventDelivery
+		var finished sql.NullTime
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.UserID, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &finished); err != nil {
+			return nil, err
+		}
+		if finished.Valid {
+			d.FinishedAt = &finished.Time
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
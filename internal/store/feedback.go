@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Feedback sources.
+const (
+	FeedbackReaction = "reaction" // emoji reaction on a reply
+	FeedbackCommand  = "command"  // /feedback in the conversation
+)
+
+// Feedback is a user's rating of or comment on an assistant reply.
+type Feedback struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Channel   string    `json:"channel"`
+	ThreadID  string    `json:"thread_id"`
+	MessageID int64     `json:"message_id,omitempty"`
+	Rating    int       `json:"rating"` // 1 good, -1 bad, 0 comment only
+	Comment   string    `json:"comment,omitempty"`
+	Source    string    `json:"source"`
+	Reaction  string    `json:"reaction,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Reply     string    `json:"reply,omitempty"` // the rated message's content (FeedbackSince)
+}
+
+// reactionRatings maps the emoji counted as feedback to a rating; other reactions are ignored.
+var reactionRatings = map[string]int{
+	"👍": 1, "❤️": 1, "🎉": 1, "👏": 1, "🙏": 1, "💯": 1,
+	"👎": -1, "😕": -1, "😞": -1, "❌": -1, "🤦": -1,
+}
+
+// ReactionRating returns the rating an emoji reaction stands for, and false for reactions that
+// are not feedback.
+func ReactionRating(emoji string) (int, bool) {
+	r, ok := reactionRatings[emoji]
+	return r, ok
+}
+
+// InsertFeedback stores f and returns its id.
+func (db *DB) InsertFeedback(ctx context.Context, f Feedback) (int64, error) {
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO feedback (user_id, channel, thread_id, message_id, rating, comment, source, reaction) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		f.UserID, f.Channel, f.ThreadID, f.MessageID, f.Rating, f.Comment, f.Source, f.Reaction)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// RecordReaction stores (or, with removed, withdraws) userID's emoji reaction on the assistant
+// reply in threadID whose content contains text. Reactions that are not feedback, and reactions
+// on messages that are not a known reply, are ignored.
+func (db *DB) RecordReaction(ctx context.Context, userID, channel, threadID, text, emoji string, removed bool) error {
+	rating, ok := ReactionRating(emoji)
+	if !ok || text == "" {
+		return nil
+	}
+	var messageID int64
+	err := db.QueryRowContext(ctx,
+		`SELECT id FROM messages WHERE thread_id = ? AND role = 'assistant' AND content != '' AND instr(content, ?) > 0
+		 ORDER BY id DESC LIMIT 1`, threadID, text).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if removed {
+		_, err := db.ExecContext(ctx,
+			`DELETE FROM feedback WHERE id = (SELECT id FROM feedback WHERE user_id = ? AND message_id = ? AND source = ? AND reaction = ? ORDER BY id DESC LIMIT 1)`,
+			userID, messageID, FeedbackReaction, emoji)
+		return err
+	}
+	_, err = db.InsertFeedback(ctx, Feedback{UserID: userID, Channel: channel, ThreadID: threadID, MessageID: messageID, Rating: rating, Source: FeedbackReaction, Reaction: emoji})
+	return err
+}
+
+// LastAssistantMessage returns the newest assistant reply with content in threadID that is not
+// superseded.
+func (db *DB) LastAssistantMessage(ctx context.Context, threadID string) (*Message, error) {
+	var m Message
+	err := db.QueryRowContext(ctx,
+		`SELECT id, role, content, sender_id, channel, thread_id, created_at FROM messages
+		 WHERE thread_id = ? AND role = 'assistant' AND content != '' AND superseded = 0 ORDER BY id DESC LIMIT 1`,
+		threadID).Scan(&m.ID, &m.Role, &m.Content, &m.SenderID, &m.Channel, &m.ThreadID, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FeedbackSince returns the feedback given since t, newest first, with the rated reply's content.
+func (db *DB) FeedbackSince(ctx context.Context, t time.Time) ([]Feedback, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT f.id, f.user_id, f.channel, f.thread_id, f.message_id, f.rating, f.comment, f.source, f.reaction, f.created_at, COALESCE(m.content, '')
+		 FROM feedback f LEFT JOIN messages m ON m.id = f.message_id
+		 WHERE f.created_at >= ? ORDER BY f.created_at DESC, f.id DESC`, t.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Feedback
+	for rows.Next() {
+		var f Feedback
+		if err := rows.Scan(&f.ID, &f.UserID, &f.Channel, &f.ThreadID, &f.MessageID, &f.Rating, &f.Comment, &f.Source, &f.Reaction, &f.CreatedAt, &f.Reply); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
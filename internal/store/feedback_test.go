@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordReaction(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	reply, _ := db.InsertMessage(ctx, "assistant", "Here are three options for dinner: pasta, curry or soup.", "", "hattiebot", "nextcloud_talk", "room", "", "", "")
+	_, _ = db.InsertMessage(ctx, "assistant", "Something else entirely.", "", "hattiebot", "nextcloud_talk", "room", "", "", "")
+
+	for _, tt := range []struct{ text, emoji string }{
+		{"three options for dinner", "👀"}, // not feedback
+		{"a message we never sent", "👍"},  // not a known reply
+	} {
+		if err := db.RecordReaction(ctx, "alice", "nextcloud_talk", "room", tt.text, tt.emoji, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A split reply is matched by its part
+	if err := db.RecordReaction(ctx, "alice", "nextcloud_talk", "room", "three options for dinner", "👎", false); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.FeedbackSince(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].MessageID != reply || got[0].Rating != -1 || got[0].Source != FeedbackReaction || got[0].Reply == "" {
+		t.Fatalf("feedback = %+v, want one -1 reaction on message %d with its reply", got, reply)
+	}
+
+	if err := db.RecordReaction(ctx, "alice", "nextcloud_talk", "room", "three options for dinner", "👎", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.FeedbackSince(ctx, time.Now().Add(-time.Hour)); len(got) != 0 {
+		t.Errorf("removed reaction is still recorded: %+v", got)
+	}
+}
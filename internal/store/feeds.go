@@ -0,0 +1,261 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Feed statuses.
+const (
+	FeedActive = "active"
+	FeedPaused = "paused"
+)
+
+// Feed item states.
+const (
+	FeedItemSeen    = "seen"    // part of the first fetch, or filtered out
+	FeedItemPending = "pending" // matched, waiting to be handed to the agent
+	FeedItemPushed  = "pushed"  // handed to the agent
+)
+
+// maxFeedItems caps the items kept per feed; older ones are dropped once the feed no longer lists them.
+const maxFeedItems = 500
+
+// Feed is an RSS or Atom subscription checked every IntervalMin minutes.
+type Feed struct {
+	ID            int64      `json:"id"`
+	UserID        string     `json:"user_id"`
+	URL           string     `json:"url"`
+	Title         string     `json:"title,omitempty"`
+	IntervalMin   int        `json:"interval_min"`
+	Include       []string   `json:"include,omitempty"`
+	Exclude       []string   `json:"exclude,omitempty"`
+	Instructions  string     `json:"instructions,omitempty"`
+	Status        string     `json:"status"`
+	ETag          string     `json:"-"`
+	LastModified  string     `json:"-"`
+	Failures      int        `json:"failures,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	NextCheckAt   *time.Time `json:"next_check_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// FeedItem is an entry seen in a feed.
+type FeedItem struct {
+	ID          int64      `json:"id"`
+	FeedID      int64      `json:"feed_id"`
+	GUID        string     `json:"guid"`
+	Title       string     `json:"title"`
+	Link        string     `json:"link,omitempty"`
+	Summary     string     `json:"summary,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	State       string     `json:"state"`
+	FetchedAt   time.Time  `json:"fetched_at"`
+}
+
+func (f *Feed) validate() error {
+	f.URL = strings.TrimSpace(f.URL)
+	if f.URL == "" {
+		return fmt.Errorf("feed url is required")
+	}
+	if f.IntervalMin < 5 {
+		return fmt.Errorf("interval_min must be at least 5")
+	}
+	switch f.Status {
+	case "":
+		f.Status = FeedActive
+	case FeedActive, FeedPaused:
+	default:
+		return fmt.Errorf("unknown feed status %q (use active or paused)", f.Status)
+	}
+	return nil
+}
+
+func encodeKeywords(k []string) string {
+	if len(k) == 0 {
+		return "[]"
+	}
+	b, _ := json.Marshal(k)
+	return string(b)
+}
+
+const feedColumns = `id, user_id, url, title, interval_min, include_keywords, exclude_keywords, instructions, status,
+	etag, last_modified, failures, last_error, last_checked_at, next_check_at, created_at`
+
+func scanFeed(row rowScanner) (*Feed, error) {
+	var f Feed
+	var include, exclude string
+	var checked, next sql.NullTime
+	if err := row.Scan(&f.ID, &f.UserID, &f.URL, &f.Title, &f.IntervalMin, &include, &exclude, &f.Instructions, &f.Status,
+		&f.ETag, &f.LastModified, &f.Failures, &f.LastError, &checked, &next, &f.CreatedAt); err != nil {
+		return nil, err
+	}
+	_ = json.Unmarshal([]byte(include), &f.Include)
+	_ = json.Unmarshal([]byte(exclude), &f.Exclude)
+	if checked.Valid {
+		f.LastCheckedAt = &checked.Time
+	}
+	if next.Valid {
+		f.NextCheckAt = &next.Time
+	}
+	return &f, nil
+}
+
+// CreateFeed stores a new subscription, due for its first check right away, and returns its ID.
+func (db *DB) CreateFeed(ctx context.Context, f Feed) (int64, error) {
+	if err := f.validate(); err != nil {
+		return 0, err
+	}
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO feeds (user_id, url, title, interval_min, include_keywords, exclude_keywords, instructions, status)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		f.UserID, f.URL, f.Title, f.IntervalMin, encodeKeywords(f.Include), encodeKeywords(f.Exclude), f.Instructions, f.Status)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return 0, fmt.Errorf("already subscribed to %s", f.URL)
+		}
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetFeed returns a feed by ID, or nil if not found.
+func (db *DB) GetFeed(ctx context.Context, id int64) (*Feed, error) {
+	f, err := scanFeed(db.QueryRowContext(ctx, `SELECT `+feedColumns+` FROM feeds WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+// ListFeeds returns userID's feeds ("" = every user's), oldest first.
+func (db *DB) ListFeeds(ctx context.Context, userID string) ([]Feed, error) {
+	query := `SELECT ` + feedColumns + ` FROM feeds`
+	var args []interface{}
+	if userID != "" {
+		query += ` WHERE user_id = ?`
+		args = append(args, userID)
+	}
+	rows, err := db.QueryContext(ctx, query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Feed
+	for rows.Next() {
+		f, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *f)
+	}
+	return out, rows.Err()
+}
+
+// UpdateFeed saves the settings of f: title, interval, filters, instructions and status.
+func (db *DB) UpdateFeed(ctx context.Context, f *Feed) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+	res, err := db.ExecContext(ctx,
+		`UPDATE feeds SET title = ?, interval_min = ?, include_keywords = ?, exclude_keywords = ?, instructions = ?, status = ? WHERE id = ?`,
+		f.Title, f.IntervalMin, encodeKeywords(f.Include), encodeKeywords(f.Exclude), f.Instructions, f.Status, f.ID)
+	return expectRow(res, err, fmt.Sprintf("feed %d not found", f.ID))
+}
+
+// RecordFeedCheck saves the outcome of a check: the conditional request headers, the error
+// (failures counts consecutive errors, "" resets it) and when to check next.
+func (db *DB) RecordFeedCheck(ctx context.Context, f *Feed, checkErr string, next time.Time) error {
+	if checkErr == "" {
+		f.Failures = 0
+	} else {
+		f.Failures++
+	}
+	now := time.Now().UTC()
+	next = next.UTC()
+	f.LastError, f.LastCheckedAt, f.NextCheckAt = checkErr, &now, &next
+	_, err := db.ExecContext(ctx,
+		`UPDATE feeds SET etag = ?, last_modified = ?, failures = ?, last_error = ?, last_checked_at = ?, next_check_at = ? WHERE id = ?`,
+		f.ETag, f.LastModified, f.Failures, f.LastError, now, next, f.ID)
+	return err
+}
+
+// DeleteFeed removes a feed and its items.
+func (db *DB) DeleteFeed(ctx context.Context, id int64) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM feeds WHERE id = ?`, id)
+	if err := expectRow(res, err, fmt.Sprintf("feed %d not found", id)); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `DELETE FROM feed_items WHERE feed_id = ?`, id)
+	return err
+}
+
+// AddFeedItem stores an item unless the feed already has one with its GUID; it reports whether
+// the item is new.
+func (db *DB) AddFeedItem(ctx context.Context, it FeedItem) (bool, error) {
+	var published interface{}
+	if it.PublishedAt != nil {
+		published = it.PublishedAt.UTC()
+	}
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO feed_items (feed_id, guid, title, link, summary, published_at, state) VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(feed_id, guid) DO NOTHING`,
+		it.FeedID, it.GUID, it.Title, it.Link, it.Summary, published, it.State)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// PruneFeedItems keeps the newest maxFeedItems items of a feed.
+func (db *DB) PruneFeedItems(ctx context.Context, feedID int64) error {
+	_, err := db.ExecContext(ctx,
+		`DELETE FROM feed_items WHERE feed_id = ? AND id NOT IN (SELECT id FROM feed_items WHERE feed_id = ? ORDER BY id DESC LIMIT ?)`,
+		feedID, feedID, maxFeedItems)
+	return err
+}
+
+// FeedItems returns a feed's newest items, optionally only those in state.
+func (db *DB) FeedItems(ctx context.Context, feedID int64, state string, limit int) ([]FeedItem, error) {
+	query := `SELECT id, feed_id, guid, title, link, summary, published_at, state, fetched_at FROM feed_items WHERE feed_id = ?`
+	args := []interface{}{feedID}
+	if state != "" {
+		query += ` AND state = ?`
+		args = append(args, state)
+	}
+	args = append(args, limit)
+	rows, err := db.QueryContext(ctx, query+` ORDER BY id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []FeedItem
+	for rows.Next() {
+		var it FeedItem
+		var published sql.NullTime
+		if err := rows.Scan(&it.ID, &it.FeedID, &it.GUID, &it.Title, &it.Link, &it.Summary, &published, &it.State, &it.FetchedAt); err != nil {
+			return nil, err
+		}
+		if published.Valid {
+			it.PublishedAt = &published.Time
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// MarkFeedItemsPushed records that items were handed to the agent.
+func (db *DB) MarkFeedItemsPushed(ctx context.Context, ids []int64) error {
+	for _, id := range ids {
+		if _, err := db.ExecContext(ctx, `UPDATE feed_items SET state = ? WHERE id = ?`, FeedItemPushed, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
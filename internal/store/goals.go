@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Goal statuses.
+const (
+	GoalActive    = "active"
+	GoalAchieved  = "achieved"
+	GoalAbandoned = "abandoned"
+)
+
+// GoalMetric is a measurable key result: progress runs from Start to Target.
+type GoalMetric struct {
+	Name    string  `json:"name"`
+	Start   float64 `json:"start"`
+	Target  float64 `json:"target"`
+	Current float64 `json:"current"`
+	Unit    string  `json:"unit,omitempty"`
+}
+
+// Progress is how far Current has moved from Start towards Target, from 0 to 1.
+func (m GoalMetric) Progress() float64 {
+	if m.Target == m.Start {
+		if m.Current == m.Target {
+			return 1
+		}
+		return 0
+	}
+	p := (m.Current - m.Start) / (m.Target - m.Start)
+	if p < 0 {
+		return 0
+	}
+	if p > 1 {
+		return 1
+	}
+	return p
+}
+
+// Goal is a user's objective with a target date and metrics, tied to the jobs and plans that serve it.
+type Goal struct {
+	ID          int64        `json:"id"`
+	UserID      string       `json:"user_id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	TargetDate  string       `json:"target_date,omitempty"` // YYYY-MM-DD
+	Metrics     []GoalMetric `json:"metrics"`
+	Status      string       `json:"status"`
+	ProjectID   int64        `json:"project_id,omitempty"`
+	JobIDs      []int64      `json:"job_ids,omitempty"`
+	PlanIDs     []int64      `json:"plan_ids,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Target returns the end of the target date in loc, and false when the goal is open-ended.
+func (g *Goal) Target(loc *time.Location) (time.Time, bool) {
+	d, err := time.ParseInLocation("2006-01-02", g.TargetDate, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return d.Add(24*time.Hour - time.Second), true
+}
+
+func (g *Goal) validate() error {
+	g.Title = strings.TrimSpace(g.Title)
+	if g.Title == "" {
+		return fmt.Errorf("goal title is required")
+	}
+	switch g.Status {
+	case "":
+		g.Status = GoalActive
+	case GoalActive, GoalAchieved, GoalAbandoned:
+	default:
+		return fmt.Errorf("unknown goal status %q (use active, achieved or abandoned)", g.Status)
+	}
+	if g.TargetDate != "" {
+		if _, err := time.Parse("2006-01-02", g.TargetDate); err != nil {
+			return fmt.Errorf("target date %q is not YYYY-MM-DD", g.TargetDate)
+		}
+	}
+	seen := map[string]bool{}
+	for _, m := range g.Metrics {
+		key := strings.ToLower(strings.TrimSpace(m.Name))
+		if key == "" {
+			return fmt.Errorf("every metric needs a name")
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate metric %q", m.Name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// encode returns the goal's JSON columns: metrics, job IDs and plan IDs.
+func (g *Goal) encode() (metrics, jobs, plans string) {
+	enc := func(v interface{}, empty bool) string {
+		if empty {
+			return "[]"
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return enc(g.Metrics, len(g.Metrics) == 0), enc(g.JobIDs, len(g.JobIDs) == 0), enc(g.PlanIDs, len(g.PlanIDs) == 0)
+}
+
+const goalColumns = `id, user_id, title, description, target_date, metrics, status, project_id, job_ids, plan_ids, created_at, updated_at`
+
+func scanGoal(row rowScanner) (*Goal, error) {
+	var g Goal
+	var metrics, jobs, plans string
+	var project sql.NullInt64
+	if err := row.Scan(&g.ID, &g.UserID, &g.Title, &g.Description, &g.TargetDate, &metrics, &g.Status, &project, &jobs, &plans, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		return nil, err
+	}
+	g.ProjectID = project.Int64
+	g.Metrics = []GoalMetric{}
+	if err := json.Unmarshal([]byte(metrics), &g.Metrics); err != nil {
+		return nil, fmt.Errorf("goal %d metrics: %w", g.ID, err)
+	}
+	_ = json.Unmarshal([]byte(jobs), &g.JobIDs)
+	_ = json.Unmarshal([]byte(plans), &g.PlanIDs)
+	return &g, nil
+}
+
+// CreateGoal stores a new goal (active unless g.Status says otherwise) and returns its ID.
+func (db *DB) CreateGoal(ctx context.Context, g Goal) (int64, error) {
+	if err := g.validate(); err != nil {
+		return 0, err
+	}
+	metrics, jobs, plans := g.encode()
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO goals (user_id, title, description, target_date, metrics, status, project_id, job_ids, plan_ids)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		g.UserID, g.Title, g.Description, g.TargetDate, metrics, g.Status, nullID(g.ProjectID), jobs, plans)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetGoal returns a goal by ID, or nil if not found.
+func (db *DB) GetGoal(ctx context.Context, id int64) (*Goal, error) {
+	g, err := scanGoal(db.QueryRowContext(ctx, `SELECT `+goalColumns+` FROM goals WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return g, err
+}
+
+// ListGoals returns userID's goals with optional status filter, nearest target date first
+// (open-ended goals last).
+func (db *DB) ListGoals(ctx context.Context, userID, status string) ([]Goal, error) {
+	query := `SELECT ` + goalColumns + ` FROM goals WHERE user_id = ?`
+	args := []interface{}{userID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	rows, err := db.QueryContext(ctx, query+` ORDER BY target_date = '', target_date, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Goal
+	for rows.Next() {
+		g, err := scanGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *g)
+	}
+	return out, rows.Err()
+}
+
+// UpdateGoal saves every field of g except its owner and creation time.
+func (db *DB) UpdateGoal(ctx context.Context, g *Goal) error {
+	if err := g.validate(); err != nil {
+		return err
+	}
+	metrics, jobs, plans := g.encode()
+	res, err := db.ExecContext(ctx,
+		`UPDATE goals SET title = ?, description = ?, target_date = ?, metrics = ?, status = ?, project_id = ?,
+		 job_ids = ?, plan_ids = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		g.Title, g.Description, g.TargetDate, metrics, g.Status, nullID(g.ProjectID), jobs, plans, g.ID)
+	return expectRow(res, err, fmt.Sprintf("goal %d not found", g.ID))
+}
+
+// DeleteGoal removes a goal; its jobs and plans stay.
+func (db *DB) DeleteGoal(ctx context.Context, id int64) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM goals WHERE id = ?`, id)
+	return expectRow(res, err, fmt.Sprintf("goal %d not found", id))
+}
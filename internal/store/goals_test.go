@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGoals(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.CreateGoal(ctx, Goal{UserID: "u1", Title: " "}); err == nil {
+		t.Error("goal without title accepted")
+	}
+	if _, err := db.CreateGoal(ctx, Goal{UserID: "u1", Title: "Run", TargetDate: "next year"}); err == nil {
+		t.Error("malformed target date accepted")
+	}
+	open, _ := db.CreateGoal(ctx, Goal{UserID: "u1", Title: "Learn Go"})
+	run, err := db.CreateGoal(ctx, Goal{
+		UserID: "u1", Title: "Run a marathon", TargetDate: "2026-10-01", JobIDs: []int64{4},
+		Metrics: []GoalMetric{{Name: "weekly km", Target: 50, Current: 20, Unit: "km"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.CreateGoal(ctx, Goal{UserID: "u2", Title: "Not mine"})
+
+	goals, _ := db.ListGoals(ctx, "u1", GoalActive)
+	if len(goals) != 2 || goals[0].ID != run || goals[1].ID != open {
+		t.Fatalf("goals = %+v (want dated goal first)", goals)
+	}
+	g := goals[0]
+	if len(g.Metrics) != 1 || g.Metrics[0].Progress() != 0.4 || len(g.JobIDs) != 1 {
+		t.Errorf("goal = %+v", g)
+	}
+	if end, ok := g.Target(time.UTC); !ok || end.Format("2006-01-02 15:04") != "2026-10-01 23:59" {
+		t.Errorf("target = %v %v", end, ok)
+	}
+
+	g.Status = GoalAchieved
+	g.Metrics[0].Current = 55
+	if err := db.UpdateGoal(ctx, &g); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := db.GetGoal(ctx, run)
+	if got.Status != GoalAchieved || got.Metrics[0].Progress() != 1 {
+		t.Errorf("updated goal = %+v", got)
+	}
+	if goals, _ := db.ListGoals(ctx, "u1", GoalActive); len(goals) != 1 {
+		t.Errorf("active goals after achieving one = %d", len(goals))
+	}
+
+	// A falling metric (e.g. weight) progresses towards a lower target
+	m := GoalMetric{Name: "weight", Start: 90, Target: 80, Current: 85}
+	if p := m.Progress(); p != 0.5 {
+		t.Errorf("falling metric progress = %v", p)
+	}
+
+	if err := db.DeleteGoal(ctx, run); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteGoal(ctx, run); err == nil {
+		t.Error("deleted a missing goal")
+	}
+}
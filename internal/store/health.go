@@ -1,23 +1,39 @@
 package store
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/hattiebot/hattiebot/internal/health"
 )
 
+// slowProbe marks the database degraded when the write probe takes longer.
+const slowProbe = 2 * time.Second
+
 // lastHealthOK tracks when the DB was last healthy
-var lastHealthOK time.Time
+var (
+	lastHealthMu sync.Mutex
+	lastHealthOK time.Time
+)
 
-// HealthCheck returns the health status of the database.
+// HealthCheck returns the health status of the database: it must answer a ping, a read and a
+// write (the single health_probe row), within slowProbe to count as ok.
 func (db *DB) HealthCheck() health.ComponentHealth {
 	h := health.ComponentHealth{
 		Name:   "database",
 		Status: "ok",
 	}
+	lastHealthMu.Lock()
+	defer lastHealthMu.Unlock()
+	h.LastOK = lastHealthOK
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
 
 	// Ping the database
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		h.Status = "error"
 		h.Message = err.Error()
 		h.LastError = time.Now()
@@ -26,13 +42,29 @@ func (db *DB) HealthCheck() health.ComponentHealth {
 
 	// Check we can query
 	var count int
-	if err := db.QueryRow("SELECT COUNT(*) FROM messages").Scan(&count); err != nil {
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM messages").Scan(&count); err != nil {
 		h.Status = "degraded"
 		h.Message = "cannot query messages: " + err.Error()
 		h.LastError = time.Now()
 		return h
 	}
 
+	// Check we can write (a full disk or a read-only mount fails here)
+	if _, err := db.ExecContext(ctx,
+		`INSERT INTO health_probe (id, checked_at) VALUES (1, ?) ON CONFLICT(id) DO UPDATE SET checked_at = excluded.checked_at`,
+		time.Now().UTC()); err != nil {
+		h.Status = "error"
+		h.Message = "write probe failed: " + err.Error()
+		h.LastError = time.Now()
+		return h
+	}
+	took := time.Since(start)
+	h.Message = fmt.Sprintf("read and write in %s", took.Round(time.Millisecond))
+	if took > slowProbe {
+		h.Status = "degraded"
+		h.Message = "slow: " + h.Message
+	}
+
 	lastHealthOK = time.Now()
 	h.LastOK = lastHealthOK
 	return h
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestHealthCheckWritesProbe(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := db.HealthCheck(); h.Status != "ok" || h.LastOK.IsZero() {
+		t.Fatalf("health = %+v", h)
+	}
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM health_probe`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("probe rows = %d, %v", n, err)
+	}
+	db.HealthCheck()
+	db.Close()
+	if h := db.HealthCheck(); h.Status != "error" || h.LastOK.IsZero() {
+		t.Fatalf("closed db health = %+v", h)
+	}
+}
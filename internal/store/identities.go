@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IdentityLinkCodeTTL is how long a code from CreateIdentityLinkCode can be used.
+const IdentityLinkCodeTTL = 15 * time.Minute
+
+// Identity links a sender ID on one channel to the canonical user it acts as.
+type Identity struct {
+	Channel    string    `json:"channel"`
+	ExternalID string    `json:"external_id"`
+	UserID     string    `json:"user_id"`
+	LinkedAt   time.Time `json:"linked_at"`
+}
+
+// ResolveIdentity returns the user a channel's sender ID acts as: the linked user, or the sender
+// ID itself when it is not linked.
+func (db *DB) ResolveIdentity(ctx context.Context, channel, externalID string) (string, error) {
+	var userID string
+	err := db.QueryRowContext(ctx, `SELECT user_id FROM identities WHERE channel = ? AND external_id = ?`, channel, externalID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return externalID, nil
+	}
+	return userID, err
+}
+
+// ListIdentities returns the identities linked to userID.
+func (db *DB) ListIdentities(ctx context.Context, userID string) ([]Identity, error) {
+	rows, err := db.QueryContext(ctx, `SELECT channel, external_id, user_id, linked_at FROM identities WHERE user_id = ? ORDER BY linked_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Identity
+	for rows.Next() {
+		var id Identity
+		if err := rows.Scan(&id.Channel, &id.ExternalID, &id.UserID, &id.LinkedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// linkCodeAlphabet leaves out letters and digits that are easy to confuse (0/O, 1/I/L).
+const linkCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// CreateIdentityLinkCode returns a one-time code that links another channel's identity to userID
+// (see LinkIdentity). Only its hash is stored; it expires after IdentityLinkCodeTTL.
+func (db *DB) CreateIdentityLinkCode(ctx context.Context, userID string) (string, time.Time, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	var b strings.Builder
+	for i, c := range buf {
+		if i == 4 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(linkCodeAlphabet[int(c)%len(linkCodeAlphabet)])
+	}
+	code := b.String()
+	expires := time.Now().Add(IdentityLinkCodeTTL)
+	if _, err := db.ExecContext(ctx, `DELETE FROM identity_link_codes WHERE expires_at < ?`, time.Now()); err != nil {
+		return "", time.Time{}, err
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO identity_link_codes (code_hash, user_id, expires_at) VALUES (?, ?, ?)`,
+		hashAPIToken(normalizeLinkCode(code)), userID, expires); err != nil {
+		return "", time.Time{}, err
+	}
+	return code, expires, nil
+}
+
+func normalizeLinkCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
+
+// identityMerges move a linked user's data to the canonical user; each takes the canonical user
+// and the linked one. Rows that would clash (a fact with the same key) stay with the linked user.
+// API tokens and tool permissions are not moved, so linking grants no new rights.
+var identityMerges = []string{
+	`UPDATE OR IGNORE facts SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE memory_chunks SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE submind_sessions SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE scheduled_plans SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE plan_runs SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE pending_inputs SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE jobs SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE goals SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE projects SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE llm_usage SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE OR IGNORE user_profiles SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE held_messages SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE OR IGNORE notification_rules SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE notification_digest SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE escalations SET user_id = ?1 WHERE user_id = ?2`,
+	`UPDATE identities SET user_id = ?1 WHERE user_id = ?2`,
+}
+
+// LinkIdentity uses a code from CreateIdentityLinkCode to make the sender externalID on channel
+// act as the code's user from now on. The user the sender acted as so far has their facts,
+// memories, schedules, jobs, goals and projects moved to the code's user, along with any other
+// identities linked to them. A user cannot be linked into a user with a lower role, so linking
+// never lowers an admin's rights by accident; link from the more privileged identity instead.
+func (db *DB) LinkIdentity(ctx context.Context, code, channel, externalID string) (*Identity, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var target string
+	var expires time.Time
+	hash := hashAPIToken(normalizeLinkCode(code))
+	err = tx.QueryRowContext(ctx, `SELECT user_id, expires_at FROM identity_link_codes WHERE code_hash = ?`, hash).Scan(&target, &expires)
+	if err == sql.ErrNoRows || (err == nil && time.Now().After(expires)) {
+		return nil, fmt.Errorf("invalid or expired link code")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM identity_link_codes WHERE code_hash = ?`, hash); err != nil {
+		return nil, err
+	}
+
+	current := externalID
+	if err := tx.QueryRowContext(ctx, `SELECT user_id FROM identities WHERE channel = ? AND external_id = ?`, channel, externalID).Scan(&current); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if current == target {
+		return nil, fmt.Errorf("this identity already acts as %s", target)
+	}
+	var targetRole, targetTrust string
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(role, 'user'), trust_level FROM users WHERE id = ?`, target).Scan(&targetRole, &targetTrust); err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user %s no longer exists", target)
+	} else if err != nil {
+		return nil, err
+	}
+	if targetTrust == "blocked" {
+		return nil, fmt.Errorf("user %s is blocked", target)
+	}
+	var currentRole string
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(role, 'user') FROM users WHERE id = ?`, current).Scan(&currentRole)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && roleRank[currentRole] > roleRank[targetRole] {
+		return nil, fmt.Errorf("%s has a higher role (%s) than %s (%s); start the link from %s instead", current, currentRole, target, targetRole, current)
+	}
+
+	for _, stmt := range identityMerges {
+		if _, err := tx.ExecContext(ctx, stmt, target, current); err != nil {
+			return nil, err
+		}
+	}
+	id := Identity{Channel: channel, ExternalID: externalID, UserID: target, LinkedAt: time.Now()}
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO identities (channel, external_id, user_id, linked_at) VALUES (?, ?, ?, ?)`,
+		id.Channel, id.ExternalID, id.UserID, id.LinkedAt); err != nil {
+		return nil, err
+	}
+	return &id, tx.Commit()
+}
+
+// UnlinkIdentity removes a link, so the sender acts as its own user again (without the data
+// moved when it was linked). It reports whether there was a link.
+func (db *DB) UnlinkIdentity(ctx context.Context, channel, externalID string) (bool, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM identities WHERE channel = ? AND external_id = ?`, channel, externalID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
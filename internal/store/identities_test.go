@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLinkIdentity(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, id := range []string{"alice", "talk-alice", "boss"} {
+		if _, err := db.GetOrCreateUser(ctx, id, "", "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.UpdateUserRole(ctx, "boss", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetFact(ctx, "talk-alice", "pet", "cat", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetFact(ctx, "talk-alice", "city", "Oslo", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetFact(ctx, "alice", "city", "Bergen", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := db.ResolveIdentity(ctx, "nextcloud_talk", "talk-alice"); err != nil || got != "talk-alice" {
+		t.Fatalf("unlinked resolve = %q, %v", got, err)
+	}
+
+	code, _, err := db.CreateIdentityLinkCode(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.LinkIdentity(ctx, "WRONG-CODE", "nextcloud_talk", "talk-alice"); err == nil {
+		t.Fatal("wrong code linked")
+	}
+	// Codes are matched case-insensitively and without the dash.
+	id, err := db.LinkIdentity(ctx, strings.ToLower(strings.ReplaceAll(code, "-", "")), "nextcloud_talk", "talk-alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.UserID != "alice" {
+		t.Errorf("linked to %q, want alice", id.UserID)
+	}
+	if _, err := db.LinkIdentity(ctx, code, "nextcloud_talk", "talk-alice"); err == nil {
+		t.Error("code used twice")
+	}
+	if got, _ := db.ResolveIdentity(ctx, "nextcloud_talk", "talk-alice"); got != "alice" {
+		t.Errorf("resolve = %q, want alice", got)
+	}
+	if got, _ := db.ResolveIdentity(ctx, "email", "talk-alice"); got != "talk-alice" {
+		t.Errorf("other channel resolve = %q, want talk-alice", got)
+	}
+
+	// The moved fact follows; a clashing one stays with the linked user.
+	if f, _ := db.GetFact(ctx, "alice", "pet"); f == nil || f.Value != "cat" {
+		t.Errorf("pet fact not moved: %+v", f)
+	}
+	if f, _ := db.GetFact(ctx, "alice", "city"); f == nil || f.Value != "Bergen" {
+		t.Errorf("city fact overwritten: %+v", f)
+	}
+
+	// An admin's identity cannot be linked into a plain user.
+	code, _, err = db.CreateIdentityLinkCode(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.LinkIdentity(ctx, code, "terminal", "boss"); err == nil {
+		t.Error("admin linked into a user")
+	}
+
+	ids, err := db.ListIdentities(ctx, "alice")
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("ListIdentities = %v, %v", ids, err)
+	}
+
+	// Purging alice also purges what is still stored under the linked sender ID.
+	res, err := db.PurgeUser(ctx, "alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Deleted["facts"] != 3 || res.Deleted["identities"] != 1 || res.Deleted["users"] != 2 {
+		t.Errorf("purge = %v", res.Deleted)
+	}
+
+	if ok, err := db.UnlinkIdentity(ctx, "nextcloud_talk", "talk-alice"); err != nil || ok {
+		t.Errorf("unlink after purge = %v, %v", ok, err)
+	}
+}
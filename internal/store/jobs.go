@@ -12,9 +12,10 @@ type Job struct {
 	UserID        string     `json:"user_id"`
 	Title         string     `json:"title"`
 	Description   string     `json:"description"`
-	Status        string     `json:"status"` // "open", "blocked", "closed"
+	Status        string     `json:"status"` // "open", "blocked", "awaiting_input", "closed"
 	BlockedReason string     `json:"blocked_reason,omitempty"`
 	SnoozedUntil  *time.Time `json:"snoozed_until,omitempty"`
+	BudgetUSD     *float64   `json:"budget_usd,omitempty"` // nil = no per-job budget
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
 }
@@ -51,7 +52,7 @@ func (db *DB) SnoozeJob(ctx context.Context, id int64, until time.Time) error {
 
 // ListJobs returns jobs filtered by user and status (excludes snoozed jobs).
 func (db *DB) ListJobs(ctx context.Context, userID, status string) ([]Job, error) {
-	query := `SELECT id, user_id, title, description, status, blocked_reason, snoozed_until, created_at, updated_at 
+	query := `SELECT id, user_id, title, description, status, blocked_reason, snoozed_until, budget_usd, created_at, updated_at 
 	          FROM jobs WHERE user_id = ? AND (snoozed_until IS NULL OR snoozed_until <= ?)`
 	args := []interface{}{userID, time.Now()}
 	if status != "" {
@@ -68,46 +69,71 @@ func (db *DB) ListJobs(ctx context.Context, userID, status string) ([]Job, error
 
 	var jobs []Job
 	for rows.Next() {
-		var j Job
-		var reason sql.NullString
-		var snoozed sql.NullTime
-		if err := rows.Scan(&j.ID, &j.UserID, &j.Title, &j.Description, &j.Status, &reason, &snoozed, &j.CreatedAt, &j.UpdatedAt); err != nil {
+		j, err := scanJob(rows)
+		if err != nil {
 			return nil, err
 		}
-		if reason.Valid {
-			j.BlockedReason = reason.String
-		}
-		if snoozed.Valid {
-			j.SnoozedUntil = &snoozed.Time
-		}
-		jobs = append(jobs, j)
+		jobs = append(jobs, *j)
 	}
 	return jobs, nil
 }
 
-// GetActiveJob returns the most recent 'open' or 'blocked' job for a user (excludes snoozed).
+// GetActiveJob returns the most recent 'open', 'blocked' or 'awaiting_input' job for a user (excludes snoozed).
 // This is used to maintain "Epic Context".
 func (db *DB) GetActiveJob(ctx context.Context, userID string) (*Job, error) {
-	query := `SELECT id, user_id, title, description, status, blocked_reason, snoozed_until, created_at, updated_at FROM jobs 
-	          WHERE user_id = ? AND status IN ('open', 'blocked') 
+	query := `SELECT id, user_id, title, description, status, blocked_reason, snoozed_until, budget_usd, created_at, updated_at FROM jobs 
+	          WHERE user_id = ? AND status IN ('open', 'blocked', 'awaiting_input') 
 	          AND (snoozed_until IS NULL OR snoozed_until <= ?)
 	          ORDER BY updated_at DESC LIMIT 1`
-	
-	var j Job
-	var reason sql.NullString
-	var snoozed sql.NullTime
-	err := db.QueryRowContext(ctx, query, userID, time.Now()).Scan(&j.ID, &j.UserID, &j.Title, &j.Description, &j.Status, &reason, &snoozed, &j.CreatedAt, &j.UpdatedAt)
+	j, err := scanJob(db.QueryRowContext(ctx, query, userID, time.Now()))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	if err != nil {
-		return nil, err
+	return j, err
+}
+
+// GetJob returns a job by ID, or nil if not found.
+func (db *DB) GetJob(ctx context.Context, id int64) (*Job, error) {
+	j, err := scanJob(db.QueryRowContext(ctx,
+		`SELECT id, user_id, title, description, status, blocked_reason, snoozed_until, budget_usd, created_at, updated_at FROM jobs WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return j, err
+}
+
+// SetJobBudget sets the per-job cost budget in USD; budget <= 0 clears it.
+func (db *DB) SetJobBudget(ctx context.Context, id int64, budgetUSD float64) error {
+	var v interface{}
+	if budgetUSD > 0 {
+		v = budgetUSD
 	}
-	if reason.Valid {
-		j.BlockedReason = reason.String
+	_, err := db.ExecContext(ctx,
+		`UPDATE jobs SET budget_usd = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		v, id,
+	)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	var j Job
+	var description, reason sql.NullString
+	var snoozed sql.NullTime
+	var budget sql.NullFloat64
+	if err := row.Scan(&j.ID, &j.UserID, &j.Title, &description, &j.Status, &reason, &snoozed, &budget, &j.CreatedAt, &j.UpdatedAt); err != nil {
+		return nil, err
 	}
+	j.Description = description.String
+	j.BlockedReason = reason.String
 	if snoozed.Valid {
 		j.SnoozedUntil = &snoozed.Time
 	}
+	if budget.Valid {
+		j.BudgetUSD = &budget.Float64
+	}
 	return &j, nil
 }
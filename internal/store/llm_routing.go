@@ -2,8 +2,10 @@ package store
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // LLMProviderEntry describes one LLM provider (e.g. openrouter, ollama).
@@ -17,12 +19,45 @@ type LLMProviderEntry struct {
 type ModelRouteEntry struct {
 	Provider string `json:"provider"`
 	Model    string `json:"model"`
+	// Reasoning sets how much a reasoning model thinks: "off", "low", "medium" or "high" ("" = the
+	// model's default). MaxReasoningTokens caps the thinking budget instead and wins over an
+	// effort level when both are set. Each fallback has its own settings.
+	Reasoning          string `json:"reasoning,omitempty"`
+	MaxReasoningTokens int    `json:"max_reasoning_tokens,omitempty"`
+	// Fallbacks are tried in order when this model fails or its circuit breaker is open.
+	Fallbacks []ModelRouteEntry `json:"fallbacks,omitempty"`
+}
+
+// ReasoningLevels are the accepted values of ModelRouteEntry.Reasoning.
+var ReasoningLevels = []string{"off", "low", "medium", "high"}
+
+// ValidateReasoning checks the entry's reasoning settings.
+func (r ModelRouteEntry) ValidateReasoning() error {
+	if r.MaxReasoningTokens < 0 {
+		return fmt.Errorf("%s: max_reasoning_tokens must not be negative", r.Model)
+	}
+	if r.Reasoning == "" {
+		return nil
+	}
+	for _, l := range ReasoningLevels {
+		if r.Reasoning == l {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: reasoning must be one of %s, not %q", r.Model, strings.Join(ReasoningLevels, ", "), r.Reasoning)
+}
+
+// CircuitBreakerConfig controls when a model in a fallback chain is taken out of rotation.
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failure_threshold,omitempty"` // consecutive failures that open the breaker (default 3)
+	CooldownSec      int `json:"cooldown_sec,omitempty"`      // how long an open breaker skips the model (default 300)
 }
 
 // LLMRoutingConfig holds llm_providers and model_routing for dynamic routing.
 type LLMRoutingConfig struct {
-	LLMProviders  map[string]LLMProviderEntry `json:"llm_providers"`
-	ModelRouting  map[string]ModelRouteEntry   `json:"model_routing"`
+	LLMProviders   map[string]LLMProviderEntry `json:"llm_providers"`
+	ModelRouting   map[string]ModelRouteEntry  `json:"model_routing"`
+	CircuitBreaker *CircuitBreakerConfig       `json:"circuit_breaker,omitempty"`
 }
 
 const llmRoutingFilename = "llm_routing.json"
@@ -63,6 +98,19 @@ func SaveLLMRouting(dir string, c *LLMRoutingConfig) error {
 	return os.WriteFile(p, data, 0600)
 }
 
+// Chain returns the route's models in the order they are tried: the primary, then its fallbacks.
+// Entries without a provider or model are skipped.
+func (r ModelRouteEntry) Chain() []ModelRouteEntry {
+	var chain []ModelRouteEntry
+	for _, e := range append([]ModelRouteEntry{r}, r.Fallbacks...) {
+		if e.Provider != "" && e.Model != "" {
+			e.Fallbacks = nil
+			chain = append(chain, e)
+		}
+	}
+	return chain
+}
+
 // HasDefaultRoute returns true if config has a non-empty "default" route.
 func (c *LLMRoutingConfig) HasDefaultRoute() bool {
 	if c == nil {
@@ -71,3 +119,12 @@ func (c *LLMRoutingConfig) HasDefaultRoute() bool {
 	r, ok := c.ModelRouting["default"]
 	return ok && r.Provider != "" && r.Model != ""
 }
+
+// DefaultIsLocal reports whether the default route runs on an "ollama" provider, so HattieBot can
+// start without a hosted provider's key.
+func (c *LLMRoutingConfig) DefaultIsLocal() bool {
+	if !c.HasDefaultRoute() {
+		return false
+	}
+	return c.LLMProviders[c.ModelRouting["default"].Provider].Type == "ollama"
+}
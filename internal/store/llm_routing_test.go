@@ -95,3 +95,21 @@ func TestHasDefaultRoute(t *testing.T) {
 		t.Error("empty provider should not count as default route")
 	}
 }
+
+func TestDefaultIsLocal(t *testing.T) {
+	var none *LLMRoutingConfig
+	if none.DefaultIsLocal() {
+		t.Error("missing config should not be local")
+	}
+	cfg := &LLMRoutingConfig{
+		LLMProviders: map[string]LLMProviderEntry{"local": {Type: "ollama"}, "openrouter": {Type: "openrouter"}},
+		ModelRouting: map[string]ModelRouteEntry{"default": {Provider: "local", Model: "llama3.1"}},
+	}
+	if !cfg.DefaultIsLocal() {
+		t.Error("default route on an ollama provider should be local")
+	}
+	cfg.ModelRouting["default"] = ModelRouteEntry{Provider: "openrouter", Model: "m"}
+	if cfg.DefaultIsLocal() {
+		t.Error("default route on openrouter should not be local")
+	}
+}
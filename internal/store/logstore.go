@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/hattiebot/hattiebot/internal/health"
+	"github.com/hattiebot/hattiebot/internal/redact"
 )
 
 // LogStore handles structured logging with automatic cleanup.
@@ -28,23 +29,6 @@ func NewLogStore(db *sql.DB) *LogStore {
 	}
 }
 
-// CreateTable creates the system_logs table if it doesn't exist.
-func (s *LogStore) CreateTable() error {
-	_, err := s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS system_logs (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-			level TEXT NOT NULL,
-			component TEXT NOT NULL,
-			message TEXT NOT NULL
-		);
-		CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON system_logs(timestamp);
-		CREATE INDEX IF NOT EXISTS idx_logs_level ON system_logs(level);
-		CREATE INDEX IF NOT EXISTS idx_logs_component ON system_logs(component);
-	`)
-	return err
-}
-
 // Log writes a log entry.
 func (s *LogStore) Log(level, component, message string) error {
 	s.mu.Lock()
@@ -52,7 +36,7 @@ func (s *LogStore) Log(level, component, message string) error {
 
 	_, err := s.db.Exec(
 		"INSERT INTO system_logs (level, component, message) VALUES (?, ?, ?)",
-		level, component, message,
+		level, component, redact.String(message),
 	)
 	return err
 }
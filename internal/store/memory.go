@@ -13,19 +13,28 @@ type MemoryChunk struct {
 	Content   string
 	Embedding []float32
 	Source    string
+	UserID    string // who the memory was stored for ("" = unknown); purge_user erases by it
 	CreatedAt time.Time
 	Score     float64 // Similarity score (transient)
+	// EmbeddingModel and EmbeddingDim describe the vector ("" = stored before models were recorded).
+	EmbeddingModel string
+	EmbeddingDim   int
 }
 
-// InsertChunk saves a memory chunk with its embedding.
-func (db *DB) InsertChunk(ctx context.Context, content string, source string, embedding []float32) error {
+// InsertChunk saves a memory chunk with its embedding, made by model, on behalf of userID.
+func (db *DB) InsertChunk(ctx context.Context, content string, source string, userID string, model string, embedding []float32) error {
+	return db.InsertProjectChunk(ctx, content, source, userID, 0, model, embedding)
+}
+
+// InsertProjectChunk saves a memory chunk in a project's namespace (projectID 0 = none).
+func (db *DB) InsertProjectChunk(ctx context.Context, content string, source string, userID string, projectID int64, model string, embedding []float32) error {
 	embBytes, err := json.Marshal(embedding)
 	if err != nil {
 		return err
 	}
 	_, err = db.ExecContext(ctx, 
-		`INSERT INTO memory_chunks (content, source, embedding) VALUES (?, ?, ?)`,
-		content, source, embBytes,
+		`INSERT INTO memory_chunks (content, source, user_id, project_id, embedding, embedding_model, embedding_dim) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		content, source, userID, nullID(projectID), embBytes, model, len(embedding),
 	)
 	return err
 }
@@ -33,7 +42,20 @@ func (db *DB) InsertChunk(ctx context.Context, content string, source string, em
 // SearchChunks performs a naive vector search (cosine similarity).
 // Note: This fetches ALL chunks. For scale > 10k, use sqlite-vec or separate vector DB.
 func (db *DB) SearchChunks(ctx context.Context, queryEmb []float32, limit int) ([]MemoryChunk, error) {
-	rows, err := db.QueryContext(ctx, `SELECT id, content, embedding, source, created_at FROM memory_chunks`)
+	return db.SearchProjectChunks(ctx, queryEmb, limit, 0)
+}
+
+// SearchProjectChunks is SearchChunks within a project: memories of other projects are left out.
+// projectID 0 searches every memory.
+func (db *DB) SearchProjectChunks(ctx context.Context, queryEmb []float32, limit int, projectID int64) ([]MemoryChunk, error) {
+	// Vectors of another dimension cannot match; they wait for a re-embed (see StaleChunks)
+	query := `SELECT id, content, embedding, source, created_at FROM memory_chunks WHERE embedding_dim = ?`
+	args := []interface{}{len(queryEmb)}
+	if projectID != 0 {
+		query += ` AND (project_id IS NULL OR project_id = ?)`
+		args = append(args, projectID)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -84,3 +106,80 @@ func cosineSimilarity(a, b []float32) float64 {
 	}
 	return dot / (math.Sqrt(magA) * math.Sqrt(magB))
 }
+
+// EmbeddingCount is how many memories have vectors from one model and dimension.
+type EmbeddingCount struct {
+	Model  string `json:"model"` // "" = unknown (stored before models were recorded)
+	Dim    int    `json:"dim"`
+	Chunks int    `json:"chunks"`
+}
+
+// EmbeddingCounts groups the memories by the model and dimension of their vectors.
+func (db *DB) EmbeddingCounts(ctx context.Context) ([]EmbeddingCount, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT embedding_model, embedding_dim, COUNT(*) FROM memory_chunks GROUP BY embedding_model, embedding_dim ORDER BY COUNT(*) DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []EmbeddingCount
+	for rows.Next() {
+		var c EmbeddingCount
+		if err := rows.Scan(&c.Model, &c.Dim, &c.Chunks); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// staleWhere selects the memories whose vectors do not come from model at dim: another
+// dimension, or another known model. Memories of an unknown model at the right dimension are
+// kept unless all is set, which selects every memory.
+func staleWhere(model string, dim int, all bool) (string, []interface{}) {
+	if all {
+		return `1 = 1`, nil
+	}
+	return `(embedding_dim != ? OR (embedding_model != '' AND embedding_model != ?))`, []interface{}{dim, model}
+}
+
+// CountStaleChunks counts the memories StaleChunks would return.
+func (db *DB) CountStaleChunks(ctx context.Context, model string, dim int, all bool) (int, error) {
+	where, args := staleWhere(model, dim, all)
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM memory_chunks WHERE `+where, args...).Scan(&n)
+	return n, err
+}
+
+// StaleChunks returns up to limit memories after afterID, in ID order, that need new vectors from
+// model at dim (see staleWhere). Only ID, content and the embedding metadata are filled in.
+func (db *DB) StaleChunks(ctx context.Context, model string, dim int, all bool, afterID int64, limit int) ([]MemoryChunk, error) {
+	where, args := staleWhere(model, dim, all)
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, content, embedding_model, embedding_dim FROM memory_chunks WHERE id > ? AND `+where+` ORDER BY id LIMIT ?`,
+		append(append([]interface{}{afterID}, args...), limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []MemoryChunk
+	for rows.Next() {
+		var c MemoryChunk
+		if err := rows.Scan(&c.ID, &c.Content, &c.EmbeddingModel, &c.EmbeddingDim); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// UpdateChunkEmbedding replaces a memory's vector with one made by model.
+func (db *DB) UpdateChunkEmbedding(ctx context.Context, id int64, model string, embedding []float32) error {
+	embBytes, err := json.Marshal(embedding)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `UPDATE memory_chunks SET embedding = ?, embedding_model = ?, embedding_dim = ? WHERE id = ?`,
+		embBytes, model, len(embedding), id)
+	return err
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaleChunks(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, c := range []struct {
+		content, model string
+		emb            []float32
+	}{
+		{"current", "new", []float32{1, 0}},
+		{"old model", "old", []float32{1, 0}},
+		{"old dimension", "old", []float32{1, 0, 0}},
+		{"unknown model", "", []float32{0, 1}},
+	} {
+		if err := db.InsertChunk(ctx, c.content, "chat", "u1", c.model, c.emb); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stale, err := db.StaleChunks(ctx, "new", 2, false, 0, 10)
+	if err != nil || len(stale) != 2 || stale[0].Content != "old model" || stale[1].Content != "old dimension" {
+		t.Fatalf("stale = %+v, %v", stale, err)
+	}
+	if n, err := db.CountStaleChunks(ctx, "new", 2, true); err != nil || n != 4 {
+		t.Errorf("count with all = %d, %v", n, err)
+	}
+	if page, err := db.StaleChunks(ctx, "new", 2, true, stale[0].ID, 1); err != nil || len(page) != 1 || page[0].Content != "old dimension" {
+		t.Errorf("page after %d = %+v, %v", stale[0].ID, page, err)
+	}
+
+	// Only vectors of the query's dimension are searched
+	found, err := db.SearchChunks(ctx, []float32{1, 0}, 10)
+	if err != nil || len(found) != 3 {
+		t.Errorf("search = %+v, %v", found, err)
+	}
+	if err := db.UpdateChunkEmbedding(ctx, stale[1].ID, "new", []float32{0.5, 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.CountStaleChunks(ctx, "new", 2, false); err != nil || n != 1 {
+		t.Errorf("stale after update = %d, %v", n, err)
+	}
+	if found, err := db.SearchChunks(ctx, []float32{1, 0}, 10); err != nil || len(found) != 4 {
+		t.Errorf("search after update = %+v, %v", found, err)
+	}
+}
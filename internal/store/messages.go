@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/hattiebot/hattiebot/internal/redact"
+	"github.com/hattiebot/hattiebot/internal/tracing"
 )
 
 // Message represents a chat message (user, assistant, or system).
@@ -18,14 +21,16 @@ type Message struct {
 	ToolCalls   string    `json:"tool_calls,omitempty"`   // JSON
 	ToolResults string    `json:"tool_results,omitempty"` // JSON
 	ToolCallID  string    `json:"tool_call_id,omitempty"` // For role=tool messages
+	TraceID     string    `json:"trace_id,omitempty"`     // OpenTelemetry trace of the turn, when tracing is on
 	CreatedAt   time.Time `json:"created_at"`
 }
 
-// InsertMessage inserts a message and returns its id.
+// InsertMessage inserts a message and returns its id. The trace ID of the span in ctx, if any, is
+// stored with it.
 func (db *DB) InsertMessage(ctx context.Context, role, content, model, senderID, channel, threadID, toolCalls, toolResults, toolCallID string) (int64, error) {
 	res, err := db.ExecContext(ctx,
-		`INSERT INTO messages (role, content, model, sender_id, channel, thread_id, tool_calls, tool_results, tool_call_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		role, content, model, senderID, channel, threadID, toolCalls, toolResults, toolCallID,
+		`INSERT INTO messages (role, content, model, sender_id, channel, thread_id, tool_calls, tool_results, tool_call_id, trace_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		role, redact.String(content), model, senderID, channel, threadID, redact.String(toolCalls), redact.String(toolResults), toolCallID, tracing.TraceID(ctx),
 	)
 	if err != nil {
 		return 0, err
@@ -33,6 +38,29 @@ func (db *DB) InsertMessage(ctx context.Context, role, content, model, senderID,
 	return res.LastInsertId()
 }
 
+// InsertImportedMessage inserts a message from an imported conversation, keeping its original
+// timestamp (zero createdAt = now). Tool fields are left empty.
+func (db *DB) InsertImportedMessage(ctx context.Context, role, content, senderID, channel, threadID string, createdAt time.Time) (int64, error) {
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO messages (role, content, model, sender_id, channel, thread_id, created_at) VALUES (?, ?, '', ?, ?, ?, ?)`,
+		role, redact.String(content), senderID, channel, threadID, createdAt.UTC().Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ThreadExists reports whether any message has been stored for threadID.
+func (db *DB) ThreadExists(ctx context.Context, threadID string) (bool, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM (SELECT 1 FROM messages WHERE thread_id = ? LIMIT 1)`, threadID).Scan(&n)
+	return n > 0, err
+}
+
 // AllMessages returns all messages ordered by created_at (full conversation history).
 func (db *DB) AllMessages(ctx context.Context) ([]Message, error) {
 	rows, err := db.QueryContext(ctx,
@@ -63,7 +91,7 @@ func (db *DB) AllMessages(ctx context.Context) ([]Message, error) {
 // RecentMessages returns the last N messages (ordered by creation).
 // Filtered by threadID. Pass "" to ignore.
 func (db *DB) RecentMessages(ctx context.Context, limit int, threadID string) ([]Message, error) {
-	query := `SELECT id, role, content, model, sender_id, channel, thread_id, tool_calls, tool_results, tool_call_id, created_at 
+	query := `SELECT id, role, content, model, sender_id, channel, thread_id, tool_calls, tool_results, tool_call_id, trace_id, created_at 
 		 FROM messages`
 	var args []interface{}
 	if threadID != "" {
@@ -82,7 +110,7 @@ func (db *DB) RecentMessages(ctx context.Context, limit int, threadID string) ([
 	for rows.Next() {
 		var m Message
 		var toolCalls, toolResults, toolCallID sql.NullString
-		err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.Model, &m.SenderID, &m.Channel, &m.ThreadID, &toolCalls, &toolResults, &toolCallID, &m.CreatedAt)
+		err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.Model, &m.SenderID, &m.Channel, &m.ThreadID, &toolCalls, &toolResults, &toolCallID, &m.TraceID, &m.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -104,6 +132,50 @@ func (db *DB) RecentMessages(ctx context.Context, limit int, threadID string) ([
 	return out, rows.Err()
 }
 
+// ThreadSummary describes one conversation thread for listings.
+type ThreadSummary struct {
+	ThreadID     string    `json:"thread_id"`
+	Channel      string    `json:"channel"`
+	MessageCount int       `json:"message_count"`
+	LastAt       time.Time `json:"last_at"`
+	LastMessage  string    `json:"last_message"` // last user or assistant message
+}
+
+// ListThreads returns the threads with the most recent activity first.
+func (db *DB) ListThreads(ctx context.Context, limit int) ([]ThreadSummary, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT m.thread_id, COUNT(*), MAX(m.id),
+			(SELECT channel FROM messages c WHERE c.thread_id = m.thread_id ORDER BY c.id DESC LIMIT 1),
+			COALESCE((SELECT content FROM messages l WHERE l.thread_id = m.thread_id AND l.role IN ('user', 'assistant') AND l.content != '' ORDER BY l.id DESC LIMIT 1), '')
+		 FROM messages m GROUP BY m.thread_id ORDER BY MAX(m.id) DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ThreadSummary
+	var lastIDs []int64
+	for rows.Next() {
+		var t ThreadSummary
+		var lastID int64
+		var channel sql.NullString
+		if err := rows.Scan(&t.ThreadID, &t.MessageCount, &lastID, &channel, &t.LastMessage); err != nil {
+			return nil, err
+		}
+		t.Channel = channel.String
+		out = append(out, t)
+		lastIDs = append(lastIDs, lastID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, id := range lastIDs {
+		if err := db.QueryRowContext(ctx, `SELECT created_at FROM messages WHERE id = ?`, id).Scan(&out[i].LastAt); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
 // SearchMessages searches for messages containing the query string (case-insensitive LIKE).
 func (db *DB) SearchMessages(ctx context.Context, query string, limit int) ([]Message, error) {
 	q := `SELECT id, role, content, model, sender_id, channel, thread_id, tool_calls, tool_results, tool_call_id, created_at 
@@ -149,3 +221,44 @@ type MessageStore interface {
 
 // Ensure *DB implements MessageStore.
 var _ MessageStore = (*DB)(nil)
+
+// ThreadMessages returns every message in threadID, oldest first.
+func (db *DB) ThreadMessages(ctx context.Context, threadID string) ([]Message, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, role, content, COALESCE(model, ''), sender_id, channel, thread_id, tool_calls, tool_results, tool_call_id, trace_id, created_at
+		 FROM messages WHERE thread_id = ? ORDER BY created_at ASC, id ASC`, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Message
+	for rows.Next() {
+		var m Message
+		var toolCalls, toolResults, toolCallID sql.NullString
+		if err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.Model, &m.SenderID, &m.Channel, &m.ThreadID, &toolCalls, &toolResults, &toolCallID, &m.TraceID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		m.ToolCalls, m.ToolResults, m.ToolCallID = toolCalls.String, toolResults.String, toolCallID.String
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// UserThreadIDs returns the threads userID has sent messages in, oldest activity first.
+func (db *DB) UserThreadIDs(ctx context.Context, userID string) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT thread_id FROM messages WHERE sender_id = ? GROUP BY thread_id ORDER BY MIN(created_at), MIN(id)`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
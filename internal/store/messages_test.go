@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/tracing"
+)
+
+func TestInsertMessageStoresTraceID(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+	shutdown := tracing.Setup(srv.URL, nil)
+	defer shutdown(ctx)
+
+	if _, err := db.InsertMessage(ctx, "user", "untraced", "", "alice", "admin_term", "t1", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	traced, span := tracing.Start(ctx, "agent.turn")
+	defer span.End()
+	if _, err := db.InsertMessage(traced, "assistant", "traced", "", "hattiebot", "admin_term", "t1", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := db.ThreadMessages(ctx, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].TraceID != "" || msgs[1].TraceID != tracing.TraceID(traced) {
+		t.Fatalf("messages = %+v, want the second with trace %s", msgs, tracing.TraceID(traced))
+	}
+	recent, err := db.RecentMessages(ctx, 1, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 || recent[0].TraceID != tracing.TraceID(traced) {
+		t.Fatalf("recent = %+v", recent)
+	}
+}
@@ -0,0 +1,539 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is one versioned schema change. Pending migrations run in order when the database is
+// opened, each in its own transaction, and are recorded in schema_migrations. Never edit or
+// renumber a migration that has shipped; add a new one at the end instead.
+type migration struct {
+	version int
+	name    string
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// column is a column added by addColumns.
+type column struct{ name, def string }
+
+var migrations = []migration{
+	// Databases created before migrations were versioned have run some of these already; the
+	// baseline only creates what is missing and addColumns skips existing columns.
+	{1, "baseline schema", execSQL(schema)},
+	{2, "scheduled_plans.locked_until", addColumns("scheduled_plans", column{"locked_until", "DATETIME"})},
+	// time zone the recurrence rule is evaluated in
+	{3, "scheduled_plans.timezone", addColumns("scheduled_plans", column{"timezone", "TEXT"})},
+	// Strict columns: NOT NULL without a default fails unless the table is empty
+	{4, "messages.sender_id", addColumns("messages", column{"sender_id", "TEXT NOT NULL"})},
+	{5, "messages.channel", addColumns("messages", column{"channel", "TEXT NOT NULL"})},
+	// The old facts table keeps its UNIQUE(key); SQLite cannot change constraints in place.
+	{6, "facts.user_id", addColumns("facts", column{"user_id", "TEXT NOT NULL"})},
+	{7, "messages.thread_id", addColumns("messages", column{"thread_id", "TEXT NOT NULL DEFAULT ''"})},
+	{8, "users.trust_level", addColumns("users", column{"trust_level", "TEXT DEFAULT 'restricted'"})},
+	{9, "users.metadata", addColumns("users", column{"metadata", "TEXT"})},
+	// tool health (status, last_success, failure_count, last_error) and versioning
+	{10, "tools_registry health and versions", addColumns("tools_registry",
+		column{"status", "TEXT DEFAULT 'active'"},
+		column{"last_success", "DATETIME"},
+		column{"failure_count", "INTEGER DEFAULT 0"},
+		column{"last_error", "TEXT"},
+		column{"version", "INTEGER DEFAULT 1"},
+		column{"source_hash", "TEXT"},
+		column{"previous_binary_path", "TEXT"},
+		column{"last_failed_input", "TEXT"},
+	)},
+	{11, "tool_versions source provenance", addColumns("tool_versions",
+		column{"source_dir", "TEXT"},
+		column{"source", "TEXT"},
+		column{"git_commit", "TEXT"},
+		column{"git_dirty", "INTEGER DEFAULT 0"},
+	)},
+	{12, "jobs.budget_usd", addColumns("jobs", column{"budget_usd", "REAL"})},
+	// Roles replaced the single admin: users promoted to trust level "admin" before roles existed become admins.
+	{13, "users.role", func(ctx context.Context, tx *sql.Tx) error {
+		if err := addColumns("users", column{"role", "TEXT DEFAULT 'user'"})(ctx, tx); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE users SET role = 'admin' WHERE trust_level = 'admin' AND (role IS NULL OR role = '' OR role = 'user')")
+		return err
+	}},
+	// Memories stored before this have no owner and are not erased by purge_user.
+	{14, "memory_chunks.user_id", addColumns("memory_chunks", column{"user_id", "TEXT NOT NULL DEFAULT ''"})},
+	{15, "conversation_summaries", execSQL(`
+CREATE TABLE IF NOT EXISTS conversation_summaries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	thread_id TEXT NOT NULL,
+	channel TEXT,
+	participants TEXT NOT NULL DEFAULT '[]', -- JSON array of the human sender IDs summarized
+	content TEXT NOT NULL, -- cumulative: includes the thread's previous summary
+	covers_until DATETIME NOT NULL, -- created_at of the newest message summarized
+	message_count INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_conversation_summaries_thread ON conversation_summaries(thread_id, covers_until);
+CREATE INDEX IF NOT EXISTS idx_messages_thread_created ON messages(thread_id, created_at);`)},
+	{16, "pending_inputs", execSQL(`
+CREATE TABLE IF NOT EXISTS pending_inputs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL, -- who was asked; only their reply answers
+	thread_id TEXT NOT NULL,
+	channel TEXT NOT NULL DEFAULT '',
+	job_id INTEGER, -- job in status awaiting_input until answered
+	submind_session_id INTEGER, -- sub-mind session resumed with the answer
+	question TEXT NOT NULL,
+	expect TEXT NOT NULL DEFAULT '{}', -- JSON Expectation
+	step TEXT NOT NULL DEFAULT '', -- what the agent does with the answer
+	status TEXT NOT NULL DEFAULT 'awaiting', -- awaiting, answered, cancelled, expired
+	answer TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL,
+	resolved_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_pending_inputs_thread ON pending_inputs(thread_id, status);`)},
+	// Background sessions keep the conversation they were started from, to ask the user and to resume after a restart
+	{17, "submind_sessions async", addColumns("submind_sessions",
+		column{"async", "INTEGER NOT NULL DEFAULT 0"},
+		column{"thread_id", "TEXT NOT NULL DEFAULT ''"},
+		column{"channel", "TEXT NOT NULL DEFAULT ''"},
+	)},
+	{18, "projects", func(ctx context.Context, tx *sql.Tx) error {
+		if err := execSQL(`
+CREATE TABLE IF NOT EXISTS projects (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'active', -- active, done, archived
+	is_current INTEGER NOT NULL DEFAULT 0, -- the user's current project (at most one)
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(user_id, name)
+);
+CREATE TABLE IF NOT EXISTS project_items (
+	project_id INTEGER NOT NULL,
+	kind TEXT NOT NULL, -- context_doc (ref = title) or thread (ref = thread ID)
+	ref TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY(project_id, kind, ref)
+);
+CREATE INDEX IF NOT EXISTS idx_project_items_ref ON project_items(kind, ref);`)(ctx, tx); err != nil {
+			return err
+		}
+		// Jobs, schedules and memories belong to at most one project (NULL = none)
+		for _, table := range []string{"jobs", "scheduled_plans", "memory_chunks"} {
+			if err := addColumns(table, column{"project_id", "INTEGER"})(ctx, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{19, "goals", execSQL(`
+CREATE TABLE IF NOT EXISTS goals (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	target_date TEXT NOT NULL DEFAULT '', -- YYYY-MM-DD, '' = open-ended
+	metrics TEXT NOT NULL DEFAULT '[]', -- JSON array of GoalMetric
+	status TEXT NOT NULL DEFAULT 'active', -- active, achieved, abandoned
+	project_id INTEGER, -- the project whose jobs and schedules serve the goal
+	job_ids TEXT NOT NULL DEFAULT '[]', -- JSON array of further related job IDs
+	plan_ids TEXT NOT NULL DEFAULT '[]', -- JSON array of further related scheduled plan IDs
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_goals_user ON goals(user_id, status);`)},
+	{20, "webhook_events", execSQL(`
+CREATE TABLE IF NOT EXISTS webhook_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	route_id TEXT NOT NULL DEFAULT '',
+	path TEXT NOT NULL,
+	tool TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL, -- ok, failed
+	summary TEXT NOT NULL DEFAULT '', -- start of the tool result or error
+	received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	read_at DATETIME -- when a briefing reported it; NULL = unread
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_unread ON webhook_events(read_at, received_at);`)},
+	{21, "workspace_sync", execSQL(`
+CREATE TABLE IF NOT EXISTS workspace_sync (
+	path TEXT PRIMARY KEY, -- slash-separated, relative to the workspace and the synced folder
+	local_sig TEXT NOT NULL, -- size and modification time of the local file at the last sync
+	remote_etag TEXT NOT NULL, -- Nextcloud ETag at the last sync
+	synced_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`)},
+	{22, "feeds", execSQL(`
+CREATE TABLE IF NOT EXISTS feeds (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	title TEXT NOT NULL DEFAULT '',
+	interval_min INTEGER NOT NULL DEFAULT 60,
+	include_keywords TEXT NOT NULL DEFAULT '[]', -- JSON array; an item must contain one (empty = all)
+	exclude_keywords TEXT NOT NULL DEFAULT '[]', -- JSON array; items containing one are skipped
+	instructions TEXT NOT NULL DEFAULT '', -- extra guidance for the agent about new items
+	status TEXT NOT NULL DEFAULT 'active', -- active, paused
+	etag TEXT NOT NULL DEFAULT '',
+	last_modified TEXT NOT NULL DEFAULT '',
+	failures INTEGER NOT NULL DEFAULT 0, -- consecutive failed checks
+	last_error TEXT NOT NULL DEFAULT '',
+	last_checked_at DATETIME,
+	next_check_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(user_id, url)
+);
+CREATE TABLE IF NOT EXISTS feed_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	feed_id INTEGER NOT NULL,
+	guid TEXT NOT NULL,
+	title TEXT NOT NULL DEFAULT '',
+	link TEXT NOT NULL DEFAULT '',
+	summary TEXT NOT NULL DEFAULT '',
+	published_at DATETIME,
+	state TEXT NOT NULL, -- seen (baseline or filtered out), pending (waiting for the agent), pushed
+	fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(feed_id, guid)
+);
+CREATE INDEX IF NOT EXISTS idx_feed_items_state ON feed_items(feed_id, state);`)},
+	// Remote tools: kind 'http' POSTs the arguments to url instead of running binary_path
+	{23, "tools_registry http tools", addColumns("tools_registry",
+		column{"kind", "TEXT NOT NULL DEFAULT 'binary'"},
+		column{"url", "TEXT NOT NULL DEFAULT ''"},
+		column{"auth_header", "TEXT NOT NULL DEFAULT ''"},
+		column{"auth_secret", "TEXT NOT NULL DEFAULT ''"},
+	)},
+	// Regenerated replies stay in the table but leave the context; branches inherit their parent's history
+	{24, "thread branches", func(ctx context.Context, tx *sql.Tx) error {
+		if err := addColumns("messages", column{"superseded", "INTEGER NOT NULL DEFAULT 0"})(ctx, tx); err != nil {
+			return err
+		}
+		return execSQL(`
+CREATE TABLE IF NOT EXISTS thread_branches (
+	thread_id TEXT PRIMARY KEY,
+	parent_thread_id TEXT NOT NULL,
+	parent_message_id INTEGER NOT NULL, -- last message of the parent the branch inherits
+	created_by TEXT NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_thread_branches_parent ON thread_branches(parent_thread_id);`)(ctx, tx)
+	}},
+	{25, "feedback", execSQL(`
+CREATE TABLE IF NOT EXISTS feedback (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	channel TEXT NOT NULL DEFAULT '',
+	thread_id TEXT NOT NULL DEFAULT '',
+	message_id INTEGER NOT NULL DEFAULT 0, -- assistant message rated; 0 = none found
+	rating INTEGER NOT NULL DEFAULT 0, -- 1 good, -1 bad, 0 comment only
+	comment TEXT NOT NULL DEFAULT '',
+	source TEXT NOT NULL, -- reaction or command
+	reaction TEXT NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_feedback_created ON feedback(created_at);`)},
+	// A/B tests of SOUL.md: turns are assigned to the control (current SOUL.md) or the variant text
+	{26, "prompt experiments", execSQL(`
+CREATE TABLE IF NOT EXISTS prompt_experiments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	variant TEXT NOT NULL,
+	baseline TEXT NOT NULL DEFAULT '', -- SOUL.md when the experiment started
+	fraction REAL NOT NULL, -- share of turns that get the variant
+	status TEXT NOT NULL DEFAULT 'running', -- running, promoted, stopped
+	created_by TEXT NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	ended_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS experiment_turns (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	experiment_id INTEGER NOT NULL,
+	arm TEXT NOT NULL, -- control or variant
+	user_id TEXT NOT NULL DEFAULT '',
+	thread_id TEXT NOT NULL DEFAULT '',
+	message_id INTEGER NOT NULL, -- the turn's final assistant reply
+	tool_errors INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_experiment_turns_experiment ON experiment_turns(experiment_id, arm);`)},
+	// OpenTelemetry trace of the turn that stored the message, to find it in the tracing backend
+	{27, "message trace ids", addColumns("messages", column{"trace_id", "TEXT NOT NULL DEFAULT ''"})},
+	// One row rewritten by the database health check, to prove writes still go through
+	{28, "health probe", execSQL(`
+CREATE TABLE IF NOT EXISTS health_probe (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	checked_at DATETIME NOT NULL
+);`)},
+	// Turns in flight; rows left after a crash are reported to their thread on startup
+	{29, "turn journal", execSQL(`
+CREATE TABLE IF NOT EXISTS turn_journal (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	channel TEXT NOT NULL DEFAULT '',
+	thread_id TEXT NOT NULL DEFAULT '',
+	content TEXT NOT NULL DEFAULT '', -- the user message the turn answers
+	state TEXT NOT NULL DEFAULT 'thinking', -- thinking, tools, replying
+	tool_calls TEXT NOT NULL DEFAULT '[]', -- JSON []JournalToolCall of the turn so far
+	started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`)},
+	{30, "webhook deliveries", execSQL(`
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	route_id TEXT NOT NULL, -- route id, or path for routes without one
+	delivery_id TEXT NOT NULL, -- from the route's delivery_id_header or delivery_id_path
+	status TEXT NOT NULL DEFAULT 'processing', -- processing, ok, failed
+	result TEXT NOT NULL DEFAULT '', -- the target tool's result, returned to duplicates
+	received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL,
+	PRIMARY KEY (route_id, delivery_id)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_expires ON webhook_deliveries(expires_at);`)},
+	// Outbound webhooks: external subscribers to bot events, and the signed deliveries owed to them
+	{31, "event subscriptions", execSQL(`
+CREATE TABLE IF NOT EXISTS event_subscriptions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL, -- HMAC-SHA256 key for the X-HattieBot-Signature header
+	events TEXT NOT NULL DEFAULT '', -- comma-separated event types; empty = all
+	description TEXT NOT NULL DEFAULT '',
+	created_by TEXT NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_delivery_at DATETIME,
+	last_error TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS event_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	subscription_id INTEGER NOT NULL,
+	event_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	user_id TEXT NOT NULL DEFAULT '', -- the user the event is about, for purges
+	payload TEXT NOT NULL, -- the JSON body, signed as sent
+	status TEXT NOT NULL DEFAULT 'pending', -- pending, delivered, failed
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at DATETIME NOT NULL,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	finished_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_event_deliveries_due ON event_deliveries(status, next_attempt_at);`)},
+	// API token scopes (read, write, admin); existing tokens keep full access
+	{32, "api_tokens.scopes", addColumns("api_tokens", column{"scopes", "TEXT NOT NULL DEFAULT 'read,write,admin'"})},
+	// Identity linking: channel-specific sender IDs that act as one canonical user
+	{33, "identities", execSQL(`
+CREATE TABLE IF NOT EXISTS identities (
+	channel TEXT NOT NULL,
+	external_id TEXT NOT NULL, -- the sender ID on that channel
+	user_id TEXT NOT NULL, -- the canonical user it acts as
+	linked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (channel, external_id)
+);
+CREATE INDEX IF NOT EXISTS idx_identities_user ON identities(user_id);
+CREATE TABLE IF NOT EXISTS identity_link_codes (
+	code_hash TEXT PRIMARY KEY, -- sha256 hex; the code is shown once to the user who asked for it
+	user_id TEXT NOT NULL,
+	expires_at DATETIME NOT NULL
+);`)},
+	// Typed user preferences, and proactive messages held during their quiet hours
+	{34, "user profiles", execSQL(`
+CREATE TABLE IF NOT EXISTS user_profiles (
+	user_id TEXT PRIMARY KEY,
+	language TEXT NOT NULL DEFAULT '',
+	timezone TEXT NOT NULL DEFAULT '', -- IANA zone; empty = server local
+	verbosity TEXT NOT NULL DEFAULT '', -- brief, normal, detailed
+	formality TEXT NOT NULL DEFAULT '', -- casual, neutral, formal
+	quiet_start TEXT NOT NULL DEFAULT '', -- HH:MM in timezone
+	quiet_end TEXT NOT NULL DEFAULT '',
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS held_messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	content TEXT NOT NULL,
+	urgency TEXT NOT NULL DEFAULT '',
+	deliver_at DATETIME NOT NULL, -- when the quiet hours end
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_held_messages_deliver ON held_messages(deliver_at);`)},
+	// Per-user notification routing, and the low-priority notifications batched into digests
+	{35, "notification rules", execSQL(`
+CREATE TABLE IF NOT EXISTS notification_rules (
+	user_id TEXT PRIMARY KEY,
+	channels TEXT NOT NULL DEFAULT '', -- JSON object: urgency -> channel
+	quiet_bypass TEXT NOT NULL DEFAULT '', -- lowest urgency sent during quiet hours; empty = urgent
+	digest_below TEXT NOT NULL DEFAULT '', -- urgencies below this are batched; empty = no digest
+	digest_hours INTEGER NOT NULL DEFAULT 0, -- 0 = daily
+	last_digest_at DATETIME,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS notification_digest (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	content TEXT NOT NULL,
+	urgency TEXT NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_notification_digest_user ON notification_digest(user_id);`)},
+	// Escalation chains: where each item needing attention is in its chain, and who acknowledged it
+	{36, "escalations", execSQL(`
+CREATE TABLE IF NOT EXISTS escalations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL, -- plan
+	item_id INTEGER NOT NULL,
+	user_id TEXT NOT NULL,
+	subject TEXT NOT NULL DEFAULT '',
+	step INTEGER NOT NULL DEFAULT 0, -- chain steps notified so far
+	admin_notified BOOLEAN NOT NULL DEFAULT 0,
+	started_at DATETIME NOT NULL,
+	acked_at DATETIME,
+	acked_by TEXT NOT NULL DEFAULT '',
+	resolved_at DATETIME -- NULL while the item still needs attention
+);
+CREATE INDEX IF NOT EXISTS idx_escalations_open ON escalations(kind, item_id, resolved_at);`)},
+	// Which embedding model and dimension produced each memory's vector; older rows get their
+	// dimension from the stored vector and an unknown ('') model
+	{37, "memory_chunks embedding model", func(ctx context.Context, tx *sql.Tx) error {
+		if err := addColumns("memory_chunks",
+			column{"embedding_model", "TEXT NOT NULL DEFAULT ''"},
+			column{"embedding_dim", "INTEGER NOT NULL DEFAULT 0"},
+		)(ctx, tx); err != nil {
+			return err
+		}
+		return execSQL(`
+UPDATE memory_chunks SET embedding_dim = json_array_length(CAST(embedding AS TEXT))
+WHERE embedding IS NOT NULL AND json_valid(CAST(embedding AS TEXT));`)(ctx, tx)
+	}},
+	// Per-thread settings (manage_thread): a prompt addendum, a tool subset and verbosity per room
+	{38, "thread_settings", execSQL(`
+CREATE TABLE IF NOT EXISTS thread_settings (
+	channel TEXT NOT NULL,
+	thread_id TEXT NOT NULL,
+	prompt_addendum TEXT NOT NULL DEFAULT '',
+	allowed_tools TEXT NOT NULL DEFAULT '', -- JSON array of tool patterns; '' = every tool
+	verbosity TEXT NOT NULL DEFAULT '',
+	updated_by TEXT NOT NULL DEFAULT '',
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (channel, thread_id)
+);`)},
+	// Which group messages the bot answers in a room ('' = the configured default)
+	{39, "thread_settings.addressing", addColumns("thread_settings", column{"addressing", "TEXT NOT NULL DEFAULT ''"})},
+	// plans whose runs may use confirm-gated tools unasked, approved by the user at creation
+	{40, "scheduled_plans.preapproved", addColumns("scheduled_plans", column{"preapproved", "INTEGER NOT NULL DEFAULT 0"})},
+}
+
+func execSQL(stmts string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, stmts)
+		return err
+	}
+}
+
+// addColumns adds the columns table does not have yet.
+func addColumns(table string, cols ...column) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, c := range cols {
+			var count int
+			if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, c.name).Scan(&count); err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, "ALTER TABLE "+table+" ADD COLUMN "+c.name+" "+c.def); err != nil {
+				return fmt.Errorf("%s.%s: %w", table, c.name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// MigrationStatus is one schema migration and when it was applied (nil = pending).
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+const migrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Migrate applies pending migrations in order and returns the ones it applied. A failed
+// migration is rolled back and stops the run; earlier ones stay applied.
+func (db *DB) Migrate(ctx context.Context) ([]MigrationStatus, error) {
+	if _, err := db.ExecContext(ctx, migrationsTable); err != nil {
+		return nil, err
+	}
+	var applied []MigrationStatus
+	for _, m := range migrations {
+		ok, err := db.applyMigration(ctx, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		if ok {
+			now := time.Now()
+			applied = append(applied, MigrationStatus{Version: m.version, Name: m.name, AppliedAt: &now})
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration runs m unless it is already recorded. The check happens inside the write
+// transaction, so two processes opening the database at once apply it only once.
+func (db *DB) applyMigration(ctx context.Context, m migration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = ?", m.version).Scan(&count); err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if err := m.up(ctx, tx); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// MigrationStatus lists every known migration with the time it was applied.
+func (db *DB) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	if _, err := db.ExecContext(ctx, migrationsTable); err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	appliedAt := map[int]time.Time{}
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[v] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.version, Name: m.name}
+		if at, ok := appliedAt[m.version]; ok {
+			s.AppliedAt = &at
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
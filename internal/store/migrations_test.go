@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenSetsPragmas(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var mode string
+	var timeout int
+	if err := db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode = %q, %v", mode, err)
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != 5000 {
+		t.Errorf("busy_timeout = %d, %v", timeout, err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// A database from before messages had threads and users had roles
+	old, err := OpenWithoutMigrating(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE messages (id INTEGER PRIMARY KEY AUTOINCREMENT, role TEXT NOT NULL, content TEXT NOT NULL, model TEXT, sender_id TEXT NOT NULL, channel TEXT NOT NULL, tool_calls TEXT, tool_results TEXT, tool_call_id TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+		`INSERT INTO messages (role, content, model, sender_id, channel, tool_calls, tool_results, tool_call_id) VALUES ('user', 'hi', '', 'alice', 'api', '', '', '')`,
+		`CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT, platform TEXT, trust_level TEXT, first_seen DATETIME, last_seen DATETIME)`,
+		`INSERT INTO users (id, name, platform, trust_level, first_seen, last_seen) VALUES ('boss', '', 'api', 'admin', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		`CREATE TABLE memory_chunks (id INTEGER PRIMARY KEY AUTOINCREMENT, content TEXT NOT NULL, embedding BLOB, source TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+		`INSERT INTO memory_chunks (content, embedding, source) VALUES ('likes tea', CAST('[0.1,0.2,0.3]' AS BLOB), 'chat')`,
+	} {
+		if _, err := old.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	status, err := old.MigrationStatus(ctx)
+	if err != nil || len(status) != len(migrations) || status[0].AppliedAt != nil {
+		t.Fatalf("status before migrating = %+v, %v", status, err)
+	}
+	old.Close()
+
+	db, err := Open(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	msgs, err := db.RecentMessages(ctx, 10, "")
+	if err != nil || len(msgs) != 1 || msgs[0].Content != "hi" {
+		t.Errorf("messages after migrating = %+v, %v", msgs, err)
+	}
+	if u, err := db.GetUser(ctx, "boss"); err != nil || u.Role != RoleAdmin {
+		t.Errorf("boss after migrating = %+v, %v", u, err)
+	}
+	if counts, err := db.EmbeddingCounts(ctx); err != nil || len(counts) != 1 || counts[0] != (EmbeddingCount{Dim: 3, Chunks: 1}) {
+		t.Errorf("memory embeddings after migrating = %+v, %v", counts, err)
+	}
+	status, err = db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %d (%s) is still pending", s.Version, s.Name)
+		}
+	}
+	if applied, err := db.Migrate(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("second run applied %+v, %v", applied, err)
+	}
+}
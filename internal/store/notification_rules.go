@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Notification urgencies, least urgent first. An empty urgency is normal.
+const (
+	UrgencyLow    = "low"
+	UrgencyNormal = "normal"
+	UrgencyHigh   = "high"
+	UrgencyUrgent = "urgent"
+)
+
+// NotificationUrgencies lists the urgencies in rank order.
+var NotificationUrgencies = []string{UrgencyLow, UrgencyNormal, UrgencyHigh, UrgencyUrgent}
+
+// UrgencyRank orders urgencies; "" and unknown values rank as normal.
+func UrgencyRank(urgency string) int {
+	for i, u := range NotificationUrgencies {
+		if u == urgency {
+			return i
+		}
+	}
+	return 1
+}
+
+// DefaultDigestHours is how often a digest is sent when the rules do not say.
+const DefaultDigestHours = 24
+
+// NotificationRules decide how a user's proactive messages are delivered (see gateway.Router).
+// Zero values keep the defaults: everything is sent at once, on the channel the user was last
+// seen on, and only urgent messages break through quiet hours.
+type NotificationRules struct {
+	UserID string `json:"user_id"`
+	// Channels maps an urgency to the channel its messages go to, e.g. {"urgent": "email"}.
+	Channels map[string]string `json:"channels,omitempty"`
+	// QuietBypass is the lowest urgency sent during the profile's quiet hours (default urgent).
+	QuietBypass string `json:"quiet_bypass,omitempty"`
+	// DigestBelow batches messages less urgent than it into a digest sent every DigestHours;
+	// empty turns digests off.
+	DigestBelow  string     `json:"digest_below,omitempty"`
+	DigestHours  int        `json:"digest_hours,omitempty"`
+	LastDigestAt *time.Time `json:"last_digest_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at,omitempty"`
+}
+
+// Validate checks the rules' urgencies and digest interval. Channel names are checked by the
+// caller, which knows the registered channels.
+func (r *NotificationRules) Validate() error {
+	for u := range r.Channels {
+		if !containsString(NotificationUrgencies, u) {
+			return fmt.Errorf("unknown urgency %q (use %s)", u, strings.Join(NotificationUrgencies, ", "))
+		}
+	}
+	for _, u := range []string{r.QuietBypass, r.DigestBelow} {
+		if u != "" && !containsString(NotificationUrgencies, u) {
+			return fmt.Errorf("unknown urgency %q (use %s)", u, strings.Join(NotificationUrgencies, ", "))
+		}
+	}
+	if r.DigestBelow == UrgencyLow {
+		return fmt.Errorf("digest_below low would batch nothing; use normal, high or urgent")
+	}
+	if r.DigestHours < 0 || r.DigestHours > 168 {
+		return fmt.Errorf("digest_hours must be between 1 and 168")
+	}
+	return nil
+}
+
+// BreaksQuiet reports whether a message of urgency is sent during quiet hours.
+func (r *NotificationRules) BreaksQuiet(urgency string) bool {
+	bypass := r.QuietBypass
+	if bypass == "" {
+		bypass = UrgencyUrgent
+	}
+	return UrgencyRank(urgency) >= UrgencyRank(bypass)
+}
+
+// Digested reports whether a message of urgency goes into the digest.
+func (r *NotificationRules) Digested(urgency string) bool {
+	return r.DigestBelow != "" && UrgencyRank(urgency) < UrgencyRank(r.DigestBelow)
+}
+
+// DigestInterval returns how often the digest is sent.
+func (r *NotificationRules) DigestInterval() time.Duration {
+	if r.DigestHours > 0 {
+		return time.Duration(r.DigestHours) * time.Hour
+	}
+	return DefaultDigestHours * time.Hour
+}
+
+// GetNotificationRules returns userID's rules; a user without any gets the defaults.
+func (db *DB) GetNotificationRules(ctx context.Context, userID string) (*NotificationRules, error) {
+	r := &NotificationRules{UserID: userID}
+	var channels string
+	var last sql.NullTime
+	err := db.QueryRowContext(ctx,
+		`SELECT channels, quiet_bypass, digest_below, digest_hours, last_digest_at, updated_at FROM notification_rules WHERE user_id = ?`, userID,
+	).Scan(&channels, &r.QuietBypass, &r.DigestBelow, &r.DigestHours, &last, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if channels != "" {
+		if err := json.Unmarshal([]byte(channels), &r.Channels); err != nil {
+			return nil, fmt.Errorf("notification rules of %s: %w", userID, err)
+		}
+	}
+	if last.Valid {
+		r.LastDigestAt = &last.Time
+	}
+	return r, nil
+}
+
+// SetNotificationRules validates and stores r, replacing the user's previous rules. The time
+// of the last digest is kept.
+func (db *DB) SetNotificationRules(ctx context.Context, r *NotificationRules) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	channels := ""
+	if len(r.Channels) > 0 {
+		b, _ := json.Marshal(r.Channels)
+		channels = string(b)
+	}
+	r.UpdatedAt = time.Now()
+	_, err := db.ExecContext(ctx, `
+INSERT INTO notification_rules (user_id, channels, quiet_bypass, digest_below, digest_hours, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(user_id) DO UPDATE SET channels = excluded.channels, quiet_bypass = excluded.quiet_bypass,
+	digest_below = excluded.digest_below, digest_hours = excluded.digest_hours, updated_at = excluded.updated_at`,
+		r.UserID, channels, r.QuietBypass, r.DigestBelow, r.DigestHours, r.UpdatedAt)
+	return err
+}
+
+// DigestItem is a notification waiting for the user's next digest.
+type DigestItem struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Content   string    `json:"content"`
+	Urgency   string    `json:"urgency,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddDigestItem queues a notification for userID's next digest.
+func (db *DB) AddDigestItem(ctx context.Context, userID, content, urgency string) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO notification_digest (user_id, content, urgency, created_at) VALUES (?, ?, ?, ?)`,
+		userID, content, urgency, time.Now())
+	return err
+}
+
+// DigestItems returns the notifications waiting for userID's digest, oldest first.
+func (db *DB) DigestItems(ctx context.Context, userID string) ([]DigestItem, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, user_id, content, urgency, created_at FROM notification_digest WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []DigestItem
+	for rows.Next() {
+		var it DigestItem
+		if err := rows.Scan(&it.ID, &it.UserID, &it.Content, &it.Urgency, &it.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// DigestUsers returns the users with notifications waiting for a digest.
+func (db *DB) DigestUsers(ctx context.Context) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT DISTINCT user_id FROM notification_digest ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// MarkDigestSent deletes the notifications up to and including lastID that a digest delivered
+// and records when it was sent.
+func (db *DB) MarkDigestSent(ctx context.Context, userID string, lastID int64, at time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM notification_digest WHERE user_id = ? AND id <= ?`, userID, lastID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO notification_rules (user_id, last_digest_at) VALUES (?, ?)
+ON CONFLICT(user_id) DO UPDATE SET last_digest_at = excluded.last_digest_at`, userID, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
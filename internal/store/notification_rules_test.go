@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotificationRules(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	def, err := db.GetNotificationRules(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Digested(UrgencyLow) || def.BreaksQuiet(UrgencyHigh) || !def.BreaksQuiet(UrgencyUrgent) || def.DigestInterval() != 24*time.Hour {
+		t.Errorf("default rules = %+v", def)
+	}
+	for _, bad := range []NotificationRules{
+		{UserID: "alice", Channels: map[string]string{"critical": "email"}},
+		{UserID: "alice", QuietBypass: "loud"},
+		{UserID: "alice", DigestBelow: "low"},
+		{UserID: "alice", DigestHours: 200},
+	} {
+		if err := db.SetNotificationRules(ctx, &bad); err == nil {
+			t.Errorf("accepted %+v", bad)
+		}
+	}
+
+	r := &NotificationRules{UserID: "alice", Channels: map[string]string{"urgent": "email"}, QuietBypass: "high", DigestBelow: "normal", DigestHours: 6}
+	if err := db.SetNotificationRules(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Digested(UrgencyLow) || r.Digested("") || !r.BreaksQuiet(UrgencyHigh) || r.BreaksQuiet("") {
+		t.Errorf("rules = %+v", r)
+	}
+
+	for _, c := range []string{"one", "two"} {
+		if err := db.AddDigestItem(ctx, "alice", c, UrgencyLow); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items, err := db.DigestItems(ctx, "alice")
+	if err != nil || len(items) != 2 {
+		t.Fatalf("DigestItems = %v, %v", items, err)
+	}
+	if err := db.AddDigestItem(ctx, "alice", "three", UrgencyLow); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MarkDigestSent(ctx, "alice", items[1].ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	left, _ := db.DigestItems(ctx, "alice")
+	if len(left) != 1 || left[0].Content != "three" {
+		t.Errorf("left after digest = %+v", left)
+	}
+	got, err := db.GetNotificationRules(ctx, "alice")
+	if err != nil || got.LastDigestAt == nil || got.Channels["urgent"] != "email" || got.DigestHours != 6 {
+		t.Errorf("rules after digest = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// OnboardingItem is one step of the post-install setup checklist.
+type OnboardingItem struct {
+	Key         string     `json:"key"`
+	Title       string     `json:"title"`
+	Hint        string     `json:"hint,omitempty"`
+	Done        bool       `json:"done"`
+	Dismissed   bool       `json:"dismissed,omitempty"`
+	Note        string     `json:"note,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// OnboardingItems defines the checklist, in the order it should be worked through.
+var OnboardingItems = []OnboardingItem{
+	{Key: "channel_connected", Title: "Chat channel connected", Hint: "Connect Nextcloud Talk (or another channel) so users can reach HattieBot outside the console."},
+	{Key: "embedding_configured", Title: "Embedding provider configured", Hint: "Set EMBEDDING_SERVICE_URL or register a default with manage_embedding_provider for reliable vector memory."},
+	{Key: "backups_enabled", Title: "Backups enabled", Hint: "Schedule a recurring backup of the config dir (hattiebot.db, config.json) with manage_schedule, or mark done if backed up externally."},
+	{Key: "admin_approved", Title: "Owner account active", Hint: "The configured admin (admin_user_id) must message HattieBot once to become the owner."},
+	{Key: "first_tool_built", Title: "First custom tool built", Hint: "Ask HattieBot to build and register a tool for a task you repeat."},
+}
+
+// OnboardingChecklist returns every checklist item with its stored state.
+func (db *DB) OnboardingChecklist(ctx context.Context) ([]OnboardingItem, error) {
+	rows, err := db.QueryContext(ctx, `SELECT item, done, dismissed, COALESCE(note, ''), completed_at FROM onboarding_checklist`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type state struct {
+		done, dismissed bool
+		note            string
+		completedAt     sql.NullTime
+	}
+	states := map[string]state{}
+	for rows.Next() {
+		var key string
+		var st state
+		if err := rows.Scan(&key, &st.done, &st.dismissed, &st.note, &st.completedAt); err != nil {
+			return nil, err
+		}
+		states[key] = st
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]OnboardingItem, len(OnboardingItems))
+	for i, item := range OnboardingItems {
+		st := states[item.Key]
+		item.Done, item.Dismissed, item.Note = st.done, st.dismissed, st.note
+		if st.completedAt.Valid {
+			t := st.completedAt.Time
+			item.CompletedAt = &t
+		}
+		out[i] = item
+	}
+	return out, nil
+}
+
+// CompleteOnboardingItem marks an item done; the first completion time is kept.
+func (db *DB) CompleteOnboardingItem(ctx context.Context, key, note string) error {
+	if !validOnboardingItem(key) {
+		return fmt.Errorf("unknown checklist item: %s", key)
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO onboarding_checklist (item, done, note, completed_at, updated_at) VALUES (?, 1, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		 ON CONFLICT(item) DO UPDATE SET done = 1, note = COALESCE(NULLIF(excluded.note, ''), note),
+		   completed_at = COALESCE(completed_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP`,
+		key, note,
+	)
+	return err
+}
+
+// SetOnboardingItemDismissed hides (or restores) an item the admin chose to skip.
+func (db *DB) SetOnboardingItemDismissed(ctx context.Context, key string, dismissed bool) error {
+	if !validOnboardingItem(key) {
+		return fmt.Errorf("unknown checklist item: %s", key)
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO onboarding_checklist (item, dismissed, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT(item) DO UPDATE SET dismissed = excluded.dismissed, updated_at = CURRENT_TIMESTAMP`,
+		key, dismissed,
+	)
+	return err
+}
+
+func validOnboardingItem(key string) bool {
+	for _, item := range OnboardingItems {
+		if item.Key == key {
+			return true
+		}
+	}
+	return false
+}
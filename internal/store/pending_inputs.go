@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AwaitingInput is the job and sub-mind session status while a PendingInput for it is open.
+const AwaitingInput = "awaiting_input"
+
+// Pending input statuses.
+const (
+	InputAwaiting  = "awaiting"
+	InputAnswered  = "answered"
+	InputCancelled = "cancelled"
+	InputExpired   = "expired"
+)
+
+// PendingInputTTL is how long a question waits for its answer by default.
+const PendingInputTTL = 7 * 24 * time.Hour
+
+// Expected answer types.
+const (
+	ExpectText    = "text"
+	ExpectChoice  = "choice"
+	ExpectConfirm = "confirm"
+	ExpectNumber  = "number"
+)
+
+// Expectation describes the answer a pending question needs.
+type Expectation struct {
+	Type    string   `json:"type"`              // text (default), choice, confirm or number
+	Choices []string `json:"choices,omitempty"` // for choice
+}
+
+// Validate checks that the expectation can be answered.
+func (e Expectation) Validate() error {
+	switch e.Type {
+	case "", ExpectText, ExpectConfirm, ExpectNumber:
+		return nil
+	case ExpectChoice:
+		if len(e.Choices) < 2 {
+			return fmt.Errorf("a choice needs at least two choices")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown answer type %q (use text, choice, confirm or number)", e.Type)
+}
+
+// Describe is a short human description of the expected answer.
+func (e Expectation) Describe() string {
+	switch e.Type {
+	case ExpectChoice:
+		return "one of: " + strings.Join(e.Choices, ", ")
+	case ExpectConfirm:
+		return "yes or no"
+	case ExpectNumber:
+		return "a number"
+	}
+	return "free text"
+}
+
+// Check returns answer normalized for the expectation: the matching choice (by text or 1-based
+// number), "yes"/"no", or the number as written. It fails when answer does not fit.
+func (e Expectation) Check(answer string) (string, error) {
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", fmt.Errorf("empty answer")
+	}
+	word := strings.ToLower(strings.TrimRight(answer, ".!"))
+	switch e.Type {
+	case ExpectChoice:
+		for _, c := range e.Choices {
+			if strings.EqualFold(c, word) {
+				return c, nil
+			}
+		}
+		if n, err := strconv.Atoi(word); err == nil && n >= 1 && n <= len(e.Choices) {
+			return e.Choices[n-1], nil
+		}
+		return "", fmt.Errorf("expected %s", e.Describe())
+	case ExpectConfirm:
+		switch word {
+		case "yes", "y", "yeah", "yep", "sure", "ok", "okay", "confirm", "confirmed":
+			return "yes", nil
+		case "no", "n", "nope", "nah":
+			return "no", nil
+		}
+		return "", fmt.Errorf("expected yes or no")
+	case ExpectNumber:
+		if _, err := strconv.ParseFloat(strings.ReplaceAll(word, ",", ""), 64); err != nil {
+			return "", fmt.Errorf("expected a number")
+		}
+		return strings.ReplaceAll(word, ",", ""), nil
+	}
+	return answer, nil
+}
+
+// PendingInput is a question the agent (or one of its sub-minds) is waiting on. The next message
+// from UserID in ThreadID answers it and resumes Step.
+type PendingInput struct {
+	ID               int64       `json:"id"`
+	UserID           string      `json:"user_id"`
+	ThreadID         string      `json:"thread_id"`
+	Channel          string      `json:"channel,omitempty"`
+	JobID            int64       `json:"job_id,omitempty"`
+	SubmindSessionID int64       `json:"submind_session_id,omitempty"`
+	Question         string      `json:"question"`
+	Expect           Expectation `json:"expect"`
+	Step             string      `json:"step,omitempty"`
+	Status           string      `json:"status"`
+	Answer           string      `json:"answer,omitempty"`
+	CreatedAt        time.Time   `json:"created_at"`
+	ExpiresAt        time.Time   `json:"expires_at"`
+	ResolvedAt       *time.Time  `json:"resolved_at,omitempty"`
+}
+
+// CreatePendingInput stores p as awaiting (expiring after PendingInputTTL when ExpiresAt is zero)
+// and cancels any question still open in the same thread: only the latest one is answered.
+func (db *DB) CreatePendingInput(ctx context.Context, p PendingInput) (int64, error) {
+	if err := p.Expect.Validate(); err != nil {
+		return 0, err
+	}
+	if p.Expect.Type == "" {
+		p.Expect.Type = ExpectText
+	}
+	expect, err := json.Marshal(p.Expect)
+	if err != nil {
+		return 0, err
+	}
+	if p.ExpiresAt.IsZero() {
+		p.ExpiresAt = time.Now().Add(PendingInputTTL)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE pending_inputs SET status = ?, resolved_at = CURRENT_TIMESTAMP WHERE thread_id = ? AND status = ?`,
+		InputCancelled, p.ThreadID, InputAwaiting); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO pending_inputs (user_id, thread_id, channel, job_id, submind_session_id, question, expect, step, status, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.UserID, p.ThreadID, p.Channel, nullID(p.JobID), nullID(p.SubmindSessionID), p.Question, string(expect), p.Step,
+		InputAwaiting, p.ExpiresAt.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func nullID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+const pendingInputColumns = `id, user_id, thread_id, channel, job_id, submind_session_id, question, expect, step, status, answer, created_at, expires_at, resolved_at`
+
+func scanPendingInput(row rowScanner) (*PendingInput, error) {
+	var p PendingInput
+	var jobID, sessionID sql.NullInt64
+	var expect string
+	var answer sql.NullString
+	var resolved sql.NullTime
+	if err := row.Scan(&p.ID, &p.UserID, &p.ThreadID, &p.Channel, &jobID, &sessionID, &p.Question, &expect, &p.Step, &p.Status, &answer, &p.CreatedAt, &p.ExpiresAt, &resolved); err != nil {
+		return nil, err
+	}
+	p.JobID, p.SubmindSessionID, p.Answer = jobID.Int64, sessionID.Int64, answer.String
+	_ = json.Unmarshal([]byte(expect), &p.Expect)
+	if resolved.Valid {
+		p.ResolvedAt = &resolved.Time
+	}
+	return &p, nil
+}
+
+// OpenPendingInput returns the question awaiting an answer in threadID, or nil. It may have
+// expired; the caller resolves it then.
+func (db *DB) OpenPendingInput(ctx context.Context, threadID string) (*PendingInput, error) {
+	p, err := scanPendingInput(db.QueryRowContext(ctx,
+		`SELECT `+pendingInputColumns+` FROM pending_inputs WHERE thread_id = ? AND status = ? ORDER BY id DESC LIMIT 1`,
+		threadID, InputAwaiting))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// ListPendingInputs returns userID's open questions, newest first.
+func (db *DB) ListPendingInputs(ctx context.Context, userID string) ([]PendingInput, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+pendingInputColumns+` FROM pending_inputs WHERE user_id = ? AND status = ? ORDER BY id DESC`,
+		userID, InputAwaiting)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PendingInput
+	for rows.Next() {
+		p, err := scanPendingInput(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *p)
+	}
+	return out, rows.Err()
+}
+
+// ResolvePendingInput closes an awaiting question with status (answered, cancelled or expired).
+// It fails when the question is no longer awaiting, so an answer is only used once.
+func (db *DB) ResolvePendingInput(ctx context.Context, id int64, status, answer string) error {
+	res, err := db.ExecContext(ctx,
+		`UPDATE pending_inputs SET status = ?, answer = ?, resolved_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?`,
+		status, answer, id, InputAwaiting)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("pending input %d is not awaiting an answer", id)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpectationCheck(t *testing.T) {
+	choice := Expectation{Type: ExpectChoice, Choices: []string{"Staging", "Production"}}
+	for _, tt := range []struct {
+		expect      Expectation
+		answer, out string
+		ok          bool
+	}{
+		{Expectation{}, "  my key  ", "my key", true},
+		{choice, "production", "Production", true},
+		{choice, "1", "Staging", true},
+		{choice, "3", "", false},
+		{Expectation{Type: ExpectConfirm}, "Yes!", "yes", true},
+		{Expectation{Type: ExpectConfirm}, "maybe", "", false},
+		{Expectation{Type: ExpectNumber}, "1,500", "1500", true},
+		{Expectation{Type: ExpectNumber}, "lots", "", false},
+	} {
+		out, err := tt.expect.Check(tt.answer)
+		if (err == nil) != tt.ok || out != tt.out {
+			t.Errorf("%+v.Check(%q) = %q, %v", tt.expect, tt.answer, out, err)
+		}
+	}
+	if err := (Expectation{Type: ExpectChoice, Choices: []string{"only"}}).Validate(); err == nil {
+		t.Error("a choice with one option is valid")
+	}
+}
+
+func TestPendingInputLifecycle(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	first, err := db.CreatePendingInput(ctx, PendingInput{UserID: "alice", ThreadID: "t1", Question: "Which bucket?", Step: "create the backup target"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A newer question in the same thread replaces the open one
+	second, err := db.CreatePendingInput(ctx, PendingInput{UserID: "alice", ThreadID: "t1", Question: "Which region?", Expect: Expectation{Type: ExpectChoice, Choices: []string{"eu", "us"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := db.OpenPendingInput(ctx, "t1")
+	if err != nil || p == nil || p.ID != second || p.Expect.Choices[1] != "us" || p.ExpiresAt.IsZero() {
+		t.Fatalf("open = %+v, %v", p, err)
+	}
+	if list, _ := db.ListPendingInputs(ctx, "alice"); len(list) != 1 {
+		t.Errorf("alice has %d open questions, want 1", len(list))
+	}
+	if err := db.ResolvePendingInput(ctx, first, InputAnswered, "b"); err == nil {
+		t.Error("answered a replaced question")
+	}
+	if err := db.ResolvePendingInput(ctx, second, InputAnswered, "eu"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ResolvePendingInput(ctx, second, InputAnswered, "us"); err == nil {
+		t.Error("answered the same question twice")
+	}
+	if p, _ := db.OpenPendingInput(ctx, "t1"); p != nil {
+		t.Errorf("still open: %+v", p)
+	}
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Plan run statuses. A run is "running" until the agent reports a result or the turn ends.
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunPartial   = "partial"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped"
+)
+
+// PlanRun is the structured completion record of one scheduled plan execution.
+type PlanRun struct {
+	ID               int64      `json:"id"`
+	PlanID           int64      `json:"plan_id"`
+	UserID           string     `json:"user_id,omitempty"`
+	ThreadID         string     `json:"thread_id,omitempty"`
+	Status           string     `json:"status"`
+	Summary          string     `json:"summary,omitempty"`
+	Artifacts        []string   `json:"artifacts,omitempty"`
+	NextSuggestedRun *time.Time `json:"next_suggested_run,omitempty"`
+	Reported         bool       `json:"reported"` // false = recorded by the runtime, not the agent
+	StartedAt        time.Time  `json:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+}
+
+// ValidRunResult reports whether status may be used to finish a run.
+func ValidRunResult(status string) bool {
+	switch status {
+	case RunSucceeded, RunPartial, RunFailed, RunSkipped:
+		return true
+	}
+	return false
+}
+
+// StartPlanRun records the start of a plan execution and returns the run ID.
+func (db *DB) StartPlanRun(ctx context.Context, planID int64, userID, threadID string) (int64, error) {
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO plan_runs (plan_id, user_id, thread_id, status) VALUES (?, ?, ?, ?)`,
+		planID, userID, threadID, RunRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// FinishPlanRun stores the result of a run. Only Status, Summary, Artifacts, NextSuggestedRun, and
+// Reported are used. A run can be finished once; later calls return an error.
+func (db *DB) FinishPlanRun(ctx context.Context, id int64, r PlanRun) error {
+	if !ValidRunResult(r.Status) {
+		return fmt.Errorf("invalid run status %q (use succeeded, partial, failed, or skipped)", r.Status)
+	}
+	artifacts := ""
+	if len(r.Artifacts) > 0 {
+		b, _ := json.Marshal(r.Artifacts)
+		artifacts = string(b)
+	}
+	var next interface{}
+	if r.NextSuggestedRun != nil {
+		next = *r.NextSuggestedRun
+	}
+	res, err := db.ExecContext(ctx,
+		`UPDATE plan_runs SET status = ?, summary = ?, artifacts = ?, next_suggested_run = ?, reported = ?, finished_at = ?
+		 WHERE id = ? AND status = ?`,
+		r.Status, r.Summary, artifacts, next, r.Reported, time.Now(), id, RunRunning,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("run %d not found or already finished", id)
+	}
+	return nil
+}
+
+// RecordPlanRun inserts an already finished run (e.g. a plan the scheduler could not start).
+func (db *DB) RecordPlanRun(ctx context.Context, planID int64, userID, status, summary string) error {
+	id, err := db.StartPlanRun(ctx, planID, userID, "")
+	if err != nil {
+		return err
+	}
+	return db.FinishPlanRun(ctx, id, PlanRun{Status: status, Summary: summary})
+}
+
+// PlanRunByID returns a run, or nil if it does not exist.
+func (db *DB) PlanRunByID(ctx context.Context, id int64) (*PlanRun, error) {
+	runs, err := db.queryPlanRuns(ctx, `WHERE id = ?`, id)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// ListPlanRuns returns a plan's runs, newest first (limit <= 0 means 20).
+func (db *DB) ListPlanRuns(ctx context.Context, planID int64, limit int) ([]PlanRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	return db.queryPlanRuns(ctx, `WHERE plan_id = ? ORDER BY id DESC LIMIT ?`, planID, limit)
+}
+
+func (db *DB) queryPlanRuns(ctx context.Context, where string, args ...interface{}) ([]PlanRun, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, plan_id, user_id, COALESCE(thread_id, ''), status, COALESCE(summary, ''), COALESCE(artifacts, ''), next_suggested_run, reported, started_at, finished_at
+		 FROM plan_runs `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PlanRun
+	for rows.Next() {
+		var r PlanRun
+		var artifacts string
+		var next, finished sql.NullTime
+		if err := rows.Scan(&r.ID, &r.PlanID, &r.UserID, &r.ThreadID, &r.Status, &r.Summary, &artifacts, &next, &r.Reported, &r.StartedAt, &finished); err != nil {
+			return nil, err
+		}
+		if artifacts != "" {
+			_ = json.Unmarshal([]byte(artifacts), &r.Artifacts)
+		}
+		if next.Valid {
+			r.NextSuggestedRun = &next.Time
+		}
+		if finished.Valid {
+			r.FinishedAt = &finished.Time
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPlanRuns(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	first, err := db.StartPlanRun(ctx, 7, "alice", "scheduler:plan_7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	if err := db.FinishPlanRun(ctx, first, PlanRun{Status: RunPartial, Summary: "filed 2 of 3 receipts", Artifacts: []string{"/receipts/a.pdf", "/receipts/b.pdf"}, NextSuggestedRun: &next, Reported: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.FinishPlanRun(ctx, first, PlanRun{Status: RunSucceeded}); err == nil {
+		t.Error("a finished run should not be overwritten")
+	}
+	if err := db.RecordPlanRun(ctx, 7, "alice", RunSkipped, "ingress buffer full"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.StartPlanRun(ctx, 8, "bob", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := db.ListPlanRuns(ctx, 7, 0)
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("runs = %+v, %v", runs, err)
+	}
+	if runs[0].Status != RunSkipped || runs[0].Reported {
+		t.Errorf("newest run = %+v", runs[0])
+	}
+	r := runs[1]
+	if r.Status != RunPartial || !r.Reported || len(r.Artifacts) != 2 || r.FinishedAt == nil || r.NextSuggestedRun == nil || !r.NextSuggestedRun.Equal(next) {
+		t.Errorf("reported run = %+v", r)
+	}
+	if err := db.FinishPlanRun(ctx, first, PlanRun{Status: "done"}); err == nil {
+		t.Error("unknown status should be rejected")
+	}
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UserProfile holds a user's typed preferences. Empty fields are unset: the agent uses its
+// defaults and the server's time zone.
+type UserProfile struct {
+	UserID    string `json:"user_id"`
+	Language  string `json:"language,omitempty"`  // e.g. "German" or "de"
+	Timezone  string `json:"timezone,omitempty"`  // IANA zone, e.g. Europe/Berlin
+	Verbosity string `json:"verbosity,omitempty"` // see ProfileVerbosities
+	Formality string `json:"formality,omitempty"` // see ProfileFormalities
+	// QuietStart and QuietEnd ("HH:MM" in Timezone) bound the hours when proactive messages that
+	// are not urgent are held. The range may wrap midnight (22:00 to 07:00).
+	QuietStart string    `json:"quiet_start,omitempty"`
+	QuietEnd   string    `json:"quiet_end,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+}
+
+// Allowed profile values.
+var (
+	ProfileVerbosities = []string{"brief", "normal", "detailed"}
+	ProfileFormalities = []string{"casual", "neutral", "formal"}
+)
+
+// Validate checks the profile's enumerated values, time zone and quiet hours.
+func (p *UserProfile) Validate() error {
+	if p.Verbosity != "" && !containsString(ProfileVerbosities, p.Verbosity) {
+		return fmt.Errorf("verbosity must be one of %s", strings.Join(ProfileVerbosities, ", "))
+	}
+	if p.Formality != "" && !containsString(ProfileFormalities, p.Formality) {
+		return fmt.Errorf("formality must be one of %s", strings.Join(ProfileFormalities, ", "))
+	}
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", p.Timezone)
+		}
+	}
+	if (p.QuietStart == "") != (p.QuietEnd == "") {
+		return fmt.Errorf("quiet hours need both quiet_start and quiet_end")
+	}
+	for _, v := range []string{p.QuietStart, p.QuietEnd} {
+		if _, err := clockMinutes(v); v != "" && err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Location returns the profile's time zone, or the server's when it has none.
+func (p *UserProfile) Location() *time.Location {
+	if p.Timezone != "" {
+		if loc, err := time.LoadLocation(p.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// QuietUntil returns when the quiet hours that t falls in end, or the zero time when t is
+// outside quiet hours or none are set.
+func (p *UserProfile) QuietUntil(t time.Time) time.Time {
+	start, err1 := clockMinutes(p.QuietStart)
+	end, err2 := clockMinutes(p.QuietEnd)
+	if p.QuietStart == "" || err1 != nil || err2 != nil || start == end {
+		return time.Time{}
+	}
+	local := t.In(p.Location())
+	now := local.Hour()*60 + local.Minute()
+	endOn := func(days int) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day()+days, end/60, end%60, 0, 0, local.Location())
+	}
+	switch {
+	case start < end && now >= start && now < end:
+		return endOn(0)
+	case start > end && now >= start:
+		return endOn(1)
+	case start > end && now < end:
+		return endOn(0)
+	}
+	return time.Time{}
+}
+
+// clockMinutes parses "HH:MM" into minutes after midnight.
+func clockMinutes(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (use HH:MM)", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUserProfile returns userID's profile; a user without one gets an empty profile.
+func (db *DB) GetUserProfile(ctx context.Context, userID string) (*UserProfile, error) {
+	p := &UserProfile{UserID: userID}
+	err := db.QueryRowContext(ctx,
+		`SELECT language, timezone, verbosity, formality, quiet_start, quiet_end, updated_at FROM user_profiles WHERE user_id = ?`, userID,
+	).Scan(&p.Language, &p.Timezone, &p.Verbosity, &p.Formality, &p.QuietStart, &p.QuietEnd, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetUserProfile validates and stores p, replacing the user's previous profile.
+func (db *DB) SetUserProfile(ctx context.Context, p *UserProfile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	p.UpdatedAt = time.Now()
+	_, err := db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO user_profiles (user_id, language, timezone, verbosity, formality, quiet_start, quiet_end, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.UserID, p.Language, p.Timezone, p.Verbosity, p.Formality, p.QuietStart, p.QuietEnd, p.UpdatedAt)
+	return err
+}
+
+// HeldMessage is a proactive message held during the user's quiet hours.
+type HeldMessage struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Content   string    `json:"content"`
+	Urgency   string    `json:"urgency,omitempty"`
+	DeliverAt time.Time `json:"deliver_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HoldMessage stores a proactive message for delivery at deliverAt.
+func (db *DB) HoldMessage(ctx context.Context, userID, content, urgency string, deliverAt time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, `INSERT INTO held_messages (user_id, content, urgency, deliver_at) VALUES (?, ?, ?, ?)`,
+		userID, content, urgency, deliverAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// DueHeldMessages returns held messages whose delivery time has come, oldest first.
+func (db *DB) DueHeldMessages(ctx context.Context, now time.Time) ([]HeldMessage, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, user_id, content, urgency, deliver_at, created_at FROM held_messages WHERE deliver_at <= ? ORDER BY id`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []HeldMessage
+	for rows.Next() {
+		var m HeldMessage
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Content, &m.Urgency, &m.DeliverAt, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// DeleteHeldMessage removes a delivered held message.
+func (db *DB) DeleteHeldMessage(ctx context.Context, id int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM held_messages WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUserProfile(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	p, err := db.GetUserProfile(ctx, "alice")
+	if err != nil || p.UserID != "alice" || p.Language != "" {
+		t.Fatalf("empty profile = %+v, %v", p, err)
+	}
+	for _, bad := range []UserProfile{
+		{UserID: "alice", Verbosity: "chatty"},
+		{UserID: "alice", Formality: "stiff"},
+		{UserID: "alice", Timezone: "Mars/Olympus"},
+		{UserID: "alice", QuietStart: "22:00"},
+		{UserID: "alice", QuietStart: "22:00", QuietEnd: "7am"},
+	} {
+		if err := db.SetUserProfile(ctx, &bad); err == nil {
+			t.Errorf("accepted %+v", bad)
+		}
+	}
+	want := UserProfile{UserID: "alice", Language: "German", Timezone: "Europe/Berlin", Verbosity: "brief", Formality: "casual", QuietStart: "22:00", QuietEnd: "07:00"}
+	if err := db.SetUserProfile(ctx, &want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.GetUserProfile(ctx, "alice")
+	if err != nil || got.Language != "German" || got.Timezone != "Europe/Berlin" || got.QuietEnd != "07:00" {
+		t.Fatalf("profile = %+v, %v", got, err)
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	at := func(day, h, m int) time.Time { return time.Date(2026, 3, day, h, m, 0, 0, berlin) }
+	overnight := &UserProfile{Timezone: "Europe/Berlin", QuietStart: "22:00", QuietEnd: "07:00"}
+	daytime := &UserProfile{Timezone: "Europe/Berlin", QuietStart: "12:00", QuietEnd: "13:30"}
+	for _, tc := range []struct {
+		p    *UserProfile
+		t    time.Time
+		want time.Time
+	}{
+		{overnight, at(10, 23, 15), at(11, 7, 0)},
+		{overnight, at(10, 3, 0), at(10, 7, 0)},
+		{overnight, at(10, 7, 0), time.Time{}},
+		{overnight, at(10, 21, 59), time.Time{}},
+		{daytime, at(10, 12, 30), at(10, 13, 30)},
+		{daytime, at(10, 13, 30), time.Time{}},
+		{&UserProfile{}, at(10, 23, 0), time.Time{}},
+	} {
+		if got := tc.p.QuietUntil(tc.t.UTC()); !got.Equal(tc.want) {
+			t.Errorf("%s-%s at %s: got %s, want %s", tc.p.QuietStart, tc.p.QuietEnd, tc.t, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Project statuses.
+const (
+	ProjectActive   = "active"
+	ProjectDone     = "done"
+	ProjectArchived = "archived"
+)
+
+// Project item kinds linked through project_items; jobs, schedules and memories carry a project_id instead.
+const (
+	ProjectItemContextDoc = "context_doc"
+	ProjectItemThread     = "thread"
+)
+
+// Project groups a user's related jobs, scheduled plans, context documents, threads and memories.
+type Project struct {
+	ID          int64     `json:"id"`
+	UserID      string    `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Status      string    `json:"status"`
+	Current     bool      `json:"current,omitempty"` // the user's current project
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+const projectColumns = `id, user_id, name, description, status, is_current, created_at, updated_at`
+
+func scanProject(row rowScanner) (*Project, error) {
+	var p Project
+	if err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.Description, &p.Status, &p.Current, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateProject creates an active project; names are unique per user.
+func (db *DB) CreateProject(ctx context.Context, userID, name, description string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("project name is required")
+	}
+	if existing, err := db.FindProject(ctx, userID, name); err != nil {
+		return 0, err
+	} else if existing != nil {
+		return 0, fmt.Errorf("project %q already exists (#%d)", name, existing.ID)
+	}
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO projects (user_id, name, description, status) VALUES (?, ?, ?, ?)`,
+		userID, name, description, ProjectActive)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetProject returns a project by ID, or nil if not found.
+func (db *DB) GetProject(ctx context.Context, id int64) (*Project, error) {
+	p, err := scanProject(db.QueryRowContext(ctx, `SELECT `+projectColumns+` FROM projects WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// FindProject returns userID's project by ID ("12" or "#12") or case-insensitive name, or nil.
+func (db *DB) FindProject(ctx context.Context, userID, ref string) (*Project, error) {
+	ref = strings.TrimSpace(ref)
+	if id, err := strconv.ParseInt(strings.TrimPrefix(ref, "#"), 10, 64); err == nil {
+		p, err := db.GetProject(ctx, id)
+		if err != nil || p == nil || p.UserID != userID {
+			return nil, err
+		}
+		return p, nil
+	}
+	p, err := scanProject(db.QueryRowContext(ctx,
+		`SELECT `+projectColumns+` FROM projects WHERE user_id = ? AND name = ? COLLATE NOCASE`, userID, ref))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// ListProjects returns userID's projects with optional status filter, most recently updated first.
+func (db *DB) ListProjects(ctx context.Context, userID, status string) ([]Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE user_id = ?`
+	args := []interface{}{userID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	rows, err := db.QueryContext(ctx, query+` ORDER BY updated_at DESC, id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *p)
+	}
+	return out, rows.Err()
+}
+
+// UpdateProject changes a project's description and/or status ("" keeps it). A project that is no
+// longer active stops being current.
+func (db *DB) UpdateProject(ctx context.Context, id int64, description, status string) error {
+	switch status {
+	case "", ProjectActive, ProjectDone, ProjectArchived:
+	default:
+		return fmt.Errorf("unknown project status %q (use active, done or archived)", status)
+	}
+	_, err := db.ExecContext(ctx,
+		`UPDATE projects SET description = COALESCE(NULLIF(?, ''), description), status = COALESCE(NULLIF(?, ''), status),
+		 is_current = CASE WHEN COALESCE(NULLIF(?, ''), status) = 'active' THEN is_current ELSE 0 END,
+		 updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		description, status, status, id)
+	return err
+}
+
+// SetCurrentProject makes project id userID's current project; id 0 clears it.
+func (db *DB) SetCurrentProject(ctx context.Context, userID string, id int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `UPDATE projects SET is_current = 0 WHERE user_id = ? AND is_current = 1`, userID); err != nil {
+		return err
+	}
+	if id != 0 {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE projects SET is_current = 1, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND status = ?`,
+			id, userID, ProjectActive)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("project %d is not an active project of this user", id)
+		}
+	}
+	return tx.Commit()
+}
+
+// CurrentProject returns the project userID is working in: the active project linked to threadID,
+// else the user's current project. It returns nil when there is none.
+func (db *DB) CurrentProject(ctx context.Context, userID, threadID string) (*Project, error) {
+	if threadID != "" {
+		p, err := scanProject(db.QueryRowContext(ctx,
+			`SELECT `+projectColumns+` FROM projects WHERE user_id = ? AND status = ? AND id IN
+			 (SELECT project_id FROM project_items WHERE kind = ? AND ref = ?) ORDER BY updated_at DESC LIMIT 1`,
+			userID, ProjectActive, ProjectItemThread, threadID))
+		if err == nil {
+			return p, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	p, err := scanProject(db.QueryRowContext(ctx,
+		`SELECT `+projectColumns+` FROM projects WHERE user_id = ? AND status = ? AND is_current = 1 LIMIT 1`,
+		userID, ProjectActive))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// CurrentProjectID is CurrentProject's ID, or 0 when there is none (or it cannot be loaded).
+func (db *DB) CurrentProjectID(ctx context.Context, userID, threadID string) int64 {
+	if p, err := db.CurrentProject(ctx, userID, threadID); err == nil && p != nil {
+		return p.ID
+	}
+	return 0
+}
+
+// AddProjectItem links a context document (by title) or a thread to a project.
+func (db *DB) AddProjectItem(ctx context.Context, projectID int64, kind, ref string) error {
+	if kind != ProjectItemContextDoc && kind != ProjectItemThread {
+		return fmt.Errorf("unknown project item kind %q", kind)
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO project_items (project_id, kind, ref) VALUES (?, ?, ?)`, projectID, kind, ref)
+	if err == nil {
+		_, err = db.ExecContext(ctx, `UPDATE projects SET updated_at = CURRENT_TIMESTAMP WHERE id = ?`, projectID)
+	}
+	return err
+}
+
+// RemoveProjectItem unlinks a context document or thread.
+func (db *DB) RemoveProjectItem(ctx context.Context, projectID int64, kind, ref string) error {
+	_, err := db.ExecContext(ctx,
+		`DELETE FROM project_items WHERE project_id = ? AND kind = ? AND ref = ?`, projectID, kind, ref)
+	return err
+}
+
+// ProjectItems returns the refs of one kind linked to a project, oldest first.
+func (db *DB) ProjectItems(ctx context.Context, projectID int64, kind string) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT ref FROM project_items WHERE project_id = ? AND kind = ? ORDER BY created_at, ref`, projectID, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, err
+		}
+		out = append(out, ref)
+	}
+	return out, rows.Err()
+}
+
+// SetJobProject moves userID's job into a project; projectID 0 takes it out.
+func (db *DB) SetJobProject(ctx context.Context, userID string, jobID, projectID int64) error {
+	res, err := db.ExecContext(ctx,
+		`UPDATE jobs SET project_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`, nullID(projectID), jobID, userID)
+	return expectRow(res, err, fmt.Sprintf("job %d not found", jobID))
+}
+
+// SetPlanProject moves userID's scheduled plan into a project; projectID 0 takes it out.
+func (db *DB) SetPlanProject(ctx context.Context, userID string, planID, projectID int64) error {
+	res, err := db.ExecContext(ctx,
+		`UPDATE scheduled_plans SET project_id = ? WHERE id = ? AND user_id = ?`, nullID(projectID), planID, userID)
+	return expectRow(res, err, fmt.Sprintf("scheduled plan %d not found", planID))
+}
+
+func expectRow(res sql.Result, err error, notFound string) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%s", notFound)
+	}
+	return nil
+}
+
+// ProjectJobs returns the jobs in a project (snoozed ones included), most recently updated first.
+func (db *DB) ProjectJobs(ctx context.Context, projectID int64) ([]Job, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, user_id, title, description, status, blocked_reason, snoozed_until, budget_usd, created_at, updated_at
+		 FROM jobs WHERE project_id = ? ORDER BY updated_at DESC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *j)
+	}
+	return out, rows.Err()
+}
+
+// ProjectPlans returns the scheduled plans in a project, next due first.
+func (db *DB) ProjectPlans(ctx context.Context, projectID int64) ([]ScheduledPlan, error) {
+	return db.listPlans(ctx, "", "", projectID)
+}
+
+// ProjectMemoryCount is how many memories are stored in a project's namespace.
+func (db *DB) ProjectMemoryCount(ctx context.Context, projectID int64) (int, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM memory_chunks WHERE project_id = ?`, projectID).Scan(&n)
+	return n, err
+}
+
+// DeleteProject removes a project and its links; its jobs, schedules, memories and goals stay, without a project.
+func (db *DB) DeleteProject(ctx context.Context, id int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		`UPDATE jobs SET project_id = NULL WHERE project_id = ?`,
+		`UPDATE scheduled_plans SET project_id = NULL WHERE project_id = ?`,
+		`UPDATE memory_chunks SET project_id = NULL WHERE project_id = ?`,
+		`UPDATE goals SET project_id = NULL WHERE project_id = ?`,
+		`DELETE FROM project_items WHERE project_id = ?`,
+		`DELETE FROM projects WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
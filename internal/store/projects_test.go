@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProjects(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.GetOrCreateUser(ctx, "u1", "", "api")
+	db.GetOrCreateUser(ctx, "u2", "", "api")
+
+	site, err := db.CreateProject(ctx, "u1", "Website", "Relaunch the site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tax, _ := db.CreateProject(ctx, "u1", "Taxes", "")
+	if _, err := db.CreateProject(ctx, "u1", "website", ""); err == nil {
+		t.Error("duplicate name accepted")
+	}
+	if p, _ := db.FindProject(ctx, "u1", "WEBSITE"); p == nil || p.ID != site {
+		t.Errorf("find by name = %+v", p)
+	}
+	if p, _ := db.FindProject(ctx, "u2", "#1"); p != nil {
+		t.Error("another user's project found by ID")
+	}
+
+	// The thread's project wins over the user's current project
+	if err := db.SetCurrentProject(ctx, "u1", tax); err != nil {
+		t.Fatal(err)
+	}
+	db.AddProjectItem(ctx, site, ProjectItemThread, "t-site")
+	if id := db.CurrentProjectID(ctx, "u1", "t-site"); id != site {
+		t.Errorf("current in linked thread = %d", id)
+	}
+	if id := db.CurrentProjectID(ctx, "u1", "t-other"); id != tax {
+		t.Errorf("current elsewhere = %d", id)
+	}
+	if err := db.UpdateProject(ctx, tax, "", ProjectDone); err != nil {
+		t.Fatal(err)
+	}
+	if id := db.CurrentProjectID(ctx, "u1", ""); id != 0 {
+		t.Errorf("finished project still current: %d", id)
+	}
+
+	// Jobs and plans join only their owner's projects
+	job, _ := db.CreateJob(ctx, "u1", "Pick a theme", "")
+	other, _ := db.CreateJob(ctx, "u2", "Not mine", "")
+	if err := db.SetJobProject(ctx, "u1", job, site); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetJobProject(ctx, "u1", other, site); err == nil {
+		t.Error("moved another user's job")
+	}
+	plan, _ := db.CreatePlan(ctx, "u1", "Check uptime", "remind", "{}", "daily", "09:00", "", time.Now().Add(time.Hour))
+	db.CreatePlan(ctx, "u1", "Unrelated", "remind", "{}", "daily", "10:00", "", time.Now().Add(time.Hour))
+	if err := db.SetPlanProject(ctx, "u1", plan, site); err != nil {
+		t.Fatal(err)
+	}
+	if jobs, _ := db.ProjectJobs(ctx, site); len(jobs) != 1 || jobs[0].ID != job {
+		t.Errorf("project jobs = %+v", jobs)
+	}
+	if plans, _ := db.ProjectPlans(ctx, site); len(plans) != 1 || plans[0].ID != plan {
+		t.Errorf("project plans = %+v", plans)
+	}
+
+	// Memories of other projects are not recalled
+	emb := []float32{1, 0}
+	db.InsertProjectChunk(ctx, "site palette", "user", "u1", site, "m", emb)
+	db.InsertProjectChunk(ctx, "tax receipts", "user", "u1", tax, "m", emb)
+	db.InsertChunk(ctx, "likes green", "user", "u1", "m", emb)
+	chunks, _ := db.SearchProjectChunks(ctx, emb, 10, site)
+	if len(chunks) != 2 {
+		t.Errorf("recalled %d memories in the project, want 2: %+v", len(chunks), chunks)
+	}
+	for _, c := range chunks {
+		if c.Content == "tax receipts" {
+			t.Error("recalled another project's memory")
+		}
+	}
+	if n, _ := db.ProjectMemoryCount(ctx, site); n != 1 {
+		t.Errorf("project memories = %d", n)
+	}
+
+	// Deleting a project keeps its items
+	if err := db.DeleteProject(ctx, site); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := db.GetJob(ctx, job); j == nil {
+		t.Error("job deleted with its project")
+	}
+	if id := db.CurrentProjectID(ctx, "u1", "t-site"); id != 0 {
+		t.Errorf("deleted project still current in its thread: %d", id)
+	}
+}
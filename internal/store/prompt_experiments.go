@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Prompt experiment statuses.
+const (
+	ExperimentRunning  = "running"
+	ExperimentPromoted = "promoted"
+	ExperimentStopped  = "stopped"
+)
+
+// Experiment arms: control turns use SOUL.md, variant turns the experiment's text.
+const (
+	ArmControl = "control"
+	ArmVariant = "variant"
+)
+
+// MinExperimentTurns is how many turns each arm needs before ExperimentWinner names a winner.
+const MinExperimentTurns = 20
+
+// minSuccessMargin is the success-rate lead a winner needs; smaller differences are noise.
+const minSuccessMargin = 0.05
+
+// PromptExperiment is an A/B test of a SOUL.md variant against the current SOUL.md. One
+// experiment runs at a time.
+type PromptExperiment struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Variant   string     `json:"variant"`
+	Baseline  string     `json:"-"` // SOUL.md when the experiment started
+	Fraction  float64    `json:"fraction"`
+	Status    string     `json:"status"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// ArmStats are the metrics of one experiment arm. A turn succeeded when none of its tool calls
+// failed, the user did not /regenerate the reply, and the reply was not rated down more than up.
+type ArmStats struct {
+	Arm            string  `json:"arm"`
+	Turns          int     `json:"turns"`
+	Succeeded      int     `json:"succeeded"`
+	ToolErrorTurns int     `json:"tool_error_turns"`
+	Regenerated    int     `json:"regenerated"`
+	RatedUp        int     `json:"rated_up"`
+	RatedDown      int     `json:"rated_down"`
+	SuccessRate    float64 `json:"success_rate"`
+}
+
+const promptExperimentColumns = `id, name, variant, baseline, fraction, status, created_by, created_at, ended_at`
+
+func scanPromptExperiment(row interface{ Scan(...interface{}) error }) (*PromptExperiment, error) {
+	var e PromptExperiment
+	var ended sql.NullTime
+	if err := row.Scan(&e.ID, &e.Name, &e.Variant, &e.Baseline, &e.Fraction, &e.Status, &e.CreatedBy, &e.CreatedAt, &ended); err != nil {
+		return nil, err
+	}
+	if ended.Valid {
+		e.EndedAt = &ended.Time
+	}
+	return &e, nil
+}
+
+// CreatePromptExperiment starts an experiment that gives variant to fraction of the turns. It
+// fails while another experiment is running.
+func (db *DB) CreatePromptExperiment(ctx context.Context, e PromptExperiment) (int64, error) {
+	if e.Fraction <= 0 || e.Fraction > 1 {
+		return 0, fmt.Errorf("fraction must be greater than 0 and at most 1")
+	}
+	running, err := db.RunningPromptExperiment(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if running != nil {
+		return 0, fmt.Errorf("experiment %q is still running; stop or promote it first", running.Name)
+	}
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO prompt_experiments (name, variant, baseline, fraction, status, created_by) VALUES (?, ?, ?, ?, ?, ?)`,
+		e.Name, e.Variant, e.Baseline, e.Fraction, ExperimentRunning, e.CreatedBy)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// RunningPromptExperiment returns the running experiment, or nil if there is none.
+func (db *DB) RunningPromptExperiment(ctx context.Context) (*PromptExperiment, error) {
+	e, err := scanPromptExperiment(db.QueryRowContext(ctx,
+		`SELECT `+promptExperimentColumns+` FROM prompt_experiments WHERE status = ? ORDER BY id DESC LIMIT 1`, ExperimentRunning))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// GetPromptExperiment returns the experiment called name, or nil if there is none.
+func (db *DB) GetPromptExperiment(ctx context.Context, name string) (*PromptExperiment, error) {
+	e, err := scanPromptExperiment(db.QueryRowContext(ctx,
+		`SELECT `+promptExperimentColumns+` FROM prompt_experiments WHERE name = ?`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// ListPromptExperiments returns all experiments, newest first.
+func (db *DB) ListPromptExperiments(ctx context.Context) ([]PromptExperiment, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+promptExperimentColumns+` FROM prompt_experiments ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PromptExperiment
+	for rows.Next() {
+		e, err := scanPromptExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *e)
+	}
+	return out, rows.Err()
+}
+
+// EndPromptExperiment marks a running experiment promoted or stopped.
+func (db *DB) EndPromptExperiment(ctx context.Context, id int64, status string) error {
+	if status != ExperimentPromoted && status != ExperimentStopped {
+		return fmt.Errorf("invalid experiment status %q", status)
+	}
+	res, err := db.ExecContext(ctx,
+		`UPDATE prompt_experiments SET status = ?, ended_at = ? WHERE id = ? AND status = ?`,
+		status, time.Now(), id, ExperimentRunning)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("experiment %d not found or not running", id)
+	}
+	return nil
+}
+
+// RecordExperimentTurn assigns a finished turn, identified by its final reply, to an arm.
+func (db *DB) RecordExperimentTurn(ctx context.Context, experimentID int64, arm, userID, threadID string, messageID int64, toolErrors int) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO experiment_turns (experiment_id, arm, user_id, thread_id, message_id, tool_errors) VALUES (?, ?, ?, ?, ?, ?)`,
+		experimentID, arm, userID, threadID, messageID, toolErrors)
+	return err
+}
+
+// ExperimentStats returns the metrics of both arms of an experiment, control first. Feedback and
+// regenerations count as they arrive, so the numbers can change after the turns were recorded.
+func (db *DB) ExperimentStats(ctx context.Context, experimentID int64) ([]ArmStats, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT t.arm, COUNT(*),
+			COALESCE(SUM(t.tool_errors = 0 AND COALESCE(m.superseded, 0) = 0 AND COALESCE(f.down, 0) <= COALESCE(f.up, 0)), 0),
+			COALESCE(SUM(t.tool_errors > 0), 0),
+			COALESCE(SUM(COALESCE(m.superseded, 0)), 0),
+			COALESCE(SUM(COALESCE(f.up, 0)), 0),
+			COALESCE(SUM(COALESCE(f.down, 0)), 0)
+		FROM experiment_turns t
+		LEFT JOIN messages m ON m.id = t.message_id
+		LEFT JOIN (SELECT message_id, SUM(rating > 0) AS up, SUM(rating < 0) AS down FROM feedback WHERE message_id != 0 GROUP BY message_id) f
+			ON f.message_id = t.message_id
+		WHERE t.experiment_id = ?
+		GROUP BY t.arm`, experimentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byArm := map[string]ArmStats{}
+	for rows.Next() {
+		var s ArmStats
+		if err := rows.Scan(&s.Arm, &s.Turns, &s.Succeeded, &s.ToolErrorTurns, &s.Regenerated, &s.RatedUp, &s.RatedDown); err != nil {
+			return nil, err
+		}
+		byArm[s.Arm] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]ArmStats, 0, 2)
+	for _, arm := range []string{ArmControl, ArmVariant} {
+		s := byArm[arm]
+		s.Arm = arm
+		if s.Turns > 0 {
+			s.SuccessRate = float64(s.Succeeded) / float64(s.Turns)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// ExperimentWinner compares the arms from ExperimentStats by success rate. It returns the winning
+// arm, or "" while either arm has fewer than MinExperimentTurns turns or neither leads clearly,
+// with the reason.
+func ExperimentWinner(stats []ArmStats) (string, string) {
+	var control, variant ArmStats
+	for _, s := range stats {
+		switch s.Arm {
+		case ArmControl:
+			control = s
+		case ArmVariant:
+			variant = s
+		}
+	}
+	if control.Turns < MinExperimentTurns || variant.Turns < MinExperimentTurns {
+		return "", fmt.Sprintf("not enough data yet: each arm needs %d turns (control %d, variant %d)", MinExperimentTurns, control.Turns, variant.Turns)
+	}
+	diff := variant.SuccessRate - control.SuccessRate
+	switch {
+	case diff >= minSuccessMargin:
+		return ArmVariant, fmt.Sprintf("variant succeeds in %.0f%% of turns vs %.0f%% for control", variant.SuccessRate*100, control.SuccessRate*100)
+	case -diff >= minSuccessMargin:
+		return ArmControl, fmt.Sprintf("control succeeds in %.0f%% of turns vs %.0f%% for the variant", control.SuccessRate*100, variant.SuccessRate*100)
+	}
+	return "", fmt.Sprintf("no clear winner: success rates %.0f%% (control) and %.0f%% (variant) are within %.0f points", control.SuccessRate*100, variant.SuccessRate*100, minSuccessMargin*100)
+}
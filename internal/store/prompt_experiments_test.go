@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestExperimentStatsAndWinner(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	id, err := db.CreatePromptExperiment(ctx, PromptExperiment{Name: "warm", Variant: "be warm", Fraction: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePromptExperiment(ctx, PromptExperiment{Name: "cold", Variant: "be cold", Fraction: 0.5}); err == nil {
+		t.Error("a second experiment started while one is running")
+	}
+
+	// Control: every third reply is rated down. Variant: one reply regenerated, one with a failed tool.
+	for i := 0; i < MinExperimentTurns; i++ {
+		reply, _ := db.InsertMessage(ctx, "assistant", "control reply", "", "hattiebot", "test", "c", "", "", "")
+		if i%3 == 0 {
+			_, _ = db.InsertFeedback(ctx, Feedback{UserID: "alice", MessageID: reply, Rating: -1, Source: FeedbackCommand})
+		}
+		if err := db.RecordExperimentTurn(ctx, id, ArmControl, "alice", "c", reply, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < MinExperimentTurns; i++ {
+		reply, _ := db.InsertMessage(ctx, "assistant", "variant reply", "", "hattiebot", "test", "v", "", "", "")
+		if i == 0 {
+			_, _ = db.SupersedeFrom(ctx, "v", reply)
+		}
+		if i == 1 {
+			_, _ = db.InsertFeedback(ctx, Feedback{UserID: "alice", MessageID: reply, Rating: 1, Source: FeedbackReaction})
+		}
+		errors := 0
+		if i == 2 {
+			errors = 1
+		}
+		if err := db.RecordExperimentTurn(ctx, id, ArmVariant, "alice", "v", reply, errors); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := db.ExperimentStats(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	control, variant := stats[0], stats[1]
+	if control.Turns != 20 || control.RatedDown != 7 || control.Succeeded != 13 {
+		t.Errorf("control = %+v", control)
+	}
+	if variant.Turns != 20 || variant.Regenerated != 1 || variant.ToolErrorTurns != 1 || variant.RatedUp != 1 || variant.Succeeded != 18 {
+		t.Errorf("variant = %+v", variant)
+	}
+	if winner, reason := ExperimentWinner(stats); winner != ArmVariant {
+		t.Errorf("winner = %q (%s), want the variant", winner, reason)
+	}
+	if winner, _ := ExperimentWinner([]ArmStats{{Arm: ArmControl, Turns: 50}, {Arm: ArmVariant, Turns: 5, Succeeded: 5, SuccessRate: 1}}); winner != "" {
+		t.Errorf("winner with too few variant turns = %q", winner)
+	}
+
+	if err := db.EndPromptExperiment(ctx, id, ExperimentPromoted); err != nil {
+		t.Fatal(err)
+	}
+	if exp, _ := db.GetPromptExperiment(ctx, "warm"); exp == nil || exp.Status != ExperimentPromoted || exp.EndedAt == nil {
+		t.Errorf("ended experiment = %+v", exp)
+	}
+	if running, _ := db.RunningPromptExperiment(ctx); running != nil {
+		t.Errorf("running after promotion = %+v", running)
+	}
+}
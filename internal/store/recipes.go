@@ -0,0 +1,53 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const installedRecipesFile = "recipes.json"
+
+// InstalledRecipe records what a recipe created, so it can be removed as a unit.
+type InstalledRecipe struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+	InstalledBy string    `json:"installed_by,omitempty"`
+	Webhooks    []string  `json:"webhooks,omitempty"` // route paths
+	Tools       []string  `json:"tools,omitempty"`
+	Subminds    []string  `json:"subminds,omitempty"`
+	PlanIDs     []int64   `json:"plan_ids,omitempty"`
+	Secrets     []string  `json:"secrets,omitempty"` // required secret refs (not owned; never removed)
+	// Source is the recipe document as installed.
+	Source string `json:"source,omitempty"`
+}
+
+// LoadInstalledRecipes reads $CONFIG_DIR/recipes.json. Returns nil, nil if the file does not exist.
+func LoadInstalledRecipes(configDir string) ([]InstalledRecipe, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, installedRecipesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var recipes []InstalledRecipe
+	if err := json.Unmarshal(data, &recipes); err != nil {
+		return nil, err
+	}
+	return recipes, nil
+}
+
+// SaveInstalledRecipes writes $CONFIG_DIR/recipes.json.
+func SaveInstalledRecipes(configDir string, recipes []InstalledRecipe) error {
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(recipes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(configDir, installedRecipesFile), data, 0600)
+}
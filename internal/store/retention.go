@@ -0,0 +1,240 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// ConversationSummary stands in for a thread's messages deleted by the retention policy.
+type ConversationSummary struct {
+	ID           int64     `json:"id"`
+	ThreadID     string    `json:"thread_id"`
+	Channel      string    `json:"channel,omitempty"`
+	Participants []string  `json:"participants"` // human senders in the summarized messages
+	Content      string    `json:"content"`
+	CoversUntil  time.Time `json:"covers_until"`
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ThreadsWithMessagesBefore returns the threads that have messages created before cutoff.
+func (db *DB) ThreadsWithMessagesBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT DISTINCT thread_id FROM messages WHERE created_at < ? ORDER BY thread_id`, cutoff.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// MessagesBefore returns threadID's messages created before cutoff, oldest first.
+func (db *DB) MessagesBefore(ctx context.Context, threadID string, cutoff time.Time) ([]Message, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, role, content, COALESCE(model, ''), sender_id, channel, thread_id, created_at
+		 FROM messages WHERE thread_id = ? AND created_at < ? ORDER BY created_at ASC, id ASC`,
+		threadID, cutoff.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.Model, &m.SenderID, &m.Channel, &m.ThreadID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// DeleteMessagesBefore deletes threadID's messages created before cutoff.
+func (db *DB) DeleteMessagesBefore(ctx context.Context, threadID string, cutoff time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM messages WHERE thread_id = ? AND created_at < ?`, threadID, cutoff.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ReplaceMessagesWithSummary stores s and deletes the thread's messages up to and including
+// s.CoversUntil, in one transaction so messages are never lost without their summary.
+func (db *DB) ReplaceMessagesWithSummary(ctx context.Context, s ConversationSummary) (int64, error) {
+	participants, err := json.Marshal(s.Participants)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	until := s.CoversUntil.UTC().Format("2006-01-02 15:04:05")
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO conversation_summaries (thread_id, channel, participants, content, covers_until, message_count) VALUES (?, ?, ?, ?, ?, ?)`,
+		s.ThreadID, s.Channel, string(participants), s.Content, until, s.MessageCount); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE thread_id = ? AND created_at <= ?`, s.ThreadID, until)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// LatestConversationSummary returns the newest summary for threadID, or nil.
+func (db *DB) LatestConversationSummary(ctx context.Context, threadID string) (*ConversationSummary, error) {
+	var s ConversationSummary
+	var participants string
+	err := db.QueryRowContext(ctx,
+		`SELECT id, thread_id, COALESCE(channel, ''), participants, content, covers_until, message_count, created_at
+		 FROM conversation_summaries WHERE thread_id = ? ORDER BY covers_until DESC, id DESC LIMIT 1`, threadID).
+		Scan(&s.ID, &s.ThreadID, &s.Channel, &participants, &s.Content, &s.CoversUntil, &s.MessageCount, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = json.Unmarshal([]byte(participants), &s.Participants)
+	return &s, nil
+}
+
+// PurgeReport counts the rows PurgeUser erased (or would erase), by table.
+type PurgeReport struct {
+	UserID  string           `json:"user_id"`
+	DryRun  bool             `json:"dry_run,omitempty"`
+	Deleted map[string]int64 `json:"deleted"`
+	// Anonymized are rows kept with the user ID cleared (LLM spend, for cost accounting).
+	Anonymized map[string]int64 `json:"anonymized"`
+}
+
+// Total is the number of rows erased or anonymized.
+func (r PurgeReport) Total() int64 {
+	var n int64
+	for _, c := range r.Deleted {
+		n += c
+	}
+	for _, c := range r.Anonymized {
+		n += c
+	}
+	return n
+}
+
+// purgeStatements erase one user's data; each takes the user ID as its only argument. Threads
+// where the user was the only human sender go entirely, bot replies included; in shared threads
+// only the user's own messages are removed. Summaries of any thread the user took part in go too.
+var purgeStatements = []struct {
+	table, where string
+}{
+	{"messages", `thread_id IN (SELECT thread_id FROM messages GROUP BY thread_id
+		HAVING SUM(sender_id = ?1) > 0 AND SUM(role = 'user' AND sender_id <> ?1) = 0) OR sender_id = ?1`},
+	{"conversation_summaries", `EXISTS (SELECT 1 FROM json_each(conversation_summaries.participants) WHERE value = ?1)`},
+	{"facts", `user_id = ?1`},
+	{"memory_chunks", `user_id = ?1`},
+	{"submind_sessions", `user_id = ?1`},
+	{"plan_runs", `user_id = ?1 OR plan_id IN (SELECT id FROM scheduled_plans WHERE user_id = ?1)`},
+	{"scheduled_plans", `user_id = ?1`},
+	{"pending_inputs", `user_id = ?1`},
+	{"turn_journal", `user_id = ?1`},
+	{"event_deliveries", `user_id = ?1`},
+	{"jobs", `user_id = ?1`},
+	{"goals", `user_id = ?1`},
+	{"project_items", `project_id IN (SELECT id FROM projects WHERE user_id = ?1)`},
+	{"projects", `user_id = ?1`},
+	{"api_tokens", `user_id = ?1`},
+	{"tool_permissions", `subject_type = 'user' AND subject = ?1`},
+	{"user_profiles", `user_id = ?1`},
+	{"held_messages", `user_id = ?1`},
+	{"notification_rules", `user_id = ?1`},
+	{"notification_digest", `user_id = ?1`},
+	{"escalations", `user_id = ?1`},
+	{"identity_link_codes", `user_id = ?1`},
+	{"identities", `user_id = ?1`},
+	{"users", `id = ?1`},
+}
+
+// PurgeUser erases everything stored about userID: messages, conversation summaries, facts,
+// memories, sub-mind sessions, schedules, pending questions, jobs, goals, projects, API tokens, permissions,
+// the profile, notification rules and the user record. LLM spend rows are kept without the user ID. The tool audit log is left to its own retention.
+// Identities linked to the user are erased too, along with what is left under their former user IDs.
+// With dryRun nothing is changed and the report counts what would be erased.
+func (db *DB) PurgeUser(ctx context.Context, userID string, dryRun bool) (PurgeReport, error) {
+	report := PurgeReport{UserID: userID, DryRun: dryRun, Deleted: map[string]int64{}, Anonymized: map[string]int64{}}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+	ids, err := linkedUserIDs(ctx, tx, userID)
+	if err != nil {
+		return report, err
+	}
+	for _, id := range ids {
+		for _, st := range purgeStatements {
+			var n int64
+			if dryRun {
+				err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+st.table+` WHERE `+st.where, id).Scan(&n)
+			} else {
+				var res sql.Result
+				if res, err = tx.ExecContext(ctx, `DELETE FROM `+st.table+` WHERE `+st.where, id); err == nil {
+					n, err = res.RowsAffected()
+				}
+			}
+			if err != nil {
+				return report, err
+			}
+			report.Deleted[st.table] += n
+		}
+		var n int64
+		if dryRun {
+			err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM llm_usage WHERE user_id = ?`, id).Scan(&n)
+		} else {
+			var res sql.Result
+			if res, err = tx.ExecContext(ctx, `UPDATE llm_usage SET user_id = '' WHERE user_id = ?`, id); err == nil {
+				n, err = res.RowsAffected()
+			}
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Anonymized["llm_usage"] += n
+	}
+	if dryRun {
+		return report, nil
+	}
+	return report, tx.Commit()
+}
+
+// linkedUserIDs returns userID and the former user IDs of the identities linked to it (a linked
+// sender's ID was its user ID until it was linked).
+func linkedUserIDs(ctx context.Context, tx *sql.Tx, userID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT external_id FROM identities WHERE user_id = ? AND external_id <> ?`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []string{userID}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPurgeUser(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, id := range []string{"alice", "bob"} {
+		if _, err := db.GetOrCreateUser(ctx, id, "", "api"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetFact(ctx, id, "pet", "cat", ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.InsertChunk(ctx, id+" likes tea", "chat", id, "m", []float32{1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.CreateJob(ctx, id, "taxes", ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := db.CreateAPIToken(ctx, id, "phone"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.InsertUsage(ctx, UsageRecord{UserID: id, Model: "m", CostUSD: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.CreatePlan(ctx, "alice", "standup", "remind", "{}", "daily", "09:00", "UTC", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []struct{ role, sender, thread string }{
+		{"user", "alice", "dm-alice"}, {"assistant", "hattiebot", "dm-alice"},
+		{"user", "alice", "kitchen"}, {"user", "bob", "kitchen"}, {"assistant", "hattiebot", "kitchen"},
+	} {
+		if _, err := db.InsertMessage(ctx, m.role, "hi", "", m.sender, "api", m.thread, "", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.ReplaceMessagesWithSummary(ctx, ConversationSummary{ThreadID: "old-room", Participants: []string{"alice", "bob"}, Content: "planned a trip", CoversUntil: time.Now().Add(-48 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int64{"messages": 3, "conversation_summaries": 1, "facts": 1, "memory_chunks": 1, "scheduled_plans": 1, "jobs": 1, "api_tokens": 1, "users": 1}
+	dry, err := db.PurgeUser(ctx, "alice", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for table, n := range want {
+		if dry.Deleted[table] != n {
+			t.Errorf("dry run %s = %d, want %d", table, dry.Deleted[table], n)
+		}
+	}
+	if _, err := db.GetUser(ctx, "alice"); err != nil {
+		t.Fatalf("dry run erased alice: %v", err)
+	}
+
+	report, err := db.PurgeUser(ctx, "alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deleted["messages"] != 3 || report.Anonymized["llm_usage"] != 1 {
+		t.Errorf("report = %+v", report)
+	}
+	if _, err := db.GetUser(ctx, "alice"); err == nil {
+		t.Error("alice still exists")
+	}
+	if again, _ := db.PurgeUser(ctx, "alice", true); again.Total() != 0 {
+		t.Errorf("left behind: %+v", again)
+	}
+	// Bob's data and the shared thread's other messages stay
+	msgs, err := db.RecentMessages(ctx, 10, "kitchen")
+	if err != nil || len(msgs) != 2 || msgs[0].SenderID != "bob" {
+		t.Errorf("kitchen after purge = %+v, %v", msgs, err)
+	}
+	if f, err := db.GetFact(ctx, "bob", "pet"); err != nil || f == nil {
+		t.Errorf("bob's fact = %+v, %v", f, err)
+	}
+	if bob, _ := db.PurgeUser(ctx, "bob", true); bob.Deleted["memory_chunks"] != 1 || bob.Deleted["users"] != 1 {
+		t.Errorf("bob's data = %+v", bob)
+	}
+}
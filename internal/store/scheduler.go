@@ -14,7 +14,8 @@ type ScheduledPlan struct {
 	ActionType    string     `json:"action_type"`    // "remind", "execute_tool", "agent_prompt"
 	ActionPayload string     `json:"action_payload"` // JSON: remind={}, execute_tool={"tool","args"}, agent_prompt={"prompt","autonomous"}
 	ScheduleType  string     `json:"schedule_type"`  // "once", "daily", "weekly"
-	ScheduleValue string     `json:"schedule_value"` // time or datetime
+	ScheduleValue string     `json:"schedule_value"` // datetime for once; recurrence rule otherwise (see scheduler.Rule)
+	Timezone      string     `json:"timezone,omitempty"` // IANA zone the rule is evaluated in; empty = server local
 	NextRunAt     *time.Time `json:"next_run_at"`
 	LastRunAt     *time.Time `json:"last_run_at"`
 	LockedUntil   *time.Time `json:"locked_until"`
@@ -23,11 +24,11 @@ type ScheduledPlan struct {
 }
 
 // CreatePlan creates a new scheduled plan.
-func (db *DB) CreatePlan(ctx context.Context, userID, description, actionType, actionPayload, scheduleType, scheduleValue string, nextRunAt time.Time) (int64, error) {
+func (db *DB) CreatePlan(ctx context.Context, userID, description, actionType, actionPayload, scheduleType, scheduleValue, timezone string, nextRunAt time.Time) (int64, error) {
 	res, err := db.ExecContext(ctx,
-		`INSERT INTO scheduled_plans (user_id, description, action_type, action_payload, schedule_type, schedule_value, next_run_at, status) 
-		 VALUES (?, ?, ?, ?, ?, ?, ?, 'active')`,
-		userID, description, actionType, actionPayload, scheduleType, scheduleValue, nextRunAt,
+		`INSERT INTO scheduled_plans (user_id, description, action_type, action_payload, schedule_type, schedule_value, timezone, next_run_at, status) 
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, 'active')`,
+		userID, description, actionType, actionPayload, scheduleType, scheduleValue, timezone, nextRunAt,
 	)
 	if err != nil {
 		return 0, err
@@ -37,12 +38,29 @@ func (db *DB) CreatePlan(ctx context.Context, userID, description, actionType, a
 
 // ListPlans returns all plans for a user with optional status filter.
 func (db *DB) ListPlans(ctx context.Context, userID, status string) ([]ScheduledPlan, error) {
-	query := `SELECT id, user_id, description, action_type, action_payload, schedule_type, schedule_value, next_run_at, last_run_at, status, created_at FROM scheduled_plans WHERE user_id = ?`
-	args := []interface{}{userID}
+	return db.listPlans(ctx, userID, status, 0)
+}
+
+// ListAllPlans returns the plans of all users with optional status filter, next due first.
+func (db *DB) ListAllPlans(ctx context.Context, status string) ([]ScheduledPlan, error) {
+	return db.listPlans(ctx, "", status, 0)
+}
+
+func (db *DB) listPlans(ctx context.Context, userID, status string, projectID int64) ([]ScheduledPlan, error) {
+	query := `SELECT id, user_id, description, action_type, action_payload, schedule_type, schedule_value, timezone, next_run_at, last_run_at, status, created_at FROM scheduled_plans WHERE 1=1`
+	var args []interface{}
+	if userID != "" {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
 	if status != "" {
 		query += " AND status = ?"
 		args = append(args, status)
 	}
+	if projectID != 0 {
+		query += " AND project_id = ?"
+		args = append(args, projectID)
+	}
 	query += " ORDER BY next_run_at ASC"
 
 	rows, err := db.QueryContext(ctx, query, args...)
@@ -55,8 +73,8 @@ func (db *DB) ListPlans(ctx context.Context, userID, status string) ([]Scheduled
 	for rows.Next() {
 		var p ScheduledPlan
 		var nextRun, lastRun sql.NullTime
-		var payload sql.NullString
-		if err := rows.Scan(&p.ID, &p.UserID, &p.Description, &p.ActionType, &payload, &p.ScheduleType, &p.ScheduleValue, &nextRun, &lastRun, &p.Status, &p.CreatedAt); err != nil {
+		var payload, tz sql.NullString
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Description, &p.ActionType, &payload, &p.ScheduleType, &p.ScheduleValue, &tz, &nextRun, &lastRun, &p.Status, &p.CreatedAt); err != nil {
 			return nil, err
 		}
 		if nextRun.Valid {
@@ -68,6 +86,7 @@ func (db *DB) ListPlans(ctx context.Context, userID, status string) ([]Scheduled
 		if payload.Valid {
 			p.ActionPayload = payload.String
 		}
+		p.Timezone = tz.String
 		out = append(out, p)
 	}
 	return out, rows.Err()
@@ -76,7 +95,7 @@ func (db *DB) ListPlans(ctx context.Context, userID, status string) ([]Scheduled
 // GetDuePlans returns plans that should run now or in the past (global, for scheduler).
 func (db *DB) GetDuePlans(ctx context.Context) ([]ScheduledPlan, error) {
 	rows, err := db.QueryContext(ctx,
-		`SELECT id, user_id, description, action_type, action_payload, schedule_type, schedule_value, next_run_at, last_run_at, status, created_at 
+		`SELECT id, user_id, description, action_type, action_payload, schedule_type, schedule_value, timezone, next_run_at, last_run_at, status, created_at 
 		 FROM scheduled_plans 
 		 WHERE status = 'active' AND next_run_at <= ?`,
 		time.Now(),
@@ -90,8 +109,8 @@ func (db *DB) GetDuePlans(ctx context.Context) ([]ScheduledPlan, error) {
 	for rows.Next() {
 		var p ScheduledPlan
 		var nextRun, lastRun sql.NullTime
-		var payload sql.NullString
-		if err := rows.Scan(&p.ID, &p.UserID, &p.Description, &p.ActionType, &payload, &p.ScheduleType, &p.ScheduleValue, &nextRun, &lastRun, &p.Status, &p.CreatedAt); err != nil {
+		var payload, tz sql.NullString
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Description, &p.ActionType, &payload, &p.ScheduleType, &p.ScheduleValue, &tz, &nextRun, &lastRun, &p.Status, &p.CreatedAt); err != nil {
 			return nil, err
 		}
 		if nextRun.Valid {
@@ -103,6 +122,7 @@ func (db *DB) GetDuePlans(ctx context.Context) ([]ScheduledPlan, error) {
 		if payload.Valid {
 			p.ActionPayload = payload.String
 		}
+		p.Timezone = tz.String
 		out = append(out, p)
 	}
 	return out, rows.Err()
@@ -120,7 +140,7 @@ func (db *DB) ClaimDuePlans(ctx context.Context, lockTimeout time.Duration) ([]S
 		WHERE status = 'active' 
 		  AND next_run_at <= ? 
 		  AND (locked_until IS NULL OR locked_until < ?)
-		RETURNING id, user_id, description, action_type, action_payload, schedule_type, schedule_value, next_run_at, last_run_at, locked_until, status, created_at
+		RETURNING id, user_id, description, action_type, action_payload, schedule_type, schedule_value, timezone, next_run_at, last_run_at, locked_until, status, created_at
 	`
 	
 	rows, err := db.QueryContext(ctx, query, lockUntil, now, now)
@@ -133,50 +153,64 @@ func (db *DB) ClaimDuePlans(ctx context.Context, lockTimeout time.Duration) ([]S
 	for rows.Next() {
 		var p ScheduledPlan
 		var nextRun, lastRun, lockedUntil sql.NullTime
-		var payload sql.NullString
-		if err := rows.Scan(&p.ID, &p.UserID, &p.Description, &p.ActionType, &payload, &p.ScheduleType, &p.ScheduleValue, &nextRun, &lastRun, &lockedUntil, &p.Status, &p.CreatedAt); err != nil {
+		var payload, tz sql.NullString
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Description, &p.ActionType, &payload, &p.ScheduleType, &p.ScheduleValue, &tz, &nextRun, &lastRun, &lockedUntil, &p.Status, &p.CreatedAt); err != nil {
 			return nil, err
 		}
 		if nextRun.Valid { p.NextRunAt = &nextRun.Time }
 		if lastRun.Valid { p.LastRunAt = &lastRun.Time }
 		if lockedUntil.Valid { p.LockedUntil = &lockedUntil.Time }
 		if payload.Valid { p.ActionPayload = payload.String }
+		p.Timezone = tz.String
 		out = append(out, p)
 	}
 	return out, rows.Err()
 }
 
-// MarkPlanRun updates last_run_at and calculates next_run_at for recurring plans.
-func (db *DB) MarkPlanRun(ctx context.Context, id int64, scheduleType string) error {
+// MarkPlanRun records a run and sets the plan's next run. A nil nextRun completes the plan
+// (one-time plans); the caller computes nextRun from the plan's recurrence rule.
+func (db *DB) MarkPlanRun(ctx context.Context, id int64, nextRun *time.Time) error {
 	now := time.Now()
-	var nextRun *time.Time
-
-	switch scheduleType {
-	case "once":
-		// One-time plans are marked complete
+	if nextRun == nil {
 		_, err := db.ExecContext(ctx,
-			`UPDATE scheduled_plans SET last_run_at = ?, status = 'completed' WHERE id = ?`,
+			`UPDATE scheduled_plans SET last_run_at = ?, locked_until = NULL, status = 'completed' WHERE id = ?`,
 			now, id,
 		)
 		return err
-	case "daily":
-		next := now.Add(24 * time.Hour)
-		nextRun = &next
-	case "weekly":
-		next := now.Add(7 * 24 * time.Hour)
-		nextRun = &next
-	case "hourly":
-		next := now.Add(1 * time.Hour)
-		nextRun = &next
 	}
-
 	_, err := db.ExecContext(ctx,
 		`UPDATE scheduled_plans SET last_run_at = ?, next_run_at = ?, locked_until = NULL WHERE id = ?`,
-		now, nextRun, id,
+		now, *nextRun, id,
+	)
+	return err
+}
+
+// DeferPlan moves a claimed plan's next run to until without recording a run.
+func (db *DB) DeferPlan(ctx context.Context, id int64, until time.Time) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE scheduled_plans SET next_run_at = ?, locked_until = NULL WHERE id = ?`,
+		until, id,
 	)
 	return err
 }
 
+// SetPlanPreapproved marks userID's plan as approved to use confirm-gated tools (send_email, ...)
+// in its runs without asking again.
+func (db *DB) SetPlanPreapproved(ctx context.Context, userID string, planID int64) error {
+	res, err := db.ExecContext(ctx, `UPDATE scheduled_plans SET preapproved = 1 WHERE id = ? AND user_id = ?`, planID, userID)
+	return expectRow(res, err, fmt.Sprintf("scheduled plan %d not found", planID))
+}
+
+// PlanPreapproved reports whether a plan's runs may use confirm-gated tools without asking.
+func (db *DB) PlanPreapproved(ctx context.Context, planID int64) (bool, error) {
+	var approved bool
+	err := db.QueryRowContext(ctx, `SELECT preapproved FROM scheduled_plans WHERE id = ?`, planID).Scan(&approved)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return approved, err
+}
+
 // UpdatePlanStatus changes the status of a plan.
 func (db *DB) UpdatePlanStatus(ctx context.Context, id int64, status string) error {
 	_, err := db.ExecContext(ctx, `UPDATE scheduled_plans SET status = ? WHERE id = ?`, status, id)
@@ -1,10 +1,11 @@
 package store
 
+// schema is the baseline (migration 1). Schema changes go in migrations.go, not here.
 const schema = `
 CREATE TABLE IF NOT EXISTS users (
 	id TEXT PRIMARY KEY,
 	name TEXT,
-	role TEXT DEFAULT 'user',
+	role TEXT DEFAULT 'user', -- owner, admin, operator, user
 	platform TEXT,
 	trust_level TEXT DEFAULT 'trusted', -- admin, trusted, guest, restricted, blocked
 	metadata TEXT,
@@ -41,7 +42,42 @@ CREATE TABLE IF NOT EXISTS tools_registry (
 	status TEXT DEFAULT 'active',
 	last_success DATETIME,
 	failure_count INTEGER DEFAULT 0,
-	last_error TEXT
+	last_error TEXT,
+	version INTEGER DEFAULT 1,
+	source_hash TEXT, -- sha256 of the Go source (or the binary when there is none)
+	previous_binary_path TEXT, -- archived binary of the version this one replaced
+	last_failed_input TEXT -- arguments of the most recent failing call, for repair
+);
+
+CREATE TABLE IF NOT EXISTS tool_repairs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	from_version INTEGER NOT NULL,
+	to_version INTEGER, -- set when the fix was registered
+	status TEXT NOT NULL DEFAULT 'running', -- running, fixed, failed
+	error TEXT, -- the tool failure being repaired
+	result TEXT, -- why the repair failed, or the sub-mind's summary of the fix
+	diff TEXT,
+	started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_repairs_name ON tool_repairs(name, from_version);
+
+CREATE TABLE IF NOT EXISTS tool_versions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	binary_path TEXT NOT NULL, -- archived copy, so rebuilding the tool in place cannot overwrite it
+	source_hash TEXT,
+	description TEXT,
+	input_schema TEXT,
+	source_dir TEXT, -- where the source was read from at registration
+	source TEXT, -- JSON [{name, content}] of the Go files, NULL when not found or too large
+	git_commit TEXT, -- HEAD of the repo containing source_dir, if any
+	git_dirty INTEGER DEFAULT 0, -- source_dir had uncommitted changes
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(name, version)
 );
 
 CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at);
@@ -54,6 +90,7 @@ CREATE TABLE IF NOT EXISTS jobs (
 	status TEXT NOT NULL DEFAULT 'open', -- open, blocked, closed
 	blocked_reason TEXT,
 	snoozed_until DATETIME, -- NULL = not snoozed, otherwise hide until this time
+	budget_usd REAL, -- NULL = no per-job budget
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	FOREIGN KEY(user_id) REFERENCES users(id)
@@ -79,6 +116,7 @@ CREATE TABLE IF NOT EXISTS scheduled_plans (
 	action_payload TEXT,
 	schedule_type TEXT NOT NULL,
 	schedule_value TEXT,
+	timezone TEXT, -- IANA zone for recurrence rules; NULL/empty = server local
 	next_run_at DATETIME,
 	last_run_at DATETIME,
 	locked_until DATETIME,
@@ -153,4 +191,91 @@ CREATE TABLE IF NOT EXISTS trusted_identities (
 	UNIQUE(type, value)
 );
 CREATE INDEX IF NOT EXISTS idx_trusted_identities_type_value ON trusted_identities(type, value);
+
+CREATE TABLE IF NOT EXISTS llm_usage (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	user_id TEXT NOT NULL,
+	thread_id TEXT,
+	job_id INTEGER, -- active job when the call was made (NULL = none)
+	plan_id INTEGER, -- scheduled plan that triggered the turn (NULL = user-initiated)
+	model TEXT,
+	prompt_tokens INTEGER NOT NULL DEFAULT 0,
+	completion_tokens INTEGER NOT NULL DEFAULT 0,
+	cost_usd REAL NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_llm_usage_created_at ON llm_usage(created_at);
+CREATE INDEX IF NOT EXISTS idx_llm_usage_job ON llm_usage(job_id);
+CREATE INDEX IF NOT EXISTS idx_llm_usage_plan ON llm_usage(plan_id);
+
+CREATE TABLE IF NOT EXISTS tool_permissions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	subject_type TEXT NOT NULL, -- user, trust_level
+	subject TEXT NOT NULL, -- user ID or trust level (e.g. trusted)
+	tool TEXT NOT NULL DEFAULT '', -- tool name; '' when granting a whole policy
+	policy TEXT NOT NULL DEFAULT '', -- e.g. restricted; '' when granting a single tool
+	work_dir TEXT NOT NULL DEFAULT '', -- absolute dir the tool's work_dir must stay in ('' = unconstrained)
+	granted_by TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(subject_type, subject, tool, policy)
+);
+
+CREATE TABLE IF NOT EXISTS tool_audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	user_id TEXT NOT NULL DEFAULT '', -- '' for system/internal calls
+	tool TEXT NOT NULL,
+	args TEXT, -- JSON with secret values redacted
+	channel TEXT,
+	thread_id TEXT,
+	outcome TEXT NOT NULL, -- ok, error, denied
+	error TEXT,
+	duration_ms INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_tool_audit_log_created_at ON tool_audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_tool_audit_log_user ON tool_audit_log(user_id);
+-- Append-only: entries can expire (retention) but never be rewritten.
+CREATE TRIGGER IF NOT EXISTS tool_audit_log_no_update BEFORE UPDATE ON tool_audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'tool_audit_log is append-only');
+END;
+
+CREATE TABLE IF NOT EXISTS plan_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	plan_id INTEGER NOT NULL,
+	user_id TEXT NOT NULL DEFAULT '',
+	thread_id TEXT,
+	status TEXT NOT NULL DEFAULT 'running', -- running, succeeded, partial, failed, skipped
+	summary TEXT,
+	artifacts TEXT, -- JSON array of strings (file paths, URLs, IDs)
+	next_suggested_run DATETIME,
+	reported INTEGER NOT NULL DEFAULT 0, -- 1 = the agent filed the result via report_task_result
+	started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	finished_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_plan_runs_plan ON plan_runs(plan_id);
+
+CREATE TABLE IF NOT EXISTS announcement_audiences (
+	name TEXT PRIMARY KEY,
+	targets TEXT NOT NULL, -- JSON array of {channel, thread_id, label}
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS onboarding_checklist (
+	item TEXT PRIMARY KEY, -- channel_connected, embedding_configured, backups_enabled, admin_approved, first_tool_built
+	done INTEGER NOT NULL DEFAULT 0,
+	dismissed INTEGER NOT NULL DEFAULT 0, -- admin chose to skip this item
+	note TEXT,
+	completed_at DATETIME,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL, -- requests authenticated with the token act as this user
+	name TEXT NOT NULL DEFAULT '', -- label, e.g. the integrating service
+	token_hash TEXT NOT NULL UNIQUE, -- sha256 hex; the token itself is shown once at creation
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_used_at DATETIME
+);
 `
@@ -15,15 +15,24 @@ type SubmindSession struct {
 	UserID       string    `json:"user_id"`
 	Mode         string    `json:"mode"`
 	Task         string    `json:"task"`
-	Status       string    `json:"status"` // running, completed, failed, suspended
+	Status       string    `json:"status"` // queued, running, completed, failed, suspended, awaiting_input
 	MessagesJSON string    `json:"-"`      // stored in DB; use Messages() for parsed slice
 	Turns        int       `json:"turns"`
 	ResultOutput string    `json:"result_output,omitempty"`
 	ResultError  string    `json:"result_error,omitempty"`
+	Async        bool      `json:"async,omitempty"`     // run by the background runner
+	ThreadID     string    `json:"thread_id,omitempty"` // conversation an async session was started from
+	Channel      string    `json:"channel,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// SubmindSessionDone reports whether a session has stopped for good or waits on the user; queued
+// and running sessions are still working.
+func SubmindSessionDone(status string) bool {
+	return status != "queued" && status != "running"
+}
+
 // Messages returns the session messages parsed from JSON. Returns nil on parse error.
 func (s *SubmindSession) Messages() []core.Message {
 	if s.MessagesJSON == "" {
@@ -61,10 +70,10 @@ func (db *DB) GetSubmindSession(ctx context.Context, id int64, userID string) (*
 	var s SubmindSession
 	var resultOut, resultErr sql.NullString
 	err := db.QueryRowContext(ctx,
-		`SELECT id, user_id, mode, task, status, messages, turns, result_output, result_error, created_at, updated_at
+		`SELECT id, user_id, mode, task, status, messages, turns, result_output, result_error, async, thread_id, channel, created_at, updated_at
 		 FROM submind_sessions WHERE id = ? AND user_id = ?`,
 		id, userID,
-	).Scan(&s.ID, &s.UserID, &s.Mode, &s.Task, &s.Status, &s.MessagesJSON, &s.Turns, &resultOut, &resultErr, &s.CreatedAt, &s.UpdatedAt)
+	).Scan(&s.ID, &s.UserID, &s.Mode, &s.Task, &s.Status, &s.MessagesJSON, &s.Turns, &resultOut, &resultErr, &s.Async, &s.ThreadID, &s.Channel, &s.CreatedAt, &s.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, err
 	}
@@ -96,9 +105,52 @@ func (db *DB) UpdateSubmindSession(ctx context.Context, id int64, messages []cor
 	return err
 }
 
+// SetSubmindSessionStatus changes only the status of a sub-mind session.
+func (db *DB) SetSubmindSessionStatus(ctx context.Context, id int64, status string) error {
+	_, err := db.ExecContext(ctx, `UPDATE submind_sessions SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, status, id)
+	return err
+}
+
+// QueueSubmindSession marks a session for the background runner, remembering the conversation it
+// was started from.
+func (db *DB) QueueSubmindSession(ctx context.Context, id int64, threadID, channel string) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE submind_sessions SET async = 1, status = 'queued', thread_id = ?, channel = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		threadID, channel, id)
+	return err
+}
+
+// FailSubmindSession marks a session failed with errMsg, keeping its messages.
+func (db *DB) FailSubmindSession(ctx context.Context, id int64, errMsg string) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE submind_sessions SET status = 'failed', result_error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, errMsg, id)
+	return err
+}
+
+// UnfinishedAsyncSubmindSessions returns the background sessions of all users that are queued or
+// were running (e.g. when the process stopped), oldest first.
+func (db *DB) UnfinishedAsyncSubmindSessions(ctx context.Context) ([]SubmindSession, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, user_id, mode, task, status, turns, thread_id, channel, created_at, updated_at
+		 FROM submind_sessions WHERE async = 1 AND status IN ('queued', 'running') ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SubmindSession
+	for rows.Next() {
+		s := SubmindSession{Async: true}
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Mode, &s.Task, &s.Status, &s.Turns, &s.ThreadID, &s.Channel, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
 // ListSubmindSessions returns sessions for the user, optionally filtered by status ("" = all).
 func (db *DB) ListSubmindSessions(ctx context.Context, userID, status string) ([]SubmindSession, error) {
-	query := `SELECT id, user_id, mode, task, status, turns, result_output, result_error, created_at, updated_at
+	query := `SELECT id, user_id, mode, task, status, turns, result_output, result_error, async, thread_id, channel, created_at, updated_at
 	          FROM submind_sessions WHERE user_id = ?`
 	args := []interface{}{userID}
 	if status != "" {
@@ -117,7 +169,7 @@ func (db *DB) ListSubmindSessions(ctx context.Context, userID, status string) ([
 	for rows.Next() {
 		var s SubmindSession
 		var resultOut, resultErr sql.NullString
-		if err := rows.Scan(&s.ID, &s.UserID, &s.Mode, &s.Task, &s.Status, &s.Turns, &resultOut, &resultErr, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Mode, &s.Task, &s.Status, &s.Turns, &resultOut, &resultErr, &s.Async, &s.ThreadID, &s.Channel, &s.CreatedAt, &s.UpdatedAt); err != nil {
 			return nil, err
 		}
 		if resultOut.Valid {
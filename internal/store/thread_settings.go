@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxPromptAddendum is the longest thread prompt addendum, in bytes.
+const MaxPromptAddendum = 4000
+
+// AddressingModes are the accepted values of ThreadSettings.Addressing: which messages the bot
+// answers in a room where several people write.
+var AddressingModes = []string{"auto", "mention", "all"}
+
+// ThreadSettings tailor the agent to one conversation (e.g. a Talk room): extra system prompt
+// text, the tools it may use, how long its replies should be, and which group messages it
+// answers. Empty fields are unset.
+type ThreadSettings struct {
+	Channel        string `json:"channel"`
+	ThreadID       string `json:"thread_id"`
+	PromptAddendum string `json:"prompt_addendum,omitempty"`
+	// AllowedTools are allowed_tools patterns, as for sub-minds; nil allows every tool.
+	AllowedTools []string  `json:"allowed_tools,omitempty"`
+	Verbosity    string    `json:"verbosity,omitempty"`  // see ProfileVerbosities
+	Addressing   string    `json:"addressing,omitempty"` // see AddressingModes; "" = the configured default
+	UpdatedBy    string    `json:"updated_by,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
+
+// IsEmpty reports whether no setting is set.
+func (s *ThreadSettings) IsEmpty() bool {
+	return s.PromptAddendum == "" && len(s.AllowedTools) == 0 && s.Verbosity == "" && s.Addressing == ""
+}
+
+// Validate checks the verbosity, the addressing mode and the addendum's length. Tool patterns are
+// checked by the tools package, which knows them.
+func (s *ThreadSettings) Validate() error {
+	if s.Channel == "" || s.ThreadID == "" {
+		return fmt.Errorf("channel and thread_id are required")
+	}
+	if s.Verbosity != "" && !containsString(ProfileVerbosities, s.Verbosity) {
+		return fmt.Errorf("verbosity must be one of %s", strings.Join(ProfileVerbosities, ", "))
+	}
+	if s.Addressing != "" && !containsString(AddressingModes, s.Addressing) {
+		return fmt.Errorf("addressing must be one of %s", strings.Join(AddressingModes, ", "))
+	}
+	if len(s.PromptAddendum) > MaxPromptAddendum {
+		return fmt.Errorf("prompt_addendum is %d bytes; the limit is %d", len(s.PromptAddendum), MaxPromptAddendum)
+	}
+	return nil
+}
+
+// GetThreadSettings returns the thread's settings; a thread without any gets empty settings.
+func (db *DB) GetThreadSettings(ctx context.Context, channel, threadID string) (*ThreadSettings, error) {
+	s := &ThreadSettings{Channel: channel, ThreadID: threadID}
+	var tools string
+	err := db.QueryRowContext(ctx,
+		`SELECT prompt_addendum, allowed_tools, verbosity, addressing, updated_by, updated_at FROM thread_settings WHERE channel = ? AND thread_id = ?`,
+		channel, threadID,
+	).Scan(&s.PromptAddendum, &tools, &s.Verbosity, &s.Addressing, &s.UpdatedBy, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeAllowedTools(tools, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetThreadSettings validates and stores s, replacing the thread's previous settings. Empty
+// settings remove the thread's row.
+func (db *DB) SetThreadSettings(ctx context.Context, s *ThreadSettings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if s.IsEmpty() {
+		_, err := db.ExecContext(ctx, `DELETE FROM thread_settings WHERE channel = ? AND thread_id = ?`, s.Channel, s.ThreadID)
+		return err
+	}
+	tools := ""
+	if len(s.AllowedTools) > 0 {
+		b, err := json.Marshal(s.AllowedTools)
+		if err != nil {
+			return err
+		}
+		tools = string(b)
+	}
+	s.UpdatedAt = time.Now()
+	_, err := db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO thread_settings (channel, thread_id, prompt_addendum, allowed_tools, verbosity, addressing, updated_by, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.Channel, s.ThreadID, s.PromptAddendum, tools, s.Verbosity, s.Addressing, s.UpdatedBy, s.UpdatedAt)
+	return err
+}
+
+// ListThreadSettings returns every thread with settings, by channel and thread.
+func (db *DB) ListThreadSettings(ctx context.Context) ([]ThreadSettings, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT channel, thread_id, prompt_addendum, allowed_tools, verbosity, addressing, updated_by, updated_at FROM thread_settings ORDER BY channel, thread_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ThreadSettings
+	for rows.Next() {
+		var s ThreadSettings
+		var tools string
+		if err := rows.Scan(&s.Channel, &s.ThreadID, &s.PromptAddendum, &tools, &s.Verbosity, &s.Addressing, &s.UpdatedBy, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := decodeAllowedTools(tools, &s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func decodeAllowedTools(tools string, s *ThreadSettings) error {
+	if tools == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(tools), &s.AllowedTools); err != nil {
+		return fmt.Errorf("thread %s:%s allowed_tools: %w", s.Channel, s.ThreadID, err)
+	}
+	return nil
+}
+
+// ThreadParticipants returns the distinct senders of the last limit user messages of a thread,
+// most recent first.
+func (db *DB) ThreadParticipants(ctx context.Context, channel, threadID string, limit int) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT sender_id FROM messages WHERE channel = ? AND thread_id = ? AND role = 'user' ORDER BY id DESC LIMIT ?`,
+		channel, threadID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	seen := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestThreadSettings(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s, err := db.GetThreadSettings(ctx, "talk", "ops")
+	if err != nil || !s.IsEmpty() || s.ThreadID != "ops" {
+		t.Fatalf("empty settings = %+v, %v", s, err)
+	}
+	for _, bad := range []ThreadSettings{
+		{Channel: "talk", ThreadID: "ops", Verbosity: "chatty"},
+		{Channel: "talk", ThreadID: "ops", Addressing: "never"},
+		{Channel: "talk", ThreadID: "ops", PromptAddendum: strings.Repeat("x", MaxPromptAddendum+1)},
+		{Channel: "", ThreadID: "ops", Verbosity: "brief"},
+	} {
+		if err := db.SetThreadSettings(ctx, &bad); err == nil {
+			t.Errorf("accepted %+v", bad)
+		}
+	}
+
+	set := &ThreadSettings{Channel: "talk", ThreadID: "ops", PromptAddendum: "Homelab ops.", AllowedTools: []string{"read_logs"}, Verbosity: "brief", Addressing: "mention", UpdatedBy: "admin"}
+	if err := db.SetThreadSettings(ctx, set); err != nil {
+		t.Fatal(err)
+	}
+	s, err = db.GetThreadSettings(ctx, "talk", "ops")
+	if err != nil || s.PromptAddendum != "Homelab ops." || len(s.AllowedTools) != 1 || s.Verbosity != "brief" || s.Addressing != "mention" || s.UpdatedBy != "admin" {
+		t.Fatalf("settings = %+v, %v", s, err)
+	}
+	// The same thread ID on another channel is another thread
+	if other, _ := db.GetThreadSettings(ctx, "matrix", "ops"); !other.IsEmpty() {
+		t.Errorf("settings leaked to another channel: %+v", other)
+	}
+	if all, err := db.ListThreadSettings(ctx); err != nil || len(all) != 1 {
+		t.Fatalf("list = %+v, %v", all, err)
+	}
+
+	// Clearing every field removes the row
+	if err := db.SetThreadSettings(ctx, &ThreadSettings{Channel: "talk", ThreadID: "ops"}); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := db.ListThreadSettings(ctx); len(all) != 0 {
+		t.Errorf("cleared settings still listed: %+v", all)
+	}
+}
+
+func TestThreadParticipants(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, sender := range []string{"alice", "bob", "alice"} {
+		if _, err := db.InsertMessage(ctx, "user", "hi", "", sender, "talk", "room", "", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.InsertMessage(ctx, "assistant", "hello", "", "", "talk", "room", "", "", "")
+	db.InsertMessage(ctx, "user", "hi", "", "carol", "talk", "other", "", "", "")
+
+	got, err := db.ThreadParticipants(ctx, "talk", "room", 50)
+	if err != nil || strings.Join(got, ",") != "alice,bob" {
+		t.Fatalf("participants = %v, %v", got, err)
+	}
+}
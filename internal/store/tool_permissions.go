@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Tool permission subject types.
+const (
+	PermissionSubjectUser       = "user"
+	PermissionSubjectTrustLevel = "trust_level"
+)
+
+// ToolPermission grants a user or everyone at a trust level one tool (Tool) or every tool with a
+// policy (Policy), optionally confined to WorkDir.
+type ToolPermission struct {
+	ID          int64     `json:"id"`
+	SubjectType string    `json:"subject_type"`
+	Subject     string    `json:"subject"`
+	Tool        string    `json:"tool,omitempty"`
+	Policy      string    `json:"policy,omitempty"`
+	WorkDir     string    `json:"work_dir,omitempty"`
+	GrantedBy   string    `json:"granted_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GrantToolPermission creates a grant, or updates the work_dir of an identical existing grant. Returns its id.
+func (db *DB) GrantToolPermission(ctx context.Context, p ToolPermission) (int64, error) {
+	if p.SubjectType != PermissionSubjectUser && p.SubjectType != PermissionSubjectTrustLevel {
+		return 0, fmt.Errorf("invalid subject_type %q (use user or trust_level)", p.SubjectType)
+	}
+	if p.Subject == "" || (p.Tool == "") == (p.Policy == "") {
+		return 0, fmt.Errorf("subject and exactly one of tool or policy are required")
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO tool_permissions (subject_type, subject, tool, policy, work_dir, granted_by) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(subject_type, subject, tool, policy) DO UPDATE SET work_dir=excluded.work_dir, granted_by=excluded.granted_by`,
+		p.SubjectType, p.Subject, p.Tool, p.Policy, p.WorkDir, p.GrantedBy,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = db.QueryRowContext(ctx,
+		`SELECT id FROM tool_permissions WHERE subject_type = ? AND subject = ? AND tool = ? AND policy = ?`,
+		p.SubjectType, p.Subject, p.Tool, p.Policy,
+	).Scan(&id)
+	return id, err
+}
+
+// RevokeToolPermission deletes a grant by id.
+func (db *DB) RevokeToolPermission(ctx context.Context, id int64) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM tool_permissions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("permission %d not found", id)
+	}
+	return nil
+}
+
+// ListToolPermissions returns grants, optionally filtered by subject ("" = all).
+func (db *DB) ListToolPermissions(ctx context.Context, subjectType, subject string) ([]ToolPermission, error) {
+	query := `SELECT id, subject_type, subject, tool, policy, work_dir, COALESCE(granted_by, ''), created_at FROM tool_permissions WHERE 1=1`
+	var args []interface{}
+	if subjectType != "" {
+		query += ` AND subject_type = ?`
+		args = append(args, subjectType)
+	}
+	if subject != "" {
+		query += ` AND subject = ?`
+		args = append(args, subject)
+	}
+	query += ` ORDER BY subject_type, subject, tool, policy`
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ToolPermission
+	for rows.Next() {
+		var p ToolPermission
+		if err := rows.Scan(&p.ID, &p.SubjectType, &p.Subject, &p.Tool, &p.Policy, &p.WorkDir, &p.GrantedBy, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// FindToolPermission returns the most specific grant letting userID (at trustLevel) run tool, which has
+// the given policy: a user grant beats a trust-level grant, and a tool grant beats a policy grant.
+// Returns nil, nil when nothing matches.
+func (db *DB) FindToolPermission(ctx context.Context, userID, trustLevel, tool, policy string) (*ToolPermission, error) {
+	var p ToolPermission
+	err := db.QueryRowContext(ctx,
+		`SELECT id, subject_type, subject, tool, policy, work_dir, COALESCE(granted_by, ''), created_at FROM tool_permissions
+		 WHERE ((subject_type = 'user' AND subject = ?) OR (subject_type = 'trust_level' AND subject = ?))
+		   AND (tool = ? OR (tool = '' AND policy = ?))
+		 ORDER BY CASE subject_type WHEN 'user' THEN 0 ELSE 1 END, CASE WHEN tool = '' THEN 1 ELSE 0 END
+		 LIMIT 1`,
+		userID, trustLevel, tool, policy,
+	).Scan(&p.ID, &p.SubjectType, &p.Subject, &p.Tool, &p.Policy, &p.WorkDir, &p.GrantedBy, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
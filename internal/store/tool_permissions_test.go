@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFindToolPermissionPrefersMostSpecificGrant(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	grants := []ToolPermission{
+		{SubjectType: PermissionSubjectTrustLevel, Subject: "trusted", Policy: "restricted"},
+		{SubjectType: PermissionSubjectUser, Subject: "bob", Tool: "run_terminal_cmd", WorkDir: "/workspace/bob"},
+	}
+	for _, g := range grants {
+		if _, err := db.GrantToolPermission(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.GrantToolPermission(ctx, ToolPermission{SubjectType: PermissionSubjectUser, Subject: "bob", Tool: "x", Policy: "restricted"}); err == nil {
+		t.Error("expected error when both tool and policy are set")
+	}
+
+	p, err := db.FindToolPermission(ctx, "bob", "trusted", "run_terminal_cmd", "restricted")
+	if err != nil || p == nil || p.WorkDir != "/workspace/bob" {
+		t.Fatalf("bob: got %+v, %v", p, err)
+	}
+	p, err = db.FindToolPermission(ctx, "carol", "trusted", "run_terminal_cmd", "restricted")
+	if err != nil || p == nil || p.Policy != "restricted" || p.WorkDir != "" {
+		t.Fatalf("carol: got %+v, %v", p, err)
+	}
+	p, err = db.FindToolPermission(ctx, "dave", "guest", "run_terminal_cmd", "restricted")
+	if err != nil || p != nil {
+		t.Fatalf("dave: got %+v, %v", p, err)
+	}
+
+	// Re-granting updates the existing row instead of duplicating it.
+	id1, _ := db.GrantToolPermission(ctx, ToolPermission{SubjectType: PermissionSubjectUser, Subject: "bob", Tool: "run_terminal_cmd", WorkDir: "/workspace/bob2"})
+	perms, _ := db.ListToolPermissions(ctx, PermissionSubjectUser, "bob")
+	if len(perms) != 1 || perms[0].ID != id1 || perms[0].WorkDir != "/workspace/bob2" {
+		t.Fatalf("unexpected grants after update: %+v", perms)
+	}
+	if err := db.RevokeToolPermission(ctx, id1); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Tool repair statuses.
+const (
+	RepairRunning = "running"
+	RepairFixed   = "fixed"
+	RepairFailed  = "failed"
+)
+
+// ToolRepair is one automatic repair attempt of a broken tool version.
+type ToolRepair struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	FromVersion int        `json:"from_version"`
+	ToVersion   int        `json:"to_version,omitempty"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Result      string     `json:"result,omitempty"`
+	Diff        string     `json:"diff,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// RecordToolFailedInput stores the arguments of a tool's latest failing call.
+func (db *DB) RecordToolFailedInput(ctx context.Context, name, input string) error {
+	_, err := db.ExecContext(ctx, `UPDATE tools_registry SET last_failed_input = ? WHERE name = ?`, input, name)
+	return err
+}
+
+// ToolFailedInput returns the arguments of a tool's latest failing call ("" if none recorded).
+func (db *DB) ToolFailedInput(ctx context.Context, name string) (string, error) {
+	var input sql.NullString
+	err := db.QueryRowContext(ctx, `SELECT last_failed_input FROM tools_registry WHERE name = ?`, name).Scan(&input)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return input.String, err
+}
+
+// SetToolStatus sets a registered tool's status (active, broken, pending_repair, deprecated).
+func (db *DB) SetToolStatus(ctx context.Context, name, status string) error {
+	_, err := db.ExecContext(ctx, `UPDATE tools_registry SET status = ? WHERE name = ?`, status, name)
+	return err
+}
+
+// StartToolRepair records the start of a repair attempt and returns its ID.
+func (db *DB) StartToolRepair(ctx context.Context, name string, fromVersion int, toolErr string) (int64, error) {
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO tool_repairs (name, from_version, status, error) VALUES (?, ?, ?, ?)`,
+		name, fromVersion, RepairRunning, toolErr,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// FinishToolRepair stores the outcome of a running repair attempt (status fixed or failed).
+func (db *DB) FinishToolRepair(ctx context.Context, id int64, status string, toVersion int, result, diff string) error {
+	if status != RepairFixed && status != RepairFailed {
+		return fmt.Errorf("invalid repair status %q", status)
+	}
+	var to interface{}
+	if toVersion > 0 {
+		to = toVersion
+	}
+	_, err := db.ExecContext(ctx,
+		`UPDATE tool_repairs SET status = ?, to_version = ?, result = ?, diff = ?, finished_at = ? WHERE id = ? AND status = ?`,
+		status, to, result, diff, time.Now(), id, RepairRunning,
+	)
+	return err
+}
+
+// CountToolRepairs returns how many repairs were attempted for a tool version.
+func (db *DB) CountToolRepairs(ctx context.Context, name string, fromVersion int) (int, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tool_repairs WHERE name = ? AND from_version = ?`, name, fromVersion).Scan(&n)
+	return n, err
+}
+
+// ListToolRepairs returns repair attempts for a tool ("" = all tools), newest first.
+func (db *DB) ListToolRepairs(ctx context.Context, name string, limit int) ([]ToolRepair, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, name, from_version, COALESCE(to_version, 0), status, COALESCE(error, ''), COALESCE(result, ''), COALESCE(diff, ''), started_at, finished_at
+		 FROM tool_repairs WHERE ? = '' OR name = ? ORDER BY id DESC LIMIT ?`, name, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ToolRepair
+	for rows.Next() {
+		var r ToolRepair
+		var finished sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Name, &r.FromVersion, &r.ToVersion, &r.Status, &r.Error, &r.Result, &r.Diff, &r.StartedAt, &finished); err != nil {
+			return nil, err
+		}
+		if finished.Valid {
+			r.FinishedAt = &finished.Time
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
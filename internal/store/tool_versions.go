@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// ToolVersion is one registered build of a tool, kept in tool_versions for rollback.
+type ToolVersion struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Version     int       `json:"version"`
+	BinaryPath  string    `json:"binary_path"`
+	SourceHash  string    `json:"source_hash,omitempty"`
+	Description string    `json:"description"`
+	InputSchema string    `json:"input_schema,omitempty"`
+	SourceDir   string    `json:"source_dir,omitempty"`
+	GitCommit   string    `json:"git_commit,omitempty"`
+	GitDirty    bool      `json:"git_dirty,omitempty"`
+	HasSource   bool      `json:"has_source"`
+	CreatedAt   time.Time `json:"created_at"`
+	Current     bool      `json:"current"`
+	// Source is the tool's Go files at registration; only loaded by ToolVersionByNumber.
+	Source []SourceFile `json:"-"`
+}
+
+// SourceFile is one source file stored with a tool version.
+type SourceFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// AddToolVersion records a tool version with its source provenance.
+func (db *DB) AddToolVersion(ctx context.Context, v ToolVersion) error {
+	var source interface{}
+	if len(v.Source) > 0 {
+		b, err := json.Marshal(v.Source)
+		if err != nil {
+			return err
+		}
+		source = string(b)
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO tool_versions (name, version, binary_path, source_hash, description, input_schema, source_dir, source, git_commit, git_dirty)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		v.Name, v.Version, v.BinaryPath, v.SourceHash, v.Description, v.InputSchema, v.SourceDir, source, v.GitCommit, v.GitDirty,
+	)
+	return err
+}
+
+// ListToolVersions returns a tool's recorded versions, newest first, marking the registry's current one.
+func (db *DB) ListToolVersions(ctx context.Context, name string) ([]ToolVersion, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT v.id, v.name, v.version, v.binary_path, COALESCE(v.source_hash, ''), COALESCE(v.description, ''), COALESCE(v.input_schema, ''),
+		        COALESCE(v.source_dir, ''), COALESCE(v.git_commit, ''), COALESCE(v.git_dirty, 0), v.source IS NOT NULL, v.created_at,
+		        COALESCE(r.version, 1) = v.version
+		 FROM tool_versions v LEFT JOIN tools_registry r ON r.name = v.name
+		 WHERE v.name = ? ORDER BY v.version DESC`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ToolVersion
+	for rows.Next() {
+		var v ToolVersion
+		if err := rows.Scan(&v.ID, &v.Name, &v.Version, &v.BinaryPath, &v.SourceHash, &v.Description, &v.InputSchema,
+			&v.SourceDir, &v.GitCommit, &v.GitDirty, &v.HasSource, &v.CreatedAt, &v.Current); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// ToolVersionByNumber returns one version of a tool, including its stored source, or nil if it is
+// not recorded.
+func (db *DB) ToolVersionByNumber(ctx context.Context, name string, version int) (*ToolVersion, error) {
+	var v ToolVersion
+	var source sql.NullString
+	err := db.QueryRowContext(ctx,
+		`SELECT id, name, version, binary_path, COALESCE(source_hash, ''), COALESCE(description, ''), COALESCE(input_schema, ''),
+		        COALESCE(source_dir, ''), source, COALESCE(git_commit, ''), COALESCE(git_dirty, 0), created_at
+		 FROM tool_versions WHERE name = ? AND version = ?`, name, version,
+	).Scan(&v.ID, &v.Name, &v.Version, &v.BinaryPath, &v.SourceHash, &v.Description, &v.InputSchema,
+		&v.SourceDir, &source, &v.GitCommit, &v.GitDirty, &v.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if source.Valid {
+		v.HasSource = true
+		if err := json.Unmarshal([]byte(source.String), &v.Source); err != nil {
+			return nil, err
+		}
+	}
+	return &v, nil
+}
+
+// LatestToolVersion returns the highest version number recorded for a tool (0 if none).
+func (db *DB) LatestToolVersion(ctx context.Context, name string) (int, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM tool_versions WHERE name = ?`, name).Scan(&n)
+	return n, err
+}
+
+// SetToolVersion points a registered tool at a version: binary, description, schema, version
+// number, and source hash, with previousBinary recorded for rollback. Health is reset so the
+// version starts with a clean failure count, and an HTTP tool becomes a binary one again.
+func (db *DB) SetToolVersion(ctx context.Context, v ToolVersion, previousBinary string) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE tools_registry SET binary_path = ?, description = ?, input_schema = ?, version = ?, source_hash = ?, previous_binary_path = ?,
+		        status = 'active', failure_count = 0, last_error = NULL, kind = 'binary', url = '', auth_header = '', auth_secret = ''
+		 WHERE name = ?`,
+		v.BinaryPath, v.Description, v.InputSchema, v.Version, v.SourceHash, previousBinary, v.Name,
+	)
+	return err
+}
+
+// PruneToolVersions deletes all but the keep newest versions older than the current one and
+// returns the removed versions so their archived binaries can be deleted. Newer versions (left
+// behind by a rollback) are kept.
+func (db *DB) PruneToolVersions(ctx context.Context, name string, current, keep int) ([]ToolVersion, error) {
+	versions, err := db.ListToolVersions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var removed []ToolVersion
+	older := 0
+	for _, v := range versions {
+		if v.Version >= current {
+			continue
+		}
+		older++
+		if older <= keep {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `DELETE FROM tool_versions WHERE id = ?`, v.ID); err != nil {
+			return removed, err
+		}
+		removed = append(removed, v)
+	}
+	return removed, nil
+}
@@ -18,36 +18,39 @@ type RegisteredTool struct {
 	LastSuccess  *time.Time `json:"last_success,omitempty"`
 	FailureCount int       `json:"failure_count"`
 	LastError    string     `json:"last_error,omitempty"`
+	Version      int        `json:"version"`
+	SourceHash   string     `json:"source_hash,omitempty"`
+	// PreviousBinaryPath is the archived binary of the version this one replaced ("" for the first).
+	PreviousBinaryPath string `json:"previous_binary_path,omitempty"`
+	// Kind is ToolKindBinary (run BinaryPath) or ToolKindHTTP (POST the arguments to URL).
+	Kind string `json:"kind"`
+	URL  string `json:"url,omitempty"`
+	// AuthHeader is the header sent to an HTTP tool, e.g. "Authorization: Bearer {secret}", with
+	// {secret} replaced by AuthSecret resolved from the secret store at call time.
+	AuthHeader string `json:"auth_header,omitempty"`
+	AuthSecret string `json:"auth_secret,omitempty"`
 }
 
-// InsertTool inserts a tool and returns its id. New tools get status 'active' and failure_count 0.
-func (db *DB) InsertTool(ctx context.Context, name, binaryPath, description, inputSchema string) (int64, error) {
-	res, err := db.ExecContext(ctx,
-		`INSERT INTO tools_registry (name, binary_path, description, input_schema, status, failure_count) VALUES (?, ?, ?, ?, 'active', 0)`,
-		name, binaryPath, description, inputSchema,
-	)
-	if err != nil {
-		return 0, err
-	}
-	return res.LastInsertId()
-}
+// Tool kinds.
+const (
+	ToolKindBinary = "binary"
+	ToolKindHTTP   = "http"
+)
 
-// ToolByName returns the tool with the given name, or nil if not found.
-func (db *DB) ToolByName(ctx context.Context, name string) (*RegisteredTool, error) {
+// toolColumns are the tools_registry columns scanTool reads.
+const toolColumns = `id, name, binary_path, description, input_schema, created_at, status, last_success, failure_count, last_error, version, source_hash, previous_binary_path, kind, url, auth_header, auth_secret`
+
+// scanTool reads a row selected with toolColumns.
+func scanTool(row interface{ Scan(...interface{}) error }) (*RegisteredTool, error) {
 	var t RegisteredTool
 	var inputSchema sql.NullString
 	var lastSuccess sql.NullTime
 	var status sql.NullString
 	var failureCount sql.NullInt64
 	var lastError sql.NullString
-	err := db.QueryRowContext(ctx,
-		`SELECT id, name, binary_path, description, input_schema, created_at, status, last_success, failure_count, last_error FROM tools_registry WHERE name = ?`,
-		name,
-	).Scan(&t.ID, &t.Name, &t.BinaryPath, &t.Description, &inputSchema, &t.CreatedAt, &status, &lastSuccess, &failureCount, &lastError)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
+	var version sql.NullInt64
+	var sourceHash, previousBinary sql.NullString
+	if err := row.Scan(&t.ID, &t.Name, &t.BinaryPath, &t.Description, &inputSchema, &t.CreatedAt, &status, &lastSuccess, &failureCount, &lastError, &version, &sourceHash, &previousBinary, &t.Kind, &t.URL, &t.AuthHeader, &t.AuthSecret); err != nil {
 		return nil, err
 	}
 	if inputSchema.Valid {
@@ -67,52 +70,79 @@ func (db *DB) ToolByName(ctx context.Context, name string) (*RegisteredTool, err
 	if lastError.Valid {
 		t.LastError = lastError.String
 	}
+	t.Version = int(version.Int64)
+	if t.Version == 0 {
+		t.Version = 1
+	}
+	t.SourceHash, t.PreviousBinaryPath = sourceHash.String, previousBinary.String
 	return &t, nil
 }
 
+// InsertTool inserts a tool and returns its id. New tools get status 'active' and failure_count 0.
+func (db *DB) InsertTool(ctx context.Context, name, binaryPath, description, inputSchema string) (int64, error) {
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO tools_registry (name, binary_path, description, input_schema, status, failure_count) VALUES (?, ?, ?, ?, 'active', 0)`,
+		name, binaryPath, description, inputSchema,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// SaveHTTPTool registers an HTTP tool, or turns the existing tool of that name into one at
+// t.Version. Health is reset as for a new binary version.
+func (db *DB) SaveHTTPTool(ctx context.Context, t RegisteredTool) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO tools_registry (name, binary_path, description, input_schema, status, failure_count, version, kind, url, auth_header, auth_secret)
+		 VALUES (?, '', ?, ?, 'active', 0, ?, 'http', ?, ?, ?)
+		 ON CONFLICT(name) DO UPDATE SET binary_path = '', description = excluded.description, input_schema = excluded.input_schema,
+		        status = 'active', failure_count = 0, last_error = NULL, version = excluded.version, source_hash = NULL,
+		        kind = 'http', url = excluded.url, auth_header = excluded.auth_header, auth_secret = excluded.auth_secret`,
+		t.Name, t.Description, t.InputSchema, t.Version, t.URL, t.AuthHeader, t.AuthSecret,
+	)
+	return err
+}
+
+// ToolByName returns the tool with the given name, or nil if not found.
+func (db *DB) ToolByName(ctx context.Context, name string) (*RegisteredTool, error) {
+	t, err := scanTool(db.QueryRowContext(ctx, `SELECT `+toolColumns+` FROM tools_registry WHERE name = ?`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
 // AllTools returns all registered tools.
 func (db *DB) AllTools(ctx context.Context) ([]RegisteredTool, error) {
-	rows, err := db.QueryContext(ctx,
-		`SELECT id, name, binary_path, description, input_schema, created_at, status, last_success, failure_count, last_error FROM tools_registry ORDER BY name`)
+	return db.queryTools(ctx, `SELECT `+toolColumns+` FROM tools_registry ORDER BY name`)
+}
+
+func (db *DB) queryTools(ctx context.Context, query string, args ...interface{}) ([]RegisteredTool, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var out []RegisteredTool
 	for rows.Next() {
-		var t RegisteredTool
-		var inputSchema sql.NullString
-		var lastSuccess sql.NullTime
-		var status sql.NullString
-		var failureCount sql.NullInt64
-		var lastError sql.NullString
-		if err := rows.Scan(&t.ID, &t.Name, &t.BinaryPath, &t.Description, &inputSchema, &t.CreatedAt, &status, &lastSuccess, &failureCount, &lastError); err != nil {
+		t, err := scanTool(rows)
+		if err != nil {
 			return nil, err
 		}
-		if inputSchema.Valid {
-			t.InputSchema = inputSchema.String
-		}
-		if status.Valid {
-			t.Status = status.String
-		} else {
-			t.Status = "active"
-		}
-		if lastSuccess.Valid {
-			t.LastSuccess = &lastSuccess.Time
-		}
-		if failureCount.Valid {
-			t.FailureCount = int(failureCount.Int64)
-		}
-		if lastError.Valid {
-			t.LastError = lastError.String
-		}
-		out = append(out, t)
+		out = append(out, *t)
 	}
 	return out, rows.Err()
 }
 
-// DeleteTool removes a tool by name.
+// DeleteTool removes a tool and its version and repair history by name.
 func (db *DB) DeleteTool(ctx context.Context, name string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM tool_versions WHERE name = ?", name); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM tool_repairs WHERE name = ?", name); err != nil {
+		return err
+	}
 	_, err := db.ExecContext(ctx, "DELETE FROM tools_registry WHERE name = ?", name)
 	return err
 }
@@ -144,41 +174,7 @@ func (db *DB) RecordToolFailure(ctx context.Context, name, errMsg string) error
 
 // ListBrokenTools returns tools with status = 'broken' for the repair queue.
 func (db *DB) ListBrokenTools(ctx context.Context) ([]RegisteredTool, error) {
-	rows, err := db.QueryContext(ctx,
-		`SELECT id, name, binary_path, description, input_schema, created_at, status, last_success, failure_count, last_error FROM tools_registry WHERE status = 'broken' ORDER BY name`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var out []RegisteredTool
-	for rows.Next() {
-		var t RegisteredTool
-		var inputSchema sql.NullString
-		var lastSuccess sql.NullTime
-		var status sql.NullString
-		var failureCount sql.NullInt64
-		var lastError sql.NullString
-		if err := rows.Scan(&t.ID, &t.Name, &t.BinaryPath, &t.Description, &inputSchema, &t.CreatedAt, &status, &lastSuccess, &failureCount, &lastError); err != nil {
-			return nil, err
-		}
-		if inputSchema.Valid {
-			t.InputSchema = inputSchema.String
-		}
-		if status.Valid {
-			t.Status = status.String
-		}
-		if lastSuccess.Valid {
-			t.LastSuccess = &lastSuccess.Time
-		}
-		if failureCount.Valid {
-			t.FailureCount = int(failureCount.Int64)
-		}
-		if lastError.Valid {
-			t.LastError = lastError.String
-		}
-		out = append(out, t)
-	}
-	return out, rows.Err()
+	return db.queryTools(ctx, `SELECT `+toolColumns+` FROM tools_registry WHERE status = 'broken' ORDER BY name`)
 }
 
 // ToolRegistry interface for dependency injection.
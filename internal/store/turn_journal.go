@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Turn journal states: where a turn was when it was last journaled.
+const (
+	TurnThinking = "thinking" // waiting on the model
+	TurnTools    = "tools"    // running the tool calls the model asked for
+	TurnReplying = "replying" // the reply is written; saving and sending it
+)
+
+// JournalToolCall is one tool call of a journaled turn. Args are redacted and shortened.
+type JournalToolCall struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Args   string `json:"args,omitempty"`
+	Done   bool   `json:"done"`
+	Failed bool   `json:"failed,omitempty"`
+}
+
+// JournaledTurn is a turn in flight, or one a crash interrupted.
+type JournaledTurn struct {
+	ID        int64             `json:"id"`
+	UserID    string            `json:"user_id"`
+	Channel   string            `json:"channel"`
+	ThreadID  string            `json:"thread_id"`
+	Content   string            `json:"content"`
+	State     string            `json:"state"`
+	ToolCalls []JournalToolCall `json:"tool_calls"`
+	StartedAt time.Time         `json:"started_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// StartTurn journals a new turn in state thinking and returns its ID.
+func (db *DB) StartTurn(ctx context.Context, userID, channel, threadID, content string) (int64, error) {
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO turn_journal (user_id, channel, thread_id, content, state) VALUES (?, ?, ?, ?, ?)`,
+		userID, channel, threadID, content, TurnThinking,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// SetTurnState records the state a turn moved to.
+func (db *DB) SetTurnState(ctx context.Context, id int64, state string) error {
+	_, err := db.ExecContext(ctx, `UPDATE turn_journal SET state = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, state, id)
+	return err
+}
+
+// JournalToolCalls adds calls, not yet run, to a turn and moves it to state tools.
+func (db *DB) JournalToolCalls(ctx context.Context, id int64, calls []JournalToolCall) error {
+	return db.updateTurnTools(ctx, id, func(tcs []JournalToolCall) []JournalToolCall {
+		return append(tcs, calls...)
+	})
+}
+
+// JournalToolDone marks a turn's tool call as run.
+func (db *DB) JournalToolDone(ctx context.Context, id int64, callID string, failed bool) error {
+	return db.updateTurnTools(ctx, id, func(tcs []JournalToolCall) []JournalToolCall {
+		for i := range tcs {
+			if tcs[i].ID == callID && !tcs[i].Done {
+				tcs[i].Done, tcs[i].Failed = true, failed
+				break
+			}
+		}
+		return tcs
+	})
+}
+
+// updateTurnTools rewrites a turn's tool calls; turns in one thread run one at a time, so the
+// read and write do not race.
+func (db *DB) updateTurnTools(ctx context.Context, id int64, update func([]JournalToolCall) []JournalToolCall) error {
+	var raw string
+	if err := db.QueryRowContext(ctx, `SELECT tool_calls FROM turn_journal WHERE id = ?`, id).Scan(&raw); err != nil {
+		return err
+	}
+	var tcs []JournalToolCall
+	_ = json.Unmarshal([]byte(raw), &tcs)
+	b, err := json.Marshal(update(tcs))
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		`UPDATE turn_journal SET tool_calls = ?, state = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		string(b), TurnTools, id,
+	)
+	return err
+}
+
+// FinishTurn removes a turn from the journal; the journal only holds turns in flight.
+func (db *DB) FinishTurn(ctx context.Context, id int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM turn_journal WHERE id = ?`, id)
+	return err
+}
+
+// JournaledTurns returns the turns in the journal, oldest first. At startup these are the turns
+// the previous process did not finish.
+func (db *DB) JournaledTurns(ctx context.Context) ([]JournaledTurn, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, user_id, channel, thread_id, content, state, tool_calls, started_at, updated_at
+		 FROM turn_journal ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []JournaledTurn
+	for rows.Next() {
+		var t JournaledTurn
+		var raw string
+		var started, updated sql.NullTime
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Channel, &t.ThreadID, &t.Content, &t.State, &raw, &started, &updated); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(raw), &t.ToolCalls)
+		t.StartedAt, t.UpdatedAt = started.Time, updated.Time
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestTurnJournal(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	id, err := db.StartTurn(ctx, "alice", "talk", "room", "back up and clean the disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := []JournalToolCall{{ID: "c1", Name: "backup_now"}, {ID: "c2", Name: "run_terminal_cmd", Args: `{"command":"rm -rf /tmp/x"}`}}
+	if err := db.JournalToolCalls(ctx, id, calls); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.JournalToolDone(ctx, id, "c1", false); err != nil {
+		t.Fatal(err)
+	}
+	turns, err := db.JournaledTurns(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(turns) != 1 || turns[0].State != TurnTools || turns[0].UserID != "alice" || turns[0].StartedAt.IsZero() {
+		t.Fatalf("turns = %+v", turns)
+	}
+	tcs := turns[0].ToolCalls
+	if len(tcs) != 2 || !tcs[0].Done || tcs[1].Done || tcs[1].Args != calls[1].Args {
+		t.Fatalf("tool calls = %+v", tcs)
+	}
+
+	if err := db.SetTurnState(ctx, id, TurnReplying); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.FinishTurn(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if turns, _ := db.JournaledTurns(ctx); len(turns) != 0 {
+		t.Fatalf("finished turn still journaled: %+v", turns)
+	}
+}
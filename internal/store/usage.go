@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+)
+
+// UsageRecord is one LLM call's token/cost usage with its attribution.
+type UsageRecord struct {
+	ID               int64     `json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UserID           string    `json:"user_id"`
+	ThreadID         string    `json:"thread_id,omitempty"`
+	JobID            int64     `json:"job_id,omitempty"`  // 0 = not attributed to a job
+	PlanID           int64     `json:"plan_id,omitempty"` // 0 = not triggered by a scheduled plan
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+}
+
+// UsageSummary is aggregated usage for one group (job, plan, model, or user).
+type UsageSummary struct {
+	Key              string  `json:"key"`
+	Label            string  `json:"label,omitempty"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// InsertUsage records one LLM call. JobID/PlanID of 0 are stored as NULL.
+func (db *DB) InsertUsage(ctx context.Context, r UsageRecord) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO llm_usage (user_id, thread_id, job_id, plan_id, model, prompt_tokens, completion_tokens, cost_usd) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.UserID, r.ThreadID, nullInt64(r.JobID), nullInt64(r.PlanID), r.Model, r.PromptTokens, r.CompletionTokens, r.CostUSD,
+	)
+	return err
+}
+
+// UsageRecorder returns a core.UsageRecorder that stores each LLM call with the given attribution.
+// Attach it with core.WithUsageRecorder so nested calls (tools, sub-minds) are attributed too.
+func (db *DB) UsageRecorder(userID, threadID string, jobID, planID int64) core.UsageRecorder {
+	return func(ctx context.Context, u core.Usage) {
+		err := db.InsertUsage(context.WithoutCancel(ctx), UsageRecord{
+			UserID:           userID,
+			ThreadID:         threadID,
+			JobID:            jobID,
+			PlanID:           planID,
+			Model:            u.Model,
+			PromptTokens:     u.PromptTokens,
+			CompletionTokens: u.CompletionTokens,
+			CostUSD:          u.CostUSD,
+		})
+		if err != nil {
+			log.Printf("[USAGE] Failed to record usage: %v", err)
+		}
+	}
+}
+
+// SummarizeUsage aggregates usage since the given time, grouped by "job", "plan", "model", or "user".
+// Rows without the grouping attribute (e.g. no job) are reported under key "none".
+func (db *DB) SummarizeUsage(ctx context.Context, groupBy string, since time.Time) ([]UsageSummary, error) {
+	var query string
+	switch groupBy {
+	case "job":
+		query = `SELECT COALESCE(CAST(u.job_id AS TEXT), 'none'), COALESCE(j.title, ''), COUNT(*), SUM(u.prompt_tokens), SUM(u.completion_tokens), SUM(u.cost_usd)
+		         FROM llm_usage u LEFT JOIN jobs j ON j.id = u.job_id WHERE u.created_at >= ? GROUP BY u.job_id ORDER BY SUM(u.cost_usd) DESC`
+	case "plan":
+		query = `SELECT COALESCE(CAST(u.plan_id AS TEXT), 'none'), COALESCE(p.description, ''), COUNT(*), SUM(u.prompt_tokens), SUM(u.completion_tokens), SUM(u.cost_usd)
+		         FROM llm_usage u LEFT JOIN scheduled_plans p ON p.id = u.plan_id WHERE u.created_at >= ? GROUP BY u.plan_id ORDER BY SUM(u.cost_usd) DESC`
+	case "model":
+		query = `SELECT COALESCE(model, 'none'), '', COUNT(*), SUM(prompt_tokens), SUM(completion_tokens), SUM(cost_usd)
+		         FROM llm_usage WHERE created_at >= ? GROUP BY model ORDER BY SUM(cost_usd) DESC`
+	case "user":
+		query = `SELECT user_id, '', COUNT(*), SUM(prompt_tokens), SUM(completion_tokens), SUM(cost_usd)
+		         FROM llm_usage WHERE created_at >= ? GROUP BY user_id ORDER BY SUM(cost_usd) DESC`
+	default:
+		return nil, fmt.Errorf("group_by must be job, plan, model, or user")
+	}
+	// created_at is CURRENT_TIMESTAMP (UTC text), so compare against the same format.
+	rows, err := db.QueryContext(ctx, query, since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UsageSummary
+	for rows.Next() {
+		var s UsageSummary
+		if err := rows.Scan(&s.Key, &s.Label, &s.Calls, &s.PromptTokens, &s.CompletionTokens, &s.CostUSD); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// JobCost returns the total cost attributed to a job.
+func (db *DB) JobCost(ctx context.Context, jobID int64) (float64, error) {
+	var cost sql.NullFloat64
+	err := db.QueryRowContext(ctx, `SELECT SUM(cost_usd) FROM llm_usage WHERE job_id = ?`, jobID).Scan(&cost)
+	return cost.Float64, err
+}
+
+func nullInt64(v int64) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+)
+
+func TestUsageRecorder_AttributesToJobAndPlan(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.GetOrCreateUser(ctx, "u1", "", "terminal"); err != nil {
+		t.Fatal(err)
+	}
+	jobID, err := db.CreateJob(ctx, "u1", "Email triage", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx = core.WithUsageRecorder(ctx, db.UsageRecorder("u1", "t1", jobID, 0))
+	core.RecordUsage(ctx, core.Usage{Model: "m", PromptTokens: 100, CompletionTokens: 20, CostUSD: 0.25})
+	core.RecordUsage(ctx, core.Usage{Model: "m", PromptTokens: 50, CompletionTokens: 10, CostUSD: 0.5})
+	planCtx := core.WithUsageRecorder(context.Background(), db.UsageRecorder("u1", "scheduler", 0, 7))
+	core.RecordUsage(planCtx, core.Usage{Model: "m", CostUSD: 1})
+
+	spent, err := db.JobCost(ctx, jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent != 0.75 {
+		t.Errorf("JobCost = %v, want 0.75", spent)
+	}
+
+	byJob, err := db.SummarizeUsage(ctx, "job", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byJob) != 2 {
+		t.Fatalf("expected 2 job groups (job + none), got %+v", byJob)
+	}
+	if byJob[0].Key != "none" || byJob[0].CostUSD != 1 {
+		t.Errorf("first group: %+v", byJob[0])
+	}
+	if byJob[1].Label != "Email triage" || byJob[1].Calls != 2 || byJob[1].PromptTokens != 150 {
+		t.Errorf("job group: %+v", byJob[1])
+	}
+
+	byPlan, err := db.SummarizeUsage(ctx, "plan", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, g := range byPlan {
+		if g.Key == "7" && g.CostUSD == 1 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("plan 7 not in summary: %+v", byPlan)
+	}
+
+	if _, err := db.SummarizeUsage(ctx, "bogus", time.Time{}); err == nil {
+		t.Error("expected error for unknown group_by")
+	}
+}
+
+func TestSetJobBudget(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.GetOrCreateUser(ctx, "u1", "", "terminal"); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := db.CreateJob(ctx, "u1", "Job", "")
+	if err := db.SetJobBudget(ctx, id, 2.5); err != nil {
+		t.Fatal(err)
+	}
+	j, err := db.GetJob(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j == nil || j.BudgetUSD == nil || *j.BudgetUSD != 2.5 {
+		t.Fatalf("budget not set: %+v", j)
+	}
+	if err := db.SetJobBudget(ctx, id, 0); err != nil {
+		t.Fatal(err)
+	}
+	j, _ = db.GetJob(ctx, id)
+	if j.BudgetUSD != nil {
+		t.Errorf("budget should be cleared, got %v", *j.BudgetUSD)
+	}
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 )
 
@@ -18,6 +19,50 @@ type User struct {
 	LastSeen   time.Time `json:"last_seen"`
 }
 
+// User roles, most privileged first. The owner (config admin_user_id) manages admins; admins may
+// use admin_only tools; operators may use operator tools (e.g. list_users) without full admin rights.
+const (
+	RoleOwner    = "owner"
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleUser     = "user"
+)
+
+var roleRank = map[string]int{RoleOwner: 3, RoleAdmin: 2, RoleOperator: 1, RoleUser: 0}
+
+// RoleAtLeast reports whether role grants at least the privileges of min. Unknown roles rank as user.
+func RoleAtLeast(role, min string) bool {
+	return roleRank[role] >= roleRank[min]
+}
+
+// WithoutAdmin returns a copy of u with at most operator rights, as a user acts through an API
+// token without the admin scope.
+func (u *User) WithoutAdmin() *User {
+	c := *u
+	if RoleAtLeast(c.Role, RoleAdmin) {
+		c.Role = RoleOperator
+	}
+	if c.TrustLevel == "admin" {
+		c.TrustLevel = "trusted"
+	}
+	return &c
+}
+
+// PolicyMinRole maps role-gated tool policies to the minimum user role allowed to run them without
+// a grant. Restricted tools need the admin role when per-user grants are enabled.
+var PolicyMinRole = map[string]string{
+	"owner_only": RoleOwner,
+	"admin_only": RoleAdmin,
+	"operator":   RoleOperator,
+	"restricted": RoleAdmin,
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
 // GetOrCreateUser retrieves a user by ID, or creates one if not exists.
 func (db *DB) GetOrCreateUser(ctx context.Context, id, name, platform string) (*User, error) {
 	// Try to get
@@ -35,7 +80,7 @@ func (db *DB) GetOrCreateUser(ctx context.Context, id, name, platform string) (*
 	if name == "" {
 		name = "User " + id // Fallback name
 	}
-	role := "user" // Default role
+	role := RoleUser // Default role
 	trustLevel := "trusted"
 	if platform == "nextcloud_talk" {
 		trustLevel = "restricted" // New Nextcloud users require admin approval
@@ -75,3 +120,70 @@ func (db *DB) UpdateUserMetadata(ctx context.Context, id, metadata string) error
 	_, err := db.ExecContext(ctx, "UPDATE users SET metadata = ? WHERE id = ?", metadata, id)
 	return err
 }
+
+// UpdateUserRole sets a user's role. Owners and admins get trust level "admin"; demoting an admin
+// to operator or user drops their trust level to "trusted".
+func (db *DB) UpdateUserRole(ctx context.Context, id, role string) error {
+	var res sql.Result
+	var err error
+	if RoleAtLeast(role, RoleAdmin) {
+		res, err = db.ExecContext(ctx, "UPDATE users SET role = ?, trust_level = 'admin' WHERE id = ?", role, id)
+	} else {
+		res, err = db.ExecContext(ctx,
+			"UPDATE users SET role = ?, trust_level = CASE WHEN trust_level = 'admin' THEN 'trusted' ELSE trust_level END WHERE id = ?",
+			role, id)
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RecentUsers returns up to limit users, most recently seen first.
+func (db *DB) RecentUsers(ctx context.Context, limit int) ([]User, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, name, COALESCE(role, 'user'), platform, trust_level, COALESCE(metadata, ''), first_seen, last_seen FROM users ORDER BY last_seen DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Role, &u.Platform, &u.TrustLevel, &u.Metadata, &u.FirstSeen, &u.LastSeen); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// UsersWithRole returns the users holding any of roles, ordered by ID.
+func (db *DB) UsersWithRole(ctx context.Context, roles ...string) ([]User, error) {
+	if len(roles) == 0 {
+		return nil, nil
+	}
+	query := `SELECT id, name, role, platform, trust_level, COALESCE(metadata, ''), first_seen, last_seen FROM users WHERE role IN (?` +
+		strings.Repeat(", ?", len(roles)-1) + `) ORDER BY id`
+	args := make([]interface{}, len(roles))
+	for i, r := range roles {
+		args[i] = r
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Role, &u.Platform, &u.TrustLevel, &u.Metadata, &u.FirstSeen, &u.LastSeen); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
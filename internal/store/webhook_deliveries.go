@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Webhook delivery statuses.
+const (
+	DeliveryProcessing = "processing"
+	DeliveryOK         = "ok"
+	DeliveryFailed     = "failed"
+)
+
+// deliveryStale is how long a delivery may stay processing before a retry may claim it again
+// (the process died while running it).
+const deliveryStale = 10 * time.Minute
+
+// maxDeliveryResult caps the stored tool result, in runes.
+const maxDeliveryResult = 4000
+
+// WebhookDelivery is a processed (or in-progress) delivery of an idempotent webhook route.
+type WebhookDelivery struct {
+	RouteID    string    `json:"route_id"`
+	DeliveryID string    `json:"delivery_id"`
+	Status     string    `json:"status"`
+	Result     string    `json:"result,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// ClaimWebhookDelivery claims a delivery for processing. It returns claimed=true for a new
+// delivery, and for one whose earlier attempt failed or went stale. Otherwise it returns the
+// earlier delivery, which is a duplicate. Expired deliveries are dropped first.
+func (db *DB) ClaimWebhookDelivery(ctx context.Context, routeID, deliveryID string, ttl time.Duration) (*WebhookDelivery, bool, error) {
+	now := time.Now().UTC()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE expires_at < ?`, now); err != nil {
+		return nil, false, err
+	}
+	var d WebhookDelivery
+	err = tx.QueryRowContext(ctx,
+		`SELECT route_id, delivery_id, status, result, received_at FROM webhook_deliveries WHERE route_id = ? AND delivery_id = ?`,
+		routeID, deliveryID,
+	).Scan(&d.RouteID, &d.DeliveryID, &d.Status, &d.Result, &d.ReceivedAt)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, false, err
+	case d.Status == DeliveryFailed, d.Status == DeliveryProcessing && now.Sub(d.ReceivedAt) > deliveryStale:
+	default:
+		return &d, false, nil
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (route_id, delivery_id, status, result, received_at, expires_at) VALUES (?, ?, ?, '', ?, ?)
+		 ON CONFLICT(route_id, delivery_id) DO UPDATE SET status = excluded.status, result = '', received_at = excluded.received_at, expires_at = excluded.expires_at`,
+		routeID, deliveryID, DeliveryProcessing, now, now.Add(ttl),
+	); err != nil {
+		return nil, false, err
+	}
+	return nil, true, tx.Commit()
+}
+
+// FinishWebhookDelivery records the outcome of a claimed delivery.
+func (db *DB) FinishWebhookDelivery(ctx context.Context, routeID, deliveryID, status, result string) error {
+	if r := []rune(strings.TrimSpace(result)); len(r) > maxDeliveryResult {
+		result = string(r[:maxDeliveryResult]) + "…"
+	}
+	_, err := db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = ?, result = ? WHERE route_id = ? AND delivery_id = ?`,
+		status, result, routeID, deliveryID,
+	)
+	return err
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClaimWebhookDelivery(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, claimed, err := db.ClaimWebhookDelivery(ctx, "stripe", "evt_1", time.Hour); err != nil || !claimed {
+		t.Fatalf("first claim = %v, %v", claimed, err)
+	}
+	prev, claimed, err := db.ClaimWebhookDelivery(ctx, "stripe", "evt_1", time.Hour)
+	if err != nil || claimed || prev.Status != DeliveryProcessing {
+		t.Fatalf("claim while processing = %+v, %v, %v", prev, claimed, err)
+	}
+	if _, claimed, _ := db.ClaimWebhookDelivery(ctx, "github", "evt_1", time.Hour); !claimed {
+		t.Fatal("delivery IDs are per route")
+	}
+	if err := db.FinishWebhookDelivery(ctx, "stripe", "evt_1", DeliveryOK, `{"charged": true}`); err != nil {
+		t.Fatal(err)
+	}
+	prev, claimed, _ = db.ClaimWebhookDelivery(ctx, "stripe", "evt_1", time.Hour)
+	if claimed || prev.Status != DeliveryOK || prev.Result != `{"charged": true}` || prev.ReceivedAt.IsZero() {
+		t.Fatalf("duplicate = %+v, claimed %v", prev, claimed)
+	}
+
+	// A failed delivery may run again when the provider retries it
+	db.FinishWebhookDelivery(ctx, "github", "evt_1", DeliveryFailed, "timeout")
+	if _, claimed, _ := db.ClaimWebhookDelivery(ctx, "github", "evt_1", time.Hour); !claimed {
+		t.Fatal("failed delivery not claimable")
+	}
+
+	// Expired IDs are forgotten
+	if _, claimed, _ := db.ClaimWebhookDelivery(ctx, "stripe", "evt_2", -time.Second); !claimed {
+		t.Fatal("claim evt_2")
+	}
+	if _, claimed, _ := db.ClaimWebhookDelivery(ctx, "stripe", "evt_2", time.Hour); !claimed {
+		t.Fatal("expired delivery still remembered")
+	}
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// WebhookEventRetention is how long events stay after a briefing reported them.
+const WebhookEventRetention = 30 * 24 * time.Hour
+
+// maxWebhookEventSummary caps the stored start of a tool result or error, in runes.
+const maxWebhookEventSummary = 300
+
+// WebhookEvent is one delivery to a dynamic webhook route and what its target tool made of it.
+type WebhookEvent struct {
+	ID         int64      `json:"id"`
+	RouteID    string     `json:"route_id,omitempty"`
+	Path       string     `json:"path"`
+	Tool       string     `json:"tool,omitempty"`
+	Status     string     `json:"status"` // ok, failed
+	Summary    string     `json:"summary,omitempty"`
+	ReceivedAt time.Time  `json:"received_at"`
+	ReadAt     *time.Time `json:"read_at,omitempty"`
+}
+
+// RecordWebhookEvent stores a webhook delivery as unread, and drops events read longer than
+// WebhookEventRetention ago.
+func (db *DB) RecordWebhookEvent(ctx context.Context, e WebhookEvent) error {
+	if r := []rune(strings.TrimSpace(e.Summary)); len(r) > maxWebhookEventSummary {
+		e.Summary = string(r[:maxWebhookEventSummary]) + "…"
+	}
+	if _, err := db.ExecContext(ctx,
+		`INSERT INTO webhook_events (route_id, path, tool, status, summary) VALUES (?, ?, ?, ?, ?)`,
+		e.RouteID, e.Path, e.Tool, e.Status, e.Summary); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `DELETE FROM webhook_events WHERE read_at IS NOT NULL AND read_at < ?`,
+		time.Now().UTC().Add(-WebhookEventRetention).Format("2006-01-02 15:04:05"))
+	return err
+}
+
+// UnreadWebhookEvents returns up to limit events no briefing has reported yet, oldest first.
+func (db *DB) UnreadWebhookEvents(ctx context.Context, limit int) ([]WebhookEvent, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, route_id, path, tool, status, summary, received_at, read_at FROM webhook_events
+		 WHERE read_at IS NULL ORDER BY received_at, id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []WebhookEvent
+	for rows.Next() {
+		var e WebhookEvent
+		var readAt sql.NullTime
+		if err := rows.Scan(&e.ID, &e.RouteID, &e.Path, &e.Tool, &e.Status, &e.Summary, &e.ReceivedAt, &readAt); err != nil {
+			return nil, err
+		}
+		if readAt.Valid {
+			e.ReadAt = &readAt.Time
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// MarkWebhookEventsRead marks events as reported.
+func (db *DB) MarkWebhookEventsRead(ctx context.Context, ids []int64) error {
+	for _, id := range ids {
+		if _, err := db.ExecContext(ctx, `UPDATE webhook_events SET read_at = CURRENT_TIMESTAMP WHERE id = ? AND read_at IS NULL`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
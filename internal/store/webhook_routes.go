@@ -2,8 +2,10 @@ package store
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const webhookRoutesFile = "webhook_routes.json"
@@ -14,17 +16,222 @@ type WebhookRoute struct {
 	ID           string `json:"id"`
 	SecretHeader string `json:"secret_header"`
 	SecretEnv    string `json:"secret_env"`
-	// SecretSource defines where to look for the secret: "env" (default), "passwords", or "disabled".
+	// SecretSource defines where to look for the secret: "env" (default), "passwords", "local", "vault", or "disabled".
 	SecretSource string `json:"secret_source,omitempty"`
-	// SecretKey is the key to look up in the source (e.g. Nextcloud Passwords label).
+	// SecretKey is the key to look up in the source (e.g. Nextcloud Passwords label, or
+	// "path#field" for vault).
 	// If empty and SecretSource is "passwords", SecretEnv is used as the key.
 	SecretKey    string `json:"secret_key,omitempty"`
 	AuthType     string `json:"auth_type"` // "header" or "hmac_sha256"
 	
-	// TargetTool is the name of the tool to execute (required for dynamic routes).
+	// TargetType is "tool" (default) or "agent_prompt", which hands the delivery to the agent
+	// instead of a tool (TargetPrompt, TargetUser, TargetThread).
+	TargetType   string `json:"target_type,omitempty"`
+	// TargetTool is the name of the tool to execute (required for tool routes unless Targets is set).
 	TargetTool   string `json:"target_tool,omitempty"`
-	// TargetArgs is a JSON template for tool arguments. Supports {{payload}} placeholder.
+	// TargetArgs is a JSON template for tool arguments. {{payload}} is the body as a JSON string,
+	// {{payload.<path>}} a value from a JSON body and {{headers.<Name>}} a request header.
 	TargetArgs   string `json:"target_args,omitempty"`
+	// TargetPrompt, TargetUser and TargetThread are an agent_prompt route's Prompt, User and Thread.
+	TargetPrompt string `json:"target_prompt,omitempty"`
+	TargetUser   string `json:"target_user,omitempty"`
+	TargetThread string `json:"target_thread,omitempty"`
+	// Targets replace TargetTool/TargetArgs with several tools or agent prompts, each with its own filter.
+	Targets []WebhookTarget `json:"targets,omitempty"`
+	// Filter must match for the delivery to trigger anything; other deliveries are acknowledged and ignored.
+	Filter []WebhookCondition `json:"filter,omitempty"`
+
+	// Idempotency: providers retry deliveries, so a delivery ID seen before does not run the tool
+	// again. The ID is read from DeliveryIDHeader (e.g. X-GitHub-Delivery) or, failing that, from
+	// DeliveryIDPath, a dot path into the JSON body (e.g. "id" or "data.object.id"; numbers index
+	// arrays). Routes with neither run every delivery.
+	DeliveryIDHeader string `json:"delivery_id_header,omitempty"`
+	DeliveryIDPath   string `json:"delivery_id_path,omitempty"`
+	// DedupTTLHours is how long a delivery ID is remembered (default DefaultDedupTTLHours).
+	DedupTTLHours int `json:"dedup_ttl_hours,omitempty"`
+	// OnDuplicate is "cached" (default: answer with the first delivery's result) or "skip"
+	// (answer without it).
+	OnDuplicate string `json:"on_duplicate,omitempty"`
+
+	// Mode is "sync" (default: the tool runs before the response) or "async" (the delivery is
+	// queued and answered 202 at once).
+	Mode string `json:"mode,omitempty"`
+	// TimeoutSec limits each run of the tool (default DefaultWebhookTimeoutSec).
+	TimeoutSec int `json:"timeout_sec,omitempty"`
+	// Retries is how many more times a failed run is tried, RetryDelaySec apart (default 5),
+	// doubling each time.
+	Retries       int `json:"retries,omitempty"`
+	RetryDelaySec int `json:"retry_delay_sec,omitempty"`
+	// ResponseTemplate is the body of a sync response, with {{status}}, {{result}} and
+	// {{result.<path>}} (a dot path into a JSON result) filled in. Empty sends no body.
+	ResponseTemplate    string `json:"response_template,omitempty"`
+	ResponseContentType string `json:"response_content_type,omitempty"` // default application/json
+	// ErrorStatus is the HTTP status of a sync response when the tool failed (default 200, so
+	// the provider does not retry).
+	ErrorStatus int `json:"error_status,omitempty"`
+
+	// CreatedBy is the user who added the route with add_webhook_route; its agent prompts run as
+	// them unless a target names another user.
+	CreatedBy string `json:"created_by,omitempty"`
+}
+
+// Webhook target types.
+const (
+	WebhookTargetTool        = "tool"
+	WebhookTargetAgentPrompt = "agent_prompt"
+)
+
+// WebhookTarget is what a delivery triggers: a tool run with Args, or an agent prompt pushed as an
+// autonomous message for User (default the route's creator, else the admin) in Thread (default webhook:<route id>). Both
+// are templates like TargetArgs; in a prompt, values are inserted as text, and an empty prompt
+// passes the payload itself.
+type WebhookTarget struct {
+	Type   string             `json:"type,omitempty"` // default tool, or agent_prompt when only Prompt is set
+	Tool   string             `json:"tool,omitempty"`
+	Args   string             `json:"args,omitempty"`
+	Prompt string             `json:"prompt,omitempty"`
+	User   string             `json:"user,omitempty"`
+	Thread string             `json:"thread,omitempty"`
+	Filter []WebhookCondition `json:"filter,omitempty"` // the target runs only when these match too
+}
+
+// Kind returns the target's type, inferring it when Type is empty.
+func (t WebhookTarget) Kind() string {
+	if t.Type != "" {
+		return t.Type
+	}
+	if t.Tool == "" && t.Prompt != "" {
+		return WebhookTargetAgentPrompt
+	}
+	return WebhookTargetTool
+}
+
+func (t WebhookTarget) validate() error {
+	switch t.Kind() {
+	case WebhookTargetTool:
+		if t.Tool == "" || t.Prompt != "" {
+			return fmt.Errorf("a tool target needs a tool and no prompt")
+		}
+	case WebhookTargetAgentPrompt:
+		if t.Tool != "" || t.Args != "" {
+			return fmt.Errorf("an agent_prompt target takes a prompt, not a tool and args")
+		}
+	default:
+		return fmt.Errorf("target type must be tool or agent_prompt")
+	}
+	return validateConditions(t.Filter)
+}
+
+// WebhookCondition tests one value of a delivery: Path (a dot path into the JSON body) or Header.
+// Every test set must pass: Equals, NotEquals, In (one of) and Exists.
+type WebhookCondition struct {
+	Path      string        `json:"path,omitempty"`
+	Header    string        `json:"header,omitempty"`
+	Equals    interface{}   `json:"equals,omitempty"`
+	NotEquals interface{}   `json:"not_equals,omitempty"`
+	In        []interface{} `json:"in,omitempty"`
+	Exists    *bool         `json:"exists,omitempty"`
+}
+
+// AllTargets returns the route's targets: Targets, or the one its Target fields describe.
+func (r WebhookRoute) AllTargets() []WebhookTarget {
+	if len(r.Targets) > 0 {
+		return r.Targets
+	}
+	if r.TargetType == WebhookTargetAgentPrompt {
+		return []WebhookTarget{{Type: WebhookTargetAgentPrompt, Prompt: r.TargetPrompt, User: r.TargetUser, Thread: r.TargetThread}}
+	}
+	if r.TargetTool == "" {
+		return nil
+	}
+	return []WebhookTarget{{Type: r.TargetType, Tool: r.TargetTool, Args: r.TargetArgs}}
+}
+
+// Route modes.
+const (
+	WebhookSync  = "sync"
+	WebhookAsync = "async"
+)
+
+// DefaultWebhookTimeoutSec limits a webhook's tool run when the route sets no timeout.
+const DefaultWebhookTimeoutSec = 60
+
+// DefaultDedupTTLHours covers the retry windows of common providers (Stripe retries for three days).
+const DefaultDedupTTLHours = 72
+
+// Idempotent reports whether the route deduplicates deliveries.
+func (r WebhookRoute) Idempotent() bool {
+	return r.DeliveryIDHeader != "" || r.DeliveryIDPath != ""
+}
+
+// DedupTTL is how long the route remembers a delivery ID.
+func (r WebhookRoute) DedupTTL() time.Duration {
+	if r.DedupTTLHours > 0 {
+		return time.Duration(r.DedupTTLHours) * time.Hour
+	}
+	return DefaultDedupTTLHours * time.Hour
+}
+
+// Async reports whether deliveries are queued rather than run before the response.
+func (r WebhookRoute) Async() bool {
+	return r.Mode == WebhookAsync
+}
+
+// Timeout limits each run of the route's tool.
+func (r WebhookRoute) Timeout() time.Duration {
+	if r.TimeoutSec > 0 {
+		return time.Duration(r.TimeoutSec) * time.Second
+	}
+	return DefaultWebhookTimeoutSec * time.Second
+}
+
+// RetryDelay is the wait before the first retry.
+func (r WebhookRoute) RetryDelay() time.Duration {
+	if r.RetryDelaySec > 0 {
+		return time.Duration(r.RetryDelaySec) * time.Second
+	}
+	return 5 * time.Second
+}
+
+// Validate checks the route's options and targets; path and auth are checked where routes are added.
+func (r WebhookRoute) Validate() error {
+	switch {
+	case r.OnDuplicate != "" && r.OnDuplicate != "cached" && r.OnDuplicate != "skip":
+		return fmt.Errorf("on_duplicate must be cached or skip")
+	case r.Mode != "" && r.Mode != WebhookSync && r.Mode != WebhookAsync:
+		return fmt.Errorf("mode must be sync or async")
+	case r.TimeoutSec < 0 || r.Retries < 0 || r.RetryDelaySec < 0 || r.DedupTTLHours < 0:
+		return fmt.Errorf("timeout_sec, retries, retry_delay_sec and dedup_ttl_hours cannot be negative")
+	case r.Retries > 10:
+		return fmt.Errorf("retries can be at most 10")
+	case r.ErrorStatus != 0 && (r.ErrorStatus < 200 || r.ErrorStatus > 599):
+		return fmt.Errorf("error_status must be an HTTP status code")
+	case r.TargetType != "" && r.TargetType != WebhookTargetTool && r.TargetType != WebhookTargetAgentPrompt:
+		return fmt.Errorf("target_type must be tool or agent_prompt")
+	case len(r.AllTargets()) == 0:
+		return fmt.Errorf("a target_tool, target_type agent_prompt or targets are required")
+	}
+	for i, t := range r.AllTargets() {
+		if err := t.validate(); err != nil {
+			if len(r.Targets) == 0 {
+				return err
+			}
+			return fmt.Errorf("target %d: %w", i+1, err)
+		}
+	}
+	return validateConditions(r.Filter)
+}
+
+func validateConditions(conds []WebhookCondition) error {
+	for _, c := range conds {
+		if (c.Path == "") == (c.Header == "") {
+			return fmt.Errorf("filter conditions need either a path or a header")
+		}
+		if c.Equals == nil && c.NotEquals == nil && len(c.In) == 0 && c.Exists == nil {
+			return fmt.Errorf("filter condition on %s%s needs equals, not_equals, in or exists", c.Path, c.Header)
+		}
+	}
+	return nil
 }
 
 // LoadWebhookRoutes reads routes from $CONFIG_DIR/webhook_routes.json.
@@ -0,0 +1,25 @@
+package store
+
+import "testing"
+
+func TestWebhookRouteValidateTargets(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		route WebhookRoute
+		ok    bool
+	}{
+		{"tool", WebhookRoute{TargetTool: "run"}, true},
+		{"no target", WebhookRoute{}, false},
+		{"agent prompt", WebhookRoute{TargetType: WebhookTargetAgentPrompt}, true},
+		{"agent prompt with tool", WebhookRoute{TargetType: WebhookTargetAgentPrompt, Targets: []WebhookTarget{{Type: WebhookTargetAgentPrompt, Tool: "run"}}}, false},
+		{"unknown type", WebhookRoute{TargetType: "email", TargetTool: "run"}, false},
+		{"targets", WebhookRoute{Targets: []WebhookTarget{{Tool: "run"}, {Prompt: "look at {{payload}}"}}}, true},
+		{"target without tool", WebhookRoute{Targets: []WebhookTarget{{Args: "{}"}}}, false},
+		{"condition without test", WebhookRoute{TargetTool: "run", Filter: []WebhookCondition{{Path: "action"}}}, false},
+		{"condition on path and header", WebhookRoute{TargetTool: "run", Filter: []WebhookCondition{{Path: "action", Header: "X-Event", Equals: "a"}}}, false},
+	} {
+		if err := tc.route.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: Validate() = %v", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// WorkspaceSyncEntry is the state of one file after the last workspace sync, the base that
+// tells which side changed since.
+type WorkspaceSyncEntry struct {
+	Path       string    `json:"path"`
+	LocalSig   string    `json:"local_sig"`
+	RemoteETag string    `json:"remote_etag"`
+	SyncedAt   time.Time `json:"synced_at"`
+}
+
+// WorkspaceSyncEntries returns the sync state of every tracked file, by path.
+func (db *DB) WorkspaceSyncEntries(ctx context.Context) (map[string]WorkspaceSyncEntry, error) {
+	rows, err := db.QueryContext(ctx, `SELECT path, local_sig, remote_etag, synced_at FROM workspace_sync`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]WorkspaceSyncEntry{}
+	for rows.Next() {
+		var e WorkspaceSyncEntry
+		if err := rows.Scan(&e.Path, &e.LocalSig, &e.RemoteETag, &e.SyncedAt); err != nil {
+			return nil, err
+		}
+		out[e.Path] = e
+	}
+	return out, rows.Err()
+}
+
+// PutWorkspaceSyncEntry records a file as in sync.
+func (db *DB) PutWorkspaceSyncEntry(ctx context.Context, e WorkspaceSyncEntry) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO workspace_sync (path, local_sig, remote_etag, synced_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT(path) DO UPDATE SET local_sig = excluded.local_sig, remote_etag = excluded.remote_etag, synced_at = CURRENT_TIMESTAMP`,
+		e.Path, e.LocalSig, e.RemoteETag)
+	return err
+}
+
+// DeleteWorkspaceSyncEntry stops tracking a file that is gone on both sides.
+func (db *DB) DeleteWorkspaceSyncEntry(ctx context.Context, path string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM workspace_sync WHERE path = ?`, path)
+	return err
+}
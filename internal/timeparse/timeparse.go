@@ -0,0 +1,300 @@
+// Package timeparse parses the durations and times tools receive from the model: Go durations plus
+// days and weeks ("90m", "1d12h", "2 weeks"), ISO dates and date-times, relative times ("in 2h",
+// "3 days ago"), and phrases like "tomorrow morning" or "friday 14:00". Errors say which forms are
+// accepted so the model can correct itself.
+package timeparse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is returned for input that cannot be parsed; the message lists the accepted forms.
+type Error struct {
+	Kind  string // "duration", "time", or "clock time"
+	Input string
+	Hint  string
+}
+
+func (e *Error) Error() string {
+	if strings.TrimSpace(e.Input) == "" {
+		return fmt.Sprintf("%s is empty: %s", e.Kind, e.Hint)
+	}
+	return fmt.Sprintf("invalid %s %q: %s", e.Kind, e.Input, e.Hint)
+}
+
+const (
+	durationHint = `use a number with a unit, e.g. 30m, 2h, 1h30m, 1d, 2w, "90 minutes", or "3 days"`
+	timeHint     = `use an ISO date-time (2026-03-01T09:00:00Z or 2026-03-01 09:00), a date (2026-03-01), a relative time ("in 2h", "3 days ago"), or a phrase like "tomorrow morning", "friday 14:00", "tonight", or "3pm"`
+	clockHint    = `use HH:MM (24-hour) or a time like 9am, 3:30pm, noon, or midnight`
+)
+
+// Times of day used when a phrase names a part of the day instead of a clock time.
+var dayParts = map[string][2]int{
+	"morning":   {9, 0},
+	"noon":      {12, 0},
+	"midday":    {12, 0},
+	"afternoon": {15, 0},
+	"evening":   {18, 0},
+	"night":     {20, 0},
+	"tonight":   {20, 0},
+	"midnight":  {0, 0},
+}
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond, "us": time.Microsecond, "ms": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "wk": 7 * 24 * time.Hour, "wks": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// durationTerm matches one "<amount> <unit>" term; amounts may be words ("an hour", "half a day").
+var durationTerm = regexp.MustCompile(`^(\d+(?:\.\d+)?|half an?|an?|one)\s*([a-z]+)`)
+
+// Duration parses a non-negative duration.
+func Duration(s string) (time.Duration, error) {
+	in := strings.ToLower(strings.TrimSpace(s))
+	if in == "" {
+		return 0, &Error{Kind: "duration", Input: s, Hint: durationHint}
+	}
+	if d, err := time.ParseDuration(in); err == nil && d >= 0 {
+		return d, nil
+	}
+	var total time.Duration
+	rest := in
+	for rest != "" {
+		m := durationTerm.FindStringSubmatch(rest)
+		if m == nil {
+			return 0, &Error{Kind: "duration", Input: s, Hint: durationHint}
+		}
+		unit, ok := durationUnits[m[2]]
+		if !ok {
+			return 0, &Error{Kind: "duration", Input: s, Hint: fmt.Sprintf("unknown unit %q; %s", m[2], durationHint)}
+		}
+		var n float64
+		switch {
+		case strings.HasPrefix(m[1], "half"):
+			n = 0.5
+		case m[1] == "a" || m[1] == "an" || m[1] == "one":
+			n = 1
+		default:
+			n, _ = strconv.ParseFloat(m[1], 64)
+		}
+		total += time.Duration(n * float64(unit))
+		rest = strings.TrimSpace(rest[len(m[0]):])
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(rest, ","), "and "))
+	}
+	return total, nil
+}
+
+var clockPattern = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+
+// Clock parses a time of day: "09:00", "9am", "3:30pm", "noon", "midnight".
+func Clock(s string) (hour, minute int, err error) {
+	in := strings.ToLower(strings.TrimSpace(s))
+	in = strings.ReplaceAll(strings.ReplaceAll(in, "a.m.", "am"), "p.m.", "pm")
+	if in == "noon" || in == "midday" || in == "midnight" {
+		hm := dayParts[in]
+		return hm[0], hm[1], nil
+	}
+	m := clockPattern.FindStringSubmatch(in)
+	if m == nil || (m[2] == "" && m[3] == "") {
+		return 0, 0, &Error{Kind: "clock time", Input: s, Hint: clockHint}
+	}
+	hour, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	switch m[3] {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, &Error{Kind: "clock time", Input: s, Hint: "hour must be 1-12 with am/pm; " + clockHint}
+		}
+		if hour == 12 {
+			hour = 0
+		}
+		if m[3] == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, &Error{Kind: "clock time", Input: s, Hint: clockHint}
+	}
+	return hour, minute, nil
+}
+
+// Time parses an absolute or relative point in time. now anchors relative input; loc is the zone
+// for input without an explicit offset (nil means time.Local). A bare clock time or part of day
+// ("15:00", "evening") means the next such time, so it is never in the past.
+func Time(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	now = now.In(loc)
+	in := strings.ToLower(strings.TrimSpace(s))
+	if in == "" {
+		return time.Time{}, &Error{Kind: "time", Input: s, Hint: timeHint}
+	}
+	if in == "now" {
+		return now, nil
+	}
+	if rel, ok := cutPrefix(in, "in ", "+"); ok {
+		d, err := Duration(rel)
+		if err != nil {
+			return time.Time{}, &Error{Kind: "time", Input: s, Hint: timeHint}
+		}
+		return now.Add(d), nil
+	}
+	if rel, ok := strings.CutSuffix(in, " ago"); ok {
+		d, err := Duration(rel)
+		if err != nil {
+			return time.Time{}, &Error{Kind: "time", Input: s, Hint: timeHint}
+		}
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, strings.ToUpper(in)); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, strings.ToUpper(in), loc); err == nil {
+			return t, nil
+		}
+	}
+	if t, ok := phrase(in, now); ok {
+		return t, nil
+	}
+	return time.Time{}, &Error{Kind: "time", Input: s, Hint: timeHint}
+}
+
+// Until parses a deadline: a bare duration counts from now ("2h", "3 days"), anything else is
+// parsed by Time.
+func Until(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if d, err := Duration(s); err == nil {
+		return now.Add(d), nil
+	}
+	return Time(s, now, loc)
+}
+
+// Since parses the start of a look-back window: a bare duration counts back from now ("24h",
+// "7d"), anything else is parsed by Time.
+func Since(s string, now time.Time) (time.Time, error) {
+	if d, err := Duration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return Time(s, now, nil)
+}
+
+var weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// weekday matches full names and abbreviations of at least three letters ("thu", "thurs").
+func weekday(word string) (time.Weekday, bool) {
+	if len(word) < 3 {
+		return 0, false
+	}
+	for i, name := range weekdays {
+		if strings.HasPrefix(name, word) {
+			return time.Weekday(i), true
+		}
+	}
+	return 0, false
+}
+
+// ampmSpace joins "3 pm" into "3pm" so it reads as one word.
+var ampmSpace = regexp.MustCompile(`(\d)\s+(a\.m\.|p\.m\.|am|pm)(\s|,|$)`)
+
+// phrase parses "[day] [at] [time]" in either order: day is today, tomorrow, a weekday (optionally
+// "next"), or an ISO date; time is a clock time or part of day.
+func phrase(in string, now time.Time) (time.Time, bool) {
+	in = ampmSpace.ReplaceAllString(in, "$1$2$3")
+	var day *time.Time
+	hour, minute, haveTime := 0, 0, false
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	afterAt, this := false, false
+	for _, w := range strings.Fields(strings.NewReplacer(",", " ").Replace(in)) {
+		switch {
+		case w == "at" || w == "on" || w == "this" || w == "next" || w == "the":
+			afterAt, this = w == "at", this || w == "this"
+			continue
+		case w == "today" || w == "tomorrow":
+			if day != nil {
+				return time.Time{}, false
+			}
+			d := today
+			if w == "tomorrow" {
+				d = today.AddDate(0, 0, 1)
+			}
+			day = &d
+		case dayParts[w] != [2]int{} || w == "midnight":
+			if haveTime {
+				return time.Time{}, false
+			}
+			hm := dayParts[w]
+			hour, minute, haveTime = hm[0], hm[1], true
+			if w == "tonight" && day == nil {
+				d := today
+				day = &d
+			}
+		default:
+			if wd, ok := weekday(w); ok {
+				if day != nil {
+					return time.Time{}, false
+				}
+				// The next such weekday after today; "monday" on a Monday means a week from now.
+				ahead := (int(wd) - int(now.Weekday()) + 7) % 7
+				if ahead == 0 {
+					ahead = 7
+				}
+				d := today.AddDate(0, 0, ahead)
+				day = &d
+				break
+			}
+			if d, err := time.ParseInLocation("2006-01-02", w, now.Location()); err == nil && day == nil {
+				day = &d
+				break
+			}
+			if haveTime {
+				return time.Time{}, false
+			}
+			if h, err := strconv.Atoi(w); err == nil && afterAt && h >= 0 && h <= 23 {
+				hour, minute, haveTime = h, 0, true // "at 8"
+				break
+			}
+			h, m, err := Clock(w)
+			if err != nil {
+				return time.Time{}, false
+			}
+			hour, minute, haveTime = h, m, true
+		}
+		afterAt = false
+	}
+	if day == nil && this {
+		day = &today // "this evening"
+	}
+	switch {
+	case day == nil && !haveTime:
+		return time.Time{}, false
+	case day == nil:
+		t := time.Date(today.Year(), today.Month(), today.Day(), hour, minute, 0, 0, now.Location())
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, true
+	case !haveTime:
+		hour, minute = dayParts["morning"][0], dayParts["morning"][1]
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location()), true
+}
+
+func cutPrefix(s string, prefixes ...string) (string, bool) {
+	for _, p := range prefixes {
+		if rest, ok := strings.CutPrefix(s, p); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return s, false
+}
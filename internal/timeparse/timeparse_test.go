@@ -0,0 +1,199 @@
+package timeparse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30m", 30 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+		{"1.5h", 90 * time.Minute},
+		{"0", 0},
+		{"2d", 48 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"90 minutes", 90 * time.Minute},
+		{"3 days", 72 * time.Hour},
+		{"1 week", 7 * 24 * time.Hour},
+		{"2 hours 15 mins", 135 * time.Minute},
+		{"1 day and 2 hours", 26 * time.Hour},
+		{"1 day, 2 hours", 26 * time.Hour},
+		{"an hour", time.Hour},
+		{"a day", 24 * time.Hour},
+		{"half an hour", 30 * time.Minute},
+		{"  45S ", 45 * time.Second},
+		{"10 secs", 10 * time.Second},
+	}
+	for _, tt := range tests {
+		got, err := Duration(tt.in)
+		if err != nil {
+			t.Errorf("Duration(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Duration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDurationErrors(t *testing.T) {
+	for _, in := range []string{"", "soon", "-5m", "3 fortnights", "2x", "d", "1h then 2m", "5"} {
+		_, err := Duration(in)
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Errorf("Duration(%q) err = %v, want *Error", in, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), "30m") {
+			t.Errorf("Duration(%q) error should show examples: %v", in, err)
+		}
+	}
+	if _, err := Duration("3 fortnights"); !strings.Contains(err.Error(), `unknown unit "fortnights"`) {
+		t.Errorf("error should name the bad unit: %v", err)
+	}
+}
+
+func TestClock(t *testing.T) {
+	tests := []struct {
+		in         string
+		hour, min  int
+		wantErrStr string
+	}{
+		{"09:00", 9, 0, ""},
+		{"9:05", 9, 5, ""},
+		{"23:59", 23, 59, ""},
+		{"9am", 9, 0, ""},
+		{"9 am", 9, 0, ""},
+		{"3:30pm", 15, 30, ""},
+		{"12am", 0, 0, ""},
+		{"12pm", 12, 0, ""},
+		{"7 p.m.", 19, 0, ""},
+		{"noon", 12, 0, ""},
+		{"midnight", 0, 0, ""},
+		{"24:00", 0, 0, "invalid clock time"},
+		{"13pm", 0, 0, "1-12"},
+		{"9:75", 0, 0, "invalid clock time"},
+		{"9", 0, 0, "invalid clock time"},
+		{"", 0, 0, "clock time is empty"},
+	}
+	for _, tt := range tests {
+		h, m, err := Clock(tt.in)
+		if tt.wantErrStr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrStr) {
+				t.Errorf("Clock(%q) err = %v, want %q", tt.in, err, tt.wantErrStr)
+			}
+			continue
+		}
+		if err != nil || h != tt.hour || m != tt.min {
+			t.Errorf("Clock(%q) = %d:%02d, %v; want %d:%02d", tt.in, h, m, err, tt.hour, tt.min)
+		}
+	}
+}
+
+func TestTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	// Wednesday 4 March 2026, 10:30 in Berlin.
+	now := time.Date(2026, 3, 4, 10, 30, 0, 0, berlin)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, berlin)
+	}
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"now", now},
+		{"2026-03-10T08:00:00Z", time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)},
+		{"2026-03-10t08:00:00+02:00", time.Date(2026, 3, 10, 6, 0, 0, 0, time.UTC)},
+		{"2026-03-10T08:00", at(3, 10, 8, 0)},
+		{"2026-03-10 08:00", at(3, 10, 8, 0)},
+		{"2026-03-10 08:00:30", at(3, 10, 8, 0).Add(30 * time.Second)},
+		{"2026-03-10", at(3, 10, 0, 0)},
+		{"2026-03-10 9am", at(3, 10, 9, 0)},
+		{"in 2h", now.Add(2 * time.Hour)},
+		{"in 3 days", now.Add(72 * time.Hour)},
+		{"+45m", now.Add(45 * time.Minute)},
+		{"2 hours ago", now.Add(-2 * time.Hour)},
+		{"tomorrow", at(3, 5, 9, 0)},
+		{"tomorrow morning", at(3, 5, 9, 0)},
+		{"Tomorrow at 3pm", at(3, 5, 15, 0)},
+		{"tomorrow at 8", at(3, 5, 8, 0)},
+		{"3 pm tomorrow", at(3, 5, 15, 0)},
+		{"tomorrow evening", at(3, 5, 18, 0)},
+		{"tomorrow midnight", at(3, 5, 0, 0)},
+		{"today 16:00", at(3, 4, 16, 0)},
+		{"this evening", at(3, 4, 18, 0)},
+		{"this morning", at(3, 4, 9, 0)},
+		{"tonight", at(3, 4, 20, 0)},
+		{"15:00", at(3, 4, 15, 0)},
+		{"09:00", at(3, 5, 9, 0)}, // already past today
+		{"evening", at(3, 4, 18, 0)},
+		{"noon", at(3, 4, 12, 0)},
+		{"midnight", at(3, 5, 0, 0)},
+		{"friday", at(3, 6, 9, 0)},
+		{"friday 14:00", at(3, 6, 14, 0)},
+		{"on Fri at 2:30pm", at(3, 6, 14, 30)},
+		{"next monday morning", at(3, 9, 9, 0)},
+		{"wednesday", at(3, 11, 9, 0)}, // today is Wednesday: next week
+		{"thurs afternoon", at(3, 5, 15, 0)},
+	}
+	for _, tt := range tests {
+		got, err := Time(tt.in, now, berlin)
+		if err != nil {
+			t.Errorf("Time(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("Time(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTimeErrors(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)
+	for _, in := range []string{"", "someday", "in a while", "tomorrow today", "friday monday", "3pm 4pm", "2026-13-01", "at 25", "ago"} {
+		_, err := Time(in, now, time.UTC)
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Errorf("Time(%q) err = %v, want *Error", in, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), "tomorrow morning") {
+			t.Errorf("Time(%q) error should show examples: %v", in, err)
+		}
+	}
+}
+
+func TestUntilAndSince(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		fn   func(string) (time.Time, error)
+		in   string
+		want time.Time
+	}{
+		{func(s string) (time.Time, error) { return Until(s, now, time.UTC) }, "2h", now.Add(2 * time.Hour)},
+		{func(s string) (time.Time, error) { return Until(s, now, time.UTC) }, "2 days", now.Add(48 * time.Hour)},
+		{func(s string) (time.Time, error) { return Until(s, now, time.UTC) }, "tomorrow morning", time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)},
+		{func(s string) (time.Time, error) { return Since(s, now) }, "24h", now.Add(-24 * time.Hour)},
+		{func(s string) (time.Time, error) { return Since(s, now) }, "7d", now.Add(-7 * 24 * time.Hour)},
+		{func(s string) (time.Time, error) { return Since(s, now) }, "2026-03-01T00:00:00Z", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := tt.fn(tt.in)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("%q = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := Until("whenever", now, time.UTC); err == nil {
+		t.Error("Until should reject unparseable input")
+	}
+}
@@ -0,0 +1,387 @@
+// Package toolcheck statically checks the Go source of an agent-built tool before it is registered:
+// destructive commands, deletes of system paths, hardcoded credentials, and network destinations
+// outside the egress policy, plus go vet. Blocking findings refuse registration; warnings are
+// returned with the registration so the agent can fix them.
+package toolcheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/redact"
+)
+
+const (
+	SeverityBlock = "block"
+	SeverityWarn  = "warn"
+)
+
+// Finding is one issue in the source.
+type Finding struct {
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Message  string `json:"message"`
+}
+
+// Report is the result of checking one tool's source.
+type Report struct {
+	SourceDir string    `json:"source_dir"`
+	Files     int       `json:"files"`
+	Findings  []Finding `json:"findings"`
+	Vet       string    `json:"vet"` // "ok", "skipped: ...", or go vet's output
+	Blocked   bool      `json:"blocked"`
+}
+
+// Options tune a check.
+type Options struct {
+	// HostAllowed reports whether the egress policy lets tools reach host, and why not.
+	// Nil skips the host check.
+	HostAllowed func(host string) (bool, string)
+	// SkipVet disables go vet (e.g. when the go toolchain is not installed).
+	SkipVet bool
+}
+
+// exfilHosts are services commonly used to receive stolen data; contacting them blocks registration.
+var exfilHosts = []string{
+	"pastebin.com", "hastebin.com", "ghostbin.com", "transfer.sh", "file.io", "0x0.st", "termbin.com",
+	"webhook.site", "requestbin.com", "pipedream.net", "ngrok.io", "ngrok-free.app", "ngrok.app",
+	"burpcollaborator.net", "oast.fun", "interact.sh", "canarytokens.com",
+}
+
+// destructiveCommands are shell fragments that destroy data or the host.
+var destructiveCommands = regexp.MustCompile(`(?i)\brm\s+-[a-z]*[rf][a-z]*\s+(/|~|\$home|\*|/\*|/(bin|boot|etc|home|lib|root|sbin|usr|var)/?)(\s|$|"|')|\bmkfs(\.\w+)?\s|\bdd\s+if=|:\(\)\s*\{\s*:\|:&\s*\};:|\bchmod\s+-r\s+777\s+/(\s|$)|>\s*/dev/sd[a-z]`)
+
+// powerCommands stop or restart the host when executed.
+var powerCommands = map[string]bool{"shutdown": true, "reboot": true, "halt": true, "poweroff": true, "init": true}
+
+// systemPaths must never be deleted by a tool.
+var systemPaths = map[string]bool{
+	"/": true, "/*": true, "~": true, "/bin": true, "/boot": true, "/dev": true, "/etc": true, "/home": true,
+	"/lib": true, "/opt": true, "/proc": true, "/root": true, "/sbin": true, "/sys": true, "/usr": true, "/var": true,
+}
+
+// credentialName matches identifiers that usually hold secrets.
+var credentialName = regexp.MustCompile(`(?i)(passw(or)?d|secret|token|api_?key|access_?key|private_?key|credential)`)
+
+// sensitiveFiles are paths whose contents a tool should not read.
+var sensitiveFiles = regexp.MustCompile(`(^|/)(\.ssh/|id_rsa|id_ed25519|\.aws/credentials|\.netrc|\.docker/config\.json)|^/etc/shadow$|/\.env$|secrets\.enc|\.secrets\.key`)
+
+// Check parses every .go file in dir (not recursive, test files skipped) and reports findings.
+func Check(ctx context.Context, dir string, opts Options) (*Report, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, m := range matches {
+		if !strings.HasSuffix(m, "_test.go") {
+			files = append(files, m)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go source files in %s", dir)
+	}
+	sort.Strings(files)
+	r := &Report{SourceDir: dir, Files: len(files), Findings: []Finding{}}
+	fset := token.NewFileSet()
+	for _, path := range files {
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			r.add(Finding{Severity: SeverityBlock, Rule: "parse", File: filepath.Base(path), Message: err.Error()})
+			continue
+		}
+		c := &checker{fset: fset, file: filepath.Base(path), report: r, opts: opts, imports: importNames(f)}
+		ast.Inspect(f, c.visit)
+	}
+	if opts.SkipVet {
+		r.Vet = "skipped"
+	} else {
+		r.Vet = vet(ctx, dir, files)
+		if r.Vet != "ok" && !strings.HasPrefix(r.Vet, "skipped") {
+			r.add(Finding{Severity: SeverityWarn, Rule: "go_vet", Message: "go vet reported problems (see vet)"})
+		}
+	}
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		if r.Findings[i].Severity != r.Findings[j].Severity {
+			return r.Findings[i].Severity == SeverityBlock
+		}
+		return false
+	})
+	return r, nil
+}
+
+func (r *Report) add(f Finding) {
+	r.Findings = append(r.Findings, f)
+	if f.Severity == SeverityBlock {
+		r.Blocked = true
+	}
+}
+
+// Summary is a one-line description of the findings, for errors returned to the agent.
+func (r *Report) Summary() string {
+	var parts []string
+	for _, f := range r.Findings {
+		loc := f.File
+		if f.Line > 0 {
+			loc = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		parts = append(parts, fmt.Sprintf("[%s] %s: %s", f.Severity, loc, f.Message))
+	}
+	return strings.Join(parts, "; ")
+}
+
+type checker struct {
+	fset    *token.FileSet
+	file    string
+	report  *Report
+	opts    Options
+	imports map[string]string // local name -> import path
+}
+
+// add records a finding, once per rule and line (a literal inside a flagged call is not reported twice).
+func (c *checker) add(n ast.Node, severity, rule, msg string) {
+	line := c.fset.Position(n.Pos()).Line
+	for _, f := range c.report.Findings {
+		if f.File == c.file && f.Line == line && f.Rule == rule {
+			return
+		}
+	}
+	c.report.add(Finding{Severity: severity, Rule: rule, File: c.file, Line: line, Message: msg})
+}
+
+func (c *checker) visit(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.CallExpr:
+		c.call(n)
+	case *ast.BasicLit:
+		if n.Kind == token.STRING {
+			c.literal(n)
+		}
+	case *ast.AssignStmt:
+		for i, lhs := range n.Lhs {
+			if i < len(n.Rhs) {
+				c.credentialAssign(lhs, n.Rhs[i])
+			}
+		}
+	case *ast.ValueSpec:
+		for i, name := range n.Names {
+			if i < len(n.Values) {
+				c.credentialAssign(name, n.Values[i])
+			}
+		}
+	case *ast.KeyValueExpr:
+		c.credentialAssign(n.Key, n.Value)
+	}
+	return true
+}
+
+// call checks exec and file-removal calls.
+func (c *checker) call(call *ast.CallExpr) {
+	pkg, fn := c.callee(call)
+	switch {
+	case pkg == "os/exec" && (fn == "Command" || fn == "CommandContext"):
+		args := call.Args
+		if fn == "CommandContext" && len(args) > 0 {
+			args = args[1:]
+		}
+		var parts []string
+		for _, a := range args {
+			if s, ok := stringLit(a); ok {
+				parts = append(parts, s)
+			}
+		}
+		cmdline := strings.Join(parts, " ")
+		first := ""
+		if len(parts) > 0 {
+			first = filepath.Base(parts[0])
+			if (first == "sh" || first == "bash") && len(parts) > 2 && parts[1] == "-c" {
+				if f := strings.Fields(parts[2]); len(f) > 0 {
+					first = filepath.Base(f[0])
+				}
+			}
+		}
+		if destructiveCommands.MatchString(cmdline) || powerCommands[first] {
+			c.add(call, SeverityBlock, "destructive_exec", fmt.Sprintf("runs a destructive command: %q", cmdline))
+		} else if len(parts) > 0 && (filepath.Base(parts[0]) == "sh" || filepath.Base(parts[0]) == "bash") && len(parts) < len(args) {
+			c.add(call, SeverityWarn, "dynamic_shell", "runs a shell with a non-constant command line; make sure input cannot inject commands")
+		}
+	case pkg == "os" && (fn == "RemoveAll" || fn == "Remove") && len(call.Args) == 1:
+		if s, ok := stringLit(call.Args[0]); ok {
+			p := strings.TrimRight(s, "/")
+			if p == "" {
+				p = "/"
+			}
+			if systemPaths[p] || systemPaths[s] {
+				c.add(call, SeverityBlock, "remove_system_path", fmt.Sprintf("os.%s(%q) deletes a system path", fn, s))
+			}
+		} else if fn == "RemoveAll" {
+			if c.rootJoin(call.Args[0]) {
+				c.add(call, SeverityBlock, "remove_system_path", "os.RemoveAll on a path built from \"/\"")
+			} else {
+				c.add(call, SeverityWarn, "remove_all", "os.RemoveAll on a computed path; make sure it stays inside the tool's own directory")
+			}
+		}
+	case pkg == "os" && fn == "Environ":
+		c.add(call, SeverityWarn, "environ", "reads the whole environment, which includes the bot's secrets; read only the variables the tool needs")
+	}
+}
+
+// rootJoin reports whether e is filepath.Join/path.Join whose first argument is "/".
+func (c *checker) rootJoin(e ast.Expr) bool {
+	call, ok := e.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return false
+	}
+	pkg, fn := c.callee(call)
+	if (pkg != "path/filepath" && pkg != "path") || fn != "Join" {
+		return false
+	}
+	s, ok := stringLit(call.Args[0])
+	return ok && strings.TrimRight(s, "/") == ""
+}
+
+// literal checks string literals for credentials, sensitive paths, destructive shell, and hosts.
+func (c *checker) literal(lit *ast.BasicLit) {
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return
+	}
+	if redact.String(s) != s && !strings.Contains(s, "%") {
+		c.add(lit, SeverityBlock, "hardcoded_credential", "string literal looks like a credential; read it from an env var or {{secret:...}} reference instead")
+	}
+	if sensitiveFiles.MatchString(s) {
+		c.add(lit, SeverityBlock, "sensitive_file", fmt.Sprintf("references a sensitive file: %q", s))
+	}
+	if destructiveCommands.MatchString(s) {
+		c.add(lit, SeverityBlock, "destructive_exec", fmt.Sprintf("contains a destructive command: %q", s))
+	}
+	c.url(lit, s)
+}
+
+// url checks http(s) URLs in literals against the exfiltration list and the egress policy.
+func (c *checker) url(lit *ast.BasicLit, s string) {
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+		return
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Hostname() == "" || strings.ContainsAny(u.Hostname(), "%{") {
+		return
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range exfilHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			c.add(lit, SeverityBlock, "exfiltration_host", fmt.Sprintf("sends data to %s, a common exfiltration endpoint", host))
+			return
+		}
+	}
+	if host == "localhost" || strings.HasPrefix(host, "127.") {
+		return
+	}
+	if c.opts.HostAllowed != nil {
+		if ok, reason := c.opts.HostAllowed(host); !ok {
+			c.add(lit, SeverityWarn, "egress_policy", fmt.Sprintf("contacts %s, which the network policy blocks (%s); ask the admin to allow it with manage_network_policy", host, reason))
+		}
+	}
+}
+
+// credentialAssign flags a credential-named variable or field set to a string constant.
+func (c *checker) credentialAssign(name ast.Expr, value ast.Expr) {
+	var ident string
+	switch n := name.(type) {
+	case *ast.Ident:
+		ident = n.Name
+	case *ast.BasicLit:
+		ident, _ = strconv.Unquote(n.Value)
+	case *ast.SelectorExpr:
+		ident = n.Sel.Name
+	default:
+		return
+	}
+	s, ok := stringLit(value)
+	if !ok || !credentialName.MatchString(ident) || len(s) < 8 || strings.ContainsAny(s, " %{$") {
+		return
+	}
+	c.add(value, SeverityBlock, "hardcoded_credential", fmt.Sprintf("%s is set to a constant; read it from an env var or {{secret:...}} reference instead", ident))
+}
+
+// callee resolves pkg.Fn calls to the imported package path and function name.
+func (c *checker) callee(call *ast.CallExpr) (pkg, fn string) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", ""
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", ""
+	}
+	return c.imports[x.Name], sel.Sel.Name
+}
+
+func importNames(f *ast.File) map[string]string {
+	out := map[string]string{}
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		out[name] = path
+	}
+	return out
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// vet runs go vet on the package in dir; a tool without go.mod is vetted as a list of files.
+func vet(ctx context.Context, dir string, files []string) string {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		return "skipped: go toolchain not found"
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	args := []string{"vet", "."}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+		args = []string{"vet"}
+		for _, f := range files {
+			args = append(args, filepath.Base(f))
+		}
+	}
+	cmd := exec.CommandContext(ctx, goBin, args...)
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return "skipped: " + err.Error()
+		}
+		msg := strings.TrimSpace(out.String())
+		if len(msg) > 2000 {
+			msg = msg[:2000] + "..."
+		}
+		return msg
+	}
+	return "ok"
+}
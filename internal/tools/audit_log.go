@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/timeparse"
+)
+
+// ReadAuditLogTool returns tool executions from the audit log (admin only).
+func ReadAuditLogTool(ctx context.Context, db *store.DB, argsJSON string) (string, error) {
+	trustLevel, ok := ctx.Value("user_trust").(string)
+	if !ok || trustLevel != "admin" {
+		return ErrJSON(fmt.Errorf("unauthorized: only admins can read the audit log")), nil
+	}
+	var args struct {
+		UserID  string `json:"user_id"`
+		Tool    string `json:"tool"`
+		Outcome string `json:"outcome"`
+		Since   string `json:"since"` // duration ("24h", "7d") or RFC3339 time
+		Limit   int    `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	filter := store.AuditFilter{UserID: args.UserID, Tool: args.Tool, Outcome: args.Outcome, Limit: args.Limit}
+	if filter.Limit > 500 {
+		filter.Limit = 500
+	}
+	if args.Since != "" {
+		since, err := timeparse.Since(args.Since, time.Now())
+		if err != nil {
+			return ErrJSON(fmt.Errorf("since: %w", err)), nil
+		}
+		filter.Since = since
+	}
+	entries, err := db.ReadAuditLog(ctx, filter)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	if entries == nil {
+		entries = []store.AuditEntry{}
+	}
+	b, _ := json.Marshal(map[string]interface{}{"entries": entries, "count": len(entries)})
+	return string(b), nil
+}
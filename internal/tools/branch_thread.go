@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+)
+
+// branchListLimit is the default number of messages listed by branch_thread's list action.
+const branchListLimit = 20
+
+// BranchThreadTool lists a thread's messages with their IDs, or starts a new thread that
+// continues a thread from one of those messages (see store.BranchThread). Non-admins may only
+// use threads they took part in.
+func (e *Executor) BranchThreadTool(ctx context.Context, argsJSON string) (string, error) {
+	var args struct {
+		Action      string `json:"action"`
+		ThreadID    string `json:"thread_id"`
+		MessageID   int64  `json:"message_id"`
+		NewThreadID string `json:"new_thread_id"`
+		Limit       int    `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	caller, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	if args.ThreadID == "" {
+		msg, ok := gateway.MessageFromContext(ctx)
+		if !ok || msg.ThreadID == "" {
+			return ErrJSON(fmt.Errorf("thread_id is required outside a conversation")), nil
+		}
+		args.ThreadID = msg.ThreadID
+	}
+	if err := e.checkThreadAccess(ctx, caller, args.ThreadID); err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "list":
+		if args.Limit <= 0 {
+			args.Limit = branchListLimit
+		}
+		msgs, err := e.DB.ThreadHistory(ctx, args.ThreadID, args.Limit)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		type item struct {
+			ID        int64  `json:"id"`
+			ThreadID  string `json:"thread_id"`
+			Role      string `json:"role"`
+			SenderID  string `json:"sender_id"`
+			CreatedAt string `json:"created_at"`
+			Content   string `json:"content"`
+		}
+		var out []item
+		for _, m := range msgs {
+			if (m.Role != "user" && m.Role != "assistant") || m.Content == "" {
+				continue
+			}
+			out = append(out, item{m.ID, m.ThreadID, m.Role, m.SenderID, m.CreatedAt.Format(time.RFC3339), snippet(m.Content, 200)})
+		}
+		b, _ := json.Marshal(map[string]interface{}{"thread_id": args.ThreadID, "messages": out})
+		return string(b), nil
+	case "branch":
+		if args.MessageID <= 0 {
+			return ErrJSON(fmt.Errorf("message_id is required (see action list)")), nil
+		}
+		if args.NewThreadID == "" {
+			args.NewThreadID = args.ThreadID + ":branch-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+		}
+		if err := e.DB.BranchThread(ctx, args.ThreadID, args.MessageID, args.NewThreadID, caller); err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{"status": "branched", "thread_id": args.NewThreadID, "parent_thread_id": args.ThreadID, "parent_message_id": args.MessageID})
+		return string(b), nil
+	default:
+		return ErrJSON(fmt.Errorf("unknown action %q (use list or branch)", args.Action)), nil
+	}
+}
+
+// checkThreadAccess allows admins any thread and others the threads they sent messages in or
+// branched themselves.
+func (e *Executor) checkThreadAccess(ctx context.Context, caller, threadID string) error {
+	if trust, _ := ctx.Value("user_trust").(string); trust == "admin" {
+		return nil
+	}
+	threads, err := e.DB.UserThreadIDs(ctx, caller)
+	if err != nil {
+		return err
+	}
+	for _, t := range threads {
+		if t == threadID {
+			return nil
+		}
+	}
+	if b, err := e.DB.GetThreadBranch(ctx, threadID); err == nil && b != nil && b.CreatedBy == caller {
+		return nil
+	}
+	return fmt.Errorf("unauthorized: you can only use threads you took part in")
+}
+
+// snippet shortens s to n runes for listings.
+func snippet(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
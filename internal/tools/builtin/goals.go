@@ -0,0 +1,483 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/scheduler"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/timeparse"
+)
+
+// GoalReviewDescription names the weekly agent_prompt plan that reviews a user's goals.
+const GoalReviewDescription = "Weekly goal review"
+
+// DefaultGoalReviewAt is when the weekly review runs unless the user picks another time.
+const DefaultGoalReviewAt = "mon 09:00"
+
+const goalReviewPrompt = `Weekly goal review. Call manage_goal with action "review". Then send the user one notify_user message: ` +
+	`for each active goal, its progress against target and time left; call out goals that are behind, overdue, or have blockers ` +
+	`(blocked jobs, questions waiting for the user, failing schedules) and suggest one next step for each. ` +
+	`Close goals whose metrics are all met by asking the user whether to mark them achieved. Finish with report_task_result.`
+
+// behindMargin is how far metric progress may trail the share of time elapsed before a goal counts as behind.
+const behindMargin = 0.1
+
+// GoalReview is the state of one goal and the work linked to it.
+type GoalReview struct {
+	Goal     store.Goal    `json:"goal"`
+	Summary  string        `json:"summary"`
+	Progress *float64      `json:"progress,omitempty"` // mean metric progress, 0-1
+	Elapsed  *float64      `json:"time_elapsed,omitempty"`
+	DaysLeft *int          `json:"days_left,omitempty"`
+	Overdue  bool          `json:"overdue,omitempty"`
+	Behind   bool          `json:"behind,omitempty"`
+	Jobs     []projectJob  `json:"jobs"`
+	Plans    []projectPlan `json:"plans"`
+	Blockers []string      `json:"blockers,omitempty"`
+}
+
+// ReviewGoal collects the progress of g and of the jobs and plans that serve it: those of its
+// project plus the ones linked directly.
+func ReviewGoal(ctx context.Context, db *store.DB, g *store.Goal, now time.Time) (*GoalReview, error) {
+	r := &GoalReview{Goal: *g, Jobs: []projectJob{}, Plans: []projectPlan{}}
+
+	var jobs []store.Job
+	if g.ProjectID != 0 {
+		var err error
+		if jobs, err = db.ProjectJobs(ctx, g.ProjectID); err != nil {
+			return nil, err
+		}
+	}
+	seen := map[int64]bool{}
+	for _, j := range jobs {
+		seen[j.ID] = true
+	}
+	for _, id := range g.JobIDs {
+		if j, err := db.GetJob(ctx, id); err == nil && j != nil && j.UserID == g.UserID && !seen[id] {
+			jobs = append(jobs, *j)
+			seen[id] = true
+		}
+	}
+	closed := 0
+	for _, j := range jobs {
+		r.Jobs = append(r.Jobs, projectJob{ID: j.ID, Title: j.Title, Status: j.Status, BlockedReason: j.BlockedReason})
+		switch j.Status {
+		case "closed":
+			closed++
+		case "blocked":
+			r.Blockers = append(r.Blockers, fmt.Sprintf("job #%d %q is blocked: %s", j.ID, j.Title, j.BlockedReason))
+		case store.AwaitingInput:
+			r.Blockers = append(r.Blockers, fmt.Sprintf("job #%d %q is waiting for the user", j.ID, j.Title))
+		}
+	}
+
+	plans, err := db.ListPlans(ctx, g.UserID, "")
+	if err != nil {
+		return nil, err
+	}
+	linked := map[int64]bool{}
+	for _, id := range g.PlanIDs {
+		linked[id] = true
+	}
+	if g.ProjectID != 0 {
+		projectPlans, err := db.ProjectPlans(ctx, g.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range projectPlans {
+			linked[p.ID] = true
+		}
+	}
+	for _, pl := range plans {
+		if !linked[pl.ID] {
+			continue
+		}
+		pp := projectPlan{ID: pl.ID, Description: pl.Description, Status: pl.Status, NextRunAt: pl.NextRunAt}
+		if runs, err := db.ListPlanRuns(ctx, pl.ID, 1); err == nil && len(runs) > 0 {
+			pp.LastRun = runs[0].Status
+			if pp.LastRun == "failed" {
+				r.Blockers = append(r.Blockers, fmt.Sprintf("schedule #%d %q failed its last run", pl.ID, pl.Description))
+			}
+		}
+		r.Plans = append(r.Plans, pp)
+	}
+
+	var parts []string
+	if len(g.Metrics) > 0 {
+		sum := 0.0
+		for _, m := range g.Metrics {
+			sum += m.Progress()
+		}
+		p := round2(sum / float64(len(g.Metrics)))
+		r.Progress = &p
+		parts = append(parts, fmt.Sprintf("%.0f%% of target", p*100))
+	}
+	if end, ok := g.Target(now.Location()); ok {
+		// Calendar days to the target date; 0 on the day itself
+		loc := now.Location()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		days := int(math.Round(time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc).Sub(today).Hours() / 24))
+		if total := end.Sub(g.CreatedAt); total > 0 {
+			e := round2(math.Min(1, math.Max(0, float64(now.Sub(g.CreatedAt))/float64(total))))
+			r.Elapsed = &e
+		}
+		if g.Status == store.GoalActive && now.After(end) {
+			r.Overdue = true
+			r.Blockers = append(r.Blockers, fmt.Sprintf("target date %s has passed", g.TargetDate))
+			parts = append(parts, "overdue")
+		} else {
+			r.DaysLeft = &days
+			parts = append(parts, fmt.Sprintf("%d days left", days))
+		}
+		if g.Status == store.GoalActive && r.Progress != nil && r.Elapsed != nil && *r.Progress < *r.Elapsed-behindMargin {
+			r.Behind = true
+			parts = append(parts, fmt.Sprintf("behind (%.0f%% of the time used)", *r.Elapsed*100))
+		}
+	}
+	if len(jobs) > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d jobs closed", closed, len(jobs)))
+	}
+	if len(r.Blockers) > 0 {
+		parts = append(parts, fmt.Sprintf("%d blockers", len(r.Blockers)))
+	}
+	r.Summary = strings.Join(parts, "; ")
+	if r.Summary == "" {
+		r.Summary = "no metrics, target date or linked work to measure"
+	}
+	return r, nil
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// EnsureGoalReview makes sure userID has the weekly goal review plan and returns its ID. An
+// existing plan is kept unless reschedule is set, in which case it is replaced by one at runAt
+// (a weekly rule like "fri 17:00") in timezone tz.
+func EnsureGoalReview(ctx context.Context, db *store.DB, userID, runAt, tz string, reschedule bool) (int64, error) {
+	plans, err := db.ListPlans(ctx, userID, "")
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range plans {
+		if p.ActionType != "agent_prompt" || p.Description != GoalReviewDescription || p.Status == "completed" {
+			continue
+		}
+		if !reschedule {
+			return p.ID, nil
+		}
+		if err := db.DeletePlan(ctx, p.ID); err != nil {
+			return 0, err
+		}
+	}
+	if runAt == "" {
+		runAt = DefaultGoalReviewAt
+	}
+	rule, err := scheduler.ParseRule("weekly", runAt, tz)
+	if err != nil {
+		return 0, err
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"prompt": goalReviewPrompt, "autonomous": true})
+	return db.CreatePlan(ctx, userID, GoalReviewDescription, "agent_prompt", string(payload), "weekly", runAt, tz, rule.Next(time.Now()))
+}
+
+// ManageGoalTool tracks goals with target dates and metrics, and schedules their weekly review.
+type ManageGoalTool struct {
+	DB *store.DB
+}
+
+func NewManageGoalTool(db *store.DB) *ManageGoalTool {
+	return &ManageGoalTool{DB: db}
+}
+
+func (t *ManageGoalTool) Name() string {
+	return "manage_goal"
+}
+
+func (t *ManageGoalTool) Definition() openrouter.ToolDefinition {
+	metric := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":    map[string]string{"type": "string"},
+			"start":   map[string]string{"type": "number", "description": "Value when the goal was set (default 0)"},
+			"target":  map[string]string{"type": "number"},
+			"current": map[string]string{"type": "number"},
+			"unit":    map[string]string{"type": "string"},
+		},
+		"required": []string{"name"},
+	}
+	return openrouter.ToolDefinition{
+		Type: "function",
+		Function: openrouter.FunctionSpec{
+			Name:        "manage_goal",
+			Description: "Track the user's goals (objectives with a target date and measurable metrics, served by jobs, scheduled plans or a project). Creating the first goal schedules a weekly review that reports progress and blockers to the user; review returns that report now.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action":         map[string]interface{}{"type": "string", "enum": []string{"create", "update", "list", "review", "delete", "schedule_review"}, "description": "update changes only the fields given (metrics are matched by name); schedule_review moves the weekly review to run_at"},
+					"id":             map[string]interface{}{"type": "integer", "description": "Goal ID (for update, delete; optional for review)"},
+					"title":          map[string]interface{}{"type": "string", "description": "Short objective (for create/update)"},
+					"description":    map[string]interface{}{"type": "string", "description": "Why it matters and what done looks like"},
+					"target_date":    map[string]interface{}{"type": "string", "description": "Deadline, e.g. 2026-12-31, \"in 12 weeks\" or \"friday\""},
+					"metrics":        map[string]interface{}{"type": "array", "items": metric, "description": "Key results; on update, given values replace those of the metric with the same name and new names are added"},
+					"remove_metrics": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Metric names to drop (for update)"},
+					"status":         map[string]interface{}{"type": "string", "enum": []string{store.GoalActive, store.GoalAchieved, store.GoalAbandoned}, "description": "New status (for update) or filter (for list)"},
+					"project":        map[string]interface{}{"type": "string", "description": "Project whose jobs and schedules serve the goal (default on create: the current project; \"none\" unlinks)"},
+					"job_ids":        map[string]interface{}{"type": "array", "items": map[string]string{"type": "integer"}, "description": "Related jobs; on update replaces the list"},
+					"plan_ids":       map[string]interface{}{"type": "array", "items": map[string]string{"type": "integer"}, "description": "Related scheduled plans; on update replaces the list"},
+					"run_at":         map[string]interface{}{"type": "string", "description": "Weekly review time for schedule_review, e.g. \"mon 09:00\" or \"fri 17:30\""},
+					"timezone":       map[string]interface{}{"type": "string", "description": "IANA time zone of run_at (default server local)"},
+				},
+				"required": []string{"action"},
+			},
+		},
+	}
+}
+
+type goalMetricArg struct {
+	Name    string   `json:"name"`
+	Start   *float64 `json:"start"`
+	Target  *float64 `json:"target"`
+	Current *float64 `json:"current"`
+	Unit    string   `json:"unit"`
+}
+
+// mergeMetrics applies the given metric values to ms: existing metrics (by case-insensitive
+// name) keep the values not given, new ones start from zero.
+func mergeMetrics(ms []store.GoalMetric, args []goalMetricArg, remove []string) []store.GoalMetric {
+	for _, a := range args {
+		i := metricIndex(ms, a.Name)
+		if i < 0 {
+			ms = append(ms, store.GoalMetric{Name: strings.TrimSpace(a.Name)})
+			i = len(ms) - 1
+		}
+		m := &ms[i]
+		if a.Start != nil {
+			m.Start = *a.Start
+		}
+		if a.Target != nil {
+			m.Target = *a.Target
+		}
+		if a.Current != nil {
+			m.Current = *a.Current
+		}
+		if a.Unit != "" {
+			m.Unit = a.Unit
+		}
+	}
+	for _, name := range remove {
+		if i := metricIndex(ms, name); i >= 0 {
+			ms = append(ms[:i], ms[i+1:]...)
+		}
+	}
+	return ms
+}
+
+func metricIndex(ms []store.GoalMetric, name string) int {
+	for i, m := range ms {
+		if strings.EqualFold(m.Name, strings.TrimSpace(name)) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *ManageGoalTool) Execute(ctx context.Context, argsJSON string) (string, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var args struct {
+		Action        string          `json:"action"`
+		ID            int64           `json:"id"`
+		Title         string          `json:"title"`
+		Description   string          `json:"description"`
+		TargetDate    string          `json:"target_date"`
+		Metrics       []goalMetricArg `json:"metrics"`
+		RemoveMetrics []string        `json:"remove_metrics"`
+		Status        string          `json:"status"`
+		Project       string          `json:"project"`
+		JobIDs        []int64         `json:"job_ids"`
+		PlanIDs       []int64         `json:"plan_ids"`
+		RunAt         string          `json:"run_at"`
+		Timezone      string          `json:"timezone"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	now := time.Now()
+
+	switch args.Action {
+	case "list":
+		goals, err := t.DB.ListGoals(ctx, userID, args.Status)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(goals)
+		return string(b), nil
+	case "review":
+		goals, err := t.DB.ListGoals(ctx, userID, store.GoalActive)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if args.ID != 0 {
+			g, err := t.ownGoal(ctx, userID, args.ID)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			goals = []store.Goal{*g}
+		}
+		reviews := []*GoalReview{}
+		for i := range goals {
+			r, err := ReviewGoal(ctx, t.DB, &goals[i], now)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			reviews = append(reviews, r)
+		}
+		b, _ := json.Marshal(map[string]interface{}{"reviewed_at": now.Format(time.RFC3339), "goals": reviews})
+		return string(b), nil
+	case "schedule_review":
+		id, err := EnsureGoalReview(ctx, t.DB, userID, args.RunAt, args.Timezone, true)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "scheduled", "review_plan_id": %d}`, id), nil
+	case "create":
+		g := store.Goal{UserID: userID, Title: args.Title, Description: args.Description, Metrics: mergeMetrics(nil, args.Metrics, nil)}
+		if err := t.applyLinks(ctx, userID, &g, args.Project, args.JobIDs, args.PlanIDs); err != nil {
+			return ErrJSON(err), nil
+		}
+		if args.Project == "" {
+			g.ProjectID = CurrentProjectID(ctx, t.DB)
+		}
+		if g.TargetDate, err = targetDate(args.TargetDate, now); err != nil {
+			return ErrJSON(err), nil
+		}
+		id, err := t.DB.CreateGoal(ctx, g)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		review, err := EnsureGoalReview(ctx, t.DB, userID, "", "", false)
+		if err != nil {
+			return ErrJSON(fmt.Errorf("goal #%d created, but scheduling its weekly review failed: %w", id, err)), nil
+		}
+		return fmt.Sprintf(`{"id": %d, "status": "created", "review_plan_id": %d}`, id, review), nil
+	case "update":
+		g, err := t.ownGoal(ctx, userID, args.ID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if args.Title != "" {
+			g.Title = args.Title
+		}
+		if args.Description != "" {
+			g.Description = args.Description
+		}
+		if args.Status != "" {
+			g.Status = args.Status
+		}
+		if args.TargetDate != "" {
+			if g.TargetDate, err = targetDate(args.TargetDate, now); err != nil {
+				return ErrJSON(err), nil
+			}
+		}
+		g.Metrics = mergeMetrics(g.Metrics, args.Metrics, args.RemoveMetrics)
+		if err := t.applyLinks(ctx, userID, g, args.Project, args.JobIDs, args.PlanIDs); err != nil {
+			return ErrJSON(err), nil
+		}
+		if err := t.DB.UpdateGoal(ctx, g); err != nil {
+			return ErrJSON(err), nil
+		}
+		r, err := ReviewGoal(ctx, t.DB, g, now)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{"id": g.ID, "status": "updated", "summary": r.Summary})
+		return string(b), nil
+	case "delete":
+		if _, err := t.ownGoal(ctx, userID, args.ID); err != nil {
+			return ErrJSON(err), nil
+		}
+		if err := t.DB.DeleteGoal(ctx, args.ID); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "deleted", "id": %d}`, args.ID), nil
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s", args.Action)), nil
+	}
+}
+
+// ownGoal loads goal id if it belongs to userID.
+func (t *ManageGoalTool) ownGoal(ctx context.Context, userID string, id int64) (*store.Goal, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("id is required")
+	}
+	g, err := t.DB.GetGoal(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil || g.UserID != userID {
+		return nil, fmt.Errorf("goal %d not found", id)
+	}
+	return g, nil
+}
+
+// applyLinks sets the goal's project (unless project is "") and replaces its job and plan lists
+// (unless nil); all must be the user's.
+func (t *ManageGoalTool) applyLinks(ctx context.Context, userID string, g *store.Goal, project string, jobIDs, planIDs []int64) error {
+	switch {
+	case project == "":
+	case strings.EqualFold(project, "none"):
+		g.ProjectID = 0
+	default:
+		p, err := findProject(ctx, t.DB, userID, project)
+		if err != nil {
+			return err
+		}
+		g.ProjectID = p.ID
+	}
+	if jobIDs != nil {
+		for _, id := range jobIDs {
+			if j, err := t.DB.GetJob(ctx, id); err != nil || j == nil || j.UserID != userID {
+				return fmt.Errorf("job %d not found", id)
+			}
+		}
+		g.JobIDs = jobIDs
+	}
+	if planIDs != nil {
+		plans, err := t.DB.ListPlans(ctx, userID, "")
+		if err != nil {
+			return err
+		}
+		own := map[int64]bool{}
+		for _, p := range plans {
+			own[p.ID] = true
+		}
+		for _, id := range planIDs {
+			if !own[id] {
+				return fmt.Errorf("scheduled plan %d not found", id)
+			}
+		}
+		g.PlanIDs = planIDs
+	}
+	return nil
+}
+
+// targetDate parses a deadline into YYYY-MM-DD; "" stays open-ended.
+func targetDate(s string, now time.Time) (string, error) {
+	if strings.TrimSpace(s) == "" {
+		return "", nil
+	}
+	t, err := timeparse.Until(s, now, time.Local)
+	if err != nil {
+		return "", fmt.Errorf("target_date: %w", err)
+	}
+	return t.Format("2006-01-02"), nil
+}
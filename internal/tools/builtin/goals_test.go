@@ -0,0 +1,105 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestManageGoal(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.GetOrCreateUser(ctx, "u1", "", "api")
+	ctx = context.WithValue(ctx, "user_id", "u1")
+	tool := NewManageGoalTool(db)
+
+	blocked, _ := db.CreateJob(ctx, "u1", "Book physio", "")
+	db.UpdateJobStatus(ctx, blocked, "blocked", "no free slots")
+	if out, _ := tool.Execute(ctx, `{"action": "create", "title": "Run", "job_ids": [99]}`); !strings.Contains(out, "job 99 not found") {
+		t.Errorf("create with unknown job = %s", out)
+	}
+	out, _ := tool.Execute(ctx, `{"action": "create", "title": "Run a half marathon", "target_date": "in 10 weeks",
+		"metrics": [{"name": "Long run", "target": 21, "current": 8, "unit": "km"}], "job_ids": [1]}`)
+	var created struct {
+		ID           int64 `json:"id"`
+		ReviewPlanID int64 `json:"review_plan_id"`
+	}
+	if err := json.Unmarshal([]byte(out), &created); err != nil || created.ID == 0 || created.ReviewPlanID == 0 {
+		t.Fatalf("create = %s", out)
+	}
+
+	// The weekly review is scheduled once, as an autonomous agent prompt
+	out, _ = tool.Execute(ctx, `{"action": "create", "title": "Read 12 books"}`)
+	if !strings.Contains(out, `"review_plan_id": 1`) {
+		t.Errorf("second goal = %s", out)
+	}
+	plans, _ := db.ListPlans(ctx, "u1", "")
+	if len(plans) != 1 || plans[0].ScheduleType != "weekly" || !strings.Contains(plans[0].ActionPayload, `"autonomous":true`) {
+		t.Fatalf("plans = %+v", plans)
+	}
+	if out, _ := tool.Execute(ctx, `{"action": "schedule_review", "run_at": "fri 17:00"}`); !strings.Contains(out, "scheduled") {
+		t.Fatalf("schedule_review = %s", out)
+	}
+	if plans, _ := db.ListPlans(ctx, "u1", ""); len(plans) != 1 || plans[0].ScheduleValue != "fri 17:00" {
+		t.Errorf("rescheduled plans = %+v", plans)
+	}
+
+	out, _ = tool.Execute(ctx, `{"action": "update", "id": 1, "metrics": [{"name": "long run", "current": 14}]}`)
+	if !strings.Contains(out, `"summary":"67% of target; 70 days left; 0 of 1 jobs closed; 1 blockers"`) {
+		t.Errorf("update = %s", out)
+	}
+
+	out, _ = tool.Execute(ctx, `{"action": "review", "id": 1}`)
+	var review struct {
+		Goals []GoalReview `json:"goals"`
+	}
+	if err := json.Unmarshal([]byte(out), &review); err != nil || len(review.Goals) != 1 {
+		t.Fatalf("review = %s", out)
+	}
+	r := review.Goals[0]
+	if len(r.Goal.Metrics) != 1 || r.Goal.Metrics[0].Unit != "km" || len(r.Blockers) != 1 || !strings.Contains(r.Blockers[0], "no free slots") {
+		t.Errorf("review = %+v", r)
+	}
+
+	if out, _ := tool.Execute(ctx, `{"action": "update", "id": 2, "status": "achieved"}`); !strings.Contains(out, "updated") {
+		t.Errorf("achieve = %s", out)
+	}
+	if out, _ := tool.Execute(ctx, `{"action": "list", "status": "active"}`); strings.Contains(out, "Read 12 books") {
+		t.Errorf("achieved goal still active: %s", out)
+	}
+}
+
+func TestReviewGoalFlagsBehindAndOverdue(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := &store.Goal{
+		UserID: "u1", Title: "Save", Status: store.GoalActive, TargetDate: "2026-01-10", CreatedAt: created,
+		Metrics: []store.GoalMetric{{Name: "saved", Target: 1000, Current: 200}},
+	}
+	r, err := ReviewGoal(ctx, db, g, created.Add(6*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Behind || r.Overdue || *r.DaysLeft != 3 {
+		t.Errorf("mid-way review = %+v", r)
+	}
+	r, _ = ReviewGoal(ctx, db, g, created.Add(15*24*time.Hour))
+	if !r.Overdue || r.DaysLeft != nil || len(r.Blockers) != 1 {
+		t.Errorf("late review = %+v", r)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/hattiebot/hattiebot/internal/openrouter"
 	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/timeparse"
 )
 
 type ManageJobTool struct {
@@ -31,13 +32,14 @@ func (t *ManageJobTool) Definition() openrouter.ToolDefinition {
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"action":         map[string]interface{}{"type": "string", "enum": []string{"create", "update", "list"}, "description": "Action to perform"},
+					"action":         map[string]interface{}{"type": "string", "enum": []string{"create", "update", "list", "snooze", "set_budget"}, "description": "Action to perform"},
 					"title":          map[string]interface{}{"type": "string", "description": "Job title (for create)"},
 					"description":    map[string]interface{}{"type": "string", "description": "Job description (for create)"},
 					"id":             map[string]interface{}{"type": "integer", "description": "Job ID (for update)"},
 					"status":         map[string]interface{}{"type": "string", "enum": []string{"open", "blocked", "closed"}, "description": "New status (for update/list)"},
 					"blocked_reason": map[string]interface{}{"type": "string", "description": "Reason if blocked (for update)"},
-					"duration":       map[string]interface{}{"type": "string", "description": "Duration for snooze (e.g. 1h, 2d)"},
+					"duration":       map[string]interface{}{"type": "string", "description": "For snooze: how long (e.g. 1h, 2d) or until when (e.g. tomorrow morning, friday 9am)"},
+					"budget_usd":     map[string]interface{}{"type": "number", "description": "Cost budget in USD for set_budget; LLM calls attributed to the job stop once it is spent (0 clears)"},
 				},
 				"required": []string{"action"},
 			},
@@ -51,13 +53,14 @@ func (t *ManageJobTool) Execute(ctx context.Context, argsJSON string) (string, e
 		return ErrJSON(err), nil
 	}
 	var args struct {
-		Action        string `json:"action"`
-		Title         string `json:"title"`
-		Description   string `json:"description"`
-		ID            int64  `json:"id"`
-		Status        string `json:"status"`
-		BlockedReason string `json:"blocked_reason"`
-		Duration      string `json:"duration"` // For snooze: "1h", "2d", etc.
+		Action        string  `json:"action"`
+		Title         string  `json:"title"`
+		Description   string  `json:"description"`
+		ID            int64   `json:"id"`
+		Status        string  `json:"status"`
+		BlockedReason string  `json:"blocked_reason"`
+		Duration      string  `json:"duration"` // For snooze: "1h", "2d", etc.
+		BudgetUSD     float64 `json:"budget_usd"`
 	}
 	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 		return ErrJSON(err), nil
@@ -68,6 +71,12 @@ func (t *ManageJobTool) Execute(ctx context.Context, argsJSON string) (string, e
 		if err != nil {
 			return ErrJSON(err), nil
 		}
+		// Jobs created while working in a project belong to it
+		if projectID := CurrentProjectID(ctx, t.DB); projectID != 0 {
+			if err := t.DB.SetJobProject(ctx, userID, id, projectID); err == nil {
+				return fmt.Sprintf(`{"id": %d, "status": "created", "project_id": %d}`, id, projectID), nil
+			}
+		}
 		return fmt.Sprintf(`{"id": %d, "status": "created"}`, id), nil
 	case "update":
 		err := t.DB.UpdateJobStatus(ctx, args.ID, args.Status, args.BlockedReason)
@@ -76,16 +85,33 @@ func (t *ManageJobTool) Execute(ctx context.Context, argsJSON string) (string, e
 		}
 		return `{"status": "updated"}`, nil
 	case "snooze":
-		// Parse duration string (e.g., "1h", "2d", "30m")
-		duration, err := parseDuration(args.Duration)
+		// A delay ("1h", "2d") or a time ("tomorrow morning", "friday 9am")
+		until, err := timeparse.Until(args.Duration, time.Now(), time.Local)
 		if err != nil {
-			return ErrJSON(fmt.Errorf("invalid duration '%s': %w", args.Duration, err)), nil
+			return ErrJSON(fmt.Errorf("snooze duration: %w", err)), nil
 		}
-		until := time.Now().Add(duration)
 		if err := t.DB.SnoozeJob(ctx, args.ID, until); err != nil {
 			return ErrJSON(err), nil
 		}
 		return fmt.Sprintf(`{"status": "snoozed", "until": "%s"}`, until.Format(time.RFC3339)), nil
+	case "set_budget":
+		// Only the job's owner (or an admin) sets what may be spent on it
+		job, err := t.DB.GetJob(ctx, args.ID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		role, _ := ctx.Value("user_role").(string)
+		if job == nil || (job.UserID != userID && !store.RoleAtLeast(role, store.RoleAdmin)) {
+			return ErrJSON(fmt.Errorf("job %d not found", args.ID)), nil
+		}
+		if err := t.DB.SetJobBudget(ctx, args.ID, args.BudgetUSD); err != nil {
+			return ErrJSON(err), nil
+		}
+		spent, err := t.DB.JobCost(ctx, args.ID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "budget_set", "budget_usd": %.4f, "spent_usd": %.4f}`, args.BudgetUSD, spent), nil
 	case "list":
 		jobs, err := t.DB.ListJobs(ctx, userID, args.Status)
 		if err != nil {
@@ -0,0 +1,43 @@
+package builtin
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestManageJobSetBudgetNeedsTheJobsOwner(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.GetOrCreateUser(ctx, "u1", "", "api")
+	db.GetOrCreateUser(ctx, "u2", "", "api")
+	tool := NewManageJobTool(db)
+	id, _ := db.CreateJob(ctx, "u1", "Sort the photos", "")
+
+	other := context.WithValue(ctx, "user_id", "u2")
+	if out, _ := tool.Execute(other, `{"action": "set_budget", "id": 1, "budget_usd": 100}`); !strings.Contains(out, "job 1 not found") {
+		t.Errorf("another user's job = %s", out)
+	}
+	if out, _ := tool.Execute(other, `{"action": "set_budget", "id": 99, "budget_usd": 1}`); !strings.Contains(out, "job 99 not found") {
+		t.Errorf("missing job = %s", out)
+	}
+	if j, _ := db.GetJob(ctx, id); j.BudgetUSD != nil {
+		t.Errorf("budget set by another user: %v", *j.BudgetUSD)
+	}
+
+	owner := context.WithValue(ctx, "user_id", "u1")
+	if out, _ := tool.Execute(owner, `{"action": "set_budget", "id": 1, "budget_usd": 2.5}`); !strings.Contains(out, "budget_set") {
+		t.Errorf("owner = %s", out)
+	}
+	admin := context.WithValue(context.WithValue(ctx, "user_id", "u2"), "user_role", store.RoleAdmin)
+	if out, _ := tool.Execute(admin, `{"action": "set_budget", "id": 1, "budget_usd": 5}`); !strings.Contains(out, "budget_set") {
+		t.Errorf("admin = %s", out)
+	}
+}
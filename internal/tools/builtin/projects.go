@@ -0,0 +1,408 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// CurrentProjectID returns the project the caller is working in (the one linked to this thread,
+// else the user's current project), or 0. New jobs, schedules and memories go into it.
+func CurrentProjectID(ctx context.Context, db *store.DB) int64 {
+	userID, _ := ctx.Value("user_id").(string)
+	if userID == "" || db == nil {
+		return 0
+	}
+	msg, _ := gateway.MessageFromContext(ctx)
+	return db.CurrentProjectID(ctx, userID, msg.ThreadID)
+}
+
+// projectItems are the items create_project and manage_project add or remove.
+type projectItems struct {
+	JobIDs      []int64  `json:"job_ids"`
+	PlanIDs     []int64  `json:"plan_ids"`
+	ContextDocs []string `json:"context_docs"`
+	ThreadIDs   []string `json:"thread_ids"`
+	ThisThread  bool     `json:"this_thread"`
+}
+
+var projectItemProps = map[string]interface{}{
+	"job_ids":      map[string]interface{}{"type": "array", "items": map[string]string{"type": "integer"}, "description": "Jobs (manage_job IDs)"},
+	"plan_ids":     map[string]interface{}{"type": "array", "items": map[string]string{"type": "integer"}, "description": "Scheduled plans (manage_schedule IDs)"},
+	"context_docs": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Context document titles; their content is loaded while the project is current"},
+	"thread_ids":   map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Conversation threads; in a linked thread the project is current"},
+	"this_thread":  map[string]interface{}{"type": "boolean", "description": "Link the current conversation thread"},
+}
+
+// apply links (or with remove, unlinks) the items to project p. Jobs and plans must be the user's.
+func (it projectItems) apply(ctx context.Context, db *store.DB, userID string, p *store.Project, remove bool) error {
+	target := p.ID
+	if remove {
+		target = 0
+	}
+	for _, id := range it.JobIDs {
+		if err := db.SetJobProject(ctx, userID, id, target); err != nil {
+			return err
+		}
+	}
+	for _, id := range it.PlanIDs {
+		if err := db.SetPlanProject(ctx, userID, id, target); err != nil {
+			return err
+		}
+	}
+	threads := it.ThreadIDs
+	if it.ThisThread {
+		msg, ok := gateway.MessageFromContext(ctx)
+		if !ok || msg.ThreadID == "" {
+			return fmt.Errorf("no current thread to link")
+		}
+		threads = append(threads, msg.ThreadID)
+	}
+	link := func(kind, ref string) error {
+		if remove {
+			return db.RemoveProjectItem(ctx, p.ID, kind, ref)
+		}
+		return db.AddProjectItem(ctx, p.ID, kind, ref)
+	}
+	for _, title := range it.ContextDocs {
+		if !remove {
+			if doc, err := db.GetContextDoc(ctx, title); err != nil || doc == nil {
+				return fmt.Errorf("context document %q not found", title)
+			}
+		}
+		if err := link(store.ProjectItemContextDoc, title); err != nil {
+			return err
+		}
+	}
+	for _, t := range threads {
+		if err := link(store.ProjectItemThread, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateProjectTool creates a project from existing jobs, schedules, context docs and threads.
+type CreateProjectTool struct {
+	DB *store.DB
+}
+
+func NewCreateProjectTool(db *store.DB) *CreateProjectTool {
+	return &CreateProjectTool{DB: db}
+}
+
+func (t *CreateProjectTool) Name() string {
+	return "create_project"
+}
+
+func (t *CreateProjectTool) Definition() openrouter.ToolDefinition {
+	props := map[string]interface{}{
+		"name":        map[string]interface{}{"type": "string", "description": "Short unique project name"},
+		"description": map[string]interface{}{"type": "string", "description": "Goal of the project and what done looks like"},
+		"current":     map[string]interface{}{"type": "boolean", "description": "Make it the current project (default true)"},
+	}
+	for k, v := range projectItemProps {
+		props[k] = v
+	}
+	return openrouter.ToolDefinition{
+		Type: "function",
+		Function: openrouter.FunctionSpec{
+			Name:        "create_project",
+			Description: "Create a project grouping related jobs, scheduled plans, context documents and threads, with its own memory namespace. While a project is current its state is in your prompt, and new jobs, schedules and memories go into it.",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": props,
+				"required":   []string{"name"},
+			},
+		},
+	}
+}
+
+func (t *CreateProjectTool) Execute(ctx context.Context, argsJSON string) (string, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var args struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Current     *bool  `json:"current"`
+		projectItems
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	id, err := t.DB.CreateProject(ctx, userID, args.Name, args.Description)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	p, err := t.DB.GetProject(ctx, id)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	if err := args.projectItems.apply(ctx, t.DB, userID, p, false); err != nil {
+		return ErrJSON(fmt.Errorf("project #%d created, but adding items failed: %w", id, err)), nil
+	}
+	current := args.Current == nil || *args.Current
+	if current {
+		if err := t.DB.SetCurrentProject(ctx, userID, id); err != nil {
+			return ErrJSON(err), nil
+		}
+	}
+	return fmt.Sprintf(`{"id": %d, "status": "created", "current": %v}`, id, current), nil
+}
+
+// ManageProjectTool lists projects, switches the current one, and adds or removes items.
+type ManageProjectTool struct {
+	DB *store.DB
+}
+
+func NewManageProjectTool(db *store.DB) *ManageProjectTool {
+	return &ManageProjectTool{DB: db}
+}
+
+func (t *ManageProjectTool) Name() string {
+	return "manage_project"
+}
+
+func (t *ManageProjectTool) Definition() openrouter.ToolDefinition {
+	props := map[string]interface{}{
+		"action":      map[string]interface{}{"type": "string", "enum": []string{"list", "switch", "add", "remove", "update", "delete"}, "description": "switch makes project current (project \"none\" clears it); add/remove link or unlink items; update sets description/status; delete keeps the items, without a project"},
+		"project":     map[string]interface{}{"type": "string", "description": "Project name or ID (default: the current project)"},
+		"description": map[string]interface{}{"type": "string", "description": "New description (for update)"},
+		"status":      map[string]interface{}{"type": "string", "enum": []string{store.ProjectActive, store.ProjectDone, store.ProjectArchived}, "description": "New status (for update) or filter (for list)"},
+	}
+	for k, v := range projectItemProps {
+		props[k] = v
+	}
+	return openrouter.ToolDefinition{
+		Type: "function",
+		Function: openrouter.FunctionSpec{
+			Name:        "manage_project",
+			Description: "List projects, switch the current project, add or remove jobs, scheduled plans, context documents and threads, or update and delete a project.",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": props,
+				"required":   []string{"action"},
+			},
+		},
+	}
+}
+
+func (t *ManageProjectTool) Execute(ctx context.Context, argsJSON string) (string, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var args struct {
+		Action      string `json:"action"`
+		Project     string `json:"project"`
+		Description string `json:"description"`
+		Status      string `json:"status"`
+		projectItems
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if args.Action == "list" {
+		projects, err := t.DB.ListProjects(ctx, userID, args.Status)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(projects)
+		return string(b), nil
+	}
+	if args.Action == "switch" && strings.EqualFold(args.Project, "none") {
+		if err := t.DB.SetCurrentProject(ctx, userID, 0); err != nil {
+			return ErrJSON(err), nil
+		}
+		return `{"status": "no current project"}`, nil
+	}
+	p, err := findProject(ctx, t.DB, userID, args.Project)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "switch":
+		if err := t.DB.SetCurrentProject(ctx, userID, p.ID); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "switched", "id": %d}`, p.ID), nil
+	case "add", "remove":
+		if err := args.projectItems.apply(ctx, t.DB, userID, p, args.Action == "remove"); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "updated", "id": %d}`, p.ID), nil
+	case "update":
+		if err := t.DB.UpdateProject(ctx, p.ID, args.Description, args.Status); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "updated", "id": %d}`, p.ID), nil
+	case "delete":
+		if err := t.DB.DeleteProject(ctx, p.ID); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "deleted", "id": %d}`, p.ID), nil
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s", args.Action)), nil
+	}
+}
+
+// findProject resolves ref (name or ID) among userID's projects; "" is the current project.
+func findProject(ctx context.Context, db *store.DB, userID, ref string) (*store.Project, error) {
+	if strings.TrimSpace(ref) == "" {
+		msg, _ := gateway.MessageFromContext(ctx)
+		p, err := db.CurrentProject(ctx, userID, msg.ThreadID)
+		if err == nil && p == nil {
+			err = fmt.Errorf("no current project; name one")
+		}
+		return p, err
+	}
+	p, err := db.FindProject(ctx, userID, ref)
+	if err == nil && p == nil {
+		err = fmt.Errorf("project %q not found", ref)
+	}
+	return p, err
+}
+
+// ProjectStatus summarizes a project's progress across its items.
+type ProjectStatus struct {
+	Project     store.Project  `json:"project"`
+	Summary     string         `json:"summary"`
+	JobCounts   map[string]int `json:"job_counts"`
+	Jobs        []projectJob   `json:"jobs"`
+	Plans       []projectPlan  `json:"plans"`
+	ContextDocs []string       `json:"context_docs,omitempty"`
+	Threads     []string       `json:"threads,omitempty"`
+	Memories    int            `json:"memories"`
+	SpentUSD    float64        `json:"spent_usd"`
+}
+
+type projectJob struct {
+	ID            int64  `json:"id"`
+	Title         string `json:"title"`
+	Status        string `json:"status"`
+	BlockedReason string `json:"blocked_reason,omitempty"`
+}
+
+type projectPlan struct {
+	ID          int64      `json:"id"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	NextRunAt   *time.Time `json:"next_run_at,omitempty"`
+	LastRun     string     `json:"last_run,omitempty"` // status of the latest run
+}
+
+// BuildProjectStatus collects the state of project p.
+func BuildProjectStatus(ctx context.Context, db *store.DB, p *store.Project) (*ProjectStatus, error) {
+	st := &ProjectStatus{Project: *p, JobCounts: map[string]int{}, Jobs: []projectJob{}, Plans: []projectPlan{}}
+	jobs, err := db.ProjectJobs(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range jobs {
+		st.JobCounts[j.Status]++
+		st.Jobs = append(st.Jobs, projectJob{ID: j.ID, Title: j.Title, Status: j.Status, BlockedReason: j.BlockedReason})
+		if cost, err := db.JobCost(ctx, j.ID); err == nil {
+			st.SpentUSD += cost
+		}
+	}
+	plans, err := db.ProjectPlans(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, pl := range plans {
+		pp := projectPlan{ID: pl.ID, Description: pl.Description, Status: pl.Status, NextRunAt: pl.NextRunAt}
+		if runs, err := db.ListPlanRuns(ctx, pl.ID, 1); err == nil && len(runs) > 0 {
+			pp.LastRun = runs[0].Status
+		}
+		st.Plans = append(st.Plans, pp)
+	}
+	if st.ContextDocs, err = db.ProjectItems(ctx, p.ID, store.ProjectItemContextDoc); err != nil {
+		return nil, err
+	}
+	if st.Threads, err = db.ProjectItems(ctx, p.ID, store.ProjectItemThread); err != nil {
+		return nil, err
+	}
+	if st.Memories, err = db.ProjectMemoryCount(ctx, p.ID); err != nil {
+		return nil, err
+	}
+
+	st.Summary = fmt.Sprintf("%d of %d jobs closed", st.JobCounts["closed"], len(jobs))
+	if n := st.JobCounts["blocked"]; n > 0 {
+		st.Summary += fmt.Sprintf(", %d blocked", n)
+	}
+	if n := st.JobCounts[store.AwaitingInput]; n > 0 {
+		st.Summary += fmt.Sprintf(", %d waiting for the user", n)
+	}
+	active := 0
+	for _, pl := range plans {
+		if pl.Status == "active" {
+			active++
+		}
+	}
+	st.Summary += fmt.Sprintf("; %d active schedules", active)
+	return st, nil
+}
+
+// ProjectStatusTool reports a project's progress across its items.
+type ProjectStatusTool struct {
+	DB *store.DB
+}
+
+func NewProjectStatusTool(db *store.DB) *ProjectStatusTool {
+	return &ProjectStatusTool{DB: db}
+}
+
+func (t *ProjectStatusTool) Name() string {
+	return "project_status"
+}
+
+func (t *ProjectStatusTool) Definition() openrouter.ToolDefinition {
+	return openrouter.ToolDefinition{
+		Type: "function",
+		Function: openrouter.FunctionSpec{
+			Name:        "project_status",
+			Description: "Summarize a project's progress: its jobs by status (blocked reasons included), scheduled plans with their next and last runs, context documents, threads, memories and LLM spend.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"project": map[string]interface{}{"type": "string", "description": "Project name or ID (default: the current project)"},
+				},
+			},
+		},
+		Policy: "safe",
+	}
+}
+
+func (t *ProjectStatusTool) Execute(ctx context.Context, argsJSON string) (string, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var args struct {
+		Project string `json:"project"`
+	}
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return ErrJSON(err), nil
+		}
+	}
+	p, err := findProject(ctx, t.DB, userID, args.Project)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	st, err := BuildProjectStatus(ctx, t.DB, p)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	b, _ := json.MarshalIndent(st, "", "  ")
+	return string(b), nil
+}
@@ -0,0 +1,57 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestProjectTools(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.GetOrCreateUser(ctx, "u1", "", "api")
+	ctx = context.WithValue(ctx, "user_id", "u1")
+	ctx = gateway.WithMessage(ctx, gateway.Message{SenderID: "u1", Channel: "api", ThreadID: "t1"})
+
+	existing, _ := db.CreateJob(ctx, "u1", "Buy domain", "")
+	db.CreateContextDoc(ctx, "Brand guide", "Use green.", "colors")
+	out, _ := NewCreateProjectTool(db).Execute(ctx, `{"name": "Website", "description": "Relaunch", "job_ids": [1], "context_docs": ["Brand guide"], "this_thread": true}`)
+	if !strings.Contains(out, `"status": "created"`) {
+		t.Fatalf("create_project = %s", out)
+	}
+	if out, _ := NewCreateProjectTool(db).Execute(ctx, `{"name": "Broken", "context_docs": ["Missing"]}`); !strings.Contains(out, "not found") {
+		t.Errorf("missing doc = %s", out)
+	}
+
+	// New jobs join the current project
+	out, _ = NewManageJobTool(db).Execute(ctx, `{"action": "create", "title": "Write copy"}`)
+	if !strings.Contains(out, `"project_id": 1`) {
+		t.Errorf("manage_job create = %s", out)
+	}
+	db.UpdateJobStatus(ctx, existing, "closed", "")
+
+	out, _ = NewProjectStatusTool(db).Execute(ctx, `{}`)
+	var st ProjectStatus
+	if err := json.Unmarshal([]byte(out), &st); err != nil {
+		t.Fatalf("project_status = %s", out)
+	}
+	if st.Project.Name != "Website" || st.Summary != "1 of 2 jobs closed; 0 active schedules" || len(st.ContextDocs) != 1 || len(st.Threads) != 1 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// Removing the thread and clearing the current project leaves no project in this thread
+	NewManageProjectTool(db).Execute(ctx, `{"action": "remove", "project": "website", "this_thread": true}`)
+	NewManageProjectTool(db).Execute(ctx, `{"action": "switch", "project": "none"}`)
+	if out, _ := NewProjectStatusTool(db).Execute(ctx, `{}`); !strings.Contains(out, "no current project") {
+		t.Errorf("status without project = %s", out)
+	}
+}
@@ -2,6 +2,8 @@ package builtin
 
 import (
 	"context"
+	"sort"
+	"sync"
 
 	"github.com/hattiebot/hattiebot/internal/openrouter"
 )
@@ -13,10 +15,43 @@ type Tool interface {
 	Execute(ctx context.Context, argsJSON string) (string, error)
 }
 
-// Registry holds all registered built-in tools.
-var Registry = map[string]Tool{}
+// registry holds all registered built-in tools. Plugins are registered and removed while the bot
+// runs, so access goes through mu.
+var (
+	mu       sync.RWMutex
+	registry = map[string]Tool{}
+)
 
-// Register adds a tool to the registry.
+// Register adds a tool to the registry, replacing one of the same name.
 func Register(t Tool) {
-	Registry[t.Name()] = t
+	mu.Lock()
+	defer mu.Unlock()
+	registry[t.Name()] = t
+}
+
+// Unregister removes a tool from the registry.
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(registry, name)
+}
+
+// Lookup returns the registered tool with the given name.
+func Lookup(name string) (Tool, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	t, ok := registry[name]
+	return t, ok
+}
+
+// All returns the registered tools sorted by name.
+func All() []Tool {
+	mu.RLock()
+	defer mu.RUnlock()
+	tools := make([]Tool, 0, len(registry))
+	for _, t := range registry {
+		tools = append(tools, t)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name() < tools[j].Name() })
+	return tools
 }
@@ -0,0 +1,99 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/websearch"
+)
+
+// SearchSettings configures the search_web provider.
+type SearchSettings struct {
+	Provider string // searxng, brave or tavily
+	URL      string // SearxNG instance; optional API endpoint override for the others
+	// APIKeySecret is a secret ref resolved per search: "env:VAR", "local:Name", or a title in
+	// the default secret store. Optional for SearxNG.
+	APIKeySecret string
+}
+
+// SearchWebTool searches the web with the configured provider.
+type SearchWebTool struct {
+	Search  SearchSettings
+	Secrets SecretLookup
+	Client  *http.Client // nil = 30 second timeout
+}
+
+func NewSearchWebTool(settings SearchSettings, secrets SecretLookup) *SearchWebTool {
+	return &SearchWebTool{Search: settings, Secrets: secrets}
+}
+
+func (t *SearchWebTool) Name() string {
+	return "search_web"
+}
+
+func (t *SearchWebTool) Definition() openrouter.ToolDefinition {
+	return openrouter.ToolDefinition{
+		Type: "function",
+		Function: openrouter.FunctionSpec{
+			Name:        "search_web",
+			Description: "Search the web with the configured search provider (SearxNG, Brave or Tavily). Returns titles, URLs and snippets; fetch a page yourself when the snippet is not enough.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query":      map[string]interface{}{"type": "string", "description": "Search query"},
+					"count":      map[string]interface{}{"type": "integer", "description": fmt.Sprintf("Results to return (default 5, max %d)", websearch.MaxResults)},
+					"time_range": map[string]interface{}{"type": "string", "enum": []string{"day", "week", "month", "year"}, "description": "Only results from the last day, week, month or year (optional)"},
+					"language":   map[string]interface{}{"type": "string", "description": "Result language code, e.g. en or de (optional; not supported by Tavily)"},
+				},
+				"required": []string{"query"},
+			},
+		},
+		Policy: "safe",
+	}
+}
+
+func (t *SearchWebTool) Execute(ctx context.Context, argsJSON string) (string, error) {
+	var args struct {
+		Query     string `json:"query"`
+		Count     int    `json:"count"`
+		TimeRange string `json:"time_range"`
+		Language  string `json:"language"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if args.Query = strings.TrimSpace(args.Query); args.Query == "" {
+		return ErrJSON(fmt.Errorf("query is required")), nil
+	}
+	opts := websearch.Options{Count: args.Count, TimeRange: args.TimeRange, Language: args.Language}
+	if err := opts.Normalize(); err != nil {
+		return ErrJSON(err), nil
+	}
+	if t.Search.Provider == "" {
+		return ErrJSON(fmt.Errorf("web search not configured (set HATTIEBOT_SEARCH_PROVIDER to searxng, brave or tavily)")), nil
+	}
+	var apiKey string
+	if t.Search.APIKeySecret != "" {
+		if t.Secrets == nil {
+			return ErrJSON(fmt.Errorf("no secret store to resolve the search API key")), nil
+		}
+		var err error
+		if apiKey, err = t.Secrets(t.Search.APIKeySecret); err != nil {
+			return ErrJSON(fmt.Errorf("search API key: %w", err)), nil
+		}
+	}
+	provider, err := websearch.New(t.Search.Provider, t.Search.URL, apiKey, t.Client)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	results, err := provider.Search(ctx, args.Query, opts)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	b, _ := json.Marshal(map[string]interface{}{"provider": provider.Name(), "query": args.Query, "results": results})
+	return string(b), nil
+}
@@ -0,0 +1,341 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+)
+
+// SMTPSettings configures outbound mail for send_email.
+type SMTPSettings struct {
+	Host     string
+	Port     int
+	Username string
+	// PasswordSecret is a secret ref resolved at send time: "env:VAR", "local:Name", or a title in
+	// the default secret store.
+	PasswordSecret string
+	From           string
+	TLS            string // "starttls" (default), "tls", or "none"
+}
+
+// SecretLookup resolves a secret reference such as "env:VAR", "local:Name", or a bare name in the
+// default source.
+type SecretLookup func(ref string) (string, error)
+
+// maxEmailAttachmentBytes caps the total size of attachments per email.
+const maxEmailAttachmentBytes = 20 << 20
+
+// SendEmailTool sends plain-text email (with optional workspace file attachments) via SMTP.
+type SendEmailTool struct {
+	SMTP         SMTPSettings
+	Secrets      SecretLookup
+	WorkspaceDir string // attachments must resolve inside this directory
+}
+
+func NewSendEmailTool(smtpSettings SMTPSettings, secrets SecretLookup, workspaceDir string) *SendEmailTool {
+	return &SendEmailTool{SMTP: smtpSettings, Secrets: secrets, WorkspaceDir: workspaceDir}
+}
+
+func (t *SendEmailTool) Name() string {
+	return "send_email"
+}
+
+func (t *SendEmailTool) Definition() openrouter.ToolDefinition {
+	return openrouter.ToolDefinition{
+		Type: "function",
+		Function: openrouter.FunctionSpec{
+			Name:        "send_email",
+			Description: "Send an email via the configured SMTP server, e.g. scheduled digests, exports, or alerts to addresses that are not chat users. Attachments are workspace file paths.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"to":          map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Recipient addresses"},
+					"cc":          map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "CC addresses (optional)"},
+					"subject":     map[string]interface{}{"type": "string", "description": "Subject line"},
+					"body":        map[string]interface{}{"type": "string", "description": "Plain-text body"},
+					"attachments": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Workspace file paths to attach (optional)"},
+				},
+				"required": []string{"to", "subject", "body"},
+			},
+		},
+		Policy: "restricted",
+	}
+}
+
+type emailArgs struct {
+	To          []string `json:"to"`
+	Cc          []string `json:"cc"`
+	Subject     string   `json:"subject"`
+	Body        string   `json:"body"`
+	Attachments []string `json:"attachments"`
+}
+
+type emailAttachment struct {
+	Name string
+	Data []byte
+}
+
+func (t *SendEmailTool) Execute(ctx context.Context, argsJSON string) (string, error) {
+	if t.SMTP.Host == "" || t.SMTP.From == "" {
+		return ErrJSON(fmt.Errorf("SMTP not configured (set HATTIEBOT_SMTP_HOST and HATTIEBOT_SMTP_FROM)")), nil
+	}
+	var args emailArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if len(args.To) == 0 {
+		return ErrJSON(fmt.Errorf("to required")), nil
+	}
+	from, err := mail.ParseAddress(t.SMTP.From)
+	if err != nil {
+		return ErrJSON(fmt.Errorf("invalid smtp_from: %w", err)), nil
+	}
+	to, err := parseAddressList(args.To)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	cc, err := parseAddressList(args.Cc)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	attachments, err := t.readAttachments(args.Attachments)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	msg, err := buildEmail(from, to, cc, args.Subject, args.Body, attachments, time.Now())
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+
+	password := ""
+	if t.SMTP.PasswordSecret != "" {
+		if t.Secrets == nil {
+			return ErrJSON(fmt.Errorf("secret store not configured for SMTP password")), nil
+		}
+		if password, err = t.Secrets(t.SMTP.PasswordSecret); err != nil {
+			return ErrJSON(fmt.Errorf("resolving SMTP password: %w", err)), nil
+		}
+	}
+
+	var rcpts []string
+	for _, a := range append(to, cc...) {
+		rcpts = append(rcpts, a.Address)
+	}
+	if err := t.deliver(ctx, password, from.Address, rcpts, msg); err != nil {
+		return ErrJSON(err), nil
+	}
+	out, _ := json.Marshal(map[string]interface{}{"status": "sent", "recipients": len(rcpts), "attachments": len(attachments)})
+	return string(out), nil
+}
+
+func parseAddressList(in []string) ([]*mail.Address, error) {
+	var out []*mail.Address
+	for _, s := range in {
+		a, err := mail.ParseAddress(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", s, err)
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+func (t *SendEmailTool) readAttachments(paths []string) ([]emailAttachment, error) {
+	var out []emailAttachment
+	total := 0
+	for _, p := range paths {
+		full := p
+		if !filepath.IsAbs(full) {
+			full = filepath.Join(t.WorkspaceDir, p)
+		}
+		full = filepath.Clean(full)
+		if rel, err := filepath.Rel(t.WorkspaceDir, full); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("attachment %q is outside the workspace", p)
+		}
+		data, err := os.ReadFile(full)
+		if err != nil {
+			return nil, fmt.Errorf("attachment %q: %w", p, err)
+		}
+		total += len(data)
+		if total > maxEmailAttachmentBytes {
+			return nil, fmt.Errorf("attachments exceed %d MB", maxEmailAttachmentBytes>>20)
+		}
+		out = append(out, emailAttachment{Name: filepath.Base(full), Data: data})
+	}
+	return out, nil
+}
+
+// buildEmail renders an RFC 5322 message: quoted-printable text, multipart/mixed when there are attachments.
+func buildEmail(from *mail.Address, to, cc []*mail.Address, subject, body string, attachments []emailAttachment, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", from.String())
+	header("To", joinAddresses(to))
+	if len(cc) > 0 {
+		header("Cc", joinAddresses(cc))
+	}
+	// Strip CR/LF so the subject cannot inject headers.
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+
+	if len(attachments) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(part, body); err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		ctype := mime.TypeByExtension(filepath.Ext(a.Name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {ctype},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		enc := base64.StdEncoding.EncodeToString(a.Data)
+		for len(enc) > 76 {
+			fmt.Fprintf(part, "%s\r\n", enc[:76])
+			enc = enc[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", enc)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func joinAddresses(list []*mail.Address) string {
+	s := make([]string, len(list))
+	for i, a := range list {
+		s[i] = a.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+func messageID(fromAddr string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	domain := "localhost"
+	if i := strings.LastIndex(fromAddr, "@"); i >= 0 {
+		domain = fromAddr[i+1:]
+	}
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// deliver sends msg over SMTP honoring the TLS mode; the dial respects ctx's deadline.
+func (t *SendEmailTool) deliver(ctx context.Context, password, from string, rcpts []string, msg []byte) error {
+	port := t.SMTP.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(t.SMTP.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: t.SMTP.Host}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if t.SMTP.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp connect: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, t.SMTP.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer c.Close()
+
+	if t.SMTP.TLS == "" || t.SMTP.TLS == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server does not offer STARTTLS (set smtp_tls to \"tls\" or \"none\")")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if t.SMTP.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", t.SMTP.Username, password, t.SMTP.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, r := range rcpts {
+		if err := c.Rcpt(r); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s: %w", r, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp send: %w", err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,64 @@
+package builtin
+
+import (
+	"context"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildEmail(t *testing.T) {
+	from := &mail.Address{Name: "Hattie", Address: "bot@example.com"}
+	to := []*mail.Address{{Address: "ops@example.org"}}
+	now := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	raw, err := buildEmail(from, to, nil, "Daily digest\r\nBcc: evil@x.y", "Héllo\nline two", nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("unparseable message: %v\n%s", err, raw)
+	}
+	if msg.Header.Get("Bcc") != "" {
+		t.Error("subject newline injected a header")
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Daily digest  Bcc: evil@x.y" {
+		t.Errorf("subject = %q", subject)
+	}
+	if msg.Header.Get("To") != "<ops@example.org>" {
+		t.Errorf("to = %q", msg.Header.Get("To"))
+	}
+
+	raw, err = buildEmail(from, to, nil, "Export", "see attached", []emailAttachment{{Name: "report.csv", Data: []byte("a,b\n1,2\n")}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), "multipart/mixed") || !strings.Contains(string(raw), `filename=report.csv`) {
+		t.Errorf("attachment missing:\n%s", raw)
+	}
+}
+
+func TestSendEmailRejectsAttachmentOutsideWorkspace(t *testing.T) {
+	ws := t.TempDir()
+	if err := os.WriteFile(filepath.Join(ws, "ok.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tool := NewSendEmailTool(SMTPSettings{}, nil, ws)
+	if _, err := tool.readAttachments([]string{"ok.txt"}); err != nil {
+		t.Errorf("workspace file rejected: %v", err)
+	}
+	if _, err := tool.readAttachments([]string{"../etc/passwd"}); err == nil {
+		t.Error("expected path outside workspace to be rejected")
+	}
+
+	out, _ := tool.Execute(context.Background(), `{"to":["a@b.c"],"subject":"s","body":"b"}`)
+	if !strings.Contains(out, "SMTP not configured") {
+		t.Errorf("expected not-configured error, got %s", out)
+	}
+}
@@ -0,0 +1,83 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/timeparse"
+)
+
+// UsageReportTool reports LLM token/cost usage attributed to jobs, plans, models, or users.
+type UsageReportTool struct {
+	DB *store.DB
+}
+
+func NewUsageReportTool(db *store.DB) *UsageReportTool {
+	return &UsageReportTool{DB: db}
+}
+
+func (t *UsageReportTool) Name() string {
+	return "usage_report"
+}
+
+func (t *UsageReportTool) Definition() openrouter.ToolDefinition {
+	return openrouter.ToolDefinition{
+		Type: "function",
+		Function: openrouter.FunctionSpec{
+			Name:        "usage_report",
+			Description: "Report LLM token and cost usage grouped by job, scheduled plan, model, or user. Use to answer questions like 'what did the nightly triage cost this month?'.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"group_by": map[string]interface{}{"type": "string", "enum": []string{"job", "plan", "model", "user"}, "description": "Grouping (default: job)"},
+					"since":    map[string]interface{}{"type": "string", "description": "Look-back window (e.g. 24h, 7d, 30d; default 30d)"},
+				},
+			},
+		},
+		Policy: "safe",
+	}
+}
+
+func (t *UsageReportTool) Execute(ctx context.Context, argsJSON string) (string, error) {
+	var args struct {
+		GroupBy string `json:"group_by"`
+		Since   string `json:"since"`
+	}
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return ErrJSON(err), nil
+		}
+	}
+	if args.GroupBy == "" {
+		args.GroupBy = "job"
+	}
+	if args.Since == "" {
+		args.Since = "30d"
+	}
+	since, err := timeparse.Since(args.Since, time.Now())
+	if err != nil {
+		return ErrJSON(fmt.Errorf("since: %w", err)), nil
+	}
+	rows, err := t.DB.SummarizeUsage(ctx, args.GroupBy, since)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var total float64
+	for _, r := range rows {
+		total += r.CostUSD
+	}
+	if rows == nil {
+		rows = []store.UsageSummary{}
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"group_by":       args.GroupBy,
+		"since":          since.Format(time.RFC3339),
+		"total_cost_usd": total,
+		"groups":         rows,
+	})
+	return string(b), nil
+}
@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// maxSubmindWait caps check_submind's wait_seconds so one call stays within the tool timeout.
+const maxSubmindWait = 10 * time.Minute
+
+// submindPollInterval is how often check_submind re-reads sessions while waiting.
+var submindPollInterval = 500 * time.Millisecond
+
+// submindTask is one entry of spawn_submind's tasks argument.
+type submindTask struct {
+	Mode string `json:"mode"`
+	Task string `json:"task"`
+}
+
+// startSubminds queues tasks on the background runner and returns their session IDs.
+func (e *Executor) startSubminds(ctx context.Context, userID string, tasks []submindTask) (string, error) {
+	async, ok := e.Spawner.(core.AsyncSubmindSpawner)
+	if !ok {
+		return ErrJSON(fmt.Errorf("background sub-minds are not available")), nil
+	}
+	for _, t := range tasks {
+		if t.Mode == "" || t.Task == "" {
+			return ErrJSON(fmt.Errorf("every task needs a mode and a task")), nil
+		}
+	}
+	ids := make([]int64, 0, len(tasks))
+	for _, t := range tasks {
+		id, err := async.StartSubmind(ctx, userID, t.Mode, t.Task)
+		if err != nil {
+			for _, started := range ids {
+				async.CancelSubmind(userID, started)
+			}
+			return ErrJSON(err), nil
+		}
+		ids = append(ids, id)
+	}
+	out := map[string]interface{}{
+		"status":      "queued",
+		"session_ids": ids,
+		"hint":        "Call check_submind with these session_ids (and wait_seconds) to collect the results.",
+	}
+	b, _ := json.MarshalIndent(out, "", "  ")
+	return string(b), nil
+}
+
+// submindStatus is check_submind's report on one session.
+type submindStatus struct {
+	SessionID int64  `json:"session_id"`
+	Mode      string `json:"mode"`
+	Status    string `json:"status"`
+	Turns     int    `json:"turns"`
+	Output    string `json:"output,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Question  string `json:"question,omitempty"`
+}
+
+// CheckSubmindTool reports on the caller's background sub-mind sessions, optionally waiting up to
+// wait_seconds for all of them to finish, or cancels them. The outputs of finished sessions are
+// returned together so parallel sub-minds can be joined in one call.
+func (e *Executor) CheckSubmindTool(ctx context.Context, argsJSON string) (string, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var args struct {
+		SessionID   int64   `json:"session_id"`
+		SessionIDs  []int64 `json:"session_ids"`
+		WaitSeconds int     `json:"wait_seconds"`
+		Cancel      bool    `json:"cancel"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	ids := args.SessionIDs
+	if args.SessionID != 0 {
+		ids = append([]int64{args.SessionID}, ids...)
+	}
+	if len(ids) == 0 {
+		return ErrJSON(fmt.Errorf("session_id or session_ids is required")), nil
+	}
+
+	if args.Cancel {
+		async, ok := e.Spawner.(core.AsyncSubmindSpawner)
+		if !ok {
+			return ErrJSON(fmt.Errorf("background sub-minds are not available")), nil
+		}
+		for _, id := range ids {
+			async.CancelSubmind(userID, id)
+		}
+		// Give the runs a moment to record the cancellation
+		if args.WaitSeconds <= 0 {
+			args.WaitSeconds = 5
+		}
+	}
+
+	wait := time.Duration(args.WaitSeconds) * time.Second
+	if wait > maxSubmindWait {
+		wait = maxSubmindWait
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		statuses, done, err := e.submindStatuses(ctx, userID, ids)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if done || !time.Now().Before(deadline) {
+			out := map[string]interface{}{"all_done": done, "sessions": statuses}
+			b, _ := json.MarshalIndent(out, "", "  ")
+			return string(b), nil
+		}
+		select {
+		case <-ctx.Done():
+			return ErrJSON(ctx.Err()), nil
+		case <-time.After(submindPollInterval):
+		}
+	}
+}
+
+// submindStatuses loads ids (which must belong to userID); done reports that none is still
+// queued or running.
+func (e *Executor) submindStatuses(ctx context.Context, userID string, ids []int64) ([]submindStatus, bool, error) {
+	out := make([]submindStatus, 0, len(ids))
+	done := true
+	for _, id := range ids {
+		s, err := e.DB.GetSubmindSession(ctx, id, userID)
+		if err == sql.ErrNoRows {
+			return nil, false, fmt.Errorf("sub-mind session %d not found", id)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		st := submindStatus{SessionID: s.ID, Mode: s.Mode, Status: s.Status, Turns: s.Turns, Output: s.ResultOutput, Error: s.ResultError}
+		if s.Status == store.AwaitingInput && s.ThreadID != "" {
+			if p, err := e.DB.OpenPendingInput(ctx, s.ThreadID); err == nil && p != nil && p.SubmindSessionID == s.ID {
+				st.Question = p.Question
+			}
+		}
+		done = done && store.SubmindSessionDone(s.Status)
+		out = append(out, st)
+	}
+	return out, done, nil
+}
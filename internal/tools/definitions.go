@@ -5,24 +5,44 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/hattiebot/hattiebot/internal/backup"
+	"github.com/hattiebot/hattiebot/internal/briefing"
 	"github.com/hattiebot/hattiebot/internal/config"
 	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/embeddinggood"
 	"regexp"
+	"github.com/hattiebot/hattiebot/internal/egress"
+	"github.com/hattiebot/hattiebot/internal/creditmon"
+	"github.com/hattiebot/hattiebot/internal/errbudget"
+	"github.com/hattiebot/hattiebot/internal/events"
+	"github.com/hattiebot/hattiebot/internal/feeds"
 	"github.com/hattiebot/hattiebot/internal/gateway"
 	"github.com/hattiebot/hattiebot/internal/secrets"
+	"github.com/hattiebot/hattiebot/internal/selfupdate"
 	"github.com/hattiebot/hattiebot/internal/health"
+	"github.com/hattiebot/hattiebot/internal/jsonschema"
 	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/plugins"
 	"github.com/hattiebot/hattiebot/internal/registry"
+	"github.com/hattiebot/hattiebot/internal/reload"
+	"github.com/hattiebot/hattiebot/internal/sandbox"
+	"github.com/hattiebot/hattiebot/internal/scheduler"
 	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/timeparse"
 	"github.com/hattiebot/hattiebot/internal/tools/builtin"
 	"github.com/hattiebot/hattiebot/internal/tools/nextcloud"
+	"github.com/hattiebot/hattiebot/internal/worksync"
 )
 
+// maxNextcloudUpload caps the size of a workspace file upload_nextcloud_file sends.
+const maxNextcloudUpload = 100 << 20
+
 func init() {
 	registry.RegisterExecutor("default", func(cfg *config.Config, db *store.DB, client core.LLMClient) (core.ToolExecutor, error) {
 		return &Executor{
@@ -39,12 +59,65 @@ func init() {
 // Init registers dynamic built-in tools that require dependencies.
 func Init(db *store.DB) {
 	builtin.Register(builtin.NewManageJobTool(db))
+	builtin.Register(builtin.NewUsageReportTool(db))
+	builtin.Register(builtin.NewCreateProjectTool(db))
+	builtin.Register(builtin.NewManageProjectTool(db))
+	builtin.Register(builtin.NewProjectStatusTool(db))
+	builtin.Register(builtin.NewManageGoalTool(db))
+}
+
+// InitEmail registers send_email with the configured SMTP settings; the password is resolved from secretStore per send.
+func InitEmail(cfg *config.Config, secretStore *secrets.MultiStore) {
+	var lookup builtin.SecretLookup
+	if secretStore != nil {
+		lookup = secretStore.Resolve
+	}
+	builtin.Register(builtin.NewSendEmailTool(builtin.SMTPSettings{
+		Host:           cfg.SMTPHost,
+		Port:           cfg.SMTPPort,
+		Username:       cfg.SMTPUsername,
+		PasswordSecret: cfg.SMTPPasswordSecret,
+		From:           cfg.SMTPFrom,
+		TLS:            cfg.SMTPTLS,
+	}, lookup, cfg.WorkspaceDir))
+}
+
+// InitSearch registers search_web with the configured provider; the API key is resolved from secretStore per search.
+func InitSearch(cfg *config.Config, secretStore *secrets.MultiStore) {
+	var lookup builtin.SecretLookup
+	if secretStore != nil {
+		lookup = secretStore.Resolve
+	}
+	builtin.Register(builtin.NewSearchWebTool(builtin.SearchSettings{
+		Provider:     cfg.SearchProvider,
+		URL:          cfg.SearchURL,
+		APIKeySecret: cfg.SearchAPIKeySecret,
+	}, lookup))
+}
+
+// webhookFilterSchema describes a webhook route or target filter (store.WebhookCondition).
+func webhookFilterSchema(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "array",
+		"description": description + ". All conditions must hold.",
+		"items": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path":       map[string]string{"type": "string", "description": "Dot path into the JSON body (e.g. action, pull_request.base.ref, data.0.id)"},
+				"header":     map[string]string{"type": "string", "description": "Header to test instead of a path (e.g. X-GitHub-Event)"},
+				"equals":     map[string]interface{}{"description": "Value must equal this"},
+				"not_equals": map[string]interface{}{"description": "Value must not equal this"},
+				"in":         map[string]interface{}{"type": "array", "description": "Value must be one of these"},
+				"exists":     map[string]string{"type": "boolean", "description": "Value must be present (true) or absent (false)"},
+			},
+		},
+	}
 }
 
 // BuiltinToolDefs returns OpenRouter tool definitions for all built-in tools.
 func BuiltinToolDefs() []openrouter.ToolDefinition {
 	defs := []openrouter.ToolDefinition{}
-	for _, t := range builtin.Registry {
+	for _, t := range builtin.All() {
 		defs = append(defs, t.Definition())
 	}
 	
@@ -54,13 +127,13 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 			Type: "function",
 			Function: openrouter.FunctionSpec{
 				Name:        "run_terminal_cmd",
-				Description: "Execute a shell command in a configurable working directory. Capture stdout, stderr, and exit code. Sandboxing is the Docker container.",
+				Description: "Execute a shell command in a configurable working directory. Capture stdout, stderr, and exit code. Commands run under the sandbox profile mapped to the caller's trust level (sandbox.json): untrusted users get an allowlist of binaries, no network and resource limits.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"work_dir": map[string]string{"type": "string", "description": "Working directory (default: workspace root)"},
 						"command":  map[string]string{"type": "string", "description": "Shell command to run. Use environment variables (e.g. $MY_SECRET) for secrets."},
-						"env_vars": map[string]string{"type": "string", "description": "Environment variables to set. Map variable names to values (or {{secret:Title}} refs)."},
+						"env_vars": map[string]interface{}{"type": "object", "additionalProperties": map[string]string{"type": "string"}, "description": "Environment variables to set. Map variable names to values (or {{secret:Title}} refs)."},
 					},
 					"required": []string{"command"},
 				},
@@ -148,7 +221,7 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 					"type": "object",
 					"properties": map[string]interface{}{
 						"instruction": map[string]string{"type": "string", "description": "Natural language instruction for the CLI"},
-						"env_vars": map[string]string{"type": "string", "description": "Environment variables to set. Map variable names to values (or {{secret:Title}} refs)."},
+						"env_vars": map[string]interface{}{"type": "object", "additionalProperties": map[string]string{"type": "string"}, "description": "Environment variables to set. Map variable names to values (or {{secret:Title}} refs)."},
 					},
 					"required": []string{"instruction"},
 				},
@@ -158,13 +231,13 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 			Type: "function",
 			Function: openrouter.FunctionSpec{
 				Name:        "execute_registered_tool",
-				Description: "Run a tool registered in tools_registry by name. Pass JSON args; the binary receives them on stdin.",
+				Description: "Run a tool registered in tools_registry by name. Pass JSON args; a binary tool receives them on stdin, an http tool as the body of a POST (the response body is returned as stdout).",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"name": map[string]string{"type": "string", "description": "Tool name in registry"},
 						"args": map[string]interface{}{"type": "object", "description": "JSON object of arguments"},
-						"env_vars": map[string]string{"type": "string", "description": "Environment variables to set."},
+						"env_vars": map[string]interface{}{"type": "object", "additionalProperties": map[string]string{"type": "string"}, "description": "Environment variables to set."},
 					},
 					"required": []string{"name"},
 				},
@@ -174,21 +247,45 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 			Type: "function",
 			Function: openrouter.FunctionSpec{
 				Name:        "register_tool",
-				Description: "Register a new tool that you have built. The binary must exist and follow the JSON-in/JSON-out contract.",
+				Description: "Register a new tool that you have built. The binary must exist and follow the JSON-in/JSON-out contract. Its Go source is statically checked first (destructive commands, deletes of system paths, hardcoded credentials, exfiltration or policy-blocked hosts, go vet): blocking findings refuse registration with a report to fix, warnings are returned with the registration. Each registration is a new version with an archived copy of the binary: action=list_versions shows them (and automatic repair attempts), action=rollback returns to an earlier one (e.g. when a new version starts failing). type=http registers a remote service instead (e.g. GPU transcription or OCR hosted elsewhere): the arguments are POSTed to url as JSON, with an auth header whose secret is looked up at call time.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
+						"action":      map[string]interface{}{"type": "string", "enum": []string{"register", "list_versions", "rollback"}, "description": "register (default), list_versions, or rollback"},
 						"name":        map[string]string{"type": "string", "description": "Name of the tool (e.g. 'fetch_url')"},
 						"binary_path": map[string]string{"type": "string", "description": "Absolute path to the executable binary"},
 						"description": map[string]string{"type": "string", "description": "Description of what the tool does"},
 						"input_schema": map[string]string{"type": "string", "description": "JSON Schema for the arguments (optional)"},
-						"force_update": map[string]interface{}{"type": "boolean", "description": "Set to true to overwrite existing tool"},
+						"force_update": map[string]interface{}{"type": "boolean", "description": "Set to true to register a new version of an existing tool"},
+						"version":      map[string]interface{}{"type": "integer", "description": "For rollback: version to return to (default: the one before the current)"},
+						"source_dir":   map[string]string{"type": "string", "description": "Directory with the tool's Go source (default: $CONFIG_DIR/tools/<name>)"},
+						"allow_unsafe": map[string]interface{}{"type": "boolean", "description": "Admin only: register despite blocking safety findings"},
+						"type":         map[string]interface{}{"type": "string", "enum": []string{"binary", "http"}, "description": "binary (default) or http for a remote service"},
+						"url":          map[string]string{"type": "string", "description": "http: endpoint the arguments are POSTed to"},
+						"auth_secret":  map[string]string{"type": "string", "description": "http: secret reference for the auth header, e.g. local:ocr_token or env:OCR_TOKEN (the name only, not a {{secret:...}} placeholder)"},
+						"auth_header":  map[string]string{"type": "string", "description": "http: header template with {secret} (default \"Authorization: Bearer {secret}\"), e.g. \"X-API-Key: {secret}\""},
 					},
-					"required": []string{"name", "binary_path", "description"},
+					"required": []string{"name"},
 				},
 			},
 			Policy: "restricted",
 		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "read_tool_source",
+				Description: "Read the Go source of a registered tool as stored when that version was registered, with its source directory and git commit. Use it to repair a failing tool or audit what a tool does, even if the workspace copy changed or was deleted.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name":    map[string]string{"type": "string", "description": "Name of the registered tool"},
+						"version": map[string]interface{}{"type": "integer", "description": "Version to read (default: the current one)"},
+						"file":    map[string]string{"type": "string", "description": "Only return this file (e.g. main.go)"},
+					},
+					"required": []string{"name"},
+				},
+			},
+		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
@@ -204,6 +301,41 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 			},
 			Policy: "admin_only",
 		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "export_toolpack",
+				Description: "Bundle registered tools (source, description, input schema, version) into a toolpack file in the workspace, to share them with another HattieBot instance via import_toolpack. A .tar.gz path writes a tarball with a toolpack.json manifest; a .json path writes a single JSON file.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"tools":       map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Names of the tools to export (default: all registered tools)"},
+						"path":        map[string]string{"type": "string", "description": "Output path relative to workspace, ending in .tar.gz or .json (default: toolpacks/<name>.tar.gz)"},
+						"name":        map[string]string{"type": "string", "description": "Name of the toolpack"},
+						"description": map[string]string{"type": "string", "description": "What the toolpack is for"},
+					},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "import_toolpack",
+				Description: "Import tools from a toolpack made by export_toolpack: each tool is rebuilt from source into $CONFIG_DIR/tools/<name>, safety checked and contract tested like register_tool, then registered. Tools whose source matches a registered tool are reported unchanged. Admin only.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path":        map[string]string{"type": "string", "description": "Path to the toolpack (.tar.gz or .json), relative to workspace"},
+						"tools":       map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Only import these tools (default: all in the pack)"},
+						"on_conflict": map[string]interface{}{"type": "string", "enum": []string{"skip", "replace", "rename"}, "description": "When a tool with the same name but different source is registered: skip it (default), replace registers the pack's source as a new version (rollback stays available), rename imports it as <name>_imported"},
+						"dry_run":     map[string]interface{}{"type": "boolean", "description": "Only report what would be imported"},
+					},
+					"required": []string{"path"},
+				},
+			},
+			Policy: "admin_only",
+		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
@@ -213,7 +345,7 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 					"type": "object",
 					"properties": map[string]interface{}{
 						"user_id": map[string]string{"type": "string", "description": "User ID to approve"},
-						"level":   map[string]interface{}{"type": "string", "enum": []string{"trusted", "admin", "guest", "restricted", "blocked"}, "description": "New trust level (default: trusted)"},
+						"level":   map[string]interface{}{"type": "string", "enum": []string{"trusted", "guest", "restricted", "blocked"}, "description": "New trust level (default: trusted). Admin access is granted with add_admin."},
 					},
 					"required": []string{"user_id"},
 				},
@@ -239,384 +371,1237 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 			Type: "function",
 			Function: openrouter.FunctionSpec{
 				Name:        "list_users",
-				Description: "List users known to the bot (admin only).",
+				Description: "List users known to the bot with their role and trust level (admins and operators).",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"filter_level": map[string]interface{}{"type": "string", "enum": []string{"trusted", "admin", "guest", "restricted", "blocked"}, "description": "Filter by trust level"},
+						"filter_role":  map[string]interface{}{"type": "string", "enum": []string{"owner", "admin", "operator", "user"}, "description": "Filter by role"},
 					},
 				},
 			},
-			Policy: "admin_only",
+			Policy: "operator",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "manage_user_preference",
-				Description: "Persistent memory for key-value facts about the current user. Use this to remember user details (name, preferences, context).",
+				Name:        "read_audit_log",
+				Description: "Read the audit log of tool executions: who ran which tool, with which arguments (secrets redacted), from which channel, and whether it succeeded, failed, or was denied. Newest first.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"action":   map[string]interface{}{"type": "string", "enum": []string{"set", "get", "search"}, "description": "Action: set, get, or search"},
-						"key":      map[string]string{"type": "string", "description": "Unique key for the fact"},
-						"value":    map[string]string{"type": "string", "description": "The fact content (for set)"},
-						"category": map[string]string{"type": "string", "description": "Category tag (optional)"},
-						"query":    map[string]string{"type": "string", "description": "Search query"},
+						"user_id": map[string]string{"type": "string", "description": "Only calls by this user"},
+						"tool":    map[string]string{"type": "string", "description": "Only calls of this tool"},
+						"outcome": map[string]interface{}{"type": "string", "enum": []string{"ok", "error", "denied", "dry_run"}, "description": "Only calls with this outcome"},
+						"since":   map[string]string{"type": "string", "description": "Only calls within this window (e.g. 24h, 7d) or after an RFC3339 time"},
+						"limit":   map[string]string{"type": "integer", "description": "Max entries (default 50, max 500)"},
 					},
-					"required": []string{"action"},
 				},
 			},
+			Policy: "admin_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "memorize",
-				Description: "Store a text chunk into long-term vector memory.",
+				Name:        "manage_onboarding",
+				Description: "Show the setup checklist (channel connected, embedding configured, backups enabled, admin approved, first tool built), mark a step done when it was completed outside HattieBot, or dismiss/restore a step the admin wants to skip.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"content": map[string]string{"type": "string", "description": "Text to memorize"},
-						"source":  map[string]string{"type": "string", "description": "Origin of the memory (e.g. user, reflection)"},
+						"action": map[string]interface{}{"type": "string", "enum": []string{"status", "complete", "dismiss", "restore"}, "description": "Action to perform"},
+						"item":   map[string]interface{}{"type": "string", "enum": []string{"channel_connected", "embedding_configured", "backups_enabled", "admin_approved", "first_tool_built"}, "description": "Checklist item (for complete/dismiss/restore)"},
+						"note":   map[string]string{"type": "string", "description": "Optional note, e.g. how backups are handled"},
 					},
-					"required": []string{"content"},
+					"required": []string{"action"},
 				},
 			},
-			Policy: "safe",
+			Policy: "admin_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "recall_memories",
-				Description: "Search long-term memory for relevant chunks using vector similarity.",
+				Name:        "announce",
+				Description: "Post one announcement (e.g. \"maintenance tonight at 10pm\") to several rooms/channels at once, formatted for each channel, and get a per-room delivery report. Save named audiences (sets of rooms) with define_audience and send to them by name; also usable from scheduled plans via execute_tool.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"query": map[string]string{"type": "string", "description": "Search query"},
-						"limit": map[string]interface{}{"type": "integer", "description": "Max results (default 5)"},
+						"action":   map[string]interface{}{"type": "string", "enum": []string{"send", "define_audience", "list_audiences", "delete_audience"}, "description": "Action to perform (default send)"},
+						"message":  map[string]string{"type": "string", "description": "Announcement text (for send)"},
+						"audience": map[string]string{"type": "string", "description": "Saved audience name (for send, define_audience, delete_audience)"},
+						"targets": map[string]interface{}{
+							"type":        "array",
+							"description": "Rooms to post to (for send, added to the audience) or the audience's rooms (for define_audience)",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"channel":   map[string]string{"type": "string", "description": "Channel name, e.g. nextcloud_talk, discord"},
+									"thread_id": map[string]string{"type": "string", "description": "Room token / chat ID on that channel"},
+									"label":     map[string]string{"type": "string", "description": "Optional human-readable room name"},
+								},
+								"required": []string{"channel", "thread_id"},
+							},
+						},
 					},
-					"required": []string{"query"},
+					"required": []string{"action"},
 				},
 			},
-			Policy: "safe",
+			Policy: "admin_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "run_sandboxed",
-				Description: "Execute a command securely inside a disposable Docker container.",
+				Name:        "manage_permissions",
+				Description: "Grant, revoke, or list per-user tool permissions, e.g. let a trusted user run run_terminal_cmd confined to one workspace directory without making them admin. Restricted tools need the admin role or a grant.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"image":    map[string]string{"type": "string", "description": "Docker image (default: debian:bookworm-slim)"},
-						"command":  map[string]string{"type": "string", "description": "Command to run. Use env vars for secrets."},
-						"work_dir": map[string]string{"type": "string", "description": "Working directory inside container"},
-						"env_vars": map[string]string{"type": "string", "description": "Environment variables to set inside container."},
+						"action":       map[string]interface{}{"type": "string", "enum": []string{"grant", "revoke", "list"}, "description": "Action to perform"},
+						"subject_type": map[string]interface{}{"type": "string", "enum": []string{"user", "trust_level"}, "description": "Grant to one user or to everyone at a trust level (default: user)"},
+						"subject":      map[string]string{"type": "string", "description": "User ID or trust level (e.g. trusted)"},
+						"tool":         map[string]string{"type": "string", "description": "Tool to grant (grant exactly one of tool or policy)"},
+						"policy":       map[string]interface{}{"type": "string", "enum": []string{"restricted"}, "description": "Grant every tool with this policy"},
+						"work_dir":     map[string]string{"type": "string", "description": "Confine the tool's work_dir to this directory, relative to workspace (e.g. sandboxes/bob)"},
+						"id":           map[string]string{"type": "integer", "description": "Permission ID (for revoke)"},
 					},
-					"required": []string{"command"},
+					"required": []string{"action"},
 				},
 			},
-			Policy: "restricted",
+			Policy: "admin_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "manage_schedule",
-				Description: "Create, list, or delete scheduled reminders and recurring tasks. remind=message user; execute_tool=run tool directly; agent_prompt=agent reasons and acts (use autonomous=true for background tasks like 'check email and file receipts').",
+				Name:        "manage_api_tokens",
+				Description: "Create, list, or revoke bearer tokens for the HTTP API (/api/v1), the OpenAI-compatible API, /chat and the dashboard, used by other programs through the Go SDK (pkg/hattiebot). Requests with a token act as its user, with that user's trust level and role, limited by the token's scopes: read (list tools), write (send messages, call tools) and admin (admin rights, dashboard, detailed health); each includes the ones before it. The token is shown only once, at creation; only its hash is stored.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"action":         map[string]interface{}{"type": "string", "enum": []string{"create", "list", "delete", "pause"}, "description": "Action to perform"},
-						"description":    map[string]string{"type": "string", "description": "What to remind or do"},
-						"action_type":    map[string]interface{}{"type": "string", "enum": []string{"remind", "execute_tool", "agent_prompt"}, "description": "remind=message user; execute_tool=run tool; agent_prompt=agent reasons/acts"},
-						"schedule_type":  map[string]interface{}{"type": "string", "enum": []string{"once", "daily", "weekly", "hourly"}, "description": "Frequency"},
-						"run_at":         map[string]string{"type": "string", "description": "ISO datetime for 'once', or time like '09:00' for recurring"},
-						"id":             map[string]interface{}{"type": "integer", "description": "Plan ID (for delete/pause)"},
-						"prompt":         map[string]string{"type": "string", "description": "For agent_prompt: task prompt (e.g. 'Run self-reflection')"},
-						"autonomous":     map[string]string{"type": "boolean", "description": "For agent_prompt: true=run silently, notify only via notify_user"},
-						"tool":           map[string]string{"type": "string", "description": "For execute_tool: tool name (e.g. self_reflect)"},
-						"tool_args":      map[string]interface{}{"type": "object", "description": "For execute_tool: JSON args for the tool"},
+						"action":  map[string]interface{}{"type": "string", "enum": []string{"create", "list", "revoke"}, "description": "Action to perform"},
+						"user_id": map[string]string{"type": "string", "description": "For create: user the token acts as (default: you)"},
+						"name":    map[string]string{"type": "string", "description": "For create: label, e.g. the service using it"},
+						"id":      map[string]interface{}{"type": "integer", "description": "Token ID (for revoke)"},
+						"scopes":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "enum": []string{"read", "write", "admin"}}, "description": "For create: what the token may do (default all); without admin, an admin's token acts with operator rights"},
 					},
 					"required": []string{"action"},
 				},
 			},
+			Policy: "admin_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "install_skill",
-				Description: "Install a new skill or tool package (go, brew, npm).",
+				Name:        "manage_event_subscriptions",
+				Description: "Outbound webhooks: let external systems subscribe to bot events (turn.completed, tool.failed, plan.executed, user.blocked) for automation around the bot. Each event is POSTed to the subscription's URL as JSON signed with HMAC-SHA256 (X-HattieBot-Signature) and retried with backoff until it gets a 2xx. subscribe returns a generated secret once unless one is given; test sends a ping; deliveries shows recent deliveries of a subscription.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"manager": map[string]interface{}{"type": "string", "enum": []string{"go", "brew", "npm"}, "description": "Package manager to use"},
-						"package": map[string]string{"type": "string", "description": "Package name (e.g. github.com/user/repo@latest, jq)"},
+						"action":      map[string]interface{}{"type": "string", "enum": []string{"subscribe", "list", "unsubscribe", "test", "deliveries"}, "description": "Action to perform (default list)"},
+						"id":          map[string]interface{}{"type": "integer", "description": "Subscription ID (unsubscribe, test, deliveries)"},
+						"url":         map[string]string{"type": "string", "description": "Endpoint to POST events to (subscribe)"},
+						"events":      map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Event types to send (subscribe; default all)"},
+						"secret":      map[string]string{"type": "string", "description": "Signing secret (subscribe; default a generated one)"},
+						"description": map[string]string{"type": "string", "description": "What the subscription is for (subscribe)"},
+						"limit":       map[string]interface{}{"type": "integer", "description": "Deliveries to show (deliveries; default 20, max 100)"},
 					},
-					"required": []string{"manager", "package"},
 				},
 			},
-			Policy: "restricted",
+			Policy: "admin_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "list_skills",
-				Description: "List installed skills.",
+				Name:        "manage_network_policy",
+				Description: "View or change the outbound network policy for registered tools, and list the hosts they contacted. Registered tools reach the network only through a logging proxy that enforces this policy (allowlist, denylist, or off).",
 				Parameters: map[string]interface{}{
-					"type":       "object",
-					"properties": map[string]interface{}{},
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":  map[string]interface{}{"type": "string", "enum": []string{"show", "set_mode", "allow", "deny", "remove", "log"}, "description": "Action to perform"},
+						"mode":    map[string]interface{}{"type": "string", "enum": []string{"allowlist", "denylist", "off"}, "description": "Policy mode (for set_mode)"},
+						"domains": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Domains (api.example.com also covers subdomains; *.example.com only subdomains), IPs, or CIDRs (for allow, deny, remove)"},
+						"tool":    map[string]string{"type": "string", "description": "Only show destinations contacted by this tool (for log)"},
+						"limit":   map[string]string{"type": "integer", "description": "Max log entries (default 50)"},
+					},
+					"required": []string{"action"},
 				},
 			},
+			Policy: "admin_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "system_status",
-				Description: "Get comprehensive system status including health of all components, message count, log entries, and recent errors.",
+				Name:        "add_admin",
+				Description: "Grant a user the admin role (admin_only tools) or operator role (operational tools such as list_users). Owner only.",
 				Parameters: map[string]interface{}{
-					"type":       "object",
-					"properties": map[string]interface{}{},
+					"type": "object",
+					"properties": map[string]interface{}{
+						"user_id": map[string]string{"type": "string", "description": "User ID to promote"},
+						"role":    map[string]interface{}{"type": "string", "enum": []string{"admin", "operator"}, "description": "Role to grant (default: admin)"},
+					},
+					"required": []string{"user_id"},
 				},
 			},
+			Policy: "owner_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "read_logs",
-				Description: "Read recent system logs with optional filtering by level and component.",
+				Name:        "remove_admin",
+				Description: "Return an admin or operator to the regular user role. Owner only.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"level":     map[string]interface{}{"type": "string", "enum": []string{"error", "warn", "info"}, "description": "Filter by log level"},
-						"component": map[string]string{"type": "string", "description": "Filter by component (db, llm, gateway, compactor)"},
-						"limit":     map[string]string{"type": "integer", "description": "Max entries to return (default 50, max 200)"},
+						"user_id": map[string]string{"type": "string", "description": "User ID to demote"},
 					},
+					"required": []string{"user_id"},
 				},
 			},
+			Policy: "owner_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "log_self_modification",
-				Description: "Record a self-modification entry when you change core code (internal/*, cmd/*, Dockerfile) or workspace config. This log survives rebuilds—if a software update wipes your changes, you or the user can reference it to re-apply them. Do NOT log changes to $CONFIG_DIR/tools (registered tools).",
+				Name:        "manage_user_preference",
+				Description: "Persistent memory for key-value facts about the current user. Use this to remember user details (name, preferences, context).",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"file_paths":  map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Paths you modified (e.g. internal/scheduler/runner.go)"},
-						"change_type": map[string]interface{}{"type": "string", "enum": []string{"core_code", "config", "registered_tool"}, "description": "Type of change"},
-						"description": map[string]string{"type": "string", "description": "Brief summary of what changed and why"},
-						"context":     map[string]string{"type": "string", "description": "Optional: user request or trigger"},
+						"action":   map[string]interface{}{"type": "string", "enum": []string{"set", "get", "search"}, "description": "Action: set, get, or search"},
+						"key":      map[string]string{"type": "string", "description": "Unique key for the fact"},
+						"value":    map[string]string{"type": "string", "description": "The fact content (for set)"},
+						"category": map[string]string{"type": "string", "description": "Category tag (optional)"},
+						"query":    map[string]string{"type": "string", "description": "Search query"},
 					},
-					"required": []string{"file_paths", "change_type", "description"},
+					"required": []string{"action"},
 				},
 			},
-			Policy: "restricted",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "read_self_modification_log",
-				Description: "Read the self-modification changelog. Use when the user asks what changes you've made, or when you need to reference past optimizations that may have been lost in a rebuild.",
+				Name:        "manage_profile",
+				Description: "The user's typed preferences, applied to every reply: language, time zone, verbosity, formality and quiet hours. During quiet hours, proactive messages that are not urgent (reminders, notify_user, briefings) are held until they end; manage_notifications changes which urgency breaks through. Use this rather than manage_user_preference for these settings. set changes only the fields given; unset clears the named fields (quiet_hours clears both bounds).",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"limit": map[string]interface{}{"type": "integer", "description": "Max entries to return (default 20)"},
+						"action":      map[string]interface{}{"type": "string", "enum": []string{"get", "set", "unset"}, "description": "Action to perform (default get)"},
+						"language":    map[string]string{"type": "string", "description": "Reply language, e.g. German"},
+						"timezone":    map[string]string{"type": "string", "description": "IANA time zone, e.g. Europe/Berlin"},
+						"verbosity":   map[string]interface{}{"type": "string", "enum": store.ProfileVerbosities},
+						"formality":   map[string]interface{}{"type": "string", "enum": store.ProfileFormalities},
+						"quiet_start": map[string]string{"type": "string", "description": "Start of quiet hours, HH:MM in the user's time zone"},
+						"quiet_end":   map[string]string{"type": "string", "description": "End of quiet hours, HH:MM; may be earlier than quiet_start (e.g. 22:00 to 07:00)"},
+						"fields":      map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "For unset: field names to clear"},
 					},
+					"required": []string{"action"},
 				},
 			},
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "list_webhook_routes",
-				Description: "List configured webhook routes from $CONFIG_DIR/webhook_routes.json. Use to see what external webhook endpoints are registered.",
+				Name:        "manage_thread",
+				Description: "Per-conversation settings for a room or thread (e.g. a family chat vs a homelab ops room), applied to every turn there: prompt_addendum is extra system prompt text (persona, purpose, rules), allowed_tools limits the tools to patterns as for sub-minds (names, globs like nextcloud_*, registered:<glob>, !exclusions), verbosity overrides the users' profile, and addressing sets which messages you answer once several people write in the room (auto: when named or @-mentioned, follow-ups to your replies and, if enabled, messages judged to be for you; mention: only when named or @-mentioned; all: every message). Defaults to the current conversation. set changes only the fields given; unset clears the named fields; clear removes all; list shows every configured thread.",
 				Parameters: map[string]interface{}{
-					"type":       "object",
-					"properties": map[string]interface{}{},
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":          map[string]interface{}{"type": "string", "enum": []string{"get", "set", "unset", "clear", "list"}, "description": "Action to perform (default get)"},
+						"channel":         map[string]string{"type": "string", "description": "Channel of the thread, e.g. nextcloud_talk (with thread_id; default: current conversation)"},
+						"thread_id":       map[string]string{"type": "string", "description": "Thread or room ID (default: current conversation)"},
+						"prompt_addendum": map[string]string{"type": "string", "description": "For set: system prompt text for this thread (up to 4000 bytes)"},
+						"allowed_tools":   map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "For set: tool patterns this thread may use (empty allows all)"},
+						"verbosity":       map[string]interface{}{"type": "string", "enum": store.ProfileVerbosities},
+						"addressing":      map[string]interface{}{"type": "string", "enum": store.AddressingModes, "description": "For set: which group messages to answer (default: HATTIEBOT_GROUP_ADDRESSING)"},
+						"fields":          map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "For unset: prompt_addendum, allowed_tools, verbosity, addressing"},
+					},
+					"required": []string{"action"},
 				},
 			},
+			Policy: "admin_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "add_webhook_route",
-				Description: "Add a webhook route for external services (GitHub, Stripe, etc.). Path must start with /webhook/ and not be /webhook/talk. Secret is read from env var (secret_env). Auth type: header (exact match) or hmac_sha256 (GitHub-style).",
+				Name:        "manage_notifications",
+				Description: "The user's rules for proactive messages (reminders, notify_user, briefings, alerts). Urgencies are low, normal, high and urgent. channels sends an urgency to a given channel (e.g. {\"urgent\": \"nextcloud_talk\"}; an empty value removes it). quiet_start/quiet_end set quiet hours, and quiet_bypass is the lowest urgency still sent during them (default urgent). digest_below batches less urgent messages into one summary every digest_hours (default 24); an empty value turns digests off. set changes only the fields given; reset restores the defaults.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"path":          map[string]string{"type": "string", "description": "URL path (e.g. /webhook/github)"},
-						"id":            map[string]string{"type": "string", "description": "Short identifier (e.g. github)"},
-						"secret_header": map[string]string{"type": "string", "description": "Header name for secret/signature"},
-						"secret_env":    map[string]string{"type": "string", "description": "Env var name for secret value (optional)"},
-						"secret_source": map[string]string{"type": "string", "description": "Source of secret: 'env' or 'passwords' (default: env)"},
-						"secret_key":    map[string]string{"type": "string", "description": "Key name for the secret (e.g. secret title in Passwords app)"},
-						"auth_type":     map[string]interface{}{"type": "string", "enum": []string{"header", "hmac_sha256"}, "description": "Auth type"},
-						"target_tool":   map[string]string{"type": "string", "description": "Name of the tool to execute (required)"},
-						"target_args":   map[string]string{"type": "string", "description": "JSON arguments for the tool. Use {{payload}} for webhook body."},
+						"action":       map[string]interface{}{"type": "string", "enum": []string{"get", "set", "reset"}, "description": "Action to perform (default get)"},
+						"channels":     map[string]interface{}{"type": "object", "additionalProperties": map[string]string{"type": "string"}, "description": "Urgency -> channel name"},
+						"quiet_start":  map[string]string{"type": "string", "description": "Start of quiet hours, HH:MM in the user's time zone (empty clears)"},
+						"quiet_end":    map[string]string{"type": "string", "description": "End of quiet hours, HH:MM"},
+						"quiet_bypass": map[string]interface{}{"type": "string", "enum": store.NotificationUrgencies, "description": "Lowest urgency sent during quiet hours"},
+						"digest_below": map[string]interface{}{"type": "string", "enum": []string{"", "normal", "high", "urgent"}, "description": "Batch messages less urgent than this into a digest; empty turns digests off"},
+						"digest_hours": map[string]string{"type": "integer", "description": "Hours between digests, 1-168 (default 24)"},
 					},
-					"required": []string{"path", "id", "secret_header", "auth_type", "target_tool"},
+					"required": []string{"action"},
 				},
 			},
-			Policy: "restricted",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "remove_webhook_route",
-				Description: "Remove a webhook route by path or id.",
+				Name:        "link_identity",
+				Description: "Link the same person's accounts on different channels (terminal, Talk, email, ...) to one user, so facts, memories, trust level and schedules follow them. start (on the account to keep) returns a one-time code that expires in 15 minutes; the user sends it from the other channel, where you call confirm with it. That account's data then moves to the first one. list shows linked identities; unlink removes one.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"path_or_id": map[string]string{"type": "string", "description": "Path (e.g. /webhook/github) or id (e.g. github)"},
+						"action":      map[string]interface{}{"type": "string", "enum": []string{"start", "confirm", "list", "unlink"}, "description": "Action to perform (default list)"},
+						"code":        map[string]string{"type": "string", "description": "For confirm: the code the user sent"},
+						"channel":     map[string]string{"type": "string", "description": "For unlink: the identity's channel"},
+						"external_id": map[string]string{"type": "string", "description": "For unlink: the identity's sender ID on that channel"},
+						"user_id":     map[string]string{"type": "string", "description": "For list (admins): whose identities to list (default: you)"},
 					},
-					"required": []string{"path_or_id"},
+					"required": []string{"action"},
 				},
 			},
-			Policy: "restricted",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "self_reflect",
-				Description: "Trigger a self-reflection analysis to review system health and identify any issues. Only suggests improvements if there are clear problems.",
+				Name:        "memorize",
+				Description: "Store a text chunk into long-term vector memory. While a project is current, the memory goes into its namespace.",
 				Parameters: map[string]interface{}{
-					"type":       "object",
-					"properties": map[string]interface{}{},
+					"type": "object",
+					"properties": map[string]interface{}{
+						"content": map[string]string{"type": "string", "description": "Text to memorize"},
+						"source":  map[string]string{"type": "string", "description": "Origin of the memory (e.g. user, reflection)"},
+					},
+					"required": []string{"content"},
 				},
 			},
+			Policy: "safe",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "notify_user",
-				Description: "Send a message to the user. Use when running autonomously to notify about errors, anomalies, or important findings. If the task completes successfully with nothing notable, do NOT call this.",
+				Name:        "recall_memories",
+				Description: "Search long-term memory for relevant chunks using vector similarity. While a project is current, memories of other projects are left out.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"message": map[string]string{"type": "string", "description": "Message to send to the user"},
+						"query": map[string]string{"type": "string", "description": "Search query"},
+						"limit": map[string]interface{}{"type": "integer", "description": "Max results (default 5)"},
 					},
-					"required": []string{"message"},
+					"required": []string{"query"},
 				},
 			},
-			Policy: "restricted",
+			Policy: "safe",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "spawn_submind",
-				Description: "Spawn a focused sub-mind for a specific task. Use for tool creation, code analysis, reflection, planning, or custom modes. Pass session_id to resume an existing session.",
+				Name:        "run_sandboxed",
+				Description: "Execute a command securely inside a disposable Docker container.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"mode":       map[string]string{"type": "string", "description": "Sub-mind mode (reflection, tool_creation, code_analysis, planning, or custom)"},
-						"task":       map[string]string{"type": "string", "description": "Task description for the sub-mind"},
-						"session_id": map[string]string{"type": "integer", "description": "If provided, resume this sub-mind session instead of starting a new one."},
+						"image":    map[string]string{"type": "string", "description": "Docker image (default: debian:bookworm-slim)"},
+						"command":  map[string]string{"type": "string", "description": "Command to run. Use env vars for secrets."},
+						"work_dir": map[string]string{"type": "string", "description": "Working directory inside container"},
+						"env_vars": map[string]interface{}{"type": "object", "additionalProperties": map[string]string{"type": "string"}, "description": "Environment variables to set inside container."},
 					},
-					"required": []string{"mode", "task"},
+					"required": []string{"command"},
 				},
 			},
+			Policy: "restricted",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "manage_submind",
-				Description: "Create, update, delete, or list sub-mind modes.",
+				Name:        "manage_schedule",
+				Description: "Create, list, or delete scheduled reminders and recurring tasks. remind=message user; execute_tool=run tool directly; agent_prompt=agent reasons and acts (use autonomous=true for background tasks like 'check email and file receipts'). history=structured results of a plan's past runs (status, summary, artifacts, next suggested run).",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"action":        map[string]interface{}{"type": "string", "enum": []string{"create", "update", "delete", "list", "list_sessions"}, "description": "Action to perform"},
-						"name":          map[string]string{"type": "string", "description": "Sub-mind name (for create/update/delete)"},
-						"system_prompt": map[string]string{"type": "string", "description": "System prompt for the sub-mind (for create/update)"},
-						"allowed_tools": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Tools available to sub-mind"},
-						"max_turns":     map[string]string{"type": "integer", "description": "Maximum turns (default 10)"},
+						"action":         map[string]interface{}{"type": "string", "enum": []string{"create", "list", "delete", "pause", "history"}, "description": "Action to perform"},
+						"description":    map[string]string{"type": "string", "description": "What to remind or do"},
+						"action_type":    map[string]interface{}{"type": "string", "enum": []string{"remind", "execute_tool", "agent_prompt"}, "description": "remind=message user; execute_tool=run tool; agent_prompt=agent reasons/acts"},
+						"schedule_type":  map[string]interface{}{"type": "string", "enum": []string{"once", "hourly", "daily", "weekdays", "weekly", "monthly"}, "description": "Frequency (weekdays = Monday to Friday)"},
+						"run_at":         map[string]string{"type": "string", "description": "For 'once': ISO datetime, a delay (\"2h\", \"in 3 days\"), or a phrase (\"tomorrow morning\", \"friday 14:00\"); for recurring a local time: daily/weekdays '09:00', weekly 'mon 09:00' or 'mon,thu 09:00', monthly '15 09:00' or 'last 09:00' (day clamped to month end)"},
+						"timezone":       map[string]string{"type": "string", "description": "IANA time zone for run_at, e.g. Europe/Berlin (default: server local). Recurring times stay fixed on the local clock across DST changes"},
+						"id":             map[string]interface{}{"type": "integer", "description": "Plan ID (for delete/pause/history)"},
+						"limit":          map[string]interface{}{"type": "integer", "description": "For history: max runs to return, newest first (default 20)"},
+						"prompt":         map[string]string{"type": "string", "description": "For agent_prompt: task prompt (e.g. 'Run self-reflection')"},
+						"autonomous":     map[string]string{"type": "boolean", "description": "For agent_prompt: true=run silently, notify only via notify_user"},
+						"tool":           map[string]string{"type": "string", "description": "For execute_tool: tool name (e.g. self_reflect)"},
+						"tool_args":      map[string]interface{}{"type": "object", "description": "For execute_tool: JSON args for the tool"},
+						"preapprove":     map[string]string{"type": "boolean", "description": "For execute_tool/agent_prompt: let the plan's runs use tools that need the user's confirmation (e.g. send_email) without asking each time. Creating the plan then needs the user's confirmation; otherwise such calls in unattended runs are held until the user confirms them"},
+						"calendar_check": map[string]interface{}{"type": "string", "enum": []string{"warn", "adjust"}, "description": "For 'once' schedules: check the user's Nextcloud calendar. warn=do not schedule during a meeting, return the conflict and a suggested time to offer the user; adjust=move to the end of the meeting"},
 					},
 					"required": []string{"action"},
 				},
 			},
-			Policy: "admin_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "manage_llm_provider",
-				Description: "Manage generic LLM provider templates and routing configuration. Use this to add support for Ollama, vLLM, etc.",
+				Name:        "install_skill",
+				Description: "Install a new skill or tool package (go, brew, npm).",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"action":        map[string]interface{}{"type": "string", "enum": []string{"list_templates", "get_template", "save_template", "list_providers", "register_provider", "set_route"}, "description": "Action to perform"},
-						"template_name": map[string]string{"type": "string", "description": "Name of template (for start/get/save)"},
-						"template_body": map[string]interface{}{"type": "object", "description": "JSON body of ProviderTemplate (for save)"},
-						"provider_name": map[string]string{"type": "string", "description": "Name of provider instance (e.g. 'my_ollama')"},
-						"provider_config": map[string]interface{}{"type": "object", "description": "JSON body of LLMProviderEntry (type, api_key_env, base_url)"},
-						"route":         map[string]string{"type": "string", "description": "Route key (default: 'default')"},
-						"model":         map[string]string{"type": "string", "description": "Target model ID"},
+						"manager": map[string]interface{}{"type": "string", "enum": []string{"go", "brew", "npm"}, "description": "Package manager to use"},
+						"package": map[string]string{"type": "string", "description": "Package name (e.g. github.com/user/repo@latest, jq)"},
 					},
-					"required": []string{"action"},
+					"required": []string{"manager", "package"},
 				},
 			},
-			Policy: "admin_only",
+			Policy: "restricted",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "manage_embedding_provider",
-				Description: "Manage embedding provider configuration and default provider. Use this to add or switch embedding services (e.g. EmbeddingGood).",
+				Name:        "list_skills",
+				Description: "List installed skills.",
 				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"action":         map[string]interface{}{"type": "string", "enum": []string{"list_providers", "register_provider", "set_default"}, "description": "Action to perform"},
-						"provider_name":  map[string]string{"type": "string", "description": "Name of provider instance (e.g. 'embeddinggood')"},
-						"provider_config": map[string]interface{}{"type": "object", "description": "JSON body of EmbeddingProviderEntry (type, base_url_env, api_key_env, dimension)"},
-					},
-					"required": []string{"action"},
+					"type":       "object",
+					"properties": map[string]interface{}{},
 				},
 			},
-			Policy: "admin_only",
 		},
-		// Nextcloud Tools
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "request_nextcloud_ocs",
-				Description: "Execute a Nextcloud OCS API request (Provisioning API, etc.) as the bot/admin. Use for managing users, groups, apps that have OCS endpoints.",
+				Name:        "system_status",
+				Description: "Get comprehensive system status including health of all components, message count, log entries, and recent errors.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "reload_config",
+				Description: "Reload llm_routing.json, embedding_routing.json, webhook_routes.json and SOUL.md without a restart. All files are validated first; if any is invalid nothing is applied and the errors are returned. The LLM and embedding clients are rebuilt once no conversation turn is running, so when called mid-turn the reload applies right after this reply (pending=true). Files are also watched and reloaded automatically when they change. Use dry_run to only validate.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"method":   map[string]interface{}{"type": "string", "enum": []string{"GET", "POST", "PUT", "DELETE"}, "description": "HTTP Method"},
-						"endpoint": map[string]string{"type": "string", "description": "API endpoint (e.g. /cloud/users). /ocs/v1.php is prepended automatically."},
-						"params":   map[string]interface{}{"type": "object", "description": "Query params (GET) or Form fields (POST/PUT). Map strings to strings."},
+						"dry_run": map[string]string{"type": "boolean", "description": "Only validate the files (default false)"},
 					},
-					"required": []string{"method", "endpoint"},
 				},
 			},
-			Policy: "restricted", // Admin/Bot power
+			Policy: "admin_only",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "list_nextcloud_files",
-				Description: "List files in Nextcloud via WebDAV.",
+				Name:        "purge_user",
+				Description: "Erase everything stored about a user (right to be forgotten): their messages (whole threads where they were the only person talking to the bot, otherwise just their own messages), conversation summaries they appear in, facts, memories, sub-mind sessions, scheduled plans and runs, jobs, API tokens, tool permissions and the user record. LLM spend is kept without the user ID. Cannot be undone; run with dry_run first to see the counts and confirm with the admin.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"path": map[string]string{"type": "string", "description": "Path to list (e.g. / or /Documents)"},
+						"user_id": map[string]string{"type": "string", "description": "ID of the user to erase"},
+						"dry_run": map[string]string{"type": "boolean", "description": "Only count what would be erased (default false)"},
 					},
-					"required": []string{"path"},
+					"required": []string{"user_id"},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "backup_now",
+				Description: "Back up the database (memories, conversations, schedules, tools registry) and the config directory to the configured backup target right now, then rotate old backups. Use before risky self-modification. Backups also run automatically on a schedule. Use list to only show the stored backups and the last result.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"list": map[string]string{"type": "boolean", "description": "Only list stored backups (default false)"},
+					},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "sync_workspace",
+				Description: "Sync the workspace directory with its Nextcloud folder now (two-way: new and changed files are copied, deletions are mirrored, files changed on both sides keep the Nextcloud version as a '(conflict ...)' copy). Use after creating files the user should see in Nextcloud, or to pick up files they dropped in. action=status shows the last result without syncing.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{"type": "string", "enum": []string{"run", "status"}, "description": "run (default) or status"},
+					},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "read_logs",
+				Description: "Read recent system logs (everything HattieBot logs at info level and above) with optional filtering by level and component.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"level":     map[string]interface{}{"type": "string", "enum": []string{"error", "warn", "info"}, "description": "Filter by log level"},
+						"component": map[string]string{"type": "string", "description": "Filter by component, e.g. agent, gateway, scheduler, llm, webhook, audit, submind"},
+						"limit":     map[string]string{"type": "integer", "description": "Max entries to return (default 50, max 200)"},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "log_self_modification",
+				Description: "Record a self-modification entry when you change core code (internal/*, cmd/*, Dockerfile) or workspace config. This log survives rebuilds—if a software update wipes your changes, you or the user can reference it to re-apply them. Do NOT log changes to $CONFIG_DIR/tools (registered tools).",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"file_paths":  map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Paths you modified (e.g. internal/scheduler/runner.go)"},
+						"change_type": map[string]interface{}{"type": "string", "enum": []string{"core_code", "config", "registered_tool"}, "description": "Type of change"},
+						"description": map[string]string{"type": "string", "description": "Brief summary of what changed and why"},
+						"context":     map[string]string{"type": "string", "description": "Optional: user request or trigger"},
+					},
+					"required": []string{"file_paths", "change_type", "description"},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "read_self_modification_log",
+				Description: "Read the self-modification changelog. Use when the user asks what changes you've made, or when you need to reference past optimizations that may have been lost in a rebuild.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"limit": map[string]interface{}{"type": "integer", "description": "Max entries to return (default 20)"},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "git",
+				Description: "Git on the checkout of your own source (HATTIEBOT_GIT_REPO_DIR), so core code changes become reviewable commits: status, diff (staged = what will be committed), log, branch (list; with branch switch to it, create makes it), commit (paths or all) on a feature branch, push the current branch to the configured remote, and open_pr against the base branch on GitHub or Gitea. Committing on or pushing the base branch is refused. Also record the change with log_self_modification.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":  map[string]interface{}{"type": "string", "enum": []string{"status", "diff", "log", "branch", "commit", "push", "open_pr"}, "description": "Action to perform (default status)"},
+						"paths":   map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Files relative to the repository (diff, commit)"},
+						"staged":  map[string]string{"type": "boolean", "description": "diff: show staged changes instead of unstaged ones"},
+						"limit":   map[string]string{"type": "integer", "description": "log: commits to show (default 10)"},
+						"branch":  map[string]string{"type": "string", "description": "branch: branch to switch to, e.g. hattie/fix-scheduler-drift"},
+						"create":  map[string]string{"type": "boolean", "description": "branch: create the branch from the current commit"},
+						"message": map[string]string{"type": "string", "description": "commit: commit message (summary line, blank line, details)"},
+						"all":     map[string]string{"type": "boolean", "description": "commit: stage every change instead of paths"},
+						"title":   map[string]string{"type": "string", "description": "open_pr: pull request title"},
+						"body":    map[string]string{"type": "string", "description": "open_pr: pull request description (what changed, why, how it was tested)"},
+					},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "self_update",
+				Description: "Rebuild and restart yourself from your source checkout. build exports a commit (ref, default HEAD; uncommitted edits are not included), runs go build and go test ./... on it in the background, and stages the binary only if the tests pass; you are told the result. apply installs the staged binary (commit: the expected commit, optional) by restarting through the supervisor, which rolls it back automatically if it crash-loops. rollback restores the previous binary, discard drops the staged one, status shows the running version, the build in progress, the staged/active/previous binaries and recent supervisor events. Only apply changes that were reviewed (git open_pr) or that the admin asked for.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{"type": "string", "enum": []string{"status", "build", "apply", "rollback", "discard"}, "description": "Action to perform (default status)"},
+						"ref":    map[string]string{"type": "string", "description": "build: branch, tag or commit to build (default HEAD)"},
+						"commit": map[string]string{"type": "string", "description": "apply: commit (prefix) the staged binary must be"},
+					},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "manage_plugin",
+				Description: "In-process Go tools (plugins) for hot-path helpers, which avoid the process spawn of a registered tool. A plugin is a main package in the workspace that exports func Definition() string (JSON with name, description, parameters, policy; default policy restricted) and func Execute(ctx context.Context, argsJSON string) (string, error), using only the standard library types in those signatures. build compiles source_dir into $CONFIG_DIR/plugins/<name>.so and loads it; reload picks up added, changed and removed .so files; list shows what is loaded. Plugins run inside the bot without sandbox or egress policy and must honor ctx: use registered tools for anything untrusted or slow.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":     map[string]interface{}{"type": "string", "enum": []string{"list", "build", "reload"}, "description": "Action to perform (default list)"},
+						"source_dir": map[string]string{"type": "string", "description": "build: directory of the plugin's main package, relative to the workspace"},
+						"name":       map[string]string{"type": "string", "description": "build: file name for the plugin (lowercase letters, digits and _)"},
+					},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "list_webhook_routes",
+				Description: "List configured webhook routes from $CONFIG_DIR/webhook_routes.json. Use to see what external webhook endpoints are registered.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "add_webhook_route",
+				Description: "Add a webhook route for external services (GitHub, Stripe, etc.). Path must start with /webhook/ and not be /webhook/talk. Secret is read from env var (secret_env). Auth type: header (exact match) or hmac_sha256 (GitHub-style). Set delivery_id_header or delivery_id_path so provider retries of the same delivery run the tool only once. Use filter and targets to map provider events (e.g. GitHub issues opened) to tools or agent prompts.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path":          map[string]string{"type": "string", "description": "URL path (e.g. /webhook/github)"},
+						"id":            map[string]string{"type": "string", "description": "Short identifier (e.g. github)"},
+						"secret_header": map[string]string{"type": "string", "description": "Header name for secret/signature"},
+						"secret_env":    map[string]string{"type": "string", "description": "Env var name for secret value (optional)"},
+						"secret_source": map[string]string{"type": "string", "description": "Source of secret: 'env', 'passwords', 'local', or 'vault' (default: env)"},
+						"secret_key":    map[string]string{"type": "string", "description": "Key name for the secret (e.g. secret title in Passwords app, or path#field for vault)"},
+						"auth_type":     map[string]interface{}{"type": "string", "enum": []string{"header", "hmac_sha256"}, "description": "Auth type"},
+						"target_type":   map[string]interface{}{"type": "string", "enum": []string{"tool", "agent_prompt"}, "description": "tool (default): run target_tool. agent_prompt: hand the delivery to the agent as an autonomous message (e.g. to reason about a failed CI run), which reports through notify_user"},
+						"target_tool":   map[string]string{"type": "string", "description": "Name of the tool to execute (required for tool routes unless targets is set)"},
+						"target_args":   map[string]string{"type": "string", "description": "JSON arguments for the tool. {{payload}} is the webhook body as a string, {{payload.issue.title}} a value from a JSON body, {{headers.X-GitHub-Event}} a header."},
+						"target_prompt": map[string]string{"type": "string", "description": "agent_prompt: the message, with the same placeholders filled in as text (default: the payload itself)"},
+						"target_user":   map[string]string{"type": "string", "description": "agent_prompt: user the agent acts for (default you; only admins may name another user)"},
+						"target_thread": map[string]string{"type": "string", "description": "agent_prompt: thread for the agent's runs (default webhook:<id>)"},
+						"targets": map[string]interface{}{
+							"type":        "array",
+							"description": "Several targets instead of target_tool: each runs a tool or prompts the agent (as a background task that reports via notify_user), in order, when its own filter matches",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"type":   map[string]interface{}{"type": "string", "enum": []string{"tool", "agent_prompt"}, "description": "Default tool, or agent_prompt when only prompt is set"},
+									"tool":   map[string]string{"type": "string", "description": "Tool to run"},
+									"args":   map[string]string{"type": "string", "description": "JSON arguments template, as target_args"},
+									"prompt": map[string]string{"type": "string", "description": "Instead of tool: prompt for the agent, with the same placeholders filled in as text (default: the payload itself)"},
+									"user":   map[string]string{"type": "string", "description": "User the prompt runs for (default you; only admins may name another user)"},
+									"thread": map[string]string{"type": "string", "description": "Thread for the prompt (default webhook:<id>)"},
+									"filter": webhookFilterSchema("Conditions for this target, on top of the route's filter"),
+								},
+							},
+						},
+						"filter":                webhookFilterSchema("Conditions a delivery must meet to trigger anything (e.g. path action equals opened); others are answered 200 ignored"),
+						"delivery_id_header":    map[string]string{"type": "string", "description": "Header carrying the provider's delivery ID (e.g. X-GitHub-Delivery); a retried delivery with a seen ID does not run the tool again"},
+						"delivery_id_path":      map[string]string{"type": "string", "description": "Dot path to the delivery ID in the JSON body when there is no header (e.g. id for Stripe events)"},
+						"dedup_ttl_hours":       map[string]string{"type": "integer", "description": "How long delivery IDs are remembered (default 72)"},
+						"on_duplicate":          map[string]interface{}{"type": "string", "enum": []string{"cached", "skip"}, "description": "Answer a duplicate with the first delivery's tool result (cached, default) or without it (skip)"},
+						"mode":                  map[string]interface{}{"type": "string", "enum": []string{"sync", "async"}, "description": "sync (default): run the tool, then respond. async: queue the delivery and respond 202 at once, for slow tools or providers with short timeouts"},
+						"timeout_sec":           map[string]string{"type": "integer", "description": "Limit for each tool run (default 60)"},
+						"retries":               map[string]string{"type": "integer", "description": "Extra attempts when the tool fails (default 0, max 10)"},
+						"retry_delay_sec":       map[string]string{"type": "integer", "description": "Wait before the first retry, doubled for each next one (default 5)"},
+						"response_template":     map[string]string{"type": "string", "description": "Sync response body with {{status}}, {{result}} or {{result.field.path}} from the tool's JSON result (default: empty body)"},
+						"response_content_type": map[string]string{"type": "string", "description": "Content type of the templated response (default application/json)"},
+						"error_status":          map[string]string{"type": "integer", "description": "HTTP status of a sync response when the tool failed (default 200, so the provider does not retry)"},
+					},
+					"required": []string{"path", "id", "secret_header", "auth_type"},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "remove_webhook_route",
+				Description: "Remove a webhook route by path or id.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path_or_id": map[string]string{"type": "string", "description": "Path (e.g. /webhook/github) or id (e.g. github)"},
+					},
+					"required": []string{"path_or_id"},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "manage_recipe",
+				Description: "Install, validate, list, show, or remove integration recipes: YAML/JSON bundles that declare required secrets, webhook routes, registered tools, sub-minds, and schedules as one unit. Install is all-or-nothing and fails if a required secret is missing; remove deletes everything the recipe created (but not its secrets).",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{"type": "string", "enum": []string{"install", "validate", "list", "show", "remove"}},
+						"recipe": map[string]string{"type": "string", "description": "Recipe document (YAML or JSON) for install/validate"},
+						"path":   map[string]string{"type": "string", "description": "Workspace file with the recipe, instead of recipe"},
+						"name":   map[string]string{"type": "string", "description": "Installed recipe name for show/remove"},
+					},
+					"required": []string{"action"},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "self_reflect",
+				Description: "Trigger a self-reflection analysis to review system health and the past week's user feedback on replies, and identify any issues. Only suggests improvements if there are clear problems.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "notify_user",
+				Description: "Send a message to the user. Use when running autonomously to notify about errors, anomalies, or important findings. If the task completes successfully with nothing notable, do NOT call this.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"message": map[string]string{"type": "string", "description": "Message to send to the user"},
+						"urgency": map[string]interface{}{"type": "string", "enum": store.NotificationUrgencies, "description": "How urgent it is (default normal); the user's notification rules route, hold or batch it by urgency"},
+					},
+					"required": []string{"message"},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "manage_briefing",
+				Description: "Configure the user's proactive daily briefing: a morning digest of today's calendar, scheduled tasks due in the next 24 hours, blocked jobs and jobs waiting for the user, unread webhook events (admins) and the weather from a registered tool, written by you and delivered to the user. configure creates or changes it (fields not given keep their values), disable pauses it, enable resumes it, preview shows today's briefing without sending, send_now delivers it at once.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":       map[string]interface{}{"type": "string", "enum": []string{"get", "configure", "enable", "disable", "preview", "send_now"}, "description": "Action to perform (default get)"},
+						"run_at":       map[string]string{"type": "string", "description": "Time of day, e.g. 07:30 or 8am (default 07:30)"},
+						"days":         map[string]interface{}{"type": "string", "enum": []string{"daily", "weekdays"}, "description": "Every day or Monday to Friday (default daily)"},
+						"timezone":     map[string]string{"type": "string", "description": "IANA time zone, e.g. Europe/Berlin (default server local)"},
+						"sections":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "enum": []string{"calendar", "plans", "jobs", "webhooks", "weather"}}, "description": "Sections to include (default all)"},
+						"weather_tool": map[string]string{"type": "string", "description": "Registered tool that returns the weather (\"\" removes the weather)"},
+						"weather_args": map[string]string{"type": "object", "description": "JSON args for the weather tool, e.g. {\"city\": \"Berlin\"}"},
+						"instructions": map[string]string{"type": "string", "description": "Extra guidance for writing the briefing, e.g. tone or what to lead with"},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "manage_feed",
+				Description: "Watch RSS/Atom feeds for the user. subscribe checks the URL and starts watching it (items already in the feed are not reported); new items that pass the keyword filters come to you later as an autonomous task to summarize and send with notify_user. list shows subscriptions, update changes settings (fields not given keep their values), pause/resume stop and restart checks, unsubscribe removes the feed, check_now checks at once, items shows recent items.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":       map[string]interface{}{"type": "string", "enum": []string{"list", "subscribe", "update", "pause", "resume", "unsubscribe", "check_now", "items"}, "description": "Action to perform (default list)"},
+						"feed_id":      map[string]string{"type": "integer", "description": "Feed ID (all actions except list and subscribe)"},
+						"url":          map[string]string{"type": "string", "description": "Feed URL (subscribe)"},
+						"title":        map[string]string{"type": "string", "description": "Name for the feed (default the feed's own title)"},
+						"interval_min": map[string]string{"type": "integer", "description": "Minutes between checks, at least 5 (default 60)"},
+						"include":      map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Only report items whose title or summary contains one of these keywords (case-insensitive; empty = all)"},
+						"exclude":      map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Never report items containing one of these keywords"},
+						"instructions": map[string]string{"type": "string", "description": "Guidance for handling new items, e.g. \"only security releases matter\""},
+						"limit":        map[string]string{"type": "integer", "description": "Items to show (items; default 20, max 50)"},
+					},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "report_task_result",
+				Description: "Record the structured result of the scheduled task you are running (only available in scheduled agent_prompt turns). Call once when done; it is shown in manage_schedule history.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"status":             map[string]interface{}{"type": "string", "enum": []string{"succeeded", "partial", "failed", "skipped"}, "description": "Outcome of the run (skipped = nothing to do)"},
+						"summary":            map[string]string{"type": "string", "description": "One or two sentences on what was done or what went wrong"},
+						"artifacts":          map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Files, URLs, or IDs produced or changed"},
+						"next_suggested_run": map[string]string{"type": "string", "description": "Optional: when the task should run next (\"tomorrow morning\", \"2h\", ISO datetime); recorded as a suggestion, the schedule is not changed"},
+					},
+					"required": []string{"status", "summary"},
+				},
+			},
+			Policy: "safe",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "react",
+				Description: "Add an emoji reaction to the user's current message (e.g. 👍, 🎉). Only on channels that support reactions (Nextcloud Talk); 👀 while working and ✅ when done are added automatically.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"emoji": map[string]string{"type": "string", "description": "Single emoji to react with"},
+					},
+					"required": []string{"emoji"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "talk_actions",
+				Description: "Nextcloud Talk features beyond a plain reply. reply_options: make your reply to the current message quote it and/or @-mention users (notifies them). send: post a message now, optionally quoting a message ID and mentioning users. create_poll / get_poll / close_poll: run a poll and read its results. Acts in the current Talk conversation; other rooms need an admin.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":    map[string]interface{}{"type": "string", "enum": []string{"reply_options", "send", "create_poll", "get_poll", "close_poll"}, "description": "Action to perform"},
+						"room":      map[string]string{"type": "string", "description": "Talk room token (default: the current conversation)"},
+						"message":   map[string]string{"type": "string", "description": "Message text (send)"},
+						"reply_to":  map[string]interface{}{"type": "integer", "description": "Talk message ID to quote (send)"},
+						"quote":     map[string]interface{}{"type": "boolean", "description": "reply_options: quote the message you are answering"},
+						"mentions":  map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Nextcloud user IDs to @-mention (reply_options, send)"},
+						"question":  map[string]string{"type": "string", "description": "Poll question (create_poll)"},
+						"options":   map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Poll options, at least two (create_poll)"},
+						"hidden":    map[string]interface{}{"type": "boolean", "description": "Hide results until the poll is closed (create_poll)"},
+						"max_votes": map[string]interface{}{"type": "integer", "description": "Options each participant may pick; 0 = unlimited (create_poll)"},
+						"poll_id":   map[string]interface{}{"type": "integer", "description": "Poll ID (get_poll, close_poll)"},
+					},
+					"required": []string{"action"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "import_conversations",
+				Description: "Import a ChatGPT or Claude data export (the .zip, an extracted folder, or conversations.json, path relative to workspace) into history, one thread per conversation, and distill memories and user facts from it. Safe to re-run; already imported conversations are skipped. Admin only.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path":        map[string]string{"type": "string", "description": "Path to the export, relative to workspace"},
+						"source":      map[string]interface{}{"type": "string", "enum": []string{"auto", "chatgpt", "claude"}, "description": "Export format (default auto-detect)"},
+						"distill":     map[string]string{"type": "boolean", "description": "Extract memories and user facts with the LLM (default true)"},
+						"max_distill": map[string]string{"type": "integer", "description": "Distill at most this many of the most recent imported conversations (default 50)"},
+					},
+					"required": []string{"path"},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "export_thread",
+				Description: "Export a conversation thread, or all history for a user, to Markdown (readable, for archiving) or JSONL (one conversation per line in the chat fine-tuning format). Writes to the workspace (default exports/) or uploads to Nextcloud Files. With neither thread_id nor user_id, exports the caller's own history. Non-admins can only export their own conversations.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"thread_id":      map[string]string{"type": "string", "description": "Thread to export"},
+						"user_id":        map[string]string{"type": "string", "description": "Export every thread this user sent messages in (instead of thread_id)"},
+						"format":         map[string]interface{}{"type": "string", "enum": []string{"markdown", "jsonl"}, "description": "Export format (default markdown)"},
+						"include_tools":  map[string]string{"type": "boolean", "description": "Include tool calls and tool results (default false)"},
+						"path":           map[string]string{"type": "string", "description": "File under exports/ in the workspace to write to (default a generated name); an existing file is not replaced"},
+						"nextcloud_path": map[string]string{"type": "string", "description": "Upload to this Nextcloud Files path instead; end with / to use a generated file name"},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "branch_thread",
+				Description: "Branch a conversation: start a new thread that continues from an earlier message, with the history up to that message but none of what followed, so a wrong turn can be retried without it in context. Action list shows the thread's recent messages with their IDs; action branch creates the new thread and returns its ID. Conversations continue in a branch over the HTTP API (send messages with its thread_id). To just get a new answer to the last message, the user can send /regenerate instead. Defaults to the current conversation.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":        map[string]interface{}{"type": "string", "enum": []string{"list", "branch"}},
+						"thread_id":     map[string]string{"type": "string", "description": "Thread to list or branch from (default: this conversation)"},
+						"message_id":    map[string]string{"type": "integer", "description": "branch: last message the new thread keeps"},
+						"new_thread_id": map[string]string{"type": "string", "description": "branch: ID for the new thread (default generated)"},
+						"limit":         map[string]string{"type": "integer", "description": "list: how many recent messages (default 20)"},
+					},
+					"required": []string{"action"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "manage_prompt_experiment",
+				Description: "A/B test a change to SOUL.md (the agent's identity) before making it permanent. Prefer this over editing SOUL.md directly for changes in tone, behavior or instructions. start runs the variant (the full new SOUL.md text, or a workspace file with it) on a sampled fraction of conversation turns; the rest use the current SOUL.md. report compares the arms: turns, tool errors, replies the user regenerated, thumbs up/down feedback, and the success rate (no tool errors, not regenerated, not rated down), and names the winner once each arm has enough turns. promote writes the variant to SOUL.md, only if it won unless force=true; stop ends the experiment and keeps SOUL.md. One experiment runs at a time.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":       map[string]interface{}{"type": "string", "enum": []string{"start", "report", "promote", "stop", "list"}},
+						"name":         map[string]string{"type": "string", "description": "Experiment name (start: required; report/promote/stop: default the running experiment)"},
+						"variant":      map[string]string{"type": "string", "description": "start: the full SOUL.md text to test"},
+						"variant_path": map[string]string{"type": "string", "description": "start: workspace file with the variant instead of variant"},
+						"fraction":     map[string]string{"type": "number", "description": "start: share of turns that get the variant, above 0 and at most 1 (default 0.2)"},
+						"force":        map[string]string{"type": "boolean", "description": "promote: promote even though the variant has not won"},
+					},
+					"required": []string{"action"},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "ask_user",
+				Description: "Pause the current step until the user answers a question (a missing credential, a choice between options, a confirmation). Records the question with the expected answer and what you will do with it; the user's next message in this conversation is checked against it and the step resumes automatically with the answer. Then end your turn by asking the question. With job_id, the job waits in status awaiting_input. Not for scheduled tasks.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"question": map[string]string{"type": "string", "description": "The question, as you will ask it"},
+						"expect": map[string]interface{}{
+							"type":        "object",
+							"description": "Expected answer (default free text)",
+							"properties": map[string]interface{}{
+								"type":    map[string]interface{}{"type": "string", "enum": []string{"text", "choice", "confirm", "number"}},
+								"choices": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Options for type choice"},
+							},
+						},
+						"step":   map[string]string{"type": "string", "description": "What you will do with the answer, precisely enough to pick up where you left off (e.g. which tool to call with which arguments)"},
+						"job_id": map[string]string{"type": "integer", "description": "Job that waits for the answer"},
+					},
+					"required": []string{"question", "step"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "spawn_submind",
+				Description: "Spawn a focused sub-mind for a specific task. Use for tool creation, code analysis, reflection, planning, or custom modes. Pass session_id to resume an existing session; for a session in status awaiting_input, task is the user's answer to its question. With async (or tasks, to run several in parallel) the sub-minds run in the background and the call returns their session_ids at once; collect the results with check_submind.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"mode":       map[string]string{"type": "string", "description": "Sub-mind mode (reflection, tool_creation, code_analysis, planning, or custom)"},
+						"task":       map[string]string{"type": "string", "description": "Task description for the sub-mind"},
+						"session_id": map[string]string{"type": "integer", "description": "If provided, resume this sub-mind session instead of starting a new one."},
+						"async":      map[string]string{"type": "boolean", "description": "Run in the background and return the session_id without waiting"},
+						"tasks": map[string]interface{}{
+							"type":        "array",
+							"description": "Several sub-minds to run in parallel in the background (instead of mode/task)",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"mode": map[string]string{"type": "string"},
+									"task": map[string]string{"type": "string"},
+								},
+								"required": []string{"mode", "task"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "check_submind",
+				Description: "Check on background sub-minds started with spawn_submind async/tasks: status, turns, output or error, and the question of any waiting on the user. Set wait_seconds to wait until all are finished; their outputs come back together. cancel stops them.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"session_id":   map[string]string{"type": "integer", "description": "Session to check"},
+						"session_ids":  map[string]interface{}{"type": "array", "items": map[string]string{"type": "integer"}, "description": "Sessions to check together"},
+						"wait_seconds": map[string]string{"type": "integer", "description": "Wait up to this long (max 600) for all sessions to finish"},
+						"cancel":       map[string]string{"type": "boolean", "description": "Cancel the sessions instead of checking them"},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "manage_submind",
+				Description: "Create, update, delete, or list sub-mind modes.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":        map[string]interface{}{"type": "string", "enum": []string{"create", "update", "delete", "list", "list_sessions"}, "description": "Action to perform"},
+						"name":          map[string]string{"type": "string", "description": "Sub-mind name (for create/update/delete)"},
+						"system_prompt": map[string]string{"type": "string", "description": "System prompt for the sub-mind (for create/update)"},
+						"allowed_tools": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Tools available to sub-mind. Entries: exact names, globs (\"nextcloud_*\"), \"registered:<glob>\" for registered tools, \"inherit\" for every tool the user's role may run, and \"!<name or glob>\" to exclude. Blocked tools are never granted."},
+						"max_turns":     map[string]string{"type": "integer", "description": "Maximum turns (default 10)"},
+						"output_schema": map[string]interface{}{"type": "object", "description": "JSON Schema for the final answer (for create/update). The mode then returns machine-parseable JSON: structured output is requested from the model and the answer is checked (and re-asked once per remaining turn) before it is returned. Strict decoding is used when every object lists all its properties as required and sets additionalProperties to false."},
+					},
+					"required": []string{"action"},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "manage_llm_provider",
+				Description: "Manage generic LLM provider templates and routing configuration. Providers of type 'ollama' (base_url, e.g. http://localhost:11434) are built in, with chat, tools and embeddings; templates add others such as vLLM. set_fallbacks gives a route an ordered list of backup models: when a model fails it is skipped for the next one, and after circuit_breaker.failure_threshold consecutive failures (default 3) it is taken out of rotation for cooldown_sec (default 300). Routes and fallbacks can set reasoning (off/low/medium/high) or max_reasoning_tokens for models that think, e.g. off for a cheap fast route and high for planning.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":        map[string]interface{}{"type": "string", "enum": []string{"list_templates", "get_template", "save_template", "list_providers", "register_provider", "set_route", "set_fallbacks"}, "description": "Action to perform"},
+						"template_name": map[string]string{"type": "string", "description": "Name of template (for start/get/save)"},
+						"template_body": map[string]interface{}{"type": "object", "description": "JSON body of ProviderTemplate (for save)"},
+						"provider_name": map[string]string{"type": "string", "description": "Name of provider instance (e.g. 'my_ollama')"},
+						"provider_config": map[string]interface{}{"type": "object", "description": "JSON body of LLMProviderEntry (type, api_key_env, base_url)"},
+						"route":         map[string]string{"type": "string", "description": "Route key (default: 'default')"},
+						"model":         map[string]string{"type": "string", "description": "Target model ID"},
+						"reasoning":     map[string]interface{}{"type": "string", "enum": []string{"off", "low", "medium", "high", "default"}, "description": "For set_route: how much a reasoning model thinks (default = the model's own setting; omit to keep the route's current one)"},
+						"max_reasoning_tokens": map[string]interface{}{"type": "integer", "minimum": 0, "description": "For set_route: thinking budget in tokens, used instead of the reasoning level (0 clears it; omit to keep the current one)"},
+						"fallbacks": map[string]interface{}{
+							"type":        "array",
+							"description": "For set_fallbacks: backup models in the order they are tried (empty clears them)",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"provider":             map[string]string{"type": "string"},
+									"model":                map[string]string{"type": "string"},
+									"reasoning":            map[string]interface{}{"type": "string", "enum": []string{"off", "low", "medium", "high"}},
+									"max_reasoning_tokens": map[string]interface{}{"type": "integer", "minimum": 0},
+								},
+								"required": []string{"provider", "model"},
+							},
+						},
+						"circuit_breaker": map[string]interface{}{
+							"type":        "object",
+							"description": "For set_fallbacks: failure_threshold and cooldown_sec for every model",
+							"properties": map[string]interface{}{
+								"failure_threshold": map[string]string{"type": "integer"},
+								"cooldown_sec":      map[string]string{"type": "integer"},
+							},
+						},
+					},
+					"required": []string{"action"},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "manage_embedding_provider",
+				Description: "Manage embedding provider configuration and default provider. Use this to add or switch embedding services (e.g. EmbeddingGood, or a local Ollama server with type 'ollama' and an embedding model such as nomic-embed-text).",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":         map[string]interface{}{"type": "string", "enum": []string{"list_providers", "register_provider", "set_default"}, "description": "Action to perform"},
+						"provider_name":  map[string]string{"type": "string", "description": "Name of provider instance (e.g. 'embeddinggood')"},
+						"provider_config": map[string]interface{}{"type": "object", "description": "JSON body of EmbeddingProviderEntry (type, base_url_env, api_key_env, dimension, model)"},
+					},
+					"required": []string{"action"},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "reembed_memories",
+				Description: "Check whether stored memories were embedded by another embedding model or dimension than the current one (recall cannot find those), and re-embed them in batches. Use after switching embedding providers. 'status' counts memories per model and dimension; 'run' re-embeds up to limit stale memories (default 1000) and reports how many remain, so call it again until none do.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{"type": "string", "enum": []string{"status", "run"}, "description": "status (default) or run"},
+						"all":    map[string]interface{}{"type": "boolean", "description": "run: re-embed every memory, e.g. after switching to a model with the same dimension"},
+						"limit":  map[string]interface{}{"type": "integer", "description": "run: most memories to re-embed in this call (default 1000)"},
+					},
+				},
+			},
+			Policy: "admin_only",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "manage_ollama",
+				Description: "Manage the models of a local Ollama server (the ollama provider from llm_routing.json, else http://localhost:11434). 'list' shows installed models, 'show' a model's capabilities (tools, embedding) and context length, 'pull' downloads a model from the Ollama library (can take many minutes), 'delete' removes one unless a route still uses it. Route to a pulled model with manage_llm_provider.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":   map[string]interface{}{"type": "string", "enum": []string{"list", "show", "pull", "delete"}, "description": "Action to perform"},
+						"model":    map[string]string{"type": "string", "description": "Model name, e.g. 'llama3.1:8b' or 'nomic-embed-text' (not needed for list)"},
+						"provider": map[string]string{"type": "string", "description": "ollama provider in llm_routing.json to manage (default: the first one)"},
+					},
+					"required": []string{"action"},
+				},
+			},
+			Policy: "admin_only",
+		},
+		// Nextcloud Tools
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "request_nextcloud_ocs",
+				Description: "Execute a Nextcloud OCS API request (Provisioning API, etc.) as the bot/admin. Use for managing users, groups, apps that have OCS endpoints.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"method":   map[string]interface{}{"type": "string", "enum": []string{"GET", "POST", "PUT", "DELETE"}, "description": "HTTP Method"},
+						"endpoint": map[string]string{"type": "string", "description": "API endpoint (e.g. /cloud/users). /ocs/v1.php is prepended automatically."},
+						"params":   map[string]interface{}{"type": "object", "description": "Query params (GET) or Form fields (POST/PUT). Map strings to strings."},
+					},
+					"required": []string{"method", "endpoint"},
+				},
+			},
+			Policy: "restricted", // Admin/Bot power
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "list_nextcloud_files",
+				Description: "List files in Nextcloud via WebDAV.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path": map[string]string{"type": "string", "description": "Path to list (e.g. / or /Documents)"},
+					},
+					"required": []string{"path"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "read_nextcloud_file",
+				Description: "Read file content from Nextcloud via WebDAV.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path": map[string]string{"type": "string", "description": "Path to file"},
+					},
+					"required": []string{"path"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "write_nextcloud_file",
+				Description: "Write text content to a file in Nextcloud (WebDAV). Fails if the file exists unless overwrite is true; the folder must exist.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path":      map[string]string{"type": "string", "description": "File path (e.g. /Documents/notes.md)"},
+						"content":   map[string]string{"type": "string", "description": "File content"},
+						"overwrite": map[string]interface{}{"type": "boolean", "description": "Replace an existing file (default false)"},
+					},
+					"required": []string{"path", "content"},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "upload_nextcloud_file",
+				Description: "Upload a file from the workspace to Nextcloud (WebDAV), e.g. a generated report or image. Fails if the destination exists unless overwrite is true.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"source":    map[string]string{"type": "string", "description": "Workspace-relative path of the file to upload"},
+						"path":      map[string]string{"type": "string", "description": "Destination in Nextcloud; ending in / uploads into that folder under the source's name"},
+						"overwrite": map[string]interface{}{"type": "boolean", "description": "Replace an existing file (default false)"},
+					},
+					"required": []string{"source", "path"},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "create_nextcloud_folder",
+				Description: "Create a folder in Nextcloud, including missing parent folders. An existing folder is fine.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path": map[string]string{"type": "string", "description": "Folder path (e.g. /Projects/Garden)"},
+					},
+					"required": []string{"path"},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "move_nextcloud_file",
+				Description: "Move or rename a file or folder in Nextcloud. Fails if the destination exists unless overwrite is true.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"from":      map[string]string{"type": "string", "description": "Current path"},
+						"to":        map[string]string{"type": "string", "description": "New path"},
+						"overwrite": map[string]interface{}{"type": "boolean", "description": "Replace an existing destination (default false)"},
+					},
+					"required": []string{"from", "to"},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "delete_nextcloud_file",
+				Description: "Delete a file or folder in Nextcloud. Deleted items go to the trash bin and can be restored there. A folder with contents needs recursive=true; if the trash bin is disabled the delete is refused unless permanent=true. Confirm with the user before deleting anything they did not ask to delete.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path":      map[string]string{"type": "string", "description": "Path to delete"},
+						"recursive": map[string]interface{}{"type": "boolean", "description": "Allow deleting a folder that has contents"},
+						"permanent": map[string]interface{}{"type": "boolean", "description": "Allow deleting when the trash bin is disabled (cannot be undone)"},
+					},
+					"required": []string{"path"},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "create_share",
+				Description: "Create a read-only public link to a file or folder in Nextcloud, optionally password-protected and expiring. Use it to hand over files you produced (reports, exports, images) instead of pasting their contents into chat; upload them first with upload_nextcloud_file or write_nextcloud_file.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path":              map[string]string{"type": "string", "description": "File or folder path in Nextcloud"},
+						"expires":           map[string]string{"type": "string", "description": "When the link stops working: a date ('2026-11-01') or duration ('7d'). Default: the server's policy"},
+						"password":          map[string]string{"type": "string", "description": "Password for the link"},
+						"generate_password": map[string]interface{}{"type": "boolean", "description": "Generate a strong password and return it (ignored when password is set)"},
+						"label":             map[string]string{"type": "string", "description": "Label shown in the share list"},
+						"note":              map[string]string{"type": "string", "description": "Note shown to recipients"},
+					},
+					"required": []string{"path"},
+				},
+			},
+			Policy: "restricted",
+		},
+		{
+			Type: "function",
+			Function: openrouter.FunctionSpec{
+				Name:        "manage_deck",
+				Description: "Manage Nextcloud Deck kanban boards: list boards, list a board's stacks and cards, create boards/stacks/cards, update or move cards between stacks, and set due dates. Use it to keep a board of your jobs and the family to-do list. Boards and stacks may be given by ID or title.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action":      map[string]interface{}{"type": "string", "enum": []string{"list_boards", "list_cards", "create_board", "create_stack", "create_card", "update_card", "move_card", "set_due"}, "description": "Action to perform"},
+						"board":       map[string]string{"type": "string", "description": "Board ID or title (all actions but list_boards and create_board)"},
+						"stack":       map[string]string{"type": "string", "description": "Stack ID or title: where to create a card (default: first stack), the target of move_card, or a filter for list_cards"},
+						"card_id":     map[string]interface{}{"type": "integer", "description": "Card ID (update_card, move_card, set_due)"},
+						"title":       map[string]string{"type": "string", "description": "Title of the new board, stack or card, or a card's new title"},
+						"description": map[string]string{"type": "string", "description": "Card description (Markdown)"},
+						"due":         map[string]string{"type": "string", "description": "Due date: RFC 3339, 'YYYY-MM-DD', 'tomorrow 17:00', 'in 3 days'. Empty clears it."},
+						"color":       map[string]string{"type": "string", "description": "Board color as hex (create_board; default 0082c9)"},
+					},
+					"required": []string{"action"},
 				},
 			},
+			Policy: "restricted",
 		},
 		{
 			Type: "function",
 			Function: openrouter.FunctionSpec{
-				Name:        "read_nextcloud_file",
-				Description: "Read file content from Nextcloud via WebDAV.",
+				Name:        "file_store",
+				Description: "Store and fetch files in a configured file store: a local directory, WebDAV or S3/MinIO bucket from config.json, or 'nextcloud' (the bot user's files). Use it to keep artifacts (reports, exports, generated files) outside the workspace. stores lists the available stores. Existing files are kept unless overwrite is set.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
-						"path": map[string]string{"type": "string", "description": "Path to file"},
+						"action":    map[string]interface{}{"type": "string", "enum": []string{"stores", "list", "read", "write", "upload", "download", "delete"}, "description": "Action to perform"},
+						"store":     map[string]string{"type": "string", "description": "Store name (default: default_file_store, or the only store)"},
+						"path":      map[string]string{"type": "string", "description": "Path in the store: the folder to list, or the file to read, write, download or delete. For upload, the destination (a path ending in / keeps the source's name)"},
+						"source":    map[string]string{"type": "string", "description": "Workspace file to upload"},
+						"dest":      map[string]string{"type": "string", "description": "Workspace path to download to (default: the file's name in the workspace root)"},
+						"content":   map[string]string{"type": "string", "description": "Text to write"},
+						"overwrite": map[string]interface{}{"type": "boolean", "description": "Replace an existing file (write, upload, download)"},
 					},
-					"required": []string{"path"},
+					"required": []string{"action"},
 				},
 			},
+			Policy: "restricted",
 		},
 
 		{
@@ -641,11 +1626,12 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 			Type: "function",
 			Function: openrouter.FunctionSpec{
 				Name:        "get_secret",
-				Description: "Retrieve a secure reference to a password/secret from Nextcloud Passwords app.",
+				Description: "Retrieve a secure reference to a password/secret from the secret store (Nextcloud Passwords app or the local encrypted store).",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"query": map[string]string{"type": "string", "description": "Title to search for"},
+						"store": map[string]interface{}{"type": "string", "enum": []string{"passwords", "local"}, "description": "Secret store to search (default: the configured default store)"},
 					},
 					"required": []string{"query"},
 				},
@@ -672,7 +1658,7 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 			Type: "function",
 			Function: openrouter.FunctionSpec{
 				Name:        "store_secret",
-				Description: "Store a new secret in Nextcloud Passwords app and share it with Admin.",
+				Description: "Store a new secret in the secret store: Nextcloud Passwords app (shared with Admin) or the local encrypted store (title and password only).",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
@@ -681,6 +1667,7 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 						"login":    map[string]string{"type": "string", "description": "Username (optional)"},
 						"url":      map[string]string{"type": "string", "description": "URL (optional)"},
 						"notes":    map[string]string{"type": "string", "description": "Notes (optional)"},
+						"store":    map[string]interface{}{"type": "string", "enum": []string{"passwords", "local"}, "description": "Secret store to write to (default: the configured default store)"},
 					},
 					"required": []string{"title", "password"},
 				},
@@ -692,6 +1679,36 @@ func BuiltinToolDefs() []openrouter.ToolDefinition {
 }
 
 // Helper to get user ID from context
+// claimWebhookRoute records the caller as the route's creator. Agent prompts then run as the
+// caller, with the caller's tools; only admins (and internal calls) may run them as someone else.
+func claimWebhookRoute(ctx context.Context, route *store.WebhookRoute) error {
+	userID, _ := ctx.Value("user_id").(string)
+	role, hasRole := ctx.Value("user_role").(string)
+	admin := !hasRole || store.RoleAtLeast(role, store.RoleAdmin)
+	route.CreatedBy = userID
+	claim := func(user *string) error {
+		if *user == "" {
+			*user = userID
+		} else if *user != userID && !admin {
+			return fmt.Errorf("only admins may run a webhook's prompts as another user (%s)", *user)
+		}
+		return nil
+	}
+	if route.TargetType == store.WebhookTargetAgentPrompt || route.TargetUser != "" {
+		if err := claim(&route.TargetUser); err != nil {
+			return err
+		}
+	}
+	for i := range route.Targets {
+		if route.Targets[i].Kind() == store.WebhookTargetAgentPrompt || route.Targets[i].User != "" {
+			if err := claim(&route.Targets[i].User); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func getUserID(ctx context.Context) (string, error) {
 	uid := ctx.Value("user_id")
 	if uid == nil {
@@ -717,6 +1734,18 @@ type Executor struct {
 	Spawner         core.SubmindSpawner  // For spawning sub-minds
 	SubmindRegistry core.SubmindRegistry // For managing sub-minds
 	SecretStore     *secrets.MultiStore
+	ErrorBudget     *errbudget.Budget // Reported by system_status
+	Credits         *creditmon.Monitor // OpenRouter credit monitor, reported by system_status
+	Sandbox         *sandbox.Config   // run_terminal_cmd profiles per trust level; nil runs unsandboxed
+	Egress          *egress.Proxy     // Outbound network policy for registered tools; nil leaves them unrestricted
+	Reloader        *reload.Reloader  // reload_config; nil when hot reload is not wired
+	Backups         *backup.Manager   // backup_now; nil when no backup target is configured
+	Briefings       *briefing.Service // manage_briefing preview and send_now; nil when not wired
+	WorkspaceSync   *worksync.Syncer  // sync_workspace; nil when no sync folder is configured
+	Feeds           *feeds.Poller     // manage_feed subscribe and check_now; nil when not wired
+	SelfUpdate      *selfupdate.Manager // self_update; nil when no source checkout is configured
+	Plugins         *plugins.Loader     // manage_plugin; nil when not wired
+	Events          *events.Bus         // manage_event_subscriptions and user.blocked events; nil when not wired
 }
 
 func (e *Executor) SetSpawner(spawner core.SubmindSpawner) {
@@ -731,30 +1760,41 @@ func (e *Executor) embed(ctx context.Context, text string, embedType string) ([]
 	return e.Client.Embed(ctx, text)
 }
 
+// embeddingModel names the model behind v, a vector from embed, recorded with each memory.
+func (e *Executor) embeddingModel(v []float32) string {
+	if embeddinggood.IsLocal(v) {
+		return embeddinggood.LocalModel
+	}
+	if e.Embedder != nil {
+		return core.EmbeddingModel(e.Embedder)
+	}
+	return "llm"
+}
+
 // Execute runs the tool by name with the given JSON arguments; returns JSON result.
 func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string, error) {
 	// Safety timeout: prevent tools from hanging the agent loop indefinitely.
 	// Default to 2 minutes, but allow known long-running tools (builds, CLI agents) more time.
 	timeout := 2 * time.Minute
-	if name == "run_terminal_cmd" || name == "autohand_cli" || name == "spawn_submind" {
+	if name == "run_terminal_cmd" || name == "autohand_cli" || name == "spawn_submind" || name == "check_submind" || name == "import_conversations" || name == "reembed_memories" || name == "manage_ollama" {
 		timeout = 15 * time.Minute
 	}
 
+	// Validate the arguments against the tool's schema before anything runs (and before secrets
+	// are resolved, so validation errors never echo secret values)
+	if schema := builtinSchema(name); schema != nil {
+		if errs := schema.ValidateJSON([]byte(argsJSON)); len(errs) > 0 {
+			return argsValidationError(name, errs, nil), nil
+		}
+	}
+
 	// Secret Resolution
-	// Look for {{secret:key}} and replace with value from SecretStore (default source: passwords)
+	// Look for {{secret:key}} and replace with value from SecretStore (default source: SecretStore.Default)
 	if e.SecretStore != nil && strings.Contains(argsJSON, "{{secret:") {
 		re := regexp.MustCompile(`\{\{secret:([^}]+)\}\}`)
 		argsJSON = re.ReplaceAllStringFunc(argsJSON, func(match string) string {
-			key := re.FindStringSubmatch(match)[1]
-			// TODO: Support {{secret:source:key}} syntax? For now assume passwords app.
-			// If key starts with "env:", use env source.
-			source := "passwords"
-			if strings.HasPrefix(key, "env:") {
-				source = "env"
-				key = strings.TrimPrefix(key, "env:")
-			}
-			
-			val, err := e.SecretStore.GetSecret(source, key)
+			// {{secret:source:key}} picks a source (env, passwords, local, vault); plain keys use the default.
+			val, err := e.SecretStore.Resolve(re.FindStringSubmatch(match)[1])
 			if err != nil {
 				return "ERROR_MISSING_SECRET" 
 			}
@@ -796,12 +1836,15 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 	defer cancel()
 
 	// 1. Check updated builtin registry
-	if tool, ok := builtin.Registry[name]; ok {
+	if tool, ok := builtin.Lookup(name); ok {
 		return tool.Execute(ctx, argsJSON)
 	}
 
 	switch name {
 	case "run_terminal_cmd":
+		if e.Sandbox != nil {
+			return RunSandboxedTerminalTool(ctx, e.WorkspaceDir, e.Sandbox, argsJSON)
+		}
 		return RunTerminalTool(ctx, e.WorkspaceDir, argsJSON)
 	case "read_file":
 		return ReadFileTool(ctx, e.WorkspaceDir, argsJSON)
@@ -818,6 +1861,14 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 	case "manage_context_doc":
 		return ManageContextDocTool(ctx, e.DB, argsJSON)
 
+	case "manage_notifications":
+		return ManageNotificationsTool(ctx, e.DB, e.Gateway, argsJSON)
+	case "manage_profile":
+		return ManageProfileTool(ctx, e.DB, argsJSON)
+	case "manage_thread":
+		return ManageThreadTool(ctx, e.DB, argsJSON)
+	case "link_identity":
+		return LinkIdentityTool(ctx, e.DB, argsJSON)
 	case "manage_user_preference":
 		userID, err := getUserID(ctx)
 		if err != nil {
@@ -874,7 +1925,8 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 			return ErrJSON(fmt.Errorf("embed failed: %w", err)), nil
 		}
 		// Store
-		if err := e.DB.InsertChunk(ctx, args.Content, args.Source, emb); err != nil {
+		userID, _ := ctx.Value("user_id").(string)
+		if err := e.DB.InsertProjectChunk(ctx, args.Content, args.Source, userID, builtin.CurrentProjectID(ctx, e.DB), e.embeddingModel(emb), emb); err != nil {
 			return ErrJSON(err), nil
 		}
 		return `{"status": "memorized"}`, nil
@@ -894,7 +1946,7 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 		if err != nil {
 			return ErrJSON(fmt.Errorf("embed failed: %w", err)), nil
 		}
-		chunks, err := e.DB.SearchChunks(ctx, emb, args.Limit)
+		chunks, err := e.DB.SearchProjectChunks(ctx, emb, args.Limit, builtin.CurrentProjectID(ctx, e.DB))
 		if err != nil {
 			return ErrJSON(err), nil
 		}
@@ -1003,39 +2055,33 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 			Autonomous   bool                   `json:"autonomous"`
 			Tool         string                 `json:"tool"`
 			ToolArgs     map[string]interface{} `json:"tool_args"`
+			CalendarCheck string                `json:"calendar_check"`
+			Timezone     string                 `json:"timezone"`
+			Limit        int                    `json:"limit"`
+			Preapprove   bool                   `json:"preapprove"`
 		}
 		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 			return ErrJSON(err), nil
 		}
 		switch args.Action {
 		case "create":
-			// Parse run_at into time
+			// Parse run_at into time; recurring rules are evaluated on the wall clock of the plan's zone
+			loc, err := scheduler.LoadLocation(args.Timezone)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
 			var nextRun time.Time
-			var err error
 			if args.ScheduleType == "once" {
-				nextRun, err = time.Parse(time.RFC3339, args.RunAt)
+				nextRun, err = timeparse.Until(args.RunAt, time.Now(), loc)
 				if err != nil {
-					// Try simpler formats
-					nextRun, err = time.Parse("2006-01-02 15:04", args.RunAt)
+					return ErrJSON(fmt.Errorf("run_at for once: %w", err)), nil
 				}
 			} else {
-				// For recurring, parse time and set to today or tomorrow
-				t, parseErr := time.Parse("15:04", args.RunAt)
-				if parseErr != nil {
-					t = time.Now().Add(1 * time.Hour) // Default: 1 hour from now
-				} else {
-					now := time.Now()
-					nextRun = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
-					if nextRun.Before(now) {
-						nextRun = nextRun.Add(24 * time.Hour)
-					}
+				rule, ruleErr := scheduler.ParseRule(args.ScheduleType, args.RunAt, args.Timezone)
+				if ruleErr != nil {
+					return ErrJSON(ruleErr), nil
 				}
-				if nextRun.IsZero() {
-					nextRun = time.Now().Add(1 * time.Hour)
-				}
-			}
-			if err != nil && nextRun.IsZero() {
-				nextRun = time.Now().Add(24 * time.Hour) // Default fallback
+				nextRun = rule.Next(time.Now())
 			}
 			actionType := args.ActionType
 			if actionType == "" {
@@ -1047,6 +2093,13 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 				if args.Tool == "" {
 					return ErrJSON(fmt.Errorf("execute_tool requires tool name")), nil
 				}
+				allowed, err := e.canScheduleTool(ctx, userID, args.Tool)
+				if err != nil {
+					return ErrJSON(err), nil
+				}
+				if !allowed {
+					return ErrJSON(fmt.Errorf("you are not allowed to run %s, so you cannot schedule it", args.Tool)), nil
+				}
 				toolArgs := args.ToolArgs
 				if toolArgs == nil {
 					toolArgs = map[string]interface{}{}
@@ -1064,10 +2117,51 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 					actionPayload = string(b)
 				}
 			}
-			id, err := e.DB.CreatePlan(ctx, userID, args.Description, actionType, actionPayload, args.ScheduleType, args.RunAt, nextRun)
+			// Calendar-aware scheduling: avoid firing during the user's meetings.
+			calendarInfo := map[string]interface{}{}
+			if args.CalendarCheck != "" && args.ScheduleType == "once" && e.Config != nil {
+				busy, calErr := nextcloud.UserBusyPeriods(e.Config, userID, nextRun.Add(-time.Second), nextRun.Add(24*time.Hour))
+				if calErr != nil {
+					calendarInfo["calendar_warning"] = "calendar not checked: " + calErr.Error()
+				} else if free, conflict := nextcloud.NextFree(busy, nextRun); conflict != nil {
+					if args.CalendarCheck == "warn" {
+						b, _ := json.Marshal(map[string]interface{}{
+							"status":           "conflict",
+							"conflict":         conflict,
+							"requested_run_at": nextRun.Format(time.RFC3339),
+							"suggested_run_at": free.Format(time.RFC3339),
+							"note":             "Not scheduled. Ask the user whether to use the suggested time instead, then create again.",
+						})
+						return string(b), nil
+					}
+					calendarInfo["conflict"] = conflict
+					calendarInfo["adjusted_from"] = nextRun.Format(time.RFC3339)
+					nextRun = free
+					args.RunAt = free.Format(time.RFC3339)
+				}
+			}
+			id, err := e.DB.CreatePlan(ctx, userID, args.Description, actionType, actionPayload, args.ScheduleType, args.RunAt, args.Timezone, nextRun)
 			if err != nil {
 				return ErrJSON(err), nil
 			}
+			// Schedules created while working in a project belong to it
+			if projectID := builtin.CurrentProjectID(ctx, e.DB); projectID != 0 {
+				_ = e.DB.SetPlanProject(ctx, userID, id, projectID)
+			}
+			// Only a preapproval the user confirmed (see middleware.Drafts) counts
+			if confirmed, _ := ctx.Value("draft_confirmed").(bool); args.Preapprove && confirmed {
+				if err := e.DB.SetPlanPreapproved(ctx, userID, id); err != nil {
+					return ErrJSON(err), nil
+				}
+				calendarInfo["preapproved"] = true
+			}
+			if len(calendarInfo) > 0 {
+				calendarInfo["id"] = id
+				calendarInfo["status"] = "scheduled"
+				calendarInfo["next_run"] = nextRun.Format(time.RFC3339)
+				b, _ := json.Marshal(calendarInfo)
+				return string(b), nil
+			}
 			return fmt.Sprintf(`{"id": %d, "status": "scheduled", "next_run": "%s"}`, id, nextRun.Format(time.RFC3339)), nil
 		case "list":
 			plans, err := e.DB.ListPlans(ctx, userID, "active")
@@ -1086,26 +2180,139 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 				return ErrJSON(err), nil
 			}
 			return `{"status": "paused"}`, nil
+		case "history":
+			plans, err := e.DB.ListPlans(ctx, userID, "")
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			var plan *store.ScheduledPlan
+			for i := range plans {
+				if plans[i].ID == args.ID {
+					plan = &plans[i]
+				}
+			}
+			if plan == nil {
+				return ErrJSON(fmt.Errorf("plan %d not found", args.ID)), nil
+			}
+			runs, err := e.DB.ListPlanRuns(ctx, plan.ID, args.Limit)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			b, _ := json.Marshal(map[string]interface{}{"plan": plan, "runs": runs})
+			return string(b), nil
 		default:
 			return ErrJSON(fmt.Errorf("unknown action: %s", args.Action)), nil
 		}
 	case "approve_user":
-		return ApproveUser(ctx, e.DB, argsJSON)
+		out, err := ApproveUser(ctx, e.DB, argsJSON)
+		var args struct {
+			Level string `json:"level"`
+		}
+		if err == nil && json.Unmarshal([]byte(argsJSON), &args) == nil && args.Level == "blocked" {
+			e.publishUserBlocked(ctx, argsJSON)
+		}
+		return out, err
 	case "block_user":
-		return BlockUser(ctx, e.DB, argsJSON)
+		out, err := BlockUser(ctx, e.DB, argsJSON)
+		if err == nil {
+			e.publishUserBlocked(ctx, argsJSON)
+		}
+		return out, err
 	case "list_users":
 		return ListUsers(ctx, e.DB, argsJSON)
+	case "read_audit_log":
+		return ReadAuditLogTool(ctx, e.DB, argsJSON)
+	case "manage_onboarding":
+		return ManageOnboardingTool(ctx, e.DB, e.Config, e.Gateway, argsJSON)
+	case "announce":
+		return AnnounceTool(ctx, e.DB, e.Gateway, argsJSON)
+	case "manage_permissions":
+		return ManagePermissionsTool(ctx, e.DB, e.WorkspaceDir, argsJSON)
+	case "manage_api_tokens":
+		return ManageAPITokensTool(ctx, e.DB, argsJSON)
+	case "manage_event_subscriptions":
+		return ManageEventSubscriptionsTool(ctx, e.DB, e.Events, argsJSON)
+	case "manage_network_policy":
+		return ManageNetworkPolicyTool(ctx, e.Egress, argsJSON)
+	case "add_admin":
+		return AddAdmin(ctx, e.DB, argsJSON)
+	case "remove_admin":
+		return RemoveAdmin(ctx, e.DB, argsJSON)
 	case "register_tool":
 		var args struct {
+			Action      string `json:"action"`
 			Name        string `json:"name"`
 			BinaryPath  string `json:"binary_path"`
 			Description string `json:"description"`
 			InputSchema string `json:"input_schema"`
 			ForceUpdate bool   `json:"force_update"`
+			SourceDir   string `json:"source_dir"`
+			AllowUnsafe bool   `json:"allow_unsafe"`
+			Version     int    `json:"version"`
+			Type        string `json:"type"`
+			URL         string `json:"url"`
+			AuthHeader  string `json:"auth_header"`
+			AuthSecret  string `json:"auth_secret"`
 		}
 		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 			return ErrJSON(err), nil
 		}
+		switch args.Action {
+		case "", "register":
+		case "list_versions":
+			versions, err := e.DB.ListToolVersions(ctx, args.Name)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			repairs, err := e.DB.ListToolRepairs(ctx, args.Name, 10)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			b, _ := json.Marshal(map[string]interface{}{"name": args.Name, "versions": versions, "repairs": repairs})
+			return string(b), nil
+		case "rollback":
+			out, err := e.rollbackTool(ctx, args.Name, args.Version)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			return out, nil
+		default:
+			return ErrJSON(fmt.Errorf("unknown action: %s", args.Action)), nil
+		}
+		if _, err := jsonschema.Parse([]byte(args.InputSchema)); err != nil {
+			return ErrJSON(fmt.Errorf("input_schema: %w", err)), nil
+		}
+		switch args.Type {
+		case "", store.ToolKindBinary:
+		case store.ToolKindHTTP:
+			if args.URL == "" || args.Description == "" {
+				return ErrJSON(fmt.Errorf("url and description are required to register an http tool")), nil
+			}
+			existing, err := e.DB.ToolByName(ctx, args.Name)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			if existing != nil && !args.ForceUpdate {
+				return `{"error": "tool already exists, set force_update=true to register a new version"}`, nil
+			}
+			return e.registerHTTPTool(ctx, existing, args.Name, args.URL, args.AuthHeader, args.AuthSecret, args.Description, args.InputSchema)
+		default:
+			return ErrJSON(fmt.Errorf("unknown tool type %q (use binary or http)", args.Type)), nil
+		}
+		if args.BinaryPath == "" || args.Description == "" {
+			return ErrJSON(fmt.Errorf("binary_path and description are required to register a tool")), nil
+		}
+		binaryPath := e.resolveBinary(args.BinaryPath)
+		// Static safety check of the Go source; blocking findings refuse registration unless an
+		// admin explicitly accepts them
+		report, safetyErr := e.checkToolSource(ctx, args.Name, binaryPath, args.SourceDir)
+		if safetyErr != nil {
+			trust, _ := ctx.Value("user_trust").(string)
+			if report == nil || !args.AllowUnsafe || trust != "admin" {
+				b, _ := json.Marshal(map[string]interface{}{"error": safetyErr.Error(), "safety_report": report})
+				return string(b), nil
+			}
+		}
 		// Check if exists
 		existing, err := e.DB.ToolByName(ctx, args.Name)
 		if err != nil {
@@ -1113,29 +2320,51 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 		}
 		if existing != nil {
 			if !args.ForceUpdate {
-				return `{"error": "tool already exists, set force_update=true to overwrite"}`, nil
-			}
-			if err := e.DB.DeleteTool(ctx, args.Name); err != nil {
-				return ErrJSON(err), nil
+				return `{"error": "tool already exists, set force_update=true to register a new version"}`, nil
 			}
 		}
 		// Pre-deployment validation: run binary with sample input and require valid JSON stdout
-		binaryPath := args.BinaryPath
-		if !filepath.IsAbs(binaryPath) && e.WorkspaceDir != "" {
-			binaryPath = filepath.Join(e.WorkspaceDir, filepath.Clean(binaryPath))
-		}
-		stdout, _, code, runErr := ExecuteRegisteredTool(ctx, binaryPath, "{}", nil)
+		stdout, _, code, runErr := ExecuteRegisteredTool(ctx, binaryPath, "{}", withEgress(e.Egress, args.Name, nil))
 		if runErr != nil {
 			return ErrJSON(fmt.Errorf("tool contract test failed: %w", runErr)), nil
 		}
 		if !ValidateToolOutput(stdout, code) {
 			return ErrJSON(fmt.Errorf("tool failed contract test: output was not valid JSON (exit_code=%d)", code)), nil
 		}
-		id, err := e.DB.InsertTool(ctx, args.Name, args.BinaryPath, args.Description, args.InputSchema)
+		version, err := e.registerToolVersion(ctx, existing, args.Name, binaryPath, args.BinaryPath, args.Description, args.InputSchema, args.SourceDir)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		registered, err := e.DB.ToolByName(ctx, args.Name)
+		if err != nil || registered == nil {
+			return ErrJSON(fmt.Errorf("tool %s vanished after registration: %v", args.Name, err)), nil
+		}
+		out := map[string]interface{}{"id": registered.ID, "status": "registered", "version": version}
+		if report != nil {
+			out["safety_report"] = report
+		} else {
+			out["safety_report"] = "skipped: no Go source found (pass source_dir to check it)"
+		}
+		b, _ := json.Marshal(out)
+		return string(b), nil
+	case "export_toolpack":
+		return e.ExportToolpackTool(ctx, argsJSON)
+	case "import_toolpack":
+		return e.ImportToolpackTool(ctx, argsJSON)
+	case "read_tool_source":
+		var args struct {
+			Name    string `json:"name"`
+			Version int    `json:"version"`
+			File    string `json:"file"`
+		}
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return ErrJSON(err), nil
+		}
+		out, err := e.readToolSource(ctx, args.Name, args.Version, args.File)
 		if err != nil {
 			return ErrJSON(err), nil
 		}
-		return fmt.Sprintf(`{"id": %d, "status": "registered"}`, id), nil
+		return out, nil
 	case "delete_tool":
 		var args struct {
 			Name string `json:"name"`
@@ -1146,6 +2375,7 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 		if err := e.DB.DeleteTool(ctx, args.Name); err != nil {
 			return ErrJSON(err), nil
 		}
+		e.removeToolArchive(args.Name)
 		return `{"status": "deleted"}`, nil
 	case "execute_registered_tool":
 		var args struct {
@@ -1163,9 +2393,18 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 		if len(args.Args) > 0 {
 			argsStr = string(args.Args)
 		}
-		result, err := ExecuteRegisteredToolByName(ctx, e.DB, e.WorkspaceDir, args.Name, argsStr, args.EnvVars)
-		if err != nil {
-			return result, err
+		if invalid := e.validateRegisteredArgs(ctx, args.Name, argsStr); invalid != "" {
+			return invalid, nil
+		}
+		var result string
+		if tool, _, _ := findRegisteredTool(ctx, e.DB, args.Name); tool != nil && tool.Kind == store.ToolKindHTTP {
+			result = e.executeHTTPTool(ctx, tool, argsStr)
+		} else {
+			res, err := ExecuteRegisteredToolByName(ctx, e.DB, e.WorkspaceDir, args.Name, argsStr, withEgress(e.Egress, args.Name, args.EnvVars))
+			if err != nil {
+				return res, err
+			}
+			result = res
 		}
 		// Health recording: validate tool stdout/exit_code and record success or failure (skip when result is lookup error)
 		if e.DB != nil && args.Name != "" {
@@ -1183,6 +2422,8 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 						errMsg = out.Stdout
 					}
 					_ = e.DB.RecordToolFailure(ctx, args.Name, errMsg)
+					_ = e.DB.RecordToolFailedInput(ctx, args.Name, argsStr)
+					result = e.rollbackHint(ctx, args.Name, result)
 				}
 			}
 		}
@@ -1192,16 +2433,70 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 	case "list_skills":
 		return ListSkillsTool(ctx, e.ConfigDir)
 	case "system_status":
-		gatherer := &SystemStatusGatherer{
-			DB:          e.DB,
-			LogStore:    e.LogStore,
-			Gateway:     e.Gateway,
-			Compactor:   nil, // Will be set if available
-			Client:      e.Client.(*openrouter.Client),
-			HealthReg:   e.HealthReg,
-			TokenBudget: e.TokenBudget,
+		return SystemStatusTool(ctx, e.StatusGatherer())
+	case "reload_config":
+		if e.Reloader == nil {
+			return `{"error": "configuration reload is not available"}`, nil
+		}
+		var args struct {
+			DryRun bool `json:"dry_run"`
+		}
+		if argsJSON != "" {
+			_ = json.Unmarshal([]byte(argsJSON), &args)
+		}
+		res, err := e.Reloader.Reload("reload_config", args.DryRun)
+		out := map[string]interface{}{"result": res}
+		if err != nil {
+			out["error"] = err.Error()
+		}
+		b, _ := json.MarshalIndent(out, "", "  ")
+		return string(b), nil
+	case "purge_user":
+		return PurgeUserTool(ctx, e.DB, argsJSON)
+	case "backup_now":
+		if e.Backups == nil {
+			return `{"error": "backups are not configured; set HATTIEBOT_BACKUP_TARGET"}`, nil
+		}
+		var args struct {
+			List bool `json:"list"`
+		}
+		if argsJSON != "" {
+			_ = json.Unmarshal([]byte(argsJSON), &args)
+		}
+		if args.List {
+			names, err := e.Backups.Target.List(ctx)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			b, _ := json.MarshalIndent(map[string]interface{}{"target": e.Backups.Target.String(), "backups": names, "last": e.Backups.Last()}, "", "  ")
+			return string(b), nil
+		}
+		res, err := e.Backups.Run(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.MarshalIndent(res, "", "  ")
+		return string(b), nil
+	case "sync_workspace":
+		if e.WorkspaceSync == nil {
+			return `{"error": "workspace sync is not configured; set HATTIEBOT_WORKSPACE_SYNC_FOLDER"}`, nil
+		}
+		var args struct {
+			Action string `json:"action"`
+		}
+		if argsJSON != "" {
+			_ = json.Unmarshal([]byte(argsJSON), &args)
+		}
+		if args.Action == "status" {
+			b, _ := json.MarshalIndent(map[string]interface{}{"remote": e.WorkspaceSync.Remote.String(), "last": e.WorkspaceSync.Last()}, "", "  ")
+			return string(b), nil
+		}
+		rep, err := e.WorkspaceSync.Run(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
 		}
-		return SystemStatusTool(ctx, gatherer)
+		b, _ := json.MarshalIndent(rep, "", "  ")
+		return string(b), nil
 	case "read_logs":
 		if e.LogStore == nil {
 			return `{"error": "log store not configured"}`, nil
@@ -1227,6 +2522,12 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 			return ErrJSON(err), nil
 		}
 		return `{"status": "logged"}`, nil
+	case "git":
+		return e.GitTool(ctx, argsJSON)
+	case "self_update":
+		return e.SelfUpdateTool(ctx, argsJSON)
+	case "manage_plugin":
+		return ManagePluginTool(ctx, e.WorkspaceDir, e.Plugins, argsJSON)
 	case "read_self_modification_log":
 		var args struct {
 			Limit int `json:"limit"`
@@ -1273,15 +2574,32 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 			return ErrJSON(fmt.Errorf("config dir not configured")), nil
 		}
 		var args struct {
-			Path         string `json:"path"`
-			ID           string `json:"id"`
-			SecretHeader string `json:"secret_header"`
-			SecretEnv    string `json:"secret_env"`
-			SecretSource string `json:"secret_source"`
-			SecretKey    string `json:"secret_key"`
-			AuthType     string `json:"auth_type"`
-			TargetTool   string `json:"target_tool"`
-			TargetArgs   string `json:"target_args"`
+			Path                string                   `json:"path"`
+			ID                  string                   `json:"id"`
+			SecretHeader        string                   `json:"secret_header"`
+			SecretEnv           string                   `json:"secret_env"`
+			SecretSource        string                   `json:"secret_source"`
+			SecretKey           string                   `json:"secret_key"`
+			AuthType            string                   `json:"auth_type"`
+			TargetType          string                   `json:"target_type"`
+			TargetTool          string                   `json:"target_tool"`
+			TargetArgs          string                   `json:"target_args"`
+			TargetPrompt        string                   `json:"target_prompt"`
+			TargetUser          string                   `json:"target_user"`
+			TargetThread        string                   `json:"target_thread"`
+			Targets             []store.WebhookTarget    `json:"targets"`
+			Filter              []store.WebhookCondition `json:"filter"`
+			DeliveryIDHeader    string                   `json:"delivery_id_header"`
+			DeliveryIDPath      string                   `json:"delivery_id_path"`
+			DedupTTLHours       int                      `json:"dedup_ttl_hours"`
+			OnDuplicate         string                   `json:"on_duplicate"`
+			Mode                string                   `json:"mode"`
+			TimeoutSec          int                      `json:"timeout_sec"`
+			Retries             int                      `json:"retries"`
+			RetryDelaySec       int                      `json:"retry_delay_sec"`
+			ResponseTemplate    string                   `json:"response_template"`
+			ResponseContentType string                   `json:"response_content_type"`
+			ErrorStatus         int                      `json:"error_status"`
 		}
 		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 			return ErrJSON(err), nil
@@ -1301,21 +2619,47 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 				return ErrJSON(fmt.Errorf("route with path %s or id %s already exists", args.Path, args.ID)), nil
 			}
 		}
-		routes = append(routes, store.WebhookRoute{
-			Path:         args.Path,
-			ID:           args.ID,
-			SecretHeader: args.SecretHeader,
-			SecretEnv:    args.SecretEnv,
-			SecretSource: args.SecretSource,
-			SecretKey:    args.SecretKey,
-			AuthType:     args.AuthType,
-			TargetTool:   args.TargetTool,
-			TargetArgs:   args.TargetArgs,
-		})
+		route := store.WebhookRoute{
+			Path:                args.Path,
+			ID:                  args.ID,
+			SecretHeader:        args.SecretHeader,
+			SecretEnv:           args.SecretEnv,
+			SecretSource:        args.SecretSource,
+			SecretKey:           args.SecretKey,
+			AuthType:            args.AuthType,
+			TargetType:          args.TargetType,
+			TargetTool:          args.TargetTool,
+			TargetArgs:          args.TargetArgs,
+			TargetPrompt:        args.TargetPrompt,
+			TargetUser:          args.TargetUser,
+			TargetThread:        args.TargetThread,
+			Targets:             args.Targets,
+			Filter:              args.Filter,
+			DeliveryIDHeader:    args.DeliveryIDHeader,
+			DeliveryIDPath:      args.DeliveryIDPath,
+			DedupTTLHours:       args.DedupTTLHours,
+			OnDuplicate:         args.OnDuplicate,
+			Mode:                args.Mode,
+			TimeoutSec:          args.TimeoutSec,
+			Retries:             args.Retries,
+			RetryDelaySec:       args.RetryDelaySec,
+			ResponseTemplate:    args.ResponseTemplate,
+			ResponseContentType: args.ResponseContentType,
+			ErrorStatus:         args.ErrorStatus,
+		}
+		if err := claimWebhookRoute(ctx, &route); err != nil {
+			return ErrJSON(err), nil
+		}
+		if err := route.Validate(); err != nil {
+			return ErrJSON(err), nil
+		}
+		routes = append(routes, route)
 		if err := store.SaveWebhookRoutes(e.ConfigDir, routes); err != nil {
 			return ErrJSON(err), nil
 		}
 		return `{"status": "added", "path": "` + args.Path + `"}`, nil
+	case "manage_recipe":
+		return e.ManageRecipeTool(ctx, argsJSON)
 	case "remove_webhook_route":
 		if e.ConfigDir == "" {
 			return ErrJSON(fmt.Errorf("config dir not configured")), nil
@@ -1357,17 +2701,23 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 			Gateway:     e.Gateway,
 			HealthReg:   e.HealthReg,
 			TokenBudget: e.TokenBudget,
+			ErrorBudget: e.ErrorBudget,
+			Credits:     e.Credits,
 		}
 		status, err := gatherer.Gather(ctx)
 		if err != nil {
 			return ErrJSON(err), nil
 		}
 		statusJSON, _ := json.MarshalIndent(status, "", "  ")
+		input := string(statusJSON)
+		if digest := feedbackDigest(ctx, e.DB); digest != "" {
+			input += "\n\nUser feedback on your replies (reactions and /feedback):\n" + digest
+		}
 		userID := ""
 		if uid := ctx.Value("user_id"); uid != nil {
 			userID = uid.(string)
 		}
-		result, err := e.Spawner.SpawnSubmind(ctx, userID, "reflection", string(statusJSON), 0)
+		result, err := e.Spawner.SpawnSubmind(ctx, userID, "reflection", input, 0)
 		if err != nil {
 			return ErrJSON(err), nil
 		}
@@ -1380,6 +2730,7 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 		}
 		var args struct {
 			Message string `json:"message"`
+			Urgency string `json:"urgency"`
 		}
 		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil || args.Message == "" {
 			return ErrJSON(fmt.Errorf("message required")), nil
@@ -1387,10 +2738,42 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 		if e.Router == nil {
 			return ErrJSON(fmt.Errorf("router not configured")), nil
 		}
-		if err := e.Router.RouteMessage(ctx, userID, args.Message, ""); err != nil {
+		if err := e.Router.RouteMessage(ctx, userID, args.Message, args.Urgency); err != nil {
 			return ErrJSON(err), nil
 		}
 		return `{"status": "sent"}`, nil
+	case "report_task_result":
+		return ReportTaskResultTool(ctx, e.DB, argsJSON)
+	case "import_conversations":
+		return e.ImportConversationsTool(ctx, argsJSON)
+	case "export_thread":
+		return e.ExportThreadTool(ctx, argsJSON)
+	case "branch_thread":
+		return e.BranchThreadTool(ctx, argsJSON)
+	case "manage_prompt_experiment":
+		return e.ManagePromptExperimentTool(ctx, argsJSON)
+	case AskUserToolName:
+		return e.AskUserTool(ctx, argsJSON)
+	case "react":
+		var args struct {
+			Emoji string `json:"emoji"`
+		}
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil || strings.TrimSpace(args.Emoji) == "" {
+			return ErrJSON(fmt.Errorf("emoji required")), nil
+		}
+		msg, ok := gateway.MessageFromContext(ctx)
+		if !ok {
+			return ErrJSON(fmt.Errorf("no current message to react to")), nil
+		}
+		if e.Gateway == nil {
+			return ErrJSON(fmt.Errorf("gateway not configured")), nil
+		}
+		if err := e.Gateway.React(msg, strings.TrimSpace(args.Emoji)); err != nil {
+			return ErrJSON(err), nil
+		}
+		return `{"status": "reacted"}`, nil
+	case "talk_actions":
+		return TalkActionsTool(ctx, e.Gateway, argsJSON)
 	case "spawn_submind":
 		if e.Spawner == nil {
 			return `{"error": "sub-mind spawner not configured"}`, nil
@@ -1400,19 +2783,36 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 			userID = uid.(string)
 		}
 		var args struct {
-			Mode      string `json:"mode"`
-			Task      string `json:"task"`
-			SessionID int64  `json:"session_id"`
+			Mode      string        `json:"mode"`
+			Task      string        `json:"task"`
+			SessionID int64         `json:"session_id"`
+			Async     bool          `json:"async"`
+			Tasks     []submindTask `json:"tasks"`
 		}
 		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 			return ErrJSON(err), nil
 		}
+		if len(args.Tasks) > 0 {
+			return e.startSubminds(ctx, userID, args.Tasks)
+		}
+		if args.Mode == "" || args.Task == "" {
+			return ErrJSON(fmt.Errorf("mode and task are required")), nil
+		}
+		if args.Async && args.SessionID == 0 {
+			return e.startSubminds(ctx, userID, []submindTask{{Mode: args.Mode, Task: args.Task}})
+		}
 		result, err := e.Spawner.SpawnSubmind(ctx, userID, args.Mode, args.Task, args.SessionID)
 		if err != nil {
 			return ErrJSON(err), nil
 		}
 		out, _ := json.MarshalIndent(result, "", "  ")
 		return string(out), nil
+	case "check_submind":
+		return e.CheckSubmindTool(ctx, argsJSON)
+	case "manage_briefing":
+		return ManageBriefingTool(ctx, e.DB, e.Briefings, argsJSON)
+	case "manage_feed":
+		return ManageFeedTool(ctx, e.DB, e.Feeds, argsJSON)
 	case "manage_submind":
 		if e.SubmindRegistry == nil {
 			return `{"error": "sub-mind registry not configured"}`, nil
@@ -1423,6 +2823,7 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 			SystemPrompt string   `json:"system_prompt"`
 			AllowedTools []string `json:"allowed_tools"`
 			MaxTurns     int      `json:"max_turns"`
+			OutputSchema map[string]interface{} `json:"output_schema"`
 		}
 		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 			return ErrJSON(err), nil
@@ -1433,10 +2834,13 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 			out, _ := json.MarshalIndent(list, "", "  ")
 			return string(out), nil
 		case "create", "update":
-			// Validate: cannot include blocked tools
-			for _, tool := range args.AllowedTools {
-				if BlockedTools[tool] {
-					return fmt.Sprintf(`{"error": "cannot grant blocked tool: %s"}`, tool), nil
+			// Validate: patterns must compile and cannot name blocked tools
+			if err := ValidateAllowedTools(args.AllowedTools); err != nil {
+				return ErrJSON(err), nil
+			}
+			if args.OutputSchema != nil {
+				if _, err := jsonschema.FromValue(args.OutputSchema); err != nil {
+					return ErrJSON(fmt.Errorf("output_schema: %w", err)), nil
 				}
 			}
 			cfg := core.SubMindConfig{
@@ -1445,6 +2849,7 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 				AllowedTools: args.AllowedTools,
 				MaxTurns:     args.MaxTurns,
 				Protected:    false, // Agent-created are not protected
+				OutputSchema: args.OutputSchema,
 			}
 			if err := e.SubmindRegistry.Add(cfg); err != nil {
 				return ErrJSON(err), nil
@@ -1476,6 +2881,14 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 		return ManageLLMProviderTool(ctx, e.ConfigDir, argsJSON)
 	case "manage_embedding_provider":
 		return ManageEmbeddingProviderTool(ctx, e.ConfigDir, argsJSON)
+	case "manage_ollama":
+		return ManageOllamaTool(ctx, e.ConfigDir, argsJSON)
+	case "reembed_memories":
+		embedder := e.Embedder
+		if embedder == nil {
+			embedder = embeddinggood.NewLLMEmbedWrapper(e.Client)
+		}
+		return ReembedMemoriesTool(ctx, e.DB, embedder, argsJSON)
 	
 	// Nextcloud Tools
 	case "request_nextcloud_ocs":
@@ -1513,16 +2926,168 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 			return ErrJSON(err), nil
 		}
 		return nextcloud.ReadNextcloudFile(e.Config, args.Path)
+	case "write_nextcloud_file":
+		if e.Config == nil {
+			return ErrJSON(fmt.Errorf("config not available")), nil
+		}
+		var args struct {
+			Path      string `json:"path"`
+			Content   string `json:"content"`
+			Overwrite bool   `json:"overwrite"`
+		}
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return ErrJSON(err), nil
+		}
+		if err := nextcloud.UploadNextcloudFile(e.Config, args.Path, []byte(args.Content), args.Overwrite); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "written", "path": %q, "bytes": %d}`, args.Path, len(args.Content)), nil
+	case "upload_nextcloud_file":
+		if e.Config == nil {
+			return ErrJSON(fmt.Errorf("config not available")), nil
+		}
+		var args struct {
+			Source    string `json:"source"`
+			Path      string `json:"path"`
+			Overwrite bool   `json:"overwrite"`
+		}
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return ErrJSON(err), nil
+		}
+		src, err := workspacePath(e.WorkspaceDir, args.Source)
+		if err != nil {
+			return ErrJSON(fmt.Errorf("source must be inside the workspace")), nil
+		}
+		info, err := os.Stat(src)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if info.IsDir() {
+			return ErrJSON(fmt.Errorf("%s is a folder; upload files one at a time", args.Source)), nil
+		}
+		if info.Size() > maxNextcloudUpload {
+			return ErrJSON(fmt.Errorf("%s is %d MB; uploads are limited to %d MB", args.Source, info.Size()>>20, maxNextcloudUpload>>20)), nil
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		dest := args.Path
+		if strings.HasSuffix(dest, "/") {
+			dest += filepath.Base(src)
+		}
+		if err := nextcloud.UploadNextcloudFile(e.Config, dest, data, args.Overwrite); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "uploaded", "path": %q, "bytes": %d}`, dest, len(data)), nil
+	case "create_nextcloud_folder":
+		if e.Config == nil {
+			return ErrJSON(fmt.Errorf("config not available")), nil
+		}
+		var args struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return ErrJSON(err), nil
+		}
+		if err := nextcloud.CreateNextcloudFolder(e.Config, args.Path); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "created", "path": %q}`, args.Path), nil
+	case "move_nextcloud_file":
+		if e.Config == nil {
+			return ErrJSON(fmt.Errorf("config not available")), nil
+		}
+		var args struct {
+			From      string `json:"from"`
+			To        string `json:"to"`
+			Overwrite bool   `json:"overwrite"`
+		}
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return ErrJSON(err), nil
+		}
+		if err := nextcloud.MoveNextcloudFile(e.Config, args.From, args.To, args.Overwrite); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "moved", "from": %q, "to": %q}`, args.From, args.To), nil
+	case "delete_nextcloud_file":
+		if e.Config == nil {
+			return ErrJSON(fmt.Errorf("config not available")), nil
+		}
+		var args struct {
+			Path      string `json:"path"`
+			Recursive bool   `json:"recursive"`
+			Permanent bool   `json:"permanent"`
+		}
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return ErrJSON(err), nil
+		}
+		trashed, err := nextcloud.DeleteNextcloudFile(e.Config, args.Path, args.Recursive, args.Permanent)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "deleted", "path": %q, "in_trash": %t}`, args.Path, trashed), nil
+	case "create_share":
+		if e.Config == nil {
+			return ErrJSON(fmt.Errorf("config not available")), nil
+		}
+		var args struct {
+			Path             string `json:"path"`
+			Expires          string `json:"expires"`
+			Password         string `json:"password"`
+			GeneratePassword bool   `json:"generate_password"`
+			Label            string `json:"label"`
+			Note             string `json:"note"`
+		}
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return ErrJSON(err), nil
+		}
+		opts := nextcloud.ShareOptions{Password: args.Password, GeneratePassword: args.GeneratePassword, Label: args.Label, Note: args.Note}
+		if args.Expires != "" {
+			t, err := timeparse.Until(args.Expires, time.Now(), nil)
+			if err != nil {
+				return ErrJSON(fmt.Errorf("expires: %w", err)), nil
+			}
+			if !t.After(time.Now()) {
+				return ErrJSON(fmt.Errorf("expires must be in the future")), nil
+			}
+			opts.Expires = t
+		}
+		share, err := nextcloud.CreatePublicShare(e.Config, args.Path, opts)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(share)
+		return string(b), nil
+	case "manage_deck":
+		if e.Config == nil {
+			return ErrJSON(fmt.Errorf("config not available")), nil
+		}
+		var args nextcloud.DeckArgs
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return ErrJSON(err), nil
+		}
+		return nextcloud.ManageDeck(e.Config, args)
+	case "file_store":
+		return e.FileStoreTool(ctx, argsJSON)
 	case "get_secret":
 		if e.Config == nil {
 			return ErrJSON(fmt.Errorf("config not available")), nil
 		}
 		var args struct {
 			Query string `json:"query"`
+			Store string `json:"store"`
 		}
 		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 			return ErrJSON(err), nil
 		}
+		local, err := secretBackend(e.SecretStore, args.Store)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if local != nil {
+			return GetLocalSecretTool(local, args.Query)
+		}
 		return nextcloud.GetNextcloudSecret(e.Config, args.Query)
 	case "store_secret":
 		if e.Config == nil {
@@ -1534,10 +3099,18 @@ func (e *Executor) Execute(ctx context.Context, name, argsJSON string) (string,
 			Login    string `json:"login"`
 			URL      string `json:"url"`
 			Notes    string `json:"notes"`
+			Store    string `json:"store"`
 		}
 		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 			return ErrJSON(err), nil
 		}
+		local, err := secretBackend(e.SecretStore, args.Store)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if local != nil {
+			return StoreLocalSecretTool(local, args.Title, args.Password)
+		}
 		return nextcloud.StoreSecret(e.Config, args.Title, args.Password, args.Login, args.URL, args.Notes)
 	case "manage_trust":
 		var args struct {
@@ -1587,21 +3160,3 @@ func ErrJSON(err error) string {
 	b, _ := json.Marshal(map[string]string{"error": err.Error()})
 	return string(b)
 }
-
-// parseDuration parses human-readable durations like "1h", "2d", "30m"
-func parseDuration(s string) (time.Duration, error) {
-	if s == "" {
-		return 0, fmt.Errorf("duration is empty")
-	}
-	// Check for day suffix (not supported by time.ParseDuration)
-	if len(s) > 1 && s[len(s)-1] == 'd' {
-		days := s[:len(s)-1]
-		var d int
-		if _, err := fmt.Sscanf(days, "%d", &d); err != nil {
-			return 0, err
-		}
-		return time.Duration(d) * 24 * time.Hour, nil
-	}
-	// Standard Go duration parsing for h, m, s
-	return time.ParseDuration(s)
-}
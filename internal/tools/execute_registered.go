@@ -8,8 +8,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/hattiebot/hattiebot/internal/egress"
 	"github.com/hattiebot/hattiebot/internal/store"
 )
 
@@ -45,13 +47,13 @@ func ExecuteRegisteredTool(ctx context.Context, binaryPath, argsJSON string, env
 // ExecuteRegisteredToolByName looks up the tool by name in the registry and runs it.
 // If binaryPath in the registry is relative, it is resolved against workspaceDir.
 func ExecuteRegisteredToolByName(ctx context.Context, db store.ToolRegistry, workspaceDir, name, argsJSON string, envVars map[string]string) (string, error) {
-	tool, err := db.ToolByName(ctx, name)
+	tool, names, err := findRegisteredTool(ctx, db, name)
 	if err != nil {
 		out, _ := json.Marshal(map[string]string{"error": err.Error()})
 		return string(out), nil
 	}
 	if tool == nil {
-		out, _ := json.Marshal(map[string]string{"error": "tool not found: " + name})
+		out, _ := json.Marshal(map[string]interface{}{"error": "tool not found: " + name, "registered_tools": names})
 		return string(out), nil
 	}
 	binaryPath := tool.BinaryPath
@@ -68,6 +70,25 @@ func ExecuteRegisteredToolByName(ctx context.Context, db store.ToolRegistry, wor
 	return string(raw), nil
 }
 
+// findRegisteredTool looks a tool up in the registry. The registry is queried on every call
+// (read-after-write), so a tool registered earlier in the turn is found; case and -/_ differences
+// in the name are tolerated. When nothing matches, the registered names are returned instead.
+func findRegisteredTool(ctx context.Context, db store.ToolRegistry, name string) (*store.RegisteredTool, []string, error) {
+	tool, err := db.ToolByName(ctx, name)
+	if err != nil || tool != nil {
+		return tool, nil, err
+	}
+	all, _ := db.AllTools(ctx)
+	var names []string
+	for i := range all {
+		if normalizeToolName(all[i].Name) == normalizeToolName(name) {
+			return &all[i], nil, nil
+		}
+		names = append(names, all[i].Name)
+	}
+	return nil, names, nil
+}
+
 // ValidateToolOutput returns true if exitCode is 0 and stdout is valid JSON (tool contract).
 // Used for health recording: invalid or non-zero triggers RecordToolFailure.
 func ValidateToolOutput(stdout string, exitCode int) bool {
@@ -81,3 +102,24 @@ func ValidateToolOutput(stdout string, exitCode int) bool {
 	var v interface{}
 	return json.Unmarshal(trimmed, &v) == nil
 }
+
+// withEgress adds the egress proxy settings for tool to envVars; they override caller-supplied
+// values so a tool cannot opt out of the network policy by setting its own proxy variables.
+func withEgress(proxy *egress.Proxy, tool string, envVars map[string]string) map[string]string {
+	proxyEnv := proxy.Env(tool)
+	if len(proxyEnv) == 0 {
+		return envVars
+	}
+	out := make(map[string]string, len(envVars)+len(proxyEnv))
+	for k, v := range envVars {
+		out[k] = v
+	}
+	for k, v := range proxyEnv {
+		out[k] = v
+	}
+	return out
+}
+
+func normalizeToolName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "-", "_"))
+}
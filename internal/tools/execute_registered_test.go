@@ -1,7 +1,10 @@
 package tools
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/egress"
 )
 
 func TestValidateToolOutput(t *testing.T) {
@@ -16,7 +19,7 @@ func TestValidateToolOutput(t *testing.T) {
 		{"valid json with error key", `{"error":""}`, 0, true},
 		{"invalid json", "not json", 0, false},
 		{"empty stdout", "", 0, false},
-		{"non-zero exit", `{"result":"ok"}`, 1, false},
+		{"non-zero exit with json error report", `{"error":"bad input"}`, 1, true},
 		{"truncated json", `{"result":`, 0, false},
 		{"whitespace then json", "  \n{\"a\":1}  ", 0, true},
 	}
@@ -29,3 +32,21 @@ func TestValidateToolOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestWithEgressOverridesToolProxy(t *testing.T) {
+	if env := withEgress(nil, "t", map[string]string{"A": "1"}); env["A"] != "1" || len(env) != 1 {
+		t.Errorf("without a proxy env should pass through, got %v", env)
+	}
+	proxy, err := egress.NewProxy(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proxy.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	env := withEgress(proxy, "weather", map[string]string{"A": "1", "HTTPS_PROXY": "http://elsewhere:3128", "NO_PROXY": "*"})
+	if env["A"] != "1" || env["NO_PROXY"] != "" || !strings.Contains(env["HTTPS_PROXY"], "weather@"+proxy.Addr()) {
+		t.Errorf("env = %v", env)
+	}
+}
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/convexport"
+	"github.com/hattiebot/hattiebot/internal/tools/nextcloud"
+)
+
+// ExportThreadTool writes a thread, or all of a user's history, to Markdown or JSONL in the
+// workspace or in Nextcloud Files. Non-admins may only export their own conversations. Workspace
+// exports go under exports/ and never replace a file, so the tool cannot stand in for write_file.
+func (e *Executor) ExportThreadTool(ctx context.Context, argsJSON string) (string, error) {
+	var args struct {
+		ThreadID      string `json:"thread_id"`
+		UserID        string `json:"user_id"`
+		Format        string `json:"format"`
+		IncludeTools  bool   `json:"include_tools"`
+		Path          string `json:"path"`
+		NextcloudPath string `json:"nextcloud_path"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	caller, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	if args.ThreadID == "" && args.UserID == "" {
+		args.UserID = caller
+	}
+	if trust, _ := ctx.Value("user_trust").(string); trust != "admin" {
+		if args.UserID != "" && args.UserID != caller {
+			return ErrJSON(fmt.Errorf("unauthorized: you can only export your own conversations")), nil
+		}
+		if args.ThreadID != "" {
+			if err := e.checkThreadAccess(ctx, caller, args.ThreadID); err != nil {
+				return ErrJSON(err), nil
+			}
+		}
+	}
+
+	opts := convexport.Options{ThreadID: args.ThreadID, UserID: args.UserID, Format: args.Format, IncludeTools: args.IncludeTools}
+	var buf strings.Builder
+	stats, err := convexport.Export(ctx, e.DB, opts, &buf)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	name := convexport.FileName(opts, time.Now())
+	out := map[string]interface{}{"status": "exported", "threads": stats.Threads, "messages": stats.Messages, "bytes": buf.Len()}
+
+	if args.NextcloudPath != "" {
+		dest := args.NextcloudPath
+		if strings.HasSuffix(dest, "/") {
+			dest += name
+		}
+		if err := nextcloud.WriteNextcloudFile(e.Config, dest, buf.String()); err != nil {
+			return ErrJSON(err), nil
+		}
+		out["nextcloud_path"] = dest
+	} else {
+		rel := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(args.Path)), "exports/")
+		if args.Path == "" {
+			rel = name
+		}
+		abs, err := workspacePath(filepath.Join(e.WorkspaceDir, "exports"), rel)
+		if err != nil || filepath.Clean(rel) == "." {
+			return ErrJSON(fmt.Errorf("path must name a file under exports/")), nil
+		}
+		if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+			return ErrJSON(err), nil
+		}
+		f, err := os.OpenFile(abs, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			return ErrJSON(fmt.Errorf("exports/%s already exists; choose another path", filepath.ToSlash(filepath.Clean(rel)))), nil
+		}
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		_, err = f.WriteString(buf.String())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		out["path"] = "exports/" + filepath.ToSlash(filepath.Clean(rel))
+	}
+	b, _ := json.MarshalIndent(out, "", "  ")
+	return string(b), nil
+}
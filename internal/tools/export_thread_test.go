@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestExportThreadWritesOnlyNewFilesUnderExports(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.InsertMessage(ctx, "user", "hello", "", "alice", "api", "t1", "", "", "")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.md"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := &Executor{DB: db, WorkspaceDir: dir}
+	ctx = context.WithValue(ctx, "user_id", "alice")
+
+	if out, _ := e.ExportThreadTool(ctx, `{"path": "exports/alice.md"}`); !strings.Contains(out, `"path": "exports/alice.md"`) {
+		t.Fatalf("export = %s", out)
+	}
+	if out, _ := e.ExportThreadTool(ctx, `{"path": "alice.md"}`); !strings.Contains(out, "already exists") {
+		t.Errorf("overwrite = %s", out)
+	}
+	for _, path := range []string{"../notes.md", "exports/../../notes.md"} {
+		if out, _ := e.ExportThreadTool(ctx, `{"path": "`+path+`"}`); !strings.Contains(out, "under exports/") {
+			t.Errorf("%s = %s", path, out)
+		}
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "notes.md")); string(b) != "keep" {
+		t.Errorf("notes.md = %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "exports", "alice.md")); !strings.Contains(string(b), "hello") {
+		t.Errorf("export file = %q", b)
+	}
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// feedbackWindow is how far back self_reflect looks at user feedback.
+const feedbackWindow = 7 * 24 * time.Hour
+
+// feedbackDigestItems caps the individual feedback entries passed to self_reflect.
+const feedbackDigestItems = 15
+
+// feedbackDigest summarizes the user feedback of the last week for self_reflect: counts, then
+// entries with complaints and comments first, each with the start of the rated reply. It returns
+// "" when there is none.
+func feedbackDigest(ctx context.Context, db *store.DB) string {
+	if db == nil {
+		return ""
+	}
+	all, err := db.FeedbackSince(ctx, time.Now().Add(-feedbackWindow))
+	if err != nil || len(all) == 0 {
+		return ""
+	}
+	type item struct {
+		Rating  int    `json:"rating"`
+		Comment string `json:"comment,omitempty"`
+		Reply   string `json:"reply,omitempty"`
+		At      string `json:"at"`
+	}
+	digest := struct {
+		Days     int    `json:"days"`
+		Positive int    `json:"positive"`
+		Negative int    `json:"negative"`
+		Comments int    `json:"comments"`
+		Items    []item `json:"items"`
+	}{Days: int(feedbackWindow / (24 * time.Hour))}
+	for _, f := range all {
+		switch {
+		case f.Rating > 0:
+			digest.Positive++
+		case f.Rating < 0:
+			digest.Negative++
+		}
+		if f.Comment != "" {
+			digest.Comments++
+		}
+	}
+	// Stable sort keeps newest first within each group: complaints, comments, praise
+	weight := func(f store.Feedback) int {
+		switch {
+		case f.Rating < 0:
+			return 0
+		case f.Comment != "":
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(all, func(i, j int) bool { return weight(all[i]) < weight(all[j]) })
+	for _, f := range all {
+		if len(digest.Items) == feedbackDigestItems {
+			break
+		}
+		digest.Items = append(digest.Items, item{f.Rating, f.Comment, snippet(f.Reply, 300), f.CreatedAt.Format("2006-01-02")})
+	}
+	b, _ := json.MarshalIndent(digest, "", "  ")
+	return string(b)
+}
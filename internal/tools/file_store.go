@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hattiebot/hattiebot/internal/filestore"
+)
+
+const (
+	maxFileStoreTransfer = 100 << 20 // upload and download
+	maxFileStoreRead     = 100 << 10 // read returns text into the conversation
+)
+
+// FileStoreTool moves files between the workspace and a configured file store (local dir,
+// WebDAV or S3 from config.json, or the bot user's Nextcloud files).
+func (e *Executor) FileStoreTool(ctx context.Context, argsJSON string) (string, error) {
+	var args struct {
+		Action    string `json:"action"`
+		Store     string `json:"store"`
+		Path      string `json:"path"`
+		Source    string `json:"source"`
+		Dest      string `json:"dest"`
+		Content   string `json:"content"`
+		Overwrite bool   `json:"overwrite"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if e.Config == nil {
+		return ErrJSON(fmt.Errorf("config not available")), nil
+	}
+	if args.Action == "stores" {
+		stores := []map[string]string{}
+		for _, name := range filestore.Names(e.Config) {
+			entry := map[string]string{"name": name}
+			if st, err := filestore.Open(e.Config, name); err != nil {
+				entry["error"] = err.Error()
+			} else {
+				entry["location"] = st.String()
+			}
+			stores = append(stores, entry)
+		}
+		b, _ := json.MarshalIndent(map[string]interface{}{"stores": stores, "default": e.Config.DefaultFileStore}, "", "  ")
+		return string(b), nil
+	}
+	st, err := filestore.Open(e.Config, args.Store)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "list":
+		objs, err := st.List(ctx, args.Path)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.MarshalIndent(map[string]interface{}{"store": st.String(), "path": args.Path, "entries": objs}, "", "  ")
+		return string(b), nil
+	case "read":
+		rc, err := st.Get(ctx, args.Path)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, maxFileStoreRead+1))
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		truncated := len(data) > maxFileStoreRead
+		if truncated {
+			data = data[:maxFileStoreRead]
+			// The cut may split the last character
+			for i := 0; i < utf8.UTFMax-1 && len(data) > 0 && !utf8.Valid(data); i++ {
+				data = data[:len(data)-1]
+			}
+		}
+		if !utf8.Valid(data) {
+			return ErrJSON(fmt.Errorf("%s is not a text file; use download to copy it to the workspace", args.Path)), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{"path": args.Path, "content": string(data), "truncated": truncated})
+		return string(b), nil
+	case "write":
+		if err := fileStorePut(ctx, st, args.Path, strings.NewReader(args.Content), int64(len(args.Content)), args.Overwrite); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "written", "store": %q, "path": %q, "bytes": %d}`, st.String(), args.Path, len(args.Content)), nil
+	case "upload":
+		src, err := workspacePath(e.WorkspaceDir, args.Source)
+		if err != nil {
+			return ErrJSON(fmt.Errorf("source must be inside the workspace")), nil
+		}
+		info, err := os.Stat(src)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if info.IsDir() || info.Size() > maxFileStoreTransfer {
+			return ErrJSON(fmt.Errorf("source must be a file of at most %d MB", maxFileStoreTransfer>>20)), nil
+		}
+		dest := args.Path
+		if dest == "" || strings.HasSuffix(dest, "/") {
+			dest += filepath.Base(src)
+		}
+		f, err := os.Open(src)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		defer f.Close()
+		if err := fileStorePut(ctx, st, dest, f, info.Size(), args.Overwrite); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "uploaded", "store": %q, "path": %q, "bytes": %d}`, st.String(), dest, info.Size()), nil
+	case "download":
+		dest := args.Dest
+		if dest == "" || strings.HasSuffix(dest, "/") {
+			dest += path.Base(args.Path)
+		}
+		abs, err := workspacePath(e.WorkspaceDir, dest)
+		if err != nil {
+			return ErrJSON(fmt.Errorf("dest must be inside the workspace")), nil
+		}
+		if _, err := os.Stat(abs); err == nil && !args.Overwrite {
+			return ErrJSON(fmt.Errorf("%s already exists in the workspace; set overwrite to replace it", dest)), nil
+		}
+		rc, err := st.Get(ctx, args.Path)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, maxFileStoreTransfer+1))
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if len(data) > maxFileStoreTransfer {
+			return ErrJSON(fmt.Errorf("%s is larger than %d MB", args.Path, maxFileStoreTransfer>>20)), nil
+		}
+		if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+			return ErrJSON(err), nil
+		}
+		if err := os.WriteFile(abs, data, 0644); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "downloaded", "path": %q, "dest": %q, "bytes": %d}`, args.Path, dest, len(data)), nil
+	case "delete":
+		if err := st.Delete(ctx, args.Path); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "deleted", "store": %q, "path": %q}`, st.String(), args.Path), nil
+	}
+	return ErrJSON(fmt.Errorf("unknown action %q (use stores, list, read, write, upload, download or delete)", args.Action)), nil
+}
+
+// fileStorePut writes a file, keeping an existing one unless overwrite is set.
+func fileStorePut(ctx context.Context, st filestore.Store, key string, r io.Reader, size int64, overwrite bool) error {
+	key, err := filestore.CleanKey(key)
+	if err != nil {
+		return err
+	}
+	if !overwrite {
+		objs, err := st.List(ctx, path.Dir(key))
+		if err != nil {
+			return err
+		}
+		for _, o := range objs {
+			if o.Key == key {
+				return fmt.Errorf("%s already exists; set overwrite to replace it", key)
+			}
+		}
+	}
+	return st.Put(ctx, key, r, size)
+}
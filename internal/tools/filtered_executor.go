@@ -2,32 +2,33 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/hattiebot/hattiebot/internal/core"
 	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
 )
 
 // BlockedTools are NEVER allowed in sub-minds (prevents nesting).
 var BlockedTools = map[string]bool{
 	"spawn_submind":  true,
 	"manage_submind": true,
+	"check_submind":  true,
 }
 
-// FilteredExecutor wraps an executor to only allow specific tools.
+// FilteredExecutor wraps an executor to only allow the tools a sub-mind's allowlist permits.
 type FilteredExecutor struct {
-	Inner        core.ToolExecutor
-	AllowedTools map[string]bool
+	Inner     core.ToolExecutor
+	Allowlist *ToolAllowlist
+	// Registry resolves direct calls to registered tools; nil leaves them unknown.
+	Registry store.ToolRegistry
 }
 
-// NewFilteredExecutor creates a new filtered executor.
+// NewFilteredExecutor creates a new filtered executor from allowed_tools entries (see ToolAllowlist).
 func NewFilteredExecutor(inner core.ToolExecutor, allowed []string) *FilteredExecutor {
-	allowedMap := make(map[string]bool)
-	for _, name := range allowed {
-		allowedMap[name] = true
-	}
 	return &FilteredExecutor{
-		Inner:        inner,
-		AllowedTools: allowedMap,
+		Inner:     inner,
+		Allowlist: CompileToolAllowlist(allowed),
 	}
 }
 
@@ -37,29 +38,47 @@ func (f *FilteredExecutor) Execute(ctx context.Context, name, args string) (stri
 	if BlockedTools[name] {
 		return `{"error": "tool not available in sub-mind context"}`, nil
 	}
-	// Check if tool is in allowed list
-	if !f.AllowedTools[name] {
-		return `{"error": "tool not available in this sub-mind mode"}`, nil
+	role, _ := ctx.Value("user_role").(string)
+	if name == "execute_registered_tool" {
+		var call struct {
+			Name string `json:"name"`
+		}
+		_ = json.Unmarshal([]byte(args), &call)
+		if !f.Allowlist.AllowsRegistered(call.Name) {
+			return `{"error": "registered tool not available in this sub-mind mode"}`, nil
+		}
+		return f.Inner.Execute(ctx, name, args)
+	}
+	if policy, builtin := builtinToolPolicy(name); builtin {
+		if !f.Allowlist.AllowsBuiltin(name, policy, role) {
+			return `{"error": "tool not available in this sub-mind mode"}`, nil
+		}
+		return f.Inner.Execute(ctx, name, args)
 	}
-	return f.Inner.Execute(ctx, name, args)
+	// A registered tool called by its own name runs through execute_registered_tool.
+	if f.Registry != nil && f.Allowlist.AllowsRegistered(name) {
+		if rt, err := f.Registry.ToolByName(ctx, name); err == nil && rt != nil {
+			toolArgs := json.RawMessage("{}")
+			if json.Valid([]byte(args)) {
+				toolArgs = json.RawMessage(args)
+			}
+			wrapped, _ := json.Marshal(map[string]interface{}{"name": name, "args": toolArgs})
+			return f.Inner.Execute(ctx, "execute_registered_tool", string(wrapped))
+		}
+	}
+	// Other names pass only when listed explicitly (or by glob), as before patterns existed.
+	if matchAny(name, f.Allowlist.builtin) && !matchAny(name, f.Allowlist.excluded) {
+		return f.Inner.Execute(ctx, name, args)
+	}
+	return `{"error": "tool not available in this sub-mind mode"}`, nil
 }
 
 func (f *FilteredExecutor) SetSpawner(spawner core.SubmindSpawner) {
 	f.Inner.SetSpawner(spawner)
 }
 
-// FilterToolDefs returns only tools matching the allowed list, excluding blocked tools.
+// FilterToolDefs returns only tools matching the allowed entries, excluding blocked tools. Role-gated
+// tools reached through "inherit" are not filtered; use ToolAllowlist.FilterDefs with the caller's role.
 func FilterToolDefs(all []openrouter.ToolDefinition, allowed []string) []openrouter.ToolDefinition {
-	allowSet := make(map[string]bool)
-	for _, name := range allowed {
-		allowSet[name] = true
-	}
-	var out []openrouter.ToolDefinition
-	for _, td := range all {
-		name := td.Function.Name
-		if allowSet[name] && !BlockedTools[name] {
-			out = append(out, td)
-		}
-	}
-	return out
+	return CompileToolAllowlist(allowed).FilterDefs(all, "")
 }
@@ -3,6 +3,8 @@ package tools
 import (
 	"context"
 	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/core"
 )
 
 type MockExecutor struct {
@@ -14,6 +16,8 @@ func (m *MockExecutor) Execute(ctx context.Context, name, args string) (string,
 	return "ok", nil
 }
 
+func (m *MockExecutor) SetSpawner(spawner core.SubmindSpawner) {}
+
 func TestFilteredExecutor(t *testing.T) {
 	mock := &MockExecutor{}
 	allowed := []string{"allowed_tool"}
@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hattiebot/hattiebot/internal/gitops"
+)
+
+// GitTool runs git on the checkout of HattieBot's own source (HATTIEBOT_GIT_REPO_DIR), so core
+// code changes become commits on a branch and a pull request instead of untracked edits.
+func (e *Executor) GitTool(ctx context.Context, argsJSON string) (string, error) {
+	var args struct {
+		Action  string   `json:"action"`
+		Paths   []string `json:"paths"`
+		Staged  bool     `json:"staged"`
+		Limit   int      `json:"limit"`
+		Branch  string   `json:"branch"`
+		Create  bool     `json:"create"`
+		Message string   `json:"message"`
+		All     bool     `json:"all"`
+		Title   string   `json:"title"`
+		Body    string   `json:"body"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if e.Config == nil {
+		return ErrJSON(fmt.Errorf("config not available")), nil
+	}
+	repo := &gitops.Repo{Dir: e.Config.GitRepoDir, Remote: e.Config.GitRemote, BaseBranch: e.Config.GitBaseBranch}
+	if err := repo.Check(ctx); err != nil {
+		return ErrJSON(err), nil
+	}
+	out := func(v interface{}) (string, error) {
+		b, _ := json.MarshalIndent(v, "", "  ")
+		return string(b), nil
+	}
+
+	switch args.Action {
+	case "status", "":
+		st, err := repo.Status(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return out(st)
+	case "diff":
+		diff, truncated, err := repo.Diff(ctx, args.Staged, args.Paths)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return out(map[string]interface{}{"diff": diff, "truncated": truncated})
+	case "log":
+		limit := args.Limit
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+		commits, err := repo.Log(ctx, limit)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return out(map[string]interface{}{"commits": commits})
+	case "branch":
+		if args.Branch == "" {
+			branches, err := repo.Branches(ctx)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			current, _ := repo.CurrentBranch(ctx)
+			return out(map[string]interface{}{"current": current, "branches": branches})
+		}
+		if err := repo.Switch(ctx, args.Branch, args.Create); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "switched", "branch": %q}`, args.Branch), nil
+	case "commit":
+		sha, err := repo.Commit(ctx, args.Message, args.Paths, args.All)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "committed", "commit": %q, "note": "push and open_pr to get it reviewed"}`, sha), nil
+	case "push":
+		token, err := e.gitToken()
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		repo.Token = token
+		branch, err := repo.Push(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "pushed", "branch": %q}`, branch), nil
+	case "open_pr":
+		if args.Title == "" {
+			return ErrJSON(fmt.Errorf("title is required")), nil
+		}
+		token, err := e.gitToken()
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		head, err := repo.CurrentBranch(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		base := e.Config.GitBaseBranch
+		if base == "" {
+			base = "main"
+		}
+		if head == base {
+			return ErrJSON(fmt.Errorf("on the base branch %s; open a pull request from a feature branch", base)), nil
+		}
+		remoteURL, err := repo.RemoteURL(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		forge, err := gitops.ForgeFromRemote(gitops.Forge{Kind: e.Config.GitForge, APIURL: e.Config.GitForgeURL, Repo: e.Config.GitForgeRepo, Token: token}, remoteURL)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		pr, err := forge.OpenPullRequest(ctx, head, base, args.Title, args.Body)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return out(map[string]interface{}{"status": "opened", "number": pr.Number, "url": pr.URL, "head": head, "base": base})
+	}
+	return ErrJSON(fmt.Errorf("unknown action %q (use status, diff, log, branch, commit, push or open_pr)", args.Action)), nil
+}
+
+// gitToken resolves HATTIEBOT_GIT_TOKEN_SECRET; "" when none is configured.
+func (e *Executor) gitToken() (string, error) {
+	if e.Config.GitTokenSecret == "" {
+		return "", nil
+	}
+	if e.SecretStore == nil {
+		return "", fmt.Errorf("no secret store to resolve the git token")
+	}
+	token, err := e.SecretStore.Resolve(e.Config.GitTokenSecret)
+	if err != nil {
+		return "", fmt.Errorf("git token: %w", err)
+	}
+	return token, nil
+}
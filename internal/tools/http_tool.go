@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// defaultAuthHeader is sent when an HTTP tool has a secret but no header template.
+const defaultAuthHeader = "Authorization: Bearer {secret}"
+
+// httpToolMaxResponse caps the response body read from an HTTP tool.
+const httpToolMaxResponse = 10 << 20
+
+// registerHTTPTool registers (or, with an existing tool, replaces) a tool that is a remote
+// service: execute_registered_tool POSTs the arguments to u as JSON. There is no contract test,
+// since calling a heavyweight service with empty arguments may be slow or costly.
+func (e *Executor) registerHTTPTool(ctx context.Context, existing *store.RegisteredTool, name, u, authHeader, authSecret, description, inputSchema string) (string, error) {
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrJSON(fmt.Errorf("url must be an absolute http or https URL")), nil
+	}
+	if authHeader != "" && authSecret == "" {
+		return ErrJSON(fmt.Errorf("auth_header needs auth_secret")), nil
+	}
+	if authSecret != "" {
+		if authHeader == "" {
+			authHeader = defaultAuthHeader
+		}
+		if hname, value, ok := strings.Cut(authHeader, ":"); !ok || strings.TrimSpace(hname) == "" || !strings.Contains(value, "{secret}") {
+			return ErrJSON(fmt.Errorf(`auth_header must look like "Header-Name: ... {secret}"`)), nil
+		}
+		// Resolve once now so a typo fails registration rather than every call
+		if _, err := e.resolveToolSecret(authSecret); err != nil {
+			return ErrJSON(fmt.Errorf("auth_secret: %w", err)), nil
+		}
+	}
+	version := 1
+	if existing != nil {
+		latest, err := e.DB.LatestToolVersion(ctx, name)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		version = existing.Version + 1
+		if latest >= version {
+			version = latest + 1
+		}
+	}
+	t := store.RegisteredTool{Name: name, Description: description, InputSchema: inputSchema, Version: version, URL: u, AuthHeader: authHeader, AuthSecret: authSecret}
+	if err := e.DB.SaveHTTPTool(ctx, t); err != nil {
+		return ErrJSON(err), nil
+	}
+	b, _ := json.Marshal(map[string]interface{}{"status": "registered", "kind": store.ToolKindHTTP, "version": version, "url": u})
+	return string(b), nil
+}
+
+func (e *Executor) resolveToolSecret(ref string) (string, error) {
+	if e.SecretStore == nil {
+		return "", fmt.Errorf("no secret store is configured")
+	}
+	return e.SecretStore.Resolve(ref)
+}
+
+// executeHTTPTool POSTs argsJSON to an HTTP tool and reports the response in the shape of a
+// binary tool's result, so health recording treats both alike: the body is stdout, and exit_code
+// is 0 for a 2xx status and the status code otherwise. The request goes through the egress proxy
+// when it runs, so the network policy applies to remote tools too.
+func (e *Executor) executeHTTPTool(ctx context.Context, tool *store.RegisteredTool, argsJSON string) string {
+	result := func(stdout, stderr string, exitCode, status int) string {
+		out := map[string]interface{}{"stdout": stdout, "stderr": stderr, "exit_code": exitCode}
+		if status != 0 {
+			out["http_status"] = status
+		}
+		b, _ := json.Marshal(out)
+		return string(b)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tool.URL, bytes.NewReader([]byte(argsJSON)))
+	if err != nil {
+		return result("", err.Error(), -1, 0)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if tool.AuthSecret != "" {
+		secret, err := e.resolveToolSecret(tool.AuthSecret)
+		if err != nil {
+			return result("", "auth_secret: "+err.Error(), -1, 0)
+		}
+		name, value, _ := strings.Cut(tool.AuthHeader, ":")
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(strings.ReplaceAll(value, "{secret}", secret)))
+	}
+	client := &http.Client{}
+	if proxy := e.Egress.Env(tool.Name)["HTTP_PROXY"]; proxy != "" {
+		if pu, err := url.Parse(proxy); err == nil {
+			client.Transport = &http.Transport{Proxy: http.ProxyURL(pu)}
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return result("", err.Error(), -1, 0)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httpToolMaxResponse))
+	if err != nil {
+		return result(string(body), err.Error(), -1, resp.StatusCode)
+	}
+	code := 0
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		code = resp.StatusCode
+	}
+	return result(string(body), "", code, resp.StatusCode)
+}
@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/convimport"
+	"github.com/hattiebot/hattiebot/internal/core"
+)
+
+// importChannel is the channel recorded on imported messages; threads are "import:<source>:<conversation id>".
+const importChannel = "import"
+
+// distillTranscriptChars bounds how much of each conversation is sent to the LLM for distillation.
+const distillTranscriptChars = 24000
+
+// ImportConversationsTool imports a ChatGPT or Claude export into message history (one thread per
+// conversation, original timestamps kept) and optionally distills each conversation into a memory
+// summary and user facts. Re-running skips conversations that were already imported.
+func (e *Executor) ImportConversationsTool(ctx context.Context, argsJSON string) (string, error) {
+	trustLevel, ok := ctx.Value("user_trust").(string)
+	if !ok || trustLevel != "admin" {
+		return ErrJSON(fmt.Errorf("unauthorized: only admins can import conversations")), nil
+	}
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var args struct {
+		Path       string `json:"path"`
+		Source     string `json:"source"`
+		Distill    *bool  `json:"distill"`
+		MaxDistill int    `json:"max_distill"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if args.Path == "" {
+		return ErrJSON(fmt.Errorf("path is required")), nil
+	}
+	if args.Source == "auto" {
+		args.Source = ""
+	}
+	distill := args.Distill == nil || *args.Distill
+	if args.MaxDistill <= 0 {
+		args.MaxDistill = 50
+	}
+
+	path, err := workspacePath(e.WorkspaceDir, args.Path)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	convs, err := convimport.Load(path, args.Source)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+
+	var imported []convimport.Conversation
+	skipped, messages := 0, 0
+	for _, conv := range convs {
+		threadID := fmt.Sprintf("%s:%s:%s", importChannel, conv.Source, conv.ID)
+		exists, err := e.DB.ThreadExists(ctx, threadID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if exists {
+			skipped++
+			continue
+		}
+		for _, m := range conv.Messages {
+			sender := userID
+			if m.Role == "assistant" {
+				sender = conv.Source
+			}
+			createdAt := m.CreatedAt
+			if createdAt.IsZero() {
+				createdAt = conv.CreatedAt
+			}
+			if _, err := e.DB.InsertImportedMessage(ctx, m.Role, m.Content, sender, importChannel, threadID, createdAt); err != nil {
+				return ErrJSON(fmt.Errorf("importing %q: %w", conv.Title, err)), nil
+			}
+			messages++
+		}
+		imported = append(imported, conv)
+	}
+
+	result := map[string]interface{}{
+		"status":                 "imported",
+		"conversations_found":    len(convs),
+		"conversations_imported": len(imported),
+		"already_imported":       skipped,
+		"messages_imported":      messages,
+	}
+	if distill && len(imported) > 0 {
+		if e.Client == nil {
+			result["distill_error"] = "no LLM client configured; history imported without memories"
+		} else {
+			// Most recent conversations are the most relevant; distill those first.
+			toDistill := imported
+			if len(toDistill) > args.MaxDistill {
+				toDistill = toDistill[len(toDistill)-args.MaxDistill:]
+			}
+			memories, facts, failures := 0, 0, 0
+			for _, conv := range toDistill {
+				if ctx.Err() != nil {
+					break
+				}
+				m, f, err := e.distillConversation(ctx, userID, conv)
+				if err != nil {
+					failures++
+					continue
+				}
+				memories += m
+				facts += f
+			}
+			result["memories_created"] = memories
+			result["facts_saved"] = facts
+			result["distill_failures"] = failures
+			if len(imported) > len(toDistill) {
+				result["not_distilled"] = len(imported) - len(toDistill)
+			}
+		}
+	}
+	b, _ := json.Marshal(result)
+	return string(b), nil
+}
+
+// distillConversation asks the LLM for a memory summary and durable user facts. Existing facts
+// win over imported ones, since what the user told HattieBot directly is more current.
+func (e *Executor) distillConversation(ctx context.Context, userID string, conv convimport.Conversation) (memories, facts int, err error) {
+	reply, err := e.Client.ChatCompletion(ctx, []core.Message{
+		{Role: "system", Content: `You extract long-term memory from a past conversation between a user and another AI assistant.
+Reply with JSON only: {"summary": "2-4 sentences on what the user worked on, decided, or learned", "facts": [{"key": "snake_case_key", "value": "...", "category": "personal|work|preference|project"}]}.
+Only include facts about the user that stay true beyond this conversation (name, job, preferences, ongoing projects). Use "facts": [] when there are none.`},
+		{Role: "user", Content: conv.Transcript(distillTranscriptChars)},
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	var out struct {
+		Summary string `json:"summary"`
+		Facts   []struct {
+			Key      string `json:"key"`
+			Value    string `json:"value"`
+			Category string `json:"category"`
+		} `json:"facts"`
+	}
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return 0, 0, fmt.Errorf("distill: no JSON in reply")
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &out); err != nil {
+		return 0, 0, fmt.Errorf("distill: %w", err)
+	}
+
+	if summary := strings.TrimSpace(out.Summary); summary != "" {
+		if conv.Title != "" {
+			summary = fmt.Sprintf("%s (imported %s conversation %q, %s)", summary, conv.Source, conv.Title, conv.CreatedAt.Format("2006-01-02"))
+		}
+		emb, err := e.embed(ctx, summary, "document")
+		if err != nil {
+			return 0, 0, fmt.Errorf("embed failed: %w", err)
+		}
+		if err := e.DB.InsertChunk(ctx, summary, fmt.Sprintf("%s:%s:%s", importChannel, conv.Source, conv.ID), userID, e.embeddingModel(emb), emb); err != nil {
+			return 0, 0, err
+		}
+		memories++
+	}
+	for _, f := range out.Facts {
+		if f.Key == "" || f.Value == "" {
+			continue
+		}
+		if existing, err := e.DB.GetFact(ctx, userID, f.Key); err != nil || existing != nil {
+			continue
+		}
+		if f.Category == "" {
+			f.Category = "imported"
+		}
+		if err := e.DB.SetFact(ctx, userID, f.Key, f.Value, f.Category); err == nil {
+			facts++
+		}
+	}
+	return memories, facts, nil
+}
+
+// workspacePath resolves a workspace-relative path, rejecting paths that escape the workspace.
+func workspacePath(workspaceDir, path string) (string, error) {
+	base, err := filepath.Abs(workspaceDir)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(filepath.Join(workspaceDir, filepath.Clean(path)))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(base, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", os.ErrPermission
+	}
+	return abs, nil
+}
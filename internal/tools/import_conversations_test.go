@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+type distillClient struct{ calls int }
+
+func (c *distillClient) ChatCompletion(ctx context.Context, msgs []core.Message) (string, error) {
+	c.calls++
+	return "```json\n" + `{"summary": "User asked about Go generics.", "facts": [{"key": "language", "value": "Go", "category": "work"}, {"key": "name", "value": "Imported Name"}]}` + "\n```", nil
+}
+
+func (c *distillClient) ChatCompletionWithTools(ctx context.Context, msgs []core.Message, tools []core.ToolDefinition) (string, []core.ToolCall, error) {
+	return "", nil, nil
+}
+
+func (c *distillClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	return []float32{1, 0}, nil
+}
+
+func TestImportConversationsIsIdempotentAndDistills(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	dir := t.TempDir()
+	export := `[{"uuid": "u1", "name": "Go generics", "created_at": "2024-03-01T10:00:00Z", "chat_messages": [
+		{"sender": "human", "text": "How do constraints work?", "created_at": "2024-03-01T10:00:01Z"},
+		{"sender": "assistant", "text": "They are interfaces.", "created_at": "2024-03-01T10:00:05Z"}]}]`
+	if err := os.WriteFile(filepath.Join(dir, "conversations.json"), []byte(export), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetFact(ctx, "alice", "name", "Alice", "personal"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &distillClient{}
+	e := &Executor{DB: db, WorkspaceDir: dir, Client: client}
+	ctx = context.WithValue(ctx, "user_id", "alice")
+	ctx = context.WithValue(ctx, "user_trust", "admin")
+
+	out, err := e.Execute(ctx, "import_conversations", `{"path": "conversations.json"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("bad result %s: %v", out, err)
+	}
+	if res["messages_imported"] != float64(2) || res["memories_created"] != float64(1) || res["facts_saved"] != float64(1) {
+		t.Fatalf("unexpected result: %s", out)
+	}
+	msgs, _ := db.RecentMessages(ctx, 10, "import:claude:u1")
+	if len(msgs) != 2 || msgs[0].SenderID != "alice" || msgs[1].SenderID != "claude" || msgs[0].CreatedAt.Year() != 2024 {
+		t.Fatalf("unexpected history: %+v", msgs)
+	}
+	if f, _ := db.GetFact(ctx, "alice", "name"); f == nil || f.Value != "Alice" {
+		t.Errorf("existing fact was overwritten: %+v", f)
+	}
+
+	out, _ = e.Execute(ctx, "import_conversations", `{"path": "conversations.json"}`)
+	if err := json.Unmarshal([]byte(out), &res); err != nil || res["already_imported"] != float64(1) || res["messages_imported"] != float64(0) {
+		t.Fatalf("re-import not skipped: %s", out)
+	}
+	if client.calls != 1 {
+		t.Errorf("expected one distill call, got %d", client.calls)
+	}
+
+	ctx = context.WithValue(ctx, "user_trust", "trusted")
+	out, _ = e.Execute(ctx, "import_conversations", `{"path": "conversations.json"}`)
+	if err := json.Unmarshal([]byte(out), &res); err != nil || res["error"] == nil {
+		t.Errorf("non-admin import should fail: %s", out)
+	}
+}
@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// LinkIdentityTool links the caller's identities on different channels to one user: start gives
+// a one-time code on one channel, confirm with that code on another channel makes the sender
+// there act as the same user. list and unlink manage the links.
+func LinkIdentityTool(ctx context.Context, db *store.DB, argsJSON string) (string, error) {
+	var args struct {
+		Action     string `json:"action"`
+		Code       string `json:"code"`
+		Channel    string `json:"channel"`
+		ExternalID string `json:"external_id"`
+		UserID     string `json:"user_id"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "start":
+		code, expires, err := db.CreateIdentityLinkCode(ctx, userID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"status":     "code_created",
+			"code":       code,
+			"user_id":    userID,
+			"expires_at": expires.UTC().Format(time.RFC3339),
+			"note":       fmt.Sprintf("Tell the user to send this code from the other channel (e.g. \"link my account with code %s\") within %s. Messages from there will then act as %s, and that account's facts, memories and schedules move here. Never share the code with anyone else.", code, store.IdentityLinkCodeTTL, userID),
+		})
+		return string(b), nil
+
+	case "confirm":
+		if args.Code == "" {
+			return ErrJSON(fmt.Errorf("code is required for confirm")), nil
+		}
+		msg, ok := gateway.MessageFromContext(ctx)
+		if !ok || msg.Autonomous || msg.SenderID == "" {
+			return ErrJSON(fmt.Errorf("confirm must come from a message the user sent on the channel to link")), nil
+		}
+		if msg.Channel == "api" {
+			return ErrJSON(fmt.Errorf("API tokens already name their user; create a token for the user instead")), nil
+		}
+		id, err := db.LinkIdentity(ctx, args.Code, msg.Channel, msg.SenderID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"status":   "linked",
+			"identity": id,
+			"note":     fmt.Sprintf("From the next message on, %s on %s acts as %s.", id.ExternalID, id.Channel, id.UserID),
+		})
+		return string(b), nil
+
+	case "list", "":
+		target := userID
+		if args.UserID != "" && isAdminCtx(ctx) {
+			target = args.UserID
+		}
+		ids, err := db.ListIdentities(ctx, target)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if ids == nil {
+			ids = []store.Identity{}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"user_id": target, "identities": ids})
+		return string(b), nil
+
+	case "unlink":
+		if args.Channel == "" || args.ExternalID == "" {
+			return ErrJSON(fmt.Errorf("channel and external_id are required for unlink")), nil
+		}
+		owner, err := db.ResolveIdentity(ctx, args.Channel, args.ExternalID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if owner != userID && !isAdminCtx(ctx) {
+			return ErrJSON(fmt.Errorf("unauthorized: %s on %s is not linked to you", args.ExternalID, args.Channel)), nil
+		}
+		ok, err := db.UnlinkIdentity(ctx, args.Channel, args.ExternalID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if !ok {
+			return ErrJSON(fmt.Errorf("%s on %s is not linked", args.ExternalID, args.Channel)), nil
+		}
+		return fmt.Sprintf(`{"status": "unlinked", "channel": %q, "external_id": %q}`, args.Channel, args.ExternalID), nil
+
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s (use start, confirm, list, unlink)", args.Action)), nil
+	}
+}
+
+func isAdminCtx(ctx context.Context) bool {
+	trust, _ := ctx.Value("user_trust").(string)
+	role, _ := ctx.Value("user_role").(string)
+	return trust == "admin" || store.RoleAtLeast(role, store.RoleAdmin)
+}
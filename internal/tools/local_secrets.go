@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/redact"
+	"github.com/hattiebot/hattiebot/internal/secrets"
+)
+
+// secretBackend picks the store for get_secret/store_secret: the requested one, else the secret
+// store's default. It returns nil for Nextcloud Passwords (handled by the nextcloud package).
+func secretBackend(ms *secrets.MultiStore, requested string) (secrets.SecretWriter, error) {
+	source := requested
+	if source == "" && ms != nil {
+		source = ms.DefaultSource()
+	}
+	switch source {
+	case "", "passwords":
+		return nil, nil
+	case "local":
+		if ms != nil {
+			if w, ok := ms.Source("local").(secrets.SecretWriter); ok {
+				return w, nil
+			}
+		}
+		return nil, fmt.Errorf("local secret store not configured")
+	default:
+		return nil, fmt.Errorf("unknown secret store %q (use passwords or local)", source)
+	}
+}
+
+// GetLocalSecretTool finds a secret in the local store by name and returns a reference to it,
+// never the value. With no match it lists the available names.
+func GetLocalSecretTool(store secrets.SecretWriter, query string) (string, error) {
+	names, err := store.List()
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	match := ""
+	for _, name := range names {
+		if strings.EqualFold(name, query) {
+			match = name
+			break
+		}
+		if match == "" && strings.Contains(strings.ToLower(name), strings.ToLower(query)) {
+			match = name
+		}
+	}
+	if match == "" {
+		b, _ := json.Marshal(map[string]interface{}{"error": "secret not found: " + query, "available": names})
+		return string(b), nil
+	}
+	ref := fmt.Sprintf("{{secret:local:%s}}", match)
+	return fmt.Sprintf("Title: %s\nSecretRef: %s\n\nIMPORTANT: Do NOT use the SecretRef directly in commands.\nInstead, pass it in the 'env_vars' field of run_terminal_cmd.\nExample: {\"command\": \"echo $MY_SECRET\", \"env_vars\": {\"MY_SECRET\": \"%s\"}}", match, ref, ref), nil
+}
+
+// StoreLocalSecretTool saves a secret in the local encrypted store and returns its reference.
+func StoreLocalSecretTool(store secrets.SecretWriter, title, password string) (string, error) {
+	if title == "" || password == "" {
+		return ErrJSON(fmt.Errorf("title and password are required")), nil
+	}
+	if err := store.SetSecret(title, password); err != nil {
+		return ErrJSON(err), nil
+	}
+	redact.AddSecret(password)
+	b, _ := json.Marshal(map[string]string{
+		"status":     "stored",
+		"title":      title,
+		"secret_ref": fmt.Sprintf("{{secret:local:%s}}", title),
+	})
+	return string(b), nil
+}
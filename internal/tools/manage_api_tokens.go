@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// ManageAPITokensTool creates, lists, and revokes bearer tokens for the HTTP API (pkg/hattiebot SDK).
+func ManageAPITokensTool(ctx context.Context, db *store.DB, argsJSON string) (string, error) {
+	trustLevel, ok := ctx.Value("user_trust").(string)
+	if !ok || trustLevel != "admin" {
+		return ErrJSON(fmt.Errorf("unauthorized: only admins can manage API tokens")), nil
+	}
+	var args struct {
+		Action string   `json:"action"`
+		UserID string   `json:"user_id"`
+		Name   string   `json:"name"`
+		ID     int64    `json:"id"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "create":
+		if args.UserID == "" {
+			args.UserID, _ = ctx.Value("user_id").(string)
+		}
+		if args.UserID == "" {
+			return ErrJSON(fmt.Errorf("user_id is required")), nil
+		}
+		if _, err := db.GetUser(ctx, args.UserID); err == sql.ErrNoRows {
+			return ErrJSON(fmt.Errorf("unknown user: %s", args.UserID)), nil
+		} else if err != nil {
+			return ErrJSON(err), nil
+		}
+		if len(args.Scopes) == 0 {
+			args.Scopes = store.APIScopes
+		}
+		if err := store.ValidateAPIScopes(args.Scopes); err != nil {
+			return ErrJSON(err), nil
+		}
+		id, token, err := db.CreateAPIToken(ctx, args.UserID, args.Name, args.Scopes...)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"status":  "created",
+			"id":      id,
+			"user_id": args.UserID,
+			"scopes":  args.Scopes,
+			"token":   token,
+			"note":    "Give the token to the integrating service now; it cannot be shown again. Requests with it act as this user, limited to its scopes.",
+		})
+		return string(b), nil
+
+	case "revoke":
+		if args.ID == 0 {
+			return ErrJSON(fmt.Errorf("id is required for revoke (see action list)")), nil
+		}
+		if err := db.RevokeAPIToken(ctx, args.ID); err == sql.ErrNoRows {
+			return ErrJSON(fmt.Errorf("no API token with id %d", args.ID)), nil
+		} else if err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "revoked", "id": %d}`, args.ID), nil
+
+	case "list":
+		tokens, err := db.ListAPITokens(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if tokens == nil {
+			tokens = []store.APIToken{}
+		}
+		b, _ := json.Marshal(tokens)
+		return string(b), nil
+
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s (use create, list, revoke)", args.Action)), nil
+	}
+}
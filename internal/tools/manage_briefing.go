@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/briefing"
+	"github.com/hattiebot/hattiebot/internal/scheduler"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// defaultBriefingAt is the briefing time when the user configures one without a time.
+const defaultBriefingAt = "07:30"
+
+// ManageBriefingTool configures, previews and sends the caller's daily briefing. svc may be nil,
+// which leaves only get, configure, enable and disable.
+func ManageBriefingTool(ctx context.Context, db *store.DB, svc *briefing.Service, argsJSON string) (string, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var args struct {
+		Action       string          `json:"action"`
+		RunAt        string          `json:"run_at"`
+		Days         string          `json:"days"`
+		Timezone     string          `json:"timezone"`
+		Sections     []string        `json:"sections"`
+		WeatherTool  *string         `json:"weather_tool"`
+		WeatherArgs  json.RawMessage `json:"weather_args"`
+		Instructions *string         `json:"instructions"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	plan, err := briefing.FindPlan(ctx, db, userID)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var settings briefing.Settings
+	if plan != nil {
+		if settings, err = briefing.ParseSettings(plan.ActionPayload); err != nil {
+			return ErrJSON(err), nil
+		}
+	}
+
+	switch args.Action {
+	case "get", "":
+		out := map[string]interface{}{"configured": plan != nil, "sections": briefing.AllSections}
+		if plan != nil {
+			out["plan"] = plan
+			out["settings"] = settings
+			if runs, err := db.ListPlanRuns(ctx, plan.ID, 1); err == nil && len(runs) > 0 {
+				out["last_run"] = runs[0]
+			}
+		}
+		b, _ := json.Marshal(out)
+		return string(b), nil
+	case "configure", "enable":
+		// Fields not given keep their current values
+		days, runAt, tz := "daily", defaultBriefingAt, ""
+		if plan != nil {
+			days, runAt, tz = plan.ScheduleType, plan.ScheduleValue, plan.Timezone
+		}
+		if args.Days != "" {
+			days = args.Days
+		}
+		if args.RunAt != "" {
+			runAt = args.RunAt
+		}
+		if args.Timezone != "" {
+			tz = args.Timezone
+		}
+		if args.Sections != nil {
+			settings.Sections = args.Sections
+		}
+		if args.WeatherTool != nil {
+			settings.WeatherTool = *args.WeatherTool
+		}
+		if len(args.WeatherArgs) > 0 {
+			settings.WeatherArgs = args.WeatherArgs
+		}
+		if args.Instructions != nil {
+			settings.Instructions = *args.Instructions
+		}
+		if settings.WeatherTool != "" {
+			if tool, err := db.ToolByName(ctx, settings.WeatherTool); err != nil || tool == nil {
+				return ErrJSON(fmt.Errorf("weather_tool %q is not a registered tool", settings.WeatherTool)), nil
+			}
+		}
+		id, err := briefing.Schedule(ctx, db, userID, days, runAt, tz, settings)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		rule, _ := scheduler.ParseRule(days, runAt, tz)
+		b, _ := json.Marshal(map[string]interface{}{"status": "scheduled", "plan_id": id, "days": days, "run_at": runAt, "next_run": rule.Next(time.Now())})
+		return string(b), nil
+	case "disable":
+		if plan == nil {
+			return ErrJSON(fmt.Errorf("no briefing configured")), nil
+		}
+		if err := db.UpdatePlanStatus(ctx, plan.ID, "paused"); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "paused", "plan_id": %d}`, plan.ID), nil
+	case "preview", "send_now":
+		if svc == nil {
+			return ErrJSON(fmt.Errorf("briefings are not available")), nil
+		}
+		tz := args.Timezone
+		if tz == "" && plan != nil {
+			tz = plan.Timezone
+		}
+		loc, err := scheduler.LoadLocation(tz)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if args.Action == "preview" {
+			text, d := svc.Build(ctx, userID, settings, loc)
+			b, _ := json.Marshal(map[string]interface{}{"briefing": text, "summary": d.Summary()})
+			return string(b), nil
+		}
+		d, err := svc.Send(ctx, userID, settings, loc)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{"status": "sent", "summary": d.Summary()})
+		return string(b), nil
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s (use get, configure, enable, disable, preview, send_now)", args.Action)), nil
+	}
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/events"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// ManageEventSubscriptionsTool subscribes external endpoints to bot events (outbound webhooks),
+// lists and removes subscriptions, sends test pings and shows recent deliveries.
+func ManageEventSubscriptionsTool(ctx context.Context, db *store.DB, bus *events.Bus, argsJSON string) (string, error) {
+	var args struct {
+		Action      string   `json:"action"`
+		ID          int64    `json:"id"`
+		URL         string   `json:"url"`
+		Events      []string `json:"events"`
+		Secret      string   `json:"secret"`
+		Description string   `json:"description"`
+		Limit       int      `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "subscribe":
+		u, err := url.Parse(args.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return ErrJSON(fmt.Errorf("url must be an http(s) URL")), nil
+		}
+		for _, e := range args.Events {
+			if e != "*" && !validEventType(e) {
+				return ErrJSON(fmt.Errorf("unknown event type %q (use %s)", e, strings.Join(events.Types, ", "))), nil
+			}
+		}
+		secret, generated := args.Secret, false
+		if secret == "" {
+			secret, generated = events.NewSecret(), true
+		}
+		sub := store.EventSubscription{URL: args.URL, Secret: secret, Events: args.Events, Description: args.Description}
+		sub.CreatedBy, _ = ctx.Value("user_id").(string)
+		id, err := db.CreateEventSubscription(ctx, sub)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		resp := map[string]interface{}{
+			"status":           "subscribed",
+			"id":               id,
+			"signature_header": events.SignatureHeader,
+			"note":             "Each event is POSTed as JSON with " + events.SignatureHeader + ": sha256=<hex HMAC-SHA256 of the body with the secret>, and retried with backoff until the endpoint answers 2xx.",
+		}
+		if generated {
+			resp["secret"] = secret
+			resp["note"] = resp["note"].(string) + " Give the secret to the receiving service now; it cannot be shown again."
+		}
+		b, _ := json.Marshal(resp)
+		return string(b), nil
+
+	case "list", "":
+		subs, err := db.ListEventSubscriptions(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if subs == nil {
+			subs = []store.EventSubscription{}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"subscriptions": subs, "event_types": events.Types})
+		return string(b), nil
+
+	case "unsubscribe":
+		ok, err := db.DeleteEventSubscription(ctx, args.ID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if !ok {
+			return ErrJSON(fmt.Errorf("no event subscription with id %d", args.ID)), nil
+		}
+		return fmt.Sprintf(`{"status": "unsubscribed", "id": %d}`, args.ID), nil
+
+	case "test":
+		sub, err := db.GetEventSubscription(ctx, args.ID)
+		if err == sql.ErrNoRows {
+			return ErrJSON(fmt.Errorf("no event subscription with id %d", args.ID)), nil
+		} else if err != nil {
+			return ErrJSON(err), nil
+		}
+		if bus == nil {
+			return ErrJSON(fmt.Errorf("event delivery is not configured")), nil
+		}
+		if err := bus.Test(ctx, *sub); err != nil {
+			return ErrJSON(fmt.Errorf("test delivery failed: %w", err)), nil
+		}
+		return fmt.Sprintf(`{"status": "delivered", "id": %d, "event": %q}`, args.ID, events.Ping), nil
+
+	case "deliveries":
+		if args.Limit <= 0 || args.Limit > 100 {
+			args.Limit = 20
+		}
+		ds, err := db.ListEventDeliveries(ctx, args.ID, args.Limit)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if ds == nil {
+			ds = []store.EventDelivery{}
+		}
+		b, _ := json.Marshal(ds)
+		return string(b), nil
+
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s (use subscribe, list, unsubscribe, test, deliveries)", args.Action)), nil
+	}
+}
+
+func validEventType(t string) bool {
+	for _, e := range events.Types {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
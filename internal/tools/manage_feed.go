@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hattiebot/hattiebot/internal/feeds"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// maxFeedItemsListed caps the items action.
+const maxFeedItemsListed = 50
+
+// ManageFeedTool subscribes the caller to RSS/Atom feeds and manages the subscriptions. poller
+// may be nil, which leaves only list, update, pause, resume, unsubscribe and items.
+func ManageFeedTool(ctx context.Context, db *store.DB, poller *feeds.Poller, argsJSON string) (string, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var args struct {
+		Action       string    `json:"action"`
+		FeedID       int64     `json:"feed_id"`
+		URL          string    `json:"url"`
+		Title        *string   `json:"title"`
+		IntervalMin  int       `json:"interval_min"`
+		Include      *[]string `json:"include"`
+		Exclude      *[]string `json:"exclude"`
+		Instructions *string   `json:"instructions"`
+		Limit        int       `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "list", "":
+		list, err := db.ListFeeds(ctx, userID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if list == nil {
+			list = []store.Feed{}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"feeds": list})
+		return string(b), nil
+	case "subscribe":
+		if poller == nil {
+			return ErrJSON(fmt.Errorf("feed watcher not available")), nil
+		}
+		// Fetch once so a typo or a web page instead of a feed fails now, not in the background
+		doc, err := poller.Preview(ctx, args.URL)
+		if err != nil {
+			return ErrJSON(fmt.Errorf("cannot read %s: %w", args.URL, err)), nil
+		}
+		f := store.Feed{UserID: userID, URL: args.URL, Title: doc.Title, IntervalMin: args.IntervalMin}
+		if f.IntervalMin == 0 {
+			f.IntervalMin = feeds.DefaultIntervalMin
+		}
+		if args.Title != nil {
+			f.Title = *args.Title
+		}
+		if args.Include != nil {
+			f.Include = *args.Include
+		}
+		if args.Exclude != nil {
+			f.Exclude = *args.Exclude
+		}
+		if args.Instructions != nil {
+			f.Instructions = *args.Instructions
+		}
+		id, err := db.CreateFeed(ctx, f)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "subscribed", "feed_id": %d, "title": %q, "items_now": %d, "note": "items already in the feed are recorded on the first check; only later ones are reported"}`,
+			id, f.Title, len(doc.Items)), nil
+	}
+
+	f, err := db.GetFeed(ctx, args.FeedID)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	if f == nil || f.UserID != userID {
+		return ErrJSON(fmt.Errorf("feed %d not found", args.FeedID)), nil
+	}
+	switch args.Action {
+	case "update", "pause", "resume":
+		if args.Title != nil {
+			f.Title = *args.Title
+		}
+		if args.IntervalMin != 0 {
+			f.IntervalMin = args.IntervalMin
+		}
+		if args.Include != nil {
+			f.Include = *args.Include
+		}
+		if args.Exclude != nil {
+			f.Exclude = *args.Exclude
+		}
+		if args.Instructions != nil {
+			f.Instructions = *args.Instructions
+		}
+		switch args.Action {
+		case "pause":
+			f.Status = store.FeedPaused
+		case "resume":
+			f.Status = store.FeedActive
+		}
+		if err := db.UpdateFeed(ctx, f); err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{"status": "updated", "feed": f})
+		return string(b), nil
+	case "unsubscribe":
+		if err := db.DeleteFeed(ctx, f.ID); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "unsubscribed", "feed_id": %d}`, f.ID), nil
+	case "check_now":
+		if poller == nil {
+			return ErrJSON(fmt.Errorf("feed watcher not available")), nil
+		}
+		res, err := poller.Check(ctx, f)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(res)
+		return string(b), nil
+	case "items":
+		limit := args.Limit
+		if limit <= 0 || limit > maxFeedItemsListed {
+			limit = 20
+		}
+		items, err := db.FeedItems(ctx, f.ID, "", limit)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if items == nil {
+			items = []store.FeedItem{}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"feed_id": f.ID, "items": items})
+		return string(b), nil
+	}
+	return ErrJSON(fmt.Errorf("unknown action %q (use list, subscribe, update, pause, resume, unsubscribe, check_now or items)", args.Action)), nil
+}
@@ -13,13 +13,17 @@ import (
 // ManageLLMProviderTool handles provider template and routing management.
 func ManageLLMProviderTool(ctx context.Context, configDir string, argsJSON string) (string, error) {
 	var args struct {
-		Action       string                      `json:"action"` // list_templates, get_template, save_template, list_providers, register_provider, set_route
+		Action       string                      `json:"action"` // list_templates, get_template, save_template, list_providers, register_provider, set_route, set_fallbacks
 		TemplateName string                      `json:"template_name"`
 		TemplateBody llmrouter.ProviderTemplate  `json:"template_body"`
 		ProviderName string                      `json:"provider_name"`
 		Provider     store.LLMProviderEntry      `json:"provider_config"`
 		Route        string                      `json:"route"` // e.g. "default"
 		Model        string                      `json:"model"`
+		Fallbacks    []store.ModelRouteEntry     `json:"fallbacks"`
+		Reasoning    *string                     `json:"reasoning"`            // set_route; nil keeps the current setting
+		MaxReasoning *int                        `json:"max_reasoning_tokens"` // set_route; nil keeps the current setting
+		Breaker      *store.CircuitBreakerConfig `json:"circuit_breaker"`
 	}
 
 	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
@@ -118,15 +122,68 @@ func ManageLLMProviderTool(ctx context.Context, configDir string, argsJSON strin
         if _, ok := cfg.LLMProviders[args.ProviderName]; !ok {
              return fmt.Sprintf(`{"error": "provider '%s' not found"}`, args.ProviderName), nil
         }
-		cfg.ModelRouting[args.Route] = store.ModelRouteEntry{
-			Provider: args.ProviderName,
-			Model:    args.Model,
+		entry := cfg.ModelRouting[args.Route] // keeps the route's fallbacks
+		entry.Provider = args.ProviderName
+		entry.Model = args.Model
+		if args.Reasoning != nil {
+			entry.Reasoning = *args.Reasoning
+			if entry.Reasoning == "default" {
+				entry.Reasoning = ""
+			}
+		}
+		if args.MaxReasoning != nil {
+			entry.MaxReasoningTokens = *args.MaxReasoning
+		}
+		if err := entry.ValidateReasoning(); err != nil {
+			return ErrJSON(err), nil
 		}
+		cfg.ModelRouting[args.Route] = entry
 		if err := store.SaveLLMRouting(configDir, cfg); err != nil {
 			return ErrJSON(err), nil
 		}
 		return `{"status": "route_updated"}`, nil
 
+	case "set_fallbacks":
+		cfg, err := store.LoadLLMRouting(configDir)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if cfg == nil {
+			return `{"error": "no config found, register a provider first"}`, nil
+		}
+		if args.Route == "" {
+			args.Route = "default"
+		}
+		entry, ok := cfg.ModelRouting[args.Route]
+		if !ok {
+			return fmt.Sprintf(`{"error": "route '%s' not found, use set_route first"}`, args.Route), nil
+		}
+		for _, f := range args.Fallbacks {
+			if f.Provider == "" || f.Model == "" {
+				return `{"error": "each fallback needs provider and model"}`, nil
+			}
+			if _, ok := cfg.LLMProviders[f.Provider]; !ok {
+				return fmt.Sprintf(`{"error": "provider '%s' not found"}`, f.Provider), nil
+			}
+			if err := f.ValidateReasoning(); err != nil {
+				return ErrJSON(err), nil
+			}
+		}
+		entry.Fallbacks = args.Fallbacks
+		cfg.ModelRouting[args.Route] = entry
+		if args.Breaker != nil {
+			cfg.CircuitBreaker = args.Breaker
+		}
+		if err := store.SaveLLMRouting(configDir, cfg); err != nil {
+			return ErrJSON(err), nil
+		}
+		var chain []string
+		for _, e := range entry.Chain() {
+			chain = append(chain, e.Provider+":"+e.Model)
+		}
+		b, _ := json.Marshal(map[string]interface{}{"status": "fallbacks_updated", "chain": chain})
+		return string(b), nil
+
 	default:
 		return `{"error": "unknown action"}`, nil
 	}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/egress"
+)
+
+// ManageNetworkPolicyTool views and edits the outbound network policy for registered tools and
+// shows the destinations they contacted.
+func ManageNetworkPolicyTool(ctx context.Context, proxy *egress.Proxy, argsJSON string) (string, error) {
+	trustLevel, ok := ctx.Value("user_trust").(string)
+	if !ok || trustLevel != "admin" {
+		return ErrJSON(fmt.Errorf("unauthorized: only admins can manage the network policy")), nil
+	}
+	if proxy == nil {
+		return ErrJSON(fmt.Errorf("egress proxy is not running")), nil
+	}
+	var args struct {
+		Action  string   `json:"action"`
+		Mode    string   `json:"mode"`
+		Domains []string `json:"domains"`
+		Tool    string   `json:"tool"`
+		Limit   int      `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+
+	for i, d := range args.Domains {
+		args.Domains[i] = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d)), ".")
+	}
+
+	pol := proxy.Policy()
+	switch args.Action {
+	case "show", "":
+		b, _ := json.Marshal(map[string]interface{}{"policy": pol, "proxy": proxy.Addr()})
+		return string(b), nil
+	case "log":
+		events := proxy.Recent(args.Limit, args.Tool)
+		if events == nil {
+			events = []egress.Event{}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"events": events, "count": len(events)})
+		return string(b), nil
+	case "set_mode":
+		pol.Mode = args.Mode
+	case "allow", "deny", "remove":
+		if len(args.Domains) == 0 {
+			return ErrJSON(fmt.Errorf("domains is required for %s", args.Action)), nil
+		}
+		pol.Allow = without(pol.Allow, args.Domains)
+		pol.Deny = without(pol.Deny, args.Domains)
+		switch args.Action {
+		case "allow":
+			pol.Allow = append(pol.Allow, args.Domains...)
+		case "deny":
+			pol.Deny = append(pol.Deny, args.Domains...)
+		}
+	default:
+		return ErrJSON(fmt.Errorf("unknown action %q (use show, set_mode, allow, deny, remove, or log)", args.Action)), nil
+	}
+	if err := proxy.SetPolicy(pol); err != nil {
+		return ErrJSON(err), nil
+	}
+	b, _ := json.Marshal(map[string]interface{}{"status": "updated", "policy": proxy.Policy()})
+	return string(b), nil
+}
+
+// without returns list minus any entries in remove.
+func without(list, remove []string) []string {
+	drop := make(map[string]bool, len(remove))
+	for _, r := range remove {
+		drop[r] = true
+	}
+	var out []string
+	for _, e := range list {
+		if !drop[e] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
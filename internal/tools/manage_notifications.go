@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// ManageNotificationsTool reads and changes the caller's notification rules: a channel per
+// urgency, the urgency that breaks through quiet hours, and digest batching. Quiet hours
+// themselves live in the profile (manage_profile) and are shown and set here too.
+func ManageNotificationsTool(ctx context.Context, db *store.DB, gw *gateway.Gateway, argsJSON string) (string, error) {
+	var args struct {
+		Action      string            `json:"action"`
+		Channels    map[string]string `json:"channels"`
+		QuietBypass *string           `json:"quiet_bypass"`
+		QuietStart  *string           `json:"quiet_start"`
+		QuietEnd    *string           `json:"quiet_end"`
+		DigestBelow *string           `json:"digest_below"`
+		DigestHours *int              `json:"digest_hours"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	rules, err := db.GetNotificationRules(ctx, userID)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	profile, err := db.GetUserProfile(ctx, userID)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "get", "":
+		return notificationsJSON(ctx, db, "", rules, profile), nil
+
+	case "set":
+		if args.Channels != nil {
+			if rules.Channels == nil {
+				rules.Channels = map[string]string{}
+			}
+			for urgency, ch := range args.Channels {
+				if ch == "" {
+					delete(rules.Channels, urgency)
+					continue
+				}
+				if gw != nil {
+					if _, ok := gw.ChannelByName(ch); !ok {
+						names := gw.GetChannelNames()
+						sort.Strings(names)
+						return ErrJSON(fmt.Errorf("unknown channel %q (available: %s)", ch, strings.Join(names, ", "))), nil
+					}
+				}
+				rules.Channels[urgency] = ch
+			}
+		}
+		if args.QuietBypass != nil {
+			rules.QuietBypass = *args.QuietBypass
+		}
+		if args.DigestBelow != nil {
+			rules.DigestBelow = *args.DigestBelow
+		}
+		if args.DigestHours != nil {
+			rules.DigestHours = *args.DigestHours
+		}
+		if err := rules.Validate(); err != nil {
+			return ErrJSON(err), nil
+		}
+		if args.QuietStart != nil || args.QuietEnd != nil {
+			if args.QuietStart != nil {
+				profile.QuietStart = strings.TrimSpace(*args.QuietStart)
+			}
+			if args.QuietEnd != nil {
+				profile.QuietEnd = strings.TrimSpace(*args.QuietEnd)
+			}
+			if err := db.SetUserProfile(ctx, profile); err != nil {
+				return ErrJSON(err), nil
+			}
+		}
+		if err := db.SetNotificationRules(ctx, rules); err != nil {
+			return ErrJSON(err), nil
+		}
+		return notificationsJSON(ctx, db, "saved", rules, profile), nil
+
+	case "reset":
+		if err := db.SetNotificationRules(ctx, &store.NotificationRules{UserID: userID}); err != nil {
+			return ErrJSON(err), nil
+		}
+		rules, _ = db.GetNotificationRules(ctx, userID)
+		return notificationsJSON(ctx, db, "reset", rules, profile), nil
+
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s (use get, set, reset)", args.Action)), nil
+	}
+}
+
+// notificationsJSON reports the rules with the profile's quiet hours and the waiting digest.
+func notificationsJSON(ctx context.Context, db *store.DB, status string, rules *store.NotificationRules, profile *store.UserProfile) string {
+	out := map[string]interface{}{"rules": rules, "urgencies": store.NotificationUrgencies}
+	if status != "" {
+		out["status"] = status
+	}
+	if profile.QuietStart != "" {
+		out["quiet_hours"] = map[string]string{"start": profile.QuietStart, "end": profile.QuietEnd, "timezone": profile.Location().String()}
+	}
+	if items, err := db.DigestItems(ctx, rules.UserID); err == nil && len(items) > 0 {
+		out["digest_pending"] = len(items)
+	}
+	b, _ := json.Marshal(out)
+	return string(b)
+}
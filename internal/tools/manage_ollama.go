@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/ollama"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// ManageOllamaTool lists, inspects, pulls and deletes the models of an Ollama server. The server is
+// the named (or else the first) "ollama" provider in llm_routing.json, or Ollama's default address.
+func ManageOllamaTool(ctx context.Context, configDir string, argsJSON string) (string, error) {
+	var args struct {
+		Action   string `json:"action"` // list, show, pull, delete
+		Model    string `json:"model"`
+		Provider string `json:"provider"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	cfg, err := store.LoadLLMRouting(configDir)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	baseURL, err := ollamaBaseURL(cfg, args.Provider)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	c := ollama.NewClient(baseURL, "")
+	if args.Action != "list" && args.Model == "" {
+		return `{"error": "model required"}`, nil
+	}
+
+	switch args.Action {
+	case "list":
+		models, err := c.ListModels(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.MarshalIndent(map[string]interface{}{"base_url": c.BaseURL, "models": models}, "", "  ")
+		return string(b), nil
+
+	case "show":
+		info, err := c.ShowModel(ctx, args.Model)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.MarshalIndent(info, "", "  ")
+		return string(b), nil
+
+	case "pull":
+		if err := c.PullModel(ctx, args.Model); err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]string{"status": "pulled", "model": args.Model})
+		return string(b), nil
+
+	case "delete":
+		// Deleting a routed model would make those routes fail over on every turn
+		if routes := routesUsingOllamaModel(cfg, c.BaseURL, args.Model); len(routes) > 0 {
+			return ErrJSON(fmt.Errorf("%s is used by routes %s; change them with manage_llm_provider first", args.Model, strings.Join(routes, ", "))), nil
+		}
+		if err := c.DeleteModel(ctx, args.Model); err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]string{"status": "deleted", "model": args.Model})
+		return string(b), nil
+
+	default:
+		return `{"error": "action must be list, show, pull, or delete"}`, nil
+	}
+}
+
+// ollamaBaseURL resolves the server of the named ollama provider, or of the first one by name.
+func ollamaBaseURL(cfg *store.LLMRoutingConfig, provider string) (string, error) {
+	var providers map[string]store.LLMProviderEntry
+	if cfg != nil {
+		providers = cfg.LLMProviders
+	}
+	if provider != "" {
+		p, ok := providers[provider]
+		if !ok || p.Type != "ollama" {
+			return "", fmt.Errorf("no ollama provider named %q in llm_routing.json", provider)
+		}
+		return p.BaseURL, nil
+	}
+	names := make([]string, 0, len(providers))
+	for name, p := range providers {
+		if p.Type == "ollama" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ollama.DefaultBaseURL, nil
+	}
+	sort.Strings(names)
+	return providers[names[0]].BaseURL, nil
+}
+
+// routesUsingOllamaModel names the routes whose model or fallbacks run model on the server at
+// baseURL.
+func routesUsingOllamaModel(cfg *store.LLMRoutingConfig, baseURL, model string) []string {
+	if cfg == nil {
+		return nil
+	}
+	uses := func(e store.ModelRouteEntry) bool {
+		p, ok := cfg.LLMProviders[e.Provider]
+		return ok && p.Type == "ollama" && ollama.NewClient(p.BaseURL, "").BaseURL == baseURL &&
+			(e.Model == model || e.Model+":latest" == model || e.Model == model+":latest")
+	}
+	var routes []string
+	for name, r := range cfg.ModelRouting {
+		used := uses(r)
+		for _, fb := range r.Fallbacks {
+			used = used || uses(fb)
+		}
+		if used {
+			routes = append(routes, name)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestManageOllamaRefusesDeletingRoutedModel(t *testing.T) {
+	deletes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deletes++
+		}
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	cfg := &store.LLMRoutingConfig{
+		LLMProviders: map[string]store.LLMProviderEntry{"local": {Type: "ollama", BaseURL: srv.URL + "/"}},
+		ModelRouting: map[string]store.ModelRouteEntry{
+			"default": {Provider: "openrouter", Model: "x", Fallbacks: []store.ModelRouteEntry{{Provider: "local", Model: "llama3.1"}}},
+		},
+	}
+	if err := store.SaveLLMRouting(dir, cfg); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	out, _ := ManageOllamaTool(ctx, dir, `{"action":"delete","model":"llama3.1:latest"}`)
+	if !strings.Contains(out, "used by routes default") || deletes != 0 {
+		t.Fatalf("deleting a fallback model: %s (%d deletes)", out, deletes)
+	}
+	out, _ = ManageOllamaTool(ctx, dir, `{"action":"delete","model":"qwen2.5"}`)
+	if !strings.Contains(out, `"deleted"`) || deletes != 1 {
+		t.Fatalf("deleting an unused model: %s (%d deletes)", out, deletes)
+	}
+	if out, _ = ManageOllamaTool(ctx, dir, `{"action":"pull","provider":"missing","model":"a"}`); !strings.Contains(out, "no ollama provider") {
+		t.Errorf("unknown provider: %s", out)
+	}
+}
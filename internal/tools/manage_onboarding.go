@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/onboarding"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// ManageOnboardingTool shows and updates the setup checklist.
+func ManageOnboardingTool(ctx context.Context, db *store.DB, cfg *config.Config, gw *gateway.Gateway, argsJSON string) (string, error) {
+	var args struct {
+		Action string `json:"action"`
+		Item   string `json:"item"`
+		Note   string `json:"note"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+
+	var err error
+	switch args.Action {
+	case "status", "":
+	case "complete":
+		err = db.CompleteOnboardingItem(ctx, args.Item, args.Note)
+	case "dismiss":
+		err = db.SetOnboardingItemDismissed(ctx, args.Item, true)
+	case "restore":
+		err = db.SetOnboardingItemDismissed(ctx, args.Item, false)
+	default:
+		err = fmt.Errorf("unknown action: %s (use status, complete, dismiss, restore)", args.Action)
+	}
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+
+	var channels []string
+	if gw != nil {
+		channels = gw.GetChannelNames()
+	}
+	items, err := onboarding.Refresh(ctx, db, cfg, channels)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	b, _ := json.Marshal(onboarding.Summarize(items))
+	return string(b), nil
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// grantablePolicies are the policies that may be granted as a whole; admin_only and operator tools
+// must be granted one by one, and owner_only tools cannot be granted.
+var grantablePolicies = map[string]bool{"restricted": true}
+
+// ManagePermissionsTool grants, revokes, and lists per-user or per-trust-level tool permissions.
+func ManagePermissionsTool(ctx context.Context, db *store.DB, workspaceDir, argsJSON string) (string, error) {
+	trustLevel, ok := ctx.Value("user_trust").(string)
+	if !ok || trustLevel != "admin" {
+		return ErrJSON(fmt.Errorf("unauthorized: only admins can manage permissions")), nil
+	}
+	var args struct {
+		Action      string `json:"action"`
+		ID          int64  `json:"id"`
+		SubjectType string `json:"subject_type"`
+		Subject     string `json:"subject"`
+		Tool        string `json:"tool"`
+		Policy      string `json:"policy"`
+		WorkDir     string `json:"work_dir"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "grant":
+		if args.SubjectType == "" {
+			args.SubjectType = store.PermissionSubjectUser
+		}
+		if args.Tool != "" {
+			def, ok := builtinToolPolicy(args.Tool)
+			if !ok {
+				return ErrJSON(fmt.Errorf("unknown tool: %s", args.Tool)), nil
+			}
+			if def == "owner_only" {
+				return ErrJSON(fmt.Errorf("%s is owner only and cannot be granted", args.Tool)), nil
+			}
+		}
+		if args.Policy != "" && !grantablePolicies[args.Policy] {
+			return ErrJSON(fmt.Errorf("policy %q cannot be granted as a whole; grant individual tools instead", args.Policy)), nil
+		}
+		if args.WorkDir != "" {
+			dir, err := workspacePath(workspaceDir, args.WorkDir)
+			if err != nil {
+				return ErrJSON(fmt.Errorf("work_dir must be inside the workspace: %w", err)), nil
+			}
+			args.WorkDir = dir
+		}
+		grantedBy, _ := ctx.Value("user_id").(string)
+		id, err := db.GrantToolPermission(ctx, store.ToolPermission{
+			SubjectType: args.SubjectType,
+			Subject:     args.Subject,
+			Tool:        args.Tool,
+			Policy:      args.Policy,
+			WorkDir:     args.WorkDir,
+			GrantedBy:   grantedBy,
+		})
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{"status": "granted", "id": id, "work_dir": args.WorkDir})
+		return string(b), nil
+
+	case "revoke":
+		if args.ID == 0 {
+			return ErrJSON(fmt.Errorf("id is required for revoke (see action list)")), nil
+		}
+		if err := db.RevokeToolPermission(ctx, args.ID); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "revoked", "id": %d}`, args.ID), nil
+
+	case "list":
+		perms, err := db.ListToolPermissions(ctx, args.SubjectType, args.Subject)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		if perms == nil {
+			perms = []store.ToolPermission{}
+		}
+		b, _ := json.Marshal(perms)
+		return string(b), nil
+
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s (use grant, revoke, list)", args.Action)), nil
+	}
+}
+
+// builtinToolPolicy returns the policy of a built-in tool and whether the tool exists.
+func builtinToolPolicy(name string) (string, bool) {
+	for _, d := range BuiltinToolDefs() {
+		if d.Function.Name == name {
+			return d.Policy, true
+		}
+	}
+	return "", false
+}
+
+// canScheduleTool reports whether the caller may schedule an execute_tool plan for tool: the
+// scheduler runs it as the caller, so a role-gated tool needs the role it would need now, or a
+// grant. Calls without a role in context (system/internal) are not gated, as in PolicyMiddleware.
+func (e *Executor) canScheduleTool(ctx context.Context, userID, tool string) (bool, error) {
+	role, ok := ctx.Value("user_role").(string)
+	if !ok {
+		return true, nil
+	}
+	policy, _ := builtinToolPolicy(tool)
+	min, gated := store.PolicyMinRole[policy]
+	if !gated || store.RoleAtLeast(role, min) {
+		return true, nil
+	}
+	if policy == "owner_only" || e.DB == nil {
+		return false, nil
+	}
+	grantPolicy := ""
+	if policy == "restricted" {
+		grantPolicy = policy
+	}
+	trust, _ := ctx.Value("user_trust").(string)
+	grant, err := e.DB.FindToolPermission(ctx, userID, trust, tool, grantPolicy)
+	if err != nil {
+		return false, err
+	}
+	return grant != nil, nil
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hattiebot/hattiebot/internal/plugins"
+	"github.com/hattiebot/hattiebot/internal/tools/builtin"
+)
+
+// InitPlugins creates the loader for $CONFIG_DIR/plugins. Plugins may not take the name of a
+// built-in tool or a registered tool.
+func InitPlugins(configDir string, registered func(ctx context.Context, name string) bool) *plugins.Loader {
+	return &plugins.Loader{
+		Dir: filepath.Join(configDir, "plugins"),
+		Reserved: func(name string) bool {
+			if t, ok := builtin.Lookup(name); ok {
+				return !plugins.IsPlugin(t)
+			}
+			for _, d := range BuiltinToolDefs() {
+				if d.Function.Name == name {
+					return true
+				}
+			}
+			return registered != nil && registered(context.Background(), name)
+		},
+	}
+}
+
+// ManagePluginTool lists, builds and reloads in-process plugin tools.
+func ManagePluginTool(ctx context.Context, workspaceDir string, loader *plugins.Loader, argsJSON string) (string, error) {
+	if loader == nil {
+		return `{"error": "plugins are not available"}`, nil
+	}
+	var args struct {
+		Action    string `json:"action"`
+		SourceDir string `json:"source_dir"`
+		Name      string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	out := func(v interface{}) (string, error) {
+		b, _ := json.MarshalIndent(v, "", "  ")
+		return string(b), nil
+	}
+	load := func(extra map[string]interface{}) (string, error) {
+		loaded, errs := loader.Load()
+		res := map[string]interface{}{"plugins": loaded}
+		for k, v := range extra {
+			res[k] = v
+		}
+		if len(errs) > 0 {
+			msgs := make([]string, len(errs))
+			for i, err := range errs {
+				msgs[i] = err.Error()
+			}
+			res["errors"] = msgs
+		}
+		return out(res)
+	}
+
+	switch args.Action {
+	case "list", "":
+		return out(map[string]interface{}{"dir": loader.Dir, "plugins": loader.Plugins()})
+	case "reload":
+		return load(nil)
+	case "build":
+		if args.SourceDir == "" || args.Name == "" {
+			return ErrJSON(fmt.Errorf("source_dir and name are required")), nil
+		}
+		src := filepath.Join(workspaceDir, filepath.Clean(args.SourceDir))
+		output, err := loader.Build(ctx, src, args.Name)
+		if err != nil {
+			return out(map[string]interface{}{"error": err.Error(), "output": output})
+		}
+		return load(map[string]interface{}{"status": "built", "file": args.Name + ".so"})
+	}
+	return ErrJSON(fmt.Errorf("unknown action %q (use list, build or reload)", args.Action)), nil
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// ManageProfileTool reads and changes the caller's typed preferences: language, time zone,
+// verbosity, formality and quiet hours. set changes only the fields given; unset clears fields.
+func ManageProfileTool(ctx context.Context, db *store.DB, argsJSON string) (string, error) {
+	var args struct {
+		Action     string   `json:"action"`
+		Language   *string  `json:"language"`
+		Timezone   *string  `json:"timezone"`
+		Verbosity  *string  `json:"verbosity"`
+		Formality  *string  `json:"formality"`
+		QuietStart *string  `json:"quiet_start"`
+		QuietEnd   *string  `json:"quiet_end"`
+		Fields     []string `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	p, err := db.GetUserProfile(ctx, userID)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	fields := map[string]*string{
+		"language":    &p.Language,
+		"timezone":    &p.Timezone,
+		"verbosity":   &p.Verbosity,
+		"formality":   &p.Formality,
+		"quiet_start": &p.QuietStart,
+		"quiet_end":   &p.QuietEnd,
+	}
+
+	switch args.Action {
+	case "get", "":
+		b, _ := json.Marshal(p)
+		return string(b), nil
+
+	case "set":
+		given := map[string]*string{
+			"language":    args.Language,
+			"timezone":    args.Timezone,
+			"verbosity":   args.Verbosity,
+			"formality":   args.Formality,
+			"quiet_start": args.QuietStart,
+			"quiet_end":   args.QuietEnd,
+		}
+		changed := false
+		for name, v := range given {
+			if v != nil {
+				*fields[name] = strings.TrimSpace(*v)
+				changed = true
+			}
+		}
+		if !changed {
+			return ErrJSON(fmt.Errorf("set needs at least one of language, timezone, verbosity, formality, quiet_start, quiet_end")), nil
+		}
+
+	case "unset":
+		if len(args.Fields) == 0 {
+			return ErrJSON(fmt.Errorf("fields is required for unset")), nil
+		}
+		for _, name := range args.Fields {
+			if name == "quiet_hours" {
+				p.QuietStart, p.QuietEnd = "", ""
+				continue
+			}
+			f, ok := fields[name]
+			if !ok {
+				return ErrJSON(fmt.Errorf("unknown field: %s", name)), nil
+			}
+			*f = ""
+		}
+
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s (use get, set, unset)", args.Action)), nil
+	}
+
+	if err := db.SetUserProfile(ctx, p); err != nil {
+		return ErrJSON(err), nil
+	}
+	b, _ := json.Marshal(map[string]interface{}{"status": "saved", "profile": p})
+	return string(b), nil
+}
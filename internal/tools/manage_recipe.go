@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hattiebot/hattiebot/internal/recipes"
+)
+
+// recipeInstaller builds the recipe installer from the executor's stores.
+func (e *Executor) recipeInstaller() *recipes.Installer {
+	return &recipes.Installer{
+		ConfigDir:            e.ConfigDir,
+		WorkspaceDir:         e.WorkspaceDir,
+		DB:                   e.DB,
+		Subminds:             e.SubmindRegistry,
+		Secrets:              e.SecretStore,
+		BlockedTools:         BlockedTools,
+		ValidateAllowedTools: ValidateAllowedTools,
+		ValidateTool: func(ctx context.Context, binaryPath string) error {
+			if _, err := e.checkToolSource(ctx, filepath.Base(binaryPath), binaryPath, ""); err != nil {
+				return err
+			}
+			stdout, _, code, err := ExecuteRegisteredTool(ctx, binaryPath, "{}", withEgress(e.Egress, filepath.Base(binaryPath), nil))
+			if err != nil {
+				return fmt.Errorf("tool contract test failed: %w", err)
+			}
+			if !ValidateToolOutput(stdout, code) {
+				return fmt.Errorf("tool failed contract test: output was not valid JSON (exit_code=%d)", code)
+			}
+			return nil
+		},
+	}
+}
+
+// ManageRecipeTool installs, lists, shows, validates, and removes integration recipes. The recipe
+// document comes inline (recipe) or from a workspace file (path), as YAML or JSON.
+func (e *Executor) ManageRecipeTool(ctx context.Context, argsJSON string) (string, error) {
+	var args struct {
+		Action string `json:"action"`
+		Name   string `json:"name"`
+		Recipe string `json:"recipe"`
+		Path   string `json:"path"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if e.ConfigDir == "" {
+		return ErrJSON(fmt.Errorf("config dir not configured")), nil
+	}
+	in := e.recipeInstaller()
+
+	source := func() (string, error) {
+		if args.Recipe != "" {
+			return args.Recipe, nil
+		}
+		if args.Path == "" {
+			return "", fmt.Errorf("recipe or path is required")
+		}
+		return ReadFile(ctx, e.WorkspaceDir, args.Path)
+	}
+
+	switch args.Action {
+	case "install":
+		src, err := source()
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		userID, _ := ctx.Value("user_id").(string)
+		rec, err := in.Install(ctx, userID, src)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		rec.Source = "" // the caller already has it
+		b, _ := json.Marshal(map[string]interface{}{"status": "installed", "recipe": rec})
+		return string(b), nil
+	case "validate":
+		src, err := source()
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		r, err := recipes.Parse(src)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{"status": "valid", "recipe": r})
+		return string(b), nil
+	case "list":
+		list, err := in.List()
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		for i := range list {
+			list[i].Source = ""
+		}
+		b, _ := json.Marshal(map[string]interface{}{"recipes": list})
+		return string(b), nil
+	case "show":
+		list, err := in.List()
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		for _, rec := range list {
+			if rec.Name == args.Name {
+				b, _ := json.Marshal(rec)
+				return string(b), nil
+			}
+		}
+		return ErrJSON(fmt.Errorf("recipe %s is not installed", args.Name)), nil
+	case "remove":
+		if args.Name == "" {
+			return ErrJSON(fmt.Errorf("name is required")), nil
+		}
+		if err := in.Remove(ctx, args.Name); err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "removed", "name": %q}`, args.Name), nil
+	default:
+		return ErrJSON(fmt.Errorf("action must be install, validate, list, show, or remove")), nil
+	}
+}
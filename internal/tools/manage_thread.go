@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// ManageThreadTool reads and changes per-thread settings: a system prompt addendum, the tools the
+// thread may use, reply verbosity, and which group messages it answers. The thread defaults to the current conversation. set changes
+// only the fields given; unset clears fields, and clear removes every setting.
+func ManageThreadTool(ctx context.Context, db *store.DB, argsJSON string) (string, error) {
+	var args struct {
+		Action         string    `json:"action"`
+		Channel        string    `json:"channel"`
+		ThreadID       string    `json:"thread_id"`
+		PromptAddendum *string   `json:"prompt_addendum"`
+		AllowedTools   *[]string `json:"allowed_tools"`
+		Verbosity      *string   `json:"verbosity"`
+		Addressing     *string   `json:"addressing"`
+		Fields         []string  `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if args.Action == "list" {
+		all, err := db.ListThreadSettings(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{"threads": all})
+		return string(b), nil
+	}
+	if args.ThreadID == "" {
+		msg, ok := gateway.MessageFromContext(ctx)
+		if !ok || msg.ThreadID == "" {
+			return ErrJSON(fmt.Errorf("channel and thread_id are required outside a conversation")), nil
+		}
+		args.Channel, args.ThreadID = msg.Channel, msg.ThreadID
+	} else if args.Channel == "" {
+		return ErrJSON(fmt.Errorf("channel is required with thread_id")), nil
+	}
+	s, err := db.GetThreadSettings(ctx, args.Channel, args.ThreadID)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+
+	switch args.Action {
+	case "get", "":
+		b, _ := json.Marshal(s)
+		return string(b), nil
+
+	case "set":
+		if args.PromptAddendum == nil && args.AllowedTools == nil && args.Verbosity == nil && args.Addressing == nil {
+			return ErrJSON(fmt.Errorf("set needs at least one of prompt_addendum, allowed_tools, verbosity, addressing")), nil
+		}
+		if args.PromptAddendum != nil {
+			s.PromptAddendum = strings.TrimSpace(*args.PromptAddendum)
+		}
+		if args.Verbosity != nil {
+			s.Verbosity = strings.TrimSpace(*args.Verbosity)
+		}
+		if args.Addressing != nil {
+			s.Addressing = strings.TrimSpace(*args.Addressing)
+		}
+		if args.AllowedTools != nil {
+			if err := ValidateAllowedTools(*args.AllowedTools); err != nil {
+				return ErrJSON(err), nil
+			}
+			s.AllowedTools = *args.AllowedTools
+		}
+
+	case "unset":
+		if len(args.Fields) == 0 {
+			return ErrJSON(fmt.Errorf("fields is required for unset")), nil
+		}
+		for _, name := range args.Fields {
+			switch name {
+			case "prompt_addendum":
+				s.PromptAddendum = ""
+			case "allowed_tools":
+				s.AllowedTools = nil
+			case "verbosity":
+				s.Verbosity = ""
+			case "addressing":
+				s.Addressing = ""
+			default:
+				return ErrJSON(fmt.Errorf("unknown field: %s", name)), nil
+			}
+		}
+
+	case "clear":
+		s = &store.ThreadSettings{Channel: s.Channel, ThreadID: s.ThreadID}
+
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s (use get, set, unset, clear, list)", args.Action)), nil
+	}
+
+	s.UpdatedBy, _ = getUserID(ctx)
+	if err := db.SetThreadSettings(ctx, s); err != nil {
+		return ErrJSON(err), nil
+	}
+	b, _ := json.Marshal(map[string]interface{}{"status": "saved", "settings": s})
+	return string(b), nil
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestManageThreadTool(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx = context.WithValue(ctx, "user_id", "admin")
+	room := gateway.WithMessage(ctx, gateway.Message{Channel: "talk", ThreadID: "family"})
+
+	if out, _ := ManageThreadTool(ctx, db, `{"action":"get"}`); !strings.Contains(out, "required outside a conversation") {
+		t.Errorf("get without a thread: %s", out)
+	}
+	if out, _ := ManageThreadTool(room, db, `{"action":"set","allowed_tools":["bad["]}`); !strings.Contains(out, "invalid tool pattern") {
+		t.Errorf("bad pattern: %s", out)
+	}
+	if out, _ := ManageThreadTool(room, db, `{"action":"set","prompt_addendum":"Family chat: keep it light.","verbosity":"brief","addressing":"mention"}`); !strings.Contains(out, `"saved"`) {
+		t.Fatalf("set: %s", out)
+	}
+	if out, _ := ManageThreadTool(room, db, `{"action":"set","addressing":"never"}`); !strings.Contains(out, "addressing must be one of") {
+		t.Errorf("bad addressing: %s", out)
+	}
+	if out, _ := ManageThreadTool(room, db, `{"action":"unset","fields":["verbosity"]}`); !strings.Contains(out, `"saved"`) {
+		t.Fatalf("unset: %s", out)
+	}
+	s, _ := db.GetThreadSettings(ctx, "talk", "family")
+	if s.PromptAddendum != "Family chat: keep it light." || s.Verbosity != "" || s.Addressing != "mention" || s.UpdatedBy != "admin" {
+		t.Errorf("settings = %+v", s)
+	}
+
+	// Another thread by ID, then list both
+	if out, _ := ManageThreadTool(room, db, `{"action":"set","channel":"talk","thread_id":"ops","allowed_tools":["read_logs","system_status"]}`); !strings.Contains(out, `"saved"`) {
+		t.Fatalf("set by id: %s", out)
+	}
+	out, _ := ManageThreadTool(ctx, db, `{"action":"list"}`)
+	var listed struct {
+		Threads []store.ThreadSettings `json:"threads"`
+	}
+	if err := json.Unmarshal([]byte(out), &listed); err != nil || len(listed.Threads) != 2 {
+		t.Fatalf("list: %s", out)
+	}
+	ManageThreadTool(room, db, `{"action":"clear"}`)
+	if s, _ := db.GetThreadSettings(ctx, "talk", "family"); !s.IsEmpty() {
+		t.Errorf("after clear: %+v", s)
+	}
+}
@@ -0,0 +1,298 @@
+package nextcloud
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+)
+
+// BusyPeriod is a calendar event that blocks time.
+type BusyPeriod struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Summary string    `json:"summary,omitempty"`
+}
+
+// UserBusyPeriods returns events between from and to in calendars owned by userID that are
+// shared with the Hattie user (CalDAV). Calendars of other owners are ignored.
+func UserBusyPeriods(cfg *config.Config, userID string, from, to time.Time) ([]BusyPeriod, error) {
+	if cfg.NextcloudURL == "" || cfg.NextcloudBotUser == "" || cfg.NextcloudBotAppPassword == "" {
+		return nil, fmt.Errorf("nextcloud credentials not configured")
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	calendars, err := listCalendars(client, cfg, userID)
+	if err != nil {
+		return nil, err
+	}
+	var busy []BusyPeriod
+	for _, href := range calendars {
+		events, err := queryEvents(client, cfg, href, from, to)
+		if err != nil {
+			return nil, err
+		}
+		busy = append(busy, events...)
+	}
+	sort.Slice(busy, func(i, j int) bool { return busy[i].Start.Before(busy[j].Start) })
+	return busy, nil
+}
+
+// NextFree returns the first time at or after t that is not inside a busy period (chaining
+// back-to-back events), and the period that blocked t, if any. busy must be sorted by Start.
+func NextFree(busy []BusyPeriod, t time.Time) (time.Time, *BusyPeriod) {
+	var blocking *BusyPeriod
+	for moved := true; moved; {
+		moved = false
+		for i := range busy {
+			b := busy[i]
+			if !t.Before(b.Start) && t.Before(b.End) {
+				if blocking == nil {
+					blocking = &busy[i]
+				}
+				t = b.End
+				moved = true
+			}
+		}
+	}
+	return t, blocking
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+				} `xml:"resourcetype"`
+				Owner struct {
+					Href string `xml:"href"`
+				} `xml:"DAV: owner"`
+				OwnerPrincipal string `xml:"http://owncloud.org/ns owner-principal"` // set on calendars shared with the Hattie user
+				CalendarData   string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func davRequest(client *http.Client, cfg *config.Config, method, url, depth, body string) (*davMultistatus, error) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(cfg.NextcloudBotUser, cfg.NextcloudBotAppPassword)
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("CalDAV %s error %d: %s", method, resp.StatusCode, string(data))
+	}
+	var ms davMultistatus
+	if err := xml.Unmarshal(data, &ms); err != nil {
+		return nil, fmt.Errorf("CalDAV %s: parsing response: %w", method, err)
+	}
+	return &ms, nil
+}
+
+// listCalendars returns hrefs of the Hattie user's calendars whose owner is userID.
+func listCalendars(client *http.Client, cfg *config.Config, userID string) ([]string, error) {
+	baseURL := strings.TrimRight(cfg.NextcloudURL, "/")
+	url := fmt.Sprintf("%s/remote.php/dav/calendars/%s/", baseURL, cfg.NextcloudBotUser)
+	ms, err := davRequest(client, cfg, "PROPFIND", url, "1", `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns"><d:prop><d:resourcetype/><d:owner/><oc:owner-principal/></d:prop></d:propfind>`)
+	if err != nil {
+		return nil, err
+	}
+	var hrefs []string
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if ps.Prop.ResourceType.Calendar == nil {
+				continue
+			}
+			if ownedBy(ps.Prop.OwnerPrincipal, userID) || ownedBy(ps.Prop.Owner.Href, userID) {
+				hrefs = append(hrefs, r.Href)
+			}
+		}
+	}
+	return hrefs, nil
+}
+
+// ownedBy reports whether a principal (e.g. "principals/users/alice" or "/remote.php/dav/principals/users/alice/") is userID.
+func ownedBy(principal, userID string) bool {
+	return userID != "" && strings.HasSuffix(strings.TrimSuffix(principal, "/"), "principals/users/"+userID)
+}
+
+// queryEvents runs a calendar-query REPORT; the server expands recurring events into the range.
+func queryEvents(client *http.Client, cfg *config.Config, href string, from, to time.Time) ([]BusyPeriod, error) {
+	baseURL := strings.TrimRight(cfg.NextcloudURL, "/")
+	start, end := from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z")
+	body := fmt.Sprintf(`<?xml version="1.0"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+<d:prop><c:calendar-data><c:expand start="%s" end="%s"/></c:calendar-data></d:prop>
+<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT"><c:time-range start="%s" end="%s"/></c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`, start, end, start, end)
+	ms, err := davRequest(client, cfg, "REPORT", baseURL+href, "1", body)
+	if err != nil {
+		return nil, err
+	}
+	var busy []BusyPeriod
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if ps.Prop.CalendarData != "" {
+				busy = append(busy, parseICSBusy(ps.Prop.CalendarData)...)
+			}
+		}
+	}
+	return busy, nil
+}
+
+// parseICSBusy extracts opaque, non-cancelled VEVENTs from iCalendar text.
+func parseICSBusy(ics string) []BusyPeriod {
+	var out []BusyPeriod
+	var ev map[string]icsProp
+	nested := 0 // depth of sub-components (e.g. VALARM) inside the current VEVENT
+	for _, line := range unfoldICS(ics) {
+		name, prop := parseICSLine(line)
+		switch {
+		case name == "BEGIN" && prop.Value == "VEVENT":
+			ev, nested = map[string]icsProp{}, 0
+		case name == "END" && prop.Value == "VEVENT" && ev != nil:
+			if b, ok := eventBusy(ev); ok {
+				out = append(out, b)
+			}
+			ev = nil
+		case ev == nil:
+		case name == "BEGIN":
+			nested++
+		case name == "END":
+			nested--
+		case nested == 0:
+			ev[name] = prop
+		}
+	}
+	return out
+}
+
+type icsProp struct {
+	Params map[string]string
+	Value  string
+}
+
+func eventBusy(ev map[string]icsProp) (BusyPeriod, bool) {
+	if ev["TRANSP"].Value == "TRANSPARENT" || ev["STATUS"].Value == "CANCELLED" {
+		return BusyPeriod{}, false
+	}
+	start, allDay, err := parseICSTime(ev["DTSTART"])
+	if err != nil {
+		return BusyPeriod{}, false
+	}
+	var end time.Time
+	if p, ok := ev["DTEND"]; ok {
+		if end, _, err = parseICSTime(p); err != nil {
+			return BusyPeriod{}, false
+		}
+	} else if d, ok := ev["DURATION"]; ok {
+		end = start.Add(parseICSDuration(d.Value))
+	} else if allDay {
+		end = start.AddDate(0, 0, 1)
+	} else {
+		end = start
+	}
+	return BusyPeriod{Start: start, End: end, Summary: ev["SUMMARY"].Value}, end.After(start)
+}
+
+func unfoldICS(ics string) []string {
+	var lines []string
+	sc := bufio.NewScanner(strings.NewReader(ics))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		l := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// parseICSLine splits "NAME;PARAM=V:value" into its name and property.
+func parseICSLine(line string) (string, icsProp) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return strings.ToUpper(line), icsProp{}
+	}
+	head, value := line[:colon], line[colon+1:]
+	parts := strings.Split(head, ";")
+	prop := icsProp{Params: map[string]string{}, Value: value}
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			prop.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), prop
+}
+
+// parseICSTime handles UTC ("...Z"), TZID-local, floating, and all-day (VALUE=DATE) times.
+func parseICSTime(p icsProp) (t time.Time, allDay bool, err error) {
+	v := p.Value
+	loc := time.Local
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if l, lerr := time.LoadLocation(tzid); lerr == nil {
+			loc = l
+		}
+	}
+	switch {
+	case p.Params["VALUE"] == "DATE" || len(v) == 8:
+		t, err = time.ParseInLocation("20060102", v, loc)
+		return t, true, err
+	case strings.HasSuffix(v, "Z"):
+		t, err = time.Parse("20060102T150405Z", v)
+	default:
+		t, err = time.ParseInLocation("20060102T150405", v, loc)
+	}
+	return t, false, err
+}
+
+// parseICSDuration parses RFC 5545 durations like "PT1H30M" or "P1D".
+func parseICSDuration(s string) time.Duration {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "+"), "P")
+	var d time.Duration
+	inTime := false
+	num := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num = num*10 + int(r-'0')
+		case r == 'T':
+			inTime = true
+		case r == 'W':
+			d += time.Duration(num) * 7 * 24 * time.Hour
+			num = 0
+		case r == 'D':
+			d += time.Duration(num) * 24 * time.Hour
+			num = 0
+		case r == 'H' && inTime:
+			d += time.Duration(num) * time.Hour
+			num = 0
+		case r == 'M' && inTime:
+			d += time.Duration(num) * time.Minute
+			num = 0
+		case r == 'S' && inTime:
+			d += time.Duration(num) * time.Second
+			num = 0
+		}
+	}
+	return d
+}
@@ -0,0 +1,48 @@
+package nextcloud
+
+import (
+	"testing"
+	"time"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\nSUMMARY:Standup\r\nDTSTART:20260301T150000Z\r\nDTEND:20260301T153000Z\r\n" +
+	"BEGIN:VALARM\r\nTRIGGER:-PT10M\r\nDURATION:PT5M\r\nEND:VALARM\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nSUMMARY:Planning with a very long\r\n  title\r\nDTSTART;TZID=Europe/Berlin:20260301T163000\r\nDURATION:PT1H\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nSUMMARY:Lunch (free)\r\nTRANSP:TRANSPARENT\r\nDTSTART:20260301T120000Z\r\nDTEND:20260301T130000Z\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nSUMMARY:Holiday\r\nDTSTART;VALUE=DATE:20260305\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICSBusy(t *testing.T) {
+	busy := parseICSBusy(testICS)
+	if len(busy) != 3 {
+		t.Fatalf("got %d busy periods, want 3: %+v", len(busy), busy)
+	}
+	if busy[0].Summary != "Standup" || busy[0].End.Sub(busy[0].Start) != 30*time.Minute {
+		t.Errorf("standup = %+v (VALARM must not override the event)", busy[0])
+	}
+	if busy[1].Summary != "Planning with a very long title" {
+		t.Errorf("folded summary = %q", busy[1].Summary)
+	}
+	if got := busy[1].Start.UTC(); !got.Equal(time.Date(2026, 3, 1, 15, 30, 0, 0, time.UTC)) {
+		t.Errorf("TZID start = %v", got)
+	}
+	if busy[2].End.Sub(busy[2].Start) != 24*time.Hour {
+		t.Errorf("all-day event = %+v", busy[2])
+	}
+}
+
+func TestNextFreeChainsBackToBackEvents(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 3, 1, h, m, 0, 0, time.UTC) }
+	busy := []BusyPeriod{
+		{Start: at(15, 0), End: at(15, 30), Summary: "A"},
+		{Start: at(15, 30), End: at(16, 0), Summary: "B"},
+	}
+	free, conflict := NextFree(busy, at(15, 10))
+	if conflict == nil || conflict.Summary != "A" || !free.Equal(at(16, 0)) {
+		t.Errorf("free=%v conflict=%+v", free, conflict)
+	}
+	if free, conflict := NextFree(busy, at(14, 0)); conflict != nil || !free.Equal(at(14, 0)) {
+		t.Errorf("free slot reported busy: %v %+v", free, conflict)
+	}
+}
@@ -0,0 +1,364 @@
+package nextcloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/timeparse"
+)
+
+// deckAPI is the Deck app's REST API, relative to the Nextcloud URL.
+const deckAPI = "/index.php/apps/deck/api/v1.0"
+
+// DeckBoard is a Deck board.
+type DeckBoard struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Color    string `json:"color,omitempty"`
+	Archived bool   `json:"archived,omitempty"`
+}
+
+// DeckStack is a column of a board with its cards.
+type DeckStack struct {
+	ID    int64      `json:"id"`
+	Title string     `json:"title"`
+	Order int        `json:"order"`
+	Cards []DeckCard `json:"cards"`
+}
+
+// DeckCard is a card; DueDate is RFC 3339 or empty.
+type DeckCard struct {
+	ID          int64           `json:"id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	StackID     int64           `json:"stackId"`
+	Order       int             `json:"order"`
+	DueDate     *string         `json:"duedate"`
+	Archived    bool            `json:"archived,omitempty"`
+	Labels      []deckLabel     `json:"labels,omitempty"`
+	Owner       json.RawMessage `json:"owner,omitempty"` // a user ID, or an object with "uid"
+}
+
+type deckLabel struct {
+	Title string `json:"title"`
+}
+
+// ownerUID returns the card owner's user ID, which the API wants back on every update.
+func (c DeckCard) ownerUID() string {
+	var uid string
+	if json.Unmarshal(c.Owner, &uid) == nil {
+		return uid
+	}
+	var owner struct {
+		UID        string `json:"uid"`
+		PrimaryKey string `json:"primaryKey"`
+	}
+	if json.Unmarshal(c.Owner, &owner) == nil {
+		if owner.UID != "" {
+			return owner.UID
+		}
+		return owner.PrimaryKey
+	}
+	return ""
+}
+
+// deckClient calls the Deck API as the Hattie user.
+type deckClient struct {
+	cfg    *config.Config
+	client *http.Client
+}
+
+func newDeckClient(cfg *config.Config) (*deckClient, error) {
+	if cfg.NextcloudURL == "" || cfg.NextcloudBotUser == "" || cfg.NextcloudBotAppPassword == "" {
+		return nil, fmt.Errorf("nextcloud credentials not configured")
+	}
+	return &deckClient{cfg: cfg, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// do sends a JSON request to path (below deckAPI) and decodes the response into out.
+func (d *deckClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(d.cfg.NextcloudURL, "/")+deckAPI+path, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(d.cfg.NextcloudBotUser, d.cfg.NextcloudBotAppPassword)
+	req.Header.Set("OCS-APIRequest", "true")
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound && strings.Contains(string(data), "<html") {
+		return fmt.Errorf("Deck API not found: is the Deck app installed and enabled?")
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Deck %s %s error %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("Deck %s %s: parsing response: %w", method, path, err)
+	}
+	return nil
+}
+
+func (d *deckClient) boards() ([]DeckBoard, error) {
+	var boards []DeckBoard
+	err := d.do("GET", "/boards", nil, &boards)
+	return boards, err
+}
+
+func (d *deckClient) stacks(boardID int64) ([]DeckStack, error) {
+	var stacks []DeckStack
+	err := d.do("GET", fmt.Sprintf("/boards/%d/stacks", boardID), nil, &stacks)
+	for i := range stacks {
+		if stacks[i].Cards == nil {
+			stacks[i].Cards = []DeckCard{}
+		}
+	}
+	return stacks, err
+}
+
+// findBoard resolves a board by ID or case-insensitive title; archived boards match only by ID.
+func (d *deckClient) findBoard(ref string) (*DeckBoard, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, fmt.Errorf("board is required")
+	}
+	boards, err := d.boards()
+	if err != nil {
+		return nil, err
+	}
+	id, _ := strconv.ParseInt(strings.TrimPrefix(ref, "#"), 10, 64)
+	for i, b := range boards {
+		if b.ID == id || (!b.Archived && strings.EqualFold(b.Title, ref)) {
+			return &boards[i], nil
+		}
+	}
+	return nil, fmt.Errorf("board %q not found (list_boards shows the boards shared with the bot)", ref)
+}
+
+// findStack resolves a stack of stacks by ID or case-insensitive title.
+func findStack(stacks []DeckStack, ref string) (*DeckStack, error) {
+	ref = strings.TrimSpace(ref)
+	id, _ := strconv.ParseInt(strings.TrimPrefix(ref, "#"), 10, 64)
+	for i, s := range stacks {
+		if s.ID == id || strings.EqualFold(s.Title, ref) {
+			return &stacks[i], nil
+		}
+	}
+	var titles []string
+	for _, s := range stacks {
+		titles = append(titles, s.Title)
+	}
+	return nil, fmt.Errorf("stack %q not found (stacks: %s)", ref, strings.Join(titles, ", "))
+}
+
+// findCard returns card id and the stack holding it.
+func findCard(stacks []DeckStack, id int64) (*DeckCard, *DeckStack, error) {
+	for i := range stacks {
+		for j := range stacks[i].Cards {
+			if stacks[i].Cards[j].ID == id {
+				return &stacks[i].Cards[j], &stacks[i], nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("card %d not found on this board", id)
+}
+
+// updateCard writes a card back with the fields the API requires on every update.
+func (d *deckClient) updateCard(boardID int64, c *DeckCard) error {
+	body := map[string]interface{}{
+		"title":       c.Title,
+		"type":        "plain",
+		"owner":       c.ownerUID(),
+		"order":       c.Order,
+		"description": c.Description,
+		"duedate":     c.DueDate,
+	}
+	return d.do("PUT", fmt.Sprintf("/boards/%d/stacks/%d/cards/%d", boardID, c.StackID, c.ID), body, c)
+}
+
+// DeckArgs are manage_deck's arguments.
+type DeckArgs struct {
+	Action      string  `json:"action"`
+	Board       string  `json:"board"`
+	Stack       string  `json:"stack"`
+	CardID      int64   `json:"card_id"`
+	Title       string  `json:"title"`
+	Description *string `json:"description"`
+	Due         *string `json:"due"`
+	Color       string  `json:"color"`
+}
+
+// ManageDeck runs one manage_deck action against the Deck app: list_boards, list_cards (the
+// stacks of a board with their cards), create_board, create_stack, create_card, update_card,
+// move_card and set_due. Boards and stacks are named by ID or title.
+func ManageDeck(cfg *config.Config, args DeckArgs) (string, error) {
+	d, err := newDeckClient(cfg)
+	if err != nil {
+		return "", err
+	}
+	result := func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+
+	switch args.Action {
+	case "list_boards":
+		boards, err := d.boards()
+		if err != nil {
+			return "", err
+		}
+		open := []DeckBoard{}
+		for _, b := range boards {
+			if !b.Archived {
+				open = append(open, b)
+			}
+		}
+		return result(open)
+	case "create_board":
+		if args.Title == "" {
+			return "", fmt.Errorf("title is required")
+		}
+		color := strings.TrimPrefix(args.Color, "#")
+		if color == "" {
+			color = "0082c9"
+		}
+		var board DeckBoard
+		if err := d.do("POST", "/boards", map[string]string{"title": args.Title, "color": color}, &board); err != nil {
+			return "", err
+		}
+		return result(board)
+	}
+
+	board, err := d.findBoard(args.Board)
+	if err != nil {
+		return "", err
+	}
+	stacks, err := d.stacks(board.ID)
+	if err != nil {
+		return "", err
+	}
+
+	switch args.Action {
+	case "list_cards":
+		if args.Stack != "" {
+			s, err := findStack(stacks, args.Stack)
+			if err != nil {
+				return "", err
+			}
+			stacks = []DeckStack{*s}
+		}
+		return result(map[string]interface{}{"board": board, "stacks": stacks})
+	case "create_stack":
+		if args.Title == "" {
+			return "", fmt.Errorf("title is required")
+		}
+		var s DeckStack
+		if err := d.do("POST", fmt.Sprintf("/boards/%d/stacks", board.ID), map[string]interface{}{"title": args.Title, "order": len(stacks)}, &s); err != nil {
+			return "", err
+		}
+		return result(s)
+	case "create_card":
+		if args.Title == "" {
+			return "", fmt.Errorf("title is required")
+		}
+		if args.Stack == "" && len(stacks) > 0 {
+			args.Stack = stacks[0].Title
+		}
+		s, err := findStack(stacks, args.Stack)
+		if err != nil {
+			return "", err
+		}
+		body := map[string]interface{}{"title": args.Title, "type": "plain", "order": len(s.Cards)}
+		if args.Description != nil {
+			body["description"] = *args.Description
+		}
+		if args.Due != nil {
+			due, err := deckDue(*args.Due)
+			if err != nil {
+				return "", err
+			}
+			body["duedate"] = due
+		}
+		var card DeckCard
+		if err := d.do("POST", fmt.Sprintf("/boards/%d/stacks/%d/cards", board.ID, s.ID), body, &card); err != nil {
+			return "", err
+		}
+		return result(card)
+	case "update_card", "set_due":
+		card, _, err := findCard(stacks, args.CardID)
+		if err != nil {
+			return "", err
+		}
+		if args.Action == "set_due" && args.Due == nil {
+			return "", fmt.Errorf("due is required (\"\" clears it)")
+		}
+		if args.Title != "" {
+			card.Title = args.Title
+		}
+		if args.Description != nil {
+			card.Description = *args.Description
+		}
+		if args.Due != nil {
+			if card.DueDate, err = deckDue(*args.Due); err != nil {
+				return "", err
+			}
+		}
+		if err := d.updateCard(board.ID, card); err != nil {
+			return "", err
+		}
+		return result(card)
+	case "move_card":
+		card, from, err := findCard(stacks, args.CardID)
+		if err != nil {
+			return "", err
+		}
+		to, err := findStack(stacks, args.Stack)
+		if err != nil {
+			return "", err
+		}
+		body := map[string]interface{}{"stackId": to.ID, "order": len(to.Cards)}
+		if err := d.do("PUT", fmt.Sprintf("/boards/%d/stacks/%d/cards/%d/reorder", board.ID, from.ID, card.ID), body, nil); err != nil {
+			return "", err
+		}
+		return result(map[string]interface{}{"status": "moved", "card_id": card.ID, "from": from.Title, "to": to.Title})
+	default:
+		return "", fmt.Errorf("unknown action: %s (use list_boards, list_cards, create_board, create_stack, create_card, update_card, move_card, set_due)", args.Action)
+	}
+}
+
+// deckDue parses a due date for the API; "" clears it (nil).
+func deckDue(s string) (*string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	t, err := timeparse.Until(s, time.Now(), time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("due: %w", err)
+	}
+	due := t.Format(time.RFC3339)
+	return &due, nil
+}
@@ -0,0 +1,96 @@
+package nextcloud
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+)
+
+func TestManageDeck(t *testing.T) {
+	var calls []string
+	bodies := map[string]map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "hattie" || p != "secret" || r.Header.Get("OCS-APIRequest") != "true" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, deckAPI)
+		calls = append(calls, r.Method+" "+path)
+		if b, _ := io.ReadAll(r.Body); len(b) > 0 {
+			var m map[string]interface{}
+			json.Unmarshal(b, &m)
+			bodies[r.Method+" "+path] = m
+		}
+		switch r.Method + " " + path {
+		case "GET /boards":
+			io.WriteString(w, `[{"id":1,"title":"Family","color":"0082c9"},{"id":2,"title":"Old","archived":true}]`)
+		case "GET /boards/1/stacks":
+			io.WriteString(w, `[{"id":10,"title":"To do","order":0,"cards":[{"id":100,"title":"Buy milk","stackId":10,"order":0,"duedate":null,"owner":{"uid":"alice"}}]},{"id":11,"title":"Done","order":1}]`)
+		case "POST /boards/1/stacks/10/cards":
+			io.WriteString(w, `{"id":101,"title":"Fix bike","stackId":10,"order":1,"duedate":"2026-03-10T00:00:00+00:00","owner":"hattie"}`)
+		case "PUT /boards/1/stacks/10/cards/100":
+			w.Write(mustJSON(bodies["PUT /boards/1/stacks/10/cards/100"]))
+		case "PUT /boards/1/stacks/10/cards/100/reorder":
+			io.WriteString(w, `[]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message":"not found"}`)
+		}
+	}))
+	defer srv.Close()
+	cfg := &config.Config{NextcloudURL: srv.URL, NextcloudBotUser: "hattie", NextcloudBotAppPassword: "secret"}
+
+	out, err := ManageDeck(cfg, DeckArgs{Action: "list_boards"})
+	if err != nil || strings.Contains(out, "Old") || !strings.Contains(out, `"title":"Family"`) {
+		t.Fatalf("list_boards = %s, %v", out, err)
+	}
+
+	out, err = ManageDeck(cfg, DeckArgs{Action: "list_cards", Board: "family", Stack: "done"})
+	if err != nil || !strings.Contains(out, `"cards":[]`) || strings.Contains(out, "Buy milk") {
+		t.Errorf("list_cards = %s, %v", out, err)
+	}
+
+	due := "2026-03-10"
+	if out, err = ManageDeck(cfg, DeckArgs{Action: "create_card", Board: "1", Title: "Fix bike", Due: &due}); err != nil {
+		t.Fatal(err)
+	}
+	if b := bodies["POST /boards/1/stacks/10/cards"]; b["title"] != "Fix bike" || b["type"] != "plain" || !strings.HasPrefix(b["duedate"].(string), "2026-03-10T00:00:00") {
+		t.Errorf("create_card body = %v", b)
+	}
+
+	clear := ""
+	if _, err = ManageDeck(cfg, DeckArgs{Action: "set_due", Board: "Family", CardID: 100, Due: &clear}); err != nil {
+		t.Fatal(err)
+	}
+	if b := bodies["PUT /boards/1/stacks/10/cards/100"]; b["owner"] != "alice" || b["title"] != "Buy milk" || b["duedate"] != nil {
+		t.Errorf("set_due body = %v", b)
+	}
+
+	out, err = ManageDeck(cfg, DeckArgs{Action: "move_card", Board: "Family", CardID: 100, Stack: "Done"})
+	if err != nil || !strings.Contains(out, `"to":"Done"`) {
+		t.Fatalf("move_card = %s, %v", out, err)
+	}
+	if b := bodies["PUT /boards/1/stacks/10/cards/100/reorder"]; b["stackId"] != float64(11) {
+		t.Errorf("reorder body = %v", b)
+	}
+
+	if _, err = ManageDeck(cfg, DeckArgs{Action: "move_card", Board: "Family", CardID: 100, Stack: "Doing"}); err == nil || !strings.Contains(err.Error(), "To do, Done") {
+		t.Errorf("unknown stack error = %v", err)
+	}
+	if _, err = ManageDeck(cfg, DeckArgs{Action: "list_cards", Board: "Old"}); err == nil {
+		t.Error("archived board matched by title")
+	}
+	if _, err = ManageDeck(&config.Config{}, DeckArgs{Action: "list_boards"}); err == nil {
+		t.Error("missing credentials accepted")
+	}
+}
+
+func mustJSON(v interface{}) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
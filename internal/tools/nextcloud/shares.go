@@ -0,0 +1,115 @@
+package nextcloud
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+)
+
+// PublicShare is a public link to a file or folder in the bot user's files.
+type PublicShare struct {
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	Path     string `json:"path"`
+	Expires  string `json:"expires,omitempty"`  // YYYY-MM-DD
+	Password string `json:"password,omitempty"` // set only when generated, so it can be passed on
+}
+
+// ShareOptions are the optional settings of a public link. A zero Expires keeps the server's
+// default, which the admin may enforce.
+type ShareOptions struct {
+	Password         string
+	GeneratePassword bool
+	Expires          time.Time
+	Label            string
+	Note             string
+}
+
+// CreatePublicShare creates a read-only public link (OCS Share API, share type 3) for path.
+func CreatePublicShare(cfg *config.Config, path string, opts ShareOptions) (*PublicShare, error) {
+	path, err := davPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if path == "/" {
+		return nil, fmt.Errorf("refusing to share the whole files root")
+	}
+	generated := ""
+	if opts.GeneratePassword && opts.Password == "" {
+		if generated, err = sharePassword(); err != nil {
+			return nil, err
+		}
+		opts.Password = generated
+	}
+	params := map[string]string{"path": path, "shareType": "3", "permissions": "1"}
+	if opts.Password != "" {
+		params["password"] = opts.Password
+	}
+	if !opts.Expires.IsZero() {
+		params["expireDate"] = opts.Expires.Format("2006-01-02")
+	}
+	if opts.Label != "" {
+		params["label"] = opts.Label
+	}
+	if opts.Note != "" {
+		params["note"] = opts.Note
+	}
+	raw, err := RequestNextcloudOCS(cfg, "POST", "/apps/files_sharing/api/v1/shares", params)
+	if err != nil {
+		return nil, err
+	}
+	// OCS v1 answers HTTP 200 and carries the outcome in meta.statuscode (100 = ok); data is an
+	// empty array on failure.
+	var resp struct {
+		OCS struct {
+			Meta struct {
+				StatusCode int    `json:"statuscode"`
+				Message    string `json:"message"`
+			} `json:"meta"`
+			Data json.RawMessage `json:"data"`
+		} `json:"ocs"`
+	}
+	var d struct {
+		ID         json.Number `json:"id"`
+		URL        string      `json:"url"`
+		Path       string      `json:"path"`
+		Expiration string      `json:"expiration"`
+	}
+	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+		return nil, fmt.Errorf("share API: parsing response: %w", err)
+	}
+	if meta := resp.OCS.Meta; meta.StatusCode != 100 && meta.StatusCode != 200 {
+		return nil, fmt.Errorf("share API error %d: %s", meta.StatusCode, meta.Message)
+	}
+	if err := json.Unmarshal(resp.OCS.Data, &d); err != nil {
+		return nil, fmt.Errorf("share API: parsing share: %w", err)
+	}
+	share := &PublicShare{ID: d.ID.String(), URL: d.URL, Path: d.Path, Password: generated}
+	if len(d.Expiration) >= 10 {
+		share.Expires = d.Expiration[:10]
+	}
+	return share, nil
+}
+
+// shareAlphabet leaves out look-alike characters, since link passwords are often retyped.
+const shareAlphabet = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func sharePassword() (string, error) {
+	var b strings.Builder
+	for i := 0; i < 16; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(shareAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(shareAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
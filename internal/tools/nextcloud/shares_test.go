@@ -0,0 +1,59 @@
+package nextcloud
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+)
+
+func TestCreatePublicShare(t *testing.T) {
+	var form url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ocs/v1.php/apps/files_sharing/api/v1/shares" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		form, _ = url.ParseQuery(string(b))
+		if form.Get("path") == "/missing.pdf" {
+			io.WriteString(w, `{"ocs":{"meta":{"status":"failure","statuscode":404,"message":"Wrong path, file/folder does not exist"},"data":[]}}`)
+			return
+		}
+		io.WriteString(w, `{"ocs":{"meta":{"status":"ok","statuscode":100,"message":"OK"},"data":{"id":"12","url":"https://cloud.example/s/AbC","path":"/Reports/q3.pdf","expiration":"2026-11-01 00:00:00"}}}`)
+	}))
+	defer srv.Close()
+	cfg := &config.Config{NextcloudURL: srv.URL, NextcloudBotUser: "hattie", NextcloudBotAppPassword: "pw"}
+
+	share, err := CreatePublicShare(cfg, "Reports/q3.pdf", ShareOptions{GeneratePassword: true, Expires: time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if share.URL != "https://cloud.example/s/AbC" || share.ID != "12" || share.Expires != "2026-11-01" {
+		t.Errorf("share = %+v", share)
+	}
+	if form.Get("shareType") != "3" || form.Get("permissions") != "1" || form.Get("path") != "/Reports/q3.pdf" || form.Get("expireDate") != "2026-11-01" {
+		t.Errorf("form = %v", form)
+	}
+	if !regexp.MustCompile(`^(\w{4}-){3}\w{4}$`).MatchString(share.Password) || form.Get("password") != share.Password {
+		t.Errorf("generated password %q, sent %q", share.Password, form.Get("password"))
+	}
+
+	share, _ = CreatePublicShare(cfg, "/Reports/q3.pdf", ShareOptions{Password: "mine", GeneratePassword: true})
+	if share.Password != "" || form.Get("password") != "mine" || form.Has("expireDate") {
+		t.Errorf("given password: share %+v, form %v", share, form)
+	}
+
+	if _, err := CreatePublicShare(cfg, "/missing.pdf", ShareOptions{}); err == nil || !strings.Contains(err.Error(), "Wrong path") {
+		t.Errorf("missing file: %v", err)
+	}
+	if _, err := CreatePublicShare(cfg, "/", ShareOptions{}); err == nil {
+		t.Error("shared the files root")
+	}
+}
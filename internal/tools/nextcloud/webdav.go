@@ -0,0 +1,401 @@
+package nextcloud
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+)
+
+// davPath cleans a path in the bot user's files. It rejects ".." segments, so a model-supplied
+// path cannot point outside the files tree, and returns "/" for the root.
+func davPath(p string) (string, error) {
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("path %q: '..' is not allowed", p)
+		}
+	}
+	return path.Clean("/" + strings.TrimSpace(p)), nil
+}
+
+// davURL returns the WebDAV URL of a cleaned path, each segment escaped.
+func davURL(cfg *config.Config, p string) string {
+	segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.TrimRight(cfg.NextcloudURL, "/") + "/remote.php/dav/files/" + url.PathEscape(cfg.NextcloudBotUser) + "/" + strings.Join(segments, "/")
+}
+
+// davResponse is what davDo keeps of a response.
+type davResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// davDo sends a WebDAV request as the bot user and returns the status code and the start of
+// the response body.
+func davDo(cfg *config.Config, method, rawURL string, body []byte, header map[string]string) (int, []byte, error) {
+	resp, err := davFetch(cfg, method, rawURL, body, header, 1<<20)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.status, resp.body, nil
+}
+
+// davFetch is davDo returning the response headers too and reading up to maxBody bytes.
+func davFetch(cfg *config.Config, method, rawURL string, body []byte, header map[string]string, maxBody int64) (*davResponse, error) {
+	if cfg.NextcloudURL == "" || cfg.NextcloudBotUser == "" || cfg.NextcloudBotAppPassword == "" {
+		return nil, fmt.Errorf("nextcloud credentials not configured")
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(cfg.NextcloudBotUser, cfg.NextcloudBotAppPassword)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	if err != nil {
+		return nil, err
+	}
+	return &davResponse{status: resp.StatusCode, header: resp.Header, body: data}, nil
+}
+
+// davEntries returns how many entries PROPFIND reports for p at depth 1 (the item itself plus
+// its children), or 0 when p does not exist.
+func davEntries(cfg *config.Config, p string) (int, error) {
+	status, body, err := davDo(cfg, "PROPFIND", davURL(cfg, p), nil, map[string]string{"Depth": "1"})
+	if err != nil {
+		return 0, err
+	}
+	if status == http.StatusNotFound {
+		return 0, nil
+	}
+	if status >= 400 {
+		return 0, fmt.Errorf("WebDAV error %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	var ms MultiStatus
+	if err := xml.Unmarshal(body, &ms); err != nil {
+		return 0, fmt.Errorf("WebDAV: parsing PROPFIND response: %w", err)
+	}
+	return len(ms.Responses), nil
+}
+
+// UploadNextcloudFile stores data at path in the bot user's files. Without overwrite, an existing
+// file is left alone and an error returned.
+func UploadNextcloudFile(cfg *config.Config, p string, data []byte, overwrite bool) error {
+	p, err := davPath(p)
+	if err != nil {
+		return err
+	}
+	if p == "/" {
+		return fmt.Errorf("path must name a file")
+	}
+	header := map[string]string{"Content-Type": "application/octet-stream"}
+	if !overwrite {
+		header["If-None-Match"] = "*"
+	}
+	status, body, err := davDo(cfg, "PUT", davURL(cfg, p), data, header)
+	if err != nil {
+		return err
+	}
+	switch {
+	case status == http.StatusPreconditionFailed:
+		return fmt.Errorf("%s already exists (set overwrite to replace it)", p)
+	case status == http.StatusConflict:
+		return fmt.Errorf("folder %s does not exist (create it first)", path.Dir(p))
+	case status >= 300:
+		return fmt.Errorf("upload failed (%d): %s", status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// CreateNextcloudFolder creates a folder and any missing parents; an existing folder is not an error.
+func CreateNextcloudFolder(cfg *config.Config, p string) error {
+	p, err := davPath(p)
+	if err != nil {
+		return err
+	}
+	if p == "/" {
+		return nil
+	}
+	cur := ""
+	for _, seg := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+		cur += "/" + seg
+		status, body, err := davDo(cfg, "MKCOL", davURL(cfg, cur), nil, nil)
+		if err != nil {
+			return err
+		}
+		// 405: already exists
+		if status >= 300 && status != http.StatusMethodNotAllowed {
+			return fmt.Errorf("creating %s failed (%d): %s", cur, status, strings.TrimSpace(string(body)))
+		}
+	}
+	return nil
+}
+
+// MoveNextcloudFile moves or renames a file or folder. Without overwrite, an existing destination
+// is left alone and an error returned.
+func MoveNextcloudFile(cfg *config.Config, from, to string, overwrite bool) error {
+	from, err := davPath(from)
+	if err != nil {
+		return err
+	}
+	if to, err = davPath(to); err != nil {
+		return err
+	}
+	if from == "/" || to == "/" {
+		return fmt.Errorf("cannot move the root folder or onto it")
+	}
+	if from == to || strings.HasPrefix(to, from+"/") {
+		return fmt.Errorf("cannot move %s into itself", from)
+	}
+	header := map[string]string{"Destination": davURL(cfg, to), "Overwrite": "F"}
+	if overwrite {
+		header["Overwrite"] = "T"
+	}
+	status, body, err := davDo(cfg, "MOVE", davURL(cfg, from), nil, header)
+	if err != nil {
+		return err
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return fmt.Errorf("%s does not exist", from)
+	case status == http.StatusPreconditionFailed:
+		return fmt.Errorf("%s already exists (set overwrite to replace it)", to)
+	case status == http.StatusConflict:
+		return fmt.Errorf("folder %s does not exist (create it first)", path.Dir(to))
+	case status >= 300:
+		return fmt.Errorf("move failed (%d): %s", status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// NextcloudTrashEnabled reports whether deleted files go to the bot user's trash bin (the
+// Deleted files app), where they can be restored.
+func NextcloudTrashEnabled(cfg *config.Config) (bool, error) {
+	trash := strings.TrimRight(cfg.NextcloudURL, "/") + "/remote.php/dav/trashbin/" + url.PathEscape(cfg.NextcloudBotUser) + "/trash"
+	status, _, err := davDo(cfg, "PROPFIND", trash, nil, map[string]string{"Depth": "0"})
+	if err != nil {
+		return false, err
+	}
+	return status == http.StatusMultiStatus, nil
+}
+
+// DeleteNextcloudFile deletes a file or folder and reports whether it went to the trash bin.
+// Folders with contents need recursive; without a trash bin, deleting needs permanent, since
+// nothing could be restored.
+func DeleteNextcloudFile(cfg *config.Config, p string, recursive, permanent bool) (trashed bool, err error) {
+	if p, err = davPath(p); err != nil {
+		return false, err
+	}
+	if p == "/" {
+		return false, fmt.Errorf("refusing to delete the root folder")
+	}
+	n, err := davEntries(cfg, p)
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		return false, fmt.Errorf("%s does not exist", p)
+	}
+	if n > 1 && !recursive {
+		return false, fmt.Errorf("%s is a folder with %d items (set recursive to delete it with its contents)", p, n-1)
+	}
+	trashed, err = NextcloudTrashEnabled(cfg)
+	if err != nil {
+		return false, err
+	}
+	if !trashed && !permanent {
+		return false, fmt.Errorf("the trash bin is disabled, so %s could not be restored (set permanent to delete it anyway)", p)
+	}
+	status, body, err := davDo(cfg, "DELETE", davURL(cfg, p), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	if status >= 300 {
+		return false, fmt.Errorf("delete failed (%d): %s", status, strings.TrimSpace(string(body)))
+	}
+	return trashed, nil
+}
+
+// DavFile is a file or folder in the bot user's files.
+type DavFile struct {
+	Path string // cleaned, e.g. /HattieBot/Workspace/notes.md
+	Dir  bool
+	Size int64
+	ETag string // changes whenever the content does
+}
+
+// davPropfind is the part of a PROPFIND response ListNextcloudTree reads.
+type davPropfind struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Prop struct {
+				ETag         string `xml:"DAV: getetag"`
+				Length       int64  `xml:"DAV: getcontentlength"`
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// ErrNotFound is returned when a path does not exist in Nextcloud.
+var ErrNotFound = fmt.Errorf("not found in Nextcloud")
+
+// ListNextcloudTree returns the files and folders below root, walking one level per request
+// (Nextcloud does not allow Depth: infinity). It returns ErrNotFound when root does not exist.
+func ListNextcloudTree(cfg *config.Config, root string) ([]DavFile, error) {
+	root, err := davPath(root)
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(davURL(cfg, "/"))
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimSuffix(base.Path, "/")
+	var out []DavFile
+	queue := []string{root}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		resp, err := davFetch(cfg, "PROPFIND", davURL(cfg, dir), nil, map[string]string{"Depth": "1"}, 64<<20)
+		if err != nil {
+			return nil, err
+		}
+		status, body := resp.status, resp.body
+		if status == http.StatusNotFound {
+			if dir == root {
+				return nil, ErrNotFound
+			}
+			continue // removed while walking
+		}
+		if status >= 400 {
+			return nil, fmt.Errorf("WebDAV error %d: %s", status, strings.TrimSpace(string(body)))
+		}
+		var ms davPropfind
+		if err := xml.Unmarshal(body, &ms); err != nil {
+			return nil, fmt.Errorf("WebDAV: parsing PROPFIND response: %w", err)
+		}
+		for _, r := range ms.Responses {
+			href, err := url.PathUnescape(r.Href)
+			if err != nil {
+				continue
+			}
+			if u, err := url.Parse(href); err == nil && u.Host != "" {
+				href = u.Path // some servers answer with absolute URLs
+			}
+			p := path.Clean("/" + strings.TrimPrefix(href, prefix))
+			if p == dir || len(r.Propstat) == 0 {
+				continue
+			}
+			f := DavFile{Path: p}
+			for _, ps := range r.Propstat {
+				if ps.Prop.ResourceType.Collection != nil {
+					f.Dir = true
+				}
+				if ps.Prop.ETag != "" {
+					f.ETag = normalizeETag(ps.Prop.ETag)
+				}
+				if ps.Prop.Length > 0 {
+					f.Size = ps.Prop.Length
+				}
+			}
+			out = append(out, f)
+			if f.Dir {
+				queue = append(queue, p)
+			}
+		}
+	}
+	return out, nil
+}
+
+// DownloadNextcloudFile returns the content of a file, up to maxBytes.
+func DownloadNextcloudFile(cfg *config.Config, p string, maxBytes int64) ([]byte, error) {
+	p, err := davPath(p)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := davFetch(cfg, "GET", davURL(cfg, p), nil, nil, maxBytes+1)
+	if err != nil {
+		return nil, err
+	}
+	if resp.status == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.status >= 300 {
+		return nil, fmt.Errorf("download failed (%d): %s", resp.status, strings.TrimSpace(string(resp.body)))
+	}
+	if int64(len(resp.body)) > maxBytes {
+		return nil, fmt.Errorf("%s is larger than %d bytes", p, maxBytes)
+	}
+	return resp.body, nil
+}
+
+// PutNextcloudFile writes data to path, replacing any file there and creating missing folders,
+// and returns the new ETag.
+func PutNextcloudFile(cfg *config.Config, p string, data []byte) (string, error) {
+	p, err := davPath(p)
+	if err != nil {
+		return "", err
+	}
+	header := map[string]string{"Content-Type": "application/octet-stream"}
+	resp, err := davFetch(cfg, "PUT", davURL(cfg, p), data, header, 1<<16)
+	if err == nil && resp.status == http.StatusConflict {
+		if err := CreateNextcloudFolder(cfg, path.Dir(p)); err != nil {
+			return "", err
+		}
+		resp, err = davFetch(cfg, "PUT", davURL(cfg, p), data, header, 1<<16)
+	}
+	if err != nil {
+		return "", err
+	}
+	if resp.status >= 300 {
+		return "", fmt.Errorf("upload failed (%d): %s", resp.status, strings.TrimSpace(string(resp.body)))
+	}
+	if etag := resp.header.Get("OC-ETag"); etag != "" {
+		return normalizeETag(etag), nil
+	}
+	if etag := resp.header.Get("ETag"); etag != "" {
+		return normalizeETag(etag), nil
+	}
+	// No ETag on the PUT response: ask for it
+	status, body, err := davDo(cfg, "PROPFIND", davURL(cfg, p), nil, map[string]string{"Depth": "0"})
+	if err != nil {
+		return "", err
+	}
+	var ms davPropfind
+	if status != http.StatusMultiStatus || xml.Unmarshal(body, &ms) != nil || len(ms.Responses) == 0 || len(ms.Responses[0].Propstat) == 0 {
+		return "", fmt.Errorf("uploaded %s but could not read its ETag", p)
+	}
+	return normalizeETag(ms.Responses[0].Propstat[0].Prop.ETag), nil
+}
+
+// normalizeETag strips the quotes and weak marker, which differ between PROPFIND and PUT answers.
+func normalizeETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(strings.TrimSpace(etag), "W/"), `"`)
+}
@@ -0,0 +1,207 @@
+package nextcloud
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+)
+
+// fakeDAV is a minimal WebDAV server holding files and folders by path.
+type fakeDAV struct {
+	files   map[string]string
+	folders map[string]bool
+	trash   bool
+	deleted []string
+}
+
+func (f *fakeDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/remote.php/dav/trashbin/") {
+		if f.trash {
+			w.WriteHeader(http.StatusMultiStatus)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, "/remote.php/dav/files/hattie")
+	parent := p[:strings.LastIndex(p, "/")]
+	exists := f.folders[p] || f.files[p] != ""
+	switch r.Method {
+	case "PUT":
+		if !f.folders[parent] && parent != "" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if exists && r.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		f.files[p] = string(b)
+		w.WriteHeader(http.StatusCreated)
+	case "MKCOL":
+		if exists {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		f.folders[p] = true
+		w.WriteHeader(http.StatusCreated)
+	case "MOVE":
+		dest := strings.TrimPrefix(r.Header.Get("Destination"), "http://"+r.Host+"/remote.php/dav/files/hattie")
+		if f.files[dest] != "" && r.Header.Get("Overwrite") == "F" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		f.files[dest] = f.files[p]
+		delete(f.files, p)
+		w.WriteHeader(http.StatusCreated)
+	case "PROPFIND":
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+		b.WriteString(`<d:response><d:href>` + p + `</d:href></d:response>`)
+		for name := range f.files {
+			if strings.HasPrefix(name, p+"/") {
+				b.WriteString(`<d:response><d:href>` + name + `</d:href></d:response>`)
+			}
+		}
+		b.WriteString(`</d:multistatus>`)
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, b.String())
+	case "DELETE":
+		f.deleted = append(f.deleted, p)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestNextcloudFileOperations(t *testing.T) {
+	dav := &fakeDAV{files: map[string]string{}, folders: map[string]bool{}, trash: true}
+	srv := httptest.NewServer(dav)
+	defer srv.Close()
+	cfg := &config.Config{NextcloudURL: srv.URL, NextcloudBotUser: "hattie", NextcloudBotAppPassword: "pw"}
+
+	if err := UploadNextcloudFile(cfg, "/Notes/a.md", []byte("x"), false); err == nil || !strings.Contains(err.Error(), "create it first") {
+		t.Errorf("upload into missing folder: %v", err)
+	}
+	if err := CreateNextcloudFolder(cfg, "Notes/2026"); err != nil || !dav.folders["/Notes"] || !dav.folders["/Notes/2026"] {
+		t.Fatalf("mkdir: %v, folders %v", err, dav.folders)
+	}
+	if err := CreateNextcloudFolder(cfg, "/Notes"); err != nil {
+		t.Errorf("existing folder: %v", err)
+	}
+	if err := UploadNextcloudFile(cfg, "/Notes/a.md", []byte("one"), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := UploadNextcloudFile(cfg, "/Notes/a.md", []byte("two"), false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("upload without overwrite: %v", err)
+	}
+	if err := UploadNextcloudFile(cfg, "/Notes/a.md", []byte("two"), true); err != nil || dav.files["/Notes/a.md"] != "two" {
+		t.Errorf("upload with overwrite: %v, %q", err, dav.files["/Notes/a.md"])
+	}
+	if err := UploadNextcloudFile(cfg, "/Notes/../../etc/passwd", []byte("x"), true); err == nil {
+		t.Error("'..' path accepted")
+	}
+
+	dav.files["/Notes/b.md"] = "b"
+	if err := MoveNextcloudFile(cfg, "/Notes/a.md", "/Notes/b.md", false); err == nil {
+		t.Error("move onto an existing file without overwrite")
+	}
+	if err := MoveNextcloudFile(cfg, "/Notes", "/Notes/2026/old", false); err == nil {
+		t.Error("moved a folder into itself")
+	}
+	if err := MoveNextcloudFile(cfg, "/Notes/a.md", "/Notes/c.md", false); err != nil || dav.files["/Notes/c.md"] != "two" {
+		t.Errorf("move: %v", err)
+	}
+
+	if _, err := DeleteNextcloudFile(cfg, "/", true, true); err == nil {
+		t.Error("deleted the root folder")
+	}
+	if _, err := DeleteNextcloudFile(cfg, "/Notes", false, false); err == nil || !strings.Contains(err.Error(), "recursive") {
+		t.Errorf("non-empty folder without recursive: %v", err)
+	}
+	if trashed, err := DeleteNextcloudFile(cfg, "/Notes", true, false); err != nil || !trashed {
+		t.Errorf("recursive delete: trashed=%v, %v", trashed, err)
+	}
+	dav.trash = false
+	if _, err := DeleteNextcloudFile(cfg, "/Notes/c.md", false, false); err == nil || !strings.Contains(err.Error(), "permanent") {
+		t.Errorf("delete without trash bin: %v", err)
+	}
+	if trashed, err := DeleteNextcloudFile(cfg, "/Notes/c.md", false, true); err != nil || trashed {
+		t.Errorf("permanent delete: trashed=%v, %v", trashed, err)
+	}
+	if len(dav.deleted) != 2 {
+		t.Errorf("deleted = %v", dav.deleted)
+	}
+}
+
+func TestListAndPutNextcloudTree(t *testing.T) {
+	entry := func(href, props string) string {
+		return `<d:response><d:href>` + href + `</d:href><d:propstat><d:prop>` + props + `</d:prop></d:propstat></d:response>`
+	}
+	var puts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := "/remote.php/dav/files/hattie"
+		switch r.Method + " " + r.URL.Path {
+		case "PROPFIND " + base + "/Work":
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<d:multistatus xmlns:d="DAV:">`+
+				entry(base+"/Work/", `<d:resourcetype><d:collection/></d:resourcetype>`)+
+				entry(base+"/Work/a%20b.md", `<d:getetag>"e1"</d:getetag><d:getcontentlength>3</d:getcontentlength><d:resourcetype/>`)+
+				entry(base+"/Work/sub/", `<d:resourcetype><d:collection/></d:resourcetype>`)+
+				`</d:multistatus>`)
+		case "PROPFIND " + base + "/Work/sub":
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<d:multistatus xmlns:d="DAV:">`+
+				entry(base+"/Work/sub/", `<d:resourcetype><d:collection/></d:resourcetype>`)+
+				entry(base+"/Work/sub/c.txt", `<d:getetag>W/"e2"</d:getetag><d:getcontentlength>1</d:getcontentlength>`)+
+				`</d:multistatus>`)
+		case "PUT " + base + "/New/x.txt":
+			puts = append(puts, r.URL.Path)
+			if len(puts) == 1 {
+				w.WriteHeader(http.StatusConflict) // parent folder missing
+				return
+			}
+			w.Header().Set("OC-ETag", `"e3"`)
+			w.WriteHeader(http.StatusCreated)
+		case "MKCOL " + base + "/New":
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	cfg := &config.Config{NextcloudURL: srv.URL, NextcloudBotUser: "hattie", NextcloudBotAppPassword: "pw"}
+
+	files, err := ListNextcloudTree(cfg, "/Work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DavFile{
+		{Path: "/Work/a b.md", Size: 3, ETag: "e1"},
+		{Path: "/Work/sub", Dir: true},
+		{Path: "/Work/sub/c.txt", Size: 1, ETag: "e2"},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("files = %+v", files)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Errorf("files[%d] = %+v, want %+v", i, files[i], want[i])
+		}
+	}
+	if _, err := ListNextcloudTree(cfg, "/Missing"); err != ErrNotFound {
+		t.Errorf("missing root: %v", err)
+	}
+
+	etag, err := PutNextcloudFile(cfg, "/New/x.txt", []byte("x"))
+	if err != nil || etag != "e3" || len(puts) != 2 {
+		t.Errorf("put: %q, %v, %d PUTs", etag, err, len(puts))
+	}
+}
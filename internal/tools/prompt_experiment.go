@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// defaultExperimentFraction is the share of turns that get the variant unless start sets one.
+const defaultExperimentFraction = 0.2
+
+// ManagePromptExperimentTool A/B tests a SOUL.md variant: start gives the variant to a sampled
+// fraction of conversation turns, report compares the arms, and promote writes the winning
+// variant to SOUL.md. Promoting a variant that has not won needs force.
+func (e *Executor) ManagePromptExperimentTool(ctx context.Context, argsJSON string) (string, error) {
+	var args struct {
+		Action      string  `json:"action"`
+		Name        string  `json:"name"`
+		Variant     string  `json:"variant"`
+		VariantPath string  `json:"variant_path"`
+		Fraction    float64 `json:"fraction"`
+		Force       bool    `json:"force"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	soulPath := filepath.Join(e.ConfigDir, "SOUL.md")
+
+	switch args.Action {
+	case "start":
+		if args.Name == "" {
+			return ErrJSON(fmt.Errorf("name is required")), nil
+		}
+		if args.VariantPath != "" {
+			content, err := ReadFile(ctx, e.WorkspaceDir, args.VariantPath)
+			if err != nil {
+				return ErrJSON(err), nil
+			}
+			args.Variant = content
+		}
+		if strings.TrimSpace(args.Variant) == "" {
+			return ErrJSON(fmt.Errorf("variant (the full SOUL.md text to test) or variant_path is required")), nil
+		}
+		if args.Fraction == 0 {
+			args.Fraction = defaultExperimentFraction
+		}
+		baseline, _ := os.ReadFile(soulPath)
+		caller, _ := ctx.Value("user_id").(string)
+		id, err := e.DB.CreatePromptExperiment(ctx, store.PromptExperiment{
+			Name: args.Name, Variant: args.Variant, Baseline: string(baseline), Fraction: args.Fraction, CreatedBy: caller,
+		})
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"status": "started", "id": id, "name": args.Name, "fraction": args.Fraction,
+			"note": fmt.Sprintf("About %.0f%% of conversation turns now use the variant. Check back with action report; each arm needs %d turns before a winner is named.", args.Fraction*100, store.MinExperimentTurns),
+		})
+		return string(b), nil
+
+	case "list":
+		exps, err := e.DB.ListPromptExperiments(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		type item struct {
+			store.PromptExperiment
+			Variant string `json:"variant"`
+		}
+		out := make([]item, 0, len(exps))
+		for _, x := range exps {
+			out = append(out, item{x, snippet(x.Variant, 200)})
+		}
+		b, _ := json.Marshal(out)
+		return string(b), nil
+
+	case "report", "promote", "stop":
+		exp, err := e.experimentByName(ctx, args.Name)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		stats, err := e.DB.ExperimentStats(ctx, exp.ID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		winner, reason := store.ExperimentWinner(stats)
+		report := map[string]interface{}{
+			"name": exp.Name, "status": exp.Status, "fraction": exp.Fraction, "arms": stats,
+			"winner": winner, "verdict": reason,
+		}
+		if current, err := os.ReadFile(soulPath); err == nil && exp.Baseline != "" && string(current) != exp.Baseline {
+			report["warning"] = "SOUL.md changed since the experiment started, so the control arm mixes two prompts"
+		}
+
+		switch args.Action {
+		case "stop":
+			if err := e.DB.EndPromptExperiment(ctx, exp.ID, store.ExperimentStopped); err != nil {
+				return ErrJSON(err), nil
+			}
+			report["status"] = store.ExperimentStopped
+		case "promote":
+			if winner != store.ArmVariant && !args.Force {
+				report["error"] = "the variant has not won (" + reason + "); keep the experiment running, stop it, or promote with force=true if the admin decides anyway"
+				b, _ := json.Marshal(report)
+				return string(b), nil
+			}
+			if err := e.DB.EndPromptExperiment(ctx, exp.ID, store.ExperimentPromoted); err != nil {
+				return ErrJSON(err), nil
+			}
+			if err := os.WriteFile(soulPath, []byte(exp.Variant), 0644); err != nil {
+				return ErrJSON(fmt.Errorf("write SOUL.md: %w", err)), nil
+			}
+			_ = e.DB.InsertSelfModification(ctx, []string{soulPath}, "config", fmt.Sprintf("Promoted prompt experiment %q to SOUL.md", exp.Name), reason)
+			report["status"] = store.ExperimentPromoted
+		}
+		b, _ := json.Marshal(report)
+		return string(b), nil
+	}
+	return ErrJSON(fmt.Errorf("unknown action %q (use start, report, promote, stop or list)", args.Action)), nil
+}
+
+// experimentByName returns the experiment called name, or the running one when name is empty.
+func (e *Executor) experimentByName(ctx context.Context, name string) (*store.PromptExperiment, error) {
+	var exp *store.PromptExperiment
+	var err error
+	if name == "" {
+		exp, err = e.DB.RunningPromptExperiment(ctx)
+	} else {
+		exp, err = e.DB.GetPromptExperiment(ctx, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if exp == nil {
+		if name == "" {
+			return nil, fmt.Errorf("no experiment is running; give its name")
+		}
+		return nil, fmt.Errorf("experiment %q not found", name)
+	}
+	return exp, nil
+}
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// PurgeUserTool erases a user's messages, facts, memories, sessions and other records (admin only).
+func PurgeUserTool(ctx context.Context, db *store.DB, argsJSON string) (string, error) {
+	var args struct {
+		UserID string `json:"user_id"`
+		DryRun bool   `json:"dry_run"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if args.UserID == "" {
+		return ErrJSON(fmt.Errorf("user_id is required")), nil
+	}
+	if caller, _ := ctx.Value("user_id").(string); caller == args.UserID {
+		return ErrJSON(fmt.Errorf("refusing to purge yourself; another admin must do it")), nil
+	}
+	u, err := db.GetUser(ctx, args.UserID)
+	if err != nil && err != sql.ErrNoRows {
+		return ErrJSON(err), nil
+	}
+	if u != nil && u.Role == store.RoleOwner {
+		return ErrJSON(fmt.Errorf("refusing to purge the owner")), nil
+	}
+	report, err := db.PurgeUser(ctx, args.UserID, args.DryRun)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	if report.Total() == 0 {
+		return ErrJSON(fmt.Errorf("nothing stored for user %q", args.UserID)), nil
+	}
+	out := map[string]interface{}{"status": "purged", "report": report}
+	if args.DryRun {
+		out["status"] = "dry_run"
+	} else {
+		out["note"] = "Backups made before now still contain this user's data until they are rotated out. Tool audit log entries expire with the audit retention."
+	}
+	b, _ := json.MarshalIndent(out, "", "  ")
+	return string(b), nil
+}
@@ -11,7 +11,7 @@ import (
 // ReadLogsArgs represents the arguments for the read_logs tool.
 type ReadLogsArgs struct {
 	Level     string `json:"level,omitempty"`     // error, warn, info
-	Component string `json:"component,omitempty"` // db, llm, gateway, compactor
+	Component string `json:"component,omitempty"` // agent, gateway, scheduler, llm, ...
 	Limit     int    `json:"limit,omitempty"`     // max entries to return
 }
 
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/memory"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// defaultReembedLimit bounds one run of reembed_memories so it finishes within the tool timeout;
+// the agent calls it again for the rest.
+const defaultReembedLimit = 1000
+
+// ReembedMemoriesTool reports whether memories need new vectors after an embedding provider or
+// dimension change, and re-embeds them.
+func ReembedMemoriesTool(ctx context.Context, db *store.DB, embedder core.EmbeddingClient, argsJSON string) (string, error) {
+	var args struct {
+		Action string `json:"action"` // status, run
+		All    bool   `json:"all"`
+		Limit  int    `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	switch args.Action {
+	case "", "status":
+		st, err := memory.CheckEmbeddings(ctx, db, embedder)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		b, _ := json.Marshal(st)
+		return string(b), nil
+	case "run":
+		if args.Limit <= 0 {
+			args.Limit = defaultReembedLimit
+		}
+		p, err := memory.Reembed(ctx, db, embedder, memory.ReembedOptions{All: args.All, Limit: args.Limit})
+		out := map[string]interface{}{"progress": p}
+		if err != nil {
+			out["error"] = err.Error()
+		}
+		if !args.All {
+			if left, err := db.CountStaleChunks(ctx, p.Model, p.Dim, false); err == nil {
+				out["remaining"] = left
+			}
+		}
+		b, _ := json.Marshal(out)
+		return string(b), nil
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s", args.Action)), nil
+	}
+}
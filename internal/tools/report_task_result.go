@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/timeparse"
+)
+
+// ReportTaskResultTool finishes the plan run of the current scheduled turn with the agent's
+// structured result. The agent loop puts the run ID in ctx as "plan_run_id".
+func ReportTaskResultTool(ctx context.Context, db *store.DB, argsJSON string) (string, error) {
+	runID, _ := ctx.Value("plan_run_id").(int64)
+	if runID == 0 {
+		return ErrJSON(fmt.Errorf("report_task_result is only available while running a scheduled task")), nil
+	}
+	var args struct {
+		Status           string   `json:"status"`
+		Summary          string   `json:"summary"`
+		Artifacts        []string `json:"artifacts"`
+		NextSuggestedRun string   `json:"next_suggested_run"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if args.Summary == "" {
+		return ErrJSON(fmt.Errorf("summary required")), nil
+	}
+	run := store.PlanRun{Status: args.Status, Summary: args.Summary, Artifacts: args.Artifacts, Reported: true}
+	if args.NextSuggestedRun != "" {
+		next, err := timeparse.Until(args.NextSuggestedRun, time.Now(), time.Local)
+		if err != nil {
+			return ErrJSON(fmt.Errorf("next_suggested_run: %w", err)), nil
+		}
+		run.NextSuggestedRun = &next
+	}
+	if err := db.FinishPlanRun(ctx, runID, run); err != nil {
+		return ErrJSON(err), nil
+	}
+	return fmt.Sprintf(`{"status": "recorded", "run_id": %d}`, runID), nil
+}
@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// SelfUpdateTool builds HattieBot from its source checkout and installs the result through the
+// supervisor: build runs in the background (go build and go test on an export of the commit),
+// apply installs the staged binary, rollback restores the previous one.
+func (e *Executor) SelfUpdateTool(ctx context.Context, argsJSON string) (string, error) {
+	if e.SelfUpdate == nil {
+		return `{"error": "self-update is not configured; set HATTIEBOT_GIT_REPO_DIR"}`, nil
+	}
+	var args struct {
+		Action string `json:"action"`
+		Ref    string `json:"ref"`
+		Commit string `json:"commit"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	out := func(v interface{}) (string, error) {
+		b, _ := json.MarshalIndent(v, "", "  ")
+		return string(b), nil
+	}
+
+	switch args.Action {
+	case "status", "":
+		return out(e.SelfUpdate.Status())
+	case "build":
+		userID, _ := getUserID(ctx)
+		res, err := e.SelfUpdate.StartBuild(args.Ref, userID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "building", "ref": %q, "note": "go build and go test run in the background; the result is sent to you when they finish (or see action=status)"}`, res.Ref), nil
+	case "apply":
+		how, err := e.SelfUpdate.Apply(args.Commit)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "restarting", "via": %q, "note": "the bot restarts in a few seconds on the new binary; it is rolled back automatically if it crash-loops"}`, how), nil
+	case "rollback":
+		how, err := e.SelfUpdate.Rollback()
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		return fmt.Sprintf(`{"status": "restarting", "via": %q, "note": "the bot restarts in a few seconds on the previous binary"}`, how), nil
+	case "discard":
+		if err := e.SelfUpdate.Discard(); err != nil {
+			return ErrJSON(err), nil
+		}
+		return `{"status": "discarded"}`, nil
+	}
+	return ErrJSON(fmt.Errorf("unknown action %q (use status, build, apply, rollback or discard)", args.Action)), nil
+}
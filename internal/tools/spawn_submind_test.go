@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/hattiebot/hattiebot/internal/core"
@@ -102,3 +103,62 @@ func (m *mockSubmindRegistry) Get(name string) (core.SubMindConfig, bool) { retu
 func (m *mockSubmindRegistry) Add(cfg core.SubMindConfig) error          { return nil }
 func (m *mockSubmindRegistry) Delete(name string) error                  { return nil }
 func (m *mockSubmindRegistry) List() []core.SubMindConfig                { return nil }
+
+// mockAsyncSpawner implements core.AsyncSubmindSpawner by creating sessions that finish at once.
+type mockAsyncSpawner struct {
+	mockSpawner
+	db *store.DB
+}
+
+func (m *mockAsyncSpawner) StartSubmind(ctx context.Context, userID, mode, task string) (int64, error) {
+	id, err := m.db.CreateSubmindSession(ctx, userID, mode, task, "sys")
+	if err != nil {
+		return 0, err
+	}
+	return id, m.db.UpdateSubmindSession(ctx, id, nil, 1, "completed", "did "+task, "")
+}
+
+func (m *mockAsyncSpawner) CancelSubmind(userID string, sessionID int64) bool { return false }
+
+func TestSpawnSubmind_parallelTasksAndCheck(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user_id", "u1")
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, _ = db.GetOrCreateUser(ctx, "u1", "", "test")
+	_, _ = db.GetOrCreateUser(ctx, "u2", "", "test")
+	e := &Executor{DB: db, Spawner: &mockAsyncSpawner{db: db}}
+
+	out, _ := e.Execute(ctx, "spawn_submind", `{"tasks":[{"mode":"planning","task":"a"},{"mode":"code_analysis","task":"b"}]}`)
+	var started struct {
+		SessionIDs []int64 `json:"session_ids"`
+	}
+	if err := json.Unmarshal([]byte(out), &started); err != nil || len(started.SessionIDs) != 2 {
+		t.Fatalf("spawn = %s", out)
+	}
+
+	out, _ = e.Execute(ctx, "check_submind", `{"session_ids":[1,2],"wait_seconds":1}`)
+	var checked struct {
+		AllDone  bool            `json:"all_done"`
+		Sessions []submindStatus `json:"sessions"`
+	}
+	if err := json.Unmarshal([]byte(out), &checked); err != nil {
+		t.Fatalf("check = %s", out)
+	}
+	if !checked.AllDone || len(checked.Sessions) != 2 || checked.Sessions[0].Output != "did a" || checked.Sessions[1].Output != "did b" {
+		t.Errorf("check = %s", out)
+	}
+
+	// Sessions of other users are not visible
+	other := context.WithValue(context.Background(), "user_id", "u2")
+	if out, _ := e.Execute(other, "check_submind", `{"session_id":1}`); !strings.Contains(out, "not found") {
+		t.Errorf("other user's check = %s", out)
+	}
+	// Without a background runner, async spawning is refused
+	e.Spawner = &mockSpawner{}
+	if out, _ := e.Execute(ctx, "spawn_submind", `{"mode":"planning","task":"a","async":true}`); !strings.Contains(out, "not available") {
+		t.Errorf("async without runner = %s", out)
+	}
+}
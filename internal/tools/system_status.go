@@ -6,10 +6,15 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/creditmon"
+	"github.com/hattiebot/hattiebot/internal/errbudget"
 	"github.com/hattiebot/hattiebot/internal/gateway"
 	"github.com/hattiebot/hattiebot/internal/health"
 	"github.com/hattiebot/hattiebot/internal/memory"
+	"github.com/hattiebot/hattiebot/internal/onboarding"
 	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/reload"
 	"github.com/hattiebot/hattiebot/internal/store"
 )
 
@@ -22,9 +27,13 @@ type SystemStatus struct {
 	TokenBudget       string                            `json:"token_budget"`
 	RegisteredTools   []string                          `json:"registered_tools"`
 	ActiveChannels    []string                          `json:"active_channels"`
+	Health            string                            `json:"health"` // worst component status: ok, degraded or error
 	Components        map[string]health.ComponentHealth `json:"components"`
 	RecentErrors      []health.LogEntry                 `json:"recent_errors,omitempty"`
 	LastReflection    time.Time                         `json:"last_reflection,omitempty"`
+	Onboarding        *onboarding.Status                `json:"onboarding,omitempty"`
+	ErrorBudget       *errbudget.Status                 `json:"error_budget,omitempty"`
+	Credits           *creditmon.Status                 `json:"credits,omitempty"`
 }
 
 // SystemStatusGatherer collects system status from various components.
@@ -36,6 +45,29 @@ type SystemStatusGatherer struct {
 	Client       *openrouter.Client
 	HealthReg    *health.Registry
 	TokenBudget  int
+	Config       *config.Config // For onboarding checklist detection
+	ErrorBudget  *errbudget.Budget
+	Credits      *creditmon.Monitor
+}
+
+// StatusGatherer returns a gatherer for the executor's components, as used by system_status.
+func (e *Executor) StatusGatherer() *SystemStatusGatherer {
+	c := e.Client
+	if s, ok := c.(*reload.LLMClient); ok {
+		c = s.Current()
+	}
+	client, _ := c.(*openrouter.Client)
+	return &SystemStatusGatherer{
+		DB:          e.DB,
+		LogStore:    e.LogStore,
+		Gateway:     e.Gateway,
+		Client:      client,
+		HealthReg:   e.HealthReg,
+		TokenBudget: e.TokenBudget,
+		Config:      e.Config,
+		ErrorBudget: e.ErrorBudget,
+		Credits:     e.Credits,
+	}
 }
 
 // Gather collects comprehensive system status.
@@ -104,6 +136,25 @@ func (g *SystemStatusGatherer) Gather(ctx context.Context) (SystemStatus, error)
 		}
 	}
 
+	if g.ErrorBudget != nil {
+		st := g.ErrorBudget.Status()
+		status.ErrorBudget = &st
+		status.Components["error_budget"] = g.ErrorBudget.HealthCheck()
+	}
+	if g.Credits != nil {
+		status.Credits = g.Credits.Status()
+		status.Components["credits"] = g.Credits.HealthCheck()
+	}
+	status.Health = health.Overall(status.Components)
+
+	// Setup checklist
+	if g.DB != nil {
+		if items, err := onboarding.Refresh(ctx, g.DB, g.Config, status.ActiveChannels); err == nil {
+			st := onboarding.Summarize(items)
+			status.Onboarding = &st
+		}
+	}
+
 	return status, nil
 }
 
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/channels/nextcloudtalk"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// talkChannel is the part of the Nextcloud Talk channel talk_actions uses.
+type talkChannel interface {
+	SendMessage(roomToken, content string, replyTo int64, mentions []string) (string, error)
+	CreatePoll(roomToken, question string, options []string, hidden bool, maxVotes int) (*nextcloudtalk.Poll, error)
+	GetPoll(roomToken string, pollID int64) (*nextcloudtalk.Poll, error)
+	ClosePoll(roomToken string, pollID int64) (*nextcloudtalk.Poll, error)
+}
+
+// TalkActionsTool uses Nextcloud Talk features beyond plain replies: quoting and mentioning in the
+// reply that ends this turn (reply_options), sending a message that quotes or mentions, and polls.
+// The room defaults to the current conversation; other rooms need an admin.
+func TalkActionsTool(ctx context.Context, gw *gateway.Gateway, argsJSON string) (string, error) {
+	var args struct {
+		Action   string   `json:"action"`
+		Room     string   `json:"room"`
+		Message  string   `json:"message"`
+		ReplyTo  int64    `json:"reply_to"`
+		Quote    *bool    `json:"quote"`
+		Mentions []string `json:"mentions"`
+		Question string   `json:"question"`
+		Options  []string `json:"options"`
+		Hidden   bool     `json:"hidden"`
+		MaxVotes int      `json:"max_votes"`
+		PollID   int64    `json:"poll_id"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	msg, inTurn := gateway.MessageFromContext(ctx)
+	inTalk := inTurn && msg.Channel == nextcloudtalk.ChannelName
+
+	if args.Action == "reply_options" {
+		opts, ok := gateway.ReplyOptionsFromContext(ctx)
+		if !ok || !inTalk || msg.Autonomous {
+			return ErrJSON(fmt.Errorf("reply_options only applies while answering a Talk message; use send instead")), nil
+		}
+		if args.Quote != nil {
+			opts.Quote = *args.Quote
+		}
+		if args.Mentions != nil {
+			opts.Mentions = args.Mentions
+		}
+		b, _ := json.Marshal(map[string]interface{}{"status": "set", "quote": opts.Quote, "mentions": opts.Mentions})
+		return string(b), nil
+	}
+
+	if gw == nil {
+		return ErrJSON(fmt.Errorf("gateway not configured")), nil
+	}
+	ch, ok := gw.ChannelByName(nextcloudtalk.ChannelName)
+	talk, isTalk := ch.(talkChannel)
+	if !ok || !isTalk {
+		return ErrJSON(fmt.Errorf("Nextcloud Talk is not configured")), nil
+	}
+	room := strings.TrimSpace(args.Room)
+	if inTalk && (room == "" || room == msg.ThreadID) {
+		room = msg.ThreadID
+	} else if room == "" {
+		return ErrJSON(fmt.Errorf("room is required outside a Talk conversation")), nil
+	} else if role, _ := ctx.Value("user_role").(string); !store.RoleAtLeast(role, store.RoleAdmin) {
+		return ErrJSON(fmt.Errorf("only admins can act in other rooms")), nil
+	}
+
+	var out interface{}
+	switch args.Action {
+	case "send":
+		if strings.TrimSpace(args.Message) == "" {
+			return ErrJSON(fmt.Errorf("message is required")), nil
+		}
+		id, err := talk.SendMessage(room, args.Message, args.ReplyTo, args.Mentions)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		out = map[string]interface{}{"status": "sent", "room": room, "message_id": id}
+	case "create_poll":
+		p, err := talk.CreatePoll(room, args.Question, args.Options, args.Hidden, args.MaxVotes)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		out = p
+	case "get_poll", "close_poll":
+		if args.PollID == 0 {
+			return ErrJSON(fmt.Errorf("poll_id is required")), nil
+		}
+		get := talk.GetPoll
+		if args.Action == "close_poll" {
+			get = talk.ClosePoll
+		}
+		p, err := get(room, args.PollID)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		out = p
+	default:
+		return ErrJSON(fmt.Errorf("unknown action: %s (use reply_options, send, create_poll, get_poll, close_poll)", args.Action)), nil
+	}
+	b, _ := json.Marshal(out)
+	return string(b), nil
+}
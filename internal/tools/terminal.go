@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"time"
+
+	"github.com/hattiebot/hattiebot/internal/sandbox"
 )
 
 // RunTerminal runs a shell command in the given working directory and returns stdout, stderr, and exit code.
@@ -89,3 +91,65 @@ func RunTerminalTool(ctx context.Context, workDirDefault string, argsJSON string
 	raw, _ := json.Marshal(out)
 	return string(raw), nil
 }
+
+// RunSandboxedTerminalTool runs run_terminal_cmd under the sandbox profile for the caller's trust
+// level (from ctx). Profiles with the "none" backend run exactly like RunTerminalTool.
+func RunSandboxedTerminalTool(ctx context.Context, workspace string, sb *sandbox.Config, argsJSON string) (string, error) {
+	trust, _ := ctx.Value("user_trust").(string)
+	name, profile := sb.ForTrust(trust)
+	if profile.BackendName() == sandbox.BackendNone {
+		return RunTerminalTool(ctx, workspace, argsJSON)
+	}
+	var args struct {
+		WorkDir string            `json:"work_dir"`
+		Command string            `json:"command"`
+		EnvVars map[string]string `json:"env_vars"`
+	}
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", err
+		}
+	}
+	if args.Command == "" {
+		out, _ := json.Marshal(map[string]interface{}{"error": "command is required", "stdout": "", "stderr": "", "exit_code": -1})
+		return string(out), nil
+	}
+	userID, _ := ctx.Value("user_id").(string)
+	req := sandbox.Request{Workspace: workspace, WorkDir: args.WorkDir, Command: args.Command, Env: args.EnvVars, UserID: userID}
+	workDir, err := profile.Check(req)
+	if err != nil {
+		out, _ := json.Marshal(map[string]interface{}{"error": err.Error(), "sandbox": name, "exit_code": -1})
+		return string(out), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, profile.Timeout(5*time.Minute))
+	defer cancel()
+	cmd, err := profile.Command(ctx, req, workDir)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	code := 0
+	if runErr := cmd.Run(); runErr != nil {
+		if exit, ok := runErr.(*exec.ExitError); ok {
+			code = exit.ExitCode()
+		} else {
+			code = -1
+			errBuf.WriteString(runErr.Error())
+		}
+	}
+	out := map[string]interface{}{
+		"stdout":    outBuf.String(),
+		"stderr":    errBuf.String(),
+		"exit_code": code,
+		"sandbox":   name,
+		"backend":   profile.BackendName(),
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		out["error"] = fmt.Sprintf("sandbox time limit (%s) exceeded", profile.Timeout(5*time.Minute))
+	}
+	raw, _ := json.Marshal(out)
+	return string(raw), nil
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/openrouter"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// Sub-mind allowed_tools entries:
+//
+//	read_file            one built-in tool
+//	nextcloud_*          built-in tools matching a glob (path.Match syntax)
+//	registered:*         registered tools matching a glob, run through execute_registered_tool
+//	inherit              every built-in tool the spawning user's role may run (the policy matrix)
+//	!run_terminal_cmd    exclude tools matching a name or glob (also "!registered:name")
+//
+// Tools in BlockedTools are never allowed, whatever the patterns say.
+const (
+	registeredPrefix = "registered:"
+	inheritEntry     = "inherit"
+)
+
+// ToolAllowlist is a compiled sub-mind allowed_tools list.
+type ToolAllowlist struct {
+	builtin    []string // names and globs
+	registered []string // globs over registered tool names
+	excluded   []string // globs; "registered:" entries apply to registered tools
+	inherit    bool
+}
+
+// CompileToolAllowlist compiles allowed_tools entries. Invalid globs never match; use
+// ValidateAllowedTools to reject them up front.
+func CompileToolAllowlist(entries []string) *ToolAllowlist {
+	a := &ToolAllowlist{}
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		switch {
+		case e == "":
+		case e == inheritEntry:
+			a.inherit = true
+		case strings.HasPrefix(e, "!"):
+			a.excluded = append(a.excluded, strings.TrimSpace(e[1:]))
+		case strings.HasPrefix(e, registeredPrefix):
+			a.registered = append(a.registered, strings.TrimPrefix(e, registeredPrefix))
+		default:
+			a.builtin = append(a.builtin, e)
+		}
+	}
+	return a
+}
+
+// ValidateAllowedTools checks allowed_tools entries: globs must be well formed and blocked tools may
+// not be named explicitly (patterns may match them; they are filtered out at run time).
+func ValidateAllowedTools(entries []string) error {
+	for _, e := range entries {
+		pattern := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(e), "!"), registeredPrefix)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid tool pattern %q: %w", e, err)
+		}
+		if BlockedTools[strings.TrimSpace(e)] {
+			return fmt.Errorf("cannot grant blocked tool: %s", e)
+		}
+	}
+	return nil
+}
+
+// AllowsBuiltin reports whether a built-in tool with the given policy may run for a parent with
+// role ("" = internal caller, which inherits everything).
+func (a *ToolAllowlist) AllowsBuiltin(name, policy, role string) bool {
+	if BlockedTools[name] || matchAny(name, a.excluded) {
+		return false
+	}
+	if matchAny(name, a.builtin) {
+		return true
+	}
+	if !a.inherit {
+		return false
+	}
+	if min, gated := store.PolicyMinRole[policy]; gated && role != "" {
+		return store.RoleAtLeast(role, min)
+	}
+	return true
+}
+
+// AllowsRegistered reports whether a registered tool may run. Allowing execute_registered_tool
+// itself (by name, glob, or inherit) allows every registered tool, as before patterns existed.
+func (a *ToolAllowlist) AllowsRegistered(name string) bool {
+	for _, ex := range a.excluded {
+		if p, ok := strings.CutPrefix(ex, registeredPrefix); ok && globMatch(p, name) {
+			return false
+		}
+	}
+	return matchAny(name, a.registered) || a.allowsAllRegistered()
+}
+
+// HasRegistered reports whether any registered tool can be allowed.
+func (a *ToolAllowlist) HasRegistered() bool {
+	return len(a.registered) > 0 || a.allowsAllRegistered()
+}
+
+func (a *ToolAllowlist) allowsAllRegistered() bool {
+	const name = "execute_registered_tool"
+	return !matchAny(name, a.excluded) && (matchAny(name, a.builtin) || a.inherit)
+}
+
+// FilterDefs returns the built-in definitions the sub-mind may see. execute_registered_tool is
+// included when registered tools are allowed.
+func (a *ToolAllowlist) FilterDefs(all []openrouter.ToolDefinition, role string) []openrouter.ToolDefinition {
+	var out []openrouter.ToolDefinition
+	for _, td := range all {
+		name := td.Function.Name
+		if a.AllowsBuiltin(name, td.Policy, role) || (name == "execute_registered_tool" && a.HasRegistered()) {
+			out = append(out, td)
+		}
+	}
+	return out
+}
+
+func matchAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if !strings.HasPrefix(p, registeredPrefix) && globMatch(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func globMatch(pattern, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestToolAllowlist_builtin(t *testing.T) {
+	tests := []struct {
+		entries      []string
+		name, policy string
+		role         string
+		want         bool
+	}{
+		{[]string{"read_file"}, "read_file", "safe", "user", true},
+		{[]string{"read_file"}, "write_file", "safe", "user", false},
+		{[]string{"manage_*"}, "manage_trust", "owner_only", "user", true},
+		{[]string{"manage_*", "!manage_trust"}, "manage_trust", "owner_only", "owner", false},
+		{[]string{"inherit"}, "memorize", "safe", "user", true},
+		{[]string{"inherit"}, "list_users", "admin_only", "user", false},
+		{[]string{"inherit"}, "list_users", "admin_only", "admin", true},
+		{[]string{"inherit"}, "add_admin", "owner_only", "admin", false},
+		{[]string{"inherit"}, "run_terminal_cmd", "restricted", "operator", false},
+		{[]string{"inherit"}, "add_admin", "owner_only", "", true},
+		{[]string{"inherit", "!run_*"}, "run_terminal_cmd", "restricted", "owner", false},
+		{[]string{"*"}, "spawn_submind", "", "owner", false},
+		{[]string{"inherit"}, "manage_submind", "", "owner", false},
+	}
+	for _, tt := range tests {
+		a := CompileToolAllowlist(tt.entries)
+		if got := a.AllowsBuiltin(tt.name, tt.policy, tt.role); got != tt.want {
+			t.Errorf("%v: AllowsBuiltin(%s, %s, %q) = %v, want %v", tt.entries, tt.name, tt.policy, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestToolAllowlist_registered(t *testing.T) {
+	tests := []struct {
+		entries []string
+		name    string
+		want    bool
+	}{
+		{[]string{"registered:*"}, "weather", true},
+		{[]string{"registered:nextcloud_*"}, "nextcloud_files", true},
+		{[]string{"registered:nextcloud_*"}, "weather", false},
+		{[]string{"registered:*", "!registered:weather"}, "weather", false},
+		{[]string{"execute_registered_tool"}, "weather", true},
+		{[]string{"inherit", "!registered:weather"}, "weather", false},
+		{[]string{"inherit", "!execute_registered_tool"}, "weather", false},
+		{[]string{"read_file"}, "weather", false},
+		{[]string{"weather"}, "weather", false}, // bare names refer to built-in tools
+	}
+	for _, tt := range tests {
+		if got := CompileToolAllowlist(tt.entries).AllowsRegistered(tt.name); got != tt.want {
+			t.Errorf("%v: AllowsRegistered(%s) = %v, want %v", tt.entries, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestToolAllowlist_FilterDefs(t *testing.T) {
+	names := func(entries []string, role string) map[string]bool {
+		out := map[string]bool{}
+		for _, d := range CompileToolAllowlist(entries).FilterDefs(BuiltinToolDefs(), role) {
+			out[d.Function.Name] = true
+		}
+		return out
+	}
+	got := names([]string{"registered:weather"}, "user")
+	if len(got) != 1 || !got["execute_registered_tool"] {
+		t.Errorf("registered-only allowlist should expose just execute_registered_tool, got %v", got)
+	}
+	got = names([]string{"inherit", "!run_terminal_cmd"}, "owner")
+	if got["run_terminal_cmd"] || got["spawn_submind"] || got["manage_submind"] || !got["add_admin"] || !got["read_file"] {
+		t.Errorf("inherit for owner = %v", got)
+	}
+	if got = names([]string{"inherit"}, "user"); got["add_admin"] || got["list_users"] || !got["memorize"] {
+		t.Errorf("inherit for user = %v", got)
+	}
+}
+
+func TestValidateAllowedTools(t *testing.T) {
+	if err := ValidateAllowedTools([]string{"read_file", "manage_*", "registered:*", "!registered:x", "inherit", "!run_*"}); err != nil {
+		t.Errorf("valid entries rejected: %v", err)
+	}
+	for _, entries := range [][]string{{"spawn_submind"}, {"manage_submind"}, {"nextcloud_["}, {"registered:[a"}, {"!["}} {
+		if err := ValidateAllowedTools(entries); err == nil {
+			t.Errorf("ValidateAllowedTools(%v) should fail", entries)
+		}
+	}
+}
+
+type fakeToolRegistry struct {
+	tools map[string]store.RegisteredTool
+}
+
+func (r *fakeToolRegistry) InsertTool(ctx context.Context, name, binaryPath, description, inputSchema string) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeToolRegistry) ToolByName(ctx context.Context, name string) (*store.RegisteredTool, error) {
+	if t, ok := r.tools[name]; ok {
+		return &t, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeToolRegistry) AllTools(ctx context.Context) ([]store.RegisteredTool, error) {
+	var out []store.RegisteredTool
+	for _, t := range r.tools {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (r *fakeToolRegistry) DeleteTool(ctx context.Context, name string) error { return nil }
+
+type recordingExecutor struct {
+	MockExecutor
+	args string
+}
+
+func (m *recordingExecutor) Execute(ctx context.Context, name, args string) (string, error) {
+	m.args = args
+	return m.MockExecutor.Execute(ctx, name, args)
+}
+
+func TestFilteredExecutor_registeredTools(t *testing.T) {
+	mock := &recordingExecutor{}
+	f := NewFilteredExecutor(mock, []string{"registered:nextcloud_*", "!registered:nextcloud_admin"})
+	f.Registry = &fakeToolRegistry{tools: map[string]store.RegisteredTool{
+		"nextcloud_files": {Name: "nextcloud_files"},
+		"nextcloud_admin": {Name: "nextcloud_admin"},
+		"weather":         {Name: "weather"},
+	}}
+	ctx := context.Background()
+
+	resp, _ := f.Execute(ctx, "execute_registered_tool", `{"name": "nextcloud_files", "args": {}}`)
+	if resp != "ok" || mock.CalledWith != "execute_registered_tool" {
+		t.Errorf("allowed registered tool: resp %s, called %s", resp, mock.CalledWith)
+	}
+	for _, name := range []string{"nextcloud_admin", "weather"} {
+		mock.CalledWith = ""
+		resp, _ = f.Execute(ctx, "execute_registered_tool", `{"name": "`+name+`"}`)
+		if !strings.Contains(resp, "error") || mock.CalledWith != "" {
+			t.Errorf("%s should be refused, got %s", name, resp)
+		}
+	}
+
+	// Direct calls by name are wrapped into execute_registered_tool.
+	mock.CalledWith = ""
+	resp, _ = f.Execute(ctx, "nextcloud_files", `{"path": "/"}`)
+	if resp != "ok" || mock.CalledWith != "execute_registered_tool" || !strings.Contains(mock.args, `"name":"nextcloud_files"`) || !strings.Contains(mock.args, `"path":"/"`) {
+		t.Errorf("direct call: resp %s, called %s with %s", resp, mock.CalledWith, mock.args)
+	}
+
+	// Built-in tools are not granted by registered patterns.
+	mock.CalledWith = ""
+	if resp, _ = f.Execute(ctx, "read_file", `{}`); !strings.Contains(resp, "error") || mock.CalledWith != "" {
+		t.Errorf("read_file should be refused, got %s", resp)
+	}
+}
+
+func TestFilteredExecutor_inheritUsesRole(t *testing.T) {
+	mock := &MockExecutor{}
+	f := NewFilteredExecutor(mock, []string{"inherit"})
+	userCtx := context.WithValue(context.Background(), "user_role", "user")
+	if resp, _ := f.Execute(userCtx, "list_users", "{}"); !strings.Contains(resp, "error") {
+		t.Errorf("user should not inherit admin tools, got %s", resp)
+	}
+	adminCtx := context.WithValue(context.Background(), "user_role", "admin")
+	if resp, _ := f.Execute(adminCtx, "list_users", "{}"); resp != "ok" {
+		t.Errorf("admin should inherit list_users, got %s", resp)
+	}
+	if resp, _ := f.Execute(adminCtx, "spawn_submind", "{}"); !strings.Contains(resp, "error") {
+		t.Errorf("blocked tools are never inherited, got %s", resp)
+	}
+}
@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hattiebot/hattiebot/internal/toolcheck"
+)
+
+// toolSourceDir finds the Go source of a tool: the given source_dir, $CONFIG_DIR/tools/<name> (where
+// the build instructions put it), or the binary's own directory. Returns "" if none has .go files.
+func (e *Executor) toolSourceDir(name, binaryPath, sourceDir string) string {
+	var candidates []string
+	if sourceDir != "" {
+		if !filepath.IsAbs(sourceDir) && e.WorkspaceDir != "" {
+			sourceDir = filepath.Join(e.WorkspaceDir, filepath.Clean(sourceDir))
+		}
+		candidates = append(candidates, sourceDir)
+	}
+	if e.ConfigDir != "" {
+		candidates = append(candidates, filepath.Join(e.ConfigDir, "tools", name), filepath.Join(e.ConfigDir, "tools", filepath.Base(binaryPath)))
+	}
+	candidates = append(candidates, filepath.Dir(binaryPath))
+	for _, dir := range candidates {
+		if m, _ := filepath.Glob(filepath.Join(dir, "*.go")); len(m) > 0 {
+			return dir
+		}
+	}
+	return ""
+}
+
+// checkToolSource runs the static safety check on a tool's source. The report is nil when no Go
+// source was found (the tool may not be written in Go); the error is set when registration must
+// be refused.
+func (e *Executor) checkToolSource(ctx context.Context, name, binaryPath, sourceDir string) (*toolcheck.Report, error) {
+	dir := e.toolSourceDir(name, binaryPath, sourceDir)
+	if dir == "" {
+		if sourceDir != "" {
+			return nil, fmt.Errorf("no Go source files in source_dir %s", sourceDir)
+		}
+		return nil, nil
+	}
+	opts := toolcheck.Options{}
+	if e.Egress != nil {
+		opts.HostAllowed = e.Egress.Policy().Check
+	}
+	report, err := toolcheck.Check(ctx, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	if report.Blocked {
+		return report, fmt.Errorf("static safety check refused %s: %s", name, report.Summary())
+	}
+	return report, nil
+}
@@ -0,0 +1,345 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// defaultToolVersionsKept is how many previous versions of a tool are kept when config does not say.
+const defaultToolVersionsKept = 3
+
+// maxStoredSourceBytes caps the source stored per tool version; larger sources keep only the path,
+// hash, and git commit.
+const maxStoredSourceBytes = 512 << 10
+
+func (e *Executor) toolVersionsKept() int {
+	if e.Config != nil && e.Config.ToolVersionsKept > 0 {
+		return e.Config.ToolVersionsKept
+	}
+	return defaultToolVersionsKept
+}
+
+// toolSourceHash hashes the tool's Go source files, or the binary when no source is found, so
+// versions can be told apart even when the binary is rebuilt in place.
+func (e *Executor) toolSourceHash(name, binaryPath, sourceDir string) string {
+	h := sha256.New()
+	files := []string{binaryPath}
+	if dir := e.toolSourceDir(name, binaryPath, sourceDir); dir != "" {
+		files, _ = filepath.Glob(filepath.Join(dir, "*.go"))
+		sort.Strings(files)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return ""
+		}
+		fmt.Fprintf(h, "%s\x00", filepath.Base(f))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// archiveToolBinary copies a tool binary to $CONFIG_DIR/tools/.versions/<name>/v<version>/ so a
+// later build cannot overwrite it. Without a config dir the binary is used in place.
+func (e *Executor) archiveToolBinary(name string, version int, binaryPath string) (string, error) {
+	if e.ConfigDir == "" {
+		return binaryPath, nil
+	}
+	dir := e.toolVersionDir(name, version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, filepath.Base(binaryPath))
+	src, err := os.Open(binaryPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return "", err
+	}
+	return dst, out.Close()
+}
+
+func (e *Executor) toolVersionDir(name string, version int) string {
+	return filepath.Join(e.ConfigDir, "tools", ".versions", filepath.Base(name), fmt.Sprintf("v%d", version))
+}
+
+// registerToolVersion records binaryPath as the next version of a tool, inserting the registry
+// row for new tools. The previous version's archived binary is kept for rollback and versions
+// beyond the retention limit are deleted. Returns the new version number.
+func (e *Executor) registerToolVersion(ctx context.Context, existing *store.RegisteredTool, name, binaryPath, storedPath, description, inputSchema, sourceDir string) (int, error) {
+	latest, err := e.DB.LatestToolVersion(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	previous := ""
+	if existing != nil {
+		if cur, err := e.DB.ToolVersionByNumber(ctx, name, existing.Version); err != nil {
+			return 0, err
+		} else if cur != nil {
+			previous = cur.BinaryPath
+		} else if e.resolveBinary(existing.BinaryPath) != binaryPath {
+			// Registered before versioning: archive the old binary now, unless the new build replaced it
+			if archived, err := e.archiveToolBinary(name, existing.Version, e.resolveBinary(existing.BinaryPath)); err == nil {
+				previous = archived
+				_ = e.DB.AddToolVersion(ctx, store.ToolVersion{Name: name, Version: existing.Version, BinaryPath: archived, SourceHash: existing.SourceHash, Description: existing.Description, InputSchema: existing.InputSchema})
+			}
+		}
+		if existing.Version > latest {
+			latest = existing.Version
+		}
+	}
+	v := store.ToolVersion{Name: name, Version: latest + 1, Description: description, InputSchema: inputSchema, SourceHash: e.toolSourceHash(name, binaryPath, sourceDir)}
+	e.collectToolSource(ctx, &v, binaryPath, sourceDir)
+	archived, err := e.archiveToolBinary(name, v.Version, binaryPath)
+	if err != nil {
+		return 0, fmt.Errorf("archiving tool binary: %w", err)
+	}
+	v.BinaryPath = archived
+	if err := e.DB.AddToolVersion(ctx, v); err != nil {
+		return 0, err
+	}
+	if existing == nil {
+		if _, err := e.DB.InsertTool(ctx, name, storedPath, description, inputSchema); err != nil {
+			return 0, err
+		}
+	}
+	// The registry keeps the path the tool was registered with; the archive is the rollback copy
+	v.BinaryPath = storedPath
+	if err := e.DB.SetToolVersion(ctx, v, previous); err != nil {
+		return 0, err
+	}
+	e.pruneToolVersions(ctx, name, v.Version)
+	return v.Version, nil
+}
+
+// collectToolSource records where a tool's source came from and, when it fits, the source itself,
+// so the exact code of a version can be read after the workspace copy is changed or deleted.
+func (e *Executor) collectToolSource(ctx context.Context, v *store.ToolVersion, binaryPath, sourceDir string) {
+	dir := e.toolSourceDir(v.Name, binaryPath, sourceDir)
+	if dir == "" {
+		return
+	}
+	v.SourceDir = dir
+	v.GitCommit, v.GitDirty = gitProvenance(ctx, dir)
+	files, _ := readSourceFiles(dir)
+	total := 0
+	for _, f := range files {
+		total += len(f.Content)
+	}
+	if total <= maxStoredSourceBytes {
+		v.Source = files
+	}
+}
+
+// readSourceFiles reads the Go files and go.mod of a source directory, sorted by name.
+func readSourceFiles(dir string) ([]store.SourceFile, error) {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		paths = append(paths, filepath.Join(dir, "go.mod"))
+	}
+	sort.Strings(paths)
+	var files []store.SourceFile
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return files, err
+		}
+		files = append(files, store.SourceFile{Name: filepath.Base(p), Content: string(data)})
+	}
+	return files, nil
+}
+
+// gitProvenance returns the HEAD commit of the git repository containing dir ("" if none) and
+// whether dir has uncommitted changes.
+func gitProvenance(ctx context.Context, dir string) (string, bool) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", false
+	}
+	commit := strings.TrimSpace(string(out))
+	status, err := exec.CommandContext(ctx, "git", "-C", dir, "status", "--porcelain", "--", ".").Output()
+	return commit, err == nil && len(strings.TrimSpace(string(status))) > 0
+}
+
+// readToolSource returns the source of a tool version (default: the current one) as stored at
+// registration. Versions without stored source fall back to the source directory on disk.
+func (e *Executor) readToolSource(ctx context.Context, name string, version int, file string) (string, error) {
+	t, err := e.DB.ToolByName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if t == nil {
+		return "", fmt.Errorf("tool %s not found", name)
+	}
+	if version == 0 {
+		version = t.Version
+	}
+	v, files, origin, err := e.toolSourceFiles(ctx, t, version)
+	if err != nil {
+		return "", err
+	}
+	if file != "" {
+		var picked []store.SourceFile
+		for _, f := range files {
+			if f.Name == file {
+				picked = append(picked, f)
+			}
+		}
+		if len(picked) == 0 {
+			return "", fmt.Errorf("%s version %d has no file %s", name, version, file)
+		}
+		files = picked
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"name": name, "version": version, "current_version": t.Version, "source_dir": v.SourceDir,
+		"git_commit": v.GitCommit, "git_dirty": v.GitDirty, "source_hash": v.SourceHash, "origin": origin, "files": files,
+	})
+	return string(b), nil
+}
+
+// toolSourceFiles loads a tool version's source files, stored or (for versions registered without
+// stored source) read from its source directory, and describes where they came from.
+func (e *Executor) toolSourceFiles(ctx context.Context, t *store.RegisteredTool, version int) (*store.ToolVersion, []store.SourceFile, string, error) {
+	name := t.Name
+	v, err := e.DB.ToolVersionByNumber(ctx, name, version)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if v == nil {
+		if version != t.Version {
+			return nil, nil, "", fmt.Errorf("tool %s has no version %d (see register_tool action=list_versions)", name, version)
+		}
+		v = &store.ToolVersion{Name: name, Version: version, SourceHash: t.SourceHash, Description: t.Description, InputSchema: t.InputSchema}
+	}
+	if v.HasSource {
+		return v, v.Source, "stored", nil
+	}
+	dir := v.SourceDir
+	if dir == "" {
+		dir = e.toolSourceDir(name, e.resolveBinary(t.BinaryPath), "")
+	}
+	if dir == "" {
+		return nil, nil, "", fmt.Errorf("no source stored or found for %s version %d", name, version)
+	}
+	files, err := readSourceFiles(dir)
+	if err != nil || len(files) == 0 {
+		return nil, nil, "", fmt.Errorf("no source stored for %s version %d and %s is unreadable", name, version, dir)
+	}
+	v.SourceDir = dir
+	origin := "disk (may differ from the registered build)"
+	if version != t.Version {
+		origin = "disk (current files; this version's source was not stored)"
+	}
+	return v, files, origin, nil
+}
+
+// rollbackTool switches a tool to an earlier (or any recorded) version after the version's binary
+// passes the contract test. version 0 means the newest version older than the current one.
+func (e *Executor) rollbackTool(ctx context.Context, name string, version int) (string, error) {
+	t, err := e.DB.ToolByName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if t == nil {
+		return "", fmt.Errorf("tool %s not found", name)
+	}
+	versions, err := e.DB.ListToolVersions(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	var target, current *store.ToolVersion
+	for i := range versions {
+		v := &versions[i]
+		if v.Version == t.Version {
+			current = v
+		}
+		if (version == 0 && v.Version < t.Version && target == nil) || (version != 0 && v.Version == version) {
+			target = v
+		}
+	}
+	if target == nil {
+		if version == 0 {
+			return "", fmt.Errorf("tool %s has no earlier version to roll back to", name)
+		}
+		return "", fmt.Errorf("tool %s has no version %d (see action=list_versions)", name, version)
+	}
+	if target.Version == t.Version {
+		return "", fmt.Errorf("tool %s is already at version %d", name, version)
+	}
+	stdout, _, code, runErr := ExecuteRegisteredTool(ctx, target.BinaryPath, "{}", withEgress(e.Egress, name, nil))
+	if runErr != nil {
+		return "", fmt.Errorf("version %d failed the contract test: %w", target.Version, runErr)
+	}
+	if !ValidateToolOutput(stdout, code) {
+		return "", fmt.Errorf("version %d failed the contract test: output was not valid JSON (exit_code=%d)", target.Version, code)
+	}
+	previous := ""
+	if current != nil {
+		previous = current.BinaryPath
+	}
+	if err := e.DB.SetToolVersion(ctx, *target, previous); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`{"status": "rolled_back", "name": %q, "from_version": %d, "version": %d}`, name, t.Version, target.Version), nil
+}
+
+// pruneToolVersions drops versions beyond the retention limit along with their archived binaries.
+func (e *Executor) pruneToolVersions(ctx context.Context, name string, current int) {
+	removed, err := e.DB.PruneToolVersions(ctx, name, current, e.toolVersionsKept())
+	if err != nil || e.ConfigDir == "" {
+		return
+	}
+	for _, v := range removed {
+		_ = os.RemoveAll(e.toolVersionDir(name, v.Version))
+	}
+}
+
+// removeToolArchive deletes all archived binaries of a deleted tool.
+func (e *Executor) removeToolArchive(name string) {
+	if e.ConfigDir == "" || name == "" {
+		return
+	}
+	_ = os.RemoveAll(filepath.Dir(e.toolVersionDir(name, 1)))
+}
+
+// resolveBinary makes a registered binary path absolute against the workspace.
+func (e *Executor) resolveBinary(binaryPath string) string {
+	if !filepath.IsAbs(binaryPath) && e.WorkspaceDir != "" {
+		return filepath.Join(e.WorkspaceDir, filepath.Clean(binaryPath))
+	}
+	return binaryPath
+}
+
+// rollbackHint adds a hint to a failing tool's result when the tool is broken and an earlier
+// version is available to roll back to.
+func (e *Executor) rollbackHint(ctx context.Context, name, result string) string {
+	t, err := e.DB.ToolByName(ctx, name)
+	if err != nil || t == nil || t.Status != "broken" || t.PreviousBinaryPath == "" {
+		return result
+	}
+	var out map[string]interface{}
+	if json.Unmarshal([]byte(result), &out) != nil {
+		return result
+	}
+	out["hint"] = fmt.Sprintf("%s has failed %d times since version %d; consider register_tool action=rollback to return to the previous version", name, t.FailureCount, t.Version)
+	b, _ := json.Marshal(out)
+	return string(b)
+}
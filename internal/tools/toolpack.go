@@ -0,0 +1,516 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// toolpackFormat identifies toolpack manifests so other JSON files are rejected on import.
+const toolpackFormat = "hattiebot-toolpack/v1"
+
+// toolpackManifest is the manifest file name inside a toolpack tarball.
+const toolpackManifest = "toolpack.json"
+
+// maxToolpackBytes bounds how much is read from a toolpack file (or from one tarball entry).
+const maxToolpackBytes = 16 << 20
+
+// toolNameRe matches names that are safe to use as directory and binary names.
+var toolNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// Toolpack is a bundle of registered tools with their source, shared between instances with
+// export_toolpack and import_toolpack. Tools are rebuilt from source on import.
+type Toolpack struct {
+	Format      string         `json:"format"`
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	ExportedAt  time.Time      `json:"exported_at"`
+	ExportedBy  string         `json:"exported_by,omitempty"`
+	Tools       []ToolpackTool `json:"tools"`
+}
+
+// ToolpackTool is one tool in a toolpack. In tarballs Files is empty in the manifest and the
+// files are stored as <name>/<file> entries.
+type ToolpackTool struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	InputSchema string             `json:"input_schema,omitempty"`
+	Version     int                `json:"version"`
+	SourceHash  string             `json:"source_hash,omitempty"`
+	GitCommit   string             `json:"git_commit,omitempty"`
+	Files       []store.SourceFile `json:"files,omitempty"`
+}
+
+// isTarball reports whether a toolpack path names a gzipped tarball rather than a JSON file.
+func isTarball(p string) bool {
+	return strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz")
+}
+
+// sourceFilesHash hashes source files the way toolSourceHash hashes a source directory, so a
+// pack's tools can be compared with registered ones.
+func sourceFilesHash(files []store.SourceFile) string {
+	var goFiles []store.SourceFile
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, ".go") {
+			goFiles = append(goFiles, f)
+		}
+	}
+	sort.Slice(goFiles, func(i, j int) bool { return goFiles[i].Name < goFiles[j].Name })
+	h := sha256.New()
+	for _, f := range goFiles {
+		fmt.Fprintf(h, "%s\x00", f.Name)
+		h.Write([]byte(f.Content))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// validToolpackFile reports whether a file name from a toolpack may be written to a source dir.
+func validToolpackFile(name string) bool {
+	return name == "go.mod" || (strings.HasSuffix(name, ".go") && name == filepath.Base(name) && !strings.HasPrefix(name, "."))
+}
+
+// ExportToolpackTool bundles registered tools (default: all) with their source, description,
+// schema, and version into a JSON or .tar.gz toolpack in the workspace.
+func (e *Executor) ExportToolpackTool(ctx context.Context, argsJSON string) (string, error) {
+	var args struct {
+		Tools       []string `json:"tools"`
+		Path        string   `json:"path"`
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	names := args.Tools
+	if len(names) == 0 {
+		all, err := e.DB.AllTools(ctx)
+		if err != nil {
+			return ErrJSON(err), nil
+		}
+		for _, t := range all {
+			if t.Status != "deprecated" {
+				names = append(names, t.Name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return ErrJSON(fmt.Errorf("no registered tools to export")), nil
+	}
+	pack := Toolpack{Format: toolpackFormat, Name: args.Name, Description: args.Description, ExportedAt: time.Now().UTC()}
+	if e.Config != nil {
+		pack.ExportedBy = e.Config.AgentName
+	}
+	var skipped []map[string]string
+	for _, name := range names {
+		t, err := e.DB.ToolByName(ctx, name)
+		if err == nil && t == nil {
+			err = fmt.Errorf("tool %s not found", name)
+		}
+		if err != nil {
+			skipped = append(skipped, map[string]string{"name": name, "error": err.Error()})
+			continue
+		}
+		if t.Kind == store.ToolKindHTTP {
+			skipped = append(skipped, map[string]string{"name": name, "error": "http tools have no source to export"})
+			continue
+		}
+		v, files, _, err := e.toolSourceFiles(ctx, t, t.Version)
+		if err != nil {
+			skipped = append(skipped, map[string]string{"name": name, "error": err.Error()})
+			continue
+		}
+		pack.Tools = append(pack.Tools, ToolpackTool{
+			Name: t.Name, Description: t.Description, InputSchema: t.InputSchema, Version: t.Version,
+			SourceHash: sourceFilesHash(files), GitCommit: v.GitCommit, Files: files,
+		})
+	}
+	if len(pack.Tools) == 0 {
+		b, _ := json.Marshal(map[string]interface{}{"error": "none of the tools could be exported", "skipped": skipped})
+		return string(b), nil
+	}
+
+	if args.Path == "" {
+		base := args.Name
+		if !toolNameRe.MatchString(base) {
+			base = "toolpack-" + time.Now().Format("20060102-150405")
+		}
+		args.Path = filepath.Join("toolpacks", base+".tar.gz")
+	}
+	out, err := workspacePath(e.WorkspaceDir, args.Path)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return ErrJSON(err), nil
+	}
+	if err := writeToolpack(out, pack); err != nil {
+		return ErrJSON(err), nil
+	}
+	exported := make([]map[string]interface{}, 0, len(pack.Tools))
+	for _, t := range pack.Tools {
+		exported = append(exported, map[string]interface{}{"name": t.Name, "version": t.Version, "files": len(t.Files)})
+	}
+	b, _ := json.Marshal(map[string]interface{}{"status": "exported", "path": args.Path, "tools": exported, "skipped": skipped})
+	return string(b), nil
+}
+
+// writeToolpack writes pack as indented JSON, or as a gzipped tarball when the path ends in
+// .tar.gz or .tgz.
+func writeToolpack(p string, pack Toolpack) error {
+	if !isTarball(p) {
+		b, err := json.MarshalIndent(pack, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(p, b, 0644)
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	add := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: pack.ExportedAt}); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	manifest := pack
+	manifest.Tools = make([]ToolpackTool, len(pack.Tools))
+	for i, t := range pack.Tools {
+		manifest.Tools[i] = t
+		manifest.Tools[i].Files = nil
+	}
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := add(toolpackManifest, b); err != nil {
+		return err
+	}
+	for _, t := range pack.Tools {
+		for _, f := range t.Files {
+			if err := add(path.Join(t.Name, f.Name), []byte(f.Content)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(p, buf.Bytes(), 0644)
+}
+
+// readToolpack reads a JSON or tarball toolpack and checks its format and file names.
+func readToolpack(p string) (*Toolpack, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var pack Toolpack
+	if !isTarball(p) {
+		data, err := io.ReadAll(io.LimitReader(f, maxToolpackBytes))
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &pack); err != nil {
+			return nil, fmt.Errorf("not a toolpack: %w", err)
+		}
+	} else {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("not a toolpack tarball: %w", err)
+		}
+		tr := tar.NewReader(io.LimitReader(gz, maxToolpackBytes))
+		files := map[string][]store.SourceFile{}
+		haveManifest := false
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading toolpack tarball: %w", err)
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if hdr.Name == toolpackManifest {
+				if err := json.Unmarshal(data, &pack); err != nil {
+					return nil, fmt.Errorf("invalid %s: %w", toolpackManifest, err)
+				}
+				haveManifest = true
+				continue
+			}
+			dir, file := path.Split(hdr.Name)
+			files[strings.TrimSuffix(dir, "/")] = append(files[strings.TrimSuffix(dir, "/")], store.SourceFile{Name: file, Content: string(data)})
+		}
+		if !haveManifest {
+			return nil, fmt.Errorf("not a toolpack tarball: no %s", toolpackManifest)
+		}
+		for i := range pack.Tools {
+			pack.Tools[i].Files = append(pack.Tools[i].Files, files[pack.Tools[i].Name]...)
+		}
+	}
+	if pack.Format != toolpackFormat {
+		return nil, fmt.Errorf("not a toolpack: format %q, want %q", pack.Format, toolpackFormat)
+	}
+	for _, t := range pack.Tools {
+		if !toolNameRe.MatchString(t.Name) {
+			return nil, fmt.Errorf("toolpack has an invalid tool name %q", t.Name)
+		}
+		for _, f := range t.Files {
+			if !validToolpackFile(f.Name) {
+				return nil, fmt.Errorf("toolpack tool %s has an invalid file name %q", t.Name, f.Name)
+			}
+		}
+	}
+	return &pack, nil
+}
+
+// ImportToolpackTool builds and registers the tools of a toolpack. Every tool goes through the
+// same safety check and contract test as register_tool; on_conflict decides what happens to tools
+// whose name is already registered with different source.
+func (e *Executor) ImportToolpackTool(ctx context.Context, argsJSON string) (string, error) {
+	trustLevel, ok := ctx.Value("user_trust").(string)
+	if !ok || trustLevel != "admin" {
+		return ErrJSON(fmt.Errorf("unauthorized: only admins can import toolpacks")), nil
+	}
+	var args struct {
+		Path       string   `json:"path"`
+		Tools      []string `json:"tools"`
+		OnConflict string   `json:"on_conflict"`
+		DryRun     bool     `json:"dry_run"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return ErrJSON(err), nil
+	}
+	if args.Path == "" {
+		return ErrJSON(fmt.Errorf("path is required")), nil
+	}
+	switch args.OnConflict {
+	case "":
+		args.OnConflict = "skip"
+	case "skip", "replace", "rename":
+	default:
+		return ErrJSON(fmt.Errorf("unknown on_conflict: %s (use skip, replace, or rename)", args.OnConflict)), nil
+	}
+	if e.ConfigDir == "" {
+		return ErrJSON(fmt.Errorf("importing toolpacks needs a config dir to build tools in")), nil
+	}
+	p, err := workspacePath(e.WorkspaceDir, args.Path)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	pack, err := readToolpack(p)
+	if err != nil {
+		return ErrJSON(err), nil
+	}
+	wanted := map[string]bool{}
+	for _, n := range args.Tools {
+		wanted[n] = true
+	}
+
+	var results []map[string]interface{}
+	for _, t := range pack.Tools {
+		if len(wanted) > 0 && !wanted[t.Name] {
+			continue
+		}
+		delete(wanted, t.Name)
+		res := map[string]interface{}{"name": t.Name}
+		results = append(results, res)
+		if len(t.Files) == 0 {
+			res["status"], res["error"] = "failed", "no source files in the toolpack"
+			continue
+		}
+		target := t.Name
+		existing, err := e.DB.ToolByName(ctx, target)
+		if err != nil {
+			res["status"], res["error"] = "failed", err.Error()
+			continue
+		}
+		status := "imported"
+		if existing != nil {
+			if existing.SourceHash != "" && existing.SourceHash == sourceFilesHash(t.Files) {
+				res["status"] = "unchanged"
+				continue
+			}
+			switch args.OnConflict {
+			case "skip":
+				res["status"] = "skipped"
+				res["reason"] = fmt.Sprintf("a tool named %s is already registered (version %d); use on_conflict=replace to register the pack's source as a new version or rename to keep both", target, existing.Version)
+				continue
+			case "replace":
+				status = "updated"
+			case "rename":
+				if target, err = e.freeToolName(ctx, t.Name); err != nil {
+					res["status"], res["error"] = "failed", err.Error()
+					continue
+				}
+				existing = nil
+				res["imported_as"] = target
+			}
+		}
+		if args.DryRun {
+			res["status"] = "would_be_" + status
+			continue
+		}
+		version, report, err := e.installToolpackTool(ctx, existing, target, t)
+		if report != nil {
+			res["safety_report"] = report
+		}
+		if err != nil {
+			res["status"], res["error"] = "failed", err.Error()
+			continue
+		}
+		res["status"], res["version"] = status, version
+	}
+	for n := range wanted {
+		results = append(results, map[string]interface{}{"name": n, "status": "failed", "error": "not in the toolpack"})
+	}
+	b, _ := json.Marshal(map[string]interface{}{"toolpack": pack.Name, "exported_by": pack.ExportedBy, "dry_run": args.DryRun, "on_conflict": args.OnConflict, "tools": results})
+	return string(b), nil
+}
+
+// freeToolName returns name with an "_imported" suffix (numbered if needed) that is not registered.
+func (e *Executor) freeToolName(ctx context.Context, name string) (string, error) {
+	for i := 1; i <= 100; i++ {
+		candidate := name + "_imported"
+		if i > 1 {
+			candidate = fmt.Sprintf("%s_imported_%d", name, i)
+		}
+		t, err := e.DB.ToolByName(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if t == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free name for %s", name)
+}
+
+// installToolpackTool builds a pack tool in a staging directory, runs the safety check and
+// contract test there, and only then moves the source to $CONFIG_DIR/tools/<name> and the binary
+// to the bin dir and registers it, so a failing import never touches an installed tool.
+func (e *Executor) installToolpackTool(ctx context.Context, existing *store.RegisteredTool, name string, t ToolpackTool) (int, interface{}, error) {
+	stage := filepath.Join(e.ConfigDir, "tools", ".import", name)
+	if err := os.RemoveAll(stage); err != nil {
+		return 0, nil, err
+	}
+	defer os.RemoveAll(stage)
+	if err := os.MkdirAll(stage, 0755); err != nil {
+		return 0, nil, err
+	}
+	for _, f := range t.Files {
+		if err := os.WriteFile(filepath.Join(stage, f.Name), []byte(f.Content), 0644); err != nil {
+			return 0, nil, err
+		}
+	}
+	stagedBin := filepath.Join(stage, name)
+	if err := buildToolSource(ctx, stage, stagedBin); err != nil {
+		return 0, nil, err
+	}
+	report, err := e.checkToolSource(ctx, name, stagedBin, stage)
+	if err != nil {
+		if report == nil {
+			return 0, nil, err
+		}
+		return 0, report, err
+	}
+	stdout, _, code, runErr := ExecuteRegisteredTool(ctx, stagedBin, "{}", withEgress(e.Egress, name, nil))
+	if runErr != nil {
+		return 0, report, fmt.Errorf("tool contract test failed: %w", runErr)
+	}
+	if !ValidateToolOutput(stdout, code) {
+		return 0, report, fmt.Errorf("tool failed contract test: output was not valid JSON (exit_code=%d)", code)
+	}
+
+	// Install: replace the Go files in the source dir (older versions stay readable from the
+	// store) and move the binary into place
+	srcDir := filepath.Join(e.ConfigDir, "tools", name)
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		return 0, report, err
+	}
+	old, _ := filepath.Glob(filepath.Join(srcDir, "*.go"))
+	for _, f := range append(old, filepath.Join(srcDir, "go.mod"), filepath.Join(srcDir, "go.sum")) {
+		_ = os.Remove(f)
+	}
+	files := t.Files
+	if data, err := os.ReadFile(filepath.Join(stage, "go.sum")); err == nil {
+		// Written by go build -mod=mod for modules with dependencies
+		files = append(files, store.SourceFile{Name: "go.sum", Content: string(data)})
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(srcDir, f.Name), []byte(f.Content), 0644); err != nil {
+			return 0, report, err
+		}
+	}
+	binDir := filepath.Join(e.ConfigDir, "bin")
+	if e.Config != nil && e.Config.BinDir != "" {
+		binDir = e.Config.BinDir
+	}
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return 0, report, err
+	}
+	binaryPath := filepath.Join(binDir, name)
+	if err := os.Rename(stagedBin, binaryPath); err != nil {
+		return 0, report, err
+	}
+	description := t.Description
+	if description == "" {
+		description = name
+	}
+	version, err := e.registerToolVersion(ctx, existing, name, binaryPath, binaryPath, description, t.InputSchema, srcDir)
+	return version, report, err
+}
+
+// buildToolSource compiles the Go tool in dir to out. Modules may fetch missing go.sum entries.
+func buildToolSource(ctx context.Context, dir, out string) error {
+	args := []string{"build", "-o", out}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		args = append(args, "-mod=mod", ".")
+	} else {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+		var srcs []string
+		for _, f := range files {
+			if !strings.HasSuffix(f, "_test.go") {
+				srcs = append(srcs, filepath.Base(f))
+			}
+		}
+		args = append(args, srcs...)
+	}
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(output))
+		if len(msg) > 2000 {
+			msg = msg[:2000] + "..."
+		}
+		return fmt.Errorf("go build failed: %v: %s", err, msg)
+	}
+	return nil
+}
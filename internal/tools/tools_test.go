@@ -3,12 +3,19 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/secrets"
 	"github.com/hattiebot/hattiebot/internal/store"
 )
 
@@ -116,7 +123,7 @@ func TestExecuteRegisteredTool_and_Registry(t *testing.T) {
 	}
 
 	// Execute via ExecuteRegisteredToolByName (workspaceDir "" since we used absolute path)
-	out, err := ExecuteRegisteredToolByName(ctx, db, "", "echo", `{"message":"hello"}`)
+	out, err := ExecuteRegisteredToolByName(ctx, db, "", "echo", `{"message":"hello"}`, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -136,12 +143,120 @@ func TestExecuteRegisteredTool_and_Registry(t *testing.T) {
 	}
 
 	// ExecuteRegisteredTool with unknown name
-	out2, _ := ExecuteRegisteredToolByName(ctx, db, "", "nonexistent", `{}`)
+	out2, _ := ExecuteRegisteredToolByName(ctx, db, "", "nonexistent", `{}`, nil)
 	var m2 map[string]string
 	_ = json.Unmarshal([]byte(out2), &m2)
 	if m2["error"] == "" {
 		t.Errorf("expected error for unknown tool")
 	}
+
+	// Args are checked against the registered input_schema before the binary runs
+	ex := &Executor{DB: db}
+	out3, _ := ex.Execute(ctx, "execute_registered_tool", `{"name": "echo", "args": {"message": 42}}`)
+	if !strings.Contains(out3, "message: expected string, got integer") || !strings.Contains(out3, `"input_schema"`) {
+		t.Errorf("invalid args: %s", out3)
+	}
+	if tool, _ := db.ToolByName(ctx, "echo"); tool.FailureCount != 0 {
+		t.Errorf("rejected args must not count as a tool failure, failure_count=%d", tool.FailureCount)
+	}
+	if out3, _ = ex.Execute(ctx, "execute_registered_tool", `{"name": "echo", "args": {"message": "hi"}}`); !strings.Contains(out3, "hi") {
+		t.Errorf("valid args: %s", out3)
+	}
+}
+
+func TestRegisterTool_http(t *testing.T) {
+	ctx := context.Background()
+	var gotAuth, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("X-API-Key")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		if strings.Contains(gotBody, "fail") {
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, "upstream down")
+			return
+		}
+		fmt.Fprint(w, `{"text": "recognized"}`)
+	}))
+	defer srv.Close()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	t.Setenv("OCR_TOKEN", "s3cret")
+	secretStore := secrets.NewMultiStore()
+	secretStore.Register("env", &secrets.EnvSecretStore{})
+	ex := &Executor{DB: db, SecretStore: secretStore}
+
+	register := func(extra string) string {
+		out, _ := ex.Execute(ctx, "register_tool", `{"name": "ocr", "type": "http", "url": "`+srv.URL+`", "description": "OCR service",
+			"input_schema": "{\"type\": \"object\", \"properties\": {\"image\": {\"type\": \"string\"}}, \"required\": [\"image\"]}"`+extra+`}`)
+		return out
+	}
+	if out := register(`, "auth_secret": "env:MISSING_TOKEN"`); !strings.Contains(out, "auth_secret") {
+		t.Errorf("unresolvable secret accepted: %s", out)
+	}
+	if out := register(`, "auth_secret": "env:OCR_TOKEN", "auth_header": "X-API-Key"`); !strings.Contains(out, "{secret}") {
+		t.Errorf("header without {secret} accepted: %s", out)
+	}
+	if out := register(`, "auth_secret": "env:OCR_TOKEN", "auth_header": "X-API-Key: {secret}"`); !strings.Contains(out, `"registered"`) {
+		t.Fatalf("register: %s", out)
+	}
+	if tool, _ := db.ToolByName(ctx, "ocr"); tool == nil || tool.Kind != store.ToolKindHTTP || tool.AuthSecret != "env:OCR_TOKEN" {
+		t.Fatalf("stored tool = %+v", tool)
+	}
+	if out := register(""); !strings.Contains(out, "already exists") {
+		t.Errorf("re-register without force_update: %s", out)
+	}
+
+	out, _ := ex.Execute(ctx, "execute_registered_tool", `{"name": "ocr", "args": {"image": "a.png"}}`)
+	if !strings.Contains(out, "recognized") || !strings.Contains(out, `"exit_code":0`) {
+		t.Errorf("execute: %s", out)
+	}
+	if gotAuth != "s3cret" || gotBody != `{"image": "a.png"}` {
+		t.Errorf("request: auth %q, body %q", gotAuth, gotBody)
+	}
+	if out, _ := ex.Execute(ctx, "execute_registered_tool", `{"name": "ocr", "args": {}}`); !strings.Contains(out, "image") {
+		t.Errorf("args not validated against the schema: %s", out)
+	}
+	out, _ = ex.Execute(ctx, "execute_registered_tool", `{"name": "ocr", "args": {"image": "fail"}}`)
+	if !strings.Contains(out, `"http_status":502`) {
+		t.Errorf("error status: %s", out)
+	}
+	if tool, _ := db.ToolByName(ctx, "ocr"); tool.FailureCount != 1 {
+		t.Errorf("failure_count = %d, want 1", tool.FailureCount)
+	}
+}
+
+func TestExecute_validates_builtin_args(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ex := &Executor{DB: db, WorkspaceDir: t.TempDir()}
+	out, _ := ex.Execute(ctx, "manage_schedule", `{"action": "remove", "limit": "5"}`)
+	var res struct {
+		Error            string `json:"error"`
+		ValidationErrors []struct {
+			Path    string `json:"path"`
+			Message string `json:"message"`
+		} `json:"validation_errors"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil || len(res.ValidationErrors) != 2 {
+		t.Fatalf("manage_schedule with bad args: %s", out)
+	}
+	if res.ValidationErrors[0].Path != "action" || res.ValidationErrors[1].Path != "limit" || !strings.Contains(res.Error, "invalid arguments for manage_schedule") {
+		t.Errorf("validation errors = %+v", res)
+	}
+	if out, _ = ex.Execute(ctx, "read_file", `{}`); !strings.Contains(out, "path: is required") {
+		t.Errorf("missing required arg: %s", out)
+	}
+	if out, _ = ex.Execute(ctx, "register_tool", `{"name": "x", "binary_path": "/bin/true", "description": "x", "input_schema": "{\"type\": "}`); !strings.Contains(out, "input_schema: invalid JSON Schema") {
+		t.Errorf("register_tool with a malformed schema: %s", out)
+	}
 }
 
 func TestRegisterTool_rejects_invalid_contract(t *testing.T) {
@@ -186,4 +301,471 @@ func main() { fmt.Fprint(os.Stdout, "not json") }
 	if tool != nil {
 		t.Error("tool should not be registered after contract failure")
 	}
-}
\ No newline at end of file
+}
+
+func TestRegisterTool_refuses_unsafe_source(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user_trust", "admin")
+	dir := t.TempDir()
+	src := "package main\n\nimport \"os\"\n\nfunc main() { os.RemoveAll(\"/\") }\n"
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	executor := &Executor{DB: db, WorkspaceDir: dir}
+	// The binary is never built: the source check runs before the contract test executes anything.
+	out, err := executor.Execute(ctx, "register_tool", `{"name":"wipe","binary_path":"`+filepath.Join(dir, "wipe")+`","description":"test"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		Error        string `json:"error"`
+		SafetyReport struct {
+			Blocked  bool `json:"blocked"`
+			Findings []struct {
+				Rule string `json:"rule"`
+			} `json:"findings"`
+		} `json:"safety_report"`
+	}
+	if err := json.Unmarshal([]byte(out), &m); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(m.Error, "static safety check") || !m.SafetyReport.Blocked || len(m.SafetyReport.Findings) == 0 || m.SafetyReport.Findings[0].Rule != "remove_system_path" {
+		t.Errorf("expected refusal with report, got %s", out)
+	}
+	if tool, _ := db.ToolByName(ctx, "wipe"); tool != nil {
+		t.Error("unsafe tool should not be registered")
+	}
+}
+
+func TestAddRemoveAdmin_owner_only(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, id := range []string{"owner", "bob"} {
+		if _, err := db.GetOrCreateUser(ctx, id, id, "admin_term"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.UpdateUserRole(ctx, "owner", store.RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	ownerCtx := context.WithValue(ctx, "user_role", store.RoleOwner)
+	adminCtx := context.WithValue(ctx, "user_role", store.RoleAdmin)
+
+	if _, err := AddAdmin(adminCtx, db, `{"user_id": "bob"}`); err == nil {
+		t.Fatal("admin should not be able to add admins")
+	}
+	if _, err := AddAdmin(ownerCtx, db, `{"user_id": "bob"}`); err != nil {
+		t.Fatal(err)
+	}
+	bob, _ := db.GetUser(ctx, "bob")
+	if bob.Role != store.RoleAdmin || bob.TrustLevel != "admin" {
+		t.Fatalf("bob = %s/%s, want admin/admin", bob.Role, bob.TrustLevel)
+	}
+	if _, err := RemoveAdmin(ownerCtx, db, `{"user_id": "owner"}`); err == nil {
+		t.Fatal("owner must not be removable")
+	}
+	if _, err := RemoveAdmin(ownerCtx, db, `{"user_id": "bob"}`); err != nil {
+		t.Fatal(err)
+	}
+	bob, _ = db.GetUser(ctx, "bob")
+	if bob.Role != store.RoleUser || bob.TrustLevel != "trusted" {
+		t.Fatalf("bob = %s/%s, want user/trusted", bob.Role, bob.TrustLevel)
+	}
+}
+
+func TestReportTaskResult_and_ScheduleHistory(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ex := &Executor{DB: db}
+	userCtx := context.WithValue(ctx, "user_id", "alice")
+	planID, err := db.CreatePlan(ctx, "alice", "file receipts", "agent_prompt", `{"prompt":"file receipts","autonomous":true}`, "daily", "09:00", "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out, _ := ex.Execute(userCtx, "report_task_result", `{"status": "succeeded", "summary": "done"}`); !strings.Contains(out, "error") {
+		t.Errorf("report outside a scheduled run should fail, got %s", out)
+	}
+	runID, err := db.StartPlanRun(ctx, planID, "alice", "scheduler:plan_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runCtx := context.WithValue(userCtx, "plan_run_id", runID)
+	if out, _ := ex.Execute(runCtx, "report_task_result", `{"status": "great", "summary": "done"}`); !strings.Contains(out, `status: must be one of`) {
+		t.Errorf("bad status: %s", out)
+	}
+	out, _ := ex.Execute(runCtx, "report_task_result", `{"status": "succeeded", "summary": "filed 3 receipts", "artifacts": ["/receipts/2026-03.pdf"], "next_suggested_run": "tomorrow morning"}`)
+	if !strings.Contains(out, "recorded") {
+		t.Fatalf("report: %s", out)
+	}
+
+	out, _ = ex.Execute(userCtx, "manage_schedule", fmt.Sprintf(`{"action": "history", "id": %d}`, planID))
+	var hist struct {
+		Plan store.ScheduledPlan `json:"plan"`
+		Runs []store.PlanRun     `json:"runs"`
+	}
+	if err := json.Unmarshal([]byte(out), &hist); err != nil {
+		t.Fatalf("history: %s", out)
+	}
+	if len(hist.Runs) != 1 || hist.Runs[0].Status != store.RunSucceeded || !hist.Runs[0].Reported || hist.Runs[0].NextSuggestedRun == nil || len(hist.Runs[0].Artifacts) != 1 {
+		t.Errorf("history runs = %+v", hist.Runs)
+	}
+	bobCtx := context.WithValue(ctx, "user_id", "bob")
+	if out, _ = ex.Execute(bobCtx, "manage_schedule", fmt.Sprintf(`{"action": "history", "id": %d}`, planID)); !strings.Contains(out, "not found") {
+		t.Errorf("other users must not see the history, got %s", out)
+	}
+}
+
+func TestRegisterTool_versions_and_rollback(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "versioned")
+	build := func(version int) string {
+		src := filepath.Join(dir, fmt.Sprintf("src%d", version))
+		if err := os.MkdirAll(src, 0755); err != nil {
+			t.Fatal(err)
+		}
+		code := fmt.Sprintf("package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Print(`{\"version\": %d}`) }\n", version)
+		if err := os.WriteFile(filepath.Join(src, "main.go"), []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Rebuilt in place, as the agent does, so only the archive keeps older builds
+		if out, err := exec.CommandContext(ctx, "go", "build", "-o", bin, filepath.Join(src, "main.go")).CombinedOutput(); err != nil {
+			t.Skipf("go build: %v\n%s", err, out)
+		}
+		return src
+	}
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	configDir := t.TempDir()
+	ex := &Executor{DB: db, WorkspaceDir: dir, ConfigDir: configDir, Config: &config.Config{ToolVersionsKept: 1}}
+	register := func(version int, force bool) map[string]interface{} {
+		args, _ := json.Marshal(map[string]interface{}{"name": "versioned", "binary_path": bin, "description": fmt.Sprintf("v%d", version), "force_update": force, "source_dir": build(version)})
+		out, _ := ex.Execute(ctx, "register_tool", string(args))
+		var m map[string]interface{}
+		_ = json.Unmarshal([]byte(out), &m)
+		if m["error"] != nil {
+			t.Fatalf("register v%d: %s", version, out)
+		}
+		return m
+	}
+	run := func() string {
+		out, _ := ExecuteRegisteredToolByName(ctx, db, dir, "versioned", "{}", nil)
+		var m map[string]interface{}
+		_ = json.Unmarshal([]byte(out), &m)
+		s, _ := m["stdout"].(string)
+		return s
+	}
+
+	register(1, false)
+	if m := register(2, true); m["version"] != float64(2) {
+		t.Fatalf("second registration = %v, want version 2", m)
+	}
+	if got := run(); got != `{"version": 2}` {
+		t.Fatalf("current build = %s", got)
+	}
+	tool, _ := db.ToolByName(ctx, "versioned")
+	if tool.Version != 2 || tool.SourceHash == "" || tool.PreviousBinaryPath == "" {
+		t.Fatalf("registry row = %+v", tool)
+	}
+
+	out, _ := ex.Execute(ctx, "register_tool", `{"action": "rollback", "name": "versioned"}`)
+	if !strings.Contains(out, "rolled_back") {
+		t.Fatalf("rollback: %s", out)
+	}
+	if got := run(); got != `{"version": 1}` {
+		t.Errorf("after rollback = %s, want version 1", got)
+	}
+	if out, _ = ex.Execute(ctx, "register_tool", `{"action": "rollback", "name": "versioned"}`); !strings.Contains(out, "no earlier version") {
+		t.Errorf("second rollback: %s", out)
+	}
+
+	// A new registration after a rollback gets a fresh number; only one previous version is kept
+	if m := register(3, true); m["version"] != float64(3) {
+		t.Fatalf("third registration = %v", m)
+	}
+	out, _ = ex.Execute(ctx, "register_tool", `{"action": "list_versions", "name": "versioned"}`)
+	var list struct {
+		Versions []store.ToolVersion `json:"versions"`
+	}
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		t.Fatalf("list_versions: %s", out)
+	}
+	if len(list.Versions) != 2 || list.Versions[0].Version != 3 || !list.Versions[0].Current || list.Versions[1].Version != 2 {
+		t.Errorf("versions = %+v", list.Versions)
+	}
+	if _, err := os.Stat(filepath.Join(configDir, "tools", ".versions", "versioned", "v1")); !os.IsNotExist(err) {
+		t.Errorf("pruned version archive should be removed: %v", err)
+	}
+	if !list.Versions[1].HasSource || !strings.HasSuffix(list.Versions[1].SourceDir, "src2") {
+		t.Errorf("version 2 provenance = %+v", list.Versions[1])
+	}
+
+	// Stored source survives the workspace copy being deleted
+	if err := os.RemoveAll(filepath.Join(dir, "src2")); err != nil {
+		t.Fatal(err)
+	}
+	out, _ = ex.Execute(ctx, "read_tool_source", `{"name": "versioned", "version": 2, "file": "main.go"}`)
+	var src struct {
+		Version int    `json:"version"`
+		Origin  string `json:"origin"`
+		Files   []store.SourceFile
+	}
+	if err := json.Unmarshal([]byte(out), &src); err != nil || src.Origin != "stored" || len(src.Files) != 1 || !strings.Contains(src.Files[0].Content, `{"version": 2}`) {
+		t.Errorf("read_tool_source v2: %s", out)
+	}
+	if out, _ = ex.Execute(ctx, "read_tool_source", `{"name": "versioned", "file": "other.go"}`); !strings.Contains(out, "no file other.go") {
+		t.Errorf("missing file: %s", out)
+	}
+}
+
+func TestToolpack_export_import(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user_trust", "admin")
+	newInstance := func() *Executor {
+		db, err := store.Open(ctx, ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return &Executor{DB: db, WorkspaceDir: t.TempDir(), ConfigDir: t.TempDir(), Config: &config.Config{AgentName: "home"}}
+	}
+	src, dst := newInstance(), newInstance()
+	register := func(greeting string, force bool) {
+		dir := filepath.Join(src.WorkspaceDir, "greeter")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		code := fmt.Sprintf("package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Print(`{\"greeting\": %q}`) }\n", greeting)
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+		bin := filepath.Join(dir, "greeter")
+		if out, err := exec.CommandContext(ctx, "go", "build", "-o", bin, filepath.Join(dir, "main.go")).CombinedOutput(); err != nil {
+			t.Skipf("go build: %v\n%s", err, out)
+		}
+		args, _ := json.Marshal(map[string]interface{}{"name": "greeter", "binary_path": bin, "description": "Greets", "input_schema": `{"type":"object"}`, "source_dir": dir, "force_update": force})
+		if out, _ := src.Execute(ctx, "register_tool", string(args)); !strings.Contains(out, `"registered"`) {
+			t.Fatalf("register: %s", out)
+		}
+	}
+	importPack := func(pack, args string) map[string]interface{} {
+		data, err := os.ReadFile(filepath.Join(src.WorkspaceDir, pack))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dst.WorkspaceDir, filepath.Base(pack)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, _ := dst.Execute(ctx, "import_toolpack", fmt.Sprintf(`{"path": %q%s}`, filepath.Base(pack), args))
+		var res struct {
+			Tools []map[string]interface{} `json:"tools"`
+		}
+		if err := json.Unmarshal([]byte(out), &res); err != nil || len(res.Tools) != 1 {
+			t.Fatalf("import_toolpack: %s", out)
+		}
+		return res.Tools[0]
+	}
+
+	register("hello", false)
+	out, _ := src.Execute(ctx, "export_toolpack", `{"name": "shared", "tools": ["greeter", "missing"]}`)
+	if !strings.Contains(out, `"exported"`) || !strings.Contains(out, "tool missing not found") {
+		t.Fatalf("export: %s", out)
+	}
+	if res := importPack("toolpacks/shared.tar.gz", ""); res["status"] != "imported" || res["version"] != float64(1) {
+		t.Fatalf("first import = %v", res)
+	}
+	out, _ = ExecuteRegisteredToolByName(ctx, dst.DB, dst.WorkspaceDir, "greeter", "{}", nil)
+	if !strings.Contains(out, "hello") {
+		t.Fatalf("imported tool output: %s", out)
+	}
+	if tool, _ := dst.DB.ToolByName(ctx, "greeter"); tool.Description != "Greets" || tool.InputSchema != `{"type":"object"}` {
+		t.Errorf("imported registry row = %+v", tool)
+	}
+	if res := importPack("toolpacks/shared.tar.gz", ""); res["status"] != "unchanged" {
+		t.Errorf("re-import = %v", res)
+	}
+
+	// A changed tool conflicts with the imported one
+	register("bonjour", true)
+	if out, _ = src.Execute(ctx, "export_toolpack", `{"path": "v2.json"}`); !strings.Contains(out, `"exported"`) {
+		t.Fatalf("json export: %s", out)
+	}
+	if res := importPack("v2.json", ""); res["status"] != "skipped" {
+		t.Errorf("conflict default = %v", res)
+	}
+	if res := importPack("v2.json", `, "on_conflict": "replace", "dry_run": true`); res["status"] != "would_be_updated" {
+		t.Errorf("dry run = %v", res)
+	}
+	if res := importPack("v2.json", `, "on_conflict": "rename"`); res["status"] != "imported" || res["imported_as"] != "greeter_imported" {
+		t.Errorf("rename = %v", res)
+	}
+	if res := importPack("v2.json", `, "on_conflict": "replace"`); res["status"] != "updated" || res["version"] != float64(2) {
+		t.Errorf("replace = %v", res)
+	}
+	out, _ = ExecuteRegisteredToolByName(ctx, dst.DB, dst.WorkspaceDir, "greeter", "{}", nil)
+	if !strings.Contains(out, "bonjour") {
+		t.Errorf("replaced tool output: %s", out)
+	}
+
+	if out, _ = dst.Execute(context.Background(), "import_toolpack", `{"path": "v2.json"}`); !strings.Contains(out, "only admins") {
+		t.Errorf("non-admin import: %s", out)
+	}
+}
+
+func TestManageLLMProvider_set_fallbacks(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	for _, args := range []string{
+		`{"action": "register_provider", "provider_name": "or", "provider_config": {"type": "openrouter", "api_key_env": "OPENROUTER_API_KEY"}}`,
+		`{"action": "set_route", "route": "default", "provider_name": "or", "model": "a"}`,
+	} {
+		if out, _ := ManageLLMProviderTool(ctx, dir, args); strings.Contains(out, "error") {
+			t.Fatal(out)
+		}
+	}
+	out, _ := ManageLLMProviderTool(ctx, dir, `{"action": "set_fallbacks", "fallbacks": [{"provider": "nope", "model": "b"}]}`)
+	if !strings.Contains(out, "provider 'nope' not found") {
+		t.Errorf("unknown provider: %s", out)
+	}
+	out, _ = ManageLLMProviderTool(ctx, dir, `{"action": "set_fallbacks", "fallbacks": [{"provider": "or", "model": "b"}, {"provider": "or", "model": "c"}], "circuit_breaker": {"failure_threshold": 5}}`)
+	if !strings.Contains(out, `"chain":["or:a","or:b","or:c"]`) {
+		t.Errorf("set_fallbacks: %s", out)
+	}
+	// set_route changes the primary and keeps the fallbacks
+	ManageLLMProviderTool(ctx, dir, `{"action": "set_route", "route": "default", "provider_name": "or", "model": "z"}`)
+	cfg, err := store.LoadLLMRouting(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := cfg.ModelRouting["default"]; r.Model != "z" || len(r.Fallbacks) != 2 || cfg.CircuitBreaker == nil || cfg.CircuitBreaker.FailureThreshold != 5 {
+		t.Errorf("routing = %+v, breaker %+v", r, cfg.CircuitBreaker)
+	}
+}
+
+func TestFileStoreTool(t *testing.T) {
+	ctx := context.Background()
+	ws, storeDir := t.TempDir(), t.TempDir()
+	cfg := &config.Config{FileStores: map[string]config.FileStore{"artifacts": {Type: "local", Dir: storeDir}}}
+	ex := &Executor{Config: cfg, WorkspaceDir: ws}
+	os.WriteFile(filepath.Join(ws, "report.pdf"), []byte("%PDF"), 0644)
+
+	for _, step := range []struct{ args, want string }{
+		{`{"action": "upload", "source": "report.pdf", "path": "2026/"}`, `"path": "2026/report.pdf"`},
+		{`{"action": "upload", "source": "report.pdf", "path": "2026/"}`, `already exists`},
+		{`{"action": "upload", "source": "../etc/passwd", "path": "x"}`, `inside the workspace`},
+		{`{"action": "write", "path": "notes.md", "content": "hello"}`, `"status": "written"`},
+		{`{"action": "read", "path": "notes.md"}`, `"content":"hello"`},
+		{`{"action": "list", "path": "2026"}`, `"key": "2026/report.pdf"`},
+		{`{"action": "download", "path": "2026/report.pdf", "dest": "copies/"}`, `"dest": "copies/report.pdf"`},
+		{`{"action": "delete", "path": "notes.md"}`, `"status": "deleted"`},
+		{`{"action": "read", "path": "notes.md"}`, `file does not exist`},
+		{`{"action": "list", "store": "s3"}`, `unknown file store`},
+		{`{"action": "stores"}`, `"location": "local:` + storeDir},
+	} {
+		out, err := ex.FileStoreTool(ctx, step.args)
+		if err != nil || !strings.Contains(out, step.want) {
+			t.Errorf("%s: %s, %v (want %s)", step.args, out, err, step.want)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "copies", "report.pdf")); string(data) != "%PDF" {
+		t.Errorf("downloaded %q", data)
+	}
+}
+
+func TestManageSchedule_executeToolNeedsTheToolsRole(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ex := &Executor{DB: db}
+	create := `{"action": "create", "description": "cleanup", "action_type": "execute_tool", "tool": "delete_tool", "tool_args": {"name": "x"}, "schedule_type": "once", "run_at": "2h"}`
+
+	userCtx := context.WithValue(context.WithValue(ctx, "user_id", "bob"), "user_role", store.RoleUser)
+	if out, _ := ex.Execute(userCtx, "manage_schedule", create); !strings.Contains(out, "not allowed") {
+		t.Errorf("a user scheduled an admin tool: %s", out)
+	}
+	if plans, _ := db.ListPlans(ctx, "bob", ""); len(plans) != 0 {
+		t.Errorf("plans = %+v, want none", plans)
+	}
+	adminCtx := context.WithValue(context.WithValue(ctx, "user_id", "alice"), "user_role", store.RoleAdmin)
+	if out, _ := ex.Execute(adminCtx, "manage_schedule", create); !strings.Contains(out, "scheduled") {
+		t.Errorf("admin schedule: %s", out)
+	}
+	// A grant for the tool lets the user schedule it
+	if _, err := db.GrantToolPermission(ctx, store.ToolPermission{SubjectType: store.PermissionSubjectUser, Subject: "bob", Tool: "delete_tool"}); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := ex.Execute(userCtx, "manage_schedule", create); !strings.Contains(out, "scheduled") {
+		t.Errorf("granted schedule: %s", out)
+	}
+}
+
+func TestAddWebhookRoute_promptsRunAsTheCaller(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ex := &Executor{ConfigDir: dir}
+	userCtx := context.WithValue(context.WithValue(ctx, "user_id", "bob"), "user_role", store.RoleUser)
+
+	out, _ := ex.Execute(userCtx, "add_webhook_route", `{"path": "/webhook/mine", "id": "mine", "auth_type": "header", "secret_header": "X-Token", "target_type": "agent_prompt", "target_prompt": "hi", "target_user": "admin"}`)
+	if !strings.Contains(out, "only admins") {
+		t.Fatalf("a user ran a route's prompts as the admin: %s", out)
+	}
+	out, _ = ex.Execute(userCtx, "add_webhook_route", `{"path": "/webhook/mine", "id": "mine", "auth_type": "header", "secret_header": "X-Token", "targets": [{"prompt": "hi"}, {"tool": "web_search", "args": "{}"}]}`)
+	if !strings.Contains(out, "added") {
+		t.Fatalf("add: %s", out)
+	}
+	routes, err := store.LoadWebhookRoutes(dir)
+	if err != nil || len(routes) != 1 {
+		t.Fatalf("routes = %+v, %v", routes, err)
+	}
+	if r := routes[0]; r.CreatedBy != "bob" || r.Targets[0].User != "bob" || r.Targets[1].User != "" {
+		t.Errorf("route = %+v, want created by bob with its prompt run as bob", r)
+	}
+
+	adminCtx := context.WithValue(context.WithValue(ctx, "user_id", "alice"), "user_role", store.RoleAdmin)
+	if out, _ := ex.Execute(adminCtx, "add_webhook_route", `{"path": "/webhook/ops", "id": "ops", "auth_type": "header", "secret_header": "X-Token", "target_type": "agent_prompt", "target_prompt": "hi", "target_user": "bob"}`); !strings.Contains(out, "added") {
+		t.Errorf("admin naming another user: %s", out)
+	}
+}
+
+func TestManageSchedule_preapproveNeedsAConfirmedDraft(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ex := &Executor{DB: db}
+	userCtx := context.WithValue(ctx, "user_id", "alice")
+	create := `{"action": "create", "description": "mail the report", "action_type": "agent_prompt", "schedule_type": "daily", "run_at": "09:00", "preapprove": true}`
+
+	for _, confirmed := range []bool{false, true} {
+		out, _ := ex.Execute(context.WithValue(userCtx, "draft_confirmed", confirmed), "manage_schedule", create)
+		var res struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(out), &res); err != nil || res.ID == 0 {
+			t.Fatalf("create: %s", out)
+		}
+		if ok, err := db.PlanPreapproved(ctx, res.ID); err != nil || ok != confirmed {
+			t.Errorf("confirmed=%v: preapproved = %v, %v", confirmed, ok, err)
+		}
+	}
+}
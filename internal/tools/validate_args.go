@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hattiebot/hattiebot/internal/jsonschema"
+	"github.com/hattiebot/hattiebot/internal/plugins"
+	"github.com/hattiebot/hattiebot/internal/tools/builtin"
+)
+
+var (
+	builtinSchemasOnce sync.Once
+	builtinSchemas     map[string]*jsonschema.Schema
+)
+
+// builtinSchema returns the parsed parameter schema of a built-in tool, or nil for other names.
+func builtinSchema(name string) *jsonschema.Schema {
+	builtinSchemasOnce.Do(func() {
+		builtinSchemas = map[string]*jsonschema.Schema{}
+		for _, def := range BuiltinToolDefs() {
+			if t, ok := builtin.Lookup(def.Function.Name); ok && plugins.IsPlugin(t) {
+				continue // plugins can be replaced at runtime and validate their own arguments
+			}
+			if s, err := jsonschema.FromValue(def.Function.Parameters); err == nil {
+				builtinSchemas[def.Function.Name] = s
+			}
+		}
+	})
+	return builtinSchemas[name]
+}
+
+// validateRegisteredArgs checks args against a registered tool's input_schema and returns a
+// validation error result, or "" when the args are valid, the tool is unknown (the caller reports
+// that), or the tool has no usable schema.
+func (e *Executor) validateRegisteredArgs(ctx context.Context, name, argsJSON string) string {
+	if e.DB == nil {
+		return ""
+	}
+	tool, _, err := findRegisteredTool(ctx, e.DB, name)
+	if err != nil || tool == nil || strings.TrimSpace(tool.InputSchema) == "" {
+		return ""
+	}
+	schema, err := jsonschema.Parse([]byte(tool.InputSchema))
+	if err != nil {
+		return ""
+	}
+	if errs := schema.ValidateJSON([]byte(argsJSON)); len(errs) > 0 {
+		return argsValidationError(tool.Name, errs, json.RawMessage(tool.InputSchema))
+	}
+	return ""
+}
+
+// argsValidationError reports invalid tool arguments in a form the model can correct from: every
+// problem with its path, and for registered tools (whose schema is not in the tool list) the schema.
+func argsValidationError(tool string, errs []jsonschema.Error, schema json.RawMessage) string {
+	msgs := make([]string, 0, 3)
+	for i, e := range errs {
+		if i == 3 {
+			msgs = append(msgs, fmt.Sprintf("and %d more", len(errs)-i))
+			break
+		}
+		msgs = append(msgs, e.Error())
+	}
+	out := map[string]interface{}{
+		"error":             fmt.Sprintf("invalid arguments for %s: %s", tool, strings.Join(msgs, "; ")),
+		"validation_errors": errs,
+		"hint":              "The tool was not run. Fix the arguments to match its input schema and call it again.",
+	}
+	if len(schema) > 0 && json.Valid(schema) {
+		out["input_schema"] = schema
+	}
+	b, _ := json.Marshal(out)
+	return string(b)
+}
@@ -0,0 +1,245 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/redact"
+	"github.com/hattiebot/hattiebot/internal/version"
+)
+
+const (
+	// batchSize and flushInterval bound how long a finished span waits before it is exported.
+	batchSize     = 256
+	flushInterval = 5 * time.Second
+	// queueSize spans may wait for export; more are dropped rather than slowing a turn down.
+	queueSize = 4096
+)
+
+// Exporter sends finished spans in batches to an OTLP/HTTP endpoint as JSON.
+type Exporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	queue chan *Span
+	flush chan chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Setup starts exporting spans to endpoint (an OTLP/HTTP collector, e.g. http://localhost:4318)
+// with the given extra headers, and returns a function that flushes pending spans and stops the
+// exporter. An empty endpoint leaves tracing off.
+func Setup(endpoint string, headers map[string]string) func(context.Context) {
+	if endpoint == "" {
+		return func(context.Context) {}
+	}
+	exp := NewExporter(endpoint, headers)
+	current.Store(exp)
+	return func(ctx context.Context) {
+		current.CompareAndSwap(exp, nil)
+		exp.Shutdown(ctx)
+	}
+}
+
+// NewExporter returns a running exporter for endpoint. Paths other than /v1/traces are taken as
+// the collector's base URL, as with OTEL_EXPORTER_OTLP_ENDPOINT.
+func NewExporter(endpoint string, headers map[string]string) *Exporter {
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	e := &Exporter{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		queue:   make(chan *Span, queueSize),
+		flush:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// ParseHeaders parses OTEL_EXPORTER_OTLP_HEADERS ("key=value,key2=value2").
+func ParseHeaders(s string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if k = strings.TrimSpace(k); ok && k != "" {
+			headers[k] = strings.TrimSpace(v)
+		}
+	}
+	return headers
+}
+
+func (e *Exporter) enqueue(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+	}
+}
+
+// Flush exports the spans queued so far and waits for it, or for ctx.
+func (e *Exporter) Flush(ctx context.Context) {
+	ack := make(chan struct{})
+	select {
+	case e.flush <- ack:
+	case <-e.done:
+		return
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-ack:
+	case <-ctx.Done():
+	}
+}
+
+// Shutdown exports what is queued and stops the exporter.
+func (e *Exporter) Shutdown(ctx context.Context) {
+	e.Flush(ctx)
+	e.once.Do(func() { close(e.done) })
+}
+
+func (e *Exporter) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	var batch []*Span
+	send := func() {
+		if len(batch) > 0 {
+			if err := e.export(batch); err != nil {
+				log.Printf("[tracing] export %d spans: %v", len(batch), err)
+			}
+			batch = nil
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case s := <-e.queue:
+				batch = append(batch, s)
+			default:
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-e.flush:
+			drain()
+			send()
+			close(ack)
+		case <-e.done:
+			return
+		}
+	}
+}
+
+func (e *Exporter) export(spans []*Span) error {
+	body, err := json.Marshal(encode(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// encode builds an OTLP ExportTraceServiceRequest in its JSON mapping. Spans leave the host, so
+// string values and error messages are redacted.
+func encode(spans []*Span) map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.spanID[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        attributes(s.attrs),
+		}
+		if s.parentID != [8]byte{} {
+			span["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.errMsg != "" {
+			span["status"] = map[string]interface{}{"code": 2, "message": redact.String(s.errMsg)}
+		}
+		s.mu.Unlock()
+		out = append(out, span)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": attributes(map[string]interface{}{
+				"service.name":    "hattiebot",
+				"service.version": version.Version,
+			})},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "github.com/hattiebot/hattiebot"},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func attributes(attrs map[string]interface{}) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(attrs))
+	for k, v := range attrs {
+		out = append(out, map[string]interface{}{"key": k, "value": anyValue(v)})
+	}
+	return out
+}
+
+func anyValue(v interface{}) map[string]interface{} {
+	switch x := v.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": x}
+	case int:
+		return map[string]interface{}{"intValue": strconv.FormatInt(int64(x), 10)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(x, 10)}
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return map[string]interface{}{"stringValue": fmt.Sprint(x)}
+		}
+		return map[string]interface{}{"doubleValue": x}
+	case string:
+		return map[string]interface{}{"stringValue": redact.String(x)}
+	default:
+		return map[string]interface{}{"stringValue": redact.String(fmt.Sprint(x))}
+	}
+}
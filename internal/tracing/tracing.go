@@ -0,0 +1,149 @@
+// Package tracing records spans of the turn pipeline (gateway, agent loop, LLM calls, tools,
+// scheduler) and exports them to an OpenTelemetry collector over OTLP/HTTP with JSON encoding.
+// It implements the small part of OpenTelemetry the bot needs, without the SDK. Until Setup is
+// called, Start returns a nil span and every Span method is a no-op, so instrumented code costs
+// next to nothing when tracing is off.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Span kinds (OTLP SpanKind).
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// Span is one timed operation of a trace. A nil *Span is valid and records nothing.
+type Span struct {
+	exp      *Exporter
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  map[string]interface{}
+	errMsg string
+	ended  bool
+}
+
+type spanKey struct{}
+
+// current is the exporter spans go to; nil while tracing is off.
+var current atomic.Pointer[Exporter]
+
+// Enabled reports whether spans are recorded.
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// Start begins a span named name as a child of the span in ctx (or a new trace), and returns a
+// context carrying it. End the span when the operation finishes.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return StartAt(ctx, name, time.Now())
+}
+
+// StartAt is Start for an operation that began at start, e.g. when a message was received.
+func StartAt(ctx context.Context, name string, start time.Time) (context.Context, *Span) {
+	exp := current.Load()
+	if exp == nil {
+		return ctx, nil
+	}
+	s := &Span{exp: exp, name: name, kind: KindInternal, start: start}
+	if parent := FromContext(ctx); parent != nil {
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	_, _ = rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext returns the span in ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// TraceID returns the hex trace ID of the span in ctx, or "" when there is none.
+func TraceID(ctx context.Context) string {
+	if s := FromContext(ctx); s != nil {
+		return hex.EncodeToString(s.traceID[:])
+	}
+	return ""
+}
+
+// SetKind sets the span kind (KindServer for handled requests, KindClient for outgoing calls).
+func (s *Span) SetKind(kind int) *Span {
+	if s != nil {
+		s.kind = kind
+	}
+	return s
+}
+
+// Set records an attribute: a string, bool, integer, float or time.Duration (as milliseconds).
+func (s *Span) Set(key string, value interface{}) *Span {
+	if s == nil {
+		return s
+	}
+	if d, ok := value.(time.Duration); ok {
+		value = d.Milliseconds()
+	}
+	s.mu.Lock()
+	if s.attrs == nil {
+		s.attrs = map[string]interface{}{}
+	}
+	s.attrs[key] = value
+	s.mu.Unlock()
+	return s
+}
+
+// SetError marks the span as failed with err; a nil err is ignored.
+func (s *Span) SetError(err error) *Span {
+	if s == nil || err == nil {
+		return s
+	}
+	s.mu.Lock()
+	s.errMsg = err.Error()
+	s.mu.Unlock()
+	return s
+}
+
+// End finishes the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.end = true, time.Now()
+	s.mu.Unlock()
+	s.exp.enqueue(s)
+}
+
+// EndErr records err (if any) and ends the span; handy as defer span.EndErr(err) at the end.
+func (s *Span) EndErr(err error) {
+	s.SetError(err).End()
+}
+
+func (s *Span) String() string {
+	if s == nil {
+		return "<no span>"
+	}
+	return fmt.Sprintf("%s %x/%x", s.name, s.traceID, s.spanID)
+}
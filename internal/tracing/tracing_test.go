@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/redact"
+)
+
+type otlpSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Start        string `json:"startTimeUnixNano"`
+	Attributes   []struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"attributes"`
+	Status *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+func (s otlpSpan) attr(key string) interface{} {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			for _, v := range a.Value {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// collector is an OTLP/HTTP receiver that keeps the spans it is sent.
+func collector(t *testing.T) (*httptest.Server, func() map[string]otlpSpan, *http.Header) {
+	var mu sync.Mutex
+	spans := map[string]otlpSpan{}
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []otlpSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("decode export: %v", err)
+		}
+		mu.Lock()
+		headers = r.Header.Clone()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					spans[s.Name] = s
+				}
+			}
+		}
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() map[string]otlpSpan {
+		mu.Lock()
+		defer mu.Unlock()
+		return spans
+	}, &headers
+}
+
+func TestSpansAreExportedAsOneTrace(t *testing.T) {
+	srv, spans, headers := collector(t)
+	shutdown := Setup(srv.URL, map[string]string{"X-Api-Key": "k1"})
+
+	received := time.Now().Add(-time.Second)
+	ctx, root := StartAt(context.Background(), "gateway.message", received)
+	root.SetKind(KindServer).Set("channel", "admin_term").Set("autonomous", false).Set("queue_wait_ms", 1500*time.Millisecond)
+	_, child := Start(ctx, "tool.run_shell")
+	child.Set("tool.outcome", "error").EndErr(errors.New("exit status 1"))
+	traceID := TraceID(ctx)
+	root.End()
+	root.End() // a second End is ignored
+	shutdown(context.Background())
+
+	got := spans()
+	if len(got) != 2 {
+		t.Fatalf("exported %d spans, want 2: %+v", len(got), got)
+	}
+	r, c := got["gateway.message"], got["tool.run_shell"]
+	if r.TraceID != traceID || c.TraceID != traceID || len(traceID) != 32 {
+		t.Errorf("trace ids root %q child %q, want %q", r.TraceID, c.TraceID, traceID)
+	}
+	if c.ParentSpanID != r.SpanID || r.ParentSpanID != "" {
+		t.Errorf("child parent %q, root span %q (root parent %q)", c.ParentSpanID, r.SpanID, r.ParentSpanID)
+	}
+	if r.Kind != KindServer || r.Start != strconv.FormatInt(received.UnixNano(), 10) {
+		t.Errorf("root kind %d start %s", r.Kind, r.Start)
+	}
+	if r.attr("channel") != "admin_term" || r.attr("autonomous") != false || r.attr("queue_wait_ms") != "1500" {
+		t.Errorf("root attributes %+v", r.Attributes)
+	}
+	if c.Status == nil || c.Status.Code != 2 || c.Status.Message != "exit status 1" {
+		t.Errorf("child status %+v", c.Status)
+	}
+	if headers.Get("X-Api-Key") != "k1" {
+		t.Errorf("export headers %v", *headers)
+	}
+}
+
+func TestSpansAreRedacted(t *testing.T) {
+	srv, spans, _ := collector(t)
+	shutdown := Setup(srv.URL+"/v1/traces", nil)
+	redact.AddSecret("tracing-secret-value")
+
+	_, span := Start(context.Background(), "tool.http_request")
+	span.Set("url", "https://example.com/?key=tracing-secret-value").EndErr(errors.New("denied for tracing-secret-value"))
+	shutdown(context.Background())
+
+	s := spans()["tool.http_request"]
+	if v, _ := s.attr("url").(string); v != "https://example.com/?key="+redact.Mask {
+		t.Errorf("url attribute %q", v)
+	}
+	if s.Status == nil || s.Status.Message != "denied for "+redact.Mask {
+		t.Errorf("status %+v", s.Status)
+	}
+}
+
+func TestTracingOff(t *testing.T) {
+	ctx, span := Start(context.Background(), "agent.turn")
+	if span != nil || TraceID(ctx) != "" || Enabled() {
+		t.Fatalf("span %v trace %q with no exporter", span, TraceID(ctx))
+	}
+	// A nil span accepts every call
+	span.SetKind(KindClient).Set("a", 1).SetError(errors.New("x")).End()
+	span.EndErr(nil)
+}
+
+func TestParseHeaders(t *testing.T) {
+	got := ParseHeaders("api-key=abc, x-team = ops,broken,=empty")
+	if len(got) != 2 || got["api-key"] != "abc" || got["x-team"] != "ops" {
+		t.Errorf("ParseHeaders = %v", got)
+	}
+}
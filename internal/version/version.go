@@ -0,0 +1,6 @@
+// Package version holds build metadata for the HattieBot binary.
+package version
+
+// Version is the release identifier. Overridden at build time with
+// -ldflags "-X github.com/hattiebot/hattiebot/internal/version.Version=v1.2.3".
+var Version = "dev"
@@ -0,0 +1,89 @@
+package webhookserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// DeliveryStore remembers the deliveries of idempotent webhook routes (implemented by store.DB).
+type DeliveryStore interface {
+	ClaimWebhookDelivery(ctx context.Context, routeID, deliveryID string, ttl time.Duration) (*store.WebhookDelivery, bool, error)
+	FinishWebhookDelivery(ctx context.Context, routeID, deliveryID, status, result string) error
+}
+
+// deliveryID returns the delivery ID of a request to route: the route's header if present, else
+// the value at its JSON path in the body. Empty when the route does not deduplicate or the
+// delivery carries no ID.
+func deliveryID(route *store.WebhookRoute, header func(string) string, body []byte) string {
+	if route.DeliveryIDHeader != "" {
+		if id := strings.TrimSpace(header(route.DeliveryIDHeader)); id != "" {
+			return id
+		}
+	}
+	if route.DeliveryIDPath == "" {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return ""
+	}
+	v, ok := lookupPath(v, route.DeliveryIDPath)
+	if !ok {
+		return ""
+	}
+	switch id := v.(type) {
+	case string:
+		return strings.TrimSpace(id)
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	}
+	return ""
+}
+
+// lookupPath follows a dot path ("data.object.id", "events.0.id") through decoded JSON.
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// duplicateResponse is the body sent for a delivery that was processed before: its status, and
+// with on_duplicate "cached" the first delivery's tool result.
+func duplicateResponse(route *store.WebhookRoute, d *store.WebhookDelivery) []byte {
+	resp := map[string]interface{}{
+		"status":      "duplicate",
+		"delivery_id": d.DeliveryID,
+		"first_seen":  d.ReceivedAt.UTC().Format(time.RFC3339),
+		"outcome":     d.Status,
+	}
+	if route.OnDuplicate != "skip" && d.Status != store.DeliveryProcessing {
+		resp["result"] = d.Result
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return []byte(fmt.Sprintf(`{"status":"duplicate","delivery_id":%q}`, d.DeliveryID))
+	}
+	return b
+}
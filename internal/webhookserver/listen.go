@@ -0,0 +1,236 @@
+package webhookserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Listener defaults, used when the Server's fields are zero.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = time.Minute
+	// DefaultWriteTimeout is longer than the HTTP API's reply timeout (10 minutes), so a request
+	// waiting for the agent is answered with a timeout error rather than cut off.
+	DefaultWriteTimeout   = 11 * time.Minute
+	DefaultIdleTimeout    = 2 * time.Minute
+	DefaultMaxHeaderBytes = 64 << 10
+)
+
+// httpServer returns the http.Server for handler with the configured timeouts and header limit.
+func (s *Server) httpServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: orDefault(s.ReadHeaderTimeout, DefaultReadHeaderTimeout),
+		ReadTimeout:       orDefault(s.ReadTimeout, DefaultReadTimeout),
+		WriteTimeout:      orDefault(s.WriteTimeout, DefaultWriteTimeout),
+		IdleTimeout:       orDefault(s.IdleTimeout, DefaultIdleTimeout),
+		MaxHeaderBytes:    orDefaultInt(s.MaxHeaderBytes, DefaultMaxHeaderBytes),
+	}
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+func orDefaultInt(n, def int) int {
+	if n > 0 {
+		return n
+	}
+	return def
+}
+
+// tlsConfig returns the TLS configuration for the listener, or nil to serve plain HTTP.
+// Certificate files are reloaded when they change on disk (e.g. renewed by certbot); autocert
+// obtains certificates from Let's Encrypt with the TLS-ALPN-01 challenge on this listener, so it
+// must be reachable on port 443. With TalkClientCAFile, clients may present a certificate, which
+// the Talk webhook then requires (see talkAuthorized).
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	var cfg *tls.Config
+	switch {
+	case s.TLSCertFile != "" || s.TLSKeyFile != "":
+		if s.TLSCertFile == "" || s.TLSKeyFile == "" {
+			return nil, fmt.Errorf("TLS needs both a certificate and a key file")
+		}
+		if len(s.AutocertDomains) > 0 {
+			return nil, fmt.Errorf("use either TLS certificate files or autocert domains, not both")
+		}
+		kp := &keyPair{certFile: s.TLSCertFile, keyFile: s.TLSKeyFile}
+		if _, err := kp.get(nil); err != nil {
+			return nil, err
+		}
+		cfg = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: kp.get}
+	case len(s.AutocertDomains) > 0:
+		if s.AutocertCacheDir == "" {
+			return nil, fmt.Errorf("autocert needs a cache directory")
+		}
+		if err := os.MkdirAll(s.AutocertCacheDir, 0700); err != nil {
+			return nil, fmt.Errorf("autocert cache: %w", err)
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(s.AutocertDomains...),
+			Cache:      autocert.DirCache(s.AutocertCacheDir),
+			Email:      s.AutocertEmail,
+		}
+		cfg = m.TLSConfig()
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if s.TalkClientCAFile != "" {
+		if cfg == nil {
+			return nil, fmt.Errorf("Talk client certificates need HTTPS (TLS certificate files or autocert domains)")
+		}
+		pem, err := os.ReadFile(s.TalkClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Talk client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Talk client CA %s: no PEM certificates", s.TalkClientCAFile)
+		}
+		// Other endpoints do not need a certificate, so one is verified only when given.
+		cfg.ClientCAs, cfg.ClientAuth = pool, tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+// keyPair serves a certificate from files, loading them again when either is modified.
+type keyPair struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (k *keyPair) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var latest time.Time
+	for _, f := range []string{k.certFile, k.keyFile} {
+		fi, err := os.Stat(f)
+		if err != nil {
+			if k.cert != nil {
+				return k.cert, nil // mid-renewal: keep serving the loaded certificate
+			}
+			return nil, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	if k.cert != nil && !latest.After(k.modTime) {
+		return k.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(k.certFile, k.keyFile)
+	if err != nil {
+		if k.cert != nil {
+			return k.cert, nil
+		}
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	k.cert, k.modTime = &cert, latest
+	return k.cert, nil
+}
+
+// ParseIPNets parses a comma-separated list of IP addresses and CIDR ranges.
+func ParseIPNets(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q: %w", item, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// withClientIP sets r.RemoteAddr to the client's address when the request came through a
+// trusted proxy: the last X-Forwarded-For entry not added by a trusted proxy. X-Forwarded-For
+// from anyone else is ignored, so clients cannot choose the address they are logged under.
+func (s *Server) withClientIP(next http.Handler) http.Handler {
+	if len(s.TrustedProxies) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := s.clientIP(r); ip != "" {
+			_, port, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				port = "0"
+			}
+			r.RemoteAddr = net.JoinHostPort(ip, port)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the forwarded client address, or "" when r did not come from a trusted proxy.
+func (s *Server) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !s.trusted(net.ParseIP(host)) {
+		return ""
+	}
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !s.trusted(ip) {
+			break
+		}
+	}
+	return client
+}
+
+func (s *Server) trusted(ip net.IP) bool {
+	return containsIP(s.TrustedProxies, ip)
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
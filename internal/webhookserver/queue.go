@@ -0,0 +1,245 @@
+package webhookserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// Defaults for the async webhook queue (Server.Workers, Server.QueueSize).
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 100
+)
+
+// webhookJob is one authenticated delivery to a dynamic route, ready to run its targets.
+type webhookJob struct {
+	route    store.WebhookRoute
+	path     string
+	targets  []jobTarget
+	routeKey string // route ID, or path for routes without one
+	delivery string // delivery ID claimed for the job; empty when not deduplicated
+}
+
+// jobTarget is a target whose filter matched, its tool arguments or prompt filled in.
+type jobTarget struct {
+	tool   string
+	args   string
+	prompt string
+	user   string
+	thread string
+}
+
+// enqueue queues job for the workers, starting them on first use. False when the queue is full.
+func (s *Server) enqueue(job webhookJob) bool {
+	s.queueOnce.Do(func() {
+		workers, size := s.Workers, s.QueueSize
+		if workers <= 0 {
+			workers = DefaultWorkers
+		}
+		if size <= 0 {
+			size = DefaultQueueSize
+		}
+		s.queue = make(chan webhookJob, size)
+		for i := 0; i < workers; i++ {
+			go func() {
+				for job := range s.queue {
+					s.runWebhookJob(context.Background(), job)
+				}
+			}()
+		}
+	})
+	select {
+	case s.queue <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// runWebhookJob runs the job's targets in order, tools with the route's timeout and retries, and
+// records each outcome as a webhook event and the overall one as the delivery's. It returns the
+// status (failed when any target failed) and the result: the target's result or error, or for
+// several targets a JSON array of their outcomes.
+func (s *Server) runWebhookJob(ctx context.Context, job webhookJob) (status, result string) {
+	type outcome struct {
+		Target string `json:"target"`
+		Status string `json:"status"`
+		Result string `json:"result"`
+	}
+	// The request may be gone (async jobs) or cancelled; record the outcome regardless
+	rec := context.Background()
+	status = store.DeliveryOK
+	var outcomes []outcome
+	for _, t := range job.targets {
+		event := store.WebhookEvent{RouteID: job.route.ID, Path: job.path, Tool: t.tool, Status: store.DeliveryOK}
+		if t.tool == "" {
+			event.Tool = store.WebhookTargetAgentPrompt
+			event.Summary = s.pushPrompt(ctx, &job.route, t)
+		} else {
+			event.Summary = s.runToolTarget(ctx, &job.route, job.path, t)
+		}
+		if toolFailed(event.Summary, nil) {
+			event.Status = store.DeliveryFailed
+			status = store.DeliveryFailed
+		}
+		outcomes = append(outcomes, outcome{Target: event.Tool, Status: event.Status, Result: event.Summary})
+		if s.Events != nil {
+			if err := s.Events.RecordWebhookEvent(rec, event); err != nil {
+				log.Printf("[WebhookServer] recording webhook event: %v", err)
+			}
+		}
+	}
+	switch len(outcomes) {
+	case 0:
+		result = `{"status":"ignored"}`
+	case 1:
+		result = outcomes[0].Result
+	default:
+		b, _ := json.Marshal(outcomes)
+		result = string(b)
+	}
+	if job.delivery != "" {
+		if err := s.Deliveries.FinishWebhookDelivery(rec, job.routeKey, job.delivery, status, result); err != nil {
+			log.Printf("[WebhookServer] dynamic webhook %s: delivery %s: %v", job.path, job.delivery, err)
+		}
+	}
+	return status, result
+}
+
+// runToolTarget runs a tool target and returns its result, or its error as an {"error": ...} object.
+func (s *Server) runToolTarget(ctx context.Context, route *store.WebhookRoute, path string, t jobTarget) string {
+	if s.ToolExecutor == nil {
+		log.Printf("[WebhookServer] dispatcher missing (ToolExecutor), dropping webhook")
+		return errorResult("no tool executor; webhook dropped")
+	}
+	log.Printf("[WebhookServer] triggering tool %s for webhook %s", t.tool, path)
+	out, err := s.runTool(ctx, route, t.tool, t.args)
+	if err != nil {
+		log.Printf("[WebhookServer] tool execution failed: %v", err)
+		return errorResult(err.Error())
+	}
+	log.Printf("[WebhookServer] tool result: %s", out)
+	return out
+}
+
+// pushPrompt hands an agent_prompt target to the agent as an autonomous message in the target's
+// thread, by default the route's own. It runs as the target's user, else the route's creator, else
+// PromptUser (routes written into webhook_routes.json by hand).
+func (s *Server) pushPrompt(ctx context.Context, route *store.WebhookRoute, t jobTarget) string {
+	user := t.user
+	if user == "" {
+		user = route.CreatedBy
+	}
+	if user == "" {
+		user = s.PromptUser
+	}
+	if s.Prompt == nil || user == "" {
+		log.Printf("[WebhookServer] no agent to prompt (Prompt, PromptUser), dropping webhook prompt")
+		return errorResult("no agent to prompt; webhook dropped")
+	}
+	thread := t.thread
+	if thread == "" && route.ID != "" {
+		thread = "webhook:" + route.ID
+	} else if thread == "" {
+		thread = "webhook:" + route.Path
+	}
+	if !s.Prompt(ctx, user, thread, webhookPrompt(route, t.prompt)) {
+		return errorResult("agent queue full")
+	}
+	return `{"status":"prompted"}`
+}
+
+func errorResult(msg string) string {
+	b, _ := json.Marshal(map[string]string{"error": msg})
+	return string(b)
+}
+
+// runTool runs a tool, each attempt limited to the route's timeout, and retries a failed run
+// after the route's delay, doubled each time.
+func (s *Server) runTool(ctx context.Context, route *store.WebhookRoute, tool, args string) (string, error) {
+	delay := route.RetryDelay()
+	if s.retryDelay > 0 {
+		delay = s.retryDelay
+	}
+	for attempt := 0; ; attempt++ {
+		runCtx, cancel := context.WithTimeout(ctx, route.Timeout())
+		result, err := s.ToolExecutor.Execute(runCtx, tool, args)
+		cancel()
+		if !toolFailed(result, err) || attempt >= route.Retries {
+			return result, err
+		}
+		log.Printf("[WebhookServer] tool %s failed (attempt %d of %d), retrying in %s", tool, attempt+1, route.Retries+1, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return result, err
+		}
+		delay *= 2
+	}
+}
+
+// toolFailed reports a tool error, or a result that is an {"error": ...} object.
+func toolFailed(result string, err error) bool {
+	return err != nil || strings.HasPrefix(strings.TrimSpace(result), `{"error"`)
+}
+
+// writeToolResponse answers a sync delivery: the route's error_status when the tool failed, and
+// its response template filled in with the outcome (no body without a template).
+func writeToolResponse(w http.ResponseWriter, route *store.WebhookRoute, status, result string) {
+	code := http.StatusOK
+	if status == store.DeliveryFailed && route.ErrorStatus != 0 {
+		code = route.ErrorStatus
+	}
+	if route.ResponseTemplate == "" {
+		w.WriteHeader(code)
+		return
+	}
+	contentType := route.ResponseContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write([]byte(renderResponse(route.ResponseTemplate, strings.Contains(contentType, "json"), status, result)))
+}
+
+var placeholder = regexp.MustCompile(`\{\{\s*(status|result(?:\.[^}\s]+)?)\s*\}\}`)
+
+// renderResponse fills in a response template. {{result}} is the tool's output as is;
+// {{result.<path>}} is a value from it when it is JSON. In a JSON template, string values are
+// escaped for use inside quotes and other values are written as JSON.
+func renderResponse(tmpl string, jsonBody bool, status, result string) string {
+	var parsed interface{}
+	decoded := json.Unmarshal([]byte(result), &parsed) == nil
+	return placeholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := placeholder.FindStringSubmatch(m)[1]
+		switch {
+		case name == "status":
+			return status
+		case name == "result":
+			return result
+		case !decoded:
+			return ""
+		}
+		v, ok := lookupPath(parsed, strings.TrimPrefix(name, "result."))
+		if !ok {
+			return ""
+		}
+		if str, isString := v.(string); isString {
+			if !jsonBody {
+				return str
+			}
+			b, _ := json.Marshal(str)
+			return string(b[1 : len(b)-1])
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	})
+}
@@ -1,21 +1,31 @@
 package webhookserver
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 
 	"github.com/hattiebot/hattiebot/internal/gateway"
 	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/health"
+	"github.com/hattiebot/hattiebot/internal/httpauth"
 
 	"github.com/hattiebot/hattiebot/internal/secrets"
+	"github.com/hattiebot/hattiebot/internal/speech"
 	"github.com/hattiebot/hattiebot/internal/store"
 )
 
@@ -25,10 +35,11 @@ const HattieBridgeSecretHeader = "X-HattieBridge-Secret"
 
 // Nextcloud Talk webhook payload (Activity Streams 2.0–style, same format from HattieBridge or Talk bot).
 type talkWebhook struct {
-	Type   string          `json:"type"`
-	Actor  *talkActor      `json:"actor"`
-	Object *talkObject     `json:"object"`
-	Target *talkTarget     `json:"target"`
+	Type    string          `json:"type"`
+	Actor   *talkActor      `json:"actor"`
+	Object  *talkObject     `json:"object"`
+	Target  *talkTarget     `json:"target"`
+	Content string          `json:"content"` // reactions ("Like"): the emoji
 }
 
 type talkActor struct {
@@ -37,9 +48,10 @@ type talkActor struct {
 }
 
 type talkObject struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Content string `json:"content"`
+	ID      string      `json:"id"`
+	Name    string      `json:"name"`
+	Content string      `json:"content"`
+	Object  *talkObject `json:"object"` // "Undo" of a reaction: the Like is the object, the message its object
 }
 
 type talkTarget struct {
@@ -49,21 +61,126 @@ type talkTarget struct {
 
 // object.content is JSON with "message" and "parameters"
 type talkContent struct {
-	Message string `json:"message"`
+	Message    string                         `json:"message"`
+	Parameters map[string]talkMessageParameter `json:"parameters"`
+}
+
+// talkMessageParameter is a rich object parameter, e.g. {"file": {...}} for a shared file or voice message.
+type talkMessageParameter struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	MimeType string `json:"mimetype"`
+}
+
+// audioAttachment returns the shared audio file parameter, if the message is a voice message / audio share.
+func (tc talkContent) audioAttachment() (talkMessageParameter, bool) {
+	f, ok := tc.Parameters["file"]
+	if !ok || f.Type != "file" || !strings.HasPrefix(f.MimeType, "audio/") {
+		return talkMessageParameter{}, false
+	}
+	return f, true
+}
+
+// expandMentions replaces the message's {mention-*} placeholders with "@name" and returns the
+// mentioned user IDs; a mention of the whole room ("call") is returned as "all".
+func (tc talkContent) expandMentions() (string, []string) {
+	text := tc.Message
+	var mentioned []string
+	for key, p := range tc.Parameters {
+		if !strings.HasPrefix(key, "mention-") {
+			continue
+		}
+		switch p.Type {
+		case "call":
+			mentioned = append(mentioned, "all")
+		case "user", "guest", "email", "federated_user":
+			mentioned = append(mentioned, p.ID)
+		default:
+			continue
+		}
+		text = strings.ReplaceAll(text, "{"+key+"}", "@"+p.Name)
+	}
+	sort.Strings(mentioned)
+	return text, mentioned
 }
 
 // Server serves webhook and health endpoints.
 type Server struct {
-	Addr               string
+	Addr               string // host:port to listen on; ":port" binds every interface
+
+	// HTTPS: with TLSCertFile and TLSKeyFile, or with Let's Encrypt certificates for
+	// AutocertDomains, cached in AutocertCacheDir. Plain HTTP when neither is set.
+	TLSCertFile        string
+	TLSKeyFile         string
+	AutocertDomains    []string
+	AutocertEmail      string
+	AutocertCacheDir   string
+	// Timeouts and the request header limit; zero uses the Default* values.
+	ReadHeaderTimeout  time.Duration
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	IdleTimeout        time.Duration
+	MaxHeaderBytes     int
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For gives the client's address.
+	TrustedProxies     []*net.IPNet
+	// Talk webhook hardening on top of HattieBridgeSecret: only from TalkAllowedIPs, and/or only
+	// with a client certificate signed by TalkClientCAFile (HTTPS only), named in TalkClientNames.
+	TalkAllowedIPs     []*net.IPNet
+	TalkClientCAFile   string
+	TalkClientNames    []string
+
 	HattieBridgeSecret string
 	PushIngress        func(gateway.Message) bool
 	HealthPath         string
 	WebhookTalkPath    string
 	ChatPath           string
+	StatusPath         string
 
 	ConfigDir          string // for dynamic webhook routes
 	SecretStore        *secrets.MultiStore
 	ToolExecutor       core.ToolExecutor
+	Events             EventRecorder // optional: records dynamic webhook deliveries for the daily briefing
+	Deliveries         DeliveryStore // optional: skips retried deliveries on routes with a delivery ID
+	// Prompt pushes a prompt target to the agent as a background task, run for the target's user
+	// or else PromptUser.
+	Prompt             func(ctx context.Context, userID, threadID, prompt string) bool
+	PromptUser         string
+	// Async routes queue deliveries for Workers goroutines (default DefaultWorkers); more than
+	// QueueSize (default DefaultQueueSize) waiting are refused with 503.
+	Workers            int
+	QueueSize          int
+	Reactions          ReactionRecorder // optional: records Talk reactions on the bot's replies as feedback
+	Status             func() PublicStatus // optional: serves the public status page when set
+	API                http.Handler        // optional: HTTP API for the Go SDK, mounted at /api/
+	OpenAI             http.Handler        // optional: OpenAI-compatible chat completions, mounted at /v1/
+	// Health serves GET /health?detail=1 to requests HealthAuth accepts; plain /health stays a liveness check.
+	Health             func() health.HealthReport
+	HealthAuth         func(r *http.Request) bool
+	// Auth guards /chat (write scope) with API tokens; without it /chat is refused.
+	Auth               *httpauth.Authenticator
+
+	// Voice messages: when both are set, Talk audio attachments are downloaded and transcribed.
+	Transcriber        speech.Transcriber
+	FetchAttachment    func(ctx context.Context, path string) ([]byte, error)
+
+	mu        sync.Mutex
+	listening time.Time // when Run started serving; zero before and after
+	serveErr  error
+	queueOnce  sync.Once
+	queue      chan webhookJob
+	retryDelay time.Duration // replaces the routes' retry delay (tests)
+}
+
+// EventRecorder stores dynamic webhook deliveries (implemented by store.DB).
+type EventRecorder interface {
+	RecordWebhookEvent(ctx context.Context, e store.WebhookEvent) error
+}
+
+// ReactionRecorder stores emoji reactions on the bot's replies as feedback (implemented by store.DB).
+type ReactionRecorder interface {
+	RecordReaction(ctx context.Context, userID, channel, threadID, text, emoji string, removed bool) error
 }
 
 // Run starts the HTTP server and blocks.
@@ -78,16 +195,71 @@ func (s *Server) Run() error {
 	if s.ChatPath == "" {
 		s.ChatPath = "/chat"
 	}
+	if s.StatusPath == "" {
+		s.StatusPath = "/status"
+	}
 
 	mux.HandleFunc(s.HealthPath, s.handleHealth)
 	mux.HandleFunc(s.WebhookTalkPath, s.handleNextcloudTalk)
 	if s.ConfigDir != "" {
 		mux.HandleFunc("/webhook/", s.handleDynamicWebhook)
 	}
-	mux.HandleFunc(s.ChatPath, s.handleChat)
+	if s.Auth != nil {
+		mux.Handle(s.ChatPath, s.Auth.Require(store.ScopeWrite, http.HandlerFunc(s.handleChat)))
+	} else {
+		mux.HandleFunc(s.ChatPath, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		})
+	}
+	mux.HandleFunc(s.StatusPath, s.handleStatus)
+	if s.API != nil {
+		mux.Handle("/api/", s.API)
+	}
+	if s.OpenAI != nil {
+		mux.Handle("/v1/", s.OpenAI)
+	}
 
-	log.Printf("[WebhookServer] listening on %s", s.Addr)
-	return http.ListenAndServe(s.Addr, mux)
+	tlsCfg, err := s.tlsConfig()
+	if err != nil {
+		s.setServing(time.Time{}, err)
+		return err
+	}
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		s.setServing(time.Time{}, err)
+		return err
+	}
+	scheme := "http"
+	if tlsCfg != nil {
+		ln, scheme = tls.NewListener(ln, tlsCfg), "https"
+	}
+	log.Printf("[WebhookServer] listening on %s (%s)", s.Addr, scheme)
+	s.setServing(time.Now(), nil)
+	err = s.httpServer(s.withClientIP(mux)).Serve(ln)
+	s.setServing(time.Time{}, err)
+	return err
+}
+
+func (s *Server) setServing(since time.Time, err error) {
+	s.mu.Lock()
+	s.listening, s.serveErr = since, err
+	s.mu.Unlock()
+}
+
+// HealthCheck reports whether the server is listening, with the error it stopped on otherwise.
+func (s *Server) HealthCheck() health.ComponentHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := health.ComponentHealth{Name: "webhook_server", Status: "ok", LastOK: s.listening}
+	switch {
+	case !s.listening.IsZero():
+		h.Message = "listening on " + s.Addr
+	case s.serveErr != nil:
+		h.Status, h.Message = "error", s.serveErr.Error()
+	default:
+		h.Status, h.Message = "unknown", "not started"
+	}
+	return h
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -95,11 +267,34 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if d := r.URL.Query().Get("detail"); d != "" && d != "0" && d != "false" {
+		s.handleHealthDetail(w, r)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"ok"}`))
 }
 
+// handleHealthDetail serves the health registry's report to admins: 200, or 503 when a component
+// is in error, so load balancers and uptime checks can use it too.
+func (s *Server) handleHealthDetail(w http.ResponseWriter, r *http.Request) {
+	if s.Health == nil {
+		http.Error(w, "detailed health not available", http.StatusNotFound)
+		return
+	}
+	if s.HealthAuth == nil || !s.HealthAuth(r) {
+		http.Error(w, "admin token required", http.StatusUnauthorized)
+		return
+	}
+	report := s.Health()
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == "error" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
 func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -115,6 +310,11 @@ func (s *Server) handleNextcloudTalk(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if reason := s.talkAuthorized(r); reason != "" {
+		log.Printf("[WebhookServer] nextcloud talk webhook from %s: %s", r.RemoteAddr, reason)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -122,7 +322,7 @@ func (s *Server) handleNextcloudTalk(w http.ResponseWriter, r *http.Request) {
 	}
 	secret := r.Header.Get(HattieBridgeSecretHeader)
 	if s.HattieBridgeSecret == "" || secret != s.HattieBridgeSecret {
-		log.Printf("[WebhookServer] nextcloud talk webhook: invalid or missing X-HattieBridge-Secret")
+		log.Printf("[WebhookServer] nextcloud talk webhook from %s: invalid or missing X-HattieBridge-Secret", r.RemoteAddr)
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -134,6 +334,12 @@ func (s *Server) handleNextcloudTalk(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if payload.Type == "Like" || payload.Type == "Undo" {
+		s.recordReaction(r.Context(), payload)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	// Only process chat messages: type "Create" and object.name "message"
 	if payload.Type != "Create" || payload.Object == nil || payload.Object.Name != "message" {
 		w.WriteHeader(http.StatusOK)
@@ -150,10 +356,15 @@ func (s *Server) handleNextcloudTalk(w http.ResponseWriter, r *http.Request) {
 		roomToken = payload.Target.ID
 	}
 	content := ""
+	var audio *talkMessageParameter
+	var mentioned []string
 	if payload.Object.Content != "" {
 		var tc talkContent
 		if err := json.Unmarshal([]byte(payload.Object.Content), &tc); err == nil && tc.Message != "" {
-			content = tc.Message
+			content, mentioned = tc.expandMentions()
+			if f, ok := tc.audioAttachment(); ok {
+				audio = &f
+			}
 		} else {
 			content = payload.Object.Content
 		}
@@ -169,6 +380,7 @@ func (s *Server) handleNextcloudTalk(w http.ResponseWriter, r *http.Request) {
 		Channel:  NextcloudTalkChannel,
 		ThreadID: roomToken,
 		ReplyToID: roomToken,
+		Mentioned: mentioned,
 	}
 	if payload.Object.ID != "" {
 		msg.ReplyToID = roomToken + ":" + payload.Object.ID
@@ -179,6 +391,12 @@ func (s *Server) handleNextcloudTalk(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
+	if audio != nil && s.Transcriber != nil && s.FetchAttachment != nil {
+		// Download + STT can take a while; acknowledge the webhook now and push once transcribed.
+		go s.pushVoiceMessage(msg, *audio)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 	if !s.PushIngress(msg) {
 		log.Printf("[WebhookServer] ingress buffer full, dropping message")
 	} else {
@@ -187,6 +405,71 @@ func (s *Server) handleNextcloudTalk(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// recordReaction passes a Talk reaction event ("Like" when added, "Undo" of the Like when removed)
+// to the feedback store.
+func (s *Server) recordReaction(ctx context.Context, payload talkWebhook) {
+	if s.Reactions == nil || payload.Object == nil || payload.Actor == nil || payload.Target == nil {
+		return
+	}
+	emoji, message := payload.Content, payload.Object
+	if payload.Type == "Undo" {
+		if payload.Object.Object == nil {
+			return
+		}
+		emoji, message = payload.Object.Content, payload.Object.Object
+	}
+	text := message.Content
+	var tc talkContent
+	if err := json.Unmarshal([]byte(message.Content), &tc); err == nil && tc.Message != "" {
+		text = tc.Message
+	}
+	userID := normalizeNextcloudUserID(payload.Actor.ID)
+	if err := s.Reactions.RecordReaction(ctx, userID, NextcloudTalkChannel, payload.Target.ID, text, emoji, payload.Type == "Undo"); err != nil {
+		log.Printf("[WebhookServer] record reaction from %s: %v", userID, err)
+	}
+}
+
+// voiceTranscribeTimeout bounds attachment download plus transcription.
+const voiceTranscribeTimeout = 3 * time.Minute
+
+func (s *Server) pushVoiceMessage(msg gateway.Message, f talkMessageParameter) {
+	if text, err := s.transcribe(f); err != nil {
+		// Still deliver the message, so the user hears back instead of being ignored
+		log.Printf("[WebhookServer] voice message from %s: %v", msg.SenderID, err)
+		msg.Content += "\n[voice message could not be transcribed]"
+	} else {
+		msg.Content = "[Voice message] " + text
+		msg.Voice = true
+	}
+	if !s.PushIngress(msg) {
+		log.Printf("[WebhookServer] ingress buffer full, dropping voice message")
+	} else {
+		log.Printf("[WebhookServer] received Talk voice message from %s in room %s", msg.SenderID, msg.ThreadID)
+	}
+}
+
+// transcribe downloads a voice message attachment and returns its transcript.
+func (s *Server) transcribe(f talkMessageParameter) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), voiceTranscribeTimeout)
+	defer cancel()
+	path := f.Path
+	if path == "" {
+		path = "Talk/" + f.Name
+	}
+	data, err := s.FetchAttachment(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+	text, err := s.Transcriber.Transcribe(ctx, data, f.Name, f.MimeType)
+	if err != nil {
+		return "", fmt.Errorf("transcription failed: %w", err)
+	}
+	if text == "" {
+		return "", fmt.Errorf("empty transcript")
+	}
+	return text, nil
+}
+
 func normalizeNextcloudUserID(actorID string) string {
 	const prefix = "users/"
 	if strings.HasPrefix(actorID, prefix) {
@@ -271,7 +554,7 @@ func (s *Server) handleDynamicWebhook(w http.ResponseWriter, r *http.Request) {
 	}
 	headerVal := r.Header.Get(route.SecretHeader)
 	if headerVal == "" {
-		log.Printf("[WebhookServer] dynamic webhook %s: missing %s header", path, route.SecretHeader)
+		log.Printf("[WebhookServer] dynamic webhook %s from %s: missing %s header", path, r.RemoteAddr, route.SecretHeader)
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -281,7 +564,7 @@ func (s *Server) handleDynamicWebhook(w http.ResponseWriter, r *http.Request) {
 		mac.Write(body)
 		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
 		if !hmac.Equal([]byte(headerVal), []byte(expected)) {
-			log.Printf("[WebhookServer] dynamic webhook %s: HMAC validation failed", path)
+			log.Printf("[WebhookServer] dynamic webhook %s from %s: HMAC validation failed", path, r.RemoteAddr)
 			http.Error(w, "forbidden", http.StatusForbidden)
 			return
 		}
@@ -289,46 +572,74 @@ func (s *Server) handleDynamicWebhook(w http.ResponseWriter, r *http.Request) {
 		fallthrough
 	default:
 		if headerVal != secret {
-			log.Printf("[WebhookServer] dynamic webhook %s: header mismatch", path)
+			log.Printf("[WebhookServer] dynamic webhook %s from %s: header mismatch", path, r.RemoteAddr)
 			http.Error(w, "forbidden", http.StatusForbidden)
 			return
 		}
 	}
 
 	
-	// Secure Routing: Enforce TargetTool
-	if route.TargetTool == "" {
-		log.Printf("[WebhookServer] dynamic webhook %s: missing target_tool", path)
+	// Secure Routing: a route only runs the targets it names
+	if len(route.AllTargets()) == 0 {
+		log.Printf("[WebhookServer] dynamic webhook %s: no target_tool or targets", path)
 		http.Error(w, "configuration error", http.StatusInternalServerError)
 		return
 	}
-	
-	// Construct Arguments
-	argsJSON := route.TargetArgs
-	if argsJSON == "" {
-		argsJSON = "{}"
-	}
-	if strings.Contains(argsJSON, "{{payload}}") {
-		// Encode body as JSON string to safely embed in JSON template
-		b, _ := json.Marshal(string(body))
-		// Replace {{payload}} with "body_content" (including quotes)
-		argsJSON = strings.ReplaceAll(argsJSON, "{{payload}}", string(b))
-	}
-
-	// Execute Tool
-	log.Printf("[WebhookServer] triggering tool %s for webhook %s", route.TargetTool, path)
-	if s.ToolExecutor != nil {
-		result, runErr := s.ToolExecutor.Execute(r.Context(), route.TargetTool, argsJSON)
-		if runErr != nil {
-			log.Printf("[WebhookServer] tool execution failed: %v", runErr)
-			// We return 200 to webhook caller to avoid retries on internal failure? 
-			// Or 500? Use 200 to acknowledge receipt.
-		} else {
-			log.Printf("[WebhookServer] tool result: %s", result)
+
+	// Filters pick the targets this delivery triggers; an event nothing wants is acknowledged so
+	// the sender does not retry it
+	targets := newPayload(body, r.Header).jobTargets(route)
+	if len(targets) == 0 {
+		log.Printf("[WebhookServer] dynamic webhook %s: delivery matches no filter, ignoring", path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"ignored"}`))
+		return
+	}
+
+	// Idempotency: a retried delivery does not run the tool again
+	job := webhookJob{route: *route, path: path, targets: targets, routeKey: route.ID}
+	if job.routeKey == "" {
+		job.routeKey = route.Path
+	}
+	if s.Deliveries != nil && route.Idempotent() {
+		job.delivery = deliveryID(route, r.Header.Get, body)
+	}
+	if job.delivery != "" {
+		prev, claimed, err := s.Deliveries.ClaimWebhookDelivery(r.Context(), job.routeKey, job.delivery, route.DedupTTL())
+		if err != nil {
+			// Better to run a duplicate than to drop a delivery
+			log.Printf("[WebhookServer] dynamic webhook %s: delivery %s: %v", path, job.delivery, err)
+			job.delivery = ""
+		} else if !claimed {
+			log.Printf("[WebhookServer] dynamic webhook %s: duplicate delivery %s (first seen %s, %s); not running %s", path, job.delivery, prev.ReceivedAt.Format(time.RFC3339), prev.Status, targetNames(targets))
+			if route.ResponseTemplate != "" && route.OnDuplicate != "skip" && prev.Status != store.DeliveryProcessing {
+				writeToolResponse(w, route, prev.Status, prev.Result)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(duplicateResponse(route, prev))
+			return
 		}
-	} else {
-		log.Printf("[WebhookServer] dispatcher missing (ToolExecutor), dropping webhook")
 	}
 
-	w.WriteHeader(http.StatusOK)
+	if route.Async() {
+		if !s.enqueue(job) {
+			log.Printf("[WebhookServer] dynamic webhook %s: queue full, asking the sender to retry", path)
+			if job.delivery != "" {
+				// Let the retry claim the delivery again
+				_ = s.Deliveries.FinishWebhookDelivery(r.Context(), job.routeKey, job.delivery, store.DeliveryFailed, "queue full")
+			}
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "webhook queue full", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "queued", "delivery_id": job.delivery})
+		return
+	}
+	status, result := s.runWebhookJob(r.Context(), job)
+	writeToolResponse(w, route, status, result)
 }
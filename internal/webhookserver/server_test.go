@@ -0,0 +1,542 @@
+package webhookserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/core"
+	"github.com/hattiebot/hattiebot/internal/gateway"
+	"github.com/hattiebot/hattiebot/internal/health"
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+func TestDetailedHealthRequiresAdmin(t *testing.T) {
+	status := "ok"
+	s := &Server{
+		Health: func() health.HealthReport {
+			return health.HealthReport{Status: status, Components: map[string]health.ComponentHealth{"database": {Name: "database", Status: status}}}
+		},
+		HealthAuth: func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer admin" },
+	}
+	get := func(url, auth string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		if auth != "" {
+			r.Header.Set("Authorization", "Bearer "+auth)
+		}
+		w := httptest.NewRecorder()
+		s.handleHealth(w, r)
+		return w
+	}
+
+	if w := get("/health", ""); w.Code != http.StatusOK || w.Body.String() != `{"status":"ok"}` {
+		t.Fatalf("liveness = %d %s", w.Code, w.Body)
+	}
+	if w := get("/health?detail=1", "user"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("non-admin detail = %d", w.Code)
+	}
+	if w := get("/health?detail=1", "admin"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"database"`) {
+		t.Fatalf("admin detail = %d %s", w.Code, w.Body)
+	}
+	status = "error"
+	if w := get("/health?detail=1", "admin"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("failing detail = %d, want 503", w.Code)
+	}
+	if w := get("/health", ""); w.Code != http.StatusOK {
+		t.Fatalf("liveness while a component fails = %d", w.Code)
+	}
+}
+
+type countingExecutor struct{ calls int }
+
+func (e *countingExecutor) Execute(ctx context.Context, name, args string) (string, error) {
+	e.calls++
+	return fmt.Sprintf(`{"run": %d}`, e.calls), nil
+}
+
+func (e *countingExecutor) SetSpawner(core.SubmindSpawner) {}
+
+func TestDynamicWebhookSkipsDuplicateDeliveries(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+	routes := []store.WebhookRoute{
+		{Path: "/webhook/github", ID: "github", SecretHeader: "X-Secret", SecretEnv: "TEST_WEBHOOK_SECRET", AuthType: "header", TargetTool: "sync", DeliveryIDHeader: "X-GitHub-Delivery"},
+		{Path: "/webhook/stripe", ID: "stripe", SecretHeader: "X-Secret", SecretEnv: "TEST_WEBHOOK_SECRET", AuthType: "header", TargetTool: "sync", DeliveryIDPath: "data.0.id", OnDuplicate: "skip"},
+		{Path: "/webhook/plain", ID: "plain", SecretHeader: "X-Secret", SecretEnv: "TEST_WEBHOOK_SECRET", AuthType: "header", TargetTool: "sync"},
+	}
+	if err := store.SaveWebhookRoutes(dir, routes); err != nil {
+		t.Fatal(err)
+	}
+	db, err := store.Open(context.Background(), filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	exec := &countingExecutor{}
+	s := &Server{ConfigDir: dir, ToolExecutor: exec, Deliveries: db}
+	post := func(path, body string, headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("X-Secret", "s3cret")
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		s.handleDynamicWebhook(w, r)
+		return w
+	}
+
+	post("/webhook/github", `{}`, "X-GitHub-Delivery", "d-1")
+	w := post("/webhook/github", `{}`, "X-GitHub-Delivery", "d-1")
+	if exec.calls != 1 || w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"duplicate"`) || !strings.Contains(w.Body.String(), `{\"run\": 1}`) {
+		t.Fatalf("github retry: %d calls, %d %s", exec.calls, w.Code, w.Body)
+	}
+	post("/webhook/github", `{}`, "X-GitHub-Delivery", "d-2")
+	if exec.calls != 2 {
+		t.Fatalf("new delivery ID not run: %d calls", exec.calls)
+	}
+
+	post("/webhook/stripe", `{"data": [{"id": "evt_9"}]}`)
+	w = post("/webhook/stripe", `{"data": [{"id": "evt_9"}]}`)
+	if exec.calls != 3 || strings.Contains(w.Body.String(), "result") {
+		t.Fatalf("stripe retry: %d calls, %s", exec.calls, w.Body)
+	}
+
+	post("/webhook/plain", `{"id": "x"}`)
+	post("/webhook/plain", `{"id": "x"}`)
+	if exec.calls != 5 {
+		t.Fatalf("route without delivery IDs deduplicated: %d calls", exec.calls)
+	}
+}
+
+// flakyExecutor fails its first failures calls, then returns result; block holds every call.
+type flakyExecutor struct {
+	mu       sync.Mutex
+	calls    int
+	failures int
+	result   string
+	block    chan struct{}
+}
+
+func (e *flakyExecutor) Execute(ctx context.Context, name, args string) (string, error) {
+	if e.block != nil {
+		<-e.block
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls++
+	if e.calls <= e.failures {
+		return `{"error": "upstream busy"}`, nil
+	}
+	return e.result, nil
+}
+
+func (e *flakyExecutor) SetSpawner(core.SubmindSpawner) {}
+
+func (e *flakyExecutor) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func webhookServer(t *testing.T, exec core.ToolExecutor, routes ...store.WebhookRoute) (*Server, func(path, body string) *httptest.ResponseRecorder) {
+	dir := t.TempDir()
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+	for i := range routes {
+		routes[i].SecretHeader, routes[i].SecretEnv, routes[i].AuthType = "X-Secret", "TEST_WEBHOOK_SECRET", "header"
+		if routes[i].TargetType == "" {
+			routes[i].TargetTool = "sync"
+		}
+	}
+	if err := store.SaveWebhookRoutes(dir, routes); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{ConfigDir: dir, ToolExecutor: exec}
+	return s, func(path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("X-Secret", "s3cret")
+		w := httptest.NewRecorder()
+		s.handleDynamicWebhook(w, r)
+		return w
+	}
+}
+
+func TestDynamicWebhookRetriesAndTemplatesResponse(t *testing.T) {
+	exec := &flakyExecutor{failures: 2, result: `{"issue": {"key": "OPS-7", "title": "Disk \"full\""}}`}
+	s, post := webhookServer(t, exec,
+		store.WebhookRoute{Path: "/webhook/jira", ID: "jira", Retries: 2, ResponseTemplate: `{"ok": "{{status}}", "key": "{{result.issue.key}}", "title": "{{result.issue.title}}"}`},
+		store.WebhookRoute{Path: "/webhook/strict", ID: "strict", ErrorStatus: http.StatusBadGateway, ResponseTemplate: `{{result}}`, ResponseContentType: "text/plain"},
+	)
+	s.retryDelay = time.Millisecond
+	w := post("/webhook/jira", `{}`)
+	if exec.count() != 3 || w.Code != http.StatusOK || w.Body.String() != `{"ok": "ok", "key": "OPS-7", "title": "Disk \"full\""}` {
+		t.Fatalf("%d calls, %d %s", exec.count(), w.Code, w.Body)
+	}
+
+	exec.calls, exec.failures = 0, 1
+	w = post("/webhook/strict", `{}`)
+	if exec.count() != 1 || w.Code != http.StatusBadGateway || w.Body.String() != `{"error": "upstream busy"}` || w.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("failed run without retries: %d calls, %d %s", exec.count(), w.Code, w.Body)
+	}
+}
+
+func TestAsyncWebhookQueues(t *testing.T) {
+	exec := &flakyExecutor{result: `{"done": true}`, block: make(chan struct{})}
+	s, post := webhookServer(t, exec, store.WebhookRoute{Path: "/webhook/slow", ID: "slow", Mode: store.WebhookAsync})
+	s.Workers, s.QueueSize = 1, 1
+
+	if w := post("/webhook/slow", `{}`); w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), `"queued"`) {
+		t.Fatalf("first delivery = %d %s", w.Code, w.Body)
+	}
+	// The worker holds the first delivery; the second waits in the queue, the third does not fit
+	deadline := time.Now().Add(2 * time.Second)
+	for len(s.queue) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	post("/webhook/slow", `{}`)
+	if w := post("/webhook/slow", `{}`); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("full queue = %d", w.Code)
+	}
+	close(exec.block)
+	for exec.count() != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if exec.count() != 2 {
+		t.Fatalf("ran %d queued deliveries, want 2", exec.count())
+	}
+}
+
+type recordingExecutor struct{ calls []string }
+
+func (e *recordingExecutor) Execute(ctx context.Context, name, args string) (string, error) {
+	e.calls = append(e.calls, name+" "+args)
+	return `{"ok": true}`, nil
+}
+
+func (e *recordingExecutor) SetSpawner(core.SubmindSpawner) {}
+
+func TestDynamicWebhookFiltersAndMapsTargets(t *testing.T) {
+	exec := &recordingExecutor{}
+	s, post := webhookServer(t, exec, store.WebhookRoute{
+		Path: "/webhook/github", ID: "github",
+		Filter: []store.WebhookCondition{{Path: "issue.number", Exists: boolPtr(true)}},
+		Targets: []store.WebhookTarget{
+			{Tool: "create_ticket", Args: `{"title": {{payload.issue.title}}, "number": {{ payload.issue.number }}, "labels": {{payload.issue.labels}}, "due": {{payload.issue.due}}}`,
+				Filter: []store.WebhookCondition{{Path: "action", Equals: "opened"}}},
+			{Prompt: "Issue #{{payload.issue.number}} {{payload.action}}: {{payload.issue.title}}",
+				Filter: []store.WebhookCondition{{Path: "action", In: []interface{}{"opened", "reopened"}}}},
+		},
+	})
+	var prompts []string
+	s.PromptUser = "admin"
+	s.Prompt = func(ctx context.Context, userID, threadID, prompt string) bool {
+		prompts = append(prompts, userID+" "+threadID+" "+prompt)
+		return true
+	}
+
+	w := post("/webhook/github", `{"action": "opened", "issue": {"number": 7, "title": "Disk \"full\"", "labels": ["ops"]}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("opened = %d %s", w.Code, w.Body)
+	}
+	if len(exec.calls) != 1 || exec.calls[0] != `create_ticket {"title": "Disk \"full\"", "number": 7, "labels": ["ops"], "due": null}` {
+		t.Fatalf("tool calls = %q", exec.calls)
+	}
+	if len(prompts) != 1 || !strings.HasPrefix(prompts[0], "admin webhook:github ") || !strings.Contains(prompts[0], `Issue #7 opened: Disk "full"`) {
+		t.Fatalf("prompts = %q", prompts)
+	}
+
+	// Only the prompt target takes a reopened issue
+	post("/webhook/github", `{"action": "reopened", "issue": {"number": 7, "title": "Disk full"}}`)
+	if len(exec.calls) != 1 || len(prompts) != 2 {
+		t.Fatalf("reopened: %d tool calls, %d prompts", len(exec.calls), len(prompts))
+	}
+
+	// Nothing takes a closed issue, and the route filter rejects a push event
+	for _, body := range []string{`{"action": "closed", "issue": {"number": 7}}`, `{"ref": "refs/heads/main"}`, `not json`} {
+		w = post("/webhook/github", body)
+		if w.Code != http.StatusOK || w.Body.String() != `{"status":"ignored"}` {
+			t.Fatalf("%s = %d %s", body, w.Code, w.Body)
+		}
+	}
+	if len(exec.calls) != 1 || len(prompts) != 2 {
+		t.Fatalf("ignored deliveries ran targets: %d tool calls, %d prompts", len(exec.calls), len(prompts))
+	}
+}
+
+func TestPayloadMatchesHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-GitHub-Event", "issues")
+	h.Set("X-Attempt", "2")
+	p := newPayload([]byte(`{"amount": 2, "live": false}`), h)
+	for _, tc := range []struct {
+		cond store.WebhookCondition
+		want bool
+	}{
+		{store.WebhookCondition{Header: "X-GitHub-Event", Equals: "issues"}, true},
+		{store.WebhookCondition{Header: "X-GitHub-Event", NotEquals: "issues"}, false},
+		{store.WebhookCondition{Header: "X-Attempt", Equals: float64(2)}, true},
+		{store.WebhookCondition{Header: "X-Missing", Exists: boolPtr(false)}, true},
+		{store.WebhookCondition{Header: "X-Missing", Equals: "x"}, false},
+		{store.WebhookCondition{Path: "amount", Equals: "2"}, false},
+		{store.WebhookCondition{Path: "live", Equals: false}, true},
+		{store.WebhookCondition{Path: "missing", NotEquals: "x"}, true},
+	} {
+		if got := p.matches([]store.WebhookCondition{tc.cond}); got != tc.want {
+			t.Errorf("%+v = %v, want %v", tc.cond, got, tc.want)
+		}
+	}
+	if got := p.renderArgs(`{"event": {{headers.X-GitHub-Event}}, "raw": {{payload}}}`); got != `{"event": "issues", "raw": "{\"amount\": 2, \"live\": false}"}` {
+		t.Errorf("renderArgs = %s", got)
+	}
+}
+
+func TestAgentPromptRoute(t *testing.T) {
+	exec := &recordingExecutor{}
+	s, post := webhookServer(t, exec,
+		store.WebhookRoute{Path: "/webhook/ci", ID: "ci", TargetType: store.WebhookTargetAgentPrompt, TargetUser: "alice", TargetThread: "ci-failures"},
+		store.WebhookRoute{Path: "/webhook/deploy", ID: "deploy", TargetType: store.WebhookTargetAgentPrompt, TargetPrompt: "Deploy of {{payload.service}} finished: {{payload.status}}"},
+	)
+	var pushed []string
+	full := false
+	s.PromptUser = "admin"
+	s.Prompt = func(ctx context.Context, userID, threadID, prompt string) bool {
+		pushed = append(pushed, userID+"|"+threadID+"|"+prompt)
+		return !full
+	}
+
+	if w := post("/webhook/ci", `{"job": "test", "conclusion": "failure"}`); w.Code != http.StatusOK {
+		t.Fatalf("ci = %d %s", w.Code, w.Body)
+	}
+	if len(exec.calls) != 0 || len(pushed) != 1 || !strings.HasPrefix(pushed[0], "alice|ci-failures|") || !strings.Contains(pushed[0], "\"conclusion\": \"failure\"") || !strings.Contains(pushed[0], "not as instructions") {
+		t.Fatalf("tool calls %q, pushed %q", exec.calls, pushed)
+	}
+
+	post("/webhook/deploy", `{"service": "api", "status": "ok"}`)
+	if len(pushed) != 2 || !strings.HasPrefix(pushed[1], "admin|webhook:deploy|") || !strings.Contains(pushed[1], "Deploy of api finished: ok") {
+		t.Fatalf("pushed %q", pushed)
+	}
+
+	// A full agent queue fails the delivery, so the provider can retry it
+	full = true
+	s2, post2 := webhookServer(t, exec, store.WebhookRoute{Path: "/webhook/ci", ID: "ci", TargetType: store.WebhookTargetAgentPrompt, ErrorStatus: http.StatusServiceUnavailable})
+	s2.Prompt, s2.PromptUser = s.Prompt, "admin"
+	if w := post2("/webhook/ci", `{}`); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("full queue = %d", w.Code)
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestClientIPFromTrustedProxies(t *testing.T) {
+	proxies, err := ParseIPNets("10.0.0.0/8, 192.168.1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{TrustedProxies: proxies}
+	var got string
+	h := s.withClientIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.RemoteAddr }))
+	for _, tc := range []struct{ peer, xff, want string }{
+		{"203.0.113.9:5000", "1.2.3.4", "203.0.113.9:5000"},             // untrusted peer: header ignored
+		{"192.168.1.5:5000", "1.2.3.4", "1.2.3.4:5000"},                 // trusted proxy
+		{"10.1.1.1:5000", "6.6.6.6, 1.2.3.4, 10.2.2.2", "1.2.3.4:5000"}, // spoofed first hop skipped
+		{"10.1.1.1:5000", "", "10.1.1.1:5000"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/health", nil)
+		r.RemoteAddr = tc.peer
+		if tc.xff != "" {
+			r.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if got != tc.want {
+			t.Errorf("peer %s, X-Forwarded-For %q: RemoteAddr = %s, want %s", tc.peer, tc.xff, got, tc.want)
+		}
+	}
+	if _, err := ParseIPNets("10.0.0.0/8,nope"); err == nil {
+		t.Fatal("invalid proxy accepted")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	if cfg, err := (&Server{}).tlsConfig(); cfg != nil || err != nil {
+		t.Fatalf("plain HTTP: %v %v", cfg, err)
+	}
+	if _, err := (&Server{TLSCertFile: "cert.pem"}).tlsConfig(); err == nil {
+		t.Fatal("certificate without key accepted")
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCert := func(name string) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: name}, NotAfter: time.Now().Add(time.Hour)}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, _ := x509.MarshalECPrivateKey(key)
+		os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+		os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	}
+	commonName := func(cfg *tls.Config) string {
+		c, err := cfg.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, _ := x509.ParseCertificate(c.Certificate[0])
+		return leaf.Subject.CommonName
+	}
+
+	writeCert("first")
+	cfg, err := (&Server{TLSCertFile: certFile, TLSKeyFile: keyFile}).tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := commonName(cfg); cn != "first" {
+		t.Fatalf("certificate = %s", cn)
+	}
+	writeCert("renewed")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	if cn := commonName(cfg); cn != "renewed" {
+		t.Fatalf("certificate after renewal = %s", cn)
+	}
+
+	srv := (&Server{WriteTimeout: time.Second}).httpServer(http.NotFoundHandler())
+	if srv.WriteTimeout != time.Second || srv.ReadHeaderTimeout != DefaultReadHeaderTimeout || srv.MaxHeaderBytes != DefaultMaxHeaderBytes {
+		t.Fatalf("server limits = %v %v %d", srv.WriteTimeout, srv.ReadHeaderTimeout, srv.MaxHeaderBytes)
+	}
+}
+
+func TestTalkWebhookSourceRestrictions(t *testing.T) {
+	allowed, _ := ParseIPNets("10.0.0.0/8")
+	s := &Server{HattieBridgeSecret: "s3cret", TalkAllowedIPs: allowed}
+	post := func(remote string, state *tls.ConnectionState) int {
+		r := httptest.NewRequest(http.MethodPost, "/webhook/talk", strings.NewReader("not json"))
+		r.RemoteAddr, r.TLS = remote, state
+		r.Header.Set(HattieBridgeSecretHeader, "s3cret")
+		w := httptest.NewRecorder()
+		s.handleNextcloudTalk(w, r)
+		return w.Code
+	}
+	// Past the source checks, the invalid body is a 400
+	if code := post("203.0.113.9:4000", nil); code != http.StatusForbidden {
+		t.Errorf("outside allowlist = %d", code)
+	}
+	if code := post("10.1.2.3:4000", nil); code != http.StatusBadRequest {
+		t.Errorf("inside allowlist = %d", code)
+	}
+
+	s.TalkClientCAFile, s.TalkClientNames = "ca.pem", []string{"hattiebridge"}
+	verified := func(cn string) *tls.ConnectionState {
+		leaf := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}
+	}
+	if code := post("10.1.2.3:4000", nil); code != http.StatusForbidden {
+		t.Errorf("without client certificate = %d", code)
+	}
+	if code := post("10.1.2.3:4000", verified("someone-else")); code != http.StatusForbidden {
+		t.Errorf("wrong certificate name = %d", code)
+	}
+	if code := post("10.1.2.3:4000", verified("hattiebridge")); code != http.StatusBadRequest {
+		t.Errorf("verified client certificate = %d", code)
+	}
+
+	if _, err := (&Server{TalkClientCAFile: "ca.pem"}).tlsConfig(); err == nil {
+		t.Error("client CA without HTTPS accepted")
+	}
+}
+
+func TestTalkWebhookExpandsMentions(t *testing.T) {
+	var got gateway.Message
+	s := &Server{HattieBridgeSecret: "s3cret", PushIngress: func(m gateway.Message) bool { got = m; return true }}
+	content, _ := json.Marshal(talkContent{
+		Message: "{mention-user1} and {mention-call1}: lunch?",
+		Parameters: map[string]talkMessageParameter{
+			"mention-user1": {Type: "user", ID: "hattie", Name: "Hattie"},
+			"mention-call1": {Type: "call", ID: "room1", Name: "Team"},
+		},
+	})
+	body, _ := json.Marshal(talkWebhook{
+		Type:   "Create",
+		Actor:  &talkActor{ID: "users/alice"},
+		Object: &talkObject{ID: "7", Name: "message", Content: string(content)},
+		Target: &talkTarget{ID: "room1"},
+	})
+	r := httptest.NewRequest(http.MethodPost, "/webhook/talk", bytes.NewReader(body))
+	r.Header.Set(HattieBridgeSecretHeader, "s3cret")
+	s.handleNextcloudTalk(httptest.NewRecorder(), r)
+
+	if got.Content != "@Hattie and @Team: lunch?" {
+		t.Errorf("content = %q", got.Content)
+	}
+	if strings.Join(got.Mentioned, ",") != "all,hattie" {
+		t.Errorf("mentioned = %v", got.Mentioned)
+	}
+}
+
+func TestAgentPromptRunsAsTheRouteCreator(t *testing.T) {
+	s, post := webhookServer(t, &recordingExecutor{}, store.WebhookRoute{
+		Path: "/webhook/bob", ID: "bob", CreatedBy: "bob",
+		TargetType: store.WebhookTargetAgentPrompt, TargetPrompt: "New: {{payload.title}}",
+	})
+	var users []string
+	s.PromptUser = "admin"
+	s.Prompt = func(ctx context.Context, userID, threadID, prompt string) bool {
+		users = append(users, userID)
+		return true
+	}
+	if w := post("/webhook/bob", `{"title": "x"}`); w.Code != http.StatusOK {
+		t.Fatalf("delivery = %d %s", w.Code, w.Body)
+	}
+	if len(users) != 1 || users[0] != "bob" {
+		t.Errorf("prompted %q, want bob rather than the admin", users)
+	}
+}
+
+type fakeTranscriber struct {
+	text string
+	err  error
+}
+
+func (f fakeTranscriber) Transcribe(ctx context.Context, audio []byte, filename, mimeType string) (string, error) {
+	return f.text, f.err
+}
+
+func TestVoiceMessageIsDeliveredWhenTranscriptionFails(t *testing.T) {
+	fetch := func(ctx context.Context, path string) ([]byte, error) { return []byte("ogg"), nil }
+	for name, s := range map[string]*Server{
+		"download": {FetchAttachment: func(ctx context.Context, path string) ([]byte, error) { return nil, fmt.Errorf("404") }, Transcriber: fakeTranscriber{text: "hi"}},
+		"stt":      {FetchAttachment: fetch, Transcriber: fakeTranscriber{err: fmt.Errorf("quota exceeded")}},
+		"empty":    {FetchAttachment: fetch, Transcriber: fakeTranscriber{}},
+	} {
+		var got []gateway.Message
+		s.PushIngress = func(m gateway.Message) bool { got = append(got, m); return true }
+		s.pushVoiceMessage(gateway.Message{SenderID: "alice", Content: "{file}"}, talkMessageParameter{Type: "file", Name: "Voice.ogg", MimeType: "audio/ogg"})
+		if len(got) != 1 || got[0].Content != "{file}\n[voice message could not be transcribed]" || got[0].Voice {
+			t.Errorf("%s failure pushed %+v", name, got)
+		}
+	}
+
+	var got gateway.Message
+	s := &Server{FetchAttachment: fetch, Transcriber: fakeTranscriber{text: "call mum"}, PushIngress: func(m gateway.Message) bool { got = m; return true }}
+	s.pushVoiceMessage(gateway.Message{SenderID: "alice", Content: "{file}"}, talkMessageParameter{Type: "file", Name: "Voice.ogg", MimeType: "audio/ogg"})
+	if got.Content != "[Voice message] call mum" || !got.Voice {
+		t.Errorf("transcribed = %+v", got)
+	}
+}
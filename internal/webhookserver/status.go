@@ -0,0 +1,81 @@
+package webhookserver
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PublicStatus is the unauthenticated, privacy-safe status snapshot served at /status.
+// It must never include user IDs, message content, error text, or secrets.
+type PublicStatus struct {
+	Status          string          `json:"status"` // "ok", "degraded"
+	Version         string          `json:"version"`
+	StartedAt       time.Time       `json:"started_at"`
+	UptimeSeconds   int64           `json:"uptime_seconds"`
+	Channels        []ChannelStatus `json:"channels"`
+	LastSchedulerAt *time.Time      `json:"last_scheduler_tick,omitempty"` // nil before the first tick
+}
+
+// ChannelStatus is a channel's connectivity on the status page: its name and health status
+// ("ok", "degraded", "error"), without the health message, which may carry error text.
+type ChannelStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// schedulerStaleAfter marks the status degraded when the scheduler has not ticked recently.
+const schedulerStaleAfter = 5 * time.Minute
+
+var statusPage = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta http-equiv="refresh" content="60"><title>Status</title>
+<style>body{font-family:sans-serif;max-width:32em;margin:2em auto}td{padding:.2em 1em .2em 0}.ok{color:#2a7}.degraded{color:#c80}</style>
+</head><body>
+<h1 class="{{.Status}}">{{if eq .Status "ok"}}All systems running{{else}}Running with problems{{end}}</h1>
+<table>
+<tr><td>Version</td><td>{{.Version}}</td></tr>
+<tr><td>Up since</td><td>{{.StartedAt.Format "2006-01-02 15:04 MST"}}</td></tr>
+<tr><td>Channels</td><td>{{range .Channels}}<span class="{{if eq .Status "ok"}}ok{{else}}degraded{{end}}">{{.Name}}: {{.Status}}</span><br>{{else}}none{{end}}</td></tr>
+<tr><td>Scheduler</td><td>{{if .LastSchedulerAt}}last check {{.LastSchedulerAt.Format "15:04:05 MST"}}{{else}}not yet run{{end}}</td></tr>
+</table>
+</body></html>
+`))
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Status == nil {
+		http.NotFound(w, r)
+		return
+	}
+	st := s.Status()
+	if st.Status == "" {
+		st.Status = "ok"
+	}
+	if !st.StartedAt.IsZero() {
+		st.UptimeSeconds = int64(time.Since(st.StartedAt).Seconds())
+	}
+	if st.LastSchedulerAt != nil && time.Since(*st.LastSchedulerAt) > schedulerStaleAfter {
+		st.Status = "degraded"
+	}
+	if len(st.Channels) == 0 {
+		st.Status = "degraded"
+	}
+	for _, c := range st.Channels {
+		if c.Status != "ok" {
+			st.Status = "degraded"
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = statusPage.Execute(w, st)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(st)
+}
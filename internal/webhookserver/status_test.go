@@ -0,0 +1,84 @@
+package webhookserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getStatus(t *testing.T, s *Server, method, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, "/status", nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	s.handleStatus(w, r)
+	return w
+}
+
+func TestStatusNegotiatesJSONAndHTML(t *testing.T) {
+	tick := time.Now()
+	s := &Server{Status: func() PublicStatus {
+		return PublicStatus{Version: "v1.2.3", StartedAt: time.Now().Add(-time.Hour), LastSchedulerAt: &tick,
+			Channels: []ChannelStatus{{Name: "nextcloud_talk", Status: "ok"}}}
+	}}
+
+	w := getStatus(t, s, http.MethodGet, "application/json")
+	var st PublicStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("json = %s (%s)", w.Body, w.Header().Get("Content-Type"))
+	}
+	if st.Status != "ok" || st.Version != "v1.2.3" || st.UptimeSeconds < 3599 || len(st.Channels) != 1 || st.Channels[0].Status != "ok" {
+		t.Errorf("status = %+v", st)
+	}
+
+	w = getStatus(t, s, http.MethodGet, "text/html,application/xhtml+xml")
+	body := w.Body.String()
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(body, "All systems running") || !strings.Contains(body, "nextcloud_talk: ok") {
+		t.Errorf("html = %s", body)
+	}
+	if !strings.Contains(body, "last check") {
+		t.Errorf("html scheduler = %s", body)
+	}
+}
+
+func TestStatusDegraded(t *testing.T) {
+	stale := time.Now().Add(-time.Hour)
+	talk := []ChannelStatus{{Name: "nextcloud_talk", Status: "ok"}}
+	for name, st := range map[string]PublicStatus{
+		"stale scheduler": {Channels: talk, LastSchedulerAt: &stale},
+		"no channels":     {},
+		"failing channel": {Channels: []ChannelStatus{{Name: "nextcloud_talk", Status: "ok"}, {Name: "email", Status: "error"}}},
+	} {
+		st := st
+		w := getStatus(t, &Server{Status: func() PublicStatus { return st }}, http.MethodGet, "")
+		var got PublicStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Status != "degraded" {
+			t.Errorf("%s: %s", name, w.Body)
+		}
+	}
+
+	// Before the first tick the scheduler is left out, not reported as stale
+	w := getStatus(t, &Server{Status: func() PublicStatus { return PublicStatus{Channels: talk} }}, http.MethodGet, "")
+	if body := w.Body.String(); strings.Contains(body, "last_scheduler_tick") || !strings.Contains(body, `"status":"ok"`) {
+		t.Errorf("no tick = %s", body)
+	}
+	w = getStatus(t, &Server{Status: func() PublicStatus { return PublicStatus{Channels: talk} }}, http.MethodGet, "text/html")
+	if !strings.Contains(w.Body.String(), "not yet run") {
+		t.Errorf("html without a tick = %s", w.Body)
+	}
+}
+
+func TestStatusRejectsNonGET(t *testing.T) {
+	s := &Server{Status: func() PublicStatus { return PublicStatus{} }}
+	if w := getStatus(t, s, http.MethodPost, ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d", w.Code)
+	}
+	if w := getStatus(t, &Server{}, http.MethodGet, ""); w.Code != http.StatusNotFound {
+		t.Errorf("without a status func = %d", w.Code)
+	}
+}
@@ -0,0 +1,46 @@
+package webhookserver
+
+import (
+	"net"
+	"net/http"
+)
+
+// talkAuthorized checks the optional source restrictions of the Talk webhook, which apply on top
+// of the shared secret: the client address must be in TalkAllowedIPs, and with TalkClientCAFile
+// the client must present a certificate signed by that CA (and named in TalkClientNames, when
+// set). It returns the reason for a refusal, or "" when the request may proceed.
+func (s *Server) talkAuthorized(r *http.Request) string {
+	if len(s.TalkAllowedIPs) > 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if !containsIP(s.TalkAllowedIPs, net.ParseIP(host)) {
+			return "source address not allowed"
+		}
+	}
+	if s.TalkClientCAFile != "" {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			return "no verified client certificate"
+		}
+		if len(s.TalkClientNames) > 0 && !s.talkClientNamed(r) {
+			return "client certificate name not allowed"
+		}
+	}
+	return ""
+}
+
+// talkClientNamed reports whether the client certificate's common name or one of its DNS names
+// is in TalkClientNames.
+func (s *Server) talkClientNamed(r *http.Request) bool {
+	leaf := r.TLS.VerifiedChains[0][0]
+	names := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+	for _, want := range s.TalkClientNames {
+		for _, n := range names {
+			if n != "" && n == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package websearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// searxng queries the JSON API of a SearxNG instance (search.formats must include json).
+type searxng struct {
+	base   string
+	apiKey string // optional; sent as a bearer token for instances behind an auth proxy
+	client *http.Client
+}
+
+func (p *searxng) Name() string { return SearxNG }
+
+func (p *searxng) Search(ctx context.Context, query string, opts Options) ([]Result, error) {
+	q := url.Values{"q": {query}, "format": {"json"}}
+	if opts.TimeRange != "" {
+		q.Set("time_range", opts.TimeRange)
+	}
+	if opts.Language != "" {
+		q.Set("language", opts.Language)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.base+"/search?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	var out struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"publishedDate"`
+		} `json:"results"`
+	}
+	if err := do(p.client, req, SearxNG, &out); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, r := range out.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Content, Published: r.PublishedDate})
+	}
+	return collect(results, opts.Count), nil
+}
+
+// brave queries the Brave Search web API.
+type brave struct {
+	base   string
+	apiKey string
+	client *http.Client
+}
+
+func (p *brave) Name() string { return Brave }
+
+// braveFreshness maps time ranges to Brave's freshness codes.
+var braveFreshness = map[string]string{"day": "pd", "week": "pw", "month": "pm", "year": "py"}
+
+func (p *brave) Search(ctx context.Context, query string, opts Options) ([]Result, error) {
+	q := url.Values{"q": {query}, "count": {strconv.Itoa(opts.Count)}}
+	if f := braveFreshness[opts.TimeRange]; f != "" {
+		q.Set("freshness", f)
+	}
+	if opts.Language != "" {
+		q.Set("search_lang", opts.Language)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.base+"/res/v1/web/search?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Subscription-Token", p.apiKey)
+	var out struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+				Age         string `json:"age"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := do(p.client, req, Brave, &out); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, r := range out.Web.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Description, Published: r.Age})
+	}
+	return collect(results, opts.Count), nil
+}
+
+// tavily queries the Tavily search API.
+type tavily struct {
+	base   string
+	apiKey string
+	client *http.Client
+}
+
+func (p *tavily) Name() string { return Tavily }
+
+func (p *tavily) Search(ctx context.Context, query string, opts Options) ([]Result, error) {
+	body := map[string]interface{}{"query": query, "max_results": opts.Count}
+	if opts.TimeRange != "" {
+		body["time_range"] = opts.TimeRange
+	}
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.base+"/search", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	var out struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"published_date"`
+		} `json:"results"`
+	}
+	if err := do(p.client, req, Tavily, &out); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, r := range out.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Content, Published: r.PublishedDate})
+	}
+	return collect(results, opts.Count), nil
+}
@@ -0,0 +1,149 @@
+// Package websearch queries a web search provider: a self-hosted SearxNG instance, the Brave
+// Search API or Tavily. The search_web tool uses it so research does not depend on a tool the
+// agent wrote itself.
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Providers.
+const (
+	SearxNG = "searxng"
+	Brave   = "brave"
+	Tavily  = "tavily"
+)
+
+// MaxResults caps the results of one search.
+const MaxResults = 20
+
+// maxResponseBytes caps a provider's answer.
+const maxResponseBytes = 4 << 20
+
+// Result is one hit.
+type Result struct {
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+	Snippet   string `json:"snippet,omitempty"`
+	Published string `json:"published,omitempty"` // as the provider reports it, e.g. "2026-10-16" or "2 days ago"
+}
+
+// Options narrow a search.
+type Options struct {
+	Count     int    // results wanted, 1 to MaxResults
+	TimeRange string // "", "day", "week", "month" or "year"
+	Language  string // e.g. "en" or "de"; "" = the provider's default
+}
+
+// Provider runs searches.
+type Provider interface {
+	Name() string
+	Search(ctx context.Context, query string, opts Options) ([]Result, error)
+}
+
+// New returns the provider called name. baseURL is required for SearxNG and overrides the API
+// endpoint of the others (for proxies and tests); apiKey is required for Brave and Tavily.
+func New(name, baseURL, apiKey string, client *http.Client) (Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	base := strings.TrimRight(baseURL, "/")
+	switch strings.ToLower(name) {
+	case SearxNG:
+		if base == "" {
+			return nil, fmt.Errorf("searxng needs the instance URL")
+		}
+		return &searxng{base: base, apiKey: apiKey, client: client}, nil
+	case Brave:
+		if apiKey == "" {
+			return nil, fmt.Errorf("brave needs an API key")
+		}
+		if base == "" {
+			base = "https://api.search.brave.com"
+		}
+		return &brave{base: base, apiKey: apiKey, client: client}, nil
+	case Tavily:
+		if apiKey == "" {
+			return nil, fmt.Errorf("tavily needs an API key")
+		}
+		if base == "" {
+			base = "https://api.tavily.com"
+		}
+		return &tavily{base: base, apiKey: apiKey, client: client}, nil
+	case "":
+		return nil, fmt.Errorf("no search provider configured")
+	}
+	return nil, fmt.Errorf("unknown search provider %q (use searxng, brave or tavily)", name)
+}
+
+// Normalize checks opts and fills in the default count.
+func (o *Options) Normalize() error {
+	if o.Count <= 0 {
+		o.Count = 5
+	}
+	if o.Count > MaxResults {
+		o.Count = MaxResults
+	}
+	switch o.TimeRange {
+	case "", "day", "week", "month", "year":
+	default:
+		return fmt.Errorf("unknown time_range %q (use day, week, month or year)", o.TimeRange)
+	}
+	return nil
+}
+
+// do sends req and decodes the JSON answer into v.
+func do(client *http.Client, req *http.Request, provider string, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return fmt.Errorf("%s: %w", provider, err)
+	}
+	if resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 300 {
+			msg = msg[:300]
+		}
+		return fmt.Errorf("%s answered %s: %s", provider, resp.Status, msg)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%s: unexpected answer: %w", provider, err)
+	}
+	return nil
+}
+
+var tagRE = regexp.MustCompile(`<[^>]*>`)
+
+// plain strips the markup providers put in titles and snippets (e.g. <strong> around matches).
+func plain(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(tagRE.ReplaceAllString(s, ""))), " ")
+}
+
+// collect drops results without a URL and keeps at most n.
+func collect(results []Result, n int) []Result {
+	out := []Result{}
+	for _, r := range results {
+		if r.URL == "" {
+			continue
+		}
+		r.Title, r.Snippet = plain(r.Title), plain(r.Snippet)
+		out = append(out, r)
+		if len(out) == n {
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func search(t *testing.T, name, apiKey string, handler http.HandlerFunc, opts Options) []Result {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	p, err := New(name, srv.URL, apiKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := p.Search(context.Background(), "go generics", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestSearxNG(t *testing.T) {
+	results := search(t, SearxNG, "", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/search" || q.Get("q") != "go generics" || q.Get("format") != "json" || q.Get("time_range") != "week" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"results": [
+			{"title": "Tutorial: Getting started with generics", "url": "https://go.dev/doc/tutorial/generics", "content": "This tutorial introduces the basics of <b>generics</b> in Go."},
+			{"title": "No URL", "content": "dropped"},
+			{"title": "Generics &amp; you", "url": "https://example.com/g", "content": "x", "publishedDate": "2026-10-12T00:00:00"}]}`)
+	}, Options{Count: 2, TimeRange: "week"})
+	want := []Result{
+		{Title: "Tutorial: Getting started with generics", URL: "https://go.dev/doc/tutorial/generics", Snippet: "This tutorial introduces the basics of generics in Go."},
+		{Title: "Generics & you", URL: "https://example.com/g", Snippet: "x", Published: "2026-10-12T00:00:00"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestBrave(t *testing.T) {
+	results := search(t, Brave, "BSA-key", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/res/v1/web/search" || r.Header.Get("X-Subscription-Token") != "BSA-key" || q.Get("count") != "5" || q.Get("freshness") != "pm" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"web": {"results": [{"title": "Go <strong>generics</strong>", "url": "https://go.dev/blog/intro-generics", "description": "An introduction", "age": "March 22, 2022"}]}}`)
+	}, Options{TimeRange: "month"})
+	if len(results) != 1 || results[0].Title != "Go generics" || results[0].Published != "March 22, 2022" {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestTavily(t *testing.T) {
+	results := search(t, Tavily, "tvly-key", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Method != http.MethodPost || r.URL.Path != "/search" || r.Header.Get("Authorization") != "Bearer tvly-key" ||
+			body["query"] != "go generics" || body["max_results"] != float64(3) {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"results": [{"title": "Generics", "url": "https://go.dev/ref/spec", "content": "Type parameters", "score": 0.9}]}`)
+	}, Options{Count: 3})
+	if len(results) != 1 || results[0].URL != "https://go.dev/ref/spec" || results[0].Snippet != "Type parameters" {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := New(Brave, "", "", nil); err == nil {
+		t.Error("brave without key accepted")
+	}
+	if _, err := New(SearxNG, "", "", nil); err == nil {
+		t.Error("searxng without URL accepted")
+	}
+	if _, err := New("google", "", "k", nil); err == nil || !strings.Contains(err.Error(), "searxng, brave or tavily") {
+		t.Errorf("unknown provider: %v", err)
+	}
+	if err := (&Options{TimeRange: "decade"}).Normalize(); err == nil {
+		t.Error("unknown time range accepted")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "quota exceeded"}`, http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	p, _ := New(Tavily, srv.URL, "k", nil)
+	if _, err := p.Search(context.Background(), "x", Options{Count: 1}); err == nil || !strings.Contains(err.Error(), "429") || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("error answer: %v", err)
+	}
+}
@@ -0,0 +1,472 @@
+// Package worksync keeps the local workspace directory and a Nextcloud folder in two-way sync,
+// so files the agent writes show up in Nextcloud and files dropped into the folder reach tools.
+//
+// Each run compares both sides with the state recorded after the previous run (store table
+// workspace_sync): a file changed on one side is copied to the other, a file deleted on one side
+// and unchanged on the other is deleted there too (Nextcloud deletes go to the trash bin). When
+// both sides changed, the local file wins its path and the Nextcloud version is kept next to it
+// as "name (conflict <time>).ext" on both sides, so no edit is lost.
+package worksync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/config"
+	"github.com/hattiebot/hattiebot/internal/store"
+	"github.com/hattiebot/hattiebot/internal/tools/nextcloud"
+)
+
+// MaxFileSize is the largest file synced; bigger files are left where they are.
+const MaxFileSize = 100 << 20
+
+// skipDirs are directories never synced: version control, dependency caches and the like.
+var skipDirs = map[string]bool{".git": true, "node_modules": true, ".cache": true, "__pycache__": true, ".venv": true}
+
+// massDeleteMin is the number of deletions on one side from which a run that would delete more
+// than half of the tracked files stops instead (e.g. after the folder was moved away).
+const massDeleteMin = 10
+
+// ErrRemoteMissing is returned by Remote.List when the synced folder does not exist.
+var ErrRemoteMissing = errors.New("synced folder does not exist")
+
+// RemoteFile is a file in the synced folder.
+type RemoteFile struct {
+	ETag string
+	Size int64
+}
+
+// Remote is the synced folder. Paths are slash-separated and relative to the folder.
+type Remote interface {
+	String() string
+	List() (map[string]RemoteFile, error)
+	Get(rel string) ([]byte, error)
+	// Put writes a file, creating missing folders, and returns its new ETag.
+	Put(rel string, data []byte) (string, error)
+	Delete(rel string) error
+}
+
+// NextcloudRemote is a folder in the Hattie user's Nextcloud files.
+type NextcloudRemote struct {
+	Config *config.Config
+	Folder string // e.g. /HattieBot/Workspace
+}
+
+func (r *NextcloudRemote) String() string { return "nextcloud:" + r.Folder }
+
+func (r *NextcloudRemote) abs(rel string) string { return path.Join("/", r.Folder, rel) }
+
+func (r *NextcloudRemote) List() (map[string]RemoteFile, error) {
+	root := path.Clean("/" + r.Folder)
+	files, err := nextcloud.ListNextcloudTree(r.Config, root)
+	if errors.Is(err, nextcloud.ErrNotFound) {
+		return nil, ErrRemoteMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]RemoteFile{}
+	for _, f := range files {
+		if f.Dir {
+			continue
+		}
+		out[strings.TrimPrefix(strings.TrimPrefix(f.Path, root), "/")] = RemoteFile{ETag: f.ETag, Size: f.Size}
+	}
+	return out, nil
+}
+
+func (r *NextcloudRemote) Get(rel string) ([]byte, error) {
+	return nextcloud.DownloadNextcloudFile(r.Config, r.abs(rel), MaxFileSize)
+}
+
+func (r *NextcloudRemote) Put(rel string, data []byte) (string, error) {
+	return nextcloud.PutNextcloudFile(r.Config, r.abs(rel), data)
+}
+
+// Delete moves the file to the trash bin; without a trash bin it fails rather than delete for good.
+func (r *NextcloudRemote) Delete(rel string) error {
+	_, err := nextcloud.DeleteNextcloudFile(r.Config, r.abs(rel), false, false)
+	return err
+}
+
+// Report describes one sync run.
+type Report struct {
+	Remote        string    `json:"remote"`
+	Uploaded      []string  `json:"uploaded,omitempty"`
+	Downloaded    []string  `json:"downloaded,omitempty"`
+	DeletedLocal  []string  `json:"deleted_local,omitempty"`
+	DeletedRemote []string  `json:"deleted_remote,omitempty"`
+	Conflicts     []string  `json:"conflicts,omitempty"` // conflict copies created
+	Errors        []string  `json:"errors,omitempty"`    // per-file failures; the rest of the run went on
+	Tracked       int       `json:"tracked"`
+	At            time.Time `json:"at"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Changes is the number of files copied, deleted or duplicated as conflict copies.
+func (r Report) Changes() int {
+	return len(r.Uploaded) + len(r.Downloaded) + len(r.DeletedLocal) + len(r.DeletedRemote) + len(r.Conflicts)
+}
+
+// Syncer syncs Dir with Remote, keeping its state in DB.
+type Syncer struct {
+	Dir    string
+	Remote Remote
+	DB     *store.DB
+	Notify func(msg string) // told about conflicts and failures of scheduled runs
+
+	now func() time.Time
+	mu  sync.Mutex // one run at a time
+	// last is guarded by lastMu so status reads do not wait for a running sync.
+	lastMu sync.Mutex
+	last   *Report
+}
+
+type localFile struct {
+	abs string
+	sig string
+}
+
+// Run syncs both sides once.
+func (s *Syncer) Run(ctx context.Context) (Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rep := Report{Remote: s.Remote.String(), At: s.time()}
+	err := s.run(ctx, &rep)
+	if err != nil {
+		rep.Error = err.Error()
+	}
+	s.lastMu.Lock()
+	s.last = &rep
+	s.lastMu.Unlock()
+	return rep, err
+}
+
+// Last returns the most recent run, or nil.
+func (s *Syncer) Last() *Report {
+	s.lastMu.Lock()
+	defer s.lastMu.Unlock()
+	return s.last
+}
+
+func (s *Syncer) time() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func (s *Syncer) run(ctx context.Context, rep *Report) error {
+	state, err := s.DB.WorkspaceSyncEntries(ctx)
+	if err != nil {
+		return err
+	}
+	local, err := s.scanLocal()
+	if err != nil {
+		return err
+	}
+	remote, err := s.Remote.List()
+	if errors.Is(err, ErrRemoteMissing) {
+		if len(state) > 0 {
+			return fmt.Errorf("%s is gone; not deleting %d local files (move it back, or clear the sync state to start over)", s.Remote, len(state))
+		}
+		remote, err = map[string]RemoteFile{}, nil // first run: Put creates the folder
+	}
+	if err != nil {
+		return err
+	}
+	for p, f := range remote {
+		if f.Size > MaxFileSize || skipped(p) {
+			delete(remote, p)
+		}
+	}
+
+	paths := map[string]bool{}
+	for p := range local {
+		paths[p] = true
+	}
+	for p := range remote {
+		paths[p] = true
+	}
+	for p := range state {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	// Refuse runs that would wipe out one side, e.g. after the folder was emptied by mistake
+	var delLocal, delRemote int
+	for _, p := range sorted {
+		l, lok := local[p]
+		r, rok := remote[p]
+		e, known := state[p]
+		if known && lok && !rok && l.sig == e.LocalSig {
+			delLocal++
+		}
+		if known && rok && !lok && r.ETag == e.RemoteETag {
+			delRemote++
+		}
+	}
+	if n := max(delLocal, delRemote); n >= massDeleteMin && n*2 > len(state) {
+		side := "local"
+		if delRemote > delLocal {
+			side = "Nextcloud"
+		}
+		return fmt.Errorf("refusing to delete %d of %d synced %s files in one run", n, len(state), side)
+	}
+
+	for _, p := range sorted {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l, lok := local[p]
+		r, rok := remote[p]
+		e, known := state[p]
+		if err := s.syncPath(ctx, rep, p, l, lok, r, rok, e, known); err != nil {
+			rep.Errors = append(rep.Errors, p+": "+err.Error())
+		}
+	}
+	all, err := s.DB.WorkspaceSyncEntries(ctx)
+	if err == nil {
+		rep.Tracked = len(all)
+	}
+	return nil
+}
+
+func (s *Syncer) syncPath(ctx context.Context, rep *Report, p string, l localFile, lok bool, r RemoteFile, rok bool, e store.WorkspaceSyncEntry, known bool) error {
+	localChanged := lok && (!known || l.sig != e.LocalSig)
+	remoteChanged := rok && (!known || r.ETag != e.RemoteETag)
+	switch {
+	case !lok && !rok:
+		return s.DB.DeleteWorkspaceSyncEntry(ctx, p)
+	case lok && rok:
+		switch {
+		case localChanged && remoteChanged:
+			return s.resolveConflict(ctx, rep, p, l, r)
+		case localChanged:
+			return s.upload(ctx, rep, p, l)
+		case remoteChanged:
+			return s.download(ctx, rep, p, r)
+		}
+		return nil
+	case lok:
+		// Gone from Nextcloud: delete here too, unless it is new or was edited here since
+		if known && !localChanged {
+			if err := os.Remove(l.abs); err != nil {
+				return err
+			}
+			rep.DeletedLocal = append(rep.DeletedLocal, p)
+			return s.DB.DeleteWorkspaceSyncEntry(ctx, p)
+		}
+		return s.upload(ctx, rep, p, l)
+	default:
+		// Gone from the workspace: delete in Nextcloud too, unless it is new or was edited there since
+		if known && !remoteChanged {
+			if err := s.Remote.Delete(p); err != nil {
+				return err
+			}
+			rep.DeletedRemote = append(rep.DeletedRemote, p)
+			return s.DB.DeleteWorkspaceSyncEntry(ctx, p)
+		}
+		return s.download(ctx, rep, p, r)
+	}
+}
+
+// resolveConflict handles a file changed on both sides (or present on both on the first run).
+// Identical content is simply recorded; otherwise the Nextcloud version becomes a conflict copy.
+func (s *Syncer) resolveConflict(ctx context.Context, rep *Report, p string, l localFile, r RemoteFile) error {
+	theirs, err := s.Remote.Get(p)
+	if err != nil {
+		return err
+	}
+	ours, err := os.ReadFile(l.abs)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(ours, theirs) {
+		return s.DB.PutWorkspaceSyncEntry(ctx, store.WorkspaceSyncEntry{Path: p, LocalSig: l.sig, RemoteETag: r.ETag})
+	}
+	copyPath := conflictName(p, s.time())
+	abs := filepath.Join(s.Dir, filepath.FromSlash(copyPath))
+	if err := writeFile(abs, theirs); err != nil {
+		return err
+	}
+	copyLocal, err := statLocal(abs)
+	if err != nil {
+		return err
+	}
+	etag, err := s.Remote.Put(copyPath, theirs)
+	if err != nil {
+		return err
+	}
+	if err := s.DB.PutWorkspaceSyncEntry(ctx, store.WorkspaceSyncEntry{Path: copyPath, LocalSig: copyLocal.sig, RemoteETag: etag}); err != nil {
+		return err
+	}
+	rep.Conflicts = append(rep.Conflicts, copyPath)
+	return s.upload(ctx, rep, p, l)
+}
+
+func (s *Syncer) upload(ctx context.Context, rep *Report, p string, l localFile) error {
+	data, err := os.ReadFile(l.abs)
+	if err != nil {
+		return err
+	}
+	etag, err := s.Remote.Put(p, data)
+	if err != nil {
+		return err
+	}
+	rep.Uploaded = append(rep.Uploaded, p)
+	return s.DB.PutWorkspaceSyncEntry(ctx, store.WorkspaceSyncEntry{Path: p, LocalSig: l.sig, RemoteETag: etag})
+}
+
+// download copies a file from Nextcloud. It records the ETag listed at the start of the run, so
+// a change made in between is downloaded again next run.
+func (s *Syncer) download(ctx context.Context, rep *Report, p string, r RemoteFile) error {
+	data, err := s.Remote.Get(p)
+	if err != nil {
+		return err
+	}
+	abs := filepath.Join(s.Dir, filepath.FromSlash(p))
+	if err := writeFile(abs, data); err != nil {
+		return err
+	}
+	l, err := statLocal(abs)
+	if err != nil {
+		return err
+	}
+	rep.Downloaded = append(rep.Downloaded, p)
+	return s.DB.PutWorkspaceSyncEntry(ctx, store.WorkspaceSyncEntry{Path: p, LocalSig: l.sig, RemoteETag: r.ETag})
+}
+
+// scanLocal lists the workspace's files by slash-separated relative path.
+func (s *Syncer) scanLocal() (map[string]localFile, error) {
+	out := map[string]localFile{}
+	err := filepath.WalkDir(s.Dir, func(abs string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if abs == s.Dir {
+				return err
+			}
+			return nil // unreadable entries are left alone
+		}
+		if d.IsDir() {
+			if abs != s.Dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), tempPrefix) {
+			return nil // symlinks, sockets, ... and our own half-written downloads
+		}
+		rel, err := filepath.Rel(s.Dir, abs)
+		if err != nil {
+			return nil
+		}
+		f, err := statLocal(abs)
+		if err != nil || f.size > MaxFileSize {
+			return nil
+		}
+		out[filepath.ToSlash(rel)] = f.localFile
+		return nil
+	})
+	return out, err
+}
+
+type statResult struct {
+	localFile
+	size int64
+}
+
+func statLocal(abs string) (statResult, error) {
+	info, err := os.Stat(abs)
+	if err != nil {
+		return statResult{}, err
+	}
+	return statResult{localFile{abs: abs, sig: fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())}, info.Size()}, nil
+}
+
+// skipped reports whether a relative path lies in a directory that is never synced.
+func skipped(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for _, dir := range parts[:len(parts)-1] {
+		if skipDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// conflictName returns the name of the conflict copy of p: "notes (conflict 2026-03-01 1504).md".
+func conflictName(p string, at time.Time) string {
+	ext := path.Ext(p)
+	return strings.TrimSuffix(p, ext) + " (conflict " + at.Format("2006-01-02 1504") + ")" + ext
+}
+
+// tempPrefix names the temporary files of downloads in progress.
+const tempPrefix = ".sync-"
+
+// writeFile writes data through a temporary file, so tools never read a half-written file.
+func writeFile(abs string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(abs), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), abs)
+}
+
+// Start syncs every interval in the background, the first time right away.
+func (s *Syncer) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			rep, err := s.Run(ctx)
+			switch {
+			case err != nil && ctx.Err() == nil:
+				log.Printf("[WorkspaceSync] Failed: %v", err)
+				if s.Notify != nil {
+					s.Notify(fmt.Sprintf("[Workspace sync] Sync with %s failed: %v", rep.Remote, err))
+				}
+			case len(rep.Conflicts) > 0 && s.Notify != nil:
+				s.Notify(fmt.Sprintf("[Workspace sync] Files changed both in the workspace and in %s; the Nextcloud versions were kept as %s", rep.Remote, strings.Join(rep.Conflicts, ", ")))
+			case rep.Changes() > 0:
+				log.Printf("[WorkspaceSync] %d up, %d down, %d deleted locally, %d deleted in Nextcloud", len(rep.Uploaded), len(rep.Downloaded), len(rep.DeletedLocal), len(rep.DeletedRemote))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,234 @@
+package worksync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hattiebot/hattiebot/internal/store"
+)
+
+// memRemote is an in-memory Remote; every write gets a new ETag.
+type memRemote struct {
+	files   map[string]string
+	etags   map[string]string
+	n       int
+	missing bool
+}
+
+func newMemRemote() *memRemote {
+	return &memRemote{files: map[string]string{}, etags: map[string]string{}}
+}
+
+func (m *memRemote) String() string { return "mem" }
+
+func (m *memRemote) List() (map[string]RemoteFile, error) {
+	if m.missing {
+		return nil, ErrRemoteMissing
+	}
+	out := map[string]RemoteFile{}
+	for p, data := range m.files {
+		out[p] = RemoteFile{ETag: m.etags[p], Size: int64(len(data))}
+	}
+	return out, nil
+}
+
+func (m *memRemote) Get(rel string) ([]byte, error) {
+	data, ok := m.files[rel]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(data), nil
+}
+
+func (m *memRemote) Put(rel string, data []byte) (string, error) {
+	m.set(rel, string(data))
+	return m.etags[rel], nil
+}
+
+func (m *memRemote) Delete(rel string) error {
+	delete(m.files, rel)
+	delete(m.etags, rel)
+	return nil
+}
+
+func (m *memRemote) set(rel, data string) {
+	m.n++
+	m.files[rel] = data
+	m.etags[rel] = fmt.Sprint("e", m.n)
+}
+
+func newTestSyncer(t *testing.T) (*Syncer, *memRemote) {
+	t.Helper()
+	db, err := store.Open(context.Background(), filepath.Join(t.TempDir(), "hattiebot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	remote := newMemRemote()
+	s := &Syncer{Dir: t.TempDir(), Remote: remote, DB: db}
+	s.now = func() time.Time { return time.Date(2026, 10, 17, 15, 4, 0, 0, time.UTC) }
+	return s, remote
+}
+
+// writeLocal writes a workspace file with a distinct mtime, so edits are seen even within one clock tick.
+func writeLocal(t *testing.T, s *Syncer, rel, data string) {
+	t.Helper()
+	abs := filepath.Join(s.Dir, filepath.FromSlash(rel))
+	os.MkdirAll(filepath.Dir(abs), 0755)
+	if err := os.WriteFile(abs, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(time.Duration(len(data)+1) * time.Second)
+	os.Chtimes(abs, mtime, mtime)
+}
+
+func readLocal(s *Syncer, rel string) string {
+	b, err := os.ReadFile(filepath.Join(s.Dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return "<missing>"
+	}
+	return string(b)
+}
+
+func run(t *testing.T, s *Syncer) Report {
+	t.Helper()
+	rep, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Errors) > 0 {
+		t.Fatalf("errors: %v", rep.Errors)
+	}
+	return rep
+}
+
+func TestSyncBothWays(t *testing.T) {
+	s, remote := newTestSyncer(t)
+	writeLocal(t, s, "report.md", "draft")
+	writeLocal(t, s, "node_modules/x/index.js", "skip me")
+	remote.set("Inbox/data.csv", "a,b")
+	remote.set("same.txt", "same")
+	writeLocal(t, s, "same.txt", "same")
+
+	rep := run(t, s)
+	if !reflect.DeepEqual(rep.Uploaded, []string{"report.md"}) || !reflect.DeepEqual(rep.Downloaded, []string{"Inbox/data.csv"}) {
+		t.Fatalf("first run: up %v, down %v", rep.Uploaded, rep.Downloaded)
+	}
+	if len(rep.Conflicts) != 0 || rep.Tracked != 3 {
+		t.Errorf("identical file on both sides: conflicts %v, tracked %d", rep.Conflicts, rep.Tracked)
+	}
+	if remote.files["report.md"] != "draft" || readLocal(s, "Inbox/data.csv") != "a,b" {
+		t.Error("files not copied")
+	}
+	if _, ok := remote.files["node_modules/x/index.js"]; ok {
+		t.Error("skipped directory synced")
+	}
+	if rep := run(t, s); rep.Changes() != 0 {
+		t.Errorf("second run changed %+v", rep)
+	}
+
+	// Edits flow both ways
+	writeLocal(t, s, "report.md", "final version")
+	remote.set("Inbox/data.csv", "a,b,c")
+	rep = run(t, s)
+	if !reflect.DeepEqual(rep.Uploaded, []string{"report.md"}) || !reflect.DeepEqual(rep.Downloaded, []string{"Inbox/data.csv"}) {
+		t.Fatalf("edits: up %v, down %v", rep.Uploaded, rep.Downloaded)
+	}
+	if remote.files["report.md"] != "final version" || readLocal(s, "Inbox/data.csv") != "a,b,c" {
+		t.Error("edits not copied")
+	}
+
+	// Deletions flow both ways
+	os.Remove(filepath.Join(s.Dir, "report.md"))
+	remote.Delete("Inbox/data.csv")
+	rep = run(t, s)
+	if !reflect.DeepEqual(rep.DeletedRemote, []string{"report.md"}) || !reflect.DeepEqual(rep.DeletedLocal, []string{"Inbox/data.csv"}) {
+		t.Fatalf("deletions: remote %v, local %v", rep.DeletedRemote, rep.DeletedLocal)
+	}
+	if _, ok := remote.files["report.md"]; ok || readLocal(s, "Inbox/data.csv") != "<missing>" {
+		t.Error("files not deleted")
+	}
+	if rep.Tracked != 1 {
+		t.Errorf("tracked = %d", rep.Tracked)
+	}
+}
+
+func TestSyncDeletedButEditedElsewhereIsKept(t *testing.T) {
+	s, remote := newTestSyncer(t)
+	writeLocal(t, s, "a.txt", "one")
+	run(t, s)
+
+	os.Remove(filepath.Join(s.Dir, "a.txt"))
+	remote.set("a.txt", "edited in Nextcloud")
+	rep := run(t, s)
+	if len(rep.DeletedRemote) != 0 || readLocal(s, "a.txt") != "edited in Nextcloud" {
+		t.Errorf("edited file deleted: %+v", rep)
+	}
+}
+
+func TestSyncConflict(t *testing.T) {
+	s, remote := newTestSyncer(t)
+	writeLocal(t, s, "notes.md", "v1")
+	run(t, s)
+
+	writeLocal(t, s, "notes.md", "local edit")
+	remote.set("notes.md", "remote edit")
+	rep := run(t, s)
+	copyName := "notes (conflict 2026-10-17 1504).md"
+	if !reflect.DeepEqual(rep.Conflicts, []string{copyName}) {
+		t.Fatalf("conflicts = %v", rep.Conflicts)
+	}
+	if readLocal(s, "notes.md") != "local edit" || remote.files["notes.md"] != "local edit" {
+		t.Error("local version does not win the path")
+	}
+	if readLocal(s, copyName) != "remote edit" || remote.files[copyName] != "remote edit" {
+		t.Error("conflict copy missing on one side")
+	}
+	if rep := run(t, s); rep.Changes() != 0 {
+		t.Errorf("run after conflict changed %+v", rep)
+	}
+}
+
+func TestSyncRefusesMassDelete(t *testing.T) {
+	s, remote := newTestSyncer(t)
+	for i := 0; i < 12; i++ {
+		writeLocal(t, s, fmt.Sprintf("f%02d.txt", i), "x")
+	}
+	run(t, s)
+
+	for p := range remote.files {
+		remote.Delete(p)
+	}
+	_, err := s.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "refusing to delete 12") {
+		t.Fatalf("err = %v", err)
+	}
+	entries, _ := os.ReadDir(s.Dir)
+	if len(entries) != 12 {
+		t.Errorf("%d local files left", len(entries))
+	}
+	if last := s.Last(); last == nil || last.Error == "" {
+		t.Errorf("last = %+v", last)
+	}
+
+	remote.missing = true
+	if _, err := s.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "is gone") {
+		t.Errorf("missing folder: %v", err)
+	}
+}
+
+func TestConflictName(t *testing.T) {
+	at := time.Date(2026, 3, 1, 15, 4, 0, 0, time.UTC)
+	if got := conflictName("a/notes.md", at); got != "a/notes (conflict 2026-03-01 1504).md" {
+		t.Errorf("got %q", got)
+	}
+	if got := conflictName("README", at); got != "README (conflict 2026-03-01 1504)" {
+		t.Errorf("got %q", got)
+	}
+}
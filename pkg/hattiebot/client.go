@@ -0,0 +1,148 @@
+// Package hattiebot is a Go client for a running HattieBot's HTTP API.
+//
+// Other programs use it to message the bot, stream its replies, and manage tools and
+// schedules. Requests authenticate with a bearer token created by an admin with the
+// manage_api_tokens tool, and act as that token's user: the same trust level, role,
+// tool policies and audit log apply as in chat.
+//
+//	c := hattiebot.New("http://hattiebot.lan:8080", os.Getenv("HATTIEBOT_TOKEN"))
+//	reply, err := c.Send(ctx, "What's on my calendar today?")
+package hattiebot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// APIPrefix is the path under which the server mounts the API.
+const APIPrefix = "/api/v1"
+
+// Client talks to the HattieBot HTTP API. It is safe for concurrent use.
+type Client struct {
+	BaseURL string       // e.g. http://localhost:8080 (without /api/v1)
+	Token   string       // API token (hb_…)
+	HTTP    *http.Client // default http.DefaultClient; replies can take minutes, so avoid short timeouts
+}
+
+// New returns a client for the bot at baseURL, authenticating with token.
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), Token: token}
+}
+
+// APIError is returned for non-2xx responses.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("hattiebot: HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return http.DefaultClient
+}
+
+// newRequest builds an authenticated request; body (if not nil) is sent as JSON.
+func (c *Client) newRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.BaseURL, "/")+APIPrefix+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	return req, nil
+}
+
+// do sends the request and decodes a JSON response into out (if not nil).
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var e ErrorResponse
+	if json.Unmarshal(b, &e) != nil || e.Error == "" {
+		e.Error = strings.TrimSpace(string(b))
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: e.Error}
+}
+
+// Send sends a message in the default API thread and waits for the bot's reply.
+func (c *Client) Send(ctx context.Context, content string) (*Reply, error) {
+	return c.SendMessage(ctx, MessageRequest{Content: content})
+}
+
+// SendMessage sends a message and waits for the bot's reply.
+func (c *Client) SendMessage(ctx context.Context, msg MessageRequest) (*Reply, error) {
+	var reply Reply
+	if err := c.do(ctx, http.MethodPost, "/messages", msg, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// CallTool runs a tool with args (a value marshalled to a JSON object, or nil) and returns its JSON output.
+// Tool errors ({"error": "..."}) are returned as an *APIError with status 422.
+func (c *Client) CallTool(ctx context.Context, name string, args interface{}) (json.RawMessage, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	var res ToolResult
+	if err := c.do(ctx, http.MethodPost, "/tools/"+url.PathEscape(name)+"/call", args, &res); err != nil {
+		return nil, err
+	}
+	return res.Output, nil
+}
+
+// callToolInto runs a tool and decodes its output into out.
+func (c *Client) callToolInto(ctx context.Context, name string, args, out interface{}) error {
+	raw, err := c.CallTool(ctx, name, args)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("hattiebot: decode %s output: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package hattiebot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Stream is a streamed reply: status events while the bot works, then one reply (or error) event.
+type Stream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	done    bool
+}
+
+// Stream sends a message and returns its events as the bot produces them. Close the stream when done.
+func (c *Client) Stream(ctx context.Context, msg MessageRequest) (*Stream, error) {
+	req, err := c.newRequest(ctx, http.MethodPost, "/messages", msg)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	return &Stream{body: resp.Body, scanner: sc}, nil
+}
+
+// Next returns the next event. After the final reply or error event it returns io.EOF.
+func (s *Stream) Next() (*Event, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue // event names, comments (keep-alives) and blank separators
+		}
+		var ev Event
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &ev); err != nil {
+			return nil, err
+		}
+		if ev.Type == EventReply || ev.Type == EventError {
+			s.done = true
+		}
+		return &ev, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// Reply reads the remaining events, calling onStatus (if not nil) for each status update, and returns the final reply.
+func (s *Stream) Reply(onStatus func(string)) (*Reply, error) {
+	for {
+		ev, err := s.Next()
+		if err != nil {
+			return nil, err
+		}
+		switch ev.Type {
+		case EventReply:
+			return &Reply{Content: ev.Content, ThreadID: ev.ThreadID}, nil
+		case EventError:
+			return nil, errors.New("hattiebot: " + ev.Content)
+		default:
+			if onStatus != nil {
+				onStatus(ev.Content)
+			}
+		}
+	}
+}
+
+// Close releases the connection. The bot finishes the turn even if the stream is closed early.
+func (s *Stream) Close() error {
+	s.done = true
+	return s.body.Close()
+}
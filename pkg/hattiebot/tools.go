@@ -0,0 +1,152 @@
+package hattiebot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// ListTools returns the built-in and registered tools.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	if err := c.do(ctx, http.MethodGet, "/tools", nil, &tools); err != nil {
+		return nil, err
+	}
+	return tools, nil
+}
+
+// RegisterTool registers a tool binary on the bot's host, or a new version of it with ForceUpdate.
+// It needs a user allowed to run register_tool (restricted policy).
+func (c *Client) RegisterTool(ctx context.Context, req RegisterToolRequest) (json.RawMessage, error) {
+	return c.CallTool(ctx, "register_tool", req)
+}
+
+// DeleteTool deletes a registered tool (admin only).
+func (c *Client) DeleteTool(ctx context.Context, name string) error {
+	return c.callToolInto(ctx, "delete_tool", map[string]string{"name": name}, nil)
+}
+
+// ListSchedules returns the token user's active schedules.
+func (c *Client) ListSchedules(ctx context.Context) ([]Schedule, error) {
+	var plans []Schedule
+	if err := c.callToolInto(ctx, "manage_schedule", map[string]string{"action": "list"}, &plans); err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// CreateSchedule creates a reminder or recurring task for the token user.
+func (c *Client) CreateSchedule(ctx context.Context, req ScheduleRequest) (*ScheduleCreated, error) {
+	args := struct {
+		Action string `json:"action"`
+		ScheduleRequest
+	}{"create", req}
+	var out ScheduleCreated
+	if err := c.callToolInto(ctx, "manage_schedule", args, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteSchedule deletes a schedule.
+func (c *Client) DeleteSchedule(ctx context.Context, id int64) error {
+	return c.callToolInto(ctx, "manage_schedule", map[string]interface{}{"action": "delete", "id": id}, nil)
+}
+
+// PauseSchedule pauses a schedule.
+func (c *Client) PauseSchedule(ctx context.Context, id int64) error {
+	return c.callToolInto(ctx, "manage_schedule", map[string]interface{}{"action": "pause", "id": id}, nil)
+}
+
+// ScheduleHistory returns the recorded results of a schedule's runs, newest first (limit 0 = server default).
+func (c *Client) ScheduleHistory(ctx context.Context, id int64, limit int) ([]ScheduleRun, error) {
+	var out struct {
+		Runs []ScheduleRun `json:"runs"`
+	}
+	args := map[string]interface{}{"action": "history", "id": id, "limit": limit}
+	if err := c.callToolInto(ctx, "manage_schedule", args, &out); err != nil {
+		return nil, err
+	}
+	return out.Runs, nil
+}
+
+// ThreadMessages returns the recent user and assistant messages of a thread as the bot sees them,
+// oldest first (limit 0 = server default).
+func (c *Client) ThreadMessages(ctx context.Context, threadID string, limit int) ([]ThreadMessage, error) {
+	var out struct {
+		Messages []ThreadMessage `json:"messages"`
+	}
+	args := map[string]interface{}{"action": "list", "thread_id": threadID, "limit": limit}
+	if err := c.callToolInto(ctx, "branch_thread", args, &out); err != nil {
+		return nil, err
+	}
+	return out.Messages, nil
+}
+
+// BranchThread starts a new thread that continues threadID from messageID; send messages with
+// the returned ThreadID to continue it.
+func (c *Client) BranchThread(ctx context.Context, threadID string, messageID int64) (*Branch, error) {
+	var out Branch
+	args := map[string]interface{}{"action": "branch", "thread_id": threadID, "message_id": messageID}
+	if err := c.callToolInto(ctx, "branch_thread", args, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Regenerate answers the thread's last message again, dropping the previous reply from the
+// conversation.
+func (c *Client) Regenerate(ctx context.Context, threadID string) (*Reply, error) {
+	return c.SendMessage(ctx, MessageRequest{Content: "/regenerate", ThreadID: threadID})
+}
+
+// Logs returns recent log entries, newest first. It needs a user allowed to run read_logs.
+func (c *Client) Logs(ctx context.Context, q LogQuery) ([]LogEntry, error) {
+	var out struct {
+		Logs []LogEntry `json:"logs"`
+	}
+	if err := c.callToolInto(ctx, "read_logs", q, &out); err != nil {
+		return nil, err
+	}
+	return out.Logs, nil
+}
+
+// WebhookRoutes returns the configured webhook routes.
+func (c *Client) WebhookRoutes(ctx context.Context) ([]WebhookRoute, error) {
+	var out struct {
+		Routes []WebhookRoute `json:"routes"`
+	}
+	if err := c.callToolInto(ctx, "list_webhook_routes", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Routes, nil
+}
+
+// AddWebhookRoute adds a webhook route; route holds the add_webhook_route arguments (a struct, a
+// map or a json.RawMessage).
+func (c *Client) AddWebhookRoute(ctx context.Context, route interface{}) (json.RawMessage, error) {
+	return c.CallTool(ctx, "add_webhook_route", route)
+}
+
+// RemoveWebhookRoute removes the webhook route with this path or ID (restricted policy).
+func (c *Client) RemoveWebhookRoute(ctx context.Context, pathOrID string) error {
+	return c.callToolInto(ctx, "remove_webhook_route", map[string]string{"path_or_id": pathOrID}, nil)
+}
+
+// Backup backs up the database and config directory now (admin only).
+func (c *Client) Backup(ctx context.Context) (*Backup, error) {
+	var out Backup
+	if err := c.callToolInto(ctx, "backup_now", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListBackups returns the stored backups and the last backup run (admin only).
+func (c *Client) ListBackups(ctx context.Context) (*Backups, error) {
+	var out Backups
+	if err := c.callToolInto(ctx, "backup_now", map[string]bool{"list": true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,182 @@
+package hattiebot
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Wire types of the HTTP API (/api/v1). The server encodes these same types.
+
+// MessageRequest is the body of POST /api/v1/messages.
+type MessageRequest struct {
+	Content string `json:"content"`
+	// ThreadID selects the conversation; messages in the same thread share history.
+	// Empty uses the token user's default API thread.
+	ThreadID string `json:"thread_id,omitempty"`
+}
+
+// Reply is the bot's final answer to a message.
+type Reply struct {
+	Content  string `json:"content"`
+	ThreadID string `json:"thread_id"`
+}
+
+// Event types of a streamed reply.
+const (
+	EventStatus = "status" // intermediate progress ("Running web_search…"); may repeat
+	EventReply  = "reply"  // final answer; last event of the stream
+	EventError  = "error"  // the turn could not be delivered; last event of the stream
+)
+
+// Event is one server-sent event of a streamed reply.
+type Event struct {
+	Type     string `json:"type"`
+	Content  string `json:"content"`
+	ThreadID string `json:"thread_id,omitempty"`
+}
+
+// Tool describes a tool the bot can run: built in, or registered (built by the bot itself).
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Builtin     bool            `json:"builtin"`
+	Policy      string          `json:"policy,omitempty"`  // built-in tools: "", restricted, admin_only, operator, owner_only
+	Status      string          `json:"status,omitempty"`  // registered tools: active, broken, pending_repair, deprecated
+	Version     int             `json:"version,omitempty"` // registered tools
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+}
+
+// ToolResult is the response of POST /api/v1/tools/{name}/call.
+type ToolResult struct {
+	// Output is the tool's JSON output (non-JSON output is returned as a JSON string).
+	Output json.RawMessage `json:"output"`
+}
+
+// ErrorResponse is the body of every non-2xx API response.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// RegisterToolRequest registers a tool binary (see the register_tool tool).
+type RegisterToolRequest struct {
+	Name        string `json:"name"`
+	BinaryPath  string `json:"binary_path"`
+	Description string `json:"description,omitempty"`
+	InputSchema string `json:"input_schema,omitempty"`
+	SourceDir   string `json:"source_dir,omitempty"`
+	ForceUpdate bool   `json:"force_update,omitempty"` // register a new version of an existing tool
+}
+
+// Schedule is a scheduled reminder or task.
+type Schedule struct {
+	ID            int64      `json:"id"`
+	UserID        string     `json:"user_id"`
+	Description   string     `json:"description"`
+	ActionType    string     `json:"action_type"`
+	ActionPayload string     `json:"action_payload"`
+	ScheduleType  string     `json:"schedule_type"`
+	ScheduleValue string     `json:"schedule_value"`
+	Timezone      string     `json:"timezone,omitempty"`
+	NextRunAt     *time.Time `json:"next_run_at"`
+	LastRunAt     *time.Time `json:"last_run_at"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// ScheduleRequest creates a schedule (see the manage_schedule tool for the accepted values).
+type ScheduleRequest struct {
+	Description  string                 `json:"description"`
+	ActionType   string                 `json:"action_type,omitempty"` // remind (default), execute_tool, agent_prompt
+	ScheduleType string                 `json:"schedule_type"`         // once, hourly, daily, weekdays, weekly, monthly
+	RunAt        string                 `json:"run_at"`                // e.g. "2h", "tomorrow 09:00", or "mon 09:00" for weekly
+	Timezone     string                 `json:"timezone,omitempty"`    // IANA zone, default server local
+	Prompt       string                 `json:"prompt,omitempty"`      // agent_prompt
+	Autonomous   bool                   `json:"autonomous,omitempty"`  // agent_prompt: reply only via notify_user
+	Tool         string                 `json:"tool,omitempty"`        // execute_tool
+	ToolArgs     map[string]interface{} `json:"tool_args,omitempty"`   // execute_tool
+}
+
+// ScheduleCreated is the result of creating a schedule.
+type ScheduleCreated struct {
+	ID      int64  `json:"id"`
+	Status  string `json:"status"`
+	NextRun string `json:"next_run"`
+}
+
+// ScheduleRun is the recorded result of one run of a scheduled task.
+type ScheduleRun struct {
+	ID               int64      `json:"id"`
+	PlanID           int64      `json:"plan_id"`
+	Status           string     `json:"status"`
+	Summary          string     `json:"summary,omitempty"`
+	Artifacts        []string   `json:"artifacts,omitempty"`
+	NextSuggestedRun *time.Time `json:"next_suggested_run,omitempty"`
+	Reported         bool       `json:"reported"` // false = recorded by the bot, not filed by the agent
+	StartedAt        time.Time  `json:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+}
+
+// ThreadMessage is a user or assistant message of a thread, shortened, with the ID a branch can
+// start from.
+type ThreadMessage struct {
+	ID        int64  `json:"id"`
+	ThreadID  string `json:"thread_id"` // an ancestor's ID for messages a branch inherits
+	Role      string `json:"role"`
+	SenderID  string `json:"sender_id"`
+	CreatedAt string `json:"created_at"`
+	Content   string `json:"content"`
+}
+
+// Branch is a thread created by BranchThread.
+type Branch struct {
+	ThreadID        string `json:"thread_id"`
+	ParentThreadID  string `json:"parent_thread_id"`
+	ParentMessageID int64  `json:"parent_message_id"`
+}
+
+// LogQuery selects log entries (see the read_logs tool). Zero values match everything.
+type LogQuery struct {
+	Level     string `json:"level,omitempty"`     // error, warn or info
+	Component string `json:"component,omitempty"` // e.g. agent, gateway, scheduler, llm
+	Limit     int    `json:"limit,omitempty"`     // default 50, max 200
+}
+
+// LogEntry is one entry of the bot's log.
+type LogEntry struct {
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Component string    `json:"component"`
+	Message   string    `json:"message"`
+}
+
+// WebhookRoute is a configured webhook endpoint (see the add_webhook_route tool for every field).
+type WebhookRoute struct {
+	Path         string `json:"path"`
+	ID           string `json:"id"`
+	AuthType     string `json:"auth_type"`
+	SecretSource string `json:"secret_source,omitempty"`
+	TargetType   string `json:"target_type,omitempty"`
+	TargetTool   string `json:"target_tool,omitempty"`
+	TargetPrompt string `json:"target_prompt,omitempty"`
+	// Targets are the route's targets when it has several; see add_webhook_route.
+	Targets []json.RawMessage `json:"targets,omitempty"`
+}
+
+// Backup is the result of a backup run.
+type Backup struct {
+	Name      string    `json:"name"`
+	Target    string    `json:"target"`
+	SizeBytes int64     `json:"size_bytes"`
+	Files     int       `json:"files"` // config files included besides the database
+	Rotated   []string  `json:"rotated,omitempty"`
+	At        time.Time `json:"at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Backups lists the stored backups and the last run.
+type Backups struct {
+	Target  string   `json:"target"`
+	Backups []string `json:"backups"`
+	Last    *Backup  `json:"last"`
+}
@@ -0,0 +1,32 @@
+#!/usr/bin/env bash
+# End-to-end test: build and start the e2e stack (bot + mock LLM + Nextcloud Talk stub),
+# run the e2e Go tests against it, and tear it down. Bot logs are printed on failure.
+#
+# Usage:
+#   ./scripts/e2e.sh
+#   KEEP_STACK=1 ./scripts/e2e.sh   # leave the stack running afterwards for debugging
+
+set -euo pipefail
+cd "$(dirname "$0")/.."
+
+COMPOSE=(docker compose -f e2e/docker-compose.yml)
+export E2E_BOT_PORT="${E2E_BOT_PORT:-18080}" E2E_NEXTCLOUD_PORT="${E2E_NEXTCLOUD_PORT:-18081}" E2E_LLM_PORT="${E2E_LLM_PORT:-18082}"
+export HATTIEBOT_E2E_BOT_URL="http://localhost:$E2E_BOT_PORT"
+export HATTIEBOT_E2E_NEXTCLOUD_URL="http://localhost:$E2E_NEXTCLOUD_PORT"
+export HATTIEBOT_E2E_LLM_URL="http://localhost:$E2E_LLM_PORT"
+
+cleanup() {
+  if [ "${KEEP_STACK:-}" != "1" ]; then
+    "${COMPOSE[@]}" down -v --remove-orphans >/dev/null 2>&1 || true
+  fi
+}
+trap cleanup EXIT
+
+"${COMPOSE[@]}" down -v --remove-orphans >/dev/null 2>&1 || true
+"${COMPOSE[@]}" up -d --build
+
+if ! go test -tags e2e -count=1 -v ./e2e/; then
+  echo "--- hattiebot logs ---"
+  "${COMPOSE[@]}" logs --no-color hattiebot | tail -200
+  exit 1
+fi